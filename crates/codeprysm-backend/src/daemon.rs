@@ -0,0 +1,438 @@
+//! Daemon for sharing one loaded index between processes.
+//!
+//! A [`DaemonServer`] owns a [`LocalBackend`] and answers [`Backend`] calls
+//! over a Unix socket in the index directory, so short-lived clients skip
+//! loading the graph and embedding models on every invocation.
+//! [`DaemonBackend`] is the client side and implements [`Backend`] itself,
+//! so callers can use either backend interchangeably.
+//!
+//! The protocol is newline-delimited JSON: each [`DaemonRequest`] is answered
+//! by one [`DaemonResponse`] on the same connection.
+
+use std::future::Future;
+use std::path::{Path, PathBuf};
+use std::sync::Arc;
+use std::time::SystemTime;
+
+use async_trait::async_trait;
+use codeprysm_search::ProviderStatus;
+use serde::de::DeserializeOwned;
+use serde::{Deserialize, Serialize};
+use tokio::io::{AsyncBufReadExt, AsyncWriteExt, BufReader};
+use tokio::net::{UnixListener, UnixStream};
+use tokio::sync::{Mutex, Notify};
+use tracing::{debug, info};
+
+use crate::error::BackendError;
+use crate::local::LocalBackend;
+use crate::traits::Backend;
+use crate::types::{EdgeInfo, GraphStats, IndexStatus, NodeInfo, SearchOptions, SearchResult};
+
+/// Socket file name inside the index directory
+const SOCKET_FILE: &str = "daemon.sock";
+
+/// Path of the daemon socket for an index directory.
+pub fn socket_path(prism_dir: &Path) -> PathBuf {
+    prism_dir.join(SOCKET_FILE)
+}
+
+/// A request sent to the daemon, one per line.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(tag = "method", content = "params", rename_all = "snake_case")]
+pub enum DaemonRequest {
+    Search {
+        query: String,
+        limit: usize,
+        options: Option<SearchOptions>,
+    },
+    GetNode {
+        node_id: String,
+    },
+    GetConnectedNodes {
+        node_id: String,
+        edge_type: Option<String>,
+        direction: String,
+    },
+    GetEdges {
+        node_id: String,
+        edge_type: Option<String>,
+        direction: String,
+    },
+    IndexStatus,
+    GraphStats,
+    ReadCode {
+        node_id: String,
+        context_lines: usize,
+    },
+    FindNodes {
+        pattern: String,
+        node_type: Option<String>,
+        limit: usize,
+    },
+    Index {
+        force: bool,
+    },
+    Sync,
+    RepoId,
+    HealthCheck,
+    CheckProvider,
+    /// Stop the daemon after answering
+    Shutdown,
+}
+
+/// The daemon's answer to a request.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum DaemonResponse {
+    /// The method's return value
+    Ok(serde_json::Value),
+    /// The method's error message
+    Error(String),
+}
+
+/// Serves a [`LocalBackend`] to daemon clients.
+///
+/// The graph is reloaded when the index manifest changes, so clients see
+/// the results of `codeprysm update` without restarting the daemon.
+pub struct DaemonServer {
+    backend: Arc<LocalBackend>,
+    manifest_modified: Mutex<Option<SystemTime>>,
+    shutdown: Notify,
+}
+
+impl DaemonServer {
+    /// Create a server for a loaded backend.
+    pub fn new(backend: Arc<LocalBackend>) -> Self {
+        let manifest_modified = manifest_modified(&backend);
+        Self {
+            backend,
+            manifest_modified: Mutex::new(manifest_modified),
+            shutdown: Notify::new(),
+        }
+    }
+
+    /// Accept clients until one sends [`DaemonRequest::Shutdown`] or `signal`
+    /// completes.
+    pub async fn serve(
+        self: Arc<Self>,
+        listener: UnixListener,
+        signal: impl Future<Output = ()>,
+    ) -> std::io::Result<()> {
+        tokio::pin!(signal);
+        loop {
+            tokio::select! {
+                accepted = listener.accept() => {
+                    let (stream, _) = accepted?;
+                    let server = Arc::clone(&self);
+                    tokio::spawn(async move {
+                        if let Err(e) = server.handle_connection(stream).await {
+                            debug!("Daemon connection failed: {}", e);
+                        }
+                    });
+                }
+                _ = self.shutdown.notified() => break,
+                _ = &mut signal => break,
+            }
+        }
+        Ok(())
+    }
+
+    /// Answer requests on one connection until the client hangs up
+    async fn handle_connection(&self, stream: UnixStream) -> std::io::Result<()> {
+        let (reader, mut writer) = stream.into_split();
+        let mut lines = BufReader::new(reader).lines();
+        while let Some(line) = lines.next_line().await? {
+            let request = serde_json::from_str::<DaemonRequest>(&line);
+            let shutdown = matches!(request, Ok(DaemonRequest::Shutdown));
+            let response = match request {
+                Ok(request) => self.dispatch(request).await,
+                Err(e) => DaemonResponse::Error(format!("invalid request: {}", e)),
+            };
+
+            let mut out = serde_json::to_vec(&response)?;
+            out.push(b'\n');
+            writer.write_all(&out).await?;
+            if shutdown {
+                self.shutdown.notify_one();
+                break;
+            }
+        }
+        Ok(())
+    }
+
+    /// Run a request against the backend
+    async fn dispatch(&self, request: DaemonRequest) -> DaemonResponse {
+        match self.call(request).await {
+            Ok(value) => DaemonResponse::Ok(value),
+            Err(e) => DaemonResponse::Error(e.to_string()),
+        }
+    }
+
+    /// Call the backend method a request names
+    async fn call(&self, request: DaemonRequest) -> Result<serde_json::Value, BackendError> {
+        self.refresh().await?;
+        let backend = &*self.backend;
+        match request {
+            DaemonRequest::Search {
+                query,
+                limit,
+                options,
+            } => encode(backend.search(&query, limit, options).await),
+            DaemonRequest::GetNode { node_id } => encode(backend.get_node(&node_id).await),
+            DaemonRequest::GetConnectedNodes {
+                node_id,
+                edge_type,
+                direction,
+            } => encode(
+                backend
+                    .get_connected_nodes(&node_id, edge_type.as_deref(), &direction)
+                    .await,
+            ),
+            DaemonRequest::GetEdges {
+                node_id,
+                edge_type,
+                direction,
+            } => encode(
+                backend
+                    .get_edges(&node_id, edge_type.as_deref(), &direction)
+                    .await,
+            ),
+            DaemonRequest::IndexStatus => encode(backend.index_status().await),
+            DaemonRequest::GraphStats => encode(backend.graph_stats().await),
+            DaemonRequest::ReadCode {
+                node_id,
+                context_lines,
+            } => encode(backend.read_code(&node_id, context_lines).await),
+            DaemonRequest::FindNodes {
+                pattern,
+                node_type,
+                limit,
+            } => encode(
+                backend
+                    .find_nodes(&pattern, node_type.as_deref(), limit)
+                    .await,
+            ),
+            DaemonRequest::Index { force } => encode(backend.index(force).await),
+            DaemonRequest::Sync => encode(backend.sync().await),
+            DaemonRequest::RepoId => encode(Ok(backend.repo_id())),
+            DaemonRequest::HealthCheck => encode(backend.health_check().await),
+            DaemonRequest::CheckProvider => encode(backend.check_provider().await),
+            DaemonRequest::Shutdown => Ok(serde_json::Value::Null),
+        }
+    }
+
+    /// Reload the graph if the index changed since it was loaded
+    async fn refresh(&self) -> Result<(), BackendError> {
+        let modified = manifest_modified(&self.backend);
+        let mut loaded = self.manifest_modified.lock().await;
+        if modified != *loaded {
+            info!("Index changed, reloading graph");
+            self.backend.sync().await?;
+            *loaded = modified;
+        }
+        Ok(())
+    }
+}
+
+/// Modification time of the backend's index manifest
+fn manifest_modified(backend: &LocalBackend) -> Option<SystemTime> {
+    std::fs::metadata(backend.prism_dir().join("manifest.json"))
+        .and_then(|m| m.modified())
+        .ok()
+}
+
+/// Convert a method's result to a response value
+fn encode<T: Serialize>(
+    result: Result<T, BackendError>,
+) -> Result<serde_json::Value, BackendError> {
+    Ok(serde_json::to_value(result?)?)
+}
+
+/// Backend that forwards every call to a running daemon.
+///
+/// Each call uses its own connection, so one client can be shared freely
+/// between tasks.
+pub struct DaemonBackend {
+    /// Daemon socket
+    socket: PathBuf,
+
+    /// Repository identifier reported by the daemon
+    repo_id: String,
+}
+
+impl DaemonBackend {
+    /// Connect to the daemon listening on `socket`.
+    ///
+    /// Fails with [`BackendError::Connection`] if no daemon is running.
+    pub async fn connect(socket: impl Into<PathBuf>) -> Result<Self, BackendError> {
+        let mut backend = Self {
+            socket: socket.into(),
+            repo_id: String::new(),
+        };
+        backend.repo_id = backend.call(DaemonRequest::RepoId).await?;
+        Ok(backend)
+    }
+
+    /// Get the daemon socket path.
+    pub fn socket_path(&self) -> &Path {
+        &self.socket
+    }
+
+    /// Ask the daemon to exit.
+    pub async fn shutdown(&self) -> Result<(), BackendError> {
+        self.call(DaemonRequest::Shutdown).await
+    }
+
+    /// Send one request and decode the daemon's answer
+    async fn call<T: DeserializeOwned>(&self, request: DaemonRequest) -> Result<T, BackendError> {
+        let stream = UnixStream::connect(&self.socket)
+            .await
+            .map_err(|e| BackendError::Connection(format!("{}: {}", self.socket.display(), e)))?;
+        let (reader, mut writer) = stream.into_split();
+
+        let mut line = serde_json::to_vec(&request)?;
+        line.push(b'\n');
+        writer.write_all(&line).await?;
+
+        let mut response = String::new();
+        BufReader::new(reader).read_line(&mut response).await?;
+        if response.is_empty() {
+            return Err(BackendError::Connection(
+                "daemon closed the connection".to_string(),
+            ));
+        }
+        match serde_json::from_str(&response)? {
+            DaemonResponse::Ok(value) => Ok(serde_json::from_value(value)?),
+            DaemonResponse::Error(message) => Err(BackendError::with_context("daemon", message)),
+        }
+    }
+}
+
+#[async_trait]
+impl Backend for DaemonBackend {
+    async fn search(
+        &self,
+        query: &str,
+        limit: usize,
+        options: Option<SearchOptions>,
+    ) -> Result<Vec<SearchResult>, BackendError> {
+        self.call(DaemonRequest::Search {
+            query: query.to_string(),
+            limit,
+            options,
+        })
+        .await
+    }
+
+    async fn get_node(&self, node_id: &str) -> Result<NodeInfo, BackendError> {
+        self.call(DaemonRequest::GetNode {
+            node_id: node_id.to_string(),
+        })
+        .await
+    }
+
+    async fn get_connected_nodes(
+        &self,
+        node_id: &str,
+        edge_type: Option<&str>,
+        direction: &str,
+    ) -> Result<Vec<NodeInfo>, BackendError> {
+        self.call(DaemonRequest::GetConnectedNodes {
+            node_id: node_id.to_string(),
+            edge_type: edge_type.map(String::from),
+            direction: direction.to_string(),
+        })
+        .await
+    }
+
+    async fn get_edges(
+        &self,
+        node_id: &str,
+        edge_type: Option<&str>,
+        direction: &str,
+    ) -> Result<Vec<EdgeInfo>, BackendError> {
+        self.call(DaemonRequest::GetEdges {
+            node_id: node_id.to_string(),
+            edge_type: edge_type.map(String::from),
+            direction: direction.to_string(),
+        })
+        .await
+    }
+
+    async fn index_status(&self) -> Result<IndexStatus, BackendError> {
+        self.call(DaemonRequest::IndexStatus).await
+    }
+
+    async fn graph_stats(&self) -> Result<GraphStats, BackendError> {
+        self.call(DaemonRequest::GraphStats).await
+    }
+
+    async fn read_code(&self, node_id: &str, context_lines: usize) -> Result<String, BackendError> {
+        self.call(DaemonRequest::ReadCode {
+            node_id: node_id.to_string(),
+            context_lines,
+        })
+        .await
+    }
+
+    async fn find_nodes(
+        &self,
+        pattern: &str,
+        node_type: Option<&str>,
+        limit: usize,
+    ) -> Result<Vec<NodeInfo>, BackendError> {
+        self.call(DaemonRequest::FindNodes {
+            pattern: pattern.to_string(),
+            node_type: node_type.map(String::from),
+            limit,
+        })
+        .await
+    }
+
+    async fn index(&self, force: bool) -> Result<usize, BackendError> {
+        self.call(DaemonRequest::Index { force }).await
+    }
+
+    async fn sync(&self) -> Result<bool, BackendError> {
+        self.call(DaemonRequest::Sync).await
+    }
+
+    fn repo_id(&self) -> &str {
+        &self.repo_id
+    }
+
+    async fn health_check(&self) -> Result<bool, BackendError> {
+        self.call(DaemonRequest::HealthCheck).await
+    }
+
+    async fn check_provider(&self) -> Result<ProviderStatus, BackendError> {
+        self.call(DaemonRequest::CheckProvider).await
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_request_wire_format() {
+        let request = DaemonRequest::GetEdges {
+            node_id: "src/lib.rs:main".to_string(),
+            edge_type: None,
+            direction: "incoming".to_string(),
+        };
+        let json = serde_json::to_value(&request).unwrap();
+        assert_eq!(json["method"], "get_edges");
+        assert_eq!(json["params"]["node_id"], "src/lib.rs:main");
+
+        let request: DaemonRequest = serde_json::from_str(r#"{"method":"graph_stats"}"#).unwrap();
+        assert!(matches!(request, DaemonRequest::GraphStats));
+    }
+
+    #[tokio::test]
+    async fn test_connect_without_daemon() {
+        let dir = tempfile::tempdir().unwrap();
+        let result = DaemonBackend::connect(socket_path(dir.path())).await;
+        assert!(matches!(result, Err(BackendError::Connection(_))));
+    }
+}
@@ -11,6 +11,8 @@
 //! - [`LocalBackend`]: Direct access to file system and Qdrant for local operations
 //! - [`RemoteBackend`]: HTTP client for connecting to a CodePrysm server (future)
 //! - [`MultiWorkspaceBackend`]: Aggregates operations across multiple workspaces
+//! - [`DaemonBackend`]: Client for a daemon that keeps one [`LocalBackend`] loaded
+//!   (Unix only)
 //!
 //! ## Workspace Registry
 //!
@@ -45,6 +47,8 @@
 //! }
 //! ```
 
+#[cfg(unix)]
+mod daemon;
 mod error;
 mod local;
 mod multi;
@@ -54,6 +58,8 @@ mod traits;
 mod types;
 
 pub use codeprysm_search::{ModelStatus, ProviderStatus};
+#[cfg(unix)]
+pub use daemon::{socket_path, DaemonBackend, DaemonRequest, DaemonResponse, DaemonServer};
 pub use error::BackendError;
 pub use local::LocalBackend;
 pub use multi::MultiWorkspaceBackend;
@@ -2,16 +2,20 @@
 //!
 //! Provides direct access to:
 //! - File system for code reading
-//! - SQLite partitions for graph storage (via LazyGraphManager)
+//! - SQLite partitions for graph storage (via LazyGraphManager); point
+//!   queries load only the partitions they reach, keeping the most recently
+//!   used ones within the configured partition cache
+//! - The memory-mapped graph store for node and edge queries, when the
+//!   `mmap` graph format is configured
 //! - Qdrant for semantic search
 
 use std::path::{Path, PathBuf};
 use std::sync::Arc;
 
 use async_trait::async_trait;
-use codeprysm_config::PrismConfig;
+use codeprysm_config::{GraphFormat, PrismConfig};
 use codeprysm_core::lazy::LazyGraphManager;
-use codeprysm_core::{EdgeType, NodeType, PetCodeGraph};
+use codeprysm_core::{Edge, EdgeData, EdgeType, MmapGraph, NodeType, PetCodeGraph};
 use codeprysm_search::{EmbeddingConfig, GraphIndexer, HybridSearcher, QdrantConfig};
 use regex::Regex;
 use tokio::sync::RwLock;
@@ -35,6 +39,9 @@ pub struct LocalBackend {
     /// Lazy graph manager (handles partition loading)
     graph_manager: Arc<RwLock<Option<LazyGraphManager>>>,
 
+    /// Memory-mapped graph store, opened on first use
+    store: Arc<RwLock<Option<Arc<MmapGraph>>>>,
+
     /// Qdrant configuration
     qdrant_config: QdrantConfig,
 }
@@ -72,6 +79,7 @@ impl LocalBackend {
             workspace_root,
             config: config.clone(),
             graph_manager: Arc::new(RwLock::new(None)),
+            store: Arc::new(RwLock::new(None)),
             qdrant_config,
         })
     }
@@ -87,6 +95,11 @@ impl LocalBackend {
         Ok(backend)
     }
 
+    /// Get the workspace root directory.
+    pub fn workspace_root(&self) -> &Path {
+        &self.workspace_root
+    }
+
     /// Get the CodePrysm data directory for this workspace.
     pub fn prism_dir(&self) -> PathBuf {
         self.config.prism_dir(&self.workspace_root)
@@ -131,7 +144,8 @@ impl LocalBackend {
 
         info!("Loading graph from {:?}", prism_dir);
 
-        let manager = LazyGraphManager::open(&prism_dir)?;
+        let budget = self.config.storage.partition_cache_mb as usize * 1024 * 1024;
+        let manager = LazyGraphManager::open_with_memory_budget(&prism_dir, Some(budget))?;
 
         let mut guard = self.graph_manager.write().await;
         *guard = Some(manager);
@@ -140,6 +154,46 @@ impl LocalBackend {
         Ok(())
     }
 
+    /// The memory-mapped graph store, if the `mmap` graph format is
+    /// configured and the store is at least as new as the partitions.
+    ///
+    /// Returns `None` to fall back to the partitions, for example while
+    /// `watch` has updated the partitions but not yet the store.
+    async fn store(&self) -> Option<Arc<MmapGraph>> {
+        if self.config.storage.graph_format != GraphFormat::Mmap {
+            return None;
+        }
+        if let Some(store) = self.store.read().await.as_ref() {
+            return Some(Arc::clone(store));
+        }
+
+        let path = self.graph_path();
+        let modified = |p: &Path| std::fs::metadata(p).and_then(|m| m.modified()).ok();
+        let store_time = modified(&path)?;
+        if modified(&self.prism_dir().join("manifest.json")).is_some_and(|t| t > store_time) {
+            debug!("Graph store {:?} is older than the partitions", path);
+            return None;
+        }
+
+        match MmapGraph::open(&path) {
+            Ok(store) => {
+                info!(
+                    "Opened graph store {:?} ({} nodes, {} edges)",
+                    path,
+                    store.node_count(),
+                    store.edge_count()
+                );
+                let store = Arc::new(store);
+                *self.store.write().await = Some(Arc::clone(&store));
+                Some(store)
+            }
+            Err(e) => {
+                warn!("Failed to open graph store {:?}: {}", path, e);
+                None
+            }
+        }
+    }
+
     /// Get a read-only reference to the graph.
     async fn with_graph<F, R>(&self, f: F) -> Result<R, BackendError>
     where
@@ -156,6 +210,45 @@ impl LocalBackend {
         f(&graph_guard)
     }
 
+    /// Run a closure against the lazy graph manager.
+    ///
+    /// Point queries go through the manager so only the partitions holding the
+    /// queried node and its cross-partition neighbors are loaded; least
+    /// recently used partitions are evicted once the partition cache is full.
+    async fn with_manager<F, R>(&self, f: F) -> Result<R, BackendError>
+    where
+        F: FnOnce(&LazyGraphManager) -> Result<R, BackendError>,
+    {
+        self.ensure_graph().await?;
+
+        let guard = self.graph_manager.read().await;
+        let manager = guard
+            .as_ref()
+            .ok_or_else(|| BackendError::with_context("graph access", "graph not loaded"))?;
+
+        f(manager)
+    }
+
+    /// Run a closure against the complete graph.
+    ///
+    /// Whole-graph analyses (paths, reachability, metrics) need every node and
+    /// edge, but the lazily loaded runtime graph only holds the partitions touched
+    /// so far and omits cross-partition edges. This materializes a full copy.
+    pub async fn with_full_graph<F, R>(&self, f: F) -> Result<R, BackendError>
+    where
+        F: FnOnce(&PetCodeGraph) -> Result<R, BackendError>,
+    {
+        self.ensure_graph().await?;
+
+        let guard = self.graph_manager.read().await;
+        let manager = guard
+            .as_ref()
+            .ok_or_else(|| BackendError::with_context("graph access", "graph not loaded"))?;
+
+        let graph = manager.load_full_graph()?;
+        f(&graph)
+    }
+
     /// Create a HybridSearcher for this backend.
     async fn create_searcher(&self) -> Result<HybridSearcher, BackendError> {
         // Convert codeprysm_config embedding settings to codeprysm_search embedding config
@@ -261,6 +354,45 @@ impl LocalBackend {
         Ok(selected.join("\n"))
     }
 
+    /// Convert an edge to its API representation.
+    fn edge_info(edge: &Edge) -> EdgeInfo {
+        let mut metadata = std::collections::HashMap::new();
+        if let Some(ref ident) = edge.ident {
+            metadata.insert("ident".to_string(), ident.clone());
+        }
+        if let Some(ref version) = edge.version_spec {
+            metadata.insert("version_spec".to_string(), version.clone());
+        }
+        if let Some(dev) = edge.is_dev_dependency {
+            metadata.insert("is_dev_dependency".to_string(), dev.to_string());
+        }
+        if let Some(weight) = edge.weight {
+            metadata.insert("weight".to_string(), weight.to_string());
+        }
+
+        EdgeInfo {
+            from_id: edge.source.clone(),
+            to_id: edge.target.clone(),
+            edge_type: format!("{:?}", edge.edge_type),
+            metadata,
+        }
+    }
+
+    /// Build an edge from a neighbor query result.
+    fn to_edge(source: &str, target: &str, data: EdgeData) -> Edge {
+        Edge {
+            source: source.to_string(),
+            target: target.to_string(),
+            edge_type: data.edge_type,
+            ref_line: data.ref_line,
+            ident: data.ident,
+            version_spec: data.version_spec,
+            is_dev_dependency: data.is_dev_dependency,
+            similarity: data.similarity,
+            weight: data.weight,
+        }
+    }
+
     /// Parse edge type from string.
     fn parse_edge_type(s: &str) -> Option<EdgeType> {
         match s.to_lowercase().as_str() {
@@ -268,6 +400,12 @@ impl LocalBackend {
             "uses" => Some(EdgeType::Uses),
             "defines" => Some(EdgeType::Defines),
             "dependson" | "depends_on" => Some(EdgeType::DependsOn),
+            "cloneof" | "clone_of" => Some(EdgeType::CloneOf),
+            "definedin" | "defined_in" => Some(EdgeType::DefinedIn),
+            "crosslanguage" | "cross_language" => Some(EdgeType::CrossLanguage),
+            "refersbyname" | "refers_by_name" => Some(EdgeType::RefersByName),
+            "equivalentto" | "equivalent_to" => Some(EdgeType::EquivalentTo),
+            "subsetof" | "subset_of" => Some(EdgeType::SubsetOf),
             _ => None,
         }
     }
@@ -327,10 +465,17 @@ impl Backend for LocalBackend {
     }
 
     async fn get_node(&self, node_id: &str) -> Result<NodeInfo, BackendError> {
-        self.with_graph(|graph| {
-            graph
+        if let Some(store) = self.store().await {
+            return store
                 .get_node(node_id)
-                .map(NodeInfo::from_node)
+                .map(|node| NodeInfo::from_node(&node.to_node()))
+                .ok_or_else(|| BackendError::node_not_found(node_id));
+        }
+
+        self.with_manager(|manager| {
+            manager
+                .get_node(node_id)?
+                .map(|node| NodeInfo::from_node(&node))
                 .ok_or_else(|| BackendError::node_not_found(node_id))
         })
         .await
@@ -344,33 +489,55 @@ impl Backend for LocalBackend {
     ) -> Result<Vec<NodeInfo>, BackendError> {
         let edge_type_filter = edge_type.and_then(Self::parse_edge_type);
 
-        self.with_graph(|graph| {
-            // Verify node exists
-            if graph.get_node(node_id).is_none() {
-                return Err(BackendError::node_not_found(node_id));
-            }
+        if let Some(store) = self.store().await {
+            let node = store
+                .get_node(node_id)
+                .ok_or_else(|| BackendError::node_not_found(node_id))?;
+            let matches = |t: EdgeType| edge_type_filter.is_none_or(|f| f == t);
 
             let mut nodes = Vec::new();
+            if direction == "outgoing" || direction == "both" {
+                nodes.extend(
+                    node.outgoing()
+                        .filter(|e| matches(e.edge_type()))
+                        .map(|e| NodeInfo::from_node(&e.target().to_node())),
+                );
+            }
+            if direction == "incoming" || direction == "both" {
+                nodes.extend(
+                    node.incoming()
+                        .filter(|e| matches(e.edge_type()))
+                        .map(|e| NodeInfo::from_node(&e.source().to_node())),
+                );
+            }
+            return Ok(nodes);
+        }
 
-            // Get edges based on direction
-            let include_outgoing = direction == "outgoing" || direction == "both";
-            let include_incoming = direction == "incoming" || direction == "both";
-
-            for edge in graph.iter_edges() {
-                let matches_filter =
-                    edge_type_filter.is_none() || edge_type_filter == Some(edge.edge_type);
-
-                if include_outgoing && edge.source == node_id && matches_filter {
-                    if let Some(target) = graph.get_node(&edge.target) {
-                        nodes.push(NodeInfo::from_node(target));
-                    }
-                }
+        self.with_manager(|manager| {
+            // Verify node exists, loading its partition
+            if manager.get_node(node_id)?.is_none() {
+                return Err(BackendError::node_not_found(node_id));
+            }
+            let matches = |data: &EdgeData| edge_type_filter.is_none_or(|f| f == data.edge_type);
 
-                if include_incoming && edge.target == node_id && matches_filter {
-                    if let Some(source) = graph.get_node(&edge.source) {
-                        nodes.push(NodeInfo::from_node(source));
-                    }
-                }
+            let mut nodes = Vec::new();
+            if direction == "outgoing" || direction == "both" {
+                nodes.extend(
+                    manager
+                        .get_outgoing_edges(node_id)?
+                        .into_iter()
+                        .filter(|(_, data)| matches(data))
+                        .map(|(target, _)| NodeInfo::from_node(&target)),
+                );
+            }
+            if direction == "incoming" || direction == "both" {
+                nodes.extend(
+                    manager
+                        .get_incoming_edges(node_id)?
+                        .into_iter()
+                        .filter(|(_, data)| matches(data))
+                        .map(|(source, _)| NodeInfo::from_node(&source)),
+                );
             }
 
             Ok(nodes)
@@ -386,45 +553,51 @@ impl Backend for LocalBackend {
     ) -> Result<Vec<EdgeInfo>, BackendError> {
         let edge_type_filter = edge_type.and_then(Self::parse_edge_type);
 
-        self.with_graph(|graph| {
-            // Verify node exists
-            if graph.get_node(node_id).is_none() {
+        if let Some(store) = self.store().await {
+            let node = store
+                .get_node(node_id)
+                .ok_or_else(|| BackendError::node_not_found(node_id))?;
+            let include_outgoing = direction == "outgoing" || direction == "both";
+            let include_incoming = direction == "incoming" || direction == "both";
+
+            let outgoing = node.outgoing().filter(|_| include_outgoing);
+            let incoming = node.incoming().filter(|_| include_incoming);
+            return Ok(outgoing
+                .chain(incoming)
+                .filter(|e| edge_type_filter.is_none_or(|f| f == e.edge_type()))
+                .map(|e| Self::edge_info(&e.to_edge()))
+                .collect());
+        }
+
+        self.with_manager(|manager| {
+            // Verify node exists, loading its partition
+            if manager.get_node(node_id)?.is_none() {
                 return Err(BackendError::node_not_found(node_id));
             }
+            let matches = |data: &EdgeData| edge_type_filter.is_none_or(|f| f == data.edge_type);
 
             let mut edges = Vec::new();
-
-            let include_outgoing = direction == "outgoing" || direction == "both";
-            let include_incoming = direction == "incoming" || direction == "both";
-
-            for edge in graph.iter_edges() {
-                let is_outgoing = edge.source == node_id;
-                let is_incoming = edge.target == node_id;
-
-                if (is_outgoing && include_outgoing) || (is_incoming && include_incoming) {
-                    let matches_filter =
-                        edge_type_filter.is_none() || edge_type_filter == Some(edge.edge_type);
-
-                    if matches_filter {
-                        let mut metadata = std::collections::HashMap::new();
-                        if let Some(ref ident) = edge.ident {
-                            metadata.insert("ident".to_string(), ident.clone());
-                        }
-                        if let Some(ref version) = edge.version_spec {
-                            metadata.insert("version_spec".to_string(), version.clone());
-                        }
-                        if let Some(dev) = edge.is_dev_dependency {
-                            metadata.insert("is_dev_dependency".to_string(), dev.to_string());
-                        }
-
-                        edges.push(EdgeInfo {
-                            from_id: edge.source.clone(),
-                            to_id: edge.target.clone(),
-                            edge_type: format!("{:?}", edge.edge_type),
-                            metadata,
-                        });
-                    }
-                }
+            if direction == "outgoing" || direction == "both" {
+                edges.extend(
+                    manager
+                        .get_outgoing_edges(node_id)?
+                        .into_iter()
+                        .filter(|(_, data)| matches(data))
+                        .map(|(target, data)| {
+                            Self::edge_info(&Self::to_edge(node_id, &target.id, data))
+                        }),
+                );
+            }
+            if direction == "incoming" || direction == "both" {
+                edges.extend(
+                    manager
+                        .get_incoming_edges(node_id)?
+                        .into_iter()
+                        .filter(|(_, data)| matches(data))
+                        .map(|(source, data)| {
+                            Self::edge_info(&Self::to_edge(&source.id, node_id, data))
+                        }),
+                );
             }
 
             Ok(edges)
@@ -458,10 +631,22 @@ impl Backend for LocalBackend {
     }
 
     async fn read_code(&self, node_id: &str, context_lines: usize) -> Result<String, BackendError> {
+        if let Some(store) = self.store().await {
+            let node = store
+                .get_node(node_id)
+                .ok_or_else(|| BackendError::node_not_found(node_id))?;
+            return self.read_file_content(
+                node.file(),
+                node.line(),
+                node.end_line(),
+                context_lines,
+            );
+        }
+
         let (file_path, start_line, end_line) = self
-            .with_graph(|graph| {
-                let node = graph
-                    .get_node(node_id)
+            .with_manager(|manager| {
+                let node = manager
+                    .get_node(node_id)?
                     .ok_or_else(|| BackendError::node_not_found(node_id))?;
 
                 Ok((node.file.clone(), node.line, node.end_line))
@@ -644,6 +829,9 @@ impl Backend for LocalBackend {
         let mut guard = self.graph_manager.write().await;
         *guard = Some(new_manager);
 
+        // Reopen the graph store on next use, in case it was rewritten
+        *self.store.write().await = None;
+
         Ok(true)
     }
 
@@ -708,4 +896,182 @@ mod tests {
         );
         assert_eq!(LocalBackend::parse_edge_type("invalid"), None);
     }
+
+    #[tokio::test]
+    async fn test_queries_read_graph_store() {
+        use codeprysm_core::{CallableKind, EdgeData, Node};
+
+        let dir = tempfile::tempdir().unwrap();
+        let mut config = PrismConfig::default();
+        config.storage.graph_format = GraphFormat::Mmap;
+        std::fs::create_dir_all(config.prism_dir(dir.path())).unwrap();
+
+        let mut graph = PetCodeGraph::new();
+        for (id, line) in [("app.py:main", 1), ("app.py:helper", 4)] {
+            graph.add_node(Node::callable(
+                id.to_string(),
+                id.rsplit(':').next().unwrap().to_string(),
+                CallableKind::Function,
+                "app.py".to_string(),
+                line,
+                line + 1,
+            ));
+        }
+        graph.add_edge(
+            "app.py:main",
+            "app.py:helper",
+            EdgeData::uses(Some(2), Some("helper".to_string())),
+        );
+        MmapGraph::write(&graph, &config.graph_path(dir.path())).unwrap();
+
+        // No partitions exist, so these can only be answered from the store
+        let backend = LocalBackend::new(&config, dir.path()).await.unwrap();
+        assert_eq!(
+            backend.get_node("app.py:helper").await.unwrap().start_line,
+            Some(4)
+        );
+        let callers = backend
+            .get_connected_nodes("app.py:helper", Some("uses"), "incoming")
+            .await
+            .unwrap();
+        assert_eq!(callers.len(), 1);
+        assert_eq!(callers[0].id, "app.py:main");
+        let edges = backend
+            .get_edges("app.py:main", None, "outgoing")
+            .await
+            .unwrap();
+        assert_eq!(edges[0].metadata.get("ident").unwrap(), "helper");
+        assert!(matches!(
+            backend.get_node("app.py:missing").await,
+            Err(BackendError::NodeNotFound { .. })
+        ));
+    }
+
+    /// Partition `graph` under `dir` as root "repo" and open a backend on it
+    async fn partitioned_backend(graph: &PetCodeGraph, dir: &Path) -> LocalBackend {
+        use codeprysm_core::lazy::partitioner::GraphPartitioner;
+
+        let config = PrismConfig::default();
+        GraphPartitioner::partition_with_stats(graph, &config.prism_dir(dir), Some("repo"))
+            .unwrap();
+        LocalBackend::new(&config, dir).await.unwrap()
+    }
+
+    /// Functions in three packages, `api` calling into `lib`
+    fn three_package_graph() -> PetCodeGraph {
+        use codeprysm_core::{CallableKind, Node};
+
+        let mut graph = PetCodeGraph::new();
+        for id in [
+            "api/app.py:main",
+            "lib/util.py:helper",
+            "web/view.py:render",
+        ] {
+            let (file, name) = id.split_once(':').unwrap();
+            graph.add_node(Node::callable(
+                id.to_string(),
+                name.to_string(),
+                CallableKind::Function,
+                file.to_string(),
+                1,
+                2,
+            ));
+        }
+        graph.add_edge(
+            "api/app.py:main",
+            "lib/util.py:helper",
+            EdgeData::uses(Some(2), Some("helper".to_string())),
+        );
+        graph
+    }
+
+    /// Sorted ids of the partitions the backend has loaded
+    async fn loaded_partitions(backend: &LocalBackend) -> Vec<String> {
+        let guard = backend.graph_manager.read().await;
+        let mut loaded = guard.as_ref().unwrap().loaded_partitions();
+        loaded.sort();
+        loaded
+    }
+
+    #[tokio::test]
+    async fn test_queries_load_only_reachable_partitions() {
+        let dir = tempfile::tempdir().unwrap();
+        let backend = partitioned_backend(&three_package_graph(), dir.path()).await;
+        let callers = backend
+            .get_connected_nodes("lib/util.py:helper", Some("uses"), "incoming")
+            .await
+            .unwrap();
+        assert_eq!(callers.len(), 1);
+        assert_eq!(callers[0].id, "api/app.py:main");
+        let edges = backend
+            .get_edges("api/app.py:main", None, "outgoing")
+            .await
+            .unwrap();
+        assert_eq!(edges[0].to_id, "lib/util.py:helper");
+
+        // The web partition is never reached
+        assert_eq!(
+            loaded_partitions(&backend).await,
+            vec!["repo_api", "repo_lib"]
+        );
+    }
+
+    #[tokio::test]
+    async fn test_queries_on_empty_graph() {
+        let dir = tempfile::tempdir().unwrap();
+        let backend = partitioned_backend(&PetCodeGraph::new(), dir.path()).await;
+
+        assert!(matches!(
+            backend.get_node("api/app.py:main").await,
+            Err(BackendError::NodeNotFound { .. })
+        ));
+        assert!(matches!(
+            backend.get_connected_nodes("", None, "both").await,
+            Err(BackendError::NodeNotFound { .. })
+        ));
+        assert!(loaded_partitions(&backend).await.is_empty());
+    }
+
+    #[tokio::test]
+    async fn test_queries_without_matching_edges() {
+        let dir = tempfile::tempdir().unwrap();
+        let backend = partitioned_backend(&three_package_graph(), dir.path()).await;
+
+        // render has no edges at all; main has no incoming or CONTAINS edges
+        let neighbors = backend
+            .get_connected_nodes("web/view.py:render", None, "both")
+            .await
+            .unwrap();
+        assert!(neighbors.is_empty());
+        let callers = backend
+            .get_connected_nodes("api/app.py:main", Some("uses"), "incoming")
+            .await
+            .unwrap();
+        assert!(callers.is_empty());
+        let edges = backend
+            .get_edges("api/app.py:main", Some("contains"), "outgoing")
+            .await
+            .unwrap();
+        assert!(edges.is_empty());
+    }
+
+    #[tokio::test]
+    async fn test_queries_report_missing_node_and_graph() {
+        let dir = tempfile::tempdir().unwrap();
+        let backend = partitioned_backend(&three_package_graph(), dir.path()).await;
+        assert!(matches!(
+            backend.get_edges("lib/util.py:missing", None, "both").await,
+            Err(BackendError::NodeNotFound { id }) if id == "lib/util.py:missing"
+        ));
+
+        // Without partitions there is nothing to load
+        let empty = tempfile::tempdir().unwrap();
+        let backend = LocalBackend::new(&PrismConfig::default(), empty.path())
+            .await
+            .unwrap();
+        assert!(matches!(
+            backend.get_node("api/app.py:main").await,
+            Err(BackendError::GraphNotFound { .. })
+        ));
+    }
 }
@@ -73,6 +73,9 @@ impl From<codeprysm_search::HybridSearchHit> for SearchResult {
     }
 }
 
+/// Prefix of [`NodeInfo::metadata`] keys holding user-defined attributes
+pub const ATTRIBUTE_PREFIX: &str = "attr.";
+
 /// Node information from the graph.
 #[derive(Debug, Clone, Serialize, Deserialize)]
 pub struct NodeInfo {
@@ -114,6 +117,24 @@ impl NodeInfo {
         if node.metadata.is_publishable == Some(true) {
             metadata.insert("is_publishable".to_string(), "true".to_string());
         }
+        if let Some(ref owners) = node.metadata.owners {
+            metadata.insert("owners".to_string(), owners.join(" "));
+        }
+        for (key, value) in node.metadata.attributes.iter().flatten() {
+            metadata.insert(format!("{}{}", ATTRIBUTE_PREFIX, key), value.clone());
+        }
+        if let (Some(covered), Some(total)) = (
+            node.metadata.covered_statements,
+            node.metadata.total_statements,
+        ) {
+            metadata.insert("coverage".to_string(), format!("{}/{}", covered, total));
+        }
+        if let Some(ref author) = node.metadata.primary_author {
+            metadata.insert("primary_author".to_string(), author.clone());
+        }
+        if let Some(risk) = node.metadata.risk_score {
+            metadata.insert("risk_score".to_string(), risk.to_string());
+        }
 
         Self {
             id: node.id.clone(),
@@ -126,6 +147,13 @@ impl NodeInfo {
             metadata,
         }
     }
+
+    /// Value of a user-defined attribute, if the node has it
+    pub fn attribute(&self, key: &str) -> Option<&str> {
+        self.metadata
+            .get(&format!("{}{}", ATTRIBUTE_PREFIX, key))
+            .map(String::as_str)
+    }
 }
 
 /// Edge information from the graph.
@@ -242,6 +270,12 @@ impl GraphStats {
             EdgeType::Uses,
             EdgeType::Defines,
             EdgeType::DependsOn,
+            EdgeType::CloneOf,
+            EdgeType::DefinedIn,
+            EdgeType::CrossLanguage,
+            EdgeType::RefersByName,
+            EdgeType::EquivalentTo,
+            EdgeType::SubsetOf,
         ] {
             let count = graph.edges_by_type(edge_type).count();
             if count > 0 {
@@ -0,0 +1,103 @@
+//! Aliases command - Symbol IDs changed by renames and moves
+//!
+//! `codeprysm update` and `codeprysm watch` record every symbol whose stable
+//! ID changed, because it was renamed, moved to another package, or
+//! redeclared, in the index's alias table. This command lists the table or
+//! follows an old ID to the symbol's current one. Commands taking a symbol
+//! accept old IDs as well.
+
+use anyhow::Result;
+use clap::Args;
+use codeprysm_core::lazy::civil_date;
+use codeprysm_core::{is_symbol_id, AliasTable, SymbolAlias};
+use serde::Serialize;
+
+use super::{load_config, resolve_workspace};
+use crate::GlobalOptions;
+
+/// Seconds per day
+const DAY: i64 = 86_400;
+
+/// Arguments for the aliases command
+#[derive(Args, Debug)]
+pub struct AliasesArgs {
+    /// Symbol ID to follow to its current ID (lists every alias if omitted)
+    id: Option<String>,
+
+    /// Output as JSON
+    #[arg(long)]
+    json: bool,
+}
+
+/// An old ID followed to the current one
+#[derive(Serialize)]
+struct Resolution<'a> {
+    id: &'a str,
+    current: &'a str,
+    chain: Vec<&'a SymbolAlias>,
+}
+
+/// Execute the aliases command
+pub async fn execute(args: AliasesArgs, global: GlobalOptions) -> Result<()> {
+    let workspace_path = resolve_workspace(&global).await?;
+    let config = load_config(&global, &workspace_path)?;
+    let table = AliasTable::load(&config.prism_dir(&workspace_path));
+
+    let Some(ref id) = args.id else {
+        if args.json {
+            println!("{}", serde_json::to_string_pretty(&table.aliases)?);
+        } else if table.is_empty() {
+            if !global.quiet {
+                println!("No renamed or moved symbols recorded.");
+                println!(
+                    "\nHint: Aliases are recorded by 'codeprysm update' and 'codeprysm watch'"
+                );
+            }
+        } else {
+            for alias in &table.aliases {
+                print_alias(alias);
+            }
+        }
+        return Ok(());
+    };
+
+    if !is_symbol_id(id) {
+        anyhow::bail!(
+            "'{}' is not a symbol ID (e.g., sym:go:1f0c6a9e3b2d4c58); symbol IDs are shown by 'codeprysm graph node'",
+            id
+        );
+    }
+    let resolution = Resolution {
+        id,
+        current: table.resolve(id),
+        chain: table.chain(id),
+    };
+
+    if args.json {
+        println!("{}", serde_json::to_string_pretty(&resolution)?);
+        return Ok(());
+    }
+    if resolution.chain.is_empty() {
+        if !global.quiet {
+            println!("{} was never renamed or moved.", id);
+        }
+        return Ok(());
+    }
+    for alias in &resolution.chain {
+        print_alias(alias);
+    }
+    println!("{}", resolution.current);
+    Ok(())
+}
+
+fn print_alias(alias: &SymbolAlias) {
+    println!(
+        "  {} {} -> {} ({} {} -> {})",
+        civil_date(alias.recorded_at.div_euclid(DAY)),
+        alias.old_id,
+        alias.new_id,
+        alias.reason.as_str(),
+        alias.old_node,
+        alias.new_node
+    );
+}
@@ -0,0 +1,1594 @@
+//! Analyze command - Whole-graph code analyses
+//!
+//! Reports derived from the complete graph, such as unreachable (dead) code,
+//! the impact of a change, copy-pasted logic, duplicated Go interfaces,
+//! untested code, dependency cycles, drift between declared build dependencies and the code, packages
+//! mixing incompatible licenses, Go exports no other package uses, and
+//! goroutines or resources that may leak.
+//! Findings can be printed as text, JSON, or SARIF for code scanning
+//! tools.
+
+use std::io::Read;
+use std::path::{Path, PathBuf};
+
+use anyhow::{Context, Result};
+use clap::{Args, Subcommand, ValueEnum};
+use codeprysm_core::analysis::build_deps::{UNDECLARED_DEPENDENCY_RULE, UNUSED_DEPENDENCY_RULE};
+use codeprysm_core::analysis::clones::CLONE_RULE;
+use codeprysm_core::analysis::cycles::CYCLE_RULE;
+use codeprysm_core::analysis::dead_code::DEAD_CODE_RULE;
+use codeprysm_core::analysis::interfaces::DUPLICATE_INTERFACE_RULE;
+use codeprysm_core::analysis::leaks::{GOROUTINE_LEAK_RULE, UNCLOSED_RESOURCE_RULE};
+use codeprysm_core::analysis::licenses::LICENSE_CONFLICT_RULE;
+use codeprysm_core::analysis::sarif::{sarif_log, SarifResult, SarifRule};
+use codeprysm_core::analysis::unused_exports::UNUSED_EXPORT_RULE;
+use codeprysm_core::analysis::{
+    analyze_impact, consolidate, coverage_report, find_clones, find_cycles, find_dead_code,
+    find_interface_overlaps, find_unused_exports, leak_findings, license_report, package_of,
+    parse_unified_diff, reconcile_build_graph, resolve_symbol, BuildDiscrepancy, BuildGraph,
+    CloneOptions, ClonePair, ConsolidationGroup, CoverageFilter, CycleLevel, DeadCodeOptions,
+    DeadCodeReport, DependencyCycle, DiscrepancyKind, ImpactOptions, ImpactReport, ImpactedSymbol,
+    InterfaceOptions, InterfaceOverlap, LeakFinding, LeakKind, OverlapKind, PackageLicenses,
+    RootKind, UnusedExportOptions, UnusedExportsReport,
+};
+use codeprysm_core::CoverProfile;
+
+use super::{create_backend, load_config, resolve_workspace};
+use crate::report::{OutputArgs, PolicyArgs};
+use crate::GlobalOptions;
+
+/// Whole-graph analysis commands
+#[derive(Subcommand, Debug)]
+pub enum AnalyzeCommand {
+    /// Report symbols not reachable from any root
+    DeadCode(DeadCodeArgs),
+
+    /// Report symbols and tests impacted by a diff
+    Impact(ImpactArgs),
+
+    /// Report near-duplicate functions (copy-pasted logic)
+    Clones(ClonesArgs),
+
+    /// Report Go interfaces duplicating another package's method set
+    Interfaces(InterfacesArgs),
+
+    /// Report test coverage of functions, weighted by fan-in
+    Coverage(CoverageArgs),
+
+    /// Report dependency cycles between packages or types
+    Cycles(CyclesArgs),
+
+    /// Compare declared build dependencies (go list, Bazel) with code references
+    BuildDeps(BuildDepsArgs),
+
+    /// Report file licenses per package and packages mixing incompatible ones
+    Licenses(LicensesArgs),
+
+    /// Report exported Go symbols that no other package uses
+    UnusedExports(UnusedExportsArgs),
+
+    /// Report goroutines without a cancellation path and resources never closed
+    Leaks(LeaksArgs),
+}
+
+/// Output format for analysis reports
+#[derive(Debug, Clone, Copy, ValueEnum)]
+pub enum ReportFormat {
+    /// Human-readable text
+    Text,
+    /// JSON report
+    Json,
+    /// SARIF 2.1.0 log
+    Sarif,
+}
+
+#[derive(Args, Debug)]
+pub struct DeadCodeArgs {
+    /// Root categories, comma-separated: main, entry-points, exported, tests, handlers
+    /// (default: all)
+    #[arg(long, value_delimiter = ',', value_parser = parse_root_kind)]
+    roots: Vec<RootKind>,
+
+    /// Additional root symbol (node ID or name); can be repeated
+    #[arg(long = "root", value_name = "SYMBOL")]
+    extra_roots: Vec<String>,
+
+    /// Glob over callable names treated as registered handlers (e.g., "Handle*"); can be repeated
+    #[arg(long = "handler", value_name = "PATTERN")]
+    handler_patterns: Vec<String>,
+
+    /// Ignore `codeprysm:ignore dead-code` suppression comments
+    #[arg(long)]
+    no_suppress: bool,
+
+    /// Output format
+    #[arg(
+        long,
+        short = 'f',
+        visible_alias = "output",
+        value_enum,
+        default_value = "text"
+    )]
+    format: ReportFormat,
+
+    #[command(flatten)]
+    policy: PolicyArgs,
+}
+
+#[derive(Args, Debug)]
+pub struct ImpactArgs {
+    /// Revision or range passed to `git diff` (default: uncommitted changes against HEAD)
+    #[arg(conflicts_with = "patch")]
+    range: Option<String>,
+
+    /// Read a unified diff from a file instead of running git ("-" for stdin)
+    #[arg(long, value_name = "FILE")]
+    patch: Option<PathBuf>,
+
+    /// Maximum number of dependency hops from a changed symbol
+    #[arg(long, short = 'd')]
+    depth: Option<usize>,
+
+    /// Only print impacted test files, one per line
+    #[arg(long)]
+    tests_only: bool,
+
+    #[command(flatten)]
+    output: OutputArgs,
+
+    #[command(flatten)]
+    policy: PolicyArgs,
+}
+
+#[derive(Args, Debug)]
+pub struct ClonesArgs {
+    /// Minimum similarity percentage (0-100)
+    #[arg(long, default_value = "80", value_parser = clap::value_parser!(u32).range(0..=100))]
+    min_similarity: u32,
+
+    /// Minimum function body size in normalized tokens
+    #[arg(long, default_value = "50")]
+    min_tokens: u32,
+
+    /// Only include pairs with a function under this path prefix
+    #[arg(long, short = 'p')]
+    package: Option<String>,
+
+    /// Only include pairs whose functions live in different packages
+    #[arg(long)]
+    cross_package: bool,
+
+    /// Output format
+    #[arg(
+        long,
+        short = 'f',
+        visible_alias = "output",
+        value_enum,
+        default_value = "text"
+    )]
+    format: ReportFormat,
+
+    #[command(flatten)]
+    policy: PolicyArgs,
+}
+
+#[derive(Args, Debug)]
+pub struct InterfacesArgs {
+    /// Minimum number of methods of the smaller interface of a pair
+    #[arg(long, default_value = "2")]
+    min_methods: usize,
+
+    /// Only include pairs with an interface under this path prefix
+    #[arg(long, short = 'p')]
+    package: Option<String>,
+
+    /// Output format
+    #[arg(
+        long,
+        short = 'f',
+        visible_alias = "output",
+        value_enum,
+        default_value = "text"
+    )]
+    format: ReportFormat,
+
+    #[command(flatten)]
+    policy: PolicyArgs,
+}
+
+#[derive(Args, Debug)]
+pub struct CoverageArgs {
+    /// Go cover profile to use instead of the coverage stored in the index
+    #[arg(long, value_name = "FILE")]
+    profile: Option<PathBuf>,
+
+    /// Only include exported functions
+    #[arg(long)]
+    exported: bool,
+
+    /// Only include functions at or below this coverage percentage (0 = untested)
+    #[arg(long, value_parser = clap::value_parser!(u32).range(0..=100))]
+    max_percent: Option<u32>,
+
+    /// Only include functions with at least this many callers
+    #[arg(long, default_value = "0")]
+    min_fan_in: usize,
+
+    /// Only include functions under this path prefix
+    #[arg(long, short = 'p')]
+    package: Option<String>,
+
+    /// Maximum number of functions to show
+    #[arg(long, short = 'n', default_value = "50")]
+    limit: usize,
+
+    #[command(flatten)]
+    output: OutputArgs,
+
+    #[command(flatten)]
+    policy: PolicyArgs,
+}
+
+#[derive(Args, Debug)]
+pub struct CyclesArgs {
+    /// Dependency level to check
+    #[arg(long, short = 'l', value_enum, default_value = "package")]
+    level: Level,
+
+    /// Only include cycles with a member under this path prefix
+    #[arg(long, short = 'p')]
+    package: Option<String>,
+
+    /// Output format
+    #[arg(
+        long,
+        short = 'f',
+        visible_alias = "output",
+        value_enum,
+        default_value = "text"
+    )]
+    format: ReportFormat,
+
+    #[command(flatten)]
+    policy: PolicyArgs,
+}
+
+#[derive(Args, Debug)]
+pub struct BuildDepsArgs {
+    /// Output of `go list -deps -json ./...` or `bazel query 'deps(//...)'`
+    /// (--output=streamed_jsonproto, or graph with --nograph:factored); "-" for stdin
+    input: PathBuf,
+
+    /// Only report this kind of discrepancy
+    #[arg(long, short = 'k', value_enum)]
+    kind: Option<Discrepancy>,
+
+    /// Only include dependencies from packages under this path prefix
+    #[arg(long, short = 'p')]
+    package: Option<String>,
+
+    /// Output format
+    #[arg(
+        long,
+        short = 'f',
+        visible_alias = "output",
+        value_enum,
+        default_value = "text"
+    )]
+    format: ReportFormat,
+
+    #[command(flatten)]
+    policy: PolicyArgs,
+}
+
+#[derive(Args, Debug)]
+pub struct LicensesArgs {
+    /// Only include packages under this path prefix
+    #[arg(long, short = 'p')]
+    package: Option<String>,
+
+    /// Only show packages mixing incompatible licenses
+    #[arg(long)]
+    conflicts: bool,
+
+    /// Output format
+    #[arg(
+        long,
+        short = 'f',
+        visible_alias = "output",
+        value_enum,
+        default_value = "text"
+    )]
+    format: ReportFormat,
+
+    #[command(flatten)]
+    policy: PolicyArgs,
+}
+
+#[derive(Args, Debug)]
+pub struct UnusedExportsArgs {
+    /// Glob over node IDs or qualified names (e.g., "pkg/client.*") of symbols
+    /// used outside the workspace, added to `external_api`; can be repeated
+    #[arg(long = "allow", value_name = "PATTERN")]
+    allow: Vec<String>,
+
+    /// Count references from test code in other packages
+    #[arg(long)]
+    include_tests: bool,
+
+    /// Only include symbols in packages under this path prefix
+    #[arg(long, short = 'p')]
+    package: Option<String>,
+
+    /// Ignore `codeprysm:ignore unused-export` suppression comments
+    #[arg(long)]
+    no_suppress: bool,
+
+    /// Output format
+    #[arg(
+        long,
+        short = 'f',
+        visible_alias = "output",
+        value_enum,
+        default_value = "text"
+    )]
+    format: ReportFormat,
+
+    #[command(flatten)]
+    policy: PolicyArgs,
+}
+
+#[derive(Args, Debug)]
+pub struct LeaksArgs {
+    /// Only report this kind of leak
+    #[arg(long, short = 'k', value_enum)]
+    kind: Option<Leak>,
+
+    /// Only include functions in packages under this path prefix
+    #[arg(long, short = 'p')]
+    package: Option<String>,
+
+    /// Output format
+    #[arg(
+        long,
+        short = 'f',
+        visible_alias = "output",
+        value_enum,
+        default_value = "text"
+    )]
+    format: ReportFormat,
+
+    #[command(flatten)]
+    policy: PolicyArgs,
+}
+
+#[derive(Debug, Clone, Copy, ValueEnum)]
+pub enum Discrepancy {
+    /// Referenced in code but not declared
+    Undeclared,
+    /// Declared but never referenced in code
+    Unused,
+}
+
+impl From<Discrepancy> for DiscrepancyKind {
+    fn from(kind: Discrepancy) -> Self {
+        match kind {
+            Discrepancy::Undeclared => DiscrepancyKind::Undeclared,
+            Discrepancy::Unused => DiscrepancyKind::Unused,
+        }
+    }
+}
+
+#[derive(Debug, Clone, Copy, ValueEnum)]
+pub enum Leak {
+    /// Goroutines without a context or done channel
+    Goroutine,
+    /// Resources never closed or returned
+    Resource,
+}
+
+impl From<Leak> for LeakKind {
+    fn from(kind: Leak) -> Self {
+        match kind {
+            Leak::Goroutine => LeakKind::Goroutine,
+            Leak::Resource => LeakKind::Resource,
+        }
+    }
+}
+
+#[derive(Debug, Clone, Copy, ValueEnum)]
+pub enum Level {
+    /// Dependencies between packages (directories)
+    Package,
+    /// References between types
+    Type,
+}
+
+impl From<Level> for CycleLevel {
+    fn from(level: Level) -> Self {
+        match level {
+            Level::Package => CycleLevel::Package,
+            Level::Type => CycleLevel::Type,
+        }
+    }
+}
+
+/// Parse a root kind argument
+fn parse_root_kind(s: &str) -> Result<RootKind, String> {
+    s.parse()
+}
+
+/// Execute analyze commands
+pub async fn execute(cmd: AnalyzeCommand, global: GlobalOptions) -> Result<()> {
+    match cmd {
+        AnalyzeCommand::DeadCode(args) => execute_dead_code(args, global).await,
+        AnalyzeCommand::Impact(args) => execute_impact(args, global).await,
+        AnalyzeCommand::Clones(args) => execute_clones(args, global).await,
+        AnalyzeCommand::Interfaces(args) => execute_interfaces(args, global).await,
+        AnalyzeCommand::Coverage(args) => execute_coverage(args, global).await,
+        AnalyzeCommand::Cycles(args) => execute_cycles(args, global).await,
+        AnalyzeCommand::BuildDeps(args) => execute_build_deps(args, global).await,
+        AnalyzeCommand::Licenses(args) => execute_licenses(args, global).await,
+        AnalyzeCommand::UnusedExports(args) => execute_unused_exports(args, global).await,
+        AnalyzeCommand::Leaks(args) => execute_leaks(args, global).await,
+    }
+}
+
+async fn execute_dead_code(args: DeadCodeArgs, global: GlobalOptions) -> Result<()> {
+    let backend = create_backend(&global).await?;
+
+    let mut report = backend
+        .with_full_graph(|graph| {
+            // Symbols given by name may match several nodes; all become roots
+            let extra_roots = args
+                .extra_roots
+                .iter()
+                .flat_map(|symbol| resolve_symbol(graph, symbol))
+                .map(|node| node.id.clone())
+                .collect();
+
+            let options = DeadCodeOptions {
+                roots: if args.roots.is_empty() {
+                    RootKind::ALL.to_vec()
+                } else {
+                    args.roots.clone()
+                },
+                handler_patterns: args.handler_patterns.clone(),
+                extra_roots,
+            };
+            Ok(find_dead_code(graph, &options))
+        })
+        .await
+        .context("Failed to analyze dead code")?;
+
+    if !args.no_suppress {
+        report.apply_suppressions(backend.workspace_root());
+    }
+
+    let check = args
+        .policy
+        .check(&[("dead-code", report.dead.len() as f64)]);
+    match args.format {
+        ReportFormat::Json => {
+            check.print_json("analyze dead-code", serde_json::to_value(&report)?)?;
+        }
+        ReportFormat::Sarif => {
+            println!(
+                "{}",
+                serde_json::to_string_pretty(&dead_code_sarif(&report))?
+            );
+        }
+        ReportFormat::Text => print_dead_code(&report, &global),
+    }
+
+    check.enforce()
+}
+
+async fn execute_impact(args: ImpactArgs, global: GlobalOptions) -> Result<()> {
+    let patch = match args.patch {
+        Some(ref path) if path.as_os_str() == "-" => {
+            let mut patch = String::new();
+            std::io::stdin()
+                .read_to_string(&mut patch)
+                .context("Failed to read diff from stdin")?;
+            patch
+        }
+        Some(ref path) => std::fs::read_to_string(path)
+            .with_context(|| format!("Failed to read diff: {}", path.display()))?,
+        None => {
+            let workspace = resolve_workspace(&global).await?;
+            git_diff(&workspace, args.range.as_deref().unwrap_or("HEAD"))?
+        }
+    };
+
+    let changes = parse_unified_diff(&patch);
+    let options = ImpactOptions {
+        max_depth: args.depth,
+    };
+
+    let backend = create_backend(&global).await?;
+    let report = backend
+        .with_full_graph(|graph| Ok(analyze_impact(graph, &changes, &options)))
+        .await
+        .context("Failed to analyze impact")?;
+
+    let check = args.policy.check(&[
+        ("impacted", report.impacted.len() as f64),
+        ("impacted-tests", report.test_files.len() as f64),
+    ]);
+    if args.tests_only {
+        if args.output.is_json() {
+            println!("{}", serde_json::to_string_pretty(&report.test_files)?);
+        } else {
+            for file in &report.test_files {
+                println!("{}", file);
+            }
+        }
+    } else if args.output.is_json() {
+        check.print_json("analyze impact", serde_json::to_value(&report)?)?;
+    } else {
+        print_impact(&report, &global);
+    }
+
+    check.enforce()
+}
+
+async fn execute_clones(args: ClonesArgs, global: GlobalOptions) -> Result<()> {
+    let backend = create_backend(&global).await?;
+    let options = CloneOptions {
+        min_similarity: args.min_similarity,
+        min_tokens: args.min_tokens,
+    };
+
+    // Recompute from the stored fingerprints so thresholds can differ from
+    // the ones used for the graph's CLONE_OF edges
+    let mut pairs = backend
+        .with_full_graph(|graph| Ok(find_clones(graph, &options)))
+        .await
+        .context("Failed to detect clones")?;
+
+    if let Some(ref prefix) = args.package {
+        pairs.retain(|p| {
+            p.first.package.starts_with(prefix.as_str())
+                || p.second.package.starts_with(prefix.as_str())
+        });
+    }
+    if args.cross_package {
+        pairs.retain(ClonePair::is_cross_package);
+    }
+
+    let check = args.policy.check(&[("clones", pairs.len() as f64)]);
+    match args.format {
+        ReportFormat::Json => {
+            let output = serde_json::json!({
+                "pair_count": pairs.len(),
+                "pairs": pairs,
+            });
+            check.print_json("analyze clones", output)?;
+        }
+        ReportFormat::Sarif => {
+            println!("{}", serde_json::to_string_pretty(&clones_sarif(&pairs))?);
+        }
+        ReportFormat::Text => print_clones(&pairs, &global),
+    }
+
+    check.enforce()
+}
+
+async fn execute_interfaces(args: InterfacesArgs, global: GlobalOptions) -> Result<()> {
+    let backend = create_backend(&global).await?;
+    let root = backend.workspace_root().to_path_buf();
+    let options = InterfaceOptions {
+        min_methods: args.min_methods,
+    };
+
+    // Recompute from source so the threshold can differ from the one used
+    // for the graph's EQUIVALENT_TO and SUBSET_OF edges
+    let mut overlaps = backend
+        .with_full_graph(|graph| {
+            Ok(find_interface_overlaps(graph, &options, |file| {
+                std::fs::read_to_string(root.join(file)).ok()
+            }))
+        })
+        .await
+        .context("Failed to detect duplicate interfaces")?;
+
+    if let Some(ref prefix) = args.package {
+        overlaps.retain(|o| {
+            o.first.package.starts_with(prefix.as_str())
+                || o.second.package.starts_with(prefix.as_str())
+        });
+    }
+    let groups = consolidate(&overlaps);
+    let subsets: Vec<&InterfaceOverlap> = overlaps
+        .iter()
+        .filter(|o| o.kind == OverlapKind::Subset)
+        .collect();
+
+    let check = args
+        .policy
+        .check(&[("duplicate-interfaces", overlaps.len() as f64)]);
+    match args.format {
+        ReportFormat::Json => {
+            let output = serde_json::json!({
+                "group_count": groups.len(),
+                "groups": groups,
+                "subset_count": subsets.len(),
+                "subsets": subsets,
+            });
+            check.print_json("analyze interfaces", output)?;
+        }
+        ReportFormat::Sarif => {
+            println!(
+                "{}",
+                serde_json::to_string_pretty(&interfaces_sarif(&groups, &subsets))?
+            );
+        }
+        ReportFormat::Text => print_interfaces(&groups, &subsets, &global),
+    }
+
+    check.enforce()
+}
+
+async fn execute_coverage(args: CoverageArgs, global: GlobalOptions) -> Result<()> {
+    let profile = match args.profile {
+        Some(ref path) => Some(
+            CoverProfile::load(path)
+                .with_context(|| format!("Failed to load coverage: {}", path.display()))?,
+        ),
+        None => None,
+    };
+    let filter = CoverageFilter {
+        exported_only: args.exported,
+        max_percent: args.max_percent.map(f64::from),
+        min_fan_in: args.min_fan_in,
+    };
+
+    let backend = create_backend(&global).await?;
+    let mut report = backend
+        .with_full_graph(|graph| match profile {
+            Some(ref profile) => {
+                let mut graph = graph.clone();
+                profile.apply(&mut graph);
+                Ok(coverage_report(&graph, &filter))
+            }
+            None => Ok(coverage_report(graph, &filter)),
+        })
+        .await
+        .context("Failed to analyze coverage")?;
+
+    if let Some(ref prefix) = args.package {
+        report.retain(|f| f.package.starts_with(prefix.as_str()));
+    }
+    // Measured over all matching functions, not just the ones shown
+    let mut metrics = vec![(
+        "untested",
+        report.iter().filter(|f| f.covered == 0).count() as f64,
+    )];
+    let statements: u64 = report.iter().map(|f| u64::from(f.total)).sum();
+    if statements > 0 {
+        let covered: u64 = report.iter().map(|f| u64::from(f.covered)).sum();
+        metrics.push(("coverage", covered as f64 * 100.0 / statements as f64));
+    }
+    let check = args.policy.check(&metrics);
+
+    let total = report.len();
+    report.truncate(args.limit);
+
+    if args.output.is_json() {
+        let output = serde_json::json!({
+            "total": total,
+            "functions": report,
+        });
+        check.print_json("analyze coverage", output)?;
+        return check.enforce();
+    }
+
+    if report.is_empty() {
+        if !global.quiet {
+            println!(
+                "No matching functions. Index with `codeprysm update --coverage <profile>` \
+                 or pass --profile."
+            );
+        }
+        return check.enforce();
+    }
+
+    println!("{:>6}  {:>9}  {:>6}  Function", "Cover", "Stmts", "Fan-in");
+    for function in &report {
+        println!(
+            "{:>5.1}%  {:>4}/{:<4}  {:>6}  {} ({}:{}){}",
+            function.percent,
+            function.covered,
+            function.total,
+            function.fan_in,
+            function.name,
+            function.file,
+            function.line,
+            if function.exported {
+                ""
+            } else {
+                "  [unexported]"
+            }
+        );
+    }
+
+    if !global.quiet && total > report.len() {
+        println!("\n{} of {} function(s) shown", report.len(), total);
+    }
+
+    check.enforce()
+}
+
+async fn execute_cycles(args: CyclesArgs, global: GlobalOptions) -> Result<()> {
+    let backend = create_backend(&global).await?;
+    let level = CycleLevel::from(args.level);
+
+    let mut cycles = backend
+        .with_full_graph(|graph| Ok(find_cycles(graph, level)))
+        .await
+        .context("Failed to detect cycles")?;
+
+    if let Some(ref prefix) = args.package {
+        cycles.retain(|c| c.members.iter().any(|m| m.starts_with(prefix.as_str())));
+    }
+
+    let check = args.policy.check(&[("cycles", cycles.len() as f64)]);
+    match args.format {
+        ReportFormat::Json => {
+            let output = serde_json::json!({
+                "cycle_count": cycles.len(),
+                "cycles": cycles,
+            });
+            check.print_json("analyze cycles", output)?;
+        }
+        ReportFormat::Sarif => {
+            println!("{}", serde_json::to_string_pretty(&cycles_sarif(&cycles))?);
+        }
+        ReportFormat::Text => print_cycles(&cycles, &global),
+    }
+
+    check.enforce()?;
+    if !cycles.is_empty() {
+        anyhow::bail!("{} dependency cycle(s) found", cycles.len());
+    }
+
+    Ok(())
+}
+
+async fn execute_build_deps(args: BuildDepsArgs, global: GlobalOptions) -> Result<()> {
+    let input = if args.input.as_os_str() == "-" {
+        let mut input = String::new();
+        std::io::stdin()
+            .read_to_string(&mut input)
+            .context("Failed to read build graph from stdin")?;
+        input
+    } else {
+        std::fs::read_to_string(&args.input)
+            .with_context(|| format!("Failed to read build graph: {}", args.input.display()))?
+    };
+
+    let backend = create_backend(&global).await?;
+    let build = BuildGraph::parse(&input, backend.workspace_root())?;
+
+    let mut discrepancies = backend
+        .with_full_graph(|graph| Ok(reconcile_build_graph(graph, &build)))
+        .await
+        .context("Failed to reconcile build graph")?;
+
+    if let Some(kind) = args.kind {
+        let kind = DiscrepancyKind::from(kind);
+        discrepancies.retain(|d| d.kind == kind);
+    }
+    if let Some(ref prefix) = args.package {
+        discrepancies.retain(|d| d.from.starts_with(prefix.as_str()));
+    }
+
+    let count = |kind: DiscrepancyKind| discrepancies.iter().filter(|d| d.kind == kind).count();
+    let check = args.policy.check(&[
+        ("undeclared", count(DiscrepancyKind::Undeclared) as f64),
+        ("unused", count(DiscrepancyKind::Unused) as f64),
+    ]);
+    match args.format {
+        ReportFormat::Json => {
+            let output = serde_json::json!({
+                "build_system": build.system,
+                "package_count": build.packages.len(),
+                "discrepancy_count": discrepancies.len(),
+                "discrepancies": discrepancies,
+            });
+            check.print_json("analyze build-deps", output)?;
+        }
+        ReportFormat::Sarif => {
+            println!(
+                "{}",
+                serde_json::to_string_pretty(&build_deps_sarif(&discrepancies))?
+            );
+        }
+        ReportFormat::Text => print_build_deps(&discrepancies, build.packages.len(), &global),
+    }
+
+    check.enforce()?;
+    if !discrepancies.is_empty() {
+        anyhow::bail!(
+            "{} build dependency discrepancy(ies) found",
+            discrepancies.len()
+        );
+    }
+
+    Ok(())
+}
+
+/// Run `git diff` for a revision or range
+fn git_diff(workspace: &Path, range: &str) -> Result<String> {
+    let output = std::process::Command::new("git")
+        .args(["diff", "--unified=0", "--no-color", range])
+        .current_dir(workspace)
+        .output()
+        .context("Failed to run git diff")?;
+
+    if !output.status.success() {
+        anyhow::bail!(
+            "git diff failed: {}",
+            String::from_utf8_lossy(&output.stderr)
+        );
+    }
+
+    Ok(String::from_utf8_lossy(&output.stdout).into_owned())
+}
+
+/// Print an impact report as text
+fn print_impact(report: &ImpactReport, global: &GlobalOptions) {
+    if report.changed.is_empty() {
+        if !global.quiet {
+            println!("No indexed symbols touched by the diff.");
+        }
+        return;
+    }
+
+    println!("Changed symbols ({}):", report.changed.len());
+    for symbol in &report.changed {
+        print_impacted(symbol);
+    }
+
+    if !report.impacted.is_empty() {
+        println!("\nImpacted symbols ({}):", report.impacted.len());
+        for symbol in &report.impacted {
+            print_impacted(symbol);
+        }
+    }
+
+    if !report.entry_points.is_empty() {
+        println!("\nImpacted entry points ({}):", report.entry_points.len());
+        for symbol in &report.entry_points {
+            println!(
+                "  {}:{}  {} ({})",
+                symbol.file,
+                symbol.line,
+                symbol.name,
+                symbol.entry_point.as_deref().unwrap_or_default()
+            );
+        }
+    }
+
+    if !report.test_files.is_empty() {
+        println!("\nImpacted tests ({}):", report.tests.len());
+        for file in &report.test_files {
+            println!("  {}", file);
+        }
+    }
+
+    if !report.deleted_files.is_empty() && !global.quiet {
+        println!("\nDeleted files: {}", report.deleted_files.join(", "));
+    }
+}
+
+/// Print one changed or impacted symbol
+fn print_impacted(symbol: &ImpactedSymbol) {
+    println!(
+        "  [{}] {}:{}  {} ({})",
+        symbol.distance,
+        symbol.file,
+        symbol.line,
+        symbol.name,
+        symbol.kind.as_deref().unwrap_or(symbol.node_type.as_str())
+    );
+}
+
+/// Print clone pairs as text
+fn print_clones(pairs: &[ClonePair], global: &GlobalOptions) {
+    if pairs.is_empty() {
+        if !global.quiet {
+            println!("No clones found. Re-index to compute clone fingerprints.");
+        }
+        return;
+    }
+
+    for pair in pairs {
+        println!(
+            "{:>4}%  {}:{}-{} {}  <->  {}:{}-{} {}",
+            pair.similarity,
+            pair.first.file,
+            pair.first.line,
+            pair.first.end_line,
+            pair.first.name,
+            pair.second.file,
+            pair.second.line,
+            pair.second.end_line,
+            pair.second.name
+        );
+    }
+
+    if !global.quiet {
+        let cross = pairs.iter().filter(|p| p.is_cross_package()).count();
+        println!("\n{} clone pair(s), {} across packages", pairs.len(), cross);
+    }
+}
+
+/// Print equivalent interface groups and subset pairs as text
+fn print_interfaces(
+    groups: &[ConsolidationGroup],
+    subsets: &[&InterfaceOverlap],
+    global: &GlobalOptions,
+) {
+    if groups.is_empty() && subsets.is_empty() {
+        if !global.quiet {
+            println!("No duplicate interfaces found.");
+        }
+        return;
+    }
+
+    for group in groups {
+        println!(
+            "Keep {}:{} {} ({} use(s)), same methods as:",
+            group.canonical.file, group.canonical.line, group.canonical.name, group.canonical.uses
+        );
+        for duplicate in &group.duplicates {
+            println!(
+                "  {}:{} {} ({} use(s))",
+                duplicate.file, duplicate.line, duplicate.name, duplicate.uses
+            );
+        }
+        for method in &group.methods {
+            println!("    {}", method);
+        }
+    }
+    if !subsets.is_empty() {
+        if !groups.is_empty() {
+            println!();
+        }
+        println!("Subsets:");
+        for overlap in subsets {
+            println!(
+                "  {}:{} {}  <  {}:{} {}  (+{})",
+                overlap.first.file,
+                overlap.first.line,
+                overlap.first.name,
+                overlap.second.file,
+                overlap.second.line,
+                overlap.second.name,
+                overlap.extra_methods.join(", ")
+            );
+        }
+    }
+
+    if !global.quiet {
+        println!(
+            "\n{} group(s) of equivalent interfaces, {} subset pair(s)",
+            groups.len(),
+            subsets.len()
+        );
+    }
+}
+
+/// Print dependency cycles as text
+fn print_cycles(cycles: &[DependencyCycle], global: &GlobalOptions) {
+    if cycles.is_empty() {
+        if !global.quiet {
+            println!("No dependency cycles found.");
+        }
+        return;
+    }
+
+    for (i, cycle) in cycles.iter().enumerate() {
+        println!("Cycle {} ({} members):", i + 1, cycle.members.len());
+        for member in &cycle.members {
+            println!("  {}", if member.is_empty() { "." } else { member });
+        }
+        println!("  Break by removing:");
+        for edge in &cycle.break_edges {
+            println!(
+                "    {} -> {}  ({} reference(s), e.g. {}:{})",
+                edge.from, edge.to, edge.references, edge.file, edge.line
+            );
+        }
+        println!();
+    }
+
+    if !global.quiet {
+        let to_break: usize = cycles.iter().map(|c| c.break_edges.len()).sum();
+        println!(
+            "{} cycle(s), {} dependency(ies) to break",
+            cycles.len(),
+            to_break
+        );
+    }
+}
+
+/// Print build dependency discrepancies as text
+fn print_build_deps(discrepancies: &[BuildDiscrepancy], packages: usize, global: &GlobalOptions) {
+    if discrepancies.is_empty() {
+        if !global.quiet {
+            println!(
+                "Build dependencies of {} package(s) match the code.",
+                packages
+            );
+        }
+        return;
+    }
+
+    let display = |package: &str| {
+        if package.is_empty() {
+            ".".to_string()
+        } else {
+            package.to_string()
+        }
+    };
+    for kind in [DiscrepancyKind::Undeclared, DiscrepancyKind::Unused] {
+        let found: Vec<&BuildDiscrepancy> =
+            discrepancies.iter().filter(|d| d.kind == kind).collect();
+        if found.is_empty() {
+            continue;
+        }
+        match kind {
+            DiscrepancyKind::Undeclared => println!("Undeclared dependencies ({}):", found.len()),
+            DiscrepancyKind::Unused => println!("Unused declared dependencies ({}):", found.len()),
+        }
+        for d in found {
+            let (from, to) = (display(&d.from), display(&d.to));
+            match kind {
+                DiscrepancyKind::Undeclared => println!(
+                    "  {} -> {}  ({} reference(s), e.g. {}:{})",
+                    from, to, d.references, d.file, d.line
+                ),
+                DiscrepancyKind::Unused if d.file.is_empty() => println!("  {} -> {}", from, to),
+                DiscrepancyKind::Unused => {
+                    println!("  {} -> {}  (declared in {})", from, to, d.file)
+                }
+            }
+        }
+        println!();
+    }
+
+    if !global.quiet {
+        println!(
+            "{} discrepancy(ies) across {} build package(s)",
+            discrepancies.len(),
+            packages
+        );
+    }
+}
+
+async fn execute_licenses(args: LicensesArgs, global: GlobalOptions) -> Result<()> {
+    let backend = create_backend(&global).await?;
+
+    let mut packages = backend
+        .with_full_graph(|graph| Ok(license_report(graph)))
+        .await
+        .context("Failed to collect licenses")?;
+
+    if let Some(ref prefix) = args.package {
+        packages.retain(|p| p.package.starts_with(prefix.as_str()));
+    }
+    let conflicting = packages.iter().filter(|p| p.has_conflicts()).count();
+    if args.conflicts {
+        packages.retain(PackageLicenses::has_conflicts);
+    }
+
+    let check = args
+        .policy
+        .check(&[("license-conflicts", conflicting as f64)]);
+    match args.format {
+        ReportFormat::Json => {
+            let output = serde_json::json!({
+                "conflicting_packages": conflicting,
+                "packages": packages,
+            });
+            check.print_json("analyze licenses", output)?;
+        }
+        ReportFormat::Sarif => {
+            println!(
+                "{}",
+                serde_json::to_string_pretty(&licenses_sarif(&packages))?
+            );
+        }
+        ReportFormat::Text => print_licenses(&packages, conflicting, &global),
+    }
+
+    check.enforce()?;
+    if conflicting > 0 {
+        anyhow::bail!("{} package(s) mix incompatible licenses", conflicting);
+    }
+
+    Ok(())
+}
+
+/// Print the licenses of packages as text
+fn print_licenses(packages: &[PackageLicenses], conflicting: usize, global: &GlobalOptions) {
+    if packages.is_empty() {
+        if !global.quiet {
+            println!("No license headers found.");
+        }
+        return;
+    }
+
+    for package in packages {
+        let licenses: Vec<String> = package
+            .licenses
+            .iter()
+            .map(|(license, files)| format!("{} ({})", license, files.len()))
+            .collect();
+        let unlicensed = if package.unlicensed > 0 {
+            format!(", {} without a header", package.unlicensed)
+        } else {
+            String::new()
+        };
+        let name = if package.package.is_empty() {
+            "."
+        } else {
+            package.package.as_str()
+        };
+        println!("{}  {}{}", name, licenses.join(", "), unlicensed);
+        for conflict in &package.conflicts {
+            println!(
+                "  Conflict: {} ({}) with {} ({}): {}",
+                conflict.first,
+                conflict.first_file,
+                conflict.second,
+                conflict.second_file,
+                conflict.reason
+            );
+        }
+    }
+
+    if !global.quiet {
+        println!(
+            "\n{} package(s), {} mixing incompatible licenses",
+            packages.len(),
+            conflicting
+        );
+    }
+}
+
+async fn execute_unused_exports(args: UnusedExportsArgs, global: GlobalOptions) -> Result<()> {
+    let workspace_path = resolve_workspace(&global).await?;
+    let config = load_config(&global, &workspace_path)?;
+    let backend = create_backend(&global).await?;
+
+    let mut allow = config.analysis.external_api;
+    allow.extend(args.allow.iter().cloned());
+    let options = UnusedExportOptions {
+        allow,
+        include_tests: args.include_tests,
+    };
+    let mut report = backend
+        .with_full_graph(|graph| Ok(find_unused_exports(graph, &options)))
+        .await
+        .context("Failed to analyze unused exports")?;
+
+    if let Some(ref prefix) = args.package {
+        report
+            .unused
+            .retain(|symbol| symbol.package.starts_with(prefix.as_str()));
+    }
+    if !args.no_suppress {
+        report.apply_suppressions(backend.workspace_root());
+    }
+
+    let check = args
+        .policy
+        .check(&[("unused-exports", report.unused.len() as f64)]);
+    match args.format {
+        ReportFormat::Json => {
+            check.print_json("analyze unused-exports", serde_json::to_value(&report)?)?;
+        }
+        ReportFormat::Sarif => {
+            println!(
+                "{}",
+                serde_json::to_string_pretty(&unused_exports_sarif(&report))?
+            );
+        }
+        ReportFormat::Text => print_unused_exports(&report, &global),
+    }
+
+    check.enforce()
+}
+
+/// Print an unused export report as text
+fn print_unused_exports(report: &UnusedExportsReport, global: &GlobalOptions) {
+    if report.unused.is_empty() {
+        if !global.quiet {
+            println!(
+                "All {} exported symbol(s) are used by other packages.",
+                report.exported_count
+            );
+        }
+        return;
+    }
+
+    for symbol in &report.unused {
+        let internal = match symbol.internal_references {
+            0 => "unused".to_string(),
+            n => format!("{} use(s) in its package", n),
+        };
+        println!(
+            "  {}:{}  {} ({}, {})",
+            symbol.file,
+            symbol.line,
+            symbol.qualified_name(),
+            symbol.kind.as_deref().unwrap_or(symbol.node_type.as_str()),
+            internal
+        );
+    }
+
+    if !global.quiet {
+        println!(
+            "\n{} of {} exported symbol(s) unused outside their package, {} allowed, {} suppressed",
+            report.unused.len(),
+            report.exported_count,
+            report.allowed_count,
+            report.suppressed_count
+        );
+    }
+}
+
+async fn execute_leaks(args: LeaksArgs, global: GlobalOptions) -> Result<()> {
+    let backend = create_backend(&global).await?;
+
+    // Findings are recorded on their functions at indexing time
+    let mut findings = backend
+        .with_full_graph(|graph| Ok(leak_findings(graph)))
+        .await
+        .context("Failed to collect leak findings")?;
+
+    if let Some(kind) = args.kind {
+        let kind = LeakKind::from(kind);
+        findings.retain(|f| f.kind == kind);
+    }
+    if let Some(ref prefix) = args.package {
+        findings.retain(|f| package_of(&f.file).starts_with(prefix.as_str()));
+    }
+
+    let check = args.policy.check(&[("leaks", findings.len() as f64)]);
+    match args.format {
+        ReportFormat::Json => {
+            let output = serde_json::json!({
+                "finding_count": findings.len(),
+                "findings": findings,
+            });
+            check.print_json("analyze leaks", output)?;
+        }
+        ReportFormat::Sarif => {
+            println!("{}", serde_json::to_string_pretty(&leaks_sarif(&findings))?);
+        }
+        ReportFormat::Text => print_leaks(&findings, &global),
+    }
+
+    check.enforce()
+}
+
+/// Print leak findings as text
+fn print_leaks(findings: &[LeakFinding], global: &GlobalOptions) {
+    if findings.is_empty() {
+        if !global.quiet {
+            println!("No suspected leaks found.");
+        }
+        return;
+    }
+
+    for finding in findings {
+        println!(
+            "  {}:{}  [{}] {}",
+            finding.file,
+            finding.line,
+            finding.kind.rule(),
+            finding.message()
+        );
+    }
+
+    if !global.quiet {
+        let goroutines = findings
+            .iter()
+            .filter(|f| f.kind == LeakKind::Goroutine)
+            .count();
+        println!(
+            "\n{} suspected leak(s): {} goroutine(s), {} resource(s)",
+            findings.len(),
+            goroutines,
+            findings.len() - goroutines
+        );
+    }
+}
+
+/// Print a dead code report as text
+fn print_dead_code(report: &DeadCodeReport, global: &GlobalOptions) {
+    if !global.quiet {
+        println!(
+            "Reachability: {} roots, {} reachable symbols\n",
+            report.root_count, report.reachable_count
+        );
+    }
+
+    if report.dead.is_empty() {
+        if !global.quiet {
+            println!("No unreachable symbols found.");
+        }
+        return;
+    }
+
+    for symbol in &report.dead {
+        println!(
+            "  {}:{}  {} ({})",
+            symbol.file,
+            symbol.line,
+            symbol.name,
+            symbol.kind.as_deref().unwrap_or(symbol.node_type.as_str())
+        );
+    }
+
+    if !global.quiet {
+        println!(
+            "\n{} unreachable symbol(s), {} suppressed",
+            report.dead.len(),
+            report.suppressed_count
+        );
+    }
+}
+
+/// Convert a dead code report to a SARIF log
+fn dead_code_sarif(report: &DeadCodeReport) -> serde_json::Value {
+    let rules = [SarifRule {
+        id: DEAD_CODE_RULE.to_string(),
+        description: "Symbol is not reachable from any entry point, export, test, or handler"
+            .to_string(),
+    }];
+
+    let results: Vec<SarifResult> = report
+        .dead
+        .iter()
+        .map(|symbol| SarifResult {
+            rule_id: DEAD_CODE_RULE.to_string(),
+            level: "warning".to_string(),
+            message: format!("'{}' is never reached from any root", symbol.name),
+            file: symbol.file.clone(),
+            start_line: symbol.line,
+            end_line: symbol.end_line,
+        })
+        .collect();
+
+    sarif_log(&rules, &results)
+}
+
+/// Convert an unused export report to a SARIF log
+fn unused_exports_sarif(report: &UnusedExportsReport) -> serde_json::Value {
+    let rules = [SarifRule {
+        id: UNUSED_EXPORT_RULE.to_string(),
+        description: "Exported symbol is not used outside its package".to_string(),
+    }];
+
+    let results: Vec<SarifResult> = report
+        .unused
+        .iter()
+        .map(|symbol| SarifResult {
+            rule_id: UNUSED_EXPORT_RULE.to_string(),
+            level: "note".to_string(),
+            message: format!(
+                "'{}' is exported but no other package uses it",
+                symbol.qualified_name()
+            ),
+            file: symbol.file.clone(),
+            start_line: symbol.line,
+            end_line: symbol.end_line,
+        })
+        .collect();
+
+    sarif_log(&rules, &results)
+}
+
+/// Convert leak findings to a SARIF log
+fn leaks_sarif(findings: &[LeakFinding]) -> serde_json::Value {
+    let rules = [
+        SarifRule {
+            id: GOROUTINE_LEAK_RULE.to_string(),
+            description: "Goroutine has no context or done channel to stop it".to_string(),
+        },
+        SarifRule {
+            id: UNCLOSED_RESOURCE_RULE.to_string(),
+            description: "Opened resource is never closed or returned".to_string(),
+        },
+    ];
+
+    let results: Vec<SarifResult> = findings
+        .iter()
+        .map(|finding| SarifResult {
+            rule_id: finding.kind.rule().to_string(),
+            level: "warning".to_string(),
+            message: finding.message(),
+            file: finding.file.clone(),
+            start_line: finding.line,
+            end_line: finding.line,
+        })
+        .collect();
+
+    sarif_log(&rules, &results)
+}
+
+/// Convert clone pairs to a SARIF log, one finding per copy
+fn clones_sarif(pairs: &[ClonePair]) -> serde_json::Value {
+    let rules = [SarifRule {
+        id: CLONE_RULE.to_string(),
+        description: "Function body is a near-duplicate of another function".to_string(),
+    }];
+
+    let results: Vec<SarifResult> = pairs
+        .iter()
+        .flat_map(|pair| {
+            [(&pair.first, &pair.second), (&pair.second, &pair.first)].map(|(this, other)| {
+                SarifResult {
+                    rule_id: CLONE_RULE.to_string(),
+                    level: "note".to_string(),
+                    message: format!(
+                        "'{}' is {}% similar to '{}' ({}:{})",
+                        this.name, pair.similarity, other.name, other.file, other.line
+                    ),
+                    file: this.file.clone(),
+                    start_line: this.line,
+                    end_line: this.end_line,
+                }
+            })
+        })
+        .collect();
+
+    sarif_log(&rules, &results)
+}
+
+/// Convert duplicate interfaces to a SARIF log, one finding per interface
+/// that could be replaced
+fn interfaces_sarif(
+    groups: &[ConsolidationGroup],
+    subsets: &[&InterfaceOverlap],
+) -> serde_json::Value {
+    let rules = [SarifRule {
+        id: DUPLICATE_INTERFACE_RULE.to_string(),
+        description: "Interface duplicates the method set of an interface in another package"
+            .to_string(),
+    }];
+
+    let duplicates = groups.iter().flat_map(|group| {
+        group.duplicates.iter().map(|duplicate| SarifResult {
+            rule_id: DUPLICATE_INTERFACE_RULE.to_string(),
+            level: "note".to_string(),
+            message: format!(
+                "'{}' declares the same methods as '{}' ({}:{})",
+                duplicate.name, group.canonical.name, group.canonical.file, group.canonical.line
+            ),
+            file: duplicate.file.clone(),
+            start_line: duplicate.line,
+            end_line: duplicate.end_line,
+        })
+    });
+    let subsets = subsets.iter().map(|overlap| SarifResult {
+        rule_id: DUPLICATE_INTERFACE_RULE.to_string(),
+        level: "note".to_string(),
+        message: format!(
+            "'{}' declares a subset of the methods of '{}' ({}:{})",
+            overlap.first.name, overlap.second.name, overlap.second.file, overlap.second.line
+        ),
+        file: overlap.first.file.clone(),
+        start_line: overlap.first.line,
+        end_line: overlap.first.end_line,
+    });
+    let results: Vec<SarifResult> = duplicates.chain(subsets).collect();
+
+    sarif_log(&rules, &results)
+}
+
+/// Convert dependency cycles to a SARIF log, one finding per edge to break
+fn cycles_sarif(cycles: &[DependencyCycle]) -> serde_json::Value {
+    let rules = [SarifRule {
+        id: CYCLE_RULE.to_string(),
+        description: "Dependency closes a cycle between packages or types".to_string(),
+    }];
+
+    let results: Vec<SarifResult> = cycles
+        .iter()
+        .flat_map(|cycle| {
+            cycle.break_edges.iter().map(|edge| SarifResult {
+                rule_id: CYCLE_RULE.to_string(),
+                level: "warning".to_string(),
+                message: format!(
+                    "'{}' depends on '{}', closing a cycle of {} ({} reference(s))",
+                    edge.from,
+                    edge.to,
+                    cycle.members.join(", "),
+                    edge.references
+                ),
+                file: edge.file.clone(),
+                start_line: edge.line.max(1),
+                end_line: edge.line.max(1),
+            })
+        })
+        .collect();
+
+    sarif_log(&rules, &results)
+}
+
+/// Convert license conflicts to a SARIF log, one finding per conflict
+fn licenses_sarif(packages: &[PackageLicenses]) -> serde_json::Value {
+    let rules = [SarifRule {
+        id: LICENSE_CONFLICT_RULE.to_string(),
+        description: "Package combines files under incompatible licenses".to_string(),
+    }];
+
+    let results: Vec<SarifResult> = packages
+        .iter()
+        .flat_map(|package| &package.conflicts)
+        .map(|conflict| SarifResult {
+            rule_id: LICENSE_CONFLICT_RULE.to_string(),
+            level: "error".to_string(),
+            message: format!(
+                "{} file sits in a package with {} code ({}): {}",
+                conflict.second, conflict.first, conflict.first_file, conflict.reason
+            ),
+            file: conflict.second_file.clone(),
+            start_line: 1,
+            end_line: 1,
+        })
+        .collect();
+
+    sarif_log(&rules, &results)
+}
+
+/// Convert build dependency discrepancies to a SARIF log
+fn build_deps_sarif(discrepancies: &[BuildDiscrepancy]) -> serde_json::Value {
+    let rules = [
+        SarifRule {
+            id: UNDECLARED_DEPENDENCY_RULE.to_string(),
+            description: "Code references a package its build target does not depend on"
+                .to_string(),
+        },
+        SarifRule {
+            id: UNUSED_DEPENDENCY_RULE.to_string(),
+            description: "Declared build dependency is never referenced in code".to_string(),
+        },
+    ];
+
+    let results: Vec<SarifResult> = discrepancies
+        .iter()
+        .map(|d| {
+            let (rule_id, message) = match d.kind {
+                DiscrepancyKind::Undeclared => (
+                    UNDECLARED_DEPENDENCY_RULE,
+                    format!(
+                        "'{}' references '{}' ({} reference(s)) without declaring the dependency",
+                        d.from, d.to, d.references
+                    ),
+                ),
+                DiscrepancyKind::Unused => (
+                    UNUSED_DEPENDENCY_RULE,
+                    format!("'{}' declares a dependency on unused '{}'", d.from, d.to),
+                ),
+            };
+            SarifResult {
+                rule_id: rule_id.to_string(),
+                level: "warning".to_string(),
+                message,
+                file: d.file.clone(),
+                start_line: d.line.max(1),
+                end_line: d.line.max(1),
+            }
+        })
+        .collect();
+
+    sarif_log(&rules, &results)
+}
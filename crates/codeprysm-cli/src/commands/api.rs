@@ -0,0 +1,112 @@
+//! API command - Exported API surface report
+//!
+//! Lists every exported symbol with its signature, doc summary, and stability
+//! so library maintainers can review their public surface package by package.
+
+use std::collections::BTreeMap;
+
+use anyhow::{Context, Result};
+use clap::Args;
+use codeprysm_core::analysis::{api_surface, ApiSymbol, Stability};
+
+use super::create_backend;
+use crate::GlobalOptions;
+
+/// Arguments for the api command
+#[derive(Args, Debug)]
+pub struct ApiArgs {
+    /// Only include packages under this path prefix
+    #[arg(long, short = 'p')]
+    package: Option<String>,
+
+    /// Only include symbols with these stabilities, comma-separated: stable, experimental, deprecated
+    #[arg(long, value_delimiter = ',', value_parser = parse_stability)]
+    stability: Vec<Stability>,
+
+    /// Omit doc summaries from text output
+    #[arg(long)]
+    no_docs: bool,
+
+    /// Output as JSON
+    #[arg(long)]
+    json: bool,
+}
+
+/// Parse a stability argument
+fn parse_stability(s: &str) -> Result<Stability, String> {
+    s.parse()
+}
+
+/// Execute the api command
+pub async fn execute(args: ApiArgs, global: GlobalOptions) -> Result<()> {
+    let backend = create_backend(&global).await?;
+    let root = backend.workspace_root().to_path_buf();
+
+    let mut symbols = backend
+        .with_full_graph(|graph| Ok(api_surface(graph, &root)))
+        .await
+        .context("Failed to collect API surface")?;
+
+    if let Some(ref prefix) = args.package {
+        symbols.retain(|s| s.package.starts_with(prefix.as_str()));
+    }
+    if !args.stability.is_empty() {
+        symbols.retain(|s| args.stability.contains(&s.stability));
+    }
+
+    let mut packages: BTreeMap<&str, Vec<&ApiSymbol>> = BTreeMap::new();
+    for symbol in &symbols {
+        packages.entry(&symbol.package).or_default().push(symbol);
+    }
+
+    if args.json {
+        let output = serde_json::json!({
+            "symbol_count": symbols.len(),
+            "packages": packages,
+        });
+        println!("{}", serde_json::to_string_pretty(&output)?);
+        return Ok(());
+    }
+
+    if symbols.is_empty() {
+        if !global.quiet {
+            println!("No exported symbols found.");
+        }
+        return Ok(());
+    }
+
+    for (package, symbols) in &packages {
+        println!("{}", if package.is_empty() { "." } else { package });
+        for symbol in symbols {
+            let signature = symbol.signature.as_deref().unwrap_or(&symbol.name);
+            let stability = match symbol.stability {
+                Stability::Stable => String::new(),
+                other => format!("  [{}]", other.as_str()),
+            };
+            println!("  {}{}", signature, stability);
+            if !args.no_docs {
+                if let Some(ref doc) = symbol.doc {
+                    println!("      {}", doc);
+                }
+            }
+        }
+        println!();
+    }
+
+    if !global.quiet {
+        let deprecated = symbols
+            .iter()
+            .filter(|s| s.stability == Stability::Deprecated)
+            .count();
+        let undocumented = symbols.iter().filter(|s| s.doc.is_none()).count();
+        println!(
+            "{} exported symbol(s) in {} package(s); {} deprecated, {} undocumented",
+            symbols.len(),
+            packages.len(),
+            deprecated,
+            undocumented
+        );
+    }
+
+    Ok(())
+}
@@ -0,0 +1,149 @@
+//! API diff command - Detect breaking API changes between two revisions
+//!
+//! Indexes both revisions in the diff worktree (see the diff command),
+//! collects their exported API surfaces, and classifies every difference as
+//! breaking or compatible. Exits with an error when a breaking change is
+//! found, so it can gate releases in CI.
+
+use anyhow::Result;
+use clap::Args;
+use codeprysm_core::analysis::{api_surface, diff_api, ApiChange, Severity};
+
+use super::diff::{resolve_commit, RevisionIndex};
+use super::{load_config, resolve_workspace};
+use crate::progress::{finish_spinner, spinner};
+use crate::GlobalOptions;
+
+/// Arguments for the apidiff command
+#[derive(Args, Debug)]
+pub struct ApiDiffArgs {
+    /// Old version (commit, branch, or tag)
+    rev1: String,
+
+    /// New version to check against the old one
+    rev2: String,
+
+    /// Only include packages under this path prefix
+    #[arg(long, short = 'p')]
+    package: Option<String>,
+
+    /// Only list breaking changes
+    #[arg(long)]
+    breaking_only: bool,
+
+    /// Report breaking changes without failing
+    #[arg(long)]
+    allow_breaking: bool,
+
+    /// Output as JSON
+    #[arg(long)]
+    json: bool,
+}
+
+/// Execute the apidiff command
+pub async fn execute(args: ApiDiffArgs, global: GlobalOptions) -> Result<()> {
+    let workspace_path = resolve_workspace(&global).await?;
+    let config = load_config(&global, &workspace_path)?;
+
+    let old_commit = resolve_commit(&workspace_path, &args.rev1)?;
+    let new_commit = resolve_commit(&workspace_path, &args.rev2)?;
+
+    let mut revisions = RevisionIndex::new(&workspace_path, &config)?;
+
+    let pb = spinner(&format!("Indexing {}...", args.rev1), global.quiet);
+    let old_graph = revisions.index(&old_commit)?;
+    let old_api = api_surface(&old_graph, revisions.worktree());
+    finish_spinner(pb, &format!("Indexed {}", args.rev1));
+
+    let pb = spinner(&format!("Indexing {}...", args.rev2), global.quiet);
+    let new_graph = revisions.index(&new_commit)?;
+    let new_api = api_surface(&new_graph, revisions.worktree());
+    finish_spinner(pb, &format!("Indexed {}", args.rev2));
+
+    let mut diff = diff_api(&old_api, &new_api);
+    if let Some(ref prefix) = args.package {
+        diff.changes
+            .retain(|c| c.package.starts_with(prefix.as_str()));
+    }
+    if args.breaking_only {
+        diff.changes.retain(|c| c.severity == Severity::Breaking);
+    }
+    let breaking = diff.breaking_count();
+
+    if args.json {
+        let output = serde_json::json!({
+            "old": { "rev": args.rev1, "commit": old_commit },
+            "new": { "rev": args.rev2, "commit": new_commit },
+            "breaking_count": breaking,
+            "changes": diff.changes,
+        });
+        println!("{}", serde_json::to_string_pretty(&output)?);
+    } else {
+        print_changes(&diff.changes, breaking, &global);
+    }
+
+    if breaking > 0 && !args.allow_breaking {
+        anyhow::bail!(
+            "{} breaking API change(s) between {} and {}",
+            breaking,
+            args.rev1,
+            args.rev2
+        );
+    }
+
+    Ok(())
+}
+
+/// Print classified API changes as text, breaking changes first
+fn print_changes(changes: &[ApiChange], breaking: usize, global: &GlobalOptions) {
+    if changes.is_empty() {
+        if !global.quiet {
+            println!("No API changes.");
+        }
+        return;
+    }
+
+    for (severity, label) in [
+        (Severity::Breaking, "Breaking changes"),
+        (Severity::Compatible, "Compatible changes"),
+    ] {
+        let group: Vec<&ApiChange> = changes.iter().filter(|c| c.severity == severity).collect();
+        if group.is_empty() {
+            continue;
+        }
+
+        println!("{} ({}):", label, group.len());
+        for change in group {
+            let package = if change.package.is_empty() {
+                "."
+            } else {
+                change.package.as_str()
+            };
+            println!(
+                "  {:<24} {}.{} ({}:{})",
+                change.kind.as_str(),
+                package,
+                change.symbol,
+                change.file,
+                change.line
+            );
+            if change.old_signature != change.new_signature {
+                if let Some(ref old) = change.old_signature {
+                    println!("      - {}", old);
+                }
+                if let Some(ref new) = change.new_signature {
+                    println!("      + {}", new);
+                }
+            }
+        }
+        println!();
+    }
+
+    if !global.quiet {
+        println!(
+            "{} breaking, {} compatible change(s)",
+            breaking,
+            changes.len() - breaking
+        );
+    }
+}
@@ -0,0 +1,157 @@
+//! Archive command - Create and read compressed graph archives
+//!
+//! Packs the code graph into a single `.cpz` file for shipping between CI
+//! and consumers, and answers queries against an archive without
+//! decompressing all of it.
+
+use std::path::PathBuf;
+
+use anyhow::{Context, Result};
+use clap::{Args, Subcommand};
+use codeprysm_backend::BackendError;
+use codeprysm_core::GraphArchive;
+
+use super::{create_backend, load_config, load_provenance, resolve_workspace};
+use crate::GlobalOptions;
+
+/// Graph archive commands
+#[derive(Subcommand, Debug)]
+pub enum ArchiveCommand {
+    /// Write the code graph to a compressed archive
+    Create(CreateArgs),
+
+    /// Show an archive's size and packages
+    Info(InfoArgs),
+
+    /// Look up a node and its edges in an archive
+    Query(ArchiveQueryArgs),
+}
+
+#[derive(Args, Debug)]
+pub struct CreateArgs {
+    /// Output file
+    #[arg(long, short = 'o', default_value = "graph.cpz")]
+    output: PathBuf,
+}
+
+#[derive(Args, Debug)]
+pub struct InfoArgs {
+    /// Archive file
+    file: PathBuf,
+
+    /// Output as JSON
+    #[arg(long)]
+    json: bool,
+}
+
+#[derive(Args, Debug)]
+pub struct ArchiveQueryArgs {
+    /// Archive file
+    file: PathBuf,
+
+    /// Node ID (e.g., src/api.py:handle)
+    node_id: String,
+}
+
+/// Execute an archive command
+pub async fn execute(cmd: ArchiveCommand, global: GlobalOptions) -> Result<()> {
+    match cmd {
+        ArchiveCommand::Create(args) => execute_create(args, global).await,
+        ArchiveCommand::Info(args) => execute_info(args),
+        ArchiveCommand::Query(args) => execute_query(args),
+    }
+}
+
+async fn execute_create(args: CreateArgs, global: GlobalOptions) -> Result<()> {
+    let backend = create_backend(&global).await?;
+    let workspace_path = resolve_workspace(&global).await?;
+    let provenance = load_provenance(&load_config(&global, &workspace_path)?, &workspace_path);
+
+    let index = backend
+        .with_full_graph(|graph| {
+            GraphArchive::write(graph, provenance.as_ref(), &args.output)
+                .map_err(|e| BackendError::with_context("archive", e.to_string()))
+        })
+        .await
+        .with_context(|| format!("Failed to write {}", args.output.display()))?;
+
+    if !global.quiet {
+        println!(
+            "Wrote {} nodes and {} edges in {} packages to {}",
+            index.node_count,
+            index.edge_count,
+            index.chunks.len(),
+            args.output.display()
+        );
+    }
+
+    Ok(())
+}
+
+fn execute_info(args: InfoArgs) -> Result<()> {
+    let archive = GraphArchive::open(&args.file)
+        .with_context(|| format!("Failed to open {}", args.file.display()))?;
+    let index = archive.index();
+
+    if args.json {
+        println!("{}", serde_json::to_string_pretty(index)?);
+        return Ok(());
+    }
+
+    let size: u64 = index.chunks.iter().map(|c| c.size).sum();
+    println!("Schema version: {}", index.schema_version);
+    println!("Nodes:          {}", index.node_count);
+    println!("Edges:          {}", index.edge_count);
+    println!("Files:          {}", index.files.len());
+    println!("Packages:       {}", index.chunks.len());
+    println!("Chunk bytes:    {}", size);
+    if let Some(ref provenance) = index.provenance {
+        println!(
+            "Commit:         {}{}",
+            provenance.commit.as_deref().unwrap_or("-"),
+            if provenance.dirty == Some(true) {
+                " (dirty)"
+            } else {
+                ""
+            }
+        );
+        println!("Tool version:   {}", provenance.tool_version);
+        println!("Indexed at:     {}", provenance.indexed_at);
+    }
+    println!();
+    println!(
+        "{:<48} {:>8} {:>8} {:>10}",
+        "PACKAGE", "NODES", "EDGES", "BYTES"
+    );
+    for chunk in &index.chunks {
+        let package = if chunk.package.is_empty() {
+            "."
+        } else {
+            &chunk.package
+        };
+        println!(
+            "{:<48} {:>8} {:>8} {:>10}",
+            package, chunk.nodes, chunk.edges, chunk.size
+        );
+    }
+
+    Ok(())
+}
+
+fn execute_query(args: ArchiveQueryArgs) -> Result<()> {
+    let mut archive = GraphArchive::open(&args.file)
+        .with_context(|| format!("Failed to open {}", args.file.display()))?;
+
+    let node = archive
+        .get_node(&args.node_id)?
+        .with_context(|| format!("Node '{}' not found in archive", args.node_id))?;
+    let edges = archive.edges_of(&args.node_id)?;
+
+    let output = serde_json::json!({
+        "node": node,
+        "edges": edges,
+    });
+    println!("{}", serde_json::to_string_pretty(&output)?);
+
+    Ok(())
+}
@@ -0,0 +1,189 @@
+//! Bench command - Measure indexing throughput
+//!
+//! Indexes a directory without writing anything and reports parse, extract,
+//! and resolve throughput by language and by file size, so performance
+//! regressions across releases can be measured on the user's own code.
+//! `--profile` additionally writes a Chrome trace of per-file timings and a
+//! heap profile. Heap usage is only counted in builds with the `bench-alloc`
+//! feature, which installs a counting global allocator.
+
+use std::path::PathBuf;
+
+use anyhow::{Context, Result};
+use clap::Args;
+use codeprysm_core::bench::{run_benchmark, ThroughputRow};
+use codeprysm_core::builder::{BuilderConfig, GraphBuilder};
+use tracing::info;
+
+use super::clean::format_size;
+use super::{
+    attribute_rules, extraction_rules, file_policy, load_config, symbol_limits, FileFilterArgs,
+};
+use crate::progress::{finish_spinner, spinner};
+use crate::GlobalOptions;
+
+/// Files listed in the heap profile
+const HEAP_PROFILE_FILES: usize = 50;
+
+/// Shown in place of heap figures when allocations are not counted
+const HEAP_UNAVAILABLE: &str = "unavailable (build with --features bench-alloc)";
+
+/// Arguments for the bench command
+#[derive(Args, Debug)]
+pub struct BenchArgs {
+    /// Directory to benchmark (defaults to current directory)
+    #[arg(default_value = ".")]
+    path: PathBuf,
+
+    /// Stop after this many files
+    #[arg(long)]
+    max_files: Option<usize>,
+
+    /// Path to custom SCM queries directory
+    #[arg(long)]
+    queries: Option<PathBuf>,
+
+    /// Write a CPU trace (trace.json) and heap profile (heap.json) to this
+    /// directory
+    #[arg(long, value_name = "DIR")]
+    profile: Option<PathBuf>,
+
+    /// Output as JSON
+    #[arg(long)]
+    json: bool,
+
+    #[command(flatten)]
+    filter: FileFilterArgs,
+}
+
+/// Execute the bench command
+pub async fn execute(args: BenchArgs, global: GlobalOptions) -> Result<()> {
+    let path = args
+        .path
+        .canonicalize()
+        .with_context(|| format!("Failed to resolve {}", args.path.display()))?;
+    let mut config = load_config(&global, &path)?;
+    args.filter.apply(&mut config.analysis);
+
+    let builder_config = BuilderConfig {
+        skip_data_nodes: false,
+        max_containment_depth: None,
+        max_files: args.max_files,
+        exclude_patterns: config.analysis.exclude_patterns.clone(),
+        include_patterns: config.analysis.include_patterns.clone(),
+        file_policy: file_policy(&config.analysis),
+        attributes: attribute_rules(&config),
+        go_type_check: None,
+        extraction: extraction_rules(&config.analysis, &path)?,
+        symbol_limits: symbol_limits(&config.analysis),
+    };
+    let mut builder = match &args.queries {
+        Some(queries_dir) => {
+            info!("Using custom queries from: {}", queries_dir.display());
+            GraphBuilder::with_config(queries_dir, builder_config)
+                .context("Failed to create graph builder")?
+        }
+        None => GraphBuilder::with_embedded_queries(builder_config),
+    };
+
+    let pb = spinner("Benchmarking...", global.quiet || args.json);
+    let report = run_benchmark(&mut builder, &path).context("Benchmark failed")?;
+    finish_spinner(
+        pb,
+        &format!(
+            "Indexed {} files in {:.0} ms",
+            report.files, report.total_ms
+        ),
+    );
+
+    if let Some(dir) = &args.profile {
+        std::fs::create_dir_all(dir)
+            .with_context(|| format!("Failed to create {}", dir.display()))?;
+        let trace_path = dir.join("trace.json");
+        let file = std::fs::File::create(&trace_path)
+            .with_context(|| format!("Failed to create {}", trace_path.display()))?;
+        report.write_trace(std::io::BufWriter::new(file))?;
+
+        if report.heap.is_some() {
+            let heap_path = dir.join("heap.json");
+            let file = std::fs::File::create(&heap_path)
+                .with_context(|| format!("Failed to create {}", heap_path.display()))?;
+            report.write_heap_profile(std::io::BufWriter::new(file), HEAP_PROFILE_FILES)?;
+        } else if !global.quiet && !args.json {
+            println!("Heap profile {}", HEAP_UNAVAILABLE);
+        }
+        if !global.quiet && !args.json {
+            println!("Wrote profile to {}", dir.display());
+        }
+    }
+
+    if args.json {
+        println!("{}", serde_json::to_string_pretty(&report)?);
+        return Ok(());
+    }
+
+    println!(
+        "codeprysm {} - {} files, {}, {} nodes",
+        report.version,
+        report.files,
+        format_size(report.bytes),
+        report.nodes
+    );
+    if report.parse_errors > 0 {
+        println!("  {} files failed to parse", report.parse_errors);
+    }
+
+    println!("\nBy language:");
+    print_rows(&report.languages);
+    println!("\nBy file size:");
+    print_rows(&report.sizes);
+    println!();
+    print_rows(std::slice::from_ref(&report.overall));
+
+    println!("\nReference resolution:");
+    println!(
+        "  {} references, {} resolved in {:.1} ms ({:.0} refs/s)",
+        report.resolve.references,
+        report.resolve.resolved,
+        report.resolve.resolve_ms,
+        report.resolve.references_per_sec()
+    );
+
+    if let Some(heap) = &report.heap {
+        println!("\nHeap:");
+        println!(
+            "  Peak while extracting: {}",
+            format_size(heap.extract_peak_bytes as u64)
+        );
+        println!(
+            "  Peak while resolving:  {}",
+            format_size(heap.resolve_peak_bytes as u64)
+        );
+        println!(
+            "  Total allocated:       {}",
+            format_size(heap.allocated_bytes as u64)
+        );
+    } else {
+        println!("\nHeap: {}", HEAP_UNAVAILABLE);
+    }
+
+    Ok(())
+}
+
+fn print_rows(rows: &[ThroughputRow]) {
+    println!(
+        "  {:<12} {:>7} {:>10} {:>13} {:>13} {:>12}",
+        "", "FILES", "SIZE", "PARSE MiB/s", "EXTRACT MiB/s", "LINES/s"
+    );
+    for row in rows {
+        println!(
+            "  {:<12} {:>7} {:>10} {:>13.2} {:>13.2} {:>12.0}",
+            row.label,
+            row.files,
+            format_size(row.bytes),
+            row.parse_mib_per_sec(),
+            row.extract_mib_per_sec(),
+            row.lines_per_sec()
+        );
+    }
+}
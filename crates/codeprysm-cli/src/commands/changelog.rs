@@ -0,0 +1,110 @@
+//! Changelog command - Symbol-level changelog between two revisions
+//!
+//! Indexes both revisions in the diff worktree (see the diff command) and
+//! lists the exported symbols added, removed, and changed between them,
+//! grouped by package with signatures and doc summaries, as Markdown release
+//! notes or JSON.
+
+use std::path::PathBuf;
+
+use anyhow::{Context, Result};
+use clap::{Args, ValueEnum};
+use codeprysm_core::analysis::{api_surface, changelog};
+
+use super::diff::{resolve_commit, RevisionIndex};
+use super::{load_config, resolve_workspace};
+use crate::progress::{finish_spinner, spinner};
+use crate::GlobalOptions;
+
+/// Changelog output format
+#[derive(Debug, Clone, Copy, PartialEq, Eq, ValueEnum)]
+pub enum ChangelogFormat {
+    /// Markdown release notes
+    Markdown,
+    /// JSON, grouped by package
+    Json,
+}
+
+/// Arguments for the changelog command
+#[derive(Args, Debug)]
+pub struct ChangelogArgs {
+    /// Old version (commit, branch, or tag)
+    rev1: String,
+
+    /// New version
+    rev2: String,
+
+    /// Only include packages under this path prefix
+    #[arg(long, short = 'p')]
+    package: Option<String>,
+
+    /// Output format
+    #[arg(long, short = 'f', value_enum, default_value = "markdown")]
+    format: ChangelogFormat,
+
+    /// Heading of the Markdown changelog (defaults to the new version)
+    #[arg(long)]
+    title: Option<String>,
+
+    /// Write to a file instead of stdout
+    #[arg(long, short = 'o')]
+    output: Option<PathBuf>,
+}
+
+/// Execute the changelog command
+pub async fn execute(args: ChangelogArgs, global: GlobalOptions) -> Result<()> {
+    let workspace_path = resolve_workspace(&global).await?;
+    let config = load_config(&global, &workspace_path)?;
+
+    let old_commit = resolve_commit(&workspace_path, &args.rev1)?;
+    let new_commit = resolve_commit(&workspace_path, &args.rev2)?;
+
+    let mut revisions = RevisionIndex::new(&workspace_path, &config)?;
+
+    let pb = spinner(&format!("Indexing {}...", args.rev1), global.quiet);
+    let old_graph = revisions.index(&old_commit)?;
+    let old_api = api_surface(&old_graph, revisions.worktree());
+    finish_spinner(pb, &format!("Indexed {}", args.rev1));
+
+    let pb = spinner(&format!("Indexing {}...", args.rev2), global.quiet);
+    let new_graph = revisions.index(&new_commit)?;
+    let new_api = api_surface(&new_graph, revisions.worktree());
+    finish_spinner(pb, &format!("Indexed {}", args.rev2));
+
+    let mut log = changelog(&old_api, &new_api);
+    if let Some(ref prefix) = args.package {
+        log.packages
+            .retain(|p| p.package.starts_with(prefix.as_str()));
+    }
+
+    let rendered = match args.format {
+        ChangelogFormat::Markdown => log.to_markdown(args.title.as_deref().unwrap_or(&args.rev2)),
+        ChangelogFormat::Json => {
+            let output = serde_json::json!({
+                "old": { "rev": args.rev1, "commit": old_commit },
+                "new": { "rev": args.rev2, "commit": new_commit },
+                "breaking_count": log.breaking_count(),
+                "packages": log.packages,
+            });
+            format!("{}\n", serde_json::to_string_pretty(&output)?)
+        }
+    };
+
+    match args.output {
+        Some(ref path) => {
+            std::fs::write(path, &rendered)
+                .with_context(|| format!("Failed to write {}", path.display()))?;
+            if !global.quiet {
+                println!(
+                    "Wrote {} change(s) in {} package(s) to {}",
+                    log.len(),
+                    log.packages.len(),
+                    path.display()
+                );
+            }
+        }
+        None => print!("{}", rendered),
+    }
+
+    Ok(())
+}
@@ -0,0 +1,587 @@
+//! Check command - Enforce policies on pending changes
+//!
+//! Applies only the changed files to a copy of the cached index and fails if
+//! the changes introduce findings the index does not already have: newly
+//! unreachable (dead) code, references into `internal` directories from
+//! outside their tree, dependencies breaking the architecture rules file, or
+//! functions growing past the configured complexity limits. Policies are
+//! configured in the `[check]` section of the config. With `--all`, the
+//! whole index is checked against the architecture and boundary rules
+//! instead.
+//!
+//! Nothing but the changed files is parsed, so the check is fast enough for
+//! git hooks:
+//!
+//! ```bash
+//! # .git/hooks/pre-commit
+//! exec codeprysm check --staged
+//!
+//! # .git/hooks/pre-push
+//! exec codeprysm check --since @{upstream}
+//! ```
+
+use std::collections::{HashMap, HashSet};
+use std::fs;
+use std::path::{Path, PathBuf};
+
+use anyhow::{bail, Context, Result};
+use clap::Args;
+use codeprysm_config::{CheckConfig, PrismConfig};
+use codeprysm_core::analysis::dead_code::{is_suppressed, DEAD_CODE_RULE};
+use codeprysm_core::analysis::{
+    check_architecture, find_boundary_violations, find_new_dead_code, function_metrics,
+    ArchViolation, ArchitectureRules, BoundaryViolation, ComplexityMetric, DeadCodeOptions,
+    DeadSymbol, FunctionMetrics,
+};
+use codeprysm_core::builder::BuilderConfig;
+use codeprysm_core::{
+    ChangeSet, ExclusionFilter, IncrementalUpdater, PetCodeGraph, SupportedLanguage,
+};
+use serde::Serialize;
+
+use super::diff::{git, resolve_commit};
+use super::{
+    attribute_rules, extraction_rules, file_policy, load_config, resolve_workspace, symbol_limits,
+};
+use crate::GlobalOptions;
+
+/// Arguments for the check command
+#[derive(Args, Debug)]
+pub struct CheckArgs {
+    /// Check staged changes instead of uncommitted ones (for pre-commit hooks)
+    #[arg(long, conflicts_with = "since")]
+    staged: bool,
+
+    /// Check changes committed since a revision (for pre-push hooks)
+    #[arg(long, value_name = "REV")]
+    since: Option<String>,
+
+    /// Check the whole index against the architecture and internal boundary
+    /// rules, reporting existing violations too
+    #[arg(long, conflicts_with_all = ["staged", "since"])]
+    all: bool,
+
+    /// Architecture rules file (overrides `check.architecture_rules`)
+    #[arg(long, value_name = "FILE")]
+    rules: Option<PathBuf>,
+
+    /// Maximum cyclomatic complexity (overrides the config)
+    #[arg(long)]
+    max_cyclomatic: Option<u32>,
+
+    /// Maximum cognitive complexity (overrides the config)
+    #[arg(long)]
+    max_cognitive: Option<u32>,
+
+    /// Output as JSON
+    #[arg(long)]
+    json: bool,
+}
+
+/// A function that grew past a complexity limit.
+#[derive(Debug, Serialize)]
+struct ComplexityRegression {
+    id: String,
+    name: String,
+    file: String,
+    line: usize,
+    metric: &'static str,
+    /// Complexity in the index (`None` for new functions)
+    before: Option<u32>,
+    after: u32,
+    max: u32,
+}
+
+/// Findings introduced by the changes
+#[derive(Debug, Default, Serialize)]
+struct CheckReport {
+    dead_code: Vec<DeadSymbol>,
+    boundary_violations: Vec<BoundaryViolation>,
+    architecture_violations: Vec<ArchViolation>,
+    complexity_regressions: Vec<ComplexityRegression>,
+}
+
+impl CheckReport {
+    fn violation_count(&self) -> usize {
+        self.dead_code.len()
+            + self.boundary_violations.len()
+            + self.architecture_violations.len()
+            + self.complexity_regressions.len()
+    }
+}
+
+/// Execute the check command
+pub async fn execute(args: CheckArgs, global: GlobalOptions) -> Result<()> {
+    let workspace_path = resolve_workspace(&global).await?;
+    let config = load_config(&global, &workspace_path)?;
+    let prism_dir = config.prism_dir(&workspace_path);
+    if !prism_dir.join("manifest.json").exists() {
+        bail!(
+            "Workspace not initialized. Run 'codeprysm init' first.\n  Path: {}",
+            workspace_path.display()
+        );
+    }
+
+    let mut policy = config.check.clone();
+    policy.max_cyclomatic = args.max_cyclomatic.or(policy.max_cyclomatic);
+    policy.max_cognitive = args.max_cognitive.or(policy.max_cognitive);
+    let rules = load_rules(&workspace_path, &policy, args.rules.as_deref())?;
+
+    if args.all {
+        let updater = load_index(&workspace_path, &prism_dir, &config)?;
+        let graph = updater.graph().context("Failed to load the index")?;
+        let mut report = CheckReport::default();
+        if policy.internal_boundaries {
+            report.boundary_violations = find_boundary_violations(graph);
+        }
+        if let Some(rules) = &rules {
+            report.architecture_violations = check_architecture(graph, rules);
+        }
+        return finish(&report, args.json, global.quiet);
+    }
+
+    // Changed files as git reports them, before anything expensive
+    let since = args
+        .since
+        .as_deref()
+        .map(|rev| resolve_commit(&workspace_path, rev))
+        .transpose()?;
+    let diff_args: Vec<&str> = match (args.staged, since.as_deref()) {
+        (true, _) => vec!["diff", "--cached"],
+        (false, Some(commit)) => vec!["diff", commit, "HEAD"],
+        (false, None) => vec!["diff", "HEAD"],
+    };
+    let (changed, deleted) = changed_files(&workspace_path, &diff_args)?;
+    if changed.is_empty() && deleted.is_empty() {
+        return finish(&CheckReport::default(), args.json, global.quiet);
+    }
+
+    // Content of changed files as it will be committed or pushed
+    let source_root = if args.staged {
+        let dir = prism_dir.join("check");
+        materialize_staged(&workspace_path, &dir, &changed)?;
+        dir
+    } else if since.is_some() {
+        let dir = prism_dir.join("check");
+        materialize_commit(&workspace_path, &dir, "HEAD", &changed)?;
+        dir
+    } else {
+        workspace_path.clone()
+    };
+
+    let mut updater = load_index(&workspace_path, &prism_dir, &config)?;
+    let baseline = updater.graph().context("Failed to load the index")?;
+    // Node IDs in multi-root indexes are relative to each root, not the workspace
+    if baseline.iter_nodes().any(|n| n.is_workspace()) {
+        bail!("Check supports single-root workspaces only.");
+    }
+
+    let mut changes = ChangeSet::new();
+    for path in changed {
+        if baseline.get_node(&path).is_some_and(|n| n.is_file()) {
+            changes.modified.push(path);
+        } else {
+            changes.added.push(path);
+        }
+    }
+    changes.deleted = deleted;
+
+    let after = updater
+        .preview_changes(&source_root, &changes)
+        .context("Failed to apply changes to the index")?;
+    let before = updater.graph().context("Failed to load the index")?;
+
+    let mut report = CheckReport::default();
+    if policy.dead_code {
+        report.dead_code = find_new_dead_code(before, &after, &DeadCodeOptions::default()).dead;
+        let sources = SourceFiles {
+            workspace: &workspace_path,
+            source_root: &source_root,
+            changed: changes
+                .files_to_process()
+                .into_iter()
+                .map(String::from)
+                .collect(),
+        };
+        report
+            .dead_code
+            .retain(|symbol| !sources.suppresses(symbol));
+    }
+    if policy.internal_boundaries {
+        report.boundary_violations = new_boundary_violations(before, &after);
+    }
+    if let Some(rules) = &rules {
+        report.architecture_violations = new_architecture_violations(before, &after, rules);
+    }
+    report.complexity_regressions = complexity_regressions(before, &after, &policy);
+
+    finish(&report, args.json, global.quiet)
+}
+
+/// Read the architecture rules: the `--rules` file, or the configured one if
+/// it exists
+fn load_rules(
+    workspace: &Path,
+    policy: &CheckConfig,
+    path: Option<&Path>,
+) -> Result<Option<ArchitectureRules>> {
+    let path = match path {
+        Some(path) => path.to_path_buf(),
+        None if policy.architecture_rules.is_empty() => return Ok(None),
+        None => {
+            let path = workspace.join(&policy.architecture_rules);
+            if !path.exists() {
+                return Ok(None);
+            }
+            path
+        }
+    };
+    ArchitectureRules::load(&path)
+        .map(Some)
+        .with_context(|| format!("Failed to load {}", path.display()))
+}
+
+/// Open the cached index for checking
+fn load_index(
+    workspace: &Path,
+    prism_dir: &Path,
+    config: &PrismConfig,
+) -> Result<IncrementalUpdater> {
+    let builder_config = BuilderConfig {
+        exclude_patterns: config.analysis.exclude_patterns.clone(),
+        include_patterns: config.analysis.include_patterns.clone(),
+        file_policy: file_policy(&config.analysis),
+        attributes: attribute_rules(config),
+        extraction: extraction_rules(&config.analysis, workspace)?,
+        symbol_limits: symbol_limits(&config.analysis),
+        ..BuilderConfig::default()
+    };
+    let mut updater = IncrementalUpdater::with_embedded_queries(
+        workspace,
+        prism_dir,
+        ExclusionFilter::default(),
+        builder_config,
+    )
+    .context("Failed to create incremental updater")?;
+    updater
+        .load_graph_state()
+        .context("Failed to load the index")?;
+    Ok(updater)
+}
+
+/// List changed and deleted source files from `git <diff_args> --name-status`
+fn changed_files(workspace: &Path, diff_args: &[&str]) -> Result<(Vec<String>, Vec<String>)> {
+    let mut command = diff_args.to_vec();
+    command.extend(["--name-status", "--no-renames", "-z"]);
+    let output = git(workspace, &command)?;
+
+    let mut changed = Vec::new();
+    let mut deleted = Vec::new();
+    let mut fields = output.split('\0').filter(|f| !f.is_empty());
+    while let (Some(status), Some(path)) = (fields.next(), fields.next()) {
+        if SupportedLanguage::from_path(Path::new(path)).is_none() {
+            continue;
+        }
+        if status.starts_with('D') {
+            deleted.push(path.to_string());
+        } else {
+            changed.push(path.to_string());
+        }
+    }
+    Ok((changed, deleted))
+}
+
+/// Write the staged content of `files` below `dir`
+fn materialize_staged(workspace: &Path, dir: &Path, files: &[String]) -> Result<()> {
+    reset_dir(dir)?;
+    let prefix = format!("{}/", dir.to_string_lossy());
+    let mut command = vec!["checkout-index", "--force", "--prefix", &prefix, "--"];
+    command.extend(files.iter().map(String::as_str));
+    git(workspace, &command)?;
+    Ok(())
+}
+
+/// Write the content of `files` at `commit` below `dir`
+fn materialize_commit(workspace: &Path, dir: &Path, commit: &str, files: &[String]) -> Result<()> {
+    reset_dir(dir)?;
+    for file in files {
+        let content = git(workspace, &["show", &format!("{}:{}", commit, file)])?;
+        let path = dir.join(file);
+        if let Some(parent) = path.parent() {
+            fs::create_dir_all(parent)?;
+        }
+        fs::write(&path, content).with_context(|| format!("Failed to write {}", path.display()))?;
+    }
+    Ok(())
+}
+
+fn reset_dir(dir: &Path) -> Result<()> {
+    if dir.exists() {
+        fs::remove_dir_all(dir).with_context(|| format!("Failed to clear {}", dir.display()))?;
+    }
+    fs::create_dir_all(dir).with_context(|| format!("Failed to create {}", dir.display()))
+}
+
+/// Boundary violations present after the changes but not before
+fn new_boundary_violations(before: &PetCodeGraph, after: &PetCodeGraph) -> Vec<BoundaryViolation> {
+    let known: HashSet<(String, String)> = find_boundary_violations(before)
+        .into_iter()
+        .map(|v| (v.source, v.target))
+        .collect();
+    find_boundary_violations(after)
+        .into_iter()
+        .filter(|v| !known.contains(&(v.source.clone(), v.target.clone())))
+        .collect()
+}
+
+/// Architecture violations present after the changes but not before
+fn new_architecture_violations(
+    before: &PetCodeGraph,
+    after: &PetCodeGraph,
+    rules: &ArchitectureRules,
+) -> Vec<ArchViolation> {
+    let key = |v: &ArchViolation| {
+        (
+            v.from_layer.clone(),
+            v.to_layer.clone(),
+            v.source.clone(),
+            v.target.clone(),
+        )
+    };
+    let known: HashSet<_> = check_architecture(before, rules).iter().map(key).collect();
+    check_architecture(after, rules)
+        .into_iter()
+        .filter(|v| !known.contains(&key(v)))
+        .collect()
+}
+
+/// Functions over a complexity limit whose complexity grew with the changes
+fn complexity_regressions(
+    before: &PetCodeGraph,
+    after: &PetCodeGraph,
+    policy: &CheckConfig,
+) -> Vec<ComplexityRegression> {
+    let limits = [
+        (
+            "cyclomatic",
+            policy.max_cyclomatic,
+            ComplexityMetric::Cyclomatic,
+        ),
+        (
+            "cognitive",
+            policy.max_cognitive,
+            ComplexityMetric::Cognitive,
+        ),
+    ];
+    if limits.iter().all(|(_, max, _)| max.is_none()) {
+        return Vec::new();
+    }
+
+    let old: HashMap<String, FunctionMetrics> =
+        function_metrics(before, ComplexityMetric::default())
+            .into_iter()
+            .map(|m| (m.id.clone(), m))
+            .collect();
+
+    let mut regressions = Vec::new();
+    for m in function_metrics(after, ComplexityMetric::default()) {
+        for (name, max, metric) in limits {
+            let Some(max) = max else {
+                continue;
+            };
+            let value = m.value(metric);
+            let previous = old.get(&m.id).map(|o| o.value(metric));
+            if value > max && previous.map_or(true, |p| p < value) {
+                regressions.push(ComplexityRegression {
+                    id: m.id.clone(),
+                    name: m.name.clone(),
+                    file: m.file.clone(),
+                    line: m.line,
+                    metric: name,
+                    before: previous,
+                    after: value,
+                    max,
+                });
+            }
+        }
+    }
+    regressions.sort_by(|a, b| (&a.file, a.line, a.metric).cmp(&(&b.file, b.line, b.metric)));
+    regressions
+}
+
+/// Where to read a file's checked content from
+struct SourceFiles<'a> {
+    workspace: &'a Path,
+    source_root: &'a Path,
+    changed: HashSet<String>,
+}
+
+impl SourceFiles<'_> {
+    fn path(&self, file: &str) -> PathBuf {
+        if self.changed.contains(file) {
+            self.source_root.join(file)
+        } else {
+            self.workspace.join(file)
+        }
+    }
+
+    /// Check if a dead symbol carries a suppression comment
+    fn suppresses(&self, symbol: &DeadSymbol) -> bool {
+        fs::read_to_string(self.path(&symbol.file)).is_ok_and(|content| {
+            let lines: Vec<String> = content.lines().map(String::from).collect();
+            is_suppressed(&lines, symbol.line, DEAD_CODE_RULE)
+        })
+    }
+}
+
+/// Print the report and fail if it has findings
+fn finish(report: &CheckReport, json: bool, quiet: bool) -> Result<()> {
+    if json {
+        println!("{}", serde_json::to_string_pretty(report)?);
+    } else {
+        print_report(report, quiet);
+    }
+
+    let count = report.violation_count();
+    if count > 0 {
+        bail!("{} policy violation(s)", count);
+    }
+    Ok(())
+}
+
+fn print_report(report: &CheckReport, quiet: bool) {
+    if report.violation_count() == 0 {
+        if !quiet {
+            println!("No policy violations.");
+        }
+        return;
+    }
+
+    if !report.dead_code.is_empty() {
+        println!("New dead code ({}):", report.dead_code.len());
+        for symbol in &report.dead_code {
+            println!("  {} ({}:{})", symbol.name, symbol.file, symbol.line);
+        }
+    }
+    if !report.boundary_violations.is_empty() {
+        println!(
+            "Internal boundary violations ({}):",
+            report.boundary_violations.len()
+        );
+        for v in &report.boundary_violations {
+            let root = if v.allowed_root.is_empty() {
+                "."
+            } else {
+                v.allowed_root.as_str()
+            };
+            println!(
+                "  {} -> {} ({}:{}), internal to {}",
+                v.source, v.target, v.file, v.line, root
+            );
+        }
+    }
+    if !report.architecture_violations.is_empty() {
+        println!(
+            "Architecture violations ({}):",
+            report.architecture_violations.len()
+        );
+        for v in &report.architecture_violations {
+            let reason = v
+                .reason
+                .as_deref()
+                .map_or_else(String::new, |r| format!(": {}", r));
+            println!(
+                "  {} -> {} ({}:{}), {} may not use {}{}",
+                v.source, v.target, v.file, v.line, v.from_layer, v.to_layer, reason
+            );
+            if v.path.len() > 2 {
+                println!("    via {}", v.path.join(" -> "));
+            }
+        }
+    }
+    if !report.complexity_regressions.is_empty() {
+        println!(
+            "Complexity regressions ({}):",
+            report.complexity_regressions.len()
+        );
+        for r in &report.complexity_regressions {
+            let before = r.before.map_or("new".to_string(), |b| b.to_string());
+            println!(
+                "  {} ({}:{}) {} {} -> {} (max {})",
+                r.name, r.file, r.line, r.metric, before, r.after, r.max
+            );
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use codeprysm_core::{CallableKind, EdgeData, Node};
+
+    fn graph_with(cyclomatic: u32) -> PetCodeGraph {
+        let mut graph = PetCodeGraph::new();
+        let mut node = Node::callable(
+            "a.go:Run".to_string(),
+            "Run".to_string(),
+            CallableKind::Function,
+            "a.go".to_string(),
+            1,
+            10,
+        );
+        node.metadata.cyclomatic_complexity = Some(cyclomatic);
+        node.metadata.cognitive_complexity = Some(1);
+        graph.add_node(node);
+        graph
+    }
+
+    #[test]
+    fn test_complexity_regressions() {
+        let policy = CheckConfig {
+            max_cyclomatic: Some(10),
+            ..CheckConfig::default()
+        };
+
+        let regressions = complexity_regressions(&graph_with(8), &graph_with(12), &policy);
+        assert_eq!(regressions.len(), 1);
+        assert_eq!(regressions[0].before, Some(8));
+        assert_eq!(regressions[0].after, 12);
+
+        // Already over the limit and not growing
+        assert!(complexity_regressions(&graph_with(14), &graph_with(12), &policy).is_empty());
+        // New functions count
+        let regressions = complexity_regressions(&PetCodeGraph::new(), &graph_with(11), &policy);
+        assert_eq!(regressions[0].before, None);
+    }
+
+    #[test]
+    fn test_new_architecture_violations() {
+        let rules = ArchitectureRules::from_yaml(
+            "layers:\n  - {name: api, paths: [api]}\n  - {name: db, paths: [db]}\nrules:\n  - {from: api, deny: [db]}\n",
+        )
+        .unwrap();
+        let mut before = PetCodeGraph::new();
+        for (id, file) in [("api/h.go:Get", "api/h.go"), ("db/q.go:Query", "db/q.go")] {
+            before.add_node(Node::callable(
+                id.to_string(),
+                id.rsplit(':').next().unwrap().to_string(),
+                CallableKind::Function,
+                file.to_string(),
+                1,
+                5,
+            ));
+        }
+        let mut after = before.clone();
+        after.add_edge(
+            "api/h.go:Get",
+            "db/q.go:Query",
+            EdgeData::uses(Some(3), None),
+        );
+
+        let violations = new_architecture_violations(&before, &after, &rules);
+        assert_eq!(violations.len(), 1);
+        assert_eq!(violations[0].line, 3);
+        // Already in the index
+        assert!(new_architecture_violations(&after, &after, &rules).is_empty());
+    }
+}
@@ -1,7 +1,9 @@
 //! Clean command - Remove CodePrysm data for a workspace
 //!
 //! Provides cleanup functionality for:
-//! - Local .codeprysm/ directory and all graph data
+//! - Local .codeprysm/ directory and all graph data, except the graph
+//!   history (history.db), which cannot be rebuilt and is kept unless
+//!   `--history` is passed
 //! - Backend index data (Qdrant points for this workspace)
 
 use std::io::{self, Write};
@@ -9,6 +11,7 @@ use std::path::Path;
 
 use anyhow::{Context, Result};
 use clap::Args;
+use codeprysm_core::lazy::history::HISTORY_FILE;
 use codeprysm_search::{schema::collections, QdrantConfig, QdrantStore};
 use serde::Serialize;
 
@@ -34,6 +37,11 @@ pub struct CleanArgs {
     #[arg(long)]
     backend_only: bool,
 
+    /// Also delete the graph history (history.db), which cannot be rebuilt
+    /// from the source
+    #[arg(long)]
+    history: bool,
+
     /// Output as JSON
     #[arg(long)]
     json: bool,
@@ -65,6 +73,8 @@ pub struct LocalCleanResult {
     /// Size of deleted data in bytes (if available)
     #[serde(skip_serializing_if = "Option::is_none")]
     pub size_bytes: Option<u64>,
+    /// Whether the graph history was kept
+    pub kept_history: bool,
 }
 
 /// Result of backend cleanup
@@ -97,8 +107,9 @@ pub async fn execute(args: CleanArgs, global: GlobalOptions) -> Result<()> {
 
     // Gather information about what will be cleaned
     let local_exists = prism_dir.exists();
+    let keep_history = !args.history && prism_dir.join(HISTORY_FILE).exists();
     let local_size = if local_exists {
-        dir_size(&prism_dir).ok()
+        deleted_size(&prism_dir, keep_history).ok()
     } else {
         None
     };
@@ -114,6 +125,7 @@ pub async fn execute(args: CleanArgs, global: GlobalOptions) -> Result<()> {
             &prism_dir,
             local_exists,
             local_size,
+            keep_history,
             &repo_id,
             &global,
         );
@@ -145,7 +157,8 @@ pub async fn execute(args: CleanArgs, global: GlobalOptions) -> Result<()> {
 
     // Clean local data
     if clean_local {
-        result.local = Some(clean_local_data(&prism_dir, args.dry_run, &global).await);
+        result.local =
+            Some(clean_local_data(&prism_dir, keep_history, args.dry_run, &global).await);
     }
 
     // Clean backend data
@@ -187,6 +200,7 @@ fn print_cleanup_preview(
     prism_dir: &Path,
     local_exists: bool,
     local_size: Option<u64>,
+    keep_history: bool,
     repo_id: &str,
     global: &GlobalOptions,
 ) {
@@ -201,6 +215,9 @@ fn print_cleanup_preview(
                 println!("  Size: {}", format_size(size));
             }
             println!("  Action: Will be deleted");
+            if keep_history {
+                println!("  Keeping: {} (pass --history to delete it)", HISTORY_FILE);
+            }
         } else {
             println!("  Path: {}", prism_dir.display());
             println!("  Status: Does not exist (nothing to delete)");
@@ -218,6 +235,7 @@ fn print_cleanup_preview(
 
 async fn clean_local_data(
     prism_dir: &Path,
+    keep_history: bool,
     dry_run: bool,
     global: &GlobalOptions,
 ) -> LocalCleanResult {
@@ -227,18 +245,25 @@ async fn clean_local_data(
             success: true,
             error: Some("Directory does not exist".to_string()),
             size_bytes: None,
+            kept_history: false,
         };
     }
 
-    let size = dir_size(prism_dir).ok();
+    let size = deleted_size(prism_dir, keep_history).ok();
+    let kept = if keep_history {
+        format!(", keeping {}", HISTORY_FILE)
+    } else {
+        String::new()
+    };
 
     if dry_run {
         if !global.quiet {
             println!(
-                "Would delete: {} ({})",
+                "Would delete: {} ({}{})",
                 prism_dir.display(),
                 size.map(format_size)
-                    .unwrap_or_else(|| "unknown size".to_string())
+                    .unwrap_or_else(|| "unknown size".to_string()),
+                kept
             );
         }
         return LocalCleanResult {
@@ -246,17 +271,19 @@ async fn clean_local_data(
             success: true,
             error: None,
             size_bytes: size,
+            kept_history: keep_history,
         };
     }
 
-    match std::fs::remove_dir_all(prism_dir) {
+    match remove_index(prism_dir, keep_history) {
         Ok(()) => {
             if !global.quiet {
                 println!(
-                    "Deleted: {} ({})",
+                    "Deleted: {} ({}{})",
                     prism_dir.display(),
                     size.map(format_size)
-                        .unwrap_or_else(|| "unknown size".to_string())
+                        .unwrap_or_else(|| "unknown size".to_string()),
+                    kept
                 );
             }
             LocalCleanResult {
@@ -264,6 +291,7 @@ async fn clean_local_data(
                 success: true,
                 error: None,
                 size_bytes: size,
+                kept_history: keep_history,
             }
         }
         Err(e) => LocalCleanResult {
@@ -271,10 +299,52 @@ async fn clean_local_data(
             success: false,
             error: Some(e.to_string()),
             size_bytes: size,
+            kept_history: keep_history,
         },
     }
 }
 
+/// Whether a file of the index directory belongs to the graph history
+/// (the database or its SQLite journal files)
+fn is_history_file(name: &std::ffi::OsStr) -> bool {
+    name.to_str()
+        .is_some_and(|name| name.starts_with(HISTORY_FILE))
+}
+
+/// Delete the index directory, or everything in it but the graph history
+/// when `keep_history` is set
+fn remove_index(prism_dir: &Path, keep_history: bool) -> io::Result<()> {
+    if !keep_history {
+        return std::fs::remove_dir_all(prism_dir);
+    }
+    for entry in std::fs::read_dir(prism_dir)? {
+        let entry = entry?;
+        if is_history_file(&entry.file_name()) {
+            continue;
+        }
+        if entry.file_type()?.is_dir() {
+            std::fs::remove_dir_all(entry.path())?;
+        } else {
+            std::fs::remove_file(entry.path())?;
+        }
+    }
+    Ok(())
+}
+
+/// Size of what [`remove_index`] deletes
+fn deleted_size(prism_dir: &Path, keep_history: bool) -> Result<u64> {
+    let mut size = dir_size(prism_dir)?;
+    if keep_history {
+        for entry in std::fs::read_dir(prism_dir)? {
+            let entry = entry?;
+            if is_history_file(&entry.file_name()) {
+                size = size.saturating_sub(entry.metadata()?.len());
+            }
+        }
+    }
+    Ok(size)
+}
+
 async fn clean_backend_data(
     repo_id: &str,
     qdrant_url: &str,
@@ -411,7 +481,7 @@ fn print_clean_result(result: &CleanResult, global: &GlobalOptions) {
 }
 
 /// Calculate the total size of a directory
-fn dir_size(path: &Path) -> Result<u64> {
+pub(super) fn dir_size(path: &Path) -> Result<u64> {
     let mut total = 0u64;
 
     if path.is_file() {
@@ -433,7 +503,7 @@ fn dir_size(path: &Path) -> Result<u64> {
 }
 
 /// Format a size in bytes as a human-readable string
-fn format_size(bytes: u64) -> String {
+pub(super) fn format_size(bytes: u64) -> String {
     const KB: u64 = 1024;
     const MB: u64 = KB * 1024;
     const GB: u64 = MB * 1024;
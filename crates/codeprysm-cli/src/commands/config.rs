@@ -92,10 +92,14 @@ pub struct ConfigValue {
 pub struct ConfigPaths {
     /// Global config file path
     pub global: Option<PathBuf>,
+    /// Project config file path (`.codeprysm.toml`)
+    pub project: PathBuf,
     /// Local config file path
     pub local: PathBuf,
     /// Whether global config exists
     pub global_exists: bool,
+    /// Whether project config exists
+    pub project_exists: bool,
     /// Whether local config exists
     pub local_exists: bool,
 }
@@ -199,12 +203,15 @@ async fn execute_path(args: PathArgs, global: GlobalOptions) -> Result<()> {
     let loader = ConfigLoader::new();
 
     let global_path = loader.global_config_path();
+    let project_path = loader.project_config_path(&workspace_path);
     let local_path = loader.local_config_path(&workspace_path);
 
     let paths = ConfigPaths {
         global: global_path.clone(),
+        project: project_path.clone(),
         local: local_path.clone(),
         global_exists: global_path.as_ref().map(|p| p.exists()).unwrap_or(false),
+        project_exists: project_path.exists(),
         local_exists: local_path.exists(),
     };
 
@@ -220,17 +227,24 @@ async fn execute_path(args: PathArgs, global: GlobalOptions) -> Result<()> {
             } else {
                 "not found"
             };
-            println!("Global: {} ({})", gp.display(), status);
+            println!("Global:  {} ({})", gp.display(), status);
         } else {
-            println!("Global: not available (no home directory)");
+            println!("Global:  not available (no home directory)");
         }
 
+        let status = if paths.project_exists {
+            "exists"
+        } else {
+            "not found"
+        };
+        println!("Project: {} ({})", paths.project.display(), status);
+
         let status = if paths.local_exists {
             "exists"
         } else {
             "not found"
         };
-        println!("Local:  {} ({})", paths.local.display(), status);
+        println!("Local:   {} ({})", paths.local.display(), status);
     }
 
     Ok(())
@@ -259,6 +273,7 @@ fn set_config_value(config: &mut PrismConfig, key: &str, value: &str) -> Result<
         "storage.prism_dir" => config.storage.prism_dir = PathBuf::from(value),
         "storage.compression" => config.storage.compression = value.parse()?,
         "storage.max_partition_size_mb" => config.storage.max_partition_size_mb = value.parse()?,
+        "storage.partition_cache_mb" => config.storage.partition_cache_mb = value.parse()?,
 
         // Backend
         "backend.qdrant.url" => config.backend.qdrant.url = value.to_string(),
@@ -272,6 +287,8 @@ fn set_config_value(config: &mut PrismConfig, key: &str, value: &str) -> Result<
         "analysis.max_file_size_kb" => config.analysis.max_file_size_kb = value.parse()?,
         "analysis.detect_components" => config.analysis.detect_components = value.parse()?,
         "analysis.parallelism" => config.analysis.parallelism = value.parse()?,
+        "analysis.max_cyclomatic" => config.analysis.max_cyclomatic = Some(value.parse()?),
+        "analysis.max_cognitive" => config.analysis.max_cognitive = Some(value.parse()?),
 
         // Workspace
         "workspace.cross_workspace_search" => {
@@ -281,6 +298,9 @@ fn set_config_value(config: &mut PrismConfig, key: &str, value: &str) -> Result<
         // Logging
         "logging.level" => config.logging.level = value.to_string(),
 
+        // Output
+        "output.limit" => config.output.limit = Some(value.parse()?),
+
         _ => anyhow::bail!("Unknown or read-only configuration key: {}", key),
     }
 
@@ -362,11 +382,14 @@ fn print_config_list(
     // Show paths
     if let Some(gp) = loader.global_config_path() {
         let status = if gp.exists() { "" } else { " (not found)" };
-        println!("Global config: {}{}", gp.display(), status);
+        println!("Global config:  {}{}", gp.display(), status);
     }
+    let pp = loader.project_config_path(workspace);
+    let status = if pp.exists() { "" } else { " (not found)" };
+    println!("Project config: {}{}", pp.display(), status);
     let lp = loader.local_config_path(workspace);
     let status = if lp.exists() { "" } else { " (not found)" };
-    println!("Local config:  {}{}\n", lp.display(), status);
+    println!("Local config:   {}{}\n", lp.display(), status);
 
     // Print sections
     println!("[storage]");
@@ -388,6 +411,12 @@ fn print_config_list(
         &global.storage.max_partition_size_mb,
         &default.storage.max_partition_size_mb,
     );
+    print_value(
+        "partition_cache_mb",
+        &local.storage.partition_cache_mb,
+        &global.storage.partition_cache_mb,
+        &default.storage.partition_cache_mb,
+    );
 
     println!("\n[backend.qdrant]");
     print_value(
@@ -506,13 +535,16 @@ mod tests {
     fn test_config_paths_serialization() {
         let paths = ConfigPaths {
             global: Some(PathBuf::from("/home/user/.codeprysm/config.toml")),
+            project: PathBuf::from("/project/.codeprysm.toml"),
             local: PathBuf::from("/project/.codeprysm/config.toml"),
             global_exists: true,
+            project_exists: true,
             local_exists: false,
         };
 
         let json = serde_json::to_string(&paths).unwrap();
         assert!(json.contains("\"global_exists\":true"));
+        assert!(json.contains("\"project_exists\":true"));
         assert!(json.contains("\"local_exists\":false"));
     }
 
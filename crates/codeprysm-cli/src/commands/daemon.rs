@@ -0,0 +1,198 @@
+//! Daemon command - Keep the index loaded between queries
+//!
+//! `daemon start` loads the index once and answers queries over a Unix socket
+//! in the index directory. While a daemon runs, `graph` and `search` send
+//! their queries to it instead of loading the index themselves, and any
+//! number of tools can share it. The daemon reloads the graph when
+//! `codeprysm update` or `codeprysm watch` changes the index.
+
+use anyhow::Result;
+use clap::Subcommand;
+
+use crate::GlobalOptions;
+
+/// Daemon subcommands
+#[derive(Subcommand, Debug)]
+pub enum DaemonCommand {
+    /// Start a daemon for the workspace
+    Start {
+        /// Run in the background and return once the daemon is ready
+        #[arg(long, short = 'd')]
+        detach: bool,
+    },
+
+    /// Stop the workspace's daemon
+    Stop,
+
+    /// Show whether a daemon is running for the workspace
+    Status,
+}
+
+/// Execute a daemon command
+#[cfg(unix)]
+pub async fn execute(cmd: DaemonCommand, global: GlobalOptions) -> Result<()> {
+    let socket = unix::workspace_socket(&global).await?;
+    match cmd {
+        DaemonCommand::Start { detach } => unix::start(socket, detach, &global).await,
+        DaemonCommand::Stop => unix::stop(&socket, &global).await,
+        DaemonCommand::Status => unix::status(&socket, &global).await,
+    }
+}
+
+/// Execute a daemon command
+#[cfg(not(unix))]
+pub async fn execute(_cmd: DaemonCommand, _global: GlobalOptions) -> Result<()> {
+    anyhow::bail!("The daemon is only supported on Unix platforms")
+}
+
+#[cfg(unix)]
+mod unix {
+    use std::os::unix::process::CommandExt;
+    use std::path::{Path, PathBuf};
+    use std::process::{Command, Stdio};
+    use std::sync::Arc;
+    use std::time::{Duration, Instant};
+
+    use anyhow::{bail, Context, Result};
+    use codeprysm_backend::{socket_path, Backend, DaemonBackend, DaemonServer};
+    use tokio::net::UnixListener;
+    use tokio::signal::unix::{signal, SignalKind};
+
+    use crate::commands::{create_backend, load_config, print_info, resolve_workspace};
+    use crate::GlobalOptions;
+
+    /// How long `start --detach` waits for the daemon to accept connections
+    const START_TIMEOUT: Duration = Duration::from_secs(60);
+
+    /// Socket of the daemon for the resolved workspace
+    pub async fn workspace_socket(global: &GlobalOptions) -> Result<PathBuf> {
+        let workspace = resolve_workspace(global).await?;
+        let config = load_config(global, &workspace)?;
+        Ok(socket_path(&config.prism_dir(&workspace)))
+    }
+
+    /// Run a daemon in the foreground, or spawn one in the background
+    pub async fn start(socket: PathBuf, detach: bool, global: &GlobalOptions) -> Result<()> {
+        if DaemonBackend::connect(&socket).await.is_ok() {
+            bail!(
+                "A daemon is already running for this workspace.\n  Socket: {}",
+                socket.display()
+            );
+        }
+        if detach {
+            return spawn_detached(&socket, global).await;
+        }
+
+        let backend = create_backend(global).await?;
+        if !backend.graph_exists() {
+            bail!(
+                "Workspace not initialized. Run 'codeprysm init' first.\n  Path: {}",
+                backend.workspace_root().display()
+            );
+        }
+
+        // Left behind by a daemon that did not exit cleanly
+        let _ = std::fs::remove_file(&socket);
+        let listener = UnixListener::bind(&socket)
+            .with_context(|| format!("Failed to listen on {}", socket.display()))?;
+        print_info(
+            &format!("Daemon listening on {} (Ctrl-C to stop)", socket.display()),
+            global.quiet,
+        );
+
+        let server = Arc::new(DaemonServer::new(backend));
+        let result = server.serve(listener, shutdown_signal()).await;
+        let _ = std::fs::remove_file(&socket);
+        print_info("Daemon stopped", global.quiet);
+        result.context("Daemon failed")
+    }
+
+    /// Re-run `daemon start` in a new process group and wait until it is ready
+    async fn spawn_detached(socket: &Path, global: &GlobalOptions) -> Result<()> {
+        let workspace = resolve_workspace(global).await?;
+        let exe = std::env::current_exe().context("Failed to locate the codeprysm executable")?;
+
+        let mut command = Command::new(exe);
+        command.arg("--workspace").arg(&workspace);
+        if let Some(config) = &global.config {
+            command.arg("--config").arg(config);
+        }
+        command.arg("--qdrant-url").arg(&global.qdrant_url);
+        if let Some(provider) = global.embedding_provider {
+            command
+                .arg("--embedding-provider")
+                .arg(provider.to_string());
+        }
+        let mut child = command
+            .args(["--quiet", "daemon", "start"])
+            .stdin(Stdio::null())
+            .stdout(Stdio::null())
+            .stderr(Stdio::null())
+            // Keep terminal signals meant for this shell away from the daemon
+            .process_group(0)
+            .spawn()
+            .context("Failed to start the daemon")?;
+
+        let start = Instant::now();
+        loop {
+            if DaemonBackend::connect(socket).await.is_ok() {
+                print_info(
+                    &format!("Daemon started (pid {})", child.id()),
+                    global.quiet,
+                );
+                return Ok(());
+            }
+            if let Some(status) = child.try_wait()? {
+                bail!(
+                    "Daemon exited during startup ({}). Run 'codeprysm daemon start' to see why.",
+                    status
+                );
+            }
+            if start.elapsed() > START_TIMEOUT {
+                bail!(
+                    "Daemon did not start within {} seconds",
+                    START_TIMEOUT.as_secs()
+                );
+            }
+            tokio::time::sleep(Duration::from_millis(100)).await;
+        }
+    }
+
+    /// Ask a running daemon to exit
+    pub async fn stop(socket: &Path, global: &GlobalOptions) -> Result<()> {
+        match DaemonBackend::connect(socket).await {
+            Ok(daemon) => {
+                daemon
+                    .shutdown()
+                    .await
+                    .context("Failed to stop the daemon")?;
+                print_info("Daemon stopped", global.quiet);
+            }
+            Err(_) => print_info("No daemon running", global.quiet),
+        }
+        Ok(())
+    }
+
+    /// Report whether a daemon is running
+    pub async fn status(socket: &Path, global: &GlobalOptions) -> Result<()> {
+        match DaemonBackend::connect(socket).await {
+            Ok(daemon) => {
+                println!("Daemon running");
+                println!("  Socket:     {}", socket.display());
+                println!("  Repository: {}", daemon.repo_id());
+            }
+            Err(_) => print_info("No daemon running", global.quiet),
+        }
+        Ok(())
+    }
+
+    /// Wait for SIGINT or SIGTERM
+    async fn shutdown_signal() {
+        let mut terminate =
+            signal(SignalKind::terminate()).expect("Failed to install SIGTERM handler");
+        tokio::select! {
+            _ = tokio::signal::ctrl_c() => {},
+            _ = terminate.recv() => {},
+        }
+    }
+}
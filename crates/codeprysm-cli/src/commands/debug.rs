@@ -0,0 +1,161 @@
+//! Debug command - Inspect how a file is extracted
+//!
+//! Dumps the syntax tree, query captures, and graph entities of a single
+//! file, so extraction bugs can be reported with precise evidence and query
+//! changes checked without re-indexing.
+
+use std::path::PathBuf;
+
+use anyhow::{Context, Result};
+use clap::{Args, Subcommand};
+use codeprysm_core::builder::{BuilderConfig, GraphBuilder};
+use codeprysm_core::{dump_source, ParseDump};
+
+use super::resolve_workspace;
+use crate::GlobalOptions;
+
+/// Extraction debugging commands
+#[derive(Subcommand, Debug)]
+pub enum DebugCommand {
+    /// Dump the syntax tree, captures, nodes, and edges of one file
+    Parse(ParseArgs),
+}
+
+#[derive(Args, Debug)]
+pub struct ParseArgs {
+    /// Source file
+    file: PathBuf,
+
+    /// Path to custom SCM queries directory
+    #[arg(long)]
+    queries: Option<PathBuf>,
+
+    /// Output as JSON
+    #[arg(long)]
+    json: bool,
+}
+
+/// Execute a debug command
+pub async fn execute(cmd: DebugCommand, global: GlobalOptions) -> Result<()> {
+    match cmd {
+        DebugCommand::Parse(args) => execute_parse(args, global).await,
+    }
+}
+
+async fn execute_parse(args: ParseArgs, global: GlobalOptions) -> Result<()> {
+    let source = std::fs::read_to_string(&args.file)
+        .with_context(|| format!("Failed to read {}", args.file.display()))?;
+
+    // Name the file as the index would, relative to the workspace root
+    let workspace_path = resolve_workspace(&global).await?;
+    let workspace_path = workspace_path.canonicalize().unwrap_or(workspace_path);
+    let rel_path = args
+        .file
+        .canonicalize()
+        .ok()
+        .and_then(|path| {
+            path.strip_prefix(&workspace_path)
+                .ok()
+                .map(|p| p.to_string_lossy().replace('\\', "/"))
+        })
+        .unwrap_or_else(|| {
+            args.file
+                .to_string_lossy()
+                .trim_start_matches("./")
+                .to_string()
+        });
+
+    let mut builder = match &args.queries {
+        Some(queries_dir) => GraphBuilder::with_config(queries_dir, BuilderConfig::default())
+            .context("Failed to create graph builder")?,
+        None => GraphBuilder::with_embedded_queries(BuilderConfig::default()),
+    };
+    let dump = dump_source(&mut builder, &rel_path, &source)
+        .with_context(|| format!("Failed to parse {}", args.file.display()))?;
+
+    if args.json {
+        println!("{}", serde_json::to_string_pretty(&dump)?);
+    } else {
+        print_dump(&dump);
+    }
+    Ok(())
+}
+
+fn print_dump(dump: &ParseDump) {
+    println!("File: {} ({})", dump.file, dump.language);
+
+    println!("\nSyntax tree:");
+    println!("{}", dump.tree);
+
+    println!("\nCaptures ({}):", dump.captures.len());
+    for capture in &dump.captures {
+        let text = capture.text.lines().next().unwrap_or("");
+        let more = if capture.line != capture.end_line {
+            " ..."
+        } else {
+            ""
+        };
+        let context = match (&capture.receiver, &capture.impl_target) {
+            (Some(receiver), _) => format!(" (receiver {})", receiver),
+            (None, Some(target)) => format!(" (impl {})", target),
+            (None, None) => String::new(),
+        };
+        println!(
+            "  {:>4}:{:<3} {:<40} {}{}{}",
+            capture.line, capture.column, capture.capture, text, more, context
+        );
+    }
+
+    println!("\nNodes ({}):", dump.nodes.len());
+    for node in &dump.nodes {
+        println!(
+            "  {:>4}-{:<4} {:<9} {:<10} {}",
+            node.line,
+            node.end_line,
+            node.node_type.as_str(),
+            node.kind.as_deref().unwrap_or("-"),
+            node.id
+        );
+    }
+
+    println!("\nEdges ({}):", dump.edges.len());
+    for edge in &dump.edges {
+        let at = match (edge.ref_line, &edge.ident) {
+            (Some(line), Some(ident)) => format!(" ({} on line {})", ident, line),
+            (Some(line), None) => format!(" (line {})", line),
+            _ => String::new(),
+        };
+        println!(
+            "  {:<8} {} -> {}{}",
+            edge.edge_type.as_str(),
+            edge.source,
+            edge.target,
+            at
+        );
+    }
+
+    println!("\nReferences ({}):", dump.references.len());
+    for reference in &dump.references {
+        println!(
+            "  {:>4} {:<24} from {} -> {}",
+            reference.line,
+            reference.name,
+            reference.source,
+            reference.target.as_deref().unwrap_or("(unresolved)")
+        );
+    }
+
+    if !dump.diagnostics.is_empty() {
+        println!("\nDiagnostics ({}):", dump.diagnostics.len());
+        for diagnostic in &dump.diagnostics {
+            let location = match diagnostic.span {
+                Some(span) => format!("{}:{}:{}", diagnostic.file, span.line, span.column),
+                None => diagnostic.file.clone(),
+            };
+            println!(
+                "  {:<7} {}: {}",
+                diagnostic.severity, location, diagnostic.message
+            );
+        }
+    }
+}
@@ -0,0 +1,95 @@
+//! Diagnostics command - Show where the graph is incomplete
+//!
+//! Lists the diagnostics recorded by the last `codeprysm init` or
+//! `codeprysm update`: files that could not be indexed, syntax errors that
+//! may hide definitions, and files with references that resolved to nothing.
+
+use anyhow::Result;
+use clap::Args;
+use codeprysm_core::{Diagnostic, Diagnostics, Severity};
+
+use super::{load_config, resolve_workspace};
+use crate::GlobalOptions;
+
+/// Arguments for the diagnostics command
+#[derive(Args, Debug)]
+pub struct DiagnosticsArgs {
+    /// Minimum severity to show (info, warning, error)
+    #[arg(long, default_value = "warning")]
+    severity: Severity,
+
+    /// Only show diagnostics for files under this path prefix
+    #[arg(long)]
+    file: Option<String>,
+
+    /// Output as JSON
+    #[arg(long)]
+    json: bool,
+}
+
+/// Execute the diagnostics command
+pub async fn execute(args: DiagnosticsArgs, global: GlobalOptions) -> Result<()> {
+    let workspace_path = resolve_workspace(&global).await?;
+    let config = load_config(&global, &workspace_path)?;
+    let prism_dir = config.prism_dir(&workspace_path);
+    let Some(diagnostics) = Diagnostics::load(&prism_dir) else {
+        anyhow::bail!(
+            "No diagnostics recorded. Run 'codeprysm init' or 'codeprysm update' first.\n  Path: {}",
+            workspace_path.display()
+        );
+    };
+
+    let prefix = args.file.as_deref().map(|f| f.trim_start_matches("./"));
+    let shown: Vec<&Diagnostic> = diagnostics
+        .diagnostics
+        .iter()
+        .filter(|d| d.severity >= args.severity)
+        .filter(|d| prefix.is_none_or(|prefix| d.file.starts_with(prefix)))
+        .collect();
+
+    if args.json {
+        println!("{}", serde_json::to_string_pretty(&shown)?);
+        return Ok(());
+    }
+
+    if shown.is_empty() {
+        if !global.quiet {
+            println!(
+                "No diagnostics at {} or above ({} in total)",
+                args.severity,
+                diagnostics.len()
+            );
+        }
+        return Ok(());
+    }
+
+    for diagnostic in &shown {
+        let location = match diagnostic.span {
+            Some(span) => format!("{}:{}:{}", diagnostic.file, span.line, span.column),
+            None => diagnostic.file.clone(),
+        };
+        println!(
+            "{:<7} {}: {}",
+            diagnostic.severity, location, diagnostic.message
+        );
+    }
+    if !global.quiet {
+        println!(
+            "\n{} error(s), {} warning(s), {} info",
+            shown
+                .iter()
+                .filter(|d| d.severity == Severity::Error)
+                .count(),
+            shown
+                .iter()
+                .filter(|d| d.severity == Severity::Warning)
+                .count(),
+            shown
+                .iter()
+                .filter(|d| d.severity == Severity::Info)
+                .count()
+        );
+    }
+
+    Ok(())
+}
@@ -0,0 +1,273 @@
+//! Diff command - Compare the code graph between two git revisions
+//!
+//! Both revisions are checked out into a dedicated git worktree under the
+//! prism directory and indexed with the incremental updater. The worktree and
+//! its index persist between runs, so only files that differ from the
+//! previously indexed revision are reparsed.
+
+use std::path::{Path, PathBuf};
+use std::process::Command;
+
+use anyhow::{Context, Result};
+use clap::Args;
+use codeprysm_config::PrismConfig;
+use codeprysm_core::analysis::{callable_signatures, diff_graphs, GraphDiff, NodeSummary};
+use codeprysm_core::builder::BuilderConfig;
+use codeprysm_core::{ExclusionFilter, IncrementalUpdater, PetCodeGraph};
+
+use super::{
+    attribute_rules, extraction_rules, file_policy, load_config, resolve_workspace, symbol_limits,
+};
+use crate::progress::{finish_spinner, spinner};
+use crate::GlobalOptions;
+
+/// Arguments for the diff command
+#[derive(Args, Debug)]
+pub struct DiffArgs {
+    /// Base revision (commit, branch, or tag)
+    rev1: String,
+
+    /// Revision to compare against the base
+    rev2: String,
+
+    /// Also list added and removed edges in text output
+    #[arg(long)]
+    edges: bool,
+
+    /// Output as JSON
+    #[arg(long)]
+    json: bool,
+}
+
+/// Execute the diff command
+pub async fn execute(args: DiffArgs, global: GlobalOptions) -> Result<()> {
+    let workspace_path = resolve_workspace(&global).await?;
+    let config = load_config(&global, &workspace_path)?;
+
+    // Resolve in the main checkout; names like HEAD differ inside the worktree
+    let old_commit = resolve_commit(&workspace_path, &args.rev1)?;
+    let new_commit = resolve_commit(&workspace_path, &args.rev2)?;
+
+    let mut revisions = RevisionIndex::new(&workspace_path, &config)?;
+
+    let pb = spinner(&format!("Indexing {}...", args.rev1), global.quiet);
+    let old_graph = revisions.index(&old_commit)?;
+    let old_signatures = callable_signatures(&old_graph, revisions.worktree());
+    finish_spinner(pb, &format!("Indexed {}", args.rev1));
+
+    let pb = spinner(&format!("Indexing {}...", args.rev2), global.quiet);
+    let new_graph = revisions.index(&new_commit)?;
+    let new_signatures = callable_signatures(&new_graph, revisions.worktree());
+    finish_spinner(pb, &format!("Indexed {}", args.rev2));
+
+    let mut diff = diff_graphs(&old_graph, &new_graph);
+    diff.add_signature_changes(&new_graph, &old_signatures, &new_signatures);
+
+    if args.json {
+        let output = serde_json::json!({
+            "old": { "rev": args.rev1, "commit": old_commit },
+            "new": { "rev": args.rev2, "commit": new_commit },
+            "diff": diff,
+        });
+        println!("{}", serde_json::to_string_pretty(&output)?);
+    } else {
+        print_diff(&diff, args.edges, &global);
+    }
+
+    Ok(())
+}
+
+/// Git worktree under the prism directory where revisions are checked out and
+/// indexed incrementally.
+pub(super) struct RevisionIndex {
+    repo: PathBuf,
+    worktree: PathBuf,
+    index_dir: PathBuf,
+    builder_config: BuilderConfig,
+    updater: Option<IncrementalUpdater>,
+}
+
+impl RevisionIndex {
+    /// Create an index for the repository at `workspace`.
+    pub(super) fn new(workspace: &Path, config: &PrismConfig) -> Result<Self> {
+        let cache_dir = config.prism_dir(workspace).join("diff");
+        Ok(Self {
+            repo: workspace.to_path_buf(),
+            worktree: cache_dir.join("worktree"),
+            index_dir: cache_dir.join("index"),
+            builder_config: BuilderConfig {
+                exclude_patterns: config.analysis.exclude_patterns.clone(),
+                include_patterns: config.analysis.include_patterns.clone(),
+                file_policy: file_policy(&config.analysis),
+                attributes: attribute_rules(config),
+                extraction: extraction_rules(&config.analysis, workspace)?,
+                symbol_limits: symbol_limits(&config.analysis),
+                ..BuilderConfig::default()
+            },
+            updater: None,
+        })
+    }
+
+    /// Directory the current revision is checked out in
+    pub(super) fn worktree(&self) -> &Path {
+        &self.worktree
+    }
+
+    /// Check out a commit and return its graph
+    pub(super) fn index(&mut self, commit: &str) -> Result<PetCodeGraph> {
+        checkout(&self.repo, &self.worktree, commit)?;
+
+        // The worktree must exist before the updater is created
+        if self.updater.is_none() {
+            let updater = IncrementalUpdater::with_embedded_queries(
+                &self.worktree,
+                &self.index_dir,
+                ExclusionFilter::default(),
+                self.builder_config.clone(),
+            )
+            .context("Failed to create incremental updater")?;
+            self.updater = Some(updater);
+        }
+        let updater = self.updater.as_mut().expect("updater created above");
+
+        updater
+            .update_repository(false)
+            .context("Failed to index revision")?;
+        updater
+            .graph()
+            .cloned()
+            .context("Incremental updater produced no graph")
+    }
+}
+
+/// Resolve a revision to a full commit hash
+pub(super) fn resolve_commit(repo: &Path, rev: &str) -> Result<String> {
+    let commit = git(
+        repo,
+        &["rev-parse", "--verify", &format!("{}^{{commit}}", rev)],
+    )
+    .with_context(|| format!("Unknown revision: {}", rev))?;
+    Ok(commit.trim().to_string())
+}
+
+/// Check out a commit in the diff worktree, creating it on first use
+fn checkout(repo: &Path, worktree: &Path, commit: &str) -> Result<()> {
+    if worktree.join(".git").exists() {
+        git(
+            worktree,
+            &["checkout", "--quiet", "--force", "--detach", commit],
+        )?;
+    } else {
+        // Drop stale registrations, e.g. after the prism directory was cleaned
+        git(repo, &["worktree", "prune"])?;
+        let path = worktree.to_string_lossy();
+        git(
+            repo,
+            &["worktree", "add", "--quiet", "--detach", &path, commit],
+        )?;
+    }
+    Ok(())
+}
+
+/// Run a git command and return its stdout
+pub(super) fn git(dir: &Path, args: &[&str]) -> Result<String> {
+    let output = Command::new("git")
+        .args(args)
+        .current_dir(dir)
+        .output()
+        .context("Failed to run git")?;
+
+    if !output.status.success() {
+        anyhow::bail!(
+            "git {} failed: {}",
+            args.first().copied().unwrap_or_default(),
+            String::from_utf8_lossy(&output.stderr).trim()
+        );
+    }
+
+    Ok(String::from_utf8_lossy(&output.stdout).into_owned())
+}
+
+/// Print a graph diff as text
+fn print_diff(diff: &GraphDiff, show_edges: bool, global: &GlobalOptions) {
+    if diff.is_empty() {
+        if !global.quiet {
+            println!("No structural changes.");
+        }
+        return;
+    }
+
+    print_nodes("Added", '+', &diff.added_nodes);
+    print_nodes("Removed", '-', &diff.removed_nodes);
+
+    if !diff.changed_nodes.is_empty() {
+        println!("Changed ({}):", diff.changed_nodes.len());
+        for changed in &diff.changed_nodes {
+            println!(
+                "  ~ {} ({}:{})",
+                changed.node.id, changed.node.file, changed.node.line
+            );
+            for change in &changed.changes {
+                println!(
+                    "      {}: {} -> {}",
+                    change.field,
+                    change.old.as_deref().unwrap_or("-"),
+                    change.new.as_deref().unwrap_or("-")
+                );
+            }
+        }
+        println!();
+    }
+
+    if show_edges {
+        for (label, marker, edges) in [
+            ("Added edges", '+', &diff.added_edges),
+            ("Removed edges", '-', &diff.removed_edges),
+        ] {
+            if edges.is_empty() {
+                continue;
+            }
+            println!("{} ({}):", label, edges.len());
+            for edge in edges {
+                println!(
+                    "  {} {} -[{}]-> {}",
+                    marker,
+                    edge.source,
+                    edge.edge_type.as_str(),
+                    edge.target
+                );
+            }
+            println!();
+        }
+    }
+
+    if !global.quiet {
+        println!(
+            "{} added, {} removed, {} changed nodes; {} added, {} removed edges",
+            diff.added_nodes.len(),
+            diff.removed_nodes.len(),
+            diff.changed_nodes.len(),
+            diff.added_edges.len(),
+            diff.removed_edges.len()
+        );
+    }
+}
+
+/// Print a list of added or removed nodes
+fn print_nodes(label: &str, marker: char, nodes: &[NodeSummary]) {
+    if nodes.is_empty() {
+        return;
+    }
+    println!("{} ({}):", label, nodes.len());
+    for node in nodes {
+        println!(
+            "  {} {} [{}] ({}:{})",
+            marker,
+            node.id,
+            node.kind.as_deref().unwrap_or(node.node_type.as_str()),
+            node.file,
+            node.line
+        );
+    }
+    println!();
+}
@@ -15,15 +15,15 @@ use std::path::Path;
 use anyhow::Result;
 use clap::Args;
 use codeprysm_backend::Backend;
+use codeprysm_core::lazy::{
+    MANIFEST_SCHEMA_VERSION as EXPECTED_MANIFEST_VERSION,
+    PARTITION_SCHEMA_VERSION as EXPECTED_PARTITION_VERSION,
+};
 use serde::{Deserialize, Serialize};
 
 use super::{create_backend, load_config, resolve_workspace};
 use crate::GlobalOptions;
 
-/// Current expected schema versions
-const EXPECTED_MANIFEST_VERSION: &str = "1.0";
-const EXPECTED_PARTITION_VERSION: &str = "1.1";
-
 /// Manifest structure for reading schema version
 #[derive(Debug, Deserialize)]
 struct ManifestInfo {
@@ -754,7 +754,7 @@ fn check_schema(workspace: &Path, checks: &mut Vec<CheckResult>) {
                 "Manifest version {} (expected {})",
                 manifest.schema_version, EXPECTED_MANIFEST_VERSION
             ),
-            "Run 'codeprysm update --force' to update schema",
+            "Run 'codeprysm migrate' to upgrade the index",
         ));
     } else if !partition_issues.is_empty() {
         checks.push(CheckResult::warn(
@@ -763,7 +763,7 @@ fn check_schema(workspace: &Path, checks: &mut Vec<CheckResult>) {
                 "Partition version mismatch: {}",
                 partition_issues.join(", ")
             ),
-            "Run 'codeprysm update --force' to rebuild partitions",
+            "Run 'codeprysm migrate' to upgrade the partitions",
         ));
     } else if partitions_checked == 0 {
         checks.push(CheckResult::warn(
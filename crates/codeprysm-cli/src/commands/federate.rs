@@ -0,0 +1,197 @@
+//! Federate command - Combine several repositories into one graph
+//!
+//! Loads each repository's existing index (or indexes it when it has none),
+//! federates the graphs under a workspace node with Go imports across
+//! repositories resolved to concrete edges, and saves the result as the
+//! current workspace's code graph, as `codeprysm merge` does.
+
+use std::path::{Path, PathBuf};
+
+use anyhow::{Context, Result};
+use clap::Args;
+use codeprysm_core::builder::{BuilderConfig, GraphBuilder};
+use codeprysm_core::lazy::partitioner::GraphPartitioner;
+use codeprysm_core::lazy::LazyGraphManager;
+use codeprysm_core::{federate, FederatedRepo, GraphArchive, PetCodeGraph};
+
+use super::{
+    attribute_rules, extraction_rules, file_policy, go_type_check, load_config, load_provenance,
+    print_info, resolve_workspace, save_provenance, symbol_limits, write_graph_store,
+};
+use crate::progress::{finish_spinner, spinner};
+use crate::GlobalOptions;
+
+/// Arguments for the federate command
+#[derive(Args, Debug)]
+pub struct FederateArgs {
+    /// Repositories to federate, as NAME=PATH or PATH (named after its directory)
+    #[arg(required = true, value_name = "[NAME=]PATH")]
+    repos: Vec<String>,
+
+    /// Name of the federated workspace (default: the workspace directory name)
+    #[arg(long)]
+    name: Option<String>,
+
+    /// Re-index every repository instead of loading its existing index
+    #[arg(long)]
+    rebuild: bool,
+
+    /// Also write the federated graph to a compressed archive (.cpz)
+    #[arg(long, value_name = "FILE")]
+    archive: Option<PathBuf>,
+
+    /// Print the federation report as JSON
+    #[arg(long)]
+    json: bool,
+}
+
+/// Split a `[NAME=]PATH` argument into a repository name and path
+fn parse_repo(arg: &str) -> Result<(String, PathBuf)> {
+    let (name, path) = match arg.split_once('=') {
+        Some((name, path)) => (Some(name.to_string()), PathBuf::from(path)),
+        None => (None, PathBuf::from(arg)),
+    };
+    let path = path
+        .canonicalize()
+        .with_context(|| format!("Repository not found: {}", path.display()))?;
+    let name = match name {
+        Some(name) => name,
+        None => path
+            .file_name()
+            .map(|n| n.to_string_lossy().to_string())
+            .with_context(|| format!("Cannot name repository {}", path.display()))?,
+    };
+    Ok((name, path))
+}
+
+/// Load the index of a repository, or index it if it has none or `rebuild`
+fn repository_graph(path: &Path, rebuild: bool, global: &GlobalOptions) -> Result<PetCodeGraph> {
+    let config = load_config(global, path)?;
+    let prism_dir = config.prism_dir(path);
+    if !rebuild && prism_dir.join("manifest.json").exists() {
+        return LazyGraphManager::open(&prism_dir)
+            .and_then(|manager| manager.load_full_graph())
+            .with_context(|| format!("Failed to load the index of {}", path.display()));
+    }
+
+    let builder_config = BuilderConfig {
+        exclude_patterns: config.analysis.exclude_patterns.clone(),
+        include_patterns: config.analysis.include_patterns.clone(),
+        file_policy: file_policy(&config.analysis),
+        attributes: attribute_rules(&config),
+        go_type_check: go_type_check(&config.analysis),
+        extraction: extraction_rules(&config.analysis, path)?,
+        symbol_limits: symbol_limits(&config.analysis),
+        ..Default::default()
+    };
+    GraphBuilder::with_embedded_queries(builder_config)
+        .build_from_directory(path)
+        .with_context(|| format!("Failed to index {}", path.display()))
+}
+
+/// Execute the federate command
+pub async fn execute(args: FederateArgs, global: GlobalOptions) -> Result<()> {
+    let workspace_path = resolve_workspace(&global).await?;
+    let config = load_config(&global, &workspace_path)?;
+    let prism_dir = config.prism_dir(&workspace_path);
+
+    let name = args.name.clone().unwrap_or_else(|| {
+        workspace_path
+            .file_name()
+            .map(|s| s.to_string_lossy().to_string())
+            .unwrap_or_else(|| "workspace".to_string())
+    });
+
+    let mut repos = Vec::new();
+    for arg in &args.repos {
+        let (repo_name, path) = parse_repo(arg)?;
+        let pb = spinner(&format!("Loading {}...", repo_name), global.quiet);
+        let graph = repository_graph(&path, args.rebuild, &global)?;
+        finish_spinner(
+            pb,
+            &format!("Loaded {} ({} nodes)", repo_name, graph.node_count()),
+        );
+        repos.push(FederatedRepo {
+            name: repo_name,
+            root: path,
+            graph,
+        });
+    }
+
+    let pb = spinner("Federating repositories...", global.quiet);
+    let (graph, report) = federate(&name, repos).context("Failed to federate repositories")?;
+    finish_spinner(
+        pb,
+        &format!(
+            "Federated {} repositories ({} nodes, {} cross-repository references)",
+            report.repositories.len(),
+            graph.node_count(),
+            report.resolved
+        ),
+    );
+
+    if !prism_dir.exists() {
+        std::fs::create_dir_all(&prism_dir).context("Failed to create .codeprysm directory")?;
+        print_info(&format!("Created {}", prism_dir.display()), global.quiet);
+    }
+
+    let pb = spinner("Saving graph...", global.quiet);
+    let (_, stats) = GraphPartitioner::partition_with_stats(&graph, &prism_dir, Some(&name))
+        .context("Failed to save graph")?;
+    write_graph_store(&graph, &config, &workspace_path)?;
+    save_provenance(&config, &workspace_path)?;
+    finish_spinner(
+        pb,
+        &format!(
+            "Saved graph ({} nodes, {} partitions)",
+            stats.total_nodes, stats.partition_count
+        ),
+    );
+
+    if let Some(ref path) = args.archive {
+        GraphArchive::write(
+            &graph,
+            load_provenance(&config, &workspace_path).as_ref(),
+            path,
+        )
+        .with_context(|| format!("Failed to write {}", path.display()))?;
+        print_info(
+            &format!("Wrote archive to {}", path.display()),
+            global.quiet,
+        );
+    }
+
+    if args.json {
+        println!("{}", serde_json::to_string_pretty(&report)?);
+        return Ok(());
+    }
+    if global.quiet {
+        return Ok(());
+    }
+
+    if report.dependencies.is_empty() {
+        println!("\nNo imports between the repositories");
+    } else {
+        println!("\nDependencies:");
+        for dependency in &report.dependencies {
+            println!(
+                "  {} -> {} ({}, {} file(s))",
+                dependency.from, dependency.to, dependency.module, dependency.files
+            );
+        }
+    }
+    if report.unresolved > 0 {
+        println!(
+            "  {} qualified name(s) into federated packages did not resolve",
+            report.unresolved
+        );
+    }
+    if report.skipped_files > 0 {
+        println!(
+            "  {} Go file(s) could not be read for imports; federate from the checkouts the indexes were built from",
+            report.skipped_files
+        );
+    }
+
+    Ok(())
+}
@@ -3,7 +3,10 @@
 use anyhow::{Context, Result};
 use clap::{Args, Subcommand, ValueEnum};
 use codeprysm_backend::Backend;
+use codeprysm_core::AttributeFilter;
 
+#[cfg(unix)]
+use super::connect_daemon;
 use super::create_backend;
 use crate::GlobalOptions;
 
@@ -34,6 +37,10 @@ pub enum GraphSubcommand {
         #[arg(long, short = 't')]
         node_type: Option<String>,
 
+        /// Only nodes with this attribute, as KEY=VALUE or KEY (repeatable)
+        #[arg(long = "attr", value_name = "KEY[=VALUE]")]
+        attributes: Vec<AttributeFilter>,
+
         /// Maximum results
         #[arg(long, short = 'n', default_value = "20")]
         limit: usize,
@@ -44,7 +51,8 @@ pub enum GraphSubcommand {
         /// Node ID to query
         node_id: String,
 
-        /// Edge type filter (Contains, Uses, Defines, DependsOn)
+        /// Edge type filter (Contains, Uses, Defines, DependsOn, CloneOf, DefinedIn,
+        /// CrossLanguage, RefersByName, EquivalentTo, SubsetOf)
         #[arg(long, short = 'e')]
         edge_type: Option<String>,
 
@@ -97,46 +105,47 @@ impl EdgeDirection {
 
 /// Execute the graph command
 pub async fn execute(args: GraphArgs, global: GlobalOptions) -> Result<()> {
+    #[cfg(unix)]
+    if let Some(daemon) = connect_daemon(&global).await? {
+        return run(args, &daemon, &global).await;
+    }
     let backend = create_backend(&global).await?;
+    run(args, &*backend, &global).await
+}
 
+/// Run a graph subcommand against a backend
+async fn run<B: Backend>(args: GraphArgs, backend: &B, global: &GlobalOptions) -> Result<()> {
     match args.command {
-        GraphSubcommand::Stats => execute_stats(&*backend, &global).await,
-        GraphSubcommand::Node { node_id } => execute_node(&*backend, &node_id, &global).await,
+        GraphSubcommand::Stats => execute_stats(backend, global).await,
+        GraphSubcommand::Node { node_id } => execute_node(backend, &node_id, global).await,
         GraphSubcommand::Find {
             pattern,
             node_type,
+            attributes,
             limit,
-        } => execute_find(&*backend, &pattern, node_type.as_deref(), limit, &global).await,
-        GraphSubcommand::Edges {
-            node_id,
-            edge_type,
-            direction,
         } => {
-            execute_edges(
-                &*backend,
-                &node_id,
-                edge_type.as_deref(),
-                direction,
-                &global,
+            execute_find(
+                backend,
+                &pattern,
+                node_type.as_deref(),
+                &attributes,
+                limit,
+                global,
             )
             .await
         }
+        GraphSubcommand::Edges {
+            node_id,
+            edge_type,
+            direction,
+        } => execute_edges(backend, &node_id, edge_type.as_deref(), direction, global).await,
         GraphSubcommand::Connected {
             node_id,
             edge_type,
             direction,
-        } => {
-            execute_connected(
-                &*backend,
-                &node_id,
-                edge_type.as_deref(),
-                direction,
-                &global,
-            )
-            .await
-        }
+        } => execute_connected(backend, &node_id, edge_type.as_deref(), direction, global).await,
         GraphSubcommand::Code { node_id, context } => {
-            execute_code(&*backend, &node_id, context, &global).await
+            execute_code(backend, &node_id, context, global).await
         }
     }
 }
@@ -209,13 +218,26 @@ async fn execute_find<B: Backend>(
     backend: &B,
     pattern: &str,
     node_type: Option<&str>,
+    attributes: &[AttributeFilter],
     limit: usize,
     global: &GlobalOptions,
 ) -> Result<()> {
-    let nodes = backend
-        .find_nodes(pattern, node_type, limit)
+    // Attribute filters apply after the lookup, so look up every match
+    let lookup_limit = if attributes.is_empty() {
+        limit
+    } else {
+        usize::MAX
+    };
+    let mut nodes = backend
+        .find_nodes(pattern, node_type, lookup_limit)
         .await
         .context("Failed to find nodes")?;
+    nodes.retain(|node| {
+        attributes
+            .iter()
+            .all(|filter| filter.matches_value(node.attribute(&filter.key)))
+    });
+    nodes.truncate(limit);
 
     if nodes.is_empty() {
         if !global.quiet {
@@ -0,0 +1,334 @@
+//! History command - Graph snapshots and trends
+//!
+//! Records timestamped snapshots of the index's health measures (package
+//! coupling, API surface, dead code, graph size) per commit or per day, and
+//! charts them over time for engineering-health dashboards.
+
+use anyhow::{Context, Result};
+use clap::{Args, Subcommand, ValueEnum};
+use codeprysm_core::lazy::{
+    civil_date, GraphSnapshot, History, SnapshotGranularity, TrendMetric, TrendPeriod,
+};
+use codeprysm_core::Provenance;
+
+use super::metrics::unix_now;
+use super::{create_backend, load_config, load_provenance, resolve_workspace};
+use crate::report::OutputArgs;
+use crate::GlobalOptions;
+
+/// Seconds per day
+const DAY: i64 = 86_400;
+
+/// Graph history commands
+#[derive(Subcommand, Debug)]
+pub enum HistoryCommand {
+    /// Record a snapshot of the current index
+    Record(RecordArgs),
+
+    /// List recorded snapshots
+    List(ListArgs),
+
+    /// Chart a measure over time
+    Trend(TrendArgs),
+
+    /// Delete snapshots older than a number of days
+    Prune(PruneArgs),
+}
+
+/// How often snapshots are kept
+#[derive(Debug, Clone, Copy, ValueEnum)]
+pub enum Granularity {
+    /// One snapshot per indexed commit
+    Commit,
+    /// One snapshot per day (UTC)
+    Day,
+}
+
+impl From<Granularity> for SnapshotGranularity {
+    fn from(granularity: Granularity) -> Self {
+        match granularity {
+            Granularity::Commit => SnapshotGranularity::Commit,
+            Granularity::Day => SnapshotGranularity::Day,
+        }
+    }
+}
+
+/// Measure to chart
+#[derive(Debug, Clone, Copy, ValueEnum)]
+pub enum Metric {
+    /// Nodes in the graph
+    Nodes,
+    /// Edges in the graph
+    Edges,
+    /// Source files
+    Files,
+    /// Exported symbols
+    ApiSurface,
+    /// Symbols unreachable from any root
+    DeadCode,
+    /// Package dependencies (efferent coupling summed over packages)
+    Coupling,
+    /// Mean package instability
+    Instability,
+    /// Mean package distance from the main sequence
+    Distance,
+}
+
+impl From<Metric> for TrendMetric {
+    fn from(metric: Metric) -> Self {
+        match metric {
+            Metric::Nodes => TrendMetric::Nodes,
+            Metric::Edges => TrendMetric::Edges,
+            Metric::Files => TrendMetric::Files,
+            Metric::ApiSurface => TrendMetric::ApiSurface,
+            Metric::DeadCode => TrendMetric::DeadCode,
+            Metric::Coupling => TrendMetric::Coupling,
+            Metric::Instability => TrendMetric::Instability,
+            Metric::Distance => TrendMetric::Distance,
+        }
+    }
+}
+
+/// Period to report one value per
+#[derive(Debug, Clone, Copy, ValueEnum)]
+pub enum Period {
+    /// Every snapshot
+    Snapshot,
+    /// The last snapshot of each day
+    Day,
+    /// The last snapshot of each week
+    Week,
+}
+
+impl From<Period> for TrendPeriod {
+    fn from(period: Period) -> Self {
+        match period {
+            Period::Snapshot => TrendPeriod::Snapshot,
+            Period::Day => TrendPeriod::Day,
+            Period::Week => TrendPeriod::Week,
+        }
+    }
+}
+
+#[derive(Args, Debug)]
+pub struct RecordArgs {
+    /// Keep one snapshot per indexed commit or per day; a new snapshot
+    /// replaces the one of the same commit or day
+    #[arg(long, value_enum, default_value = "commit")]
+    per: Granularity,
+
+    #[command(flatten)]
+    output: OutputArgs,
+}
+
+#[derive(Args, Debug)]
+pub struct ListArgs {
+    /// Show only the most recent snapshots
+    #[arg(long, short = 'n')]
+    limit: Option<usize>,
+
+    #[command(flatten)]
+    output: OutputArgs,
+}
+
+#[derive(Args, Debug)]
+pub struct TrendArgs {
+    /// Measure to chart
+    #[arg(value_enum)]
+    metric: Metric,
+
+    /// Report the last snapshot of each period
+    #[arg(long, value_enum, default_value = "week")]
+    by: Period,
+
+    /// Only include packages under this path prefix (package measures only)
+    #[arg(long, short = 'p')]
+    package: Option<String>,
+
+    /// Only include snapshots from the last DAYS days
+    #[arg(long, value_name = "DAYS")]
+    days: Option<u32>,
+
+    #[command(flatten)]
+    output: OutputArgs,
+}
+
+#[derive(Args, Debug)]
+pub struct PruneArgs {
+    /// Keep snapshots from the last DAYS days
+    #[arg(long, value_name = "DAYS")]
+    keep_days: u32,
+}
+
+/// Execute a history command
+pub async fn execute(cmd: HistoryCommand, global: GlobalOptions) -> Result<()> {
+    match cmd {
+        HistoryCommand::Record(args) => execute_record(args, global).await,
+        HistoryCommand::List(args) => execute_list(args, global).await,
+        HistoryCommand::Trend(args) => execute_trend(args, global).await,
+        HistoryCommand::Prune(args) => execute_prune(args, global).await,
+    }
+}
+
+/// Open the history of the workspace's index
+async fn open_history(global: &GlobalOptions, create: bool) -> Result<History> {
+    let workspace = resolve_workspace(global).await?;
+    let config = load_config(global, &workspace)?;
+    let prism_dir = config.prism_dir(&workspace);
+    if !create && !History::exists(&prism_dir) {
+        anyhow::bail!(
+            "No snapshots recorded. Run 'codeprysm history record' first.\n  Path: {}",
+            prism_dir.display()
+        );
+    }
+    History::open(&prism_dir).context("Failed to open graph history")
+}
+
+async fn execute_record(args: RecordArgs, global: GlobalOptions) -> Result<()> {
+    let workspace = resolve_workspace(&global).await?;
+    let config = load_config(&global, &workspace)?;
+    // Stamp the snapshot with the commit the index was built from
+    let commit = load_provenance(&config, &workspace)
+        .unwrap_or_else(|| Provenance::collect(&workspace))
+        .commit;
+    if matches!(args.per, Granularity::Commit) && commit.is_none() {
+        anyhow::bail!("No commit to record a snapshot for; use --per day outside git");
+    }
+
+    let backend = create_backend(&global).await?;
+    let root = backend.workspace_root().to_path_buf();
+    let now = unix_now();
+    let snapshot = backend
+        .with_full_graph(|graph| Ok(GraphSnapshot::capture(graph, &root, now, commit)))
+        .await
+        .context("Failed to measure the graph")?;
+
+    let mut history = open_history(&global, true).await?;
+    let id = history
+        .record(&snapshot, args.per.into())
+        .context("Failed to record snapshot")?;
+
+    if args.output.is_json() {
+        let output = serde_json::json!({ "id": id, "snapshot": snapshot });
+        println!("{}", serde_json::to_string_pretty(&output)?);
+    } else if !global.quiet {
+        println!(
+            "Recorded snapshot {} ({}): {} nodes, {} packages, {} exported, {} dead",
+            id,
+            snapshot.commit.as_deref().map_or("no commit", short_commit),
+            snapshot.nodes,
+            snapshot.packages.len(),
+            snapshot.api_symbols,
+            snapshot.dead_symbols
+        );
+    }
+    Ok(())
+}
+
+async fn execute_list(args: ListArgs, global: GlobalOptions) -> Result<()> {
+    let history = open_history(&global, false).await?;
+    let mut snapshots = history.snapshots().context("Failed to read snapshots")?;
+    if let Some(limit) = args.limit {
+        snapshots.drain(..snapshots.len().saturating_sub(limit));
+    }
+
+    if args.output.is_json() {
+        println!("{}", serde_json::to_string_pretty(&snapshots)?);
+        return Ok(());
+    }
+    if snapshots.is_empty() {
+        if !global.quiet {
+            println!("No snapshots recorded.");
+        }
+        return Ok(());
+    }
+
+    if !global.quiet {
+        println!(
+            "{:>5}  {:<10} {:<12} {:>8} {:>8} {:>6} {:>6} {:>6}",
+            "ID", "DATE", "COMMIT", "NODES", "EDGES", "FILES", "API", "DEAD"
+        );
+    }
+    for s in &snapshots {
+        println!(
+            "{:>5}  {:<10} {:<12} {:>8} {:>8} {:>6} {:>6} {:>6}",
+            s.id,
+            civil_date(s.taken_at.div_euclid(DAY)),
+            s.commit.as_deref().map_or("-", short_commit),
+            s.nodes,
+            s.edges,
+            s.files,
+            s.api_symbols,
+            s.dead_symbols
+        );
+    }
+    Ok(())
+}
+
+async fn execute_trend(args: TrendArgs, global: GlobalOptions) -> Result<()> {
+    let metric = TrendMetric::from(args.metric);
+    if args.package.is_some() && !metric.is_per_package() {
+        anyhow::bail!(
+            "--package does not apply to {}, a graph-wide measure",
+            metric
+        );
+    }
+    let since = args.days.map(|days| unix_now() - i64::from(days) * DAY);
+
+    let history = open_history(&global, false).await?;
+    let points = history
+        .trend(metric, args.by.into(), args.package.as_deref(), since)
+        .context("Failed to query trend")?;
+
+    if args.output.is_json() {
+        let output = serde_json::json!({
+            "metric": metric,
+            "package": args.package,
+            "points": points,
+        });
+        println!("{}", serde_json::to_string_pretty(&output)?);
+        return Ok(());
+    }
+    if points.is_empty() {
+        if !global.quiet {
+            println!("No snapshots in range.");
+        }
+        return Ok(());
+    }
+
+    if !global.quiet {
+        println!(
+            "{:<10} {:<12} {:>10} {:>10}",
+            "PERIOD", "COMMIT", "VALUE", "CHANGE"
+        );
+    }
+    let mut previous: Option<f64> = None;
+    for point in &points {
+        let change = previous.map_or_else(String::new, |p| format!("{:+.2}", point.value - p));
+        println!(
+            "{:<10} {:<12} {:>10.2} {:>10}",
+            point.period,
+            point.commit.as_deref().map_or("-", short_commit),
+            point.value,
+            change
+        );
+        previous = Some(point.value);
+    }
+    Ok(())
+}
+
+async fn execute_prune(args: PruneArgs, global: GlobalOptions) -> Result<()> {
+    let mut history = open_history(&global, false).await?;
+    let deleted = history
+        .prune(unix_now() - i64::from(args.keep_days) * DAY)
+        .context("Failed to prune snapshots")?;
+    if !global.quiet {
+        println!("Deleted {} snapshot(s)", deleted);
+    }
+    Ok(())
+}
+
+/// First 12 characters of a commit SHA
+fn short_commit(commit: &str) -> &str {
+    &commit[..commit.len().min(12)]
+}
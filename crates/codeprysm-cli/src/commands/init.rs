@@ -1,18 +1,32 @@
 //! Initialize command - Create a new CodePrysm workspace
 
-use std::path::PathBuf;
+use std::path::{Path, PathBuf};
 
 use anyhow::{Context, Result};
 use clap::Args;
+use codeprysm_config::GraphFormat;
 use codeprysm_core::builder::{BuilderConfig, GraphBuilder};
+use codeprysm_core::lazy::manager::RootInfo;
 use codeprysm_core::lazy::partitioner::GraphPartitioner;
+use codeprysm_core::lazy::PartitionSink;
+use codeprysm_core::{plan_workspace, ExtractionCache, IndexPlan};
 use codeprysm_search::{GraphIndexer, QdrantConfig};
 use tracing::info;
 
-use super::{load_config, print_info, to_search_embedding_config};
-use crate::progress::{finish_spinner, finish_spinner_warn, spinner};
+use super::clean::format_size;
+use super::plugin::run_extractors;
+use super::{
+    attribute_rules, extraction_rules, file_policy, go_type_check, load_config, print_info,
+    print_warning, save_diagnostics, save_provenance, symbol_limits, sync_text_index,
+    to_search_embedding_config, write_graph_store, FileFilterArgs,
+};
+use crate::progress::{finish_spinner, finish_spinner_warn, spinner, BuildReporter};
+use crate::summarize::summarize_symbols;
 use crate::GlobalOptions;
 
+/// Excluded files listed per reason in dry-run output
+const DRY_RUN_EXAMPLES: usize = 5;
+
 /// Arguments for the init command
 #[derive(Args, Debug)]
 pub struct InitArgs {
@@ -43,6 +57,36 @@ pub struct InitArgs {
     /// Embedding batch size for API calls (default: 200)
     #[arg(long, default_value = "200")]
     embedding_batch_size: usize,
+
+    /// Write nodes and edges to partitioned storage as files are extracted,
+    /// capping peak memory on very large inputs (indexes the workspace as a
+    /// single root; skips extractor plugins, summaries, and semantic
+    /// indexing)
+    #[arg(long)]
+    stream: bool,
+
+    /// Only set cached symbol summaries; do not ask the `[summaries]` model
+    /// for new ones
+    #[arg(long)]
+    no_summaries: bool,
+
+    /// Resolve Go references with the Go type checker (`codeprysm-gotypes`)
+    /// as well as by name; slower, but accurate where names are ambiguous
+    #[arg(long)]
+    go_types: bool,
+
+    /// Show which files the build would parse, reuse from the extraction
+    /// cache, or leave out (and why), with an estimated node count, without
+    /// building anything
+    #[arg(long, short = 'n')]
+    dry_run: bool,
+
+    /// Output the dry-run plan as JSON
+    #[arg(long, requires = "dry_run")]
+    json: bool,
+
+    #[command(flatten)]
+    filter: FileFilterArgs,
 }
 
 /// Execute the init command
@@ -62,6 +106,8 @@ pub async fn execute(args: InitArgs, global: GlobalOptions) -> Result<()> {
         .context("Failed to resolve workspace path")?;
 
     let mut config = load_config(&global, &workspace_path)?;
+    args.filter.apply(&mut config.analysis);
+    config.analysis.go_type_check |= args.go_types;
 
     // Apply CLI overrides (e.g., --embedding-provider)
     let overrides = global.to_config_overrides();
@@ -70,6 +116,31 @@ pub async fn execute(args: InitArgs, global: GlobalOptions) -> Result<()> {
     let prism_dir = config.prism_dir(&workspace_path);
     let manifest_path = prism_dir.join("manifest.json");
 
+    if args.stream && config.storage.graph_format == GraphFormat::Mmap {
+        anyhow::bail!("--stream writes partitioned storage and cannot write the mmap graph store");
+    }
+
+    // Build configuration
+    let builder_config = BuilderConfig {
+        skip_data_nodes: false,
+        max_containment_depth: None,
+        max_files: None,
+        exclude_patterns: config.analysis.exclude_patterns.clone(),
+        include_patterns: config.analysis.include_patterns.clone(),
+        file_policy: file_policy(&config.analysis),
+        attributes: attribute_rules(&config),
+        go_type_check: go_type_check(&config.analysis),
+        extraction: extraction_rules(&config.analysis, &workspace_path)?,
+        symbol_limits: symbol_limits(&config.analysis),
+    };
+    let cache = ExtractionCache::load(&ExtractionCache::locate(&workspace_path, &prism_dir));
+
+    if args.dry_run {
+        let builder = create_builder(args.queries.as_deref(), builder_config)?.with_cache(cache);
+        let plan = plan_workspace(&builder, &workspace_path).context("Failed to plan the build")?;
+        return print_plan(&plan, args.json, quiet);
+    }
+
     // Check if already initialized
     if manifest_path.exists() && !args.force {
         anyhow::bail!(
@@ -92,42 +163,54 @@ pub async fn execute(args: InitArgs, global: GlobalOptions) -> Result<()> {
         print_info(&format!("Created {}", prism_dir.display()), quiet);
     }
 
-    // Build configuration
-    let builder_config = BuilderConfig {
-        skip_data_nodes: false,
-        max_containment_depth: None,
-        max_files: None,
-        exclude_patterns: config.analysis.exclude_patterns.clone(),
-    };
+    // Report build progress from the start of the build
+    let reporter = BuildReporter::new(
+        if args.stream {
+            "Streaming code graph to partitioned storage..."
+        } else {
+            "Building code graph..."
+        },
+        &global.log_format,
+        quiet,
+    );
 
     // Create builder
-    let mut builder = match &args.queries {
-        Some(queries_dir) => {
-            info!("Using custom queries from: {}", queries_dir.display());
-            GraphBuilder::with_config(queries_dir, builder_config)
-                .context("Failed to create graph builder with custom queries")?
-        }
-        None => {
-            info!("Using embedded queries");
-            GraphBuilder::with_embedded_queries(builder_config)
+    let mut builder = create_builder(args.queries.as_deref(), builder_config)?
+        .with_cache(cache)
+        .with_progress(reporter.callback());
+
+    // Derive root name
+    let root_name = workspace_path
+        .file_name()
+        .map(|s| s.to_string_lossy().to_string())
+        .unwrap_or_else(|| "workspace".to_string());
+
+    if args.stream {
+        build_streaming(
+            &mut builder,
+            &workspace_path,
+            &prism_dir,
+            &root_name,
+            reporter,
+        )?;
+        save_diagnostics(&builder, &prism_dir, quiet)?;
+        save_provenance(&config, &workspace_path)?;
+        if !quiet {
+            println!("  Index for search with: codeprysm update --reindex");
         }
-    };
+        return finish_init(&prism_dir, quiet);
+    }
 
     // Build the graph
-    let pb = spinner("Building code graph...", quiet);
-
-    let (graph, roots) = builder
+    let (mut graph, roots) = builder
         .build_from_workspace(&workspace_path)
         .context("Failed to build code graph")?;
 
-    finish_spinner(
-        pb,
-        &format!(
-            "Built code graph ({} code root{})",
-            roots.len(),
-            if roots.len() == 1 { "" } else { "s" }
-        ),
-    );
+    reporter.finish(&format!(
+        "Built code graph ({} code root{})",
+        roots.len(),
+        if roots.len() == 1 { "" } else { "s" }
+    ));
 
     if !quiet && global.verbose {
         println!("  Discovered roots:");
@@ -141,17 +224,26 @@ pub async fn execute(args: InitArgs, global: GlobalOptions) -> Result<()> {
         }
     }
 
-    // Derive root name
-    let root_name = workspace_path
-        .file_name()
-        .map(|s| s.to_string_lossy().to_string())
-        .unwrap_or_else(|| "workspace".to_string());
+    // Add nodes and edges from extractor plugins
+    run_extractors(&mut graph, &workspace_path, &config, quiet);
+
+    // Summarize symbols with the configured model
+    summarize_symbols(
+        &mut graph,
+        &workspace_path,
+        &prism_dir,
+        &config,
+        args.no_summaries,
+        quiet,
+    )
+    .await?;
 
     // Partition and save the graph
     let pb = spinner("Saving graph to partitioned storage...", quiet);
 
     let (_, stats) = GraphPartitioner::partition_with_stats(&graph, &prism_dir, Some(&root_name))
         .context("Failed to partition graph")?;
+    write_graph_store(&graph, &config, &workspace_path)?;
 
     finish_spinner(
         pb,
@@ -161,6 +253,17 @@ pub async fn execute(args: InitArgs, global: GlobalOptions) -> Result<()> {
         ),
     );
 
+    builder
+        .stats()
+        .save(&prism_dir)
+        .context("Failed to save build statistics")?;
+    save_diagnostics(&builder, &prism_dir, quiet)?;
+    save_provenance(&config, &workspace_path)?;
+    sync_text_index(&graph, &prism_dir, &workspace_path, quiet)?;
+    if let Some(cache) = builder.cache() {
+        cache.save().context("Failed to save extraction cache")?;
+    }
+
     // Index the graph if not skipped
     // Use the in-memory graph directly instead of reloading from disk via backend
     if !no_index {
@@ -168,7 +271,7 @@ pub async fn execute(args: InitArgs, global: GlobalOptions) -> Result<()> {
 
         // Create indexer with the configured embedding provider
         // This avoids the overhead of reloading all partitions from disk
-        let qdrant_config = QdrantConfig::with_url(&global.qdrant_url);
+        let qdrant_config = QdrantConfig::with_url(&config.backend.qdrant.url);
         let embedding_config = to_search_embedding_config(&config);
 
         match GraphIndexer::from_config(
@@ -191,8 +294,10 @@ pub async fn execute(args: InitArgs, global: GlobalOptions) -> Result<()> {
                     Err(e) => {
                         finish_spinner_warn(pb, "Indexing failed");
                         if !quiet {
-                            eprintln!("  Warning: {}", e);
-                            eprintln!("  You can index later with: codeprysm update --reindex");
+                            print_warning(&format!(
+                                "{}; you can index later with: codeprysm update --reindex",
+                                e
+                            ));
                         }
                     }
                 }
@@ -200,13 +305,108 @@ pub async fn execute(args: InitArgs, global: GlobalOptions) -> Result<()> {
             Err(e) => {
                 finish_spinner_warn(pb, "Indexing skipped (Qdrant may not be running)");
                 if !quiet {
-                    eprintln!("  Warning: {}", e);
-                    eprintln!("  You can index later with: codeprysm update --reindex");
+                    print_warning(&format!(
+                        "{}; you can index later with: codeprysm update --reindex",
+                        e
+                    ));
                 }
             }
         }
     }
 
+    finish_init(&prism_dir, quiet)
+}
+
+/// Create a graph builder with custom queries from `queries_dir`, or the
+/// embedded queries.
+fn create_builder(queries_dir: Option<&Path>, config: BuilderConfig) -> Result<GraphBuilder> {
+    match queries_dir {
+        Some(queries_dir) => {
+            info!("Using custom queries from: {}", queries_dir.display());
+            GraphBuilder::with_config(queries_dir, config)
+                .context("Failed to create graph builder with custom queries")
+        }
+        None => {
+            info!("Using embedded queries");
+            Ok(GraphBuilder::with_embedded_queries(config))
+        }
+    }
+}
+
+/// Print what a build would do, as a summary with examples of the excluded
+/// files of each reason or as JSON.
+fn print_plan(plan: &IndexPlan, json: bool, quiet: bool) -> Result<()> {
+    if json {
+        println!("{}", serde_json::to_string_pretty(plan)?);
+        return Ok(());
+    }
+    if quiet {
+        return Ok(());
+    }
+
+    println!("Dry run: nothing was built\n");
+    println!(
+        "  Parse:     {} file(s) ({})",
+        plan.parse,
+        format_size(plan.parse_bytes())
+    );
+    println!("  Cached:    {} file(s)", plan.cached);
+    println!("  Excluded:  {} file(s)", plan.excluded);
+    println!("  Estimated nodes: ~{}", plan.estimated_nodes);
+
+    for (reason, files) in plan.exclusions() {
+        println!("\nExcluded: {} ({}):", reason, files.len());
+        for file in files.iter().take(DRY_RUN_EXAMPLES) {
+            println!("  {}", file.path);
+        }
+        if files.len() > DRY_RUN_EXAMPLES {
+            println!("  ... and {} more", files.len() - DRY_RUN_EXAMPLES);
+        }
+    }
+    Ok(())
+}
+
+/// Build the graph of a workspace straight into partitioned storage.
+fn build_streaming(
+    builder: &mut GraphBuilder,
+    workspace_path: &Path,
+    prism_dir: &Path,
+    root_name: &str,
+    reporter: BuildReporter,
+) -> Result<()> {
+    let root = RootInfo {
+        name: root_name.to_string(),
+        root_type: "code".to_string(),
+        relative_path: ".".to_string(),
+        remote_url: None,
+        branch: None,
+        commit: None,
+    };
+    let mut sink =
+        PartitionSink::create(prism_dir, root).context("Failed to create partitioned storage")?;
+    let stats = builder
+        .build_streaming(workspace_path, &mut sink)
+        .context("Failed to build code graph")?;
+
+    reporter.finish(&format!(
+        "Saved graph ({} nodes, {} edges, {} partitions)",
+        stats.nodes,
+        stats.edges,
+        sink.stats().partition_count
+    ));
+
+    builder
+        .stats()
+        .save(prism_dir)
+        .context("Failed to save build statistics")?;
+    if let Some(cache) = builder.cache() {
+        cache.save().context("Failed to save extraction cache")?;
+    }
+    Ok(())
+}
+
+/// Write the local config file and print next steps.
+fn finish_init(prism_dir: &Path, quiet: bool) -> Result<()> {
     // Create local config file if it doesn't exist
     let local_config_path = prism_dir.join("config.toml");
     if !local_config_path.exists() {
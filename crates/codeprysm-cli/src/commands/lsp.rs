@@ -0,0 +1,595 @@
+//! LSP command - Language server backed by the code graph
+//!
+//! Serves go-to-definition, find-references, call hierarchy, workspace
+//! symbols, and rename over stdio from the index, for editors in repositories where the
+//! native language server is unavailable or too slow. Answers reflect the
+//! last `codeprysm update`; the index is reloaded when it changes on disk.
+//!
+//! Open documents are not tracked: positions are resolved against the files
+//! on disk, so unsaved edits that shift lines give stale answers.
+
+use std::collections::HashMap;
+use std::io::{BufRead, BufReader, Read, Write};
+use std::path::{Path, PathBuf};
+use std::time::SystemTime;
+
+use anyhow::{bail, Context, Result};
+use clap::Args;
+use codeprysm_core::analysis::call_hierarchy::{path_to_uri, symbol_kind, utf16_len};
+use codeprysm_core::analysis::{
+    call_edges, fuzzy_search, plan_rename, references, symbols_at, CallDirection, FuzzyOptions,
+};
+use codeprysm_core::lazy::LazyGraphManager;
+use codeprysm_core::{Node, NodeType, PetCodeGraph};
+use serde_json::{json, Value};
+
+use super::{load_config, resolve_workspace};
+use crate::GlobalOptions;
+
+/// JSON-RPC error: request before initialize or after shutdown
+const INVALID_REQUEST: i64 = -32600;
+/// JSON-RPC error: unknown method
+const METHOD_NOT_FOUND: i64 = -32601;
+/// JSON-RPC error: missing or malformed parameters
+const INVALID_PARAMS: i64 = -32602;
+/// JSON-RPC error: failure while answering
+const INTERNAL_ERROR: i64 = -32603;
+
+/// Maximum number of workspace symbols returned per query
+const WORKSPACE_SYMBOL_LIMIT: usize = 100;
+
+/// Arguments for the lsp command
+#[derive(Args, Debug)]
+pub struct LspArgs {
+    /// Communicate over stdin/stdout (the default and only transport;
+    /// accepted because editors commonly pass it)
+    #[arg(long)]
+    stdio: bool,
+}
+
+/// Execute the lsp command
+pub async fn execute(_args: LspArgs, global: GlobalOptions) -> Result<()> {
+    let workspace_path = resolve_workspace(&global).await?;
+    let config = load_config(&global, &workspace_path)?;
+    let prism_dir = config.prism_dir(&workspace_path);
+    if !prism_dir.join("manifest.json").exists() {
+        bail!(
+            "No index found at {}. Run 'codeprysm init' first.",
+            prism_dir.display()
+        );
+    }
+
+    tokio::task::spawn_blocking(move || {
+        let mut server = Server::new(workspace_path, prism_dir);
+        let stdin = std::io::stdin();
+        let stdout = std::io::stdout();
+        server.run(&mut BufReader::new(stdin.lock()), &mut stdout.lock())
+    })
+    .await
+    .context("Language server stopped unexpectedly")?
+}
+
+/// Error answered to a request
+type RpcError = (i64, String);
+
+/// Language server state
+struct Server {
+    /// Repository root (paths in the graph are relative to it)
+    root: PathBuf,
+    /// Index directory
+    prism_dir: PathBuf,
+    /// Loaded graph, with the manifest time it was loaded at
+    graph: Option<(PetCodeGraph, Option<SystemTime>)>,
+    /// Source lines read while answering, keyed by relative path
+    sources: HashMap<String, Vec<String>>,
+    /// Whether `initialize` was received
+    initialized: bool,
+    /// Whether `shutdown` was received
+    shutting_down: bool,
+}
+
+impl Server {
+    fn new(root: PathBuf, prism_dir: PathBuf) -> Self {
+        Self {
+            root,
+            prism_dir,
+            graph: None,
+            sources: HashMap::new(),
+            initialized: false,
+            shutting_down: false,
+        }
+    }
+
+    /// Answer messages until `exit` or end of input
+    fn run(&mut self, input: &mut impl BufRead, output: &mut impl Write) -> Result<()> {
+        while let Some(message) = read_message(input)? {
+            let method = message["method"].as_str().unwrap_or_default();
+            let Some(id) = message.get("id").cloned() else {
+                // Notifications need no answer
+                if method == "exit" {
+                    break;
+                }
+                continue;
+            };
+
+            let response = match self.handle(method, &message["params"]) {
+                Ok(result) => json!({"jsonrpc": "2.0", "id": id, "result": result}),
+                Err((code, message)) => json!({
+                    "jsonrpc": "2.0",
+                    "id": id,
+                    "error": {"code": code, "message": message},
+                }),
+            };
+            write_message(output, &response)?;
+        }
+        Ok(())
+    }
+
+    /// Answer a request
+    fn handle(&mut self, method: &str, params: &Value) -> Result<Value, RpcError> {
+        if method == "initialize" {
+            self.initialized = true;
+            return Ok(json!({
+                "capabilities": {
+                    "definitionProvider": true,
+                    "referencesProvider": true,
+                    "callHierarchyProvider": true,
+                    "workspaceSymbolProvider": true,
+                    "renameProvider": true,
+                },
+                "serverInfo": {"name": "codeprysm", "version": env!("CARGO_PKG_VERSION")},
+            }));
+        }
+        if !self.initialized || self.shutting_down {
+            return Err((INVALID_REQUEST, format!("Unexpected request: {}", method)));
+        }
+        if method == "shutdown" {
+            self.shutting_down = true;
+            return Ok(Value::Null);
+        }
+
+        self.refresh()
+            .map_err(|e| (INTERNAL_ERROR, format!("Failed to load index: {:#}", e)))?;
+        // Files may have changed since the last request
+        self.sources.clear();
+
+        match method {
+            "textDocument/definition" => self.definition(params),
+            "textDocument/references" => self.references(params),
+            "textDocument/prepareCallHierarchy" => self.prepare_call_hierarchy(params),
+            "callHierarchy/incomingCalls" => self.calls(params, CallDirection::Incoming),
+            "callHierarchy/outgoingCalls" => self.calls(params, CallDirection::Outgoing),
+            "workspace/symbol" => self.workspace_symbols(params),
+            "textDocument/rename" => self.rename(params),
+            _ => Err((METHOD_NOT_FOUND, format!("Unsupported method: {}", method))),
+        }
+    }
+
+    /// Load the graph, or reload it if the index changed since
+    fn refresh(&mut self) -> Result<()> {
+        let modified = std::fs::metadata(self.prism_dir.join("manifest.json"))
+            .and_then(|m| m.modified())
+            .ok();
+        if self
+            .graph
+            .as_ref()
+            .is_some_and(|(_, loaded)| *loaded == modified)
+        {
+            return Ok(());
+        }
+
+        let graph = LazyGraphManager::open(&self.prism_dir)?.load_full_graph()?;
+        self.graph = Some((graph, modified));
+        Ok(())
+    }
+
+    /// Symbols named by the identifier at a text document position
+    fn symbols_at_position(&mut self, params: &Value) -> Result<Vec<Node>, RpcError> {
+        let uri = params["textDocument"]["uri"].as_str().unwrap_or_default();
+        let (Some(line), Some(character)) = (
+            params["position"]["line"].as_u64(),
+            params["position"]["character"].as_u64(),
+        ) else {
+            return Err((INVALID_PARAMS, "Missing position".to_string()));
+        };
+        let Some(file) = uri_to_relative(uri, &self.root) else {
+            return Ok(Vec::new());
+        };
+
+        let line = line as usize + 1;
+        let Some(ident) = self
+            .source_line(&file, line)
+            .and_then(|text| identifier_at(text, character as usize))
+        else {
+            return Ok(Vec::new());
+        };
+
+        let graph = self.graph();
+        Ok(symbols_at(graph, &file, line, &ident)
+            .into_iter()
+            .cloned()
+            .collect())
+    }
+
+    fn definition(&mut self, params: &Value) -> Result<Value, RpcError> {
+        let symbols = self.symbols_at_position(params)?;
+        let locations: Vec<Value> = symbols
+            .iter()
+            .map(|node| self.location(&node.file, node.line, &node.name))
+            .collect();
+        Ok(json!(locations))
+    }
+
+    fn references(&mut self, params: &Value) -> Result<Value, RpcError> {
+        let include_declaration = params["context"]["includeDeclaration"]
+            .as_bool()
+            .unwrap_or(false);
+        let symbols = self.symbols_at_position(params)?;
+
+        let mut locations = Vec::new();
+        for node in &symbols {
+            if include_declaration {
+                locations.push(self.location(&node.file, node.line, &node.name));
+            }
+            for reference in references(self.graph(), &node.id) {
+                locations.push(self.location(&reference.file, reference.line, &node.name));
+            }
+        }
+        Ok(json!(locations))
+    }
+
+    fn prepare_call_hierarchy(&mut self, params: &Value) -> Result<Value, RpcError> {
+        let symbols = self.symbols_at_position(params)?;
+        let items: Vec<Value> = symbols
+            .iter()
+            .filter(|node| node.node_type == NodeType::Callable)
+            .map(|node| self.call_hierarchy_item(node))
+            .collect();
+        Ok(json!(items))
+    }
+
+    fn calls(&mut self, params: &Value, direction: CallDirection) -> Result<Value, RpcError> {
+        let Some(id) = params["item"]["data"]["id"].as_str() else {
+            return Err((INVALID_PARAMS, "Missing call hierarchy item".to_string()));
+        };
+
+        // Clone out of the graph so source lines can be read while rendering
+        let edges: Vec<(Node, Vec<usize>)> = call_edges(self.graph(), id, direction)
+            .into_iter()
+            .map(|call| (call.node.clone(), call.lines))
+            .collect();
+        let target_name = self
+            .graph()
+            .get_node(id)
+            .map(|n| n.name.clone())
+            .unwrap_or_default();
+        let target_file = self.file_of(id);
+
+        let calls: Vec<Value> = edges
+            .iter()
+            .map(|(node, lines)| {
+                // Call sites are in the caller's file and name the callee
+                let (file, name, key) = match direction {
+                    CallDirection::Incoming => (&node.file, &target_name, "from"),
+                    CallDirection::Outgoing => (&target_file, &node.name, "to"),
+                };
+                let ranges: Vec<Value> = lines
+                    .iter()
+                    .map(|&line| self.location(file, line, name)["range"].clone())
+                    .collect();
+                json!({key: self.call_hierarchy_item(node), "fromRanges": ranges})
+            })
+            .collect();
+        Ok(json!(calls))
+    }
+
+    fn workspace_symbols(&mut self, params: &Value) -> Result<Value, RpcError> {
+        let query = params["query"].as_str().unwrap_or_default();
+        let options = FuzzyOptions {
+            limit: WORKSPACE_SYMBOL_LIMIT,
+            docs_root: None,
+        };
+        let matches = fuzzy_search(self.graph(), query, &options);
+
+        let symbols: Vec<Value> = matches
+            .iter()
+            .map(|m| {
+                json!({
+                    "name": m.name,
+                    "kind": symbol_kind(m.node_type, m.kind.as_deref(), None),
+                    "location": self.location(&m.file, m.line, &m.name),
+                    "containerName": m.file,
+                })
+            })
+            .collect();
+        Ok(json!(symbols))
+    }
+
+    /// Rename the symbol at a position, refusing names already taken in its
+    /// scope
+    fn rename(&mut self, params: &Value) -> Result<Value, RpcError> {
+        let Some(new_name) = params["newName"].as_str() else {
+            return Err((INVALID_PARAMS, "Missing newName".to_string()));
+        };
+        let symbols = self.symbols_at_position(params)?;
+        let symbol = match symbols.as_slice() {
+            [] => return Ok(Value::Null),
+            [symbol] => symbol,
+            _ => {
+                let ids: Vec<&str> = symbols.iter().map(|n| n.id.as_str()).collect();
+                return Err((
+                    INVALID_PARAMS,
+                    format!("Ambiguous symbol: {}", ids.join(", ")),
+                ));
+            }
+        };
+
+        let plan = plan_rename(self.graph(), &symbol.id, new_name, &self.root)
+            .map_err(|e| (INVALID_PARAMS, e.to_string()))?;
+        if let Some(conflict) = plan.conflicts.first() {
+            return Err((
+                INVALID_PARAMS,
+                format!("'{}' is already declared: {}", new_name, conflict),
+            ));
+        }
+
+        let mut changes: HashMap<String, Vec<Value>> = HashMap::new();
+        for edit in &plan.edits {
+            let Some(start) = self
+                .source_line(&edit.file, edit.line)
+                .and_then(|text| text.get(..edit.column - 1))
+                .map(utf16_len)
+            else {
+                continue;
+            };
+            let line = edit.line - 1;
+            changes
+                .entry(path_to_uri(&self.root.join(&edit.file)))
+                .or_default()
+                .push(json!({
+                    "range": {
+                        "start": {"line": line, "character": start},
+                        "end": {"line": line, "character": start + utf16_len(&edit.old_text)},
+                    },
+                    "newText": edit.new_text,
+                }));
+        }
+        Ok(json!({"changes": changes}))
+    }
+
+    fn call_hierarchy_item(&mut self, node: &Node) -> Value {
+        let selection = self.location(&node.file, node.line, &node.name);
+        json!({
+            "name": node.name,
+            "kind": symbol_kind(node.node_type, node.kind.as_deref(), node.subtype.as_deref()),
+            "detail": node.id,
+            "uri": selection["uri"],
+            "range": {
+                "start": {"line": node.line.saturating_sub(1), "character": 0},
+                "end": {"line": node.end_line.max(node.line).saturating_sub(1), "character": 0},
+            },
+            "selectionRange": selection["range"],
+            "data": {"id": node.id},
+        })
+    }
+
+    /// LSP location of `name` on a line (1-indexed), or of the line start if
+    /// the name does not occur on it
+    fn location(&mut self, file: &str, line: usize, name: &str) -> Value {
+        let uri = path_to_uri(&self.root.join(file));
+        let (start, end) = self
+            .source_line(file, line)
+            .and_then(|text| {
+                let offset = text.find(name)?;
+                let start = utf16_len(&text[..offset]);
+                Some((start, start + utf16_len(name)))
+            })
+            .unwrap_or((0, 0));
+        let line = line.saturating_sub(1);
+        json!({
+            "uri": uri,
+            "range": {
+                "start": {"line": line, "character": start},
+                "end": {"line": line, "character": end},
+            },
+        })
+    }
+
+    /// A source line (1-indexed), read from disk and cached per request
+    fn source_line(&mut self, file: &str, line: usize) -> Option<&str> {
+        let root = &self.root;
+        let lines = self.sources.entry(file.to_string()).or_insert_with(|| {
+            std::fs::read_to_string(root.join(file))
+                .map(|text| text.lines().map(str::to_string).collect())
+                .unwrap_or_default()
+        });
+        lines.get(line.checked_sub(1)?).map(String::as_str)
+    }
+
+    fn file_of(&self, id: &str) -> String {
+        self.graph()
+            .get_node(id)
+            .map(|n| n.file.clone())
+            .unwrap_or_default()
+    }
+
+    fn graph(&self) -> &PetCodeGraph {
+        &self
+            .graph
+            .as_ref()
+            .expect("graph is loaded before answering requests")
+            .0
+    }
+}
+
+/// The identifier around a UTF-16 column of a line
+fn identifier_at(text: &str, character: usize) -> Option<String> {
+    let chars: Vec<char> = text.chars().collect();
+    let is_ident = |c: char| c.is_alphanumeric() || c == '_' || c == '$';
+
+    // Convert the UTF-16 column to a char index
+    let mut index = chars.len();
+    let mut column = 0;
+    for (i, c) in chars.iter().enumerate() {
+        if column >= character {
+            index = i;
+            break;
+        }
+        column += c.len_utf16();
+    }
+
+    // A cursor right after an identifier still selects it
+    if !chars.get(index).is_some_and(|&c| is_ident(c)) {
+        index = index.checked_sub(1)?;
+        if !is_ident(chars[index]) {
+            return None;
+        }
+    }
+
+    let start = (0..=index)
+        .rev()
+        .take_while(|&i| is_ident(chars[i]))
+        .last()?;
+    let end = (index..chars.len())
+        .take_while(|&i| is_ident(chars[i]))
+        .last()?;
+    Some(chars[start..=end].iter().collect())
+}
+
+/// Convert a `file://` URI to a path relative to the repository root
+fn uri_to_relative(uri: &str, root: &Path) -> Option<String> {
+    let path = percent_decode(uri.strip_prefix("file://")?);
+    let relative = Path::new(&path).strip_prefix(root).ok()?;
+    Some(relative.to_string_lossy().replace('\\', "/"))
+}
+
+/// Decode `%XX` escapes
+fn percent_decode(text: &str) -> String {
+    let bytes = text.as_bytes();
+    let mut decoded = Vec::with_capacity(bytes.len());
+    let mut i = 0;
+    while i < bytes.len() {
+        let escaped = (bytes[i] == b'%')
+            .then(|| text.get(i + 1..i + 3))
+            .flatten()
+            .and_then(|hex| u8::from_str_radix(hex, 16).ok());
+        match escaped {
+            Some(byte) => {
+                decoded.push(byte);
+                i += 3;
+            }
+            None => {
+                decoded.push(bytes[i]);
+                i += 1;
+            }
+        }
+    }
+    String::from_utf8_lossy(&decoded).into_owned()
+}
+
+/// Read one `Content-Length` framed message, or `None` at end of input
+fn read_message(input: &mut impl BufRead) -> Result<Option<Value>> {
+    let mut length = None;
+    loop {
+        let mut header = String::new();
+        if input.read_line(&mut header)? == 0 {
+            return Ok(None);
+        }
+        let header = header.trim_end();
+        if header.is_empty() {
+            break;
+        }
+        if let Some((name, value)) = header.split_once(':') {
+            if name.eq_ignore_ascii_case("content-length") {
+                length = Some(
+                    value
+                        .trim()
+                        .parse::<usize>()
+                        .context("Invalid Content-Length")?,
+                );
+            }
+        }
+    }
+
+    let length = length.context("Message without Content-Length")?;
+    let mut body = vec![0; length];
+    input.read_exact(&mut body)?;
+    Ok(Some(
+        serde_json::from_slice(&body).context("Invalid JSON-RPC message")?,
+    ))
+}
+
+/// Write one `Content-Length` framed message
+fn write_message(output: &mut impl Write, message: &Value) -> Result<()> {
+    let body = serde_json::to_string(message)?;
+    write!(output, "Content-Length: {}\r\n\r\n{}", body.len(), body)?;
+    output.flush()?;
+    Ok(())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn frame(message: Value) -> String {
+        let body = message.to_string();
+        format!("Content-Length: {}\r\n\r\n{}", body.len(), body)
+    }
+
+    #[test]
+    fn test_identifier_at() {
+        let line = "    result := util.Parse(input)";
+        assert_eq!(identifier_at(line, 4).as_deref(), Some("result"));
+        assert_eq!(identifier_at(line, 21).as_deref(), Some("Parse"));
+        // Right after the identifier
+        assert_eq!(identifier_at(line, 10).as_deref(), Some("result"));
+        assert_eq!(identifier_at(line, 0), None);
+        assert_eq!(identifier_at("", 3), None);
+        // UTF-16 columns
+        assert_eq!(identifier_at("\"😀\" + name", 7).as_deref(), Some("name"));
+    }
+
+    #[test]
+    fn test_uri_conversion() {
+        let root = Path::new("/repo/my project");
+        let uri = path_to_uri(&root.join("src/main.go"));
+        assert_eq!(uri, "file:///repo/my%20project/src/main.go");
+        assert_eq!(uri_to_relative(&uri, root).as_deref(), Some("src/main.go"));
+        assert_eq!(uri_to_relative("file:///elsewhere/a.go", root), None);
+        assert_eq!(uri_to_relative("untitled:Untitled-1", root), None);
+    }
+
+    #[test]
+    fn test_lifecycle_without_index() {
+        let temp = tempfile::tempdir().unwrap();
+        let mut server = Server::new(temp.path().to_path_buf(), temp.path().join(".codeprysm"));
+
+        let input = [
+            frame(json!({"jsonrpc": "2.0", "id": 1, "method": "workspace/symbol", "params": {}})),
+            frame(json!({"jsonrpc": "2.0", "id": 2, "method": "initialize", "params": {}})),
+            frame(json!({"jsonrpc": "2.0", "method": "initialized", "params": {}})),
+            frame(json!({"jsonrpc": "2.0", "id": 3, "method": "shutdown"})),
+            frame(json!({"jsonrpc": "2.0", "method": "exit"})),
+            frame(json!({"jsonrpc": "2.0", "id": 4, "method": "shutdown"})),
+        ]
+        .concat();
+        let mut output = Vec::new();
+        server
+            .run(&mut std::io::Cursor::new(input), &mut output)
+            .unwrap();
+
+        let mut responses = Vec::new();
+        let mut reader = std::io::Cursor::new(output);
+        while let Some(message) = read_message(&mut reader).unwrap() {
+            responses.push(message);
+        }
+        assert_eq!(responses.len(), 3);
+        assert_eq!(responses[0]["error"]["code"], INVALID_REQUEST);
+        assert_eq!(
+            responses[1]["result"]["capabilities"]["definitionProvider"],
+            true
+        );
+        assert_eq!(responses[2]["id"], 3);
+        assert!(responses[2]["result"].is_null());
+    }
+}
@@ -0,0 +1,103 @@
+//! Merge command - Combine shards into the workspace graph
+//!
+//! Unifies shards written by `codeprysm shard`, resolves references across
+//! them, and saves the result as the workspace's code graph, as
+//! `codeprysm init` would.
+
+use std::path::PathBuf;
+
+use anyhow::{Context, Result};
+use clap::Args;
+use codeprysm_core::lazy::partitioner::GraphPartitioner;
+use codeprysm_core::{merge_shards, remove_disabled_edges, GraphArchive, GraphShard};
+
+use super::{
+    extraction_rules, load_config, load_provenance, print_info, resolve_workspace, save_provenance,
+    write_graph_store,
+};
+use crate::progress::{finish_spinner, spinner};
+use crate::GlobalOptions;
+
+/// Arguments for the merge command
+#[derive(Args, Debug)]
+pub struct MergeArgs {
+    /// Shard files written by `codeprysm shard`
+    #[arg(required = true)]
+    shards: Vec<PathBuf>,
+
+    /// Also write the merged graph to a compressed archive (.cpz)
+    #[arg(long, value_name = "FILE")]
+    archive: Option<PathBuf>,
+}
+
+/// Execute the merge command
+pub async fn execute(args: MergeArgs, global: GlobalOptions) -> Result<()> {
+    let workspace_path = resolve_workspace(&global).await?;
+    let config = load_config(&global, &workspace_path)?;
+    let prism_dir = config.prism_dir(&workspace_path);
+
+    let shards = args
+        .shards
+        .iter()
+        .map(|path| {
+            GraphShard::load(path)
+                .with_context(|| format!("Failed to load shard {}", path.display()))
+        })
+        .collect::<Result<Vec<_>>>()?;
+
+    let pb = spinner("Merging shards...", global.quiet);
+    let mut graph = merge_shards(&shards).context("Failed to merge shards")?;
+    // References resolved across shards may be of disabled types
+    remove_disabled_edges(
+        &mut graph,
+        &extraction_rules(&config.analysis, &workspace_path)?,
+    );
+    finish_spinner(
+        pb,
+        &format!(
+            "Merged {} shard{} ({} nodes, {} edges)",
+            shards.len(),
+            if shards.len() == 1 { "" } else { "s" },
+            graph.node_count(),
+            graph.edge_count()
+        ),
+    );
+
+    if !prism_dir.exists() {
+        std::fs::create_dir_all(&prism_dir).context("Failed to create .codeprysm directory")?;
+        print_info(&format!("Created {}", prism_dir.display()), global.quiet);
+    }
+
+    let root_name = workspace_path
+        .file_name()
+        .map(|s| s.to_string_lossy().to_string())
+        .unwrap_or_else(|| "workspace".to_string());
+
+    let pb = spinner("Saving graph...", global.quiet);
+    let (_, stats) = GraphPartitioner::partition_with_stats(&graph, &prism_dir, Some(&root_name))
+        .context("Failed to save graph")?;
+    write_graph_store(&graph, &config, &workspace_path)?;
+    save_provenance(&config, &workspace_path)?;
+    finish_spinner(
+        pb,
+        &format!(
+            "Saved graph ({} nodes, {} partitions)",
+            stats.total_nodes, stats.partition_count
+        ),
+    );
+
+    if let Some(ref path) = args.archive {
+        GraphArchive::write(
+            &graph,
+            load_provenance(&config, &workspace_path).as_ref(),
+            path,
+        )
+        .with_context(|| format!("Failed to write {}", path.display()))?;
+        print_info(
+            &format!("Wrote archive to {}", path.display()),
+            global.quiet,
+        );
+    }
+
+    Ok(())
+}
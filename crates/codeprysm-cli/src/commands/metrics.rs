@@ -0,0 +1,700 @@
+//! Metrics command - Code quality metrics
+//!
+//! Lists the most complex functions per package from the complexity recorded
+//! during indexing, with optional thresholds that fail the command for CI
+//! gating, package-level coupling metrics that can be compared against a
+//! saved baseline to track architectural drift, fan-in/fan-out hotspots
+//! weighted by git churn, and per-function refactoring risk from churn,
+//! complexity, and fan-in.
+
+use std::collections::{BTreeMap, HashMap};
+use std::path::{Path, PathBuf};
+use std::time::{SystemTime, UNIX_EPOCH};
+
+use anyhow::{Context, Result};
+use clap::{Args, Subcommand, ValueEnum};
+use codeprysm_core::analysis::{
+    find_hotspots, function_metrics, package_metrics, risk_report, top_by_package,
+    ComplexityMetric, ComplexityThresholds, FunctionMetrics, Hotspot, HotspotOptions,
+    PackageMetrics, RiskOptions, SymbolRisk,
+};
+use codeprysm_core::{GitHistory, NodeType};
+use serde::Deserialize;
+
+use super::{create_backend, load_config, print_warning, resolve_workspace};
+use crate::report::{OutputArgs, PolicyArgs};
+use crate::GlobalOptions;
+
+/// Code metrics commands
+#[derive(Subcommand, Debug)]
+pub enum MetricsCommand {
+    /// List the most complex functions per package
+    Functions(FunctionsArgs),
+
+    /// Show package coupling, instability, and abstractness
+    Packages(PackagesArgs),
+
+    /// Rank functions and types by fan-in, fan-out, and churn
+    Hotspots(HotspotsArgs),
+
+    /// Rank functions by refactoring risk: per-function churn, complexity,
+    /// and fan-in
+    Risk(RiskArgs),
+}
+
+/// Metric to rank functions by
+#[derive(Debug, Clone, Copy, ValueEnum)]
+pub enum SortMetric {
+    /// Cognitive complexity
+    Cognitive,
+    /// Cyclomatic complexity
+    Cyclomatic,
+}
+
+impl From<SortMetric> for ComplexityMetric {
+    fn from(metric: SortMetric) -> Self {
+        match metric {
+            SortMetric::Cognitive => ComplexityMetric::Cognitive,
+            SortMetric::Cyclomatic => ComplexityMetric::Cyclomatic,
+        }
+    }
+}
+
+#[derive(Args, Debug)]
+pub struct FunctionsArgs {
+    /// Number of functions to show per package
+    #[arg(long, short = 'n', default_value = "5")]
+    top: usize,
+
+    /// Only include packages under this path prefix
+    #[arg(long, short = 'p')]
+    package: Option<String>,
+
+    /// Metric to rank by
+    #[arg(long, short = 's', value_enum, default_value = "cognitive")]
+    sort: SortMetric,
+
+    /// Fail if any function's cyclomatic complexity exceeds this value
+    /// (defaults to `analysis.max_cyclomatic`)
+    #[arg(long)]
+    max_cyclomatic: Option<u32>,
+
+    /// Fail if any function's cognitive complexity exceeds this value
+    /// (defaults to `analysis.max_cognitive`)
+    #[arg(long)]
+    max_cognitive: Option<u32>,
+
+    #[command(flatten)]
+    output: OutputArgs,
+
+    #[command(flatten)]
+    policy: PolicyArgs,
+}
+
+/// Column to order packages by
+#[derive(Debug, Clone, Copy, ValueEnum)]
+pub enum PackageSort {
+    /// Package path
+    Name,
+    /// Instability, most unstable first
+    Instability,
+    /// Distance from the main sequence, farthest first
+    Distance,
+    /// Afferent coupling, most depended-upon first
+    Afferent,
+    /// Efferent coupling, most dependencies first
+    Efferent,
+}
+
+#[derive(Args, Debug)]
+pub struct PackagesArgs {
+    /// Only include packages under this path prefix
+    #[arg(long, short = 'p')]
+    package: Option<String>,
+
+    /// Column to order packages by
+    #[arg(long, short = 's', value_enum, default_value = "name")]
+    sort: PackageSort,
+
+    /// Compare against a report previously saved with --output json
+    #[arg(long, value_name = "FILE")]
+    baseline: Option<PathBuf>,
+
+    #[command(flatten)]
+    output: OutputArgs,
+
+    #[command(flatten)]
+    policy: PolicyArgs,
+}
+
+/// Symbols to include in the hotspot report
+#[derive(Debug, Clone, Copy, ValueEnum)]
+pub enum HotspotKind {
+    /// Functions and types
+    All,
+    /// Functions and methods only
+    Functions,
+    /// Types only
+    Types,
+}
+
+/// Column to order hotspots by
+#[derive(Debug, Clone, Copy, ValueEnum)]
+pub enum HotspotSort {
+    /// Churn-weighted centrality
+    Score,
+    /// Fan-in, most used first
+    FanIn,
+    /// Fan-out, most dependencies first
+    FanOut,
+    /// Commits touching the file
+    Churn,
+}
+
+#[derive(Args, Debug)]
+pub struct HotspotsArgs {
+    /// Symbols to include
+    #[arg(long, short = 'k', value_enum, default_value = "all")]
+    kind: HotspotKind,
+
+    /// Only include symbols under this path prefix
+    #[arg(long, short = 'p')]
+    package: Option<String>,
+
+    /// Column to order by
+    #[arg(long, short = 's', value_enum, default_value = "score")]
+    sort: HotspotSort,
+
+    /// Only count commits more recent than this date (e.g., "6 months ago")
+    #[arg(long)]
+    since: Option<String>,
+
+    /// Ignore git history and rank by connectivity alone
+    #[arg(long)]
+    no_churn: bool,
+
+    /// Only show flagged symbols (god objects and fragile hubs)
+    #[arg(long)]
+    flagged: bool,
+
+    /// Maximum number of results
+    #[arg(long, short = 'n', default_value = "20")]
+    limit: usize,
+
+    /// Number of results to skip
+    #[arg(long, default_value = "0")]
+    offset: usize,
+
+    #[command(flatten)]
+    output: OutputArgs,
+
+    #[command(flatten)]
+    policy: PolicyArgs,
+}
+
+#[derive(Args, Debug)]
+pub struct RiskArgs {
+    /// Only include functions under this path prefix
+    #[arg(long, short = 'p')]
+    package: Option<String>,
+
+    /// Only count commits more recent than this date (e.g., "1 year ago")
+    #[arg(long)]
+    since: Option<String>,
+
+    /// Age in days at which a commit counts half
+    #[arg(long, default_value = "90")]
+    half_life: f64,
+
+    /// Maximum number of results
+    #[arg(long, short = 'n', default_value = "20")]
+    limit: usize,
+
+    /// Number of results to skip
+    #[arg(long, default_value = "0")]
+    offset: usize,
+
+    #[command(flatten)]
+    output: OutputArgs,
+
+    #[command(flatten)]
+    policy: PolicyArgs,
+}
+
+/// Saved `metrics packages --output json` report used as a drift baseline
+#[derive(Debug, Deserialize)]
+struct PackageBaseline {
+    packages: Vec<PackageMetrics>,
+}
+
+/// Execute metrics commands
+pub async fn execute(cmd: MetricsCommand, global: GlobalOptions) -> Result<()> {
+    match cmd {
+        MetricsCommand::Functions(args) => execute_functions(args, global).await,
+        MetricsCommand::Packages(args) => execute_packages(args, global).await,
+        MetricsCommand::Hotspots(args) => execute_hotspots(args, global).await,
+        MetricsCommand::Risk(args) => execute_risk(args, global).await,
+    }
+}
+
+async fn execute_functions(args: FunctionsArgs, global: GlobalOptions) -> Result<()> {
+    let backend = create_backend(&global).await?;
+    let metric = ComplexityMetric::from(args.sort);
+
+    let mut metrics = backend
+        .with_full_graph(|graph| Ok(function_metrics(graph, metric)))
+        .await
+        .context("Failed to compute function metrics")?;
+
+    if let Some(ref prefix) = args.package {
+        metrics.retain(|m| m.package.starts_with(prefix.as_str()));
+    }
+
+    let config = load_config(&global, &resolve_workspace(&global).await?)?;
+    let thresholds = ComplexityThresholds {
+        max_cyclomatic: args.max_cyclomatic.or(config.analysis.max_cyclomatic),
+        max_cognitive: args.max_cognitive.or(config.analysis.max_cognitive),
+    };
+    let violations: Vec<&FunctionMetrics> = metrics
+        .iter()
+        .filter(|m| thresholds.exceeded_by(m))
+        .collect();
+    let packages = top_by_package(&metrics, args.top);
+    let check = args.policy.check(&[
+        (
+            "complexity",
+            metrics.iter().map(|m| m.cyclomatic).max().unwrap_or(0) as f64,
+        ),
+        (
+            "cognitive",
+            metrics.iter().map(|m| m.cognitive).max().unwrap_or(0) as f64,
+        ),
+    ]);
+
+    if args.output.is_json() {
+        let output = serde_json::json!({
+            "function_count": metrics.len(),
+            "packages": packages,
+            "violations": violations,
+        });
+        check.print_json("metrics functions", output)?;
+    } else {
+        if metrics.is_empty() && !global.quiet {
+            println!("No function metrics found. Re-index to compute complexity.");
+        }
+
+        for (package, functions) in &packages {
+            let name = if package.is_empty() {
+                "."
+            } else {
+                package.as_str()
+            };
+            println!("{}", name);
+            for m in functions {
+                println!(
+                    "  {:>4} cog {:>4} cyc  {} ({}:{})",
+                    m.cognitive, m.cyclomatic, m.name, m.file, m.line
+                );
+            }
+        }
+
+        if thresholds.is_set() && !violations.is_empty() {
+            println!("\nThreshold violations:");
+            for m in &violations {
+                println!(
+                    "  {} ({}:{}) cognitive={} cyclomatic={}",
+                    m.name, m.file, m.line, m.cognitive, m.cyclomatic
+                );
+            }
+        }
+    }
+
+    check.enforce()?;
+    if !violations.is_empty() {
+        anyhow::bail!(
+            "{} function(s) exceed complexity thresholds",
+            violations.len()
+        );
+    }
+
+    Ok(())
+}
+
+async fn execute_packages(args: PackagesArgs, global: GlobalOptions) -> Result<()> {
+    let backend = create_backend(&global).await?;
+
+    let mut packages = backend
+        .with_full_graph(|graph| Ok(package_metrics(graph)))
+        .await
+        .context("Failed to compute package metrics")?;
+
+    if let Some(ref prefix) = args.package {
+        packages.retain(|p| p.package.starts_with(prefix.as_str()));
+    }
+
+    match args.sort {
+        PackageSort::Name => {}
+        PackageSort::Instability => {
+            packages.sort_by(|a, b| b.instability.total_cmp(&a.instability))
+        }
+        PackageSort::Distance => packages.sort_by(|a, b| b.distance.total_cmp(&a.distance)),
+        PackageSort::Afferent => packages.sort_by(|a, b| b.afferent.cmp(&a.afferent)),
+        PackageSort::Efferent => packages.sort_by(|a, b| b.efferent.cmp(&a.efferent)),
+    }
+
+    let baseline: Option<BTreeMap<String, PackageMetrics>> = match args.baseline {
+        Some(ref path) => {
+            let content = std::fs::read_to_string(path)
+                .with_context(|| format!("Failed to read baseline: {}", path.display()))?;
+            let report: PackageBaseline = serde_json::from_str(&content)
+                .with_context(|| format!("Invalid baseline report: {}", path.display()))?;
+            Some(
+                report
+                    .packages
+                    .into_iter()
+                    .map(|p| (p.package.clone(), p))
+                    .collect(),
+            )
+        }
+        None => None,
+    };
+
+    let check = args.policy.check(&[
+        (
+            "distance",
+            packages.iter().map(|p| p.distance).fold(0.0, f64::max),
+        ),
+        (
+            "instability",
+            packages.iter().map(|p| p.instability).fold(0.0, f64::max),
+        ),
+    ]);
+
+    if args.output.is_json() {
+        let mut output = serde_json::json!({
+            "package_count": packages.len(),
+            "packages": packages,
+        });
+        if let Some(ref baseline) = baseline {
+            output["drift"] = serde_json::json!(package_drift(&packages, baseline));
+        }
+        check.print_json("metrics packages", output)?;
+        return check.enforce();
+    }
+
+    if packages.is_empty() {
+        if !global.quiet {
+            println!("No packages found.");
+        }
+        return check.enforce();
+    }
+
+    if !global.quiet {
+        println!(
+            "{:<40} {:>4} {:>4} {:>6} {:>6} {:>6}",
+            "PACKAGE", "Ca", "Ce", "I", "A", "D"
+        );
+    }
+    for p in &packages {
+        let name = if p.package.is_empty() {
+            "."
+        } else {
+            p.package.as_str()
+        };
+        let drift = baseline
+            .as_ref()
+            .map(|b| match b.get(&p.package) {
+                Some(old) => format!(
+                    "  (I {:+.2}, D {:+.2})",
+                    p.instability - old.instability,
+                    p.distance - old.distance
+                ),
+                None => "  (new)".to_string(),
+            })
+            .unwrap_or_default();
+        println!(
+            "{:<40} {:>4} {:>4} {:>6.2} {:>6.2} {:>6.2}{}",
+            name, p.afferent, p.efferent, p.instability, p.abstractness, p.distance, drift
+        );
+    }
+
+    if let Some(ref baseline) = baseline {
+        let removed: Vec<&str> = baseline
+            .keys()
+            .filter(|name| !packages.iter().any(|p| &p.package == *name))
+            .map(String::as_str)
+            .collect();
+        if !removed.is_empty() && !global.quiet {
+            println!("\nRemoved since baseline: {}", removed.join(", "));
+        }
+    }
+
+    check.enforce()
+}
+
+async fn execute_hotspots(args: HotspotsArgs, global: GlobalOptions) -> Result<()> {
+    let churn = if args.no_churn {
+        HashMap::new()
+    } else {
+        let workspace = resolve_workspace(&global).await?;
+        match git_churn(&workspace, args.since.as_deref()) {
+            Ok(churn) => churn,
+            Err(e) => {
+                if !global.quiet {
+                    print_warning(&format!(
+                        "churn unavailable ({}); ranking by connectivity",
+                        e
+                    ));
+                }
+                HashMap::new()
+            }
+        }
+    };
+
+    let backend = create_backend(&global).await?;
+    let mut hotspots = backend
+        .with_full_graph(|graph| Ok(find_hotspots(graph, &churn, &HotspotOptions::default())))
+        .await
+        .context("Failed to compute hotspots")?;
+
+    hotspots.retain(|h| match args.kind {
+        HotspotKind::All => true,
+        HotspotKind::Functions => h.node_type == NodeType::Callable,
+        HotspotKind::Types => h.node_type == NodeType::Container,
+    });
+    if let Some(ref prefix) = args.package {
+        hotspots.retain(|h| h.package.starts_with(prefix.as_str()));
+    }
+    if args.flagged {
+        hotspots.retain(|h| !h.flags.is_empty());
+    }
+
+    match args.sort {
+        HotspotSort::Score => {}
+        HotspotSort::FanIn => hotspots.sort_by(|a, b| b.fan_in.cmp(&a.fan_in)),
+        HotspotSort::FanOut => hotspots.sort_by(|a, b| b.fan_out.cmp(&a.fan_out)),
+        HotspotSort::Churn => hotspots.sort_by(|a, b| b.churn.cmp(&a.churn)),
+    }
+
+    let check = args.policy.check(&[(
+        "flagged",
+        hotspots.iter().filter(|h| !h.flags.is_empty()).count() as f64,
+    )]);
+
+    let total = hotspots.len();
+    let page: Vec<Hotspot> = hotspots
+        .into_iter()
+        .skip(args.offset)
+        .take(args.limit)
+        .collect();
+
+    if args.output.is_json() {
+        let output = serde_json::json!({
+            "total": total,
+            "offset": args.offset,
+            "limit": args.limit,
+            "hotspots": page,
+        });
+        check.print_json("metrics hotspots", output)?;
+        return check.enforce();
+    }
+
+    if page.is_empty() {
+        if !global.quiet {
+            println!("No hotspots found.");
+        }
+        return check.enforce();
+    }
+
+    if !global.quiet {
+        println!(
+            "{:>8} {:>6} {:>7} {:>6}  SYMBOL",
+            "SCORE", "FAN-IN", "FAN-OUT", "CHURN"
+        );
+    }
+    for h in &page {
+        let flags: Vec<&str> = h.flags.iter().map(|f| f.as_str()).collect();
+        let flags = if flags.is_empty() {
+            String::new()
+        } else {
+            format!("  [{}]", flags.join(", "))
+        };
+        println!(
+            "{:>8.1} {:>6} {:>7} {:>6}  {} ({}:{}){}",
+            h.score, h.fan_in, h.fan_out, h.churn, h.name, h.file, h.line, flags
+        );
+    }
+
+    if !global.quiet && total > args.offset + page.len() {
+        println!(
+            "\nShowing {}-{} of {}; use --offset {} for more",
+            args.offset + 1,
+            args.offset + page.len(),
+            total,
+            args.offset + page.len()
+        );
+    }
+
+    check.enforce()
+}
+
+async fn execute_risk(args: RiskArgs, global: GlobalOptions) -> Result<()> {
+    if args.half_life <= 0.0 {
+        anyhow::bail!("--half-life must be positive");
+    }
+    let workspace = resolve_workspace(&global).await?;
+    let history = GitHistory::load(&workspace, args.since.as_deref())
+        .context("Failed to read git history")?;
+    let options = RiskOptions {
+        now: unix_now(),
+        half_life_days: args.half_life,
+    };
+
+    let backend = create_backend(&global).await?;
+    let mut report = backend
+        .with_full_graph(|graph| {
+            let churn = history.symbol_churn(graph);
+            Ok(risk_report(graph, &churn, &options))
+        })
+        .await
+        .context("Failed to compute risk")?;
+
+    if let Some(ref prefix) = args.package {
+        report.retain(|r| r.package.starts_with(prefix.as_str()));
+    }
+
+    let check = args
+        .policy
+        .check(&[("risk", report.first().map_or(0.0, |r| r.score))]);
+
+    let total = report.len();
+    let page: Vec<SymbolRisk> = report
+        .into_iter()
+        .skip(args.offset)
+        .take(args.limit)
+        .collect();
+
+    if args.output.is_json() {
+        let output = serde_json::json!({
+            "total": total,
+            "offset": args.offset,
+            "limit": args.limit,
+            "commits": history.commits.len(),
+            "half_life_days": args.half_life,
+            "functions": page,
+        });
+        check.print_json("metrics risk", output)?;
+        return check.enforce();
+    }
+
+    if page.is_empty() {
+        if !global.quiet {
+            println!("No changed functions found in the git history.");
+        }
+        return check.enforce();
+    }
+
+    if !global.quiet {
+        println!(
+            "{:>8} {:>7} {:>7} {:>6} {:>4} {:>6}  FUNCTION",
+            "RISK", "COMMITS", "AUTHORS", "LAST", "COG", "FAN-IN"
+        );
+    }
+    for r in &page {
+        println!(
+            "{:>8.1} {:>7} {:>7} {:>5}d {:>4} {:>6}  {} ({}:{})",
+            r.score,
+            r.commits,
+            r.authors,
+            r.days_since_change,
+            r.complexity,
+            r.fan_in,
+            r.name,
+            r.file,
+            r.line
+        );
+    }
+
+    if !global.quiet && total > args.offset + page.len() {
+        println!(
+            "\nShowing {}-{} of {}; use --offset {} for more",
+            args.offset + 1,
+            args.offset + page.len(),
+            total,
+            args.offset + page.len()
+        );
+    }
+
+    check.enforce()
+}
+
+/// Current time as Unix seconds
+pub(crate) fn unix_now() -> i64 {
+    SystemTime::now()
+        .duration_since(UNIX_EPOCH)
+        .map_or(0, |d| d.as_secs() as i64)
+}
+
+/// Count commits touching each file, with paths relative to `workspace`
+fn git_churn(workspace: &Path, since: Option<&str>) -> Result<HashMap<String, u32>> {
+    let mut command = std::process::Command::new("git");
+    command
+        .args([
+            "log",
+            "--format=",
+            "--name-only",
+            "--relative",
+            "--no-renames",
+        ])
+        .current_dir(workspace);
+    if let Some(since) = since {
+        command.arg(format!("--since={}", since));
+    }
+
+    let output = command.output().context("Failed to run git log")?;
+    if !output.status.success() {
+        anyhow::bail!(
+            "git log failed: {}",
+            String::from_utf8_lossy(&output.stderr).trim()
+        );
+    }
+
+    let mut churn: HashMap<String, u32> = HashMap::new();
+    for file in String::from_utf8_lossy(&output.stdout)
+        .lines()
+        .filter(|l| !l.is_empty())
+    {
+        *churn.entry(file.to_string()).or_default() += 1;
+    }
+    Ok(churn)
+}
+
+/// Per-package changes relative to a baseline report
+fn package_drift(
+    packages: &[PackageMetrics],
+    baseline: &BTreeMap<String, PackageMetrics>,
+) -> Vec<serde_json::Value> {
+    let mut drift: Vec<serde_json::Value> = packages
+        .iter()
+        .map(|p| match baseline.get(&p.package) {
+            Some(old) => serde_json::json!({
+                "package": p.package,
+                "status": "changed",
+                "afferent_delta": p.afferent as i64 - old.afferent as i64,
+                "efferent_delta": p.efferent as i64 - old.efferent as i64,
+                "instability_delta": p.instability - old.instability,
+                "abstractness_delta": p.abstractness - old.abstractness,
+                "distance_delta": p.distance - old.distance,
+            }),
+            None => serde_json::json!({ "package": p.package, "status": "added" }),
+        })
+        .collect();
+
+    drift.extend(
+        baseline
+            .keys()
+            .filter(|name| !packages.iter().any(|p| &p.package == *name))
+            .map(|name| serde_json::json!({ "package": name, "status": "removed" })),
+    );
+    drift
+}
@@ -0,0 +1,96 @@
+//! Migrate command - Upgrade an index written by an older version
+//!
+//! Brings every store of the index (manifest, partition databases, cross-ref
+//! database, and the memory-mapped graph store) to the schema versions this
+//! version writes, in place and without re-parsing the workspace.
+
+use anyhow::{Context, Result};
+use clap::Args;
+use codeprysm_config::GraphFormat;
+use codeprysm_core::lazy::{migrate_index, MigrationAction, StoreMigration};
+
+use super::{load_config, resolve_workspace};
+use crate::GlobalOptions;
+
+/// Arguments for the migrate command
+#[derive(Args, Debug)]
+pub struct MigrateArgs {
+    /// Show what would be migrated without changing the index
+    #[arg(long, short = 'n')]
+    dry_run: bool,
+
+    /// Output as JSON
+    #[arg(long)]
+    json: bool,
+}
+
+/// Execute the migrate command
+pub async fn execute(args: MigrateArgs, global: GlobalOptions) -> Result<()> {
+    let workspace_path = resolve_workspace(&global).await?;
+    let config = load_config(&global, &workspace_path)?;
+    let prism_dir = config.prism_dir(&workspace_path);
+    if !prism_dir.join("manifest.json").exists() {
+        anyhow::bail!(
+            "Workspace not initialized. Run 'codeprysm init' first.\n  Path: {}",
+            workspace_path.display()
+        );
+    }
+
+    let graph_store = (config.storage.graph_format == GraphFormat::Mmap)
+        .then(|| config.graph_path(&workspace_path));
+    let migrations = migrate_index(&prism_dir, graph_store.as_deref(), args.dry_run)
+        .context("Failed to migrate the index")?;
+
+    if args.json {
+        let output = serde_json::json!({
+            "dry_run": args.dry_run,
+            "stores": migrations,
+        });
+        println!("{}", serde_json::to_string_pretty(&output)?);
+        return Ok(());
+    }
+    if global.quiet {
+        return Ok(());
+    }
+
+    let changed: Vec<&StoreMigration> = migrations.iter().filter(|m| m.changes()).collect();
+    if changed.is_empty() {
+        println!(
+            "Index is up to date ({} store(s) checked)",
+            migrations.len()
+        );
+        return Ok(());
+    }
+
+    for migration in &changed {
+        let relative = migration
+            .path
+            .strip_prefix(&prism_dir)
+            .unwrap_or(&migration.path);
+        let action = match (migration.action, args.dry_run) {
+            (MigrationAction::Rebuilt, true) => "would rebuild",
+            (MigrationAction::Rebuilt, false) => "rebuilt",
+            (_, true) => "would migrate",
+            (_, false) => "migrated",
+        };
+        println!(
+            "  {} {}: v{} -> v{}",
+            action,
+            relative.display(),
+            migration.from,
+            migration.to
+        );
+    }
+    println!(
+        "\n{} {} of {} store(s)",
+        if args.dry_run {
+            "Would migrate"
+        } else {
+            "✓ Migrated"
+        },
+        changed.len(),
+        migrations.len()
+    );
+
+    Ok(())
+}
@@ -2,29 +2,78 @@
 //!
 //! This module contains all Prism CLI command implementations.
 
+pub mod aliases;
+pub mod analyze;
+pub mod api;
+pub mod apidiff;
+pub mod archive;
 pub mod backend;
+pub mod bench;
+pub mod changelog;
+pub mod check;
 pub mod clean;
 pub mod components;
 pub mod config;
+pub mod daemon;
+pub mod debug;
+pub mod diagnostics;
+pub mod diff;
 pub mod doctor;
+pub mod federate;
 pub mod graph;
+pub mod history;
 pub mod init;
+pub mod lsp;
 pub mod mcp;
+pub mod merge;
+pub mod metrics;
+pub mod migrate;
+pub mod plugin;
+pub mod projects;
+pub mod query;
+pub mod review;
 pub mod search;
+pub mod select_tests;
+pub mod serve;
+pub mod shard;
+pub mod stats;
 pub mod status;
+pub mod subgraph;
+pub mod tags;
+pub mod todos;
+pub mod ui;
 pub mod update;
+pub mod validate;
+pub mod watch;
 pub mod workspace;
 
+use std::collections::HashMap;
 use std::path::{Path, PathBuf};
 use std::sync::Arc;
 
 use anyhow::{Context, Result};
+use clap::Args;
+#[cfg(unix)]
+use codeprysm_backend::{socket_path, DaemonBackend};
 use codeprysm_backend::{LocalBackend, WorkspaceRegistry};
-use codeprysm_config::{ConfigLoader, PrismConfig};
+use codeprysm_config::{
+    AnalysisConfig, ConfigLoader, GeneratedCodePolicy, GraphFormat, PrismConfig, TokenScope,
+};
+use codeprysm_core::analysis::EdgeSelector;
+use codeprysm_core::builder::GraphBuilder;
+use codeprysm_core::lazy::{LazyGraphManager, TextIndex, WriteLock};
+use codeprysm_core::source_links::origin_remote;
+use codeprysm_core::{
+    attach_source_links, detect_aliases, AliasOptions, AliasTable, AttributeRule, Diagnostics,
+    EdgeType, ExportBudget, ExportFilter, ExtractionRules, FilePolicy, GoTypeCheck, LanguageRules,
+    LinkTemplate, MmapGraph, PetCodeGraph, Provenance, SampledGraph, Severity, SymbolLimits,
+};
 use codeprysm_search::embeddings::{
     AzureMLAuth, AzureMLConfig, EmbeddingConfig as SearchEmbeddingConfig, OpenAIConfig,
 };
+use codeprysm_server::{ApiToken, Auth, GraphStore, QueryCache, Scope, TlsConfig};
 
+use crate::telemetry::json_logs;
 use crate::GlobalOptions;
 
 /// Resolve the workspace path from options or current directory.
@@ -77,6 +126,393 @@ pub fn load_config(global: &GlobalOptions, workspace: &Path) -> Result<PrismConf
         .context("Failed to load configuration")
 }
 
+/// File selection flags of the commands that index files
+#[derive(Args, Debug, Clone, Default)]
+pub struct FileFilterArgs {
+    /// Only index files matching this glob (repeatable; replaces
+    /// `analysis.include_patterns`)
+    #[arg(long = "include", value_name = "GLOB")]
+    pub include: Vec<String>,
+
+    /// Skip files matching this glob (repeatable; added to
+    /// `analysis.exclude_patterns`)
+    #[arg(long = "exclude", value_name = "GLOB")]
+    pub exclude: Vec<String>,
+}
+
+impl FileFilterArgs {
+    /// Apply the flags over the configured patterns.
+    pub fn apply(&self, analysis: &mut AnalysisConfig) {
+        if !self.include.is_empty() {
+            analysis.include_patterns = self.include.clone();
+        }
+        for pattern in &self.exclude {
+            if !analysis.exclude_patterns.contains(pattern) {
+                analysis.exclude_patterns.push(pattern.clone());
+            }
+        }
+    }
+}
+
+/// Node filters of the commands that export graphs
+#[derive(Args, Debug, Clone, Default)]
+pub struct ExportFilterArgs {
+    /// Keep only exported symbols (and the files and packages holding them)
+    #[arg(long)]
+    pub only_exported: bool,
+
+    /// Drop test files and test code
+    #[arg(long)]
+    pub exclude_tests: bool,
+
+    /// Drop generated files and their symbols
+    #[arg(long)]
+    pub exclude_generated: bool,
+
+    /// Drop symbols nested more than N levels below their file (1 keeps
+    /// top-level declarations only)
+    #[arg(long, value_name = "N")]
+    pub max_node_kind_depth: Option<usize>,
+}
+
+impl ExportFilterArgs {
+    /// Node filter of the flags
+    pub fn filter(&self) -> ExportFilter {
+        ExportFilter {
+            only_exported: self.only_exported,
+            exclude_tests: self.exclude_tests,
+            exclude_generated: self.exclude_generated,
+            max_depth: self.max_node_kind_depth,
+        }
+    }
+}
+
+/// Size budget flags of the commands that export graphs
+#[derive(Args, Debug, Clone, Default)]
+pub struct ExportBudgetArgs {
+    /// Keep at most N nodes: the most connected, with the rest collapsed into
+    /// aggregate nodes
+    #[arg(long, value_name = "N")]
+    pub max_nodes: Option<usize>,
+
+    /// Keep at most N edges, preferring those between the most connected nodes
+    #[arg(long, value_name = "N")]
+    pub max_edges: Option<usize>,
+}
+
+impl ExportBudgetArgs {
+    /// Size budget of the flags
+    pub fn budget(&self) -> ExportBudget {
+        ExportBudget {
+            max_nodes: self.max_nodes,
+            max_edges: self.max_edges,
+        }
+    }
+}
+
+/// Print what an export budget dropped, unless quiet
+pub fn report_sampling(sampled: &SampledGraph, global: &GlobalOptions) {
+    if sampled.is_sampled() {
+        print_info(
+            &format!(
+                "Sampled to fit the budget: collapsed {} node(s) into {} aggregate(s), omitted {} node(s) and {} edge(s)",
+                sampled.collapsed, sampled.aggregates, sampled.omitted, sampled.dropped_edges
+            ),
+            global.quiet,
+        );
+    }
+}
+
+/// Build the file policy of the configured per-language settings.
+pub fn file_policy(analysis: &AnalysisConfig) -> FilePolicy {
+    let skip = |policy: GeneratedCodePolicy| policy == GeneratedCodePolicy::Skip;
+    FilePolicy {
+        skip_generated: skip(analysis.generated),
+        languages: analysis
+            .languages
+            .iter()
+            .map(|(name, language)| {
+                let rules = LanguageRules {
+                    enabled: language.enabled,
+                    include_patterns: language.include_patterns.clone(),
+                    exclude_patterns: language.exclude_patterns.clone(),
+                    skip_generated: language.generated.map(skip),
+                    target: language.target.clone(),
+                };
+                (name.clone(), rules)
+            })
+            .collect(),
+    }
+}
+
+/// Build the per-language extraction rules of the configuration, with query
+/// files resolved against the workspace root.
+pub fn extraction_rules(
+    analysis: &AnalysisConfig,
+    workspace: &Path,
+) -> Result<HashMap<String, ExtractionRules>> {
+    let mut rules = HashMap::new();
+    for (name, language) in &analysis.languages {
+        let mut disabled_edges = Vec::new();
+        for edge in &language.disabled_edges {
+            let edge_type = parse_edge_type(edge).map_err(|e| {
+                anyhow::anyhow!("Invalid disabled edge for language '{}': {}", name, e)
+            })?;
+            if edge_type == EdgeType::Contains {
+                anyhow::bail!("CONTAINS edges cannot be disabled (language '{}')", name);
+            }
+            disabled_edges.push(edge_type);
+        }
+        let query_files = language
+            .query_file
+            .iter()
+            .chain(&language.query_files)
+            .map(|file| workspace.join(file))
+            .collect();
+        let language_rules = ExtractionRules {
+            disabled_edges,
+            query_files,
+        };
+        if !language_rules.is_empty() {
+            rules.insert(name.clone(), language_rules);
+        }
+    }
+    Ok(rules)
+}
+
+/// Build the attribute enrichment rules of the configuration.
+pub fn attribute_rules(config: &PrismConfig) -> Vec<AttributeRule> {
+    config
+        .attributes
+        .iter()
+        .map(|rule| AttributeRule {
+            paths: rule.paths.clone(),
+            names: rule.names.clone(),
+            attributes: rule.attributes.clone(),
+        })
+        .collect()
+}
+
+/// Build the Go type checker run if `go_type_check` is configured.
+pub fn go_type_check(analysis: &AnalysisConfig) -> Option<GoTypeCheck> {
+    if !analysis.go_type_check {
+        return None;
+    }
+    Some(match &analysis.go_type_checker {
+        Some(command) => GoTypeCheck {
+            command: command.clone(),
+        },
+        None => GoTypeCheck::default(),
+    })
+}
+
+/// Build the symbol limits of the configuration.
+pub fn symbol_limits(analysis: &AnalysisConfig) -> SymbolLimits {
+    SymbolLimits {
+        max_per_package: analysis.max_symbols_per_package,
+        max_total: analysis.max_symbols,
+    }
+}
+
+/// Write the memory-mapped graph store if the `mmap` graph format is
+/// configured.
+pub fn write_graph_store(
+    graph: &PetCodeGraph,
+    config: &PrismConfig,
+    workspace: &Path,
+) -> Result<()> {
+    if config.storage.graph_format != GraphFormat::Mmap {
+        return Ok(());
+    }
+    let path = config.graph_path(workspace);
+    MmapGraph::write(graph, &path)
+        .with_context(|| format!("Failed to write graph store {}", path.display()))
+}
+
+/// Record the provenance of a build of the workspace in its index directory.
+pub fn save_provenance(config: &PrismConfig, workspace: &Path) -> Result<()> {
+    Provenance::collect(workspace)
+        .with_config(&config.analysis)
+        .save(&config.prism_dir(workspace))
+        .context("Failed to save provenance")
+}
+
+/// Save the diagnostics of a build into the index directory, pointing at
+/// `codeprysm diagnostics` when parts of the workspace are missing from the
+/// graph.
+pub fn save_diagnostics(builder: &GraphBuilder, prism_dir: &Path, quiet: bool) -> Result<()> {
+    let diagnostics = Diagnostics::new(builder.diagnostics().to_vec());
+    diagnostics
+        .save(prism_dir)
+        .context("Failed to save diagnostics")?;
+
+    let (errors, warnings) = (
+        diagnostics.count(Severity::Error),
+        diagnostics.count(Severity::Warning),
+    );
+    if !quiet && errors + warnings > 0 && json_logs() {
+        tracing::warn!(
+            errors,
+            warnings,
+            "The graph is incomplete; run 'codeprysm diagnostics' for details"
+        );
+    } else if !quiet && errors + warnings > 0 {
+        eprintln!(
+            "  Warning: {} file(s) not indexed, {} warning(s) (syntax errors, truncated symbols); the graph is incomplete there. Run 'codeprysm diagnostics' for details.",
+            errors, warnings
+        );
+    }
+    Ok(())
+}
+
+/// Bring the workspace's full-text index of docs, comments, and strings in
+/// line with a freshly built graph; only changed files are scanned again.
+pub fn sync_text_index(
+    graph: &PetCodeGraph,
+    prism_dir: &Path,
+    workspace: &Path,
+    quiet: bool,
+) -> Result<()> {
+    let stats = TextIndex::open(prism_dir)
+        .and_then(|mut index| index.sync(workspace, graph))
+        .context("Failed to update the full-text index")?;
+    if stats.files_indexed + stats.files_removed > 0 {
+        print_info(
+            &format!(
+                "  Full-text index: {} entries from {} changed file(s)",
+                stats.entries, stats.files_indexed
+            ),
+            quiet,
+        );
+    }
+    Ok(())
+}
+
+/// Record the symbols renamed or moved since the build still in the index
+/// in its alias table, and attach every symbol's earlier IDs to the new
+/// graph so lookups by an old symbol ID still find it.
+///
+/// The caller holds the index's write lock until the new graph is saved, so
+/// no other writer changes the index in between.
+pub fn update_aliases(
+    graph: &mut PetCodeGraph,
+    prism_dir: &Path,
+    _lock: &WriteLock,
+    quiet: bool,
+) -> Result<()> {
+    let previous = LazyGraphManager::open(prism_dir)
+        .and_then(|manager| manager.load_full_graph())
+        .ok();
+
+    let mut table = AliasTable::load(prism_dir);
+    if let Some(previous) = previous {
+        let aliases = detect_aliases(&previous, graph, &AliasOptions::default());
+        let recorded = table.record(aliases, metrics::unix_now());
+        if recorded > 0 {
+            table
+                .save(prism_dir)
+                .context("Failed to save symbol aliases")?;
+            print_info(
+                &format!("  Recorded {} renamed or moved symbol(s)", recorded),
+                quiet,
+            );
+        }
+    }
+    table.attach(graph);
+    Ok(())
+}
+
+/// Load the provenance of the workspace's index, if it was recorded.
+pub fn load_provenance(config: &PrismConfig, workspace: &Path) -> Option<Provenance> {
+    Provenance::load(&config.prism_dir(workspace))
+}
+
+/// Build the link template of the `[links]` configuration (None if unset),
+/// with the workspace's `origin` remote unless a remote is configured.
+pub fn link_template(config: &PrismConfig, workspace: &Path) -> Result<Option<LinkTemplate>> {
+    let Some(ref template) = config.links.template else {
+        return Ok(None);
+    };
+    let remote = config
+        .links
+        .remote
+        .clone()
+        .or_else(|| origin_remote(workspace));
+    LinkTemplate::parse(template, remote.as_deref())
+        .map(Some)
+        .context("Invalid [links] configuration")
+}
+
+/// Link the nodes of a graph about to be exported to their source at the
+/// indexed commit, if `[links]` is configured.
+pub fn attach_links(
+    graph: &mut PetCodeGraph,
+    config: &PrismConfig,
+    workspace: &Path,
+    provenance: Option<&Provenance>,
+) -> Result<()> {
+    let Some(links) = link_template(config, workspace)? else {
+        return Ok(());
+    };
+    match provenance.and_then(|p| p.commit.as_deref()) {
+        Some(commit) => {
+            attach_source_links(graph, &links, commit);
+        }
+        None => print_warning("No indexed commit recorded; exporting without source links"),
+    }
+    Ok(())
+}
+
+/// Open the workspace's index for the query servers, behind the tokens and
+/// with the query cache size of the `[server]` configuration.
+pub fn open_graph_store(config: &PrismConfig, workspace: &Path) -> Result<GraphStore> {
+    let tokens = config
+        .server
+        .tokens
+        .iter()
+        .map(|token| {
+            let secret = token.secret().with_context(|| match token.token_env {
+                Some(ref var) => format!("Token '{}': {} is not set", token.name, var),
+                None => format!("Token '{}' has no token or token_env", token.name),
+            })?;
+            Ok(ApiToken {
+                name: token.name.clone(),
+                secret,
+                scope: match token.scope {
+                    TokenScope::Read => Scope::Read,
+                    TokenScope::Admin => Scope::Admin,
+                },
+                paths: token.paths.clone(),
+            })
+        })
+        .collect::<Result<Vec<_>>>()?;
+    let auth = Auth::new(tokens).context("Invalid server tokens")?;
+    let mut store = GraphStore::open(config.prism_dir(workspace))?.with_auth(auth);
+    if let Some(entries) = config.server.cache_entries {
+        store = store.with_cache(QueryCache::new(entries));
+    }
+    if let Some(links) = link_template(config, workspace)? {
+        store = store.with_source_links(links);
+    }
+    Ok(store)
+}
+
+/// Load the TLS certificates of the `[server.tls]` configuration, with
+/// relative paths resolved against the workspace.
+pub fn server_tls(config: &PrismConfig, workspace: &Path) -> Result<Option<TlsConfig>> {
+    let Some(ref tls) = config.server.tls else {
+        return Ok(None);
+    };
+    let client_ca = tls.client_ca.as_ref().map(|ca| workspace.join(ca));
+    TlsConfig::from_pem_files(
+        &workspace.join(&tls.cert),
+        &workspace.join(&tls.key),
+        client_ca.as_deref(),
+    )
+    .map(Some)
+    .context("Failed to load TLS certificates")
+}
+
 /// Create a backend for the resolved workspace.
 pub async fn create_backend(global: &GlobalOptions) -> Result<Arc<LocalBackend>> {
     let workspace = resolve_workspace(global).await?;
@@ -93,6 +529,28 @@ pub async fn create_backend(global: &GlobalOptions) -> Result<Arc<LocalBackend>>
     Ok(Arc::new(backend))
 }
 
+/// Connect to the workspace's daemon, if one is running.
+#[cfg(unix)]
+pub async fn connect_daemon(global: &GlobalOptions) -> Result<Option<DaemonBackend>> {
+    let workspace = resolve_workspace(global).await?;
+    let config = load_config(global, &workspace)?;
+    let socket = socket_path(&config.prism_dir(&workspace));
+    if !socket.exists() {
+        return Ok(None);
+    }
+    Ok(DaemonBackend::connect(socket).await.ok())
+}
+
+/// Parse an edge type argument (e.g., "uses", "CONTAINS", "depends_on").
+pub fn parse_edge_type(s: &str) -> Result<EdgeType, String> {
+    s.parse()
+}
+
+/// Parse an edge type argument that may also be "calls" or "implements".
+pub fn parse_edge_selector(s: &str) -> Result<EdgeSelector, String> {
+    s.parse()
+}
+
 /// Print a result in a consistent format.
 #[allow(dead_code)]
 pub fn print_result<T: std::fmt::Display>(result: T, quiet: bool) {
@@ -104,18 +562,31 @@ pub fn print_result<T: std::fmt::Display>(result: T, quiet: bool) {
 /// Print an error message to stderr.
 #[allow(dead_code)]
 pub fn print_error(message: &str) {
-    eprintln!("error: {}", message);
+    if json_logs() {
+        tracing::error!("{}", message);
+    } else {
+        eprintln!("error: {}", message);
+    }
 }
 
 /// Print a warning message to stderr.
 #[allow(dead_code)]
 pub fn print_warning(message: &str) {
-    eprintln!("warning: {}", message);
+    if json_logs() {
+        tracing::warn!("{}", message);
+    } else {
+        eprintln!("warning: {}", message);
+    }
 }
 
 /// Print an info message (respects quiet flag).
 pub fn print_info(message: &str, quiet: bool) {
-    if !quiet {
+    if quiet {
+        return;
+    }
+    if json_logs() {
+        tracing::info!("{}", message.trim());
+    } else {
         eprintln!("{}", message);
     }
 }
@@ -0,0 +1,204 @@
+//! Plugin command - List plugins and run their analyses
+//!
+//! Plugins are external programs configured under `[[plugins]]` that speak
+//! JSON-RPC over stdio (see `codeprysm_core::plugin`). Extractor plugins run
+//! during `init` and `update`; analysis plugins run with `plugin run`.
+
+use std::path::Path;
+
+use anyhow::{bail, Context, Result};
+use clap::{Args, Subcommand};
+use codeprysm_backend::BackendError;
+use codeprysm_config::PrismConfig;
+use codeprysm_core::{apply_extractors, Finding, PetCodeGraph, Plugin};
+use serde_json::{Map, Value};
+
+use super::{create_backend, load_config, print_info, print_warning, resolve_workspace};
+use crate::GlobalOptions;
+
+/// Plugin subcommands
+#[derive(Subcommand, Debug)]
+pub enum PluginCommand {
+    /// List configured plugins and what they provide
+    List,
+
+    /// Run an analysis provided by a plugin
+    Run(RunArgs),
+}
+
+#[derive(Args, Debug)]
+pub struct RunArgs {
+    /// Configured plugin name
+    plugin: String,
+
+    /// Analysis to run (see `plugin list`)
+    analysis: String,
+
+    /// Argument passed to the analysis (repeatable)
+    #[arg(long = "arg", value_name = "KEY=VALUE", value_parser = parse_arg)]
+    args: Vec<(String, String)>,
+
+    /// Output as JSON
+    #[arg(long)]
+    json: bool,
+}
+
+fn parse_arg(s: &str) -> Result<(String, String), String> {
+    s.split_once('=')
+        .map(|(key, value)| (key.to_string(), value.to_string()))
+        .ok_or_else(|| format!("expected KEY=VALUE, got '{}'", s))
+}
+
+/// Execute plugin commands
+pub async fn execute(cmd: PluginCommand, global: GlobalOptions) -> Result<()> {
+    match cmd {
+        PluginCommand::List => execute_list(global).await,
+        PluginCommand::Run(args) => execute_run(args, global).await,
+    }
+}
+
+async fn execute_list(global: GlobalOptions) -> Result<()> {
+    let workspace_path = resolve_workspace(&global).await?;
+    let config = load_config(&global, &workspace_path)?;
+    if config.plugins.is_empty() {
+        print_info("No plugins configured", global.quiet);
+        return Ok(());
+    }
+
+    for spec in &config.plugins {
+        println!("{} ({})", spec.name, spec.command.join(" "));
+        let plugin = match Plugin::spawn(&spec.name, &spec.command, &workspace_path) {
+            Ok(plugin) => plugin,
+            Err(e) => {
+                println!("  error: {}", e);
+                continue;
+            }
+        };
+        let capabilities = plugin.capabilities();
+        if let Some(ref extractor) = capabilities.extractor {
+            println!("  extractor: {}", extractor.files.join(", "));
+        }
+        for analysis in &capabilities.analyses {
+            println!("  analysis:  {} - {}", analysis.name, analysis.description);
+        }
+    }
+    Ok(())
+}
+
+async fn execute_run(args: RunArgs, global: GlobalOptions) -> Result<()> {
+    let workspace_path = resolve_workspace(&global).await?;
+    let config = load_config(&global, &workspace_path)?;
+    let spec = config
+        .plugins
+        .iter()
+        .find(|p| p.name == args.plugin)
+        .with_context(|| format!("No plugin named '{}' is configured", args.plugin))?;
+
+    let mut plugin = Plugin::spawn(&spec.name, &spec.command, &workspace_path)?;
+    if !plugin
+        .capabilities()
+        .analyses
+        .iter()
+        .any(|a| a.name == args.analysis)
+    {
+        bail!(
+            "Plugin '{}' has no analysis '{}'. Run 'codeprysm plugin list' to see its analyses.",
+            args.plugin,
+            args.analysis
+        );
+    }
+
+    let params: Map<String, Value> = args
+        .args
+        .into_iter()
+        .map(|(key, value)| (key, Value::String(value)))
+        .collect();
+    let backend = create_backend(&global).await?;
+    let findings = backend
+        .with_full_graph(|graph| {
+            plugin
+                .analyze(&args.analysis, graph, Value::Object(params))
+                .map_err(|e| BackendError::with_context("plugin", e.to_string()))
+        })
+        .await?;
+
+    if args.json {
+        println!("{}", serde_json::to_string_pretty(&findings)?);
+    } else {
+        print_findings(&findings, &global);
+    }
+
+    let errors = findings
+        .iter()
+        .filter(|f| f.severity.as_deref() == Some("error"))
+        .count();
+    if errors > 0 {
+        bail!("{} error finding(s)", errors);
+    }
+    Ok(())
+}
+
+fn print_findings(findings: &[Finding], global: &GlobalOptions) {
+    if findings.is_empty() {
+        print_info("No findings", global.quiet);
+        return;
+    }
+    for finding in findings {
+        let location = match (&finding.file, finding.line) {
+            (Some(file), Some(line)) => format!("{}:{}", file, line),
+            (Some(file), None) => file.clone(),
+            _ => finding.node.clone().unwrap_or_default(),
+        };
+        let severity = finding.severity.as_deref().unwrap_or("info");
+        if location.is_empty() {
+            println!("[{}] {}", severity, finding.message);
+        } else {
+            println!("[{}] {}: {}", severity, location, finding.message);
+        }
+    }
+    if !global.quiet {
+        println!("\n{} finding(s)", findings.len());
+    }
+}
+
+/// Run the configured extractor plugins over the workspace and add their
+/// nodes and edges to `graph`.
+///
+/// Plugins that fail to start are skipped with a warning.
+pub fn run_extractors(
+    graph: &mut PetCodeGraph,
+    workspace: &Path,
+    config: &PrismConfig,
+    quiet: bool,
+) {
+    let mut plugins: Vec<Plugin> = config
+        .plugins
+        .iter()
+        .filter_map(
+            |spec| match Plugin::spawn(&spec.name, &spec.command, workspace) {
+                Ok(plugin) => Some(plugin),
+                Err(e) => {
+                    print_warning(&e.to_string());
+                    None
+                }
+            },
+        )
+        .collect();
+    if plugins.iter().all(|p| p.capabilities().extractor.is_none()) {
+        return;
+    }
+
+    let stats = apply_extractors(graph, workspace, &mut plugins);
+    if !quiet {
+        println!(
+            "  Plugins added {} node(s) and {} edge(s) from {} file(s)",
+            stats.nodes, stats.edges, stats.files
+        );
+    }
+    if stats.errors > 0 {
+        print_warning(&format!(
+            "Plugins failed on {} file(s); see the log above",
+            stats.errors
+        ));
+    }
+}
@@ -0,0 +1,198 @@
+//! Projects command - Monorepo projects detected at index time
+//!
+//! Indexing tags every node with the project it belongs to (the nearest
+//! `go.mod`, `Cargo.toml`, or `package.json` above its file), in the
+//! `project` attribute. These commands list the projects, show the references
+//! between them, and export one project's graph. To filter other commands by
+//! project, match the attribute (`graph find --attr project=NAME`).
+
+use std::path::PathBuf;
+
+use anyhow::{Context, Result};
+use clap::{Args, Subcommand};
+use codeprysm_core::analysis::{project_dependencies, project_subgraph, project_summaries};
+use codeprysm_core::{export_graph, ExportFormat};
+
+use super::subgraph::parse_export_format;
+use super::{
+    attach_links, create_backend, load_config, load_provenance, report_sampling, resolve_workspace,
+    ExportBudgetArgs, ExportFilterArgs,
+};
+use crate::GlobalOptions;
+
+/// Project commands
+#[derive(Subcommand, Debug)]
+pub enum ProjectsCommand {
+    /// List the projects in the index
+    List(ListArgs),
+
+    /// Show references between projects
+    Deps(DepsArgs),
+
+    /// Export the graph of one project
+    Export(ExportArgs),
+}
+
+#[derive(Args, Debug)]
+pub struct ListArgs {
+    /// Output as JSON
+    #[arg(long)]
+    json: bool,
+}
+
+#[derive(Args, Debug)]
+pub struct DepsArgs {
+    /// Only show dependencies of this project
+    name: Option<String>,
+
+    /// Show the projects depending on NAME instead
+    #[arg(long, short = 'r', requires = "name")]
+    reverse: bool,
+
+    /// Output as JSON
+    #[arg(long)]
+    json: bool,
+}
+
+#[derive(Args, Debug)]
+pub struct ExportArgs {
+    /// Project name
+    name: String,
+
+    /// Output format: json, dot, mermaid, or graphml
+    #[arg(long, short = 'f', default_value = "json", value_parser = parse_export_format)]
+    format: ExportFormat,
+
+    /// Write to a file instead of stdout
+    #[arg(long, short = 'o')]
+    output: Option<PathBuf>,
+
+    #[command(flatten)]
+    filter: ExportFilterArgs,
+
+    #[command(flatten)]
+    budget: ExportBudgetArgs,
+}
+
+/// Execute project commands
+pub async fn execute(cmd: ProjectsCommand, global: GlobalOptions) -> Result<()> {
+    match cmd {
+        ProjectsCommand::List(args) => execute_list(args, global).await,
+        ProjectsCommand::Deps(args) => execute_deps(args, global).await,
+        ProjectsCommand::Export(args) => execute_export(args, global).await,
+    }
+}
+
+async fn execute_list(args: ListArgs, global: GlobalOptions) -> Result<()> {
+    let backend = create_backend(&global).await?;
+    let projects = backend
+        .with_full_graph(|graph| Ok(project_summaries(graph)))
+        .await
+        .context("Failed to list projects")?;
+
+    if args.json {
+        println!("{}", serde_json::to_string_pretty(&projects)?);
+        return Ok(());
+    }
+    if projects.is_empty() {
+        if !global.quiet {
+            println!("No projects found.");
+            println!("\nHint: Projects are detected from go.mod, Cargo.toml, and package.json");
+            println!("Run 'codeprysm update' to refresh the graph if you've added manifest files.");
+        }
+        return Ok(());
+    }
+
+    if !global.quiet {
+        println!("Found {} projects:\n", projects.len());
+    }
+    for project in &projects {
+        println!(
+            "  {} ({}) - {} files, {} symbols",
+            project.name, project.root, project.files, project.symbols
+        );
+    }
+    Ok(())
+}
+
+async fn execute_deps(args: DepsArgs, global: GlobalOptions) -> Result<()> {
+    let backend = create_backend(&global).await?;
+    let mut dependencies = backend
+        .with_full_graph(|graph| Ok(project_dependencies(graph)))
+        .await
+        .context("Failed to collect project dependencies")?;
+
+    if let Some(ref name) = args.name {
+        dependencies.retain(|d| {
+            if args.reverse {
+                &d.to == name
+            } else {
+                &d.from == name
+            }
+        });
+    }
+
+    if args.json {
+        println!("{}", serde_json::to_string_pretty(&dependencies)?);
+        return Ok(());
+    }
+    if dependencies.is_empty() {
+        if !global.quiet {
+            println!("No cross-project references found.");
+        }
+        return Ok(());
+    }
+    for dependency in &dependencies {
+        println!(
+            "  {} -> {} ({} references)",
+            dependency.from, dependency.to, dependency.references
+        );
+    }
+    Ok(())
+}
+
+async fn execute_export(args: ExportArgs, global: GlobalOptions) -> Result<()> {
+    let backend = create_backend(&global).await?;
+    let filter = args.filter.filter();
+    let budget = args.budget.budget();
+    let (found, sampled) = backend
+        .with_full_graph(|graph| {
+            let subgraph = project_subgraph(graph, &args.name);
+            let sampled = budget.apply(&filter.apply(&subgraph, graph), &[]);
+            Ok((subgraph.node_count() > 0, sampled))
+        })
+        .await
+        .context("Failed to extract project")?;
+    if !found {
+        anyhow::bail!(
+            "No project named '{}' (see 'codeprysm projects list')",
+            args.name
+        );
+    }
+    report_sampling(&sampled, &global);
+    let mut subgraph = sampled.graph;
+
+    let workspace_path = resolve_workspace(&global).await?;
+    let config = load_config(&global, &workspace_path)?;
+    let provenance = load_provenance(&config, &workspace_path);
+    attach_links(&mut subgraph, &config, &workspace_path, provenance.as_ref())?;
+    let rendered = export_graph(&subgraph, args.format, provenance.as_ref());
+
+    match args.output {
+        Some(ref path) => {
+            std::fs::write(path, &rendered)
+                .with_context(|| format!("Failed to write {}", path.display()))?;
+            if !global.quiet {
+                println!(
+                    "Wrote {} nodes and {} edges of project '{}' to {}",
+                    subgraph.node_count(),
+                    subgraph.edge_count(),
+                    args.name,
+                    path.display()
+                );
+            }
+        }
+        None => print!("{}", rendered),
+    }
+    Ok(())
+}
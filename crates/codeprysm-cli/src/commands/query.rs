@@ -0,0 +1,943 @@
+//! Query command - Whole-graph structural queries
+//!
+//! Provides queries that need the complete graph rather than a single node's
+//! neighborhood, such as shortest paths between two symbols, interface
+//! implementers across the whole indexed workspace, the owning teams of a
+//! symbol's callers, call hierarchy trees, free-form pattern queries (see
+//! [`codeprysm_core::analysis::query`]), batches of pattern queries against
+//! one loaded graph, and rename previews.
+
+use std::collections::HashSet;
+use std::io::{BufRead, BufWriter, IsTerminal, Read, Write};
+use std::path::PathBuf;
+use std::time::Instant;
+
+use anyhow::{Context, Result};
+use clap::{Args, Subcommand, ValueEnum};
+use codeprysm_backend::BackendError;
+use codeprysm_core::analysis::{
+    call_hierarchy, caller_owners, plan_rename, resolve_symbol, run_query, shortest_paths,
+    CallDirection, CallHierarchyCall, CallHierarchyOptions, MethodSets, PathOptions, QueryResult,
+    DEFAULT_HIERARCHY_DEPTH, DEFAULT_HIERARCHY_MAX_CALLS,
+};
+use codeprysm_core::{paginate, EdgeType, Node, NodeType, PageRequest, PetCodeGraph};
+use serde::Deserialize;
+
+use super::{
+    create_backend, parse_edge_type, print_error, print_info, print_warning, resolve_workspace,
+};
+use crate::GlobalOptions;
+
+/// Whole-graph query commands
+#[derive(Subcommand, Debug)]
+pub enum QueryCommand {
+    /// Find the shortest edge path(s) between two symbols
+    Path(PathArgs),
+
+    /// List concrete types that implement an interface (by method set)
+    Implementers(ImplementersArgs),
+
+    /// List interfaces a concrete type implements (by method set)
+    InterfacesOf(InterfacesOfArgs),
+
+    /// Show who owns a symbol and its callers (from CODEOWNERS)
+    Owners(OwnersArgs),
+
+    /// Show the callers or callees of a function as a tree (LSP
+    /// `callHierarchy` shape with --json)
+    CallHierarchy(CallHierarchyArgs),
+
+    /// Run a Cypher-like pattern query, or read queries from stdin when none
+    /// is given
+    Run(RunArgs),
+
+    /// Run a file of pattern queries against one loaded graph, writing one
+    /// JSON line of results per query
+    Batch(BatchArgs),
+
+    /// Preview renaming a symbol as a patch of its declaration and references
+    Rename(RenameArgs),
+}
+
+#[derive(Args, Debug)]
+pub struct PathArgs {
+    /// Starting symbol (node ID or name)
+    from: String,
+
+    /// Target symbol (node ID or name)
+    to: String,
+
+    /// Edge types to traverse, comma-separated (default: all)
+    #[arg(long, short = 'e', value_delimiter = ',', value_parser = parse_edge_type)]
+    edges: Vec<EdgeType>,
+
+    /// Maximum number of hops to explore
+    #[arg(long, short = 'd', default_value = "10")]
+    max_depth: usize,
+
+    /// Maximum number of equally short paths to show
+    #[arg(long, short = 'n', default_value = "5")]
+    max_paths: usize,
+
+    /// Output as JSON
+    #[arg(long)]
+    json: bool,
+}
+
+/// Paging of long result lists
+#[derive(Args, Debug)]
+pub struct PageArgs {
+    /// Maximum number of results (default: all)
+    #[arg(long, value_parser = clap::builder::RangedU64ValueParser::<usize>::new().range(1..))]
+    limit: Option<usize>,
+
+    /// Continue after a previous page (its `next_cursor`)
+    #[arg(long)]
+    cursor: Option<String>,
+}
+
+#[derive(Args, Debug)]
+pub struct ImplementersArgs {
+    /// Interface (node ID or name)
+    interface: String,
+
+    #[command(flatten)]
+    page: PageArgs,
+
+    /// Output as JSON
+    #[arg(long)]
+    json: bool,
+}
+
+#[derive(Args, Debug)]
+pub struct InterfacesOfArgs {
+    /// Concrete type (node ID or name)
+    #[arg(value_name = "TYPE")]
+    type_name: String,
+
+    #[command(flatten)]
+    page: PageArgs,
+
+    /// Output as JSON
+    #[arg(long)]
+    json: bool,
+}
+
+#[derive(Args, Debug)]
+pub struct OwnersArgs {
+    /// Symbol (node ID or name)
+    symbol: String,
+
+    /// Include callers up to this many hops away
+    #[arg(long, short = 'd', default_value = "1")]
+    depth: usize,
+
+    /// Output as JSON
+    #[arg(long)]
+    json: bool,
+}
+
+/// Side of the calls to expand
+#[derive(Debug, Clone, Copy, ValueEnum)]
+pub enum Calls {
+    /// Callers of the function
+    Incoming,
+    /// Functions it calls
+    Outgoing,
+}
+
+impl From<Calls> for CallDirection {
+    fn from(calls: Calls) -> Self {
+        match calls {
+            Calls::Incoming => CallDirection::Incoming,
+            Calls::Outgoing => CallDirection::Outgoing,
+        }
+    }
+}
+
+#[derive(Args, Debug)]
+pub struct CallHierarchyArgs {
+    /// Function or method (node ID or name)
+    symbol: String,
+
+    /// Expand callers or callees
+    #[arg(long, value_enum, default_value = "incoming")]
+    direction: Calls,
+
+    /// Levels to expand below the symbol
+    #[arg(long, short = 'd', default_value_t = DEFAULT_HIERARCHY_DEPTH)]
+    depth: usize,
+
+    /// Maximum number of calls in the tree
+    #[arg(long, default_value_t = DEFAULT_HIERARCHY_MAX_CALLS)]
+    max_calls: usize,
+
+    /// Output as JSON
+    #[arg(long)]
+    json: bool,
+}
+
+#[derive(Args, Debug)]
+pub struct RunArgs {
+    /// Query, e.g. 'MATCH (f:Function)-[:CALLS]->(g {name: "Save"}) RETURN f'
+    query: Option<String>,
+
+    /// Output as JSON
+    #[arg(long)]
+    json: bool,
+}
+
+#[derive(Args, Debug)]
+pub struct BatchArgs {
+    /// File of queries: one per line, or a JSON array of query strings or
+    /// `{"id": ..., "query": ...}` objects ('-' for stdin)
+    file: PathBuf,
+
+    /// Write the results to a file instead of stdout
+    #[arg(long, short = 'o')]
+    output: Option<PathBuf>,
+}
+
+#[derive(Args, Debug)]
+pub struct RenameArgs {
+    /// Symbol to rename (node ID or name)
+    symbol: String,
+
+    /// New name
+    new_name: String,
+
+    /// Output the edits as JSON instead of a unified diff
+    #[arg(long)]
+    json: bool,
+}
+
+/// Execute query commands
+pub async fn execute(cmd: QueryCommand, global: GlobalOptions) -> Result<()> {
+    match cmd {
+        QueryCommand::Path(args) => execute_path(args, global).await,
+        QueryCommand::Implementers(args) => execute_implementers(args, global).await,
+        QueryCommand::InterfacesOf(args) => execute_interfaces_of(args, global).await,
+        QueryCommand::Owners(args) => execute_owners(args, global).await,
+        QueryCommand::CallHierarchy(args) => execute_call_hierarchy(args, global).await,
+        QueryCommand::Run(args) => execute_run(args, global).await,
+        QueryCommand::Batch(args) => execute_batch(args, global).await,
+        QueryCommand::Rename(args) => execute_rename(args, global).await,
+    }
+}
+
+async fn execute_path(args: PathArgs, global: GlobalOptions) -> Result<()> {
+    let backend = create_backend(&global).await?;
+
+    let options = PathOptions {
+        edge_types: if args.edges.is_empty() {
+            None
+        } else {
+            Some(args.edges.clone())
+        },
+        max_depth: args.max_depth,
+        max_paths: args.max_paths,
+    };
+
+    let (from, to, paths) = backend
+        .with_full_graph(|graph| {
+            let from = resolve_unique(graph, &args.from)?;
+            let to = resolve_unique(graph, &args.to)?;
+            let paths = shortest_paths(graph, &from, &to, &options);
+            Ok((from, to, paths))
+        })
+        .await
+        .context("Failed to query paths")?;
+
+    if args.json {
+        let output = serde_json::json!({
+            "from": from,
+            "to": to,
+            "path_count": paths.len(),
+            "paths": paths,
+        });
+        println!("{}", serde_json::to_string_pretty(&output)?);
+        return Ok(());
+    }
+
+    if paths.is_empty() {
+        if !global.quiet {
+            println!(
+                "No path from '{}' to '{}' within {} hops.",
+                from, to, args.max_depth
+            );
+        }
+        return Ok(());
+    }
+
+    if !global.quiet {
+        println!(
+            "Found {} shortest path(s) from '{}' to '{}' ({} hops):\n",
+            paths.len(),
+            from,
+            to,
+            paths[0].len()
+        );
+    }
+
+    for (i, path) in paths.iter().enumerate() {
+        if i > 0 {
+            println!();
+        }
+        println!("  {}", path.nodes[0]);
+        for (edge_type, node) in path.edges.iter().zip(path.nodes.iter().skip(1)) {
+            println!("    --[{}]--> {}", edge_type.as_str(), node);
+        }
+    }
+
+    Ok(())
+}
+
+async fn execute_implementers(args: ImplementersArgs, global: GlobalOptions) -> Result<()> {
+    let backend = create_backend(&global).await?;
+
+    let (interface, implementers) = backend
+        .with_full_graph(|graph| {
+            let sets = MethodSets::build(graph);
+            let interface =
+                resolve_unique_where(graph, &args.interface, |n| sets.is_interface(&n.id))?;
+            let implementers = sets.implementers(&interface);
+            Ok((interface, implementers))
+        })
+        .await
+        .context("Failed to query implementers")?;
+
+    print_matches(
+        "interface",
+        &interface,
+        "implementers",
+        implementers,
+        &args.page,
+        args.json,
+        &global,
+    )
+}
+
+async fn execute_interfaces_of(args: InterfacesOfArgs, global: GlobalOptions) -> Result<()> {
+    let backend = create_backend(&global).await?;
+
+    let (type_id, interfaces) = backend
+        .with_full_graph(|graph| {
+            let sets = MethodSets::build(graph);
+            let type_id = resolve_unique_where(graph, &args.type_name, |n| sets.is_type(&n.id))?;
+            let interfaces = sets.interfaces_of(&type_id);
+            Ok((type_id, interfaces))
+        })
+        .await
+        .context("Failed to query interfaces")?;
+
+    print_matches(
+        "type",
+        &type_id,
+        "interfaces",
+        interfaces,
+        &args.page,
+        args.json,
+        &global,
+    )
+}
+
+async fn execute_owners(args: OwnersArgs, global: GlobalOptions) -> Result<()> {
+    let backend = create_backend(&global).await?;
+
+    let (symbol, owners, groups) = backend
+        .with_full_graph(|graph| {
+            let symbol = resolve_unique(graph, &args.symbol)?;
+            let owners = graph
+                .get_node(&symbol)
+                .and_then(|n| n.metadata.owners.clone())
+                .unwrap_or_default();
+            let groups = caller_owners(graph, &symbol, args.depth);
+            Ok((symbol, owners, groups))
+        })
+        .await
+        .context("Failed to query owners")?;
+
+    if args.json {
+        let output = serde_json::json!({
+            "symbol": symbol,
+            "owners": owners,
+            "callers_by_owner": groups,
+        });
+        println!("{}", serde_json::to_string_pretty(&output)?);
+        return Ok(());
+    }
+
+    if owners.is_empty() {
+        println!("{} (unowned)", symbol);
+    } else {
+        println!("{} ({})", symbol, owners.join(", "));
+    }
+
+    if groups.is_empty() {
+        if !global.quiet {
+            println!("\nNo callers within {} hop(s).", args.depth);
+        }
+        return Ok(());
+    }
+
+    for group in &groups {
+        println!(
+            "\n  {} ({} caller(s))",
+            group.owner.as_deref().unwrap_or("(unowned)"),
+            group.callers.len()
+        );
+        for caller in &group.callers {
+            println!("    {}", caller);
+        }
+    }
+
+    if !global.quiet {
+        let teams = groups.iter().filter(|g| g.owner.is_some()).count();
+        println!("\nCallers span {} owner(s).", teams);
+    }
+
+    Ok(())
+}
+
+async fn execute_call_hierarchy(args: CallHierarchyArgs, global: GlobalOptions) -> Result<()> {
+    let backend = create_backend(&global).await?;
+    let root = backend.workspace_root().to_path_buf();
+    let options = CallHierarchyOptions {
+        depth: args.depth,
+        max_calls: args.max_calls,
+    };
+
+    let hierarchy = backend
+        .with_full_graph(|graph| {
+            let symbol =
+                resolve_unique_where(graph, &args.symbol, |n| n.node_type == NodeType::Callable)?;
+            Ok(call_hierarchy(
+                graph,
+                &symbol,
+                args.direction.into(),
+                Some(&root),
+                &options,
+            ))
+        })
+        .await
+        .context("Failed to build call hierarchy")?
+        .context("Symbol is not a function or method")?;
+
+    if args.json {
+        println!("{}", serde_json::to_string_pretty(&hierarchy)?);
+        return Ok(());
+    }
+
+    println!("{}", hierarchy.item.detail);
+    let arrow = match args.direction {
+        Calls::Incoming => "<-",
+        Calls::Outgoing => "->",
+    };
+    print_calls(&hierarchy.calls, arrow, 1);
+
+    if !global.quiet {
+        if hierarchy.calls.is_empty() {
+            println!("\nNo {} calls.", hierarchy.direction);
+        } else if hierarchy.truncated {
+            println!(
+                "\nStopped at {} calls; raise --max-calls to see more.",
+                hierarchy.call_count()
+            );
+        }
+    }
+
+    Ok(())
+}
+
+/// Print calls as an indented tree, with call-site lines (1-indexed)
+fn print_calls(calls: &[CallHierarchyCall], arrow: &str, level: usize) {
+    for call in calls {
+        let lines: Vec<String> = call
+            .from_ranges
+            .iter()
+            .map(|r| (r.start.line + 1).to_string())
+            .collect();
+        println!(
+            "{}{} {}{}{}",
+            "  ".repeat(level),
+            arrow,
+            call.item().detail,
+            if lines.is_empty() {
+                String::new()
+            } else {
+                format!(" (line {})", lines.join(", "))
+            },
+            if call.recursive { " [recursive]" } else { "" }
+        );
+        print_calls(&call.calls, arrow, level + 1);
+    }
+}
+
+async fn execute_run(args: RunArgs, global: GlobalOptions) -> Result<()> {
+    let backend = create_backend(&global).await?;
+
+    // An interactive session queries the graph loaded here until stdin ends
+    let result = backend
+        .with_full_graph(|graph| match args.query {
+            Some(ref query) => Ok(Some(run_query(graph, query))),
+            None => {
+                run_interactive(graph, args.json, global.quiet)
+                    .map_err(|e| BackendError::with_context("reading queries", e.to_string()))?;
+                Ok(None)
+            }
+        })
+        .await
+        .context("Failed to run query")?;
+
+    match result {
+        Some(result) => print_query_result(&result?, args.json, global.quiet),
+        None => Ok(()),
+    }
+}
+
+/// Run queries read from stdin, one per line, until end of input or `exit`
+fn run_interactive(graph: &PetCodeGraph, json: bool, quiet: bool) -> std::io::Result<()> {
+    let stdin = std::io::stdin();
+    let prompt = !quiet && stdin.is_terminal();
+    if prompt {
+        println!("Enter one query per line; end with Ctrl-D or 'exit'.");
+    }
+
+    let mut lines = stdin.lock().lines();
+    loop {
+        if prompt {
+            print!("query> ");
+            std::io::stdout().flush()?;
+        }
+        let Some(line) = lines.next().transpose()? else {
+            break;
+        };
+        let query = line.trim().trim_end_matches(';').trim();
+        if query.is_empty() {
+            continue;
+        }
+        if query.eq_ignore_ascii_case("exit") || query.eq_ignore_ascii_case("quit") {
+            break;
+        }
+        match run_query(graph, query) {
+            Ok(result) => {
+                if let Err(e) = print_query_result(&result, json, quiet) {
+                    print_error(&e.to_string());
+                }
+            }
+            Err(e) => print_error(&e.to_string()),
+        }
+    }
+    Ok(())
+}
+
+/// A query of a batch, with the caller's ID for matching up results.
+#[derive(Debug, PartialEq, Eq, Deserialize)]
+struct BatchQuery {
+    #[serde(default)]
+    id: Option<String>,
+    query: String,
+}
+
+/// Parse a batch of queries: a JSON array of query strings or objects, or
+/// one query per line (blank lines and `#` comments skipped)
+fn parse_batch(input: &str) -> Result<Vec<BatchQuery>> {
+    #[derive(Deserialize)]
+    #[serde(untagged)]
+    enum Entry {
+        Query(String),
+        Object(BatchQuery),
+    }
+
+    if input.trim_start().starts_with('[') {
+        let entries: Vec<Entry> =
+            serde_json::from_str(input).context("Invalid JSON array of queries")?;
+        return Ok(entries
+            .into_iter()
+            .map(|entry| match entry {
+                Entry::Query(query) => BatchQuery { id: None, query },
+                Entry::Object(query) => query,
+            })
+            .collect());
+    }
+    Ok(input
+        .lines()
+        .map(|line| line.trim().trim_end_matches(';').trim())
+        .filter(|line| !line.is_empty() && !line.starts_with('#'))
+        .map(|line| BatchQuery {
+            id: None,
+            query: line.to_string(),
+        })
+        .collect())
+}
+
+/// Run a batch against `graph`, writing one JSON line per query to `out`. A
+/// failing query gets a line with its `error` instead of stopping the batch.
+/// Returns the number of failed queries.
+fn run_batch(
+    graph: &PetCodeGraph,
+    queries: &[BatchQuery],
+    out: &mut dyn Write,
+) -> std::io::Result<usize> {
+    let mut failed = 0;
+    for (index, batch) in queries.iter().enumerate() {
+        let mut line = serde_json::json!({ "index": index });
+        if let Some(ref id) = batch.id {
+            line["id"] = id.clone().into();
+        }
+        line["query"] = batch.query.clone().into();
+        match run_query(graph, &batch.query) {
+            Ok(result) => {
+                line["columns"] = serde_json::json!(result.columns);
+                line["rows"] = serde_json::json!(result.rows);
+            }
+            Err(e) => {
+                line["error"] = e.to_string().into();
+                failed += 1;
+            }
+        }
+        writeln!(out, "{}", line)?;
+    }
+    out.flush()?;
+    Ok(failed)
+}
+
+async fn execute_batch(args: BatchArgs, global: GlobalOptions) -> Result<()> {
+    let input = if args.file.as_os_str() == "-" {
+        let mut input = String::new();
+        std::io::stdin()
+            .read_to_string(&mut input)
+            .context("Failed to read queries from stdin")?;
+        input
+    } else {
+        std::fs::read_to_string(&args.file)
+            .with_context(|| format!("Failed to read {}", args.file.display()))?
+    };
+    let queries = parse_batch(&input)?;
+
+    let mut out: Box<dyn Write + Send> = match args.output {
+        Some(ref path) => Box::new(BufWriter::new(
+            std::fs::File::create(path)
+                .with_context(|| format!("Failed to create {}", path.display()))?,
+        )),
+        None => Box::new(BufWriter::new(std::io::stdout())),
+    };
+
+    let backend = create_backend(&global).await?;
+    let start = Instant::now();
+    // Results stream out as each query finishes
+    let failed = backend
+        .with_full_graph(|graph| {
+            run_batch(graph, &queries, &mut out)
+                .map_err(|e| BackendError::with_context("writing results", e.to_string()))
+        })
+        .await
+        .context("Failed to run queries")?;
+
+    print_info(
+        &format!(
+            "Ran {} queries in {:.2}s ({} failed)",
+            queries.len(),
+            start.elapsed().as_secs_f64(),
+            failed
+        ),
+        global.quiet,
+    );
+    if failed > 0 {
+        anyhow::bail!("{} of {} queries failed", failed, queries.len());
+    }
+    Ok(())
+}
+
+/// Print query rows as JSON or as aligned columns, showing nodes by ID
+fn print_query_result(result: &QueryResult, json: bool, quiet: bool) -> Result<()> {
+    if json {
+        println!("{}", serde_json::to_string_pretty(result)?);
+        return Ok(());
+    }
+
+    let cell = |value: &serde_json::Value| match value {
+        serde_json::Value::Null => String::new(),
+        serde_json::Value::String(s) => s.clone(),
+        serde_json::Value::Object(fields) if fields.contains_key("id") => match &fields["id"] {
+            serde_json::Value::String(id) => id.clone(),
+            id => id.to_string(),
+        },
+        other => other.to_string(),
+    };
+    let rows: Vec<Vec<String>> = result
+        .rows
+        .iter()
+        .map(|row| row.iter().map(cell).collect())
+        .collect();
+    let widths: Vec<usize> = result
+        .columns
+        .iter()
+        .enumerate()
+        .map(|(i, column)| {
+            rows.iter()
+                .map(|row| row[i].chars().count())
+                .fold(column.chars().count(), usize::max)
+        })
+        .collect();
+
+    let print_row = |cells: &[String]| {
+        let line: Vec<String> = cells
+            .iter()
+            .zip(&widths)
+            .map(|(cell, width)| format!("{:<width$}", cell, width = width))
+            .collect();
+        println!("{}", line.join("  ").trim_end());
+    };
+    if !quiet {
+        print_row(&result.columns);
+    }
+    for row in &rows {
+        print_row(row);
+    }
+    if !quiet {
+        println!("\n{} row(s)", rows.len());
+    }
+    Ok(())
+}
+
+async fn execute_rename(args: RenameArgs, global: GlobalOptions) -> Result<()> {
+    let workspace = resolve_workspace(&global).await?;
+    let backend = create_backend(&global).await?;
+
+    let plan = backend
+        .with_full_graph(|graph| {
+            let symbol = resolve_unique(graph, &args.symbol)?;
+            plan_rename(graph, &symbol, &args.new_name, &workspace)
+                .map_err(|e| BackendError::with_context("planning rename", e.to_string()))
+        })
+        .await
+        .context("Failed to preview rename")?;
+
+    if args.json {
+        println!("{}", serde_json::to_string_pretty(&plan)?);
+    } else {
+        print!("{}", plan.unified_diff(&workspace));
+    }
+
+    // Warnings go to stderr so the diff can be piped to `git apply`
+    for id in &plan.conflicts {
+        print_warning(&format!("'{}' is already declared: {}", plan.new_name, id));
+    }
+    for id in &plan.related {
+        print_warning(&format!(
+            "{} shares the method name through an interface; rename it too",
+            id
+        ));
+    }
+    for location in &plan.unlocated {
+        print_warning(&format!(
+            "'{}' not found at {}:{}; the index may be stale",
+            plan.old_name, location.file, location.line
+        ));
+    }
+    if !global.quiet {
+        let files: HashSet<&str> = plan.edits.iter().map(|e| e.file.as_str()).collect();
+        print_info(
+            &format!(
+                "{} edit(s) in {} file(s) to rename {} to {}",
+                plan.edits.len(),
+                files.len(),
+                plan.symbol,
+                plan.new_name
+            ),
+            false,
+        );
+    }
+    Ok(())
+}
+
+/// Print one page of the result of an implementers/interfaces-of query
+fn print_matches(
+    subject_key: &str,
+    subject: &str,
+    matches_key: &str,
+    matches: Vec<String>,
+    page: &PageArgs,
+    json: bool,
+    global: &GlobalOptions,
+) -> Result<()> {
+    let request = PageRequest::parse(0, page.cursor.as_deref(), page.limit.unwrap_or(usize::MAX))?;
+    let page = paginate(matches, &request, String::clone);
+
+    if json {
+        let mut output = serde_json::json!({
+            subject_key: subject,
+            "count": page.total,
+            matches_key: page.items,
+        });
+        if let Some(ref cursor) = page.next_cursor {
+            output["next_cursor"] = serde_json::json!(cursor);
+        }
+        println!("{}", serde_json::to_string_pretty(&output)?);
+        return Ok(());
+    }
+
+    if !global.quiet {
+        if page.total == 0 {
+            println!("No {} found for '{}'.", matches_key, subject);
+            return Ok(());
+        }
+        println!("Found {} {} for '{}':\n", page.total, matches_key, subject);
+    }
+
+    for id in &page.items {
+        println!("  {}", id);
+    }
+
+    if let Some(cursor) = page.next_cursor {
+        if !global.quiet {
+            println!(
+                "\n{} more; continue with --cursor {}",
+                page.total - page.offset - page.items.len(),
+                cursor
+            );
+        }
+    }
+
+    Ok(())
+}
+
+/// Resolve a symbol argument to exactly one node ID.
+///
+/// Fails with the list of candidates when the symbol is ambiguous, so the user
+/// can retry with a full node ID.
+pub(crate) fn resolve_unique(graph: &PetCodeGraph, symbol: &str) -> Result<String, BackendError> {
+    resolve_unique_where(graph, symbol, |_| true)
+}
+
+/// Resolve a symbol argument to exactly one node ID among nodes accepted by
+/// `filter` (e.g., only interfaces).
+pub(crate) fn resolve_unique_where<F>(
+    graph: &PetCodeGraph,
+    symbol: &str,
+    filter: F,
+) -> Result<String, BackendError>
+where
+    F: Fn(&Node) -> bool,
+{
+    let matches: Vec<&Node> = resolve_symbol(graph, symbol)
+        .into_iter()
+        .filter(|n| filter(n))
+        .collect();
+    match matches.len() {
+        0 => Err(BackendError::node_not_found(symbol)),
+        1 => Ok(matches[0].id.clone()),
+        count => {
+            let candidates: Vec<&str> = matches.iter().take(10).map(|n| n.id.as_str()).collect();
+            Err(BackendError::with_context(
+                "resolving symbol",
+                format!(
+                    "'{}' is ambiguous ({} matches); use a node ID: {}",
+                    symbol,
+                    count,
+                    candidates.join(", ")
+                ),
+            ))
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use codeprysm_core::CallableKind;
+
+    fn batch(id: Option<&str>, query: &str) -> BatchQuery {
+        BatchQuery {
+            id: id.map(str::to_string),
+            query: query.to_string(),
+        }
+    }
+
+    #[test]
+    fn test_parse_batch_json_array() {
+        let queries = parse_batch(
+            r#"["MATCH (f) RETURN f", {"id": "callers", "query": "MATCH (f)-[:CALLS]->(g) RETURN f"}]"#,
+        )
+        .unwrap();
+        assert_eq!(
+            queries,
+            vec![
+                batch(None, "MATCH (f) RETURN f"),
+                batch(Some("callers"), "MATCH (f)-[:CALLS]->(g) RETURN f"),
+            ]
+        );
+
+        assert!(parse_batch("[\"MATCH (f) RETURN f\"").is_err());
+    }
+
+    #[test]
+    fn test_parse_batch_objects_without_id() {
+        let queries = parse_batch(r#"[{"query": "MATCH (f) RETURN f"}]"#).unwrap();
+        assert_eq!(queries, vec![batch(None, "MATCH (f) RETURN f")]);
+    }
+
+    #[test]
+    fn test_parse_batch_lines() {
+        let input = "MATCH (f) RETURN f\n\n  MATCH (t:Type) RETURN t  \n";
+        assert_eq!(
+            parse_batch(input).unwrap(),
+            vec![
+                batch(None, "MATCH (f) RETURN f"),
+                batch(None, "MATCH (t:Type) RETURN t"),
+            ]
+        );
+    }
+
+    #[test]
+    fn test_parse_batch_skips_comments() {
+        let input = "# callers\nMATCH (f) RETURN f\n  # indented\n";
+        assert_eq!(
+            parse_batch(input).unwrap(),
+            vec![batch(None, "MATCH (f) RETURN f")]
+        );
+    }
+
+    #[test]
+    fn test_parse_batch_trims_semicolons() {
+        let input = "MATCH (f) RETURN f;\nMATCH (g) RETURN g ; \n;\n";
+        assert_eq!(
+            parse_batch(input).unwrap(),
+            vec![
+                batch(None, "MATCH (f) RETURN f"),
+                batch(None, "MATCH (g) RETURN g"),
+            ]
+        );
+    }
+
+    #[test]
+    fn test_run_batch_reports_malformed_query() {
+        let mut graph = PetCodeGraph::new();
+        graph.add_node(Node::callable(
+            "a.go:Run".to_string(),
+            "Run".to_string(),
+            CallableKind::Function,
+            "a.go".to_string(),
+            1,
+            3,
+        ));
+        let queries = parse_batch("MATCH (f RETURN f\nMATCH (f:Function) RETURN f.name").unwrap();
+
+        let mut out = Vec::new();
+        let failed = run_batch(&graph, &queries, &mut out).unwrap();
+        assert_eq!(failed, 1);
+
+        let lines: Vec<serde_json::Value> = String::from_utf8(out)
+            .unwrap()
+            .lines()
+            .map(|line| serde_json::from_str(line).unwrap())
+            .collect();
+        assert_eq!(lines.len(), 2);
+        assert_eq!(lines[0]["index"], 0);
+        assert!(lines[0]["error"].is_string());
+        assert!(lines[0].get("rows").is_none());
+        assert_eq!(lines[1]["index"], 1);
+        assert_eq!(lines[1]["rows"], serde_json::json!([["Run"]]));
+    }
+}
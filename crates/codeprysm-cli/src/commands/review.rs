@@ -0,0 +1,443 @@
+//! Review command - Annotate a pull request with its structural impact
+//!
+//! Indexes the merge base and head of a pull request in the diff worktree
+//! (see the diff command) and reports what reviewers should look at: changed
+//! symbols with many callers, breaking changes to the exported API, and code
+//! the change leaves unreachable. Findings can be printed as GitHub workflow
+//! annotations, and a Markdown summary can be written for the job summary or
+//! as a pull request comment payload.
+
+use std::collections::HashSet;
+use std::fmt::Write as _;
+use std::fs::OpenOptions;
+use std::io::Write as _;
+use std::path::PathBuf;
+
+use anyhow::{Context, Result};
+use clap::{Args, ValueEnum};
+use codeprysm_core::analysis::{
+    analyze_impact, api_surface, diff_api, diff_graphs, find_new_dead_code, parse_unified_diff,
+    ApiChange, DeadCodeOptions, DeadSymbol, ImpactOptions, Severity,
+};
+use codeprysm_core::{EdgeType, PetCodeGraph};
+use serde::Serialize;
+
+use super::diff::{git, resolve_commit, RevisionIndex};
+use super::{load_config, resolve_workspace};
+use crate::progress::{finish_spinner, spinner};
+use crate::GlobalOptions;
+
+/// Arguments for the review command
+#[derive(Args, Debug)]
+pub struct ReviewArgs {
+    /// Branch the pull request merges into (e.g. origin/main)
+    base: String,
+
+    /// Head of the pull request
+    #[arg(default_value = "HEAD")]
+    head: String,
+
+    /// Minimum number of callers for a changed symbol to be flagged
+    #[arg(long, default_value = "10")]
+    min_fan_in: usize,
+
+    /// Output format
+    #[arg(long, short = 'f', value_enum, default_value = "text")]
+    format: ReviewFormat,
+
+    /// Append a Markdown summary to this file (e.g. "$GITHUB_STEP_SUMMARY")
+    #[arg(long, value_name = "FILE")]
+    summary: Option<PathBuf>,
+
+    /// Write a pull request comment payload (`{"body": ...}`) to this file
+    #[arg(long, value_name = "FILE")]
+    comment: Option<PathBuf>,
+
+    /// Fail if the change breaks the API or leaves code unreachable
+    #[arg(long)]
+    strict: bool,
+}
+
+/// Output format for review findings
+#[derive(Debug, Clone, Copy, ValueEnum)]
+pub enum ReviewFormat {
+    /// Human-readable text
+    Text,
+    /// GitHub workflow commands (`::warning file=...::`), shown as annotations
+    Github,
+    /// JSON report
+    Json,
+    /// Markdown summary
+    Markdown,
+}
+
+/// A changed symbol with many callers.
+#[derive(Debug, Serialize)]
+struct HotSymbol {
+    id: String,
+    name: String,
+    file: String,
+    line: usize,
+    /// Number of distinct symbols using it
+    fan_in: usize,
+}
+
+/// Findings for a pull request
+#[derive(Debug, Serialize)]
+struct ReviewReport {
+    base: String,
+    head: String,
+    added_symbols: usize,
+    removed_symbols: usize,
+    changed_symbols: usize,
+    /// Symbols depending on the changed ones, transitively
+    impacted_symbols: usize,
+    test_files: Vec<String>,
+    hot_symbols: Vec<HotSymbol>,
+    breaking_changes: Vec<ApiChange>,
+    dead_code: Vec<DeadSymbol>,
+}
+
+/// A GitHub check annotation
+struct Annotation<'a> {
+    level: &'static str,
+    file: &'a str,
+    line: usize,
+    title: String,
+    message: String,
+}
+
+/// Execute the review command
+pub async fn execute(args: ReviewArgs, global: GlobalOptions) -> Result<()> {
+    let workspace_path = resolve_workspace(&global).await?;
+    let config = load_config(&global, &workspace_path)?;
+
+    // Compare against where the branch forked, as the pull request diff does
+    let head_commit = resolve_commit(&workspace_path, &args.head)?;
+    let base_commit = resolve_commit(&workspace_path, &args.base)?;
+    let merge_base = git(&workspace_path, &["merge-base", &base_commit, &head_commit])
+        .with_context(|| format!("No common ancestor of {} and {}", args.base, args.head))?
+        .trim()
+        .to_string();
+
+    let mut revisions = RevisionIndex::new(&workspace_path, &config)?;
+
+    let pb = spinner(&format!("Indexing {}...", args.base), global.quiet);
+    let old_graph = revisions.index(&merge_base)?;
+    let old_api = api_surface(&old_graph, revisions.worktree());
+    finish_spinner(pb, &format!("Indexed {}", args.base));
+
+    let pb = spinner(&format!("Indexing {}...", args.head), global.quiet);
+    let new_graph = revisions.index(&head_commit)?;
+    let new_api = api_surface(&new_graph, revisions.worktree());
+    finish_spinner(pb, &format!("Indexed {}", args.head));
+
+    let diff = diff_graphs(&old_graph, &new_graph);
+    let patch = git(
+        &workspace_path,
+        &[
+            "diff",
+            "--unified=0",
+            "--no-color",
+            &merge_base,
+            &head_commit,
+        ],
+    )?;
+    let impact = analyze_impact(
+        &new_graph,
+        &parse_unified_diff(&patch),
+        &ImpactOptions::default(),
+    );
+
+    let mut hot_symbols: Vec<HotSymbol> = impact
+        .changed
+        .iter()
+        .map(|symbol| HotSymbol {
+            id: symbol.id.clone(),
+            name: symbol.name.clone(),
+            file: symbol.file.clone(),
+            line: symbol.line,
+            fan_in: fan_in(&new_graph, &symbol.id),
+        })
+        .filter(|symbol| symbol.fan_in >= args.min_fan_in)
+        .collect();
+    hot_symbols.sort_by(|a, b| b.fan_in.cmp(&a.fan_in).then_with(|| a.id.cmp(&b.id)));
+
+    let mut dead_code = find_new_dead_code(&old_graph, &new_graph, &DeadCodeOptions::default());
+    dead_code.apply_suppressions(revisions.worktree());
+
+    let report = ReviewReport {
+        base: args.base.clone(),
+        head: args.head.clone(),
+        added_symbols: diff.added_nodes.len(),
+        removed_symbols: diff.removed_nodes.len(),
+        changed_symbols: diff.changed_nodes.len(),
+        impacted_symbols: impact.impacted.len(),
+        test_files: impact.test_files,
+        hot_symbols,
+        breaking_changes: diff_api(&old_api, &new_api)
+            .changes
+            .into_iter()
+            .filter(|c| c.severity == Severity::Breaking)
+            .collect(),
+        dead_code: dead_code.dead,
+    };
+
+    match args.format {
+        ReviewFormat::Text => print_report(&report, &global),
+        ReviewFormat::Github => {
+            for annotation in annotations(&report) {
+                println!("{}", workflow_command(&annotation));
+            }
+        }
+        ReviewFormat::Json => println!("{}", serde_json::to_string_pretty(&report)?),
+        ReviewFormat::Markdown => print!("{}", markdown(&report)),
+    }
+
+    if let Some(ref path) = args.summary {
+        // The job summary file is shared by all steps of a job
+        let mut file = OpenOptions::new()
+            .create(true)
+            .append(true)
+            .open(path)
+            .with_context(|| format!("Failed to open {}", path.display()))?;
+        file.write_all(markdown(&report).as_bytes())
+            .with_context(|| format!("Failed to write {}", path.display()))?;
+    }
+    if let Some(ref path) = args.comment {
+        let payload = serde_json::json!({ "body": markdown(&report) });
+        std::fs::write(path, serde_json::to_string_pretty(&payload)?)
+            .with_context(|| format!("Failed to write {}", path.display()))?;
+    }
+
+    let findings = report.breaking_changes.len() + report.dead_code.len();
+    if args.strict && findings > 0 {
+        anyhow::bail!(
+            "{} breaking change(s) or newly unreachable symbol(s)",
+            findings
+        );
+    }
+
+    Ok(())
+}
+
+fn write_file(path: &Path, content: &str) -> Result<()> {
+    std::fs::write(path, content).with_context(|| format!("Failed to write {}", path.display()))
+}
+
+/// Number of distinct symbols with USES edges to `id`
+fn fan_in(graph: &PetCodeGraph, id: &str) -> usize {
+    graph
+        .incoming_edges(id)
+        .filter(|(source, edge)| edge.edge_type == EdgeType::Uses && source.id != id)
+        .map(|(source, _)| source.id.as_str())
+        .collect::<HashSet<_>>()
+        .len()
+}
+
+/// Annotations for every finding, most important first
+fn annotations(report: &ReviewReport) -> Vec<Annotation<'_>> {
+    let mut annotations = Vec::new();
+    for change in &report.breaking_changes {
+        annotations.push(Annotation {
+            level: "warning",
+            file: &change.file,
+            line: change.line,
+            title: format!("Breaking API change: {}", change.kind.as_str()),
+            message: match (&change.old_signature, &change.new_signature) {
+                (Some(old), Some(new)) => format!("{}: {} -> {}", change.symbol, old, new),
+                _ => format!("{} is {}", change.symbol, change.kind.as_str()),
+            },
+        });
+    }
+    for symbol in &report.dead_code {
+        annotations.push(Annotation {
+            level: "warning",
+            file: &symbol.file,
+            line: symbol.line,
+            title: "Unreachable code".to_string(),
+            message: format!("{} is no longer used from any entry point", symbol.name),
+        });
+    }
+    for symbol in &report.hot_symbols {
+        annotations.push(Annotation {
+            level: "notice",
+            file: &symbol.file,
+            line: symbol.line,
+            title: "High fan-in symbol changed".to_string(),
+            message: format!("{} is used by {} symbols", symbol.name, symbol.fan_in),
+        });
+    }
+    annotations
+}
+
+/// Format an annotation as a GitHub workflow command
+fn workflow_command(annotation: &Annotation) -> String {
+    format!(
+        "::{} file={},line={},title={}::{}",
+        annotation.level,
+        escape_property(annotation.file),
+        annotation.line.max(1),
+        escape_property(&annotation.title),
+        escape_data(&annotation.message)
+    )
+}
+
+fn escape_data(value: &str) -> String {
+    value
+        .replace('%', "%25")
+        .replace('\r', "%0D")
+        .replace('\n', "%0A")
+}
+
+fn escape_property(value: &str) -> String {
+    escape_data(value).replace(':', "%3A").replace(',', "%2C")
+}
+
+/// Render the report as a Markdown summary
+fn markdown(report: &ReviewReport) -> String {
+    let mut out = String::new();
+    let _ = writeln!(out, "## CodePrysm review of {}", report.head);
+    let _ = writeln!(out);
+    let _ = writeln!(
+        out,
+        "{} symbols added, {} removed, {} changed; {} symbols and {} test files impacted.",
+        report.added_symbols,
+        report.removed_symbols,
+        report.changed_symbols,
+        report.impacted_symbols,
+        report.test_files.len()
+    );
+
+    if !report.breaking_changes.is_empty() {
+        let _ = writeln!(
+            out,
+            "\n### Breaking API changes ({})\n",
+            report.breaking_changes.len()
+        );
+        let _ = writeln!(out, "| Symbol | Change | Location |");
+        let _ = writeln!(out, "|---|---|---|");
+        for change in &report.breaking_changes {
+            let _ = writeln!(
+                out,
+                "| `{}` | {} | `{}:{}` |",
+                change.symbol,
+                change.kind.as_str(),
+                change.file,
+                change.line
+            );
+        }
+    }
+    if !report.dead_code.is_empty() {
+        let _ = writeln!(
+            out,
+            "\n### Newly unreachable code ({})\n",
+            report.dead_code.len()
+        );
+        let _ = writeln!(out, "| Symbol | Location |");
+        let _ = writeln!(out, "|---|---|");
+        for symbol in &report.dead_code {
+            let _ = writeln!(
+                out,
+                "| `{}` | `{}:{}` |",
+                symbol.name, symbol.file, symbol.line
+            );
+        }
+    }
+    if !report.hot_symbols.is_empty() {
+        let _ = writeln!(
+            out,
+            "\n### Changed symbols with high fan-in ({})\n",
+            report.hot_symbols.len()
+        );
+        let _ = writeln!(out, "| Symbol | Callers | Location |");
+        let _ = writeln!(out, "|---|---|---|");
+        for symbol in &report.hot_symbols {
+            let _ = writeln!(
+                out,
+                "| `{}` | {} | `{}:{}` |",
+                symbol.name, symbol.fan_in, symbol.file, symbol.line
+            );
+        }
+    }
+    if !report.test_files.is_empty() {
+        let _ = writeln!(
+            out,
+            "\n<details><summary>Impacted test files ({})</summary>\n",
+            report.test_files.len()
+        );
+        for file in &report.test_files {
+            let _ = writeln!(out, "- `{}`", file);
+        }
+        let _ = writeln!(out, "\n</details>");
+    }
+    out
+}
+
+/// Print the report as text
+fn print_report(report: &ReviewReport, global: &GlobalOptions) {
+    if !report.breaking_changes.is_empty() {
+        println!("Breaking API changes ({}):", report.breaking_changes.len());
+        for change in &report.breaking_changes {
+            println!(
+                "  {} [{}] ({}:{})",
+                change.symbol,
+                change.kind.as_str(),
+                change.file,
+                change.line
+            );
+        }
+        println!();
+    }
+    if !report.dead_code.is_empty() {
+        println!("Newly unreachable code ({}):", report.dead_code.len());
+        for symbol in &report.dead_code {
+            println!("  {} ({}:{})", symbol.name, symbol.file, symbol.line);
+        }
+        println!();
+    }
+    if !report.hot_symbols.is_empty() {
+        println!(
+            "Changed symbols with high fan-in ({}):",
+            report.hot_symbols.len()
+        );
+        for symbol in &report.hot_symbols {
+            println!(
+                "  {:>5} callers  {} ({}:{})",
+                symbol.fan_in, symbol.name, symbol.file, symbol.line
+            );
+        }
+        println!();
+    }
+
+    if !global.quiet {
+        println!(
+            "{} added, {} removed, {} changed symbols; {} impacted symbols, {} impacted test files",
+            report.added_symbols,
+            report.removed_symbols,
+            report.changed_symbols,
+            report.impacted_symbols,
+            report.test_files.len()
+        );
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_workflow_command_escaping() {
+        let annotation = Annotation {
+            level: "warning",
+            file: "src/a,b.go",
+            line: 0,
+            title: "Breaking API change: removed".to_string(),
+            message: "100% gone\nreally".to_string(),
+        };
+        assert_eq!(
+            workflow_command(&annotation),
+            "::warning file=src/a%2Cb.go,line=1,title=Breaking API change%3A removed::100%25 gone%0Areally"
+        );
+    }
+}
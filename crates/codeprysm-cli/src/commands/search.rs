@@ -1,12 +1,23 @@
-//! Search command - Semantic and keyword code search
+//! Search command - Semantic, keyword, fuzzy, and full-text code search
+
+use std::path::Path;
 
 use anyhow::{Context, Result};
 use clap::{Args, ValueEnum};
-use codeprysm_backend::{Backend, SearchOptions};
+use codeprysm_backend::{Backend, LocalBackend, SearchOptions};
+use codeprysm_config::{OutputConfig, OutputFormat as ConfiguredFormat};
+use codeprysm_core::analysis::{fuzzy_search, FuzzyOptions, SymbolMatch};
+use codeprysm_core::lazy::{TextIndex, TextKind};
+use codeprysm_core::AttributeFilter;
 
-use super::create_backend;
+#[cfg(unix)]
+use super::connect_daemon;
+use super::{create_backend, load_config, print_info, resolve_workspace};
 use crate::GlobalOptions;
 
+/// Results returned when neither `--limit` nor `output.limit` is set
+const DEFAULT_LIMIT: usize = 10;
+
 /// Search mode
 #[derive(Debug, Clone, Copy, ValueEnum)]
 pub enum SearchMode {
@@ -16,6 +27,8 @@ pub enum SearchMode {
     Semantic,
     /// Code pattern search optimized for code identifiers
     Code,
+    /// Fuzzy (camel-hump) symbol name matching on the local graph, no embeddings needed
+    Fuzzy,
 }
 
 impl SearchMode {
@@ -24,6 +37,7 @@ impl SearchMode {
             SearchMode::Hybrid => None,
             SearchMode::Semantic => Some("info"),
             SearchMode::Code => Some("code"),
+            SearchMode::Fuzzy => None,
         }
     }
 }
@@ -34,15 +48,25 @@ pub struct SearchArgs {
     /// Search query
     query: String,
 
-    /// Maximum number of results to return
-    #[arg(long, short = 'n', default_value = "10")]
-    limit: usize,
+    /// Maximum number of results to return (default: 10, or `output.limit`)
+    #[arg(long, short = 'n')]
+    limit: Option<usize>,
 
-    /// Search mode: hybrid, semantic, or code
+    /// Search mode: hybrid, semantic, code, or fuzzy
     #[arg(long, short = 'm', value_enum, default_value = "hybrid")]
     mode: SearchMode,
 
-    /// Filter by node types (e.g., Callable, Container)
+    /// Shorthand for --mode semantic (natural language queries)
+    #[arg(long, conflicts_with = "mode")]
+    semantic: bool,
+
+    /// Search doc comments, comments, and string literals in the local
+    /// full-text index instead of symbols
+    #[arg(long, conflicts_with_all = ["mode", "semantic"])]
+    text: bool,
+
+    /// Filter by node types (e.g., Callable, Container), or by doc, comment,
+    /// or string with --text
     #[arg(long, short = 't')]
     types: Vec<String>,
 
@@ -50,9 +74,9 @@ pub struct SearchArgs {
     #[arg(long)]
     min_score: Option<f32>,
 
-    /// Output format: text (default), json
-    #[arg(long, short = 'o', default_value = "text")]
-    output: OutputFormat,
+    /// Output format: text (default, or `output.format`), json
+    #[arg(long, short = 'o')]
+    output: Option<OutputFormat>,
 
     /// Include code snippets in output
     #[arg(long, short = 's')]
@@ -61,6 +85,15 @@ pub struct SearchArgs {
     /// Show file paths only (compact output)
     #[arg(long)]
     files_only: bool,
+
+    /// Also match doc comments (fuzzy mode)
+    #[arg(long)]
+    docs: bool,
+
+    /// Only symbols with this attribute, as KEY=VALUE or KEY (fuzzy mode,
+    /// repeatable)
+    #[arg(long = "attr", value_name = "KEY[=VALUE]")]
+    attributes: Vec<AttributeFilter>,
 }
 
 #[derive(Debug, Clone, Copy, ValueEnum)]
@@ -71,34 +104,94 @@ pub enum OutputFormat {
     Json,
 }
 
+impl SearchArgs {
+    /// Fill in the options not given on the command line from the configured
+    /// output defaults.
+    fn apply_defaults(&mut self, defaults: &OutputConfig) {
+        self.limit = self.limit.or(defaults.limit);
+        self.output = self.output.or(Some(match defaults.format {
+            ConfiguredFormat::Text => OutputFormat::Text,
+            ConfiguredFormat::Json => OutputFormat::Json,
+        }));
+    }
+
+    fn limit(&self) -> usize {
+        self.limit.unwrap_or(DEFAULT_LIMIT)
+    }
+
+    fn output(&self) -> OutputFormat {
+        self.output.unwrap_or(OutputFormat::Text)
+    }
+}
+
 /// Execute the search command
-pub async fn execute(args: SearchArgs, global: GlobalOptions) -> Result<()> {
+pub async fn execute(mut args: SearchArgs, global: GlobalOptions) -> Result<()> {
+    let workspace = resolve_workspace(&global).await?;
+    args.apply_defaults(&load_config(&global, &workspace)?.output);
+
+    let mode = if args.semantic {
+        SearchMode::Semantic
+    } else {
+        args.mode
+    };
+
+    if args.text {
+        if !args.attributes.is_empty() {
+            anyhow::bail!("--attr is only supported with --mode fuzzy");
+        }
+        let config = load_config(&global, &workspace)?;
+        return execute_text(args, &config.prism_dir(&workspace), global);
+    }
+
+    // Fuzzy search walks the whole graph, so it always runs in-process
+    if let SearchMode::Fuzzy = mode {
+        let backend = create_backend(&global).await?;
+        return execute_fuzzy(args, &backend, global).await;
+    }
+    if !args.attributes.is_empty() {
+        anyhow::bail!("--attr is only supported with --mode fuzzy");
+    }
+
+    #[cfg(unix)]
+    if let Some(daemon) = connect_daemon(&global).await? {
+        return execute_search(args, mode, &daemon, global).await;
+    }
     let backend = create_backend(&global).await?;
+    execute_search(args, mode, &*backend, global).await
+}
 
+/// Run a hybrid, semantic, or code search through a backend
+async fn execute_search<B: Backend>(
+    args: SearchArgs,
+    mode: SearchMode,
+    backend: &B,
+    global: GlobalOptions,
+) -> Result<()> {
     // Build search options
     let options = SearchOptions {
         node_types: args.types.clone(),
         file_patterns: vec![],
-        mode: args.mode.to_option_str().map(String::from),
+        mode: mode.to_option_str().map(String::from),
         include_snippets: args.snippets,
         min_score: args.min_score,
     };
 
     // Perform search
     let results = backend
-        .search(&args.query, args.limit, Some(options))
+        .search(&args.query, args.limit(), Some(options))
         .await
         .context("Search failed")?;
 
     if results.is_empty() {
-        if !global.quiet {
-            eprintln!("No results found for: {}", args.query);
-        }
+        print_info(
+            &format!("No results found for: {}", args.query),
+            global.quiet,
+        );
         return Ok(());
     }
 
     // Format output
-    match args.output {
+    match args.output() {
         OutputFormat::Json => {
             let json =
                 serde_json::to_string_pretty(&results).context("Failed to serialize results")?;
@@ -149,3 +242,151 @@ pub async fn execute(args: SearchArgs, global: GlobalOptions) -> Result<()> {
 
     Ok(())
 }
+
+/// Run a fuzzy symbol search over the local graph
+async fn execute_fuzzy(
+    args: SearchArgs,
+    backend: &LocalBackend,
+    global: GlobalOptions,
+) -> Result<()> {
+    let options = FuzzyOptions {
+        // Filter before truncating so --types and --attr do not starve the
+        // results
+        limit: if args.types.is_empty() && args.attributes.is_empty() {
+            args.limit()
+        } else {
+            usize::MAX
+        },
+        docs_root: args.docs.then(|| backend.workspace_root().to_path_buf()),
+    };
+
+    let mut matches = backend
+        .with_full_graph(|graph| {
+            let mut matches = fuzzy_search(graph, &args.query, &options);
+            matches.retain(|m| {
+                graph
+                    .get_node(&m.id)
+                    .is_some_and(|node| args.attributes.iter().all(|filter| filter.matches(node)))
+            });
+            Ok(matches)
+        })
+        .await
+        .context("Search failed")?;
+
+    if !args.types.is_empty() {
+        matches.retain(|m| {
+            args.types.iter().any(|t| {
+                t.eq_ignore_ascii_case(m.node_type.as_str())
+                    || m.kind.as_deref().is_some_and(|k| t.eq_ignore_ascii_case(k))
+            })
+        });
+    }
+    matches.truncate(args.limit());
+
+    if matches.is_empty() {
+        print_info(
+            &format!("No results found for: {}", args.query),
+            global.quiet,
+        );
+        return Ok(());
+    }
+
+    match args.output() {
+        OutputFormat::Json => {
+            let json =
+                serde_json::to_string_pretty(&matches).context("Failed to serialize results")?;
+            println!("{}", json);
+        }
+        OutputFormat::Text if args.files_only => {
+            for m in &matches {
+                println!("{}:{}", m.file, m.line);
+            }
+        }
+        OutputFormat::Text => {
+            for (i, m) in matches.iter().enumerate() {
+                println!("{}. {} ({})", i + 1, highlight(m), kind_label(m));
+                println!("   {}:{}-{}", m.file, m.line, m.end_line);
+                println!(
+                    "   Score: {}  Fan-in: {}{}",
+                    m.score,
+                    m.fan_in,
+                    if m.doc_match { "  (doc comment)" } else { "" }
+                );
+            }
+        }
+    }
+
+    Ok(())
+}
+
+/// Search the full-text index of docs, comments, and strings
+fn execute_text(args: SearchArgs, prism_dir: &Path, global: GlobalOptions) -> Result<()> {
+    if !TextIndex::exists(prism_dir) {
+        anyhow::bail!(
+            "No full-text index. Run 'codeprysm init' or 'codeprysm update' first.\n  Path: {}",
+            prism_dir.display()
+        );
+    }
+    let kinds = args
+        .types
+        .iter()
+        .map(|t| t.parse::<TextKind>())
+        .collect::<Result<Vec<_>, _>>()
+        .map_err(anyhow::Error::msg)?;
+    let matches = TextIndex::open(prism_dir)
+        .and_then(|index| index.search(&args.query, &kinds, args.limit()))
+        .context("Search failed")?;
+
+    if matches.is_empty() {
+        print_info(
+            &format!("No results found for: {}", args.query),
+            global.quiet,
+        );
+        return Ok(());
+    }
+
+    match args.output() {
+        OutputFormat::Json => {
+            let json =
+                serde_json::to_string_pretty(&matches).context("Failed to serialize results")?;
+            println!("{}", json);
+        }
+        OutputFormat::Text if args.files_only => {
+            for m in &matches {
+                println!("{}:{}", m.file, m.line);
+            }
+        }
+        OutputFormat::Text => {
+            for (i, m) in matches.iter().enumerate() {
+                println!("{}. {} ({})", i + 1, m.node_id, m.kind);
+                println!("   {}:{}-{}", m.file, m.line, m.end_line);
+                println!("   Score: {:.3}", m.score);
+                for line in m.snippet.lines().map(str::trim).take(5) {
+                    println!("   | {}", line);
+                }
+            }
+        }
+    }
+
+    Ok(())
+}
+
+/// Node kind, falling back to the node type
+fn kind_label(m: &SymbolMatch) -> &str {
+    m.kind.as_deref().unwrap_or(m.node_type.as_str())
+}
+
+/// Bracket the name characters matched by the pattern
+fn highlight(m: &SymbolMatch) -> String {
+    m.name
+        .chars()
+        .enumerate()
+        .map(|(i, c)| {
+            if m.positions.contains(&i) {
+                format!("[{}]", c)
+            } else {
+                c.to_string()
+            }
+        })
+        .collect()
+}
@@ -0,0 +1,129 @@
+//! Select-tests command - Go tests affected by a change, for CI
+//!
+//! Runs impact analysis (see `analyze impact`) on the changes since a base
+//! revision and prints the Go test packages and functions they can affect,
+//! as arguments for `go test`:
+//!
+//! ```bash
+//! go test $(codeprysm select-tests --since origin/main)
+//! ```
+//!
+//! The index should be up to date with the working tree, since changed lines
+//! are matched against it.
+
+use anyhow::{Context, Result};
+use clap::{Args, ValueEnum};
+use codeprysm_core::analysis::{
+    analyze_impact, parse_unified_diff, select_go_tests, ImpactOptions,
+};
+
+use super::diff::{git, resolve_commit};
+use super::{create_backend, print_info, resolve_workspace};
+use crate::GlobalOptions;
+
+/// Selection output format
+#[derive(Debug, Clone, Copy, PartialEq, Eq, ValueEnum)]
+pub enum SelectionFormat {
+    /// Arguments for `go test` on one line (`-run <pattern> <packages>`)
+    Go,
+    /// Package patterns, one per line
+    Packages,
+    /// JSON with the tests selected per package
+    Json,
+}
+
+/// Arguments for the select-tests command
+#[derive(Args, Debug)]
+pub struct SelectTestsArgs {
+    /// Select for changes since the merge base with this revision, committed
+    /// or not (default: uncommitted changes against HEAD)
+    #[arg(long, value_name = "REV")]
+    since: Option<String>,
+
+    /// Maximum number of dependency hops from a changed symbol
+    #[arg(long, short = 'd')]
+    depth: Option<usize>,
+
+    /// Output format
+    #[arg(long, short = 'f', value_enum, default_value = "go")]
+    format: SelectionFormat,
+}
+
+/// Execute the select-tests command
+pub async fn execute(args: SelectTestsArgs, global: GlobalOptions) -> Result<()> {
+    let workspace_path = resolve_workspace(&global).await?;
+
+    // Compare the working tree with where the branch forked
+    let base = match args.since {
+        Some(ref rev) => {
+            let commit = resolve_commit(&workspace_path, rev)?;
+            git(&workspace_path, &["merge-base", &commit, "HEAD"])
+                .with_context(|| format!("No common ancestor of {} and HEAD", rev))?
+                .trim()
+                .to_string()
+        }
+        None => "HEAD".to_string(),
+    };
+    let patch = git(
+        &workspace_path,
+        &["diff", "--unified=0", "--no-color", &base],
+    )?;
+
+    let changes = parse_unified_diff(&patch);
+    let options = ImpactOptions {
+        max_depth: args.depth,
+    };
+
+    let backend = create_backend(&global).await?;
+    let report = backend
+        .with_full_graph(|graph| Ok(analyze_impact(graph, &changes, &options)))
+        .await
+        .context("Failed to analyze impact")?;
+    let selection = select_go_tests(&report);
+
+    match args.format {
+        SelectionFormat::Go => {
+            let go_args = selection.go_test_args();
+            if !go_args.is_empty() {
+                println!("{}", go_args.join(" "));
+            }
+        }
+        SelectionFormat::Packages => {
+            if selection.all {
+                println!("./...");
+            }
+            for package in &selection.packages {
+                println!("{}", package.pattern());
+            }
+        }
+        SelectionFormat::Json => {
+            let output = serde_json::json!({
+                "base": base,
+                "changed_files": report.changed_files.len() + report.deleted_files.len(),
+                "all": selection.all,
+                "run": selection.run_pattern(),
+                "packages": selection.packages,
+            });
+            println!("{}", serde_json::to_string_pretty(&output)?);
+        }
+    }
+
+    // Summary on stderr, so stdout can be passed to go test as is
+    if !global.quiet && args.format != SelectionFormat::Json {
+        if selection.all {
+            print_info("Module files changed; selected all tests", false);
+        } else {
+            print_info(
+                &format!(
+                    "Selected {} test(s) in {} package(s) for {} changed file(s)",
+                    selection.test_count(),
+                    selection.packages.len(),
+                    report.changed_files.len() + report.deleted_files.len()
+                ),
+                false,
+            );
+        }
+    }
+
+    Ok(())
+}
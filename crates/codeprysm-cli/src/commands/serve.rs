@@ -0,0 +1,135 @@
+//! Serve command - Query services for other backends
+//!
+//! Serves the index over the network so services can query the graph with
+//! typed clients (gRPC) or plain HTTP (REST, GraphQL) instead of shelling out
+//! to the CLI. The index is reloaded when `codeprysm update` changes it; the servers
+//! run until interrupted.
+//!
+//! The `[server]` configuration can require API tokens, each with a read or
+//! admin scope and limited to some paths, and serve over TLS, optionally
+//! requiring client certificates.
+
+use std::net::SocketAddr;
+use std::sync::Arc;
+
+use anyhow::{bail, Context, Result};
+use clap::Args;
+use codeprysm_server::{serve_grpc, serve_grpc_tls, serve_http, serve_https};
+use tokio::net::TcpListener;
+use tokio::sync::watch;
+
+use super::{load_config, open_graph_store, print_info, resolve_workspace, server_tls};
+use crate::GlobalOptions;
+
+/// Arguments for the serve command
+#[derive(Args, Debug)]
+pub struct ServeArgs {
+    /// Serve the gRPC API (codeprysm.v1.GraphService) on this address
+    #[arg(
+        long,
+        value_name = "ADDR",
+        num_args = 0..=1,
+        default_missing_value = "127.0.0.1:50051"
+    )]
+    grpc: Option<SocketAddr>,
+
+    /// Serve the REST and GraphQL APIs on this address
+    #[arg(
+        long,
+        value_name = "ADDR",
+        num_args = 0..=1,
+        default_missing_value = "127.0.0.1:8080"
+    )]
+    http: Option<SocketAddr>,
+}
+
+/// Execute the serve command
+pub async fn execute(args: ServeArgs, global: GlobalOptions) -> Result<()> {
+    if args.grpc.is_none() && args.http.is_none() {
+        bail!("Nothing to serve. Pass --grpc and/or --http.");
+    }
+
+    let workspace_path = resolve_workspace(&global).await?;
+    let config = load_config(&global, &workspace_path)?;
+    let store = Arc::new(open_graph_store(&config, &workspace_path)?);
+    let tls = server_tls(&config, &workspace_path)?.map(Arc::new);
+    if store.auth().is_enabled() {
+        print_info(
+            &format!(
+                "Requiring API tokens ({} configured)",
+                config.server.tokens.len()
+            ),
+            global.quiet,
+        );
+    }
+    if tls
+        .as_ref()
+        .is_some_and(|tls| tls.requires_client_certificates())
+    {
+        print_info("Requiring client certificates", global.quiet);
+    }
+    let scheme = if tls.is_some() { "https" } else { "http" };
+
+    // Both servers stop on Ctrl-C
+    let (stop, stopped) = watch::channel(());
+    tokio::spawn(async move {
+        let _ = tokio::signal::ctrl_c().await;
+        let _ = stop.send(());
+    });
+    let shutdown = move || {
+        let mut stopped = stopped.clone();
+        async move {
+            let _ = stopped.changed().await;
+        }
+    };
+
+    let grpc = match args.grpc {
+        Some(addr) => {
+            let listener = bind(addr).await?;
+            print_info(
+                &format!("Serving gRPC on {}", listener.local_addr()?),
+                global.quiet,
+            );
+            let (store, tls, shutdown) = (Arc::clone(&store), tls.clone(), shutdown());
+            Some(tokio::spawn(async move {
+                match tls {
+                    Some(tls) => serve_grpc_tls(store, listener, &tls, shutdown).await,
+                    None => serve_grpc(store, listener, shutdown).await,
+                }
+            }))
+        }
+        None => None,
+    };
+    let http = match args.http {
+        Some(addr) => {
+            let listener = bind(addr).await?;
+            print_info(
+                &format!(
+                    "Serving REST and GraphQL on {}://{}",
+                    scheme,
+                    listener.local_addr()?
+                ),
+                global.quiet,
+            );
+            let (store, tls, shutdown) = (Arc::clone(&store), tls.clone(), shutdown());
+            Some(tokio::spawn(async move {
+                match tls {
+                    Some(tls) => serve_https(store, listener, &tls, shutdown).await,
+                    None => serve_http(store, listener, shutdown).await,
+                }
+            }))
+        }
+        None => None,
+    };
+
+    for server in [grpc, http].into_iter().flatten() {
+        server.await.context("Server task failed")??;
+    }
+    Ok(())
+}
+
+async fn bind(addr: SocketAddr) -> Result<TcpListener> {
+    TcpListener::bind(addr)
+        .await
+        .with_context(|| format!("Failed to bind {}", addr))
+}
@@ -0,0 +1,85 @@
+//! Shard command - Index one shard of a monorepo
+//!
+//! Builds the graph of a subset of a repository's directories, so large
+//! monorepos can be indexed across several machines or CI jobs. Shards are
+//! combined with `codeprysm merge`, which resolves references across them.
+
+use std::path::PathBuf;
+
+use anyhow::{Context, Result};
+use clap::Args;
+use codeprysm_core::builder::{BuilderConfig, GraphBuilder};
+use tracing::info;
+
+use super::{
+    attribute_rules, extraction_rules, file_policy, load_config, resolve_workspace, symbol_limits,
+    FileFilterArgs,
+};
+use crate::progress::BuildReporter;
+use crate::GlobalOptions;
+
+/// Arguments for the shard command
+#[derive(Args, Debug)]
+pub struct ShardArgs {
+    /// Directories of the shard, relative to the workspace root
+    #[arg(required = true)]
+    paths: Vec<PathBuf>,
+
+    /// Output file
+    #[arg(long, short = 'o', default_value = "shard.json")]
+    output: PathBuf,
+
+    /// Path to custom SCM queries directory
+    #[arg(long)]
+    queries: Option<PathBuf>,
+
+    #[command(flatten)]
+    filter: FileFilterArgs,
+}
+
+/// Execute the shard command
+pub async fn execute(args: ShardArgs, global: GlobalOptions) -> Result<()> {
+    let workspace_path = resolve_workspace(&global).await?;
+    let mut config = load_config(&global, &workspace_path)?;
+    args.filter.apply(&mut config.analysis);
+
+    let builder_config = BuilderConfig {
+        skip_data_nodes: false,
+        max_containment_depth: None,
+        max_files: None,
+        exclude_patterns: config.analysis.exclude_patterns.clone(),
+        include_patterns: config.analysis.include_patterns.clone(),
+        file_policy: file_policy(&config.analysis),
+        attributes: attribute_rules(&config),
+        go_type_check: None,
+        extraction: extraction_rules(&config.analysis, &workspace_path)?,
+        symbol_limits: symbol_limits(&config.analysis),
+    };
+    let reporter = BuildReporter::new("Building shard...", &global.log_format, global.quiet);
+    let mut builder = match &args.queries {
+        Some(queries_dir) => {
+            info!("Using custom queries from: {}", queries_dir.display());
+            GraphBuilder::with_config(queries_dir, builder_config)
+                .context("Failed to create graph builder")?
+        }
+        None => GraphBuilder::with_embedded_queries(builder_config),
+    }
+    .with_progress(reporter.callback());
+
+    let shard = builder
+        .build_shard(&workspace_path, &args.paths)
+        .context("Failed to build shard")?;
+    shard
+        .save(&args.output)
+        .with_context(|| format!("Failed to write {}", args.output.display()))?;
+
+    reporter.finish(&format!(
+        "Wrote shard to {} ({} files, {} nodes, {} unresolved references)",
+        args.output.display(),
+        shard.files().count(),
+        shard.node_count(),
+        shard.reference_count()
+    ));
+
+    Ok(())
+}
@@ -0,0 +1,105 @@
+//! Stats command - Report index size and quality
+//!
+//! Shows node and edge counts by kind and language, the share of references
+//! that resolved to an indexed definition, the packages with the most
+//! unresolved references, and the on-disk size of the index. Useful as a
+//! sanity check after indexing a new repository.
+
+use anyhow::{Context, Result};
+use clap::Args;
+use codeprysm_core::analysis::index_stats;
+
+use super::clean::{dir_size, format_size};
+use super::{create_backend, load_config, resolve_workspace};
+use crate::GlobalOptions;
+
+/// Arguments for the stats command
+#[derive(Args, Debug)]
+pub struct StatsArgs {
+    /// Number of packages with unresolved references to show
+    #[arg(long, default_value = "10")]
+    top: usize,
+
+    /// Output as JSON
+    #[arg(long)]
+    json: bool,
+}
+
+/// Execute the stats command
+pub async fn execute(args: StatsArgs, global: GlobalOptions) -> Result<()> {
+    let workspace_path = resolve_workspace(&global).await?;
+    let config = load_config(&global, &workspace_path)?;
+    let prism_dir = config.prism_dir(&workspace_path);
+
+    // The diff worktree cache is not part of the index
+    let diff_cache = prism_dir.join("diff");
+    let cache_size = if diff_cache.exists() {
+        dir_size(&diff_cache)?
+    } else {
+        0
+    };
+    let index_size = dir_size(&prism_dir)
+        .with_context(|| format!("Failed to measure {}", prism_dir.display()))?
+        .saturating_sub(cache_size);
+
+    let backend = create_backend(&global).await?;
+    let mut stats = backend
+        .with_full_graph(|graph| Ok(index_stats(graph)))
+        .await
+        .context("Failed to compute index statistics")?;
+    stats.packages.truncate(args.top);
+
+    if args.json {
+        let mut output = serde_json::to_value(&stats)?;
+        output["index_size_bytes"] = serde_json::json!(index_size);
+        println!("{}", serde_json::to_string_pretty(&output)?);
+        return Ok(());
+    }
+
+    println!("Index: {}", prism_dir.display());
+    println!("  Size:  {}", format_size(index_size));
+    println!("  Nodes: {}", stats.node_count);
+    println!("  Edges: {}", stats.edge_count);
+
+    println!("\nNodes by kind:");
+    for (kind, count) in &stats.nodes_by_kind {
+        println!("  {:>8}  {}", count, kind);
+    }
+
+    println!("\nEdges by type:");
+    for (edge_type, count) in &stats.edges_by_type {
+        println!("  {:>8}  {}", count, edge_type);
+    }
+
+    println!("\nLanguages:");
+    for (language, files) in &stats.files_by_language {
+        let nodes = stats.nodes_by_language.get(language).copied().unwrap_or(0);
+        println!("  {:<12} {:>6} files {:>8} nodes", language, files, nodes);
+    }
+
+    println!("\nReference resolution:");
+    match stats.resolution_rate {
+        Some(rate) => println!(
+            "  {:.1}% resolved ({} resolved, {} unresolved)",
+            rate, stats.resolved_refs, stats.unresolved_refs
+        ),
+        None => println!("  No reference counts recorded. Re-index to compute them."),
+    }
+
+    if !stats.packages.is_empty() {
+        println!("\nMost unresolved references:");
+        for package in &stats.packages {
+            let name = if package.package.is_empty() {
+                "."
+            } else {
+                package.package.as_str()
+            };
+            println!(
+                "  {:>6} unresolved {:>6} resolved  {}",
+                package.unresolved, package.resolved, name
+            );
+        }
+    }
+
+    Ok(())
+}
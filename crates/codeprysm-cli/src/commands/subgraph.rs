@@ -0,0 +1,136 @@
+//! Subgraph command - Export the neighborhood of a symbol
+//!
+//! Writes the ego graph around a symbol (nodes within a number of hops, and
+//! the edges among them) in any export format, for visualization tools or as
+//! bounded context for LLMs. A size budget keeps the output small: the
+//! symbol and the most connected nodes around it stay, and the rest are
+//! collapsed into aggregate nodes.
+
+use std::path::PathBuf;
+
+use anyhow::{Context, Result};
+use clap::{Args, ValueEnum};
+use codeprysm_core::analysis::{ego_graph, EdgeSelector, EgoDirection, EgoOptions};
+use codeprysm_core::{export_graph, ExportFormat};
+
+use super::query::resolve_unique;
+use super::{
+    attach_links, create_backend, load_config, load_provenance, parse_edge_selector,
+    report_sampling, resolve_workspace, ExportBudgetArgs, ExportFilterArgs,
+};
+use crate::GlobalOptions;
+
+/// Arguments for the subgraph command
+#[derive(Args, Debug)]
+pub struct SubgraphArgs {
+    /// Center symbol (node ID or name)
+    symbol: String,
+
+    /// Maximum number of hops from the symbol
+    #[arg(long, short = 'r', default_value = "2")]
+    radius: usize,
+
+    /// Edge types to follow, comma-separated; `calls` selects uses between
+    /// callables, `implements` types implementing interfaces by use or by
+    /// method set (default: all)
+    #[arg(long, short = 'e', value_delimiter = ',', value_parser = parse_edge_selector)]
+    edges: Vec<EdgeSelector>,
+
+    /// Direction to follow edges in
+    #[arg(long, short = 'd', value_enum, default_value = "both")]
+    direction: Direction,
+
+    /// Output format: json, dot, mermaid, or graphml
+    #[arg(long, short = 'f', default_value = "json", value_parser = parse_export_format)]
+    format: ExportFormat,
+
+    /// Write to a file instead of stdout
+    #[arg(long, short = 'o')]
+    output: Option<PathBuf>,
+
+    #[command(flatten)]
+    filter: ExportFilterArgs,
+
+    #[command(flatten)]
+    budget: ExportBudgetArgs,
+}
+
+#[derive(Debug, Clone, Copy, ValueEnum)]
+pub enum Direction {
+    /// Follow edges out of each node
+    Outgoing,
+    /// Follow edges into each node
+    Incoming,
+    /// Follow edges both ways
+    Both,
+}
+
+impl From<Direction> for EgoDirection {
+    fn from(direction: Direction) -> Self {
+        match direction {
+            Direction::Outgoing => EgoDirection::Outgoing,
+            Direction::Incoming => EgoDirection::Incoming,
+            Direction::Both => EgoDirection::Both,
+        }
+    }
+}
+
+/// Parse an export format argument
+pub(super) fn parse_export_format(s: &str) -> Result<ExportFormat, String> {
+    s.parse()
+}
+
+/// Execute the subgraph command
+pub async fn execute(args: SubgraphArgs, global: GlobalOptions) -> Result<()> {
+    let backend = create_backend(&global).await?;
+
+    let options = EgoOptions {
+        radius: args.radius,
+        edge_types: if args.edges.is_empty() {
+            None
+        } else {
+            Some(args.edges.clone())
+        },
+        direction: args.direction.into(),
+        max_nodes: None,
+    };
+
+    let filter = args.filter.filter();
+    let budget = args.budget.budget();
+    let (center, sampled) = backend
+        .with_full_graph(|graph| {
+            let center = resolve_unique(graph, &args.symbol)?;
+            let subgraph = filter.apply(&ego_graph(graph, &center, &options), graph);
+            let sampled = budget.apply(&subgraph, &[center.as_str()]);
+            Ok((center, sampled))
+        })
+        .await
+        .context("Failed to extract subgraph")?;
+    report_sampling(&sampled, &global);
+    let mut subgraph = sampled.graph;
+
+    let workspace_path = resolve_workspace(&global).await?;
+    let config = load_config(&global, &workspace_path)?;
+    let provenance = load_provenance(&config, &workspace_path);
+    attach_links(&mut subgraph, &config, &workspace_path, provenance.as_ref())?;
+    let rendered = export_graph(&subgraph, args.format, provenance.as_ref());
+
+    match args.output {
+        Some(ref path) => {
+            std::fs::write(path, &rendered)
+                .with_context(|| format!("Failed to write {}", path.display()))?;
+            if !global.quiet {
+                println!(
+                    "Wrote {} nodes and {} edges around '{}' to {}",
+                    subgraph.node_count(),
+                    subgraph.edge_count(),
+                    center,
+                    path.display()
+                );
+            }
+        }
+        None => print!("{}", rendered),
+    }
+
+    Ok(())
+}
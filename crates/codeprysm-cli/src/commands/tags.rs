@@ -0,0 +1,58 @@
+//! Tags command - Write a ctags or etags file
+//!
+//! Writes the indexed symbols as a tags file so Vim, Emacs, and other
+//! editors with built-in tags support get jump-to-definition without a
+//! language server.
+
+use std::path::PathBuf;
+
+use anyhow::{Context, Result};
+use clap::Args;
+use codeprysm_core::{write_tags, TagsFormat};
+
+use super::create_backend;
+use crate::GlobalOptions;
+
+/// Arguments for the tags command
+#[derive(Args, Debug)]
+pub struct TagsArgs {
+    /// Tags format: ctags (universal-ctags extended format) or etags (Emacs)
+    #[arg(long, short = 'f', default_value = "ctags", value_parser = parse_tags_format)]
+    format: TagsFormat,
+
+    /// Output file, or "-" for stdout (default: tags or TAGS in the workspace root)
+    #[arg(long, short = 'o')]
+    output: Option<PathBuf>,
+}
+
+/// Parse a tags format argument
+fn parse_tags_format(s: &str) -> Result<TagsFormat, String> {
+    s.parse()
+}
+
+/// Execute the tags command
+pub async fn execute(args: TagsArgs, global: GlobalOptions) -> Result<()> {
+    let backend = create_backend(&global).await?;
+    let root = backend.workspace_root().to_path_buf();
+
+    let tags = backend
+        .with_full_graph(|graph| Ok(write_tags(graph, &root, args.format)))
+        .await
+        .context("Failed to generate tags")?;
+
+    let path = match args.output {
+        Some(ref path) if path.as_os_str() == "-" => {
+            print!("{}", tags);
+            return Ok(());
+        }
+        Some(path) => path,
+        None => root.join(args.format.default_file_name()),
+    };
+
+    std::fs::write(&path, &tags).with_context(|| format!("Failed to write {}", path.display()))?;
+    if !global.quiet {
+        println!("Wrote {} tags to {}", args.format.as_str(), path.display());
+    }
+
+    Ok(())
+}
@@ -0,0 +1,149 @@
+//! Todos command - List TODO, FIXME, and HACK markers
+//!
+//! Reports the tech-debt markers recorded by the last build, with the symbol
+//! each one sits in and, in a git checkout, who last changed its line.
+
+use std::collections::BTreeMap;
+
+use anyhow::{Context, Result};
+use clap::Args;
+use codeprysm_core::lazy::LazyGraphManager;
+use codeprysm_core::MarkerKind;
+use serde::Serialize;
+
+use super::{load_config, resolve_workspace};
+use crate::GlobalOptions;
+
+/// Arguments for the todos command
+#[derive(Args, Debug)]
+pub struct TodosArgs {
+    /// Only show markers of this kind (todo, fixme, hack)
+    #[arg(long, short = 'k')]
+    kind: Option<MarkerKind>,
+
+    /// Only show markers last changed by this author
+    #[arg(long, short = 'a')]
+    author: Option<String>,
+
+    /// Only show markers in files under this path prefix
+    #[arg(long)]
+    file: Option<String>,
+
+    /// Output as JSON
+    #[arg(long)]
+    json: bool,
+}
+
+/// A marker as reported
+#[derive(Debug, Serialize)]
+struct TodoEntry {
+    kind: MarkerKind,
+    file: String,
+    line: usize,
+    text: String,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    author: Option<String>,
+    /// ID of the symbol or file containing the marker
+    #[serde(skip_serializing_if = "Option::is_none")]
+    parent: Option<String>,
+}
+
+/// Execute the todos command
+pub async fn execute(args: TodosArgs, global: GlobalOptions) -> Result<()> {
+    let workspace_path = resolve_workspace(&global).await?;
+    let config = load_config(&global, &workspace_path)?;
+    let prism_dir = config.prism_dir(&workspace_path);
+    if !prism_dir.join("manifest.json").exists() {
+        anyhow::bail!(
+            "No index found. Run 'codeprysm init' first.\n  Path: {}",
+            workspace_path.display()
+        );
+    }
+    let graph = LazyGraphManager::open(&prism_dir)
+        .and_then(|manager| manager.load_full_graph())
+        .context("Failed to load the graph")?;
+
+    let prefix = args.file.as_deref().map(|f| f.trim_start_matches("./"));
+    let mut entries: Vec<TodoEntry> = graph
+        .iter_nodes()
+        .filter(|node| node.is_marker())
+        .filter_map(|node| {
+            let kind = node.subtype.as_deref()?.parse::<MarkerKind>().ok()?;
+            Some(TodoEntry {
+                kind,
+                file: node.file.clone(),
+                line: node.line,
+                text: node.text.clone().unwrap_or_default(),
+                author: node.metadata.author.clone(),
+                parent: graph.parent(&node.id).map(|parent| parent.id.clone()),
+            })
+        })
+        .filter(|entry| args.kind.is_none_or(|kind| entry.kind == kind))
+        .filter(|entry| {
+            args.author.as_deref().is_none_or(|author| {
+                entry
+                    .author
+                    .as_deref()
+                    .is_some_and(|a| a.eq_ignore_ascii_case(author))
+            })
+        })
+        .filter(|entry| prefix.is_none_or(|prefix| entry.file.starts_with(prefix)))
+        .collect();
+    entries.sort_by(|a, b| (&a.file, a.line).cmp(&(&b.file, b.line)));
+
+    if args.json {
+        println!("{}", serde_json::to_string_pretty(&entries)?);
+        return Ok(());
+    }
+
+    if entries.is_empty() {
+        if !global.quiet {
+            println!("No markers found");
+        }
+        return Ok(());
+    }
+
+    for entry in &entries {
+        let author = entry
+            .author
+            .as_deref()
+            .map(|a| format!(" ({})", a))
+            .unwrap_or_default();
+        let parent = entry
+            .parent
+            .as_deref()
+            .filter(|parent| *parent != entry.file)
+            .and_then(|parent| graph.get_node(parent))
+            .map(|parent| format!(" in {}", parent.name))
+            .unwrap_or_default();
+        println!(
+            "{:<5} {}:{}{}{}: {}",
+            entry.kind, entry.file, entry.line, author, parent, entry.text
+        );
+    }
+
+    if !global.quiet {
+        let mut by_kind: BTreeMap<MarkerKind, usize> = BTreeMap::new();
+        let mut by_author: BTreeMap<&str, usize> = BTreeMap::new();
+        for entry in &entries {
+            *by_kind.entry(entry.kind).or_default() += 1;
+            *by_author
+                .entry(entry.author.as_deref().unwrap_or("(unknown)"))
+                .or_default() += 1;
+        }
+        let kinds: Vec<String> = by_kind
+            .iter()
+            .map(|(kind, count)| format!("{} {}", count, kind))
+            .collect();
+        println!("\n{} marker(s): {}", entries.len(), kinds.join(", "));
+        if by_author.len() > 1 || !by_author.contains_key("(unknown)") {
+            let mut authors: Vec<(&str, usize)> = by_author.into_iter().collect();
+            authors.sort_by(|a, b| b.1.cmp(&a.1).then(a.0.cmp(b.0)));
+            for (author, count) in authors {
+                println!("  {:<24} {}", author, count);
+            }
+        }
+    }
+
+    Ok(())
+}
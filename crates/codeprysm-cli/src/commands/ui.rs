@@ -0,0 +1,90 @@
+//! UI command - Explore the graph in a browser
+//!
+//! Serves a local web app for browsing packages, searching symbols, and
+//! expanding call and implementation neighborhoods in a force-directed view.
+//! The app runs on the REST API (see `serve --http`), which is served with
+//! it; the index is reloaded when `codeprysm update` changes it. The
+//! `[server]` tokens and TLS settings apply as for `codeprysm serve`.
+
+use std::net::SocketAddr;
+use std::process::{Command, Stdio};
+use std::sync::Arc;
+
+use anyhow::{Context, Result};
+use clap::Args;
+use codeprysm_server::{serve_ui, serve_ui_tls};
+use tokio::net::TcpListener;
+
+use super::{
+    load_config, open_graph_store, print_info, print_warning, resolve_workspace, server_tls,
+};
+use crate::GlobalOptions;
+
+/// Arguments for the ui command
+#[derive(Args, Debug)]
+pub struct UiArgs {
+    /// Address to serve the explorer on
+    #[arg(long, value_name = "ADDR", default_value = "127.0.0.1:7070")]
+    addr: SocketAddr,
+
+    /// Open the explorer in the default browser
+    #[arg(long)]
+    open: bool,
+}
+
+/// Execute the ui command
+pub async fn execute(args: UiArgs, global: GlobalOptions) -> Result<()> {
+    let workspace_path = resolve_workspace(&global).await?;
+    let config = load_config(&global, &workspace_path)?;
+    let store = Arc::new(open_graph_store(&config, &workspace_path)?);
+    let tls = server_tls(&config, &workspace_path)?;
+
+    let listener = TcpListener::bind(args.addr)
+        .await
+        .with_context(|| format!("Failed to bind {}", args.addr))?;
+    let scheme = if tls.is_some() { "https" } else { "http" };
+    let url = format!("{}://{}/", scheme, listener.local_addr()?);
+    print_info(
+        &format!("Explorer running at {} (Ctrl-C to stop)", url),
+        global.quiet,
+    );
+    if store.auth().is_enabled() {
+        print_info(
+            &format!("API tokens required: open {}#token=<token>", url),
+            global.quiet,
+        );
+    }
+    if args.open {
+        if let Err(e) = open_browser(&url) {
+            print_warning(&format!("Failed to open a browser: {}", e));
+        }
+    }
+
+    let shutdown = async {
+        let _ = tokio::signal::ctrl_c().await;
+    };
+    match tls {
+        Some(ref tls) => serve_ui_tls(store, listener, tls, shutdown).await?,
+        None => serve_ui(store, listener, shutdown).await?,
+    }
+    Ok(())
+}
+
+/// Open `url` with the platform's default handler
+fn open_browser(url: &str) -> std::io::Result<()> {
+    let mut command = if cfg!(target_os = "macos") {
+        Command::new("open")
+    } else if cfg!(windows) {
+        let mut command = Command::new("cmd");
+        command.args(["/C", "start", ""]);
+        command
+    } else {
+        Command::new("xdg-open")
+    };
+    command
+        .arg(url)
+        .stdout(Stdio::null())
+        .stderr(Stdio::null())
+        .spawn()
+        .map(drop)
+}
@@ -5,18 +5,29 @@ use std::path::PathBuf;
 use anyhow::{Context, Result};
 use clap::Args;
 use codeprysm_backend::Backend;
+use codeprysm_core::analysis::{apply_risk, risk_report, RiskOptions};
 use codeprysm_core::builder::{BuilderConfig, GraphBuilder};
 use codeprysm_core::lazy::partitioner::GraphPartitioner;
+use codeprysm_core::lazy::WriteLock;
+use codeprysm_core::{attach_symbol_authors, CoverProfile, ExtractionCache, GitHistory};
 use tracing::info;
 
-use super::{create_backend, load_config, resolve_workspace};
-use crate::progress::{finish_spinner, spinner};
+use super::metrics::unix_now;
+use super::plugin::run_extractors;
+use super::{
+    attribute_rules, create_backend, extraction_rules, file_policy, go_type_check, load_config,
+    resolve_workspace, save_diagnostics, save_provenance, symbol_limits, sync_text_index,
+    update_aliases, write_graph_store, FileFilterArgs,
+};
+use crate::progress::{finish_spinner, spinner, BuildReporter};
+use crate::summarize::summarize_symbols;
 use crate::GlobalOptions;
 
 /// Arguments for the update command
 #[derive(Args, Debug)]
 pub struct UpdateArgs {
-    /// Force full rebuild instead of incremental update
+    /// Force full rebuild, re-parsing every file instead of reusing the
+    /// extraction cache
     #[arg(long, short = 'f')]
     force: bool,
 
@@ -31,12 +42,41 @@ pub struct UpdateArgs {
     /// Path to custom SCM queries directory
     #[arg(long)]
     queries: Option<PathBuf>,
+
+    /// Go cover profile (from `go test -coverprofile`) to annotate functions with
+    #[arg(long, value_name = "PROFILE")]
+    coverage: Option<PathBuf>,
+
+    /// Record per-function churn (commits, authors, last change) and risk
+    /// scores from git history
+    #[arg(long)]
+    churn: bool,
+
+    /// Record the primary author and last modifier of functions and types
+    /// from git blame
+    #[arg(long)]
+    blame: bool,
+
+    /// Only set cached symbol summaries; do not ask the `[summaries]` model
+    /// for new ones
+    #[arg(long)]
+    no_summaries: bool,
+
+    /// Resolve Go references with the Go type checker (`codeprysm-gotypes`)
+    /// as well as by name; slower, but accurate where names are ambiguous
+    #[arg(long)]
+    go_types: bool,
+
+    #[command(flatten)]
+    filter: FileFilterArgs,
 }
 
 /// Execute the update command
 pub async fn execute(args: UpdateArgs, global: GlobalOptions) -> Result<()> {
     let workspace_path = resolve_workspace(&global).await?;
-    let config = load_config(&global, &workspace_path)?;
+    let mut config = load_config(&global, &workspace_path)?;
+    args.filter.apply(&mut config.analysis);
+    config.analysis.go_type_check |= args.go_types;
     let prism_dir = config.prism_dir(&workspace_path);
     let manifest_path = prism_dir.join("manifest.json");
 
@@ -66,8 +106,31 @@ pub async fn execute(args: UpdateArgs, global: GlobalOptions) -> Result<()> {
         max_containment_depth: None,
         max_files: None,
         exclude_patterns: config.analysis.exclude_patterns.clone(),
+        include_patterns: config.analysis.include_patterns.clone(),
+        file_policy: file_policy(&config.analysis),
+        attributes: attribute_rules(&config),
+        go_type_check: go_type_check(&config.analysis),
+        extraction: extraction_rules(&config.analysis, &workspace_path)?,
+        symbol_limits: symbol_limits(&config.analysis),
+    };
+
+    // Reuse the extraction of unchanged files unless rebuilding from scratch
+    let cache_dir = ExtractionCache::locate(&workspace_path, &prism_dir);
+    let cache = if args.force {
+        ExtractionCache::new(&cache_dir)
+    } else {
+        ExtractionCache::load(&cache_dir)
     };
 
+    // Only changed files are re-parsed; references are resolved across the
+    // whole graph again so USES edges into changed files stay correct
+    let msg = if args.force {
+        "Rebuilding code graph..."
+    } else {
+        "Updating code graph..."
+    };
+    let reporter = BuildReporter::new(msg, &global.log_format, global.quiet);
+
     // Create builder
     let mut builder = match &args.queries {
         Some(queries_dir) => {
@@ -79,30 +142,21 @@ pub async fn execute(args: UpdateArgs, global: GlobalOptions) -> Result<()> {
             info!("Using embedded queries");
             GraphBuilder::with_embedded_queries(builder_config)
         }
-    };
-
-    // For now, we do a full rebuild. Incremental updates would use the merkle tree.
-    // TODO: Implement true incremental updates using codeprysm_core::incremental
-    let msg = if args.force {
-        "Rebuilding code graph..."
-    } else {
-        "Updating code graph..."
-    };
-
-    let pb = spinner(msg, global.quiet);
+    }
+    .with_cache(cache)
+    .with_progress(reporter.callback());
 
-    let (graph, roots) = builder
+    let (mut graph, roots) = builder
         .build_from_workspace(&workspace_path)
         .context("Failed to build code graph")?;
 
-    finish_spinner(
-        pb,
-        &format!(
-            "Built code graph ({} root{})",
-            roots.len(),
-            if roots.len() == 1 { "" } else { "s" }
-        ),
-    );
+    reporter.finish(&format!(
+        "Built code graph ({} root{}, {} file(s) parsed, {} unchanged)",
+        roots.len(),
+        if roots.len() == 1 { "" } else { "s" },
+        builder.stats().files_parsed,
+        builder.stats().files_cached
+    ));
 
     if !global.quiet && global.verbose {
         println!("  Discovered roots:");
@@ -116,17 +170,82 @@ pub async fn execute(args: UpdateArgs, global: GlobalOptions) -> Result<()> {
         }
     }
 
+    // Annotate functions with test coverage
+    if let Some(ref path) = args.coverage {
+        let profile = CoverProfile::load(path)
+            .with_context(|| format!("Failed to load coverage: {}", path.display()))?;
+        let annotated = profile.apply(&mut graph);
+        if !global.quiet {
+            println!(
+                "  Annotated {} function(s) with coverage from {} file(s)",
+                annotated,
+                profile.files.len()
+            );
+        }
+    }
+
+    // Annotate functions with churn and risk
+    if args.churn {
+        let history =
+            GitHistory::load(&workspace_path, None).context("Failed to read git history")?;
+        let options = RiskOptions {
+            now: unix_now(),
+            ..Default::default()
+        };
+        let report = risk_report(&graph, &history.symbol_churn(&graph), &options);
+        let annotated = apply_risk(&mut graph, &report);
+        if !global.quiet {
+            println!(
+                "  Annotated {} function(s) with churn from {} commit(s)",
+                annotated,
+                history.commits.len()
+            );
+        }
+    }
+
+    // Attribute functions and types to their authors
+    if args.blame {
+        let pb = spinner("Running git blame...", global.quiet);
+        let attributed = attach_symbol_authors(&workspace_path, &mut graph);
+        finish_spinner(
+            pb,
+            &format!("Attributed {} symbol(s) from git blame", attributed),
+        );
+    }
+
+    // Add nodes and edges from extractor plugins
+    run_extractors(&mut graph, &workspace_path, &config, global.quiet);
+
+    // Summarize symbols with the configured model
+    summarize_symbols(
+        &mut graph,
+        &workspace_path,
+        &prism_dir,
+        &config,
+        args.no_summaries,
+        global.quiet,
+    )
+    .await?;
+
     // Derive root name
     let root_name = workspace_path
         .file_name()
         .map(|s| s.to_string_lossy().to_string())
         .unwrap_or_else(|| "workspace".to_string());
 
+    // Follow symbols renamed or moved since the last build, holding the
+    // index until the graph is saved
+    let lock = WriteLock::acquire(&prism_dir).context("Failed to lock the index")?;
+    update_aliases(&mut graph, &prism_dir, &lock, global.quiet)?;
+
     // Save the graph
     let pb = spinner("Saving graph...", global.quiet);
 
-    let (_, stats) = GraphPartitioner::partition_with_stats(&graph, &prism_dir, Some(&root_name))
-        .context("Failed to save graph")?;
+    let (_, stats) =
+        GraphPartitioner::partition_locked(&graph, &prism_dir, Some(&root_name), &lock)
+            .context("Failed to save graph")?;
+    write_graph_store(&graph, &config, &workspace_path)?;
+    drop(lock);
 
     finish_spinner(
         pb,
@@ -136,6 +255,17 @@ pub async fn execute(args: UpdateArgs, global: GlobalOptions) -> Result<()> {
         ),
     );
 
+    builder
+        .stats()
+        .save(&prism_dir)
+        .context("Failed to save build statistics")?;
+    save_diagnostics(&builder, &prism_dir, global.quiet)?;
+    sync_text_index(&graph, &prism_dir, &workspace_path, global.quiet)?;
+    save_provenance(&config, &workspace_path)?;
+    if let Some(cache) = builder.cache() {
+        cache.save().context("Failed to save extraction cache")?;
+    }
+
     // Reindex if requested
     if args.reindex {
         let pb = spinner("Reindexing for semantic search...", global.quiet);
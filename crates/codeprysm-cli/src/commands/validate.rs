@@ -0,0 +1,131 @@
+//! Validate command - Check the consistency of an index
+//!
+//! Reads the raw nodes and edges of a workspace index or a graph archive and
+//! checks referential integrity, ID uniqueness, schema compatibility, and
+//! span sanity, reporting each problem with the nodes, file, and line
+//! involved.
+
+use std::path::{Path, PathBuf};
+
+use anyhow::{Context, Result};
+use clap::Args;
+use codeprysm_core::lazy::LazyGraphManager;
+use codeprysm_core::{validate, GraphArchive, IndexContents, Provenance, Severity};
+
+use super::{load_config, resolve_workspace};
+use crate::GlobalOptions;
+
+/// Arguments for the validate command
+#[derive(Args, Debug)]
+pub struct ValidateArgs {
+    /// Index to validate: a workspace, its index directory, or a graph
+    /// archive (default: the current workspace)
+    index: Option<PathBuf>,
+
+    /// Problems shown per check (0 = all)
+    #[arg(long, default_value = "10")]
+    limit: usize,
+
+    /// Output as JSON
+    #[arg(long)]
+    json: bool,
+}
+
+/// Execute the validate command
+pub async fn execute(args: ValidateArgs, global: GlobalOptions) -> Result<()> {
+    let contents = match args.index {
+        Some(ref path) if path.is_file() => read_archive(path)?,
+        Some(ref path) if path.join("manifest.json").exists() => read_index(path)?,
+        Some(ref path) => {
+            let config = load_config(&global, path)?;
+            read_index(&config.prism_dir(path))?
+        }
+        None => {
+            let workspace_path = resolve_workspace(&global).await?;
+            let config = load_config(&global, &workspace_path)?;
+            read_index(&config.prism_dir(&workspace_path))?
+        }
+    };
+    let report = validate(&contents);
+
+    if args.json {
+        println!("{}", serde_json::to_string_pretty(&report)?);
+    } else if !global.quiet {
+        println!(
+            "Validated {} node(s) and {} edge(s) (schema {})",
+            report.nodes,
+            report.edges,
+            report.schema_version.as_deref().unwrap_or("unknown")
+        );
+        for (check, problems) in report.by_check() {
+            println!("\n{} ({}):", check.as_str(), problems.len());
+            let shown = if args.limit == 0 {
+                problems.len()
+            } else {
+                args.limit.min(problems.len())
+            };
+            for problem in &problems[..shown] {
+                let location = match (&problem.file, problem.line) {
+                    (Some(file), Some(line)) => format!(" [{}:{}]", file, line),
+                    (Some(file), None) => format!(" [{}]", file),
+                    _ => String::new(),
+                };
+                println!("  {:<7} {}{}", problem.severity, problem.message, location);
+            }
+            if shown < problems.len() {
+                println!("  ... and {} more", problems.len() - shown);
+            }
+        }
+        if report.problems.is_empty() {
+            println!("\n✓ No problems found");
+        } else {
+            println!(
+                "\n{} error(s), {} warning(s), {} info",
+                report.count(Severity::Error),
+                report.count(Severity::Warning),
+                report.count(Severity::Info)
+            );
+        }
+    }
+
+    if !report.is_valid() {
+        anyhow::bail!(
+            "Index is inconsistent: {} error(s)",
+            report.count(Severity::Error)
+        );
+    }
+    Ok(())
+}
+
+/// Read a workspace index, with the schema version of its provenance
+fn read_index(prism_dir: &Path) -> Result<IndexContents> {
+    if !prism_dir.join("manifest.json").exists() {
+        anyhow::bail!(
+            "No index found. Run 'codeprysm init' first.\n  Path: {}",
+            prism_dir.display()
+        );
+    }
+    let (nodes, edges) = LazyGraphManager::open(prism_dir)
+        .and_then(|manager| manager.read_contents())
+        .with_context(|| format!("Failed to read the index at {}", prism_dir.display()))?;
+    Ok(IndexContents {
+        schema_version: Provenance::load(prism_dir).map(|p| p.schema_version),
+        nodes,
+        edges,
+    })
+}
+
+/// Read a graph archive, with the schema version of its index
+fn read_archive(path: &Path) -> Result<IndexContents> {
+    let mut archive =
+        GraphArchive::open(path).with_context(|| format!("Failed to open {}", path.display()))?;
+    let schema_version = archive.index().schema_version.clone();
+    let (nodes, edges) = archive
+        .read_contents()
+        .with_context(|| format!("Failed to read {}", path.display()))?;
+    Ok(IndexContents {
+        schema_version: Some(schema_version),
+        nodes,
+        edges,
+    })
+}
@@ -0,0 +1,330 @@
+//! Watch command - Keep the index up to date as files change
+//!
+//! Watches the workspace for filesystem changes and applies them to the index
+//! incrementally: only changed files are reparsed, and references into and out
+//! of them are re-resolved. Editors and agents using the index (`mcp`, `lsp`,
+//! `serve`, `daemon`) pick up each update without a restart.
+//!
+//! Webhooks configured under `[[webhooks]]` are notified after each update
+//! in which a watched symbol's signature changed, it gained a caller, or it
+//! was removed.
+
+use std::collections::BTreeSet;
+use std::path::{Path, PathBuf};
+use std::time::{Duration, Instant};
+
+use anyhow::{bail, Context, Result};
+use clap::Args;
+use codeprysm_config::WebhookConfig;
+use codeprysm_core::analysis::{
+    change_events, snapshot_symbols, ChangeEvent, ChangeEventKind, SymbolFilter, SymbolSnapshot,
+};
+use codeprysm_core::builder::BuilderConfig;
+use codeprysm_core::{ExclusionFilter, IncrementalUpdater, PetCodeGraph, UpdateResult};
+use notify::{Event, EventKind, RecursiveMode, Watcher};
+use tokio::sync::mpsc;
+
+use super::{
+    attribute_rules, extraction_rules, file_policy, load_config, print_info, print_warning,
+    resolve_workspace, symbol_limits, write_graph_store, FileFilterArgs,
+};
+use crate::GlobalOptions;
+
+/// Arguments for the watch command
+#[derive(Args, Debug)]
+pub struct WatchArgs {
+    /// Milliseconds to wait for changes to settle before updating
+    #[arg(long, value_name = "MS", default_value = "300")]
+    debounce: u64,
+
+    /// Path to custom SCM queries directory
+    #[arg(long)]
+    queries: Option<PathBuf>,
+
+    #[command(flatten)]
+    filter: FileFilterArgs,
+}
+
+/// Execute the watch command
+pub async fn execute(args: WatchArgs, global: GlobalOptions) -> Result<()> {
+    let workspace_path = resolve_workspace(&global).await?.canonicalize()?;
+    let mut config = load_config(&global, &workspace_path)?;
+    args.filter.apply(&mut config.analysis);
+    let prism_dir = config.prism_dir(&workspace_path);
+    if !prism_dir.join("manifest.json").exists() {
+        bail!(
+            "Workspace not initialized. Run 'codeprysm init' first.\n  Path: {}",
+            workspace_path.display()
+        );
+    }
+    // Resolve symlinks so event paths can be matched against it
+    let prism_dir = prism_dir.canonicalize().unwrap_or(prism_dir);
+
+    let builder_config = BuilderConfig {
+        exclude_patterns: config.analysis.exclude_patterns.clone(),
+        include_patterns: config.analysis.include_patterns.clone(),
+        file_policy: file_policy(&config.analysis),
+        attributes: attribute_rules(&config),
+        extraction: extraction_rules(&config.analysis, &workspace_path)?,
+        symbol_limits: symbol_limits(&config.analysis),
+        ..BuilderConfig::default()
+    };
+    let mut updater = match &args.queries {
+        Some(queries_dir) => IncrementalUpdater::with_config(
+            &workspace_path,
+            &prism_dir,
+            queries_dir,
+            ExclusionFilter::default(),
+            builder_config,
+        ),
+        None => IncrementalUpdater::with_embedded_queries(
+            &workspace_path,
+            &prism_dir,
+            ExclusionFilter::default(),
+            builder_config,
+        ),
+    }
+    .context("Failed to create incremental updater")?;
+
+    updater
+        .load_graph_state()
+        .context("Failed to load the index")?;
+    // Node IDs in multi-root indexes are relative to each root, not the workspace
+    if updater
+        .graph()
+        .is_some_and(|graph| graph.iter_nodes().any(|n| n.is_workspace()))
+    {
+        bail!("Watch mode supports single-root workspaces only. Use 'codeprysm update' instead.");
+    }
+
+    // Catch up with changes made while nothing was watching
+    let start = Instant::now();
+    let result = updater
+        .apply_file_changes(&[String::new()])
+        .context("Failed to sync the index")?;
+    report(&result, start.elapsed(), global.quiet);
+    if let Some(graph) = updater.graph() {
+        write_graph_store(graph, &config, &workspace_path)?;
+    }
+
+    let mut webhooks = config
+        .webhooks
+        .iter()
+        .map(Webhook::new)
+        .collect::<Result<Vec<_>>>()?;
+    if let Some(graph) = updater.graph() {
+        for webhook in &mut webhooks {
+            webhook.snapshot = snapshot_symbols(graph, &workspace_path, &webhook.filter);
+        }
+    }
+    let client = reqwest::Client::builder()
+        .timeout(WEBHOOK_TIMEOUT)
+        .build()
+        .context("Failed to create HTTP client")?;
+
+    let (tx, mut rx) = mpsc::unbounded_channel::<Event>();
+    let mut watcher = notify::recommended_watcher(move |event: notify::Result<Event>| {
+        if let Ok(event) = event {
+            let _ = tx.send(event);
+        }
+    })
+    .context("Failed to create file watcher")?;
+    watcher
+        .watch(&workspace_path, RecursiveMode::Recursive)
+        .with_context(|| format!("Failed to watch {}", workspace_path.display()))?;
+
+    print_info(
+        &format!(
+            "Watching {} for changes (Ctrl-C to stop)",
+            workspace_path.display()
+        ),
+        global.quiet,
+    );
+
+    let debounce = Duration::from_millis(args.debounce);
+    loop {
+        let first = tokio::select! {
+            event = rx.recv() => match event {
+                Some(event) => event,
+                None => break,
+            },
+            _ = tokio::signal::ctrl_c() => break,
+        };
+
+        // Editors save in bursts (temp file, rename, chmod); wait for quiet
+        let mut paths = BTreeSet::new();
+        collect_paths(&first, &workspace_path, &prism_dir, &mut paths);
+        while let Ok(Some(event)) = tokio::time::timeout(debounce, rx.recv()).await {
+            collect_paths(&event, &workspace_path, &prism_dir, &mut paths);
+        }
+        if paths.is_empty() {
+            continue;
+        }
+
+        let start = Instant::now();
+        let paths: Vec<String> = paths.into_iter().collect();
+        match updater.apply_file_changes(&paths) {
+            Ok(result) => {
+                report(&result, start.elapsed(), global.quiet);
+                if let Some(graph) = updater.graph().filter(|_| result.has_changes()) {
+                    if let Err(e) = write_graph_store(graph, &config, &workspace_path) {
+                        print_warning(&format!("{:#}", e));
+                    }
+                    for webhook in &mut webhooks {
+                        webhook.notify(graph, &workspace_path, &client);
+                    }
+                }
+            }
+            Err(e) => print_warning(&format!("Update failed: {}", e)),
+        }
+    }
+
+    print_info("Stopped watching", global.quiet);
+    Ok(())
+}
+
+/// How long to wait for a webhook endpoint to respond
+const WEBHOOK_TIMEOUT: Duration = Duration::from_secs(10);
+
+/// A configured webhook and the last recorded state of its symbols
+struct Webhook {
+    url: String,
+    filter: SymbolFilter,
+    events: BTreeSet<ChangeEventKind>,
+    snapshot: SymbolSnapshot,
+}
+
+impl Webhook {
+    fn new(config: &WebhookConfig) -> Result<Self> {
+        if config.url.is_empty() {
+            bail!("Webhook is missing a url");
+        }
+        let filter = SymbolFilter::new(&config.symbols, &config.packages)
+            .with_context(|| format!("Invalid symbol pattern for webhook {}", config.url))?;
+        let events: BTreeSet<ChangeEventKind> = if config.events.is_empty() {
+            ChangeEventKind::ALL.into_iter().collect()
+        } else {
+            config
+                .events
+                .iter()
+                .map(|e| e.parse().map_err(anyhow::Error::msg))
+                .collect::<Result<_>>()
+                .with_context(|| format!("Invalid events for webhook {}", config.url))?
+        };
+        Ok(Self {
+            url: config.url.clone(),
+            filter,
+            events,
+            snapshot: SymbolSnapshot::new(),
+        })
+    }
+
+    /// Record the new state of the watched symbols and POST any events since
+    /// the last update. Delivery runs in the background.
+    fn notify(&mut self, graph: &PetCodeGraph, workspace: &Path, client: &reqwest::Client) {
+        let snapshot = snapshot_symbols(graph, workspace, &self.filter);
+        let events: Vec<ChangeEvent> = change_events(&self.snapshot, &snapshot)
+            .into_iter()
+            .filter(|e| self.events.contains(&e.kind))
+            .collect();
+        self.snapshot = snapshot;
+        if events.is_empty() {
+            return;
+        }
+
+        let body = serde_json::json!({
+            "workspace": workspace,
+            "events": events,
+        });
+        let request = client.post(&self.url).json(&body);
+        let url = self.url.clone();
+        tokio::spawn(async move {
+            let result = request.send().await.and_then(|r| r.error_for_status());
+            if let Err(e) = result {
+                print_warning(&format!("Webhook {} failed: {}", url, e));
+            }
+        });
+    }
+}
+
+/// Add the workspace-relative paths an event touches, skipping the index
+/// directory and events that do not change content.
+fn collect_paths(event: &Event, workspace: &Path, prism_dir: &Path, paths: &mut BTreeSet<String>) {
+    if matches!(event.kind, EventKind::Access(_)) {
+        return;
+    }
+    for path in &event.paths {
+        if path.starts_with(prism_dir) {
+            continue;
+        }
+        if let Ok(rel_path) = path.strip_prefix(workspace) {
+            let rel_path = rel_path.to_string_lossy().replace('\\', "/");
+            if !rel_path.is_empty() {
+                paths.insert(rel_path);
+            }
+        }
+    }
+}
+
+/// Print a one-line summary of an update
+fn report(result: &UpdateResult, elapsed: Duration, quiet: bool) {
+    if !result.has_changes() {
+        return;
+    }
+    let changes = &result.changes;
+    print_info(
+        &format!(
+            "Updated index: {} added, {} modified, {} deleted ({} ms)",
+            changes.added.len(),
+            changes.modified.len(),
+            changes.deleted.len(),
+            elapsed.as_millis()
+        ),
+        quiet,
+    );
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use notify::event::{AccessKind, ModifyKind};
+
+    #[test]
+    fn test_collect_paths() {
+        let workspace = Path::new("/repo");
+        let prism_dir = Path::new("/repo/.codeprysm");
+        let mut paths = BTreeSet::new();
+
+        let event = Event::new(EventKind::Modify(ModifyKind::Any))
+            .add_path(PathBuf::from("/repo/src/main.go"))
+            .add_path(PathBuf::from("/repo/.codeprysm/manifest.json"))
+            .add_path(PathBuf::from("/elsewhere/x.go"));
+        collect_paths(&event, workspace, prism_dir, &mut paths);
+        assert_eq!(paths.into_iter().collect::<Vec<_>>(), vec!["src/main.go"]);
+
+        let mut paths = BTreeSet::new();
+        let access = Event::new(EventKind::Access(AccessKind::Any))
+            .add_path(PathBuf::from("/repo/src/main.go"));
+        collect_paths(&access, workspace, prism_dir, &mut paths);
+        assert!(paths.is_empty());
+    }
+
+    #[test]
+    fn test_webhook_config() {
+        let mut config = WebhookConfig {
+            url: "http://localhost:9000/hook".to_string(),
+            packages: vec!["pkg/api".to_string()],
+            ..Default::default()
+        };
+        let webhook = Webhook::new(&config).unwrap();
+        assert_eq!(webhook.events.len(), ChangeEventKind::ALL.len());
+
+        config.events = vec!["caller_added".to_string()];
+        let webhook = Webhook::new(&config).unwrap();
+        assert!(webhook.events.contains(&ChangeEventKind::CallerAdded));
+        assert!(!webhook.events.contains(&ChangeEventKind::Removed));
+
+        config.events = vec!["renamed".to_string()];
+        assert!(Webhook::new(&config).is_err());
+    }
+}
@@ -24,10 +24,20 @@ use std::path::PathBuf;
 use anyhow::Result;
 use clap::{Args, Parser, Subcommand};
 use tracing::Level;
-use tracing_subscriber::FmtSubscriber;
 
 mod commands;
 mod progress;
+mod report;
+mod summarize;
+mod telemetry;
+
+/// Counts heap usage for the reports of `codeprysm bench`
+#[cfg(feature = "bench-alloc")]
+#[global_allocator]
+static ALLOC: codeprysm_core::bench::CountingAllocator = codeprysm_core::bench::CountingAllocator;
+
+/// Qdrant URL used when neither a flag nor the configuration sets one
+const DEFAULT_QDRANT_URL: &str = "http://localhost:6334";
 
 /// CodePrism - Semantic code search and graph analysis
 #[derive(Parser, Debug)]
@@ -61,12 +71,38 @@ struct GlobalOptions {
     #[arg(long, short = 'q', global = true)]
     quiet: bool,
 
+    /// Log level, overall and per subsystem (parser, resolver, exporter),
+    /// e.g. "debug" or "warn,resolver=debug"; overrides -v and -q
+    #[arg(
+        long,
+        global = true,
+        env = "CODEPRYSM_LOG_LEVEL",
+        value_parser = parse_log_level
+    )]
+    log_level: Option<telemetry::LogLevel>,
+
+    /// Export OpenTelemetry traces of indexing over OTLP (endpoint from
+    /// OTEL_EXPORTER_OTLP_ENDPOINT, default http://localhost:4317)
+    #[arg(long, global = true, env = "CODEPRYSM_OTEL")]
+    otel: bool,
+
+    /// Log and progress output format (text, json); json writes one object
+    /// per line to stderr, including build progress events
+    #[arg(
+        long,
+        global = true,
+        env = "CODEPRYSM_LOG_FORMAT",
+        default_value = "text",
+        value_parser = parse_log_format
+    )]
+    log_format: codeprysm_config::LogFormat,
+
     /// Qdrant server URL
     #[arg(
         long,
         global = true,
         env = "CODEPRYSM_QDRANT_URL",
-        default_value = "http://localhost:6334"
+        default_value = DEFAULT_QDRANT_URL
     )]
     qdrant_url: String,
 
@@ -75,6 +111,17 @@ struct GlobalOptions {
     embedding_provider: Option<codeprysm_config::EmbeddingProviderType>,
 }
 
+/// Parse log format from string
+fn parse_log_format(s: &str) -> Result<codeprysm_config::LogFormat, String> {
+    s.parse()
+        .map_err(|e: codeprysm_config::ConfigError| e.to_string())
+}
+
+/// Parse log levels from string
+fn parse_log_level(s: &str) -> Result<telemetry::LogLevel, String> {
+    s.parse()
+}
+
 /// Parse embedding provider from string
 fn parse_embedding_provider(s: &str) -> Result<codeprysm_config::EmbeddingProviderType, String> {
     s.parse()
@@ -85,7 +132,8 @@ impl GlobalOptions {
     /// Convert global options to config overrides
     pub fn to_config_overrides(&self) -> codeprysm_config::ConfigOverrides {
         codeprysm_config::ConfigOverrides {
-            qdrant_url: Some(self.qdrant_url.clone()),
+            // Only a URL other than the default overrides the configured one
+            qdrant_url: (self.qdrant_url != DEFAULT_QDRANT_URL).then(|| self.qdrant_url.clone()),
             embedding_provider: self.embedding_provider,
             ..Default::default()
         }
@@ -100,16 +148,105 @@ enum Commands {
     /// Update the code graph incrementally
     Update(commands::update::UpdateArgs),
 
+    /// Watch for file changes and keep the code graph up to date
+    Watch(commands::watch::WatchArgs),
+
+    /// Index one shard (a set of directories) of a monorepo
+    Shard(commands::shard::ShardArgs),
+
+    /// Merge shards into the workspace graph, resolving references across them
+    Merge(commands::merge::MergeArgs),
+
+    /// Federate several repositories into one graph, linking Go imports across them
+    Federate(commands::federate::FederateArgs),
+
     /// Search the codebase semantically or by pattern
     Search(commands::search::SearchArgs),
 
     /// Graph query and navigation commands
     Graph(commands::graph::GraphArgs),
 
+    /// Whole-graph structural queries (paths, interface implementers)
+    #[command(subcommand)]
+    Query(commands::query::QueryCommand),
+
+    /// Whole-graph code analyses (dead code, impact, clones)
+    #[command(subcommand)]
+    Analyze(commands::analyze::AnalyzeCommand),
+
+    /// Code quality metrics (function complexity, package coupling, hotspots)
+    #[command(subcommand)]
+    Metrics(commands::metrics::MetricsCommand),
+
+    /// Record graph snapshots and chart coupling, API surface, and dead code over time
+    #[command(subcommand)]
+    History(commands::history::HistoryCommand),
+
+    /// List configured plugins and run their analyses
+    #[command(subcommand)]
+    Plugin(commands::plugin::PluginCommand),
+
+    /// Follow symbol IDs changed by renames and moves
+    Aliases(commands::aliases::AliasesArgs),
+
+    /// List the exported API surface
+    Api(commands::api::ApiArgs),
+
+    /// Check pending changes against policies (for git hooks)
+    Check(commands::check::CheckArgs),
+
+    /// Compare the code graph between two git revisions
+    Diff(commands::diff::DiffArgs),
+
+    /// Classify API changes between two versions as breaking or compatible
+    Apidiff(commands::apidiff::ApiDiffArgs),
+
+    /// Generate a symbol-level changelog between two versions
+    Changelog(commands::changelog::ChangelogArgs),
+
+    /// Report a pull request's impact, with GitHub annotations for CI
+    Review(commands::review::ReviewArgs),
+
+    /// Select the Go tests affected by a change, as arguments for go test
+    SelectTests(commands::select_tests::SelectTestsArgs),
+
+    /// Export the neighborhood of a symbol (ego graph)
+    Subgraph(commands::subgraph::SubgraphArgs),
+
+    /// Write a ctags or etags file for editors
+    Tags(commands::tags::TagsArgs),
+
+    /// Create and read compressed single-file graph archives (.cpz)
+    #[command(subcommand)]
+    Archive(commands::archive::ArchiveCommand),
+
+    /// Show index statistics and resolution quality
+    Stats(commands::stats::StatsArgs),
+
+    /// Measure parse, extract, and resolve throughput by language and file size
+    Bench(commands::bench::BenchArgs),
+
+    /// Start a language server backed by the code graph
+    Lsp(commands::lsp::LspArgs),
+
+    /// Serve graph queries over the network (gRPC, REST, GraphQL)
+    Serve(commands::serve::ServeArgs),
+
+    /// Explore the code graph in a local web UI
+    Ui(commands::ui::UiArgs),
+
+    /// Keep the index loaded in a background daemon shared by later commands
+    #[command(subcommand)]
+    Daemon(commands::daemon::DaemonCommand),
+
     /// Component management and analysis
     #[command(subcommand)]
     Components(commands::components::ComponentsCommand),
 
+    /// Monorepo projects: list, cross-project dependencies, and export
+    #[command(subcommand)]
+    Projects(commands::projects::ProjectsCommand),
+
     /// Workspace management commands
     #[command(subcommand)]
     Workspace(commands::workspace::WorkspaceCommand),
@@ -120,6 +257,22 @@ enum Commands {
     /// Comprehensive health check with recommendations
     Doctor(commands::doctor::DoctorArgs),
 
+    /// Upgrade an index written by an older version in place
+    Migrate(commands::migrate::MigrateArgs),
+
+    /// Check an index for dangling edges, duplicate IDs, and malformed spans
+    Validate(commands::validate::ValidateArgs),
+
+    /// Show where the last build could not fully index the workspace
+    Diagnostics(commands::diagnostics::DiagnosticsArgs),
+
+    /// Inspect how files are parsed and extracted
+    #[command(subcommand)]
+    Debug(commands::debug::DebugCommand),
+
+    /// List TODO, FIXME, and HACK comments with their authors
+    Todos(commands::todos::TodosArgs),
+
     /// Remove CodePrysm data (local and/or backend)
     Clean(commands::clean::CleanArgs),
 
@@ -139,39 +292,92 @@ enum Commands {
 async fn main() -> Result<()> {
     let cli = Cli::parse();
 
-    // Set up logging based on verbosity
-    let log_level = if cli.global.quiet {
+    // Set up logging based on verbosity, unless --log-level sets the level
+    let verbosity = if cli.global.quiet {
         Level::ERROR
     } else if cli.global.verbose {
         Level::DEBUG
     } else {
         Level::INFO
     };
+    let log_level = cli
+        .global
+        .log_level
+        .clone()
+        .unwrap_or_default()
+        .with_default_level(verbosity);
 
     // MCP command handles its own tracing setup (needs ansi=false for JSON-RPC protocol,
     // and must gracefully handle pre-existing subscribers when launched by Claude Code)
-    if !matches!(cli.command, Commands::Mcp(_)) {
-        let subscriber = FmtSubscriber::builder()
-            .with_max_level(log_level)
-            .with_writer(std::io::stderr)
-            .with_ansi(true)
-            .finish();
-        tracing::subscriber::set_global_default(subscriber)?;
-    }
+    let telemetry = if matches!(cli.command, Commands::Mcp(_)) {
+        None
+    } else {
+        telemetry::init(&log_level, &cli.global.log_format, cli.global.otel)?
+    };
 
     // Execute the command
-    match cli.command {
+    let result = match cli.command {
         Commands::Init(args) => commands::init::execute(args, cli.global).await,
         Commands::Update(args) => commands::update::execute(args, cli.global).await,
+        Commands::Watch(args) => commands::watch::execute(args, cli.global).await,
+        Commands::Shard(args) => commands::shard::execute(args, cli.global).await,
+        Commands::Merge(args) => commands::merge::execute(args, cli.global).await,
+        Commands::Federate(args) => commands::federate::execute(args, cli.global).await,
         Commands::Search(args) => commands::search::execute(args, cli.global).await,
         Commands::Graph(args) => commands::graph::execute(args, cli.global).await,
+        Commands::Query(cmd) => commands::query::execute(cmd, cli.global).await,
+        Commands::Analyze(cmd) => commands::analyze::execute(cmd, cli.global).await,
+        Commands::Metrics(cmd) => commands::metrics::execute(cmd, cli.global).await,
+        Commands::History(cmd) => commands::history::execute(cmd, cli.global).await,
+        Commands::Plugin(cmd) => commands::plugin::execute(cmd, cli.global).await,
+        Commands::Aliases(args) => commands::aliases::execute(args, cli.global).await,
+        Commands::Api(args) => commands::api::execute(args, cli.global).await,
+        Commands::Check(args) => commands::check::execute(args, cli.global).await,
+        Commands::Diff(args) => commands::diff::execute(args, cli.global).await,
+        Commands::Apidiff(args) => commands::apidiff::execute(args, cli.global).await,
+        Commands::Changelog(args) => commands::changelog::execute(args, cli.global).await,
+        Commands::Review(args) => commands::review::execute(args, cli.global).await,
+        Commands::SelectTests(args) => commands::select_tests::execute(args, cli.global).await,
+        Commands::Subgraph(args) => commands::subgraph::execute(args, cli.global).await,
+        Commands::Tags(args) => commands::tags::execute(args, cli.global).await,
+        Commands::Archive(cmd) => commands::archive::execute(cmd, cli.global).await,
+        Commands::Stats(args) => commands::stats::execute(args, cli.global).await,
+        Commands::Bench(args) => commands::bench::execute(args, cli.global).await,
+        Commands::Lsp(args) => commands::lsp::execute(args, cli.global).await,
+        Commands::Serve(args) => commands::serve::execute(args, cli.global).await,
+        Commands::Ui(args) => commands::ui::execute(args, cli.global).await,
+        Commands::Daemon(cmd) => commands::daemon::execute(cmd, cli.global).await,
         Commands::Components(cmd) => commands::components::execute(cmd, cli.global).await,
+        Commands::Projects(cmd) => commands::projects::execute(cmd, cli.global).await,
         Commands::Workspace(cmd) => commands::workspace::execute(cmd, cli.global).await,
         Commands::Status(args) => commands::status::execute(args, cli.global).await,
         Commands::Doctor(args) => commands::doctor::execute(args, cli.global).await,
+        Commands::Migrate(args) => commands::migrate::execute(args, cli.global).await,
+        Commands::Validate(args) => commands::validate::execute(args, cli.global).await,
+        Commands::Diagnostics(args) => commands::diagnostics::execute(args, cli.global).await,
+        Commands::Debug(cmd) => commands::debug::execute(cmd, cli.global).await,
+        Commands::Todos(args) => commands::todos::execute(args, cli.global).await,
         Commands::Clean(args) => commands::clean::execute(args, cli.global).await,
         Commands::Backend(cmd) => commands::backend::execute(cmd, cli.global).await,
         Commands::Config(cmd) => commands::config::execute(cmd, cli.global).await,
         Commands::Mcp(args) => commands::mcp::execute(args, cli.global).await,
+    };
+
+    if let Some(telemetry) = telemetry {
+        telemetry.shutdown().await;
+    }
+
+    // Policy failures get their own exit code so CI can tell them from errors
+    if let Err(ref e) = result {
+        if let Some(violation) = e.downcast_ref::<report::PolicyViolation>() {
+            commands::print_error(&violation.to_string());
+            std::process::exit(report::POLICY_EXIT_CODE);
+        }
+        // Keep stderr all JSON rather than printing the error's debug form
+        if telemetry::json_logs() {
+            commands::print_error(&format!("{:#}", e));
+            std::process::exit(1);
+        }
     }
+    result
 }
@@ -2,9 +2,21 @@
 //!
 //! Provides spinners and progress bars for long-running operations.
 //! All progress output is suppressed when --quiet flag is set.
+//!
+//! Graph builds report their progress per phase: on a terminal as a bar with
+//! files done, total, and ETA, and with `--log-format json` as structured
+//! `progress` log events, so CI logs show how far a long index run has got.
+
+use std::sync::{Arc, Mutex};
+use std::time::{Duration, Instant};
 
+use codeprysm_config::LogFormat;
+use codeprysm_core::builder::{BuildPhase, BuildProgress};
 use indicatif::{ProgressBar, ProgressStyle};
-use std::time::Duration;
+use tracing::info;
+
+/// Minimum time between JSON progress events within a phase
+const PROGRESS_EVENT_INTERVAL: Duration = Duration::from_secs(1);
 
 /// Create a spinner with a message
 pub fn spinner(message: &str, quiet: bool) -> Option<ProgressBar> {
@@ -108,6 +120,147 @@ pub fn finish_progress(pb: Option<ProgressBar>) {
     }
 }
 
+/// Reports the progress of a graph build.
+///
+/// Clones share the same output, so one can be moved into the builder's
+/// progress callback while the command keeps another to finish with.
+#[derive(Clone)]
+pub struct BuildReporter {
+    inner: Arc<ReporterState>,
+}
+
+struct ReporterState {
+    /// Bar on the terminal; `None` when quiet or logging JSON
+    bar: Option<ProgressBar>,
+    /// Emit progress log events instead of drawing a bar
+    json: bool,
+    message: String,
+    phase: Mutex<Option<PhaseClock>>,
+}
+
+/// When the current phase started and last emitted an event
+struct PhaseClock {
+    phase: BuildPhase,
+    started: Instant,
+    last_event: Instant,
+}
+
+impl BuildReporter {
+    /// Start reporting a build described by `message`.
+    ///
+    /// Shows a spinner until the builder reports its first phase. Nothing
+    /// is drawn when stderr is not a terminal.
+    pub fn new(message: &str, format: &LogFormat, quiet: bool) -> Self {
+        let json = !quiet && *format == LogFormat::Json;
+        let bar = if json { None } else { spinner(message, quiet) };
+        Self {
+            inner: Arc::new(ReporterState {
+                bar,
+                json,
+                message: message.trim_end_matches('.').to_string(),
+                phase: Mutex::new(None),
+            }),
+        }
+    }
+
+    /// Callback passing build progress to this reporter, for
+    /// [`GraphBuilder::with_progress`](codeprysm_core::GraphBuilder::with_progress)
+    pub fn callback(&self) -> impl Fn(&BuildProgress) + Send + Sync + 'static {
+        let reporter = self.clone();
+        move |progress| reporter.report(progress)
+    }
+
+    /// Show the progress of the current phase
+    pub fn report(&self, progress: &BuildProgress) {
+        let state = &self.inner;
+        let now = Instant::now();
+        let mut clock = state.phase.lock().unwrap_or_else(|e| e.into_inner());
+        let new_phase = clock.as_ref().is_none_or(|c| c.phase != progress.phase);
+        if new_phase {
+            *clock = Some(PhaseClock {
+                phase: progress.phase,
+                started: now,
+                last_event: now,
+            });
+            if let Some(bar) = &state.bar {
+                bar.set_style(build_bar_style());
+                bar.reset();
+                bar.set_message(format!(
+                    "{} ({})",
+                    state.message,
+                    phase_label(progress.phase)
+                ));
+            }
+        }
+        let Some(clock) = clock.as_mut() else {
+            return;
+        };
+
+        if let Some(bar) = &state.bar {
+            bar.set_length(progress.total);
+            bar.set_position(progress.done);
+        }
+
+        let finished = progress.done >= progress.total;
+        if state.json
+            && (new_phase || finished || now - clock.last_event >= PROGRESS_EVENT_INTERVAL)
+        {
+            clock.last_event = now;
+            let elapsed = now - clock.started;
+            info!(
+                event = "progress",
+                phase = progress.phase.as_str(),
+                done = progress.done,
+                total = progress.total,
+                elapsed_ms = elapsed.as_millis() as u64,
+                eta_ms = eta(elapsed, progress.done, progress.total).map(|d| d.as_millis() as u64),
+                "{} {}/{}",
+                phase_label(progress.phase),
+                progress.done,
+                progress.total
+            );
+        }
+    }
+
+    /// Replace the bar with a success message
+    pub fn finish(self, message: &str) {
+        match &self.inner.bar {
+            Some(bar) => finish_spinner(Some(bar.clone()), message),
+            None if self.inner.json => info!(event = "finished", "{}", message),
+            None => {}
+        }
+    }
+}
+
+/// Style of the per-phase build progress bar
+fn build_bar_style() -> ProgressStyle {
+    ProgressStyle::default_bar()
+        .template("{spinner:.cyan} {msg} [{bar:30.cyan/blue}] {pos}/{len} (ETA {eta})")
+        .expect("Invalid build progress template")
+        .tick_chars("⠋⠙⠹⠸⠼⠴⠦⠧⠇⠏")
+        .progress_chars("█▓░")
+}
+
+/// What a build is doing in a phase
+fn phase_label(phase: BuildPhase) -> &'static str {
+    match phase {
+        BuildPhase::Parse => "parsing files",
+        BuildPhase::Resolve => "resolving references",
+        BuildPhase::Clones => "linking clones",
+    }
+}
+
+/// Time left in a phase, assuming the rest goes at the rate so far.
+///
+/// `None` until some work is done.
+fn eta(elapsed: Duration, done: u64, total: u64) -> Option<Duration> {
+    if done == 0 {
+        return None;
+    }
+    let remaining = total.saturating_sub(done);
+    Some(elapsed.mul_f64(remaining as f64 / done as f64))
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
@@ -146,4 +299,29 @@ mod tests {
         // Should not panic
         finish_progress(None);
     }
+
+    #[test]
+    fn test_eta() {
+        assert_eq!(eta(Duration::from_secs(10), 0, 100), None);
+        assert_eq!(
+            eta(Duration::from_secs(10), 25, 100),
+            Some(Duration::from_secs(30))
+        );
+        assert_eq!(eta(Duration::from_secs(10), 100, 100), Some(Duration::ZERO));
+    }
+
+    #[test]
+    fn test_build_reporter_quiet_draws_nothing() {
+        let reporter = BuildReporter::new("Building code graph...", &LogFormat::Json, true);
+        assert!(reporter.inner.bar.is_none());
+        assert!(!reporter.inner.json);
+
+        let report = reporter.callback();
+        report(&BuildProgress {
+            phase: BuildPhase::Parse,
+            done: 1,
+            total: 2,
+        });
+        reporter.finish("done");
+    }
 }
@@ -0,0 +1,349 @@
+//! Machine-readable analysis results and policy exit codes
+//!
+//! Analysis commands (`analyze *`, `metrics *`) print JSON results with
+//! `--output json`. Every result object carries the schema version, the
+//! command that produced it, and the metrics the command measured, so CI
+//! scripts can read the same fields from every command.
+//!
+//! `--fail-on` takes comma-separated conditions on those metrics, such as
+//! `dead-code>0,complexity>25`. When a condition holds, the command exits
+//! with [`POLICY_EXIT_CODE`]. Conditions on metrics a command does not
+//! measure are ignored, so one policy can be passed to every command.
+
+use std::fmt;
+use std::str::FromStr;
+
+use anyhow::Result;
+use clap::{Args, ValueEnum};
+use serde::Serialize;
+use serde_json::Value;
+
+/// Version of the JSON result schema, bumped on incompatible changes
+pub const RESULT_SCHEMA_VERSION: u32 = 1;
+
+/// Exit code of a command whose results violate a `--fail-on` condition
+pub const POLICY_EXIT_CODE: i32 = 3;
+
+/// Metrics that `--fail-on` conditions can refer to
+pub const METRICS: &[&str] = &[
+    "dead-code",
+    "impacted",
+    "impacted-tests",
+    "clones",
+    "duplicate-interfaces",
+    "coverage",
+    "untested",
+    "cycles",
+    "undeclared",
+    "unused",
+    "license-conflicts",
+    "unused-exports",
+    "leaks",
+    "complexity",
+    "cognitive",
+    "distance",
+    "instability",
+    "flagged",
+    "risk",
+];
+
+/// Output format of commands printing text or JSON
+#[derive(Debug, Clone, Copy, PartialEq, Eq, ValueEnum)]
+pub enum ResultFormat {
+    /// Human-readable text
+    Text,
+    /// JSON result
+    Json,
+}
+
+/// Output format arguments for commands printing text or JSON
+#[derive(Args, Debug, Clone)]
+pub struct OutputArgs {
+    /// Output format
+    #[arg(long, value_enum, default_value = "text")]
+    output: ResultFormat,
+
+    /// Output as JSON (same as `--output json`)
+    #[arg(long)]
+    json: bool,
+}
+
+impl OutputArgs {
+    /// Whether results are printed as JSON
+    pub fn is_json(&self) -> bool {
+        self.json || self.output == ResultFormat::Json
+    }
+}
+
+/// Comparison in a `--fail-on` condition
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum Comparison {
+    /// `>`
+    Greater,
+    /// `>=`
+    GreaterOrEqual,
+    /// `<`
+    Less,
+    /// `<=`
+    LessOrEqual,
+    /// `=`
+    Equal,
+}
+
+impl Comparison {
+    /// Operators; where several match at the same position the longest wins
+    const OPERATORS: [(&'static str, Comparison); 5] = [
+        (">=", Comparison::GreaterOrEqual),
+        ("<=", Comparison::LessOrEqual),
+        (">", Comparison::Greater),
+        ("<", Comparison::Less),
+        ("=", Comparison::Equal),
+    ];
+
+    fn holds(self, value: f64, threshold: f64) -> bool {
+        match self {
+            Self::Greater => value > threshold,
+            Self::GreaterOrEqual => value >= threshold,
+            Self::Less => value < threshold,
+            Self::LessOrEqual => value <= threshold,
+            Self::Equal => value == threshold,
+        }
+    }
+
+    fn as_str(self) -> &'static str {
+        match self {
+            Self::Greater => ">",
+            Self::GreaterOrEqual => ">=",
+            Self::Less => "<",
+            Self::LessOrEqual => "<=",
+            Self::Equal => "=",
+        }
+    }
+}
+
+/// A condition on a metric that fails the command when it holds, such as
+/// `dead-code>0`
+#[derive(Debug, Clone, PartialEq)]
+pub struct FailCondition {
+    metric: String,
+    comparison: Comparison,
+    threshold: f64,
+}
+
+impl FromStr for FailCondition {
+    type Err = String;
+
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        let (index, operator, comparison) = Comparison::OPERATORS
+            .iter()
+            .filter_map(|(op, cmp)| s.find(op).map(|i| (i, *op, *cmp)))
+            .min_by_key(|(i, op, _)| (*i, std::cmp::Reverse(op.len())))
+            .ok_or_else(|| format!("Missing comparison in '{}' (e.g. dead-code>0)", s))?;
+
+        let metric = s[..index].trim();
+        if !METRICS.contains(&metric) {
+            return Err(format!(
+                "Unknown metric: '{}'. Valid metrics: {}",
+                metric,
+                METRICS.join(", ")
+            ));
+        }
+        let value = s[index + operator.len()..].trim();
+        let threshold = value
+            .parse()
+            .map_err(|_| format!("Invalid threshold '{}' in '{}'", value, s))?;
+
+        Ok(Self {
+            metric: metric.to_string(),
+            comparison,
+            threshold,
+        })
+    }
+}
+
+impl fmt::Display for FailCondition {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        write!(
+            f,
+            "{}{}{}",
+            self.metric,
+            self.comparison.as_str(),
+            self.threshold
+        )
+    }
+}
+
+/// Policy arguments shared by analysis commands
+#[derive(Args, Debug, Clone)]
+pub struct PolicyArgs {
+    /// Exit with code 3 when a condition on the results holds, e.g.
+    /// "dead-code>0,complexity>25"; conditions on metrics this command does
+    /// not measure are ignored
+    #[arg(
+        long,
+        value_name = "CONDITIONS",
+        value_delimiter = ',',
+        env = "CODEPRYSM_FAIL_ON"
+    )]
+    fail_on: Vec<FailCondition>,
+}
+
+impl PolicyArgs {
+    /// Check the metrics a command measured against the conditions
+    pub fn check(&self, metrics: &[(&'static str, f64)]) -> PolicyCheck {
+        let violations = self
+            .fail_on
+            .iter()
+            .filter_map(|condition| {
+                let (_, value) = metrics.iter().find(|(name, _)| *name == condition.metric)?;
+                condition
+                    .comparison
+                    .holds(*value, condition.threshold)
+                    .then(|| Violation {
+                        metric: condition.metric.clone(),
+                        value: *value,
+                        condition: condition.to_string(),
+                    })
+            })
+            .collect();
+        PolicyCheck {
+            conditions: self.fail_on.iter().map(ToString::to_string).collect(),
+            metrics: metrics.to_vec(),
+            violations,
+        }
+    }
+}
+
+/// A `--fail-on` condition that held
+#[derive(Debug, Clone, PartialEq, Serialize)]
+pub struct Violation {
+    /// Metric the condition is on
+    pub metric: String,
+    /// Measured value
+    pub value: f64,
+    /// The condition, as normalized from `--fail-on`
+    pub condition: String,
+}
+
+/// Metrics of a command's results checked against `--fail-on`
+#[derive(Debug)]
+pub struct PolicyCheck {
+    conditions: Vec<String>,
+    metrics: Vec<(&'static str, f64)>,
+    violations: Vec<Violation>,
+}
+
+impl PolicyCheck {
+    /// Print a JSON result of `command`, adding the schema version, the
+    /// command, the measured metrics, and the policy outcome.
+    ///
+    /// Results that are not objects are printed unchanged.
+    pub fn print_json(&self, command: &str, mut result: Value) -> Result<()> {
+        if let Value::Object(ref mut fields) = result {
+            let metrics = self
+                .metrics
+                .iter()
+                .map(|(name, value)| (name.to_string(), metric_value(*value)))
+                .collect();
+            fields.insert("schema_version".to_string(), RESULT_SCHEMA_VERSION.into());
+            fields.insert("command".to_string(), command.into());
+            fields.insert("metrics".to_string(), Value::Object(metrics));
+            fields.insert(
+                "policy".to_string(),
+                serde_json::json!({
+                    "passed": self.violations.is_empty(),
+                    "conditions": self.conditions,
+                    "violations": self.violations,
+                }),
+            );
+        }
+        println!("{}", serde_json::to_string_pretty(&result)?);
+        Ok(())
+    }
+
+    /// Fail with a [`PolicyViolation`] if any condition held
+    pub fn enforce(self) -> Result<()> {
+        if self.violations.is_empty() {
+            return Ok(());
+        }
+        Err(PolicyViolation(self.violations).into())
+    }
+}
+
+/// Metric values are counts except for percentages and ratios; keep counts
+/// integral in JSON
+fn metric_value(value: f64) -> Value {
+    if value.fract() == 0.0 {
+        (value as i64).into()
+    } else {
+        value.into()
+    }
+}
+
+/// Results violated `--fail-on` conditions; exits with [`POLICY_EXIT_CODE`]
+#[derive(Debug)]
+pub struct PolicyViolation(Vec<Violation>);
+
+impl fmt::Display for PolicyViolation {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        let violations: Vec<String> = self
+            .0
+            .iter()
+            .map(|v| format!("{} = {} ({})", v.metric, v.value, v.condition))
+            .collect();
+        write!(f, "Policy failed: {}", violations.join("; "))
+    }
+}
+
+impl std::error::Error for PolicyViolation {}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn policy(conditions: &str) -> PolicyArgs {
+        PolicyArgs {
+            fail_on: conditions.split(',').map(|c| c.parse().unwrap()).collect(),
+        }
+    }
+
+    #[test]
+    fn test_parse_fail_condition() {
+        let condition: FailCondition = "complexity>=25".parse().unwrap();
+        assert_eq!(condition.metric, "complexity");
+        assert_eq!(condition.comparison, Comparison::GreaterOrEqual);
+        assert_eq!(condition.threshold, 25.0);
+        assert_eq!(condition.to_string(), "complexity>=25");
+
+        let condition: FailCondition = " coverage < 62.5 ".parse().unwrap();
+        assert_eq!(condition.comparison, Comparison::Less);
+        assert_eq!(condition.threshold, 62.5);
+
+        assert!("dead-code".parse::<FailCondition>().is_err());
+        assert!("dead_code>0".parse::<FailCondition>().is_err());
+        assert!("cycles>many".parse::<FailCondition>().is_err());
+    }
+
+    #[test]
+    fn test_check_ignores_unmeasured_metrics() {
+        let check = policy("dead-code>0,complexity>25").check(&[("dead-code", 3.0)]);
+        assert_eq!(check.violations.len(), 1);
+        assert_eq!(check.violations[0].condition, "dead-code>0");
+
+        let err = check.enforce().unwrap_err();
+        assert!(err.downcast_ref::<PolicyViolation>().is_some());
+        assert_eq!(
+            err.to_string(),
+            "Policy failed: dead-code = 3 (dead-code>0)"
+        );
+
+        let check = policy("complexity>25").check(&[("complexity", 25.0)]);
+        assert!(check.enforce().is_ok());
+    }
+
+    #[test]
+    fn test_metric_value_keeps_counts_integral() {
+        assert_eq!(metric_value(3.0), serde_json::json!(3));
+        assert_eq!(metric_value(62.5), serde_json::json!(62.5));
+    }
+}
@@ -0,0 +1,221 @@
+//! Symbol summaries from a language model
+//!
+//! After a build, symbols whose source is in the summary cache get their
+//! cached summary, and the rest are sent to the OpenAI-compatible chat
+//! completions endpoint of the `[summaries]` configuration, a few at a time.
+//! An unreachable endpoint or a failed request leaves symbols without a
+//! summary; it never fails the build.
+
+use std::path::Path;
+use std::sync::Arc;
+use std::time::Duration;
+
+use anyhow::{Context, Result};
+use codeprysm_config::{PrismConfig, SummaryConfig};
+use codeprysm_core::summaries::{apply_cached, apply_summary};
+use codeprysm_core::{PetCodeGraph, SummaryCache, SummaryOptions, SummaryRequest};
+use tokio::sync::Semaphore;
+use tokio::task::JoinSet;
+use tracing::debug;
+
+use crate::commands::print_warning;
+use crate::progress::{finish_spinner, finish_spinner_warn, spinner};
+
+/// Instructions sent ahead of each symbol unless the configuration has its own
+const DEFAULT_PROMPT: &str = "You summarize source code for a code search index. \
+Reply with one plain-text paragraph of at most three sentences saying what the given \
+symbol does, what it takes and returns, and any notable side effects. Do not repeat \
+the code, and do not use markdown.";
+
+/// Delay before the first retry, doubled on each further one
+const RETRY_BASE_DELAY: Duration = Duration::from_millis(500);
+
+/// Set summaries on the symbols of `graph`, asking the configured model for
+/// those not in the workspace's summary cache.
+///
+/// With `offline`, only cached summaries are set. Does nothing without a
+/// `[summaries]` configuration.
+pub async fn summarize_symbols(
+    graph: &mut PetCodeGraph,
+    workspace: &Path,
+    prism_dir: &Path,
+    config: &PrismConfig,
+    offline: bool,
+    quiet: bool,
+) -> Result<()> {
+    let Some(ref settings) = config.summaries else {
+        return Ok(());
+    };
+    let client = ChatClient::new(settings)?;
+    let mut cache = SummaryCache::load(prism_dir, &client.fingerprint());
+    let options = SummaryOptions {
+        paths: settings.paths.clone(),
+        min_lines: settings.min_lines,
+        max_lines: settings.max_lines,
+    };
+    let requests = apply_cached(graph, workspace, &mut cache, &options);
+
+    if !offline && !requests.is_empty() {
+        let pb = spinner(
+            &format!("Summarizing {} symbol(s)...", requests.len()),
+            quiet,
+        );
+        let requests = Arc::new(requests);
+        let semaphore = Arc::new(Semaphore::new(settings.concurrency.max(1)));
+        let mut tasks = JoinSet::new();
+        for index in 0..requests.len() {
+            let (client, requests, semaphore) =
+                (client.clone(), requests.clone(), semaphore.clone());
+            tasks.spawn(async move {
+                let _permit = semaphore.acquire_owned().await;
+                (index, client.summarize(&requests[index]).await)
+            });
+        }
+
+        let (mut written, mut failed) = (0, 0);
+        let mut first_error = None;
+        while let Some(joined) = tasks.join_next().await {
+            let (index, result) = joined.context("Summary task panicked")?;
+            match result {
+                Ok(summary) => {
+                    apply_summary(graph, &mut cache, &requests[index], &summary);
+                    written += 1;
+                }
+                Err(e) => {
+                    debug!("Failed to summarize {}: {:#}", requests[index].node_id, e);
+                    first_error.get_or_insert(e);
+                    failed += 1;
+                }
+            }
+        }
+
+        if failed == 0 {
+            finish_spinner(pb, &format!("Summarized {} symbol(s)", written));
+        } else {
+            finish_spinner_warn(
+                pb,
+                &format!("Summarized {} symbol(s), {} failed", written, failed),
+            );
+            if let Some(e) = first_error {
+                print_warning(&format!("Summaries failed: {:#}", e));
+            }
+        }
+    }
+
+    cache.save().context("Failed to save summary cache")
+}
+
+/// Client of an OpenAI-compatible chat completions endpoint
+#[derive(Clone)]
+struct ChatClient {
+    http: reqwest::Client,
+    url: String,
+    api_key: Option<String>,
+    azure_mode: bool,
+    model: String,
+    prompt: String,
+    max_retries: u32,
+}
+
+impl ChatClient {
+    fn new(settings: &SummaryConfig) -> Result<Self> {
+        let http = reqwest::Client::builder()
+            .timeout(Duration::from_secs(settings.timeout_secs))
+            .build()
+            .context("Failed to create HTTP client")?;
+        let base = settings.url.trim_end_matches('/');
+        let url = if base.ends_with("/v1") {
+            format!("{}/chat/completions", base)
+        } else {
+            format!("{}/v1/chat/completions", base)
+        };
+        let api_key = settings
+            .api_key_env
+            .as_deref()
+            .filter(|var| !var.is_empty())
+            .and_then(|var| std::env::var(var).ok())
+            .filter(|key| !key.is_empty());
+        Ok(Self {
+            http,
+            url,
+            api_key,
+            azure_mode: settings.azure_mode,
+            model: settings.model.clone(),
+            prompt: settings
+                .prompt
+                .clone()
+                .unwrap_or_else(|| DEFAULT_PROMPT.to_string()),
+            max_retries: settings.max_retries,
+        })
+    }
+
+    /// Model and prompt, which the cached summaries depend on
+    fn fingerprint(&self) -> String {
+        format!("{}\n{}", self.model, self.prompt)
+    }
+
+    /// Ask for the summary of a symbol, retrying failed requests
+    async fn summarize(&self, request: &SummaryRequest) -> Result<String> {
+        let mut delay = RETRY_BASE_DELAY;
+        let mut attempt = 0;
+        loop {
+            match self.complete(request).await {
+                Ok(summary) => return Ok(summary),
+                Err(e) if attempt >= self.max_retries => return Err(e),
+                Err(e) => debug!("Retrying summary of {}: {:#}", request.node_id, e),
+            }
+            tokio::time::sleep(delay).await;
+            delay *= 2;
+            attempt += 1;
+        }
+    }
+
+    async fn complete(&self, request: &SummaryRequest) -> Result<String> {
+        let body = serde_json::json!({
+            "model": self.model,
+            "temperature": 0.2,
+            "messages": [
+                {"role": "system", "content": self.prompt},
+                {"role": "user", "content": user_message(request)},
+            ],
+        });
+        let mut http_request = self.http.post(&self.url).json(&body);
+        if let Some(ref api_key) = self.api_key {
+            http_request = if self.azure_mode {
+                http_request.header("api-key", api_key)
+            } else {
+                http_request.bearer_auth(api_key)
+            };
+        }
+
+        let response = http_request
+            .send()
+            .await
+            .with_context(|| format!("Request to {} failed", self.url))?;
+        let status = response.status();
+        if !status.is_success() {
+            let text = response.text().await.unwrap_or_default();
+            anyhow::bail!("{} returned {}: {}", self.url, status, text.trim());
+        }
+        let response: serde_json::Value = response
+            .json()
+            .await
+            .context("Invalid chat completion response")?;
+        response["choices"][0]["message"]["content"]
+            .as_str()
+            .map(str::to_string)
+            .context("Chat completion response without a message")
+    }
+}
+
+/// The user message describing a symbol
+fn user_message(request: &SummaryRequest) -> String {
+    format!(
+        "{} `{}` in {}:\n\n```{}\n{}\n```",
+        request.kind,
+        request.name,
+        request.file,
+        request.language.as_deref().unwrap_or_default(),
+        request.source
+    )
+}
@@ -0,0 +1,480 @@
+//! Logging and trace export setup
+//!
+//! Logs go to stderr. With `--otel`, spans from the indexing pipeline (index,
+//! file, parse, extract, resolve, clones, export) are also exported to an
+//! OpenTelemetry collector over OTLP/gRPC. The collector endpoint and other
+//! exporter settings come from the standard `OTEL_EXPORTER_OTLP_*` environment
+//! variables (default `http://localhost:4317`).
+//!
+//! With `--log-format json`, each log event is written as one JSON object
+//! per line, for CI systems that parse their logs. Events carry the spans
+//! they occur in, with the spans' fields (such as the `path` of a `file`
+//! span), so they can be correlated. Messages commands print to stderr
+//! become log events too, so stderr holds nothing but JSON.
+//!
+//! `--log-level` sets the level of all logs, of individual subsystems, or
+//! both (`warn,resolver=debug`). Subsystems select events by the pipeline
+//! span they occur in and the modules emitting them:
+//!
+//! - `parser`: parsing and tag extraction
+//! - `resolver`: reference resolution and Go type checking
+//! - `exporter`: partition, graph store, and graph export writes
+
+use std::fmt;
+use std::str::FromStr;
+use std::sync::atomic::{AtomicBool, Ordering};
+use std::time::{SystemTime, UNIX_EPOCH};
+
+use anyhow::{Context, Result};
+use codeprysm_config::LogFormat;
+use opentelemetry::trace::TracerProvider as _;
+use opentelemetry::KeyValue;
+use opentelemetry_sdk::trace::TracerProvider;
+use opentelemetry_sdk::{runtime, Resource};
+use serde_json::{Map, Value};
+use tracing::field::{Field, Visit};
+use tracing::span::Record;
+use tracing::{Event, Level, Subscriber};
+use tracing_subscriber::field::RecordFields;
+use tracing_subscriber::filter::Targets;
+use tracing_subscriber::fmt::format::Writer;
+use tracing_subscriber::fmt::{FmtContext, FormatEvent, FormatFields, FormattedFields};
+use tracing_subscriber::layer::SubscriberExt;
+use tracing_subscriber::registry::LookupSpan;
+use tracing_subscriber::util::SubscriberInitExt;
+use tracing_subscriber::{EnvFilter, Layer};
+
+/// Whether logs are written as JSON
+static JSON_LOGS: AtomicBool = AtomicBool::new(false);
+
+/// Check if logs are written as JSON, in which case messages for the user
+/// should be logged rather than printed.
+pub fn json_logs() -> bool {
+    JSON_LOGS.load(Ordering::Relaxed)
+}
+
+/// Subsystems whose log level can be set on their own.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum Subsystem {
+    /// Parsing and tag extraction
+    Parser,
+    /// Reference resolution and Go type checking
+    Resolver,
+    /// Partition, graph store, and graph export writes
+    Exporter,
+}
+
+impl Subsystem {
+    /// All subsystems
+    pub const ALL: [Subsystem; 3] = [Subsystem::Parser, Subsystem::Resolver, Subsystem::Exporter];
+
+    /// Get the string representation
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            Subsystem::Parser => "parser",
+            Subsystem::Resolver => "resolver",
+            Subsystem::Exporter => "exporter",
+        }
+    }
+
+    /// Spans the subsystem's events occur in
+    fn spans(&self) -> &'static [&'static str] {
+        match self {
+            Subsystem::Parser => &["parse", "extract"],
+            Subsystem::Resolver => &["resolve", "go_types"],
+            Subsystem::Exporter => &["export"],
+        }
+    }
+
+    /// Modules emitting the subsystem's events
+    fn targets(&self) -> &'static [&'static str] {
+        match self {
+            Subsystem::Parser => &["codeprysm_core::parser", "codeprysm_core::tags"],
+            Subsystem::Resolver => &["codeprysm_core::go_types"],
+            Subsystem::Exporter => &[
+                "codeprysm_core::export",
+                "codeprysm_core::archive",
+                "codeprysm_core::mmap",
+                "codeprysm_core::stream",
+            ],
+        }
+    }
+}
+
+impl FromStr for Subsystem {
+    type Err = String;
+
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        Subsystem::ALL
+            .into_iter()
+            .find(|subsystem| subsystem.as_str().eq_ignore_ascii_case(s))
+            .ok_or_else(|| {
+                format!(
+                    "Unknown log subsystem: '{}'. Valid values: parser, resolver, exporter",
+                    s
+                )
+            })
+    }
+}
+
+/// Log levels: a default level and per-subsystem overrides, parsed from
+/// `LEVEL` or `[LEVEL,]SUBSYSTEM=LEVEL,...`.
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub struct LogLevel {
+    /// Level of everything not overridden (from `-v` and `-q` if unset)
+    pub default: Option<Level>,
+    /// Subsystem levels, in order given
+    pub subsystems: Vec<(Subsystem, Level)>,
+}
+
+impl LogLevel {
+    /// Use `level` as the default level unless one is set
+    pub fn with_default_level(mut self, level: Level) -> Self {
+        self.default.get_or_insert(level);
+        self
+    }
+
+    /// Filter selecting the events to log
+    fn filter(&self) -> Result<EnvFilter> {
+        let level = |level: &Level| level.as_str().to_ascii_lowercase();
+        let mut directives = vec![level(&self.default.unwrap_or(Level::INFO))];
+        for (subsystem, subsystem_level) in &self.subsystems {
+            let subsystem_level = level(subsystem_level);
+            for span in subsystem.spans() {
+                directives.push(format!("[{}]={}", span, subsystem_level));
+            }
+            for target in subsystem.targets() {
+                directives.push(format!("{}={}", target, subsystem_level));
+            }
+        }
+        EnvFilter::try_new(directives.join(",")).context("Invalid log level")
+    }
+}
+
+impl FromStr for LogLevel {
+    type Err = String;
+
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        let parse_level = |level: &str| {
+            Level::from_str(level.trim()).map_err(|_| {
+                format!(
+                    "Unknown log level: '{}'. Valid values: trace, debug, info, warn, error",
+                    level.trim()
+                )
+            })
+        };
+        let mut log_level = LogLevel::default();
+        for part in s.split(',').map(str::trim).filter(|part| !part.is_empty()) {
+            match part.split_once('=') {
+                Some((subsystem, level)) => log_level
+                    .subsystems
+                    .push((subsystem.trim().parse()?, parse_level(level)?)),
+                None => log_level.default = Some(parse_level(part)?),
+            }
+        }
+        Ok(log_level)
+    }
+}
+
+/// Exports spans until shut down.
+pub struct Telemetry {
+    provider: TracerProvider,
+}
+
+impl Telemetry {
+    /// Flush pending spans and stop the exporter.
+    pub async fn shutdown(self) {
+        let provider = self.provider;
+        let _ = tokio::task::spawn_blocking(move || provider.shutdown()).await;
+    }
+}
+
+/// Install the global subscriber: stderr logs at `level` in `format`, plus
+/// OTLP span export when `otel` is set.
+///
+/// Exported spans include per-file debug spans regardless of `level`.
+pub fn init(level: &LogLevel, format: &LogFormat, otel: bool) -> Result<Option<Telemetry>> {
+    let logs = match format {
+        LogFormat::Text => tracing_subscriber::fmt::layer()
+            .with_writer(std::io::stderr)
+            .with_ansi(true)
+            .with_filter(level.filter()?)
+            .boxed(),
+        LogFormat::Json => tracing_subscriber::fmt::layer()
+            .with_writer(std::io::stderr)
+            .with_ansi(false)
+            .fmt_fields(JsonSpanFields)
+            .event_format(JsonFormat)
+            .with_filter(level.filter()?)
+            .boxed(),
+    };
+    JSON_LOGS.store(*format == LogFormat::Json, Ordering::Relaxed);
+
+    if !otel {
+        tracing_subscriber::registry().with(logs).try_init()?;
+        return Ok(None);
+    }
+
+    let exporter = opentelemetry_otlp::SpanExporter::builder()
+        .with_tonic()
+        .build()
+        .context("Failed to create the OTLP exporter")?;
+    let provider = TracerProvider::builder()
+        .with_batch_exporter(exporter, runtime::Tokio)
+        .with_resource(Resource::new([
+            KeyValue::new("service.name", "codeprysm"),
+            KeyValue::new("service.version", env!("CARGO_PKG_VERSION")),
+        ]))
+        .build();
+    let spans = tracing_opentelemetry::layer()
+        .with_tracer(provider.tracer("codeprysm"))
+        .with_filter(Targets::new().with_target("codeprysm", Level::DEBUG));
+
+    tracing_subscriber::registry()
+        .with(logs)
+        .with(spans)
+        .try_init()?;
+    Ok(Some(Telemetry { provider }))
+}
+
+/// Formats each event as a JSON object with its timestamp (milliseconds since
+/// the Unix epoch), level, target, message, and fields, plus the innermost
+/// span it occurs in as `span` and all of them, outermost first, as `spans`.
+///
+/// Span fields are read as recorded by [`JsonSpanFields`].
+struct JsonFormat;
+
+impl<S, N> FormatEvent<S, N> for JsonFormat
+where
+    S: Subscriber + for<'a> LookupSpan<'a>,
+    N: for<'a> FormatFields<'a> + 'static,
+{
+    fn format_event(
+        &self,
+        ctx: &FmtContext<'_, S, N>,
+        mut writer: Writer<'_>,
+        event: &Event<'_>,
+    ) -> fmt::Result {
+        let metadata = event.metadata();
+        let timestamp = SystemTime::now()
+            .duration_since(UNIX_EPOCH)
+            .map_or(0, |d| d.as_millis() as u64);
+        let mut object = Map::new();
+        object.insert("timestamp".to_string(), timestamp.into());
+        object.insert("level".to_string(), metadata.level().as_str().into());
+        object.insert("target".to_string(), metadata.target().into());
+        event.record(&mut JsonFields(&mut object));
+
+        if let Some(scope) = ctx.event_scope() {
+            let spans: Vec<Value> = scope
+                .from_root()
+                .map(|span| {
+                    let mut fields = span
+                        .extensions()
+                        .get::<FormattedFields<N>>()
+                        .and_then(|fields| serde_json::from_str(&fields.fields).ok())
+                        .unwrap_or_else(Map::new);
+                    fields.insert("name".to_string(), span.name().into());
+                    Value::Object(fields)
+                })
+                .collect();
+            if let Some(current) = spans.last() {
+                object.insert("span".to_string(), current.clone());
+            }
+            object.insert("spans".to_string(), spans.into());
+        }
+        writeln!(writer, "{}", Value::Object(object))
+    }
+}
+
+/// Records span fields as a JSON object, for [`JsonFormat`] to include in
+/// the events within the span
+struct JsonSpanFields;
+
+impl<'writer> FormatFields<'writer> for JsonSpanFields {
+    fn format_fields<R: RecordFields>(
+        &self,
+        mut writer: Writer<'writer>,
+        fields: R,
+    ) -> fmt::Result {
+        let mut object = Map::new();
+        fields.record(&mut JsonFields(&mut object));
+        write!(writer, "{}", Value::Object(object))
+    }
+
+    fn add_fields(
+        &self,
+        current: &'writer mut FormattedFields<Self>,
+        fields: &Record<'_>,
+    ) -> fmt::Result {
+        let mut object: Map<String, Value> =
+            serde_json::from_str(&current.fields).unwrap_or_default();
+        fields.record(&mut JsonFields(&mut object));
+        current.fields = Value::Object(object).to_string();
+        Ok(())
+    }
+}
+
+/// Records event fields into a JSON object
+struct JsonFields<'a>(&'a mut Map<String, Value>);
+
+impl Visit for JsonFields<'_> {
+    fn record_f64(&mut self, field: &Field, value: f64) {
+        self.0.insert(field.name().to_string(), value.into());
+    }
+
+    fn record_i64(&mut self, field: &Field, value: i64) {
+        self.0.insert(field.name().to_string(), value.into());
+    }
+
+    fn record_u64(&mut self, field: &Field, value: u64) {
+        self.0.insert(field.name().to_string(), value.into());
+    }
+
+    fn record_bool(&mut self, field: &Field, value: bool) {
+        self.0.insert(field.name().to_string(), value.into());
+    }
+
+    fn record_str(&mut self, field: &Field, value: &str) {
+        self.0.insert(field.name().to_string(), value.into());
+    }
+
+    fn record_debug(&mut self, field: &Field, value: &dyn fmt::Debug) {
+        self.0
+            .insert(field.name().to_string(), format!("{:?}", value).into());
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_json_format_writes_one_object_per_event() {
+        let buffer = std::sync::Arc::new(std::sync::Mutex::new(Vec::new()));
+        let writer = {
+            let buffer = std::sync::Arc::clone(&buffer);
+            move || SharedBuffer(std::sync::Arc::clone(&buffer))
+        };
+        let subscriber = tracing_subscriber::registry().with(
+            tracing_subscriber::fmt::layer()
+                .with_writer(writer)
+                .with_ansi(false)
+                .fmt_fields(JsonSpanFields)
+                .event_format(JsonFormat),
+        );
+        tracing::subscriber::with_default(subscriber, || {
+            tracing::info!(event = "progress", done = 3u64, "parsing files 3/10");
+            let file =
+                tracing::info_span!("file", path = "src/a.go", lines = tracing::field::Empty);
+            file.record("lines", 12u64);
+            file.in_scope(|| {
+                tracing::info_span!("parse").in_scope(|| tracing::warn!("syntax error"));
+            });
+        });
+
+        let output = String::from_utf8(buffer.lock().unwrap().clone()).unwrap();
+        let lines: Vec<Value> = output
+            .lines()
+            .map(|line| serde_json::from_str(line).unwrap())
+            .collect();
+        assert_eq!(lines.len(), 2);
+        let line = &lines[0];
+        assert_eq!(line["level"], "INFO");
+        assert_eq!(line["event"], "progress");
+        assert_eq!(line["done"], 3);
+        assert_eq!(line["message"], "parsing files 3/10");
+        assert!(line.get("spans").is_none());
+
+        // Events carry the spans they occur in, with fields recorded later
+        let line = &lines[1];
+        assert_eq!(line["message"], "syntax error");
+        assert_eq!(line["span"]["name"], "parse");
+        assert_eq!(line["spans"][0]["name"], "file");
+        assert_eq!(line["spans"][0]["path"], "src/a.go");
+        assert_eq!(line["spans"][0]["lines"], 12);
+    }
+
+    #[test]
+    fn test_log_level_parsing() {
+        assert_eq!(
+            "debug".parse::<LogLevel>(),
+            Ok(LogLevel {
+                default: Some(Level::DEBUG),
+                subsystems: Vec::new(),
+            })
+        );
+        assert_eq!(
+            "WARN, resolver=trace,parser=info".parse::<LogLevel>(),
+            Ok(LogLevel {
+                default: Some(Level::WARN),
+                subsystems: vec![
+                    (Subsystem::Resolver, Level::TRACE),
+                    (Subsystem::Parser, Level::INFO),
+                ],
+            })
+        );
+
+        // Subsystems alone keep the level of -v and -q
+        let level = "exporter=debug".parse::<LogLevel>().unwrap();
+        assert_eq!(level.default, None);
+        assert_eq!(
+            level.with_default_level(Level::ERROR).default,
+            Some(Level::ERROR)
+        );
+
+        assert!("loud"
+            .parse::<LogLevel>()
+            .unwrap_err()
+            .contains("Unknown log level"));
+        assert!("linker=debug"
+            .parse::<LogLevel>()
+            .unwrap_err()
+            .contains("Unknown log subsystem"));
+    }
+
+    #[test]
+    fn test_subsystem_level_applies_within_its_spans() {
+        let buffer = std::sync::Arc::new(std::sync::Mutex::new(Vec::new()));
+        let writer = {
+            let buffer = std::sync::Arc::clone(&buffer);
+            move || SharedBuffer(std::sync::Arc::clone(&buffer))
+        };
+        let level: LogLevel = "warn,resolver=debug".parse().unwrap();
+        let subscriber = tracing_subscriber::registry().with(
+            tracing_subscriber::fmt::layer()
+                .with_writer(writer)
+                .with_ansi(false)
+                .fmt_fields(JsonSpanFields)
+                .event_format(JsonFormat)
+                .with_filter(level.filter().unwrap()),
+        );
+        tracing::subscriber::with_default(subscriber, || {
+            tracing::debug!("outside");
+            tracing::info_span!("resolve").in_scope(|| tracing::debug!("resolving"));
+            tracing::debug_span!("parse").in_scope(|| tracing::debug!("parsing"));
+            tracing::warn!("warning");
+        });
+
+        let output = String::from_utf8(buffer.lock().unwrap().clone()).unwrap();
+        let messages: Vec<Value> = output
+            .lines()
+            .map(|line| serde_json::from_str::<Value>(line).unwrap()["message"].clone())
+            .collect();
+        assert_eq!(messages, ["resolving", "warning"]);
+    }
+
+    /// Test writer collecting output in memory
+    struct SharedBuffer(std::sync::Arc<std::sync::Mutex<Vec<u8>>>);
+
+    impl std::io::Write for SharedBuffer {
+        fn write(&mut self, buf: &[u8]) -> std::io::Result<usize> {
+            self.0.lock().unwrap().extend_from_slice(buf);
+            Ok(buf.len())
+        }
+
+        fn flush(&mut self) -> std::io::Result<()> {
+            Ok(())
+        }
+    }
+}
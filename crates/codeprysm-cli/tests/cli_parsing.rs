@@ -58,186 +58,1512 @@ fn test_global_options_in_help() {
         .stdout(predicate::str::contains("--config"))
         .stdout(predicate::str::contains("--verbose"))
         .stdout(predicate::str::contains("--quiet"))
+        .stdout(predicate::str::contains("--otel"))
+        .stdout(predicate::str::contains("--log-format"))
+        .stdout(predicate::str::contains("--log-level"))
         .stdout(predicate::str::contains("--qdrant-url"));
 }
 
+#[test]
+fn test_log_format_values() {
+    prism()
+        .args(["--log-format", "json", "init", "--help"])
+        .assert()
+        .success();
+    prism()
+        .args(["--log-format", "xml", "init", "--help"])
+        .assert()
+        .failure()
+        .stderr(predicate::str::contains("Unknown log format"));
+}
+
+#[test]
+fn test_json_log_format_keeps_stderr_json() {
+    let repo = tempfile::tempdir().unwrap();
+    std::fs::write(repo.path().join("app.py"), "def main():\n    return 1\n").unwrap();
+    let workspace = repo.path().to_str().unwrap();
+
+    // A command that logs while it works, and one that fails
+    for args in [
+        &["--log-format", "json", "bench", workspace][..],
+        &["--log-format", "json", "--workspace", workspace, "check"],
+    ] {
+        let output = prism().args(args).output().unwrap();
+        let stderr = String::from_utf8(output.stderr).unwrap();
+        assert!(
+            !stderr.trim().is_empty(),
+            "{:?} wrote nothing to stderr",
+            args
+        );
+        for line in stderr.lines().filter(|line| !line.trim().is_empty()) {
+            assert!(
+                serde_json::from_str::<serde_json::Value>(line).is_ok(),
+                "{:?} wrote a non-JSON stderr line: {}",
+                args,
+                line
+            );
+        }
+    }
+}
+
+#[test]
+fn test_log_level_values() {
+    prism()
+        .args(["--log-level", "warn,parser=debug", "init", "--help"])
+        .assert()
+        .success();
+    prism()
+        .args(["--log-level", "resolver=trace", "init", "--help"])
+        .assert()
+        .success();
+    prism()
+        .args(["--log-level", "chatty", "init", "--help"])
+        .assert()
+        .failure()
+        .stderr(predicate::str::contains("Unknown log level"));
+    prism()
+        .args(["--log-level", "linker=debug", "init", "--help"])
+        .assert()
+        .failure()
+        .stderr(predicate::str::contains("Unknown log subsystem"));
+}
+
 #[test]
 fn test_conflicting_verbose_quiet_not_prevented() {
     // clap doesn't prevent both by default, but our code handles it
     // This just verifies both flags are accepted
     prism()
-        .args(["--verbose", "--quiet", "--help"])
+        .args(["--verbose", "--quiet", "--help"])
+        .assert()
+        .success();
+}
+
+// ============================================================================
+// Init Command Tests
+// ============================================================================
+
+#[test]
+fn test_init_help() {
+    prism()
+        .args(["init", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("Initialize"))
+        .stdout(predicate::str::contains("--force"))
+        .stdout(predicate::str::contains("--no-index"))
+        .stdout(predicate::str::contains("--queries"))
+        .stdout(predicate::str::contains("--no-components"))
+        .stdout(predicate::str::contains("--ci"));
+}
+
+#[test]
+fn test_init_ci_flag() {
+    // Just testing parsing, not execution
+    prism()
+        .args(["init", "--ci", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("CI/CD mode"));
+}
+
+#[test]
+fn test_init_stream_flag() {
+    // Just testing parsing, not execution
+    prism()
+        .args(["init", "--stream", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("--stream"))
+        .stdout(predicate::str::contains("peak memory"));
+}
+
+#[test]
+fn test_init_dry_run_flag() {
+    // Just testing parsing, not execution
+    prism()
+        .args(["init", "--dry-run", "--json", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("--dry-run"))
+        .stdout(predicate::str::contains("--json"));
+
+    // --json only applies to the dry-run plan
+    prism().args(["init", "--json"]).assert().failure();
+}
+
+#[test]
+fn test_include_exclude_flags() {
+    // Just testing parsing, not execution
+    for command in ["init", "update", "watch", "shard", "bench"] {
+        prism()
+            .args([
+                command,
+                "--include",
+                "src/**",
+                "--exclude",
+                "**/testdata/**",
+                "--exclude",
+                "third_party/**",
+                "--help",
+            ])
+            .assert()
+            .success()
+            .stdout(predicate::str::contains("--include <GLOB>"))
+            .stdout(predicate::str::contains("--exclude <GLOB>"));
+    }
+}
+
+#[test]
+fn test_no_summaries_flag() {
+    // Just testing parsing, not execution
+    for command in ["init", "update"] {
+        prism()
+            .args([command, "--no-summaries", "--help"])
+            .assert()
+            .success()
+            .stdout(predicate::str::contains("--no-summaries"));
+    }
+}
+
+#[test]
+fn test_go_types_flag() {
+    // Just testing parsing, not execution
+    for command in ["init", "update"] {
+        prism()
+            .args([command, "--go-types", "--help"])
+            .assert()
+            .success()
+            .stdout(predicate::str::contains("--go-types"))
+            .stdout(predicate::str::contains("type checker"));
+    }
+}
+
+#[test]
+fn test_init_accepts_path() {
+    // Just testing parsing, not execution
+    prism()
+        .args(["init", "/some/path", "--help"])
+        .assert()
+        .success();
+}
+
+// ============================================================================
+// Update Command Tests
+// ============================================================================
+
+#[test]
+fn test_update_help() {
+    prism()
+        .args(["update", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("Update"))
+        .stdout(predicate::str::contains("--force"))
+        .stdout(predicate::str::contains("--reindex"))
+        .stdout(predicate::str::contains("--index-only"))
+        .stdout(predicate::str::contains("--coverage"))
+        .stdout(predicate::str::contains("--churn"))
+        .stdout(predicate::str::contains("--blame"));
+}
+
+// ============================================================================
+// Watch Command Tests
+// ============================================================================
+
+#[test]
+fn test_watch_help() {
+    prism()
+        .args(["watch", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("--debounce"))
+        .stdout(predicate::str::contains("--queries"));
+}
+
+#[test]
+fn test_watch_uninitialized_workspace() {
+    let temp = tempfile::TempDir::new().unwrap();
+    prism()
+        .args(["--workspace", temp.path().to_str().unwrap(), "watch"])
+        .assert()
+        .failure()
+        .stderr(predicate::str::contains("not initialized"));
+}
+
+// ============================================================================
+// Shard and Merge Command Tests
+// ============================================================================
+
+#[test]
+fn test_shard_help() {
+    prism()
+        .args(["shard", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("<PATHS>"))
+        .stdout(predicate::str::contains("--output"));
+}
+
+#[test]
+fn test_shard_requires_paths() {
+    prism()
+        .args(["shard"])
+        .assert()
+        .failure()
+        .stderr(predicate::str::contains("<PATHS>"));
+}
+
+#[test]
+fn test_merge_help() {
+    prism()
+        .args(["merge", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("<SHARDS>"))
+        .stdout(predicate::str::contains("--archive"));
+}
+
+#[test]
+fn test_merge_missing_shard() {
+    let temp = tempfile::TempDir::new().unwrap();
+    prism()
+        .args([
+            "--workspace",
+            temp.path().to_str().unwrap(),
+            "merge",
+            "missing-shard.json",
+        ])
+        .assert()
+        .failure()
+        .stderr(predicate::str::contains("Failed to load shard"));
+}
+
+// ============================================================================
+// Federate Command Tests
+// ============================================================================
+
+#[test]
+fn test_federate_help() {
+    prism()
+        .args(["federate", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("[NAME=]PATH"))
+        .stdout(predicate::str::contains("--rebuild"))
+        .stdout(predicate::str::contains("--archive"));
+}
+
+#[test]
+fn test_federate_missing_repository() {
+    let temp = tempfile::TempDir::new().unwrap();
+    prism()
+        .args([
+            "--workspace",
+            temp.path().to_str().unwrap(),
+            "federate",
+            "lib=missing-repo",
+        ])
+        .assert()
+        .failure()
+        .stderr(predicate::str::contains("Repository not found"));
+}
+
+// ============================================================================
+// Search Command Tests
+// ============================================================================
+
+#[test]
+fn test_search_help() {
+    prism()
+        .args(["search", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("Search"))
+        .stdout(predicate::str::contains("--limit"))
+        .stdout(predicate::str::contains("--mode"))
+        .stdout(predicate::str::contains("--types"))
+        .stdout(predicate::str::contains("--min-score"))
+        .stdout(predicate::str::contains("--output"))
+        .stdout(predicate::str::contains("--snippets"))
+        .stdout(predicate::str::contains("--files-only"));
+}
+
+#[test]
+fn test_search_mode_values() {
+    prism()
+        .args(["search", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("hybrid"))
+        .stdout(predicate::str::contains("semantic"))
+        .stdout(predicate::str::contains("code"));
+}
+
+#[test]
+fn test_search_fuzzy_mode() {
+    prism()
+        .args(["search", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("fuzzy"))
+        .stdout(predicate::str::contains("--docs"));
+}
+
+#[test]
+fn test_search_text_mode() {
+    prism()
+        .args(["search", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("--text"));
+    prism()
+        .args(["search", "--text", "--mode", "fuzzy", "retry"])
+        .assert()
+        .failure()
+        .stderr(predicate::str::contains("cannot be used with"));
+}
+
+#[test]
+fn test_search_semantic_conflicts_with_mode() {
+    prism()
+        .args(["search", "--semantic", "--mode", "code", "retry"])
+        .assert()
+        .failure()
+        .stderr(predicate::str::contains("cannot be used with"));
+}
+
+#[test]
+fn test_search_requires_query() {
+    prism()
+        .args(["search"])
+        .assert()
+        .failure()
+        .stderr(predicate::str::contains("required"));
+}
+
+// ============================================================================
+// Graph Command Tests
+// ============================================================================
+
+#[test]
+fn test_graph_help() {
+    prism()
+        .args(["graph", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("stats"))
+        .stdout(predicate::str::contains("node"))
+        .stdout(predicate::str::contains("find"))
+        .stdout(predicate::str::contains("edges"))
+        .stdout(predicate::str::contains("connected"))
+        .stdout(predicate::str::contains("code"));
+}
+
+#[test]
+fn test_graph_stats_help() {
+    prism()
+        .args(["graph", "stats", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("statistics"));
+}
+
+#[test]
+fn test_graph_node_requires_id() {
+    prism()
+        .args(["graph", "node"])
+        .assert()
+        .failure()
+        .stderr(predicate::str::contains("required"));
+}
+
+#[test]
+fn test_graph_find_help() {
+    prism()
+        .args(["graph", "find", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("pattern"))
+        .stdout(predicate::str::contains("--node-type"))
+        .stdout(predicate::str::contains("--limit"));
+}
+
+#[test]
+fn test_graph_edges_help() {
+    prism()
+        .args(["graph", "edges", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("--direction"))
+        .stdout(predicate::str::contains("--edge-type"));
+}
+
+#[test]
+fn test_graph_connected_help() {
+    prism()
+        .args(["graph", "connected", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("--direction"))
+        .stdout(predicate::str::contains("--edge-type"));
+}
+
+#[test]
+fn test_graph_code_help() {
+    prism()
+        .args(["graph", "code", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("--context"));
+}
+
+// ============================================================================
+// Query Command Tests
+// ============================================================================
+
+#[test]
+fn test_query_help() {
+    prism()
+        .args(["query", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("path"))
+        .stdout(predicate::str::contains("implementers"))
+        .stdout(predicate::str::contains("interfaces-of"))
+        .stdout(predicate::str::contains("owners"))
+        .stdout(predicate::str::contains("call-hierarchy"))
+        .stdout(predicate::str::contains("run"));
+}
+
+#[test]
+fn test_query_path_help() {
+    prism()
+        .args(["query", "path", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("--edges"))
+        .stdout(predicate::str::contains("--max-depth"))
+        .stdout(predicate::str::contains("--max-paths"))
+        .stdout(predicate::str::contains("--json"));
+}
+
+#[test]
+fn test_query_path_rejects_unknown_edge_type() {
+    prism()
+        .args(["query", "path", "a", "b", "--edges", "calls"])
+        .assert()
+        .failure()
+        .stderr(predicate::str::contains("Unknown edge type"));
+}
+
+#[test]
+fn test_query_implementers_help() {
+    prism()
+        .args(["query", "implementers", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("<INTERFACE>"))
+        .stdout(predicate::str::contains("--cursor"))
+        .stdout(predicate::str::contains("--json"));
+}
+
+#[test]
+fn test_query_implementers_rejects_zero_limit() {
+    for command in ["implementers", "interfaces-of"] {
+        prism()
+            .args(["query", command, "Reader", "--limit", "0"])
+            .assert()
+            .failure()
+            .stderr(predicate::str::contains("--limit"));
+    }
+}
+
+#[test]
+fn test_query_interfaces_of_help() {
+    prism()
+        .args(["query", "interfaces-of", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("<TYPE>"))
+        .stdout(predicate::str::contains("--json"));
+}
+
+#[test]
+fn test_query_owners_help() {
+    prism()
+        .args(["query", "owners", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("<SYMBOL>"))
+        .stdout(predicate::str::contains("--depth"))
+        .stdout(predicate::str::contains("--json"));
+}
+
+#[test]
+fn test_query_call_hierarchy_help() {
+    prism()
+        .args(["query", "call-hierarchy", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("<SYMBOL>"))
+        .stdout(predicate::str::contains("--direction"))
+        .stdout(predicate::str::contains("--max-calls"))
+        .stdout(predicate::str::contains("--json"));
+}
+
+#[test]
+fn test_query_call_hierarchy_invalid_direction() {
+    prism()
+        .args(["query", "call-hierarchy", "main", "--direction", "both"])
+        .assert()
+        .failure();
+}
+
+#[test]
+fn test_query_run_help() {
+    prism()
+        .args(["query", "run", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("[QUERY]"))
+        .stdout(predicate::str::contains("--json"));
+}
+
+#[test]
+fn test_query_batch_help() {
+    prism()
+        .args(["query", "batch", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("<FILE>"))
+        .stdout(predicate::str::contains("--output"));
+}
+
+#[test]
+fn test_query_rename_help() {
+    prism()
+        .args(["query", "rename", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("<SYMBOL>"))
+        .stdout(predicate::str::contains("<NEW_NAME>"))
+        .stdout(predicate::str::contains("--json"));
+}
+
+#[test]
+fn test_query_rename_requires_new_name() {
+    prism()
+        .args(["query", "rename", "Save"])
+        .assert()
+        .failure()
+        .stderr(predicate::str::contains("<NEW_NAME>"));
+}
+
+// ============================================================================
+// Analyze Command Tests
+// ============================================================================
+
+#[test]
+fn test_analyze_help() {
+    prism()
+        .args(["analyze", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("dead-code"))
+        .stdout(predicate::str::contains("impact"))
+        .stdout(predicate::str::contains("clones"))
+        .stdout(predicate::str::contains("coverage"));
+}
+
+#[test]
+fn test_analyze_dead_code_help() {
+    prism()
+        .args(["analyze", "dead-code", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("--roots"))
+        .stdout(predicate::str::contains("--root"))
+        .stdout(predicate::str::contains("--handler"))
+        .stdout(predicate::str::contains("--no-suppress"))
+        .stdout(predicate::str::contains("sarif"));
+}
+
+#[test]
+fn test_analysis_output_and_fail_on_flags() {
+    for args in [
+        &["analyze", "dead-code", "--output", "json", "--help"][..],
+        &["analyze", "coverage", "--output", "json", "--help"],
+        &["metrics", "functions", "--output", "json", "--help"],
+    ] {
+        prism().args(args).assert().success();
+    }
+    prism()
+        .args(["metrics", "hotspots", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("--output"))
+        .stdout(predicate::str::contains("--fail-on"));
+    prism()
+        .args(["analyze", "cycles", "--fail-on", "cycles>0,complexity>=25"])
+        .args(["--help"])
+        .assert()
+        .success();
+}
+
+#[test]
+fn test_fail_on_rejects_unknown_metric() {
+    prism()
+        .args(["analyze", "dead-code", "--fail-on", "dead_code>0"])
+        .assert()
+        .failure()
+        .stderr(predicate::str::contains("Unknown metric"));
+    prism()
+        .args(["metrics", "functions", "--fail-on", "complexity"])
+        .assert()
+        .failure()
+        .stderr(predicate::str::contains("Missing comparison"));
+}
+
+#[test]
+fn test_analyze_dead_code_rejects_unknown_root_kind() {
+    prism()
+        .args(["analyze", "dead-code", "--roots", "everything"])
+        .assert()
+        .failure()
+        .stderr(predicate::str::contains("Unknown root kind"));
+}
+
+#[test]
+fn test_analyze_impact_help() {
+    prism()
+        .args(["analyze", "impact", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("[RANGE]"))
+        .stdout(predicate::str::contains("--patch"))
+        .stdout(predicate::str::contains("--depth"))
+        .stdout(predicate::str::contains("--tests-only"))
+        .stdout(predicate::str::contains("--json"));
+}
+
+#[test]
+fn test_analyze_impact_range_conflicts_with_patch() {
+    prism()
+        .args(["analyze", "impact", "main..HEAD", "--patch", "change.diff"])
+        .assert()
+        .failure();
+}
+
+#[test]
+fn test_analyze_clones_help() {
+    prism()
+        .args(["analyze", "clones", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("--min-similarity"))
+        .stdout(predicate::str::contains("--min-tokens"))
+        .stdout(predicate::str::contains("--cross-package"))
+        .stdout(predicate::str::contains("sarif"));
+}
+
+#[test]
+fn test_analyze_clones_rejects_similarity_over_100() {
+    prism()
+        .args(["analyze", "clones", "--min-similarity", "150"])
+        .assert()
+        .failure();
+}
+
+#[test]
+fn test_analyze_interfaces_help() {
+    prism()
+        .args(["analyze", "interfaces", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("--min-methods"))
+        .stdout(predicate::str::contains("--package"))
+        .stdout(predicate::str::contains("sarif"));
+}
+
+#[test]
+fn test_analyze_coverage_help() {
+    prism()
+        .args(["analyze", "coverage", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("--profile"))
+        .stdout(predicate::str::contains("--exported"))
+        .stdout(predicate::str::contains("--max-percent"))
+        .stdout(predicate::str::contains("--min-fan-in"))
+        .stdout(predicate::str::contains("--json"));
+}
+
+#[test]
+fn test_analyze_coverage_rejects_percent_over_100() {
+    prism()
+        .args(["analyze", "coverage", "--max-percent", "120"])
+        .assert()
+        .failure();
+}
+
+#[test]
+fn test_analyze_cycles_help() {
+    prism()
+        .args(["analyze", "cycles", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("--level"))
+        .stdout(predicate::str::contains("--package"))
+        .stdout(predicate::str::contains("--format"));
+}
+
+#[test]
+fn test_analyze_cycles_rejects_unknown_level() {
+    prism()
+        .args(["analyze", "cycles", "--level", "module"])
+        .assert()
+        .failure();
+}
+
+#[test]
+fn test_analyze_build_deps_help() {
+    prism()
+        .args(["analyze", "build-deps", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("<INPUT>"))
+        .stdout(predicate::str::contains("--kind"))
+        .stdout(predicate::str::contains("--format"));
+}
+
+#[test]
+fn test_analyze_build_deps_requires_input() {
+    prism().args(["analyze", "build-deps"]).assert().failure();
+}
+
+#[test]
+fn test_analyze_licenses_help() {
+    prism()
+        .args(["analyze", "licenses", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("--conflicts"))
+        .stdout(predicate::str::contains("--package"))
+        .stdout(predicate::str::contains("--format"));
+}
+
+#[test]
+fn test_analyze_unused_exports_help() {
+    prism()
+        .args(["analyze", "unused-exports", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("--allow"))
+        .stdout(predicate::str::contains("--include-tests"))
+        .stdout(predicate::str::contains("--format"));
+}
+
+#[test]
+fn test_analyze_leaks_help() {
+    prism()
+        .args(["analyze", "leaks", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("--kind"))
+        .stdout(predicate::str::contains("--package"))
+        .stdout(predicate::str::contains("--format"));
+}
+
+// ============================================================================
+// Metrics Command Tests
+// ============================================================================
+
+#[test]
+fn test_metrics_help() {
+    prism()
+        .args(["metrics", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("functions"))
+        .stdout(predicate::str::contains("packages"))
+        .stdout(predicate::str::contains("hotspots"))
+        .stdout(predicate::str::contains("risk"));
+}
+
+#[test]
+fn test_metrics_functions_help() {
+    prism()
+        .args(["metrics", "functions", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("--top"))
+        .stdout(predicate::str::contains("--sort"))
+        .stdout(predicate::str::contains("--max-cyclomatic"))
+        .stdout(predicate::str::contains("--max-cognitive"))
+        .stdout(predicate::str::contains("--json"));
+}
+
+#[test]
+fn test_metrics_packages_help() {
+    prism()
+        .args(["metrics", "packages", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("--sort"))
+        .stdout(predicate::str::contains("--baseline"))
+        .stdout(predicate::str::contains("--json"));
+}
+
+#[test]
+fn test_metrics_hotspots_help() {
+    prism()
+        .args(["metrics", "hotspots", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("--kind"))
+        .stdout(predicate::str::contains("--since"))
+        .stdout(predicate::str::contains("--no-churn"))
+        .stdout(predicate::str::contains("--limit"))
+        .stdout(predicate::str::contains("--offset"))
+        .stdout(predicate::str::contains("--json"));
+}
+
+#[test]
+fn test_metrics_hotspots_rejects_unknown_sort() {
+    prism()
+        .args(["metrics", "hotspots", "--sort", "pagerank"])
+        .assert()
+        .failure();
+}
+
+#[test]
+fn test_metrics_risk_help() {
+    prism()
+        .args(["metrics", "risk", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("--since"))
+        .stdout(predicate::str::contains("--half-life"))
+        .stdout(predicate::str::contains("--limit"))
+        .stdout(predicate::str::contains("--fail-on"));
+}
+
+#[test]
+fn test_metrics_risk_rejects_zero_half_life() {
+    prism()
+        .args(["metrics", "risk", "--half-life", "0"])
+        .assert()
+        .failure()
+        .stderr(predicate::str::contains("--half-life must be positive"));
+}
+
+// ============================================================================
+// History Command Tests
+// ============================================================================
+
+#[test]
+fn test_history_help() {
+    prism()
+        .args(["history", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("record"))
+        .stdout(predicate::str::contains("trend"))
+        .stdout(predicate::str::contains("prune"));
+}
+
+#[test]
+fn test_history_trend_help() {
+    prism()
+        .args(["history", "trend", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("dead-code"))
+        .stdout(predicate::str::contains("api-surface"))
+        .stdout(predicate::str::contains("--by"));
+}
+
+#[test]
+fn test_history_trend_rejects_package_for_graph_measure() {
+    prism()
+        .args(["history", "trend", "nodes", "--package", "api"])
+        .assert()
+        .failure()
+        .stderr(predicate::str::contains("graph-wide measure"));
+}
+
+// ============================================================================
+// Api Command Tests
+// ============================================================================
+
+#[test]
+fn test_api_help() {
+    prism()
+        .args(["api", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("--package"))
+        .stdout(predicate::str::contains("--stability"))
+        .stdout(predicate::str::contains("--no-docs"))
+        .stdout(predicate::str::contains("--json"));
+}
+
+#[test]
+fn test_api_rejects_unknown_stability() {
+    prism()
+        .args(["api", "--stability", "frozen"])
+        .assert()
+        .failure()
+        .stderr(predicate::str::contains("Unknown stability"));
+}
+
+// ============================================================================
+// Check Command Tests
+// ============================================================================
+
+#[test]
+fn test_check_help() {
+    prism()
+        .args(["check", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("--staged"))
+        .stdout(predicate::str::contains("--since"))
+        .stdout(predicate::str::contains("--max-cyclomatic"))
+        .stdout(predicate::str::contains("--rules"))
+        .stdout(predicate::str::contains("--all"));
+}
+
+#[test]
+fn test_check_staged_conflicts_with_since() {
+    prism()
+        .args(["check", "--staged", "--since", "main"])
+        .assert()
+        .failure();
+}
+
+#[test]
+fn test_check_all_conflicts_with_staged() {
+    prism()
+        .args(["check", "--all", "--staged"])
+        .assert()
+        .failure();
+}
+
+#[test]
+fn test_check_uninitialized_workspace() {
+    let temp = tempfile::TempDir::new().unwrap();
+    prism()
+        .args(["--workspace", temp.path().to_str().unwrap(), "check"])
+        .assert()
+        .failure()
+        .stderr(predicate::str::contains("not initialized"));
+}
+
+// ============================================================================
+// Diff Command Tests
+// ============================================================================
+
+#[test]
+fn test_diff_help() {
+    prism()
+        .args(["diff", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("<REV1>"))
+        .stdout(predicate::str::contains("<REV2>"))
+        .stdout(predicate::str::contains("--edges"))
+        .stdout(predicate::str::contains("--json"));
+}
+
+#[test]
+fn test_diff_requires_two_revisions() {
+    prism().args(["diff", "main"]).assert().failure();
+}
+
+// ============================================================================
+// Select Tests Command Tests
+// ============================================================================
+
+#[test]
+fn test_select_tests_help() {
+    prism()
+        .args(["select-tests", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("--since"))
+        .stdout(predicate::str::contains("--depth"))
+        .stdout(predicate::str::contains("packages"));
+}
+
+#[test]
+fn test_select_tests_invalid_format() {
+    prism()
+        .args(["select-tests", "--format", "junit"])
+        .assert()
+        .failure();
+}
+
+// ============================================================================
+// Review Command Tests
+// ============================================================================
+
+#[test]
+fn test_review_help() {
+    prism()
+        .args(["review", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("<BASE>"))
+        .stdout(predicate::str::contains("--min-fan-in"))
+        .stdout(predicate::str::contains("github"))
+        .stdout(predicate::str::contains("--summary"))
+        .stdout(predicate::str::contains("--comment"));
+}
+
+#[test]
+fn test_review_requires_base() {
+    prism().args(["review"]).assert().failure();
+}
+
+#[test]
+fn test_review_invalid_format() {
+    prism()
+        .args(["review", "main", "--format", "gitlab"])
+        .assert()
+        .failure();
+}
+
+// ============================================================================
+// API Diff Command Tests
+// ============================================================================
+
+#[test]
+fn test_apidiff_help() {
+    prism()
+        .args(["apidiff", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("<REV1>"))
+        .stdout(predicate::str::contains("<REV2>"))
+        .stdout(predicate::str::contains("--breaking-only"))
+        .stdout(predicate::str::contains("--allow-breaking"))
+        .stdout(predicate::str::contains("--json"));
+}
+
+#[test]
+fn test_apidiff_requires_two_revisions() {
+    prism().args(["apidiff", "v1.2.0"]).assert().failure();
+}
+
+// ============================================================================
+// Changelog Command Tests
+// ============================================================================
+
+#[test]
+fn test_changelog_help() {
+    prism()
+        .args(["changelog", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("<REV1>"))
+        .stdout(predicate::str::contains("<REV2>"))
+        .stdout(predicate::str::contains("--format"))
+        .stdout(predicate::str::contains("--title"))
+        .stdout(predicate::str::contains("--output"));
+}
+
+#[test]
+fn test_changelog_rejects_unknown_format() {
+    prism()
+        .args(["changelog", "v1.2.0", "v1.3.0", "--format", "html"])
+        .assert()
+        .failure();
+}
+
+// ============================================================================
+// Subgraph Command Tests
+// ============================================================================
+
+#[test]
+fn test_subgraph_help() {
+    prism()
+        .args(["subgraph", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("<SYMBOL>"))
+        .stdout(predicate::str::contains("--radius"))
+        .stdout(predicate::str::contains("--edges"))
+        .stdout(predicate::str::contains("--max-nodes"))
+        .stdout(predicate::str::contains("--format"));
+}
+
+#[test]
+fn test_subgraph_export_filters() {
+    prism()
+        .args(["subgraph", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("--only-exported"))
+        .stdout(predicate::str::contains("--exclude-tests"))
+        .stdout(predicate::str::contains("--exclude-generated"))
+        .stdout(predicate::str::contains("--max-node-kind-depth"));
+
+    prism()
+        .args(["subgraph", "Handle", "--max-node-kind-depth", "deep"])
+        .assert()
+        .failure();
+}
+
+#[test]
+fn test_export_budgets() {
+    for command in [&["subgraph"][..], &["projects", "export"]] {
+        prism()
+            .args(command)
+            .arg("--help")
+            .assert()
+            .success()
+            .stdout(predicate::str::contains("--max-nodes"))
+            .stdout(predicate::str::contains("--max-edges"));
+    }
+
+    prism()
+        .args(["subgraph", "Handle", "--max-edges", "many"])
+        .assert()
+        .failure();
+}
+
+#[test]
+fn test_subgraph_edge_names() {
+    // The query language's relationship names are accepted too
+    let temp = tempfile::TempDir::new().unwrap();
+    prism()
+        .args(["--workspace", temp.path().to_str().unwrap()])
+        .args(["subgraph", "Server", "--edges", "calls,implements"])
+        .assert()
+        .stderr(predicate::str::contains("invalid value").not());
+
+    prism()
+        .args(["subgraph", "Server", "--edges", "calls_into"])
+        .assert()
+        .failure()
+        .stderr(predicate::str::contains("invalid value"));
+}
+
+#[test]
+fn test_subgraph_rejects_unknown_format() {
+    prism()
+        .args(["subgraph", "Handle", "--format", "svg"])
+        .assert()
+        .failure()
+        .stderr(predicate::str::contains("Unknown export format"));
+}
+
+// ============================================================================
+// Projects Command Tests
+// ============================================================================
+
+#[test]
+fn test_projects_help() {
+    prism()
+        .args(["projects", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("list"))
+        .stdout(predicate::str::contains("deps"))
+        .stdout(predicate::str::contains("export"));
+}
+
+#[test]
+fn test_projects_deps_reverse_requires_name() {
+    prism()
+        .args(["projects", "deps", "--reverse"])
         .assert()
-        .success();
+        .failure();
+}
+
+#[test]
+fn test_projects_export_rejects_unknown_format() {
+    prism()
+        .args(["projects", "export", "api", "--format", "svg"])
+        .assert()
+        .failure()
+        .stderr(predicate::str::contains("Unknown export format"));
 }
 
 // ============================================================================
-// Init Command Tests
+// Tags Command Tests
 // ============================================================================
 
 #[test]
-fn test_init_help() {
+fn test_aliases_help() {
     prism()
-        .args(["init", "--help"])
+        .args(["aliases", "--help"])
         .assert()
         .success()
-        .stdout(predicate::str::contains("Initialize"))
-        .stdout(predicate::str::contains("--force"))
-        .stdout(predicate::str::contains("--no-index"))
-        .stdout(predicate::str::contains("--queries"))
-        .stdout(predicate::str::contains("--no-components"))
-        .stdout(predicate::str::contains("--ci"));
+        .stdout(predicate::str::contains("Symbol ID"))
+        .stdout(predicate::str::contains("--json"));
 }
 
 #[test]
-fn test_init_ci_flag() {
-    // Just testing parsing, not execution
+fn test_tags_help() {
     prism()
-        .args(["init", "--ci", "--help"])
+        .args(["tags", "--help"])
         .assert()
         .success()
-        .stdout(predicate::str::contains("CI/CD mode"));
+        .stdout(predicate::str::contains("--format"))
+        .stdout(predicate::str::contains("--output"));
 }
 
 #[test]
-fn test_init_accepts_path() {
-    // Just testing parsing, not execution
+fn test_tags_rejects_unknown_format() {
     prism()
-        .args(["init", "/some/path", "--help"])
+        .args(["tags", "--format", "gtags"])
         .assert()
-        .success();
+        .failure()
+        .stderr(predicate::str::contains("Unknown tags format"));
 }
 
 // ============================================================================
-// Update Command Tests
+// Archive Command Tests
 // ============================================================================
 
 #[test]
-fn test_update_help() {
+fn test_archive_help() {
     prism()
-        .args(["update", "--help"])
+        .args(["archive", "--help"])
         .assert()
         .success()
-        .stdout(predicate::str::contains("Update"))
-        .stdout(predicate::str::contains("--force"))
-        .stdout(predicate::str::contains("--reindex"))
-        .stdout(predicate::str::contains("--index-only"));
+        .stdout(predicate::str::contains("create"))
+        .stdout(predicate::str::contains("info"))
+        .stdout(predicate::str::contains("query"));
+}
+
+#[test]
+fn test_archive_create_help() {
+    prism()
+        .args(["archive", "create", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("--output"));
+}
+
+#[test]
+fn test_archive_query_requires_node_id() {
+    prism()
+        .args(["archive", "query", "graph.cpz"])
+        .assert()
+        .failure()
+        .stderr(predicate::str::contains("<NODE_ID>"));
 }
 
 // ============================================================================
-// Search Command Tests
+// Stats Command Tests
 // ============================================================================
 
 #[test]
-fn test_search_help() {
+fn test_stats_help() {
     prism()
-        .args(["search", "--help"])
+        .args(["stats", "--help"])
         .assert()
         .success()
-        .stdout(predicate::str::contains("Search"))
-        .stdout(predicate::str::contains("--limit"))
-        .stdout(predicate::str::contains("--mode"))
-        .stdout(predicate::str::contains("--types"))
-        .stdout(predicate::str::contains("--min-score"))
-        .stdout(predicate::str::contains("--output"))
-        .stdout(predicate::str::contains("--snippets"))
-        .stdout(predicate::str::contains("--files-only"));
+        .stdout(predicate::str::contains("--top"))
+        .stdout(predicate::str::contains("--json"));
+}
+
+// ============================================================================
+// Bench Command Tests
+// ============================================================================
+
+#[test]
+fn test_bench_help() {
+    prism()
+        .args(["bench", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("--profile"))
+        .stdout(predicate::str::contains("--max-files"))
+        .stdout(predicate::str::contains("--json"));
 }
 
 #[test]
-fn test_search_mode_values() {
+fn test_bench_reports_languages_and_profile() {
+    let repo = tempfile::tempdir().unwrap();
+    std::fs::write(repo.path().join("app.py"), "def main():\n    return 1\n").unwrap();
+    let profile = repo.path().join("profile");
+
     prism()
-        .args(["search", "--help"])
+        .args(["bench", "--json", "--profile"])
+        .arg(&profile)
+        .arg(repo.path())
         .assert()
         .success()
-        .stdout(predicate::str::contains("hybrid"))
-        .stdout(predicate::str::contains("semantic"))
-        .stdout(predicate::str::contains("code"));
+        .stdout(predicate::str::contains("\"languages\""))
+        .stdout(predicate::str::contains("\"python\""));
+    assert!(profile.join("trace.json").exists());
+    assert_eq!(
+        profile.join("heap.json").exists(),
+        cfg!(feature = "bench-alloc")
+    );
 }
 
+#[cfg(not(feature = "bench-alloc"))]
 #[test]
-fn test_search_requires_query() {
+fn test_bench_reports_heap_unavailable() {
+    let repo = tempfile::tempdir().unwrap();
+    std::fs::write(repo.path().join("app.py"), "def main():\n    return 1\n").unwrap();
+
     prism()
-        .args(["search"])
+        .arg("bench")
+        .arg(repo.path())
         .assert()
-        .failure()
-        .stderr(predicate::str::contains("required"));
+        .success()
+        .stdout(predicate::str::contains("Heap: unavailable"));
 }
 
 // ============================================================================
-// Graph Command Tests
+// LSP Command Tests
 // ============================================================================
 
 #[test]
-fn test_graph_help() {
+fn test_lsp_help() {
     prism()
-        .args(["graph", "--help"])
+        .args(["lsp", "--help"])
         .assert()
         .success()
-        .stdout(predicate::str::contains("stats"))
-        .stdout(predicate::str::contains("node"))
-        .stdout(predicate::str::contains("find"))
-        .stdout(predicate::str::contains("edges"))
-        .stdout(predicate::str::contains("connected"))
-        .stdout(predicate::str::contains("code"));
+        .stdout(predicate::str::contains("--stdio"));
 }
 
+// ============================================================================
+// Serve Command Tests
+// ============================================================================
+
 #[test]
-fn test_graph_stats_help() {
+fn test_serve_help() {
     prism()
-        .args(["graph", "stats", "--help"])
+        .args(["serve", "--help"])
         .assert()
         .success()
-        .stdout(predicate::str::contains("statistics"));
+        .stdout(predicate::str::contains("--grpc"))
+        .stdout(predicate::str::contains("--http"));
 }
 
 #[test]
-fn test_graph_node_requires_id() {
+fn test_serve_invalid_address() {
     prism()
-        .args(["graph", "node"])
+        .args(["serve", "--grpc", "not-an-address"])
         .assert()
         .failure()
-        .stderr(predicate::str::contains("required"));
+        .stderr(predicate::str::contains("invalid value"));
 }
 
 #[test]
-fn test_graph_find_help() {
+fn test_ui_help() {
     prism()
-        .args(["graph", "find", "--help"])
+        .args(["ui", "--help"])
         .assert()
         .success()
-        .stdout(predicate::str::contains("pattern"))
-        .stdout(predicate::str::contains("--node-type"))
-        .stdout(predicate::str::contains("--limit"));
+        .stdout(predicate::str::contains("--addr"))
+        .stdout(predicate::str::contains("--open"));
 }
 
 #[test]
-fn test_graph_edges_help() {
+fn test_ui_not_initialized() {
+    let temp = tempfile::TempDir::new().unwrap();
     prism()
-        .args(["graph", "edges", "--help"])
+        .args(["--workspace", temp.path().to_str().unwrap(), "ui"])
+        .assert()
+        .failure();
+}
+
+// ============================================================================
+// Plugin Command Tests
+// ============================================================================
+
+#[test]
+fn test_plugin_help() {
+    prism()
+        .args(["plugin", "--help"])
         .assert()
         .success()
-        .stdout(predicate::str::contains("--direction"))
-        .stdout(predicate::str::contains("--edge-type"));
+        .stdout(predicate::str::contains("list"))
+        .stdout(predicate::str::contains("run"));
 }
 
 #[test]
-fn test_graph_connected_help() {
+fn test_plugin_run_help() {
     prism()
-        .args(["graph", "connected", "--help"])
+        .args(["plugin", "run", "--help"])
         .assert()
         .success()
-        .stdout(predicate::str::contains("--direction"))
-        .stdout(predicate::str::contains("--edge-type"));
+        .stdout(predicate::str::contains("--arg"))
+        .stdout(predicate::str::contains("--json"));
 }
 
 #[test]
-fn test_graph_code_help() {
+fn test_plugin_run_invalid_arg() {
     prism()
-        .args(["graph", "code", "--help"])
+        .args(["plugin", "run", "lint", "todo", "--arg", "noequals"])
+        .assert()
+        .failure()
+        .stderr(predicate::str::contains("KEY=VALUE"));
+}
+
+#[test]
+fn test_plugin_run_unknown_plugin() {
+    let temp = tempfile::TempDir::new().unwrap();
+    prism()
+        .args([
+            "--workspace",
+            temp.path().to_str().unwrap(),
+            "plugin",
+            "run",
+            "missing",
+            "todo",
+        ])
+        .assert()
+        .failure()
+        .stderr(predicate::str::contains("No plugin named 'missing'"));
+}
+
+// ============================================================================
+// Daemon Command Tests
+// ============================================================================
+
+#[test]
+fn test_daemon_help() {
+    prism()
+        .args(["daemon", "--help"])
         .assert()
         .success()
-        .stdout(predicate::str::contains("--context"));
+        .stdout(predicate::str::contains("start"))
+        .stdout(predicate::str::contains("stop"))
+        .stdout(predicate::str::contains("status"));
+}
+
+#[test]
+fn test_daemon_start_help() {
+    prism()
+        .args(["daemon", "start", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("--detach"));
+}
+
+#[cfg(unix)]
+#[test]
+fn test_daemon_status_not_running() {
+    let temp = tempfile::TempDir::new().unwrap();
+    prism()
+        .args([
+            "--workspace",
+            temp.path().to_str().unwrap(),
+            "daemon",
+            "status",
+        ])
+        .assert()
+        .success()
+        .stderr(predicate::str::contains("No daemon running"));
 }
 
 // ============================================================================
@@ -399,6 +1725,129 @@ fn test_doctor_check_option() {
         .success();
 }
 
+// ============================================================================
+// Migrate Command Tests
+// ============================================================================
+
+#[test]
+fn test_migrate_help() {
+    prism()
+        .args(["migrate", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("older version"))
+        .stdout(predicate::str::contains("--dry-run"))
+        .stdout(predicate::str::contains("--json"));
+}
+
+// ============================================================================
+// Validate Command Tests
+// ============================================================================
+
+#[test]
+fn test_validate_help() {
+    prism()
+        .args(["validate", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("dangling edges"))
+        .stdout(predicate::str::contains("--limit"))
+        .stdout(predicate::str::contains("--json"));
+}
+
+// ============================================================================
+// Diagnostics Command Tests
+// ============================================================================
+
+#[test]
+fn test_diagnostics_help() {
+    prism()
+        .args(["diagnostics", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("--severity"))
+        .stdout(predicate::str::contains("--file"))
+        .stdout(predicate::str::contains("--json"));
+}
+
+#[test]
+fn test_diagnostics_rejects_unknown_severity() {
+    prism()
+        .args(["diagnostics", "--severity", "fatal"])
+        .assert()
+        .failure()
+        .stderr(predicate::str::contains("Unknown severity"));
+}
+
+// ============================================================================
+// Debug Command Tests
+// ============================================================================
+
+#[test]
+fn test_debug_parse_help() {
+    prism()
+        .args(["debug", "parse", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("--queries"))
+        .stdout(predicate::str::contains("--json"));
+}
+
+#[test]
+fn test_debug_parse_dumps_file() {
+    let temp = tempfile::TempDir::new().unwrap();
+    std::fs::write(
+        temp.path().join("app.go"),
+        "package app\n\nfunc helper() {}\n\nfunc run() {\n\thelper()\n}\n",
+    )
+    .unwrap();
+
+    prism()
+        .current_dir(temp.path())
+        .args(["debug", "parse", "app.go"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("File: app.go (go)"))
+        .stdout(predicate::str::contains("(source_file [0, 0]"))
+        .stdout(predicate::str::contains(
+            "name.definition.callable.function",
+        ))
+        .stdout(predicate::str::contains("app.go:run -> app.go:helper"));
+}
+
+#[test]
+fn test_debug_parse_missing_file() {
+    prism()
+        .args(["debug", "parse", "does/not/exist.go"])
+        .assert()
+        .failure()
+        .stderr(predicate::str::contains("Failed to read"));
+}
+
+// ============================================================================
+// Todos Command Tests
+// ============================================================================
+
+#[test]
+fn test_todos_help() {
+    prism()
+        .args(["todos", "--help"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("--kind"))
+        .stdout(predicate::str::contains("--author"))
+        .stdout(predicate::str::contains("--json"));
+}
+
+#[test]
+fn test_todos_rejects_unknown_kind() {
+    prism()
+        .args(["todos", "--kind", "note"])
+        .assert()
+        .failure()
+        .stderr(predicate::str::contains("Unknown marker"));
+}
+
 // ============================================================================
 // Clean Command Tests
 // ============================================================================
@@ -432,6 +1881,46 @@ fn test_clean_force_option() {
         .success();
 }
 
+#[test]
+fn test_clean_keeps_history() {
+    let temp = tempfile::TempDir::new().unwrap();
+    let prism_dir = temp.path().join(".codeprysm");
+    std::fs::create_dir_all(prism_dir.join("partitions")).unwrap();
+    std::fs::write(prism_dir.join("manifest.json"), "{}").unwrap();
+    std::fs::write(prism_dir.join("partitions/p.db"), "partition").unwrap();
+    std::fs::write(prism_dir.join("history.db"), "history").unwrap();
+    let workspace = temp.path().to_str().unwrap();
+
+    prism()
+        .args([
+            "--workspace",
+            workspace,
+            "clean",
+            "--force",
+            "--local-only",
+            "--json",
+        ])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains(r#""kept_history": true"#));
+    assert!(prism_dir.join("history.db").exists());
+    assert!(!prism_dir.join("manifest.json").exists());
+    assert!(!prism_dir.join("partitions").exists());
+
+    prism()
+        .args([
+            "--workspace",
+            workspace,
+            "clean",
+            "--force",
+            "--local-only",
+            "--history",
+        ])
+        .assert()
+        .success();
+    assert!(!prism_dir.exists());
+}
+
 // ============================================================================
 // Backend Command Tests
 // ============================================================================
@@ -806,6 +806,31 @@ fn test_config_get_json() {
         .stdout(predicate::str::contains("\"http://localhost:6334\""));
 }
 
+#[test]
+#[ignore = "Integration test - run with --ignored"]
+fn test_config_get_from_project_file() {
+    let workspace = setup_workspace("rust-workspace");
+    std::fs::write(
+        workspace.path().join(".codeprysm.toml"),
+        "[analysis.languages.rust]\ntarget = \"linux\"\n\n[output]\nlimit = 25\n",
+    )
+    .unwrap();
+
+    // Values from the shared project config are picked up
+    prism()
+        .current_dir(workspace.path())
+        .args(["config", "get", "output.limit"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("25"));
+    prism()
+        .current_dir(workspace.path())
+        .args(["config", "get", "analysis.languages.rust.target"])
+        .assert()
+        .success()
+        .stdout(predicate::str::contains("linux"));
+}
+
 #[test]
 #[ignore = "Integration test - run with --ignored"]
 fn test_config_get_unknown_key() {
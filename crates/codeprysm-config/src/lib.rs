@@ -2,19 +2,20 @@
 //!
 //! Provides configuration loading with support for:
 //! - Global config: `~/.codeprysm/config.toml`
+//! - Project config: `.codeprysm.toml` (at the repository root, committed)
 //! - Local config: `.codeprysm/config.toml` (in workspace)
 //! - CLI overrides via `ConfigOverrides`
 //!
-//! Configuration is merged in order: global → local → CLI overrides.
+//! Configuration is merged in order: global → project → local → CLI overrides.
 
 mod error;
 mod loader;
 
 pub use error::ConfigError;
-pub use loader::ConfigLoader;
+pub use loader::{ConfigLoader, PROJECT_CONFIG_FILE};
 
 use serde::{Deserialize, Serialize};
-use std::collections::HashMap;
+use std::collections::{BTreeMap, HashMap};
 use std::path::PathBuf;
 
 /// Root configuration for CodePrism.
@@ -40,6 +41,31 @@ pub struct PrismConfig {
 
     /// Logging configuration
     pub logging: LoggingConfig,
+
+    /// Policies enforced by `codeprysm check`
+    pub check: CheckConfig,
+
+    /// Webhooks notified by `codeprysm watch` when watched symbols change
+    pub webhooks: Vec<WebhookConfig>,
+
+    /// External extractor and analysis plugins
+    pub plugins: Vec<PluginConfig>,
+
+    /// Rules tagging matching nodes with user-defined attributes
+    pub attributes: Vec<AttributeRuleConfig>,
+
+    /// Output defaults for query commands
+    pub output: OutputConfig,
+
+    /// Access control for `codeprysm serve` and `codeprysm ui`
+    pub server: ServerConfig,
+
+    /// Permalinks of nodes on the repository's code host
+    pub links: LinksConfig,
+
+    /// Model-written symbol summaries (off if unset)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub summaries: Option<SummaryConfig>,
 }
 
 /// Embedding provider configuration.
@@ -261,6 +287,10 @@ pub struct StorageConfig {
 
     /// Maximum partition size in MB (for lazy loading)
     pub max_partition_size_mb: u32,
+
+    /// Memory budget in MB for partitions loaded by queries; least recently
+    /// used partitions are unloaded beyond it
+    pub partition_cache_mb: u32,
 }
 
 impl Default for StorageConfig {
@@ -270,6 +300,7 @@ impl Default for StorageConfig {
             graph_format: GraphFormat::default(),
             compression: false,
             max_partition_size_mb: 100,
+            partition_cache_mb: 512,
         }
     }
 }
@@ -283,6 +314,9 @@ pub enum GraphFormat {
     Sqlite,
     /// JSON format (for debugging/export)
     Json,
+    /// SQLite partitions plus a memory-mapped graph store (`graph.cpg`) that
+    /// node and edge queries read directly, for graphs larger than RAM
+    Mmap,
 }
 
 /// Backend configuration for search and query operations.
@@ -384,7 +418,39 @@ pub struct AnalysisConfig {
     /// Parallelism level (0 = auto-detect)
     pub parallelism: usize,
 
-    /// Language-specific settings
+    /// Whether generated files are indexed
+    pub generated: GeneratedCodePolicy,
+
+    /// Cyclomatic complexity limit `codeprysm metrics functions` enforces
+    /// unless `--max-cyclomatic` is given
+    pub max_cyclomatic: Option<u32>,
+
+    /// Cognitive complexity limit `codeprysm metrics functions` enforces
+    /// unless `--max-cognitive` is given
+    pub max_cognitive: Option<u32>,
+
+    /// Glob patterns over Go symbols used by consumers outside the
+    /// workspace, which `codeprysm analyze unused-exports` never reports
+    /// (e.g., "pkg/client.*")
+    pub external_api: Vec<String>,
+
+    /// Resolve Go references with the Go type checker in addition to the
+    /// name-based resolver (slower, more accurate)
+    pub go_type_check: bool,
+
+    /// Command running the Go type checker, given the repository root as
+    /// its last argument (default: `codeprysm-gotypes`)
+    pub go_type_checker: Option<Vec<String>>,
+
+    /// Maximum symbols kept per package; the symbols of files over the
+    /// limit are left out behind a truncation marker
+    pub max_symbols_per_package: Option<usize>,
+
+    /// Maximum symbols kept in the whole workspace
+    pub max_symbols: Option<usize>,
+
+    /// Language-specific settings, keyed by language name ("python",
+    /// "javascript", "typescript", "rust", "go", "c", "cpp", "csharp")
     pub languages: HashMap<String, LanguageConfig>,
 }
 
@@ -404,20 +470,450 @@ impl Default for AnalysisConfig {
             include_patterns: Vec::new(),
             detect_components: true,
             parallelism: 0, // auto-detect
+            generated: GeneratedCodePolicy::default(),
+            max_cyclomatic: None,
+            max_cognitive: None,
+            external_api: Vec::new(),
+            go_type_check: false,
+            go_type_checker: None,
+            max_symbols_per_package: None,
+            max_symbols: None,
             languages: HashMap::new(),
         }
     }
 }
 
 /// Language-specific configuration.
-#[derive(Debug, Clone, Serialize, Deserialize, Default)]
+///
+/// # Example TOML
+///
+/// ```toml
+/// [analysis.languages.go]
+/// target = "linux/amd64"
+/// exclude_patterns = ["**/testdata/**"]
+/// generated = "skip"
+/// disabled_edges = ["refers_by_name"]
+/// query_files = [".codeprysm/queries/go-registry.scm"]
+/// ```
+#[derive(Debug, Clone, Serialize, Deserialize)]
 #[serde(default)]
 pub struct LanguageConfig {
     /// Enable this language
     pub enabled: bool,
 
-    /// Custom query file path
+    /// Query file appended to the built-in queries (same as a single
+    /// `query_files` entry)
     pub query_file: Option<PathBuf>,
+
+    /// Tree-sitter query files appended to the built-in queries, relative to
+    /// the workspace root; their captures follow the SCM tag naming
+    /// convention
+    pub query_files: Vec<PathBuf>,
+
+    /// Edge types not extracted for this language (e.g., "refers_by_name");
+    /// CONTAINS edges cannot be disabled
+    pub disabled_edges: Vec<String>,
+
+    /// Target platform as "os" or "os/arch" (e.g., "linux/amd64"); files
+    /// named for another platform (`poll_windows.go`) are skipped
+    pub target: Option<String>,
+
+    /// Only index files of this language matching these patterns
+    pub include_patterns: Vec<String>,
+
+    /// File patterns of this language to exclude (glob patterns)
+    pub exclude_patterns: Vec<String>,
+
+    /// Whether generated files of this language are indexed (defaults to
+    /// `analysis.generated`)
+    pub generated: Option<GeneratedCodePolicy>,
+}
+
+impl Default for LanguageConfig {
+    fn default() -> Self {
+        Self {
+            enabled: true,
+            query_file: None,
+            query_files: Vec::new(),
+            disabled_edges: Vec::new(),
+            target: None,
+            include_patterns: Vec::new(),
+            exclude_patterns: Vec::new(),
+            generated: None,
+        }
+    }
+}
+
+/// Policy for generated files.
+///
+/// A file is generated if a header comment says so, e.g.
+/// `// Code generated by protoc-gen-go. DO NOT EDIT.` or `@generated`.
+#[derive(Debug, Clone, Copy, Serialize, Deserialize, Default, PartialEq, Eq)]
+#[serde(rename_all = "lowercase")]
+pub enum GeneratedCodePolicy {
+    /// Index generated files like any other (default)
+    #[default]
+    Index,
+    /// Leave generated files out of the graph
+    Skip,
+}
+
+/// Output defaults for query commands, overridden by command-line flags.
+///
+/// # Example TOML
+///
+/// ```toml
+/// [output]
+/// format = "json"
+/// limit = 25
+/// ```
+#[derive(Debug, Clone, Serialize, Deserialize, Default, PartialEq, Eq)]
+#[serde(default)]
+pub struct OutputConfig {
+    /// Default output format
+    pub format: OutputFormat,
+
+    /// Default maximum number of search results
+    pub limit: Option<usize>,
+}
+
+/// Output format.
+#[derive(Debug, Clone, Copy, Serialize, Deserialize, Default, PartialEq, Eq)]
+#[serde(rename_all = "lowercase")]
+pub enum OutputFormat {
+    /// Human-readable text (default)
+    #[default]
+    Text,
+    /// JSON
+    Json,
+}
+
+/// Permalink URLs of nodes on the repository's code host, set as the `url`
+/// of nodes in exports and server responses.
+///
+/// # Example TOML
+///
+/// ```toml
+/// [links]
+/// # "github", "gitlab", "bitbucket", "auto" (from the remote's host), or a
+/// # template with {remote}, {commit}, {path}, {line}, and {end_line}
+/// template = "github"
+/// # Repository URL (default: the `origin` remote)
+/// remote = "https://github.com/org/repo"
+/// ```
+#[derive(Debug, Clone, Serialize, Deserialize, Default, PartialEq, Eq)]
+#[serde(default)]
+pub struct LinksConfig {
+    /// Link template or preset name (off if unset)
+    pub template: Option<String>,
+
+    /// Repository URL filled in for `{remote}`
+    pub remote: Option<String>,
+}
+
+/// Policies enforced by `codeprysm check` on staged or uncommitted changes.
+///
+/// Each policy only fails on findings the changes introduce; existing
+/// findings in the index are not reported.
+///
+/// # Example TOML
+///
+/// ```toml
+/// [check]
+/// dead_code = true
+/// internal_boundaries = true
+/// architecture_rules = "arch.codeprysm.yaml"
+/// max_cyclomatic = 15
+/// max_cognitive = 20
+/// ```
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(default)]
+pub struct CheckConfig {
+    /// Fail on symbols that become unreachable
+    pub dead_code: bool,
+
+    /// Fail on references into `internal` directories from outside their tree
+    pub internal_boundaries: bool,
+
+    /// Fail on dependencies breaking the layering rules in this file,
+    /// relative to the workspace root (skipped if the file does not exist)
+    pub architecture_rules: String,
+
+    /// Fail when a function's cyclomatic complexity grows past this limit
+    pub max_cyclomatic: Option<u32>,
+
+    /// Fail when a function's cognitive complexity grows past this limit
+    pub max_cognitive: Option<u32>,
+}
+
+impl Default for CheckConfig {
+    fn default() -> Self {
+        Self {
+            dead_code: true,
+            internal_boundaries: true,
+            architecture_rules: "arch.codeprysm.yaml".to_string(),
+            max_cyclomatic: None,
+            max_cognitive: None,
+        }
+    }
+}
+
+/// A webhook notified when watched symbols change.
+///
+/// After each index update, `codeprysm watch` POSTs the events for the
+/// symbols matching `symbols` or `packages` as one JSON body.
+///
+/// # Example TOML
+///
+/// ```toml
+/// [[webhooks]]
+/// url = "https://hooks.example.com/codeprysm"
+/// symbols = ["pkg/client/client.go:Client:*"]
+/// packages = ["pkg/api"]
+/// events = ["signature_changed", "caller_added"]
+/// ```
+#[derive(Debug, Clone, Serialize, Deserialize, Default, PartialEq, Eq)]
+#[serde(default)]
+pub struct WebhookConfig {
+    /// URL that receives the events
+    pub url: String,
+
+    /// Node ID glob patterns of the symbols to watch
+    pub symbols: Vec<String>,
+
+    /// Package directories whose symbols to watch, including subpackages
+    pub packages: Vec<String>,
+
+    /// Events to send: "signature_changed", "caller_added", "removed"
+    /// (all if empty)
+    pub events: Vec<String>,
+}
+
+/// An external plugin program.
+///
+/// Plugins speak JSON-RPC over stdin and stdout (see
+/// `codeprysm_core::plugin`). Extractor plugins add nodes and edges during
+/// `codeprysm init` and `codeprysm update`; analysis plugins run with
+/// `codeprysm plugin run`.
+///
+/// # Example TOML
+///
+/// ```toml
+/// [[plugins]]
+/// name = "routes"
+/// command = ["python3", "tools/codeprysm_routes.py"]
+/// ```
+#[derive(Debug, Clone, Serialize, Deserialize, Default, PartialEq, Eq)]
+#[serde(default)]
+pub struct PluginConfig {
+    /// Name used to refer to the plugin
+    pub name: String,
+
+    /// Program and arguments, run from the workspace root
+    pub command: Vec<String>,
+}
+
+/// A rule tagging matching nodes with user-defined attributes.
+///
+/// Patterns are globs; a rule without `paths` or without `names` matches
+/// any path or name. When several rules set the same attribute on a node,
+/// the last one wins. Attributes can be filtered on by `codeprysm graph find
+/// --attr`, `codeprysm search --attr`, and the server and MCP queries.
+///
+/// # Example TOML
+///
+/// ```toml
+/// [[attributes]]
+/// paths = ["internal/payments/**"]
+/// attributes = { team = "payments", layer = "domain" }
+///
+/// [[attributes]]
+/// names = ["*Handler"]
+/// attributes = { layer = "api" }
+/// ```
+#[derive(Debug, Clone, Serialize, Deserialize, Default, PartialEq, Eq)]
+#[serde(default)]
+pub struct AttributeRuleConfig {
+    /// Glob patterns matched against node file paths (any if empty)
+    pub paths: Vec<String>,
+
+    /// Glob patterns matched against node names (any if empty)
+    pub names: Vec<String>,
+
+    /// Attributes set on matching nodes
+    pub attributes: BTreeMap<String, String>,
+}
+
+/// Symbol summaries written by a language model.
+///
+/// When set, `codeprysm init` and `codeprysm update` ask an OpenAI-compatible
+/// chat completions endpoint (OpenAI, Azure OpenAI, Ollama, llama.cpp, etc.)
+/// for a one-paragraph summary of each function, method, and type, and store
+/// it as the node's `summary` attribute. Summaries are cached by the content
+/// hash of the symbol, so only new and changed symbols are sent.
+///
+/// # Example TOML
+///
+/// ```toml
+/// [summaries]
+/// url = "http://localhost:11434/v1"
+/// model = "qwen2.5-coder:7b"
+/// api_key_env = ""
+/// paths = ["internal/**", "pkg/**"]
+/// ```
+#[derive(Debug, Clone, Serialize, Deserialize, PartialEq, Eq)]
+#[serde(default)]
+pub struct SummaryConfig {
+    /// API base URL (e.g., "https://api.openai.com/v1")
+    pub url: String,
+
+    /// Environment variable name containing the API key (none if empty)
+    pub api_key_env: Option<String>,
+
+    /// Chat model writing the summaries
+    pub model: String,
+
+    /// Instructions sent ahead of each symbol (a built-in prompt if unset)
+    pub prompt: Option<String>,
+
+    /// Glob patterns of the files whose symbols to summarize (all if empty)
+    pub paths: Vec<String>,
+
+    /// Symbols shorter than this many lines are not summarized
+    pub min_lines: usize,
+
+    /// Lines of a symbol's source sent at most
+    pub max_lines: usize,
+
+    /// Requests in flight at once
+    pub concurrency: usize,
+
+    /// Request timeout in seconds
+    pub timeout_secs: u64,
+
+    /// Maximum retry attempts
+    pub max_retries: u32,
+
+    /// Use Azure OpenAI authentication (api-key header)
+    pub azure_mode: bool,
+}
+
+impl Default for SummaryConfig {
+    fn default() -> Self {
+        Self {
+            url: "https://api.openai.com/v1".to_string(),
+            api_key_env: Some("OPENAI_API_KEY".to_string()),
+            model: "gpt-4o-mini".to_string(),
+            prompt: None,
+            paths: Vec::new(),
+            min_lines: 3,
+            max_lines: 200,
+            concurrency: 4,
+            timeout_secs: 60,
+            max_retries: 2,
+            azure_mode: false,
+        }
+    }
+}
+
+/// Access control for the query servers.
+///
+/// Without tokens, the servers answer every request. With tokens, each
+/// request must present one as `Authorization: Bearer <token>`, and sees only
+/// the files its token allows. With `tls`, the servers only accept TLS
+/// connections, and with `tls.client_ca` only from clients presenting a
+/// certificate signed by that CA (mutual TLS). `cache_entries` sizes the
+/// cache of traversal query responses.
+///
+/// # Example TOML
+///
+/// ```toml
+/// [[server.tokens]]
+/// name = "payments-team"
+/// token_env = "CODEPRYSM_PAYMENTS_TOKEN"
+/// paths = ["services/payments/**", "pkg/**"]
+///
+/// [[server.tokens]]
+/// name = "ops"
+/// token_env = "CODEPRYSM_OPS_TOKEN"
+/// scope = "admin"
+///
+/// [server.tls]
+/// cert = "/etc/codeprysm/server.pem"
+/// key = "/etc/codeprysm/server.key"
+/// client_ca = "/etc/codeprysm/clients-ca.pem"
+///
+/// [server]
+/// cache_entries = 4096
+/// ```
+#[derive(Debug, Clone, Serialize, Deserialize, Default, PartialEq, Eq)]
+#[serde(default)]
+pub struct ServerConfig {
+    /// Tokens accepted by the servers (no authentication if empty)
+    pub tokens: Vec<TokenConfig>,
+
+    /// TLS settings (plain connections if unset)
+    pub tls: Option<TlsConfig>,
+
+    /// Responses kept in the query cache (the server's default if unset, 0
+    /// disables caching)
+    pub cache_entries: Option<usize>,
+}
+
+/// A token accepted by the query servers.
+#[derive(Debug, Clone, Serialize, Deserialize, Default, PartialEq, Eq)]
+#[serde(default)]
+pub struct TokenConfig {
+    /// Name of the token, shown in logs
+    pub name: String,
+
+    /// Environment variable holding the token
+    pub token_env: Option<String>,
+
+    /// The token itself (prefer `token_env` to keep it out of config files)
+    pub token: Option<String>,
+
+    /// "read" (query the graph) or "admin" (also read metrics)
+    pub scope: TokenScope,
+
+    /// Glob patterns of the files the token may see (all if empty)
+    pub paths: Vec<String>,
+}
+
+impl TokenConfig {
+    /// Resolve the token, from `token_env` if set, otherwise `token`.
+    pub fn secret(&self) -> Option<String> {
+        match self.token_env {
+            Some(ref var) => std::env::var(var).ok().filter(|s| !s.is_empty()),
+            None => self.token.clone().filter(|s| !s.is_empty()),
+        }
+    }
+}
+
+/// What a server token may do.
+#[derive(Debug, Clone, Copy, Serialize, Deserialize, Default, PartialEq, Eq)]
+#[serde(rename_all = "lowercase")]
+pub enum TokenScope {
+    /// Query the graph
+    #[default]
+    Read,
+    /// Query the graph and read server metrics
+    Admin,
+}
+
+/// TLS settings of the query servers.
+#[derive(Debug, Clone, Serialize, Deserialize, Default, PartialEq, Eq)]
+#[serde(default)]
+pub struct TlsConfig {
+    /// PEM file with the server certificate chain
+    pub cert: PathBuf,
+
+    /// PEM file with the server private key
+    pub key: PathBuf,
+
+    /// PEM file with the CAs client certificates must be signed by
+    /// (clients are not asked for certificates if unset)
+    pub client_ca: Option<PathBuf>,
 }
 
 /// Workspace configuration for multi-repo support.
@@ -479,6 +975,21 @@ pub enum LogFormat {
     Json,
 }
 
+impl std::str::FromStr for LogFormat {
+    type Err = ConfigError;
+
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        match s.to_lowercase().as_str() {
+            "text" => Ok(Self::Text),
+            "json" => Ok(Self::Json),
+            _ => Err(ConfigError::ValidationError(format!(
+                "Unknown log format: '{}'. Valid values: text, json",
+                s
+            ))),
+        }
+    }
+}
+
 /// CLI overrides for configuration values.
 ///
 /// Used to apply command-line arguments over file-based config.
@@ -557,6 +1068,7 @@ impl PrismConfig {
         match self.storage.graph_format {
             GraphFormat::Sqlite => prism_dir.join("manifest.json"),
             GraphFormat::Json => prism_dir.join("graph.json"),
+            GraphFormat::Mmap => prism_dir.join("graph.cpg"),
         }
     }
 }
@@ -630,6 +1142,16 @@ mod tests {
         assert_eq!(path, PathBuf::from("/project/.codeprysm/graph.json"));
     }
 
+    #[test]
+    fn test_graph_path_mmap() {
+        let mut config = PrismConfig::default();
+        config.storage.graph_format = GraphFormat::Mmap;
+        let workspace = PathBuf::from("/project");
+
+        let path = config.graph_path(&workspace);
+        assert_eq!(path, PathBuf::from("/project/.codeprysm/graph.cpg"));
+    }
+
     #[test]
     fn test_embedding_config_default() {
         let config = EmbeddingConfig::default();
@@ -773,4 +1295,22 @@ mod tests {
         assert_eq!(azure_ml.code_auth_key_env, Some("MY_CODE_KEY".to_string()));
         assert_eq!(azure_ml.timeout_secs, 60);
     }
+
+    #[test]
+    fn test_summary_config_partial_toml() {
+        let config: PrismConfig = toml::from_str(
+            r#"
+            [summaries]
+            url = "http://localhost:11434/v1"
+            model = "qwen2.5-coder:7b"
+            "#,
+        )
+        .unwrap();
+
+        let summaries = config.summaries.unwrap();
+        assert_eq!(summaries.url, "http://localhost:11434/v1");
+        assert_eq!(summaries.model, "qwen2.5-coder:7b");
+        assert_eq!(summaries.concurrency, 4);
+        assert!(PrismConfig::default().summaries.is_none());
+    }
 }
@@ -2,8 +2,10 @@
 //!
 //! Loads configuration from multiple sources and merges them:
 //! 1. Global config: `~/.codeprysm/config.toml`
-//! 2. Local config: `.codeprysm/config.toml` (in workspace)
-//! 3. CLI overrides
+//! 2. Project config: `.codeprysm.toml` (at the repository root, meant to be
+//!    committed so a team shares it)
+//! 3. Local config: `.codeprysm/config.toml` (in workspace)
+//! 4. CLI overrides
 //!
 //! Later sources override earlier ones.
 
@@ -21,6 +23,9 @@ const GLOBAL_CONFIG_DIR: &str = ".codeprysm";
 /// Local configuration directory name.
 const LOCAL_CONFIG_DIR: &str = ".codeprysm";
 
+/// Project configuration file name, at the workspace root.
+pub const PROJECT_CONFIG_FILE: &str = ".codeprysm.toml";
+
 /// Configuration loader with caching and inheritance support.
 #[derive(Debug, Clone)]
 pub struct ConfigLoader {
@@ -72,9 +77,14 @@ impl ConfigLoader {
         workspace_root.join(LOCAL_CONFIG_DIR).join(CONFIG_FILE_NAME)
     }
 
+    /// Get the project config file path for a workspace.
+    pub fn project_config_path(&self, workspace_root: &Path) -> PathBuf {
+        workspace_root.join(PROJECT_CONFIG_FILE)
+    }
+
     /// Load configuration for a workspace with optional CLI overrides.
     ///
-    /// Merges config in order: global → local → overrides.
+    /// Merges config in order: global → project → local → overrides.
     pub fn load(
         &mut self,
         workspace_root: &Path,
@@ -88,6 +98,11 @@ impl ConfigLoader {
             config = merge_configs(config, global_config);
         }
 
+        // Apply project config if available
+        if let Some(project_config) = self.load_project(workspace_root)? {
+            config = merge_configs(config, project_config);
+        }
+
         // Apply local config if available
         if let Some(local_config) = self.load_local(workspace_root)? {
             config = merge_configs(config, local_config);
@@ -140,6 +155,19 @@ impl ConfigLoader {
         load_config_file(&local_path).map(Some)
     }
 
+    /// Load only the project configuration (`.codeprysm.toml`) for a workspace.
+    pub fn load_project(&self, workspace_root: &Path) -> Result<Option<PrismConfig>, ConfigError> {
+        let project_path = self.project_config_path(workspace_root);
+
+        if !project_path.exists() {
+            trace!("Project config not found at {:?}", project_path);
+            return Ok(None);
+        }
+
+        debug!("Loading project config from {:?}", project_path);
+        load_config_file(&project_path).map(Some)
+    }
+
     /// Save configuration to the global config file.
     pub fn save_global(&self, config: &PrismConfig) -> Result<(), ConfigError> {
         let Some(ref global_dir) = self.global_config_dir else {
@@ -243,6 +271,17 @@ fn merge_configs(base: PrismConfig, overlay: PrismConfig) -> PrismConfig {
         analysis: merge_analysis(base.analysis, overlay.analysis),
         workspace: merge_workspace(base.workspace, overlay.workspace),
         logging: merge_logging(base.logging, overlay.logging),
+        check: merge_check(base.check, overlay.check),
+        webhooks: merge_webhooks(base.webhooks, overlay.webhooks),
+        plugins: merge_plugins(base.plugins, overlay.plugins),
+        attributes: merge_attributes(base.attributes, overlay.attributes),
+        output: merge_output(base.output, overlay.output),
+        server: merge_server(base.server, overlay.server),
+        links: crate::LinksConfig {
+            template: overlay.links.template.or(base.links.template),
+            remote: overlay.links.remote.or(base.links.remote),
+        },
+        summaries: overlay.summaries.or(base.summaries),
     }
 }
 
@@ -258,13 +297,22 @@ fn merge_storage(
         } else {
             base.prism_dir
         },
-        graph_format: overlay.graph_format, // Always use overlay
+        graph_format: if overlay.graph_format != crate::GraphFormat::default() {
+            overlay.graph_format
+        } else {
+            base.graph_format
+        },
         compression: overlay.compression,
         max_partition_size_mb: if overlay.max_partition_size_mb != 100 {
             overlay.max_partition_size_mb
         } else {
             base.max_partition_size_mb
         },
+        partition_cache_mb: if overlay.partition_cache_mb != 512 {
+            overlay.partition_cache_mb
+        } else {
+            base.partition_cache_mb
+        },
     }
 }
 
@@ -363,6 +411,28 @@ fn merge_analysis(
         } else {
             base.parallelism
         },
+        generated: if overlay.generated != crate::GeneratedCodePolicy::Index {
+            overlay.generated
+        } else {
+            base.generated
+        },
+        max_cyclomatic: overlay.max_cyclomatic.or(base.max_cyclomatic),
+        max_cognitive: overlay.max_cognitive.or(base.max_cognitive),
+        external_api: {
+            let mut patterns = base.external_api;
+            for pattern in overlay.external_api {
+                if !patterns.contains(&pattern) {
+                    patterns.push(pattern);
+                }
+            }
+            patterns
+        },
+        go_type_check: overlay.go_type_check || base.go_type_check,
+        go_type_checker: overlay.go_type_checker.or(base.go_type_checker),
+        max_symbols_per_package: overlay
+            .max_symbols_per_package
+            .or(base.max_symbols_per_package),
+        max_symbols: overlay.max_symbols.or(base.max_symbols),
         languages: {
             let mut langs = base.languages;
             langs.extend(overlay.languages);
@@ -403,6 +473,86 @@ fn merge_logging(
     }
 }
 
+/// Merge check config.
+fn merge_check(base: crate::CheckConfig, overlay: crate::CheckConfig) -> crate::CheckConfig {
+    crate::CheckConfig {
+        dead_code: overlay.dead_code,
+        internal_boundaries: overlay.internal_boundaries,
+        architecture_rules: overlay.architecture_rules,
+        max_cyclomatic: overlay.max_cyclomatic.or(base.max_cyclomatic),
+        max_cognitive: overlay.max_cognitive.or(base.max_cognitive),
+    }
+}
+
+/// Merge webhooks: overlay webhooks are added to the base ones.
+fn merge_webhooks(
+    base: Vec<crate::WebhookConfig>,
+    overlay: Vec<crate::WebhookConfig>,
+) -> Vec<crate::WebhookConfig> {
+    let mut webhooks = base;
+    for webhook in overlay {
+        if !webhooks.contains(&webhook) {
+            webhooks.push(webhook);
+        }
+    }
+    webhooks
+}
+
+/// Merge attribute rules: overlay rules follow the base ones, so they win
+/// where both set an attribute.
+fn merge_attributes(
+    base: Vec<crate::AttributeRuleConfig>,
+    overlay: Vec<crate::AttributeRuleConfig>,
+) -> Vec<crate::AttributeRuleConfig> {
+    let mut rules: Vec<crate::AttributeRuleConfig> = base
+        .into_iter()
+        .filter(|rule| !overlay.contains(rule))
+        .collect();
+    rules.extend(overlay);
+    rules
+}
+
+/// Merge plugins: overlay plugins replace base plugins of the same name.
+fn merge_plugins(
+    base: Vec<crate::PluginConfig>,
+    overlay: Vec<crate::PluginConfig>,
+) -> Vec<crate::PluginConfig> {
+    let mut plugins: Vec<crate::PluginConfig> = base
+        .into_iter()
+        .filter(|plugin| !overlay.iter().any(|o| o.name == plugin.name))
+        .collect();
+    plugins.extend(overlay);
+    plugins
+}
+
+/// Merge server config: overlay tokens replace base tokens of the same name,
+/// and overlay TLS and cache settings replace the base ones.
+fn merge_server(base: crate::ServerConfig, overlay: crate::ServerConfig) -> crate::ServerConfig {
+    let mut tokens: Vec<crate::TokenConfig> = base
+        .tokens
+        .into_iter()
+        .filter(|token| !overlay.tokens.iter().any(|o| o.name == token.name))
+        .collect();
+    tokens.extend(overlay.tokens);
+    crate::ServerConfig {
+        tokens,
+        tls: overlay.tls.or(base.tls),
+        cache_entries: overlay.cache_entries.or(base.cache_entries),
+    }
+}
+
+/// Merge output config.
+fn merge_output(base: crate::OutputConfig, overlay: crate::OutputConfig) -> crate::OutputConfig {
+    crate::OutputConfig {
+        format: if overlay.format != crate::OutputFormat::Text {
+            overlay.format
+        } else {
+            base.format
+        },
+        limit: overlay.limit.or(base.limit),
+    }
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
@@ -512,6 +662,86 @@ mod tests {
         assert_eq!(config.logging.level, "debug");
     }
 
+    #[test]
+    fn test_project_config_between_global_and_local() {
+        let temp = TempDir::new().unwrap();
+        let global_dir = temp.path().join("global");
+
+        std::fs::create_dir_all(&global_dir).unwrap();
+        std::fs::write(
+            global_dir.join("config.toml"),
+            r#"
+            [output]
+            limit = 50
+
+            [links]
+            template = "gitlab"
+            remote = "https://git.example.com/org/repo"
+
+            [backend.qdrant]
+            url = "http://global:6334"
+            "#,
+        )
+        .unwrap();
+
+        std::fs::write(
+            temp.path().join(".codeprysm.toml"),
+            r#"
+            [analysis]
+            generated = "skip"
+            max_cyclomatic = 15
+
+            [analysis.languages.go]
+            target = "linux/amd64"
+            exclude_patterns = ["**/testdata/**"]
+            disabled_edges = ["refers_by_name"]
+            query_files = ["queries/go-registry.scm"]
+
+            [output]
+            format = "json"
+            limit = 25
+
+            [links]
+            template = "github"
+
+            [backend.qdrant]
+            url = "http://project:6334"
+            "#,
+        )
+        .unwrap();
+
+        create_test_config(
+            r#"
+            [backend.qdrant]
+            url = "http://local:6334"
+            "#,
+            temp.path(),
+            "config.toml",
+        );
+
+        let mut loader = ConfigLoader::with_global_dir(&global_dir);
+        let config = loader.load(temp.path(), None).unwrap();
+
+        // Project overrides global, local overrides project
+        assert_eq!(config.output.limit, Some(25));
+        assert_eq!(config.output.format, crate::OutputFormat::Json);
+        assert_eq!(config.backend.qdrant.url, "http://local:6334");
+        assert_eq!(config.links.template.as_deref(), Some("github"));
+        assert_eq!(
+            config.links.remote.as_deref(),
+            Some("https://git.example.com/org/repo")
+        );
+
+        assert_eq!(config.analysis.generated, crate::GeneratedCodePolicy::Skip);
+        assert_eq!(config.analysis.max_cyclomatic, Some(15));
+        let go = &config.analysis.languages["go"];
+        assert!(go.enabled);
+        assert_eq!(go.target.as_deref(), Some("linux/amd64"));
+        assert_eq!(go.exclude_patterns, ["**/testdata/**"]);
+        assert_eq!(go.disabled_edges, ["refers_by_name"]);
+        assert_eq!(go.query_files, [PathBuf::from("queries/go-registry.scm")]);
+    }
+
     #[test]
     fn test_cli_overrides_all() {
         let temp = TempDir::new().unwrap();
@@ -599,6 +829,124 @@ mod tests {
             .contains(&"**/custom/**".to_string()));
     }
 
+    #[test]
+    fn test_check_merge() {
+        let base = crate::CheckConfig {
+            max_cyclomatic: Some(15),
+            ..Default::default()
+        };
+        let overlay = crate::CheckConfig {
+            dead_code: false,
+            max_cognitive: Some(20),
+            ..Default::default()
+        };
+
+        let merged = merge_check(base, overlay);
+
+        assert!(!merged.dead_code);
+        assert!(merged.internal_boundaries);
+        assert_eq!(merged.max_cyclomatic, Some(15));
+        assert_eq!(merged.max_cognitive, Some(20));
+    }
+
+    #[test]
+    fn test_webhooks_merge() {
+        let hook = |url: &str| crate::WebhookConfig {
+            url: url.to_string(),
+            packages: vec!["pkg/api".to_string()],
+            ..Default::default()
+        };
+
+        let merged = merge_webhooks(
+            vec![hook("https://a")],
+            vec![hook("https://a"), hook("https://b")],
+        );
+
+        assert_eq!(merged, vec![hook("https://a"), hook("https://b")]);
+    }
+
+    #[test]
+    fn test_plugins_merge() {
+        let plugin = |name: &str, program: &str| crate::PluginConfig {
+            name: name.to_string(),
+            command: vec![program.to_string()],
+        };
+
+        let merged = merge_plugins(
+            vec![plugin("routes", "old"), plugin("lint", "lint")],
+            vec![plugin("routes", "new")],
+        );
+
+        assert_eq!(
+            merged,
+            vec![plugin("lint", "lint"), plugin("routes", "new")]
+        );
+    }
+
+    #[test]
+    fn test_server_merge() {
+        let token = |name: &str, scope: crate::TokenScope| crate::TokenConfig {
+            name: name.to_string(),
+            token_env: Some(format!("{}_TOKEN", name.to_uppercase())),
+            scope,
+            ..Default::default()
+        };
+        let tls = crate::TlsConfig {
+            cert: PathBuf::from("server.pem"),
+            key: PathBuf::from("server.key"),
+            client_ca: None,
+        };
+
+        let merged = merge_server(
+            crate::ServerConfig {
+                tokens: vec![
+                    token("ci", crate::TokenScope::Read),
+                    token("ops", crate::TokenScope::Read),
+                ],
+                tls: Some(tls.clone()),
+                cache_entries: Some(4096),
+            },
+            crate::ServerConfig {
+                tokens: vec![token("ops", crate::TokenScope::Admin)],
+                tls: None,
+                cache_entries: Some(0),
+            },
+        );
+
+        assert_eq!(
+            merged.tokens,
+            vec![
+                token("ci", crate::TokenScope::Read),
+                token("ops", crate::TokenScope::Admin)
+            ]
+        );
+        assert_eq!(merged.tls, Some(tls));
+        assert_eq!(merged.cache_entries, Some(0));
+    }
+
+    #[test]
+    fn test_attributes_merge() {
+        let rule = |path: &str, team: &str| crate::AttributeRuleConfig {
+            paths: vec![path.to_string()],
+            attributes: [("team".to_string(), team.to_string())].into(),
+            ..Default::default()
+        };
+
+        let merged = merge_attributes(
+            vec![rule("pkg/**", "core"), rule("api/**", "web")],
+            vec![rule("pkg/**", "core"), rule("pkg/pay/**", "payments")],
+        );
+
+        assert_eq!(
+            merged,
+            vec![
+                rule("api/**", "web"),
+                rule("pkg/**", "core"),
+                rule("pkg/pay/**", "payments"),
+            ]
+        );
+    }
+
     #[test]
     fn test_workspace_merge() {
         let mut base_ws = std::collections::HashMap::new();
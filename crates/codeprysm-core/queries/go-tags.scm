@@ -70,10 +70,55 @@
     name: (type_identifier) @name.definition.container.type.interface
     type: (interface_type))) @definition.container.type.interface
 
+; Interface method specifications (the interface's method set)
+(method_elem
+  name: (field_identifier) @name.definition.callable.method) @definition.callable.method
+
 ; Struct field declarations
 (field_declaration
   name: (field_identifier) @name.definition.data.field) @definition.data.field
 
+; Anonymous struct and interface types of fields and variables. They are
+; named after the field or variable (`Server.struct`), so their members are
+; nested under them rather than under the enclosing type.
+[
+  (field_declaration type: [
+      (struct_type) @name.definition.container.type.struct
+      (pointer_type (struct_type) @name.definition.container.type.struct)
+      (slice_type element: (struct_type) @name.definition.container.type.struct)
+      (array_type element: (struct_type) @name.definition.container.type.struct)
+      (map_type value: (struct_type) @name.definition.container.type.struct)
+      (channel_type value: (struct_type) @name.definition.container.type.struct)
+    ])
+  (var_spec type: [
+      (struct_type) @name.definition.container.type.struct
+      (pointer_type (struct_type) @name.definition.container.type.struct)
+      (slice_type element: (struct_type) @name.definition.container.type.struct)
+      (array_type element: (struct_type) @name.definition.container.type.struct)
+      (map_type value: (struct_type) @name.definition.container.type.struct)
+      (channel_type value: (struct_type) @name.definition.container.type.struct)
+    ])
+]
+
+[
+  (field_declaration type: [
+      (interface_type) @name.definition.container.type.interface
+      (pointer_type (interface_type) @name.definition.container.type.interface)
+      (slice_type element: (interface_type) @name.definition.container.type.interface)
+      (array_type element: (interface_type) @name.definition.container.type.interface)
+      (map_type value: (interface_type) @name.definition.container.type.interface)
+      (channel_type value: (interface_type) @name.definition.container.type.interface)
+    ])
+  (var_spec type: [
+      (interface_type) @name.definition.container.type.interface
+      (pointer_type (interface_type) @name.definition.container.type.interface)
+      (slice_type element: (interface_type) @name.definition.container.type.interface)
+      (array_type element: (interface_type) @name.definition.container.type.interface)
+      (map_type value: (interface_type) @name.definition.container.type.interface)
+      (channel_type value: (interface_type) @name.definition.container.type.interface)
+    ])
+]
+
 ; Embedded types in structs (Go's composition/inheritance)
 (field_declaration
   type: (type_identifier) @name.reference.container.type) @reference.container.type
@@ -0,0 +1,492 @@
+//! Symbol Aliases
+//!
+//! Symbol IDs (see [`crate::symbol_id`]) change when a symbol is renamed,
+//! moved to another package, or has its signature changed. The alias table
+//! records each such change as a mapping from the old ID to the new one, so
+//! issue trackers, dashboards, and other holders of an old ID can follow the
+//! symbol across refactors.
+//!
+//! Changes are detected by comparing the graph before and after an update:
+//! a symbol whose ID disappeared is matched to one of the same type and kind
+//! whose ID appeared. Callables are matched by the similarity of their clone
+//! fingerprints, so a renamed function with an unchanged body is found;
+//! symbols with the same name in the same package (a changed signature) are
+//! always matched, and symbols without fingerprints (e.g. types) are matched
+//! by name.
+//!
+//! The table is saved as `aliases.json` in the index directory. Builds attach
+//! every symbol's earlier IDs in the `previous_symbol_ids` attribute, so
+//! [`resolve_symbol`](crate::analysis::resolve_symbol) finds a symbol by any
+//! ID it has had.
+
+use std::collections::{BTreeSet, HashMap, HashSet};
+use std::path::Path;
+
+use serde::{Deserialize, Serialize};
+
+use crate::analysis::package_of;
+use crate::fingerprint::similarity;
+use crate::graph::{Node, PetCodeGraph};
+
+/// File in the index directory holding the alias table
+pub const ALIASES_FILE: &str = "aliases.json";
+
+/// Attribute listing the earlier symbol IDs of a symbol, comma-separated
+pub const PREVIOUS_IDS_ATTRIBUTE: &str = "previous_symbol_ids";
+
+/// Default minimum fingerprint similarity (percent) for matching callables
+/// with different names or packages
+pub const DEFAULT_MIN_SIMILARITY: u32 = 85;
+
+/// Why a symbol's ID changed.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum AliasReason {
+    /// The symbol has a different name (and possibly package)
+    Renamed,
+    /// The symbol moved to another package under the same name
+    Moved,
+    /// Same name and package; the signature or enclosing symbol changed
+    Redeclared,
+}
+
+impl AliasReason {
+    /// Reason name as used in output
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            Self::Renamed => "renamed",
+            Self::Moved => "moved",
+            Self::Redeclared => "redeclared",
+        }
+    }
+}
+
+/// One change of a symbol's ID.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct SymbolAlias {
+    /// Symbol ID before the change
+    pub old_id: String,
+    /// Symbol ID after the change
+    pub new_id: String,
+    /// Node ID before the change
+    pub old_node: String,
+    /// Node ID after the change
+    pub new_node: String,
+    /// Why the ID changed
+    pub reason: AliasReason,
+    /// Fingerprint similarity of the two bodies (0-100), when both have one
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub similarity: Option<u32>,
+    /// Unix time the alias was recorded (0 until recorded)
+    #[serde(default)]
+    pub recorded_at: i64,
+}
+
+/// Options for [`detect_aliases`].
+#[derive(Debug, Clone)]
+pub struct AliasOptions {
+    /// Minimum fingerprint similarity (percent) for matching callables with
+    /// different names or packages
+    pub min_similarity: u32,
+}
+
+impl Default for AliasOptions {
+    fn default() -> Self {
+        Self {
+            min_similarity: DEFAULT_MIN_SIMILARITY,
+        }
+    }
+}
+
+/// Detect symbols whose ID changed between two graphs.
+///
+/// Each disappeared ID is matched to at most one appeared ID, preferring
+/// symbols with the same name, then the same package, then the most similar
+/// body. Aliases are sorted by old ID.
+pub fn detect_aliases(
+    old: &PetCodeGraph,
+    new: &PetCodeGraph,
+    options: &AliasOptions,
+) -> Vec<SymbolAlias> {
+    let old_ids: HashSet<&str> = old.iter_nodes().filter_map(symbol_id_of).collect();
+    let new_ids: HashSet<&str> = new.iter_nodes().filter_map(symbol_id_of).collect();
+    let removed = symbols_missing_from(old, &new_ids);
+    let added = symbols_missing_from(new, &old_ids);
+
+    let mut candidates = Vec::new();
+    for before in &removed {
+        for after in &added {
+            if before.node_type != after.node_type || before.kind != after.kind {
+                continue;
+            }
+            let same_name = before.name == after.name;
+            let same_package = package_of(&before.file) == package_of(&after.file);
+            let score = match (
+                before.metadata.clone_signature.as_deref(),
+                after.metadata.clone_signature.as_deref(),
+            ) {
+                (Some(a), Some(b)) => Some(similarity(a, b)),
+                _ => None,
+            };
+            let matched = (same_name && same_package)
+                || score.map_or(same_name, |s| s >= options.min_similarity);
+            if matched {
+                candidates.push((same_name, same_package, score, *before, *after));
+            }
+        }
+    }
+    candidates.sort_by(|a, b| {
+        (b.0, b.1, b.2)
+            .cmp(&(a.0, a.1, a.2))
+            .then_with(|| a.3.id.cmp(&b.3.id))
+            .then_with(|| a.4.id.cmp(&b.4.id))
+    });
+
+    let mut taken_old = HashSet::new();
+    let mut taken_new = HashSet::new();
+    let mut aliases = Vec::new();
+    for (same_name, same_package, score, before, after) in candidates {
+        if !taken_old.insert(before.id.as_str()) {
+            continue;
+        }
+        if !taken_new.insert(after.id.as_str()) {
+            taken_old.remove(before.id.as_str());
+            continue;
+        }
+        let reason = if !same_name {
+            AliasReason::Renamed
+        } else if !same_package {
+            AliasReason::Moved
+        } else {
+            AliasReason::Redeclared
+        };
+        aliases.push(SymbolAlias {
+            old_id: symbol_id_of(before).unwrap_or_default().to_string(),
+            new_id: symbol_id_of(after).unwrap_or_default().to_string(),
+            old_node: before.id.clone(),
+            new_node: after.id.clone(),
+            reason,
+            similarity: score,
+            recorded_at: 0,
+        });
+    }
+    aliases.sort_by(|a, b| a.old_id.cmp(&b.old_id));
+    aliases
+}
+
+/// Earlier symbol IDs recorded on a node by [`AliasTable::attach`]
+pub fn previous_symbol_ids(node: &Node) -> impl Iterator<Item = &str> {
+    node.metadata
+        .attributes
+        .as_ref()
+        .and_then(|attributes| attributes.get(PREVIOUS_IDS_ATTRIBUTE))
+        .into_iter()
+        .flat_map(|ids| ids.split(','))
+        .filter(|id| !id.is_empty())
+}
+
+/// All symbol ID changes recorded for an index, oldest first.
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+pub struct AliasTable {
+    /// Recorded aliases
+    pub aliases: Vec<SymbolAlias>,
+}
+
+impl AliasTable {
+    /// Load the alias table saved in an index directory; empty if none was
+    /// saved.
+    pub fn load(prism_dir: &Path) -> Self {
+        std::fs::read_to_string(prism_dir.join(ALIASES_FILE))
+            .ok()
+            .and_then(|json| serde_json::from_str(&json).ok())
+            .unwrap_or_default()
+    }
+
+    /// Save the alias table into an index directory.
+    pub fn save(&self, prism_dir: &Path) -> std::io::Result<()> {
+        let json = serde_json::to_string_pretty(self).map_err(std::io::Error::other)?;
+
+        // Write a temporary file and rename it so that concurrent readers
+        // never load a partial table
+        let path = prism_dir.join(ALIASES_FILE);
+        let tmp = path.with_extension(format!("json.{}.tmp", std::process::id()));
+        std::fs::write(&tmp, json)?;
+        std::fs::rename(&tmp, &path)
+    }
+
+    /// Check if no aliases are recorded
+    pub fn is_empty(&self) -> bool {
+        self.aliases.is_empty()
+    }
+
+    /// Record newly detected aliases at `now` (Unix seconds); returns how
+    /// many were new.
+    ///
+    /// An alias to an ID that had itself been aliased away (a rename that was
+    /// reverted) drops the earlier alias, so the table never has cycles.
+    pub fn record(&mut self, aliases: Vec<SymbolAlias>, now: i64) -> usize {
+        let mut recorded = 0;
+        for mut alias in aliases {
+            if self
+                .aliases
+                .iter()
+                .any(|a| a.old_id == alias.old_id && a.new_id == alias.new_id)
+            {
+                continue;
+            }
+            self.aliases.retain(|a| a.old_id != alias.new_id);
+            alias.recorded_at = now;
+            self.aliases.push(alias);
+            recorded += 1;
+        }
+        recorded
+    }
+
+    /// Follow the aliases of a symbol ID to its latest ID.
+    ///
+    /// Returns `id` itself when it was never aliased.
+    pub fn resolve<'a>(&'a self, id: &'a str) -> &'a str {
+        let latest: HashMap<&str, &str> = self
+            .aliases
+            .iter()
+            .map(|a| (a.old_id.as_str(), a.new_id.as_str()))
+            .collect();
+        let mut current = id;
+        let mut seen = HashSet::from([id]);
+        while let Some(&next) = latest.get(current) {
+            if !seen.insert(next) {
+                break;
+            }
+            current = next;
+        }
+        current
+    }
+
+    /// The aliases leading from `id` to its latest ID, oldest first
+    pub fn chain(&self, id: &str) -> Vec<&SymbolAlias> {
+        let mut chain = Vec::new();
+        let mut current = id;
+        let mut seen = HashSet::from([id]);
+        while let Some(alias) = self.aliases.iter().rev().find(|a| a.old_id == current) {
+            if !seen.insert(alias.new_id.as_str()) {
+                break;
+            }
+            chain.push(alias);
+            current = &alias.new_id;
+        }
+        chain
+    }
+
+    /// Every ID that resolves to `id`, excluding `id` itself, sorted
+    pub fn previous_ids(&self, id: &str) -> Vec<&str> {
+        previous_in(&self.by_new_id(), id)
+    }
+
+    /// Old IDs of the aliases, by new ID
+    fn by_new_id(&self) -> HashMap<&str, Vec<&str>> {
+        let mut by_new_id: HashMap<&str, Vec<&str>> = HashMap::new();
+        for alias in &self.aliases {
+            by_new_id
+                .entry(alias.new_id.as_str())
+                .or_default()
+                .push(alias.old_id.as_str());
+        }
+        by_new_id
+    }
+
+    /// Record every symbol's earlier IDs on the graph in the
+    /// [`PREVIOUS_IDS_ATTRIBUTE`] attribute, replacing earlier values;
+    /// returns how many symbols have earlier IDs.
+    pub fn attach(&self, graph: &mut PetCodeGraph) -> usize {
+        let by_new_id = self.by_new_id();
+        let mut attached = 0;
+        for node in graph.inner_mut().node_weights_mut() {
+            if let Some(attributes) = node.metadata.attributes.as_mut() {
+                attributes.remove(PREVIOUS_IDS_ATTRIBUTE);
+            }
+            let Some(id) = node.metadata.symbol_id.as_deref() else {
+                continue;
+            };
+            let previous = previous_in(&by_new_id, id).join(",");
+            if previous.is_empty() {
+                continue;
+            }
+            node.metadata
+                .attributes
+                .get_or_insert_with(Default::default)
+                .insert(PREVIOUS_IDS_ATTRIBUTE.to_string(), previous);
+            attached += 1;
+        }
+        attached
+    }
+}
+
+/// IDs leading to `id` in an index of old IDs by new ID, sorted
+fn previous_in<'a>(by_new_id: &HashMap<&str, Vec<&'a str>>, id: &str) -> Vec<&'a str> {
+    let mut previous = BTreeSet::new();
+    let mut pending = vec![id];
+    while let Some(current) = pending.pop() {
+        for &old_id in by_new_id.get(current).into_iter().flatten() {
+            if old_id != id && previous.insert(old_id) {
+                pending.push(old_id);
+            }
+        }
+    }
+    previous.into_iter().collect()
+}
+
+/// Symbol ID of a node, if it has one
+fn symbol_id_of(node: &Node) -> Option<&str> {
+    node.metadata.symbol_id.as_deref()
+}
+
+/// Nodes whose symbol ID is not in `ids`, one per symbol ID
+fn symbols_missing_from<'a>(graph: &'a PetCodeGraph, ids: &HashSet<&str>) -> Vec<&'a Node> {
+    let mut nodes: Vec<&Node> = graph
+        .iter_nodes()
+        .filter(|n| symbol_id_of(n).is_some_and(|id| !ids.contains(id)))
+        .collect();
+    nodes.sort_by(|a, b| a.id.cmp(&b.id));
+    let mut seen = HashSet::new();
+    nodes.retain(|n| seen.insert(symbol_id_of(n)));
+    nodes
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::CallableKind;
+
+    fn function(id: &str, file: &str, symbol_id: &str, signature: Vec<u32>) -> Node {
+        let name = id.rsplit(':').next().unwrap();
+        let mut node = Node::callable(
+            id.to_string(),
+            name.to_string(),
+            CallableKind::Function,
+            file.to_string(),
+            1,
+            10,
+        );
+        node.metadata.symbol_id = Some(symbol_id.to_string());
+        node.metadata.clone_signature = Some(signature);
+        node
+    }
+
+    #[test]
+    fn test_detect_renames_moves_and_signature_changes() {
+        let body: Vec<u32> = (0..32).collect();
+        let other: Vec<u32> = (100..132).collect();
+
+        let mut old = PetCodeGraph::new();
+        old.add_node(function(
+            "api/h.go:Handle",
+            "api/h.go",
+            "sym:go:1",
+            body.clone(),
+        ));
+        old.add_node(function(
+            "api/h.go:Save",
+            "api/h.go",
+            "sym:go:2",
+            other.clone(),
+        ));
+        old.add_node(function(
+            "api/h.go:Load",
+            "api/h.go",
+            "sym:go:3",
+            vec![7; 32],
+        ));
+        old.add_node(function(
+            "api/h.go:Keep",
+            "api/h.go",
+            "sym:go:4",
+            vec![9; 32],
+        ));
+
+        let mut new = PetCodeGraph::new();
+        // Renamed with the same body
+        new.add_node(function("api/h.go:Serve", "api/h.go", "sym:go:a", body));
+        // Moved to another package, body rewritten
+        new.add_node(function(
+            "store/s.go:Save",
+            "store/s.go",
+            "sym:go:b",
+            vec![1; 32],
+        ));
+        // Same name and package, new signature and body
+        new.add_node(function(
+            "api/h.go:Load",
+            "api/h.go",
+            "sym:go:c",
+            vec![8; 32],
+        ));
+        new.add_node(function(
+            "api/h.go:Keep",
+            "api/h.go",
+            "sym:go:4",
+            vec![9; 32],
+        ));
+
+        let aliases = detect_aliases(&old, &new, &AliasOptions::default());
+        let summary: Vec<(&str, &str, AliasReason)> = aliases
+            .iter()
+            .map(|a| (a.old_id.as_str(), a.new_id.as_str(), a.reason))
+            .collect();
+        assert_eq!(
+            summary,
+            vec![
+                ("sym:go:1", "sym:go:a", AliasReason::Renamed),
+                ("sym:go:3", "sym:go:c", AliasReason::Redeclared),
+            ]
+        );
+        assert_eq!(aliases[0].similarity, Some(100));
+
+        // Symbols without fingerprints are matched by name
+        let mut moved = new.clone();
+        moved
+            .get_node_mut("store/s.go:Save")
+            .unwrap()
+            .metadata
+            .clone_signature = None;
+        let aliases = detect_aliases(&old, &moved, &AliasOptions::default());
+        assert!(aliases
+            .iter()
+            .any(|a| a.old_id == "sym:go:2" && a.reason == AliasReason::Moved));
+    }
+
+    #[test]
+    fn test_alias_table_chains() {
+        let alias = |old: &str, new: &str| SymbolAlias {
+            old_id: old.to_string(),
+            new_id: new.to_string(),
+            old_node: old.to_string(),
+            new_node: new.to_string(),
+            reason: AliasReason::Renamed,
+            similarity: None,
+            recorded_at: 0,
+        };
+
+        let mut table = AliasTable::default();
+        assert_eq!(table.record(vec![alias("a", "b")], 10), 1);
+        assert_eq!(table.record(vec![alias("b", "c"), alias("a", "b")], 20), 1);
+        assert_eq!(table.resolve("a"), "c");
+        assert_eq!(table.resolve("z"), "z");
+        assert_eq!(table.chain("a").len(), 2);
+        assert_eq!(table.previous_ids("c"), vec!["a", "b"]);
+
+        let mut graph = PetCodeGraph::new();
+        graph.add_node(function("x.go:C", "x.go", "c", vec![]));
+        assert_eq!(table.attach(&mut graph), 1);
+        let node = graph.get_node("x.go:C").unwrap();
+        assert_eq!(
+            previous_symbol_ids(node).collect::<Vec<_>>(),
+            vec!["a", "b"]
+        );
+
+        // Reverting the last rename drops the alias away from "b"
+        table.record(vec![alias("c", "b")], 30);
+        assert_eq!(table.resolve("a"), "b");
+        assert_eq!(table.resolve("c"), "b");
+        assert_eq!(table.previous_ids("b"), vec!["a", "c"]);
+    }
+}
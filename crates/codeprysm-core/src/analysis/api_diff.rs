@@ -0,0 +1,367 @@
+//! API Compatibility Diff
+//!
+//! Compares two exported API surfaces (see [`api_surface`](super::api_surface))
+//! and classifies each change as breaking or compatible, following Go's
+//! compatibility rules:
+//!
+//! - Removing an exported symbol is breaking
+//! - Changing a symbol's signature, kind, or subtype is breaking
+//! - Adding a method to an exported interface is breaking, since existing
+//!   implementations no longer satisfy it
+//! - Adding a symbol or deprecating one is compatible
+//!
+//! Symbols are matched by package and owner-qualified name rather than node
+//! ID, so moving a declaration between files of the same package is not a
+//! change. Names declared more than once in a package (overloads) are matched
+//! by signature.
+
+use std::collections::{BTreeMap, BTreeSet};
+
+use serde::{Deserialize, Serialize};
+
+use super::api_surface::{ApiSymbol, Stability};
+
+/// Whether a change breaks existing users.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, PartialOrd, Ord, Hash, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum Severity {
+    /// Existing code may stop compiling or behave differently
+    Breaking,
+    /// Existing code keeps working
+    Compatible,
+}
+
+impl Severity {
+    /// Get the string representation
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            Severity::Breaking => "breaking",
+            Severity::Compatible => "compatible",
+        }
+    }
+}
+
+/// What changed about a symbol.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, PartialOrd, Ord, Hash, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum ApiChangeKind {
+    /// Symbol no longer exported
+    Removed,
+    /// Declaration signature changed
+    SignatureChanged,
+    /// Kind or subtype changed (e.g. struct to interface)
+    KindChanged,
+    /// Method added to an existing interface
+    InterfaceMethodAdded,
+    /// Newly exported symbol
+    Added,
+    /// Symbol newly marked deprecated
+    Deprecated,
+}
+
+impl ApiChangeKind {
+    /// Get the string representation
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            ApiChangeKind::Removed => "removed",
+            ApiChangeKind::SignatureChanged => "signature_changed",
+            ApiChangeKind::KindChanged => "kind_changed",
+            ApiChangeKind::InterfaceMethodAdded => "interface_method_added",
+            ApiChangeKind::Added => "added",
+            ApiChangeKind::Deprecated => "deprecated",
+        }
+    }
+
+    /// Severity of this kind of change
+    pub fn severity(&self) -> Severity {
+        match self {
+            ApiChangeKind::Removed
+            | ApiChangeKind::SignatureChanged
+            | ApiChangeKind::KindChanged
+            | ApiChangeKind::InterfaceMethodAdded => Severity::Breaking,
+            ApiChangeKind::Added | ApiChangeKind::Deprecated => Severity::Compatible,
+        }
+    }
+}
+
+/// One classified API change.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct ApiChange {
+    /// Package (containing directory)
+    pub package: String,
+    /// Owner-qualified symbol name (e.g. "Store.Get")
+    pub symbol: String,
+    /// What changed
+    pub kind: ApiChangeKind,
+    /// Whether the change is breaking
+    pub severity: Severity,
+    /// File of the symbol (new revision, or old if removed)
+    pub file: String,
+    /// Line of the symbol (new revision, or old if removed)
+    pub line: usize,
+    /// Signature in the old revision
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub old_signature: Option<String>,
+    /// Signature in the new revision
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub new_signature: Option<String>,
+}
+
+/// Classified changes between two API surfaces.
+#[derive(Debug, Clone, Default, PartialEq, Eq, Serialize, Deserialize)]
+pub struct ApiDiff {
+    /// Changes sorted by severity (breaking first), package, and symbol
+    pub changes: Vec<ApiChange>,
+}
+
+impl ApiDiff {
+    /// Number of breaking changes
+    pub fn breaking_count(&self) -> usize {
+        self.changes
+            .iter()
+            .filter(|c| c.severity == Severity::Breaking)
+            .count()
+    }
+
+    /// Check if any change is breaking
+    pub fn is_breaking(&self) -> bool {
+        self.breaking_count() > 0
+    }
+}
+
+/// Match key: package and owner-qualified name
+type SymbolKey = (String, String);
+
+/// Compare two API surfaces.
+pub fn diff_api(old: &[ApiSymbol], new: &[ApiSymbol]) -> ApiDiff {
+    let old_symbols = by_key(old);
+    let new_symbols = by_key(new);
+
+    // Interfaces that existed before; methods added to them are breaking
+    let old_interfaces: BTreeSet<(&str, &str)> = old
+        .iter()
+        .filter(|s| s.subtype.as_deref() == Some("interface"))
+        .map(|s| (s.package.as_str(), s.name.as_str()))
+        .collect();
+
+    let keys: BTreeSet<&SymbolKey> = old_symbols.keys().chain(new_symbols.keys()).collect();
+    let mut changes = Vec::new();
+    for key in keys {
+        let before = old_symbols.get(key).map(Vec::as_slice).unwrap_or_default();
+        let after = new_symbols.get(key).map(Vec::as_slice).unwrap_or_default();
+
+        match (before, after) {
+            ([], added) => {
+                for symbol in added {
+                    let in_old_interface = symbol.owner.as_deref().is_some_and(|owner| {
+                        old_interfaces.contains(&(symbol.package.as_str(), owner))
+                    });
+                    let kind = if in_old_interface {
+                        ApiChangeKind::InterfaceMethodAdded
+                    } else {
+                        ApiChangeKind::Added
+                    };
+                    changes.push(change(kind, None, Some(*symbol)));
+                }
+            }
+            (removed, []) => {
+                for symbol in removed {
+                    changes.push(change(ApiChangeKind::Removed, Some(*symbol), None));
+                }
+            }
+            ([old], [new]) => compare(old, new, &mut changes),
+            (before, after) => {
+                // Overloads: match by signature
+                let signatures = |symbols: &[&ApiSymbol]| -> BTreeSet<Option<String>> {
+                    symbols.iter().map(|s| s.signature.clone()).collect()
+                };
+                let (old_sigs, new_sigs) = (signatures(before), signatures(after));
+                for symbol in before.iter().filter(|s| !new_sigs.contains(&s.signature)) {
+                    changes.push(change(ApiChangeKind::Removed, Some(*symbol), None));
+                }
+                for symbol in after.iter().filter(|s| !old_sigs.contains(&s.signature)) {
+                    changes.push(change(ApiChangeKind::Added, None, Some(*symbol)));
+                }
+            }
+        }
+    }
+
+    changes.sort_by(|a, b| {
+        (a.severity, &a.package, &a.symbol, a.kind)
+            .cmp(&(b.severity, &b.package, &b.symbol, b.kind))
+    });
+    ApiDiff { changes }
+}
+
+/// Compare one symbol present in both revisions
+fn compare(old: &ApiSymbol, new: &ApiSymbol, changes: &mut Vec<ApiChange>) {
+    if old.node_type != new.node_type || old.kind != new.kind || old.subtype != new.subtype {
+        changes.push(change(ApiChangeKind::KindChanged, Some(old), Some(new)));
+    } else if old.signature != new.signature {
+        changes.push(change(
+            ApiChangeKind::SignatureChanged,
+            Some(old),
+            Some(new),
+        ));
+    }
+
+    if new.stability == Stability::Deprecated && old.stability != Stability::Deprecated {
+        changes.push(change(ApiChangeKind::Deprecated, Some(old), Some(new)));
+    }
+}
+
+/// Group symbols by match key
+fn by_key(symbols: &[ApiSymbol]) -> BTreeMap<SymbolKey, Vec<&ApiSymbol>> {
+    let mut map: BTreeMap<SymbolKey, Vec<&ApiSymbol>> = BTreeMap::new();
+    for symbol in symbols {
+        map.entry((symbol.package.clone(), symbol.qualified_name()))
+            .or_default()
+            .push(symbol);
+    }
+    map
+}
+
+/// Build a change record, locating it in the new revision when possible
+fn change(kind: ApiChangeKind, old: Option<&ApiSymbol>, new: Option<&ApiSymbol>) -> ApiChange {
+    let located = new.or(old).expect("change needs at least one side");
+    ApiChange {
+        package: located.package.clone(),
+        symbol: located.qualified_name(),
+        kind,
+        severity: kind.severity(),
+        file: located.file.clone(),
+        line: located.line,
+        old_signature: old.and_then(|s| s.signature.clone()),
+        new_signature: new.and_then(|s| s.signature.clone()),
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::NodeType;
+
+    fn symbol(package: &str, owner: Option<&str>, name: &str, signature: &str) -> ApiSymbol {
+        ApiSymbol {
+            id: format!("{}/x.go:{}", package, name),
+            name: name.to_string(),
+            node_type: NodeType::Callable,
+            kind: Some("method".to_string()),
+            subtype: None,
+            package: package.to_string(),
+            file: format!("{}/x.go", package),
+            line: 1,
+            owner: owner.map(str::to_string),
+            signature: Some(signature.to_string()),
+            doc: None,
+            stability: Stability::Stable,
+        }
+    }
+
+    fn interface(package: &str, name: &str) -> ApiSymbol {
+        ApiSymbol {
+            node_type: NodeType::Container,
+            kind: Some("type".to_string()),
+            subtype: Some("interface".to_string()),
+            ..symbol(package, None, name, &format!("type {} interface", name))
+        }
+    }
+
+    fn kinds(diff: &ApiDiff) -> Vec<(&str, ApiChangeKind)> {
+        diff.changes
+            .iter()
+            .map(|c| (c.symbol.as_str(), c.kind))
+            .collect()
+    }
+
+    #[test]
+    fn test_diff_api_classification() {
+        let old = vec![
+            interface("store", "Reader"),
+            symbol(
+                "store",
+                Some("Reader"),
+                "Read",
+                "Read(key string) ([]byte, error)",
+            ),
+            symbol(
+                "store",
+                None,
+                "Open",
+                "func Open(path string) (*Store, error)",
+            ),
+            symbol("store", None, "Close", "func Close() error"),
+            symbol("store", None, "Legacy", "func Legacy()"),
+        ];
+        let mut legacy = symbol("store", None, "Legacy", "func Legacy()");
+        legacy.stability = Stability::Deprecated;
+        // Moving a function to another file is not a change
+        let mut moved = symbol("store", None, "Close", "func Close() error");
+        moved.file = "store/close.go".to_string();
+        let new = vec![
+            interface("store", "Reader"),
+            symbol(
+                "store",
+                Some("Reader"),
+                "Read",
+                "Read(key string) ([]byte, error)",
+            ),
+            symbol("store", Some("Reader"), "Size", "Size() int"),
+            symbol(
+                "store",
+                None,
+                "Open",
+                "func Open(path string, ro bool) (*Store, error)",
+            ),
+            moved,
+            legacy,
+            symbol("store", None, "Version", "func Version() string"),
+        ];
+
+        let diff = diff_api(&old, &new);
+        assert_eq!(
+            kinds(&diff),
+            vec![
+                ("Open", ApiChangeKind::SignatureChanged),
+                ("Reader.Size", ApiChangeKind::InterfaceMethodAdded),
+                ("Legacy", ApiChangeKind::Deprecated),
+                ("Version", ApiChangeKind::Added),
+            ]
+        );
+        assert_eq!(diff.breaking_count(), 2);
+
+        let removed = diff_api(&new, &old);
+        assert!(removed
+            .changes
+            .iter()
+            .any(|c| c.symbol == "Reader.Size" && c.kind == ApiChangeKind::Removed));
+        assert!(removed.is_breaking());
+
+        assert!(diff_api(&old, &old).changes.is_empty());
+    }
+
+    #[test]
+    fn test_overloads_match_by_signature() {
+        let old = vec![
+            symbol("app", None, "Add", "int Add(int a, int b)"),
+            symbol("app", None, "Add", "double Add(double a, double b)"),
+        ];
+        let new = vec![
+            symbol("app", None, "Add", "int Add(int a, int b)"),
+            symbol("app", None, "Add", "long Add(long a, long b)"),
+        ];
+
+        let diff = diff_api(&old, &new);
+        assert_eq!(
+            kinds(&diff),
+            vec![
+                ("Add", ApiChangeKind::Removed),
+                ("Add", ApiChangeKind::Added)
+            ]
+        );
+        assert_eq!(
+            diff.changes[0].old_signature.as_deref(),
+            Some("double Add(double a, double b)")
+        );
+    }
+}
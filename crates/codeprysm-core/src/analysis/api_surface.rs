@@ -0,0 +1,544 @@
+//! Exported API Surface
+//!
+//! Lists every symbol a package exposes to its users: public functions,
+//! types, methods, constants, and fields. A symbol only counts as exported if
+//! everything enclosing it is exported too, so a public method on a private
+//! type is not part of the surface. Test code and Go `internal` packages are
+//! excluded.
+//!
+//! The graph does not store source text, so signatures and doc comments are
+//! read from the files under the repository root:
+//!
+//! - **Signature**: the declaration up to its body, whitespace-collapsed
+//! - **Doc summary**: the first paragraph of the comment block above the
+//!   declaration (or a Python docstring)
+//! - **Stability**: derived from deprecation and experimental markers in the
+//!   doc comment, attributes, and decorators (e.g. Go `Deprecated:`, JSDoc
+//!   `@deprecated`/`@experimental`, Rust `#[deprecated]`, C# `[Obsolete]`)
+
+use std::collections::BTreeMap;
+use std::ffi::OsStr;
+use std::path::{Component, Path};
+
+use serde::{Deserialize, Serialize};
+
+use super::dead_code::is_test_code;
+use super::package_of;
+use crate::graph::{ContainerKind, DataKind, Node, NodeType, PetCodeGraph};
+
+/// Data kinds that can be part of an API (locals and parameters cannot)
+const API_DATA_KINDS: &[DataKind] = &[
+    DataKind::Constant,
+    DataKind::Value,
+    DataKind::Field,
+    DataKind::Property,
+];
+
+/// Maximum number of lines scanned for a multi-line signature
+const MAX_SIGNATURE_LINES: usize = 12;
+
+/// Stability of an exported symbol.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, PartialOrd, Ord, Hash, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum Stability {
+    /// No stability marker
+    Stable,
+    /// Marked experimental, beta, or unstable
+    Experimental,
+    /// Marked deprecated or obsolete
+    Deprecated,
+}
+
+impl Stability {
+    /// Get the string representation
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            Stability::Stable => "stable",
+            Stability::Experimental => "experimental",
+            Stability::Deprecated => "deprecated",
+        }
+    }
+}
+
+impl std::str::FromStr for Stability {
+    type Err = String;
+
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        match s.to_lowercase().as_str() {
+            "stable" => Ok(Stability::Stable),
+            "experimental" => Ok(Stability::Experimental),
+            "deprecated" => Ok(Stability::Deprecated),
+            _ => Err(format!(
+                "Unknown stability: {}. Valid values: stable, experimental, deprecated",
+                s
+            )),
+        }
+    }
+}
+
+/// One exported symbol.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct ApiSymbol {
+    /// Node ID
+    pub id: String,
+    /// Entity name
+    pub name: String,
+    /// Node type
+    pub node_type: NodeType,
+    /// Kind within the node type
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub kind: Option<String>,
+    /// Subtype (e.g. "struct", "interface")
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub subtype: Option<String>,
+    /// Package (containing directory)
+    pub package: String,
+    /// File path relative to the repository root
+    pub file: String,
+    /// Start line (1-indexed)
+    pub line: usize,
+    /// Enclosing type (or Go receiver type) for members
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub owner: Option<String>,
+    /// Declaration signature
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub signature: Option<String>,
+    /// First paragraph of the doc comment
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub doc: Option<String>,
+    /// Stability derived from markers
+    pub stability: Stability,
+}
+
+impl ApiSymbol {
+    /// Name qualified by the owning type (e.g. "Store.Get")
+    pub fn qualified_name(&self) -> String {
+        match self.owner {
+            Some(ref owner) => format!("{}.{}", owner, self.name),
+            None => self.name.clone(),
+        }
+    }
+}
+
+/// Collect the exported API surface, sorted by package, file, and line.
+///
+/// Signatures and docs are read from `repo_root`; symbols in unreadable files
+/// are still listed without them.
+pub fn api_surface(graph: &PetCodeGraph, repo_root: &Path) -> Vec<ApiSymbol> {
+    let mut by_file: BTreeMap<&str, Vec<&Node>> = BTreeMap::new();
+    for node in graph.iter_nodes().filter(|n| is_exported(graph, n)) {
+        by_file.entry(node.file.as_str()).or_default().push(node);
+    }
+
+    let mut symbols = Vec::new();
+    for (file, nodes) in by_file {
+        let content = std::fs::read_to_string(repo_root.join(file)).unwrap_or_default();
+        let lines: Vec<&str> = content.lines().collect();
+
+        for node in nodes {
+            let signature = declaration_signature(&lines, node.line, node.end_line);
+            let (doc, markers) = doc_comment(&lines, node.line);
+            let doc_text = doc.join("\n");
+            let mut stability = stability_from(&doc_text, &markers);
+            if let Some(decorators) = node.metadata.decorators.as_ref() {
+                stability = stability.max(stability_from("", decorators));
+            }
+
+            symbols.push(ApiSymbol {
+                id: node.id.clone(),
+                name: node.name.clone(),
+                node_type: node.node_type,
+                kind: node.kind.clone(),
+                subtype: node.subtype.clone(),
+                package: package_of(&node.file).to_string(),
+                file: node.file.clone(),
+                line: node.line,
+                owner: owner_name(graph, node),
+                signature,
+                doc: summary(&doc),
+                stability,
+            });
+        }
+    }
+
+    symbols.sort_by(|a, b| {
+        (a.package.as_str(), a.file.as_str(), a.line, a.id.as_str()).cmp(&(
+            b.package.as_str(),
+            b.file.as_str(),
+            b.line,
+            b.id.as_str(),
+        ))
+    });
+    symbols
+}
+
+/// Read a declaration's signature: its text from the start line up to the
+/// body (`{`, or a trailing `:` for Python), whitespace-collapsed.
+///
+/// `line` and `end_line` are 1-indexed. Returns `None` if the line is out of
+/// range.
+pub fn declaration_signature(lines: &[&str], line: usize, end_line: usize) -> Option<String> {
+    let start = line.checked_sub(1)?;
+    let end = end_line.max(line).min(start + MAX_SIGNATURE_LINES);
+
+    let mut parts = Vec::new();
+    for text in lines.get(start..end.min(lines.len()))? {
+        let text = text.trim();
+        if let Some(index) = text.find('{') {
+            parts.push(&text[..index]);
+            break;
+        }
+        if let Some(head) = text.strip_suffix(':') {
+            parts.push(head);
+            break;
+        }
+        parts.push(text);
+        if text.ends_with(';') {
+            break;
+        }
+    }
+
+    let signature = parts
+        .join(" ")
+        .split_whitespace()
+        .collect::<Vec<_>>()
+        .join(" ");
+    let signature = signature.trim_end_matches(';').trim_end().to_string();
+    (!signature.is_empty()).then_some(signature)
+}
+
+/// Check whether a node is part of the exported API surface
+pub(crate) fn is_exported(graph: &PetCodeGraph, node: &Node) -> bool {
+    let eligible = match node.node_type {
+        NodeType::Callable => true,
+        NodeType::Container => is_type(node),
+        NodeType::Data => API_DATA_KINDS
+            .iter()
+            .any(|k| node.kind.as_deref() == Some(k.as_str())),
+    };
+    if !eligible || !is_public(node) || is_test_code(node) || is_go_internal(&node.file) {
+        return false;
+    }
+
+    // Go methods are exported only if their receiver type is
+    if let Some(receiver) = node.metadata.receiver.as_deref() {
+        if !receiver.starts_with(|c: char| c.is_uppercase()) {
+            return false;
+        }
+    }
+
+    // Every enclosing type must be exported; anything inside a function is local
+    let mut current = node;
+    while let Some(parent) = graph.parent(&current.id) {
+        match parent.node_type {
+            NodeType::Callable => return false,
+            NodeType::Container if is_type(parent) => {
+                if !is_public(parent) {
+                    return false;
+                }
+            }
+            _ => break,
+        }
+        current = parent;
+    }
+    true
+}
+
+/// Check if a node is declared public
+fn is_public(node: &Node) -> bool {
+    node.metadata.visibility.as_deref() == Some("public")
+}
+
+/// Check if a Go file lives in an `internal` package, which other modules
+/// cannot import
+fn is_go_internal(file: &str) -> bool {
+    file.ends_with(".go")
+        && Path::new(file)
+            .components()
+            .any(|c| c == Component::Normal(OsStr::new("internal")))
+}
+
+/// Check if a node is a `Container/type` node
+pub(crate) fn is_type(node: &Node) -> bool {
+    node.node_type == NodeType::Container
+        && node.kind.as_deref() == Some(ContainerKind::Type.as_str())
+}
+
+/// Name of the enclosing type or Go receiver
+pub(crate) fn owner_name(graph: &PetCodeGraph, node: &Node) -> Option<String> {
+    if let Some(receiver) = node.metadata.receiver.as_ref() {
+        return Some(receiver.clone());
+    }
+    graph
+        .parent(&node.id)
+        .filter(|p| is_type(p))
+        .map(|p| p.name.clone())
+}
+
+/// First paragraph of the doc comment of the declaration at `line`
+/// (1-indexed), or its Python docstring.
+pub fn doc_summary(lines: &[&str], line: usize) -> Option<String> {
+    summary(&doc_comment(lines, line).0)
+}
+
+/// Doc comment lines above a declaration (markers stripped, in order), plus
+/// the attribute/decorator lines found between the comment and the
+/// declaration. Falls back to a Python docstring below the declaration.
+pub(crate) fn doc_comment(lines: &[&str], line: usize) -> (Vec<String>, Vec<String>) {
+    let mut doc = Vec::new();
+    let mut markers = Vec::new();
+
+    let mut index = line.saturating_sub(1);
+    while index > 0 {
+        let text = lines.get(index - 1).map_or("", |l| l.trim());
+        if is_attribute(text) {
+            markers.push(text.to_string());
+        } else if let Some(comment) = strip_comment(text) {
+            doc.push(comment.to_string());
+        } else {
+            break;
+        }
+        index -= 1;
+    }
+    doc.reverse();
+
+    if doc.iter().all(|l| l.is_empty()) {
+        doc = docstring(lines, line);
+    }
+    (doc, markers)
+}
+
+/// Check if a line is an attribute, annotation, or decorator
+pub(crate) fn is_attribute(text: &str) -> bool {
+    text.starts_with("#[")
+        || text.starts_with('@')
+        || (text.starts_with('[') && text.ends_with(']'))
+}
+
+/// Strip comment markers from a comment line; `None` if not a comment
+fn strip_comment(text: &str) -> Option<&str> {
+    let stripped = ["///", "//!", "//", "/**", "*/", "*", "#"]
+        .iter()
+        .find_map(|marker| text.strip_prefix(marker))?;
+    Some(stripped.trim_end_matches("*/").trim())
+}
+
+/// Python docstring: a string literal as the first statement of the body
+fn docstring(lines: &[&str], line: usize) -> Vec<String> {
+    // `line` is 1-indexed, so this is the line after the declaration
+    let Some(first) = lines.get(line).map(|l| l.trim()) else {
+        return Vec::new();
+    };
+    let Some(quote) = ["\"\"\"", "'''"].into_iter().find(|q| first.starts_with(q)) else {
+        return Vec::new();
+    };
+
+    let mut doc = Vec::new();
+    let rest = lines.iter().skip(line + 1).map(|l| l.trim());
+    for text in std::iter::once(&first[quote.len()..]).chain(rest) {
+        if let Some(end) = text.find(quote) {
+            doc.push(text[..end].trim().to_string());
+            break;
+        }
+        doc.push(text.to_string());
+    }
+    doc
+}
+
+/// First paragraph of a doc comment, joined into one line
+fn summary(doc: &[String]) -> Option<String> {
+    let paragraph: Vec<&str> = doc
+        .iter()
+        .map(|l| l.as_str())
+        .skip_while(|l| l.is_empty())
+        .take_while(|l| !l.is_empty() && !l.starts_with('@'))
+        .collect();
+    (!paragraph.is_empty()).then(|| paragraph.join(" "))
+}
+
+/// Derive stability from doc text and attribute/decorator markers
+fn stability_from(doc: &str, markers: &[String]) -> Stability {
+    let doc = doc.to_lowercase();
+    let has_marker = |names: &[&str]| {
+        markers.iter().any(|m| {
+            let m = m.to_lowercase();
+            names.iter().any(|name| m.contains(name))
+        })
+    };
+
+    if doc.contains("deprecated:")
+        || doc.contains("@deprecated")
+        || has_marker(&["deprecated", "obsolete"])
+    {
+        Stability::Deprecated
+    } else if doc.contains("experimental:")
+        || doc.contains("@experimental")
+        || doc.contains("@beta")
+        || doc.contains("@alpha")
+        || has_marker(&["experimental", "unstable", "beta"])
+    {
+        Stability::Experimental
+    } else {
+        Stability::Stable
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::{CallableKind, EdgeData};
+
+    const SOURCE: &str = r#"package store
+
+// Store persists records.
+//
+// It is safe for concurrent use.
+type Store struct {
+	Name string
+}
+
+// Get returns a record
+// by key.
+func (s *Store) Get(
+	key string,
+) (string, error) {
+	return "", nil
+}
+
+// Put stores a record.
+//
+// Deprecated: use Set instead.
+func (s *Store) Put(key string) {}
+
+func helper() {}
+"#;
+
+    fn sample_graph() -> PetCodeGraph {
+        let mut graph = PetCodeGraph::new();
+        let mut store = Node::container(
+            "store/store.go:Store".to_string(),
+            "Store".to_string(),
+            ContainerKind::Type,
+            Some("struct".to_string()),
+            "store/store.go".to_string(),
+            6,
+            8,
+        );
+        store.metadata.visibility = Some("public".to_string());
+        graph.add_node(store);
+
+        let mut field = Node::data(
+            "store/store.go:Store:Name".to_string(),
+            "Name".to_string(),
+            DataKind::Field,
+            None,
+            "store/store.go".to_string(),
+            7,
+            7,
+        );
+        field.metadata.visibility = Some("public".to_string());
+        graph.add_node(field);
+        graph.add_edge(
+            "store/store.go:Store",
+            "store/store.go:Store:Name",
+            EdgeData::contains(),
+        );
+
+        for (name, visibility, line, end_line) in [
+            ("Get", "public", 12, 16),
+            ("Put", "public", 21, 21),
+            ("helper", "private", 23, 23),
+        ] {
+            let mut node = Node::callable(
+                format!("store/store.go:{}", name),
+                name.to_string(),
+                CallableKind::Method,
+                "store/store.go".to_string(),
+                line,
+                end_line,
+            );
+            node.metadata.visibility = Some(visibility.to_string());
+            if name != "helper" {
+                node.metadata.receiver = Some("Store".to_string());
+            }
+            graph.add_node(node);
+        }
+
+        let mut internal = Node::callable(
+            "internal/x.go:Exported".to_string(),
+            "Exported".to_string(),
+            CallableKind::Function,
+            "internal/x.go".to_string(),
+            1,
+            1,
+        );
+        internal.metadata.visibility = Some("public".to_string());
+        graph.add_node(internal);
+        graph
+    }
+
+    #[test]
+    fn test_api_surface() {
+        let dir = tempfile::tempdir().unwrap();
+        std::fs::create_dir_all(dir.path().join("store")).unwrap();
+        std::fs::write(dir.path().join("store/store.go"), SOURCE).unwrap();
+
+        let surface = api_surface(&sample_graph(), dir.path());
+        let names: Vec<&str> = surface.iter().map(|s| s.name.as_str()).collect();
+        assert_eq!(names, vec!["Store", "Name", "Get", "Put"]);
+
+        let store = &surface[0];
+        assert_eq!(store.signature.as_deref(), Some("type Store struct"));
+        assert_eq!(store.doc.as_deref(), Some("Store persists records."));
+        assert_eq!(store.stability, Stability::Stable);
+
+        assert_eq!(surface[1].owner.as_deref(), Some("Store"));
+
+        let get = &surface[2];
+        assert_eq!(
+            get.signature.as_deref(),
+            Some("func (s *Store) Get( key string, ) (string, error)")
+        );
+        assert_eq!(get.doc.as_deref(), Some("Get returns a record by key."));
+        assert_eq!(get.owner.as_deref(), Some("Store"));
+
+        let put = &surface[3];
+        assert_eq!(put.stability, Stability::Deprecated);
+        assert_eq!(put.doc.as_deref(), Some("Put stores a record."));
+    }
+
+    #[test]
+    fn test_stability_markers() {
+        assert_eq!(stability_from("", &[]), Stability::Stable);
+        assert_eq!(
+            stability_from("Frobnicate.\n@deprecated since 2.0", &[]),
+            Stability::Deprecated
+        );
+        assert_eq!(
+            stability_from("", &["#[deprecated(note = \"x\")]".to_string()]),
+            Stability::Deprecated
+        );
+        assert_eq!(stability_from("@beta", &[]), Stability::Experimental);
+        assert_eq!(
+            stability_from("", &["#[unstable(feature = \"x\")]".to_string()]),
+            Stability::Experimental
+        );
+    }
+
+    #[test]
+    fn test_python_docstring() {
+        let lines = vec![
+            "def fetch(url):",
+            "    \"\"\"Fetch a URL.",
+            "",
+            "    Retries on failure.\"\"\"",
+            "    pass",
+        ];
+        let (doc, _) = doc_comment(&lines, 1);
+        assert_eq!(summary(&doc).as_deref(), Some("Fetch a URL."));
+        assert_eq!(
+            declaration_signature(&lines, 1, 5).as_deref(),
+            Some("def fetch(url)")
+        );
+    }
+}
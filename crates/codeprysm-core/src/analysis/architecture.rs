@@ -0,0 +1,514 @@
+//! Architecture Rules
+//!
+//! Checks the dependency graph against layering rules a team declares in a
+//! rules file (by default `arch.codeprysm.yaml` at the repository root):
+//!
+//! ```yaml
+//! layers:
+//!   - name: handlers
+//!     paths: [internal/handlers]
+//!   - name: service
+//!     paths: [internal/service]
+//!   - name: storage
+//!     paths: ["internal/storage/**", internal/db]
+//!
+//! rules:
+//!   - from: handlers
+//!     deny: [storage]
+//!     reason: Handlers go through the service layer
+//!   - from: storage
+//!     allow: []
+//!     transitive: true
+//! ```
+//!
+//! A layer is a set of files: a path without glob characters matches the
+//! directory and everything below it. A file belongs to the first layer
+//! matching it; files in no layer are unrestricted. A rule lists the layers
+//! its `from` layer may not use (`deny`) or the only other layers it may use
+//! (`allow`). Dependencies are USES edges between symbols.
+//!
+//! By default only direct references break a rule. A `transitive` rule also
+//! catches dependencies through any number of intermediate symbols, and the
+//! violation reports the shortest such path.
+
+use std::collections::{BTreeMap, HashMap, HashSet, VecDeque};
+use std::path::Path;
+
+use serde::{Deserialize, Serialize};
+use thiserror::Error;
+
+use crate::graph::{EdgeType, PetCodeGraph};
+
+/// Default file name of the rules, at the repository root
+pub const ARCH_RULES_FILE: &str = "arch.codeprysm.yaml";
+
+/// SARIF rule ID for architecture findings
+pub const ARCHITECTURE_RULE: &str = "architecture";
+
+/// Errors that can occur while reading architecture rules.
+#[derive(Debug, Error)]
+pub enum ArchitectureError {
+    /// Failed to read the rules file
+    #[error("Failed to read architecture rules: {0}")]
+    Io(#[from] std::io::Error),
+
+    /// Malformed YAML
+    #[error("Invalid architecture rules: {0}")]
+    Yaml(#[from] serde_yaml::Error),
+
+    /// A layer is declared twice
+    #[error("Layer '{0}' is declared more than once")]
+    DuplicateLayer(String),
+
+    /// A rule names a layer that is not declared
+    #[error("Rule for '{rule}' names unknown layer '{layer}'")]
+    UnknownLayer { rule: String, layer: String },
+
+    /// A layer path is not a valid glob
+    #[error("Layer '{layer}' has invalid path pattern '{pattern}'")]
+    InvalidPattern { layer: String, pattern: String },
+}
+
+/// A named set of files.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct Layer {
+    /// Layer name used by rules
+    pub name: String,
+    /// Directories or glob patterns over file paths relative to the
+    /// repository root
+    pub paths: Vec<String>,
+}
+
+/// Dependencies a layer may or may not have.
+#[derive(Debug, Clone, Default, PartialEq, Eq, Serialize, Deserialize)]
+pub struct ArchRule {
+    /// Layer the rule restricts
+    pub from: String,
+    /// Layers `from` may not use
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub deny: Vec<String>,
+    /// The only other layers `from` may use (any layer if absent)
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub allow: Option<Vec<String>>,
+    /// Also forbid dependencies through intermediate symbols
+    #[serde(default)]
+    pub transitive: bool,
+    /// Why the rule exists, shown with violations
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub reason: Option<String>,
+}
+
+/// Layers and the rules between them.
+#[derive(Debug, Clone, Default, PartialEq, Eq, Serialize, Deserialize)]
+pub struct ArchitectureRules {
+    /// Layers, in matching order
+    #[serde(default)]
+    pub layers: Vec<Layer>,
+    /// Rules between layers
+    #[serde(default)]
+    pub rules: Vec<ArchRule>,
+}
+
+impl ArchitectureRules {
+    /// Parse and validate rules from YAML
+    pub fn from_yaml(input: &str) -> Result<Self, ArchitectureError> {
+        let rules: Self = serde_yaml::from_str(input)?;
+        rules.validate()?;
+        Ok(rules)
+    }
+
+    /// Read and validate a rules file
+    pub fn load(path: &Path) -> Result<Self, ArchitectureError> {
+        Self::from_yaml(&std::fs::read_to_string(path)?)
+    }
+
+    /// Check that layer names are unique, rules name declared layers, and
+    /// paths are valid patterns
+    pub fn validate(&self) -> Result<(), ArchitectureError> {
+        let mut names = HashSet::new();
+        for layer in &self.layers {
+            if !names.insert(layer.name.as_str()) {
+                return Err(ArchitectureError::DuplicateLayer(layer.name.clone()));
+            }
+            if let Some(pattern) = layer
+                .paths
+                .iter()
+                .find(|p| is_glob(p) && glob::Pattern::new(p).is_err())
+            {
+                return Err(ArchitectureError::InvalidPattern {
+                    layer: layer.name.clone(),
+                    pattern: pattern.clone(),
+                });
+            }
+        }
+        for rule in &self.rules {
+            let named = std::iter::once(&rule.from)
+                .chain(&rule.deny)
+                .chain(rule.allow.iter().flatten());
+            for layer in named {
+                if !names.contains(layer.as_str()) {
+                    return Err(ArchitectureError::UnknownLayer {
+                        rule: rule.from.clone(),
+                        layer: layer.clone(),
+                    });
+                }
+            }
+        }
+        Ok(())
+    }
+
+    /// Layer of a file, if any
+    pub fn layer_of(&self, file: &str) -> Option<&str> {
+        self.layers
+            .iter()
+            .find(|layer| layer.paths.iter().any(|p| path_matches(p, file)))
+            .map(|layer| layer.name.as_str())
+    }
+}
+
+/// A dependency breaking an architecture rule.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct ArchViolation {
+    /// Layer the rule restricts
+    pub from_layer: String,
+    /// Layer it must not use
+    pub to_layer: String,
+    /// Reason given with the rule
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub reason: Option<String>,
+    /// Symbol in `from_layer` the dependency starts at
+    pub source: String,
+    /// Symbol in `to_layer` it reaches
+    pub target: String,
+    /// File of the first reference on the path
+    pub file: String,
+    /// Line of the first reference (1-indexed, 0 if unknown)
+    pub line: usize,
+    /// Node IDs from `source` to `target` (just the two for direct
+    /// references)
+    pub path: Vec<String>,
+}
+
+/// Check every rule against the USES edges of `graph`, ordered by file and
+/// line.
+///
+/// A direct rule reports each offending pair of symbols once; a transitive
+/// rule reports each symbol of a forbidden layer reachable from the
+/// restricted one, with the shortest path to it.
+pub fn check_architecture(graph: &PetCodeGraph, rules: &ArchitectureRules) -> Vec<ArchViolation> {
+    // Layer of every node, by layer index
+    let index: HashMap<&str, usize> = rules
+        .layers
+        .iter()
+        .enumerate()
+        .map(|(i, layer)| (layer.name.as_str(), i))
+        .collect();
+    let mut file_layers: HashMap<&str, Option<usize>> = HashMap::new();
+    let mut node_layer: HashMap<&str, usize> = HashMap::new();
+    for node in graph.iter_nodes().filter(|n| !n.file.is_empty()) {
+        let layer = *file_layers.entry(node.file.as_str()).or_insert_with(|| {
+            rules
+                .layer_of(&node.file)
+                .and_then(|name| index.get(name).copied())
+        });
+        if let Some(layer) = layer {
+            node_layer.insert(node.id.as_str(), layer);
+        }
+    }
+
+    // Sorted dependencies of every symbol, with the line of the first
+    // reference
+    let mut uses: BTreeMap<&str, BTreeMap<&str, usize>> = BTreeMap::new();
+    for (source, target, edge) in graph.edges_by_type(EdgeType::Uses) {
+        if source.id == target.id {
+            continue;
+        }
+        let line = edge.ref_line.unwrap_or(0);
+        let entry = uses
+            .entry(source.id.as_str())
+            .or_default()
+            .entry(target.id.as_str())
+            .or_insert(line);
+        if line != 0 && (*entry == 0 || line < *entry) {
+            *entry = line;
+        }
+    }
+
+    let file_of = |id: &str| {
+        graph
+            .get_node(id)
+            .map_or_else(String::new, |n| n.file.clone())
+    };
+    let mut violations = Vec::new();
+    for rule in &rules.rules {
+        let Some(&from) = index.get(rule.from.as_str()) else {
+            continue;
+        };
+        let forbidden: HashSet<usize> = (0..rules.layers.len())
+            .filter(|&layer| layer != from)
+            .filter(|&layer| {
+                let name = rules.layers[layer].name.as_str();
+                rule.deny.iter().any(|d| d == name)
+                    || rule
+                        .allow
+                        .as_ref()
+                        .is_some_and(|allow| !allow.iter().any(|a| a == name))
+            })
+            .collect();
+        if forbidden.is_empty() {
+            continue;
+        }
+        let violation = |path: Vec<&str>, line: usize, to: usize| ArchViolation {
+            from_layer: rule.from.clone(),
+            to_layer: rules.layers[to].name.clone(),
+            reason: rule.reason.clone(),
+            source: path[0].to_string(),
+            target: path[path.len() - 1].to_string(),
+            file: file_of(path[0]),
+            line,
+            path: path.into_iter().map(String::from).collect(),
+        };
+
+        let sources: Vec<&str> = uses
+            .keys()
+            .copied()
+            .filter(|id| node_layer.get(id) == Some(&from))
+            .collect();
+        if !rule.transitive {
+            for source in sources {
+                for (&target, &line) in &uses[source] {
+                    if let Some(&to) = node_layer.get(target).filter(|l| forbidden.contains(*l)) {
+                        violations.push(violation(vec![source, target], line, to));
+                    }
+                }
+            }
+            continue;
+        }
+
+        // Breadth-first from every symbol of the layer at once, stopping at
+        // forbidden layers; each node remembers the symbol it was reached
+        // from and the line of the first reference
+        let mut parent: HashMap<&str, Option<(&str, usize)>> =
+            sources.iter().map(|&id| (id, None)).collect();
+        let mut queue: VecDeque<&str> = sources.into_iter().collect();
+        while let Some(current) = queue.pop_front() {
+            let Some(targets) = uses.get(current) else {
+                continue;
+            };
+            for (&target, &line) in targets {
+                if parent.contains_key(target) {
+                    continue;
+                }
+                let first_line = match parent[current] {
+                    Some((_, first)) => first,
+                    None => line,
+                };
+                parent.insert(target, Some((current, first_line)));
+                match node_layer.get(target) {
+                    Some(to) if forbidden.contains(to) => {
+                        let mut path = vec![target];
+                        let mut at = current;
+                        path.push(at);
+                        while let Some(Some((previous, _))) = parent.get(at) {
+                            at = previous;
+                            path.push(at);
+                        }
+                        path.reverse();
+                        violations.push(violation(path, first_line, *to));
+                    }
+                    _ => queue.push_back(target),
+                }
+            }
+        }
+    }
+
+    violations.sort_by(|a, b| {
+        (
+            &a.file,
+            a.line,
+            &a.source,
+            &a.target,
+            &a.from_layer,
+            &a.to_layer,
+        )
+            .cmp(&(
+                &b.file,
+                b.line,
+                &b.source,
+                &b.target,
+                &b.from_layer,
+                &b.to_layer,
+            ))
+    });
+    violations
+}
+
+/// Check if a path has glob characters
+fn is_glob(path: &str) -> bool {
+    path.contains(['*', '?', '['])
+}
+
+/// Check if a layer path matches a file: globs match the whole path, other
+/// paths match the directory (or file) and everything below it
+fn path_matches(path: &str, file: &str) -> bool {
+    if is_glob(path) {
+        return glob::Pattern::new(path).is_ok_and(|p| p.matches(file));
+    }
+    let path = path.trim_end_matches('/');
+    path.is_empty()
+        || path == "."
+        || file == path
+        || file
+            .strip_prefix(path)
+            .is_some_and(|rest| rest.starts_with('/'))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::EdgeData;
+    use crate::test_support::add_fn;
+
+    const RULES: &str = r#"
+layers:
+  - name: handlers
+    paths: [internal/handlers]
+  - name: service
+    paths: [internal/service/]
+  - name: storage
+    paths: ["internal/storage/*", internal/db]
+
+rules:
+  - from: handlers
+    deny: [storage]
+    reason: Handlers go through the service layer
+  - from: storage
+    allow: []
+    transitive: true
+"#;
+
+    fn sample() -> PetCodeGraph {
+        let mut graph = PetCodeGraph::new();
+        for id in [
+            "internal/handlers/user.go:GetUser",
+            "internal/service/user.go:FindUser",
+            "internal/storage/users.go:Load",
+            "internal/db/conn.go:Query",
+            "pkg/util/strings.go:Trim",
+        ] {
+            add_fn(&mut graph, id);
+        }
+        for (source, target, line) in [
+            // Allowed: handlers -> service -> storage
+            (
+                "internal/handlers/user.go:GetUser",
+                "internal/service/user.go:FindUser",
+                10,
+            ),
+            (
+                "internal/service/user.go:FindUser",
+                "internal/storage/users.go:Load",
+                20,
+            ),
+            // Direct handlers -> storage (and db, also storage)
+            (
+                "internal/handlers/user.go:GetUser",
+                "internal/db/conn.go:Query",
+                12,
+            ),
+            // storage -> unlayered util -> service, reaching another layer
+            (
+                "internal/storage/users.go:Load",
+                "pkg/util/strings.go:Trim",
+                30,
+            ),
+            (
+                "pkg/util/strings.go:Trim",
+                "internal/service/user.go:FindUser",
+                40,
+            ),
+            // Within the layer
+            (
+                "internal/storage/users.go:Load",
+                "internal/db/conn.go:Query",
+                31,
+            ),
+        ] {
+            graph.add_edge(source, target, EdgeData::uses(Some(line), None));
+        }
+        graph
+    }
+
+    #[test]
+    fn test_layer_of() {
+        let rules = ArchitectureRules::from_yaml(RULES).unwrap();
+        assert_eq!(
+            rules.layer_of("internal/handlers/v1/user.go"),
+            Some("handlers")
+        );
+        assert_eq!(rules.layer_of("internal/service/user.go"), Some("service"));
+        assert_eq!(rules.layer_of("internal/storage/users.go"), Some("storage"));
+        assert_eq!(rules.layer_of("internal/db/conn.go"), Some("storage"));
+        assert_eq!(rules.layer_of("internal/handlersx/user.go"), None);
+        assert_eq!(rules.layer_of("pkg/util/strings.go"), None);
+    }
+
+    #[test]
+    fn test_validate() {
+        let unknown = "layers: [{name: a, paths: [a]}]\nrules: [{from: a, deny: [b]}]";
+        assert!(matches!(
+            ArchitectureRules::from_yaml(unknown),
+            Err(ArchitectureError::UnknownLayer { .. })
+        ));
+        let duplicate = "layers: [{name: a, paths: [a]}, {name: a, paths: [b]}]";
+        assert!(matches!(
+            ArchitectureRules::from_yaml(duplicate),
+            Err(ArchitectureError::DuplicateLayer(_))
+        ));
+    }
+
+    #[test]
+    fn test_check_architecture() {
+        let rules = ArchitectureRules::from_yaml(RULES).unwrap();
+        let violations = check_architecture(&sample(), &rules);
+        assert_eq!(violations.len(), 2);
+
+        let direct = &violations[0];
+        assert_eq!(
+            (direct.from_layer.as_str(), direct.to_layer.as_str()),
+            ("handlers", "storage")
+        );
+        assert_eq!(direct.target, "internal/db/conn.go:Query");
+        assert_eq!(
+            (direct.file.as_str(), direct.line),
+            ("internal/handlers/user.go", 12)
+        );
+        assert_eq!(direct.path.len(), 2);
+        assert_eq!(
+            direct.reason.as_deref(),
+            Some("Handlers go through the service layer")
+        );
+
+        // storage reaches service through an unlayered package
+        let transitive = &violations[1];
+        assert_eq!(transitive.to_layer, "service");
+        assert_eq!(
+            transitive.path,
+            vec![
+                "internal/storage/users.go:Load",
+                "pkg/util/strings.go:Trim",
+                "internal/service/user.go:FindUser"
+            ]
+        );
+        assert_eq!(transitive.line, 30);
+    }
+
+    #[test]
+    fn test_check_architecture_direct_only() {
+        let mut rules = ArchitectureRules::from_yaml(RULES).unwrap();
+        rules.rules[1].transitive = false;
+        let violations = check_architecture(&sample(), &rules);
+        assert_eq!(violations.len(), 1);
+        assert_eq!(violations[0].from_layer, "handlers");
+    }
+}
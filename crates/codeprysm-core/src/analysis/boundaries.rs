@@ -0,0 +1,140 @@
+//! Internal Package Boundaries
+//!
+//! Reports references that cross into an `internal` directory from outside
+//! the tree it belongs to. Following Go's rule, code under `a/b/internal/...`
+//! may only be used by code rooted at `a/b`; an `internal` directory at the
+//! repository root is open to the whole repository. Nested `internal`
+//! directories are checked against the innermost one.
+//!
+//! The rule is applied to every language, since the convention is common in
+//! other ecosystems too.
+
+use serde::Serialize;
+
+use super::package_of;
+use crate::graph::{EdgeType, PetCodeGraph};
+
+/// SARIF rule ID for internal boundary findings
+pub const BOUNDARY_RULE: &str = "internal-boundary";
+
+/// Directory name that marks a package tree as internal
+const INTERNAL_DIR: &str = "internal";
+
+/// A reference to an internal symbol from outside its allowed tree.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize)]
+pub struct BoundaryViolation {
+    /// Referencing node ID
+    pub source: String,
+    /// Referenced node ID
+    pub target: String,
+    /// File of the reference
+    pub file: String,
+    /// Line of the reference (1-indexed, 0 if unknown)
+    pub line: usize,
+    /// Directory whose code may use the target ("" for the repository root)
+    pub allowed_root: String,
+}
+
+/// Directory allowed to use code in `file`, or `None` if the file is not
+/// inside an `internal` directory.
+pub fn internal_root(file: &str) -> Option<&str> {
+    let package = package_of(file);
+    let components: Vec<&str> = package.split('/').collect();
+    let pos = components.iter().rposition(|c| *c == INTERNAL_DIR)?;
+    let len: usize = components[..pos].iter().map(|c| c.len() + 1).sum();
+    Some(&package[..len.saturating_sub(1)])
+}
+
+/// Find USES edges that reach into an `internal` directory from outside its
+/// allowed tree, ordered by file and line.
+pub fn find_boundary_violations(graph: &PetCodeGraph) -> Vec<BoundaryViolation> {
+    let mut violations: Vec<BoundaryViolation> = graph
+        .iter_edges()
+        .filter(|edge| edge.edge_type == EdgeType::Uses)
+        .filter_map(|edge| {
+            let source = graph.get_node(&edge.source)?;
+            let target = graph.get_node(&edge.target)?;
+            let allowed_root = internal_root(&target.file)?;
+            if is_within(package_of(&source.file), allowed_root) {
+                return None;
+            }
+            Some(BoundaryViolation {
+                file: source.file.clone(),
+                line: edge.ref_line.unwrap_or(0),
+                allowed_root: allowed_root.to_string(),
+                source: edge.source,
+                target: edge.target,
+            })
+        })
+        .collect();
+
+    violations.sort_by(|a, b| {
+        (&a.file, a.line, &a.source, &a.target).cmp(&(&b.file, b.line, &b.source, &b.target))
+    });
+    violations
+}
+
+/// Check if `package` is `root` or below it
+fn is_within(package: &str, root: &str) -> bool {
+    root.is_empty()
+        || package == root
+        || package
+            .strip_prefix(root)
+            .is_some_and(|rest| rest.starts_with('/'))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::EdgeData;
+    use crate::test_support::add_fn;
+
+    #[test]
+    fn test_internal_root() {
+        assert_eq!(internal_root("a/b/internal/c/x.go"), Some("a/b"));
+        assert_eq!(internal_root("internal/x.go"), Some(""));
+        assert_eq!(
+            internal_root("a/internal/b/internal/x.go"),
+            Some("a/internal/b")
+        );
+        assert_eq!(internal_root("a/internals/x.go"), None);
+        assert_eq!(internal_root("internal.go"), None);
+    }
+
+    #[test]
+    fn test_find_boundary_violations() {
+        let mut graph = PetCodeGraph::new();
+        for id in [
+            "svc/internal/db/db.go:Open",
+            "svc/api/api.go:Serve",
+            "svc/main.go:main",
+            "other/cmd.go:Run",
+            "internal/log/log.go:Info",
+        ] {
+            add_fn(&mut graph, id);
+        }
+        for (source, target, line) in [
+            ("svc/api/api.go:Serve", "svc/internal/db/db.go:Open", 3),
+            ("svc/main.go:main", "svc/internal/db/db.go:Open", 4),
+            ("other/cmd.go:Run", "svc/internal/db/db.go:Open", 7),
+            ("other/cmd.go:Run", "internal/log/log.go:Info", 8),
+        ] {
+            graph.add_edge(source, target, EdgeData::uses(Some(line), None));
+        }
+
+        let violations = find_boundary_violations(&graph);
+        assert_eq!(violations.len(), 1);
+        assert_eq!(violations[0].source, "other/cmd.go:Run");
+        assert_eq!(violations[0].target, "svc/internal/db/db.go:Open");
+        assert_eq!(violations[0].line, 7);
+        assert_eq!(violations[0].allowed_root, "svc");
+    }
+
+    #[test]
+    fn test_is_within() {
+        assert!(is_within("svc/api", "svc"));
+        assert!(is_within("svc", "svc"));
+        assert!(!is_within("svcx", "svc"));
+        assert!(is_within("anything", ""));
+    }
+}
@@ -0,0 +1,569 @@
+//! Build Graph Reconciliation
+//!
+//! Compares the dependencies a build system declares between packages with
+//! the dependencies the code actually has (cross-package USES edges), and
+//! reports the differences:
+//!
+//! - **Undeclared**: code references a package its build package does not
+//!   declare as a dependency (it builds only through a transitive edge, or
+//!   the reference resolved to the wrong package)
+//! - **Unused**: a declared dependency no code references
+//!
+//! Supported build graphs:
+//!
+//! - `go list -deps -json ./...`
+//! - `bazel query 'deps(//...)' --output=streamed_jsonproto`
+//! - `bazel query 'deps(//...)' --output=graph --nograph:factored`
+//!
+//! Build packages are directories relative to the repository root. Source
+//! packages without a build package of their own belong to the nearest
+//! enclosing one, as files without a BUILD file do in Bazel. Only packages
+//! inside the repository are compared; the standard library and external
+//! modules are skipped. Test code is ignored on both sides, as Go's
+//! `go list` omits test imports: `*_test` Bazel rules are skipped where the
+//! rule class is known (for graph output, exclude them in the query).
+
+use std::collections::{BTreeMap, BTreeSet, HashMap};
+use std::path::Path;
+
+use serde::{Deserialize, Serialize};
+use thiserror::Error;
+
+use super::cycles::{collect_dependencies, CycleLevel};
+use crate::graph::PetCodeGraph;
+
+/// SARIF rule ID for undeclared build dependency findings
+pub const UNDECLARED_DEPENDENCY_RULE: &str = "undeclared-build-dependency";
+
+/// SARIF rule ID for unused build dependency findings
+pub const UNUSED_DEPENDENCY_RULE: &str = "unused-build-dependency";
+
+/// Errors that can occur while reading a build graph.
+#[derive(Debug, Error)]
+pub enum BuildGraphError {
+    /// Failed to read the build graph
+    #[error("Failed to read build graph: {0}")]
+    Io(#[from] std::io::Error),
+
+    /// Malformed JSON output
+    #[error("Invalid build graph JSON: {0}")]
+    Json(#[from] serde_json::Error),
+
+    /// Input is not a supported build graph
+    #[error("Unrecognized build graph: expected `go list -deps -json` or `bazel query` output (streamed_jsonproto or graph)")]
+    UnknownFormat,
+}
+
+/// Build system a graph was read from.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum BuildSystem {
+    /// `go list`
+    Go,
+    /// `bazel query`
+    Bazel,
+}
+
+/// A build package and the packages it declares as dependencies.
+#[derive(Debug, Clone, Default, PartialEq, Eq, Serialize, Deserialize)]
+pub struct BuildPackage {
+    /// File declaring the dependencies (BUILD file, or the first Go source
+    /// file), relative to the repository root, if known
+    pub declared_in: Option<String>,
+    /// Line of the declaration (1-indexed, 0 if unknown)
+    pub line: usize,
+    /// Package directories depended on
+    pub dependencies: BTreeSet<String>,
+}
+
+/// Package-level dependencies declared by a build system.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct BuildGraph {
+    /// Build system the dependencies come from
+    pub system: BuildSystem,
+    /// Build packages by directory ("" for the repository root)
+    pub packages: BTreeMap<String, BuildPackage>,
+}
+
+/// Kind of build graph discrepancy.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, PartialOrd, Ord, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum DiscrepancyKind {
+    /// Referenced in code but not declared
+    Undeclared,
+    /// Declared but never referenced in code
+    Unused,
+}
+
+impl DiscrepancyKind {
+    /// Get the string representation
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            DiscrepancyKind::Undeclared => "undeclared",
+            DiscrepancyKind::Unused => "unused",
+        }
+    }
+}
+
+/// A dependency on which the build graph and the code disagree.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct BuildDiscrepancy {
+    /// Kind of discrepancy
+    pub kind: DiscrepancyKind,
+    /// Depending build package
+    pub from: String,
+    /// Build package depended on
+    pub to: String,
+    /// Number of code references (0 for unused dependencies)
+    pub references: usize,
+    /// Sample reference for undeclared dependencies, declaration for unused
+    /// ones ("" if unknown)
+    pub file: String,
+    /// Line of `file` (1-indexed, 0 if unknown)
+    pub line: usize,
+}
+
+impl BuildGraph {
+    /// Parse build graph output, detecting its format.
+    ///
+    /// `repo_root` maps the absolute paths in `go list` and Bazel JSON output
+    /// to repository-relative packages.
+    pub fn parse(input: &str, repo_root: &Path) -> Result<Self, BuildGraphError> {
+        let trimmed = input.trim_start();
+        if trimmed.starts_with("digraph") {
+            Ok(Self::from_bazel_graph(input))
+        } else if trimmed.starts_with('{') {
+            let first: serde_json::Value = serde_json::Deserializer::from_str(trimmed)
+                .into_iter()
+                .next()
+                .ok_or(BuildGraphError::UnknownFormat)??;
+            if first.get("ImportPath").is_some() {
+                Self::from_go_list(input, repo_root)
+            } else if first.get("type").is_some() {
+                Self::from_bazel_jsonproto(input, repo_root)
+            } else {
+                Err(BuildGraphError::UnknownFormat)
+            }
+        } else {
+            Err(BuildGraphError::UnknownFormat)
+        }
+    }
+
+    /// Read and parse build graph output
+    pub fn load(path: &Path, repo_root: &Path) -> Result<Self, BuildGraphError> {
+        Self::parse(&std::fs::read_to_string(path)?, repo_root)
+    }
+
+    /// Parse `go list -deps -json` output: a stream of package objects.
+    pub fn from_go_list(input: &str, repo_root: &Path) -> Result<Self, BuildGraphError> {
+        #[derive(Deserialize)]
+        #[serde(rename_all = "PascalCase")]
+        struct GoPackage {
+            import_path: String,
+            #[serde(default)]
+            dir: String,
+            #[serde(default)]
+            standard: bool,
+            #[serde(default)]
+            go_files: Vec<String>,
+            #[serde(default)]
+            imports: Vec<String>,
+        }
+
+        let mut listed = Vec::new();
+        for package in serde_json::Deserializer::from_str(input).into_iter::<GoPackage>() {
+            listed.push(package?);
+        }
+
+        let dirs: HashMap<&str, String> = listed
+            .iter()
+            .filter(|p| !p.standard)
+            .filter_map(|p| Some((p.import_path.as_str(), relative_to(&p.dir, repo_root)?)))
+            .collect();
+
+        let mut packages = BTreeMap::new();
+        for package in &listed {
+            let Some(dir) = dirs.get(package.import_path.as_str()) else {
+                continue;
+            };
+            let declared_in = package.go_files.first().map(|f| join(dir, f));
+            let dependencies = package
+                .imports
+                .iter()
+                .filter_map(|import| dirs.get(import.as_str()).cloned())
+                .collect();
+            packages.insert(
+                dir.clone(),
+                BuildPackage {
+                    declared_in,
+                    line: 0,
+                    dependencies,
+                },
+            );
+        }
+
+        Ok(Self {
+            system: BuildSystem::Go,
+            packages,
+        })
+    }
+
+    /// Parse `bazel query --output=streamed_jsonproto` output: one target
+    /// per line. Rules in the same directory are merged into one package.
+    pub fn from_bazel_jsonproto(input: &str, repo_root: &Path) -> Result<Self, BuildGraphError> {
+        #[derive(Deserialize)]
+        #[serde(rename_all = "camelCase")]
+        struct Target {
+            #[serde(rename = "type")]
+            target_type: String,
+            rule: Option<Rule>,
+        }
+        #[derive(Deserialize)]
+        #[serde(rename_all = "camelCase")]
+        struct Rule {
+            name: String,
+            #[serde(default)]
+            rule_class: String,
+            #[serde(default)]
+            location: String,
+            #[serde(default)]
+            attribute: Vec<Attribute>,
+        }
+        #[derive(Deserialize)]
+        #[serde(rename_all = "camelCase")]
+        struct Attribute {
+            name: String,
+            #[serde(default)]
+            string_list_value: Vec<String>,
+        }
+
+        let mut packages: BTreeMap<String, BuildPackage> = BTreeMap::new();
+        for target in serde_json::Deserializer::from_str(input).into_iter::<Target>() {
+            let target = target?;
+            let Some(rule) = target.rule.filter(|_| target.target_type == "RULE") else {
+                continue;
+            };
+            if rule.rule_class.ends_with("_test") {
+                continue;
+            }
+            let Some(dir) = label_package(&rule.name) else {
+                continue;
+            };
+
+            let package = packages.entry(dir.to_string()).or_default();
+            if package.declared_in.is_none() {
+                // "/abs/path/pkg/BUILD.bazel:12:8"
+                let mut location = rule.location.rsplitn(3, ':');
+                let (_column, line, file) = (location.next(), location.next(), location.next());
+                if let Some(file) = file.and_then(|f| relative_to(f, repo_root)) {
+                    package.declared_in = Some(file);
+                    package.line = line.and_then(|l| l.parse().ok()).unwrap_or(0);
+                }
+            }
+            for attribute in rule.attribute.iter().filter(|a| a.name == "deps") {
+                package.dependencies.extend(
+                    attribute
+                        .string_list_value
+                        .iter()
+                        .filter_map(|label| label_package(label))
+                        .filter(|&to| to != dir)
+                        .map(str::to_string),
+                );
+            }
+        }
+
+        Ok(Self {
+            system: BuildSystem::Bazel,
+            packages,
+        })
+    }
+
+    /// Parse `bazel query --output=graph --nograph:factored` output: a DOT
+    /// digraph of `"//a:x" -> "//b:y"` edges. Edges to source files in the
+    /// same package are dropped with the other intra-package edges.
+    pub fn from_bazel_graph(input: &str) -> Self {
+        let mut packages: BTreeMap<String, BuildPackage> = BTreeMap::new();
+        for line in input.lines() {
+            let quoted: Vec<&str> = line.split('"').skip(1).step_by(2).collect();
+            let Some(from) = quoted.first().and_then(|l| label_package(l)) else {
+                continue;
+            };
+            let package = packages.entry(from.to_string()).or_default();
+            if let Some(to) = quoted.get(1).and_then(|l| label_package(l)) {
+                if to != from && line.contains("->") {
+                    package.dependencies.insert(to.to_string());
+                }
+            }
+        }
+
+        Self {
+            system: BuildSystem::Bazel,
+            packages,
+        }
+    }
+
+    /// Build package owning a source package directory: the directory itself
+    /// or its nearest enclosing build package.
+    pub fn owning_package<'a>(&'a self, dir: &str) -> Option<&'a str> {
+        let mut current = dir;
+        loop {
+            if let Some((key, _)) = self.packages.get_key_value(current) {
+                return Some(key);
+            }
+            if current.is_empty() {
+                return None;
+            }
+            current = current.rsplit_once('/').map_or("", |(parent, _)| parent);
+        }
+    }
+}
+
+/// Package directory of a Bazel label in the main repository
+/// (`//pkg/sub:target` or `@//pkg/sub` is "pkg/sub"), or `None` for labels
+/// in external repositories.
+fn label_package(label: &str) -> Option<&str> {
+    let label = label.trim_start_matches('@');
+    let path = label.strip_prefix("//")?;
+    Some(path.split_once(':').map_or(path, |(package, _)| package))
+}
+
+/// `path` relative to `repo_root` with `/` separators, or `None` if it is
+/// outside the repository.
+fn relative_to(path: &str, repo_root: &Path) -> Option<String> {
+    let path = Path::new(path);
+    let relative = match path.strip_prefix(repo_root) {
+        Ok(relative) => relative.to_path_buf(),
+        // go list and Bazel report resolved paths
+        Err(_) => path
+            .strip_prefix(repo_root.canonicalize().ok()?)
+            .ok()?
+            .to_path_buf(),
+    };
+    Some(
+        relative
+            .components()
+            .filter_map(|c| c.as_os_str().to_str())
+            .collect::<Vec<_>>()
+            .join("/"),
+    )
+}
+
+/// Join a package directory and a file name
+fn join(dir: &str, file: &str) -> String {
+    if dir.is_empty() {
+        file.to_string()
+    } else {
+        format!("{}/{}", dir, file)
+    }
+}
+
+/// Compare the declared build dependencies with the code's package
+/// dependencies, sorted by (from, to, kind).
+pub fn reconcile_build_graph(graph: &PetCodeGraph, build: &BuildGraph) -> Vec<BuildDiscrepancy> {
+    // Code dependencies between build packages, with a sample reference
+    let mut actual: BTreeMap<(&str, &str), (usize, String, usize)> = BTreeMap::new();
+    for dependency in collect_dependencies(graph, CycleLevel::Package) {
+        let (Some(from), Some(to)) = (
+            build.owning_package(&dependency.from),
+            build.owning_package(&dependency.to),
+        ) else {
+            continue;
+        };
+        if from == to {
+            continue;
+        }
+        actual
+            .entry((from, to))
+            .or_insert_with(|| (0, dependency.file.clone(), dependency.line))
+            .0 += dependency.references;
+    }
+
+    let mut discrepancies = Vec::new();
+    for (&(from, to), (references, file, line)) in &actual {
+        if !build.packages[from].dependencies.contains(to) {
+            discrepancies.push(BuildDiscrepancy {
+                kind: DiscrepancyKind::Undeclared,
+                from: from.to_string(),
+                to: to.to_string(),
+                references: *references,
+                file: file.clone(),
+                line: *line,
+            });
+        }
+    }
+    for (from, package) in &build.packages {
+        for to in &package.dependencies {
+            let declared_in_repo = build.packages.contains_key(to);
+            if declared_in_repo && !actual.contains_key(&(from.as_str(), to.as_str())) {
+                discrepancies.push(BuildDiscrepancy {
+                    kind: DiscrepancyKind::Unused,
+                    from: from.clone(),
+                    to: to.clone(),
+                    references: 0,
+                    file: package.declared_in.clone().unwrap_or_default(),
+                    line: package.line,
+                });
+            }
+        }
+    }
+
+    discrepancies.sort_by(|a, b| (&a.from, &a.to, a.kind).cmp(&(&b.from, &b.to, b.kind)));
+    discrepancies
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::EdgeData;
+    use crate::test_support::add_fn;
+
+    /// api calls store and util; cmd calls api
+    fn sample_graph() -> PetCodeGraph {
+        let mut graph = PetCodeGraph::new();
+        for (file, name) in [
+            ("cmd/main.go", "main"),
+            ("api/server.go", "Serve"),
+            ("store/db.go", "Open"),
+            ("store/sql/query.go", "Query"),
+            ("util/strings.go", "Trim"),
+        ] {
+            add_fn(&mut graph, &format!("{}:{}", file, name));
+        }
+        graph.add_edge(
+            "cmd/main.go:main",
+            "api/server.go:Serve",
+            EdgeData::uses(Some(3), None),
+        );
+        graph.add_edge(
+            "api/server.go:Serve",
+            "store/sql/query.go:Query",
+            EdgeData::uses(Some(4), None),
+        );
+        graph.add_edge(
+            "api/server.go:Serve",
+            "util/strings.go:Trim",
+            EdgeData::uses(Some(5), None),
+        );
+        graph
+    }
+
+    #[test]
+    fn test_go_list() {
+        let root = Path::new("/src/repo");
+        let input = r#"{
+	"Dir": "/usr/local/go/src/fmt",
+	"ImportPath": "fmt",
+	"Standard": true
+}
+{
+	"Dir": "/src/repo/api",
+	"ImportPath": "example.com/repo/api",
+	"GoFiles": ["server.go"],
+	"Imports": ["example.com/repo/store", "fmt", "github.com/pkg/errors"]
+}
+{
+	"Dir": "/src/repo/store",
+	"ImportPath": "example.com/repo/store",
+	"GoFiles": ["db.go"]
+}
+{
+	"Dir": "/home/u/go/pkg/mod/github.com/pkg/errors@v0.9.1",
+	"ImportPath": "github.com/pkg/errors"
+}"#;
+
+        let build = BuildGraph::parse(input, root).unwrap();
+        assert_eq!(build.system, BuildSystem::Go);
+        assert_eq!(
+            build.packages.keys().collect::<Vec<_>>(),
+            vec!["api", "store"]
+        );
+        let api = &build.packages["api"];
+        assert_eq!(api.declared_in.as_deref(), Some("api/server.go"));
+        assert_eq!(api.dependencies.iter().collect::<Vec<_>>(), vec!["store"]);
+    }
+
+    #[test]
+    fn test_bazel_jsonproto() {
+        let root = Path::new("/src/repo");
+        let input = concat!(
+            r#"{"type":"RULE","rule":{"name":"//api:api","ruleClass":"go_library","location":"/src/repo/api/BUILD.bazel:3:11","attribute":[{"name":"deps","type":"LABEL_LIST","stringListValue":["//store","@com_github_pkg_errors//:errors"]}]}}"#,
+            "\n",
+            r#"{"type":"RULE","rule":{"name":"//api:api_test","ruleClass":"go_test","attribute":[{"name":"deps","stringListValue":["//util"]}]}}"#,
+            "\n",
+            r#"{"type":"SOURCE_FILE","sourceFile":{"name":"//api:server.go"}}"#,
+            "\n",
+        );
+
+        let build = BuildGraph::parse(input, root).unwrap();
+        assert_eq!(build.system, BuildSystem::Bazel);
+        let api = &build.packages["api"];
+        assert_eq!(api.declared_in.as_deref(), Some("api/BUILD.bazel"));
+        assert_eq!(api.line, 3);
+        assert_eq!(api.dependencies.iter().collect::<Vec<_>>(), vec!["store"]);
+    }
+
+    #[test]
+    fn test_bazel_graph() {
+        let input = r#"digraph mygraph {
+  node [shape=box];
+  "//api:api"
+  "//api:api" -> "//store:store"
+  "//api:api" -> "//api:server.go"
+  "//store:store"
+  "//store:store" -> "@io_bazel_rules_go//go/platform:darwin"
+}
+"#;
+        let build = BuildGraph::parse(input, Path::new("/src/repo")).unwrap();
+        assert_eq!(
+            build.packages["api"]
+                .dependencies
+                .iter()
+                .collect::<Vec<_>>(),
+            vec!["store"]
+        );
+        assert!(build.packages["store"].dependencies.is_empty());
+    }
+
+    #[test]
+    fn test_reconcile() {
+        let mut packages = BTreeMap::new();
+        let package = |deps: &[&str]| BuildPackage {
+            declared_in: None,
+            line: 0,
+            dependencies: deps.iter().map(|d| d.to_string()).collect(),
+        };
+        packages.insert("cmd".to_string(), package(&["api"]));
+        // api uses util without declaring it, and declares cmd without using it
+        packages.insert("api".to_string(), package(&["store", "cmd"]));
+        packages.insert("store".to_string(), package(&[]));
+        packages.insert("util".to_string(), package(&[]));
+        let build = BuildGraph {
+            system: BuildSystem::Bazel,
+            packages,
+        };
+
+        let found = reconcile_build_graph(&sample_graph(), &build);
+        let summary: Vec<(DiscrepancyKind, &str, &str)> = found
+            .iter()
+            .map(|d| (d.kind, d.from.as_str(), d.to.as_str()))
+            .collect();
+        // store/sql has no build package, so it belongs to store
+        assert_eq!(
+            summary,
+            vec![
+                (DiscrepancyKind::Unused, "api", "cmd"),
+                (DiscrepancyKind::Undeclared, "api", "util"),
+            ]
+        );
+        assert_eq!(found[1].file, "api/server.go");
+        assert_eq!(found[1].line, 5);
+    }
+
+    #[test]
+    fn test_unknown_format() {
+        assert!(matches!(
+            BuildGraph::parse("not a build graph", Path::new("/")),
+            Err(BuildGraphError::UnknownFormat)
+        ));
+    }
+}
@@ -0,0 +1,480 @@
+//! Call Hierarchy Trees
+//!
+//! Expands the callers (incoming) or callees (outgoing) of a symbol into a
+//! tree in the shape of the LSP `callHierarchy` requests, so editor plugins
+//! and the web UI can render expandable hierarchies without converting:
+//! items are `CallHierarchyItem`s and calls are
+//! `CallHierarchyIncomingCall`/`CallHierarchyOutgoingCall` objects, each
+//! with its own expansion in an extra `calls` field. Positions are 0-indexed
+//! with UTF-16 columns, as in LSP.
+//!
+//! With a repository root, items carry `file://` URIs and the columns of the
+//! name on each line are read from the sources. Without one, `uri` is the
+//! path relative to the repository root and ranges cover whole lines.
+//!
+//! Each call is expanded unless the depth limit is reached or its symbol is
+//! already on the path from the root (recursion).
+
+use std::collections::HashMap;
+use std::path::{Path, PathBuf};
+
+use serde::{Deserialize, Serialize};
+
+use super::navigation::{call_edges, CallDirection};
+use crate::graph::{Node, NodeType, PetCodeGraph};
+
+/// Default number of levels expanded below the root
+pub const DEFAULT_HIERARCHY_DEPTH: usize = 3;
+
+/// Default maximum number of calls in a tree
+pub const DEFAULT_HIERARCHY_MAX_CALLS: usize = 500;
+
+/// LSP position (0-indexed line, UTF-16 column).
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, Serialize, Deserialize)]
+pub struct Position {
+    pub line: usize,
+    pub character: usize,
+}
+
+/// LSP range.
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, Serialize, Deserialize)]
+pub struct Range {
+    pub start: Position,
+    pub end: Position,
+}
+
+/// Data round-tripped by LSP clients to identify an item.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct ItemData {
+    /// Node ID
+    pub id: String,
+}
+
+/// LSP `CallHierarchyItem`.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct CallHierarchyItem {
+    pub name: String,
+    /// LSP `SymbolKind`
+    pub kind: u32,
+    /// Node ID
+    pub detail: String,
+    pub uri: String,
+    /// Span of the declaration
+    pub range: Range,
+    /// Span of the name in the declaration
+    pub selection_range: Range,
+    pub data: ItemData,
+}
+
+/// LSP `CallHierarchyIncomingCall` (with `from`) or
+/// `CallHierarchyOutgoingCall` (with `to`), expanded further in `calls`.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct CallHierarchyCall {
+    /// The caller, for incoming calls
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub from: Option<CallHierarchyItem>,
+    /// The callee, for outgoing calls
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub to: Option<CallHierarchyItem>,
+    /// Call sites, in the caller's file
+    pub from_ranges: Vec<Range>,
+    /// Whether the symbol is already on the path from the root
+    #[serde(default, skip_serializing_if = "std::ops::Not::not")]
+    pub recursive: bool,
+    /// Calls of the caller or callee, one level further
+    pub calls: Vec<CallHierarchyCall>,
+}
+
+impl CallHierarchyCall {
+    /// The caller or callee
+    pub fn item(&self) -> &CallHierarchyItem {
+        self.from
+            .as_ref()
+            .or(self.to.as_ref())
+            .expect("a call has a caller or a callee")
+    }
+}
+
+/// A call hierarchy tree rooted at one symbol.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct CallHierarchy {
+    /// The symbol the hierarchy is rooted at
+    pub item: CallHierarchyItem,
+    /// "incoming" (callers) or "outgoing" (callees)
+    pub direction: String,
+    /// Calls of the root symbol
+    pub calls: Vec<CallHierarchyCall>,
+    /// Whether expansion stopped at the call limit
+    pub truncated: bool,
+}
+
+impl CallHierarchy {
+    /// Number of calls in the tree
+    pub fn call_count(&self) -> usize {
+        fn count(calls: &[CallHierarchyCall]) -> usize {
+            calls.iter().map(|c| 1 + count(&c.calls)).sum()
+        }
+        count(&self.calls)
+    }
+}
+
+/// Options for building a call hierarchy.
+#[derive(Debug, Clone)]
+pub struct CallHierarchyOptions {
+    /// Levels expanded below the root
+    pub depth: usize,
+    /// Maximum number of calls in the tree
+    pub max_calls: usize,
+}
+
+impl Default for CallHierarchyOptions {
+    fn default() -> Self {
+        Self {
+            depth: DEFAULT_HIERARCHY_DEPTH,
+            max_calls: DEFAULT_HIERARCHY_MAX_CALLS,
+        }
+    }
+}
+
+/// Build the call hierarchy of a callable. None if `id` is not a callable.
+///
+/// `root` is the repository root, for `file://` URIs and name columns.
+pub fn call_hierarchy(
+    graph: &PetCodeGraph,
+    id: &str,
+    direction: CallDirection,
+    root: Option<&Path>,
+    options: &CallHierarchyOptions,
+) -> Option<CallHierarchy> {
+    let node = graph
+        .get_node(id)
+        .filter(|n| n.node_type == NodeType::Callable)?;
+    let mut builder = Builder {
+        graph,
+        direction,
+        sources: Sources::new(root),
+        options,
+        path: vec![node.id.as_str()],
+        calls: 0,
+        truncated: false,
+    };
+    let item = builder.sources.item(node);
+    let calls = builder.expand(node, 1);
+    Some(CallHierarchy {
+        item,
+        direction: match direction {
+            CallDirection::Incoming => "incoming",
+            CallDirection::Outgoing => "outgoing",
+        }
+        .to_string(),
+        calls,
+        truncated: builder.truncated,
+    })
+}
+
+/// Map a node to an LSP `SymbolKind`
+pub fn symbol_kind(node_type: NodeType, kind: Option<&str>, subtype: Option<&str>) -> u32 {
+    match (node_type, kind, subtype) {
+        (NodeType::Callable, Some("method"), _) => 6,
+        (NodeType::Callable, Some("constructor"), _) => 9,
+        (NodeType::Callable, _, _) => 12,
+        (NodeType::Container, Some("file"), _) => 1,
+        (NodeType::Container, Some("module"), _) => 2,
+        (NodeType::Container, Some("namespace"), _) => 3,
+        (NodeType::Container, Some("package"), _) => 4,
+        (NodeType::Container, _, Some("interface" | "trait" | "protocol")) => 11,
+        (NodeType::Container, _, Some("struct")) => 23,
+        (NodeType::Container, _, Some("enum")) => 10,
+        (NodeType::Container, _, _) => 5,
+        (NodeType::Data, Some("field"), _) => 8,
+        (NodeType::Data, Some("property"), _) => 7,
+        (NodeType::Data, Some("constant"), _) => 14,
+        (NodeType::Data, _, _) => 13,
+    }
+}
+
+/// Convert an absolute path to a `file://` URI
+pub fn path_to_uri(path: &Path) -> String {
+    let mut uri = String::from("file://");
+    for byte in path.to_string_lossy().bytes() {
+        match byte {
+            b'A'..=b'Z' | b'a'..=b'z' | b'0'..=b'9' | b'/' | b'-' | b'_' | b'.' | b'~' => {
+                uri.push(byte as char)
+            }
+            _ => uri.push_str(&format!("%{:02X}", byte)),
+        }
+    }
+    uri
+}
+
+/// Length of a string in UTF-16 code units (the LSP default position encoding)
+pub fn utf16_len(text: &str) -> usize {
+    text.chars().map(char::len_utf16).sum()
+}
+
+/// Depth-first expansion state
+struct Builder<'a> {
+    graph: &'a PetCodeGraph,
+    direction: CallDirection,
+    sources: Sources,
+    options: &'a CallHierarchyOptions,
+    /// Node IDs from the root to the symbol being expanded
+    path: Vec<&'a str>,
+    calls: usize,
+    truncated: bool,
+}
+
+impl<'a> Builder<'a> {
+    /// Calls of `node`, which is `level - 1` levels below the root
+    fn expand(&mut self, node: &Node, level: usize) -> Vec<CallHierarchyCall> {
+        let mut calls = Vec::new();
+        for edge in call_edges(self.graph, &node.id, self.direction) {
+            if self.calls == self.options.max_calls {
+                self.truncated = true;
+                break;
+            }
+            self.calls += 1;
+
+            // Call sites are in the caller's file and name the callee
+            let (file, name) = match self.direction {
+                CallDirection::Incoming => (&edge.node.file, &node.name),
+                CallDirection::Outgoing => (&node.file, &edge.node.name),
+            };
+            let from_ranges = edge
+                .lines
+                .iter()
+                .map(|&line| self.sources.name_range(file, line, name))
+                .collect();
+            let item = self.sources.item(edge.node);
+            let recursive = self.path.contains(&edge.node.id.as_str());
+            let children = if recursive || level >= self.options.depth {
+                Vec::new()
+            } else {
+                self.path.push(edge.node.id.as_str());
+                let children = self.expand(edge.node, level + 1);
+                self.path.pop();
+                children
+            };
+
+            let (from, to) = match self.direction {
+                CallDirection::Incoming => (Some(item), None),
+                CallDirection::Outgoing => (None, Some(item)),
+            };
+            calls.push(CallHierarchyCall {
+                from,
+                to,
+                from_ranges,
+                recursive,
+                calls: children,
+            });
+        }
+        calls
+    }
+}
+
+/// Source lines read on demand, for URIs and name columns
+struct Sources {
+    root: Option<PathBuf>,
+    files: HashMap<String, Vec<String>>,
+}
+
+impl Sources {
+    fn new(root: Option<&Path>) -> Self {
+        Self {
+            root: root.map(Path::to_path_buf),
+            files: HashMap::new(),
+        }
+    }
+
+    fn item(&mut self, node: &Node) -> CallHierarchyItem {
+        let end_line = node.end_line.max(node.line).saturating_sub(1);
+        CallHierarchyItem {
+            name: node.name.clone(),
+            kind: symbol_kind(
+                node.node_type,
+                node.kind.as_deref(),
+                node.subtype.as_deref(),
+            ),
+            detail: node.id.clone(),
+            uri: self.uri(&node.file),
+            range: Range {
+                start: Position {
+                    line: node.line.saturating_sub(1),
+                    character: 0,
+                },
+                end: Position {
+                    line: end_line,
+                    character: 0,
+                },
+            },
+            selection_range: self.name_range(&node.file, node.line, &node.name),
+            data: ItemData {
+                id: node.id.clone(),
+            },
+        }
+    }
+
+    fn uri(&self, file: &str) -> String {
+        match &self.root {
+            Some(root) => path_to_uri(&root.join(file)),
+            None => file.to_string(),
+        }
+    }
+
+    /// Range of `name` on a line (1-indexed), or an empty range at the line
+    /// start if the name does not occur on it or sources are unavailable
+    fn name_range(&mut self, file: &str, line: usize, name: &str) -> Range {
+        let (start, end) = self
+            .line(file, line)
+            .and_then(|text| {
+                let offset = text.find(name)?;
+                let start = utf16_len(&text[..offset]);
+                Some((start, start + utf16_len(name)))
+            })
+            .unwrap_or((0, 0));
+        let line = line.saturating_sub(1);
+        Range {
+            start: Position {
+                line,
+                character: start,
+            },
+            end: Position {
+                line,
+                character: end,
+            },
+        }
+    }
+
+    fn line(&mut self, file: &str, line: usize) -> Option<&str> {
+        let root = self.root.as_ref()?;
+        let lines = self.files.entry(file.to_string()).or_insert_with(|| {
+            std::fs::read_to_string(root.join(file))
+                .map(|text| text.lines().map(str::to_string).collect())
+                .unwrap_or_default()
+        });
+        lines.get(line.checked_sub(1)?).map(String::as_str)
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::{CallableKind, EdgeData};
+
+    /// main -> serve -> handle -> serve (recursion), main -> handle
+    fn graph() -> PetCodeGraph {
+        let mut graph = PetCodeGraph::new();
+        for (name, line) in [("main", 1), ("serve", 10), ("handle", 20)] {
+            graph.add_node(Node::callable(
+                format!("app.go:{}", name),
+                name.to_string(),
+                CallableKind::Function,
+                "app.go".to_string(),
+                line,
+                line + 5,
+            ));
+        }
+        for (source, target, line) in [
+            ("main", "serve", 2),
+            ("main", "handle", 3),
+            ("serve", "handle", 11),
+            ("handle", "serve", 21),
+        ] {
+            graph.add_edge(
+                &format!("app.go:{}", source),
+                &format!("app.go:{}", target),
+                EdgeData::uses(Some(line), None),
+            );
+        }
+        graph
+    }
+
+    #[test]
+    fn test_incoming_hierarchy() {
+        let graph = graph();
+        let tree = call_hierarchy(
+            &graph,
+            "app.go:serve",
+            CallDirection::Incoming,
+            None,
+            &CallHierarchyOptions::default(),
+        )
+        .unwrap();
+        assert_eq!(tree.item.name, "serve");
+        assert_eq!(tree.item.uri, "app.go");
+        assert_eq!(tree.item.range.start.line, 9);
+
+        // handle <- main, handle <- serve (recursive); main
+        let names: Vec<&str> = tree.calls.iter().map(|c| c.item().name.as_str()).collect();
+        assert_eq!(names, vec!["handle", "main"]);
+        let handle = &tree.calls[0];
+        assert!(handle.to.is_none());
+        assert_eq!(handle.from_ranges[0].start.line, 20);
+        assert_eq!(handle.calls.len(), 2);
+        assert!(handle.calls[1].recursive);
+        assert!(handle.calls[1].calls.is_empty());
+        assert_eq!(tree.call_count(), 4);
+        assert!(!tree.truncated);
+
+        let json = serde_json::to_value(&tree).unwrap();
+        assert!(json["calls"][0]["from"]["selectionRange"].is_object());
+        assert!(json["calls"][0]["fromRanges"].is_array());
+    }
+
+    #[test]
+    fn test_outgoing_hierarchy_limits() {
+        let dir = tempfile::tempdir().unwrap();
+        std::fs::write(
+            dir.path().join("app.go"),
+            "func main() {\n\tserve()\n\thandle()\n",
+        )
+        .unwrap();
+        let graph = graph();
+        let options = CallHierarchyOptions {
+            depth: 1,
+            max_calls: 10,
+        };
+        let tree = call_hierarchy(
+            &graph,
+            "app.go:main",
+            CallDirection::Outgoing,
+            Some(dir.path()),
+            &options,
+        )
+        .unwrap();
+        assert!(tree.item.uri.starts_with("file://"));
+        assert_eq!(tree.item.selection_range.start.character, 5);
+        let serve = &tree.calls[1];
+        assert_eq!(serve.item().name, "serve");
+        assert_eq!(serve.from_ranges[0].start.character, 1);
+        assert_eq!(serve.from_ranges[0].end.character, 6);
+        assert!(serve.calls.is_empty());
+
+        let options = CallHierarchyOptions {
+            depth: 5,
+            max_calls: 1,
+        };
+        let tree = call_hierarchy(
+            &graph,
+            "app.go:main",
+            CallDirection::Outgoing,
+            None,
+            &options,
+        )
+        .unwrap();
+        assert_eq!(tree.call_count(), 1);
+        assert!(tree.truncated);
+
+        assert!(call_hierarchy(
+            &graph,
+            "app.go:missing",
+            CallDirection::Outgoing,
+            None,
+            &options
+        )
+        .is_none());
+    }
+}
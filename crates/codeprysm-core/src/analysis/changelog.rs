@@ -0,0 +1,359 @@
+//! Symbol-Level Changelog
+//!
+//! Turns the API diff between two versions (see [`diff_api`]) into a
+//! changelog grouped by package, to bootstrap release notes: exported
+//! symbols added, removed, and changed, each with its signature and doc
+//! summary. Breaking entries are flagged.
+//!
+//! Entries are sorted into three sections:
+//!
+//! - **Added**: newly exported symbols, including methods added to existing
+//!   interfaces (which are breaking)
+//! - **Removed**: symbols no longer exported
+//! - **Changed**: changed signatures or kinds, and newly deprecated symbols
+
+use std::collections::{BTreeMap, HashMap};
+use std::fmt::Write;
+
+use serde::{Deserialize, Serialize};
+
+use super::api_diff::{diff_api, ApiChange, ApiChangeKind, Severity};
+use super::api_surface::ApiSymbol;
+
+/// One changelog line.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct ChangelogEntry {
+    /// Owner-qualified symbol name (e.g. "Store.Get")
+    pub symbol: String,
+    /// Kind of symbol (e.g. "function", "type")
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub kind: Option<String>,
+    /// What changed
+    pub change: ApiChangeKind,
+    /// Whether the change breaks existing users
+    pub breaking: bool,
+    /// Signature in the new version (the old one for removed symbols)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub signature: Option<String>,
+    /// Signature in the old version, for changed signatures and kinds
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub old_signature: Option<String>,
+    /// First paragraph of the doc comment
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub doc: Option<String>,
+    /// File of the symbol (new version, or old if removed)
+    pub file: String,
+    /// Line of the symbol (new version, or old if removed)
+    pub line: usize,
+}
+
+/// Changes to one package.
+#[derive(Debug, Clone, Default, PartialEq, Eq, Serialize, Deserialize)]
+pub struct PackageChangelog {
+    /// Package path (containing directory)
+    pub package: String,
+    /// Newly exported symbols
+    pub added: Vec<ChangelogEntry>,
+    /// Symbols no longer exported
+    pub removed: Vec<ChangelogEntry>,
+    /// Changed or deprecated symbols
+    pub changed: Vec<ChangelogEntry>,
+}
+
+/// Changelog between two versions, grouped by package.
+#[derive(Debug, Clone, Default, PartialEq, Eq, Serialize, Deserialize)]
+pub struct Changelog {
+    /// Packages with changes, sorted by package
+    pub packages: Vec<PackageChangelog>,
+}
+
+impl Changelog {
+    /// Number of entries
+    pub fn len(&self) -> usize {
+        self.packages
+            .iter()
+            .map(|p| p.added.len() + p.removed.len() + p.changed.len())
+            .sum()
+    }
+
+    /// Check if nothing changed
+    pub fn is_empty(&self) -> bool {
+        self.packages.is_empty()
+    }
+
+    /// Number of breaking entries
+    pub fn breaking_count(&self) -> usize {
+        self.packages
+            .iter()
+            .flat_map(|p| p.added.iter().chain(&p.removed).chain(&p.changed))
+            .filter(|e| e.breaking)
+            .count()
+    }
+
+    /// Render as Markdown release notes under a `## title` heading
+    pub fn to_markdown(&self, title: &str) -> String {
+        let mut out = String::new();
+        let _ = writeln!(out, "## {}", title);
+        let _ = writeln!(out);
+        if self.is_empty() {
+            let _ = writeln!(out, "No API changes.");
+            return out;
+        }
+        let _ = writeln!(
+            out,
+            "{} API change(s) in {} package(s), {} breaking.",
+            self.len(),
+            self.packages.len(),
+            self.breaking_count()
+        );
+
+        for package in &self.packages {
+            let name = if package.package.is_empty() {
+                "."
+            } else {
+                package.package.as_str()
+            };
+            let _ = writeln!(out, "\n### `{}`", name);
+            for (label, entries) in [
+                ("Added", &package.added),
+                ("Removed", &package.removed),
+                ("Changed", &package.changed),
+            ] {
+                if entries.is_empty() {
+                    continue;
+                }
+                let _ = writeln!(out, "\n#### {}\n", label);
+                for entry in entries {
+                    let _ = writeln!(out, "{}", markdown_entry(entry));
+                }
+            }
+        }
+        out
+    }
+}
+
+/// Build the changelog between two API surfaces.
+pub fn changelog(old: &[ApiSymbol], new: &[ApiSymbol]) -> Changelog {
+    let old_symbols = by_signature(old);
+    let new_symbols = by_signature(new);
+
+    let mut packages: BTreeMap<String, PackageChangelog> = BTreeMap::new();
+    for change in diff_api(old, new).changes {
+        let key = |signature: &Option<String>| {
+            (
+                change.package.clone(),
+                change.symbol.clone(),
+                signature.clone(),
+            )
+        };
+        let before = old_symbols.get(&key(&change.old_signature)).copied();
+        let after = new_symbols.get(&key(&change.new_signature)).copied();
+        let package = packages
+            .entry(change.package.clone())
+            .or_insert_with(|| PackageChangelog {
+                package: change.package.clone(),
+                ..Default::default()
+            });
+        let section = match change.kind {
+            ApiChangeKind::Added | ApiChangeKind::InterfaceMethodAdded => &mut package.added,
+            ApiChangeKind::Removed => &mut package.removed,
+            ApiChangeKind::SignatureChanged
+            | ApiChangeKind::KindChanged
+            | ApiChangeKind::Deprecated => &mut package.changed,
+        };
+        section.push(entry(change, before, after));
+    }
+
+    let mut packages: Vec<PackageChangelog> = packages.into_values().collect();
+    for package in &mut packages {
+        for section in [
+            &mut package.added,
+            &mut package.removed,
+            &mut package.changed,
+        ] {
+            section.sort_by(|a, b| (&a.symbol, a.change).cmp(&(&b.symbol, b.change)));
+        }
+    }
+    Changelog { packages }
+}
+
+/// Match key: package, owner-qualified name, and signature
+type SignatureKey = (String, String, Option<String>);
+
+/// Index symbols by package, qualified name, and signature
+fn by_signature(symbols: &[ApiSymbol]) -> HashMap<SignatureKey, &ApiSymbol> {
+    symbols
+        .iter()
+        .map(|s| {
+            (
+                (s.package.clone(), s.qualified_name(), s.signature.clone()),
+                s,
+            )
+        })
+        .collect()
+}
+
+/// Changelog entry for a change, described by its newest side
+fn entry(change: ApiChange, old: Option<&ApiSymbol>, new: Option<&ApiSymbol>) -> ChangelogEntry {
+    let current = new.or(old);
+    let signature_changed = matches!(
+        change.kind,
+        ApiChangeKind::SignatureChanged | ApiChangeKind::KindChanged
+    );
+    ChangelogEntry {
+        kind: current.and_then(|s| s.kind.clone()),
+        doc: current.and_then(|s| s.doc.clone()),
+        breaking: change.severity == Severity::Breaking,
+        signature: change
+            .new_signature
+            .clone()
+            .or_else(|| change.old_signature.clone()),
+        old_signature: if signature_changed {
+            change.old_signature
+        } else {
+            None
+        },
+        symbol: change.symbol,
+        change: change.kind,
+        file: change.file,
+        line: change.line,
+    }
+}
+
+/// One Markdown list item
+fn markdown_entry(entry: &ChangelogEntry) -> String {
+    let mut line = String::from("- ");
+    if entry.breaking {
+        line.push_str("**Breaking:** ");
+    }
+    let _ = write!(line, "`{}`", entry.symbol);
+    match entry.change {
+        ApiChangeKind::Deprecated => line.push_str(" is deprecated"),
+        ApiChangeKind::InterfaceMethodAdded => line.push_str(" added to the interface"),
+        ApiChangeKind::KindChanged => line.push_str(" changed kind"),
+        _ => {}
+    }
+    match (&entry.old_signature, &entry.signature) {
+        (Some(old), Some(new)) => {
+            let _ = write!(line, ": `{}` (was `{}`)", new, old);
+        }
+        (None, Some(signature)) => {
+            let _ = write!(line, ": `{}`", signature);
+        }
+        _ => {}
+    }
+    if let Some(doc) = &entry.doc {
+        let _ = write!(line, "\n  {}", doc);
+    }
+    line
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::analysis::api_surface::Stability;
+    use crate::graph::NodeType;
+
+    fn symbol(package: &str, name: &str, signature: &str, doc: Option<&str>) -> ApiSymbol {
+        ApiSymbol {
+            id: format!("{}/x.go:{}", package, name),
+            name: name.to_string(),
+            node_type: NodeType::Callable,
+            kind: Some("function".to_string()),
+            subtype: None,
+            package: package.to_string(),
+            file: format!("{}/x.go", package),
+            line: 1,
+            owner: None,
+            signature: Some(signature.to_string()),
+            doc: doc.map(str::to_string),
+            stability: Stability::Stable,
+        }
+    }
+
+    #[test]
+    fn test_changelog() {
+        let old = vec![
+            symbol("client", "Dial", "func Dial(addr string) *Conn", None),
+            symbol(
+                "client",
+                "Ping",
+                "func Ping()",
+                Some("Ping checks the server."),
+            ),
+            symbol("store", "Open", "func Open() *Store", None),
+        ];
+        let mut open = symbol("store", "Open", "func Open() *Store", None);
+        open.stability = Stability::Deprecated;
+        let new = vec![
+            symbol(
+                "client",
+                "Dial",
+                "func Dial(ctx context.Context, addr string) *Conn",
+                Some("Dial connects to addr."),
+            ),
+            symbol(
+                "client",
+                "Retry",
+                "func Retry(n int)",
+                Some("Retry sets the retry count."),
+            ),
+            open,
+        ];
+
+        let log = changelog(&old, &new);
+        assert_eq!(log.len(), 4);
+        assert_eq!(log.breaking_count(), 2);
+
+        let client = &log.packages[0];
+        assert_eq!(client.package, "client");
+        assert_eq!(client.added[0].symbol, "Retry");
+        assert_eq!(
+            client.added[0].doc.as_deref(),
+            Some("Retry sets the retry count.")
+        );
+        // Removed symbols keep their old signature and doc
+        assert_eq!(client.removed[0].signature.as_deref(), Some("func Ping()"));
+        assert_eq!(
+            client.removed[0].doc.as_deref(),
+            Some("Ping checks the server.")
+        );
+        let dial = &client.changed[0];
+        assert_eq!(dial.change, ApiChangeKind::SignatureChanged);
+        assert_eq!(
+            dial.old_signature.as_deref(),
+            Some("func Dial(addr string) *Conn")
+        );
+
+        let store = &log.packages[1];
+        assert_eq!(store.changed[0].change, ApiChangeKind::Deprecated);
+        assert!(!store.changed[0].breaking);
+        assert!(store.changed[0].old_signature.is_none());
+    }
+
+    #[test]
+    fn test_to_markdown() {
+        let old = vec![symbol("client", "Ping", "func Ping()", None)];
+        let new = vec![symbol(
+            "client",
+            "Retry",
+            "func Retry(n int)",
+            Some("Retry sets the retry count."),
+        )];
+        let markdown = changelog(&old, &new).to_markdown("v1.3.0");
+        assert_eq!(
+            markdown,
+            "## v1.3.0\n\n\
+             2 API change(s) in 1 package(s), 1 breaking.\n\n\
+             ### `client`\n\n\
+             #### Added\n\n\
+             - `Retry`: `func Retry(n int)`\n  Retry sets the retry count.\n\n\
+             #### Removed\n\n\
+             - **Breaking:** `Ping`: `func Ping()`\n"
+        );
+        assert_eq!(
+            Changelog::default().to_markdown("v1"),
+            "## v1\n\nNo API changes.\n"
+        );
+    }
+}
@@ -0,0 +1,270 @@
+//! Clone Detection
+//!
+//! Finds near-duplicate callables by comparing the MinHash clone signatures
+//! recorded at indexing time (see the `fingerprint` module). Candidate pairs
+//! come from locality-sensitive hashing: signatures are split into bands and
+//! only callables sharing an identical band are compared, so the analysis
+//! stays close to linear in the number of callables.
+//!
+//! Detected pairs are stored in the graph as `CLONE_OF` edges carrying their
+//! similarity, see [`link_clones`].
+
+use std::collections::{BTreeSet, HashMap};
+
+use serde::{Deserialize, Serialize};
+
+use super::package_of;
+use crate::fingerprint::{similarity, SIGNATURE_SIZE};
+use crate::graph::{EdgeData, EdgeType, Node, NodeType, PetCodeGraph};
+
+/// SARIF rule ID for clone findings
+pub const CLONE_RULE: &str = "duplicate-code";
+
+/// Signature values per LSH band.
+///
+/// With 32-value signatures this gives 8 bands, which finds pairs at 80%
+/// similarity with ~98% probability and at 60% with ~50%.
+const BAND_SIZE: usize = 4;
+
+/// Options for clone detection.
+#[derive(Debug, Clone, Copy)]
+pub struct CloneOptions {
+    /// Minimum similarity percentage (0-100) for a pair to be reported
+    pub min_similarity: u32,
+    /// Minimum body size in normalized tokens; smaller callables are ignored
+    pub min_tokens: u32,
+}
+
+impl Default for CloneOptions {
+    fn default() -> Self {
+        Self {
+            min_similarity: 80,
+            min_tokens: 50,
+        }
+    }
+}
+
+/// One side of a clone pair.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct CloneInstance {
+    /// Node ID
+    pub id: String,
+    /// Entity name
+    pub name: String,
+    /// Package (containing directory)
+    pub package: String,
+    /// File path
+    pub file: String,
+    /// Start line
+    pub line: usize,
+    /// End line
+    pub end_line: usize,
+    /// Body size in normalized tokens
+    pub tokens: u32,
+}
+
+/// Two callables with near-duplicate bodies.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct ClonePair {
+    /// Callable with the lower node ID
+    pub first: CloneInstance,
+    /// Callable with the higher node ID
+    pub second: CloneInstance,
+    /// Estimated similarity percentage (0-100)
+    pub similarity: u32,
+}
+
+impl ClonePair {
+    /// Check whether the two callables live in different packages
+    pub fn is_cross_package(&self) -> bool {
+        self.first.package != self.second.package
+    }
+}
+
+/// Find near-duplicate callables in a graph.
+///
+/// Pairs are sorted by descending similarity, then by node IDs. A callable
+/// nested inside the other (e.g. a closure) is never reported as its clone.
+pub fn find_clones(graph: &PetCodeGraph, options: &CloneOptions) -> Vec<ClonePair> {
+    let candidates: Vec<(&Node, &[u32])> = graph
+        .iter_nodes()
+        .filter(|n| n.node_type == NodeType::Callable)
+        .filter(|n| n.metadata.token_count.unwrap_or(0) >= options.min_tokens)
+        .filter_map(|n| {
+            let signature = n.metadata.clone_signature.as_deref()?;
+            (signature.len() == SIGNATURE_SIZE).then_some((n, signature))
+        })
+        .collect();
+
+    let mut buckets: HashMap<(usize, &[u32]), Vec<usize>> = HashMap::new();
+    for (index, (_, signature)) in candidates.iter().enumerate() {
+        for (band, rows) in signature.chunks(BAND_SIZE).enumerate() {
+            buckets.entry((band, rows)).or_default().push(index);
+        }
+    }
+
+    let mut compared: BTreeSet<(usize, usize)> = BTreeSet::new();
+    let mut pairs = Vec::new();
+    for members in buckets.values().filter(|m| m.len() > 1) {
+        for (i, &a) in members.iter().enumerate() {
+            for &b in &members[i + 1..] {
+                if !compared.insert((a.min(b), a.max(b))) {
+                    continue;
+                }
+
+                let (node_a, sig_a) = candidates[a];
+                let (node_b, sig_b) = candidates[b];
+                let score = similarity(sig_a, sig_b);
+                if score < options.min_similarity || is_nested(node_a, node_b) {
+                    continue;
+                }
+
+                let (first, second) = if node_a.id < node_b.id {
+                    (node_a, node_b)
+                } else {
+                    (node_b, node_a)
+                };
+                pairs.push(ClonePair {
+                    first: instance(first),
+                    second: instance(second),
+                    similarity: score,
+                });
+            }
+        }
+    }
+
+    pairs.sort_by(|a, b| {
+        b.similarity
+            .cmp(&a.similarity)
+            .then_with(|| a.first.id.cmp(&b.first.id))
+            .then_with(|| a.second.id.cmp(&b.second.id))
+    });
+    pairs
+}
+
+/// Replace the graph's `CLONE_OF` edges with freshly detected clones.
+///
+/// Each pair gets one edge from the lower to the higher node ID. Returns the
+/// number of edges added.
+pub fn link_clones(graph: &mut PetCodeGraph, options: &CloneOptions) -> usize {
+    graph.remove_edges_by_type(EdgeType::CloneOf);
+
+    let pairs = find_clones(graph, options);
+    for pair in &pairs {
+        graph.add_edge(
+            &pair.first.id,
+            &pair.second.id,
+            EdgeData::clone_of(pair.similarity),
+        );
+    }
+    pairs.len()
+}
+
+/// Check whether one callable's span lies within the other's
+fn is_nested(a: &Node, b: &Node) -> bool {
+    a.file == b.file
+        && ((a.line <= b.line && b.end_line <= a.end_line)
+            || (b.line <= a.line && a.end_line <= b.end_line))
+}
+
+fn instance(node: &Node) -> CloneInstance {
+    CloneInstance {
+        id: node.id.clone(),
+        name: node.name.clone(),
+        package: package_of(&node.file).to_string(),
+        file: node.file.clone(),
+        line: node.line,
+        end_line: node.end_line,
+        tokens: node.metadata.token_count.unwrap_or(0),
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::test_support::function;
+
+    fn add_fn(
+        graph: &mut PetCodeGraph,
+        id: &str,
+        line: usize,
+        end_line: usize,
+        signature: Vec<u32>,
+    ) {
+        let mut node = function(id);
+        node.line = line;
+        node.end_line = end_line;
+        node.metadata.token_count = Some(120);
+        node.metadata.clone_signature = Some(signature);
+        graph.add_node(node);
+    }
+
+    /// Signature sharing its first `shared` values with `base`
+    fn variant(base: &[u32], shared: usize) -> Vec<u32> {
+        base.iter()
+            .enumerate()
+            .map(|(i, &v)| if i < shared { v } else { v + 1000 })
+            .collect()
+    }
+
+    fn sample_graph() -> PetCodeGraph {
+        let base: Vec<u32> = (0..SIGNATURE_SIZE as u32).collect();
+        let mut graph = PetCodeGraph::new();
+        add_fn(&mut graph, "api/users.go:parse", 10, 40, base.clone());
+        add_fn(
+            &mut graph,
+            "store/orders.go:decode",
+            5,
+            35,
+            variant(&base, 28),
+        );
+        add_fn(
+            &mut graph,
+            "store/orders.go:unrelated",
+            50,
+            80,
+            variant(&base, 8),
+        );
+        // Closure inside parse with the same body shape
+        add_fn(&mut graph, "api/users.go:parse:func1", 12, 38, base.clone());
+        graph
+    }
+
+    #[test]
+    fn test_find_clones() {
+        let pairs = find_clones(&sample_graph(), &CloneOptions::default());
+
+        // The closure matches decode too, but is never a clone of its parent
+        assert_eq!(pairs.len(), 2);
+        assert_eq!(pairs[0].first.id, "api/users.go:parse");
+        assert_eq!(pairs[0].second.id, "store/orders.go:decode");
+        assert_eq!(pairs[0].similarity, 88);
+        assert!(pairs[0].is_cross_package());
+        assert_eq!(pairs[1].first.id, "api/users.go:parse:func1");
+
+        let strict = CloneOptions {
+            min_similarity: 90,
+            ..CloneOptions::default()
+        };
+        assert!(find_clones(&sample_graph(), &strict).is_empty());
+
+        let large_only = CloneOptions {
+            min_tokens: 500,
+            ..CloneOptions::default()
+        };
+        assert!(find_clones(&sample_graph(), &large_only).is_empty());
+    }
+
+    #[test]
+    fn test_link_clones_replaces_edges() {
+        let mut graph = sample_graph();
+        assert_eq!(link_clones(&mut graph, &CloneOptions::default()), 2);
+        assert_eq!(link_clones(&mut graph, &CloneOptions::default()), 2);
+
+        let edges: Vec<_> = graph.edges_by_type(EdgeType::CloneOf).collect();
+        assert_eq!(edges.len(), 2);
+        assert!(edges
+            .iter()
+            .all(|(source, target, data)| source.id < target.id && data.similarity == Some(88)));
+    }
+}
@@ -0,0 +1,220 @@
+//! Package Coupling Metrics
+//!
+//! Robert C. Martin's package metrics derived from cross-package USES edges:
+//!
+//! - **Afferent coupling (Ca)**: number of other packages that depend on this one
+//! - **Efferent coupling (Ce)**: number of other packages this one depends on
+//! - **Instability (I)**: `Ce / (Ca + Ce)`, from 0 (stable) to 1 (unstable)
+//! - **Abstractness (A)**: share of the package's types that are interfaces,
+//!   traits, or abstract classes
+//! - **Distance (D)**: `|A + I - 1|`, how far the package is from the "main
+//!   sequence" balancing stability and abstraction
+//!
+//! Packages are directories (see [`package_of`]).
+
+use std::collections::{BTreeMap, BTreeSet};
+
+use serde::{Deserialize, Serialize};
+
+use super::api_surface::is_type;
+use super::package_of;
+use crate::graph::{EdgeType, Node, NodeType, PetCodeGraph};
+
+/// Type subtypes that count as abstract
+const ABSTRACT_SUBTYPES: &[&str] = &["interface", "trait"];
+
+/// Coupling metrics for one package.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct PackageMetrics {
+    /// Package path (containing directory)
+    pub package: String,
+    /// Afferent coupling: packages depending on this one
+    pub afferent: usize,
+    /// Efferent coupling: packages this one depends on
+    pub efferent: usize,
+    /// Instability: Ce / (Ca + Ce), 0 when the package is isolated
+    pub instability: f64,
+    /// Abstractness: abstract types / all types, 0 when there are no types
+    pub abstractness: f64,
+    /// Distance from the main sequence: |A + I - 1|
+    pub distance: f64,
+    /// Number of types declared in the package
+    pub type_count: usize,
+    /// Number of abstract types declared in the package
+    pub abstract_count: usize,
+    /// Packages this one depends on
+    pub dependencies: Vec<String>,
+}
+
+/// Compute coupling metrics for every package with code, sorted by package.
+pub fn package_metrics(graph: &PetCodeGraph) -> Vec<PackageMetrics> {
+    let mut packages: BTreeMap<&str, (usize, usize)> = BTreeMap::new();
+    for node in graph.iter_nodes().filter(|n| is_code(n)) {
+        let counts = packages.entry(package_of(&node.file)).or_default();
+        if is_type(node) {
+            counts.0 += 1;
+            if is_abstract(node) {
+                counts.1 += 1;
+            }
+        }
+    }
+
+    let mut dependencies: BTreeMap<&str, BTreeSet<&str>> = BTreeMap::new();
+    let mut dependents: BTreeMap<&str, BTreeSet<&str>> = BTreeMap::new();
+    for (source, target, _) in graph.edges_by_type(EdgeType::Uses) {
+        if !is_code(source) || !is_code(target) {
+            continue;
+        }
+
+        let from = package_of(&source.file);
+        let to = package_of(&target.file);
+        if from != to {
+            dependencies.entry(from).or_default().insert(to);
+            dependents.entry(to).or_default().insert(from);
+        }
+    }
+
+    packages
+        .into_iter()
+        .map(|(package, (type_count, abstract_count))| {
+            let efferent = dependencies.get(package).map_or(0, BTreeSet::len);
+            let afferent = dependents.get(package).map_or(0, BTreeSet::len);
+            let instability = ratio(efferent, afferent + efferent);
+            let abstractness = ratio(abstract_count, type_count);
+
+            PackageMetrics {
+                package: package.to_string(),
+                afferent,
+                efferent,
+                instability,
+                abstractness,
+                distance: (abstractness + instability - 1.0).abs(),
+                type_count,
+                abstract_count,
+                dependencies: dependencies
+                    .get(package)
+                    .map(|deps| deps.iter().map(|d| d.to_string()).collect())
+                    .unwrap_or_default(),
+            }
+        })
+        .collect()
+}
+
+/// Divide, treating an empty denominator as 0
+fn ratio(numerator: usize, denominator: usize) -> f64 {
+    if denominator == 0 {
+        0.0
+    } else {
+        numerator as f64 / denominator as f64
+    }
+}
+
+/// Source-level symbols (excludes workspace/repository/component/file containers)
+fn is_code(node: &Node) -> bool {
+    !node.file.is_empty()
+        && match node.node_type {
+            NodeType::Callable | NodeType::Data => true,
+            NodeType::Container => !matches!(
+                node.kind.as_deref(),
+                Some("workspace" | "repository" | "component" | "file")
+            ),
+        }
+}
+
+/// Check if a type node is abstract (interface, trait, or abstract class)
+fn is_abstract(node: &Node) -> bool {
+    node.subtype
+        .as_deref()
+        .is_some_and(|s| ABSTRACT_SUBTYPES.contains(&s))
+        || node.metadata.is_abstract == Some(true)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::{ContainerKind, EdgeData};
+    use crate::test_support::function;
+
+    fn add_type(graph: &mut PetCodeGraph, id: &str, subtype: &str, file: &str) {
+        graph.add_node(Node::container(
+            id.to_string(),
+            id.rsplit(':').next().unwrap().to_string(),
+            ContainerKind::Type,
+            Some(subtype.to_string()),
+            file.to_string(),
+            1,
+            5,
+        ));
+    }
+
+    fn add_fn(graph: &mut PetCodeGraph, id: &str, file: &str) {
+        let mut node = function(id);
+        node.file = file.to_string();
+        graph.add_node(node);
+    }
+
+    /// api -> domain <- store; domain declares one interface and one struct
+    fn sample_graph() -> PetCodeGraph {
+        let mut graph = PetCodeGraph::new();
+        add_type(
+            &mut graph,
+            "domain/repo.go:Repo",
+            "interface",
+            "domain/repo.go",
+        );
+        add_type(
+            &mut graph,
+            "domain/user.go:User",
+            "struct",
+            "domain/user.go",
+        );
+        add_fn(&mut graph, "api/h.go:handle", "api/h.go");
+        add_fn(&mut graph, "api/h.go:helper", "api/h.go");
+        add_fn(&mut graph, "store/s.go:save", "store/s.go");
+
+        graph.add_edge(
+            "api/h.go:handle",
+            "domain/repo.go:Repo",
+            EdgeData::uses(Some(2), None),
+        );
+        graph.add_edge(
+            "api/h.go:handle",
+            "domain/user.go:User",
+            EdgeData::uses(Some(3), None),
+        );
+        // Intra-package references do not count
+        graph.add_edge(
+            "api/h.go:handle",
+            "api/h.go:helper",
+            EdgeData::uses(Some(4), None),
+        );
+        graph.add_edge(
+            "store/s.go:save",
+            "domain/user.go:User",
+            EdgeData::uses(Some(2), None),
+        );
+        graph
+    }
+
+    #[test]
+    fn test_package_metrics() {
+        let metrics = package_metrics(&sample_graph());
+        let names: Vec<&str> = metrics.iter().map(|m| m.package.as_str()).collect();
+        assert_eq!(names, vec!["api", "domain", "store"]);
+
+        let api = &metrics[0];
+        assert_eq!((api.afferent, api.efferent), (0, 1));
+        assert_eq!(api.instability, 1.0);
+        assert_eq!(api.abstractness, 0.0);
+        assert_eq!(api.distance, 0.0);
+        assert_eq!(api.dependencies, vec!["domain"]);
+
+        let domain = &metrics[1];
+        assert_eq!((domain.afferent, domain.efferent), (2, 0));
+        assert_eq!(domain.instability, 0.0);
+        assert_eq!((domain.type_count, domain.abstract_count), (2, 1));
+        assert_eq!(domain.abstractness, 0.5);
+        assert_eq!(domain.distance, 0.5);
+        assert!(domain.dependencies.is_empty());
+    }
+}
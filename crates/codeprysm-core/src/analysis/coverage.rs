@@ -0,0 +1,165 @@
+//! Coverage Gaps
+//!
+//! Combines the test coverage annotations of callables (see
+//! [`crate::coverage`]) with their fan-in, so queries like "exported functions
+//! with zero coverage and fan-in above 10" find untested code that many
+//! callers depend on.
+
+use std::collections::{BTreeSet, HashMap};
+
+use serde::{Deserialize, Serialize};
+
+use super::api_surface::is_exported;
+use super::dead_code::is_test_code;
+use super::package_of;
+use crate::graph::{EdgeType, NodeType, PetCodeGraph};
+
+/// Filters for a coverage report.
+#[derive(Debug, Clone, Default)]
+pub struct CoverageFilter {
+    /// Only include exported callables
+    pub exported_only: bool,
+    /// Only include callables at or below this coverage percentage
+    pub max_percent: Option<f64>,
+    /// Only include callables with at least this many distinct callers
+    pub min_fan_in: usize,
+}
+
+/// Test coverage of one callable.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct FunctionCoverage {
+    /// Node ID
+    pub id: String,
+    /// Callable name
+    pub name: String,
+    /// Callable kind (function, method, ...)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub kind: Option<String>,
+    /// Package (containing directory)
+    pub package: String,
+    /// File path relative to the repository root
+    pub file: String,
+    /// Start line (1-indexed)
+    pub line: usize,
+    /// Statements executed by tests
+    pub covered: u32,
+    /// Statements in the callable
+    pub total: u32,
+    /// Covered percentage (0-100)
+    pub percent: f64,
+    /// Distinct symbols using this callable
+    pub fan_in: usize,
+    /// Whether the callable is part of the exported API
+    pub exported: bool,
+}
+
+/// Report coverage of annotated, non-test callables matching `filter`.
+///
+/// Sorted by fan-in (highest first), then by coverage (lowest first), so the
+/// riskiest gaps come first. Callables without coverage annotations are
+/// omitted.
+pub fn coverage_report(graph: &PetCodeGraph, filter: &CoverageFilter) -> Vec<FunctionCoverage> {
+    let mut callers: HashMap<&str, BTreeSet<&str>> = HashMap::new();
+    for (source, target, _) in graph.edges_by_type(EdgeType::Uses) {
+        if source.id != target.id {
+            callers
+                .entry(target.id.as_str())
+                .or_default()
+                .insert(source.id.as_str());
+        }
+    }
+
+    let mut report: Vec<FunctionCoverage> = graph
+        .iter_nodes()
+        .filter(|n| n.node_type == NodeType::Callable && !is_test_code(n))
+        .filter_map(|node| {
+            let total = node.metadata.total_statements?;
+            let covered = node.metadata.covered_statements.unwrap_or(0);
+            let percent = if total == 0 {
+                100.0
+            } else {
+                f64::from(covered) * 100.0 / f64::from(total)
+            };
+            let fan_in = callers.get(node.id.as_str()).map_or(0, BTreeSet::len);
+            let exported = is_exported(graph, node);
+
+            if (filter.exported_only && !exported)
+                || filter.max_percent.is_some_and(|max| percent > max)
+                || fan_in < filter.min_fan_in
+            {
+                return None;
+            }
+
+            Some(FunctionCoverage {
+                id: node.id.clone(),
+                name: node.name.clone(),
+                kind: node.kind.clone(),
+                package: package_of(&node.file).to_string(),
+                file: node.file.clone(),
+                line: node.line,
+                covered,
+                total,
+                percent,
+                fan_in,
+                exported,
+            })
+        })
+        .collect();
+
+    report.sort_by(|a, b| {
+        b.fan_in
+            .cmp(&a.fan_in)
+            .then_with(|| a.percent.total_cmp(&b.percent))
+            .then_with(|| a.id.cmp(&b.id))
+    });
+    report
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::EdgeData;
+    use crate::test_support::function;
+
+    fn add_fn(graph: &mut PetCodeGraph, id: &str, coverage: Option<(u32, u32)>) {
+        let mut node = function(id);
+        let public = node.name.starts_with(|c: char| c.is_uppercase());
+        node.metadata.visibility = Some(if public { "public" } else { "private" }.to_string());
+        if let Some((covered, total)) = coverage {
+            node.metadata.covered_statements = Some(covered);
+            node.metadata.total_statements = Some(total);
+        }
+        graph.add_node(node);
+    }
+
+    #[test]
+    fn test_untested_exported_hubs() {
+        let mut graph = PetCodeGraph::new();
+        add_fn(&mut graph, "store/db.go:Query", Some((0, 6)));
+        add_fn(&mut graph, "store/db.go:Close", Some((2, 2)));
+        add_fn(&mut graph, "store/db.go:scan", Some((0, 3)));
+        add_fn(&mut graph, "store/db.go:Unprofiled", None);
+        for caller in ["api/a.go:list", "api/b.go:show"] {
+            add_fn(&mut graph, caller, Some((1, 4)));
+            for target in ["store/db.go:Query", "store/db.go:scan", "store/db.go:Close"] {
+                graph.add_edge(caller, target, EdgeData::uses(Some(2), None));
+            }
+        }
+
+        let all = coverage_report(&graph, &CoverageFilter::default());
+        assert_eq!(all.len(), 5);
+        assert_eq!(all[0].id, "store/db.go:Query");
+        assert_eq!(all[0].fan_in, 2);
+
+        let filter = CoverageFilter {
+            exported_only: true,
+            max_percent: Some(0.0),
+            min_fan_in: 2,
+        };
+        let gaps: Vec<String> = coverage_report(&graph, &filter)
+            .into_iter()
+            .map(|f| f.id)
+            .collect();
+        assert_eq!(gaps, vec!["store/db.go:Query"]);
+    }
+}
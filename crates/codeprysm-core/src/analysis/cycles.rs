@@ -0,0 +1,371 @@
+//! Dependency Cycles
+//!
+//! Finds cycles in the dependencies between packages (directories) or between
+//! types, derived from USES edges. Each cycle is a strongly connected component
+//! of the dependency graph, reported with a small set of dependencies whose
+//! removal makes it acyclic.
+//!
+//! Finding the smallest such set is NP-hard, so the set is computed with the
+//! Eades-Lin-Smyth ordering heuristic (weighted by reference count) and then
+//! pruned until every remaining dependency is needed: restoring any one of
+//! them re-creates a cycle.
+//!
+//! References from test code are ignored, since Go external test packages
+//! may import packages that depend on the package under test.
+
+use std::collections::{BTreeMap, HashMap, HashSet};
+
+use petgraph::algo::tarjan_scc;
+use petgraph::graph::DiGraph;
+use serde::{Deserialize, Serialize};
+
+use super::dead_code::is_test_code;
+use super::package_of;
+use crate::graph::{ContainerKind, EdgeType, Node, NodeType, PetCodeGraph};
+
+/// SARIF rule ID for dependency cycle findings
+pub const CYCLE_RULE: &str = "dependency-cycle";
+
+/// Granularity of the dependency graph.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum CycleLevel {
+    /// Dependencies between packages (directories)
+    Package,
+    /// References between types, including through their methods and fields
+    Type,
+}
+
+/// A dependency from one package or type to another.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct Dependency {
+    /// Depending package or type ID
+    pub from: String,
+    /// Package or type ID depended on
+    pub to: String,
+    /// Number of references making up the dependency
+    pub references: usize,
+    /// File of a sample reference
+    pub file: String,
+    /// Line of a sample reference (1-indexed, 0 if unknown)
+    pub line: usize,
+}
+
+/// A strongly connected set of packages or types.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct DependencyCycle {
+    /// Granularity of the members
+    pub level: CycleLevel,
+    /// Packages or type IDs in the cycle, sorted
+    pub members: Vec<String>,
+    /// Dependencies among the members
+    pub dependencies: Vec<Dependency>,
+    /// Dependencies to remove to break every cycle among the members
+    pub break_edges: Vec<Dependency>,
+}
+
+/// Find dependency cycles at the given level.
+///
+/// Cycles are sorted by size (largest first), then by first member.
+pub fn find_cycles(graph: &PetCodeGraph, level: CycleLevel) -> Vec<DependencyCycle> {
+    let dependencies = collect_dependencies(graph, level);
+
+    let mut dep_graph: DiGraph<&str, ()> = DiGraph::new();
+    let mut indices = HashMap::new();
+    for dependency in &dependencies {
+        for key in [dependency.from.as_str(), dependency.to.as_str()] {
+            indices
+                .entry(key)
+                .or_insert_with(|| dep_graph.add_node(key));
+        }
+    }
+    for dependency in &dependencies {
+        dep_graph.add_edge(
+            indices[dependency.from.as_str()],
+            indices[dependency.to.as_str()],
+            (),
+        );
+    }
+
+    let mut cycles: Vec<DependencyCycle> = tarjan_scc(&dep_graph)
+        .into_iter()
+        .filter(|component| component.len() > 1)
+        .map(|component| {
+            let mut members: Vec<String> = component
+                .iter()
+                .map(|&index| dep_graph[index].to_string())
+                .collect();
+            members.sort();
+
+            let in_cycle: HashSet<&str> = members.iter().map(String::as_str).collect();
+            let edges: Vec<Dependency> = dependencies
+                .iter()
+                .filter(|d| in_cycle.contains(d.from.as_str()) && in_cycle.contains(d.to.as_str()))
+                .cloned()
+                .collect();
+            let break_edges = feedback_edges(&members, &edges);
+
+            DependencyCycle {
+                level,
+                members,
+                dependencies: edges,
+                break_edges,
+            }
+        })
+        .collect();
+
+    cycles.sort_by(|a, b| {
+        b.members
+            .len()
+            .cmp(&a.members.len())
+            .then_with(|| a.members.cmp(&b.members))
+    });
+    cycles
+}
+
+/// Aggregate USES edges into dependencies between packages or types, sorted
+/// by (from, to).
+///
+/// References from test code are skipped, as test packages routinely import
+/// each other's subjects.
+pub fn collect_dependencies(graph: &PetCodeGraph, level: CycleLevel) -> Vec<Dependency> {
+    let mut owners: HashMap<&str, Option<&str>> = HashMap::new();
+    let mut dependencies: BTreeMap<(&str, &str), Dependency> = BTreeMap::new();
+
+    for (source, target, edge) in graph.edges_by_type(EdgeType::Uses) {
+        if source.file.is_empty() || target.file.is_empty() || is_test_code(source) {
+            continue;
+        }
+
+        let (from, to) = match level {
+            CycleLevel::Package => (package_of(&source.file), package_of(&target.file)),
+            CycleLevel::Type => {
+                match (
+                    owning_type(graph, source, &mut owners),
+                    owning_type(graph, target, &mut owners),
+                ) {
+                    (Some(from), Some(to)) => (from, to),
+                    _ => continue,
+                }
+            }
+        };
+        if from == to {
+            continue;
+        }
+
+        dependencies
+            .entry((from, to))
+            .or_insert_with(|| Dependency {
+                from: from.to_string(),
+                to: to.to_string(),
+                references: 0,
+                file: source.file.clone(),
+                line: edge.ref_line.unwrap_or(0),
+            })
+            .references += 1;
+    }
+
+    dependencies.into_values().collect()
+}
+
+/// The type a node is or belongs to (memoized)
+fn owning_type<'a>(
+    graph: &'a PetCodeGraph,
+    node: &'a Node,
+    owners: &mut HashMap<&'a str, Option<&'a str>>,
+) -> Option<&'a str> {
+    if let Some(owner) = owners.get(node.id.as_str()) {
+        return *owner;
+    }
+
+    let owner = if node.node_type == NodeType::Container
+        && node.kind.as_deref() == Some(ContainerKind::Type.as_str())
+    {
+        Some(node.id.as_str())
+    } else if node.node_type == NodeType::Container {
+        // Files, packages, and other containers do not belong to a type
+        None
+    } else {
+        graph
+            .parent(&node.id)
+            .and_then(|parent| owning_type(graph, parent, owners))
+    };
+
+    owners.insert(node.id.as_str(), owner);
+    owner
+}
+
+/// Select dependencies to remove so that `edges` among `members` is acyclic.
+///
+/// Orders the members with the Eades-Lin-Smyth heuristic, takes every
+/// dependency pointing backwards in that order, then restores the heaviest
+/// ones that do not close a cycle.
+fn feedback_edges(members: &[String], edges: &[Dependency]) -> Vec<Dependency> {
+    let index: HashMap<&str, usize> = members
+        .iter()
+        .enumerate()
+        .map(|(i, m)| (m.as_str(), i))
+        .collect();
+    let arcs: Vec<(usize, usize, usize)> = edges
+        .iter()
+        .map(|e| (index[e.from.as_str()], index[e.to.as_str()], e.references))
+        .collect();
+
+    let order = greedy_order(members.len(), &arcs);
+    let mut position = vec![0; members.len()];
+    for (pos, &node) in order.iter().enumerate() {
+        position[node] = pos;
+    }
+
+    let mut kept: Vec<Vec<usize>> = vec![Vec::new(); members.len()];
+    let mut backward: Vec<usize> = Vec::new();
+    for (i, &(from, to, _)) in arcs.iter().enumerate() {
+        if position[from] < position[to] {
+            kept[from].push(to);
+        } else {
+            backward.push(i);
+        }
+    }
+
+    backward.sort_by(|&a, &b| arcs[b].2.cmp(&arcs[a].2).then_with(|| a.cmp(&b)));
+    let mut removed = Vec::new();
+    for i in backward {
+        let (from, to, _) = arcs[i];
+        if reaches(&kept, to, from) {
+            removed.push(edges[i].clone());
+        } else {
+            kept[from].push(to);
+        }
+    }
+
+    removed.sort_by(|a, b| (&a.from, &a.to).cmp(&(&b.from, &b.to)));
+    removed
+}
+
+/// Eades-Lin-Smyth ordering: sinks go last, sources first, and otherwise the
+/// node with the largest weighted out-degree surplus goes next
+fn greedy_order(count: usize, arcs: &[(usize, usize, usize)]) -> Vec<usize> {
+    let mut remaining: Vec<bool> = vec![true; count];
+    let mut head = Vec::new();
+    let mut tail = Vec::new();
+
+    for _ in 0..count {
+        let mut out_weight = vec![0usize; count];
+        let mut in_weight = vec![0usize; count];
+        for &(from, to, weight) in arcs {
+            if remaining[from] && remaining[to] {
+                out_weight[from] += weight;
+                in_weight[to] += weight;
+            }
+        }
+
+        let candidates = (0..count).filter(|&n| remaining[n]);
+        let next = if let Some(sink) = candidates.clone().find(|&n| out_weight[n] == 0) {
+            tail.push(sink);
+            sink
+        } else if let Some(source) = candidates.clone().find(|&n| in_weight[n] == 0) {
+            head.push(source);
+            source
+        } else {
+            let best = candidates
+                .max_by_key(|&n| {
+                    (
+                        out_weight[n] as i64 - in_weight[n] as i64,
+                        std::cmp::Reverse(n),
+                    )
+                })
+                .unwrap_or(0);
+            head.push(best);
+            best
+        };
+        remaining[next] = false;
+    }
+
+    head.extend(tail.into_iter().rev());
+    head
+}
+
+/// Check if `to` is reachable from `from` in an adjacency list
+fn reaches(adjacency: &[Vec<usize>], from: usize, to: usize) -> bool {
+    let mut visited = vec![false; adjacency.len()];
+    let mut stack = vec![from];
+    while let Some(node) = stack.pop() {
+        if node == to {
+            return true;
+        }
+        if !std::mem::replace(&mut visited[node], true) {
+            stack.extend(&adjacency[node]);
+        }
+    }
+    false
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::EdgeData;
+    use crate::test_support::add_fn;
+
+    fn uses(graph: &mut PetCodeGraph, source: &str, target: &str, count: usize) {
+        for line in 0..count {
+            graph.add_edge(source, target, EdgeData::uses(Some(line + 1), None));
+        }
+    }
+
+    #[test]
+    fn test_package_cycle_break_edges() {
+        let mut graph = PetCodeGraph::new();
+        for id in ["a/a.go:A", "b/b.go:B", "c/c.go:C", "d/d.go:D"] {
+            add_fn(&mut graph, id);
+        }
+        // a -> b -> c -> a, with the weakest link c -> a; d is acyclic
+        uses(&mut graph, "a/a.go:A", "b/b.go:B", 5);
+        uses(&mut graph, "b/b.go:B", "c/c.go:C", 4);
+        uses(&mut graph, "c/c.go:C", "a/a.go:A", 1);
+        uses(&mut graph, "d/d.go:D", "a/a.go:A", 2);
+
+        let cycles = find_cycles(&graph, CycleLevel::Package);
+        assert_eq!(cycles.len(), 1);
+        assert_eq!(cycles[0].members, vec!["a", "b", "c"]);
+        assert_eq!(cycles[0].dependencies.len(), 3);
+        assert_eq!(cycles[0].break_edges.len(), 1);
+        assert_eq!(
+            (
+                cycles[0].break_edges[0].from.as_str(),
+                cycles[0].break_edges[0].to.as_str()
+            ),
+            ("c", "a")
+        );
+        assert_eq!(cycles[0].break_edges[0].file, "c/c.go");
+    }
+
+    #[test]
+    fn test_type_cycle_through_methods() {
+        let mut graph = PetCodeGraph::new();
+        for (id, name) in [("x.go:Order", "Order"), ("x.go:Item", "Item")] {
+            graph.add_node(Node::container(
+                id.to_string(),
+                name.to_string(),
+                ContainerKind::Type,
+                Some("struct".to_string()),
+                "x.go".to_string(),
+                1,
+                10,
+            ));
+        }
+        add_fn(&mut graph, "x.go:Order:Total");
+        add_fn(&mut graph, "x.go:Item:Parent");
+        graph.add_edge("x.go:Order", "x.go:Order:Total", EdgeData::contains());
+        graph.add_edge("x.go:Item", "x.go:Item:Parent", EdgeData::contains());
+        uses(&mut graph, "x.go:Order:Total", "x.go:Item", 1);
+        uses(&mut graph, "x.go:Item:Parent", "x.go:Order", 1);
+
+        let cycles = find_cycles(&graph, CycleLevel::Type);
+        assert_eq!(cycles.len(), 1);
+        assert_eq!(cycles[0].members, vec!["x.go:Item", "x.go:Order"]);
+        assert_eq!(cycles[0].break_edges.len(), 1);
+
+        // All in one package, so no package cycle
+        assert!(find_cycles(&graph, CycleLevel::Package).is_empty());
+    }
+}
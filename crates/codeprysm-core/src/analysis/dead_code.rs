@@ -0,0 +1,601 @@
+//! Dead Code Detection
+//!
+//! Marks every symbol reachable from a set of roots (entry points, exported
+//! API, tests, registered handlers) and reports the callables and types that
+//! are never reached. Entry points are the symbols tagged at build time (see
+//! [`crate::entry_points`]).
+//!
+//! Reachability follows USES and CROSS_LANGUAGE edges forward. A live member keeps its enclosing
+//! type alive, a live Go method keeps its receiver type alive, and a live
+//! interface method keeps the matching method of every implementer alive (see
+//! [`MethodSets`]), so calls through interfaces are not reported.
+//!
+//! Symbols can be excluded from the report with a suppression comment on the
+//! declaration line or the line above it:
+//!
+//! ```text
+//! // codeprysm:ignore dead-code
+//! func legacyHook() {}
+//! ```
+
+use std::collections::{HashMap, HashSet, VecDeque};
+use std::fs;
+use std::path::Path;
+use std::str::FromStr;
+
+use serde::Serialize;
+
+use super::implementers::MethodSets;
+use crate::file_policy::is_test_file;
+use crate::graph::{ContainerKind, EdgeType, Node, NodeType, PetCodeGraph};
+
+/// Marker recognized in suppression comments
+pub const SUPPRESSION_MARKER: &str = "codeprysm:ignore";
+
+/// Rule name accepted after the suppression marker
+pub const DEAD_CODE_RULE: &str = "dead-code";
+
+/// Scopes (from test overlays) that mark a callable as a test root
+const TEST_SCOPES: &[&str] = &["test", "benchmark", "example"];
+
+/// Categories of reachability roots.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash, Serialize)]
+#[serde(rename_all = "snake_case")]
+pub enum RootKind {
+    /// Program entry points (`main`, Go `init`)
+    Main,
+    /// Detected entry points: `main` and `TestMain` functions, registered
+    /// HTTP and gRPC handlers, scheduled jobs, and queue consumers
+    EntryPoints,
+    /// Public API; symbols without visibility information count as exported
+    Exported,
+    /// Test, benchmark and example functions, and anything in test files
+    Tests,
+    /// Decorated/attributed callables and names matching handler patterns
+    Handlers,
+}
+
+impl RootKind {
+    /// All root kinds
+    pub const ALL: [RootKind; 5] = [
+        RootKind::Main,
+        RootKind::EntryPoints,
+        RootKind::Exported,
+        RootKind::Tests,
+        RootKind::Handlers,
+    ];
+
+    /// Get the string representation
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            RootKind::Main => "main",
+            RootKind::EntryPoints => "entry-points",
+            RootKind::Exported => "exported",
+            RootKind::Tests => "tests",
+            RootKind::Handlers => "handlers",
+        }
+    }
+}
+
+impl FromStr for RootKind {
+    type Err = String;
+
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        match s.to_ascii_lowercase().as_str() {
+            "main" => Ok(RootKind::Main),
+            "entry-points" | "entry_points" | "entrypoints" => Ok(RootKind::EntryPoints),
+            "exported" => Ok(RootKind::Exported),
+            "tests" | "test" => Ok(RootKind::Tests),
+            "handlers" | "handler" => Ok(RootKind::Handlers),
+            _ => Err(format!(
+                "Unknown root kind: '{}'. Valid values: main, entry-points, exported, tests, handlers",
+                s
+            )),
+        }
+    }
+}
+
+/// Options controlling dead code detection.
+#[derive(Debug, Clone)]
+pub struct DeadCodeOptions {
+    /// Root categories to start reachability from
+    pub roots: Vec<RootKind>,
+    /// Glob patterns over callable names treated as registered handlers
+    /// (e.g., "ServeHTTP", "Handle*"); invalid patterns are ignored
+    pub handler_patterns: Vec<String>,
+    /// Additional root node IDs
+    pub extra_roots: Vec<String>,
+}
+
+impl Default for DeadCodeOptions {
+    fn default() -> Self {
+        Self {
+            roots: RootKind::ALL.to_vec(),
+            handler_patterns: Vec::new(),
+            extra_roots: Vec::new(),
+        }
+    }
+}
+
+/// A symbol with no inbound reachability from any root.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize)]
+pub struct DeadSymbol {
+    /// Node ID
+    pub id: String,
+    /// Symbol name
+    pub name: String,
+    /// Node type
+    pub node_type: NodeType,
+    /// Kind within the node type (e.g., "function", "type")
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub kind: Option<String>,
+    /// File path relative to the repository root
+    pub file: String,
+    /// Start line (1-indexed)
+    pub line: usize,
+    /// End line (1-indexed)
+    pub end_line: usize,
+}
+
+/// Result of a dead code analysis.
+#[derive(Debug, Clone, Default, Serialize)]
+pub struct DeadCodeReport {
+    /// Number of root symbols reachability started from
+    pub root_count: usize,
+    /// Number of symbols reachable from the roots
+    pub reachable_count: usize,
+    /// Unreachable symbols, ordered by file and line
+    pub dead: Vec<DeadSymbol>,
+    /// Number of unreachable symbols hidden by suppression comments
+    pub suppressed_count: usize,
+}
+
+impl DeadCodeReport {
+    /// Drop symbols whose declaration carries a suppression comment.
+    ///
+    /// Source files are read relative to `repo_root`; unreadable files are
+    /// left unsuppressed.
+    pub fn apply_suppressions(&mut self, repo_root: &Path) {
+        self.suppressed_count +=
+            retain_unsuppressed(&mut self.dead, repo_root, DEAD_CODE_RULE, |symbol| {
+                (&symbol.file, symbol.line)
+            });
+    }
+}
+
+/// Find callables and types not reachable from the configured roots.
+pub fn find_dead_code(graph: &PetCodeGraph, options: &DeadCodeOptions) -> DeadCodeReport {
+    let sets = MethodSets::build(graph);
+    let owners = sets.method_owners();
+
+    let handler_patterns: Vec<glob::Pattern> = options
+        .handler_patterns
+        .iter()
+        .filter_map(|p| glob::Pattern::new(p).ok())
+        .collect();
+
+    let mut live: HashSet<&str> = HashSet::new();
+    let mut queue: VecDeque<&str> = VecDeque::new();
+
+    for node in graph.iter_nodes() {
+        if is_root(node, options, &handler_patterns) && live.insert(node.id.as_str()) {
+            queue.push_back(node.id.as_str());
+        }
+    }
+    for id in &options.extra_roots {
+        if let Some(node) = graph.get_node(id) {
+            if live.insert(node.id.as_str()) {
+                queue.push_back(node.id.as_str());
+            }
+        }
+    }
+    let root_count = live.len();
+
+    while let Some(id) = queue.pop_front() {
+        let mut next: Vec<&str> = Vec::new();
+
+        // Forward references, also across language boundaries
+        next.extend(
+            graph
+                .outgoing_edges(id)
+                .filter(|(_, edge)| {
+                    matches!(edge.edge_type, EdgeType::Uses | EdgeType::CrossLanguage)
+                })
+                .map(|(target, _)| target.id.as_str()),
+        );
+
+        // Enclosing types and callables stay alive with their members
+        if let Some(parent) = graph.parent(id) {
+            if is_candidate(parent) {
+                next.push(parent.id.as_str());
+
+                // Calls through an interface reach every implementation
+                if sets.is_interface(&parent.id) {
+                    if let Some(method) = graph.get_node(id) {
+                        for implementer in sets.implementers(&parent.id) {
+                            next.extend(
+                                sets.method_ids(&implementer, &method.name)
+                                    .iter()
+                                    .filter_map(|m| graph.get_node(m))
+                                    .map(|n| n.id.as_str()),
+                            );
+                        }
+                    }
+                }
+            }
+        }
+
+        // Go methods keep their receiver type alive
+        if let Some(types) = owners.get(id) {
+            next.extend(types.iter().copied());
+        }
+
+        for target in next {
+            if live.insert(target) {
+                queue.push_back(target);
+            }
+        }
+    }
+
+    let mut dead: Vec<DeadSymbol> = graph
+        .iter_nodes()
+        .filter(|n| is_candidate(n) && !live.contains(n.id.as_str()))
+        .map(|n| DeadSymbol {
+            id: n.id.clone(),
+            name: n.name.clone(),
+            node_type: n.node_type,
+            kind: n.kind.clone(),
+            file: n.file.clone(),
+            line: n.line,
+            end_line: n.end_line,
+        })
+        .collect();
+    dead.sort_by(|a, b| (&a.file, a.line, &a.id).cmp(&(&b.file, b.line, &b.id)));
+
+    DeadCodeReport {
+        root_count,
+        reachable_count: live.len(),
+        dead,
+        suppressed_count: 0,
+    }
+}
+
+/// Symbols that can be reported: callables and type containers
+fn is_candidate(node: &Node) -> bool {
+    match node.node_type {
+        NodeType::Callable => true,
+        NodeType::Container => node.kind.as_deref() == Some(ContainerKind::Type.as_str()),
+        NodeType::Data => false,
+    }
+}
+
+/// Check whether a node is a root under the given options
+fn is_root(node: &Node, options: &DeadCodeOptions, handler_patterns: &[glob::Pattern]) -> bool {
+    if !is_candidate(node) {
+        return false;
+    }
+
+    options.roots.iter().any(|kind| match kind {
+        RootKind::Main => {
+            node.node_type == NodeType::Callable && matches!(node.name.as_str(), "main" | "init")
+        }
+        RootKind::EntryPoints => node.metadata.entry_point.is_some(),
+        RootKind::Exported => node
+            .metadata
+            .visibility
+            .as_deref()
+            .is_none_or(|v| v == "public"),
+        RootKind::Tests => is_test_code(node),
+        RootKind::Handlers => {
+            node.node_type == NodeType::Callable
+                && (node
+                    .metadata
+                    .decorators
+                    .as_ref()
+                    .is_some_and(|d| !d.is_empty())
+                    || handler_patterns.iter().any(|p| p.matches(&node.name)))
+        }
+    })
+}
+
+/// Check whether a node is test code: a test, benchmark, or example callable,
+/// or anything declared in a test file
+pub(crate) fn is_test_code(node: &Node) -> bool {
+    node.metadata
+        .scope
+        .as_deref()
+        .is_some_and(|s| TEST_SCOPES.contains(&s))
+        || is_test_file(&node.file)
+}
+
+/// Find symbols that are dead in `new` but were not in `old`, e.g. because a
+/// change removed their last use or added them unused.
+///
+/// Counts in the report describe `new`.
+pub fn find_new_dead_code(
+    old: &PetCodeGraph,
+    new: &PetCodeGraph,
+    options: &DeadCodeOptions,
+) -> DeadCodeReport {
+    let known: HashSet<String> = find_dead_code(old, options)
+        .dead
+        .into_iter()
+        .map(|symbol| symbol.id)
+        .collect();
+    let mut report = find_dead_code(new, options);
+    report.dead.retain(|symbol| !known.contains(&symbol.id));
+    report
+}
+
+/// Check whether the declaration at `line` (1-indexed) carries a
+/// suppression comment for `rule`, trailing the declaration or on a comment
+/// line of its own right above it.
+///
+/// A bare `codeprysm:ignore` suppresses every rule. A comment trailing the
+/// line above belongs to that line's declaration and does not count.
+pub fn is_suppressed(lines: &[String], line: usize, rule: &str) -> bool {
+    let own = line.checked_sub(1).and_then(|idx| lines.get(idx));
+    let above = line
+        .checked_sub(2)
+        .and_then(|idx| lines.get(idx))
+        .filter(|text| is_comment_line(text));
+    own.into_iter().chain(above).any(|text| {
+        text.find(SUPPRESSION_MARKER).is_some_and(|pos| {
+            let rest = text[pos + SUPPRESSION_MARKER.len()..].trim_start();
+            rest.is_empty() || rest.starts_with(rule) || rest.starts_with("*/")
+        })
+    })
+}
+
+/// Drop the items whose declaration carries a suppression comment for
+/// `rule`, given each item's file (relative to `repo_root`) and line.
+///
+/// Each file is read once; unreadable files are left unsuppressed. Returns
+/// the number of items dropped.
+pub fn retain_unsuppressed<T>(
+    items: &mut Vec<T>,
+    repo_root: &Path,
+    rule: &str,
+    location: impl Fn(&T) -> (&str, usize),
+) -> usize {
+    let mut files: HashMap<String, Option<Vec<String>>> = HashMap::new();
+
+    let before = items.len();
+    items.retain(|item| {
+        let (file, line) = location(item);
+        let lines = files.entry(file.to_string()).or_insert_with(|| {
+            fs::read_to_string(repo_root.join(file))
+                .ok()
+                .map(|content| content.lines().map(String::from).collect())
+        });
+        !lines
+            .as_deref()
+            .is_some_and(|lines| is_suppressed(lines, line, rule))
+    });
+    before - items.len()
+}
+
+/// Whether a line holds nothing but a comment
+fn is_comment_line(text: &str) -> bool {
+    let text = text.trim_start();
+    text.starts_with("//") || text.starts_with('#') || text.starts_with("/*")
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::EdgeData;
+    use crate::test_support::function;
+
+    fn add_fn(graph: &mut PetCodeGraph, id: &str, file: &str, visibility: &str) {
+        let mut node = function(id);
+        node.file = file.to_string();
+        node.line = 3;
+        node.metadata.visibility = Some(visibility.to_string());
+        graph.add_node(node);
+    }
+
+    /// main -> run -> helper; orphan is private and unused; Exported is public
+    fn sample_graph() -> PetCodeGraph {
+        let mut graph = PetCodeGraph::new();
+        add_fn(&mut graph, "cmd/main.go:main", "cmd/main.go", "private");
+        add_fn(&mut graph, "cmd/main.go:run", "cmd/main.go", "private");
+        add_fn(&mut graph, "cmd/util.go:helper", "cmd/util.go", "private");
+        add_fn(&mut graph, "cmd/util.go:orphan", "cmd/util.go", "private");
+        add_fn(&mut graph, "cmd/util.go:Exported", "cmd/util.go", "public");
+        add_fn(
+            &mut graph,
+            "cmd/util_test.go:checkHelper",
+            "cmd/util_test.go",
+            "private",
+        );
+        graph.add_edge(
+            "cmd/main.go:main",
+            "cmd/main.go:run",
+            EdgeData::uses(Some(4), None),
+        );
+        graph.add_edge(
+            "cmd/main.go:run",
+            "cmd/util.go:helper",
+            EdgeData::uses(Some(4), None),
+        );
+        graph
+    }
+
+    fn dead_ids(report: &DeadCodeReport) -> Vec<&str> {
+        report.dead.iter().map(|d| d.id.as_str()).collect()
+    }
+
+    #[test]
+    fn test_find_dead_code_default_roots() {
+        let report = find_dead_code(&sample_graph(), &DeadCodeOptions::default());
+        assert_eq!(dead_ids(&report), vec!["cmd/util.go:orphan"]);
+        assert_eq!(report.root_count, 3);
+        assert_eq!(report.reachable_count, 5);
+    }
+
+    #[test]
+    fn test_find_dead_code_root_selection() {
+        let graph = sample_graph();
+
+        let main_only = DeadCodeOptions {
+            roots: vec![RootKind::Main],
+            ..Default::default()
+        };
+        assert_eq!(
+            dead_ids(&find_dead_code(&graph, &main_only)),
+            vec![
+                "cmd/util.go:Exported",
+                "cmd/util.go:orphan",
+                "cmd/util_test.go:checkHelper"
+            ]
+        );
+
+        let with_handlers = DeadCodeOptions {
+            roots: vec![RootKind::Main, RootKind::Handlers],
+            handler_patterns: vec!["orph*".to_string()],
+            extra_roots: vec!["cmd/util.go:Exported".to_string()],
+        };
+        assert_eq!(
+            dead_ids(&find_dead_code(&graph, &with_handlers)),
+            vec!["cmd/util_test.go:checkHelper"]
+        );
+    }
+
+    #[test]
+    fn test_entry_points_are_roots() {
+        let mut graph = sample_graph();
+        graph
+            .get_node_mut("cmd/util.go:orphan")
+            .unwrap()
+            .metadata
+            .entry_point = Some("http_handler".to_string());
+
+        let report = find_dead_code(&graph, &DeadCodeOptions::default());
+        assert!(report.dead.is_empty());
+
+        let main_only = DeadCodeOptions {
+            roots: vec![RootKind::Main],
+            ..Default::default()
+        };
+        assert!(dead_ids(&find_dead_code(&graph, &main_only)).contains(&"cmd/util.go:orphan"));
+    }
+
+    #[test]
+    fn test_interface_dispatch_and_receivers_keep_code_alive() {
+        let mut graph = PetCodeGraph::new();
+        add_fn(&mut graph, "main.go:main", "main.go", "private");
+
+        graph.add_node(Node::container(
+            "main.go:store".to_string(),
+            "store".to_string(),
+            ContainerKind::Type,
+            Some("interface".to_string()),
+            "main.go".to_string(),
+            10,
+            12,
+        ));
+        add_fn(&mut graph, "main.go:store:save", "main.go", "private");
+        graph.add_edge("main.go:store", "main.go:store:save", EdgeData::contains());
+
+        graph.add_node(Node::container(
+            "main.go:memStore".to_string(),
+            "memStore".to_string(),
+            ContainerKind::Type,
+            Some("struct".to_string()),
+            "main.go".to_string(),
+            14,
+            16,
+        ));
+        add_fn(&mut graph, "main.go:save", "main.go", "private");
+        graph
+            .get_node_mut("main.go:save")
+            .unwrap()
+            .metadata
+            .receiver = Some("memStore".to_string());
+
+        graph.add_edge(
+            "main.go:main",
+            "main.go:store:save",
+            EdgeData::uses(Some(4), None),
+        );
+
+        let options = DeadCodeOptions {
+            roots: vec![RootKind::Main],
+            ..Default::default()
+        };
+        let report = find_dead_code(&graph, &options);
+        assert!(
+            report.dead.is_empty(),
+            "unexpected: {:?}",
+            dead_ids(&report)
+        );
+    }
+
+    #[test]
+    fn test_find_new_dead_code() {
+        let old = sample_graph();
+        let mut new = sample_graph();
+        // run no longer calls helper
+        new.remove_edges_by_type(EdgeType::Uses);
+        new.add_edge(
+            "cmd/main.go:main",
+            "cmd/main.go:run",
+            EdgeData::uses(Some(4), None),
+        );
+
+        let report = find_new_dead_code(&old, &new, &DeadCodeOptions::default());
+        assert_eq!(dead_ids(&report), vec!["cmd/util.go:helper"]);
+    }
+
+    #[test]
+    fn test_suppression_comments() {
+        let lines: Vec<String> = [
+            "package main",
+            "// codeprysm:ignore dead-code",
+            "func legacy() {}",
+            "func other() {} // codeprysm:ignore",
+            "func kept() {} // codeprysm:ignore complexity",
+        ]
+        .iter()
+        .map(|s| s.to_string())
+        .collect();
+
+        assert!(is_suppressed(&lines, 3, DEAD_CODE_RULE));
+        assert!(is_suppressed(&lines, 4, DEAD_CODE_RULE));
+        assert!(!is_suppressed(&lines, 5, DEAD_CODE_RULE));
+        assert!(!is_suppressed(&lines, 1, DEAD_CODE_RULE));
+    }
+
+    #[test]
+    fn test_apply_suppressions_reads_sources() {
+        let dir = tempfile::tempdir().unwrap();
+        fs::write(
+            dir.path().join("util.go"),
+            "package util\n\n// codeprysm:ignore dead-code\nfunc orphan() {}\n",
+        )
+        .unwrap();
+
+        // Items in missing files are kept
+        let mut items = vec![("util.go", 4), ("util.go", 1), ("missing.go", 4)];
+        let dropped = retain_unsuppressed(&mut items, dir.path(), DEAD_CODE_RULE, |&item| item);
+        assert_eq!(dropped, 1);
+        assert_eq!(items, vec![("util.go", 1), ("missing.go", 4)]);
+
+        let mut report = DeadCodeReport {
+            dead: vec![DeadSymbol {
+                id: "util.go:orphan".to_string(),
+                name: "orphan".to_string(),
+                node_type: NodeType::Callable,
+                kind: Some("function".to_string()),
+                file: "util.go".to_string(),
+                line: 4,
+                end_line: 4,
+            }],
+            ..Default::default()
+        };
+        report.apply_suppressions(dir.path());
+        assert!(report.dead.is_empty());
+        assert_eq!(report.suppressed_count, 1);
+    }
+}
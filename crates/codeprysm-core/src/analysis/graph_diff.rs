@@ -0,0 +1,419 @@
+//! Graph Diff
+//!
+//! Structural comparison of two graphs, typically built from two revisions of
+//! the same repository. Nodes are matched by ID, which is stable across line
+//! moves (see `generate_node_id`), so a function that merely moved is not
+//! reported. Edges are matched by `(source, target, type)`.
+//!
+//! A node counts as changed when its kind, subtype, semantic metadata, size in
+//! lines, file hash, or declaration signature differs. Signatures are read from
+//! source by [`callable_signatures`] because the graph does not store text.
+
+use std::collections::{BTreeMap, BTreeSet, HashMap};
+use std::path::Path;
+
+use serde::{Deserialize, Serialize};
+
+use super::api_surface::declaration_signature;
+use crate::graph::{EdgeType, Node, NodeType, PetCodeGraph};
+
+/// Metadata fields left out of the comparison: the clone signature changes
+/// with any body edit, which the file hash already reports, coverage, churn,
+/// and blame authors depend on which profile and history were loaded rather
+/// than on the code, and reference and type usage counts shift whenever
+/// another file adds or removes a definition or a use
+const IGNORED_METADATA: &[&str] = &[
+    "clone_signature",
+    "covered_statements",
+    "total_statements",
+    "churn_commits",
+    "churn_authors",
+    "last_changed",
+    "risk_score",
+    "primary_author",
+    "last_modified_by",
+    "resolved_refs",
+    "unresolved_refs",
+    "signature_uses",
+    "field_uses",
+    "instantiations",
+    "weight",
+];
+
+/// Summary of a node that was added or removed.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct NodeSummary {
+    /// Node ID
+    pub id: String,
+    /// Entity name
+    pub name: String,
+    /// Node type
+    pub node_type: NodeType,
+    /// Kind within the node type
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub kind: Option<String>,
+    /// File path relative to the repository root
+    pub file: String,
+    /// Start line (1-indexed)
+    pub line: usize,
+}
+
+impl From<&Node> for NodeSummary {
+    fn from(node: &Node) -> Self {
+        Self {
+            id: node.id.clone(),
+            name: node.name.clone(),
+            node_type: node.node_type,
+            kind: node.kind.clone(),
+            file: node.file.clone(),
+            line: node.line,
+        }
+    }
+}
+
+/// One attribute that differs between the two revisions of a node.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct FieldChange {
+    /// Attribute name (e.g., "signature", "subtype", "visibility")
+    pub field: String,
+    /// Value in the old revision, if any
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub old: Option<String>,
+    /// Value in the new revision, if any
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub new: Option<String>,
+}
+
+/// A node present in both revisions whose attributes differ.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct ChangedNode {
+    /// The node as it appears in the new revision
+    #[serde(flatten)]
+    pub node: NodeSummary,
+    /// Attributes that differ, sorted by field name
+    pub changes: Vec<FieldChange>,
+}
+
+/// An edge that was added or removed.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct EdgeKey {
+    /// Source node ID
+    pub source: String,
+    /// Target node ID
+    pub target: String,
+    /// Relationship type
+    pub edge_type: EdgeType,
+}
+
+impl Ord for EdgeKey {
+    fn cmp(&self, other: &Self) -> std::cmp::Ordering {
+        (&self.source, &self.target, self.edge_type.as_str()).cmp(&(
+            &other.source,
+            &other.target,
+            other.edge_type.as_str(),
+        ))
+    }
+}
+
+impl PartialOrd for EdgeKey {
+    fn partial_cmp(&self, other: &Self) -> Option<std::cmp::Ordering> {
+        Some(self.cmp(other))
+    }
+}
+
+/// Differences between two graphs. All lists are sorted by node ID.
+#[derive(Debug, Clone, Default, Serialize, Deserialize)]
+pub struct GraphDiff {
+    /// Nodes only in the new graph
+    pub added_nodes: Vec<NodeSummary>,
+    /// Nodes only in the old graph
+    pub removed_nodes: Vec<NodeSummary>,
+    /// Nodes in both graphs with different attributes
+    pub changed_nodes: Vec<ChangedNode>,
+    /// Edges only in the new graph
+    pub added_edges: Vec<EdgeKey>,
+    /// Edges only in the old graph
+    pub removed_edges: Vec<EdgeKey>,
+}
+
+impl GraphDiff {
+    /// Check if the graphs are identical
+    pub fn is_empty(&self) -> bool {
+        self.added_nodes.is_empty()
+            && self.removed_nodes.is_empty()
+            && self.changed_nodes.is_empty()
+            && self.added_edges.is_empty()
+            && self.removed_edges.is_empty()
+    }
+
+    /// Record signature changes for nodes present in both revisions.
+    ///
+    /// `old` and `new` map node IDs to declaration signatures, as returned by
+    /// [`callable_signatures`]. Nodes missing from either map are skipped.
+    pub fn add_signature_changes(
+        &mut self,
+        new_graph: &PetCodeGraph,
+        old: &HashMap<String, String>,
+        new: &HashMap<String, String>,
+    ) {
+        let mut changed: BTreeMap<String, ChangedNode> = self
+            .changed_nodes
+            .drain(..)
+            .map(|c| (c.node.id.clone(), c))
+            .collect();
+
+        for (id, new_signature) in new {
+            let Some(old_signature) = old.get(id) else {
+                continue;
+            };
+            if old_signature == new_signature {
+                continue;
+            }
+            let Some(node) = new_graph.get_node(id) else {
+                continue;
+            };
+
+            let entry = changed.entry(id.clone()).or_insert_with(|| ChangedNode {
+                node: NodeSummary::from(node),
+                changes: Vec::new(),
+            });
+            entry.changes.push(FieldChange {
+                field: "signature".to_string(),
+                old: Some(old_signature.clone()),
+                new: Some(new_signature.clone()),
+            });
+            entry.changes.sort_by(|a, b| a.field.cmp(&b.field));
+        }
+
+        self.changed_nodes = changed.into_values().collect();
+    }
+}
+
+/// Compare two graphs.
+pub fn diff_graphs(old: &PetCodeGraph, new: &PetCodeGraph) -> GraphDiff {
+    let mut diff = GraphDiff::default();
+
+    for node in new.iter_nodes() {
+        match old.get_node(&node.id) {
+            None => diff.added_nodes.push(NodeSummary::from(node)),
+            Some(previous) => {
+                let changes = node_changes(previous, node);
+                if !changes.is_empty() {
+                    diff.changed_nodes.push(ChangedNode {
+                        node: NodeSummary::from(node),
+                        changes,
+                    });
+                }
+            }
+        }
+    }
+    diff.removed_nodes = old
+        .iter_nodes()
+        .filter(|n| !new.contains_node(&n.id))
+        .map(NodeSummary::from)
+        .collect();
+
+    let old_edges = edge_keys(old);
+    let new_edges = edge_keys(new);
+    diff.added_edges = new_edges.difference(&old_edges).cloned().collect();
+    diff.removed_edges = old_edges.difference(&new_edges).cloned().collect();
+
+    diff.added_nodes.sort_by(|a, b| a.id.cmp(&b.id));
+    diff.removed_nodes.sort_by(|a, b| a.id.cmp(&b.id));
+    diff.changed_nodes.sort_by(|a, b| a.node.id.cmp(&b.node.id));
+    diff
+}
+
+/// Read the declaration signature of every callable from source.
+///
+/// The signature is the declaration up to its body (see
+/// [`declaration_signature`]), which captures the name, parameters, and return
+/// type. Files that cannot be read are skipped.
+pub fn callable_signatures(graph: &PetCodeGraph, repo_root: &Path) -> HashMap<String, String> {
+    let mut by_file: BTreeMap<&str, Vec<&Node>> = BTreeMap::new();
+    for node in graph
+        .iter_nodes()
+        .filter(|n| n.node_type == NodeType::Callable)
+    {
+        by_file.entry(node.file.as_str()).or_default().push(node);
+    }
+
+    let mut signatures = HashMap::new();
+    for (file, nodes) in by_file {
+        let Ok(content) = std::fs::read_to_string(repo_root.join(file)) else {
+            continue;
+        };
+        let lines: Vec<&str> = content.lines().collect();
+        for node in nodes {
+            if let Some(signature) = declaration_signature(&lines, node.line, node.end_line) {
+                signatures.insert(node.id.clone(), signature);
+            }
+        }
+    }
+    signatures
+}
+
+/// Attribute differences between two revisions of a node
+fn node_changes(old: &Node, new: &Node) -> Vec<FieldChange> {
+    let mut changes = Vec::new();
+    let mut compare = |field: &str, old: Option<String>, new: Option<String>| {
+        if old != new {
+            changes.push(FieldChange {
+                field: field.to_string(),
+                old,
+                new,
+            });
+        }
+    };
+
+    compare("kind", old.kind.clone(), new.kind.clone());
+    compare("subtype", old.subtype.clone(), new.subtype.clone());
+    compare(
+        "lines",
+        Some(span(old).to_string()),
+        Some(span(new).to_string()),
+    );
+    compare("hash", old.hash.clone(), new.hash.clone());
+
+    // Compare metadata generically so new fields are picked up automatically
+    let old_meta = metadata_fields(old);
+    let new_meta = metadata_fields(new);
+    let keys: BTreeSet<&String> = old_meta.keys().chain(new_meta.keys()).collect();
+    for key in keys {
+        compare(key, old_meta.get(key).cloned(), new_meta.get(key).cloned());
+    }
+
+    changes.sort_by(|a, b| a.field.cmp(&b.field));
+    changes
+}
+
+/// Number of lines a node spans
+fn span(node: &Node) -> usize {
+    node.end_line.saturating_sub(node.line) + 1
+}
+
+/// Metadata fields rendered as strings, keyed by their serialized name
+fn metadata_fields(node: &Node) -> BTreeMap<String, String> {
+    match serde_json::to_value(&node.metadata) {
+        Ok(serde_json::Value::Object(map)) => map
+            .into_iter()
+            .filter(|(key, _)| !IGNORED_METADATA.contains(&key.as_str()))
+            .map(|(key, value)| {
+                let text = match value {
+                    serde_json::Value::String(s) => s,
+                    other => other.to_string(),
+                };
+                (key, text)
+            })
+            .collect(),
+        _ => BTreeMap::new(),
+    }
+}
+
+/// Identity keys of all edges in a graph
+fn edge_keys(graph: &PetCodeGraph) -> BTreeSet<EdgeKey> {
+    graph
+        .iter_edges()
+        .map(|e| EdgeKey {
+            source: e.source,
+            target: e.target,
+            edge_type: e.edge_type,
+        })
+        .collect()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::{CallableKind, EdgeData};
+
+    fn func(id: &str, line: usize, end_line: usize) -> Node {
+        Node::callable(
+            id.to_string(),
+            id.rsplit(':').next().unwrap().to_string(),
+            CallableKind::Function,
+            "api/h.go".to_string(),
+            line,
+            end_line,
+        )
+    }
+
+    #[test]
+    fn test_diff_graphs() {
+        let mut old = PetCodeGraph::new();
+        old.add_node(func("api/h.go:serve", 1, 10));
+        old.add_node(func("api/h.go:moved", 12, 15));
+        old.add_node(func("api/h.go:gone", 20, 25));
+        old.add_edge(
+            "api/h.go:serve",
+            "api/h.go:gone",
+            EdgeData::uses(Some(3), None),
+        );
+
+        let mut new = PetCodeGraph::new();
+        let mut serve = func("api/h.go:serve", 1, 12);
+        serve.metadata.cyclomatic_complexity = Some(3);
+        new.add_node(serve);
+        // Same size, different position: not a change
+        new.add_node(func("api/h.go:moved", 30, 33));
+        new.add_node(func("api/h.go:fresh", 40, 42));
+        new.add_edge(
+            "api/h.go:serve",
+            "api/h.go:fresh",
+            EdgeData::uses(Some(3), None),
+        );
+
+        let diff = diff_graphs(&old, &new);
+        assert!(!diff.is_empty());
+
+        let added: Vec<&str> = diff.added_nodes.iter().map(|n| n.id.as_str()).collect();
+        assert_eq!(added, vec!["api/h.go:fresh"]);
+        let removed: Vec<&str> = diff.removed_nodes.iter().map(|n| n.id.as_str()).collect();
+        assert_eq!(removed, vec!["api/h.go:gone"]);
+
+        assert_eq!(diff.changed_nodes.len(), 1);
+        let changed = &diff.changed_nodes[0];
+        assert_eq!(changed.node.id, "api/h.go:serve");
+        let fields: Vec<&str> = changed.changes.iter().map(|c| c.field.as_str()).collect();
+        assert_eq!(fields, vec!["cyclomatic_complexity", "lines"]);
+        assert_eq!(changed.changes[1].old.as_deref(), Some("10"));
+        assert_eq!(changed.changes[1].new.as_deref(), Some("12"));
+
+        assert_eq!(diff.added_edges.len(), 1);
+        assert_eq!(diff.added_edges[0].target, "api/h.go:fresh");
+        assert_eq!(diff.removed_edges.len(), 1);
+        assert_eq!(diff.removed_edges[0].target, "api/h.go:gone");
+
+        assert!(diff_graphs(&new, &new).is_empty());
+    }
+
+    #[test]
+    fn test_signature_changes() {
+        let dir = tempfile::tempdir().unwrap();
+        std::fs::create_dir_all(dir.path().join("api")).unwrap();
+
+        let mut graph = PetCodeGraph::new();
+        graph.add_node(func("api/h.go:serve", 1, 3));
+
+        std::fs::write(dir.path().join("api/h.go"), "func serve(a int) {\n}\n").unwrap();
+        let old = callable_signatures(&graph, dir.path());
+        assert_eq!(old["api/h.go:serve"], "func serve(a int)");
+
+        std::fs::write(
+            dir.path().join("api/h.go"),
+            "func serve(a int, b string) error {\n}\n",
+        )
+        .unwrap();
+        let new = callable_signatures(&graph, dir.path());
+
+        let mut diff = diff_graphs(&graph, &graph);
+        diff.add_signature_changes(&graph, &old, &new);
+        assert_eq!(diff.changed_nodes.len(), 1);
+        let change = &diff.changed_nodes[0].changes[0];
+        assert_eq!(change.field, "signature");
+        assert_eq!(
+            change.new.as_deref(),
+            Some("func serve(a int, b string) error")
+        );
+    }
+}
@@ -0,0 +1,290 @@
+//! Hotspot Detection
+//!
+//! Ranks functions and types by how central they are in the dependency graph
+//! and how often they change:
+//!
+//! - **Fan-in**: distinct symbols that use this one (callers, referencing code)
+//! - **Fan-out**: distinct symbols this one uses
+//! - **Churn**: commits touching the symbol's file, supplied by the caller
+//! - **Score**: `(fan-in + fan-out) * (1 + ln(1 + churn))`, so a well-connected
+//!   symbol that also changes often ranks above an equally connected stable one
+//!
+//! Types are measured as a unit: references to or from any of their members
+//! count for the type, and references between members are ignored. Go methods
+//! are attributed to their receiver type.
+//!
+//! Two patterns are flagged: **god objects** (types with many members and
+//! many connections) and **fragile hubs** (symbols with high fan-in that change
+//! often, so every change ripples widely).
+
+use std::collections::{BTreeSet, HashMap};
+
+use serde::{Deserialize, Serialize};
+
+use super::api_surface::is_type;
+use super::{package_of, MethodSets};
+use crate::graph::{EdgeType, Node, NodeType, PetCodeGraph};
+
+/// Thresholds for flagging hotspots.
+#[derive(Debug, Clone, Copy)]
+pub struct HotspotOptions {
+    /// Minimum member count for a type to be considered a god object
+    pub god_object_members: usize,
+    /// Minimum fan-in for a hub, and minimum fan-in + fan-out for a god object
+    pub hub_fan_in: usize,
+    /// Minimum churn for a hub to be considered fragile
+    pub fragile_churn: u32,
+}
+
+impl Default for HotspotOptions {
+    fn default() -> Self {
+        Self {
+            god_object_members: 20,
+            hub_fan_in: 20,
+            fragile_churn: 10,
+        }
+    }
+}
+
+/// Pattern a hotspot matches.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum HotspotFlag {
+    /// Type with many members and many connections
+    GodObject,
+    /// Heavily used symbol that changes often
+    FragileHub,
+}
+
+impl HotspotFlag {
+    /// Get the string representation
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            HotspotFlag::GodObject => "god_object",
+            HotspotFlag::FragileHub => "fragile_hub",
+        }
+    }
+}
+
+/// Centrality and churn of one function or type.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct Hotspot {
+    /// Node ID
+    pub id: String,
+    /// Entity name
+    pub name: String,
+    /// Node type (Callable or Container)
+    pub node_type: NodeType,
+    /// Kind within the node type
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub kind: Option<String>,
+    /// Package (containing directory)
+    pub package: String,
+    /// File path relative to the repository root
+    pub file: String,
+    /// Start line (1-indexed)
+    pub line: usize,
+    /// Distinct symbols using this one
+    pub fan_in: usize,
+    /// Distinct symbols this one uses
+    pub fan_out: usize,
+    /// Number of members (types only)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub members: Option<usize>,
+    /// Commits touching the file
+    pub churn: u32,
+    /// Churn-weighted centrality
+    pub score: f64,
+    /// Patterns this symbol matches
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    pub flags: Vec<HotspotFlag>,
+}
+
+/// Rank functions and types by churn-weighted centrality, highest first.
+///
+/// `churn` maps file paths (relative to the repository root) to commit
+/// counts; pass an empty map to rank by connectivity alone. Symbols without
+/// any USES edges are omitted.
+pub fn find_hotspots(
+    graph: &PetCodeGraph,
+    churn: &HashMap<String, u32>,
+    options: &HotspotOptions,
+) -> Vec<Hotspot> {
+    let method_sets = MethodSets::build(graph);
+
+    // Member -> owning type, from CONTAINS children and Go receivers
+    let mut owners: HashMap<&str, &str> = HashMap::new();
+    let mut members: HashMap<&str, usize> = HashMap::new();
+    for node in graph.iter_nodes().filter(|n| is_type(n)) {
+        for child in graph.children(&node.id) {
+            owners.insert(child.id.as_str(), node.id.as_str());
+            *members.entry(node.id.as_str()).or_default() += 1;
+        }
+    }
+    for (method, types) in method_sets.method_owners() {
+        if let [type_id] = *types.as_slice() {
+            if owners.insert(method, type_id).is_none() {
+                *members.entry(type_id).or_default() += 1;
+            }
+        }
+    }
+
+    let mut fan_in: HashMap<&str, BTreeSet<&str>> = HashMap::new();
+    let mut fan_out: HashMap<&str, BTreeSet<&str>> = HashMap::new();
+    for (source, target, _) in graph.edges_by_type(EdgeType::Uses) {
+        if !is_symbol(source) || !is_symbol(target) || source.id == target.id {
+            continue;
+        }
+        let (source, target) = (source.id.as_str(), target.id.as_str());
+
+        fan_in.entry(target).or_default().insert(source);
+        fan_out.entry(source).or_default().insert(target);
+
+        // Type-level: attribute members to their type, skip internal references
+        let source_unit = owners.get(source).copied().unwrap_or(source);
+        let target_unit = owners.get(target).copied().unwrap_or(target);
+        if source_unit == target_unit {
+            continue;
+        }
+        if target_unit != target {
+            fan_in.entry(target_unit).or_default().insert(source_unit);
+        }
+        if source_unit != source {
+            fan_out.entry(source_unit).or_default().insert(target_unit);
+        }
+    }
+
+    let mut hotspots: Vec<Hotspot> = graph
+        .iter_nodes()
+        .filter(|n| n.node_type == NodeType::Callable || is_type(n))
+        .filter_map(|node| {
+            let fan_in = fan_in.get(node.id.as_str()).map_or(0, BTreeSet::len);
+            let fan_out = fan_out.get(node.id.as_str()).map_or(0, BTreeSet::len);
+            if fan_in + fan_out == 0 {
+                return None;
+            }
+
+            let member_count =
+                is_type(node).then(|| members.get(node.id.as_str()).copied().unwrap_or(0));
+            let churn = churn.get(&node.file).copied().unwrap_or(0);
+            let score = (fan_in + fan_out) as f64 * (1.0 + f64::from(churn).ln_1p());
+
+            let mut flags = Vec::new();
+            if member_count.is_some_and(|m| m >= options.god_object_members)
+                && fan_in + fan_out >= options.hub_fan_in
+            {
+                flags.push(HotspotFlag::GodObject);
+            }
+            if fan_in >= options.hub_fan_in && churn >= options.fragile_churn {
+                flags.push(HotspotFlag::FragileHub);
+            }
+
+            Some(Hotspot {
+                id: node.id.clone(),
+                name: node.name.clone(),
+                node_type: node.node_type,
+                kind: node.kind.clone(),
+                package: package_of(&node.file).to_string(),
+                file: node.file.clone(),
+                line: node.line,
+                fan_in,
+                fan_out,
+                members: member_count,
+                churn,
+                score,
+                flags,
+            })
+        })
+        .collect();
+
+    hotspots.sort_by(|a, b| b.score.total_cmp(&a.score).then_with(|| a.id.cmp(&b.id)));
+    hotspots
+}
+
+/// Source-level symbols that can take part in a dependency
+fn is_symbol(node: &Node) -> bool {
+    !node.file.is_empty() && (node.node_type != NodeType::Container || is_type(node))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::{ContainerKind, EdgeData};
+    use crate::test_support::{add_fn, uses};
+
+    /// Store type with two methods used by three handlers
+    fn sample_graph() -> PetCodeGraph {
+        let mut graph = PetCodeGraph::new();
+        graph.add_node(Node::container(
+            "store.go:Store".to_string(),
+            "Store".to_string(),
+            ContainerKind::Type,
+            Some("struct".to_string()),
+            "store.go".to_string(),
+            1,
+            20,
+        ));
+        for method in ["store.go:Store:Get", "store.go:Store:Put"] {
+            add_fn(&mut graph, method);
+            graph.add_edge("store.go:Store", method, EdgeData::contains());
+        }
+        for handler in ["api/a.go:list", "api/b.go:show", "api/b.go:update"] {
+            add_fn(&mut graph, handler);
+        }
+        add_fn(&mut graph, "db.go:query");
+
+        uses(&mut graph, "api/a.go:list", "store.go:Store:Get");
+        uses(&mut graph, "api/b.go:show", "store.go:Store:Get");
+        uses(&mut graph, "api/b.go:update", "store.go:Store:Put");
+        uses(&mut graph, "api/b.go:update", "store.go:Store");
+        // Internal reference, ignored at type level
+        uses(&mut graph, "store.go:Store:Put", "store.go:Store:Get");
+        uses(&mut graph, "store.go:Store:Get", "db.go:query");
+        uses(&mut graph, "store.go:Store:Put", "db.go:query");
+        graph
+    }
+
+    fn get<'a>(hotspots: &'a [Hotspot], id: &str) -> &'a Hotspot {
+        hotspots.iter().find(|h| h.id == id).unwrap()
+    }
+
+    #[test]
+    fn test_fan_in_and_fan_out() {
+        let hotspots = find_hotspots(&sample_graph(), &HashMap::new(), &HotspotOptions::default());
+
+        let store = get(&hotspots, "store.go:Store");
+        assert_eq!(
+            (store.fan_in, store.fan_out, store.members),
+            (3, 1, Some(2))
+        );
+
+        let get_method = get(&hotspots, "store.go:Store:Get");
+        assert_eq!((get_method.fan_in, get_method.fan_out), (3, 1));
+        assert_eq!(get_method.members, None);
+
+        let update = get(&hotspots, "api/b.go:update");
+        assert_eq!((update.fan_in, update.fan_out), (0, 2));
+
+        assert_eq!(hotspots[0].id, "store.go:Store");
+        assert!(hotspots.iter().all(|h| h.flags.is_empty()));
+    }
+
+    #[test]
+    fn test_churn_weighting_and_flags() {
+        let churn = HashMap::from([("db.go".to_string(), 30)]);
+        let options = HotspotOptions {
+            god_object_members: 2,
+            hub_fan_in: 2,
+            fragile_churn: 10,
+        };
+        let hotspots = find_hotspots(&sample_graph(), &churn, &options);
+
+        // db.go:query has fan-in 2 but churns heavily
+        assert_eq!(hotspots[0].id, "db.go:query");
+        assert_eq!(hotspots[0].flags, vec![HotspotFlag::FragileHub]);
+        assert_eq!(
+            get(&hotspots, "store.go:Store").flags,
+            vec![HotspotFlag::GodObject]
+        );
+    }
+}
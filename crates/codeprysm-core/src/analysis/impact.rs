@@ -0,0 +1,521 @@
+//! Change Impact Analysis
+//!
+//! Maps the line spans touched by a unified diff to the symbols that contain
+//! them, then walks USES and CROSS_LANGUAGE edges in reverse to find every
+//! symbol that depends on a changed one. Tests among the impacted symbols form a selective test set,
+//! and the entry points among them (see [`crate::entry_points`]) are the
+//! programs, handlers, and consumers whose behavior may change.
+//!
+//! Line numbers are taken from the new side of the diff, so the graph should
+//! be indexed from the post-change tree (e.g., the working tree for
+//! `git diff HEAD`, or the second revision of a range).
+//!
+//! A changed line belongs to the innermost callable enclosing it; lines outside
+//! any callable belong to the innermost enclosing type or data declaration.
+//! Changing a concrete method also impacts callers of the interface methods it
+//! implements (see [`MethodSets`]).
+
+use std::collections::{BTreeSet, HashMap, VecDeque};
+
+use serde::Serialize;
+
+use super::dead_code::is_test_code;
+use super::implementers::MethodSets;
+use crate::graph::{EdgeType, Node, NodeType, PetCodeGraph};
+
+/// Lines touched by a diff in one file.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize)]
+pub struct FileChange {
+    /// File path relative to the repository root
+    pub file: String,
+    /// Changed lines on the new side (1-indexed), sorted
+    pub lines: BTreeSet<usize>,
+    /// Whether the file was deleted
+    pub deleted: bool,
+}
+
+/// Parse a unified diff (as produced by `git diff`) into per-file changes.
+///
+/// Added lines are recorded at their new position. Removed lines are recorded
+/// at the line preceding the removal, which belongs to the same enclosing
+/// symbol in the common case. Context lines are ignored.
+pub fn parse_unified_diff(patch: &str) -> Vec<FileChange> {
+    let mut changes: Vec<FileChange> = Vec::new();
+    let mut old_path: Option<String> = None;
+    let mut hunk = Hunk::default();
+
+    for text in patch.lines() {
+        if hunk.is_done() {
+            if let Some(path) = text.strip_prefix("--- ") {
+                old_path = diff_path(path, "a/");
+            } else if let Some(path) = text.strip_prefix("+++ ") {
+                let (file, deleted) = match diff_path(path, "b/") {
+                    Some(file) => (file, false),
+                    None => (old_path.take().unwrap_or_default(), true),
+                };
+                changes.push(FileChange {
+                    file,
+                    lines: BTreeSet::new(),
+                    deleted,
+                });
+            } else if text.starts_with("@@") {
+                hunk = Hunk::parse(text).unwrap_or_default();
+            }
+            continue;
+        }
+
+        let Some(current) = changes.last_mut() else {
+            continue;
+        };
+        match text.as_bytes().first() {
+            Some(b'+') => {
+                current.lines.insert(hunk.line);
+                hunk.line += 1;
+                hunk.new_remaining = hunk.new_remaining.saturating_sub(1);
+            }
+            Some(b'-') => {
+                if !current.deleted {
+                    current.lines.insert(hunk.line.saturating_sub(1).max(1));
+                }
+                hunk.old_remaining = hunk.old_remaining.saturating_sub(1);
+            }
+            Some(b' ') => {
+                hunk.line += 1;
+                hunk.old_remaining = hunk.old_remaining.saturating_sub(1);
+                hunk.new_remaining = hunk.new_remaining.saturating_sub(1);
+            }
+            _ => {}
+        }
+    }
+
+    changes.retain(|c| !c.file.is_empty());
+    changes
+}
+
+/// Position within the body of a diff hunk
+#[derive(Debug, Default)]
+struct Hunk {
+    /// Next line number on the new side
+    line: usize,
+    /// Old-side lines not yet consumed
+    old_remaining: usize,
+    /// New-side lines not yet consumed
+    new_remaining: usize,
+}
+
+impl Hunk {
+    /// Parse a hunk header (`@@ -a,b +c,d @@`); omitted counts default to 1
+    fn parse(header: &str) -> Option<Self> {
+        let mut ranges = header.split_whitespace().skip(1).take(2);
+        let (_, old_count) = parse_range(ranges.next()?.strip_prefix('-')?)?;
+        let (line, new_count) = parse_range(ranges.next()?.strip_prefix('+')?)?;
+        Some(Self {
+            line,
+            old_remaining: old_count,
+            new_remaining: new_count,
+        })
+    }
+
+    /// Check whether every line of the hunk has been consumed
+    fn is_done(&self) -> bool {
+        self.old_remaining == 0 && self.new_remaining == 0
+    }
+}
+
+/// Parse `start[,count]`
+fn parse_range(range: &str) -> Option<(usize, usize)> {
+    match range.split_once(',') {
+        Some((start, count)) => Some((start.parse().ok()?, count.parse().ok()?)),
+        None => Some((range.parse().ok()?, 1)),
+    }
+}
+
+/// Strip the `a/` or `b/` prefix from a diff header path; `None` for /dev/null
+fn diff_path(path: &str, prefix: &str) -> Option<String> {
+    // Headers may carry a tab-separated timestamp
+    let path = path.split('\t').next().unwrap_or(path).trim_end();
+    if path == "/dev/null" {
+        return None;
+    }
+    Some(path.strip_prefix(prefix).unwrap_or(path).to_string())
+}
+
+/// Options for impact analysis.
+#[derive(Debug, Clone, Default)]
+pub struct ImpactOptions {
+    /// Maximum number of reverse hops from a changed symbol (`None` = unlimited)
+    pub max_depth: Option<usize>,
+}
+
+/// A symbol that changed or depends on a change.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize)]
+pub struct ImpactedSymbol {
+    /// Node ID
+    pub id: String,
+    /// Entity name
+    pub name: String,
+    /// Node type
+    pub node_type: NodeType,
+    /// Kind within the node type
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub kind: Option<String>,
+    /// File path relative to the repository root
+    pub file: String,
+    /// Start line (1-indexed)
+    pub line: usize,
+    /// Reverse hops from the nearest changed symbol (0 = changed directly)
+    pub distance: usize,
+    /// Whether the symbol is test code
+    pub is_test: bool,
+    /// Kind of entry point, if the symbol is one
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub entry_point: Option<String>,
+}
+
+/// Result of an impact analysis.
+#[derive(Debug, Clone, Default, Serialize)]
+pub struct ImpactReport {
+    /// Files touched by the diff
+    pub changed_files: Vec<String>,
+    /// Files deleted by the diff (their symbols are no longer in the graph)
+    pub deleted_files: Vec<String>,
+    /// Symbols whose lines were touched, sorted by file and line
+    pub changed: Vec<ImpactedSymbol>,
+    /// Symbols depending on a changed symbol, sorted by distance, file, and line
+    pub impacted: Vec<ImpactedSymbol>,
+    /// Test symbols among the changed and impacted ones
+    pub tests: Vec<ImpactedSymbol>,
+    /// Files containing those tests, sorted
+    pub test_files: Vec<String>,
+    /// Entry points among the changed and impacted symbols
+    pub entry_points: Vec<ImpactedSymbol>,
+}
+
+/// Compute the symbols changed by a diff and everything that depends on them.
+pub fn analyze_impact(
+    graph: &PetCodeGraph,
+    changes: &[FileChange],
+    options: &ImpactOptions,
+) -> ImpactReport {
+    let mut report = ImpactReport::default();
+    for change in changes {
+        if change.deleted {
+            report.deleted_files.push(change.file.clone());
+        } else {
+            report.changed_files.push(change.file.clone());
+        }
+    }
+
+    let mut by_file: HashMap<&str, Vec<&Node>> = HashMap::new();
+    for node in graph.iter_nodes().filter(|n| is_symbol(n)) {
+        by_file.entry(node.file.as_str()).or_default().push(node);
+    }
+
+    // Distance from the nearest changed symbol
+    let mut distance: HashMap<&str, usize> = HashMap::new();
+    let mut queue: VecDeque<&str> = VecDeque::new();
+    for change in changes.iter().filter(|c| !c.deleted) {
+        let Some(nodes) = by_file.get(change.file.as_str()) else {
+            continue;
+        };
+        for &line in &change.lines {
+            for node in enclosing_symbols(nodes, line) {
+                if distance.insert(node.id.as_str(), 0).is_none() {
+                    queue.push_back(node.id.as_str());
+                }
+            }
+        }
+    }
+
+    let sets = MethodSets::build(graph);
+    let owners = sets.method_owners();
+
+    while let Some(id) = queue.pop_front() {
+        let depth = distance[id];
+        if options.max_depth.is_some_and(|max| depth >= max) {
+            continue;
+        }
+
+        let mut dependents: Vec<&str> = graph
+            .incoming_edges(id)
+            .filter(|(_, edge)| matches!(edge.edge_type, EdgeType::Uses | EdgeType::CrossLanguage))
+            .map(|(source, _)| source.id.as_str())
+            .collect();
+
+        // Callers through an interface depend on every implementation
+        dependents.extend(interface_callers(graph, &sets, &owners, id));
+
+        for source in dependents {
+            if !distance.contains_key(source) {
+                distance.insert(source, depth + 1);
+                queue.push_back(source);
+            }
+        }
+    }
+
+    let mut symbols: Vec<ImpactedSymbol> = distance
+        .iter()
+        .filter_map(|(id, &d)| graph.get_node(id).map(|n| impacted_symbol(n, d)))
+        .collect();
+    symbols.sort_by(|a, b| {
+        (a.distance, &a.file, a.line, &a.id).cmp(&(b.distance, &b.file, b.line, &b.id))
+    });
+
+    report.tests = symbols.iter().filter(|s| s.is_test).cloned().collect();
+    report.test_files = report
+        .tests
+        .iter()
+        .map(|s| s.file.clone())
+        .collect::<BTreeSet<_>>()
+        .into_iter()
+        .collect();
+    report.entry_points = symbols
+        .iter()
+        .filter(|s| s.entry_point.is_some())
+        .cloned()
+        .collect();
+    let (changed, impacted): (Vec<_>, Vec<_>) = symbols.into_iter().partition(|s| s.distance == 0);
+    report.changed = changed;
+    report.impacted = impacted;
+    report
+}
+
+/// Symbols a changed line belongs to: the innermost enclosing callables, or
+/// failing that the innermost enclosing types and data declarations
+fn enclosing_symbols<'a>(nodes: &[&'a Node], line: usize) -> Vec<&'a Node> {
+    let enclosing: Vec<&Node> = nodes
+        .iter()
+        .copied()
+        .filter(|n| n.line <= line && line <= n.end_line)
+        .collect();
+
+    let callables: Vec<&Node> = enclosing
+        .iter()
+        .copied()
+        .filter(|n| n.node_type == NodeType::Callable)
+        .collect();
+    let candidates = if callables.is_empty() {
+        enclosing
+    } else {
+        callables
+    };
+
+    // Keep only the innermost: drop any candidate whose span contains another
+    candidates
+        .iter()
+        .copied()
+        .filter(|n| {
+            !candidates.iter().any(|other| {
+                other.id != n.id
+                    && n.line <= other.line
+                    && other.end_line <= n.end_line
+                    && (other.end_line - other.line) < (n.end_line - n.line)
+            })
+        })
+        .collect()
+}
+
+/// Interface method declarations implemented by a concrete method, mapped to
+/// their callers
+fn interface_callers<'a>(
+    graph: &'a PetCodeGraph,
+    sets: &MethodSets,
+    owners: &HashMap<&str, Vec<&str>>,
+    method_id: &str,
+) -> Vec<&'a str> {
+    let Some(method) = graph.get_node(method_id) else {
+        return Vec::new();
+    };
+    let mut types: Vec<&str> = owners.get(method_id).cloned().unwrap_or_default();
+    if let Some(parent) = graph.parent(method_id) {
+        if sets.is_type(&parent.id) {
+            types.push(parent.id.as_str());
+        }
+    }
+
+    let mut callers = Vec::new();
+    for type_id in types {
+        for interface in sets.interfaces_of(type_id) {
+            for declaration in graph.children(&interface) {
+                if declaration.name != method.name {
+                    continue;
+                }
+                callers.extend(
+                    graph
+                        .incoming_edges(&declaration.id)
+                        .filter(|(_, edge)| edge.edge_type == EdgeType::Uses)
+                        .map(|(source, _)| source.id.as_str()),
+                );
+            }
+        }
+    }
+    callers
+}
+
+/// Source-level symbols (not files or higher-level containers)
+fn is_symbol(node: &Node) -> bool {
+    !node.file.is_empty()
+        && match node.node_type {
+            NodeType::Callable | NodeType::Data => true,
+            NodeType::Container => !matches!(
+                node.kind.as_deref(),
+                Some("workspace" | "repository" | "component" | "file")
+            ),
+        }
+}
+
+fn impacted_symbol(node: &Node, distance: usize) -> ImpactedSymbol {
+    ImpactedSymbol {
+        id: node.id.clone(),
+        name: node.name.clone(),
+        node_type: node.node_type,
+        kind: node.kind.clone(),
+        file: node.file.clone(),
+        line: node.line,
+        distance,
+        is_test: is_test_code(node),
+        entry_point: node.metadata.entry_point.clone(),
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::{ContainerKind, EdgeData};
+    use crate::test_support::function;
+
+    const PATCH: &str = "\
+diff --git a/api/h.go b/api/h.go
+index 1111111..2222222 100644
+--- a/api/h.go
++++ b/api/h.go
+@@ -12,3 +12,4 @@ func parse() {
+ \tx := 1
+-\ty := 2
++\ty := 3
++\tz := 4
+ \treturn
+diff --git a/old.go b/old.go
+deleted file mode 100644
+--- a/old.go
++++ /dev/null
+@@ -1,2 +0,0 @@
+-package main
+-func gone() {}
+";
+
+    #[test]
+    fn test_parse_unified_diff() {
+        let changes = parse_unified_diff(PATCH);
+        assert_eq!(changes.len(), 2);
+
+        assert_eq!(changes[0].file, "api/h.go");
+        assert!(!changes[0].deleted);
+        let lines: Vec<usize> = changes[0].lines.iter().copied().collect();
+        assert_eq!(lines, vec![12, 13, 14]);
+
+        assert_eq!(changes[1].file, "old.go");
+        assert!(changes[1].deleted);
+        assert!(changes[1].lines.is_empty());
+    }
+
+    fn add_fn(graph: &mut PetCodeGraph, id: &str, file: &str, line: usize, end_line: usize) {
+        let mut node = function(id);
+        node.file = file.to_string();
+        node.line = line;
+        node.end_line = end_line;
+        graph.add_node(node);
+    }
+
+    /// parse <- serve <- TestServe; main -> serve; unrelated is untouched
+    fn sample_graph() -> PetCodeGraph {
+        let mut graph = PetCodeGraph::new();
+        graph.add_node(Node::container(
+            "api/h.go:Config".to_string(),
+            "Config".to_string(),
+            ContainerKind::Type,
+            Some("struct".to_string()),
+            "api/h.go".to_string(),
+            1,
+            5,
+        ));
+        add_fn(&mut graph, "api/h.go:parse", "api/h.go", 10, 16);
+        add_fn(&mut graph, "api/h.go:serve", "api/h.go", 20, 30);
+        add_fn(&mut graph, "api/h.go:unrelated", "api/h.go", 40, 45);
+        add_fn(&mut graph, "cmd/main.go:main", "cmd/main.go", 3, 8);
+        add_fn(
+            &mut graph,
+            "api/h_test.go:TestServe",
+            "api/h_test.go",
+            5,
+            12,
+        );
+        graph
+            .get_node_mut("cmd/main.go:main")
+            .unwrap()
+            .metadata
+            .entry_point = Some("main".to_string());
+        for (source, target) in [
+            ("api/h.go:serve", "api/h.go:parse"),
+            ("cmd/main.go:main", "api/h.go:serve"),
+            ("api/h_test.go:TestServe", "api/h.go:serve"),
+        ] {
+            graph.add_edge(source, target, EdgeData::uses(Some(1), None));
+        }
+        graph
+    }
+
+    #[test]
+    fn test_analyze_impact() {
+        let graph = sample_graph();
+        let changes = parse_unified_diff(PATCH);
+        let report = analyze_impact(&graph, &changes, &ImpactOptions::default());
+
+        assert_eq!(report.changed_files, vec!["api/h.go"]);
+        assert_eq!(report.deleted_files, vec!["old.go"]);
+
+        let changed: Vec<&str> = report.changed.iter().map(|s| s.id.as_str()).collect();
+        assert_eq!(changed, vec!["api/h.go:parse"]);
+
+        let impacted: Vec<(&str, usize)> = report
+            .impacted
+            .iter()
+            .map(|s| (s.id.as_str(), s.distance))
+            .collect();
+        assert_eq!(
+            impacted,
+            vec![
+                ("api/h.go:serve", 1),
+                ("api/h_test.go:TestServe", 2),
+                ("cmd/main.go:main", 2),
+            ]
+        );
+
+        assert_eq!(report.tests.len(), 1);
+        assert_eq!(report.test_files, vec!["api/h_test.go"]);
+
+        assert_eq!(report.entry_points.len(), 1);
+        assert_eq!(report.entry_points[0].id, "cmd/main.go:main");
+        assert_eq!(report.entry_points[0].entry_point.as_deref(), Some("main"));
+    }
+
+    #[test]
+    fn test_impact_depth_and_type_changes() {
+        let graph = sample_graph();
+        let mut lines = BTreeSet::new();
+        lines.insert(3);
+        lines.insert(12);
+        let changes = vec![FileChange {
+            file: "api/h.go".to_string(),
+            lines,
+            deleted: false,
+        }];
+
+        let report = analyze_impact(&graph, &changes, &ImpactOptions { max_depth: Some(1) });
+        let changed: Vec<&str> = report.changed.iter().map(|s| s.id.as_str()).collect();
+        assert_eq!(changed, vec!["api/h.go:Config", "api/h.go:parse"]);
+        let impacted: Vec<&str> = report.impacted.iter().map(|s| s.id.as_str()).collect();
+        assert_eq!(impacted, vec!["api/h.go:serve"]);
+        assert!(report.tests.is_empty());
+    }
+}
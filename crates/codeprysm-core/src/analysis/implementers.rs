@@ -0,0 +1,386 @@
+//! Interface Implementers
+//!
+//! Matches concrete types to interfaces by method set, the way Go's structural
+//! typing does: a type implements an interface when it declares every method
+//! the interface requires. The same matching is applied to interfaces and
+//! traits in other languages, where it approximates explicit `implements`
+//! clauses that are not captured in the graph.
+//!
+//! Method sets are matched by name only; signatures are not compared. Methods
+//! promoted through embedded interfaces or structs are not followed.
+
+use std::collections::{BTreeMap, BTreeSet, HashMap};
+
+use super::api_surface::is_type;
+use super::package_of;
+use crate::graph::{Node, NodeType, PetCodeGraph};
+
+/// Subtypes of `Container/type` nodes that declare a method set rather than
+/// implement one.
+const INTERFACE_SUBTYPES: &[&str] = &["interface", "trait"];
+
+/// Method-set index over every type in a graph.
+#[derive(Debug, Default)]
+pub struct MethodSets {
+    /// Interface node ID -> required method names
+    interfaces: HashMap<String, BTreeSet<String>>,
+    /// Concrete type node ID -> declared method name -> method node IDs
+    types: HashMap<String, BTreeMap<String, Vec<String>>>,
+}
+
+impl MethodSets {
+    /// Build method sets for all interfaces and concrete types in the graph.
+    ///
+    /// A type's methods are its Callable children plus, for Go, methods whose
+    /// receiver names the type within the same package (directory).
+    pub fn build(graph: &PetCodeGraph) -> Self {
+        let mut sets = Self::default();
+
+        for node in graph.iter_nodes().filter(|n| is_type(n)) {
+            let children = graph
+                .children(&node.id)
+                .filter(|child| child.node_type == NodeType::Callable);
+
+            if is_interface(node) {
+                let required = children.map(|child| child.name.clone()).collect();
+                sets.interfaces.insert(node.id.clone(), required);
+            } else {
+                let mut methods: BTreeMap<String, Vec<String>> = BTreeMap::new();
+                for child in children {
+                    methods
+                        .entry(child.name.clone())
+                        .or_default()
+                        .push(child.id.clone());
+                }
+                sets.types.insert(node.id.clone(), methods);
+            }
+        }
+
+        // Go methods are declared at file level and linked to their type by receiver
+        let mut type_ids: HashMap<(&str, &str), Vec<&str>> = HashMap::new();
+        for node in graph
+            .iter_nodes()
+            .filter(|n| is_type(n) && !is_interface(n))
+        {
+            type_ids
+                .entry((package_of(&node.file), node.name.as_str()))
+                .or_default()
+                .push(node.id.as_str());
+        }
+
+        for method in graph.iter_nodes() {
+            let Some(receiver) = method.metadata.receiver.as_deref() else {
+                continue;
+            };
+            let Some(ids) = type_ids.get(&(package_of(&method.file), receiver)) else {
+                continue;
+            };
+            for id in ids {
+                if let Some(methods) = sets.types.get_mut(*id) {
+                    methods
+                        .entry(method.name.clone())
+                        .or_default()
+                        .push(method.id.clone());
+                }
+            }
+        }
+
+        sets
+    }
+
+    /// Check whether a node ID refers to an interface (or trait).
+    pub fn is_interface(&self, id: &str) -> bool {
+        self.interfaces.contains_key(id)
+    }
+
+    /// Check whether a node ID refers to a concrete type.
+    pub fn is_type(&self, id: &str) -> bool {
+        self.types.contains_key(id)
+    }
+
+    /// Methods required by an interface, sorted by name.
+    pub fn interface_methods(&self, interface_id: &str) -> Option<&BTreeSet<String>> {
+        self.interfaces.get(interface_id)
+    }
+
+    /// Method node IDs declared by a concrete type under the given name.
+    pub fn method_ids(&self, type_id: &str, name: &str) -> &[String] {
+        self.types
+            .get(type_id)
+            .and_then(|methods| methods.get(name))
+            .map(Vec::as_slice)
+            .unwrap_or_default()
+    }
+
+    /// Map each method node ID to the concrete types that declare it.
+    ///
+    /// Go methods are linked to their type by receiver rather than by a
+    /// CONTAINS edge, so this is the only way to walk from a method to its type.
+    pub fn method_owners(&self) -> HashMap<&str, Vec<&str>> {
+        let mut owners: HashMap<&str, Vec<&str>> = HashMap::new();
+        for (type_id, methods) in &self.types {
+            for method_id in methods.values().flatten() {
+                owners
+                    .entry(method_id.as_str())
+                    .or_default()
+                    .push(type_id.as_str());
+            }
+        }
+        owners
+    }
+
+    /// Concrete types whose method set covers the interface's, sorted by ID.
+    ///
+    /// Interfaces with no methods are not matched, since every type would
+    /// trivially satisfy them.
+    pub fn implementers(&self, interface_id: &str) -> Vec<String> {
+        let Some(required) = self.interfaces.get(interface_id) else {
+            return Vec::new();
+        };
+        if required.is_empty() {
+            return Vec::new();
+        }
+
+        let mut ids: Vec<String> = self
+            .types
+            .iter()
+            .filter(|(_, methods)| covers(methods, required))
+            .map(|(id, _)| id.clone())
+            .collect();
+        ids.sort();
+        ids
+    }
+
+    /// Interfaces whose method set is covered by the type's, sorted by ID.
+    pub fn interfaces_of(&self, type_id: &str) -> Vec<String> {
+        let Some(methods) = self.types.get(type_id) else {
+            return Vec::new();
+        };
+
+        let mut ids: Vec<String> = self
+            .interfaces
+            .iter()
+            .filter(|(_, required)| !required.is_empty() && covers(methods, required))
+            .map(|(id, _)| id.clone())
+            .collect();
+        ids.sort();
+        ids
+    }
+}
+
+/// Check if a type's methods include every required method name
+fn covers(methods: &BTreeMap<String, Vec<String>>, required: &BTreeSet<String>) -> bool {
+    required.iter().all(|name| methods.contains_key(name))
+}
+
+/// Check if a type node declares an interface or trait
+pub(crate) fn is_interface(node: &Node) -> bool {
+    node.subtype
+        .as_deref()
+        .is_some_and(|s| INTERFACE_SUBTYPES.contains(&s))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::{CallableKind, ContainerKind, Edge};
+
+    fn add_type(graph: &mut PetCodeGraph, id: &str, name: &str, subtype: &str, file: &str) {
+        graph.add_node(Node::container(
+            id.to_string(),
+            name.to_string(),
+            ContainerKind::Type,
+            Some(subtype.to_string()),
+            file.to_string(),
+            1,
+            10,
+        ));
+    }
+
+    fn add_method(
+        graph: &mut PetCodeGraph,
+        id: &str,
+        name: &str,
+        file: &str,
+        parent: Option<&str>,
+        receiver: Option<&str>,
+    ) {
+        let mut node = Node::callable(
+            id.to_string(),
+            name.to_string(),
+            CallableKind::Method,
+            file.to_string(),
+            1,
+            2,
+        );
+        node.metadata.receiver = receiver.map(String::from);
+        graph.add_node(node);
+        if let Some(parent) = parent {
+            graph.add_edge_from_struct(&Edge::contains(parent.to_string(), id.to_string()));
+        }
+    }
+
+    /// io/io.go declares Reader and ReadCloser; store/file.go has File with
+    /// Read+Close; store/buf.go has Buffer with only Read; other/file.go has a
+    /// same-named File whose methods must not leak across packages.
+    fn sample_graph() -> PetCodeGraph {
+        let mut graph = PetCodeGraph::new();
+
+        add_type(
+            &mut graph,
+            "io/io.go:Reader",
+            "Reader",
+            "interface",
+            "io/io.go",
+        );
+        add_method(
+            &mut graph,
+            "io/io.go:Reader:Read",
+            "Read",
+            "io/io.go",
+            Some("io/io.go:Reader"),
+            None,
+        );
+        add_type(
+            &mut graph,
+            "io/io.go:ReadCloser",
+            "ReadCloser",
+            "interface",
+            "io/io.go",
+        );
+        add_method(
+            &mut graph,
+            "io/io.go:ReadCloser:Read",
+            "Read",
+            "io/io.go",
+            Some("io/io.go:ReadCloser"),
+            None,
+        );
+        add_method(
+            &mut graph,
+            "io/io.go:ReadCloser:Close",
+            "Close",
+            "io/io.go",
+            Some("io/io.go:ReadCloser"),
+            None,
+        );
+        add_type(&mut graph, "io/io.go:Any", "Any", "interface", "io/io.go");
+
+        add_type(
+            &mut graph,
+            "store/file.go:File",
+            "File",
+            "struct",
+            "store/file.go",
+        );
+        add_method(
+            &mut graph,
+            "store/file_io.go:Read",
+            "Read",
+            "store/file_io.go",
+            None,
+            Some("File"),
+        );
+        add_method(
+            &mut graph,
+            "store/file_io.go:Close",
+            "Close",
+            "store/file_io.go",
+            None,
+            Some("File"),
+        );
+
+        add_type(
+            &mut graph,
+            "store/buf.go:Buffer",
+            "Buffer",
+            "struct",
+            "store/buf.go",
+        );
+        add_method(
+            &mut graph,
+            "store/buf.go:Read",
+            "Read",
+            "store/buf.go",
+            None,
+            Some("Buffer"),
+        );
+
+        add_type(
+            &mut graph,
+            "other/file.go:File",
+            "File",
+            "struct",
+            "other/file.go",
+        );
+
+        graph
+    }
+
+    #[test]
+    fn test_implementers_matches_method_sets() {
+        let sets = MethodSets::build(&sample_graph());
+
+        assert_eq!(
+            sets.implementers("io/io.go:Reader"),
+            vec!["store/buf.go:Buffer", "store/file.go:File"]
+        );
+        assert_eq!(
+            sets.implementers("io/io.go:ReadCloser"),
+            vec!["store/file.go:File"]
+        );
+
+        // Empty interfaces and non-interfaces match nothing
+        assert!(sets.implementers("io/io.go:Any").is_empty());
+        assert!(sets.implementers("store/file.go:File").is_empty());
+    }
+
+    #[test]
+    fn test_interfaces_of_respects_package_boundary() {
+        let sets = MethodSets::build(&sample_graph());
+
+        assert_eq!(
+            sets.interfaces_of("store/file.go:File"),
+            vec!["io/io.go:ReadCloser", "io/io.go:Reader"]
+        );
+        assert_eq!(
+            sets.interfaces_of("store/buf.go:Buffer"),
+            vec!["io/io.go:Reader"]
+        );
+        assert!(sets.interfaces_of("other/file.go:File").is_empty());
+
+        assert!(sets.is_interface("io/io.go:Reader"));
+        assert!(sets.is_type("store/file.go:File"));
+        assert_eq!(
+            sets.method_ids("store/file.go:File", "Close"),
+            ["store/file_io.go:Close".to_string()]
+        );
+        assert!(sets.method_ids("other/file.go:File", "Close").is_empty());
+    }
+
+    #[test]
+    fn test_contained_methods_count_toward_method_set() {
+        let mut graph = PetCodeGraph::new();
+        add_type(&mut graph, "lib.rs:Shape", "Shape", "trait", "lib.rs");
+        add_method(
+            &mut graph,
+            "lib.rs:Shape:area",
+            "area",
+            "lib.rs",
+            Some("lib.rs:Shape"),
+            None,
+        );
+        add_type(&mut graph, "lib.rs:Circle", "Circle", "struct", "lib.rs");
+        add_method(
+            &mut graph,
+            "lib.rs:Circle:area",
+            "area",
+            "lib.rs",
+            Some("lib.rs:Circle"),
+            None,
+        );
+
+        let sets = MethodSets::build(&graph);
+        assert_eq!(sets.implementers("lib.rs:Shape"), vec!["lib.rs:Circle"]);
+    }
+}
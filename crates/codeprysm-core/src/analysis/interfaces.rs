@@ -0,0 +1,662 @@
+//! Duplicate Interfaces
+//!
+//! Finds Go interfaces in different packages that declare the same method
+//! set, or one whose methods are a strict subset of another's. Packages that
+//! each declare their own `Store` or `Getter` interface for the same
+//! behavior are a common Go smell; the duplicates can usually be replaced by
+//! one shared interface (or, for subsets, by embedding the smaller one).
+//!
+//! Methods are compared by signature: name, parameter types, and result
+//! types, with parameter names dropped and `interface{}` read as `any`.
+//! Types are compared as written, so `User` and `model.User` differ.
+//! Interfaces embedding other interfaces or declaring type constraints are
+//! skipped, since their method sets are not fully written out. Anonymous
+//! interface types and test files are skipped as well.
+//!
+//! Overlaps are stored in the graph as `EQUIVALENT_TO` edges (lower node ID
+//! to higher) and `SUBSET_OF` edges (smaller interface to larger), see
+//! [`link_interface_overlaps`]. [`consolidate`] groups equivalent interfaces
+//! and suggests which one to keep.
+
+use std::collections::{BTreeMap, BTreeSet, HashMap};
+
+use serde::{Deserialize, Serialize};
+
+use super::package_of;
+use crate::file_policy::is_test_file;
+use crate::graph::{ContainerKind, EdgeData, EdgeType, Node, NodeType, PetCodeGraph};
+
+/// SARIF rule ID for duplicate interface findings
+pub const DUPLICATE_INTERFACE_RULE: &str = "duplicate-interface";
+
+/// Options for duplicate interface detection.
+#[derive(Debug, Clone, Copy)]
+pub struct InterfaceOptions {
+    /// Minimum number of methods of the smaller interface; one-method
+    /// interfaces like `Close() error` overlap by design
+    pub min_methods: usize,
+}
+
+impl Default for InterfaceOptions {
+    fn default() -> Self {
+        Self { min_methods: 2 }
+    }
+}
+
+/// How two interfaces' method sets relate.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, PartialOrd, Ord, Hash, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum OverlapKind {
+    /// Both declare the same methods
+    Equivalent,
+    /// The first declares a strict subset of the second's methods
+    Subset,
+}
+
+/// An interface with its method signatures.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct InterfaceInstance {
+    /// Node ID
+    pub id: String,
+    /// Interface name
+    pub name: String,
+    /// Package (containing directory)
+    pub package: String,
+    /// File path
+    pub file: String,
+    /// Start line
+    pub line: usize,
+    /// End line
+    pub end_line: usize,
+    /// Normalized method signatures, sorted
+    pub methods: Vec<String>,
+    /// Number of USES edges into the interface
+    pub uses: usize,
+}
+
+/// Two interfaces in different packages with overlapping method sets.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct InterfaceOverlap {
+    /// How the method sets relate
+    pub kind: OverlapKind,
+    /// The interface with the lower node ID (equivalent) or the smaller
+    /// method set (subset)
+    pub first: InterfaceInstance,
+    /// The other interface
+    pub second: InterfaceInstance,
+    /// Methods of the second interface the first lacks (empty if equivalent)
+    pub extra_methods: Vec<String>,
+}
+
+/// Equivalent interfaces that could be consolidated into one.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct ConsolidationGroup {
+    /// Suggested interface to keep: the most used one, then the lowest ID
+    pub canonical: InterfaceInstance,
+    /// Interfaces that could be replaced by the canonical one, sorted by ID
+    pub duplicates: Vec<InterfaceInstance>,
+    /// Shared method signatures
+    pub methods: Vec<String>,
+}
+
+/// Find Go interfaces in different packages with equivalent or subset
+/// method sets.
+///
+/// `read_source` returns the contents of a graph file (by its graph path),
+/// from which method signatures are read. Overlaps are sorted by kind, then
+/// by the IDs of both interfaces.
+pub fn find_interface_overlaps<F>(
+    graph: &PetCodeGraph,
+    options: &InterfaceOptions,
+    read_source: F,
+) -> Vec<InterfaceOverlap>
+where
+    F: FnMut(&str) -> Option<String>,
+{
+    let interfaces = collect_interfaces(graph, read_source);
+
+    // Interfaces by method signature, to find the supersets of each one
+    let mut postings: HashMap<&str, Vec<usize>> = HashMap::new();
+    for (index, interface) in interfaces.iter().enumerate() {
+        for method in &interface.methods {
+            postings.entry(method.as_str()).or_default().push(index);
+        }
+    }
+
+    let mut overlaps = Vec::new();
+    for smaller in &interfaces {
+        if smaller.methods.len() < options.min_methods.max(1) {
+            continue;
+        }
+        // A superset contains every method, so its rarest one suffices
+        let Some(candidates) = smaller
+            .methods
+            .iter()
+            .filter_map(|method| postings.get(method.as_str()))
+            .min_by_key(|candidates| candidates.len())
+        else {
+            continue;
+        };
+        let methods: BTreeSet<&str> = smaller.methods.iter().map(String::as_str).collect();
+        for larger in candidates.iter().map(|&index| &interfaces[index]) {
+            if larger.package == smaller.package
+                || larger.methods.len() < smaller.methods.len()
+                || !methods.iter().all(|method| {
+                    larger
+                        .methods
+                        .binary_search_by(|m| m.as_str().cmp(method))
+                        .is_ok()
+                })
+            {
+                continue;
+            }
+            let kind = if larger.methods.len() == smaller.methods.len() {
+                if larger.id < smaller.id {
+                    continue;
+                }
+                OverlapKind::Equivalent
+            } else {
+                OverlapKind::Subset
+            };
+            let extra_methods = larger
+                .methods
+                .iter()
+                .filter(|method| !methods.contains(method.as_str()))
+                .cloned()
+                .collect();
+            overlaps.push(InterfaceOverlap {
+                kind,
+                first: smaller.clone(),
+                second: larger.clone(),
+                extra_methods,
+            });
+        }
+    }
+
+    overlaps.sort_by(|a, b| {
+        (a.kind, &a.first.id, &a.second.id).cmp(&(b.kind, &b.first.id, &b.second.id))
+    });
+    overlaps
+}
+
+/// Replace a graph's `EQUIVALENT_TO` and `SUBSET_OF` edges with the
+/// overlaps found with `options`.
+///
+/// Returns the number of overlaps linked.
+pub fn link_interface_overlaps<F>(
+    graph: &mut PetCodeGraph,
+    options: &InterfaceOptions,
+    read_source: F,
+) -> usize
+where
+    F: FnMut(&str) -> Option<String>,
+{
+    graph.remove_edges_by_type(EdgeType::EquivalentTo);
+    graph.remove_edges_by_type(EdgeType::SubsetOf);
+
+    let overlaps = find_interface_overlaps(graph, options, read_source);
+    for overlap in &overlaps {
+        let edge = match overlap.kind {
+            OverlapKind::Equivalent => EdgeData::equivalent_to(),
+            OverlapKind::Subset => EdgeData::subset_of(),
+        };
+        graph.add_edge(&overlap.first.id, &overlap.second.id, edge);
+    }
+    overlaps.len()
+}
+
+/// Group equivalent interfaces and pick the one to keep from each group.
+///
+/// Groups are sorted by the ID of their canonical interface.
+pub fn consolidate(overlaps: &[InterfaceOverlap]) -> Vec<ConsolidationGroup> {
+    let mut groups: BTreeMap<&[String], BTreeMap<&str, &InterfaceInstance>> = BTreeMap::new();
+    for overlap in overlaps {
+        if overlap.kind != OverlapKind::Equivalent {
+            continue;
+        }
+        let members = groups.entry(overlap.first.methods.as_slice()).or_default();
+        for interface in [&overlap.first, &overlap.second] {
+            members.insert(&interface.id, interface);
+        }
+    }
+
+    let mut groups: Vec<ConsolidationGroup> = groups
+        .into_iter()
+        .filter_map(|(methods, members)| {
+            let mut members: Vec<InterfaceInstance> = members.into_values().cloned().collect();
+            // Most used first; members are already sorted by ID
+            members.sort_by(|a, b| b.uses.cmp(&a.uses));
+            let mut members = members.into_iter();
+            let canonical = members.next()?;
+            let mut duplicates: Vec<InterfaceInstance> = members.collect();
+            duplicates.sort_by(|a, b| a.id.cmp(&b.id));
+            Some(ConsolidationGroup {
+                canonical,
+                duplicates,
+                methods: methods.to_vec(),
+            })
+        })
+        .collect();
+    groups.sort_by(|a, b| a.canonical.id.cmp(&b.canonical.id));
+    groups
+}
+
+/// Named Go interfaces whose method signatures could all be read
+fn collect_interfaces<F>(graph: &PetCodeGraph, mut read_source: F) -> Vec<InterfaceInstance>
+where
+    F: FnMut(&str) -> Option<String>,
+{
+    let mut files: HashMap<String, Option<Vec<String>>> = HashMap::new();
+    let mut interfaces = Vec::new();
+
+    let mut candidates: Vec<&Node> = graph.iter_nodes().filter(|n| is_go_interface(n)).collect();
+    candidates.sort_by(|a, b| a.id.cmp(&b.id));
+    for node in candidates {
+        let lines = files
+            .entry(node.file.clone())
+            .or_insert_with(|| {
+                read_source(&node.file).map(|source| source.lines().map(str::to_string).collect())
+            })
+            .as_deref();
+        let Some(lines) = lines else {
+            continue;
+        };
+
+        let mut methods: Vec<&Node> = graph
+            .children(&node.id)
+            .filter(|child| child.node_type == NodeType::Callable)
+            .collect();
+        methods.sort_by_key(|method| method.line);
+        if methods.is_empty() || embeds_types(lines, node, &methods) {
+            continue;
+        }
+        let Some(mut signatures) = methods
+            .iter()
+            .map(|method| method_signature(lines, method))
+            .collect::<Option<Vec<String>>>()
+        else {
+            continue;
+        };
+        signatures.sort();
+        signatures.dedup();
+
+        interfaces.push(InterfaceInstance {
+            id: node.id.clone(),
+            name: node.name.clone(),
+            package: package_of(&node.file).to_string(),
+            file: node.file.clone(),
+            line: node.line,
+            end_line: node.end_line,
+            methods: signatures,
+            uses: graph
+                .incoming_edges(&node.id)
+                .filter(|(_, edge)| edge.edge_type == EdgeType::Uses)
+                .count(),
+        });
+    }
+    interfaces
+}
+
+/// Check if a node is a named Go interface outside test files
+fn is_go_interface(node: &Node) -> bool {
+    node.node_type == NodeType::Container
+        && node.kind.as_deref() == Some(ContainerKind::Type.as_str())
+        && node.subtype.as_deref() == Some("interface")
+        && node.file.ends_with(".go")
+        && !is_test_file(&node.file)
+        // Anonymous interface types are named `<field>.interface`
+        && !node.name.contains('.')
+}
+
+/// Check whether an interface body has lines outside its methods: embedded
+/// interfaces or type constraints
+fn embeds_types(lines: &[String], interface: &Node, methods: &[&Node]) -> bool {
+    (interface.line + 1..interface.end_line).any(|line| {
+        if methods
+            .iter()
+            .any(|method| method.line <= line && line <= method.end_line)
+        {
+            return false;
+        }
+        let text = lines
+            .get(line - 1)
+            .map_or("", |text| strip_comment(text).trim());
+        !(text.is_empty() || text.starts_with("/*") || text.starts_with('*'))
+    })
+}
+
+/// Read and normalize the signature of an interface method, e.g.
+/// `Get(ctx context.Context, id string) (*User, error)` becomes
+/// `Get(context.Context, string) (*User, error)`
+fn method_signature(lines: &[String], method: &Node) -> Option<String> {
+    let text: Vec<&str> = lines
+        .get(method.line.checked_sub(1)?..method.end_line.min(lines.len()))?
+        .iter()
+        .map(|line| strip_comment(line))
+        .collect();
+    let text = text.join(" ");
+    let start = text.find(&format!("{}(", method.name))?;
+    let rest = &text[start + method.name.len()..];
+
+    let params_end = closing_paren(rest)?;
+    let params = normalize_params(&rest[1..params_end]);
+    let rest = rest[params_end + 1..].trim_start();
+    let results = if rest.starts_with('(') {
+        let end = closing_paren(rest)?;
+        let results = normalize_params(&rest[1..end]);
+        if results.contains(',') {
+            format!("({})", results)
+        } else {
+            results
+        }
+    } else {
+        normalize_type(until_top_level(rest, &[';', '}']))
+    };
+
+    let mut signature = format!("{}({})", method.name, params);
+    if !results.is_empty() {
+        signature.push(' ');
+        signature.push_str(&results);
+    }
+    Some(signature)
+}
+
+/// Text of a line before a `//` comment
+fn strip_comment(line: &str) -> &str {
+    line.split_once("//").map_or(line, |(code, _)| code)
+}
+
+/// Index of the parenthesis closing the one `text` starts with
+fn closing_paren(text: &str) -> Option<usize> {
+    let mut depth = 0usize;
+    for (index, c) in text.char_indices() {
+        match c {
+            '(' | '[' | '{' => depth += 1,
+            ')' | ']' | '}' => {
+                depth = depth.checked_sub(1)?;
+                if depth == 0 {
+                    return (c == ')').then_some(index);
+                }
+            }
+            _ => {}
+        }
+    }
+    None
+}
+
+/// Prefix of `text` up to the first of `stops` outside brackets
+fn until_top_level<'a>(text: &'a str, stops: &[char]) -> &'a str {
+    let mut depth = 0usize;
+    for (index, c) in text.char_indices() {
+        match c {
+            '(' | '[' | '{' => depth += 1,
+            ')' | ']' | '}' if depth > 0 => depth -= 1,
+            c if depth == 0 && stops.contains(&c) => return &text[..index],
+            _ => {}
+        }
+    }
+    text
+}
+
+/// Split a list at commas outside brackets
+fn split_top_level(text: &str) -> Vec<&str> {
+    let mut parts = Vec::new();
+    let mut rest = text;
+    loop {
+        let part = until_top_level(rest, &[',']);
+        parts.push(part.trim());
+        match rest.get(part.len() + 1..) {
+            Some(next) => rest = next,
+            None => break,
+        }
+    }
+    parts.retain(|part| !part.is_empty());
+    parts
+}
+
+/// Parameter (or result) types of a Go parameter list, comma-separated.
+///
+/// Go lists either name every parameter or none, and a type applies to the
+/// names before it without one (`a, b int`).
+fn normalize_params(params: &str) -> String {
+    let parts: Vec<(Option<&str>, &str)> = split_top_level(params)
+        .into_iter()
+        .map(|part| match part.split_once(char::is_whitespace) {
+            Some((name, kind)) if is_param_name(name) => (Some(name), kind.trim()),
+            _ => (None, part),
+        })
+        .collect();
+
+    let named = parts.iter().any(|(name, _)| name.is_some());
+    let mut types: Vec<String> = Vec::with_capacity(parts.len());
+    let mut current = "";
+    for (name, kind) in parts.into_iter().rev() {
+        if named && name.is_none() {
+            // A bare name sharing the type of the parameters after it
+            types.push(normalize_type(current));
+        } else {
+            current = kind;
+            types.push(normalize_type(kind));
+        }
+    }
+    types.reverse();
+    types.join(", ")
+}
+
+/// Check if a word is a parameter name rather than the start of a type
+fn is_param_name(word: &str) -> bool {
+    !matches!(word, "chan" | "func" | "map" | "struct" | "interface")
+        && word.chars().all(|c| c.is_alphanumeric() || c == '_')
+}
+
+/// Collapse whitespace in a type and spell `interface{}` as `any`
+fn normalize_type(kind: &str) -> String {
+    kind.split_whitespace()
+        .collect::<Vec<_>>()
+        .join(" ")
+        .replace("interface{}", "any")
+        .replace("interface {}", "any")
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::{CallableKind, Edge};
+
+    /// Add the interfaces declared in a Go source file, with their methods
+    fn add_file(graph: &mut PetCodeGraph, file: &str, source: &str) {
+        let lines: Vec<&str> = source.lines().collect();
+        let mut index = 0;
+        while index < lines.len() {
+            let Some(name) = lines[index]
+                .strip_prefix("type ")
+                .and_then(|rest| rest.strip_suffix(" interface {"))
+            else {
+                index += 1;
+                continue;
+            };
+            let end = (index..lines.len()).find(|&i| lines[i] == "}").unwrap();
+            let id = format!("{}:{}", file, name);
+            graph.add_node(Node::container(
+                id.clone(),
+                name.to_string(),
+                ContainerKind::Type,
+                Some("interface".to_string()),
+                file.to_string(),
+                index + 1,
+                end + 1,
+            ));
+            for (line, text) in lines.iter().enumerate().take(end).skip(index + 1) {
+                let Some((method, _)) = text.trim().split_once('(') else {
+                    continue;
+                };
+                let method_id = format!("{}:{}", id, method);
+                graph.add_node(Node::callable(
+                    method_id.clone(),
+                    method.to_string(),
+                    CallableKind::Method,
+                    file.to_string(),
+                    line + 1,
+                    line + 1,
+                ));
+                graph.add_edge_from_struct(&Edge::contains(id.clone(), method_id));
+            }
+            index = end;
+        }
+    }
+
+    fn sample_graph() -> (PetCodeGraph, HashMap<&'static str, &'static str>) {
+        let sources = HashMap::from([
+            (
+                "api/store.go",
+                "package api\n\ntype Store interface {\n\tGet(ctx context.Context, id string) (*User, error)\n\tPut(ctx context.Context, u *User) error // saves\n}\n",
+            ),
+            (
+                "db/store.go",
+                "package db\n\ntype UserStore interface {\n\tPut(context.Context, *User) error\n\tGet(ctx context.Context, key string) (user *User, err error)\n}\n\ntype ReadWriter interface {\n\tGet(context.Context, string) (*User, error)\n\tPut(_ context.Context, u *User) error\n\tDelete(ctx context.Context, a, b string) error\n}\n",
+            ),
+            (
+                "cache/cache.go",
+                "package cache\n\ntype Store interface {\n\tGet(ctx context.Context, id string) (*User, error)\n\tPut(ctx context.Context, u *User) error\n}\n\n// Embeds another interface\ntype Full interface {\n\tStore\n\tGet(ctx context.Context, id string) (*User, error)\n\tPut(ctx context.Context, u *User) error\n}\n",
+            ),
+            (
+                "api/store_test.go",
+                "package api\n\ntype fake interface {\n\tGet(ctx context.Context, id string) (*User, error)\n\tPut(ctx context.Context, u *User) error\n}\n",
+            ),
+        ]);
+        let mut graph = PetCodeGraph::new();
+        for (file, source) in &sources {
+            add_file(&mut graph, file, source);
+        }
+        (graph, sources)
+    }
+
+    #[test]
+    fn test_method_signature() {
+        let lines: Vec<String> = [
+            "type X interface {",
+            "\tList(ctx context.Context, a, b int, opts ...Option) ([]Item, error)",
+            "\tWatch(func(int) error, chan<- Event) // streams",
+            "\tDecode(v interface{}) map[string]any",
+            "}",
+        ]
+        .iter()
+        .map(|line| line.to_string())
+        .collect();
+        let method = |name: &str, line: usize| {
+            Node::callable(
+                format!("x.go:X:{}", name),
+                name.to_string(),
+                CallableKind::Method,
+                "x.go".to_string(),
+                line,
+                line,
+            )
+        };
+
+        assert_eq!(
+            method_signature(&lines, &method("List", 2)).unwrap(),
+            "List(context.Context, int, int, ...Option) ([]Item, error)"
+        );
+        assert_eq!(
+            method_signature(&lines, &method("Watch", 3)).unwrap(),
+            "Watch(func(int) error, chan<- Event)"
+        );
+        assert_eq!(
+            method_signature(&lines, &method("Decode", 4)).unwrap(),
+            "Decode(any) map[string]any"
+        );
+    }
+
+    #[test]
+    fn test_find_interface_overlaps() {
+        let (graph, sources) = sample_graph();
+        let read = |file: &str| sources.get(file).map(|s| s.to_string());
+        let overlaps = find_interface_overlaps(&graph, &InterfaceOptions::default(), read);
+
+        let found: Vec<(OverlapKind, &str, &str)> = overlaps
+            .iter()
+            .map(|o| (o.kind, o.first.id.as_str(), o.second.id.as_str()))
+            .collect();
+        assert_eq!(
+            found,
+            [
+                (
+                    OverlapKind::Equivalent,
+                    "api/store.go:Store",
+                    "cache/cache.go:Store"
+                ),
+                (
+                    OverlapKind::Equivalent,
+                    "api/store.go:Store",
+                    "db/store.go:UserStore"
+                ),
+                (
+                    OverlapKind::Equivalent,
+                    "cache/cache.go:Store",
+                    "db/store.go:UserStore"
+                ),
+                (
+                    OverlapKind::Subset,
+                    "api/store.go:Store",
+                    "db/store.go:ReadWriter"
+                ),
+                (
+                    OverlapKind::Subset,
+                    "cache/cache.go:Store",
+                    "db/store.go:ReadWriter"
+                ),
+            ]
+        );
+        assert_eq!(
+            overlaps[3].extra_methods,
+            ["Delete(context.Context, string, string) error"]
+        );
+
+        // Requiring more methods than the smaller interface has
+        let options = InterfaceOptions { min_methods: 3 };
+        let read = |file: &str| sources.get(file).map(|s| s.to_string());
+        assert!(find_interface_overlaps(&graph, &options, read).is_empty());
+    }
+
+    #[test]
+    fn test_link_and_consolidate() {
+        let (mut graph, sources) = sample_graph();
+        graph.add_node(Node::callable(
+            "web/h.go:Handle".to_string(),
+            "Handle".to_string(),
+            CallableKind::Function,
+            "web/h.go".to_string(),
+            1,
+            3,
+        ));
+        graph.add_edge(
+            "web/h.go:Handle",
+            "db/store.go:UserStore",
+            EdgeData::uses(Some(2), Some("UserStore".to_string())),
+        );
+
+        let options = InterfaceOptions::default();
+        let read = |file: &str| sources.get(file).map(|s| s.to_string());
+        assert_eq!(link_interface_overlaps(&mut graph, &options, read), 5);
+        // Relinking replaces the edges
+        let read = |file: &str| sources.get(file).map(|s| s.to_string());
+        assert_eq!(link_interface_overlaps(&mut graph, &options, read), 5);
+        assert_eq!(graph.edges_by_type(EdgeType::EquivalentTo).count(), 3);
+        assert_eq!(graph.edges_by_type(EdgeType::SubsetOf).count(), 2);
+
+        let read = |file: &str| sources.get(file).map(|s| s.to_string());
+        let groups = consolidate(&find_interface_overlaps(&graph, &options, read));
+        assert_eq!(groups.len(), 1);
+        assert_eq!(groups[0].canonical.id, "db/store.go:UserStore");
+        let duplicates: Vec<&str> = groups[0].duplicates.iter().map(|d| d.id.as_str()).collect();
+        assert_eq!(duplicates, ["api/store.go:Store", "cache/cache.go:Store"]);
+        assert_eq!(
+            groups[0].methods,
+            [
+                "Get(context.Context, string) (*User, error)",
+                "Put(context.Context, *User) error"
+            ]
+        );
+    }
+}
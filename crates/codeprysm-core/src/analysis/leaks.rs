@@ -0,0 +1,491 @@
+//! Goroutine Leak and Unclosed Resource Heuristics
+//!
+//! Scans the bodies of Go functions for two common leaks:
+//!
+//! - **goroutine-leak**: a `go` statement whose spawned code has no way to be
+//!   told to stop: neither the statement nor, for a named function, the
+//!   callee's body mentions a context or a done/quit/stop channel
+//! - **unclosed-resource**: a file, connection, response, or query result
+//!   opened into a local variable that is neither closed (`x.Close()`,
+//!   `x.Body.Close()`, directly or deferred) nor returned in the same
+//!   function
+//!
+//! These are textual heuristics over the source lines of each function, so
+//! they miss resources closed by helpers and may flag goroutines that finish
+//! on their own. Test files are skipped.
+//!
+//! Indexing records findings on their functions in the `leaks` attribute
+//! (see [`attach_leaks`]), from where [`leak_findings`] reads them back.
+
+use std::collections::HashMap;
+
+use serde::{Deserialize, Serialize};
+
+use crate::file_policy::is_test_file;
+use crate::graph::{EdgeType, Node, NodeType, PetCodeGraph};
+
+/// SARIF rule ID for goroutines without a cancellation path
+pub const GOROUTINE_LEAK_RULE: &str = "goroutine-leak";
+
+/// SARIF rule ID for resources that are never closed
+pub const UNCLOSED_RESOURCE_RULE: &str = "unclosed-resource";
+
+/// Attribute holding the leak findings of a function, as comma-separated
+/// `rule:line:call` entries
+pub const LEAKS_ATTRIBUTE: &str = "leaks";
+
+/// Words whose presence (case-insensitive) gives a goroutine a way to stop
+const CANCELLATION_MARKERS: &[&str] = &["ctx", "context", "done", "quit", "stop", "cancel"];
+
+/// Calls returning a value that has to be closed
+const RESOURCE_OPENERS: &[&str] = &[
+    "os.Open",
+    "os.OpenFile",
+    "os.Create",
+    "os.CreateTemp",
+    "ioutil.TempFile",
+    "sql.Open",
+    "net.Dial",
+    "net.DialTimeout",
+    "net.Listen",
+    "tls.Dial",
+    "http.Get",
+    "http.Post",
+    "http.PostForm",
+    "http.Head",
+    "grpc.Dial",
+    "grpc.NewClient",
+    "zip.OpenReader",
+    "gzip.NewReader",
+    ".Query",
+    ".QueryContext",
+];
+
+/// Kind of leak.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, PartialOrd, Ord, Hash, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum LeakKind {
+    /// Goroutine spawned without a cancellation path
+    Goroutine,
+    /// Resource opened without a matching close
+    Resource,
+}
+
+impl LeakKind {
+    /// SARIF rule ID of the kind
+    pub fn rule(&self) -> &'static str {
+        match self {
+            LeakKind::Goroutine => GOROUTINE_LEAK_RULE,
+            LeakKind::Resource => UNCLOSED_RESOURCE_RULE,
+        }
+    }
+
+    /// Kind of a SARIF rule ID
+    pub fn from_rule(rule: &str) -> Option<Self> {
+        match rule {
+            GOROUTINE_LEAK_RULE => Some(LeakKind::Goroutine),
+            UNCLOSED_RESOURCE_RULE => Some(LeakKind::Resource),
+            _ => None,
+        }
+    }
+}
+
+/// A suspected leak in a function.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct LeakFinding {
+    /// Kind of leak
+    pub kind: LeakKind,
+    /// Node ID of the enclosing function
+    pub id: String,
+    /// Name of the enclosing function
+    pub name: String,
+    /// File path
+    pub file: String,
+    /// Line of the `go` statement or the opening call
+    pub line: usize,
+    /// Spawned function (`func` for literals) or opening call (e.g., `os.Open`)
+    pub call: String,
+}
+
+impl LeakFinding {
+    /// One-line description of the finding
+    pub fn message(&self) -> String {
+        match self.kind {
+            LeakKind::Goroutine => format!(
+                "goroutine running '{}' in '{}' has no context or done channel to stop it",
+                self.call, self.name
+            ),
+            LeakKind::Resource => format!(
+                "result of {} in '{}' is never closed or returned",
+                self.call, self.name
+            ),
+        }
+    }
+}
+
+/// Find suspected leaks in the Go functions of `graph`, sorted by file and
+/// line.
+///
+/// `read_source` returns the content of a file by its path in the graph.
+pub fn find_leaks<F>(graph: &PetCodeGraph, mut read_source: F) -> Vec<LeakFinding>
+where
+    F: FnMut(&str) -> Option<String>,
+{
+    let mut files: HashMap<String, Option<Vec<String>>> = HashMap::new();
+    let mut body_of = |node: &Node| -> Option<Vec<String>> {
+        let lines = files
+            .entry(node.file.clone())
+            .or_insert_with(|| {
+                read_source(&node.file).map(|content| content.lines().map(String::from).collect())
+            })
+            .as_ref()?;
+        let start = node.line.checked_sub(1)?;
+        let end = node.end_line.min(lines.len());
+        (start < end).then(|| lines[start..end].iter().map(|l| strip_comment(l)).collect())
+    };
+
+    let mut findings = Vec::new();
+    for node in graph.iter_nodes() {
+        if node.node_type != NodeType::Callable
+            || !node.file.ends_with(".go")
+            || is_test_file(&node.file)
+        {
+            continue;
+        }
+        let Some(body) = body_of(node) else {
+            continue;
+        };
+        let finding = |kind, offset: usize, call: String| LeakFinding {
+            kind,
+            id: node.id.clone(),
+            name: node.name.clone(),
+            file: node.file.clone(),
+            line: node.line + offset,
+            call,
+        };
+
+        for (offset, call, statement) in go_statements(&body) {
+            let line = node.line + offset;
+            // A named callee may select on a context or done channel itself
+            let callee_stops = graph
+                .outgoing_edges(&node.id)
+                .filter(|(target, edge)| {
+                    edge.edge_type == EdgeType::Uses
+                        && edge.ref_line == Some(line)
+                        && target.node_type == NodeType::Callable
+                        && target.name == call.rsplit('.').next().unwrap_or(&call)
+                })
+                .any(|(target, _)| body_of(target).is_some_and(|b| can_stop(&b.join("\n"))));
+            if !can_stop(&statement) && !callee_stops {
+                findings.push(finding(LeakKind::Goroutine, offset, call));
+            }
+        }
+        for (offset, call) in unclosed_resources(&body) {
+            findings.push(finding(LeakKind::Resource, offset, call));
+        }
+    }
+
+    sort_findings(&mut findings);
+    findings
+}
+
+/// Record findings on their functions in the `leaks` attribute.
+///
+/// Findings from a previous run are cleared first. Returns the number of
+/// functions with findings.
+pub fn attach_leaks(graph: &mut PetCodeGraph, findings: &[LeakFinding]) -> usize {
+    let mut by_id: HashMap<&str, Vec<String>> = HashMap::new();
+    for finding in findings {
+        by_id.entry(finding.id.as_str()).or_default().push(format!(
+            "{}:{}:{}",
+            finding.kind.rule(),
+            finding.line,
+            finding.call
+        ));
+    }
+    for node in graph.inner_mut().node_weights_mut() {
+        if let Some(attributes) = node.metadata.attributes.as_mut() {
+            attributes.remove(LEAKS_ATTRIBUTE);
+        }
+        if let Some(entries) = by_id.get(node.id.as_str()) {
+            node.metadata
+                .attributes
+                .get_or_insert_with(Default::default)
+                .insert(LEAKS_ATTRIBUTE.to_string(), entries.join(","));
+        }
+    }
+    by_id.len()
+}
+
+/// Findings recorded on the graph by [`attach_leaks`], sorted by file and
+/// line.
+pub fn leak_findings(graph: &PetCodeGraph) -> Vec<LeakFinding> {
+    let mut findings = Vec::new();
+    for node in graph.iter_nodes() {
+        let Some(entries) = node
+            .metadata
+            .attributes
+            .as_ref()
+            .and_then(|attributes| attributes.get(LEAKS_ATTRIBUTE))
+        else {
+            continue;
+        };
+        for entry in entries.split(',') {
+            let mut parts = entry.splitn(3, ':');
+            let (Some(kind), Some(Ok(line)), Some(call)) = (
+                parts.next().and_then(LeakKind::from_rule),
+                parts.next().map(str::parse),
+                parts.next(),
+            ) else {
+                continue;
+            };
+            findings.push(LeakFinding {
+                kind,
+                id: node.id.clone(),
+                name: node.name.clone(),
+                file: node.file.clone(),
+                line,
+                call: call.to_string(),
+            });
+        }
+    }
+    sort_findings(&mut findings);
+    findings
+}
+
+/// Sort findings by file, line, and kind
+fn sort_findings(findings: &mut [LeakFinding]) {
+    findings.sort_by(|a, b| {
+        a.file
+            .cmp(&b.file)
+            .then(a.line.cmp(&b.line))
+            .then(a.kind.cmp(&b.kind))
+    });
+}
+
+/// Line without its trailing `//` comment (ignoring `://` in URLs)
+fn strip_comment(line: &str) -> String {
+    let mut from = 0;
+    while let Some(at) = line[from..].find("//") {
+        let at = from + at;
+        if !line[..at].ends_with(':') {
+            return line[..at].to_string();
+        }
+        from = at + 2;
+    }
+    line.to_string()
+}
+
+/// Check whether code mentions a way to cancel it
+fn can_stop(code: &str) -> bool {
+    let code = code.to_lowercase();
+    CANCELLATION_MARKERS
+        .iter()
+        .any(|marker| code.contains(marker))
+}
+
+/// `go` statements in a body: line offset, spawned function, and the text
+/// of the whole statement (including a literal's body)
+fn go_statements(body: &[String]) -> Vec<(usize, String, String)> {
+    let mut statements = Vec::new();
+    for (offset, line) in body.iter().enumerate() {
+        let Some(rest) = line.trim_start().strip_prefix("go ") else {
+            continue;
+        };
+        let rest = rest.trim_start();
+        let call: String = rest
+            .chars()
+            .take_while(|c| c.is_alphanumeric() || *c == '_' || *c == '.')
+            .collect();
+        if call.is_empty() || !rest[call.len()..].trim_start().starts_with('(') {
+            continue;
+        }
+
+        // Extend a literal's statement to its closing brace
+        let mut statement = String::new();
+        let mut depth = 0i32;
+        for line in &body[offset..] {
+            statement.push_str(line);
+            statement.push('\n');
+            depth += line.matches('{').count() as i32 - line.matches('}').count() as i32;
+            if depth <= 0 {
+                break;
+            }
+        }
+        statements.push((offset, call, statement));
+    }
+    statements
+}
+
+/// Opening calls whose result is never closed or returned: line offset and
+/// opener
+fn unclosed_resources(body: &[String]) -> Vec<(usize, String)> {
+    let mut unclosed = Vec::new();
+    for (offset, line) in body.iter().enumerate() {
+        let Some((lhs, rhs)) = line.split_once(":=").or_else(|| {
+            line.split_once('=')
+                .filter(|(lhs, rhs)| !rhs.starts_with('=') && !lhs.ends_with(['!', '<', '>']))
+        }) else {
+            continue;
+        };
+        let Some(opener) = RESOURCE_OPENERS
+            .iter()
+            .find(|opener| rhs.contains(&format!("{}(", opener)))
+        else {
+            continue;
+        };
+        let variable = lhs
+            .split(',')
+            .next()
+            .unwrap_or_default()
+            .trim()
+            .trim_start_matches("var ");
+        // Stored in a field or elsewhere: owned beyond this function
+        if !variable.chars().all(|c| c.is_alphanumeric() || c == '_') {
+            continue;
+        }
+        let closed = variable != "_"
+            && body[offset + 1..].iter().any(|line| {
+                line.contains(&format!("{}.Close(", variable))
+                    || line.contains(&format!("{}.Body.Close(", variable))
+                    || (line.trim_start().starts_with("return")
+                        && line
+                            .split(|c: char| !(c.is_alphanumeric() || c == '_'))
+                            .any(|word| word == variable))
+            });
+        if !closed {
+            let call = if opener.starts_with('.') {
+                rhs.split('(').next().unwrap_or_default().trim().to_string()
+            } else {
+                opener.to_string()
+            };
+            unclosed.push((offset, call));
+        }
+    }
+    unclosed
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::EdgeData;
+    use crate::test_support;
+
+    const SOURCE: &str = r#"package worker
+
+func Start(jobs chan int) {
+	go process(jobs)
+	go loop(jobs)
+	go func() {
+		for j := range jobs {
+			handle(j)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+	}()
+}
+
+func process(jobs chan int) {}
+
+func loop(jobs chan int) {
+	for {
+		select {
+		case <-stop:
+			return
+		}
+	}
+}
+
+func Read(path string) error {
+	f, err := os.Open(path) // see https://example.com
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	resp, err := http.Get("http://example.com")
+	rows, err := db.Query("SELECT 1")
+	_, err = os.Create(path)
+	return nil
+}
+
+func Open(path string) (*os.File, error) {
+	f, err := os.Open(path)
+	return f, err
+}
+"#;
+
+    fn function(name: &str, line: usize, end_line: usize) -> Node {
+        let mut node = test_support::function(&format!("worker.go:{}", name));
+        node.line = line;
+        node.end_line = end_line;
+        node
+    }
+
+    fn graph() -> PetCodeGraph {
+        let mut graph = PetCodeGraph::new();
+        graph.add_node(function("Start", 3, 14));
+        graph.add_node(function("process", 16, 16));
+        graph.add_node(function("loop", 18, 25));
+        graph.add_node(function("Read", 27, 37));
+        graph.add_node(function("Open", 39, 42));
+        graph.add_edge(
+            "worker.go:Start",
+            "worker.go:process",
+            EdgeData::uses(Some(4), None),
+        );
+        graph.add_edge(
+            "worker.go:Start",
+            "worker.go:loop",
+            EdgeData::uses(Some(5), None),
+        );
+        graph
+    }
+
+    #[test]
+    fn test_find_leaks() {
+        let graph = graph();
+        let findings = find_leaks(&graph, |file| {
+            (file == "worker.go").then(|| SOURCE.to_string())
+        });
+        let summary: Vec<(LeakKind, usize, &str)> = findings
+            .iter()
+            .map(|f| (f.kind, f.line, f.call.as_str()))
+            .collect();
+        assert_eq!(
+            summary,
+            vec![
+                (LeakKind::Goroutine, 4, "process"),
+                (LeakKind::Goroutine, 6, "func"),
+                (LeakKind::Resource, 33, "http.Get"),
+                (LeakKind::Resource, 34, "db.Query"),
+                (LeakKind::Resource, 35, "os.Create"),
+            ]
+        );
+        assert_eq!(
+            findings[0].message(),
+            "goroutine running 'process' in 'Start' has no context or done channel to stop it"
+        );
+    }
+
+    #[test]
+    fn test_attach_leaks_round_trip() {
+        let mut graph = graph();
+        let findings = find_leaks(&graph, |_| Some(SOURCE.to_string()));
+        assert_eq!(attach_leaks(&mut graph, &findings), 2);
+        assert_eq!(leak_findings(&graph), findings);
+
+        // Reattaching replaces earlier findings
+        assert_eq!(attach_leaks(&mut graph, &findings[..1]), 1);
+        assert_eq!(leak_findings(&graph).len(), 1);
+    }
+
+    #[test]
+    fn test_strip_comment() {
+        assert_eq!(strip_comment("x := 1 // one"), "x := 1 ");
+        assert_eq!(
+            strip_comment(r#"http.Get("http://a") // b"#),
+            r#"http.Get("http://a") "#
+        );
+    }
+}
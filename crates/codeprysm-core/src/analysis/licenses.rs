@@ -0,0 +1,143 @@
+//! License Mixing
+//!
+//! Groups the licenses detected in file headers (see [`crate::license`]) by
+//! package and flags packages whose files carry licenses that cannot be
+//! combined, such as a vendored GPL-2.0-only file copied next to
+//! first-party Apache-2.0 code.
+
+use std::collections::BTreeMap;
+
+use serde::{Deserialize, Serialize};
+
+use super::package_of;
+use crate::graph::PetCodeGraph;
+use crate::license::conflict;
+
+/// SARIF rule ID for license conflict findings
+pub const LICENSE_CONFLICT_RULE: &str = "license-conflict";
+
+/// Two incompatible licenses in one package.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct LicenseConflict {
+    /// First license expression
+    pub first: String,
+    /// A file under the first license
+    pub first_file: String,
+    /// Second license expression
+    pub second: String,
+    /// A file under the second license
+    pub second_file: String,
+    /// Why the licenses cannot be combined
+    pub reason: String,
+}
+
+/// The licenses of a package's files.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct PackageLicenses {
+    /// Package (directory relative to the repository root, "" at the root)
+    pub package: String,
+    /// Files by license expression, sorted
+    pub licenses: BTreeMap<String, Vec<String>>,
+    /// Files without a detected license
+    pub unlicensed: usize,
+    /// Pairs of licenses that cannot be combined
+    pub conflicts: Vec<LicenseConflict>,
+}
+
+impl PackageLicenses {
+    /// Check whether the package mixes incompatible licenses
+    pub fn has_conflicts(&self) -> bool {
+        !self.conflicts.is_empty()
+    }
+}
+
+/// Report the licenses of every package with at least one licensed file,
+/// sorted by package.
+pub fn license_report(graph: &PetCodeGraph) -> Vec<PackageLicenses> {
+    let mut packages: BTreeMap<&str, PackageLicenses> = BTreeMap::new();
+    for file in graph.iter_nodes().filter(|n| n.is_file()) {
+        let package = packages
+            .entry(package_of(&file.file))
+            .or_insert_with_key(|package| PackageLicenses {
+                package: package.to_string(),
+                licenses: BTreeMap::new(),
+                unlicensed: 0,
+                conflicts: Vec::new(),
+            });
+        match file.metadata.license {
+            Some(ref license) => package
+                .licenses
+                .entry(license.clone())
+                .or_default()
+                .push(file.file.clone()),
+            None => package.unlicensed += 1,
+        }
+    }
+
+    packages
+        .into_values()
+        .filter(|package| !package.licenses.is_empty())
+        .map(|mut package| {
+            for files in package.licenses.values_mut() {
+                files.sort();
+            }
+            let licenses: Vec<(&String, &Vec<String>)> = package.licenses.iter().collect();
+            let conflicts = licenses
+                .iter()
+                .enumerate()
+                .flat_map(|(i, (first, first_files))| {
+                    licenses[i + 1..]
+                        .iter()
+                        .filter_map(move |(second, second_files)| {
+                            conflict(first, second).map(|reason| LicenseConflict {
+                                first: first.to_string(),
+                                first_file: first_files[0].clone(),
+                                second: second.to_string(),
+                                second_file: second_files[0].clone(),
+                                reason,
+                            })
+                        })
+                })
+                .collect();
+            package.conflicts = conflicts;
+            package
+        })
+        .collect()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::{Node, NodeMetadata};
+
+    fn file(graph: &mut PetCodeGraph, path: &str, license: Option<&str>) {
+        graph.add_node(
+            Node::source_file(path.to_string(), path.to_string(), String::new(), 10)
+                .with_metadata(NodeMetadata::new().with_license(license.map(String::from), None)),
+        );
+    }
+
+    #[test]
+    fn test_license_report() {
+        let mut graph = PetCodeGraph::new();
+        file(&mut graph, "api/server.go", Some("Apache-2.0"));
+        file(&mut graph, "api/handler.go", Some("Apache-2.0"));
+        file(&mut graph, "api/readline.go", Some("GPL-2.0-only"));
+        file(&mut graph, "api/util.go", None);
+        file(&mut graph, "vendor/x/lib.go", Some("MIT OR Apache-2.0"));
+        file(&mut graph, "cmd/main.go", None);
+
+        let report = license_report(&graph);
+        let packages: Vec<&str> = report.iter().map(|p| p.package.as_str()).collect();
+        assert_eq!(packages, vec!["api", "vendor/x"]);
+
+        let api = &report[0];
+        assert_eq!(api.licenses["Apache-2.0"].len(), 2);
+        assert_eq!(api.unlicensed, 1);
+        assert_eq!(api.conflicts.len(), 1);
+        assert_eq!(api.conflicts[0].first, "Apache-2.0");
+        assert_eq!(api.conflicts[0].first_file, "api/handler.go");
+        assert_eq!(api.conflicts[0].second_file, "api/readline.go");
+        assert!(!report[1].has_conflicts());
+    }
+}
@@ -0,0 +1,203 @@
+//! Code Metrics
+//!
+//! Aggregates the per-function complexity computed during extraction (see
+//! `crate::complexity`) into rankings and threshold checks suitable for CI
+//! gating.
+
+use std::collections::BTreeMap;
+
+use serde::Serialize;
+
+use super::package_of;
+use crate::control_flow::ControlFlow;
+use crate::graph::{NodeType, PetCodeGraph};
+
+/// Complexity metrics for one function or method.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize)]
+pub struct FunctionMetrics {
+    /// Node ID
+    pub id: String,
+    /// Function name
+    pub name: String,
+    /// File path relative to the repository root
+    pub file: String,
+    /// Start line (1-indexed)
+    pub line: usize,
+    /// Package (containing directory)
+    pub package: String,
+    /// Cyclomatic complexity
+    pub cyclomatic: u32,
+    /// Cognitive complexity
+    pub cognitive: u32,
+    /// Control-flow summary, for graphs indexed with one
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub control_flow: Option<ControlFlow>,
+}
+
+/// Metric used to rank functions.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default)]
+pub enum ComplexityMetric {
+    /// Cyclomatic complexity
+    Cyclomatic,
+    /// Cognitive complexity
+    #[default]
+    Cognitive,
+}
+
+impl FunctionMetrics {
+    /// Get the value of a metric
+    pub fn value(&self, metric: ComplexityMetric) -> u32 {
+        match metric {
+            ComplexityMetric::Cyclomatic => self.cyclomatic,
+            ComplexityMetric::Cognitive => self.cognitive,
+        }
+    }
+}
+
+/// Upper bounds for CI gating; `None` disables a check.
+#[derive(Debug, Clone, Copy, Default)]
+pub struct ComplexityThresholds {
+    /// Maximum allowed cyclomatic complexity
+    pub max_cyclomatic: Option<u32>,
+    /// Maximum allowed cognitive complexity
+    pub max_cognitive: Option<u32>,
+}
+
+impl ComplexityThresholds {
+    /// Check if any threshold is configured
+    pub fn is_set(&self) -> bool {
+        self.max_cyclomatic.is_some() || self.max_cognitive.is_some()
+    }
+
+    /// Check if a function exceeds any configured threshold
+    pub fn exceeded_by(&self, metrics: &FunctionMetrics) -> bool {
+        self.max_cyclomatic
+            .is_some_and(|max| metrics.cyclomatic > max)
+            || self
+                .max_cognitive
+                .is_some_and(|max| metrics.cognitive > max)
+    }
+}
+
+/// Collect metrics for every callable with measured complexity, ranked by
+/// `metric` (highest first, ties broken by node ID).
+pub fn function_metrics(graph: &PetCodeGraph, metric: ComplexityMetric) -> Vec<FunctionMetrics> {
+    let mut metrics: Vec<FunctionMetrics> = graph
+        .iter_nodes()
+        .filter(|n| n.node_type == NodeType::Callable)
+        .filter_map(|n| {
+            Some(FunctionMetrics {
+                id: n.id.clone(),
+                name: n.name.clone(),
+                file: n.file.clone(),
+                line: n.line,
+                package: package_of(&n.file).to_string(),
+                cyclomatic: n.metadata.cyclomatic_complexity?,
+                cognitive: n.metadata.cognitive_complexity?,
+                control_flow: n.metadata.control_flow,
+            })
+        })
+        .collect();
+
+    metrics.sort_by(|a, b| {
+        b.value(metric)
+            .cmp(&a.value(metric))
+            .then_with(|| a.id.cmp(&b.id))
+    });
+    metrics
+}
+
+/// Group ranked metrics by package, keeping at most `top` functions each.
+///
+/// The input order is preserved within each package.
+pub fn top_by_package(
+    metrics: &[FunctionMetrics],
+    top: usize,
+) -> BTreeMap<String, Vec<FunctionMetrics>> {
+    let mut packages: BTreeMap<String, Vec<FunctionMetrics>> = BTreeMap::new();
+    for m in metrics {
+        let entries = packages.entry(m.package.clone()).or_default();
+        if entries.len() < top {
+            entries.push(m.clone());
+        }
+    }
+    packages
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::{CallableKind, Node};
+    use crate::test_support::function;
+
+    fn add_fn(graph: &mut PetCodeGraph, id: &str, file: &str, cyclomatic: u32, cognitive: u32) {
+        let mut node = function(id);
+        node.file = file.to_string();
+        node.end_line = 10;
+        node.metadata.cyclomatic_complexity = Some(cyclomatic);
+        node.metadata.cognitive_complexity = Some(cognitive);
+        graph.add_node(node);
+    }
+
+    fn sample_graph() -> PetCodeGraph {
+        let mut graph = PetCodeGraph::new();
+        add_fn(&mut graph, "api/h.go:serve", "api/h.go", 12, 20);
+        add_fn(&mut graph, "api/h.go:parse", "api/h.go", 15, 8);
+        add_fn(&mut graph, "api/h.go:small", "api/h.go", 1, 0);
+        add_fn(&mut graph, "db/q.go:query", "db/q.go", 4, 3);
+        // Bodiless callables carry no metrics and are skipped
+        graph.add_node(Node::callable(
+            "api/h.go:Iface:Read".to_string(),
+            "Read".to_string(),
+            CallableKind::Method,
+            "api/h.go".to_string(),
+            1,
+            1,
+        ));
+        graph
+    }
+
+    #[test]
+    fn test_function_metrics_ranking() {
+        let graph = sample_graph();
+
+        let by_cognitive = function_metrics(&graph, ComplexityMetric::Cognitive);
+        assert_eq!(by_cognitive.len(), 4);
+        assert_eq!(by_cognitive[0].id, "api/h.go:serve");
+        assert_eq!(by_cognitive[0].package, "api");
+
+        let by_cyclomatic = function_metrics(&graph, ComplexityMetric::Cyclomatic);
+        assert_eq!(by_cyclomatic[0].id, "api/h.go:parse");
+    }
+
+    #[test]
+    fn test_top_by_package() {
+        let metrics = function_metrics(&sample_graph(), ComplexityMetric::Cognitive);
+        let packages = top_by_package(&metrics, 2);
+
+        assert_eq!(packages.len(), 2);
+        let api: Vec<&str> = packages["api"].iter().map(|m| m.name.as_str()).collect();
+        assert_eq!(api, vec!["serve", "parse"]);
+        assert_eq!(packages["db"].len(), 1);
+    }
+
+    #[test]
+    fn test_thresholds() {
+        let metrics = function_metrics(&sample_graph(), ComplexityMetric::Cognitive);
+
+        let none = ComplexityThresholds::default();
+        assert!(!none.is_set());
+        assert!(metrics.iter().all(|m| !none.exceeded_by(m)));
+
+        let strict = ComplexityThresholds {
+            max_cyclomatic: Some(14),
+            max_cognitive: Some(15),
+        };
+        let violations: Vec<&str> = metrics
+            .iter()
+            .filter(|m| strict.exceeded_by(m))
+            .map(|m| m.name.as_str())
+            .collect();
+        assert_eq!(violations, vec!["serve", "parse"]);
+    }
+}
@@ -0,0 +1,264 @@
+//! Graph Analysis Module
+//!
+//! This module provides whole-graph queries and analyses built on `PetCodeGraph`:
+//! - Shortest paths between two symbols
+//! - Fuzzy (camel-hump) symbol search
+//! - Source navigation: definitions, references, and call hierarchies
+//! - Call hierarchy trees in the LSP `callHierarchy` shape
+//! - Bounded neighborhoods (ego graphs) around a symbol
+//! - Interface implementers by method-set matching
+//! - Go interfaces duplicating (or subsetting) another package's method set
+//! - Callers of a symbol grouped by CODEOWNERS owner
+//! - Exported API surface with signatures, doc summaries, and stability
+//! - Breaking-change classification between two API surfaces
+//! - Symbol-level changelogs grouped by package
+//! - Dead code detection from configurable roots
+//! - Exported Go symbols no other package uses
+//! - Complexity rankings and thresholds
+//! - Package coupling, instability, and abstractness
+//! - Monorepo project summaries, cross-project dependencies, and subgraphs
+//! - Package and type dependency cycles with edges to break them
+//! - References across `internal` package boundaries
+//! - Layer dependencies checked against declared architecture rules
+//! - Declared build dependencies (go list, Bazel) reconciled with code references
+//! - Fan-in/fan-out hotspots weighted by churn
+//! - Refactoring risk from per-symbol churn, complexity, and fan-in
+//! - Test coverage gaps weighted by fan-in
+//! - Structural diffs between two graphs
+//! - Signature and caller changes to watched symbols
+//! - Change impact from a unified diff
+//! - Go tests selected from a change's impact
+//! - Near-duplicate (clone) detection from body fingerprints
+//! - Packages mixing incompatible file licenses
+//! - Goroutines without a cancellation path and resources never closed
+//! - Index statistics and reference resolution rates
+//! - Cypher-like pattern queries (`MATCH ... WHERE ... RETURN`)
+//! - Rename previews: every declaration and reference span to edit
+//!
+//! Findings can be rendered as SARIF via the `sarif` module.
+//!
+//! Analyses are read-only and expect a fully materialized graph (see
+//! `LazyGraphManager::load_full_graph`), since the lazily loaded runtime graph
+//! only contains the partitions touched so far.
+
+pub mod api_diff;
+pub mod api_surface;
+pub mod architecture;
+pub mod boundaries;
+pub mod build_deps;
+pub mod call_hierarchy;
+pub mod changelog;
+pub mod clones;
+pub mod coupling;
+pub mod coverage;
+pub mod cycles;
+pub mod dead_code;
+pub mod graph_diff;
+pub mod hotspots;
+pub mod impact;
+pub mod implementers;
+pub mod interfaces;
+pub mod leaks;
+pub mod licenses;
+pub mod metrics;
+pub mod navigation;
+pub mod ownership;
+pub mod paths;
+pub mod projects;
+pub mod query;
+pub mod rename;
+pub mod risk;
+pub mod sarif;
+pub mod stats;
+pub mod subgraph;
+pub mod subscriptions;
+pub mod symbol_search;
+pub mod test_selection;
+pub mod unused_exports;
+
+use std::path::Path;
+
+use crate::aliases::previous_symbol_ids;
+use crate::graph::{Node, PetCodeGraph};
+use crate::symbol_id::is_symbol_id;
+
+// Re-exports
+pub use api_diff::{diff_api, ApiChange, ApiChangeKind, ApiDiff, Severity};
+pub use api_surface::{api_surface, declaration_signature, doc_summary, ApiSymbol, Stability};
+pub use architecture::{
+    check_architecture, ArchRule, ArchViolation, ArchitectureError, ArchitectureRules, Layer,
+    ARCH_RULES_FILE,
+};
+pub use boundaries::{find_boundary_violations, internal_root, BoundaryViolation};
+pub use build_deps::{
+    reconcile_build_graph, BuildDiscrepancy, BuildGraph, BuildGraphError, BuildPackage,
+    BuildSystem, DiscrepancyKind,
+};
+pub use call_hierarchy::{
+    call_hierarchy, CallHierarchy, CallHierarchyCall, CallHierarchyItem, CallHierarchyOptions,
+    DEFAULT_HIERARCHY_DEPTH, DEFAULT_HIERARCHY_MAX_CALLS,
+};
+pub use changelog::{changelog, Changelog, ChangelogEntry, PackageChangelog};
+pub use clones::{find_clones, link_clones, CloneInstance, CloneOptions, ClonePair};
+pub use coupling::{package_metrics, PackageMetrics};
+pub use coverage::{coverage_report, CoverageFilter, FunctionCoverage};
+pub use cycles::{collect_dependencies, find_cycles, CycleLevel, Dependency, DependencyCycle};
+pub use dead_code::{
+    find_dead_code, find_new_dead_code, DeadCodeOptions, DeadCodeReport, DeadSymbol, RootKind,
+};
+pub use graph_diff::{
+    callable_signatures, diff_graphs, ChangedNode, EdgeKey, FieldChange, GraphDiff, NodeSummary,
+};
+pub use hotspots::{find_hotspots, Hotspot, HotspotFlag, HotspotOptions};
+pub use impact::{
+    analyze_impact, parse_unified_diff, FileChange, ImpactOptions, ImpactReport, ImpactedSymbol,
+};
+pub use implementers::MethodSets;
+pub use interfaces::{
+    consolidate, find_interface_overlaps, link_interface_overlaps, ConsolidationGroup,
+    InterfaceInstance, InterfaceOptions, InterfaceOverlap, OverlapKind,
+};
+pub use leaks::{attach_leaks, find_leaks, leak_findings, LeakFinding, LeakKind};
+pub use licenses::{license_report, LicenseConflict, PackageLicenses};
+pub use metrics::{
+    function_metrics, top_by_package, ComplexityMetric, ComplexityThresholds, FunctionMetrics,
+};
+pub use navigation::{call_edges, references, symbols_at, CallDirection, CallEdge, Location};
+pub use ownership::{caller_owners, OwnerGroup};
+pub use paths::{shortest_paths, GraphPath, PathOptions};
+pub use projects::{
+    project_dependencies, project_subgraph, project_summaries, ProjectDependency, ProjectSummary,
+};
+pub use query::{run_query, GraphQuery, QueryError, QueryResult};
+pub use rename::{plan_rename, RenameEdit, RenameEditKind, RenameError, RenamePlan};
+pub use risk::{apply_risk, risk_report, RiskOptions, SymbolRisk};
+pub use stats::{index_stats, IndexStats, PackageResolution};
+pub use subgraph::{ego_graph, EdgeSelector, EgoDirection, EgoOptions, ImplicitImplementations};
+pub use subscriptions::{
+    change_events, snapshot_symbols, ChangeEvent, ChangeEventKind, SymbolFilter, SymbolSnapshot,
+    SymbolState,
+};
+pub use symbol_search::{fuzzy_match, fuzzy_search, FuzzyOptions, SymbolMatch};
+pub use test_selection::{select_go_tests, TestPackage, TestSelection};
+pub use unused_exports::{
+    find_unused_exports, UnusedExport, UnusedExportOptions, UnusedExportsReport,
+};
+
+/// Package of a file: its containing directory relative to the repository root.
+///
+/// This matches Go's package boundary and is a reasonable grouping for other
+/// languages. Files at the root belong to the "" package.
+pub fn package_of(file: &str) -> &str {
+    Path::new(file)
+        .parent()
+        .and_then(|p| p.to_str())
+        .unwrap_or("")
+}
+
+/// Resolve a user-supplied symbol to matching nodes.
+///
+/// Resolution order:
+/// 1. Exact node ID (e.g., "src/server.go:Server:Handle")
+/// 2. Stable symbol ID (e.g., "sym:go:1f0c6a9e3b2d4c58"), current or one the
+///    symbol had before a rename or move (see [`crate::aliases`])
+/// 3. Exact entity name (e.g., "Handle")
+/// 4. Node ID suffix (e.g., "Server:Handle")
+///
+/// Returns all candidates of the first strategy that matches, sorted by node ID
+/// so callers can report ambiguity deterministically.
+pub fn resolve_symbol<'a>(graph: &'a PetCodeGraph, symbol: &str) -> Vec<&'a Node> {
+    if let Some(node) = graph.get_node(symbol) {
+        return vec![node];
+    }
+
+    if is_symbol_id(symbol) {
+        let mut matches: Vec<&Node> = graph
+            .iter_nodes()
+            .filter(|n| n.metadata.symbol_id.as_deref() == Some(symbol))
+            .collect();
+        if matches.is_empty() {
+            matches = graph
+                .iter_nodes()
+                .filter(|n| previous_symbol_ids(n).any(|id| id == symbol))
+                .collect();
+        }
+        matches.sort_by(|a, b| a.id.cmp(&b.id));
+        return matches;
+    }
+
+    let mut matches: Vec<&Node> = graph.iter_nodes().filter(|n| n.name == symbol).collect();
+
+    if matches.is_empty() {
+        let suffix = format!(":{}", symbol);
+        matches = graph
+            .iter_nodes()
+            .filter(|n| n.id.ends_with(&suffix))
+            .collect();
+    }
+
+    matches.sort_by(|a, b| a.id.cmp(&b.id));
+    matches
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::CallableKind;
+
+    fn callable(id: &str, name: &str) -> Node {
+        Node::callable(
+            id.to_string(),
+            name.to_string(),
+            CallableKind::Function,
+            "main.go".to_string(),
+            1,
+            5,
+        )
+    }
+
+    #[test]
+    fn test_resolve_symbol_by_id_name_and_suffix() {
+        let mut graph = PetCodeGraph::new();
+        graph.add_node(callable("main.go:Server:Handle", "Handle"));
+        graph.add_node(callable("util.go:Handle", "Handle"));
+
+        let by_id = resolve_symbol(&graph, "util.go:Handle");
+        assert_eq!(by_id.len(), 1);
+        assert_eq!(by_id[0].id, "util.go:Handle");
+
+        let by_name = resolve_symbol(&graph, "Handle");
+        assert_eq!(by_name.len(), 2);
+        assert_eq!(by_name[0].id, "main.go:Server:Handle");
+
+        let by_suffix = resolve_symbol(&graph, "Server:Handle");
+        assert_eq!(by_suffix.len(), 1);
+
+        assert!(resolve_symbol(&graph, "Missing").is_empty());
+    }
+
+    #[test]
+    fn test_resolve_symbol_by_symbol_id() {
+        let mut graph = PetCodeGraph::new();
+        let mut node = callable("main.go:Server:Handle", "Handle");
+        node.metadata.symbol_id = Some("sym:go:0123456789abcdef".to_string());
+        graph.add_node(node);
+
+        let by_symbol_id = resolve_symbol(&graph, "sym:go:0123456789abcdef");
+        assert_eq!(by_symbol_id.len(), 1);
+        assert_eq!(by_symbol_id[0].id, "main.go:Server:Handle");
+
+        // Unknown symbol IDs match nothing rather than falling back to suffixes
+        assert!(resolve_symbol(&graph, "sym:go:fedcba9876543210").is_empty());
+
+        // IDs from before a rename still find the symbol
+        let node = graph.get_node_mut("main.go:Server:Handle").unwrap();
+        node.metadata.attributes = Some(
+            [(
+                crate::aliases::PREVIOUS_IDS_ATTRIBUTE.to_string(),
+                "sym:go:fedcba9876543210".to_string(),
+            )]
+            .into(),
+        );
+        assert_eq!(resolve_symbol(&graph, "sym:go:fedcba9876543210").len(), 1);
+    }
+}
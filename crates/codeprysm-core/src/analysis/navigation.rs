@@ -0,0 +1,231 @@
+//! Source Navigation
+//!
+//! Maps between source positions and graph nodes for editor integrations:
+//! the symbol declared or referenced at a position, the references to a
+//! symbol, and callers/callees for call hierarchies.
+//!
+//! Reference positions come from the `ref_line` and `ident` of USES edges, so
+//! lookups are per line; the identifier under the cursor tells references on
+//! the same line apart.
+
+use std::collections::BTreeMap;
+
+use serde::{Deserialize, Serialize};
+
+use crate::graph::{EdgeData, EdgeType, Node, NodeType, PetCodeGraph};
+
+/// A line in a file.
+#[derive(Debug, Clone, PartialEq, Eq, PartialOrd, Ord, Serialize, Deserialize)]
+pub struct Location {
+    /// File path relative to the repository root
+    pub file: String,
+    /// Line (1-indexed)
+    pub line: usize,
+}
+
+/// Which side of a call to report in a call hierarchy.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum CallDirection {
+    /// Callables calling the symbol
+    Incoming,
+    /// Callables the symbol calls
+    Outgoing,
+}
+
+/// A caller or callee, with the lines of the calls in the caller's file.
+#[derive(Debug, Clone)]
+pub struct CallEdge<'a> {
+    /// The caller (incoming) or callee (outgoing)
+    pub node: &'a Node,
+    /// Lines of the call sites, sorted (1-indexed)
+    pub lines: Vec<usize>,
+}
+
+/// Find the symbols at `line` (1-indexed) of `file` named by `ident`.
+///
+/// A declaration at that line is returned as itself; otherwise the targets of
+/// references from that line whose identifier matches are returned. Results
+/// are sorted by node ID.
+pub fn symbols_at<'a>(
+    graph: &'a PetCodeGraph,
+    file: &str,
+    line: usize,
+    ident: &str,
+) -> Vec<&'a Node> {
+    let mut symbols: Vec<&Node> = graph
+        .iter_nodes()
+        .filter(|n| n.file == file && n.line == line && n.name == ident)
+        .collect();
+
+    if symbols.is_empty() {
+        symbols = graph
+            .edges_by_type(EdgeType::Uses)
+            .filter(|(source, target, edge)| {
+                source.file == file
+                    && edge.ref_line == Some(line)
+                    && references_ident(target, edge, ident)
+            })
+            .map(|(_, target, _)| target)
+            .collect();
+    }
+
+    symbols.sort_by(|a, b| a.id.cmp(&b.id));
+    symbols.dedup_by(|a, b| a.id == b.id);
+    symbols
+}
+
+/// Locations referencing a symbol, sorted and deduplicated.
+///
+/// References without a recorded line point at the referencing symbol.
+pub fn references(graph: &PetCodeGraph, id: &str) -> Vec<Location> {
+    let mut locations: Vec<Location> = graph
+        .incoming_edges(id)
+        .filter(|(_, edge)| edge.edge_type == EdgeType::Uses)
+        .map(|(source, edge)| Location {
+            file: source.file.clone(),
+            line: edge.ref_line.unwrap_or(source.line),
+        })
+        .collect();
+    locations.sort();
+    locations.dedup();
+    locations
+}
+
+/// Callables calling (incoming) or called by (outgoing) a symbol, sorted by
+/// node ID.
+pub fn call_edges<'a>(
+    graph: &'a PetCodeGraph,
+    id: &str,
+    direction: CallDirection,
+) -> Vec<CallEdge<'a>> {
+    let edges: Vec<(&Node, &EdgeData)> = match direction {
+        CallDirection::Incoming => graph.incoming_edges(id).collect(),
+        CallDirection::Outgoing => graph.outgoing_edges(id).collect(),
+    };
+
+    let mut calls: BTreeMap<&str, CallEdge> = BTreeMap::new();
+    for (node, edge) in edges {
+        if edge.edge_type != EdgeType::Uses || node.node_type != NodeType::Callable || node.id == id
+        {
+            continue;
+        }
+        let call = calls.entry(node.id.as_str()).or_insert_with(|| CallEdge {
+            node,
+            lines: Vec::new(),
+        });
+        if let Some(line) = edge.ref_line {
+            call.lines.push(line);
+        }
+    }
+
+    calls
+        .into_values()
+        .map(|mut call| {
+            call.lines.sort_unstable();
+            call.lines.dedup();
+            call
+        })
+        .collect()
+}
+
+/// Check if a USES edge to `target` was made through `ident`.
+///
+/// The recorded identifier may be qualified (`pkg.Func`, `self.run`,
+/// `mod::f`); without one, the target's name is compared.
+fn references_ident(target: &Node, edge: &EdgeData, ident: &str) -> bool {
+    match edge.ident.as_deref() {
+        Some(text) => {
+            text == ident
+                || text
+                    .rsplit(['.', ':'])
+                    .next()
+                    .is_some_and(|last| last == ident)
+        }
+        None => target.name == ident,
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::test_support::function;
+
+    fn add_fn(graph: &mut PetCodeGraph, id: &str, line: usize) {
+        let mut node = function(id);
+        node.line = line;
+        node.end_line = line + 5;
+        graph.add_node(node);
+    }
+
+    fn sample_graph() -> PetCodeGraph {
+        let mut graph = PetCodeGraph::new();
+        add_fn(&mut graph, "main.go:main", 3);
+        add_fn(&mut graph, "util.go:parse", 1);
+        add_fn(&mut graph, "util.go:run", 10);
+        graph.add_edge(
+            "main.go:main",
+            "util.go:parse",
+            EdgeData::uses(Some(4), Some("util.parse".to_string())),
+        );
+        graph.add_edge(
+            "main.go:main",
+            "util.go:run",
+            EdgeData::uses(Some(4), Some("run".to_string())),
+        );
+        graph.add_edge(
+            "main.go:main",
+            "util.go:run",
+            EdgeData::uses(Some(6), Some("run".to_string())),
+        );
+        graph
+    }
+
+    #[test]
+    fn test_symbols_at_reference_and_declaration() {
+        let graph = sample_graph();
+
+        let ids = |nodes: Vec<&Node>| nodes.iter().map(|n| n.id.clone()).collect::<Vec<_>>();
+        assert_eq!(
+            ids(symbols_at(&graph, "main.go", 4, "parse")),
+            vec!["util.go:parse"]
+        );
+        assert_eq!(
+            ids(symbols_at(&graph, "main.go", 4, "run")),
+            vec!["util.go:run"]
+        );
+        assert_eq!(
+            ids(symbols_at(&graph, "util.go", 10, "run")),
+            vec!["util.go:run"]
+        );
+        assert!(symbols_at(&graph, "main.go", 5, "run").is_empty());
+    }
+
+    #[test]
+    fn test_references_and_call_edges() {
+        let graph = sample_graph();
+
+        let locations = references(&graph, "util.go:run");
+        assert_eq!(
+            locations,
+            vec![
+                Location {
+                    file: "main.go".to_string(),
+                    line: 4
+                },
+                Location {
+                    file: "main.go".to_string(),
+                    line: 6
+                },
+            ]
+        );
+
+        let callers = call_edges(&graph, "util.go:run", CallDirection::Incoming);
+        assert_eq!(callers.len(), 1);
+        assert_eq!(callers[0].node.id, "main.go:main");
+        assert_eq!(callers[0].lines, vec![4, 6]);
+
+        let callees = call_edges(&graph, "main.go:main", CallDirection::Outgoing);
+        let ids: Vec<&str> = callees.iter().map(|c| c.node.id.as_str()).collect();
+        assert_eq!(ids, vec!["util.go:parse", "util.go:run"]);
+    }
+}
@@ -0,0 +1,134 @@
+//! Cross-Team Ownership
+//!
+//! Answers questions like "which teams own the callers of this function" by
+//! walking USES edges in reverse from a symbol and grouping the dependent
+//! callables by their CODEOWNERS owners (see [`crate::codeowners`]).
+
+use std::collections::{BTreeMap, BTreeSet, VecDeque};
+
+use serde::Serialize;
+
+use crate::graph::{EdgeType, NodeType, PetCodeGraph};
+
+/// Callers of a symbol owned by one team or user.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize)]
+pub struct OwnerGroup {
+    /// Owner (e.g., "@org/api-team"); `None` for unowned callers
+    pub owner: Option<String>,
+    /// Caller node IDs, sorted
+    pub callers: Vec<String>,
+}
+
+/// Group the callers of a symbol by owner.
+///
+/// Callers are callables with a USES edge to the symbol; with `max_depth`
+/// above 1, their callers are included too. A caller with several owners is
+/// listed under each. Groups are sorted by caller count, largest first, with
+/// unowned callers last.
+pub fn caller_owners(graph: &PetCodeGraph, id: &str, max_depth: usize) -> Vec<OwnerGroup> {
+    let mut visited: BTreeSet<&str> = BTreeSet::from([id]);
+    let mut queue: VecDeque<(&str, usize)> = VecDeque::from([(id, 0)]);
+    let mut groups: BTreeMap<Option<&str>, Vec<String>> = BTreeMap::new();
+
+    while let Some((current, depth)) = queue.pop_front() {
+        if depth >= max_depth {
+            continue;
+        }
+        for (caller, edge) in graph.incoming_edges(current) {
+            if edge.edge_type != EdgeType::Uses
+                || caller.node_type != NodeType::Callable
+                || !visited.insert(caller.id.as_str())
+            {
+                continue;
+            }
+            queue.push_back((caller.id.as_str(), depth + 1));
+
+            match caller.metadata.owners.as_deref() {
+                Some(owners) if !owners.is_empty() => {
+                    for owner in owners {
+                        groups
+                            .entry(Some(owner.as_str()))
+                            .or_default()
+                            .push(caller.id.clone());
+                    }
+                }
+                _ => groups.entry(None).or_default().push(caller.id.clone()),
+            }
+        }
+    }
+
+    let mut groups: Vec<OwnerGroup> = groups
+        .into_iter()
+        .map(|(owner, mut callers)| {
+            callers.sort();
+            OwnerGroup {
+                owner: owner.map(str::to_string),
+                callers,
+            }
+        })
+        .collect();
+    groups.sort_by(|a, b| {
+        a.owner
+            .is_none()
+            .cmp(&b.owner.is_none())
+            .then_with(|| b.callers.len().cmp(&a.callers.len()))
+            .then_with(|| a.owner.cmp(&b.owner))
+    });
+    groups
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::test_support::{function, uses};
+
+    fn add_fn(graph: &mut PetCodeGraph, id: &str, owners: &[&str]) {
+        let mut node = function(id);
+        if !owners.is_empty() {
+            node.metadata.owners = Some(owners.iter().map(|o| o.to_string()).collect());
+        }
+        graph.add_node(node);
+    }
+
+    #[test]
+    fn test_caller_owners() {
+        let mut graph = PetCodeGraph::new();
+        add_fn(&mut graph, "store/db.go:Query", &["@org/storage"]);
+        add_fn(&mut graph, "api/users.go:List", &["@org/api"]);
+        add_fn(&mut graph, "api/users.go:Show", &["@org/api"]);
+        add_fn(
+            &mut graph,
+            "billing/tax.go:Rate",
+            &["@org/payments", "@alice"],
+        );
+        add_fn(&mut graph, "scripts/seed.go:Seed", &[]);
+        add_fn(&mut graph, "cmd/main.go:main", &["@org/platform"]);
+
+        uses(&mut graph, "api/users.go:List", "store/db.go:Query");
+        uses(&mut graph, "api/users.go:Show", "store/db.go:Query");
+        uses(&mut graph, "billing/tax.go:Rate", "store/db.go:Query");
+        uses(&mut graph, "scripts/seed.go:Seed", "store/db.go:Query");
+        uses(&mut graph, "cmd/main.go:main", "api/users.go:List");
+
+        let groups = caller_owners(&graph, "store/db.go:Query", 1);
+        let owners: Vec<(Option<&str>, usize)> = groups
+            .iter()
+            .map(|g| (g.owner.as_deref(), g.callers.len()))
+            .collect();
+        assert_eq!(
+            owners,
+            vec![
+                (Some("@org/api"), 2),
+                (Some("@alice"), 1),
+                (Some("@org/payments"), 1),
+                (None, 1),
+            ]
+        );
+
+        // Transitive callers reach the platform team
+        let groups = caller_owners(&graph, "store/db.go:Query", 2);
+        assert!(groups
+            .iter()
+            .any(|g| g.owner.as_deref() == Some("@org/platform")));
+    }
+}
@@ -0,0 +1,281 @@
+//! Shortest-Path Queries
+//!
+//! Finds the shortest edge paths between two nodes using a breadth-first search
+//! that records every predecessor at the shortest distance, so all equally short
+//! paths (up to a limit) can be reported. Useful for answering questions like
+//! "how does this request handler eventually reach this low-level function?".
+
+use std::collections::HashMap;
+
+use petgraph::stable_graph::NodeIndex;
+use petgraph::visit::EdgeRef;
+use petgraph::Direction;
+use serde::Serialize;
+
+use crate::graph::{EdgeType, PetCodeGraph};
+
+/// Options controlling shortest-path search.
+#[derive(Debug, Clone)]
+pub struct PathOptions {
+    /// Edge types to traverse (None = all but DEFINED_IN, which would link
+    /// every symbol to the others in its file)
+    pub edge_types: Option<Vec<EdgeType>>,
+    /// Maximum number of hops to explore
+    pub max_depth: usize,
+    /// Maximum number of equally short paths to return
+    pub max_paths: usize,
+}
+
+impl Default for PathOptions {
+    fn default() -> Self {
+        Self {
+            edge_types: None,
+            max_depth: 10,
+            max_paths: 5,
+        }
+    }
+}
+
+impl PathOptions {
+    /// Check whether an edge type may be traversed
+    fn allows(&self, edge_type: EdgeType) -> bool {
+        match self.edge_types {
+            Some(ref types) => types.contains(&edge_type),
+            None => edge_type != EdgeType::DefinedIn,
+        }
+    }
+}
+
+/// A path through the graph.
+///
+/// `edges[i]` is the type of the edge from `nodes[i]` to `nodes[i + 1]`.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize)]
+pub struct GraphPath {
+    /// Node IDs along the path, starting at the source
+    pub nodes: Vec<String>,
+    /// Edge types between consecutive nodes
+    pub edges: Vec<EdgeType>,
+}
+
+impl GraphPath {
+    /// Number of hops (edges) in the path
+    pub fn len(&self) -> usize {
+        self.edges.len()
+    }
+
+    /// Check if the path has no hops (source and target are the same node)
+    pub fn is_empty(&self) -> bool {
+        self.edges.is_empty()
+    }
+}
+
+/// Find the shortest directed paths from `from` to `to`.
+///
+/// Returns an empty vector if either node is missing or no path exists within
+/// `options.max_depth` hops. Paths are ordered deterministically by node ID.
+pub fn shortest_paths(
+    graph: &PetCodeGraph,
+    from: &str,
+    to: &str,
+    options: &PathOptions,
+) -> Vec<GraphPath> {
+    let (Some(start), Some(goal)) = (graph.get_node_index(from), graph.get_node_index(to)) else {
+        return Vec::new();
+    };
+
+    if start == goal {
+        return vec![GraphPath {
+            nodes: vec![from.to_string()],
+            edges: Vec::new(),
+        }];
+    }
+
+    let inner = graph.inner();
+    let mut depth: HashMap<NodeIndex, usize> = HashMap::new();
+    let mut predecessors: HashMap<NodeIndex, Vec<(NodeIndex, EdgeType)>> = HashMap::new();
+    let mut frontier = vec![start];
+    let mut level = 0;
+    depth.insert(start, 0);
+
+    // Level-by-level BFS, stopping at the level where the goal is first reached
+    while !frontier.is_empty() && level < options.max_depth && !depth.contains_key(&goal) {
+        level += 1;
+        let mut next = Vec::new();
+
+        for &node in &frontier {
+            for edge_ref in inner.edges_directed(node, Direction::Outgoing) {
+                let edge_type = edge_ref.weight().edge_type;
+                if !options.allows(edge_type) {
+                    continue;
+                }
+
+                let target = edge_ref.target();
+                match depth.get(&target) {
+                    Some(&d) if d < level => continue,
+                    Some(_) => {}
+                    None => {
+                        depth.insert(target, level);
+                        next.push(target);
+                    }
+                }
+
+                // Multiple edges between the same pair (e.g., USES at different lines)
+                // would otherwise produce duplicate paths
+                let preds = predecessors.entry(target).or_default();
+                if !preds.contains(&(node, edge_type)) {
+                    preds.push((node, edge_type));
+                }
+            }
+        }
+
+        frontier = next;
+    }
+
+    if !depth.contains_key(&goal) {
+        return Vec::new();
+    }
+
+    let mut paths = Vec::new();
+    let mut nodes = vec![goal];
+    let mut edges = Vec::new();
+    collect_paths(
+        graph,
+        &predecessors,
+        start,
+        &mut nodes,
+        &mut edges,
+        options.max_paths,
+        &mut paths,
+    );
+    paths
+}
+
+/// Walk predecessor lists back from the goal, emitting each complete path.
+fn collect_paths(
+    graph: &PetCodeGraph,
+    predecessors: &HashMap<NodeIndex, Vec<(NodeIndex, EdgeType)>>,
+    start: NodeIndex,
+    nodes: &mut Vec<NodeIndex>,
+    edges: &mut Vec<EdgeType>,
+    max_paths: usize,
+    paths: &mut Vec<GraphPath>,
+) {
+    if paths.len() >= max_paths {
+        return;
+    }
+
+    let current = *nodes.last().expect("path always contains the goal");
+    if current == start {
+        paths.push(GraphPath {
+            nodes: nodes
+                .iter()
+                .rev()
+                .filter_map(|&idx| graph.get_node_by_index(idx))
+                .map(|n| n.id.clone())
+                .collect(),
+            edges: edges.iter().rev().copied().collect(),
+        });
+        return;
+    }
+
+    let node_id = |idx: NodeIndex| graph.get_node_by_index(idx).map(|n| n.id.as_str());
+    let mut candidates = predecessors.get(&current).cloned().unwrap_or_default();
+    candidates.sort_by(|a, b| {
+        node_id(a.0)
+            .cmp(&node_id(b.0))
+            .then_with(|| a.1.as_str().cmp(b.1.as_str()))
+    });
+
+    for (pred, edge_type) in candidates {
+        nodes.push(pred);
+        edges.push(edge_type);
+        collect_paths(graph, predecessors, start, nodes, edges, max_paths, paths);
+        nodes.pop();
+        edges.pop();
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::EdgeData;
+    use crate::test_support::add_fn;
+
+    /// handler -> service -> repo -> db, plus handler -> cache -> db
+    fn sample_graph() -> PetCodeGraph {
+        let mut graph = PetCodeGraph::new();
+        for id in ["handler", "service", "repo", "cache", "db"] {
+            add_fn(&mut graph, id);
+        }
+        graph.add_edge("handler", "service", EdgeData::uses(Some(3), None));
+        graph.add_edge("service", "repo", EdgeData::uses(Some(4), None));
+        graph.add_edge("repo", "db", EdgeData::uses(Some(5), None));
+        graph.add_edge("handler", "cache", EdgeData::uses(Some(6), None));
+        graph.add_edge("cache", "db", EdgeData::defines());
+        graph
+    }
+
+    #[test]
+    fn test_shortest_path_prefers_fewest_hops() {
+        let graph = sample_graph();
+        let paths = shortest_paths(&graph, "handler", "db", &PathOptions::default());
+
+        assert_eq!(paths.len(), 1);
+        assert_eq!(paths[0].nodes, vec!["handler", "cache", "db"]);
+        assert_eq!(paths[0].edges, vec![EdgeType::Uses, EdgeType::Defines]);
+        assert_eq!(paths[0].len(), 2);
+    }
+
+    #[test]
+    fn test_shortest_path_respects_edge_filter() {
+        let graph = sample_graph();
+        let options = PathOptions {
+            edge_types: Some(vec![EdgeType::Uses]),
+            ..Default::default()
+        };
+        let paths = shortest_paths(&graph, "handler", "db", &options);
+
+        assert_eq!(paths.len(), 1);
+        assert_eq!(paths[0].nodes, vec!["handler", "service", "repo", "db"]);
+    }
+
+    #[test]
+    fn test_shortest_path_returns_all_equal_length_paths() {
+        let mut graph = sample_graph();
+        graph.add_edge("service", "db", EdgeData::uses(Some(7), None));
+        // Duplicate edge at another line must not duplicate the path
+        graph.add_edge("cache", "db", EdgeData::uses(Some(8), None));
+
+        let options = PathOptions {
+            edge_types: Some(vec![EdgeType::Uses]),
+            ..Default::default()
+        };
+        let paths = shortest_paths(&graph, "handler", "db", &options);
+
+        assert_eq!(paths.len(), 2);
+        assert_eq!(paths[0].nodes, vec!["handler", "cache", "db"]);
+        assert_eq!(paths[1].nodes, vec!["handler", "service", "db"]);
+    }
+
+    #[test]
+    fn test_shortest_path_limits() {
+        let graph = sample_graph();
+
+        let shallow = PathOptions {
+            max_depth: 1,
+            ..Default::default()
+        };
+        assert!(shortest_paths(&graph, "handler", "db", &shallow).is_empty());
+
+        // Edges are directed
+        assert!(shortest_paths(&graph, "db", "handler", &PathOptions::default()).is_empty());
+
+        // Unknown nodes
+        assert!(shortest_paths(&graph, "handler", "missing", &PathOptions::default()).is_empty());
+
+        // Same node yields a zero-length path
+        let same = shortest_paths(&graph, "db", "db", &PathOptions::default());
+        assert_eq!(same.len(), 1);
+        assert!(same[0].is_empty());
+    }
+}
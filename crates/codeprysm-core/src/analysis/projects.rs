@@ -0,0 +1,194 @@
+//! Project Queries
+//!
+//! Summaries, cross-project dependencies, and per-project subgraphs built on
+//! the `project` attribute that indexing attaches to every node (see
+//! [`attach_projects`](crate::projects::attach_projects)). Nodes without the
+//! attribute (built before project discovery, or outside any project) are
+//! ignored.
+
+use std::collections::{BTreeMap, BTreeSet, HashSet};
+
+use serde::Serialize;
+
+use crate::graph::{EdgeType, Node, PetCodeGraph};
+use crate::projects::{PROJECT_ATTRIBUTE, PROJECT_ROOT_ATTRIBUTE};
+
+/// Size of one project in the graph.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize)]
+pub struct ProjectSummary {
+    /// Project name
+    pub name: String,
+    /// Project root relative to the repository root
+    pub root: String,
+    /// Number of indexed files
+    pub files: usize,
+    /// Number of symbols (non-file nodes)
+    pub symbols: usize,
+}
+
+/// References from one project to another.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize)]
+pub struct ProjectDependency {
+    /// Referencing project
+    pub from: String,
+    /// Referenced project
+    pub to: String,
+    /// Number of reference edges between the two
+    pub references: usize,
+}
+
+/// Project a node belongs to, if tagged
+pub fn project_of(node: &Node) -> Option<&str> {
+    node.metadata
+        .attributes
+        .as_ref()?
+        .get(PROJECT_ATTRIBUTE)
+        .map(String::as_str)
+}
+
+/// Summarize every project in the graph, sorted by name.
+pub fn project_summaries(graph: &PetCodeGraph) -> Vec<ProjectSummary> {
+    let mut projects: BTreeMap<&str, (String, BTreeSet<&str>, usize)> = BTreeMap::new();
+    for node in graph.iter_nodes() {
+        let Some(name) = project_of(node) else {
+            continue;
+        };
+        let entry = projects.entry(name).or_insert_with(|| {
+            let root = node
+                .metadata
+                .attributes
+                .as_ref()
+                .and_then(|attributes| attributes.get(PROJECT_ROOT_ATTRIBUTE))
+                .cloned()
+                .unwrap_or_default();
+            (root, BTreeSet::new(), 0)
+        });
+        entry.1.insert(node.file.as_str());
+        if !node.is_file() {
+            entry.2 += 1;
+        }
+    }
+    projects
+        .into_iter()
+        .map(|(name, (root, files, symbols))| ProjectSummary {
+            name: name.to_string(),
+            root,
+            files: files.len(),
+            symbols,
+        })
+        .collect()
+}
+
+/// Count the references (`USES` and cross-language edges) between nodes of
+/// different projects, sorted by referencing then referenced project.
+pub fn project_dependencies(graph: &PetCodeGraph) -> Vec<ProjectDependency> {
+    let mut counts: BTreeMap<(&str, &str), usize> = BTreeMap::new();
+    for edge in graph.iter_edges() {
+        if !matches!(edge.edge_type, EdgeType::Uses | EdgeType::CrossLanguage) {
+            continue;
+        }
+        let (Some(source), Some(target)) =
+            (graph.get_node(&edge.source), graph.get_node(&edge.target))
+        else {
+            continue;
+        };
+        if let (Some(from), Some(to)) = (project_of(source), project_of(target)) {
+            if from != to {
+                *counts.entry((from, to)).or_default() += 1;
+            }
+        }
+    }
+    counts
+        .into_iter()
+        .map(|((from, to), references)| ProjectDependency {
+            from: from.to_string(),
+            to: to.to_string(),
+            references,
+        })
+        .collect()
+}
+
+/// Extract the nodes of one project and the edges among them.
+///
+/// Returns an empty graph when no node belongs to the project.
+pub fn project_subgraph(graph: &PetCodeGraph, project: &str) -> PetCodeGraph {
+    let mut subgraph = PetCodeGraph::new();
+    let mut keep: HashSet<&str> = HashSet::new();
+    for node in graph.iter_nodes() {
+        if project_of(node) == Some(project) {
+            keep.insert(node.id.as_str());
+            subgraph.add_node(node.clone());
+        }
+    }
+    for edge in graph.iter_edges() {
+        if keep.contains(edge.source.as_str()) && keep.contains(edge.target.as_str()) {
+            subgraph.add_edge_from_struct(&edge);
+        }
+    }
+    subgraph
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::{CallableKind, EdgeData};
+
+    fn tagged(id: &str, file: &str, project: &str) -> Node {
+        let mut node = Node::callable(
+            id.to_string(),
+            id.to_string(),
+            CallableKind::Function,
+            file.to_string(),
+            1,
+            2,
+        );
+        node.metadata.attributes = Some(BTreeMap::from([
+            (PROJECT_ATTRIBUTE.to_string(), project.to_string()),
+            (PROJECT_ROOT_ATTRIBUTE.to_string(), project.to_string()),
+        ]));
+        node
+    }
+
+    #[test]
+    fn test_project_queries() {
+        let mut graph = PetCodeGraph::new();
+        graph.add_node(tagged("api/h.go:Handle", "api/h.go", "api"));
+        graph.add_node(tagged("api/h.go:helper", "api/h.go", "api"));
+        graph.add_node(tagged("lib/s.go:Save", "lib/s.go", "lib"));
+        graph.add_node(tagged("lib/t.go:Load", "lib/t.go", "lib"));
+        graph.add_edge(
+            "api/h.go:Handle",
+            "lib/s.go:Save",
+            EdgeData::uses(Some(3), None),
+        );
+        graph.add_edge(
+            "api/h.go:helper",
+            "lib/t.go:Load",
+            EdgeData::uses(Some(4), None),
+        );
+        graph.add_edge(
+            "api/h.go:Handle",
+            "api/h.go:helper",
+            EdgeData::uses(Some(5), None),
+        );
+
+        let summaries = project_summaries(&graph);
+        assert_eq!(summaries.len(), 2);
+        assert_eq!(summaries[0].name, "api");
+        assert_eq!((summaries[0].files, summaries[0].symbols), (1, 2));
+        assert_eq!((summaries[1].files, summaries[1].symbols), (2, 2));
+
+        assert_eq!(
+            project_dependencies(&graph),
+            vec![ProjectDependency {
+                from: "api".to_string(),
+                to: "lib".to_string(),
+                references: 2,
+            }]
+        );
+
+        let api = project_subgraph(&graph, "api");
+        assert_eq!((api.node_count(), api.edge_count()), (2, 1));
+        assert_eq!(project_subgraph(&graph, "web").node_count(), 0);
+    }
+}
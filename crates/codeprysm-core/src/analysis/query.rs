@@ -0,0 +1,1781 @@
+//! Graph Query Language
+//!
+//! A small Cypher-like language for traversals the built-in queries do not
+//! cover, such as the callers of a function up to three calls away:
+//!
+//! ```text
+//! MATCH (f:Function)-[:CALLS*1..3]->(g {name: "Save"})
+//! WHERE f.file STARTS WITH "api/" AND NOT f.name ENDS WITH "Test"
+//! RETURN DISTINCT f.name, g.id
+//! ORDER BY f.name
+//! LIMIT 20
+//! ```
+//!
+//! - Node patterns `(var:Label {key: value})` match nodes whose type, kind,
+//!   or subtype is one of the `|`-separated labels (case-insensitive, e.g.
+//!   `Callable`, `Function`, `Method`, `Type`, `Struct`, `File`) and whose
+//!   properties equal the given values.
+//! - Relationship patterns `-[var:TYPE*min..max]->`, `<-[...]-`, and `-[...]-`
+//!   follow edges of the given types (`CONTAINS`, `USES`, `DEFINES`,
+//!   `DEPENDS_ON`, `CLONE_OF`, `DEFINED_IN`, `CROSS_LANGUAGE`,
+//!   `REFERS_BY_NAME`, `EQUIVALENT_TO`, `SUBSET_OF`, `CALLS` for uses between
+//!   callables, or `IMPLEMENTS` for types implementing an interface, by a
+//!   use or by method set; see [`EdgeSelector`]). A variable-length relationship matches each node
+//!   reached by a path whose length is within its bounds once, including the
+//!   start node through a cycle; `*` alone means 1 to [`MAX_HOPS`] hops.
+//! - Node properties are the node fields (`id`, `name`, `type`, `kind`,
+//!   `subtype`, `file`, `line`, `end_line`, `text`), the metadata fields under
+//!   their JSON names (`visibility`, `owners`, `cyclomatic_complexity`, ...),
+//!   and user-defined attributes. Relationships have `type`, `line`, `ident`,
+//!   `similarity`, and `weight`.
+//! - `WHERE` combines comparisons (`=`, `<>`, `<`, `<=`, `>`, `>=`,
+//!   `STARTS WITH`, `ENDS WITH`, `CONTAINS`, `IN [...]`, `IS [NOT] NULL`)
+//!   with `AND`, `OR`, and `NOT`.
+//! - `RETURN` takes variables, properties, and `count(*)` or
+//!   `count([DISTINCT] expr)`, which group the rows by the other items;
+//!   `ORDER BY` sorts by returned columns, then `SKIP` and `LIMIT` apply.
+//!
+//! Several `MATCH` clauses or comma-separated patterns join on their shared
+//! variables. Keywords are case-insensitive. A query stops with an error once
+//! it has visited [`MAX_STEPS`] nodes and edges, so cross products and deep
+//! traversals of a large graph fail instead of running without end.
+
+use std::cmp::Ordering;
+use std::collections::{HashMap, HashSet};
+use std::fmt;
+
+use serde::{Deserialize, Serialize};
+use serde_json::Value;
+use thiserror::Error;
+
+use super::subgraph::{EdgeSelector, ImplicitImplementations};
+use crate::graph::{EdgeData, Node, PetCodeGraph};
+
+/// Hops followed by a variable-length relationship without an upper bound
+pub const MAX_HOPS: usize = 16;
+
+/// Rows a query may match before it is stopped
+pub const MAX_ROWS: usize = 100_000;
+
+/// Nodes and edges a query may visit before it is stopped
+pub const MAX_STEPS: usize = 10_000_000;
+
+/// Errors of parsing or running a query.
+#[derive(Debug, Clone, PartialEq, Error)]
+pub enum QueryError {
+    /// The query text is malformed
+    #[error("Syntax error at position {position}: {message}")]
+    Syntax { position: usize, message: String },
+
+    /// The query is well-formed but cannot run (unknown variable, edge type,
+    /// or column)
+    #[error("{0}")]
+    Invalid(String),
+
+    /// The query matched more than [`MAX_ROWS`] rows
+    #[error("Query matches more than {0} rows; narrow the pattern or add a LIMIT")]
+    TooManyRows(usize),
+
+    /// The query visited more than the allowed number of nodes and edges
+    #[error("Query visits more than {0} nodes and edges; narrow the pattern")]
+    TooManySteps(usize),
+}
+
+/// Columns and rows returned by a query.
+///
+/// Nodes are returned as their JSON form; relationships as an object with
+/// their `type`, `source`, `target`, and `line`.
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+pub struct QueryResult {
+    /// Column names, from `AS` aliases or the returned expressions
+    pub columns: Vec<String>,
+    /// Rows of values, one per column
+    pub rows: Vec<Vec<Value>>,
+}
+
+/// Parse and run a query over a graph.
+pub fn run_query(graph: &PetCodeGraph, query: &str) -> Result<QueryResult, QueryError> {
+    GraphQuery::parse(query)?.execute(graph)
+}
+
+/// A parsed query.
+#[derive(Debug, Clone)]
+pub struct GraphQuery {
+    patterns: Vec<PathPattern>,
+    filter: Option<Expr>,
+    distinct: bool,
+    items: Vec<ReturnItem>,
+    order: Vec<(usize, bool)>,
+    skip: usize,
+    limit: Option<usize>,
+    /// Variable names by slot
+    variables: Vec<String>,
+}
+
+#[derive(Debug, Clone)]
+struct PathPattern {
+    start: NodePattern,
+    steps: Vec<(RelPattern, NodePattern)>,
+}
+
+#[derive(Debug, Clone)]
+struct NodePattern {
+    slot: Option<usize>,
+    /// Label groups that must all match; a group matches any of its labels
+    labels: Vec<Vec<String>>,
+    properties: Vec<(String, Value)>,
+}
+
+#[derive(Debug, Clone)]
+struct RelPattern {
+    slot: Option<usize>,
+    types: Vec<EdgeSelector>,
+    direction: Direction,
+    min: usize,
+    max: usize,
+}
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+enum Direction {
+    Outgoing,
+    Incoming,
+    Both,
+}
+
+#[derive(Debug, Clone)]
+struct ReturnItem {
+    name: String,
+    expr: Expr,
+}
+
+#[derive(Debug, Clone)]
+enum Expr {
+    Literal(Value),
+    List(Vec<Expr>),
+    Variable(usize),
+    Property(usize, String),
+    Not(Box<Expr>),
+    And(Box<Expr>, Box<Expr>),
+    Or(Box<Expr>, Box<Expr>),
+    Compare(CompareOp, Box<Expr>, Box<Expr>),
+    IsNull(Box<Expr>, bool),
+    /// `count(*)` (None) or `count([DISTINCT] expr)`
+    Count(Option<Box<Expr>>, bool),
+}
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+enum CompareOp {
+    Eq,
+    Ne,
+    Lt,
+    Le,
+    Gt,
+    Ge,
+    StartsWith,
+    EndsWith,
+    Contains,
+    In,
+}
+
+impl Expr {
+    fn is_aggregate(&self) -> bool {
+        matches!(self, Expr::Count(..))
+    }
+
+    fn contains_aggregate(&self) -> bool {
+        match self {
+            Expr::Count(..) => true,
+            Expr::Literal(_) | Expr::Variable(_) | Expr::Property(..) => false,
+            Expr::List(items) => items.iter().any(Expr::contains_aggregate),
+            Expr::Not(e) | Expr::IsNull(e, _) => e.contains_aggregate(),
+            Expr::And(a, b) | Expr::Or(a, b) | Expr::Compare(_, a, b) => {
+                a.contains_aggregate() || b.contains_aggregate()
+            }
+        }
+    }
+}
+
+// ============================================================================
+// Lexer
+// ============================================================================
+
+#[derive(Debug, Clone, PartialEq)]
+enum Token {
+    Ident(String),
+    Str(String),
+    Num(f64),
+    Sym(&'static str),
+}
+
+impl fmt::Display for Token {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        match self {
+            Token::Ident(s) => write!(f, "'{}'", s),
+            Token::Str(s) => write!(f, "\"{}\"", s),
+            Token::Num(n) => write!(f, "{}", n),
+            Token::Sym(s) => write!(f, "'{}'", s),
+        }
+    }
+}
+
+/// Symbols, longest first
+const SYMBOLS: &[&str] = &[
+    "..", "<>", "<=", ">=", "(", ")", "[", "]", "{", "}", ":", ",", ".", "|", "*", "-", ">", "<",
+    "=",
+];
+
+/// Tokens with their byte offsets
+fn lex(input: &str) -> Result<Vec<(Token, usize)>, QueryError> {
+    let bytes = input.as_bytes();
+    let mut tokens = Vec::new();
+    let mut i = 0;
+    while i < bytes.len() {
+        let c = bytes[i];
+        if c.is_ascii_whitespace() {
+            i += 1;
+            continue;
+        }
+        let start = i;
+        if c.is_ascii_alphabetic() || c == b'_' {
+            while i < bytes.len() && (bytes[i].is_ascii_alphanumeric() || bytes[i] == b'_') {
+                i += 1;
+            }
+            tokens.push((Token::Ident(input[start..i].to_string()), start));
+        } else if c == b'`' {
+            let end = input[i + 1..].find('`').ok_or_else(|| QueryError::Syntax {
+                position: start,
+                message: "unterminated quoted name".to_string(),
+            })?;
+            tokens.push((Token::Ident(input[i + 1..i + 1 + end].to_string()), start));
+            i += end + 2;
+        } else if c.is_ascii_digit() {
+            while i < bytes.len() && bytes[i].is_ascii_digit() {
+                i += 1;
+            }
+            // A fraction, but not the start of a `..` range
+            if i + 1 < bytes.len() && bytes[i] == b'.' && bytes[i + 1].is_ascii_digit() {
+                i += 1;
+                while i < bytes.len() && bytes[i].is_ascii_digit() {
+                    i += 1;
+                }
+            }
+            let number = input[start..i].parse().map_err(|_| QueryError::Syntax {
+                position: start,
+                message: "invalid number".to_string(),
+            })?;
+            tokens.push((Token::Num(number), start));
+        } else if c == b'"' || c == b'\'' {
+            let mut value = String::new();
+            let mut chars = input[i + 1..].char_indices();
+            let mut closed = false;
+            while let Some((offset, ch)) = chars.next() {
+                if ch == c as char {
+                    i += offset + 2;
+                    closed = true;
+                    break;
+                }
+                if ch == '\\' {
+                    match chars.next() {
+                        Some((_, 'n')) => value.push('\n'),
+                        Some((_, 't')) => value.push('\t'),
+                        Some((_, escaped)) => value.push(escaped),
+                        None => break,
+                    }
+                } else {
+                    value.push(ch);
+                }
+            }
+            if !closed {
+                return Err(QueryError::Syntax {
+                    position: start,
+                    message: "unterminated string".to_string(),
+                });
+            }
+            tokens.push((Token::Str(value), start));
+        } else {
+            let symbol = SYMBOLS
+                .iter()
+                .find(|s| input[i..].starts_with(**s))
+                .ok_or_else(|| QueryError::Syntax {
+                    position: start,
+                    message: format!(
+                        "unexpected character '{}'",
+                        input[i..].chars().next().unwrap_or_default()
+                    ),
+                })?;
+            i += symbol.len();
+            tokens.push((Token::Sym(symbol), start));
+        }
+    }
+    Ok(tokens)
+}
+
+// ============================================================================
+// Parser
+// ============================================================================
+
+struct Parser<'a> {
+    input: &'a str,
+    tokens: Vec<(Token, usize)>,
+    pos: usize,
+    variables: Vec<String>,
+    /// Variables bound to relationships
+    relationships: HashSet<usize>,
+}
+
+impl<'a> Parser<'a> {
+    fn new(input: &'a str) -> Result<Self, QueryError> {
+        Ok(Self {
+            input,
+            tokens: lex(input)?,
+            pos: 0,
+            variables: Vec::new(),
+            relationships: HashSet::new(),
+        })
+    }
+
+    fn peek(&self) -> Option<&Token> {
+        self.tokens.get(self.pos).map(|(token, _)| token)
+    }
+
+    fn position(&self) -> usize {
+        self.tokens
+            .get(self.pos)
+            .map_or(self.input.len(), |(_, position)| *position)
+    }
+
+    /// Byte offset where the previous token ends
+    fn end_of_previous(&self) -> usize {
+        match self.pos.checked_sub(1).and_then(|i| self.tokens.get(i)) {
+            Some((Token::Ident(s), p)) if self.input[*p..].starts_with('`') => p + s.len() + 2,
+            Some((Token::Ident(s), p)) => p + s.len(),
+            Some((Token::Sym(s), p)) => p + s.len(),
+            // Strings and numbers end where the next token starts
+            Some(_) => self
+                .tokens
+                .get(self.pos)
+                .map_or(self.input.len(), |(_, p)| *p),
+            None => 0,
+        }
+    }
+
+    fn error<T>(&self, message: impl Into<String>) -> Result<T, QueryError> {
+        Err(QueryError::Syntax {
+            position: self.position(),
+            message: message.into(),
+        })
+    }
+
+    fn unexpected<T>(&self, expected: &str) -> Result<T, QueryError> {
+        match self.peek() {
+            Some(token) => self.error(format!("expected {}, found {}", expected, token)),
+            None => self.error(format!("expected {}, found end of query", expected)),
+        }
+    }
+
+    fn eat_symbol(&mut self, symbol: &str) -> bool {
+        if matches!(self.peek(), Some(Token::Sym(s)) if *s == symbol) {
+            self.pos += 1;
+            true
+        } else {
+            false
+        }
+    }
+
+    fn expect_symbol(&mut self, symbol: &str) -> Result<(), QueryError> {
+        if self.eat_symbol(symbol) {
+            Ok(())
+        } else {
+            self.unexpected(&format!("'{}'", symbol))
+        }
+    }
+
+    fn is_keyword(&self, keyword: &str) -> bool {
+        matches!(self.peek(), Some(Token::Ident(s)) if s.eq_ignore_ascii_case(keyword))
+    }
+
+    fn eat_keyword(&mut self, keyword: &str) -> bool {
+        if self.is_keyword(keyword) {
+            self.pos += 1;
+            true
+        } else {
+            false
+        }
+    }
+
+    fn expect_keyword(&mut self, keyword: &str) -> Result<(), QueryError> {
+        if self.eat_keyword(keyword) {
+            Ok(())
+        } else {
+            self.unexpected(keyword)
+        }
+    }
+
+    fn identifier(&mut self) -> Result<String, QueryError> {
+        match self.peek() {
+            Some(Token::Ident(s)) => {
+                let s = s.clone();
+                self.pos += 1;
+                Ok(s)
+            }
+            _ => self.unexpected("a name"),
+        }
+    }
+
+    fn integer(&mut self) -> Result<usize, QueryError> {
+        match self.peek() {
+            Some(Token::Num(n)) if n.fract() == 0.0 && *n >= 0.0 => {
+                let n = *n as usize;
+                self.pos += 1;
+                Ok(n)
+            }
+            _ => self.unexpected("a non-negative integer"),
+        }
+    }
+
+    /// Slot of a variable, declaring it in patterns
+    fn declare(&mut self, name: String) -> usize {
+        match self.variables.iter().position(|v| *v == name) {
+            Some(slot) => slot,
+            None => {
+                self.variables.push(name);
+                self.variables.len() - 1
+            }
+        }
+    }
+
+    /// Slot of a variable used in an expression
+    fn lookup(&self, name: &str) -> Result<usize, QueryError> {
+        self.variables
+            .iter()
+            .position(|v| v == name)
+            .ok_or_else(|| QueryError::Invalid(format!("Variable '{}' is not defined", name)))
+    }
+
+    fn query(mut self) -> Result<GraphQuery, QueryError> {
+        let mut patterns = Vec::new();
+        self.expect_keyword("MATCH")?;
+        loop {
+            patterns.push(self.path_pattern()?);
+            if self.eat_symbol(",") || self.eat_keyword("MATCH") {
+                continue;
+            }
+            break;
+        }
+
+        let filter = if self.eat_keyword("WHERE") {
+            let filter = self.expr()?;
+            if filter.contains_aggregate() {
+                return Err(QueryError::Invalid(
+                    "count() is only allowed in RETURN".to_string(),
+                ));
+            }
+            Some(filter)
+        } else {
+            None
+        };
+
+        self.expect_keyword("RETURN")?;
+        let distinct = self.eat_keyword("DISTINCT");
+        let mut items = Vec::new();
+        loop {
+            let start = self.position();
+            let expr = self.expr()?;
+            let text = self.input[start..self.end_of_previous()].trim().to_string();
+            let name = if self.eat_keyword("AS") {
+                self.identifier()?
+            } else {
+                text
+            };
+            if expr.contains_aggregate() && !expr.is_aggregate() {
+                return Err(QueryError::Invalid(
+                    "count() must be a RETURN item of its own".to_string(),
+                ));
+            }
+            items.push(ReturnItem { name, expr });
+            if !self.eat_symbol(",") {
+                break;
+            }
+        }
+
+        let mut order = Vec::new();
+        if self.eat_keyword("ORDER") {
+            self.expect_keyword("BY")?;
+            loop {
+                let start = self.position();
+                self.expr()?;
+                let text = self.input[start..self.end_of_previous()].trim();
+                let column = items
+                    .iter()
+                    .position(|item| item.name == text)
+                    .ok_or_else(|| {
+                        QueryError::Invalid(format!(
+                            "ORDER BY {} does not name a returned column",
+                            text
+                        ))
+                    })?;
+                let descending = self.eat_keyword("DESC") || self.eat_keyword("DESCENDING");
+                if !descending && !self.eat_keyword("ASC") {
+                    self.eat_keyword("ASCENDING");
+                }
+                order.push((column, descending));
+                if !self.eat_symbol(",") {
+                    break;
+                }
+            }
+        }
+
+        let skip = if self.eat_keyword("SKIP") {
+            self.integer()?
+        } else {
+            0
+        };
+        let limit = if self.eat_keyword("LIMIT") {
+            Some(self.integer()?)
+        } else {
+            None
+        };
+
+        if self.peek().is_some() {
+            return self.unexpected("end of query");
+        }
+        Ok(GraphQuery {
+            patterns,
+            filter,
+            distinct,
+            items,
+            order,
+            skip,
+            limit,
+            variables: self.variables,
+        })
+    }
+
+    fn path_pattern(&mut self) -> Result<PathPattern, QueryError> {
+        let start = self.node_pattern()?;
+        let mut steps = Vec::new();
+        while matches!(self.peek(), Some(Token::Sym("-" | "<"))) {
+            let rel = self.rel_pattern()?;
+            steps.push((rel, self.node_pattern()?));
+        }
+        Ok(PathPattern { start, steps })
+    }
+
+    fn node_pattern(&mut self) -> Result<NodePattern, QueryError> {
+        self.expect_symbol("(")?;
+        let slot = match self.peek() {
+            Some(Token::Ident(_)) => {
+                let name = self.identifier()?;
+                let slot = self.declare(name.clone());
+                if self.relationships.contains(&slot) {
+                    return Err(QueryError::Invalid(format!(
+                        "Variable '{}' is a relationship, not a node",
+                        name
+                    )));
+                }
+                Some(slot)
+            }
+            _ => None,
+        };
+
+        let mut labels = Vec::new();
+        while self.eat_symbol(":") {
+            let mut group = vec![self.identifier()?.to_lowercase()];
+            while self.eat_symbol("|") {
+                group.push(self.identifier()?.to_lowercase());
+            }
+            labels.push(group);
+        }
+
+        let properties = if matches!(self.peek(), Some(Token::Sym("{"))) {
+            self.properties()?
+        } else {
+            Vec::new()
+        };
+        self.expect_symbol(")")?;
+        Ok(NodePattern {
+            slot,
+            labels,
+            properties,
+        })
+    }
+
+    fn properties(&mut self) -> Result<Vec<(String, Value)>, QueryError> {
+        self.expect_symbol("{")?;
+        let mut properties = Vec::new();
+        if !self.eat_symbol("}") {
+            loop {
+                let key = self.identifier()?;
+                self.expect_symbol(":")?;
+                let value = match self.primary()? {
+                    Expr::Literal(value) => value,
+                    _ => return self.error("property values must be literals"),
+                };
+                properties.push((key, value));
+                if !self.eat_symbol(",") {
+                    break;
+                }
+            }
+            self.expect_symbol("}")?;
+        }
+        Ok(properties)
+    }
+
+    fn rel_pattern(&mut self) -> Result<RelPattern, QueryError> {
+        let incoming = self.eat_symbol("<");
+        self.expect_symbol("-")?;
+
+        let mut rel = RelPattern {
+            slot: None,
+            types: Vec::new(),
+            direction: Direction::Both,
+            min: 1,
+            max: 1,
+        };
+        if self.eat_symbol("[") {
+            if let Some(Token::Ident(_)) = self.peek() {
+                let name = self.identifier()?;
+                let slot = self.declare(name);
+                self.relationships.insert(slot);
+                rel.slot = Some(slot);
+            }
+            if self.eat_symbol(":") {
+                loop {
+                    let position = self.position();
+                    let name = self.identifier()?;
+                    rel.types.push(name.parse().map_err(|_| QueryError::Syntax {
+                        position,
+                        message: format!("unknown relationship type '{}'", name),
+                    })?);
+                    if !self.eat_symbol("|") {
+                        break;
+                    }
+                    self.eat_symbol(":");
+                }
+            }
+            if self.eat_symbol("*") {
+                let min = match self.peek() {
+                    Some(Token::Num(_)) => Some(self.integer()?),
+                    _ => None,
+                };
+                if self.eat_symbol("..") {
+                    rel.min = min.unwrap_or(1);
+                    rel.max = match self.peek() {
+                        Some(Token::Num(_)) => self.integer()?,
+                        _ => MAX_HOPS,
+                    };
+                } else {
+                    rel.min = min.unwrap_or(1);
+                    rel.max = min.unwrap_or(MAX_HOPS);
+                }
+                if rel.min > rel.max {
+                    return self.error("relationship lower bound exceeds its upper bound");
+                }
+                if rel.slot.is_some() {
+                    return Err(QueryError::Invalid(
+                        "Variable-length relationships cannot be bound to a variable".to_string(),
+                    ));
+                }
+            }
+            self.expect_symbol("]")?;
+        }
+
+        self.expect_symbol("-")?;
+        let outgoing = self.eat_symbol(">");
+        rel.direction = match (incoming, outgoing) {
+            (false, true) => Direction::Outgoing,
+            (true, false) => Direction::Incoming,
+            (false, false) => Direction::Both,
+            (true, true) => return self.error("a relationship cannot point both ways"),
+        };
+        Ok(rel)
+    }
+
+    fn expr(&mut self) -> Result<Expr, QueryError> {
+        let mut left = self.and_expr()?;
+        while self.eat_keyword("OR") {
+            left = Expr::Or(Box::new(left), Box::new(self.and_expr()?));
+        }
+        Ok(left)
+    }
+
+    fn and_expr(&mut self) -> Result<Expr, QueryError> {
+        let mut left = self.not_expr()?;
+        while self.eat_keyword("AND") {
+            left = Expr::And(Box::new(left), Box::new(self.not_expr()?));
+        }
+        Ok(left)
+    }
+
+    fn not_expr(&mut self) -> Result<Expr, QueryError> {
+        if self.eat_keyword("NOT") {
+            return Ok(Expr::Not(Box::new(self.not_expr()?)));
+        }
+        self.comparison()
+    }
+
+    fn comparison(&mut self) -> Result<Expr, QueryError> {
+        let left = self.primary()?;
+        let op = match self.peek() {
+            Some(Token::Sym("=")) => Some(CompareOp::Eq),
+            Some(Token::Sym("<>")) => Some(CompareOp::Ne),
+            Some(Token::Sym("<")) => Some(CompareOp::Lt),
+            Some(Token::Sym("<=")) => Some(CompareOp::Le),
+            Some(Token::Sym(">")) => Some(CompareOp::Gt),
+            Some(Token::Sym(">=")) => Some(CompareOp::Ge),
+            _ => None,
+        };
+        let op = match op {
+            Some(op) => {
+                self.pos += 1;
+                op
+            }
+            None if self.eat_keyword("STARTS") => {
+                self.expect_keyword("WITH")?;
+                CompareOp::StartsWith
+            }
+            None if self.eat_keyword("ENDS") => {
+                self.expect_keyword("WITH")?;
+                CompareOp::EndsWith
+            }
+            None if self.eat_keyword("CONTAINS") => CompareOp::Contains,
+            None if self.eat_keyword("IN") => CompareOp::In,
+            None if self.eat_keyword("IS") => {
+                let negated = self.eat_keyword("NOT");
+                self.expect_keyword("NULL")?;
+                return Ok(Expr::IsNull(Box::new(left), negated));
+            }
+            None => return Ok(left),
+        };
+        let right = self.primary()?;
+        Ok(Expr::Compare(op, Box::new(left), Box::new(right)))
+    }
+
+    fn primary(&mut self) -> Result<Expr, QueryError> {
+        match self.peek().cloned() {
+            Some(Token::Str(s)) => {
+                self.pos += 1;
+                Ok(Expr::Literal(Value::String(s)))
+            }
+            Some(Token::Num(n)) => {
+                self.pos += 1;
+                Ok(Expr::Literal(number(n)))
+            }
+            Some(Token::Sym("-")) => {
+                self.pos += 1;
+                match self.peek() {
+                    Some(Token::Num(n)) => {
+                        let n = -*n;
+                        self.pos += 1;
+                        Ok(Expr::Literal(number(n)))
+                    }
+                    _ => self.unexpected("a number"),
+                }
+            }
+            Some(Token::Sym("[")) => {
+                self.pos += 1;
+                let mut items = Vec::new();
+                if !self.eat_symbol("]") {
+                    loop {
+                        items.push(self.expr()?);
+                        if !self.eat_symbol(",") {
+                            break;
+                        }
+                    }
+                    self.expect_symbol("]")?;
+                }
+                Ok(Expr::List(items))
+            }
+            Some(Token::Sym("(")) => {
+                self.pos += 1;
+                let expr = self.expr()?;
+                self.expect_symbol(")")?;
+                Ok(expr)
+            }
+            Some(Token::Ident(name)) => {
+                self.pos += 1;
+                match name.to_ascii_lowercase().as_str() {
+                    "true" => return Ok(Expr::Literal(Value::Bool(true))),
+                    "false" => return Ok(Expr::Literal(Value::Bool(false))),
+                    "null" => return Ok(Expr::Literal(Value::Null)),
+                    "count" if matches!(self.peek(), Some(Token::Sym("("))) => {
+                        self.pos += 1;
+                        if self.eat_symbol("*") {
+                            self.expect_symbol(")")?;
+                            return Ok(Expr::Count(None, false));
+                        }
+                        let distinct = self.eat_keyword("DISTINCT");
+                        let expr = self.expr()?;
+                        self.expect_symbol(")")?;
+                        return Ok(Expr::Count(Some(Box::new(expr)), distinct));
+                    }
+                    _ => {}
+                }
+                let slot = self.lookup(&name)?;
+                if self.eat_symbol(".") {
+                    Ok(Expr::Property(slot, self.identifier()?))
+                } else {
+                    Ok(Expr::Variable(slot))
+                }
+            }
+            _ => self.unexpected("an expression"),
+        }
+    }
+}
+
+/// JSON number, integral when possible
+fn number(n: f64) -> Value {
+    if n.fract() == 0.0 && n.abs() < i64::MAX as f64 {
+        Value::from(n as i64)
+    } else {
+        serde_json::Number::from_f64(n).map_or(Value::Null, Value::Number)
+    }
+}
+
+// ============================================================================
+// Evaluation
+// ============================================================================
+
+/// A relationship bound to a variable
+#[derive(Debug, Clone, Copy)]
+struct EdgeRef<'g> {
+    source: &'g Node,
+    target: &'g Node,
+    data: &'g EdgeData,
+}
+
+#[derive(Debug, Clone, Copy)]
+enum Bound<'g> {
+    Node(&'g Node),
+    Edge(EdgeRef<'g>),
+}
+
+/// A value during evaluation
+#[derive(Debug, Clone)]
+enum Val<'g> {
+    Json(Value),
+    Node(&'g Node),
+    Edge(EdgeRef<'g>),
+}
+
+impl Val<'_> {
+    fn null() -> Self {
+        Val::Json(Value::Null)
+    }
+
+    fn is_null(&self) -> bool {
+        matches!(self, Val::Json(Value::Null))
+    }
+
+    fn is_true(&self) -> bool {
+        matches!(self, Val::Json(Value::Bool(true)))
+    }
+
+    fn to_json(&self) -> Value {
+        match self {
+            Val::Json(value) => value.clone(),
+            Val::Node(node) => serde_json::to_value(node).unwrap_or(Value::Null),
+            Val::Edge(edge) => serde_json::json!({
+                "type": edge.data.edge_type.as_str(),
+                "source": edge.source.id,
+                "target": edge.target.id,
+                "line": edge.data.ref_line,
+            }),
+        }
+    }
+}
+
+type Row<'g> = Vec<Option<Bound<'g>>>;
+
+impl GraphQuery {
+    /// Parse a query.
+    pub fn parse(query: &str) -> Result<Self, QueryError> {
+        Parser::new(query)?.query()
+    }
+
+    /// Names of the returned columns
+    pub fn columns(&self) -> Vec<String> {
+        self.items.iter().map(|item| item.name.clone()).collect()
+    }
+
+    /// Run the query over a graph, visiting at most [`MAX_STEPS`] nodes and
+    /// edges.
+    pub fn execute(&self, graph: &PetCodeGraph) -> Result<QueryResult, QueryError> {
+        self.execute_with_budget(graph, MAX_STEPS)
+    }
+
+    /// Run the query over a graph, visiting at most `max_steps` nodes and
+    /// edges.
+    pub fn execute_with_budget(
+        &self,
+        graph: &PetCodeGraph,
+        max_steps: usize,
+    ) -> Result<QueryResult, QueryError> {
+        let aggregate = self.items.iter().any(|item| item.expr.is_aggregate());
+        // Without reordering or merging rows, matching can stop at the last
+        // row returned
+        let stop_after = (!aggregate && !self.distinct && self.order.is_empty())
+            .then(|| self.limit.map(|limit| self.skip + limit))
+            .flatten();
+
+        let implements = self
+            .patterns
+            .iter()
+            .flat_map(|pattern| &pattern.steps)
+            .any(|(rel, _)| rel.types.contains(&EdgeSelector::Implements));
+        let implicit = implements.then(|| ImplicitImplementations::build(graph));
+        let implicit_edge = EdgeData::uses(None, None);
+
+        let mut matcher = Matcher {
+            query: self,
+            graph,
+            implicit: implicit.as_ref(),
+            implicit_edge: &implicit_edge,
+            rows: Vec::new(),
+            stop_after,
+            steps: 0,
+            max_steps,
+        };
+        let mut row = vec![None; self.variables.len()];
+        matcher.match_pattern(0, &mut row)?;
+
+        let mut rows: Vec<Vec<Value>> = if aggregate {
+            self.aggregate(&matcher.rows)
+        } else {
+            matcher
+                .rows
+                .iter()
+                .map(|row| {
+                    self.items
+                        .iter()
+                        .map(|item| eval(&item.expr, row).to_json())
+                        .collect()
+                })
+                .collect()
+        };
+
+        if self.distinct {
+            let mut seen = HashSet::new();
+            rows.retain(|row| seen.insert(Value::Array(row.clone()).to_string()));
+        }
+        if !self.order.is_empty() {
+            rows.sort_by(|a, b| {
+                self.order
+                    .iter()
+                    .map(|&(column, descending)| {
+                        let ordering = compare_json(&a[column], &b[column]);
+                        if descending {
+                            ordering.reverse()
+                        } else {
+                            ordering
+                        }
+                    })
+                    .find(|ordering| ordering.is_ne())
+                    .unwrap_or(Ordering::Equal)
+            });
+        }
+        let rows = rows
+            .into_iter()
+            .skip(self.skip)
+            .take(self.limit.unwrap_or(usize::MAX))
+            .collect();
+
+        Ok(QueryResult {
+            columns: self.columns(),
+            rows,
+        })
+    }
+
+    /// Group rows by the non-aggregate items and count each group
+    fn aggregate(&self, rows: &[Row]) -> Vec<Vec<Value>> {
+        let mut groups: Vec<(Vec<Value>, Vec<Vec<Val>>)> = Vec::new();
+        let mut index: HashMap<String, usize> = HashMap::new();
+        for row in rows {
+            let key: Vec<Value> = self
+                .items
+                .iter()
+                .filter(|item| !item.expr.is_aggregate())
+                .map(|item| eval(&item.expr, row).to_json())
+                .collect();
+            let group = *index
+                .entry(Value::Array(key.clone()).to_string())
+                .or_insert_with(|| {
+                    groups.push((key, Vec::new()));
+                    groups.len() - 1
+                });
+            let counted = self
+                .items
+                .iter()
+                .filter_map(|item| match item.expr {
+                    Expr::Count(Some(ref expr), _) => Some(eval(expr, row)),
+                    Expr::Count(None, _) => Some(Val::Json(Value::Bool(true))),
+                    _ => None,
+                })
+                .collect();
+            groups[group].1.push(counted);
+        }
+
+        // count(*) over no rows is a single row of zero
+        if groups.is_empty() && self.items.iter().all(|item| item.expr.is_aggregate()) {
+            groups.push((Vec::new(), Vec::new()));
+        }
+
+        groups
+            .into_iter()
+            .map(|(key, counted)| {
+                let mut key = key.into_iter();
+                let mut aggregate = 0;
+                self.items
+                    .iter()
+                    .map(|item| match item.expr {
+                        Expr::Count(_, distinct) => {
+                            let values = counted
+                                .iter()
+                                .map(|values| &values[aggregate])
+                                .filter(|value| !value.is_null())
+                                .map(|value| value.to_json().to_string());
+                            let count = if distinct {
+                                values.collect::<HashSet<_>>().len()
+                            } else {
+                                values.count()
+                            };
+                            aggregate += 1;
+                            Value::from(count)
+                        }
+                        _ => key.next().unwrap_or(Value::Null),
+                    })
+                    .collect()
+            })
+            .collect()
+    }
+}
+
+struct Matcher<'q, 'g> {
+    query: &'q GraphQuery,
+    graph: &'g PetCodeGraph,
+    /// Implementations to follow for `IMPLEMENTS`, if the query has any
+    implicit: Option<&'g ImplicitImplementations>,
+    /// Data of the edges standing for implicit implementations
+    implicit_edge: &'g EdgeData,
+    rows: Vec<Row<'g>>,
+    stop_after: Option<usize>,
+    steps: usize,
+    max_steps: usize,
+}
+
+impl<'g> Matcher<'_, 'g> {
+    fn done(&self) -> bool {
+        self.stop_after.is_some_and(|n| self.rows.len() >= n)
+    }
+
+    /// Count `n` nodes or edges visited against the budget
+    fn visit(&mut self, n: usize) -> Result<(), QueryError> {
+        self.steps += n;
+        if self.steps > self.max_steps {
+            return Err(QueryError::TooManySteps(self.max_steps));
+        }
+        Ok(())
+    }
+
+    fn match_pattern(&mut self, index: usize, row: &mut Row<'g>) -> Result<(), QueryError> {
+        if self.done() {
+            return Ok(());
+        }
+        let Some(pattern) = self.query.patterns.get(index) else {
+            self.visit(1)?;
+            if self
+                .query
+                .filter
+                .as_ref()
+                .is_none_or(|filter| eval(filter, row).is_true())
+            {
+                if self.rows.len() >= MAX_ROWS {
+                    return Err(QueryError::TooManyRows(MAX_ROWS));
+                }
+                self.rows.push(row.clone());
+            }
+            return Ok(());
+        };
+
+        for node in self.candidates(&pattern.start, row) {
+            self.visit(1)?;
+            let bound = bind(row, pattern.start.slot, Bound::Node(node));
+            self.match_steps(index, 0, node, row)?;
+            unbind(row, pattern.start.slot, bound);
+            if self.done() {
+                break;
+            }
+        }
+        Ok(())
+    }
+
+    fn match_steps(
+        &mut self,
+        index: usize,
+        step: usize,
+        current: &'g Node,
+        row: &mut Row<'g>,
+    ) -> Result<(), QueryError> {
+        let pattern = &self.query.patterns[index];
+        let Some((rel, node_pattern)) = pattern.steps.get(step) else {
+            return self.match_pattern(index + 1, row);
+        };
+
+        for (edge, next) in self.expand(current, rel)? {
+            if !node_matches(node_pattern, next, row) {
+                continue;
+            }
+            if let (Some(slot), Some(edge)) = (rel.slot, edge) {
+                match row[slot] {
+                    Some(Bound::Edge(bound)) if !same_edge(&bound, &edge) => continue,
+                    _ => {}
+                }
+            }
+            let edge_bound = match (rel.slot, edge) {
+                (Some(slot), Some(edge)) => bind(row, Some(slot), Bound::Edge(edge)),
+                _ => false,
+            };
+            let node_bound = bind(row, node_pattern.slot, Bound::Node(next));
+            self.match_steps(index, step + 1, next, row)?;
+            unbind(row, node_pattern.slot, node_bound);
+            unbind(row, rel.slot, edge_bound);
+            if self.done() {
+                break;
+            }
+        }
+        Ok(())
+    }
+
+    /// Nodes matching the start of a path
+    fn candidates(&self, pattern: &NodePattern, row: &Row<'g>) -> Vec<&'g Node> {
+        if let Some(Some(Bound::Node(node))) = pattern.slot.map(|slot| row[slot]) {
+            return if node_matches(pattern, node, row) {
+                vec![node]
+            } else {
+                Vec::new()
+            };
+        }
+        let by_id = pattern
+            .properties
+            .iter()
+            .find_map(|(key, value)| match value {
+                Value::String(id) if key == "id" => Some(id.as_str()),
+                _ => None,
+            });
+        match by_id {
+            Some(id) => self
+                .graph
+                .get_node(id)
+                .filter(|node| node_matches(pattern, node, row))
+                .into_iter()
+                .collect(),
+            None => self
+                .graph
+                .iter_nodes()
+                .filter(|node| node_matches(pattern, node, row))
+                .collect(),
+        }
+    }
+
+    /// Edges one hop from a node that a relationship pattern follows
+    fn neighbors(&self, node: &'g Node, rel: &RelPattern) -> Vec<(EdgeRef<'g>, &'g Node)> {
+        let graph = self.graph;
+        let mut neighbors = Vec::new();
+        if rel.direction != Direction::Incoming {
+            for (target, data) in graph.outgoing_edges(&node.id) {
+                neighbors.push((
+                    EdgeRef {
+                        source: node,
+                        target,
+                        data,
+                    },
+                    target,
+                ));
+            }
+        }
+        if rel.direction != Direction::Outgoing {
+            for (source, data) in graph.incoming_edges(&node.id) {
+                neighbors.push((
+                    EdgeRef {
+                        source,
+                        target: node,
+                        data,
+                    },
+                    source,
+                ));
+            }
+        }
+        neighbors.retain(|(edge, _)| {
+            rel.types.is_empty() || rel.types.iter().any(|t| type_matches(*t, edge))
+        });
+
+        if let Some(implicit) = self
+            .implicit
+            .filter(|_| rel.types.contains(&EdgeSelector::Implements))
+        {
+            if rel.direction != Direction::Incoming {
+                for target in implicit
+                    .interfaces_of(&node.id)
+                    .iter()
+                    .filter_map(|id| graph.get_node(id))
+                {
+                    neighbors.push((
+                        EdgeRef {
+                            source: node,
+                            target,
+                            data: self.implicit_edge,
+                        },
+                        target,
+                    ));
+                }
+            }
+            if rel.direction != Direction::Outgoing {
+                for source in implicit
+                    .implementers(&node.id)
+                    .iter()
+                    .filter_map(|id| graph.get_node(id))
+                {
+                    neighbors.push((
+                        EdgeRef {
+                            source,
+                            target: node,
+                            data: self.implicit_edge,
+                        },
+                        source,
+                    ));
+                }
+            }
+        }
+        neighbors
+    }
+
+    /// Nodes a relationship pattern reaches from a node, with the edge for
+    /// single-hop relationships.
+    ///
+    /// Variable-length relationships are followed level by level, so a node
+    /// is reached at each path length up to `max`, not just the shortest, and
+    /// returned once if any of them is at least `min`.
+    fn expand(
+        &mut self,
+        node: &'g Node,
+        rel: &RelPattern,
+    ) -> Result<Vec<(Option<EdgeRef<'g>>, &'g Node)>, QueryError> {
+        if rel.min == 1 && rel.max == 1 {
+            let neighbors = self.neighbors(node, rel);
+            self.visit(neighbors.len())?;
+            return Ok(neighbors
+                .into_iter()
+                .map(|(edge, next)| (Some(edge), next))
+                .collect());
+        }
+
+        let mut reached = Vec::new();
+        let mut emitted: HashSet<&str> = HashSet::new();
+        let mut level = vec![node];
+        for depth in 0..=rel.max {
+            if depth >= rel.min {
+                for &current in &level {
+                    if emitted.insert(current.id.as_str()) {
+                        reached.push((None, current));
+                    }
+                }
+            }
+            if depth == rel.max {
+                break;
+            }
+
+            let mut next_level = Vec::new();
+            let mut in_level: HashSet<&str> = HashSet::new();
+            for current in level {
+                let neighbors = self.neighbors(current, rel);
+                self.visit(neighbors.len())?;
+                for (_, next) in neighbors {
+                    if in_level.insert(next.id.as_str()) {
+                        next_level.push(next);
+                    }
+                }
+            }
+            if next_level.is_empty() {
+                break;
+            }
+            level = next_level;
+        }
+        Ok(reached)
+    }
+}
+
+/// Bind a variable unless it is already bound; returns whether it was bound
+fn bind<'g>(row: &mut Row<'g>, slot: Option<usize>, value: Bound<'g>) -> bool {
+    match slot {
+        Some(slot) if row[slot].is_none() => {
+            row[slot] = Some(value);
+            true
+        }
+        _ => false,
+    }
+}
+
+fn unbind(row: &mut Row, slot: Option<usize>, bound: bool) {
+    if let (Some(slot), true) = (slot, bound) {
+        row[slot] = None;
+    }
+}
+
+fn same_edge(a: &EdgeRef, b: &EdgeRef) -> bool {
+    a.source.id == b.source.id && a.target.id == b.target.id && std::ptr::eq(a.data, b.data)
+}
+
+fn type_matches(selector: EdgeSelector, edge: &EdgeRef) -> bool {
+    selector.matches(edge.data.edge_type, edge.source, edge.target)
+}
+
+/// Check a node against a pattern and the variable the pattern binds
+fn node_matches(pattern: &NodePattern, node: &Node, row: &Row) -> bool {
+    if let Some(Some(bound)) = pattern.slot.map(|slot| row[slot]) {
+        match bound {
+            Bound::Node(bound) if bound.id == node.id => {}
+            _ => return false,
+        }
+    }
+    pattern.labels.iter().all(|group| {
+        group.iter().any(|label| {
+            node.node_type.as_str().eq_ignore_ascii_case(label)
+                || node
+                    .kind
+                    .as_deref()
+                    .is_some_and(|k| k.eq_ignore_ascii_case(label))
+                || node
+                    .subtype
+                    .as_deref()
+                    .is_some_and(|s| s.eq_ignore_ascii_case(label))
+        })
+    }) && pattern
+        .properties
+        .iter()
+        .all(|(key, value)| compare_json(&node_property(node, key), value) == Ordering::Equal)
+}
+
+/// A property of a node, null if it has none
+fn node_property(node: &Node, key: &str) -> Value {
+    let text = |s: &Option<String>| s.clone().map_or(Value::Null, Value::String);
+    match key {
+        "id" => Value::String(node.id.clone()),
+        "name" => Value::String(node.name.clone()),
+        "type" => Value::String(node.node_type.as_str().to_string()),
+        "kind" => text(&node.kind),
+        "subtype" => text(&node.subtype),
+        "file" => Value::String(node.file.clone()),
+        "line" => Value::from(node.line),
+        "end_line" => Value::from(node.end_line),
+        "text" => text(&node.text),
+        "hash" => text(&node.hash),
+        _ => {
+            let metadata = serde_json::to_value(&node.metadata).unwrap_or(Value::Null);
+            match metadata.get(key) {
+                Some(value) => value.clone(),
+                None => node
+                    .metadata
+                    .attributes
+                    .as_ref()
+                    .and_then(|attributes| attributes.get(key))
+                    .map_or(Value::Null, |value| Value::String(value.clone())),
+            }
+        }
+    }
+}
+
+/// A property of a relationship, null if it has none
+fn edge_property(edge: &EdgeRef, key: &str) -> Value {
+    match key {
+        "type" => Value::String(edge.data.edge_type.as_str().to_string()),
+        "source" => Value::String(edge.source.id.clone()),
+        "target" => Value::String(edge.target.id.clone()),
+        "line" => edge.data.ref_line.map_or(Value::Null, Value::from),
+        "ident" => edge.data.ident.clone().map_or(Value::Null, Value::String),
+        "similarity" => edge.data.similarity.map_or(Value::Null, Value::from),
+        "weight" => edge.data.weight.map_or(Value::Null, Value::from),
+        _ => Value::Null,
+    }
+}
+
+fn eval<'g>(expr: &Expr, row: &Row<'g>) -> Val<'g> {
+    let boolean = |b: bool| Val::Json(Value::Bool(b));
+    match expr {
+        Expr::Literal(value) => Val::Json(value.clone()),
+        Expr::List(items) => Val::Json(Value::Array(
+            items.iter().map(|item| eval(item, row).to_json()).collect(),
+        )),
+        Expr::Variable(slot) => match row[*slot] {
+            Some(Bound::Node(node)) => Val::Node(node),
+            Some(Bound::Edge(edge)) => Val::Edge(edge),
+            None => Val::null(),
+        },
+        Expr::Property(slot, key) => Val::Json(match row[*slot] {
+            Some(Bound::Node(node)) => node_property(node, key),
+            Some(Bound::Edge(ref edge)) => edge_property(edge, key),
+            None => Value::Null,
+        }),
+        Expr::Not(e) => boolean(!eval(e, row).is_true()),
+        Expr::And(a, b) => boolean(eval(a, row).is_true() && eval(b, row).is_true()),
+        Expr::Or(a, b) => boolean(eval(a, row).is_true() || eval(b, row).is_true()),
+        Expr::IsNull(e, negated) => boolean(eval(e, row).is_null() != *negated),
+        Expr::Compare(op, a, b) => {
+            let (a, b) = (comparable(eval(a, row)), comparable(eval(b, row)));
+            compare(*op, &a, &b).map_or(Val::null(), boolean)
+        }
+        // Aggregates are evaluated per group
+        Expr::Count(..) => Val::null(),
+    }
+}
+
+/// A value as compared: nodes and relationships by identity
+fn comparable(value: Val) -> Value {
+    match value {
+        Val::Json(value) => value,
+        Val::Node(node) => Value::String(node.id.clone()),
+        Val::Edge(edge) => Value::String(format!(
+            "{}-{}->{}",
+            edge.source.id,
+            edge.data.edge_type.as_str(),
+            edge.target.id
+        )),
+    }
+}
+
+/// Apply a comparison, or None when it does not apply to the operands
+fn compare(op: CompareOp, a: &Value, b: &Value) -> Option<bool> {
+    if a.is_null() || b.is_null() {
+        return None;
+    }
+    let strings = || Some((a.as_str()?, b.as_str()?));
+    match op {
+        CompareOp::Eq => Some(compare_json(a, b) == Ordering::Equal),
+        CompareOp::Ne => Some(compare_json(a, b) != Ordering::Equal),
+        CompareOp::Lt | CompareOp::Le | CompareOp::Gt | CompareOp::Ge => {
+            let comparable = (a.is_number() && b.is_number()) || (a.is_string() && b.is_string());
+            if !comparable {
+                return None;
+            }
+            let ordering = compare_json(a, b);
+            Some(match op {
+                CompareOp::Lt => ordering.is_lt(),
+                CompareOp::Le => ordering.is_le(),
+                CompareOp::Gt => ordering.is_gt(),
+                _ => ordering.is_ge(),
+            })
+        }
+        CompareOp::StartsWith => strings().map(|(a, b)| a.starts_with(b)),
+        CompareOp::EndsWith => strings().map(|(a, b)| a.ends_with(b)),
+        CompareOp::Contains => match a {
+            Value::Array(items) => Some(items.iter().any(|item| item == b)),
+            _ => strings().map(|(a, b)| a.contains(b)),
+        },
+        CompareOp::In => b.as_array().map(|items| {
+            items
+                .iter()
+                .any(|item| compare_json(a, item) == Ordering::Equal)
+        }),
+    }
+}
+
+/// Order JSON values: nulls last, numbers numerically, nodes by ID
+fn compare_json(a: &Value, b: &Value) -> Ordering {
+    match (a, b) {
+        (Value::Null, Value::Null) => Ordering::Equal,
+        (Value::Null, _) => Ordering::Greater,
+        (_, Value::Null) => Ordering::Less,
+        (Value::Number(x), Value::Number(y)) => x
+            .as_f64()
+            .partial_cmp(&y.as_f64())
+            .unwrap_or(Ordering::Equal),
+        (Value::String(x), Value::String(y)) => x.cmp(y),
+        (Value::Bool(x), Value::Bool(y)) => x.cmp(y),
+        (Value::Object(x), Value::Object(y)) if x.contains_key("id") => {
+            compare_json(&x["id"], y.get("id").unwrap_or(&Value::Null))
+        }
+        _ => a.to_string().cmp(&b.to_string()),
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::{CallableKind, ContainerKind, EdgeData};
+    use crate::test_support::{add_fn, uses};
+
+    /// `main` calls `Handle`, which calls `save`; `Store` defines `save`.
+    fn sample_graph() -> PetCodeGraph {
+        let mut graph = PetCodeGraph::new();
+        graph.add_node(Node::callable(
+            "cmd/main.go:main".to_string(),
+            "main".to_string(),
+            CallableKind::Function,
+            "cmd/main.go".to_string(),
+            1,
+            5,
+        ));
+        graph.add_node(Node::callable(
+            "api/server.go:Server:Handle".to_string(),
+            "Handle".to_string(),
+            CallableKind::Method,
+            "api/server.go".to_string(),
+            10,
+            20,
+        ));
+        graph.add_node(Node::container(
+            "store/db.go:Store".to_string(),
+            "Store".to_string(),
+            ContainerKind::Type,
+            Some("struct".to_string()),
+            "store/db.go".to_string(),
+            1,
+            30,
+        ));
+        graph.add_node(Node::callable(
+            "store/db.go:Store:Save".to_string(),
+            "Save".to_string(),
+            CallableKind::Method,
+            "store/db.go".to_string(),
+            5,
+            25,
+        ));
+        graph.add_edge(
+            "cmd/main.go:main",
+            "api/server.go:Server:Handle",
+            EdgeData::uses(Some(3), Some("Handle".to_string())),
+        );
+        graph.add_edge(
+            "api/server.go:Server:Handle",
+            "store/db.go:Store:Save",
+            EdgeData::uses(Some(12), Some("Save".to_string())),
+        );
+        graph.add_edge(
+            "api/server.go:Server:Handle",
+            "store/db.go:Store",
+            EdgeData::uses(Some(11), Some("Store".to_string())),
+        );
+        graph.add_edge(
+            "store/db.go:Store",
+            "store/db.go:Store:Save",
+            EdgeData::defines(),
+        );
+        graph
+    }
+
+    fn run(query: &str) -> QueryResult {
+        run_query(&sample_graph(), query).unwrap()
+    }
+
+    #[test]
+    fn test_variable_length_calls() {
+        let result = run(
+            r#"MATCH (f:Function|Method)-[:CALLS*1..3]->(g {name: "Save"})
+               RETURN f.name ORDER BY f.name"#,
+        );
+        assert_eq!(result.columns, vec!["f.name"]);
+        assert_eq!(
+            result.rows,
+            vec![vec![Value::from("Handle")], vec![Value::from("main")]]
+        );
+
+        // CALLS skips uses of types
+        let result = run("MATCH (f)-[:CALLS]->(t:Type) RETURN f");
+        assert!(result.rows.is_empty());
+        let result = run("MATCH (f)-[:USES]->(t:Struct) RETURN f.id, t.name");
+        assert_eq!(result.rows[0][1], "Store");
+    }
+
+    #[test]
+    fn test_implicit_implements() {
+        // Store implements Saver by declaring Save, without naming it
+        let mut graph = sample_graph();
+        graph.add_edge(
+            "store/db.go:Store",
+            "store/db.go:Store:Save",
+            EdgeData::contains(),
+        );
+        graph.add_node(Node::container(
+            "store/store.go:Saver".to_string(),
+            "Saver".to_string(),
+            ContainerKind::Type,
+            Some("interface".to_string()),
+            "store/store.go".to_string(),
+            1,
+            3,
+        ));
+        graph.add_node(Node::callable(
+            "store/store.go:Saver:Save".to_string(),
+            "Save".to_string(),
+            CallableKind::Method,
+            "store/store.go".to_string(),
+            2,
+            2,
+        ));
+        graph.add_edge(
+            "store/store.go:Saver",
+            "store/store.go:Saver:Save",
+            EdgeData::contains(),
+        );
+
+        let result = run_query(
+            &graph,
+            "MATCH (t)-[:IMPLEMENTS]->(i:Interface) RETURN t.name, i.name",
+        )
+        .unwrap();
+        assert_eq!(
+            result.rows,
+            vec![vec![Value::from("Store"), Value::from("Saver")]]
+        );
+
+        let result = run_query(
+            &graph,
+            r#"MATCH (i {name: "Saver"})<-[r:IMPLEMENTS]-(t) RETURN t.id, r.type, r.line"#,
+        )
+        .unwrap();
+        assert_eq!(
+            result.rows,
+            vec![vec![
+                Value::from("store/db.go:Store"),
+                Value::from("USES"),
+                Value::Null
+            ]]
+        );
+
+        // Only IMPLEMENTS follows them
+        let result = run_query(&graph, "MATCH (t)-[:USES]->(i:Interface) RETURN t").unwrap();
+        assert!(result.rows.is_empty());
+    }
+
+    #[test]
+    fn test_variable_length_minimum() {
+        // a calls c both directly and through b; f and g call each other
+        let mut graph = PetCodeGraph::new();
+        for id in ["x.go:a", "x.go:b", "x.go:c", "y.go:f", "y.go:g"] {
+            add_fn(&mut graph, id);
+        }
+        for (source, target) in [
+            ("x.go:a", "x.go:b"),
+            ("x.go:b", "x.go:c"),
+            ("x.go:a", "x.go:c"),
+            ("y.go:f", "y.go:g"),
+            ("y.go:g", "y.go:f"),
+        ] {
+            uses(&mut graph, source, target);
+        }
+
+        let result = run_query(
+            &graph,
+            r#"MATCH (a {name: "a"})-[:CALLS*2..3]->(x) RETURN x.name"#,
+        )
+        .unwrap();
+        assert_eq!(result.rows, vec![vec![Value::from("c")]]);
+
+        let result = run_query(
+            &graph,
+            "MATCH (f)-[:CALLS*2]->(f) RETURN f.name ORDER BY f.name",
+        )
+        .unwrap();
+        assert_eq!(
+            result.rows,
+            vec![vec![Value::from("f")], vec![Value::from("g")]]
+        );
+    }
+
+    #[test]
+    fn test_step_budget() {
+        let mut graph = PetCodeGraph::new();
+        for i in 0..30 {
+            add_fn(&mut graph, &format!("x.go:f{}", i));
+        }
+
+        // The filter rejects every row, but only after the cross product
+        let query =
+            GraphQuery::parse(r#"MATCH (a), (b), (c) WHERE a.name = "nope" RETURN a"#).unwrap();
+        assert_eq!(
+            query.execute_with_budget(&graph, 10_000),
+            Err(QueryError::TooManySteps(10_000))
+        );
+        assert!(query.execute(&graph).unwrap().rows.is_empty());
+    }
+
+    #[test]
+    fn test_where_and_relationships() {
+        let result = run(r#"MATCH (a)-[r:USES]->(b)
+               WHERE a.file STARTS WITH "api/" AND r.line >= 12
+               RETURN b.id AS callee, r.line"#);
+        assert_eq!(result.columns, vec!["callee", "r.line"]);
+        assert_eq!(
+            result.rows,
+            vec![vec![Value::from("store/db.go:Store:Save"), Value::from(12)]]
+        );
+
+        let result = run(r#"MATCH (s:Type)<-[:DEFINES]-(t) RETURN t"#);
+        assert!(result.rows.is_empty());
+        let result =
+            run(r#"match (m:method)<-[:defines]-(t) where not m.name in ["Handle"] return m.name"#);
+        assert_eq!(result.rows, vec![vec![Value::from("Save")]]);
+    }
+
+    #[test]
+    fn test_joins_and_count() {
+        // Callers of Save also using its type
+        let result = run(
+            "MATCH (f)-[:CALLS]->(s {name: 'Save'}), (f)-[:USES]->(t:Type)
+               RETURN f.name, t.name",
+        );
+        assert_eq!(
+            result.rows,
+            vec![vec![Value::from("Handle"), Value::from("Store")]]
+        );
+
+        let result = run("MATCH (f:Callable) RETURN f.file, count(*) ORDER BY f.file");
+        assert_eq!(result.rows.len(), 3);
+        assert_eq!(
+            result.rows[2],
+            vec![Value::from("store/db.go"), Value::from(1)]
+        );
+
+        let result = run("MATCH (f)-[:USES]->(g) RETURN count(DISTINCT f) AS callers");
+        assert_eq!(result.rows, vec![vec![Value::from(2)]]);
+        let result = run("MATCH (f:Interface) RETURN count(*)");
+        assert_eq!(result.rows, vec![vec![Value::from(0)]]);
+    }
+
+    #[test]
+    fn test_nodes_skip_and_limit() {
+        let result = run("MATCH (n:Callable) RETURN n ORDER BY n DESC SKIP 1 LIMIT 1");
+        assert_eq!(result.rows.len(), 1);
+        assert_eq!(result.rows[0][0]["id"], "cmd/main.go:main");
+        assert_eq!(result.rows[0][0]["name"], "main");
+
+        let result = run("MATCH (n) RETURN n.id LIMIT 2");
+        assert_eq!(result.rows.len(), 2);
+    }
+
+    #[test]
+    fn test_errors() {
+        let graph = sample_graph();
+        assert!(matches!(
+            run_query(&graph, "MATCH (f RETURN f"),
+            Err(QueryError::Syntax { position: 9, .. })
+        ));
+        assert!(matches!(
+            run_query(&graph, "MATCH (f)-[:CALLS_INTO]->(g) RETURN f"),
+            Err(QueryError::Syntax { .. })
+        ));
+        assert_eq!(
+            run_query(&graph, "MATCH (f) RETURN g"),
+            Err(QueryError::Invalid(
+                "Variable 'g' is not defined".to_string()
+            ))
+        );
+        assert!(matches!(
+            run_query(&graph, "MATCH (f) RETURN f.name ORDER BY f.line"),
+            Err(QueryError::Invalid(_))
+        ));
+        assert!(matches!(
+            run_query(&graph, "MATCH (f) WHERE count(*) > 1 RETURN f"),
+            Err(QueryError::Invalid(_))
+        ));
+    }
+}
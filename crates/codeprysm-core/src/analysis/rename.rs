@@ -0,0 +1,706 @@
+//! Rename Preview
+//!
+//! Lists every span that renaming a symbol would edit: its declaration, the
+//! references the index resolved to it (calls, method values, selectors
+//! through embedded fields, type uses), and for a Go type the receivers of
+//! its methods. Spans are found by matching the old name as a whole
+//! identifier on each line, skipping string literals (including struct tags)
+//! and comments.
+//!
+//! The preview also reports what the rename cannot do alone: existing symbols
+//! that already use the new name in the same scope, and interface methods or
+//! implementations that share the old name and must be renamed together to
+//! keep types satisfying their interfaces. References the index did not
+//! resolve (for example, through reflection or in unparsed files) are not
+//! found.
+
+use std::collections::{BTreeMap, BTreeSet, HashMap};
+use std::path::Path;
+
+use serde::{Deserialize, Serialize};
+use thiserror::Error;
+
+use super::navigation::Location;
+use super::{package_of, MethodSets};
+use crate::graph::{EdgeType, Node, NodeType, PetCodeGraph};
+
+/// Errors of planning a rename.
+#[derive(Debug, Clone, PartialEq, Eq, Error)]
+pub enum RenameError {
+    /// The symbol is not in the graph
+    #[error("Symbol not found: {0}")]
+    NotFound(String),
+
+    /// The new name is not an identifier
+    #[error("Invalid name '{0}': expected an identifier")]
+    InvalidName(String),
+
+    /// The new name equals the old one
+    #[error("'{0}' already has that name")]
+    SameName(String),
+
+    /// Files and packages are renamed by moving them, not by editing code
+    #[error("Cannot rename {0}: only symbols inside files can be renamed")]
+    NotRenamable(String),
+}
+
+/// What an edit renames.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, PartialOrd, Ord, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum RenameEditKind {
+    /// The symbol's declaration
+    Definition,
+    /// A reference to the symbol
+    Reference,
+    /// The receiver of a method of a renamed Go type
+    Receiver,
+}
+
+/// One span to replace.
+#[derive(Debug, Clone, PartialEq, Eq, PartialOrd, Ord, Serialize, Deserialize)]
+pub struct RenameEdit {
+    /// File path relative to the repository root
+    pub file: String,
+    /// Line (1-indexed)
+    pub line: usize,
+    /// Byte column of the span's first character (1-indexed)
+    pub column: usize,
+    /// What the span renames
+    pub kind: RenameEditKind,
+    /// Text replaced
+    pub old_text: String,
+    /// Replacement text
+    pub new_text: String,
+}
+
+/// Edits and warnings of renaming one symbol.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct RenamePlan {
+    /// Node ID of the renamed symbol
+    pub symbol: String,
+    /// Current name
+    pub old_name: String,
+    /// New name
+    pub new_name: String,
+    /// Spans to replace, sorted by file, line, and column
+    pub edits: Vec<RenameEdit>,
+    /// Symbols already named `new_name` in the same scope
+    pub conflicts: Vec<String>,
+    /// Interface methods or implementations named `old_name` that must be
+    /// renamed with the symbol to keep their types related
+    pub related: Vec<String>,
+    /// Reference lines where the old name was not found in the source,
+    /// usually because the file changed since it was indexed
+    pub unlocated: Vec<Location>,
+}
+
+/// Plan the rename of a symbol, reading sources from `workspace`.
+pub fn plan_rename(
+    graph: &PetCodeGraph,
+    id: &str,
+    new_name: &str,
+    workspace: &Path,
+) -> Result<RenamePlan, RenameError> {
+    let symbol = graph
+        .get_node(id)
+        .ok_or_else(|| RenameError::NotFound(id.to_string()))?;
+    if symbol.is_file() || symbol.kind.as_deref() == Some("package") {
+        return Err(RenameError::NotRenamable(id.to_string()));
+    }
+    if !is_identifier(new_name) {
+        return Err(RenameError::InvalidName(new_name.to_string()));
+    }
+    if new_name == symbol.name {
+        return Err(RenameError::SameName(id.to_string()));
+    }
+
+    let mut sources = Sources::new(workspace);
+    let old_name = symbol.name.as_str();
+    let mut edits = Vec::new();
+    let mut unlocated = Vec::new();
+
+    // The declaration is on its first line, unless attributes or doc
+    // comments come first
+    let declaration = (symbol.line..=symbol.end_line.max(symbol.line)).find_map(|line| {
+        let columns = sources.find(&symbol.file, line, old_name);
+        columns.first().map(|&column| (line, column))
+    });
+    if let Some((line, column)) = declaration {
+        edits.push(edit(
+            symbol,
+            line,
+            column,
+            RenameEditKind::Definition,
+            new_name,
+        ));
+    }
+
+    let mut reference_lines = BTreeSet::new();
+    for (source, edge) in graph.incoming_edges(id) {
+        if edge.edge_type == EdgeType::Uses {
+            reference_lines.insert((source.file.as_str(), edge.ref_line.unwrap_or(source.line)));
+        }
+    }
+
+    let sets = MethodSets::build(graph);
+    // Go receivers name the type without a USES edge
+    if sets.is_type(id) && !sets.is_interface(id) {
+        for method in graph.iter_nodes() {
+            if method.metadata.receiver.as_deref() == Some(old_name)
+                && package_of(&method.file) == package_of(&symbol.file)
+            {
+                for column in sources.find(&method.file, method.line, old_name) {
+                    edits.push(RenameEdit {
+                        file: method.file.clone(),
+                        line: method.line,
+                        column,
+                        kind: RenameEditKind::Receiver,
+                        old_text: old_name.to_string(),
+                        new_text: new_name.to_string(),
+                    });
+                }
+                reference_lines.remove(&(method.file.as_str(), method.line));
+            }
+        }
+    }
+
+    for (file, line) in reference_lines {
+        let columns = sources.find(file, line, old_name);
+        if columns.is_empty() {
+            unlocated.push(Location {
+                file: file.to_string(),
+                line,
+            });
+        }
+        for column in columns {
+            edits.push(RenameEdit {
+                file: file.to_string(),
+                line,
+                column,
+                kind: RenameEditKind::Reference,
+                old_text: old_name.to_string(),
+                new_text: new_name.to_string(),
+            });
+        }
+    }
+
+    // A span may be both a declaration and a reference (recursive calls)
+    edits.sort();
+    edits.dedup_by(|a, b| a.file == b.file && a.line == b.line && a.column == b.column);
+
+    Ok(RenamePlan {
+        symbol: id.to_string(),
+        old_name: old_name.to_string(),
+        new_name: new_name.to_string(),
+        edits,
+        conflicts: conflicts(graph, &sets, symbol, new_name),
+        related: related(graph, &sets, symbol),
+        unlocated,
+    })
+}
+
+impl RenamePlan {
+    /// Render the edits as a unified diff (`git apply` or `patch -p1`),
+    /// reading sources from `workspace`.
+    pub fn unified_diff(&self, workspace: &Path) -> String {
+        let mut by_file: BTreeMap<&str, BTreeMap<usize, Vec<&RenameEdit>>> = BTreeMap::new();
+        for edit in &self.edits {
+            by_file
+                .entry(edit.file.as_str())
+                .or_default()
+                .entry(edit.line)
+                .or_default()
+                .push(edit);
+        }
+
+        let mut diff = String::new();
+        for (file, lines) in by_file {
+            let Ok(content) = std::fs::read_to_string(workspace.join(file)) else {
+                continue;
+            };
+            let source: Vec<&str> = content.lines().collect();
+            let missing_newline = !content.ends_with('\n');
+            diff.push_str(&format!("--- a/{}\n+++ b/{}\n", file, file));
+
+            // Group edited lines whose context overlaps into hunks
+            let mut hunks: Vec<Vec<usize>> = Vec::new();
+            for &line in lines.keys() {
+                match hunks.last_mut() {
+                    Some(hunk) if line <= hunk[hunk.len() - 1] + 2 * DIFF_CONTEXT + 1 => {
+                        hunk.push(line)
+                    }
+                    _ => hunks.push(vec![line]),
+                }
+            }
+
+            for hunk in hunks {
+                let start = hunk[0].saturating_sub(DIFF_CONTEXT).max(1);
+                let end = (hunk[hunk.len() - 1] + DIFF_CONTEXT).min(source.len());
+                let count = end + 1 - start;
+                diff.push_str(&format!(
+                    "@@ -{},{} +{},{} @@\n",
+                    start, count, start, count
+                ));
+                for line in start..=end {
+                    let text = source[line - 1];
+                    let last = missing_newline && line == source.len();
+                    let mut push = |prefix: char, text: &str| {
+                        diff.push(prefix);
+                        diff.push_str(text);
+                        diff.push('\n');
+                        if last {
+                            diff.push_str("\\ No newline at end of file\n");
+                        }
+                    };
+                    match lines.get(&line) {
+                        Some(edits) => {
+                            push('-', text);
+                            push('+', &apply_edits(text, edits));
+                        }
+                        None => push(' ', text),
+                    }
+                }
+            }
+        }
+        diff
+    }
+}
+
+/// Unchanged lines shown around each change in a unified diff
+const DIFF_CONTEXT: usize = 3;
+
+/// Replace the spans of one line's edits
+fn apply_edits(text: &str, edits: &[&RenameEdit]) -> String {
+    let mut result = text.to_string();
+    // Right to left, so earlier columns stay valid
+    for edit in edits.iter().rev() {
+        let start = edit.column - 1;
+        let end = start + edit.old_text.len();
+        if result.get(start..end) == Some(edit.old_text.as_str()) {
+            result.replace_range(start..end, &edit.new_text);
+        }
+    }
+    result
+}
+
+fn edit(
+    node: &Node,
+    line: usize,
+    column: usize,
+    kind: RenameEditKind,
+    new_name: &str,
+) -> RenameEdit {
+    RenameEdit {
+        file: node.file.clone(),
+        line,
+        column,
+        kind,
+        old_text: node.name.clone(),
+        new_text: new_name.to_string(),
+    }
+}
+
+/// Symbols named `new_name` in the scope of `symbol`
+fn conflicts(
+    graph: &PetCodeGraph,
+    sets: &MethodSets,
+    symbol: &Node,
+    new_name: &str,
+) -> Vec<String> {
+    let owners = sets.method_owners();
+    let mut ids: BTreeSet<String> = BTreeSet::new();
+
+    // Methods clash with the other methods of their types
+    if let Some(types) = owners.get(symbol.id.as_str()) {
+        for type_id in types {
+            ids.extend(sets.method_ids(type_id, new_name).iter().cloned());
+        }
+    }
+
+    match graph.parent(&symbol.id) {
+        // Go methods are declared at file level but scoped by their type
+        _ if symbol.metadata.receiver.is_some() => {}
+        // File-level symbols share the package scope
+        Some(parent) if parent.is_file() => {
+            let package = package_of(&symbol.file);
+            ids.extend(
+                graph
+                    .iter_nodes()
+                    .filter(|n| n.name == new_name && package_of(&n.file) == package)
+                    .filter(|n| graph.parent(&n.id).is_some_and(Node::is_file))
+                    .filter(|n| n.metadata.receiver.is_none())
+                    .map(|n| n.id.clone()),
+            );
+        }
+        Some(parent) => ids.extend(
+            graph
+                .children(&parent.id)
+                .filter(|n| n.name == new_name)
+                .map(|n| n.id.clone()),
+        ),
+        None => {}
+    }
+    ids.into_iter().collect()
+}
+
+/// Interface methods and implementations linked to a method by its name
+fn related(graph: &PetCodeGraph, sets: &MethodSets, symbol: &Node) -> Vec<String> {
+    if symbol.node_type != NodeType::Callable {
+        return Vec::new();
+    }
+    let mut ids = BTreeSet::new();
+
+    // A method of an interface: the implementations' methods
+    if let Some(interface) = graph
+        .parent(&symbol.id)
+        .filter(|p| sets.is_interface(&p.id))
+    {
+        for type_id in sets.implementers(&interface.id) {
+            ids.extend(sets.method_ids(&type_id, &symbol.name).iter().cloned());
+        }
+    }
+
+    // A method of a type: the interfaces' methods it implements
+    let owners = sets.method_owners();
+    for type_id in owners.get(symbol.id.as_str()).into_iter().flatten() {
+        for interface in sets.interfaces_of(type_id) {
+            ids.extend(
+                graph
+                    .children(&interface)
+                    .filter(|n| n.name == symbol.name && n.node_type == NodeType::Callable)
+                    .map(|n| n.id.clone()),
+            );
+        }
+    }
+
+    ids.remove(&symbol.id);
+    ids.into_iter().collect()
+}
+
+/// Check if a name is an identifier in the indexed languages
+fn is_identifier(name: &str) -> bool {
+    let mut chars = name.chars();
+    chars
+        .next()
+        .is_some_and(|c| c.is_alphabetic() || c == '_' || c == '$')
+        && chars.all(is_identifier_char)
+}
+
+fn is_identifier_char(c: char) -> bool {
+    c.is_alphanumeric() || c == '_' || c == '$'
+}
+
+/// Source lines read on demand
+struct Sources<'a> {
+    workspace: &'a Path,
+    files: HashMap<String, Vec<String>>,
+}
+
+impl<'a> Sources<'a> {
+    fn new(workspace: &'a Path) -> Self {
+        Self {
+            workspace,
+            files: HashMap::new(),
+        }
+    }
+
+    /// Byte columns (1-indexed) of `name` as an identifier in code on a line
+    fn find(&mut self, file: &str, line: usize, name: &str) -> Vec<usize> {
+        let workspace = self.workspace;
+        let lines = self.files.entry(file.to_string()).or_insert_with(|| {
+            std::fs::read_to_string(workspace.join(file))
+                .map(|text| text.lines().map(String::from).collect())
+                .unwrap_or_default()
+        });
+        match line.checked_sub(1).and_then(|i| lines.get(i)) {
+            Some(text) => identifier_columns(text, name, Syntax::of(file)),
+            None => Vec::new(),
+        }
+    }
+}
+
+/// Lexical differences between languages that matter to finding identifiers
+#[derive(Debug, Clone, Copy, Default)]
+struct Syntax {
+    /// `#` starts a comment
+    hash_comments: bool,
+    /// `'` starts a lifetime unless it closes a character literal
+    lifetimes: bool,
+}
+
+impl Syntax {
+    fn of(file: &str) -> Self {
+        let extension = Path::new(file)
+            .extension()
+            .and_then(|e| e.to_str())
+            .unwrap_or_default();
+        Self {
+            hash_comments: matches!(
+                extension,
+                "py" | "pyi" | "rb" | "sh" | "toml" | "yaml" | "yml"
+            ),
+            lifetimes: extension == "rs",
+        }
+    }
+}
+
+/// Byte columns (1-indexed) where `name` occurs as a whole identifier outside
+/// string literals and comments
+fn identifier_columns(text: &str, name: &str, syntax: Syntax) -> Vec<usize> {
+    let mut columns = Vec::new();
+    let mut chars = text.char_indices().peekable();
+    let mut previous: Option<char> = None;
+    while let Some((i, c)) = chars.next() {
+        // A character literal closes after one character or an escape
+        let lifetime = c == '\''
+            && syntax.lifetimes
+            && text[i + 1..].chars().nth(1) != Some('\'')
+            && !text[i + 1..].starts_with('\\');
+        match c {
+            '"' | '\'' | '`' if !lifetime => {
+                // Skip to the closing quote; a quote left open ends the line
+                let mut escaped = false;
+                for (_, d) in chars.by_ref() {
+                    if escaped {
+                        escaped = false;
+                    } else if d == '\\' && c != '`' {
+                        escaped = true;
+                    } else if d == c {
+                        break;
+                    }
+                }
+                previous = Some(c);
+                continue;
+            }
+            '/' if text[i..].starts_with("//") => break,
+            '#' if syntax.hash_comments => break,
+            '/' if text[i..].starts_with("/*") => {
+                match text[i + 2..].find("*/") {
+                    Some(end) => {
+                        let close = i + 2 + end + 2;
+                        while chars.peek().is_some_and(|&(j, _)| j < close) {
+                            chars.next();
+                        }
+                    }
+                    None => break,
+                }
+                previous = Some(' ');
+                continue;
+            }
+            _ => {}
+        }
+
+        if text[i..].starts_with(name)
+            && !previous.is_some_and(is_identifier_char)
+            && !text[i + name.len()..]
+                .chars()
+                .next()
+                .is_some_and(is_identifier_char)
+        {
+            columns.push(i + 1);
+        }
+        previous = Some(c);
+    }
+    columns
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::{CallableKind, ContainerKind, EdgeData};
+
+    const SERVER: &str = "package api
+
+type Server struct {
+\tName string `json:\"Name\"`
+}
+
+// Handle serves a request.
+func (s *Server) Handle() {
+\th := s.Handle // Handle again
+\t_ = \"Handle\"
+}
+";
+
+    const MAIN: &str = "package main
+
+func main() {
+\tvar s api.Server
+\ts.Handle()
+}";
+
+    fn setup() -> (tempfile::TempDir, PetCodeGraph) {
+        let dir = tempfile::tempdir().unwrap();
+        std::fs::create_dir_all(dir.path().join("api")).unwrap();
+        std::fs::create_dir_all(dir.path().join("cmd")).unwrap();
+        std::fs::write(dir.path().join("api/server.go"), SERVER).unwrap();
+        std::fs::write(dir.path().join("cmd/main.go"), MAIN).unwrap();
+
+        let mut graph = PetCodeGraph::new();
+        graph.add_node(Node::container(
+            "api/server.go".to_string(),
+            "server.go".to_string(),
+            ContainerKind::File,
+            None,
+            "api/server.go".to_string(),
+            1,
+            11,
+        ));
+        graph.add_node(Node::container(
+            "api/server.go:Server".to_string(),
+            "Server".to_string(),
+            ContainerKind::Type,
+            Some("struct".to_string()),
+            "api/server.go".to_string(),
+            3,
+            5,
+        ));
+        let mut handle = Node::callable(
+            "api/server.go:Handle".to_string(),
+            "Handle".to_string(),
+            CallableKind::Method,
+            "api/server.go".to_string(),
+            8,
+            11,
+        );
+        handle.metadata.receiver = Some("Server".to_string());
+        graph.add_node(handle);
+        graph.add_node(Node::callable(
+            "cmd/main.go:main".to_string(),
+            "main".to_string(),
+            CallableKind::Function,
+            "cmd/main.go".to_string(),
+            3,
+            6,
+        ));
+        for child in ["api/server.go:Server", "api/server.go:Handle"] {
+            graph.add_edge("api/server.go", child, EdgeData::contains());
+        }
+        graph.add_edge(
+            "cmd/main.go:main",
+            "api/server.go:Handle",
+            EdgeData::uses(Some(5), Some("s.Handle".to_string())),
+        );
+        graph.add_edge(
+            "api/server.go:Handle",
+            "api/server.go:Handle",
+            EdgeData::uses(Some(9), Some("s.Handle".to_string())),
+        );
+        graph.add_edge(
+            "cmd/main.go:main",
+            "api/server.go:Server",
+            EdgeData::uses(Some(4), Some("api.Server".to_string())),
+        );
+        (dir, graph)
+    }
+
+    fn spans(plan: &RenamePlan) -> Vec<(&str, usize, usize, RenameEditKind)> {
+        plan.edits
+            .iter()
+            .map(|e| (e.file.as_str(), e.line, e.column, e.kind))
+            .collect()
+    }
+
+    #[test]
+    fn test_rename_method() {
+        let (dir, graph) = setup();
+        let plan = plan_rename(&graph, "api/server.go:Handle", "Serve", dir.path()).unwrap();
+
+        // The doc comment, the comment, and the string are left alone
+        assert_eq!(
+            spans(&plan),
+            vec![
+                ("api/server.go", 8, 18, RenameEditKind::Definition),
+                ("api/server.go", 9, 9, RenameEditKind::Reference),
+                ("cmd/main.go", 5, 4, RenameEditKind::Reference),
+            ]
+        );
+        assert!(plan.conflicts.is_empty());
+        assert!(plan.unlocated.is_empty());
+
+        let diff = plan.unified_diff(dir.path());
+        assert!(diff.contains("--- a/api/server.go\n+++ b/api/server.go\n@@ -5,7 +5,7 @@\n"));
+        assert!(
+            diff.contains("-\th := s.Handle // Handle again\n+\th := s.Serve // Handle again\n")
+        );
+        assert!(diff.contains("-\ts.Handle()\n+\ts.Serve()\n }\n\\ No newline at end of file\n"));
+    }
+
+    #[test]
+    fn test_rename_type_and_conflicts() {
+        let (dir, graph) = setup();
+        let plan = plan_rename(&graph, "api/server.go:Server", "Service", dir.path()).unwrap();
+
+        // The struct tag is a string; the receiver is renamed too
+        assert_eq!(
+            spans(&plan),
+            vec![
+                ("api/server.go", 3, 6, RenameEditKind::Definition),
+                ("api/server.go", 8, 10, RenameEditKind::Receiver),
+                ("cmd/main.go", 4, 12, RenameEditKind::Reference),
+            ]
+        );
+
+        let plan = plan_rename(&graph, "api/server.go:Server", "Handle", dir.path()).unwrap();
+        assert!(
+            plan.conflicts.is_empty(),
+            "methods do not share the package scope"
+        );
+        let mut graph = graph;
+        graph.add_node(Node::callable(
+            "api/server.go:Start".to_string(),
+            "Start".to_string(),
+            CallableKind::Function,
+            "api/server.go".to_string(),
+            20,
+            22,
+        ));
+        graph.add_edge("api/server.go", "api/server.go:Start", EdgeData::contains());
+        let plan = plan_rename(&graph, "api/server.go:Server", "Start", dir.path()).unwrap();
+        assert_eq!(plan.conflicts, vec!["api/server.go:Start"]);
+    }
+
+    #[test]
+    fn test_invalid_renames() {
+        let (dir, graph) = setup();
+        assert_eq!(
+            plan_rename(&graph, "api/server.go:Handle", "Handle", dir.path()),
+            Err(RenameError::SameName("api/server.go:Handle".to_string()))
+        );
+        assert!(matches!(
+            plan_rename(&graph, "api/server.go:Handle", "not-valid", dir.path()),
+            Err(RenameError::InvalidName(_))
+        ));
+        assert!(matches!(
+            plan_rename(&graph, "api/server.go", "x", dir.path()),
+            Err(RenameError::NotRenamable(_))
+        ));
+        assert!(matches!(
+            plan_rename(&graph, "api/missing.go:X", "Y", dir.path()),
+            Err(RenameError::NotFound(_))
+        ));
+    }
+
+    #[test]
+    fn test_identifier_columns() {
+        let go = Syntax::of("a.go");
+        assert_eq!(
+            identifier_columns("a.Foo(Foo2, Foo)", "Foo", go),
+            vec![3, 13]
+        );
+        assert_eq!(
+            identifier_columns("x = 'Foo' /* Foo */ + Foo", "Foo", go),
+            vec![23]
+        );
+        assert_eq!(identifier_columns("\"a\\\"Foo\" Foo", "Foo", go), vec![10]);
+        assert_eq!(
+            identifier_columns("Foo  # Foo", "Foo", Syntax::of("a.py")),
+            vec![1]
+        );
+        let rust = Syntax::of("a.rs");
+        assert_eq!(
+            identifier_columns("fn f<'a>(x: &'a Foo) -> char { 'F' }", "Foo", rust),
+            vec![17]
+        );
+    }
+}
@@ -0,0 +1,281 @@
+//! Churn-Weighted Risk
+//!
+//! Ranks callables for refactoring by combining how often and how recently
+//! their lines changed (see [`crate::churn`]) with how hard they are to
+//! change and how many callers a change can break:
+//!
+//! ```text
+//! score = recent churn * (1 + ln(1 + cognitive complexity))
+//!                      * (1 + ln(1 + fan-in))
+//!                      * (1 + ln(authors))
+//! ```
+//!
+//! Recent churn counts each commit with a weight halving every
+//! `half_life_days` of age, so a callable edited often last month outranks
+//! one edited as often years ago. Many distinct authors raise the score, as
+//! knowledge of the code is spread thin.
+
+use std::collections::{BTreeSet, HashMap};
+
+use serde::{Deserialize, Serialize};
+
+use super::dead_code::is_test_code;
+use super::package_of;
+use crate::churn::SymbolChurn;
+use crate::graph::{EdgeType, NodeType, PetCodeGraph};
+
+/// Seconds per day
+const DAY: f64 = 86_400.0;
+
+/// Options for risk scoring.
+#[derive(Debug, Clone, Copy)]
+pub struct RiskOptions {
+    /// Current time (Unix seconds) commit ages are measured from
+    pub now: i64,
+    /// Age in days at which a commit counts half
+    pub half_life_days: f64,
+}
+
+impl Default for RiskOptions {
+    fn default() -> Self {
+        Self {
+            now: 0,
+            half_life_days: 90.0,
+        }
+    }
+}
+
+/// Risk of one callable.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct SymbolRisk {
+    /// Node ID
+    pub id: String,
+    /// Callable name
+    pub name: String,
+    /// Package (containing directory)
+    pub package: String,
+    /// File path relative to the repository root
+    pub file: String,
+    /// Start line (1-indexed)
+    pub line: usize,
+    /// Commits that changed the callable
+    pub commits: u32,
+    /// Distinct authors of those commits
+    pub authors: u32,
+    /// Author time of the latest commit (Unix seconds)
+    pub last_changed: i64,
+    /// Days since the latest commit
+    pub days_since_change: u32,
+    /// Commits weighted by recency
+    pub recent_churn: f64,
+    /// Cognitive complexity (0 when not computed)
+    pub complexity: u32,
+    /// Distinct symbols using this callable
+    pub fan_in: usize,
+    /// Risk score
+    pub score: f64,
+}
+
+/// Score every non-test callable that changed in the history, riskiest
+/// first.
+///
+/// `churn` maps node IDs to their history, as computed by
+/// [`crate::churn::GitHistory::symbol_churn`].
+pub fn risk_report(
+    graph: &PetCodeGraph,
+    churn: &HashMap<String, SymbolChurn>,
+    options: &RiskOptions,
+) -> Vec<SymbolRisk> {
+    let mut callers: HashMap<&str, BTreeSet<&str>> = HashMap::new();
+    for (source, target, _) in graph.edges_by_type(EdgeType::Uses) {
+        if source.id != target.id {
+            callers
+                .entry(target.id.as_str())
+                .or_default()
+                .insert(source.id.as_str());
+        }
+    }
+
+    let mut report: Vec<SymbolRisk> = graph
+        .iter_nodes()
+        .filter(|n| n.node_type == NodeType::Callable && !is_test_code(n))
+        .filter_map(|node| {
+            let history = churn.get(&node.id)?;
+            let last_changed = history.last_changed()?;
+            let recent_churn: f64 = history
+                .times
+                .iter()
+                .map(|&time| 0.5f64.powf(age_days(options.now, time) / options.half_life_days))
+                .sum();
+            let complexity = node.metadata.cognitive_complexity.unwrap_or(0);
+            let fan_in = callers.get(node.id.as_str()).map_or(0, BTreeSet::len);
+            let score = recent_churn
+                * (1.0 + f64::from(complexity).ln_1p())
+                * (1.0 + (fan_in as f64).ln_1p())
+                * (1.0 + f64::from(history.authors.max(1)).ln());
+
+            Some(SymbolRisk {
+                id: node.id.clone(),
+                name: node.name.clone(),
+                package: package_of(&node.file).to_string(),
+                file: node.file.clone(),
+                line: node.line,
+                commits: history.commits,
+                authors: history.authors,
+                last_changed,
+                days_since_change: age_days(options.now, last_changed) as u32,
+                recent_churn,
+                complexity,
+                fan_in,
+                score,
+            })
+        })
+        .collect();
+
+    report.sort_by(|a, b| b.score.total_cmp(&a.score).then_with(|| a.id.cmp(&b.id)));
+    report
+}
+
+/// Record churn and risk on callable metadata (`churn_commits`,
+/// `churn_authors`, `last_changed`, and `risk_score`, rounded).
+///
+/// Values from a previous history are cleared first. Returns the number of
+/// annotated callables.
+pub fn apply_risk(graph: &mut PetCodeGraph, report: &[SymbolRisk]) -> usize {
+    let by_id: HashMap<&str, &SymbolRisk> = report.iter().map(|r| (r.id.as_str(), r)).collect();
+    let mut annotated = 0;
+    for node in graph.inner_mut().node_weights_mut() {
+        let metadata = &mut node.metadata;
+        metadata.churn_commits = None;
+        metadata.churn_authors = None;
+        metadata.last_changed = None;
+        metadata.risk_score = None;
+        if let Some(risk) = by_id.get(node.id.as_str()) {
+            metadata.churn_commits = Some(risk.commits);
+            metadata.churn_authors = Some(risk.authors);
+            metadata.last_changed = Some(risk.last_changed);
+            metadata.risk_score = Some(risk.score.round() as u32);
+            annotated += 1;
+        }
+    }
+    annotated
+}
+
+/// Age in days of a commit at `now`, never negative
+fn age_days(now: i64, time: i64) -> f64 {
+    (now - time).max(0) as f64 / DAY
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::EdgeData;
+    use crate::test_support::function;
+
+    const NOW: i64 = 1_700_000_000;
+    const DAYS: i64 = 86_400;
+
+    fn add_fn(graph: &mut PetCodeGraph, id: &str, cognitive: u32) {
+        let mut node = function(id);
+        node.end_line = 10;
+        node.metadata.cognitive_complexity = Some(cognitive);
+        graph.add_node(node);
+    }
+
+    fn history(times: &[i64], authors: u32) -> SymbolChurn {
+        SymbolChurn {
+            commits: times.len() as u32,
+            authors,
+            times: times.to_vec(),
+        }
+    }
+
+    fn sample() -> (PetCodeGraph, HashMap<String, SymbolChurn>) {
+        let mut graph = PetCodeGraph::new();
+        add_fn(&mut graph, "billing/charge.go:Charge", 20);
+        add_fn(&mut graph, "billing/charge.go:format", 1);
+        add_fn(&mut graph, "billing/legacy.go:Reconcile", 20);
+        add_fn(&mut graph, "billing/charge_test.go:TestCharge", 5);
+        add_fn(&mut graph, "api/handler.go:Pay", 2);
+        for caller in ["api/handler.go:Pay", "billing/charge_test.go:TestCharge"] {
+            graph.add_edge(
+                caller,
+                "billing/charge.go:Charge",
+                EdgeData::uses(Some(3), None),
+            );
+        }
+
+        let churn = HashMap::from([
+            (
+                "billing/charge.go:Charge".to_string(),
+                history(&[NOW - 2 * DAYS, NOW - 30 * DAYS, NOW - 60 * DAYS], 3),
+            ),
+            (
+                "billing/charge.go:format".to_string(),
+                history(&[NOW - 2 * DAYS, NOW - 30 * DAYS, NOW - 60 * DAYS], 1),
+            ),
+            // As often and as complex as Charge, but years ago
+            (
+                "billing/legacy.go:Reconcile".to_string(),
+                history(&[NOW - 900 * DAYS, NOW - 950 * DAYS, NOW - 1000 * DAYS], 3),
+            ),
+            (
+                "billing/charge_test.go:TestCharge".to_string(),
+                history(&[NOW - DAYS], 1),
+            ),
+        ]);
+        (graph, churn)
+    }
+
+    #[test]
+    fn test_risk_ranking() {
+        let (graph, churn) = sample();
+        let options = RiskOptions {
+            now: NOW,
+            ..Default::default()
+        };
+        let report = risk_report(&graph, &churn, &options);
+
+        let ids: Vec<&str> = report.iter().map(|r| r.id.as_str()).collect();
+        assert_eq!(
+            ids,
+            vec![
+                "billing/charge.go:Charge",
+                "billing/charge.go:format",
+                "billing/legacy.go:Reconcile"
+            ]
+        );
+
+        let charge = &report[0];
+        assert_eq!((charge.commits, charge.authors), (3, 3));
+        assert_eq!((charge.complexity, charge.fan_in), (20, 2));
+        assert_eq!(charge.days_since_change, 2);
+        assert_eq!(charge.last_changed, NOW - 2 * DAYS);
+        // Commits 2, 30, and 60 days old with a 90-day half-life
+        assert!((charge.recent_churn - 2.41).abs() < 0.01);
+    }
+
+    #[test]
+    fn test_apply_risk() {
+        let (mut graph, churn) = sample();
+        let options = RiskOptions {
+            now: NOW,
+            ..Default::default()
+        };
+        let report = risk_report(&graph, &churn, &options);
+        assert_eq!(apply_risk(&mut graph, &report), 3);
+
+        let charge = graph.get_node("billing/charge.go:Charge").unwrap();
+        assert_eq!(charge.metadata.churn_commits, Some(3));
+        assert_eq!(charge.metadata.last_changed, Some(NOW - 2 * DAYS));
+        assert_eq!(
+            charge.metadata.risk_score,
+            Some(report[0].score.round() as u32)
+        );
+
+        // A later history without the callable clears it
+        assert_eq!(apply_risk(&mut graph, &report[1..]), 2);
+        let charge = graph.get_node("billing/charge.go:Charge").unwrap();
+        assert!(charge.metadata.risk_score.is_none());
+    }
+}
@@ -0,0 +1,119 @@
+//! SARIF Output
+//!
+//! Minimal [SARIF 2.1.0](https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html)
+//! log generation so analysis findings can be uploaded to code scanning tools
+//! (e.g., GitHub code scanning) or consumed by editors.
+
+use serde_json::{json, Value};
+
+/// SARIF schema URI
+const SARIF_SCHEMA: &str = "https://json.schemastore.org/sarif-2.1.0.json";
+
+/// A rule reported by the tool.
+#[derive(Debug, Clone)]
+pub struct SarifRule {
+    /// Stable rule identifier (e.g., "dead-code")
+    pub id: String,
+    /// One-line description of what the rule detects
+    pub description: String,
+}
+
+/// A single finding.
+#[derive(Debug, Clone)]
+pub struct SarifResult {
+    /// Identifier of the rule that produced this finding
+    pub rule_id: String,
+    /// Severity: "error", "warning", or "note"
+    pub level: String,
+    /// Human-readable message
+    pub message: String,
+    /// File path relative to the repository root
+    pub file: String,
+    /// Start line (1-indexed)
+    pub start_line: usize,
+    /// End line (1-indexed)
+    pub end_line: usize,
+}
+
+/// Build a SARIF log with a single run of the `codeprysm` tool.
+pub fn sarif_log(rules: &[SarifRule], results: &[SarifResult]) -> Value {
+    let rules: Vec<Value> = rules
+        .iter()
+        .map(|rule| {
+            json!({
+                "id": rule.id,
+                "shortDescription": { "text": rule.description },
+            })
+        })
+        .collect();
+
+    let results: Vec<Value> = results
+        .iter()
+        .map(|result| {
+            json!({
+                "ruleId": result.rule_id,
+                "level": result.level,
+                "message": { "text": result.message },
+                "locations": [{
+                    "physicalLocation": {
+                        "artifactLocation": { "uri": result.file },
+                        "region": {
+                            "startLine": result.start_line.max(1),
+                            "endLine": result.end_line.max(result.start_line).max(1),
+                        },
+                    },
+                }],
+            })
+        })
+        .collect();
+
+    json!({
+        "$schema": SARIF_SCHEMA,
+        "version": "2.1.0",
+        "runs": [{
+            "tool": {
+                "driver": {
+                    "name": "codeprysm",
+                    "version": env!("CARGO_PKG_VERSION"),
+                    "rules": rules,
+                },
+            },
+            "results": results,
+        }],
+    })
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_sarif_log_structure() {
+        let rules = vec![SarifRule {
+            id: "dead-code".to_string(),
+            description: "Unreachable symbol".to_string(),
+        }];
+        let results = vec![SarifResult {
+            rule_id: "dead-code".to_string(),
+            level: "warning".to_string(),
+            message: "'helper' is never used".to_string(),
+            file: "pkg/util.go".to_string(),
+            start_line: 12,
+            end_line: 20,
+        }];
+
+        let log = sarif_log(&rules, &results);
+        assert_eq!(log["version"], "2.1.0");
+        assert_eq!(
+            log["runs"][0]["tool"]["driver"]["rules"][0]["id"],
+            "dead-code"
+        );
+
+        let result = &log["runs"][0]["results"][0];
+        assert_eq!(result["ruleId"], "dead-code");
+        let location = &result["locations"][0]["physicalLocation"];
+        assert_eq!(location["artifactLocation"]["uri"], "pkg/util.go");
+        assert_eq!(location["region"]["startLine"], 12);
+        assert_eq!(location["region"]["endLine"], 20);
+    }
+}
@@ -0,0 +1,170 @@
+//! Index Statistics
+//!
+//! Summarizes an index for sanity checks after a run: node and edge counts by
+//! kind and language, and how many references resolved to a definition in the
+//! index. Unresolved references include calls into external dependencies and
+//! the standard library, so a low rate is expected for thin wrappers; a sudden
+//! drop usually means a parser or query regression.
+
+use std::collections::BTreeMap;
+use std::path::Path;
+
+use serde::{Deserialize, Serialize};
+
+use super::package_of;
+use crate::graph::{ContainerKind, PetCodeGraph};
+use crate::parser::SupportedLanguage;
+
+/// Unresolved reference count of one package.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct PackageResolution {
+    /// Package (containing directory)
+    pub package: String,
+    /// References resolved to an indexed definition
+    pub resolved: u32,
+    /// References to names not defined in the index
+    pub unresolved: u32,
+}
+
+/// Statistics of an index.
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+pub struct IndexStats {
+    /// Total nodes
+    pub node_count: usize,
+    /// Total edges
+    pub edge_count: usize,
+    /// Nodes by "Type/kind" (e.g., "Callable/function")
+    pub nodes_by_kind: BTreeMap<String, usize>,
+    /// Edges by type (e.g., "USES")
+    pub edges_by_type: BTreeMap<String, usize>,
+    /// Source files by language
+    pub files_by_language: BTreeMap<String, usize>,
+    /// Nodes by language of their file
+    pub nodes_by_language: BTreeMap<String, usize>,
+    /// References resolved to an indexed definition
+    pub resolved_refs: u32,
+    /// References to names not defined in the index
+    pub unresolved_refs: u32,
+    /// Share of references resolved, in percent (None if no references recorded)
+    pub resolution_rate: Option<f64>,
+    /// Packages with unresolved references, most unresolved first
+    pub packages: Vec<PackageResolution>,
+}
+
+/// Compute statistics for a graph.
+pub fn index_stats(graph: &PetCodeGraph) -> IndexStats {
+    let mut stats = IndexStats {
+        node_count: graph.node_count(),
+        edge_count: graph.edge_count(),
+        ..Default::default()
+    };
+
+    let mut packages: BTreeMap<&str, (u32, u32)> = BTreeMap::new();
+    for node in graph.iter_nodes() {
+        let kind = match node.kind.as_deref() {
+            Some(kind) => format!("{}/{}", node.node_type.as_str(), kind),
+            None => node.node_type.as_str().to_string(),
+        };
+        *stats.nodes_by_kind.entry(kind).or_default() += 1;
+
+        let language = SupportedLanguage::from_path(Path::new(&node.file));
+        if let Some(language) = language {
+            *stats
+                .nodes_by_language
+                .entry(language.as_str().to_string())
+                .or_default() += 1;
+        }
+
+        if node.kind.as_deref() != Some(ContainerKind::File.as_str()) {
+            continue;
+        }
+        if let Some(language) = language {
+            *stats
+                .files_by_language
+                .entry(language.as_str().to_string())
+                .or_default() += 1;
+        }
+        let resolved = node.metadata.resolved_refs.unwrap_or(0);
+        let unresolved = node.metadata.unresolved_refs.unwrap_or(0);
+        stats.resolved_refs += resolved;
+        stats.unresolved_refs += unresolved;
+        let entry = packages.entry(package_of(&node.file)).or_default();
+        entry.0 += resolved;
+        entry.1 += unresolved;
+    }
+
+    for edge in graph.iter_edges() {
+        *stats
+            .edges_by_type
+            .entry(edge.edge_type.as_str().to_string())
+            .or_default() += 1;
+    }
+
+    let total = stats.resolved_refs + stats.unresolved_refs;
+    stats.resolution_rate =
+        (total > 0).then(|| f64::from(stats.resolved_refs) * 100.0 / f64::from(total));
+
+    stats.packages = packages
+        .into_iter()
+        .filter(|(_, (_, unresolved))| *unresolved > 0)
+        .map(|(package, (resolved, unresolved))| PackageResolution {
+            package: package.to_string(),
+            resolved,
+            unresolved,
+        })
+        .collect();
+    stats.packages.sort_by(|a, b| {
+        b.unresolved
+            .cmp(&a.unresolved)
+            .then_with(|| a.package.cmp(&b.package))
+    });
+    stats
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::{CallableKind, EdgeData, Node};
+
+    fn add_file(graph: &mut PetCodeGraph, path: &str, resolved: u32, unresolved: u32) {
+        let mut node = Node::source_file(path.to_string(), path.to_string(), String::new(), 10);
+        node.metadata.resolved_refs = Some(resolved);
+        node.metadata.unresolved_refs = Some(unresolved);
+        graph.add_node(node);
+    }
+
+    #[test]
+    fn test_index_stats() {
+        let mut graph = PetCodeGraph::new();
+        add_file(&mut graph, "api/server.go", 6, 2);
+        add_file(&mut graph, "api/routes.go", 2, 0);
+        add_file(&mut graph, "scripts/gen.py", 0, 5);
+        graph.add_node(Node::callable(
+            "api/server.go:Serve".to_string(),
+            "Serve".to_string(),
+            CallableKind::Function,
+            "api/server.go".to_string(),
+            1,
+            5,
+        ));
+        graph.add_edge("api/server.go", "api/server.go:Serve", EdgeData::contains());
+
+        let stats = index_stats(&graph);
+        assert_eq!(stats.node_count, 4);
+        assert_eq!(stats.nodes_by_kind["Container/file"], 3);
+        assert_eq!(stats.nodes_by_kind["Callable/function"], 1);
+        assert_eq!(stats.edges_by_type["CONTAINS"], 1);
+        assert_eq!(stats.files_by_language["go"], 2);
+        assert_eq!(stats.files_by_language["python"], 1);
+        assert_eq!(stats.nodes_by_language["go"], 3);
+
+        assert_eq!((stats.resolved_refs, stats.unresolved_refs), (8, 7));
+        assert_eq!(stats.resolution_rate, Some(800.0 / 15.0));
+        let packages: Vec<(&str, u32)> = stats
+            .packages
+            .iter()
+            .map(|p| (p.package.as_str(), p.unresolved))
+            .collect();
+        assert_eq!(packages, vec![("scripts", 5), ("api", 2)]);
+    }
+}
@@ -0,0 +1,439 @@
+//! Ego-Graph Extraction
+//!
+//! Extracts the neighborhood of a symbol: every node within a given number of
+//! hops, and the edges among them. Most visualization and LLM-context use cases
+//! need such a bounded slice rather than the whole graph.
+
+use std::collections::{HashMap, HashSet, VecDeque};
+
+use super::implementers::{is_interface, MethodSets};
+use crate::graph::{EdgeData, EdgeType, Node, NodeType, PetCodeGraph};
+
+/// An edge type, or one of the derived relationships the graph stores as
+/// `USES` edges.
+///
+/// Shared by ego-graph extraction and the query language so both accept the
+/// same names.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum EdgeSelector {
+    /// Edges of one stored type
+    Edge(EdgeType),
+    /// USES between two callables
+    Calls,
+    /// A type to an interface or trait it implements: USES edges between
+    /// them, plus the implementations matched by method set alone (see
+    /// [`ImplicitImplementations`])
+    Implements,
+}
+
+impl EdgeSelector {
+    /// Check if an edge between `source` and `target` is selected
+    ///
+    /// Implicit implementations have no stored edge to check; traversals
+    /// selecting [`EdgeSelector::Implements`] add them separately.
+    pub fn matches(&self, edge_type: EdgeType, source: &Node, target: &Node) -> bool {
+        match self {
+            EdgeSelector::Edge(selected) => edge_type == *selected,
+            EdgeSelector::Calls => {
+                edge_type == EdgeType::Uses
+                    && source.node_type == NodeType::Callable
+                    && target.node_type == NodeType::Callable
+            }
+            EdgeSelector::Implements => {
+                edge_type == EdgeType::Uses
+                    && source.node_type == NodeType::Container
+                    && is_interface(target)
+            }
+        }
+    }
+}
+
+impl From<EdgeType> for EdgeSelector {
+    fn from(edge_type: EdgeType) -> Self {
+        EdgeSelector::Edge(edge_type)
+    }
+}
+
+impl std::str::FromStr for EdgeSelector {
+    type Err = String;
+
+    /// Parse an edge type, `calls`, or `implements` case-insensitively
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        if s.eq_ignore_ascii_case("calls") {
+            Ok(EdgeSelector::Calls)
+        } else if s.eq_ignore_ascii_case("implements") {
+            Ok(EdgeSelector::Implements)
+        } else {
+            s.parse()
+                .map(EdgeSelector::Edge)
+                .map_err(|e: String| format!("{}, calls, implements", e))
+        }
+    }
+}
+
+/// Implementations matched by method set that no USES edge records, such as
+/// Go types satisfying an interface without naming it.
+///
+/// Traversals selecting [`EdgeSelector::Implements`] follow these pairs as if
+/// they were USES edges without a line or identifier.
+#[derive(Debug, Default)]
+pub struct ImplicitImplementations {
+    /// Concrete type ID -> interface IDs
+    interfaces: HashMap<String, Vec<String>>,
+    /// Interface ID -> concrete type IDs
+    implementers: HashMap<String, Vec<String>>,
+}
+
+impl ImplicitImplementations {
+    /// Match every type in the graph against every interface by method set
+    pub fn build(graph: &PetCodeGraph) -> Self {
+        let sets = MethodSets::build(graph);
+        let mut implicit = Self::default();
+
+        for node in graph.iter_nodes().filter(|n| sets.is_type(&n.id)) {
+            for interface in sets.interfaces_of(&node.id) {
+                let stored = graph.outgoing_edges(&node.id).any(|(target, edge)| {
+                    target.id == interface && edge.edge_type == EdgeType::Uses
+                });
+                if stored {
+                    continue;
+                }
+                implicit
+                    .implementers
+                    .entry(interface.clone())
+                    .or_default()
+                    .push(node.id.clone());
+                implicit
+                    .interfaces
+                    .entry(node.id.clone())
+                    .or_default()
+                    .push(interface);
+            }
+        }
+        implicit
+    }
+
+    /// Interfaces a type implements implicitly, sorted by ID
+    pub fn interfaces_of(&self, type_id: &str) -> &[String] {
+        self.interfaces
+            .get(type_id)
+            .map(Vec::as_slice)
+            .unwrap_or_default()
+    }
+
+    /// Types implementing an interface implicitly
+    pub fn implementers(&self, interface_id: &str) -> &[String] {
+        self.implementers
+            .get(interface_id)
+            .map(Vec::as_slice)
+            .unwrap_or_default()
+    }
+}
+
+/// Which edge directions to follow when expanding the neighborhood.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default)]
+pub enum EgoDirection {
+    /// Only follow edges out of a node (what it uses, contains, ...)
+    Outgoing,
+    /// Only follow edges into a node (what uses or contains it)
+    Incoming,
+    /// Follow edges both ways
+    #[default]
+    Both,
+}
+
+/// Options for ego-graph extraction.
+#[derive(Debug, Clone)]
+pub struct EgoOptions {
+    /// Maximum number of hops from the center
+    pub radius: usize,
+    /// Edge types to follow and keep (None = all)
+    pub edge_types: Option<Vec<EdgeSelector>>,
+    /// Directions to follow
+    pub direction: EgoDirection,
+    /// Stop expanding once this many nodes are collected (None = unbounded)
+    pub max_nodes: Option<usize>,
+}
+
+impl Default for EgoOptions {
+    fn default() -> Self {
+        Self {
+            radius: 2,
+            edge_types: None,
+            direction: EgoDirection::Both,
+            max_nodes: None,
+        }
+    }
+}
+
+impl EgoOptions {
+    /// Check if implicit implementations are followed
+    fn follows_implementations(&self) -> bool {
+        self.edge_types
+            .as_ref()
+            .is_some_and(|types| types.contains(&EdgeSelector::Implements))
+    }
+
+    /// Check if an edge between `source` and `target` is selected
+    fn follows(&self, edge_type: EdgeType, source: &Node, target: &Node) -> bool {
+        self.edge_types.as_ref().is_none_or(|types| {
+            types
+                .iter()
+                .any(|selector| selector.matches(edge_type, source, target))
+        })
+    }
+}
+
+/// Extract the neighborhood of `center` as a new graph.
+///
+/// Nodes are collected breadth-first, so when `max_nodes` cuts the expansion
+/// short the closest nodes are kept. The result contains every selected edge
+/// between collected nodes, not just the ones traversed, with implicit
+/// implementations as USES edges. Returns an empty graph if `center` does not
+/// exist.
+pub fn ego_graph(graph: &PetCodeGraph, center: &str, options: &EgoOptions) -> PetCodeGraph {
+    let mut subgraph = PetCodeGraph::new();
+    if graph.get_node(center).is_none() {
+        return subgraph;
+    }
+    let implicit = options
+        .follows_implementations()
+        .then(|| ImplicitImplementations::build(graph));
+
+    let limit = options.max_nodes.unwrap_or(usize::MAX);
+    let mut selected: Vec<&str> = vec![center];
+    let mut visited: HashSet<&str> = HashSet::from([center]);
+    let mut queue: VecDeque<(&str, usize)> = VecDeque::from([(center, 0)]);
+
+    'expand: while let Some((current, depth)) = queue.pop_front() {
+        if depth >= options.radius {
+            continue;
+        }
+        let Some(node) = graph.get_node(current) else {
+            continue;
+        };
+
+        let outgoing = graph
+            .outgoing_edges(current)
+            .filter(|_| options.direction != EgoDirection::Incoming)
+            .map(|(neighbor, edge)| (neighbor, edge, node, neighbor));
+        let incoming = graph
+            .incoming_edges(current)
+            .filter(|_| options.direction != EgoDirection::Outgoing)
+            .map(|(neighbor, edge)| (neighbor, edge, neighbor, node));
+        let mut neighbors: Vec<&Node> = outgoing
+            .chain(incoming)
+            .filter(|(_, edge, source, target)| options.follows(edge.edge_type, source, target))
+            .map(|(neighbor, ..)| neighbor)
+            .collect();
+
+        if let Some(implicit) = &implicit {
+            let interfaces = implicit
+                .interfaces_of(current)
+                .iter()
+                .filter(|_| options.direction != EgoDirection::Incoming);
+            let implementers = implicit
+                .implementers(current)
+                .iter()
+                .filter(|_| options.direction != EgoDirection::Outgoing);
+            neighbors.extend(
+                interfaces
+                    .chain(implementers)
+                    .filter_map(|id| graph.get_node(id)),
+            );
+        }
+
+        for neighbor in neighbors {
+            if !visited.insert(neighbor.id.as_str()) {
+                continue;
+            }
+            if selected.len() >= limit {
+                break 'expand;
+            }
+            selected.push(neighbor.id.as_str());
+            queue.push_back((neighbor.id.as_str(), depth + 1));
+        }
+    }
+
+    let keep: HashSet<&str> = selected.iter().copied().collect();
+    for id in &selected {
+        if let Some(node) = graph.get_node(id) {
+            subgraph.add_node(node.clone());
+        }
+    }
+    for edge in graph.iter_edges() {
+        if !keep.contains(edge.source.as_str()) || !keep.contains(edge.target.as_str()) {
+            continue;
+        }
+        let (Some(source), Some(target)) =
+            (graph.get_node(&edge.source), graph.get_node(&edge.target))
+        else {
+            continue;
+        };
+        if options.follows(edge.edge_type, source, target) {
+            subgraph.add_edge_from_struct(&edge);
+        }
+    }
+    if let Some(implicit) = &implicit {
+        for id in &selected {
+            for interface in implicit.interfaces_of(id) {
+                if keep.contains(interface.as_str()) {
+                    subgraph.add_edge(id, interface, EdgeData::uses(None, None));
+                }
+            }
+        }
+    }
+    subgraph
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::{CallableKind, ContainerKind, Node};
+    use crate::test_support::add_fn;
+
+    /// Chain a -> b -> c -> d with a containing file node for a
+    fn sample_graph() -> PetCodeGraph {
+        let mut graph = PetCodeGraph::new();
+        graph.add_node(Node::container(
+            "x.go".to_string(),
+            "x.go".to_string(),
+            ContainerKind::File,
+            None,
+            "x.go".to_string(),
+            1,
+            20,
+        ));
+        for id in ["x.go:a", "x.go:b", "x.go:c", "x.go:d"] {
+            add_fn(&mut graph, id);
+        }
+        graph.add_edge("x.go", "x.go:a", EdgeData::contains());
+        for (source, target) in [
+            ("x.go:a", "x.go:b"),
+            ("x.go:b", "x.go:c"),
+            ("x.go:c", "x.go:d"),
+        ] {
+            graph.add_edge(source, target, EdgeData::uses(Some(1), None));
+        }
+        graph
+    }
+
+    fn ids(graph: &PetCodeGraph) -> Vec<&str> {
+        let mut ids: Vec<&str> = graph.iter_nodes().map(|n| n.id.as_str()).collect();
+        ids.sort();
+        ids
+    }
+
+    #[test]
+    fn test_radius_and_edge_filter() {
+        let graph = sample_graph();
+
+        let options = EgoOptions {
+            radius: 1,
+            ..Default::default()
+        };
+        let sub = ego_graph(&graph, "x.go:b", &options);
+        assert_eq!(ids(&sub), vec!["x.go:a", "x.go:b", "x.go:c"]);
+        assert_eq!(sub.edge_count(), 2);
+
+        let options = EgoOptions {
+            radius: 2,
+            edge_types: Some(vec![EdgeSelector::Edge(EdgeType::Uses)]),
+            direction: EgoDirection::Incoming,
+            max_nodes: None,
+        };
+        let sub = ego_graph(&graph, "x.go:c", &options);
+        assert_eq!(ids(&sub), vec!["x.go:a", "x.go:b", "x.go:c"]);
+
+        assert_eq!(ego_graph(&graph, "missing", &options).node_count(), 0);
+    }
+
+    #[test]
+    fn test_max_nodes_keeps_closest() {
+        let graph = sample_graph();
+        let options = EgoOptions {
+            radius: 3,
+            direction: EgoDirection::Outgoing,
+            max_nodes: Some(2),
+            ..Default::default()
+        };
+        let sub = ego_graph(&graph, "x.go:a", &options);
+        assert_eq!(ids(&sub), vec!["x.go:a", "x.go:b"]);
+        assert_eq!(sub.edge_count(), 1);
+    }
+
+    #[test]
+    fn test_calls_and_implements_selectors() {
+        let mut graph = sample_graph();
+        for (id, subtype) in [
+            ("x.go:T", "struct"),
+            ("x.go:I", "interface"),
+            ("x.go:J", "interface"),
+        ] {
+            graph.add_node(Node::container(
+                id.to_string(),
+                id.rsplit(':').next().unwrap().to_string(),
+                ContainerKind::Type,
+                Some(subtype.to_string()),
+                "x.go".to_string(),
+                3,
+                5,
+            ));
+        }
+        graph.add_edge("x.go:a", "x.go:T", EdgeData::uses(Some(1), None));
+        graph.add_edge("x.go:T", "x.go:I", EdgeData::uses(Some(3), None));
+        // T implements J implicitly, by declaring its only method
+        for owner in ["x.go:T", "x.go:J"] {
+            let id = format!("{}:Run", owner);
+            graph.add_node(Node::callable(
+                id.clone(),
+                "Run".to_string(),
+                CallableKind::Method,
+                "x.go".to_string(),
+                4,
+                4,
+            ));
+            graph.add_edge(owner, &id, EdgeData::contains());
+        }
+
+        let options = EgoOptions {
+            radius: 3,
+            edge_types: Some(vec![EdgeSelector::Calls]),
+            direction: EgoDirection::Outgoing,
+            max_nodes: None,
+        };
+        let sub = ego_graph(&graph, "x.go:a", &options);
+        assert_eq!(ids(&sub), vec!["x.go:a", "x.go:b", "x.go:c", "x.go:d"]);
+
+        let options = EgoOptions {
+            radius: 1,
+            edge_types: Some(vec![EdgeSelector::Implements]),
+            ..Default::default()
+        };
+        let sub = ego_graph(&graph, "x.go:T", &options);
+        assert_eq!(ids(&sub), vec!["x.go:I", "x.go:J", "x.go:T"]);
+        assert_eq!(sub.edge_count(), 2);
+        assert!(sub
+            .outgoing_edges("x.go:T")
+            .any(|(target, edge)| target.id == "x.go:J" && edge.edge_type == EdgeType::Uses));
+
+        let options = EgoOptions {
+            direction: EgoDirection::Incoming,
+            ..options
+        };
+        let sub = ego_graph(&graph, "x.go:J", &options);
+        assert_eq!(ids(&sub), vec!["x.go:J", "x.go:T"]);
+    }
+
+    #[test]
+    fn test_parse_edge_selector() {
+        assert_eq!("CALLS".parse(), Ok(EdgeSelector::Calls));
+        assert_eq!("implements".parse(), Ok(EdgeSelector::Implements));
+        assert_eq!(
+            "depends_on".parse(),
+            Ok(EdgeSelector::Edge(EdgeType::DependsOn))
+        );
+        let err = "calls_into".parse::<EdgeSelector>().unwrap_err();
+        assert!(err.contains("calls, implements"));
+    }
+}
@@ -0,0 +1,323 @@
+//! Symbol Subscriptions
+//!
+//! Tracks a chosen set of symbols across index updates so that teams
+//! depending on an API can be told when it changes. A [`SymbolFilter`] picks
+//! the watched symbols by node ID glob or package, [`snapshot_symbols`]
+//! records their declaration signatures and callers, and [`change_events`]
+//! compares two snapshots.
+//!
+//! Signatures are read from source (see [`declaration_signature`]), so a
+//! snapshot must be taken while the files it describes are on disk.
+
+use std::collections::{BTreeMap, BTreeSet};
+use std::path::Path;
+
+use globset::{Glob, GlobSet, GlobSetBuilder};
+use serde::{Deserialize, Serialize};
+
+use super::api_surface::declaration_signature;
+use super::package_of;
+use crate::graph::{EdgeType, Node, NodeType, PetCodeGraph};
+
+/// Kind of change reported for a watched symbol.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, PartialOrd, Ord, Hash, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum ChangeEventKind {
+    /// The declaration signature changed
+    SignatureChanged,
+    /// A new reference to the symbol appeared
+    CallerAdded,
+    /// The symbol no longer exists
+    Removed,
+}
+
+impl ChangeEventKind {
+    /// All event kinds
+    pub const ALL: [ChangeEventKind; 3] = [
+        ChangeEventKind::SignatureChanged,
+        ChangeEventKind::CallerAdded,
+        ChangeEventKind::Removed,
+    ];
+
+    /// Get the serialized name
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            ChangeEventKind::SignatureChanged => "signature_changed",
+            ChangeEventKind::CallerAdded => "caller_added",
+            ChangeEventKind::Removed => "removed",
+        }
+    }
+}
+
+impl std::str::FromStr for ChangeEventKind {
+    type Err = String;
+
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        Self::ALL
+            .into_iter()
+            .find(|kind| kind.as_str() == s)
+            .ok_or_else(|| {
+                format!(
+                    "unknown event '{}' (expected signature_changed, caller_added, or removed)",
+                    s
+                )
+            })
+    }
+}
+
+/// Selects the symbols a subscriber watches.
+#[derive(Debug, Clone)]
+pub struct SymbolFilter {
+    /// Node ID patterns
+    symbols: GlobSet,
+    /// Package directories, matched with their subpackages
+    packages: Vec<String>,
+}
+
+impl SymbolFilter {
+    /// Create a filter from node ID glob patterns (e.g.,
+    /// `"pkg/api/client.go:Client:*"`) and package directories (e.g.,
+    /// `"pkg/api"`). A symbol is watched if it matches either.
+    pub fn new(symbols: &[String], packages: &[String]) -> Result<Self, globset::Error> {
+        let mut builder = GlobSetBuilder::new();
+        for pattern in symbols {
+            builder.add(Glob::new(pattern)?);
+        }
+        Ok(Self {
+            symbols: builder.build()?,
+            packages: packages
+                .iter()
+                .map(|p| p.trim_matches('/').to_string())
+                .collect(),
+        })
+    }
+
+    /// Check if a node is watched
+    pub fn matches(&self, node: &Node) -> bool {
+        if !is_symbol(node) {
+            return false;
+        }
+        if self.symbols.is_match(&node.id) {
+            return true;
+        }
+        let package = package_of(&node.file);
+        self.packages.iter().any(|root| {
+            package == root
+                || package
+                    .strip_prefix(root.as_str())
+                    .is_some_and(|rest| rest.starts_with('/'))
+        })
+    }
+}
+
+/// Recorded state of one watched symbol.
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub struct SymbolState {
+    /// File path relative to the repository root
+    pub file: String,
+    /// Start line (1-indexed)
+    pub line: usize,
+    /// Declaration signature, if it could be read
+    pub signature: Option<String>,
+    /// IDs of nodes that reference the symbol
+    pub callers: BTreeSet<String>,
+}
+
+/// Watched symbols at one point in time, keyed by node ID.
+pub type SymbolSnapshot = BTreeMap<String, SymbolState>;
+
+/// A change to a watched symbol.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct ChangeEvent {
+    /// What changed
+    pub kind: ChangeEventKind,
+    /// Node ID of the watched symbol
+    pub symbol: String,
+    /// File of the symbol (its last location if removed)
+    pub file: String,
+    /// Start line of the symbol
+    pub line: usize,
+    /// Signature before the change
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub old_signature: Option<String>,
+    /// Signature after the change
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub new_signature: Option<String>,
+    /// Node ID of the new caller
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub caller: Option<String>,
+}
+
+/// Record the signatures and callers of the watched symbols.
+///
+/// Signatures are read from files under `repo_root`; files that cannot be
+/// read leave the signature unset.
+pub fn snapshot_symbols(
+    graph: &PetCodeGraph,
+    repo_root: &Path,
+    filter: &SymbolFilter,
+) -> SymbolSnapshot {
+    let mut by_file: BTreeMap<&str, Vec<&Node>> = BTreeMap::new();
+    for node in graph.iter_nodes().filter(|n| filter.matches(n)) {
+        by_file.entry(node.file.as_str()).or_default().push(node);
+    }
+
+    let mut snapshot = SymbolSnapshot::new();
+    for (file, nodes) in by_file {
+        let content = std::fs::read_to_string(repo_root.join(file)).unwrap_or_default();
+        let lines: Vec<&str> = content.lines().collect();
+        for node in nodes {
+            let callers = graph
+                .incoming_edges(&node.id)
+                .filter(|(_, edge)| edge.edge_type == EdgeType::Uses)
+                .map(|(source, _)| source.id.clone())
+                .collect();
+            snapshot.insert(
+                node.id.clone(),
+                SymbolState {
+                    file: node.file.clone(),
+                    line: node.line,
+                    signature: declaration_signature(&lines, node.line, node.end_line),
+                    callers,
+                },
+            );
+        }
+    }
+    snapshot
+}
+
+/// Compare two snapshots of the same filter, ordered by symbol.
+///
+/// Symbols that appear in `new` only are not reported: a new symbol has no
+/// subscribers relying on it yet. Signatures that could not be read in
+/// either snapshot are not compared.
+pub fn change_events(old: &SymbolSnapshot, new: &SymbolSnapshot) -> Vec<ChangeEvent> {
+    let mut events = Vec::new();
+    for (id, before) in old {
+        let event = |kind| ChangeEvent {
+            kind,
+            symbol: id.clone(),
+            file: before.file.clone(),
+            line: before.line,
+            old_signature: None,
+            new_signature: None,
+            caller: None,
+        };
+
+        let Some(after) = new.get(id) else {
+            events.push(event(ChangeEventKind::Removed));
+            continue;
+        };
+        let located = |kind| ChangeEvent {
+            file: after.file.clone(),
+            line: after.line,
+            ..event(kind)
+        };
+
+        if let (Some(old_signature), Some(new_signature)) = (&before.signature, &after.signature) {
+            if old_signature != new_signature {
+                events.push(ChangeEvent {
+                    old_signature: Some(old_signature.clone()),
+                    new_signature: Some(new_signature.clone()),
+                    ..located(ChangeEventKind::SignatureChanged)
+                });
+            }
+        }
+        for caller in after.callers.difference(&before.callers) {
+            events.push(ChangeEvent {
+                caller: Some(caller.clone()),
+                ..located(ChangeEventKind::CallerAdded)
+            });
+        }
+    }
+    events
+}
+
+/// Check if a node is a declaration worth watching: a callable or a
+/// non-structural container
+fn is_symbol(node: &Node) -> bool {
+    match node.node_type {
+        NodeType::Callable => true,
+        NodeType::Container => {
+            !(node.is_file() || node.is_repository() || node.is_workspace() || node.is_component())
+        }
+        NodeType::Data => false,
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::EdgeData;
+    use crate::test_support::add_fn;
+
+    #[test]
+    fn test_symbol_filter() {
+        let filter =
+            SymbolFilter::new(&["lib/util.go:Parse*".to_string()], &["api/".to_string()]).unwrap();
+        let mut graph = PetCodeGraph::new();
+        for id in [
+            "lib/util.go:ParseURL",
+            "lib/util.go:Format",
+            "api/v1/handlers.go:Get",
+            "apix/x.go:Run",
+        ] {
+            add_fn(&mut graph, id);
+        }
+
+        let mut watched: Vec<&str> = graph
+            .iter_nodes()
+            .filter(|n| filter.matches(n))
+            .map(|n| n.id.as_str())
+            .collect();
+        watched.sort();
+        assert_eq!(
+            watched,
+            vec!["api/v1/handlers.go:Get", "lib/util.go:ParseURL"]
+        );
+    }
+
+    #[test]
+    fn test_change_events() {
+        let dir = tempfile::tempdir().unwrap();
+        std::fs::write(
+            dir.path().join("api.go"),
+            "func Get(id string) {\n}\n\nfunc List() {\n}\n",
+        )
+        .unwrap();
+
+        let mut graph = PetCodeGraph::new();
+        add_fn(&mut graph, "api.go:Get");
+        add_fn(&mut graph, "api.go:List");
+        graph.get_node_mut("api.go:List").unwrap().line = 4;
+        graph.get_node_mut("api.go:List").unwrap().end_line = 5;
+        add_fn(&mut graph, "main.go:main");
+        let filter = SymbolFilter::new(&["api.go:*".to_string()], &[]).unwrap();
+        let old = snapshot_symbols(&graph, dir.path(), &filter);
+
+        std::fs::write(
+            dir.path().join("api.go"),
+            "func Get(id string, limit int) {\n}\n",
+        )
+        .unwrap();
+        graph.remove_node("api.go:List");
+        graph.add_edge("main.go:main", "api.go:Get", EdgeData::uses(Some(2), None));
+        let new = snapshot_symbols(&graph, dir.path(), &filter);
+
+        let events = change_events(&old, &new);
+        let kinds: Vec<_> = events.iter().map(|e| (e.kind, e.symbol.as_str())).collect();
+        assert_eq!(
+            kinds,
+            vec![
+                (ChangeEventKind::SignatureChanged, "api.go:Get"),
+                (ChangeEventKind::CallerAdded, "api.go:Get"),
+                (ChangeEventKind::Removed, "api.go:List"),
+            ]
+        );
+        assert_eq!(
+            events[0].old_signature.as_deref(),
+            Some("func Get(id string)")
+        );
+        assert_eq!(events[1].caller.as_deref(), Some("main.go:main"));
+    }
+}
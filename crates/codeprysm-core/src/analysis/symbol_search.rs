@@ -0,0 +1,379 @@
+//! Fuzzy Symbol Search
+//!
+//! Matches a short pattern against symbol names the way editor pickers do:
+//! pattern characters must appear in order, and matches at word starts
+//! (camel humps, `_`, `.`, `-`) score higher, so "hrq" or "HR" finds
+//! `HandleRequest`; an uppercase pattern character only scores well on a
+//! camel hump. Results are ranked by match quality, then symbol kind
+//! (types and functions before fields), then fan-in.
+//!
+//! Unlike semantic search this needs no embeddings, only the graph. Doc
+//! comments are not stored in the graph; when a repository root is given they
+//! are read from the source files and matched as plain words.
+
+use std::collections::{BTreeSet, HashMap};
+use std::path::PathBuf;
+
+use serde::{Deserialize, Serialize};
+
+use super::api_surface::doc_comment;
+use crate::graph::{EdgeType, Node, NodeType, PetCodeGraph};
+
+/// Score of each matched pattern character
+const MATCH_SCORE: i64 = 16;
+/// Bonus for a character matched at a word start
+const BOUNDARY_BONUS: i64 = 24;
+/// Bonus for a character matched right after the previous one
+const CONSECUTIVE_BONUS: i64 = 12;
+/// Penalty per name character skipped between matches
+const GAP_PENALTY: i64 = 2;
+/// Score of a doc-only match (below any name match of the same length)
+const DOC_SCORE: i64 = 8;
+
+/// Options for fuzzy symbol search.
+#[derive(Debug, Clone)]
+pub struct FuzzyOptions {
+    /// Maximum number of results
+    pub limit: usize,
+    /// Repository root to read doc comments from (None = names only)
+    pub docs_root: Option<PathBuf>,
+}
+
+impl Default for FuzzyOptions {
+    fn default() -> Self {
+        Self {
+            limit: 20,
+            docs_root: None,
+        }
+    }
+}
+
+/// A symbol matching a fuzzy pattern.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct SymbolMatch {
+    /// Node ID
+    pub id: String,
+    /// Entity name
+    pub name: String,
+    /// Node type
+    pub node_type: NodeType,
+    /// Entity kind (function, type, field, ...)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub kind: Option<String>,
+    /// File path relative to the repository root
+    pub file: String,
+    /// Start line (1-indexed)
+    pub line: usize,
+    /// End line (1-indexed)
+    pub end_line: usize,
+    /// Ranking score (higher is better)
+    pub score: i64,
+    /// Name positions matched by the pattern (char indices)
+    pub positions: Vec<usize>,
+    /// Whether the symbol matched through its doc comment only
+    pub doc_match: bool,
+    /// Distinct symbols using this one
+    pub fan_in: usize,
+}
+
+/// Search symbol names (and optionally doc comments) for a fuzzy pattern.
+///
+/// Whitespace in the pattern is ignored for name matching; for doc matching
+/// every whitespace-separated word must occur in the doc comment.
+pub fn fuzzy_search(
+    graph: &PetCodeGraph,
+    pattern: &str,
+    options: &FuzzyOptions,
+) -> Vec<SymbolMatch> {
+    let compact: String = pattern.chars().filter(|c| !c.is_whitespace()).collect();
+    if compact.is_empty() {
+        return Vec::new();
+    }
+    let words: Vec<String> = pattern.split_whitespace().map(str::to_lowercase).collect();
+
+    let mut callers: HashMap<&str, BTreeSet<&str>> = HashMap::new();
+    for (source, target, _) in graph.edges_by_type(EdgeType::Uses) {
+        if source.id != target.id {
+            callers
+                .entry(target.id.as_str())
+                .or_default()
+                .insert(source.id.as_str());
+        }
+    }
+
+    let mut docs = DocReader::new(options.docs_root.clone());
+    let mut matches: Vec<SymbolMatch> = graph
+        .iter_nodes()
+        .filter(|n| is_symbol(n))
+        .filter_map(|node| {
+            let (score, positions, doc_match) = match fuzzy_match(&compact, &node.name) {
+                Some((score, positions)) => (score, positions, false),
+                None if docs.matches(node, &words) => {
+                    (DOC_SCORE * words.len() as i64, Vec::new(), true)
+                }
+                None => return None,
+            };
+            let fan_in = callers.get(node.id.as_str()).map_or(0, BTreeSet::len);
+
+            Some(SymbolMatch {
+                id: node.id.clone(),
+                name: node.name.clone(),
+                node_type: node.node_type,
+                kind: node.kind.clone(),
+                file: node.file.clone(),
+                line: node.line,
+                end_line: node.end_line,
+                score: score + kind_bonus(node) + fan_in_bonus(fan_in),
+                positions,
+                doc_match,
+                fan_in,
+            })
+        })
+        .collect();
+
+    matches.sort_by(|a, b| {
+        b.score
+            .cmp(&a.score)
+            .then_with(|| a.name.len().cmp(&b.name.len()))
+            .then_with(|| a.id.cmp(&b.id))
+    });
+    matches.truncate(options.limit);
+    matches
+}
+
+/// Score `pattern` against `name`, returning the best score and the matched
+/// char positions, or `None` if the pattern is not a subsequence of the name.
+///
+/// Matching is case-insensitive; an exact-case match earns a small bonus.
+pub fn fuzzy_match(pattern: &str, name: &str) -> Option<(i64, Vec<usize>)> {
+    let pattern: Vec<char> = pattern.chars().collect();
+    let name: Vec<char> = name.chars().collect();
+    if pattern.is_empty() || pattern.len() > name.len() {
+        return None;
+    }
+
+    let boundary: Vec<bool> = (0..name.len()).map(|j| is_boundary(&name, j)).collect();
+    let char_score = |i: usize, j: usize| -> Option<i64> {
+        if !pattern[i].to_lowercase().eq(name[j].to_lowercase()) {
+            return None;
+        }
+        let mut score = MATCH_SCORE;
+        if boundary[j] {
+            score += BOUNDARY_BONUS;
+        }
+        if pattern[i] == name[j] {
+            score += 1;
+        } else if pattern[i].is_uppercase() {
+            // An uppercase pattern char asks for a camel hump
+            score -= BOUNDARY_BONUS;
+        }
+        Some(score)
+    };
+
+    // best[i][j]: best score with pattern[i] matched at name[j]
+    let mut best: Vec<Vec<Option<i64>>> = vec![vec![None; name.len()]; pattern.len()];
+    let mut from: Vec<Vec<usize>> = vec![vec![0; name.len()]; pattern.len()];
+    for j in 0..name.len() {
+        best[0][j] = char_score(0, j).map(|s| s - (j as i64).min(8));
+    }
+    for i in 1..pattern.len() {
+        for j in i..name.len() {
+            let Some(score) = char_score(i, j) else {
+                continue;
+            };
+            for k in (i - 1)..j {
+                let Some(previous) = best[i - 1][k] else {
+                    continue;
+                };
+                let transition = if k + 1 == j {
+                    CONSECUTIVE_BONUS
+                } else {
+                    -GAP_PENALTY * (j - k - 1) as i64
+                };
+                let total = previous + score + transition;
+                if best[i][j].is_none_or(|b| total > b) {
+                    best[i][j] = Some(total);
+                    from[i][j] = k;
+                }
+            }
+        }
+    }
+
+    let last = pattern.len() - 1;
+    let (mut j, mut score) = (0..name.len())
+        .filter_map(|j| best[last][j].map(|s| (j, s)))
+        .max_by_key(|&(j, s)| (s, std::cmp::Reverse(j)))?;
+
+    let mut positions = vec![0; pattern.len()];
+    for i in (0..pattern.len()).rev() {
+        positions[i] = j;
+        if i > 0 {
+            j = from[i][j];
+        }
+    }
+
+    // Prefer whole-name matches, then shorter names
+    if pattern.len() == name.len() {
+        score += 100;
+    }
+    score -= (name.len() - pattern.len()) as i64 / 4;
+
+    Some((score, positions))
+}
+
+/// Check if the char at `j` starts a word: the first char, a char after a
+/// separator, an uppercase letter after a lowercase one, or the first digit
+fn is_boundary(name: &[char], j: usize) -> bool {
+    let Some(previous) = j.checked_sub(1).map(|p| name[p]) else {
+        return true;
+    };
+    let current = name[j];
+    !previous.is_alphanumeric()
+        || (previous.is_lowercase() && current.is_uppercase())
+        || (!previous.is_ascii_digit() && current.is_ascii_digit())
+}
+
+/// Nodes worth offering in a symbol picker (no parameters, locals, or
+/// markers)
+fn is_symbol(node: &Node) -> bool {
+    !node.file.is_empty()
+        && !matches!(
+            node.kind.as_deref(),
+            Some(
+                "workspace"
+                    | "repository"
+                    | "component"
+                    | "parameter"
+                    | "local"
+                    | "marker"
+                    | "documentation"
+                    | "truncation"
+            )
+        )
+}
+
+/// Rank types and functions above fields and files
+fn kind_bonus(node: &Node) -> i64 {
+    match (node.node_type, node.kind.as_deref()) {
+        (NodeType::Container, Some("type")) => 12,
+        (NodeType::Callable, _) => 10,
+        (NodeType::Container, Some("file")) => 0,
+        (NodeType::Container, _) => 6,
+        (NodeType::Data, _) => 3,
+    }
+}
+
+/// Logarithmic bonus for widely used symbols
+fn fan_in_bonus(fan_in: usize) -> i64 {
+    i64::from(usize::BITS - fan_in.leading_zeros()) * 2
+}
+
+/// Reads doc comments, caching file contents
+struct DocReader {
+    root: Option<PathBuf>,
+    files: HashMap<String, Option<Vec<String>>>,
+}
+
+impl DocReader {
+    fn new(root: Option<PathBuf>) -> Self {
+        Self {
+            root,
+            files: HashMap::new(),
+        }
+    }
+
+    /// Check if every word occurs in the node's doc comment
+    fn matches(&mut self, node: &Node, words: &[String]) -> bool {
+        let Some(ref root) = self.root else {
+            return false;
+        };
+        if words.is_empty() {
+            return false;
+        }
+
+        let lines = self.files.entry(node.file.clone()).or_insert_with(|| {
+            std::fs::read_to_string(root.join(&node.file))
+                .ok()
+                .map(|text| text.lines().map(str::to_string).collect())
+        });
+        let Some(lines) = lines else {
+            return false;
+        };
+
+        let lines: Vec<&str> = lines.iter().map(String::as_str).collect();
+        let doc = doc_comment(&lines, node.line).0.join(" ").to_lowercase();
+        words.iter().all(|word| doc.contains(word.as_str()))
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::{CallableKind, ContainerKind, DataKind, EdgeData};
+
+    #[test]
+    fn test_fuzzy_match_prefers_word_starts() {
+        let (score, positions) = fuzzy_match("hrq", "HandleRequest").unwrap();
+        assert_eq!(positions, vec![0, 6, 8]);
+        assert!(score > 0);
+
+        let (boundary, _) = fuzzy_match("hr", "HandleRequest").unwrap();
+        let (inner, _) = fuzzy_match("hr", "threshold").unwrap();
+        assert!(boundary > inner);
+
+        let (exact, _) = fuzzy_match("parse", "parse").unwrap();
+        let (prefix, _) = fuzzy_match("parse", "parseArgs").unwrap();
+        assert!(exact > prefix);
+
+        assert!(fuzzy_match("xyz", "HandleRequest").is_none());
+        assert!(fuzzy_match("qh", "HandleRequest").is_none());
+    }
+
+    #[test]
+    fn test_search_ranks_by_kind_and_fan_in() {
+        let mut graph = PetCodeGraph::new();
+        graph.add_node(Node::container(
+            "a.go:Handler".to_string(),
+            "Handler".to_string(),
+            ContainerKind::Type,
+            Some("struct".to_string()),
+            "a.go".to_string(),
+            1,
+            5,
+        ));
+        graph.add_node(Node::data(
+            "a.go:Server:handler".to_string(),
+            "handler".to_string(),
+            DataKind::Field,
+            None,
+            "a.go".to_string(),
+            8,
+            8,
+        ));
+        for id in ["a.go:Handle", "a.go:HandleAll", "a.go:run"] {
+            graph.add_node(Node::callable(
+                id.to_string(),
+                id.rsplit(':').next().unwrap().to_string(),
+                CallableKind::Function,
+                "a.go".to_string(),
+                10,
+                12,
+            ));
+        }
+        graph.add_edge("a.go:run", "a.go:HandleAll", EdgeData::uses(Some(11), None));
+
+        let options = FuzzyOptions::default();
+        let names: Vec<String> = fuzzy_search(&graph, "handle", &options)
+            .into_iter()
+            .map(|m| m.name)
+            .collect();
+        assert_eq!(names[0], "Handle");
+        assert_eq!(names.len(), 4);
+        assert_eq!(names.last().unwrap(), "handler");
+
+        let results = fuzzy_search(&graph, "HA", &options);
+        assert_eq!(results[0].name, "HandleAll");
+        assert_eq!(results[0].fan_in, 1);
+        assert!(fuzzy_search(&graph, "  ", &options).is_empty());
+    }
+}
@@ -0,0 +1,236 @@
+//! Go Test Selection
+//!
+//! Reduces an impact report (see [`analyze_impact`](super::analyze_impact))
+//! to the Go tests a change can affect: the packages of the impacted test
+//! files and the `Test`, `Fuzz`, and `Example` functions in them, in a form
+//! `go test -run` accepts. Running only those cuts CI time on large
+//! repositories.
+//!
+//! Selection errs on the side of running more:
+//!
+//! - A package whose `TestMain` is impacted runs all of its tests
+//! - A change to `go.mod`, `go.sum`, or `go.work` runs every test (`./...`)
+//!
+//! Package paths are relative to the repository root, so `go test` should run
+//! from there (or from the module root when it is the repository root).
+
+use std::collections::{BTreeMap, BTreeSet};
+
+use serde::Serialize;
+
+use super::impact::ImpactReport;
+use super::package_of;
+
+/// Files whose change can affect the build of every package
+const MODULE_FILES: &[&str] = &["go.mod", "go.sum", "go.work", "go.work.sum"];
+
+/// Function name prefixes `go test -run` matches
+const TEST_PREFIXES: &[&str] = &["Test", "Fuzz", "Example"];
+
+/// Tests selected in one Go package.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize)]
+pub struct TestPackage {
+    /// Package path relative to the repository root ("" for the root)
+    pub package: String,
+    /// Selected test functions, sorted
+    pub tests: Vec<String>,
+    /// Whether every test of the package must run (its `TestMain` changed)
+    pub all: bool,
+}
+
+impl TestPackage {
+    /// Package pattern for `go test` (e.g. "./pkg/client")
+    pub fn pattern(&self) -> String {
+        if self.package.is_empty() {
+            ".".to_string()
+        } else {
+            format!("./{}", self.package)
+        }
+    }
+}
+
+/// Go tests affected by a change.
+#[derive(Debug, Clone, Default, PartialEq, Eq, Serialize)]
+pub struct TestSelection {
+    /// Whether every test must run (a module file changed)
+    pub all: bool,
+    /// Packages with selected tests, sorted by package
+    pub packages: Vec<TestPackage>,
+}
+
+impl TestSelection {
+    /// Check if no test needs to run
+    pub fn is_empty(&self) -> bool {
+        !self.all && self.packages.is_empty()
+    }
+
+    /// Number of selected test functions
+    pub fn test_count(&self) -> usize {
+        self.packages.iter().map(|p| p.tests.len()).sum()
+    }
+
+    /// `-run` pattern matching every selected test, or None when some
+    /// package must run all of its tests.
+    ///
+    /// The pattern is shared by all packages, so a selected name also runs
+    /// in another selected package that has a test of the same name.
+    pub fn run_pattern(&self) -> Option<String> {
+        if self.all || self.packages.iter().any(|p| p.all) {
+            return None;
+        }
+        let names: BTreeSet<&str> = self
+            .packages
+            .iter()
+            .flat_map(|p| p.tests.iter().map(String::as_str))
+            .collect();
+        if names.is_empty() {
+            return None;
+        }
+        Some(format!(
+            "^({})$",
+            names.into_iter().collect::<Vec<_>>().join("|")
+        ))
+    }
+
+    /// Arguments for `go test`: an optional `-run` pattern followed by the
+    /// package patterns. Empty when no test needs to run.
+    pub fn go_test_args(&self) -> Vec<String> {
+        if self.all {
+            return vec!["./...".to_string()];
+        }
+        if self.packages.is_empty() {
+            return Vec::new();
+        }
+        let mut args = Vec::new();
+        if let Some(pattern) = self.run_pattern() {
+            args.push("-run".to_string());
+            args.push(pattern);
+        }
+        args.extend(self.packages.iter().map(TestPackage::pattern));
+        args
+    }
+}
+
+/// Select the Go tests impacted by a change.
+pub fn select_go_tests(report: &ImpactReport) -> TestSelection {
+    let all = report
+        .changed_files
+        .iter()
+        .chain(&report.deleted_files)
+        .any(|file| MODULE_FILES.contains(&file.rsplit('/').next().unwrap_or(file)));
+    if all {
+        return TestSelection {
+            all,
+            packages: Vec::new(),
+        };
+    }
+
+    let mut packages: BTreeMap<&str, (BTreeSet<&str>, bool)> = BTreeMap::new();
+    for test in &report.tests {
+        if !test.file.ends_with("_test.go") || test.kind.as_deref() != Some("function") {
+            continue;
+        }
+        let run_all = test.name == "TestMain";
+        if !run_all && !is_test_function(&test.name) {
+            continue;
+        }
+        let entry = packages.entry(package_of(&test.file)).or_default();
+        if run_all {
+            entry.1 = true;
+        } else {
+            entry.0.insert(test.name.as_str());
+        }
+    }
+
+    TestSelection {
+        all: false,
+        packages: packages
+            .into_iter()
+            .map(|(package, (tests, all))| TestPackage {
+                package: package.to_string(),
+                tests: tests.into_iter().map(str::to_string).collect(),
+                all,
+            })
+            .collect(),
+    }
+}
+
+/// Check if a function name is a test, fuzz test, or example as `go test`
+/// defines it: a prefix not followed by a lowercase letter
+fn is_test_function(name: &str) -> bool {
+    TEST_PREFIXES.iter().any(|prefix| {
+        name.strip_prefix(prefix)
+            .is_some_and(|rest| !rest.starts_with(|c: char| c.is_lowercase()))
+    })
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::analysis::impact::ImpactedSymbol;
+    use crate::graph::NodeType;
+
+    fn test_symbol(file: &str, name: &str) -> ImpactedSymbol {
+        ImpactedSymbol {
+            id: format!("{}:{}", file, name),
+            name: name.to_string(),
+            node_type: NodeType::Callable,
+            kind: Some("function".to_string()),
+            file: file.to_string(),
+            line: 1,
+            distance: 1,
+            is_test: true,
+            entry_point: None,
+        }
+    }
+
+    #[test]
+    fn test_is_test_function() {
+        assert!(is_test_function("TestParse"));
+        assert!(is_test_function("Test"));
+        assert!(is_test_function("Test_parse"));
+        assert!(is_test_function("FuzzParse"));
+        assert!(is_test_function("ExampleClient_Get"));
+        assert!(!is_test_function("Testify"));
+        assert!(!is_test_function("BenchmarkParse"));
+        assert!(!is_test_function("newTestServer"));
+    }
+
+    #[test]
+    fn test_select_go_tests() {
+        let report = ImpactReport {
+            changed_files: vec!["client/dial.go".to_string()],
+            tests: vec![
+                test_symbol("client/dial_test.go", "TestDial"),
+                test_symbol("client/dial_test.go", "newTestServer"),
+                test_symbol("client/dial_test.go", "TestDial"),
+                test_symbol("cmd/app/main_test.go", "TestRun"),
+                test_symbol("tools/gen_test.py", "TestGen"),
+            ],
+            ..Default::default()
+        };
+        let selection = select_go_tests(&report);
+        assert_eq!(selection.packages.len(), 2);
+        assert_eq!(selection.packages[0].tests, vec!["TestDial"]);
+        assert_eq!(selection.test_count(), 2);
+        assert_eq!(
+            selection.go_test_args(),
+            vec!["-run", "^(TestDial|TestRun)$", "./client", "./cmd/app"]
+        );
+
+        // A changed TestMain runs the whole package, so no -run filter
+        let mut report = report;
+        report.tests.push(test_symbol("main_test.go", "TestMain"));
+        let selection = select_go_tests(&report);
+        assert!(selection.packages[0].all);
+        assert_eq!(selection.packages[0].pattern(), ".");
+        assert_eq!(selection.run_pattern(), None);
+
+        report.changed_files.push("go.mod".to_string());
+        let selection = select_go_tests(&report);
+        assert!(selection.all);
+        assert_eq!(selection.go_test_args(), vec!["./..."]);
+
+        assert!(select_go_tests(&ImpactReport::default()).is_empty());
+    }
+}
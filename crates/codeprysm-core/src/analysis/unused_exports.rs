@@ -0,0 +1,446 @@
+//! Unused Exports
+//!
+//! Finds exported Go symbols that no code outside their own package refers
+//! to, across every module in the indexed workspace. Such symbols can be
+//! unexported or removed without breaking any indexed consumer.
+//!
+//! Top-level functions, types, constants, and variables are checked, and so
+//! are methods on exported types; fields and interface methods go with their
+//! type. A symbol counts as used when:
+//!
+//! - code in another package references it, or, for a type, one of its
+//!   fields or methods
+//! - it is a type referenced by a used symbol of its own package, such as the
+//!   result of an exported constructor
+//!
+//! Methods that satisfy an interface in the graph, or a common standard
+//! library interface (`String`, `Error`, `MarshalJSON`, ...), are never
+//! reported, since calls through the interface do not reference them. `main`
+//! packages cannot be imported and are skipped. References from test code
+//! only count when asked for.
+//!
+//! Consumers outside the workspace are declared with an allowlist of glob
+//! patterns, matched against node IDs and against qualified names of the form
+//! `<package>.<Name>` or `<package>.<Type>.<Method>` (e.g. `pkg/client.*`).
+//! Symbols can also be excluded with a suppression comment:
+//!
+//! ```text
+//! // codeprysm:ignore unused-export
+//! func LegacyHook() {}
+//! ```
+
+use std::collections::{HashMap, HashSet, VecDeque};
+use std::path::Path;
+
+use serde::Serialize;
+
+use super::api_surface::{is_exported, is_type, owner_name};
+use super::dead_code::{is_test_code, retain_unsuppressed};
+use super::implementers::MethodSets;
+use super::package_of;
+use crate::graph::{EdgeType, Node, NodeType, PetCodeGraph};
+
+/// Rule name accepted after the suppression marker
+pub const UNUSED_EXPORT_RULE: &str = "unused-export";
+
+/// Methods of common standard library interfaces, which code outside the
+/// workspace may call through the interface
+const WELL_KNOWN_METHODS: &[&str] = &[
+    "As",
+    "Close",
+    "Error",
+    "Format",
+    "GoString",
+    "Is",
+    "Len",
+    "Less",
+    "MarshalBinary",
+    "MarshalJSON",
+    "MarshalText",
+    "MarshalYAML",
+    "Pop",
+    "Push",
+    "Read",
+    "ReadFrom",
+    "RoundTrip",
+    "Scan",
+    "ServeHTTP",
+    "String",
+    "Swap",
+    "UnmarshalBinary",
+    "UnmarshalJSON",
+    "UnmarshalText",
+    "UnmarshalYAML",
+    "Unwrap",
+    "Value",
+    "Write",
+    "WriteTo",
+];
+
+/// Options controlling unused export detection.
+#[derive(Debug, Clone, Default)]
+pub struct UnusedExportOptions {
+    /// Glob patterns over node IDs and qualified names of symbols used
+    /// outside the workspace; invalid patterns are ignored
+    pub allow: Vec<String>,
+    /// Count references from test code in other packages
+    pub include_tests: bool,
+}
+
+/// An exported symbol without references from other packages.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize)]
+pub struct UnusedExport {
+    /// Node ID
+    pub id: String,
+    /// Symbol name
+    pub name: String,
+    /// Node type
+    pub node_type: NodeType,
+    /// Kind within the node type (e.g., "function", "type")
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub kind: Option<String>,
+    /// Package (containing directory)
+    pub package: String,
+    /// File path relative to the repository root
+    pub file: String,
+    /// Start line (1-indexed)
+    pub line: usize,
+    /// End line (1-indexed)
+    pub end_line: usize,
+    /// Receiver type for methods
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub owner: Option<String>,
+    /// Number of references from its own package
+    pub internal_references: usize,
+}
+
+impl UnusedExport {
+    /// Name qualified by package and receiver type (e.g.
+    /// "pkg/client.Client.Do")
+    pub fn qualified_name(&self) -> String {
+        qualified_name(&self.package, self.owner.as_deref(), &self.name)
+    }
+}
+
+/// Result of an unused export analysis.
+#[derive(Debug, Clone, Default, Serialize)]
+pub struct UnusedExportsReport {
+    /// Number of exported symbols checked
+    pub exported_count: usize,
+    /// Symbols no other package uses, ordered by package, file, and line
+    pub unused: Vec<UnusedExport>,
+    /// Number of unused symbols matching the allowlist
+    pub allowed_count: usize,
+    /// Number of unused symbols hidden by suppression comments
+    pub suppressed_count: usize,
+}
+
+impl UnusedExportsReport {
+    /// Drop symbols whose declaration carries a suppression comment.
+    ///
+    /// Source files are read relative to `repo_root`; unreadable files are
+    /// left unsuppressed.
+    pub fn apply_suppressions(&mut self, repo_root: &Path) {
+        self.suppressed_count +=
+            retain_unsuppressed(&mut self.unused, repo_root, UNUSED_EXPORT_RULE, |symbol| {
+                (&symbol.file, symbol.line)
+            });
+    }
+}
+
+/// Find exported Go symbols that no other package in the graph uses.
+pub fn find_unused_exports(
+    graph: &PetCodeGraph,
+    options: &UnusedExportOptions,
+) -> UnusedExportsReport {
+    let sets = MethodSets::build(graph);
+    let owners = sets.method_owners();
+    let allow: Vec<glob::Pattern> = options
+        .allow
+        .iter()
+        .filter_map(|p| glob::Pattern::new(p).ok())
+        .collect();
+
+    // Packages with a `main` function build commands, which nothing imports
+    let main_packages: HashSet<&str> = graph
+        .iter_nodes()
+        .filter(|n| {
+            is_go(n)
+                && n.node_type == NodeType::Callable
+                && n.name == "main"
+                && n.metadata.receiver.is_none()
+                && !is_test_code(n)
+        })
+        .map(|n| package_of(&n.file))
+        .collect();
+
+    let candidates: Vec<&Node> = graph
+        .iter_nodes()
+        .filter(|n| {
+            is_go(n)
+                && !main_packages.contains(package_of(&n.file))
+                && is_exported(graph, n)
+                && !graph.parent(&n.id).is_some_and(is_type)
+        })
+        .collect();
+
+    // Symbols used from other packages, then what they keep exported
+    let mut used: HashSet<&str> = HashSet::new();
+    let mut queue: VecDeque<&Node> = VecDeque::new();
+    let mut internal: HashMap<&str, usize> = HashMap::new();
+    for (source, target, _) in graph.edges_by_type(EdgeType::Uses) {
+        if !is_go(target) {
+            continue;
+        }
+        if package_of(&source.file) == package_of(&target.file) {
+            *internal.entry(target.id.as_str()).or_default() += 1;
+        } else if (options.include_tests || !is_test_code(source))
+            && used.insert(target.id.as_str())
+        {
+            queue.push_back(target);
+        }
+    }
+
+    while let Some(node) = queue.pop_front() {
+        let mut next: Vec<&Node> = Vec::new();
+
+        // Members keep their enclosing type, and Go methods their receiver
+        if let Some(parent) = graph.parent(&node.id).filter(|p| is_type(p)) {
+            next.push(parent);
+        }
+        if let Some(types) = owners.get(node.id.as_str()) {
+            next.extend(types.iter().filter_map(|id| graph.get_node(id)));
+        }
+
+        // Types in the signature or body of a used symbol of the same package
+        let package = package_of(&node.file);
+        next.extend(
+            graph
+                .outgoing_edges(&node.id)
+                .filter(|(target, edge)| {
+                    edge.edge_type == EdgeType::Uses
+                        && is_type(target)
+                        && package_of(&target.file) == package
+                })
+                .map(|(target, _)| target),
+        );
+
+        for target in next {
+            if used.insert(target.id.as_str()) {
+                queue.push_back(target);
+            }
+        }
+    }
+
+    let mut report = UnusedExportsReport {
+        exported_count: candidates.len(),
+        ..Default::default()
+    };
+    for node in candidates {
+        if used.contains(node.id.as_str()) || satisfies_interface(node, &sets, &owners) {
+            continue;
+        }
+
+        let symbol = UnusedExport {
+            id: node.id.clone(),
+            name: node.name.clone(),
+            node_type: node.node_type,
+            kind: node.kind.clone(),
+            package: package_of(&node.file).to_string(),
+            file: node.file.clone(),
+            line: node.line,
+            end_line: node.end_line,
+            owner: owner_name(graph, node),
+            internal_references: internal.get(node.id.as_str()).copied().unwrap_or(0),
+        };
+        let qualified = symbol.qualified_name();
+        if allow
+            .iter()
+            .any(|p| p.matches(&symbol.id) || p.matches(&qualified))
+        {
+            report.allowed_count += 1;
+            continue;
+        }
+        report.unused.push(symbol);
+    }
+
+    report.unused.sort_by(|a, b| {
+        (&a.package, &a.file, a.line, &a.id).cmp(&(&b.package, &b.file, b.line, &b.id))
+    });
+    report
+}
+
+/// Name qualified by package and owning type; symbols at the repository
+/// root are qualified by their owner only
+fn qualified_name(package: &str, owner: Option<&str>, name: &str) -> String {
+    let mut parts: Vec<&str> = Vec::new();
+    if !package.is_empty() {
+        parts.push(package);
+    }
+    parts.extend(owner);
+    parts.push(name);
+    parts.join(".")
+}
+
+/// Check whether a method may be called through an interface: some
+/// interface its type satisfies requires it, or it is a well-known standard
+/// library method
+fn satisfies_interface(node: &Node, sets: &MethodSets, owners: &HashMap<&str, Vec<&str>>) -> bool {
+    let Some(types) = owners.get(node.id.as_str()) else {
+        return false;
+    };
+    WELL_KNOWN_METHODS.contains(&node.name.as_str())
+        || types.iter().any(|type_id| {
+            sets.interfaces_of(type_id).iter().any(|interface| {
+                sets.interface_methods(interface)
+                    .is_some_and(|methods| methods.contains(&node.name))
+            })
+        })
+}
+
+/// Check if a node is declared in a Go file
+fn is_go(node: &Node) -> bool {
+    node.file.ends_with(".go")
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::{CallableKind, ContainerKind, DataKind, EdgeData};
+    use crate::test_support::{function, uses};
+
+    fn add_type(graph: &mut PetCodeGraph, id: &str, subtype: &str) {
+        let (file, name) = id.rsplit_once(':').unwrap();
+        let mut node = Node::container(
+            id.to_string(),
+            name.to_string(),
+            ContainerKind::Type,
+            Some(subtype.to_string()),
+            file.to_string(),
+            3,
+            6,
+        );
+        node.metadata.visibility = Some(visibility(name).to_string());
+        graph.add_node(node);
+    }
+
+    fn add_fn(graph: &mut PetCodeGraph, id: &str, receiver: Option<&str>) {
+        let mut node = function(id);
+        if receiver.is_some() {
+            node.kind = Some(CallableKind::Method.as_str().to_string());
+        }
+        node.line = 10;
+        node.end_line = 12;
+        node.metadata.visibility = Some(visibility(&node.name).to_string());
+        node.metadata.receiver = receiver.map(String::from);
+        graph.add_node(node);
+    }
+
+    fn visibility(name: &str) -> &'static str {
+        if name.starts_with(|c: char| c.is_uppercase()) {
+            "public"
+        } else {
+            "private"
+        }
+    }
+
+    /// A client library used by a command and by end-to-end tests
+    fn sample_graph() -> PetCodeGraph {
+        let mut graph = PetCodeGraph::new();
+        add_type(&mut graph, "client/client.go:Client", "struct");
+        add_fn(&mut graph, "client/client.go:New", None);
+        add_fn(&mut graph, "client/client.go:Get", Some("Client"));
+        add_fn(&mut graph, "client/client.go:Reset", Some("Client"));
+        add_fn(&mut graph, "client/client.go:String", Some("Client"));
+        add_fn(&mut graph, "client/client.go:Legacy", None);
+        add_fn(&mut graph, "client/client.go:helper", None);
+        add_type(&mut graph, "client/options.go:Options", "struct");
+        let mut timeout = Node::data(
+            "client/options.go:Options:Timeout".to_string(),
+            "Timeout".to_string(),
+            DataKind::Field,
+            None,
+            "client/options.go".to_string(),
+            4,
+            4,
+        );
+        timeout.metadata.visibility = Some("public".to_string());
+        graph.add_node(timeout);
+        graph.add_edge(
+            "client/options.go:Options",
+            "client/options.go:Options:Timeout",
+            EdgeData::contains(),
+        );
+        add_fn(&mut graph, "e2e/client_test.go:TestReset", None);
+        add_fn(&mut graph, "cmd/tool/main.go:main", None);
+        add_fn(&mut graph, "cmd/tool/main.go:Run", None);
+
+        uses(&mut graph, "cmd/tool/main.go:main", "client/client.go:New");
+        uses(&mut graph, "cmd/tool/main.go:main", "client/client.go:Get");
+        uses(
+            &mut graph,
+            "cmd/tool/main.go:main",
+            "client/options.go:Options:Timeout",
+        );
+        uses(
+            &mut graph,
+            "client/client.go:New",
+            "client/client.go:Client",
+        );
+        uses(
+            &mut graph,
+            "client/client.go:New",
+            "client/client.go:Legacy",
+        );
+        uses(
+            &mut graph,
+            "e2e/client_test.go:TestReset",
+            "client/client.go:Reset",
+        );
+        graph
+    }
+
+    fn unused_ids(report: &UnusedExportsReport) -> Vec<&str> {
+        report.unused.iter().map(|s| s.id.as_str()).collect()
+    }
+
+    #[test]
+    fn test_find_unused_exports() {
+        let report = find_unused_exports(&sample_graph(), &UnusedExportOptions::default());
+
+        // Client is only returned by New, Options only reached through a
+        // field, String satisfies fmt.Stringer, and main packages are skipped
+        assert_eq!(
+            unused_ids(&report),
+            vec!["client/client.go:Legacy", "client/client.go:Reset"]
+        );
+        assert_eq!(report.exported_count, 7);
+
+        let legacy = &report.unused[0];
+        assert_eq!(legacy.internal_references, 1);
+        assert_eq!(legacy.qualified_name(), "client.Legacy");
+        assert_eq!(report.unused[1].qualified_name(), "client.Client.Reset");
+    }
+
+    #[test]
+    fn test_find_unused_exports_options() {
+        let graph = sample_graph();
+
+        let with_tests = UnusedExportOptions {
+            include_tests: true,
+            ..Default::default()
+        };
+        assert_eq!(
+            unused_ids(&find_unused_exports(&graph, &with_tests)),
+            vec!["client/client.go:Legacy"]
+        );
+
+        let allowed = UnusedExportOptions {
+            allow: vec!["client.Client.*".to_string(), "*:Legacy".to_string()],
+            ..Default::default()
+        };
+        let report = find_unused_exports(&graph, &allowed);
+        assert!(report.unused.is_empty());
+        assert_eq!(report.allowed_count, 2);
+    }
+}
@@ -0,0 +1,572 @@
+//! Graph Archives
+//!
+//! A compressed single-file format (`.cpz`) for shipping graphs between CI
+//! and consumers. Nodes are grouped into chunks by package (the directory of
+//! their file), each chunk is compressed with zstd on its own, and an index
+//! header records where each chunk lives and which files it holds, so readers
+//! decompress only the chunks a query touches.
+//!
+//! # Layout
+//!
+//! ```text
+//! magic       8 bytes  "CPRYSMZ\0"
+//! version     u32 LE
+//! index size  u32 LE   compressed size of the index
+//! index       zstd-compressed JSON (see ArchiveIndex)
+//! chunks      zstd-compressed JSON {"nodes": [...], "edges": [...]}
+//! ```
+//!
+//! Chunk offsets in the index are relative to the end of the index. An edge
+//! is stored with its source's chunk and, when it crosses packages, also with
+//! its target's, so every chunk answers both directions for its own nodes.
+
+use std::collections::{BTreeMap, HashSet};
+use std::fs::File;
+use std::io::{BufReader, BufWriter, Read, Seek, SeekFrom, Write};
+use std::path::Path;
+
+use serde::{Deserialize, Serialize};
+use thiserror::Error;
+
+use crate::analysis::package_of;
+use crate::graph::{Edge, Node, PetCodeGraph, GRAPH_SCHEMA_VERSION};
+use crate::provenance::Provenance;
+
+/// File magic
+const MAGIC: &[u8; 8] = b"CPRYSMZ\0";
+
+/// Format version, bumped on incompatible layout changes
+pub const ARCHIVE_VERSION: u32 = 1;
+
+/// File extension of graph archives
+pub const ARCHIVE_EXTENSION: &str = "cpz";
+
+/// zstd compression level used for chunks and the index
+const COMPRESSION_LEVEL: i32 = 9;
+
+/// Errors that can occur reading or writing a graph archive.
+#[derive(Debug, Error)]
+pub enum ArchiveError {
+    /// IO error
+    #[error("IO error: {0}")]
+    Io(#[from] std::io::Error),
+
+    /// Index or chunk contents could not be encoded or decoded
+    #[error("Invalid archive contents: {0}")]
+    Json(#[from] serde_json::Error),
+
+    /// The file is not a graph archive
+    #[error("Not a graph archive: {0}")]
+    Invalid(String),
+
+    /// The file was written by an incompatible version
+    #[error("Unsupported archive version {found} (expected {expected})")]
+    Version { found: u32, expected: u32 },
+}
+
+/// Table of contents of an archive, read when it is opened.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct ArchiveIndex {
+    /// Graph schema version of the archived graph
+    pub schema_version: String,
+    /// Total number of nodes
+    pub node_count: usize,
+    /// Total number of edges
+    pub edge_count: usize,
+    /// Chunks in file order
+    pub chunks: Vec<ChunkInfo>,
+    /// Chunk holding each file's nodes
+    pub files: BTreeMap<String, usize>,
+    /// Chunk of each node whose ID does not start with its file path
+    /// (repositories, workspaces, components)
+    pub other_nodes: BTreeMap<String, usize>,
+    /// Provenance of the archived index, if known
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub provenance: Option<Provenance>,
+}
+
+/// Location and size of one package's chunk.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct ChunkInfo {
+    /// Package (directory) the chunk's nodes belong to; "" for the root
+    pub package: String,
+    /// Offset from the end of the index
+    pub offset: u64,
+    /// Compressed size in bytes
+    pub size: u64,
+    /// Number of nodes in the chunk
+    pub nodes: usize,
+    /// Number of edges stored in the chunk, including copies of edges that
+    /// cross into it from other packages
+    pub edges: usize,
+}
+
+/// Decompressed contents of a chunk
+#[derive(Debug, Default, Serialize, Deserialize)]
+struct Chunk {
+    nodes: Vec<Node>,
+    edges: Vec<Edge>,
+}
+
+// ============================================================================
+// Writing
+// ============================================================================
+
+/// Write a graph as an archive, returning its index.
+///
+/// `provenance` describes the index the graph was read from, if known.
+pub fn write_archive<W: Write>(
+    graph: &PetCodeGraph,
+    provenance: Option<&Provenance>,
+    writer: W,
+) -> Result<ArchiveIndex, ArchiveError> {
+    let mut chunks: BTreeMap<&str, Chunk> = BTreeMap::new();
+    let mut package_of_node: BTreeMap<&str, &str> = BTreeMap::new();
+    for node in graph.iter_nodes() {
+        let package = package_of(&node.file);
+        package_of_node.insert(&node.id, package);
+        chunks.entry(package).or_default().nodes.push(node.clone());
+    }
+
+    let mut edge_count = 0;
+    for edge in graph.iter_edges() {
+        let (Some(&source), Some(&target)) = (
+            package_of_node.get(edge.source.as_str()),
+            package_of_node.get(edge.target.as_str()),
+        ) else {
+            continue;
+        };
+        edge_count += 1;
+        if source != target {
+            chunks.entry(target).or_default().edges.push(edge.clone());
+        }
+        chunks.entry(source).or_default().edges.push(edge);
+    }
+
+    let mut index = ArchiveIndex {
+        schema_version: GRAPH_SCHEMA_VERSION.to_string(),
+        node_count: graph.node_count(),
+        edge_count,
+        chunks: Vec::with_capacity(chunks.len()),
+        files: BTreeMap::new(),
+        other_nodes: BTreeMap::new(),
+        provenance: provenance.cloned(),
+    };
+    let mut data = Vec::new();
+    for (position, (package, mut chunk)) in chunks.into_iter().enumerate() {
+        chunk.nodes.sort_by(|a, b| a.id.cmp(&b.id));
+        chunk.edges.sort_by(Edge::stable_cmp);
+        for node in &chunk.nodes {
+            if is_under_file(&node.id, &node.file) {
+                index.files.insert(node.file.clone(), position);
+            } else {
+                index.other_nodes.insert(node.id.clone(), position);
+            }
+        }
+
+        let compressed =
+            zstd::encode_all(serde_json::to_vec(&chunk)?.as_slice(), COMPRESSION_LEVEL)?;
+        index.chunks.push(ChunkInfo {
+            package: package.to_string(),
+            offset: data.len() as u64,
+            size: compressed.len() as u64,
+            nodes: chunk.nodes.len(),
+            edges: chunk.edges.len(),
+        });
+        data.extend_from_slice(&compressed);
+    }
+
+    let compressed_index =
+        zstd::encode_all(serde_json::to_vec(&index)?.as_slice(), COMPRESSION_LEVEL)?;
+    let mut writer = BufWriter::new(writer);
+    writer.write_all(MAGIC)?;
+    writer.write_all(&ARCHIVE_VERSION.to_le_bytes())?;
+    writer.write_all(&(compressed_index.len() as u32).to_le_bytes())?;
+    writer.write_all(&compressed_index)?;
+    writer.write_all(&data)?;
+    writer.flush()?;
+
+    Ok(index)
+}
+
+/// Whether a node ID names its file or something inside it
+fn is_under_file(id: &str, file: &str) -> bool {
+    !file.is_empty()
+        && id
+            .strip_prefix(file)
+            .is_some_and(|rest| rest.is_empty() || rest.starts_with(':'))
+}
+
+// ============================================================================
+// Reading
+// ============================================================================
+
+/// A graph archive opened for random-access reads.
+///
+/// Opening reads only the header and index; each query decompresses the one
+/// chunk holding the node it asks about. The most recently read chunk is
+/// kept decompressed, so queries within one package do not repeat the work.
+///
+/// ## Example
+///
+/// ```ignore
+/// use codeprysm_core::archive::GraphArchive;
+///
+/// let mut archive = GraphArchive::open(Path::new("graph.cpz"))?;
+/// if let Some(node) = archive.get_node("src/api.py:handle")? {
+///     println!("{} at line {}", node.id, node.line);
+/// }
+/// for edge in archive.edges_of("src/api.py:handle")? {
+///     println!("{} -> {}", edge.source, edge.target);
+/// }
+/// ```
+pub struct GraphArchive<R = BufReader<File>> {
+    reader: R,
+    index: ArchiveIndex,
+    /// Offset of the first chunk
+    data_start: u64,
+    /// Most recently read chunk
+    cached: Option<(usize, Chunk)>,
+}
+
+impl GraphArchive {
+    /// Open an archive file.
+    pub fn open(path: &Path) -> Result<Self, ArchiveError> {
+        Self::from_reader(BufReader::new(File::open(path)?))
+    }
+
+    /// Write a graph as an archive file, returning its index.
+    pub fn write(
+        graph: &PetCodeGraph,
+        provenance: Option<&Provenance>,
+        path: &Path,
+    ) -> Result<ArchiveIndex, ArchiveError> {
+        write_archive(graph, provenance, File::create(path)?)
+    }
+}
+
+impl<R: Read + Seek> GraphArchive<R> {
+    /// Open an archive from a reader positioned anywhere.
+    pub fn from_reader(mut reader: R) -> Result<Self, ArchiveError> {
+        reader.seek(SeekFrom::Start(0))?;
+        let mut header = [0u8; 16];
+        reader
+            .read_exact(&mut header)
+            .map_err(|_| ArchiveError::Invalid("missing header".to_string()))?;
+        if &header[..8] != MAGIC {
+            return Err(ArchiveError::Invalid("bad magic".to_string()));
+        }
+        let version = u32::from_le_bytes(header[8..12].try_into().unwrap());
+        if version != ARCHIVE_VERSION {
+            return Err(ArchiveError::Version {
+                found: version,
+                expected: ARCHIVE_VERSION,
+            });
+        }
+        let index_size = u32::from_le_bytes(header[12..16].try_into().unwrap()) as u64;
+
+        let mut compressed = Vec::new();
+        (&mut reader)
+            .take(index_size)
+            .read_to_end(&mut compressed)?;
+        if compressed.len() as u64 != index_size {
+            return Err(ArchiveError::Invalid("truncated index".to_string()));
+        }
+        let index: ArchiveIndex =
+            serde_json::from_slice(&zstd::decode_all(compressed.as_slice())?)?;
+
+        Ok(Self {
+            reader,
+            index,
+            data_start: 16 + index_size,
+            cached: None,
+        })
+    }
+
+    /// The archive's index.
+    pub fn index(&self) -> &ArchiveIndex {
+        &self.index
+    }
+
+    /// Chunk holding a node, from the index alone.
+    pub fn chunk_of(&self, node_id: &str) -> Option<usize> {
+        if let Some(&chunk) = self.index.other_nodes.get(node_id) {
+            return Some(chunk);
+        }
+        // The file is the longest prefix of the ID that ends at a ':'
+        let mut end = node_id.len();
+        loop {
+            if let Some(&chunk) = self.index.files.get(&node_id[..end]) {
+                return Some(chunk);
+            }
+            end = node_id[..end].rfind(':')?;
+        }
+    }
+
+    /// Decompress a chunk, reusing the most recently read one.
+    fn chunk(&mut self, position: usize) -> Result<&Chunk, ArchiveError> {
+        if self.cached.as_ref().is_none_or(|(p, _)| *p != position) {
+            let info =
+                self.index.chunks.get(position).ok_or_else(|| {
+                    ArchiveError::Invalid(format!("chunk {} out of range", position))
+                })?;
+            let start = self.data_start.checked_add(info.offset).ok_or_else(|| {
+                ArchiveError::Invalid(format!("chunk {} is out of range", info.package))
+            })?;
+            self.reader.seek(SeekFrom::Start(start))?;
+            // Sizes come from the index, so the buffer grows with what is
+            // actually read rather than being reserved up front
+            let mut compressed = Vec::new();
+            (&mut self.reader)
+                .take(info.size)
+                .read_to_end(&mut compressed)?;
+            if compressed.len() as u64 != info.size {
+                return Err(ArchiveError::Invalid(format!(
+                    "chunk {} is truncated",
+                    info.package
+                )));
+            }
+            let chunk: Chunk = serde_json::from_slice(&zstd::decode_all(compressed.as_slice())?)?;
+            self.cached = Some((position, chunk));
+        }
+        Ok(&self.cached.as_ref().unwrap().1)
+    }
+
+    /// Look up a node by ID.
+    pub fn get_node(&mut self, node_id: &str) -> Result<Option<Node>, ArchiveError> {
+        let Some(position) = self.chunk_of(node_id) else {
+            return Ok(None);
+        };
+        let chunk = self.chunk(position)?;
+        Ok(chunk
+            .nodes
+            .binary_search_by(|n| n.id.as_str().cmp(node_id))
+            .ok()
+            .map(|i| chunk.nodes[i].clone()))
+    }
+
+    /// Edges into and out of a node.
+    pub fn edges_of(&mut self, node_id: &str) -> Result<Vec<Edge>, ArchiveError> {
+        let Some(position) = self.chunk_of(node_id) else {
+            return Ok(Vec::new());
+        };
+        Ok(self
+            .chunk(position)?
+            .edges
+            .iter()
+            .filter(|e| e.source == node_id || e.target == node_id)
+            .cloned()
+            .collect())
+    }
+
+    /// Read one package's nodes and the edges among them.
+    ///
+    /// Returns `None` if the archive has no such package.
+    pub fn read_package(&mut self, package: &str) -> Result<Option<PetCodeGraph>, ArchiveError> {
+        let Some(position) = self.index.chunks.iter().position(|c| c.package == package) else {
+            return Ok(None);
+        };
+        let chunk = self.chunk(position)?;
+        let mut graph = PetCodeGraph::new();
+        for node in &chunk.nodes {
+            graph.add_node(node.clone());
+        }
+        // Edges to other packages are dropped, as their other end is missing
+        for edge in &chunk.edges {
+            graph.add_edge_from_struct(edge);
+        }
+        Ok(Some(graph))
+    }
+
+    /// Read the whole graph.
+    pub fn read_all(&mut self) -> Result<PetCodeGraph, ArchiveError> {
+        let (nodes, edges) = self.read_contents()?;
+        let mut graph = PetCodeGraph::new();
+        for node in nodes {
+            graph.add_node(node);
+        }
+        for edge in &edges {
+            graph.add_edge_from_struct(edge);
+        }
+        Ok(graph)
+    }
+
+    /// Read every stored node and edge, without assembling a graph.
+    ///
+    /// Nodes stored in several chunks are returned once per copy, and edges
+    /// whether or not their endpoints exist, for index validation.
+    pub fn read_contents(&mut self) -> Result<(Vec<Node>, Vec<Edge>), ArchiveError> {
+        let mut nodes = Vec::new();
+        let mut edges = Vec::new();
+        let mut foreign = Vec::new();
+        for position in 0..self.index.chunks.len() {
+            let chunk = self.chunk(position)?;
+            let ids: HashSet<&str> = chunk.nodes.iter().map(|n| n.id.as_str()).collect();
+            nodes.extend(chunk.nodes.iter().cloned());
+            for edge in &chunk.edges {
+                if ids.contains(edge.source.as_str()) {
+                    edges.push(edge.clone());
+                } else {
+                    foreign.push(edge.clone());
+                }
+            }
+        }
+
+        // Cross-package edges are stored twice; keep the source's copy, or
+        // one copy if the source is in no chunk
+        let ids: HashSet<&str> = nodes.iter().map(|n| n.id.as_str()).collect();
+        foreign.retain(|edge| !ids.contains(edge.source.as_str()));
+        foreign.sort_by(Edge::stable_cmp);
+        foreign.dedup_by(|a, b| a.stable_cmp(b).is_eq());
+        edges.extend(foreign);
+        Ok((nodes, edges))
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::{CallableKind, EdgeData};
+    use std::io::Cursor;
+
+    fn sample_graph() -> PetCodeGraph {
+        let mut graph = PetCodeGraph::new();
+        graph.add_node(Node::repository("demo".to_string(), Default::default()));
+        for (file, name) in [("api/handlers.go", "Handle"), ("db/conn.go", "Connect")] {
+            graph.add_node(Node::source_file(
+                file.to_string(),
+                file.to_string(),
+                "hash".to_string(),
+                10,
+            ));
+            graph.add_node(Node::callable(
+                format!("{}:{}", file, name),
+                name.to_string(),
+                CallableKind::Function,
+                file.to_string(),
+                3,
+                8,
+            ));
+            graph.add_edge("demo", file, EdgeData::contains());
+            graph.add_edge(file, &format!("{}:{}", file, name), EdgeData::contains());
+        }
+        graph.add_edge(
+            "api/handlers.go:Handle",
+            "db/conn.go:Connect",
+            EdgeData::uses(Some(5), Some("Connect".to_string())),
+        );
+        graph
+    }
+
+    fn archive() -> GraphArchive<Cursor<Vec<u8>>> {
+        let mut bytes = Vec::new();
+        write_archive(&sample_graph(), None, &mut bytes).unwrap();
+        GraphArchive::from_reader(Cursor::new(bytes)).unwrap()
+    }
+
+    #[test]
+    fn test_index() {
+        let archive = archive();
+        let index = archive.index();
+        assert_eq!(index.node_count, 5);
+        assert_eq!(index.edge_count, 5);
+        let packages: Vec<&str> = index.chunks.iter().map(|c| c.package.as_str()).collect();
+        assert_eq!(packages, vec!["", "api", "db"]);
+        assert_eq!(index.other_nodes.get("demo"), Some(&0));
+        assert!(index.provenance.is_none());
+        assert_eq!(archive.chunk_of("db/conn.go:Connect"), Some(2));
+        assert_eq!(archive.chunk_of("db/conn.go"), Some(2));
+        assert_eq!(archive.chunk_of("missing.go:x"), None);
+    }
+
+    #[test]
+    fn test_random_access() {
+        let mut archive = archive();
+        let node = archive.get_node("db/conn.go:Connect").unwrap().unwrap();
+        assert_eq!(node.name, "Connect");
+        assert!(archive.get_node("db/conn.go:Missing").unwrap().is_none());
+
+        // The cross-package USES edge is visible from both ends
+        let edges = archive.edges_of("db/conn.go:Connect").unwrap();
+        assert!(edges.iter().any(|e| e.source == "api/handlers.go:Handle"));
+        let edges = archive.edges_of("api/handlers.go:Handle").unwrap();
+        assert!(edges.iter().any(|e| e.target == "db/conn.go:Connect"));
+
+        let api = archive.read_package("api").unwrap().unwrap();
+        assert_eq!(api.node_count(), 2);
+        assert_eq!(api.edge_count(), 1);
+        assert!(archive.read_package("web").unwrap().is_none());
+    }
+
+    #[test]
+    fn test_read_all() {
+        let graph = sample_graph();
+        let restored = archive().read_all().unwrap();
+        assert_eq!(restored.node_count(), graph.node_count());
+        assert_eq!(restored.edge_count(), graph.edge_count());
+        for node in graph.iter_nodes() {
+            assert_eq!(restored.get_node(&node.id), Some(node));
+        }
+
+        // Raw contents keep one copy of the cross-package edge
+        let (nodes, edges) = archive().read_contents().unwrap();
+        assert_eq!(nodes.len(), 5);
+        assert_eq!(edges.len(), 5);
+        assert_eq!(
+            edges
+                .iter()
+                .filter(|e| e.target == "db/conn.go:Connect")
+                .count(),
+            2
+        );
+    }
+
+    /// The sample archive with its index changed by `edit`
+    fn corrupt_archive(edit: impl FnOnce(&mut ArchiveIndex)) -> GraphArchive<Cursor<Vec<u8>>> {
+        let mut bytes = Vec::new();
+        write_archive(&sample_graph(), None, &mut bytes).unwrap();
+        let index_size = u32::from_le_bytes(bytes[12..16].try_into().unwrap()) as usize;
+        let chunks = bytes.split_off(16 + index_size);
+        let mut index: ArchiveIndex =
+            serde_json::from_slice(&zstd::decode_all(&bytes[16..]).unwrap()).unwrap();
+        edit(&mut index);
+
+        let compressed =
+            zstd::encode_all(serde_json::to_vec(&index).unwrap().as_slice(), 0).unwrap();
+        bytes.truncate(12);
+        bytes.extend_from_slice(&(compressed.len() as u32).to_le_bytes());
+        bytes.extend_from_slice(&compressed);
+        bytes.extend_from_slice(&chunks);
+        GraphArchive::from_reader(Cursor::new(bytes)).unwrap()
+    }
+
+    #[test]
+    fn test_corrupt_index() {
+        // Counts and sizes in the index are not trusted for allocation
+        let mut archive = corrupt_archive(|index| {
+            index.node_count = usize::MAX;
+            index.edge_count = usize::MAX;
+        });
+        let (nodes, edges) = archive.read_contents().unwrap();
+        assert_eq!((nodes.len(), edges.len()), (5, 5));
+
+        let mut archive = corrupt_archive(|index| index.chunks[2].size = u64::MAX);
+        let result = archive.get_node("db/conn.go:Connect");
+        assert!(matches!(result, Err(ArchiveError::Invalid(_))));
+
+        let mut archive = corrupt_archive(|index| index.chunks[2].offset = u64::MAX);
+        let result = archive.get_node("db/conn.go:Connect");
+        assert!(matches!(result, Err(ArchiveError::Invalid(_))));
+        // Other chunks are still readable
+        assert!(archive
+            .get_node("api/handlers.go:Handle")
+            .unwrap()
+            .is_some());
+    }
+
+    #[test]
+    fn test_rejects_other_files() {
+        let result = GraphArchive::from_reader(Cursor::new(b"PK\x03\x04 not an archive".to_vec()));
+        assert!(matches!(result, Err(ArchiveError::Invalid(_))));
+    }
+}
@@ -0,0 +1,569 @@
+//! Indexing Benchmark
+//!
+//! Measures how fast the builder indexes a directory, so performance
+//! regressions across releases show up as numbers users can compare. Each
+//! file is timed through tree-sitter parsing and entity extraction, and the
+//! timings are summed by language and by file-size bucket. Reference
+//! resolution runs once over the whole directory and is timed on its own.
+//!
+//! Heap usage is reported when the binary installs [`CountingAllocator`] as
+//! its global allocator; otherwise it is left out.
+
+use std::alloc::{GlobalAlloc, Layout, System};
+use std::collections::{BTreeMap, HashMap};
+use std::io::{self, Write};
+use std::path::Path;
+use std::sync::atomic::{AtomicUsize, Ordering};
+use std::time::{Duration, Instant};
+
+use serde::Serialize;
+use tracing::{info, info_span, warn};
+
+use crate::builder::{BuilderError, GraphBuilder, ReferenceInfo};
+use crate::merkle::compute_content_hash;
+use crate::parser::{CodeParser, SupportedLanguage};
+
+/// Upper bounds (exclusive) and labels of the file-size buckets
+const SIZE_BUCKETS: [(u64, &str); 5] = [
+    (4 * 1024, "<4K"),
+    (16 * 1024, "4K-16K"),
+    (64 * 1024, "16K-64K"),
+    (256 * 1024, "64K-256K"),
+    (u64::MAX, ">=256K"),
+];
+
+// ============================================================================
+// Heap Accounting
+// ============================================================================
+
+static HEAP_CURRENT: AtomicUsize = AtomicUsize::new(0);
+static HEAP_PEAK: AtomicUsize = AtomicUsize::new(0);
+static HEAP_ALLOCATED: AtomicUsize = AtomicUsize::new(0);
+
+/// Global allocator that counts live and peak heap bytes.
+///
+/// Install it in a binary to get heap figures in benchmark reports:
+///
+/// ```ignore
+/// #[global_allocator]
+/// static ALLOC: codeprysm_core::bench::CountingAllocator =
+///     codeprysm_core::bench::CountingAllocator;
+/// ```
+pub struct CountingAllocator;
+
+impl CountingAllocator {
+    fn record_alloc(size: usize) {
+        let current = HEAP_CURRENT.fetch_add(size, Ordering::Relaxed) + size;
+        HEAP_PEAK.fetch_max(current, Ordering::Relaxed);
+        HEAP_ALLOCATED.fetch_add(size, Ordering::Relaxed);
+    }
+
+    fn record_dealloc(size: usize) {
+        HEAP_CURRENT.fetch_sub(size, Ordering::Relaxed);
+    }
+}
+
+unsafe impl GlobalAlloc for CountingAllocator {
+    unsafe fn alloc(&self, layout: Layout) -> *mut u8 {
+        let ptr = System.alloc(layout);
+        if !ptr.is_null() {
+            Self::record_alloc(layout.size());
+        }
+        ptr
+    }
+
+    unsafe fn alloc_zeroed(&self, layout: Layout) -> *mut u8 {
+        let ptr = System.alloc_zeroed(layout);
+        if !ptr.is_null() {
+            Self::record_alloc(layout.size());
+        }
+        ptr
+    }
+
+    unsafe fn dealloc(&self, ptr: *mut u8, layout: Layout) {
+        System.dealloc(ptr, layout);
+        Self::record_dealloc(layout.size());
+    }
+
+    unsafe fn realloc(&self, ptr: *mut u8, layout: Layout, new_size: usize) -> *mut u8 {
+        let new_ptr = System.realloc(ptr, layout, new_size);
+        if !new_ptr.is_null() {
+            Self::record_dealloc(layout.size());
+            Self::record_alloc(new_size);
+        }
+        new_ptr
+    }
+}
+
+/// Snapshot of the heap counters
+#[derive(Debug, Clone, Copy)]
+struct HeapMark {
+    current: usize,
+    allocated: usize,
+}
+
+impl HeapMark {
+    /// Read the counters, or `None` if [`CountingAllocator`] is not installed
+    fn take() -> Option<Self> {
+        let current = HEAP_CURRENT.load(Ordering::Relaxed);
+        (current > 0).then(|| Self {
+            current,
+            allocated: HEAP_ALLOCATED.load(Ordering::Relaxed),
+        })
+    }
+
+    /// Start a phase: its peak is measured from the current usage
+    fn start_phase() -> Option<Self> {
+        let mark = Self::take()?;
+        HEAP_PEAK.store(mark.current, Ordering::Relaxed);
+        Some(mark)
+    }
+
+    /// Peak bytes above this mark since it was taken
+    fn peak_since(&self) -> usize {
+        HEAP_PEAK
+            .load(Ordering::Relaxed)
+            .saturating_sub(self.current)
+    }
+
+    /// Bytes allocated since this mark was taken, freed or not
+    fn allocated_since(&self) -> usize {
+        HEAP_ALLOCATED
+            .load(Ordering::Relaxed)
+            .saturating_sub(self.allocated)
+    }
+}
+
+// ============================================================================
+// Report
+// ============================================================================
+
+/// Timings of the files of one language or size bucket.
+#[derive(Debug, Clone, Default, Serialize)]
+pub struct ThroughputRow {
+    /// Language name or size bucket label
+    pub label: String,
+    /// Files indexed
+    pub files: usize,
+    /// Bytes of source
+    pub bytes: u64,
+    /// Lines of source
+    pub lines: usize,
+    /// Time spent in tree-sitter parsing, in milliseconds
+    pub parse_ms: f64,
+    /// Time spent extracting entities from parse trees, in milliseconds
+    pub extract_ms: f64,
+}
+
+impl ThroughputRow {
+    fn new(label: &str) -> Self {
+        Self {
+            label: label.to_string(),
+            ..Self::default()
+        }
+    }
+
+    fn add(&mut self, file: &FileTiming) {
+        self.files += 1;
+        self.bytes += file.bytes;
+        self.lines += file.lines;
+        self.parse_ms += file.parse_us as f64 / 1000.0;
+        self.extract_ms += file.extract_us as f64 / 1000.0;
+    }
+
+    /// Parse throughput in MiB of source per second
+    pub fn parse_mib_per_sec(&self) -> f64 {
+        mib_per_sec(self.bytes, self.parse_ms)
+    }
+
+    /// Extraction throughput in MiB of source per second
+    pub fn extract_mib_per_sec(&self) -> f64 {
+        mib_per_sec(self.bytes, self.extract_ms)
+    }
+
+    /// Lines parsed and extracted per second
+    pub fn lines_per_sec(&self) -> f64 {
+        per_sec(self.lines as f64, self.parse_ms + self.extract_ms)
+    }
+}
+
+/// Timing of reference resolution over the whole directory.
+#[derive(Debug, Clone, Default, Serialize)]
+pub struct ResolveTiming {
+    /// References recorded by extraction
+    pub references: usize,
+    /// USES edges created
+    pub resolved: usize,
+    /// Time spent resolving, in milliseconds
+    pub resolve_ms: f64,
+}
+
+impl ResolveTiming {
+    /// References resolved per second
+    pub fn references_per_sec(&self) -> f64 {
+        per_sec(self.references as f64, self.resolve_ms)
+    }
+}
+
+/// Heap usage of a benchmark run, when heap accounting is available.
+#[derive(Debug, Clone, Default, Serialize)]
+pub struct HeapUsage {
+    /// Peak heap growth while parsing and extracting, in bytes
+    pub extract_peak_bytes: usize,
+    /// Peak heap growth while resolving references, in bytes
+    pub resolve_peak_bytes: usize,
+    /// Total bytes allocated over the run, freed or not
+    pub allocated_bytes: usize,
+}
+
+/// Timing of one file.
+#[derive(Debug, Clone, Serialize)]
+pub struct FileTiming {
+    /// Path relative to the benchmarked directory
+    pub path: String,
+    /// Language name
+    pub language: String,
+    /// Bytes of source
+    pub bytes: u64,
+    /// Lines of source
+    pub lines: usize,
+    /// When the file started, in microseconds since the run started
+    pub start_us: u64,
+    /// Time spent in tree-sitter parsing, in microseconds
+    pub parse_us: u64,
+    /// Time spent extracting entities, in microseconds
+    pub extract_us: u64,
+    /// Bytes allocated while indexing the file (0 without heap accounting)
+    pub allocated_bytes: usize,
+}
+
+/// Result of [`run_benchmark`].
+#[derive(Debug, Clone, Serialize)]
+pub struct BenchReport {
+    /// codeprysm version that ran the benchmark
+    pub version: String,
+    /// Files indexed
+    pub files: usize,
+    /// Bytes of source
+    pub bytes: u64,
+    /// Files that failed to read or parse
+    pub parse_errors: usize,
+    /// Nodes extracted
+    pub nodes: usize,
+    /// Wall-clock time of the run, in milliseconds
+    pub total_ms: f64,
+    /// Totals of all files
+    pub overall: ThroughputRow,
+    /// Totals by language, sorted by name
+    pub languages: Vec<ThroughputRow>,
+    /// Totals by file-size bucket, smallest first (empty buckets omitted)
+    pub sizes: Vec<ThroughputRow>,
+    /// Reference resolution
+    pub resolve: ResolveTiming,
+    /// Heap usage, if the binary counts allocations
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub heap: Option<HeapUsage>,
+    /// Per-file timings, in indexing order
+    #[serde(skip)]
+    pub file_timings: Vec<FileTiming>,
+}
+
+impl BenchReport {
+    /// Write the run as a Chrome trace (one event per file, plus
+    /// resolution), viewable in Perfetto or `chrome://tracing`.
+    pub fn write_trace<W: Write>(&self, writer: W) -> io::Result<()> {
+        let mut events: Vec<serde_json::Value> = self
+            .file_timings
+            .iter()
+            .map(|file| {
+                serde_json::json!({
+                    "name": file.path,
+                    "cat": file.language,
+                    "ph": "X",
+                    "ts": file.start_us,
+                    "dur": file.parse_us + file.extract_us,
+                    "pid": 1,
+                    "tid": 1,
+                    "args": {
+                        "bytes": file.bytes,
+                        "lines": file.lines,
+                        "parse_us": file.parse_us,
+                        "extract_us": file.extract_us,
+                        "allocated_bytes": file.allocated_bytes,
+                    },
+                })
+            })
+            .collect();
+        if let Some(last) = self.file_timings.last() {
+            events.push(serde_json::json!({
+                "name": "resolve",
+                "cat": "resolve",
+                "ph": "X",
+                "ts": last.start_us + last.parse_us + last.extract_us,
+                "dur": (self.resolve.resolve_ms * 1000.0) as u64,
+                "pid": 1,
+                "tid": 1,
+                "args": {
+                    "references": self.resolve.references,
+                    "resolved": self.resolve.resolved,
+                },
+            }));
+        }
+        serde_json::to_writer(writer, &serde_json::json!({ "traceEvents": events }))?;
+        Ok(())
+    }
+
+    /// Write heap usage and the files that allocated the most, as JSON.
+    ///
+    /// Returns `false` without writing if heap accounting was unavailable.
+    pub fn write_heap_profile<W: Write>(&self, writer: W, top: usize) -> io::Result<bool> {
+        let Some(heap) = &self.heap else {
+            return Ok(false);
+        };
+        let mut files: Vec<&FileTiming> = self.file_timings.iter().collect();
+        files.sort_by(|a, b| b.allocated_bytes.cmp(&a.allocated_bytes));
+        files.truncate(top);
+        let profile = serde_json::json!({
+            "summary": heap,
+            "files": files
+                .iter()
+                .map(|f| serde_json::json!({
+                    "path": f.path,
+                    "language": f.language,
+                    "bytes": f.bytes,
+                    "allocated_bytes": f.allocated_bytes,
+                }))
+                .collect::<Vec<_>>(),
+        });
+        serde_json::to_writer_pretty(writer, &profile)?;
+        Ok(true)
+    }
+}
+
+fn per_sec(amount: f64, ms: f64) -> f64 {
+    if ms > 0.0 {
+        amount * 1000.0 / ms
+    } else {
+        0.0
+    }
+}
+
+fn mib_per_sec(bytes: u64, ms: f64) -> f64 {
+    per_sec(bytes as f64 / (1024.0 * 1024.0), ms)
+}
+
+fn size_bucket(bytes: u64) -> usize {
+    SIZE_BUCKETS
+        .iter()
+        .position(|(limit, _)| bytes < *limit)
+        .unwrap_or(SIZE_BUCKETS.len() - 1)
+}
+
+fn micros(duration: Duration) -> u64 {
+    duration.as_micros() as u64
+}
+
+// ============================================================================
+// Benchmark
+// ============================================================================
+
+/// Index a directory with `builder`, timing each phase.
+///
+/// Files are collected as [`GraphBuilder::build_from_directory`] would,
+/// honoring the builder's exclude patterns and file limit. Parse time is a
+/// separate tree-sitter parse of each file; extract time is the rest of the
+/// file's extraction. Nothing is written to disk and the extraction cache is
+/// not used.
+pub fn run_benchmark(
+    builder: &mut GraphBuilder,
+    directory: &Path,
+) -> Result<BenchReport, BuilderError> {
+    let _span = info_span!("bench", root = %directory.display()).entered();
+    let start = Instant::now();
+
+    let mut files = builder.collect_files(directory)?;
+    if files.is_empty() {
+        return Err(BuilderError::NoFilesFound(directory.to_path_buf()));
+    }
+    if let Some(max) = builder.config().max_files {
+        files.truncate(max);
+    }
+    info!(
+        "Benchmarking {} files in {}",
+        files.len(),
+        directory.display()
+    );
+
+    let mut file_timings = Vec::with_capacity(files.len());
+    let mut parse_errors = 0;
+    let mut nodes = 0;
+    let mut defines: HashMap<String, String> = HashMap::new();
+    let mut references: HashMap<String, Vec<ReferenceInfo>> = HashMap::new();
+    let mut source_files: HashMap<String, String> = HashMap::new();
+
+    let run_mark = HeapMark::start_phase();
+    for file_path in files {
+        let Some(language) = SupportedLanguage::from_path(&file_path) else {
+            continue;
+        };
+        let rel_path = file_path
+            .strip_prefix(directory)
+            .unwrap_or(&file_path)
+            .to_string_lossy()
+            .to_string();
+
+        let file_mark = HeapMark::take();
+        let file_start = start.elapsed();
+        let timed = std::fs::read(&file_path)
+            .map_err(BuilderError::from)
+            .and_then(|content| {
+                let bytes = content.len() as u64;
+                let source = std::str::from_utf8(&content)
+                    .map_err(|e| io::Error::new(io::ErrorKind::InvalidData, e))?;
+                let lines = source.lines().count();
+
+                let parse_start = Instant::now();
+                CodeParser::new(language)?.parse(source)?;
+                let parse = parse_start.elapsed();
+
+                let hash = compute_content_hash(&content);
+                let extract_start = Instant::now();
+                let extraction = builder.extract_file(language, content, hash, &rel_path)?;
+                let extract = extract_start.elapsed().saturating_sub(parse);
+                Ok((bytes, lines, parse, extract, extraction))
+            });
+        let (bytes, lines, parse, extract, extraction) = match timed {
+            Ok(timed) => timed,
+            Err(e) => {
+                warn!("Error processing {}: {}", rel_path, e);
+                parse_errors += 1;
+                continue;
+            }
+        };
+
+        nodes += extraction.nodes.len();
+        let node_files: HashMap<&str, &str> = extraction
+            .nodes
+            .iter()
+            .map(|n| (n.id.as_str(), n.file.as_str()))
+            .collect();
+        for (name, refs) in extraction.references {
+            for reference in &refs {
+                if let Some(file) = node_files.get(reference.source_id.as_str()) {
+                    source_files.insert(reference.source_id.clone(), file.to_string());
+                }
+            }
+            references.entry(name).or_default().extend(refs);
+        }
+        defines.extend(extraction.defines);
+
+        file_timings.push(FileTiming {
+            path: rel_path,
+            language: language.as_str().to_string(),
+            bytes,
+            lines,
+            start_us: micros(file_start),
+            parse_us: micros(parse),
+            extract_us: micros(extract),
+            allocated_bytes: file_mark.map_or(0, |m| m.allocated_since()),
+        });
+    }
+    let extract_peak = run_mark.map(|m| m.peak_since());
+
+    let resolve_mark = HeapMark::start_phase();
+    let resolve_start = Instant::now();
+    let mut resolved = 0;
+    GraphBuilder::resolve_into(
+        &defines,
+        &references,
+        |id| source_files.get(id).cloned(),
+        |_| resolved += 1,
+    );
+    let resolve = ResolveTiming {
+        references: references.values().map(Vec::len).sum(),
+        resolved,
+        resolve_ms: resolve_start.elapsed().as_secs_f64() * 1000.0,
+    };
+
+    let heap = match (run_mark, resolve_mark) {
+        (Some(run_mark), Some(resolve_mark)) => Some(HeapUsage {
+            extract_peak_bytes: extract_peak.unwrap_or(0),
+            resolve_peak_bytes: resolve_mark.peak_since(),
+            allocated_bytes: run_mark.allocated_since(),
+        }),
+        _ => None,
+    };
+
+    let mut overall = ThroughputRow::new("all");
+    let mut languages: BTreeMap<String, ThroughputRow> = BTreeMap::new();
+    let mut sizes: Vec<ThroughputRow> = SIZE_BUCKETS
+        .iter()
+        .map(|(_, label)| ThroughputRow::new(label))
+        .collect();
+    for file in &file_timings {
+        overall.add(file);
+        languages
+            .entry(file.language.clone())
+            .or_insert_with(|| ThroughputRow::new(&file.language))
+            .add(file);
+        sizes[size_bucket(file.bytes)].add(file);
+    }
+    sizes.retain(|row| row.files > 0);
+
+    Ok(BenchReport {
+        version: env!("CARGO_PKG_VERSION").to_string(),
+        files: overall.files,
+        bytes: overall.bytes,
+        parse_errors,
+        nodes,
+        total_ms: start.elapsed().as_secs_f64() * 1000.0,
+        overall,
+        languages: languages.into_values().collect(),
+        sizes,
+        resolve,
+        heap,
+        file_timings,
+    })
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_size_buckets() {
+        assert_eq!(SIZE_BUCKETS[size_bucket(0)].1, "<4K");
+        assert_eq!(SIZE_BUCKETS[size_bucket(4 * 1024)].1, "4K-16K");
+        assert_eq!(SIZE_BUCKETS[size_bucket(10 << 20)].1, ">=256K");
+    }
+
+    #[test]
+    fn test_benchmark_breaks_down_by_language() {
+        let repo = tempfile::tempdir().unwrap();
+        std::fs::write(repo.path().join("util.py"), "def helper():\n    return 1\n").unwrap();
+        std::fs::write(
+            repo.path().join("main.py"),
+            "from util import helper\n\ndef run():\n    return helper()\n",
+        )
+        .unwrap();
+        std::fs::write(repo.path().join("lib.go"), "package lib\n\nfunc Add() {}\n").unwrap();
+
+        let mut builder = GraphBuilder::new_with_embedded_queries();
+        let report = run_benchmark(&mut builder, repo.path()).unwrap();
+
+        assert_eq!(report.files, 3);
+        assert_eq!(report.parse_errors, 0);
+        let labels: Vec<&str> = report.languages.iter().map(|r| r.label.as_str()).collect();
+        assert_eq!(labels, vec!["go", "python"]);
+        assert_eq!(report.languages[1].files, 2);
+        assert_eq!(report.sizes.len(), 1);
+        assert_eq!(report.sizes[0].label, "<4K");
+        assert!(report.resolve.resolved >= 1);
+        assert_eq!(report.file_timings.len(), 3);
+
+        let mut trace = Vec::new();
+        report.write_trace(&mut trace).unwrap();
+        let trace: serde_json::Value = serde_json::from_slice(&trace).unwrap();
+        assert_eq!(trace["traceEvents"].as_array().unwrap().len(), 4);
+    }
+}
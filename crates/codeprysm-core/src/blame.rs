@@ -0,0 +1,209 @@
+//! Symbol Authorship from Git Blame
+//!
+//! Runs `git blame` over the files of a graph and records, for each callable
+//! and type, the author of most of its lines (`primary_author`) and the
+//! author of its most recently changed line (`last_modified_by`). Together
+//! with fan-in and coverage this answers questions like "which heavily used,
+//! untested functions were written by people who have left".
+//!
+//! Lines not committed yet are ignored; a symbol with no committed lines is
+//! left without authors.
+
+use std::collections::HashMap;
+use std::path::Path;
+use std::process::Command;
+
+use crate::graph::{ContainerKind, Node, NodeType, PetCodeGraph};
+
+/// Author of one line, from `git blame`.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct BlameLine {
+    /// Author name
+    pub author: String,
+    /// Author time of the commit that last changed the line (Unix seconds)
+    pub time: i64,
+}
+
+/// Authors of the committed lines of a file of the repository at `root`, by
+/// line number. None if git cannot blame the file.
+pub fn blame_file(root: &Path, file: &str) -> Option<HashMap<usize, BlameLine>> {
+    let output = Command::new("git")
+        .args(["blame", "--line-porcelain", "--", file])
+        .current_dir(root)
+        .output()
+        .ok()
+        .filter(|o| o.status.success())?;
+    Some(parse_blame(&String::from_utf8_lossy(&output.stdout)))
+}
+
+/// Parse `git blame --line-porcelain` output: per line, a header
+/// (`<commit> <original line> <final line> [<group size>]`), `key value`
+/// fields, and the line's content prefixed by a tab.
+fn parse_blame(porcelain: &str) -> HashMap<usize, BlameLine> {
+    let mut lines = HashMap::new();
+    let mut current: Option<usize> = None;
+    let mut author = String::new();
+    let mut time = 0;
+    let mut at_header = true;
+    for entry in porcelain.lines() {
+        if entry.starts_with('\t') {
+            if let Some(line) = current.take() {
+                lines.insert(
+                    line,
+                    BlameLine {
+                        author: std::mem::take(&mut author),
+                        time,
+                    },
+                );
+            }
+            at_header = true;
+            continue;
+        }
+        if at_header {
+            at_header = false;
+            let mut fields = entry.split_whitespace();
+            let commit = fields.next().unwrap_or_default();
+            let uncommitted = commit.bytes().all(|b| b == b'0');
+            current = fields
+                .nth(1)
+                .and_then(|line| line.parse().ok())
+                .filter(|_| !uncommitted);
+            author.clear();
+            time = 0;
+            continue;
+        }
+        if let Some(name) = entry.strip_prefix("author ") {
+            author = name.to_string();
+        } else if let Some(value) = entry.strip_prefix("author-time ") {
+            time = value.trim().parse().unwrap_or(0);
+        }
+    }
+    lines
+}
+
+/// Record the primary author and last modifier of every callable and type
+/// in `graph`, from `git blame` of the repository at `root`.
+///
+/// Values from a previous run are cleared first. Returns the number of
+/// attributed symbols. Does nothing outside a git checkout.
+pub fn attach_symbol_authors(root: &Path, graph: &mut PetCodeGraph) -> usize {
+    if !root.join(".git").exists() {
+        return 0;
+    }
+    let mut by_file: HashMap<String, Vec<&mut Node>> = HashMap::new();
+    for node in graph.inner_mut().node_weights_mut() {
+        node.metadata.primary_author = None;
+        node.metadata.last_modified_by = None;
+        if is_symbol(node) {
+            by_file.entry(node.file.clone()).or_default().push(node);
+        }
+    }
+
+    let mut attributed = 0;
+    for (file, nodes) in by_file {
+        let Some(lines) = blame_file(root, &file) else {
+            continue;
+        };
+        for node in nodes {
+            if let Some((primary, last)) = span_authors(&lines, node.line, node.end_line) {
+                node.metadata.primary_author = Some(primary);
+                node.metadata.last_modified_by = Some(last);
+                attributed += 1;
+            }
+        }
+    }
+    attributed
+}
+
+/// Author of most lines of `start..=end` (the most recent one on a tie) and
+/// author of the most recently changed line
+fn span_authors(
+    lines: &HashMap<usize, BlameLine>,
+    start: usize,
+    end: usize,
+) -> Option<(String, String)> {
+    // Lines and latest change per author
+    let mut authors: HashMap<&str, (usize, i64)> = HashMap::new();
+    for line in (start..=end).filter_map(|n| lines.get(&n)) {
+        let entry = authors.entry(line.author.as_str()).or_insert((0, i64::MIN));
+        entry.0 += 1;
+        entry.1 = entry.1.max(line.time);
+    }
+    let primary = authors
+        .iter()
+        .max_by_key(|(author, (count, time))| (*count, *time, std::cmp::Reverse(*author)))?;
+    let last = authors
+        .iter()
+        .max_by_key(|(author, (_, time))| (*time, std::cmp::Reverse(*author)))?;
+    Some((primary.0.to_string(), last.0.to_string()))
+}
+
+/// Callables and types
+fn is_symbol(node: &Node) -> bool {
+    !node.file.is_empty()
+        && (node.node_type == NodeType::Callable
+            || (node.node_type == NodeType::Container
+                && node.kind.as_deref() == Some(ContainerKind::Type.as_str())))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_parse_blame() {
+        let porcelain = "\
+4e1243bd22c66e76c2ba9eddc1f91394e57f9f83 1 1 2
+author Alice
+author-mail <alice@example.com>
+author-time 1700000000
+summary Add server
+\tpackage main
+4e1243bd22c66e76c2ba9eddc1f91394e57f9f83 2 2
+author Alice
+author-mail <alice@example.com>
+author-time 1700000000
+\t// TODO: more
+0000000000000000000000000000000000000000 3 3 1
+author Not Committed Yet
+author-time 1800000000
+\t// FIXME
+";
+        let lines = parse_blame(porcelain);
+        assert_eq!(
+            lines.get(&1),
+            Some(&BlameLine {
+                author: "Alice".to_string(),
+                time: 1700000000
+            })
+        );
+        assert_eq!(lines[&2].author, "Alice");
+        assert!(!lines.contains_key(&3));
+    }
+
+    #[test]
+    fn test_span_authors() {
+        let line = |author: &str, time: i64| BlameLine {
+            author: author.to_string(),
+            time,
+        };
+        let lines = HashMap::from([
+            (1, line("Alice", 100)),
+            (2, line("Alice", 100)),
+            (3, line("Bob", 300)),
+            (4, line("Carol", 200)),
+            (5, line("Carol", 200)),
+        ]);
+
+        assert_eq!(
+            span_authors(&lines, 1, 3),
+            Some(("Alice".to_string(), "Bob".to_string()))
+        );
+        // Alice and Carol tie on lines; Carol changed hers later
+        assert_eq!(
+            span_authors(&lines, 1, 5),
+            Some(("Carol".to_string(), "Bob".to_string()))
+        );
+        assert_eq!(span_authors(&lines, 6, 9), None);
+    }
+}
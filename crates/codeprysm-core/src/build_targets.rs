@@ -0,0 +1,843 @@
+//! Build Target Linking
+//!
+//! Links the targets of build files to the Go binaries they build, so a
+//! deployment artifact can be traced back to the code it contains:
+//!
+//! - Makefiles (`Makefile`, `makefile`, `GNUmakefile`, `*.mk`): rules whose
+//!   recipe runs `go build` or `go install`
+//! - Dockerfiles (`Dockerfile`, `Dockerfile.*`, `*.dockerfile`): build stages
+//!   whose `RUN` instructions run `go build` or `go install`
+//!
+//! Build files with such targets become File nodes with language `make` or
+//! `dockerfile`, holding a Data node of kind `build_target` per target, named
+//! after the rule or after the stage (its `AS` name, or its index when
+//! unnamed). A target has a CROSS_LANGUAGE edge to the `main` function of
+//! each Go main package it builds, carrying the line of the command and the
+//! package pattern as written.
+//!
+//! Package patterns may be directories (`./cmd/api`), wildcards
+//! (`./cmd/...`), files (`main.go`), or import paths of a Go module in the
+//! repository. Relative patterns are resolved against the build file's
+//! directory, following `cd` and `-C`; Dockerfile patterns that match nothing
+//! there are resolved against the repository root, the other usual build
+//! context. The analysis is textual: packages named through variables
+//! (`./cmd/$(APP)`) are not linked.
+
+use std::collections::{BTreeMap, BTreeSet};
+use std::path::Path;
+
+use crate::analysis::package_of;
+use crate::file_policy::is_test_file;
+use crate::graph::{DataKind, Edge, EdgeData, Node, NodeMetadata, PetCodeGraph};
+use crate::merkle::compute_content_hash;
+use crate::parser::generate_node_id;
+use crate::projects::ProjectKind;
+
+/// Language recorded on Makefile File nodes
+pub const MAKE_LANGUAGE: &str = "make";
+
+/// Language recorded on Dockerfile File nodes
+pub const DOCKERFILE_LANGUAGE: &str = "dockerfile";
+
+/// `go build` flags taking the next argument as their value
+const VALUE_FLAGS: &[&str] = &[
+    "C",
+    "o",
+    "p",
+    "asmflags",
+    "buildmode",
+    "compiler",
+    "coverpkg",
+    "covermode",
+    "gccgoflags",
+    "gcflags",
+    "installsuffix",
+    "ldflags",
+    "mod",
+    "modfile",
+    "overlay",
+    "pgo",
+    "pkgdir",
+    "tags",
+    "toolexec",
+];
+
+/// Kinds of build files.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum BuildFileKind {
+    /// Makefile, whose rules are targets
+    Makefile,
+    /// Dockerfile, whose build stages are targets
+    Dockerfile,
+}
+
+impl BuildFileKind {
+    /// Kind of a build file, from its name
+    pub fn from_path(path: &Path) -> Option<Self> {
+        let name = path.file_name()?.to_str()?;
+        let lower = name.to_ascii_lowercase();
+        if matches!(name, "Makefile" | "makefile" | "GNUmakefile") || lower.ends_with(".mk") {
+            Some(Self::Makefile)
+        } else if lower == "dockerfile"
+            || (lower.starts_with("dockerfile.") && !lower.ends_with(".dockerignore"))
+            || lower.ends_with(".dockerfile")
+        {
+            Some(Self::Dockerfile)
+        } else {
+            None
+        }
+    }
+
+    /// Language recorded on File nodes of this kind
+    pub fn language(&self) -> &'static str {
+        match self {
+            Self::Makefile => MAKE_LANGUAGE,
+            Self::Dockerfile => DOCKERFILE_LANGUAGE,
+        }
+    }
+
+    /// Subtype recorded on target nodes of this kind
+    pub fn target_subtype(&self) -> &'static str {
+        match self {
+            Self::Makefile => "rule",
+            Self::Dockerfile => "stage",
+        }
+    }
+}
+
+/// Check if a path names a Makefile or a Dockerfile.
+pub fn is_build_file(path: &Path) -> bool {
+    BuildFileKind::from_path(path).is_some()
+}
+
+/// Result of build target linking.
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq)]
+pub struct BuildTargetLinks {
+    /// Build target nodes added
+    pub targets: usize,
+    /// CROSS_LANGUAGE edges from build targets to Go `main` functions
+    pub edges: usize,
+}
+
+/// Add the targets of build files to a graph built from `directory` and link
+/// them to the Go binaries they build.
+///
+/// `build_files` are the Makefiles and Dockerfiles found in the repository
+/// (relative to `directory`). Their File nodes are contained in the
+/// `repo_name` node, if any.
+pub fn link_build_targets(
+    graph: &mut PetCodeGraph,
+    directory: &Path,
+    repo_name: &str,
+    build_files: &[String],
+) -> BuildTargetLinks {
+    let mut links = BuildTargetLinks::default();
+    let mains = main_functions(graph);
+    if mains.is_empty() {
+        return links;
+    }
+    let modules = go_modules(directory, mains.keys().map(String::as_str));
+
+    for file in build_files {
+        let Some(kind) = BuildFileKind::from_path(Path::new(file)) else {
+            continue;
+        };
+        let Ok(source) = std::fs::read_to_string(directory.join(file)) else {
+            continue;
+        };
+        let targets = match kind {
+            BuildFileKind::Makefile => parse_makefile(&source),
+            BuildFileKind::Dockerfile => parse_dockerfile(&source),
+        };
+
+        let base = package_of(file);
+        let resolve_in = |dir: &str, pattern: &str| -> Vec<&str> {
+            join_path(base, dir)
+                .map(|dir| resolve(&mains, &modules, &dir, pattern))
+                .filter(|found| !found.is_empty())
+                .or_else(|| {
+                    (kind == BuildFileKind::Dockerfile && !base.is_empty())
+                        .then(|| join_path("", dir))
+                        .flatten()
+                        .map(|dir| resolve(&mains, &modules, &dir, pattern))
+                })
+                .unwrap_or_default()
+        };
+
+        let mut linked = Vec::new();
+        for target in targets {
+            // First command and pattern building each binary
+            let mut builds: BTreeMap<&str, (usize, String)> = BTreeMap::new();
+            for (line, command) in &target.commands {
+                for build in go_builds(command) {
+                    for pattern in &build.packages {
+                        for main in resolve_in(&build.dir, pattern) {
+                            builds.entry(main).or_insert((*line, pattern.clone()));
+                        }
+                    }
+                }
+            }
+            if !builds.is_empty() {
+                let builds: Vec<(String, (usize, String))> = builds
+                    .into_iter()
+                    .map(|(main, build)| (main.to_string(), build))
+                    .collect();
+                linked.push((target, builds));
+            }
+        }
+        if linked.is_empty() {
+            continue;
+        }
+
+        if !graph.contains_node(file) {
+            add_build_file(graph, repo_name, file, &source, kind);
+        }
+        for (target, builds) in linked {
+            let id = generate_node_id(file, &[], &target.name, None);
+            if !graph.contains_node(&id) {
+                graph.add_node(Node::data(
+                    id.clone(),
+                    target.name.clone(),
+                    DataKind::BuildTarget,
+                    Some(kind.target_subtype().to_string()),
+                    file.clone(),
+                    target.line,
+                    target.end_line,
+                ));
+                graph.add_edge_from_struct(&Edge::contains(file.clone(), id.clone()));
+                graph.add_edge_from_struct(&Edge::defined_in(id.clone(), file.clone()));
+                links.targets += 1;
+            }
+            for (main, (line, pattern)) in builds {
+                graph.add_edge(
+                    &id,
+                    &main,
+                    EdgeData::cross_language(Some(line), Some(pattern)),
+                );
+                links.edges += 1;
+            }
+        }
+    }
+    links
+}
+
+/// `main` functions of Go main packages, by package
+fn main_functions(graph: &PetCodeGraph) -> BTreeMap<String, Vec<String>> {
+    let mut mains: BTreeMap<String, Vec<String>> = BTreeMap::new();
+    for node in graph.iter_nodes().filter(|n| {
+        n.is_callable()
+            && n.name == "main"
+            && n.metadata.receiver.is_none()
+            && n.file.ends_with(".go")
+            && !is_test_file(&n.file)
+    }) {
+        mains
+            .entry(package_of(&node.file).to_string())
+            .or_default()
+            .push(node.id.clone());
+    }
+    // Files selected by build constraints may each declare one
+    for ids in mains.values_mut() {
+        ids.sort();
+    }
+    mains
+}
+
+/// Add the File node of a build file
+fn add_build_file(
+    graph: &mut PetCodeGraph,
+    repo_name: &str,
+    file: &str,
+    source: &str,
+    kind: BuildFileKind,
+) {
+    let node = Node::source_file(
+        file.to_string(),
+        file.to_string(),
+        compute_content_hash(source.as_bytes()),
+        source.lines().count(),
+    )
+    .with_metadata(NodeMetadata::new().with_file(
+        kind.language(),
+        source.len() as u64,
+        false,
+        false,
+    ));
+    graph.add_node(node);
+    if !repo_name.is_empty() {
+        graph.add_edge_from_struct(&Edge::contains(repo_name.to_string(), file.to_string()));
+    }
+}
+
+/// A Go module in the repository
+#[derive(Debug, Clone, PartialEq, Eq)]
+struct GoModule {
+    /// Module path from `go.mod`
+    path: String,
+    /// Directory relative to the repository root
+    dir: String,
+}
+
+impl GoModule {
+    /// Directory of a package import path in this module
+    fn package_dir(&self, import: &str) -> Option<String> {
+        if import == self.path {
+            return Some(self.dir.clone());
+        }
+        join_path(
+            &self.dir,
+            import.strip_prefix(&self.path)?.strip_prefix('/')?,
+        )
+    }
+}
+
+/// Modules enclosing `packages`, longest module path first
+fn go_modules<'a>(directory: &Path, packages: impl Iterator<Item = &'a str>) -> Vec<GoModule> {
+    let mut dirs = BTreeSet::new();
+    for package in packages {
+        let mut dir = package;
+        while dirs.insert(dir) && !dir.is_empty() {
+            dir = package_of(dir);
+        }
+    }
+    let mut modules: Vec<GoModule> = dirs
+        .into_iter()
+        .filter_map(|dir| {
+            let manifest = std::fs::read_to_string(directory.join(dir).join("go.mod")).ok()?;
+            Some(GoModule {
+                path: ProjectKind::Go.declared_name(&manifest)?,
+                dir: dir.to_string(),
+            })
+        })
+        .collect();
+    modules.sort_by_key(|module| std::cmp::Reverse(module.path.len()));
+    modules
+}
+
+/// IDs of the `main` functions a package pattern of a command running in
+/// `dir` builds
+fn resolve<'m>(
+    mains: &'m BTreeMap<String, Vec<String>>,
+    modules: &[GoModule],
+    dir: &str,
+    pattern: &str,
+) -> Vec<&'m str> {
+    // Versioned patterns install tools from outside the repository
+    if pattern.contains(['$', '@']) {
+        return Vec::new();
+    }
+    let (path, recursive) = match pattern.strip_suffix("...") {
+        Some(prefix) => (prefix.trim_end_matches('/'), true),
+        None => (pattern, false),
+    };
+    let package = if path.ends_with(".go") && !recursive {
+        join_path(dir, path).map(|file| package_of(&file).to_string())
+    } else if path.is_empty() || path == "." || path.starts_with("./") || path.starts_with("../") {
+        join_path(dir, path)
+    } else {
+        modules.iter().find_map(|module| module.package_dir(path))
+    };
+    let Some(package) = package else {
+        return Vec::new();
+    };
+
+    mains
+        .iter()
+        .filter(|(candidate, _)| {
+            *candidate == &package
+                || (recursive
+                    && (package.is_empty() || candidate.starts_with(&format!("{}/", package))))
+        })
+        .flat_map(|(_, ids)| ids.iter().map(String::as_str))
+        .collect()
+}
+
+/// Join a relative path onto a directory relative to the repository root, or
+/// None if it leaves the repository
+fn join_path(dir: &str, path: &str) -> Option<String> {
+    let mut parts: Vec<&str> = dir.split('/').filter(|part| !part.is_empty()).collect();
+    for part in path.split('/') {
+        match part {
+            "" | "." => {}
+            ".." => {
+                parts.pop()?;
+            }
+            _ => parts.push(part),
+        }
+    }
+    Some(parts.join("/"))
+}
+
+/// A target of a build file
+#[derive(Debug, Clone, PartialEq, Eq)]
+struct BuildTarget {
+    name: String,
+    line: usize,
+    end_line: usize,
+    /// Shell commands and the line each starts on
+    commands: Vec<(usize, String)>,
+}
+
+impl BuildTarget {
+    fn new(name: String, line: usize) -> Self {
+        Self {
+            name,
+            line,
+            end_line: line,
+            commands: Vec::new(),
+        }
+    }
+}
+
+/// Join lines continued with a trailing backslash, returning each logical
+/// line with its first and last line (1-indexed). Comment lines inside a
+/// continuation are dropped if `skip_comments` is set, as Docker does.
+fn logical_lines(source: &str, skip_comments: bool) -> Vec<(usize, usize, String)> {
+    let mut lines = Vec::new();
+    let mut current: Option<(usize, String)> = None;
+    for (index, line) in source.lines().enumerate() {
+        let line_number = index + 1;
+        let text = match current.take() {
+            Some((start, mut text)) => {
+                let trimmed = line.trim_start();
+                if skip_comments && trimmed.starts_with('#') {
+                    current = Some((start, text));
+                    continue;
+                }
+                text.push(' ');
+                text.push_str(trimmed);
+                (start, text)
+            }
+            None => (line_number, line.to_string()),
+        };
+        match text.1.strip_suffix('\\') {
+            Some(continued) => current = Some((text.0, continued.trim_end().to_string())),
+            None => lines.push((text.0, line_number, text.1)),
+        }
+    }
+    if let Some((start, text)) = current {
+        let end = source.lines().count();
+        lines.push((start, end, text));
+    }
+    lines
+}
+
+/// Parse the rules of a Makefile, with their recipe commands
+fn parse_makefile(source: &str) -> Vec<BuildTarget> {
+    let mut targets: Vec<BuildTarget> = Vec::new();
+    // Targets of the rule being read, as indices into `targets`
+    let mut current: Vec<usize> = Vec::new();
+
+    for (line, end_line, text) in logical_lines(source, false) {
+        if let Some(recipe) = text.strip_prefix('\t') {
+            let command = recipe.trim_start_matches(['@', '-', '+']).trim();
+            for &index in &current {
+                targets[index].commands.push((line, command.to_string()));
+                targets[index].end_line = end_line;
+            }
+            continue;
+        }
+        let trimmed = text.trim();
+        if trimmed.is_empty() || trimmed.starts_with('#') {
+            continue;
+        }
+
+        current.clear();
+        let Some((names, rest)) = split_rule(trimmed) else {
+            continue;
+        };
+        // Special targets (.PHONY), pattern rules, and computed names
+        for name in names
+            .split_whitespace()
+            .filter(|name| !name.starts_with('.') && !name.contains(['%', '$']))
+        {
+            current.push(targets.len());
+            targets.push(BuildTarget::new(name.to_string(), line));
+        }
+        if let Some((_, recipe)) = rest.split_once(';') {
+            for &index in &current {
+                targets[index]
+                    .commands
+                    .push((line, recipe.trim().to_string()));
+            }
+        }
+    }
+    targets
+}
+
+/// Split a Makefile rule into its targets and the rest of the line, or None
+/// for other lines (variable assignments, directives)
+fn split_rule(line: &str) -> Option<(&str, &str)> {
+    let (names, rest) = line.split_once(':')?;
+    // Double-colon rules
+    let rest = rest.strip_prefix(':').unwrap_or(rest);
+    if names.contains('=') || rest.starts_with('=') {
+        return None;
+    }
+    Some((names, rest))
+}
+
+/// Parse the build stages of a Dockerfile, with their `RUN` commands
+fn parse_dockerfile(source: &str) -> Vec<BuildTarget> {
+    let mut stages: Vec<BuildTarget> = Vec::new();
+    for (line, end_line, text) in logical_lines(source, true) {
+        let trimmed = text.trim();
+        if trimmed.is_empty() || trimmed.starts_with('#') {
+            continue;
+        }
+        let (instruction, args) = trimmed
+            .split_once(char::is_whitespace)
+            .unwrap_or((trimmed, ""));
+        if instruction.eq_ignore_ascii_case("FROM") {
+            let words: Vec<&str> = args
+                .split_whitespace()
+                .filter(|word| !word.starts_with("--"))
+                .collect();
+            let name = match words.as_slice() {
+                [_, keyword, name, ..] if keyword.eq_ignore_ascii_case("as") => name.to_string(),
+                _ => stages.len().to_string(),
+            };
+            stages.push(BuildTarget::new(name, line));
+            continue;
+        }
+        let Some(stage) = stages.last_mut() else {
+            continue;
+        };
+        stage.end_line = end_line;
+        if instruction.eq_ignore_ascii_case("RUN") {
+            stage.commands.push((line, run_command(args)));
+        }
+    }
+    stages
+}
+
+/// Shell command of a `RUN` instruction, in shell or exec form
+fn run_command(args: &str) -> String {
+    let mut args = args.trim();
+    // Options (--mount, --network)
+    while args.starts_with("--") {
+        args = args
+            .split_once(char::is_whitespace)
+            .map_or("", |(_, rest)| rest.trim_start());
+    }
+    if args.starts_with('[') {
+        if let Ok(words) = serde_json::from_str::<Vec<String>>(args) {
+            return words.join(" ");
+        }
+    }
+    args.to_string()
+}
+
+/// A `go build` or `go install` command
+#[derive(Debug, Clone, PartialEq, Eq)]
+struct GoBuild {
+    /// Directory the command runs in, relative to where the command line
+    /// starts
+    dir: String,
+    /// Package patterns, as written
+    packages: Vec<String>,
+}
+
+/// Find the `go build` and `go install` commands of a shell command line
+fn go_builds(command: &str) -> Vec<GoBuild> {
+    let words = shell_words(command);
+    let mut builds = Vec::new();
+    // None once the command line changes to an unknown directory
+    let mut dir = Some(String::new());
+
+    for simple in words.split(|word| is_operator(word)) {
+        let simple: Vec<&str> = simple
+            .iter()
+            .map(String::as_str)
+            .skip_while(|word| is_assignment(word))
+            .collect();
+        match simple.as_slice() {
+            ["cd", path, ..] => dir = dir.and_then(|dir| subdir(&dir, path)),
+            [go, "build" | "install", args @ ..] if is_go(go) => {
+                if let Some(build) = dir.as_deref().and_then(|dir| go_build(dir, args)) {
+                    builds.push(build);
+                }
+            }
+            _ => {}
+        }
+    }
+    builds
+}
+
+/// Parse the arguments of `go build` running in `dir`
+fn go_build(dir: &str, args: &[&str]) -> Option<GoBuild> {
+    let mut dir = dir.to_string();
+    let mut packages = Vec::new();
+    let mut args = args.iter();
+    while let Some(arg) = args.next() {
+        let Some(flag) = arg.strip_prefix('-') else {
+            packages.push(arg.to_string());
+            continue;
+        };
+        let flag = flag.strip_prefix('-').unwrap_or(flag);
+        let (name, value) = match flag.split_once('=') {
+            Some((name, value)) => (name, Some(value)),
+            None if VALUE_FLAGS.contains(&flag) => (flag, args.next().copied()),
+            None => (flag, None),
+        };
+        if name == "C" {
+            dir = subdir(&dir, value?)?;
+        }
+    }
+    if packages.is_empty() {
+        packages.push(".".to_string());
+    }
+    Some(GoBuild { dir, packages })
+}
+
+/// Directory `path` of `dir`, if it is a relative path without variables
+fn subdir(dir: &str, path: &str) -> Option<String> {
+    if path.starts_with('/') || path.contains('$') {
+        None
+    } else if dir.is_empty() {
+        Some(path.to_string())
+    } else {
+        Some(format!("{}/{}", dir, path))
+    }
+}
+
+/// Check if a command word runs the Go tool
+fn is_go(word: &str) -> bool {
+    matches!(word, "go" | "$(GO)" | "${GO}") || word.ends_with("/go")
+}
+
+/// Check if a word is a shell control operator
+fn is_operator(word: &str) -> bool {
+    matches!(word, "&&" | "||" | ";" | "|" | "&")
+}
+
+/// Check if a word assigns an environment variable (`CGO_ENABLED=0`)
+fn is_assignment(word: &str) -> bool {
+    word.split_once('=').is_some_and(|(name, _)| {
+        !name.is_empty() && name.chars().all(|c| c.is_ascii_alphanumeric() || c == '_')
+    })
+}
+
+/// Split a shell command line into unquoted words and control operators
+fn shell_words(command: &str) -> Vec<String> {
+    let mut words = Vec::new();
+    let mut word = String::new();
+    let mut in_word = false;
+    let mut chars = command.chars().peekable();
+
+    while let Some(c) = chars.next() {
+        match c {
+            '\'' | '"' => {
+                in_word = true;
+                for quoted in chars.by_ref() {
+                    if quoted == c {
+                        break;
+                    }
+                    word.push(quoted);
+                }
+            }
+            '\\' => {
+                in_word = true;
+                if let Some(escaped) = chars.next() {
+                    word.push(escaped);
+                }
+            }
+            ';' | '&' | '|' => {
+                if in_word {
+                    words.push(std::mem::take(&mut word));
+                    in_word = false;
+                }
+                let mut operator = c.to_string();
+                if c != ';' && chars.peek() == Some(&c) {
+                    chars.next();
+                    operator.push(c);
+                }
+                words.push(operator);
+            }
+            '#' if !in_word => break,
+            c if c.is_whitespace() => {
+                if in_word {
+                    words.push(std::mem::take(&mut word));
+                    in_word = false;
+                }
+            }
+            _ => {
+                in_word = true;
+                word.push(c);
+            }
+        }
+    }
+    if in_word {
+        words.push(word);
+    }
+    words
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::{CallableKind, EdgeType};
+
+    #[test]
+    fn test_build_file_kind() {
+        let kind = |path: &str| BuildFileKind::from_path(Path::new(path));
+        assert_eq!(kind("Makefile"), Some(BuildFileKind::Makefile));
+        assert_eq!(kind("build/go.mk"), Some(BuildFileKind::Makefile));
+        assert_eq!(kind("Dockerfile"), Some(BuildFileKind::Dockerfile));
+        assert_eq!(
+            kind("deploy/Dockerfile.api"),
+            Some(BuildFileKind::Dockerfile)
+        );
+        assert_eq!(kind("api.dockerfile"), Some(BuildFileKind::Dockerfile));
+        assert_eq!(kind("Dockerfile.dockerignore"), None);
+        assert_eq!(kind("main.go"), None);
+    }
+
+    #[test]
+    fn test_parse_makefile() {
+        let source = "GO ?= go\nLDFLAGS := -s -w\n\n.PHONY: build api\n\nbuild: api worker\n\napi:\n\t@echo building\n\t$(GO) build -o bin/api \\\n\t\t./cmd/api\n\n# The worker\nworker: ; go build ./cmd/worker\n%.o: %.c\n\tcc -c $<\n";
+        let targets = parse_makefile(source);
+        let names: Vec<&str> = targets.iter().map(|t| t.name.as_str()).collect();
+        assert_eq!(names, ["build", "api", "worker"]);
+
+        let api = &targets[1];
+        assert_eq!((api.line, api.end_line), (8, 11));
+        assert_eq!(
+            api.commands,
+            [
+                (9, "echo building".to_string()),
+                (10, "$(GO) build -o bin/api ./cmd/api".to_string()),
+            ]
+        );
+        assert_eq!(
+            targets[2].commands,
+            [(14, "go build ./cmd/worker".to_string())]
+        );
+        assert!(targets[0].commands.is_empty());
+    }
+
+    #[test]
+    fn test_parse_dockerfile() {
+        let source = "# syntax=docker/dockerfile:1\nFROM --platform=$BUILDPLATFORM golang:1.22 AS build\nWORKDIR /src\nCOPY . .\nRUN --mount=type=cache,target=/root/.cache \\\n    # static binary\n    CGO_ENABLED=0 go build -o /out/api ./cmd/api\n\nFROM alpine\nRUN [\"go\", \"install\", \"./cmd/tool\"]\nCOPY --from=build /out/api /api\n";
+        let stages = parse_dockerfile(source);
+        assert_eq!(stages.len(), 2);
+        assert_eq!(stages[0].name, "build");
+        assert_eq!((stages[0].line, stages[0].end_line), (2, 7));
+        assert_eq!(
+            stages[0].commands,
+            [(
+                5,
+                "CGO_ENABLED=0 go build -o /out/api ./cmd/api".to_string()
+            )]
+        );
+        assert_eq!(stages[1].name, "1");
+        assert_eq!(
+            stages[1].commands,
+            [(10, "go install ./cmd/tool".to_string())]
+        );
+    }
+
+    #[test]
+    fn test_go_builds() {
+        let build = |dir: &str, packages: &[&str]| GoBuild {
+            dir: dir.to_string(),
+            packages: packages.iter().map(|p| p.to_string()).collect(),
+        };
+        assert_eq!(
+            go_builds("CGO_ENABLED=0 go build -ldflags \"-X main.version=1\" -o bin/ ./cmd/api ./cmd/worker"),
+            [build("", &["./cmd/api", "./cmd/worker"])]
+        );
+        assert_eq!(
+            go_builds("cd services/billing && go build -trimpath; go install -C tools ./..."),
+            [
+                build("services/billing", &["."]),
+                build("services/billing/tools", &["./..."])
+            ]
+        );
+        assert_eq!(
+            go_builds("go vet ./... && /usr/local/go/bin/go build main.go | tee log # done"),
+            [build("", &["main.go"])]
+        );
+        // Unknown directories
+        assert!(go_builds("cd $(SRC) && go build ./cmd/api").is_empty());
+        assert!(go_builds("go build -C /src ./cmd/api").is_empty());
+        assert!(go_builds("docker build -t api .").is_empty());
+    }
+
+    #[test]
+    fn test_link_build_targets() {
+        let dir = tempfile::tempdir().unwrap();
+        let write = |path: &str, content: &str| {
+            let path = dir.path().join(path);
+            std::fs::create_dir_all(path.parent().unwrap()).unwrap();
+            std::fs::write(path, content).unwrap();
+        };
+        write("go.mod", "module example.com/shop\n\ngo 1.22\n");
+        write(
+            "Makefile",
+            "build:\n\tgo build ./cmd/...\n\ntool:\n\tgo install example.com/shop/tools/gen golang.org/x/tools/cmd/stringer@latest\n\nlint:\n\tgolangci-lint run\n",
+        );
+        write(
+            "deploy/Dockerfile",
+            "FROM golang:1.22 AS build\nRUN go build -o /api ./cmd/api\nFROM scratch\n",
+        );
+
+        let mut graph = PetCodeGraph::new();
+        graph.add_node(Node::repository("shop".to_string(), NodeMetadata::new()));
+        for file in [
+            "cmd/api/main.go",
+            "cmd/worker/main.go",
+            "tools/gen/main.go",
+            "cmd/api/main_test.go",
+        ] {
+            graph.add_node(Node::callable(
+                format!("{}:main", file),
+                "main".to_string(),
+                CallableKind::Function,
+                file.to_string(),
+                3,
+                5,
+            ));
+        }
+
+        let links = link_build_targets(
+            &mut graph,
+            dir.path(),
+            "shop",
+            &["Makefile".to_string(), "deploy/Dockerfile".to_string()],
+        );
+        assert_eq!(
+            links,
+            BuildTargetLinks {
+                targets: 3,
+                edges: 4
+            }
+        );
+
+        let targets = |id: &str| -> Vec<String> {
+            let mut found: Vec<String> = graph
+                .outgoing_edges(id)
+                .filter(|(_, edge)| edge.edge_type == EdgeType::CrossLanguage)
+                .map(|(node, _)| node.id.clone())
+                .collect();
+            found.sort();
+            found
+        };
+        assert_eq!(
+            targets("Makefile:build"),
+            ["cmd/api/main.go:main", "cmd/worker/main.go:main"]
+        );
+        assert_eq!(targets("Makefile:tool"), ["tools/gen/main.go:main"]);
+        // Resolved against the repository root
+        assert_eq!(targets("deploy/Dockerfile:build"), ["cmd/api/main.go:main"]);
+        assert!(graph.get_node("Makefile:lint").is_none());
+        assert!(graph.get_node("deploy/Dockerfile:1").is_none());
+
+        let target = graph.get_node("Makefile:build").unwrap();
+        assert!(target.is_build_target());
+        assert_eq!(graph.parent(&target.id).unwrap().id, "Makefile");
+        let file = graph.get_node("deploy/Dockerfile").unwrap();
+        assert_eq!(file.metadata.language.as_deref(), Some(DOCKERFILE_LANGUAGE));
+        assert_eq!(graph.parent(&file.id).unwrap().id, "shop");
+    }
+}
@@ -13,25 +13,54 @@
 //! let graph = builder.build_from_directory(Path::new("src"))?;
 //! ```
 
-use std::collections::HashMap;
-use std::path::{Path, PathBuf};
+use std::collections::{BTreeMap, HashMap};
+use std::path::{Component, Path, PathBuf};
+use std::sync::Arc;
+use std::time::{Instant, SystemTime, UNIX_EPOCH};
 
 use ignore::WalkBuilder;
+use rayon::prelude::*;
+use serde::{Deserialize, Serialize};
 use thiserror::Error;
-use tracing::{debug, info, warn};
-
+use tracing::{debug, debug_span, field, info, info_span, warn, Span};
+
+use crate::analysis::clones::{find_clones, link_clones, CloneOptions};
+use crate::analysis::interfaces::{link_interface_overlaps, InterfaceOptions};
+use crate::analysis::leaks::{attach_leaks, find_leaks};
+use crate::analysis::{declaration_signature, package_of};
+use crate::build_targets::{is_build_file, link_build_targets};
+use crate::codeowners::CodeOwners;
+use crate::cross_language::{is_proto_path, link_cross_language};
+use crate::diagnostics::Diagnostic;
 use crate::discovery::{DiscoveredRoot, RootDiscovery};
+use crate::enrichment::{AttributeRule, Enrichment};
+use crate::entry_points::tag_entry_points;
+use crate::extraction_cache::{ExtractionCache, FileExtraction};
+use crate::extraction_rules::{is_disabled, remove_disabled_edges, ExtractionRules};
+use crate::file_policy::{build_glob_set, is_generated, is_test_file, FilePolicy};
+use crate::go_types::{merge_typed_references, GoTypeCheck};
 use crate::graph::{
     CallableKind, ContainerKind, DataKind, Edge, EdgeType, Node, NodeMetadata, NodeType,
     PetCodeGraph,
 };
+use crate::license;
+use crate::limits::{attach_truncations, enforce_limits, SymbolBudget, SymbolLimits, Truncation};
 use crate::manifest::{DependencyType, LocalDependency, ManifestInfo, ManifestParser};
-use crate::merkle::compute_file_hash;
+use crate::markers;
+use crate::merkle::compute_content_hash;
+use crate::package_docs::attach_package_docs;
 use crate::parser::{
-    generate_node_id, ContainmentContext, ManifestLanguage, MetadataExtractor, SupportedLanguage,
-    TagExtractor,
+    generate_node_id, ContainmentContext, ManifestLanguage, MetadataExtractor, ParserError,
+    QueryManager, SupportedLanguage, TagExtractor,
 };
+use crate::projects::attach_projects;
+use crate::shard::GraphShard;
+use crate::stream::{GraphSink, NodePatch, StreamStats};
+use crate::string_refs::link_string_references;
+use crate::symbol_id::{symbol_id, symbol_scope};
 use crate::tags::{parse_tag_string, TagParseResult};
+use crate::templates::{is_template_path, link_templates};
+use crate::type_usage::weigh_type_usage;
 
 // ============================================================================
 // Errors
@@ -72,6 +101,19 @@ pub struct BuilderConfig {
     pub max_files: Option<usize>,
     /// File patterns to exclude (glob patterns)
     pub exclude_patterns: Vec<String>,
+    /// Only index files matching these patterns (all if empty)
+    pub include_patterns: Vec<String>,
+    /// Per-language file rules and generated-code policy
+    pub file_policy: FilePolicy,
+    /// Rules tagging matching nodes with user-defined attributes
+    pub attributes: Vec<AttributeRule>,
+    /// Type checker whose Go resolutions replace name-based ones (None =
+    /// name-based resolution only)
+    pub go_type_check: Option<GoTypeCheck>,
+    /// Disabled edge types and extra query files, by language name
+    pub extraction: HashMap<String, ExtractionRules>,
+    /// Caps on the symbols kept per package and per build
+    pub symbol_limits: SymbolLimits,
 }
 
 impl Default for BuilderConfig {
@@ -91,21 +133,185 @@ impl Default for BuilderConfig {
                 "**/dist/**".to_string(),
                 "**/build/**".to_string(),
             ],
+            include_patterns: Vec::new(),
+            file_policy: FilePolicy::default(),
+            attributes: Vec::new(),
+            go_type_check: None,
+            extraction: HashMap::new(),
+            symbol_limits: SymbolLimits::default(),
+        }
+    }
+}
+
+// ============================================================================
+// Build Statistics
+// ============================================================================
+
+/// File in the index directory holding the statistics of the last build
+const BUILD_STATS_FILE: &str = "build_stats.json";
+
+/// Version of the per-file extraction output, bumped when extraction adds or
+/// changes node content so cached extractions are not reused
+const EXTRACTION_FORMAT: u32 = 6;
+
+/// Statistics of a graph build, kept next to the index for monitoring.
+#[derive(Debug, Clone, Default, PartialEq, Eq, Serialize, Deserialize)]
+pub struct BuildStats {
+    /// Files parsed into the graph
+    pub files_parsed: usize,
+    /// Files whose extraction was reused from the extraction cache
+    #[serde(default)]
+    pub files_cached: usize,
+    /// Files that failed to parse
+    pub parse_errors: usize,
+    /// Time spent building, in milliseconds
+    pub duration_ms: u64,
+    /// When the build finished (seconds since the Unix epoch)
+    pub finished_at: u64,
+}
+
+impl BuildStats {
+    /// Save the statistics into an index directory.
+    pub fn save(&self, prism_dir: &Path) -> Result<(), BuilderError> {
+        let json = serde_json::to_string_pretty(self).map_err(std::io::Error::other)?;
+        std::fs::write(prism_dir.join(BUILD_STATS_FILE), json)?;
+        Ok(())
+    }
+
+    /// Load the statistics saved in an index directory, if any.
+    pub fn load(prism_dir: &Path) -> Option<Self> {
+        let json = std::fs::read_to_string(prism_dir.join(BUILD_STATS_FILE)).ok()?;
+        serde_json::from_str(&json).ok()
+    }
+}
+
+// ============================================================================
+// Build Progress
+// ============================================================================
+
+/// Stage of a build, as reported to a progress callback.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum BuildPhase {
+    /// Parsing and extracting source files
+    Parse,
+    /// Resolving references into USES edges
+    Resolve,
+    /// Linking near-duplicate callables
+    Clones,
+}
+
+impl BuildPhase {
+    /// Phase name as used in progress events
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            Self::Parse => "parse",
+            Self::Resolve => "resolve",
+            Self::Clones => "clones",
         }
     }
 }
 
+/// How far a build has got within its current phase.
+///
+/// For [`BuildPhase::Parse`] the units are files, for
+/// [`BuildPhase::Resolve`] references; [`BuildPhase::Clones`] reports 0 of 1
+/// when it starts and 1 of 1 when it is done.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct BuildProgress {
+    /// Current phase
+    pub phase: BuildPhase,
+    /// Units of work done in this phase
+    pub done: u64,
+    /// Units of work in this phase
+    pub total: u64,
+}
+
+/// Callback receiving build progress, set with [`GraphBuilder::with_progress`]
+pub type ProgressCallback = Arc<dyn Fn(&BuildProgress) + Send + Sync>;
+
 // ============================================================================
 // Reference Info
 // ============================================================================
 
 /// Information about a reference to be resolved later.
-#[derive(Debug, Clone)]
-struct ReferenceInfo {
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub(crate) struct ReferenceInfo {
     /// Source node ID (where the reference comes from)
-    source_id: String,
+    pub(crate) source_id: String,
     /// Line number of the reference
-    line: usize,
+    pub(crate) line: usize,
+}
+
+/// Unresolved references of a parsed file, kept for cross-file resolution.
+///
+/// Returned by [`GraphBuilder::parse_file_with_references`] and resolved into
+/// USES edges with [`resolve_file_references`].
+#[derive(Debug, Clone, Default)]
+pub struct FileReferences {
+    /// References by referenced name
+    references: HashMap<String, Vec<ReferenceInfo>>,
+}
+
+impl FileReferences {
+    /// Number of references
+    pub fn len(&self) -> usize {
+        self.references.values().map(Vec::len).sum()
+    }
+
+    /// Check if the file has no references
+    pub fn is_empty(&self) -> bool {
+        self.references.is_empty()
+    }
+
+    /// Iterate over the references as (referenced name, source node ID,
+    /// line) triples.
+    pub fn iter(&self) -> impl Iterator<Item = (&str, &str, usize)> {
+        self.references.iter().flat_map(|(name, refs)| {
+            refs.iter()
+                .map(move |r| (name.as_str(), r.source_id.as_str(), r.line))
+        })
+    }
+}
+
+/// Entities extracted from a repository's files, before reference resolution
+struct DirectoryExtraction {
+    /// Repository node and the files' entities
+    graph: PetCodeGraph,
+    /// ID of the repository node
+    repo_name: String,
+    /// Definition names and the node IDs they resolve to
+    defines: HashMap<String, String>,
+    /// References by referenced name
+    references: HashMap<String, Vec<ReferenceInfo>>,
+    /// Data nodes skipped by `skip_data_nodes`
+    skipped_data_nodes: usize,
+    /// Nodes skipped by `max_containment_depth`
+    skipped_depth_nodes: usize,
+}
+
+/// A reference awaiting resolution, with the file of its source node
+struct PendingRef<'a> {
+    /// Referenced name
+    name: &'a str,
+    reference: &'a ReferenceInfo,
+    /// File of the source node, `None` if the node does not exist
+    file: Option<String>,
+}
+
+/// Result of resolving the references made from one package
+#[derive(Default)]
+struct PackageResolution {
+    /// USES edges to definitions in the same package
+    local: Vec<Edge>,
+    /// USES edges to definitions in other packages
+    cross: Vec<Edge>,
+    /// Per-file (resolved, unresolved) reference counts
+    file_refs: HashMap<String, (u32, u32)>,
+    /// References to names without a definition
+    forward_refs: usize,
+    /// References whose source node does not exist
+    skipped_missing_source: usize,
 }
 
 // ============================================================================
@@ -135,6 +341,14 @@ pub struct GraphBuilder {
     queries_dir: Option<PathBuf>,
     /// Builder configuration
     config: BuilderConfig,
+    /// Statistics accumulated over all builds
+    stats: BuildStats,
+    /// Per-file extraction results reused across builds
+    cache: Option<ExtractionCache>,
+    /// Receives progress as builds go through their phases
+    progress: Option<ProgressCallback>,
+    /// Diagnostics accumulated over all builds
+    diagnostics: Vec<Diagnostic>,
 }
 
 impl GraphBuilder {
@@ -146,6 +360,10 @@ impl GraphBuilder {
         Self {
             queries_dir: None,
             config: BuilderConfig::default(),
+            stats: BuildStats::default(),
+            cache: None,
+            progress: None,
+            diagnostics: Vec::new(),
         }
     }
 
@@ -157,6 +375,10 @@ impl GraphBuilder {
         Self {
             queries_dir: None,
             config,
+            stats: BuildStats::default(),
+            cache: None,
+            progress: None,
+            diagnostics: Vec::new(),
         }
     }
 
@@ -191,9 +413,91 @@ impl GraphBuilder {
         Ok(Self {
             queries_dir: Some(queries_dir.to_path_buf()),
             config,
+            stats: BuildStats::default(),
+            cache: None,
+            progress: None,
+            diagnostics: Vec::new(),
         })
     }
 
+    /// Reuse per-file extraction results from `cache`.
+    ///
+    /// Builds re-parse only files whose content hash is not in the cache and
+    /// record every file they extract, so [`ExtractionCache::save`] after
+    /// building keeps the cache current.
+    pub fn with_cache(mut self, mut cache: ExtractionCache) -> Self {
+        cache.bind(self.cache_fingerprint());
+        self.cache = Some(cache);
+        self
+    }
+
+    /// The extraction cache attached with [`with_cache`](Self::with_cache).
+    pub fn cache(&self) -> Option<&ExtractionCache> {
+        self.cache.as_ref()
+    }
+
+    /// Report progress to `callback` during builds.
+    ///
+    /// The callback runs on the building thread, once per file while parsing
+    /// and at the start and end of the later phases; it should return quickly.
+    pub fn with_progress(
+        mut self,
+        callback: impl Fn(&BuildProgress) + Send + Sync + 'static,
+    ) -> Self {
+        self.progress = Some(Arc::new(callback));
+        self
+    }
+
+    /// Pass the progress of the current phase to the progress callback
+    fn report_progress(&self, phase: BuildPhase, done: usize, total: usize) {
+        if let Some(callback) = &self.progress {
+            callback(&BuildProgress {
+                phase,
+                done: done as u64,
+                total: total as u64,
+            });
+        }
+    }
+
+    /// Version and settings that shape per-file extraction results
+    fn cache_fingerprint(&self) -> String {
+        format!(
+            "{}:{}:{}:{:?}:{:?}{}",
+            env!("CARGO_PKG_VERSION"),
+            EXTRACTION_FORMAT,
+            self.config.skip_data_nodes,
+            self.config.max_containment_depth,
+            self.queries_dir,
+            self.query_files_fingerprint()
+        )
+    }
+
+    /// Fingerprint of the user query files, so editing one invalidates the
+    /// extractions made with it (empty if there are none)
+    fn query_files_fingerprint(&self) -> String {
+        let mut languages: Vec<(&String, &ExtractionRules)> = self
+            .config
+            .extraction
+            .iter()
+            .filter(|(_, rules)| !rules.query_files.is_empty())
+            .collect();
+        languages.sort_by_key(|(language, _)| *language);
+        languages
+            .into_iter()
+            .flat_map(|(language, rules)| {
+                rules.query_files.iter().map(move |file| {
+                    let content = std::fs::read(file).unwrap_or_default();
+                    format!(
+                        ":{}={}@{}",
+                        language,
+                        file.display(),
+                        compute_content_hash(&content)
+                    )
+                })
+            })
+            .collect()
+    }
+
     /// Build a code graph from a directory.
     ///
     /// Walks the directory, processes all supported source files, and
@@ -208,6 +512,520 @@ impl GraphBuilder {
     /// A `PetCodeGraph` containing all discovered code entities and relationships.
     /// Uses petgraph::StableGraph internally for efficient traversal and algorithms.
     pub fn build_from_directory(&mut self, directory: &Path) -> Result<PetCodeGraph, BuilderError> {
+        let span = info_span!(
+            "index",
+            root = %directory.display(),
+            files = field::Empty,
+            duration_ms = field::Empty
+        )
+        .entered();
+        let start = Instant::now();
+        self.check_query_files()?;
+
+        info!("Processing files in {}", directory.display());
+
+        // Collect files to process
+        let files: Vec<PathBuf> = self.collect_files(directory)?;
+        span.record("files", files.len());
+        let DirectoryExtraction {
+            mut graph,
+            repo_name,
+            defines,
+            references,
+            skipped_data_nodes,
+            skipped_depth_nodes,
+        } = self.extract_directory(directory, files)?;
+
+        // Resolve references and create USES edges
+        let reference_count = references.values().map(Vec::len).sum();
+        self.report_progress(BuildPhase::Resolve, 0, reference_count);
+        Self::resolve_references(&mut graph, &defines, &references);
+        self.record_unresolved(&graph);
+        self.report_progress(BuildPhase::Resolve, reference_count, reference_count);
+
+        // Replace name-based Go resolutions with type-checked ones
+        if let Some(check) = &self.config.go_type_check {
+            if graph
+                .iter_nodes()
+                .any(|n| n.is_file() && n.file.ends_with(".go"))
+            {
+                let _span = info_span!("go_types").entered();
+                match check.run(directory) {
+                    Ok(typed) => {
+                        let merged = merge_typed_references(&mut graph, &typed);
+                        info!(
+                            "Type-checked {} Go reference(s): {} confirmed, {} added, {} removed",
+                            typed.len(),
+                            merged.confirmed,
+                            merged.added,
+                            merged.removed
+                        );
+                    }
+                    Err(e) => warn!(
+                        "Go type checking failed, keeping name-based resolution: {}",
+                        e
+                    ),
+                }
+            }
+        }
+
+        // Link Go templates to the code loading them and the data they render
+        let template_files = self.collect_relative_files(directory, is_template_path)?;
+        let templates = link_templates(&mut graph, directory, &repo_name, &template_files);
+        debug!(
+            "Linked {} template(s) with {} edge(s)",
+            templates.templates, templates.edges
+        );
+        self.diagnostics.extend(templates.diagnostics);
+
+        // Link Go to C through cgo, and generated Go to its .proto files
+        let proto_files = self.collect_relative_files(directory, is_proto_path)?;
+        let cross = link_cross_language(&mut graph, directory, &repo_name, &proto_files);
+        debug!(
+            "Indexed {} .proto file(s); linked {} cgo and {} protobuf edge(s)",
+            cross.proto_files, cross.cgo, cross.protobuf
+        );
+
+        // Link Makefile and Dockerfile targets to the Go binaries they build
+        let build_files = self.collect_relative_files(directory, is_build_file)?;
+        let targets = link_build_targets(&mut graph, directory, &repo_name, &build_files);
+        debug!(
+            "Linked {} build target(s) to Go binaries with {} edge(s)",
+            targets.targets, targets.edges
+        );
+
+        // Link near-duplicate callables with CLONE_OF edges
+        self.report_progress(BuildPhase::Clones, 0, 1);
+        let clone_count = {
+            let _span = info_span!("clones").entered();
+            link_clones(&mut graph, &CloneOptions::default())
+        };
+        self.report_progress(BuildPhase::Clones, 1, 1);
+
+        // Link Go interfaces duplicating another package's method set
+        let overlaps = link_interface_overlaps(&mut graph, &InterfaceOptions::default(), |file| {
+            std::fs::read_to_string(directory.join(file)).ok()
+        });
+        debug!("Linked {} overlapping interface pair(s)", overlaps);
+
+        // Tag main functions, handlers, and consumers as reachability roots
+        let entry_points = tag_entry_points(&mut graph, |file| {
+            std::fs::read_to_string(directory.join(file)).ok()
+        });
+        debug!("Tagged {} entry point(s)", entry_points);
+
+        // Link string literals naming symbols and routes (after entry points,
+        // which mark route handlers)
+        let by_name = link_string_references(&mut graph, |file| {
+            std::fs::read_to_string(directory.join(file)).ok()
+        });
+        debug!(
+            "Linked {} symbol and {} route reference(s) by name",
+            by_name.symbols, by_name.routes
+        );
+
+        // Record suspected goroutine and resource leaks on their functions
+        let leaks = find_leaks(&graph, |file| {
+            std::fs::read_to_string(directory.join(file)).ok()
+        });
+        let leaky = attach_leaks(&mut graph, &leaks);
+        debug!(
+            "Found {} suspected leak(s) in {} function(s)",
+            leaks.len(),
+            leaky
+        );
+
+        // Drop the edge types disabled for their language
+        let disabled = remove_disabled_edges(&mut graph, &self.config.extraction);
+        debug!("Removed {} disabled edge(s)", disabled);
+
+        // Weigh types by their uses in signatures, fields, and bodies
+        let weighed = weigh_type_usage(&mut graph);
+        debug!(
+            "Weighed {} type(s) by {} use(s)",
+            weighed.types, weighed.uses
+        );
+
+        // Attach CODEOWNERS owners to files and symbols
+        if let Some(codeowners) = CodeOwners::load(directory) {
+            let owned = codeowners.apply(&mut graph);
+            info!("Assigned owners to {} node(s) from CODEOWNERS", owned);
+        }
+
+        // Attribute tech-debt markers to their authors
+        let attributed = markers::attach_graph_authors(directory, &mut graph);
+        debug!("Attributed {} marker(s) from git blame", attributed);
+
+        // Aggregate package comments and READMEs
+        let documented = attach_package_docs(directory, &mut graph);
+        debug!("Documented {} package(s)", documented);
+
+        // Tag nodes with user-defined attributes
+        let enrichment = Enrichment::new(&self.config.attributes);
+        if !enrichment.is_empty() {
+            let tagged = enrichment.apply(&mut graph);
+            info!("Tagged {} node(s) with attributes", tagged);
+        }
+
+        // Tag nodes with their monorepo project (after rules, which replace
+        // attributes)
+        let in_projects = attach_projects(directory, &mut graph);
+        debug!("Tagged {} node(s) with their project", in_projects);
+
+        // Log statistics
+        let contains_count = graph.edges_by_type(EdgeType::Contains).count();
+        let uses_count = graph.edges_by_type(EdgeType::Uses).count();
+        let defines_count = graph.edges_by_type(EdgeType::Defines).count();
+
+        info!("Graph summary:");
+        info!("  - Nodes: {}", graph.node_count());
+        info!("  - CONTAINS edges: {}", contains_count);
+        info!("  - USES edges: {}", uses_count);
+        info!("  - DEFINES edges: {}", defines_count);
+        info!("  - CLONE_OF edges: {}", clone_count);
+        info!("  - Total edges: {}", graph.edge_count());
+
+        if skipped_data_nodes > 0 || skipped_depth_nodes > 0 {
+            info!("Performance filtering:");
+            if skipped_data_nodes > 0 {
+                info!("  - Skipped Data nodes: {}", skipped_data_nodes);
+            }
+            if skipped_depth_nodes > 0 {
+                info!("  - Skipped nodes (max depth): {}", skipped_depth_nodes);
+            }
+        }
+
+        self.finish_build(start);
+        Ok(graph)
+    }
+
+    /// Build one shard of a repository: the files under `paths` (relative to
+    /// the repository root `directory`).
+    ///
+    /// Node IDs are the same as in a build of the whole repository. References
+    /// are left unresolved, since their targets may be in other shards;
+    /// [`merge_shards`](crate::shard::merge_shards) resolves them across all
+    /// shards and links clones.
+    pub fn build_shard(
+        &mut self,
+        directory: &Path,
+        paths: &[PathBuf],
+    ) -> Result<GraphShard, BuilderError> {
+        let span = info_span!(
+            "shard",
+            root = %directory.display(),
+            files = field::Empty,
+            duration_ms = field::Empty
+        )
+        .entered();
+        let start = Instant::now();
+        self.check_query_files()?;
+
+        let prefixes: Vec<PathBuf> = paths
+            .iter()
+            .map(|p| {
+                p.strip_prefix(directory)
+                    .unwrap_or(p)
+                    .components()
+                    .filter(|c| !matches!(c, Component::CurDir))
+                    .collect()
+            })
+            .collect();
+        let files: Vec<PathBuf> = self
+            .collect_files(directory)?
+            .into_iter()
+            .filter(|file| {
+                let rel_path = file.strip_prefix(directory).unwrap_or(file);
+                prefixes.iter().any(|prefix| rel_path.starts_with(prefix))
+            })
+            .collect();
+        span.record("files", files.len());
+        info!("Shard {:?} has {} files to process", prefixes, files.len());
+
+        let DirectoryExtraction {
+            mut graph,
+            repo_name,
+            references,
+            ..
+        } = self.extract_directory(directory, files)?;
+
+        if let Some(codeowners) = CodeOwners::load(directory) {
+            let owned = codeowners.apply(&mut graph);
+            info!("Assigned owners to {} node(s) from CODEOWNERS", owned);
+        }
+        markers::attach_graph_authors(directory, &mut graph);
+        attach_package_docs(directory, &mut graph);
+
+        let enrichment = Enrichment::new(&self.config.attributes);
+        if !enrichment.is_empty() {
+            let tagged = enrichment.apply(&mut graph);
+            info!("Tagged {} node(s) with attributes", tagged);
+        }
+        attach_projects(directory, &mut graph);
+        remove_disabled_edges(&mut graph, &self.config.extraction);
+
+        self.finish_build(start);
+        Ok(GraphShard::new(
+            repo_name,
+            prefixes
+                .iter()
+                .map(|p| p.to_string_lossy().to_string())
+                .collect(),
+            &graph,
+            references,
+        ))
+    }
+
+    /// Build a code graph from a directory, writing it to `sink` as files are
+    /// extracted instead of returning it.
+    ///
+    /// Only definitions, references, and clone fingerprints are kept until
+    /// the end, where a final pass writes the USES and CLONE_OF edges and
+    /// patches file nodes with their resolution counts. The stream adds up to
+    /// the graph [`build_from_directory`](Self::build_from_directory) returns.
+    pub fn build_streaming(
+        &mut self,
+        directory: &Path,
+        sink: &mut dyn GraphSink,
+    ) -> Result<StreamStats, BuilderError> {
+        let span = info_span!(
+            "index",
+            root = %directory.display(),
+            streaming = true,
+            files = field::Empty,
+            duration_ms = field::Empty
+        )
+        .entered();
+        let start = Instant::now();
+        self.check_query_files()?;
+
+        let files: Vec<PathBuf> = self.collect_files(directory)?;
+        if files.is_empty() {
+            return Err(BuilderError::NoFilesFound(directory.to_path_buf()));
+        }
+        span.record("files", files.len());
+        info!(
+            "Streaming {} files from {}",
+            files.len(),
+            directory.display()
+        );
+
+        let mut stats = StreamStats::default();
+        let repo_name = get_repo_name(directory);
+        let (git_remote, git_branch, git_commit) = extract_git_metadata(directory);
+        let repo_metadata = NodeMetadata::default().with_git(git_remote, git_branch, git_commit);
+        let enrichment = Enrichment::new(&self.config.attributes);
+        let mut repo_node = Node::repository(repo_name.clone(), repo_metadata);
+        enrichment.tag(&mut repo_node);
+        sink.write(&[repo_node], &[])?;
+        stats.nodes += 1;
+
+        let codeowners = CodeOwners::load(directory);
+
+        // What the final pass needs: definitions, references, the files of
+        // reference sources, and clone candidates
+        let mut defines: HashMap<String, String> = HashMap::new();
+        let mut references: HashMap<String, Vec<ReferenceInfo>> = HashMap::new();
+        let mut source_files: HashMap<String, String> = HashMap::new();
+        let mut clone_candidates = PetCodeGraph::new();
+        let mut budget = SymbolBudget::new(self.config.symbol_limits);
+
+        let mut file_count = 0;
+        let mut cached_count = 0;
+        let total = self.files_to_process(&files);
+        for (index, file_path) in files.into_iter().enumerate() {
+            if let Some(max) = self.config.max_files {
+                if file_count >= max {
+                    info!("Reached maximum file limit of {}", max);
+                    break;
+                }
+            }
+            self.report_progress(BuildPhase::Parse, index, total);
+            let Some(language) = SupportedLanguage::from_path(&file_path) else {
+                continue;
+            };
+
+            let rel_path = file_path
+                .strip_prefix(directory)
+                .unwrap_or(&file_path)
+                .to_string_lossy()
+                .to_string();
+            let _file_span = debug_span!("file", path = %rel_path).entered();
+
+            let extracted = std::fs::read(&file_path)
+                .map_err(BuilderError::from)
+                .and_then(|content| {
+                    let file_hash = compute_content_hash(&content);
+                    self.extract_or_reuse(language, content, file_hash, &rel_path)
+                });
+            let (mut extraction, hit) = match extracted {
+                Ok(extracted) => extracted,
+                Err(e) => {
+                    warn!("Error processing {}: {}", rel_path, e);
+                    self.stats.parse_errors += 1;
+                    self.diagnostics
+                        .push(Diagnostic::extraction_failed(&rel_path, &e.to_string()));
+                    continue;
+                }
+            };
+
+            if let Some(codeowners) = &codeowners {
+                for node in &mut extraction.nodes {
+                    let owners = codeowners.owners_of(&node.file);
+                    node.metadata.owners = (!owners.is_empty()).then(|| owners.to_vec());
+                }
+            }
+            markers::attach_authors(directory, &mut extraction.nodes);
+            if !enrichment.is_empty() {
+                for node in &mut extraction.nodes {
+                    enrichment.tag(node);
+                }
+            }
+            let rules = &self.config.extraction;
+            extraction
+                .edges
+                .retain(|edge| !is_disabled(rules, &rel_path, edge.edge_type));
+
+            // Files over a symbol limit keep only their file node
+            let admitted = budget.admit(&rel_path, extraction.symbol_count());
+            let file_only = (!admitted).then(|| extraction.file_only());
+            let written = file_only.as_ref().unwrap_or(&extraction);
+            sink.write(&written.nodes, &written.edges)?;
+            sink.write(&[], &[Edge::contains(repo_name.clone(), rel_path.clone())])?;
+            stats.nodes += written.nodes.len();
+            stats.edges += written.edges.len() + 1;
+
+            for node in &written.nodes {
+                let is_candidate = node.is_callable() && node.metadata.clone_signature.is_some();
+                if is_candidate && !clone_candidates.contains_node(&node.id) {
+                    clone_candidates.add_node(node.clone());
+                }
+            }
+            let node_files: HashMap<&str, &str> = written
+                .nodes
+                .iter()
+                .map(|n| (n.id.as_str(), n.file.as_str()))
+                .collect();
+            for (name, refs) in &written.references {
+                for reference in refs {
+                    if let Some(file) = node_files.get(reference.source_id.as_str()) {
+                        source_files.insert(reference.source_id.clone(), file.to_string());
+                    }
+                }
+                references
+                    .entry(name.clone())
+                    .or_default()
+                    .extend(refs.iter().cloned());
+            }
+            defines.extend(
+                written
+                    .defines
+                    .iter()
+                    .map(|(name, id)| (name.clone(), id.clone())),
+            );
+
+            file_count += 1;
+            if hit {
+                cached_count += 1;
+            }
+            if let Some(cache) = self.cache.as_mut() {
+                cache.insert(&rel_path, extraction);
+            }
+        }
+        self.report_progress(BuildPhase::Parse, total, total);
+        info!(
+            "Streamed {} files ({} from extraction cache)",
+            file_count, cached_count
+        );
+        self.stats.files_parsed += file_count - cached_count;
+        self.stats.files_cached += cached_count;
+
+        // Final pass: USES edges, CLONE_OF edges, then resolution counts
+        let reference_count = references.values().map(Vec::len).sum();
+        self.report_progress(BuildPhase::Resolve, 0, reference_count);
+        let mut uses = Vec::new();
+        let file_refs = Self::resolve_into(
+            &defines,
+            &references,
+            |id| source_files.get(id).cloned(),
+            |edge| uses.push(edge),
+        );
+        let rules = &self.config.extraction;
+        uses.retain(|edge| {
+            let file = source_files.get(&edge.source).map_or("", String::as_str);
+            !is_disabled(rules, file, edge.edge_type)
+        });
+        sink.write(&[], &uses)?;
+        stats.edges += uses.len();
+        self.report_progress(BuildPhase::Resolve, reference_count, reference_count);
+
+        self.report_progress(BuildPhase::Clones, 0, 1);
+        let clones: Vec<Edge> = {
+            let _span = info_span!("clones").entered();
+            find_clones(&clone_candidates, &CloneOptions::default())
+                .into_iter()
+                .filter(|pair| !is_disabled(rules, &pair.first.file, EdgeType::CloneOf))
+                .map(|pair| Edge::clone_of(pair.first.id, pair.second.id, pair.similarity))
+                .collect()
+        };
+        self.report_progress(BuildPhase::Clones, 1, 1);
+        sink.write(&[], &clones)?;
+        stats.edges += clones.len();
+
+        // Mark the packages whose symbols were left out
+        let markers: Vec<Node> = self
+            .report_truncations(budget)
+            .iter()
+            .map(Truncation::node)
+            .collect();
+        let contains: Vec<Edge> = markers
+            .iter()
+            .map(|marker| Edge::contains(marker.file.clone(), marker.id.clone()))
+            .collect();
+        sink.write(&markers, &contains)?;
+        stats.nodes += markers.len();
+        stats.edges += contains.len();
+
+        let mut file_refs: Vec<_> = file_refs.into_iter().collect();
+        file_refs.sort();
+        for (file, (resolved_refs, unresolved_refs)) in file_refs {
+            if file.is_empty() {
+                continue;
+            }
+            if unresolved_refs > 0 {
+                self.diagnostics.push(Diagnostic::unresolved_references(
+                    &file,
+                    resolved_refs,
+                    unresolved_refs,
+                ));
+            }
+            sink.patch(&NodePatch {
+                id: file,
+                resolved_refs,
+                unresolved_refs,
+            })?;
+            stats.patches += 1;
+        }
+        sink.finish()?;
+
+        info!(
+            "Streamed {} nodes and {} edges ({} USES, {} CLONE_OF)",
+            stats.nodes,
+            stats.edges,
+            uses.len(),
+            clones.len()
+        );
+        self.finish_build(start);
+        Ok(stats)
+    }
+
+    /// Extract the entities of `files` under a repository root, without
+    /// resolving references.
+    fn extract_directory(
+        &mut self,
+        directory: &Path,
+        files: Vec<PathBuf>,
+    ) -> Result<DirectoryExtraction, BuilderError> {
         let mut graph = PetCodeGraph::new();
 
         // Create Repository node as root of the hierarchy
@@ -225,14 +1043,10 @@ impl GraphBuilder {
 
         // Statistics
         let mut file_count = 0;
+        let mut cached_count = 0;
         let mut skipped_data_nodes = 0;
         let mut skipped_depth_nodes = 0;
 
-        info!("Processing files in {}", directory.display());
-
-        // Collect files to process
-        let files: Vec<PathBuf> = self.collect_files(directory)?;
-
         if files.is_empty() {
             return Err(BuilderError::NoFilesFound(directory.to_path_buf()));
         }
@@ -240,7 +1054,9 @@ impl GraphBuilder {
         info!("Found {} files to process", files.len());
 
         // Process each file
-        for file_path in files {
+        let mut budget = SymbolBudget::new(self.config.symbol_limits);
+        let total = self.files_to_process(&files);
+        for (index, file_path) in files.into_iter().enumerate() {
             // Check max files limit
             if let Some(max) = self.config.max_files {
                 if file_count >= max {
@@ -248,6 +1064,7 @@ impl GraphBuilder {
                     break;
                 }
             }
+            self.report_progress(BuildPhase::Parse, index, total);
 
             // Get relative path
             let rel_path = file_path
@@ -257,6 +1074,7 @@ impl GraphBuilder {
                 .to_string();
 
             // Process the file
+            let _file_span = debug_span!("file", path = %rel_path).entered();
             match self.process_file(
                 &file_path,
                 &rel_path,
@@ -266,47 +1084,231 @@ impl GraphBuilder {
                 &mut references,
                 &mut skipped_data_nodes,
                 &mut skipped_depth_nodes,
+                &mut budget,
             ) {
-                Ok(_) => {
+                Ok(cached) => {
                     file_count += 1;
+                    if cached {
+                        cached_count += 1;
+                    }
                     if file_count % 100 == 0 {
                         debug!("Processed {} files", file_count);
                     }
                 }
                 Err(e) => {
                     warn!("Error processing {}: {}", rel_path, e);
+                    self.stats.parse_errors += 1;
+                    self.diagnostics
+                        .push(Diagnostic::extraction_failed(&rel_path, &e.to_string()));
                 }
             }
         }
 
-        info!("Processed {} files", file_count);
+        self.report_progress(BuildPhase::Parse, total, total);
+        info!(
+            "Processed {} files ({} from extraction cache)",
+            file_count, cached_count
+        );
 
-        // Resolve references and create USES edges
-        self.resolve_references(&mut graph, &defines, &references);
+        self.stats.files_parsed += file_count - cached_count;
+        self.stats.files_cached += cached_count;
+        let truncations = self.report_truncations(budget);
+        attach_truncations(&mut graph, &truncations);
+
+        Ok(DirectoryExtraction {
+            graph,
+            repo_name,
+            defines,
+            references,
+            skipped_data_nodes,
+            skipped_depth_nodes,
+        })
+    }
 
-        // Log statistics
-        let contains_count = graph.edges_by_type(EdgeType::Contains).count();
-        let uses_count = graph.edges_by_type(EdgeType::Uses).count();
-        let defines_count = graph.edges_by_type(EdgeType::Defines).count();
+    /// Report the packages whose symbols a build left out as warnings and
+    /// diagnostics, returning them.
+    fn report_truncations(&mut self, budget: SymbolBudget) -> Vec<Truncation> {
+        let truncations = budget.into_truncations();
+        for truncation in &truncations {
+            warn!(
+                "Left {} symbol(s) of {} file(s) in {} out of the graph: {}",
+                truncation.symbols,
+                truncation.files.len(),
+                truncation.package,
+                truncation.limit.describe()
+            );
+            self.diagnostics.push(truncation.diagnostic());
+        }
+        truncations
+    }
 
-        info!("Graph summary:");
-        info!("  - Nodes: {}", graph.node_count());
-        info!("  - CONTAINS edges: {}", contains_count);
-        info!("  - USES edges: {}", uses_count);
-        info!("  - DEFINES edges: {}", defines_count);
-        info!("  - Total edges: {}", graph.edge_count());
+    /// Number of `files` a build processes, given the `max_files` limit
+    fn files_to_process(&self, files: &[PathBuf]) -> usize {
+        self.config
+            .max_files
+            .map_or(files.len(), |max| files.len().min(max))
+    }
 
-        if skipped_data_nodes > 0 || skipped_depth_nodes > 0 {
-            info!("Performance filtering:");
-            if skipped_data_nodes > 0 {
-                info!("  - Skipped Data nodes: {}", skipped_data_nodes);
+    /// Record the duration and finish time of a build started at `start`,
+    /// also as the `duration_ms` of the build's span.
+    fn finish_build(&mut self, start: Instant) {
+        let duration_ms = start.elapsed().as_millis() as u64;
+        Span::current().record("duration_ms", duration_ms);
+        self.stats.duration_ms += duration_ms;
+        self.stats.finished_at = SystemTime::now()
+            .duration_since(UNIX_EPOCH)
+            .map_or(0, |d| d.as_secs());
+    }
+
+    /// Build a code graph from in-memory `(relative path, source)` pairs, as
+    /// [`build_from_directory`](Self::build_from_directory) would for a
+    /// directory holding those files.
+    ///
+    /// Files in unsupported languages are skipped. Without a filesystem
+    /// there is no git metadata or CODEOWNERS overlay, and the build is not
+    /// timed; used by the WebAssembly build.
+    pub fn build_from_sources<I, P, S>(&mut self, name: &str, files: I) -> PetCodeGraph
+    where
+        I: IntoIterator<Item = (P, S)>,
+        P: AsRef<str>,
+        S: AsRef<str>,
+    {
+        let _span = info_span!("index", root = name).entered();
+        let mut graph = PetCodeGraph::new();
+        graph.add_node(Node::repository(name.to_string(), NodeMetadata::default()));
+
+        let mut defines: HashMap<String, String> = HashMap::new();
+        let mut references: HashMap<String, Vec<ReferenceInfo>> = HashMap::new();
+        let mut skipped_data_nodes = 0;
+        let mut skipped_depth_nodes = 0;
+
+        let mut sources: HashMap<String, String> = HashMap::new();
+        for (rel_path, source) in files {
+            let (rel_path, source) = (rel_path.as_ref(), source.as_ref());
+            let Some(language) = SupportedLanguage::from_path(Path::new(rel_path)) else {
+                continue;
+            };
+            sources.insert(rel_path.to_string(), source.to_string());
+
+            let _file_span = debug_span!("file", path = %rel_path).entered();
+            match self.process_source(
+                language,
+                source,
+                compute_content_hash(source.as_bytes()),
+                rel_path,
+                name,
+                &mut graph,
+                &mut defines,
+                &mut references,
+                &mut skipped_data_nodes,
+                &mut skipped_depth_nodes,
+            ) {
+                Ok(()) => self.stats.files_parsed += 1,
+                Err(e) => {
+                    warn!("Error processing {}: {}", rel_path, e);
+                    self.stats.parse_errors += 1;
+                    self.diagnostics
+                        .push(Diagnostic::extraction_failed(rel_path, &e.to_string()));
+                }
             }
-            if skipped_depth_nodes > 0 {
-                info!("  - Skipped nodes (max depth): {}", skipped_depth_nodes);
+        }
+
+        Self::resolve_references(&mut graph, &defines, &references);
+        self.record_unresolved(&graph);
+        {
+            let _span = info_span!("clones").entered();
+            link_clones(&mut graph, &CloneOptions::default());
+        }
+        link_interface_overlaps(&mut graph, &InterfaceOptions::default(), |file| {
+            sources.get(file).cloned()
+        });
+        tag_entry_points(&mut graph, |file| sources.get(file).cloned());
+        link_string_references(&mut graph, |file| sources.get(file).cloned());
+        let leaks = find_leaks(&graph, |file| sources.get(file).cloned());
+        attach_leaks(&mut graph, &leaks);
+        remove_disabled_edges(&mut graph, &self.config.extraction);
+        for truncation in enforce_limits(&mut graph, &self.config.symbol_limits) {
+            self.diagnostics.push(truncation.diagnostic());
+        }
+        weigh_type_usage(&mut graph);
+
+        graph
+    }
+
+    /// Tag extractor for a language, with the builder's queries.
+    pub(crate) fn tag_extractor(
+        &self,
+        language: SupportedLanguage,
+    ) -> Result<TagExtractor, ParserError> {
+        let query_files = self
+            .config
+            .extraction
+            .get(language.as_str())
+            .map(|rules| rules.query_files.as_slice())
+            .unwrap_or_default();
+        if query_files.is_empty() {
+            return match &self.queries_dir {
+                Some(dir) => TagExtractor::from_queries_dir(language, dir),
+                None => TagExtractor::from_embedded(language),
+            };
+        }
+
+        // Append the user's query files to the builder's queries
+        let mut query_source = match &self.queries_dir {
+            Some(dir) => QueryManager::source_from_queries_dir(language, dir)?,
+            None => QueryManager::embedded_source(language)?,
+        };
+        QueryManager::append_query_files(language, &mut query_source, query_files)?;
+        TagExtractor::new(language, &query_source)
+    }
+
+    /// Check that the user query files of every language load and compile
+    /// with the builder's queries, so a broken file fails the build instead
+    /// of every file of its language.
+    pub fn check_query_files(&self) -> Result<(), BuilderError> {
+        for language in crate::embedded_queries::supported_languages() {
+            let has_query_files = self
+                .config
+                .extraction
+                .get(language.as_str())
+                .is_some_and(|rules| !rules.query_files.is_empty());
+            if has_query_files {
+                self.tag_extractor(*language)?;
             }
         }
+        Ok(())
+    }
 
-        Ok(graph)
+    /// Statistics of the builds run so far, summed across code roots.
+    pub fn stats(&self) -> &BuildStats {
+        &self.stats
+    }
+
+    /// The builder's configuration.
+    pub fn config(&self) -> &BuilderConfig {
+        &self.config
+    }
+
+    /// Diagnostics of the builds run so far: files that could not be
+    /// extracted, syntax errors, and files with unresolved references.
+    pub fn diagnostics(&self) -> &[Diagnostic] {
+        &self.diagnostics
+    }
+
+    /// Record a diagnostic for each file node of `graph` with references
+    /// that resolved to nothing.
+    fn record_unresolved(&mut self, graph: &PetCodeGraph) {
+        for node in graph.iter_nodes() {
+            if let (Some(resolved), Some(unresolved)) =
+                (node.metadata.resolved_refs, node.metadata.unresolved_refs)
+            {
+                if unresolved > 0 {
+                    self.diagnostics.push(Diagnostic::unresolved_references(
+                        &node.file, resolved, unresolved,
+                    ));
+                }
+            }
+        }
     }
 
     /// Build a code graph from a workspace root that may contain multiple repositories.
@@ -386,6 +1388,7 @@ impl GraphBuilder {
 
         // Build and merge each discovered root
         for root in &roots {
+            let _span = info_span!("root", name = %root.name).entered();
             info!("Processing root: {} ({:?})", root.name, root.root_type);
 
             // Build graph for this root (returns PetCodeGraph)
@@ -411,6 +1414,13 @@ impl GraphBuilder {
             info!("Merged root '{}' into workspace graph", root.name);
         }
 
+        // Per-root builds only see their own clones; relink across roots
+        let clone_count = {
+            let _span = info_span!("clones").entered();
+            link_clones(&mut workspace_graph, &CloneOptions::default())
+        };
+        info!("Linked {} clone pair(s) across roots", clone_count);
+
         info!(
             "Workspace graph complete: {} nodes, {} edges across {} roots",
             workspace_graph.node_count(),
@@ -459,12 +1469,50 @@ impl GraphBuilder {
     /// Collect all supported source files from a directory.
     ///
     /// Uses the `ignore` crate to respect:
-    /// - `.gitignore` files
+    /// - `.gitignore` files, also outside git repositories
     /// - `.codeprysmignore` files (custom exclusions for CodePrysm indexing)
     /// - Global gitignore patterns
-    fn collect_files(&self, directory: &Path) -> Result<Vec<PathBuf>, BuilderError> {
+    ///
+    /// The remaining files are then filtered by the configured exclude and
+    /// include patterns and the file policy.
+    pub(crate) fn collect_files(&self, directory: &Path) -> Result<Vec<PathBuf>, BuilderError> {
+        self.walk_files(directory, |path| {
+            SupportedLanguage::from_path(path).is_some()
+        })
+    }
+
+    /// Collect the non-source files `accept` selects (Go templates, `.proto`
+    /// files), relative to the directory, with the same exclusions as source
+    /// files.
+    fn collect_relative_files(
+        &self,
+        directory: &Path,
+        accept: impl Fn(&Path) -> bool,
+    ) -> Result<Vec<String>, BuilderError> {
+        Ok(self
+            .walk_files(directory, accept)?
+            .iter()
+            .map(|path| {
+                path.strip_prefix(directory)
+                    .unwrap_or(path)
+                    .to_string_lossy()
+                    .replace('\\', "/")
+            })
+            .collect())
+    }
+
+    /// Walk a directory for the files `accept` selects, honoring ignore
+    /// files, exclude and include patterns, and the file policy.
+    fn walk_files(
+        &self,
+        directory: &Path,
+        accept: impl Fn(&Path) -> bool,
+    ) -> Result<Vec<PathBuf>, BuilderError> {
         let mut files = Vec::new();
         let glob_set = self.build_exclude_glob_set();
+        let include_set = (!self.config.include_patterns.is_empty())
+            .then(|| build_glob_set(&self.config.include_patterns));
+        let file_filter = self.config.file_policy.compile();
 
         // Use ignore::WalkBuilder which respects .gitignore and custom ignore files
         let walker = WalkBuilder::new(directory)
@@ -473,6 +1521,7 @@ impl GraphBuilder {
             .git_ignore(true) // Respect .gitignore
             .git_global(true) // Respect global gitignore
             .git_exclude(true) // Respect .git/info/exclude
+            .require_git(false) // Respect .gitignore outside git repositories too
             .add_custom_ignore_filename(".codeprysmignore") // Respect .codeprysmignore
             .build();
 
@@ -496,8 +1545,8 @@ impl GraphBuilder {
 
             let path = entry.path();
 
-            // Check if file is supported
-            if SupportedLanguage::from_path(path).is_none() {
+            // Check if file is wanted
+            if !accept(path) {
                 continue;
             }
 
@@ -509,6 +1558,15 @@ impl GraphBuilder {
             if glob_set.is_match(rel_path.as_ref()) {
                 continue;
             }
+            if include_set
+                .as_ref()
+                .is_some_and(|include| !include.is_match(rel_path.as_ref()))
+            {
+                continue;
+            }
+            if !file_filter.admits(&rel_path, path) {
+                continue;
+            }
 
             files.push(path.to_path_buf());
         }
@@ -519,24 +1577,164 @@ impl GraphBuilder {
         Ok(files)
     }
 
-    /// Build a glob set from exclude patterns.
-    fn build_exclude_glob_set(&self) -> globset::GlobSet {
-        let mut builder = globset::GlobSetBuilder::new();
-        for pattern in &self.config.exclude_patterns {
-            if let Ok(glob) = globset::Glob::new(pattern) {
-                builder.add(glob);
-            }
-        }
-        builder
-            .build()
-            .unwrap_or_else(|_| globset::GlobSet::empty())
+    /// Build a glob set from exclude patterns.
+    fn build_exclude_glob_set(&self) -> globset::GlobSet {
+        let mut builder = globset::GlobSetBuilder::new();
+        for pattern in &self.config.exclude_patterns {
+            if let Ok(glob) = globset::Glob::new(pattern) {
+                builder.add(glob);
+            }
+        }
+        builder
+            .build()
+            .unwrap_or_else(|_| globset::GlobSet::empty())
+    }
+
+    /// Process a single file and add its entities to the graph.
+    ///
+    /// Returns whether the file's extraction was reused from the cache.
+    #[allow(clippy::too_many_arguments)]
+    fn process_file(
+        &mut self,
+        file_path: &Path,
+        rel_path: &str,
+        repo_name: &str,
+        graph: &mut PetCodeGraph,
+        defines: &mut HashMap<String, String>,
+        references: &mut HashMap<String, Vec<ReferenceInfo>>,
+        skipped_data_nodes: &mut usize,
+        skipped_depth_nodes: &mut usize,
+        budget: &mut SymbolBudget,
+    ) -> Result<bool, BuilderError> {
+        // Detect language
+        let language = match SupportedLanguage::from_path(file_path) {
+            Some(lang) => lang,
+            None => return Ok(false), // Skip unsupported files
+        };
+
+        // Read file content and compute its hash
+        let content = std::fs::read(file_path)?;
+        let file_hash = compute_content_hash(&content);
+
+        if self.cache.is_none() && budget.is_unlimited() {
+            let source = String::from_utf8(content)
+                .map_err(|e| std::io::Error::new(std::io::ErrorKind::InvalidData, e))?;
+            self.process_source(
+                language,
+                &source,
+                file_hash,
+                rel_path,
+                repo_name,
+                graph,
+                defines,
+                references,
+                skipped_data_nodes,
+                skipped_depth_nodes,
+            )?;
+            return Ok(false);
+        }
+
+        // Reuse the cached extraction of unchanged files, extracting changed
+        // ones on their own so the results can be cached (and counted
+        // against the symbol limits before they are added)
+        let (extraction, hit) = self.extract_or_reuse(language, content, file_hash, rel_path)?;
+
+        if budget.admit(rel_path, extraction.symbol_count()) {
+            extraction.merge_into(graph, defines, references);
+            *skipped_data_nodes += extraction.skipped_data_nodes;
+            *skipped_depth_nodes += extraction.skipped_depth_nodes;
+        } else {
+            extraction
+                .file_only()
+                .merge_into(graph, defines, references);
+        }
+        if !repo_name.is_empty() {
+            graph
+                .add_edge_from_struct(&Edge::contains(repo_name.to_string(), rel_path.to_string()));
+        }
+
+        if let Some(cache) = self.cache.as_mut() {
+            cache.insert(rel_path, extraction);
+        }
+        Ok(hit)
+    }
+
+    /// Extract one file into a standalone [`FileExtraction`], reusing the
+    /// cached extraction if the same content at the same path was extracted
+    /// before, by this checkout or another sharing the cache.
+    ///
+    /// Returns the extraction and whether it came from the cache; the caller
+    /// records it in the cache once done with it.
+    fn extract_or_reuse(
+        &mut self,
+        language: SupportedLanguage,
+        content: Vec<u8>,
+        file_hash: String,
+        rel_path: &str,
+    ) -> Result<(FileExtraction, bool), BuilderError> {
+        if let Some(extraction) = self
+            .cache
+            .as_mut()
+            .and_then(|c| c.take(rel_path, &file_hash))
+        {
+            self.diagnostics
+                .extend(extraction.diagnostics.iter().cloned());
+            return Ok((extraction, true));
+        }
+        let extraction = self.extract_file(language, content, file_hash, rel_path)?;
+        self.diagnostics
+            .extend(extraction.diagnostics.iter().cloned());
+        Ok((extraction, false))
+    }
+
+    /// Extract one file's entities into a standalone [`FileExtraction`].
+    pub(crate) fn extract_file(
+        &mut self,
+        language: SupportedLanguage,
+        content: Vec<u8>,
+        file_hash: String,
+        rel_path: &str,
+    ) -> Result<FileExtraction, BuilderError> {
+        let source = String::from_utf8(content)
+            .map_err(|e| std::io::Error::new(std::io::ErrorKind::InvalidData, e))?;
+        let mut graph = PetCodeGraph::new();
+        let mut defines = HashMap::new();
+        let mut references = HashMap::new();
+        let mut skipped_data_nodes = 0;
+        let mut skipped_depth_nodes = 0;
+        let first_diagnostic = self.diagnostics.len();
+
+        self.process_source(
+            language,
+            &source,
+            file_hash.clone(),
+            rel_path,
+            "",
+            &mut graph,
+            &mut defines,
+            &mut references,
+            &mut skipped_data_nodes,
+            &mut skipped_depth_nodes,
+        )?;
+
+        Ok(FileExtraction {
+            defines,
+            references,
+            skipped_data_nodes,
+            skipped_depth_nodes,
+            diagnostics: self.diagnostics.split_off(first_diagnostic),
+            ..FileExtraction::from_graph(file_hash, &graph)
+        })
     }
 
-    /// Process a single file and add its entities to the graph.
+    /// Extract the entities of one file's `source` into `graph`, without
+    /// touching the filesystem.
     #[allow(clippy::too_many_arguments)]
-    fn process_file(
+    fn process_source(
         &mut self,
-        file_path: &Path,
+        language: SupportedLanguage,
+        source: &str,
+        file_hash: String,
         rel_path: &str,
         repo_name: &str,
         graph: &mut PetCodeGraph,
@@ -545,27 +1743,27 @@ impl GraphBuilder {
         skipped_data_nodes: &mut usize,
         skipped_depth_nodes: &mut usize,
     ) -> Result<(), BuilderError> {
-        // Detect language
-        let language = match SupportedLanguage::from_path(file_path) {
-            Some(lang) => lang,
-            None => return Ok(()), // Skip unsupported files
-        };
-
-        // Read file content
-        let source = std::fs::read_to_string(file_path)?;
-
-        // Compute file hash
-        let file_hash = compute_file_hash(file_path)?;
-
-        // Count lines
-        let line_count = source.lines().count();
+        let lines: Vec<&str> = source.lines().collect();
+        let line_count = lines.len();
+        let scope = symbol_scope(language, rel_path, source);
 
         // Add file container node
+        let header = license::detect(source);
         let file_node = Node::source_file(
             rel_path.to_string(),
             rel_path.to_string(),
             file_hash,
             line_count,
+        )
+        .with_metadata(
+            NodeMetadata::new()
+                .with_file(
+                    language.as_str(),
+                    source.len() as u64,
+                    is_generated(source.as_bytes()),
+                    is_test_file(rel_path),
+                )
+                .with_license(header.license, header.copyright),
         );
         graph.add_node(file_node);
 
@@ -576,14 +1774,17 @@ impl GraphBuilder {
         }
 
         // Get or create tag extractor for this language
-        let mut extractor = match &self.queries_dir {
-            Some(dir) => TagExtractor::from_queries_dir(language, dir)?,
-            None => TagExtractor::from_embedded(language)?,
-        };
+        let mut extractor = self.tag_extractor(language)?;
         let metadata_extractor = MetadataExtractor::new(language);
 
-        // Extract tags
-        let tags = extractor.extract(&source)?;
+        // Parse and extract tags
+        let (tags, markers) = {
+            let _span = debug_span!("parse", language = ?language).entered();
+            let extracted = extractor.extract_source(source, rel_path)?;
+            self.diagnostics.extend(extracted.diagnostics);
+            (extracted.tags, extracted.markers)
+        };
+        let _span = debug_span!("extract").entered();
 
         // Separate definition and reference tags
         // IMPORTANT: Only use `name.` prefixed tags (e.g., @name.definition.X) which capture
@@ -673,7 +1874,7 @@ impl GraphBuilder {
             defines.insert(tag.name.clone(), node_id.clone());
 
             // Create node
-            let node = self.create_node_from_tag(
+            let mut node = self.create_node_from_tag(
                 &node_id,
                 &tag.name,
                 &tag_info,
@@ -682,6 +1883,37 @@ impl GraphBuilder {
                 tag.end_line_number(),
                 &metadata_extractor,
             );
+            node.metadata.receiver = tag.receiver.clone();
+            if !node.is_file() {
+                // Signatures tell overloads apart; other declarations can
+                // include values that change without the symbol changing
+                let signature = (node.node_type == NodeType::Callable)
+                    .then(|| declaration_signature(&lines, node.line, node.end_line))
+                    .flatten();
+                let symbol_path: Vec<&str> = node_id
+                    .strip_prefix(rel_path)
+                    .and_then(|path| path.strip_prefix(':'))
+                    .unwrap_or(node_id.as_str())
+                    .split(':')
+                    .collect();
+                node.metadata.symbol_id = Some(symbol_id(
+                    language,
+                    &scope,
+                    &symbol_path,
+                    signature.as_deref(),
+                ));
+            }
+            if let Some(complexity) = tag.complexity {
+                node.metadata.cyclomatic_complexity = Some(complexity.cyclomatic);
+                node.metadata.cognitive_complexity = Some(complexity.cognitive);
+            }
+            if tag.control_flow.is_some() {
+                node.metadata.control_flow = tag.control_flow;
+            }
+            if let Some(ref fingerprint) = tag.fingerprint {
+                node.metadata.token_count = Some(fingerprint.tokens);
+                node.metadata.clone_signature = Some(fingerprint.signature.clone());
+            }
 
             // Skip if node already exists
             if graph.contains_node(&node_id) {
@@ -689,16 +1921,36 @@ impl GraphBuilder {
             }
 
             // Add node to graph
+            let is_file = node.is_file();
             graph.add_node(node);
 
             // Add CONTAINS edge from parent
             graph.add_edge_from_struct(&Edge::contains(parent_id.clone(), node_id.clone()));
 
+            // Add DEFINED_IN edge to the file, however deeply the symbol is nested
+            if !is_file {
+                graph
+                    .add_edge_from_struct(&Edge::defined_in(node_id.clone(), rel_path.to_string()));
+            }
+
             // Add DEFINES edge for Data nodes (if parent is not the file)
             if tag_info.node_type == NodeType::Data && parent_id != rel_path {
                 graph.add_edge_from_struct(&Edge::defines(parent_id.clone(), node_id.clone()));
             }
 
+            // Link a field to the anonymous type it is declared with
+            if let Some(owner) = &tag.anonymous_owner {
+                let owner_id = generate_node_id(rel_path, &containment_path, owner, None);
+                if graph.contains_node(&owner_id) {
+                    graph.add_edge_from_struct(&Edge::uses(
+                        owner_id,
+                        node_id.clone(),
+                        Some(tag.line_number()),
+                        Some(tag.name.clone()),
+                    ));
+                }
+            }
+
             // Push containers onto containment stack (use parent line range for proper nesting)
             let node_type_str = tag_info.node_type.as_str();
             if node_type_str == "Container" || node_type_str == "Callable" {
@@ -712,6 +1964,26 @@ impl GraphBuilder {
             }
         }
 
+        // Add tech-debt markers, contained by their innermost enclosing symbol
+        for marker in &markers {
+            if self.config.skip_data_nodes {
+                *skipped_data_nodes += 1;
+                continue;
+            }
+            let node = marker.to_node(rel_path);
+            if graph.contains_node(&node.id) {
+                continue;
+            }
+            let parent_id =
+                Some(self.find_enclosing_context(&definition_tags, marker.line - 1, rel_path))
+                    .filter(|id| graph.contains_node(id))
+                    .unwrap_or_else(|| rel_path.to_string());
+            let node_id = node.id.clone();
+            graph.add_node(node);
+            graph.add_edge_from_struct(&Edge::contains(parent_id, node_id.clone()));
+            graph.add_edge_from_struct(&Edge::defined_in(node_id, rel_path.to_string()));
+        }
+
         // Process references
         for tag in &reference_tags {
             let tag_string = normalize_tag_string(&tag.tag);
@@ -885,55 +2157,100 @@ impl GraphBuilder {
     }
 
     /// Resolve references and create USES edges.
-    fn resolve_references(
-        &self,
+    pub(crate) fn resolve_references(
         graph: &mut PetCodeGraph,
         defines: &HashMap<String, String>,
         references: &HashMap<String, Vec<ReferenceInfo>>,
     ) {
+        let mut edges = Vec::new();
+        let file_refs = Self::resolve_into(
+            defines,
+            references,
+            |id| graph.get_node(id).map(|n| n.file.clone()),
+            |edge| edges.push(edge),
+        );
+        for edge in &edges {
+            graph.add_edge_from_struct(edge);
+        }
+
+        // Record resolution counts on file nodes (node ID is the file path)
+        for (file, (resolved, unresolved)) in file_refs {
+            if let Some(node) = graph.get_node_mut(&file) {
+                node.metadata.resolved_refs = Some(resolved);
+                node.metadata.unresolved_refs = Some(unresolved);
+            }
+        }
+    }
+
+    /// Resolve references into USES edges passed to `emit`.
+    ///
+    /// `node_file` gives the file of a node, or `None` if the node does not
+    /// exist. References are grouped by the package of their source and the
+    /// packages resolved in parallel. `defines` is one workspace-wide name
+    /// table, so each package's pass resolves all of its references, those
+    /// into other packages included; the final pass only orders the output,
+    /// emitting every package's local edges before the cross-package ones.
+    /// Returns per-file (resolved, unresolved) reference counts.
+    pub(crate) fn resolve_into(
+        defines: &HashMap<String, String>,
+        references: &HashMap<String, Vec<ReferenceInfo>>,
+        node_file: impl Fn(&str) -> Option<String> + Sync,
+        mut emit: impl FnMut(Edge),
+    ) -> HashMap<String, (u32, u32)> {
+        let _span = info_span!("resolve").entered();
         info!("Creating USES relationships...");
+
+        // Group references by the package of their source file
+        let mut packages: BTreeMap<String, Vec<PendingRef<'_>>> = BTreeMap::new();
+        for (name, refs) in references {
+            for reference in refs {
+                let file = node_file(&reference.source_id);
+                let package = file.as_deref().map(package_of).unwrap_or_default();
+                packages
+                    .entry(package.to_string())
+                    .or_default()
+                    .push(PendingRef {
+                        name,
+                        reference,
+                        file,
+                    });
+            }
+        }
+
+        let resolutions: Vec<PackageResolution> = packages
+            .par_iter()
+            .map(|(package, refs)| Self::resolve_package(package, refs, defines, &node_file))
+            .collect();
+
+        // Final pass: emit edges within packages first, then across packages
         let mut uses_count = 0;
+        let mut cross_count = 0;
         let mut forward_refs = 0;
         let mut skipped_missing_source = 0;
-
-        for (name, refs) in references {
-            if let Some(target_id) = defines.get(name) {
-                // Target definition found - create USES edges
-                for ref_info in refs {
-                    // Only create edge if source and target are different
-                    if ref_info.source_id != *target_id {
-                        // Only create edge if source node exists in the graph
-                        if graph.contains_node(&ref_info.source_id) {
-                            graph.add_edge_from_struct(&Edge::uses(
-                                ref_info.source_id.clone(),
-                                target_id.clone(),
-                                Some(ref_info.line),
-                                Some(name.clone()),
-                            ));
-                            uses_count += 1;
-                        } else {
-                            // Source node doesn't exist (e.g., reference inside impl block
-                            // for a type not defined in this codebase)
-                            skipped_missing_source += 1;
-                            debug!(
-                                "Skipped USES edge: source '{}' not found (ref to '{}')",
-                                ref_info.source_id, name
-                            );
-                        }
-                    }
-                }
-            } else {
-                // Forward reference or external dependency
-                forward_refs += refs.len();
-                debug!(
-                    "Forward/external reference to '{}' ({} occurrences)",
-                    name,
-                    refs.len()
-                );
+        // Per-file (resolved, unresolved) reference counts
+        let mut file_refs: HashMap<String, (u32, u32)> = HashMap::new();
+        let mut cross_edges = Vec::new();
+        for resolution in resolutions {
+            uses_count += resolution.local.len() + resolution.cross.len();
+            cross_count += resolution.cross.len();
+            forward_refs += resolution.forward_refs;
+            skipped_missing_source += resolution.skipped_missing_source;
+            for (file, (resolved, unresolved)) in resolution.file_refs {
+                let counts = file_refs.entry(file).or_default();
+                counts.0 += resolved;
+                counts.1 += unresolved;
             }
+            resolution.local.into_iter().for_each(&mut emit);
+            cross_edges.push(resolution.cross);
         }
+        cross_edges.into_iter().flatten().for_each(&mut emit);
 
-        info!("Created {} USES relationships", uses_count);
+        info!(
+            "Created {} USES relationships ({} across packages) in {} package(s)",
+            uses_count,
+            cross_count,
+            packages.len()
+        );
         if forward_refs > 0 {
             info!("Skipped {} forward/external references", forward_refs);
         }
@@ -943,6 +2260,63 @@ impl GraphBuilder {
                 skipped_missing_source
             );
         }
+        file_refs
+    }
+
+    /// Resolve the references made from one package.
+    fn resolve_package(
+        package: &str,
+        refs: &[PendingRef<'_>],
+        defines: &HashMap<String, String>,
+        node_file: &(impl Fn(&str) -> Option<String> + Sync),
+    ) -> PackageResolution {
+        let mut resolution = PackageResolution::default();
+        for pending in refs {
+            let reference = pending.reference;
+            let Some(target_id) = defines.get(pending.name) else {
+                // Forward reference or external dependency
+                resolution.forward_refs += 1;
+                if let Some(file) = &pending.file {
+                    resolution.file_refs.entry(file.clone()).or_default().1 += 1;
+                }
+                debug!("Forward/external reference to '{}'", pending.name);
+                continue;
+            };
+
+            // Only create edge if source and target are different
+            if reference.source_id == *target_id {
+                continue;
+            }
+            // Only create edge if source node exists in the graph
+            let Some(file) = &pending.file else {
+                // Source node doesn't exist (e.g., reference inside impl block
+                // for a type not defined in this codebase)
+                resolution.skipped_missing_source += 1;
+                debug!(
+                    "Skipped USES edge: source '{}' not found (ref to '{}')",
+                    reference.source_id, pending.name
+                );
+                continue;
+            };
+
+            resolution.file_refs.entry(file.clone()).or_default().0 += 1;
+            let edge = Edge::uses(
+                reference.source_id.clone(),
+                target_id.clone(),
+                Some(reference.line),
+                Some(pending.name.to_string()),
+            );
+            let target_file = node_file(target_id);
+            if target_file.as_deref().map(package_of) == Some(package) {
+                resolution.local.push(edge);
+            } else {
+                resolution.cross.push(edge);
+            }
+        }
+        // References arrive in hash map order; emit edges in a fixed one
+        resolution.local.sort_by(Edge::stable_cmp);
+        resolution.cross.sort_by(Edge::stable_cmp);
+        resolution
     }
 
     /// Parse a single file and return a graph with its entities.
@@ -955,8 +2329,10 @@ impl GraphBuilder {
     /// - DEFINES edges (Container/Callable → Data)
     ///
     /// Note: USES edges are NOT included because they require cross-file
-    /// reference resolution. Call `resolve_references_for_file()` after
-    /// merging into the main graph if needed.
+    /// reference resolution. Use [`parse_file_with_references`] and
+    /// [`resolve_file_references`] after merging into the main graph if needed.
+    ///
+    /// [`parse_file_with_references`]: Self::parse_file_with_references
     ///
     /// # Arguments
     ///
@@ -971,6 +2347,18 @@ impl GraphBuilder {
         file_path: &Path,
         rel_path: &str,
     ) -> Result<PetCodeGraph, BuilderError> {
+        self.parse_file_with_references(file_path, rel_path)
+            .map(|(graph, _)| graph)
+    }
+
+    /// Parse a single file like [`parse_file`](Self::parse_file), also
+    /// returning its references for [`resolve_file_references`].
+    pub fn parse_file_with_references(
+        &mut self,
+        file_path: &Path,
+        rel_path: &str,
+    ) -> Result<(PetCodeGraph, FileReferences), BuilderError> {
+        let _span = debug_span!("file", path = %rel_path).entered();
         let mut graph = PetCodeGraph::new();
         let mut defines = HashMap::new();
         let mut references = HashMap::new();
@@ -995,10 +2383,81 @@ impl GraphBuilder {
             graph.edge_count()
         );
 
-        Ok(graph)
+        Ok((graph, FileReferences { references }))
+    }
+
+    /// Parse a file's contents like
+    /// [`parse_file_with_references`](Self::parse_file_with_references),
+    /// without reading from disk.
+    ///
+    /// The language is detected from `rel_path`'s extension. Used where there
+    /// is no filesystem, such as the WebAssembly build.
+    pub fn parse_source(
+        &mut self,
+        rel_path: &str,
+        source: &str,
+    ) -> Result<(PetCodeGraph, FileReferences), BuilderError> {
+        let _span = debug_span!("file", path = %rel_path).entered();
+        let language = SupportedLanguage::from_path(Path::new(rel_path))
+            .ok_or_else(|| ParserError::UnsupportedLanguage(rel_path.to_string()))?;
+        let mut graph = PetCodeGraph::new();
+        let mut references = HashMap::new();
+
+        self.process_source(
+            language,
+            source,
+            compute_content_hash(source.as_bytes()),
+            rel_path,
+            "",
+            &mut graph,
+            &mut HashMap::new(),
+            &mut references,
+            &mut 0,
+            &mut 0,
+        )?;
+
+        Ok((graph, FileReferences { references }))
     }
 }
 
+/// Resolve a file's references into USES edges, as a full build would.
+///
+/// `defines` maps names to the node IDs they resolve to (see [`definitions`]).
+/// The file's entities must already be in `graph`; its file node gets the
+/// resolved and unresolved reference counts.
+pub fn resolve_file_references(
+    graph: &mut PetCodeGraph,
+    defines: &HashMap<String, String>,
+    references: &FileReferences,
+) {
+    GraphBuilder::resolve_references(graph, defines, &references.references);
+}
+
+/// Map definition names to node IDs for reference resolution.
+///
+/// When several entities share a name, the one a full build would pick wins:
+/// the last in file and line order.
+pub fn definitions(graph: &PetCodeGraph) -> HashMap<String, String> {
+    let mut nodes: Vec<&Node> = graph
+        .iter_nodes()
+        .filter(|n| {
+            n.is_callable()
+                || n.is_data()
+                || (n.is_container()
+                    && !n.is_file()
+                    && !n.is_repository()
+                    && !n.is_workspace()
+                    && !n.is_component())
+        })
+        .collect();
+    nodes.sort_by(|a, b| (&a.file, a.line, &a.id).cmp(&(&b.file, b.line, &b.id)));
+
+    nodes
+        .into_iter()
+        .map(|n| (n.name.clone(), n.id.clone()))
+        .collect()
+}
+
 // ============================================================================
 // Component Builder
 // ============================================================================
@@ -1089,6 +2548,7 @@ impl ComponentBuilder {
             .git_ignore(true) // Respect .gitignore
             .git_global(true) // Respect global gitignore
             .git_exclude(true) // Respect .git/info/exclude
+            .require_git(false) // Respect .gitignore outside git repositories too
             .add_custom_ignore_filename(".codeprysmignore") // Respect .codeprysmignore
             .build();
 
@@ -1618,6 +3078,12 @@ fn get_repo_name(directory: &Path) -> String {
 #[cfg(test)]
 mod tests {
     use super::*;
+    use std::sync::Mutex;
+    use tracing::field::{Field, Visit};
+    use tracing::span::{Attributes, Id, Record};
+    use tracing::Subscriber;
+    use tracing_subscriber::layer::{Context, Layer, SubscriberExt};
+    use tracing_subscriber::registry::LookupSpan;
 
     #[test]
     fn test_normalize_tag_string() {
@@ -1650,6 +3116,560 @@ mod tests {
         assert!(matches!(result, Err(BuilderError::QueryDirNotFound(_))));
     }
 
+    #[test]
+    fn test_build_stats_roundtrip() {
+        let dir = tempfile::tempdir().unwrap();
+        assert!(BuildStats::load(dir.path()).is_none());
+
+        let stats = BuildStats {
+            files_parsed: 42,
+            files_cached: 7,
+            parse_errors: 1,
+            duration_ms: 1500,
+            finished_at: 1_700_000_000,
+        };
+        stats.save(dir.path()).unwrap();
+        assert_eq!(BuildStats::load(dir.path()), Some(stats));
+    }
+
+    /// Fields of a span, as recorded
+    #[derive(Default)]
+    struct SpanFields(HashMap<String, String>);
+
+    impl Visit for SpanFields {
+        fn record_debug(&mut self, field: &Field, value: &dyn std::fmt::Debug) {
+            self.0
+                .insert(field.name().to_string(), format!("{:?}", value));
+        }
+    }
+
+    /// Layer collecting the fields of closed `index` spans
+    #[derive(Clone, Default)]
+    struct IndexSpans(Arc<Mutex<Vec<HashMap<String, String>>>>);
+
+    impl<S: Subscriber + for<'a> LookupSpan<'a>> Layer<S> for IndexSpans {
+        fn on_new_span(&self, attrs: &Attributes<'_>, id: &Id, ctx: Context<'_, S>) {
+            if attrs.metadata().name() == "index" {
+                let mut fields = SpanFields::default();
+                attrs.record(&mut fields);
+                ctx.span(id).unwrap().extensions_mut().insert(fields);
+            }
+        }
+
+        fn on_record(&self, id: &Id, values: &Record<'_>, ctx: Context<'_, S>) {
+            let span = ctx.span(id).unwrap();
+            if let Some(fields) = span.extensions_mut().get_mut::<SpanFields>() {
+                values.record(fields);
+            }
+        }
+
+        fn on_close(&self, id: Id, ctx: Context<'_, S>) {
+            let span = ctx.span(&id).unwrap();
+            if let Some(fields) = span.extensions_mut().remove::<SpanFields>() {
+                self.0.lock().unwrap().push(fields.0);
+            }
+        }
+    }
+
+    #[test]
+    fn test_index_span_records_files_and_duration() {
+        let dir = tempfile::tempdir().unwrap();
+        std::fs::write(dir.path().join("util.py"), "def helper():\n    return 1\n").unwrap();
+        std::fs::write(dir.path().join("main.py"), "def run():\n    return 2\n").unwrap();
+
+        let spans = IndexSpans::default();
+        let subscriber = tracing_subscriber::registry().with(spans.clone());
+        tracing::subscriber::with_default(subscriber, || {
+            let mut builder = GraphBuilder::new_with_embedded_queries();
+            builder.build_from_directory(dir.path()).unwrap();
+        });
+
+        let spans = spans.0.lock().unwrap();
+        assert_eq!(spans.len(), 1);
+        let index = &spans[0];
+        assert_eq!(index["files"], "2");
+        assert!(index["duration_ms"].parse::<u64>().is_ok());
+        assert_eq!(index["root"], dir.path().display().to_string());
+    }
+
+    #[test]
+    fn test_build_from_sources() {
+        let mut builder = GraphBuilder::new_with_embedded_queries();
+        let graph = builder.build_from_sources(
+            "demo",
+            [
+                ("util.py", "def helper():\n    return 1\n"),
+                (
+                    "main.py",
+                    "from util import helper\n\ndef run():\n    return helper()\n",
+                ),
+                ("README.md", "# demo\n"),
+            ],
+        );
+
+        assert!(graph.get_node("demo").unwrap().is_repository());
+        assert!(graph.contains_node("util.py"));
+        assert!(graph.contains_node("main.py"));
+        assert!(!graph.contains_node("README.md"));
+        assert_eq!(builder.stats().files_parsed, 2);
+
+        let helper = graph
+            .iter_nodes()
+            .find(|n| n.name == "helper" && n.is_callable())
+            .unwrap();
+        assert!(graph
+            .incoming_edges(&helper.id)
+            .any(|(_, data)| data.edge_type == EdgeType::Uses));
+    }
+
+    #[test]
+    fn test_parse_source() {
+        let mut builder = GraphBuilder::new_with_embedded_queries();
+        let (graph, _) = builder
+            .parse_source("app/greet.py", "def greet(name):\n    return name\n")
+            .unwrap();
+
+        let file = graph.get_node("app/greet.py").unwrap();
+        assert!(file.is_file());
+        assert!(graph
+            .iter_nodes()
+            .any(|n| n.name == "greet" && n.is_callable()));
+
+        let result = builder.parse_source("notes.txt", "hello");
+        assert!(matches!(
+            result,
+            Err(BuilderError::Parser(ParserError::UnsupportedLanguage(_)))
+        ));
+    }
+
+    #[test]
+    fn test_go_anonymous_types() {
+        let mut builder = GraphBuilder::new_with_embedded_queries();
+        let source = "package api
+
+type Config struct {
+	Server struct {
+		Port int
+		TLS  *struct {
+			Cert string
+		}
+	}
+	Hooks []interface {
+		Run() error
+	}
+	Seen map[string]struct{}
+}
+
+var defaults struct {
+	Timeout int
+}
+";
+        let (graph, _) = builder.parse_source("api/config.go", source).unwrap();
+
+        let server = graph
+            .get_node("api/config.go:Config:Server.struct")
+            .unwrap();
+        assert_eq!(server.name, "Server.struct");
+        assert_eq!(server.subtype.as_deref(), Some("struct"));
+        assert_eq!((server.line, server.end_line), (4, 9));
+        assert_eq!(graph.parent(&server.id).unwrap().id, "api/config.go:Config");
+        assert!(graph
+            .incoming_edges(&server.id)
+            .any(|(node, edge)| node.id == "api/config.go:Config:Server"
+                && edge.edge_type == EdgeType::Uses));
+
+        // Members are nested under their anonymous type
+        for id in [
+            "api/config.go:Config:Server.struct:Port",
+            "api/config.go:Config:Server.struct:TLS.struct:Cert",
+            "api/config.go:Config:Hooks.interface:Run",
+            "api/config.go:defaults.struct:Timeout",
+        ] {
+            assert!(graph.contains_node(id), "{}", id);
+        }
+        assert!(!graph.contains_node("api/config.go:Config:Port"));
+        assert!(graph
+            .get_node("api/config.go:Config:Hooks.interface")
+            .is_some_and(|node| node.subtype.as_deref() == Some("interface")));
+
+        // Empty types have no members to show
+        assert!(!graph.contains_node("api/config.go:Config:Seen.struct"));
+    }
+
+    #[test]
+    fn test_resolve_into_partitions_by_package() {
+        let files: HashMap<String, String> = [
+            ("a/x.py:run", "a/x.py"),
+            ("a/y.py:helper", "a/y.py"),
+            ("b/z.py:util", "b/z.py"),
+        ]
+        .into_iter()
+        .map(|(id, file)| (id.to_string(), file.to_string()))
+        .collect();
+        let defines: HashMap<String, String> =
+            [("helper", "a/y.py:helper"), ("util", "b/z.py:util")]
+                .into_iter()
+                .map(|(name, id)| (name.to_string(), id.to_string()))
+                .collect();
+        let reference = |source_id: &str, line| ReferenceInfo {
+            source_id: source_id.to_string(),
+            line,
+        };
+        let references: HashMap<String, Vec<ReferenceInfo>> = [
+            ("helper".to_string(), vec![reference("a/x.py:run", 2)]),
+            (
+                "util".to_string(),
+                vec![reference("a/x.py:run", 3), reference("b/z.py:util", 4)],
+            ),
+            ("missing".to_string(), vec![reference("a/x.py:run", 5)]),
+        ]
+        .into_iter()
+        .collect();
+
+        let mut edges = Vec::new();
+        let file_refs = GraphBuilder::resolve_into(
+            &defines,
+            &references,
+            |id| files.get(id).cloned(),
+            |edge| edges.push(edge),
+        );
+
+        // Same-package edges come before the cross-package pass
+        let targets: Vec<&str> = edges.iter().map(|e| e.target.as_str()).collect();
+        assert_eq!(targets, vec!["a/y.py:helper", "b/z.py:util"]);
+        assert_eq!(file_refs["a/x.py"], (2, 1));
+        // A self-reference is neither resolved nor unresolved
+        assert!(!file_refs.contains_key("b/z.py"));
+    }
+
+    #[test]
+    fn test_collect_files_filters() {
+        let dir = tempfile::tempdir().unwrap();
+        for file in [
+            "src/app.py",
+            "vendor/lib.py",
+            "tests/data/case.py",
+            "build/out.py",
+        ] {
+            let path = dir.path().join(file);
+            std::fs::create_dir_all(path.parent().unwrap()).unwrap();
+            std::fs::write(path, "x = 1\n").unwrap();
+        }
+        // Not a git repository, but .gitignore still applies
+        std::fs::write(dir.path().join(".gitignore"), "vendor/\n").unwrap();
+
+        let builder = GraphBuilder::with_embedded_queries(BuilderConfig {
+            exclude_patterns: vec!["**/data/**".to_string()],
+            include_patterns: vec!["src/**".to_string(), "tests/**".to_string()],
+            ..Default::default()
+        });
+        let files: Vec<String> = builder
+            .collect_files(dir.path())
+            .unwrap()
+            .iter()
+            .map(|path| {
+                path.strip_prefix(dir.path())
+                    .unwrap()
+                    .to_string_lossy()
+                    .replace('\\', "/")
+            })
+            .collect();
+
+        assert_eq!(files, ["src/app.py"]);
+    }
+
+    #[test]
+    fn test_progress_reports_phases() {
+        let dir = tempfile::tempdir().unwrap();
+        std::fs::write(dir.path().join("a.py"), "def a():\n    b()\n").unwrap();
+        std::fs::write(dir.path().join("b.py"), "def b():\n    pass\n").unwrap();
+
+        let events = Arc::new(std::sync::Mutex::new(Vec::new()));
+        let recorded = Arc::clone(&events);
+        let mut builder = GraphBuilder::with_embedded_queries(BuilderConfig::default())
+            .with_progress(move |progress| recorded.lock().unwrap().push(progress.clone()));
+        builder.build_from_directory(dir.path()).unwrap();
+
+        let events = events.lock().unwrap();
+        let parse: Vec<(u64, u64)> = events
+            .iter()
+            .filter(|p| p.phase == BuildPhase::Parse)
+            .map(|p| (p.done, p.total))
+            .collect();
+        assert_eq!(parse, [(0, 2), (1, 2), (2, 2)]);
+        let phases: Vec<BuildPhase> = events.iter().map(|p| p.phase).collect();
+        let resolve = phases
+            .iter()
+            .position(|p| *p == BuildPhase::Resolve)
+            .unwrap();
+        let clones = phases
+            .iter()
+            .position(|p| *p == BuildPhase::Clones)
+            .unwrap();
+        assert!(resolve > 2 && clones > resolve);
+        assert_eq!(events.last().unwrap().done, 1);
+    }
+
+    #[test]
+    fn test_symbol_ids_are_stable_within_their_scope() {
+        let symbol_id_of = |file: &str, source: &str, id: &str| {
+            let dir = tempfile::tempdir().unwrap();
+            std::fs::write(dir.path().join(file), source).unwrap();
+            let mut builder = GraphBuilder::with_embedded_queries(BuilderConfig::default());
+            let graph = builder.build_from_directory(dir.path()).unwrap();
+            graph
+                .get_node(id)
+                .unwrap()
+                .metadata
+                .symbol_id
+                .clone()
+                .unwrap()
+        };
+
+        let original = symbol_id_of("a.py", "def run(x):\n    pass\n", "a.py:run");
+        assert!(crate::symbol_id::is_symbol_id(&original));
+        assert_eq!(
+            symbol_id_of("a.py", "def run(x):\n    pass\n", "a.py:run"),
+            original
+        );
+        // Shifting lines keeps it
+        assert_eq!(
+            symbol_id_of("a.py", "\n\ndef run(x):\n    return 1\n", "a.py:run"),
+            original
+        );
+        // Python modules are files, so a function of another file differs
+        assert_ne!(
+            symbol_id_of("b.py", "def run(x):\n    pass\n", "b.py:run"),
+            original
+        );
+        // Changing the signature does not
+        assert_ne!(
+            symbol_id_of("a.py", "def run(x, y):\n    pass\n", "a.py:run"),
+            original
+        );
+
+        // Go packages are directories: moving between their files keeps it,
+        // but variants for another platform differ
+        let source = "package api\n\nfunc Run(x int) {\n}\n";
+        let original = symbol_id_of("api.go", source, "api.go:Run");
+        assert_eq!(
+            symbol_id_of("run.go", &format!("\n{}", source), "run.go:Run"),
+            original
+        );
+        assert_ne!(
+            symbol_id_of("run_linux.go", source, "run_linux.go:Run"),
+            original
+        );
+    }
+
+    #[test]
+    fn test_file_nodes_carry_attributes_and_symbols() {
+        let dir = tempfile::tempdir().unwrap();
+        let source = "# @generated\nclass Server:\n    def handle(self):\n        pass\n";
+        std::fs::write(dir.path().join("server_test.py"), source).unwrap();
+        let mut builder = GraphBuilder::with_embedded_queries(BuilderConfig::default());
+        let graph = builder.build_from_directory(dir.path()).unwrap();
+
+        let file = graph.get_node("server_test.py").unwrap();
+        assert_eq!(file.metadata.language.as_deref(), Some("python"));
+        assert_eq!(file.metadata.size_bytes, Some(source.len() as u64));
+        assert_eq!(file.metadata.is_generated, Some(true));
+        assert_eq!(file.metadata.is_test, Some(true));
+
+        // Nested symbols point straight at their file
+        let symbols: Vec<&str> = graph
+            .file_symbols("server_test.py")
+            .map(|node| node.id.as_str())
+            .collect();
+        assert!(symbols.contains(&"server_test.py:Server"));
+        assert!(symbols.contains(&"server_test.py:Server:handle"));
+        assert!(graph
+            .file_symbols("server_test.py")
+            .all(|node| !node.is_file()));
+    }
+
+    #[test]
+    fn test_attribute_rules_tag_nodes() {
+        let dir = tempfile::tempdir().unwrap();
+        std::fs::create_dir(dir.path().join("payments")).unwrap();
+        std::fs::write(
+            dir.path().join("payments/refund.py"),
+            "def refund_handler():\n    pass\n",
+        )
+        .unwrap();
+        let config = BuilderConfig {
+            attributes: vec![AttributeRule {
+                paths: vec!["payments/**".to_string()],
+                names: vec!["*_handler".to_string()],
+                attributes: [("layer".to_string(), "api".to_string())].into(),
+            }],
+            ..Default::default()
+        };
+        let mut builder = GraphBuilder::with_embedded_queries(config);
+        let graph = builder.build_from_directory(dir.path()).unwrap();
+
+        let handler = graph.get_node("payments/refund.py:refund_handler").unwrap();
+        assert_eq!(
+            handler.metadata.attributes.as_ref().unwrap()["layer"],
+            "api"
+        );
+        // The file is under the path but its name does not match
+        let file = graph.get_node("payments/refund.py").unwrap();
+        assert!(file.metadata.attributes.is_none());
+    }
+
+    #[test]
+    fn test_build_records_diagnostics() {
+        use crate::diagnostics::{DiagnosticKind, Severity};
+
+        let dir = tempfile::tempdir().unwrap();
+        std::fs::write(
+            dir.path().join("ok.py"),
+            "def main():\n    external_call()\n",
+        )
+        .unwrap();
+        std::fs::write(
+            dir.path().join("broken.py"),
+            "def fine():\n    pass\n\ndef broken(:\n    pass\n",
+        )
+        .unwrap();
+        std::fs::write(dir.path().join("binary.py"), [0xff, 0xfe, 0x00]).unwrap();
+
+        let mut builder = GraphBuilder::new_with_embedded_queries();
+        let graph = builder.build_from_directory(dir.path()).unwrap();
+        // Definitions before the syntax error are kept
+        assert!(graph.get_node("broken.py:fine").is_some());
+
+        let diagnostics = builder.diagnostics();
+        let of = |file: &str, kind: DiagnosticKind| {
+            diagnostics
+                .iter()
+                .find(|d| d.file == file && d.kind == kind)
+                .cloned()
+        };
+        let failed = of("binary.py", DiagnosticKind::ExtractionFailed).unwrap();
+        assert_eq!(failed.severity, Severity::Error);
+        let syntax = of("broken.py", DiagnosticKind::SyntaxError).unwrap();
+        assert_eq!(syntax.span.unwrap().line, 4);
+        assert!(of("ok.py", DiagnosticKind::UnresolvedReferences).is_some());
+        assert!(of("ok.py", DiagnosticKind::SyntaxError).is_none());
+    }
+
+    #[test]
+    fn test_build_extracts_markers() {
+        let dir = tempfile::tempdir().unwrap();
+        std::fs::write(
+            dir.path().join("app.py"),
+            "# FIXME: module-level\nclass App:\n    def run(self):\n        # TODO: retry on failure\n        pass\n",
+        )
+        .unwrap();
+
+        let graph = GraphBuilder::new_with_embedded_queries()
+            .build_from_directory(dir.path())
+            .unwrap();
+        let todo = graph.get_node("app.py:<todo>:4").unwrap();
+        assert!(todo.is_marker());
+        assert_eq!(todo.text.as_deref(), Some("retry on failure"));
+        assert_eq!(
+            graph.parent("app.py:<todo>:4").unwrap().id,
+            "app.py:App:run"
+        );
+        assert_eq!(graph.parent("app.py:<fixme>:1").unwrap().id, "app.py");
+    }
+
+    #[test]
+    fn test_extraction_rules() {
+        let dir = tempfile::tempdir().unwrap();
+        std::fs::write(
+            dir.path().join("routes.go"),
+            "package app\n\nfunc handleOrders() {}\n\nfunc setup(r *Router) {\n\tr.Route(\"/orders\", handleOrders)\n}\n",
+        )
+        .unwrap();
+        let queries = dir.path().join("go-routes.scm");
+        std::fs::write(
+            &queries,
+            "(call_expression\n  function: (selector_expression field: (field_identifier) @_route)\n  arguments: (argument_list (_) (identifier) @name.reference.callable)\n  (#eq? @_route \"Route\")) @reference.callable\n",
+        )
+        .unwrap();
+        let with_rules = |rules: ExtractionRules| BuilderConfig {
+            extraction: HashMap::from([("go".to_string(), rules)]),
+            ..Default::default()
+        };
+        let uses_handler = |graph: &PetCodeGraph| {
+            graph
+                .incoming_edges("routes.go:handleOrders")
+                .any(|(node, edge)| {
+                    edge.edge_type == EdgeType::Uses && node.id == "routes.go:setup"
+                })
+        };
+
+        // The query file's references become USES edges
+        let graph = GraphBuilder::with_embedded_queries(with_rules(ExtractionRules {
+            query_files: vec![queries.clone()],
+            ..Default::default()
+        }))
+        .build_from_directory(dir.path())
+        .unwrap();
+        assert!(uses_handler(&graph));
+
+        // Disabled edges are dropped
+        let graph = GraphBuilder::with_embedded_queries(with_rules(ExtractionRules {
+            disabled_edges: vec![EdgeType::Uses],
+            query_files: vec![queries.clone()],
+        }))
+        .build_from_directory(dir.path())
+        .unwrap();
+        assert_eq!(graph.edges_by_type(EdgeType::Uses).count(), 0);
+        assert!(graph.edges_by_type(EdgeType::Contains).count() > 0);
+
+        // A broken query file fails the build, naming the file
+        std::fs::write(&queries, "(call_expression @reference.callable").unwrap();
+        let err = GraphBuilder::with_embedded_queries(with_rules(ExtractionRules {
+            query_files: vec![queries.clone()],
+            ..Default::default()
+        }))
+        .build_from_directory(dir.path())
+        .unwrap_err();
+        assert!(err.to_string().contains("go-routes.scm"));
+    }
+
+    #[test]
+    fn test_symbol_limits() {
+        use crate::diagnostics::DiagnosticKind;
+
+        let dir = tempfile::tempdir().unwrap();
+        std::fs::create_dir(dir.path().join("gen")).unwrap();
+        for file in ["a.go", "b.go"] {
+            std::fs::write(
+                dir.path().join("gen").join(file),
+                "package gen\n\nfunc One() {}\n\nfunc Two() {}\n",
+            )
+            .unwrap();
+        }
+        let mut builder = GraphBuilder::with_embedded_queries(BuilderConfig {
+            symbol_limits: SymbolLimits {
+                max_per_package: Some(3),
+                max_total: None,
+            },
+            ..Default::default()
+        });
+        let graph = builder.build_from_directory(dir.path()).unwrap();
+
+        // gen/b.go keeps its file node and a marker, but not its functions
+        assert!(graph.get_node("gen/a.go:One").is_some());
+        assert!(graph.get_node("gen/b.go:One").is_none());
+        let marker = graph.get_node("gen/b.go:<truncated>").unwrap();
+        assert!(marker.is_truncation());
+        assert_eq!(graph.parent(&marker.id).unwrap().id, "gen/b.go");
+
+        let diagnostic = builder
+            .diagnostics()
+            .iter()
+            .find(|d| d.kind == DiagnosticKind::SymbolsTruncated)
+            .unwrap();
+        assert_eq!(diagnostic.file, "gen/b.go");
+    }
+
     // ========================================================================
     // ComponentBuilder Tests
     // ========================================================================
@@ -0,0 +1,429 @@
+//! Per-Symbol Churn from Git History
+//!
+//! Reads `git log` with zero-context patches and attributes each commit to
+//! the callables whose lines it changed, giving per-callable commit counts,
+//! distinct authors, and commit times (see [`crate::analysis::risk`] for the
+//! risk score built on them).
+//!
+//! Callable spans come from the indexed tree and are carried back through
+//! history hunk by hunk: lines before a hunk keep their position shifted by
+//! earlier hunks, and a span reaching into a hunk maps onto the lines the
+//! hunk replaced. A callable stops being tracked at the commit that added
+//! all of its lines. Only first-parent history is followed, and renames are
+//! not, so churn starts over at the commit that moved a file.
+
+use std::collections::{BTreeSet, HashMap};
+use std::path::Path;
+use std::process::Command;
+
+use thiserror::Error;
+
+use crate::graph::{NodeType, PetCodeGraph};
+
+/// Errors that can occur while reading git history.
+#[derive(Debug, Error)]
+pub enum ChurnError {
+    /// Failed to run git
+    #[error("Failed to run git: {0}")]
+    Io(#[from] std::io::Error),
+
+    /// git exited with an error
+    #[error("git log failed: {0}")]
+    Git(String),
+}
+
+/// A hunk of a zero-context patch: `count` lines at `start` in one version
+/// replaced by lines of the other. A count of 0 means nothing was removed or
+/// added right after line `start`.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub struct Hunk {
+    /// Start line in the parent version
+    pub old_start: usize,
+    /// Lines in the parent version
+    pub old_count: usize,
+    /// Start line in the commit's version
+    pub new_start: usize,
+    /// Lines in the commit's version
+    pub new_count: usize,
+}
+
+impl Hunk {
+    /// First line of the hunk in the parent version; insertions sit after
+    /// `old_start`
+    fn old_first(&self) -> usize {
+        if self.old_count == 0 {
+            self.old_start + 1
+        } else {
+            self.old_start
+        }
+    }
+
+    /// First line of the hunk in the commit's version; deletions sit after
+    /// `new_start`
+    fn new_first(&self) -> usize {
+        if self.new_count == 0 {
+            self.new_start + 1
+        } else {
+            self.new_start
+        }
+    }
+}
+
+/// One commit of the history, with the hunks it made per file.
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub struct Commit {
+    /// Author time (Unix seconds)
+    pub time: i64,
+    /// Author name
+    pub author: String,
+    /// Hunks by file path
+    pub files: HashMap<String, Vec<Hunk>>,
+}
+
+/// Change history of one callable.
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub struct SymbolChurn {
+    /// Commits that changed the callable's lines
+    pub commits: u32,
+    /// Distinct authors of those commits
+    pub authors: u32,
+    /// Author times of those commits, newest first
+    pub times: Vec<i64>,
+}
+
+impl SymbolChurn {
+    /// Author time of the latest commit
+    pub fn last_changed(&self) -> Option<i64> {
+        self.times.first().copied()
+    }
+}
+
+/// Git history, newest commit first.
+#[derive(Debug, Clone, Default)]
+pub struct GitHistory {
+    /// Commits, newest first
+    pub commits: Vec<Commit>,
+}
+
+/// Span of a callable in one version of its file, None once a commit added
+/// all of its lines
+type Span = Option<(usize, usize)>;
+
+/// Prefix of the commit lines of [`GitHistory::load`]'s log format
+const COMMIT_PREFIX: &str = "commit\t";
+
+impl GitHistory {
+    /// Read the first-parent history of the repository at `root`, with paths
+    /// relative to it, optionally only commits more recent than `since`
+    /// (e.g., "6 months ago").
+    pub fn load(root: &Path, since: Option<&str>) -> Result<Self, ChurnError> {
+        let mut command = Command::new("git");
+        command
+            .args([
+                "log",
+                "--first-parent",
+                "--no-renames",
+                "--relative",
+                "--no-color",
+                "--no-ext-diff",
+                "--patch",
+                "--unified=0",
+                "--format=commit%x09%at%x09%aN",
+            ])
+            .current_dir(root);
+        if let Some(since) = since {
+            command.arg(format!("--since={}", since));
+        }
+
+        let output = command.output()?;
+        if !output.status.success() {
+            return Err(ChurnError::Git(
+                String::from_utf8_lossy(&output.stderr).trim().to_string(),
+            ));
+        }
+        Ok(Self::parse(&String::from_utf8_lossy(&output.stdout)))
+    }
+
+    /// Parse `git log --patch --unified=0` output whose commits start with
+    /// `commit<TAB><author time><TAB><author>` lines.
+    pub fn parse(log: &str) -> Self {
+        let mut commits: Vec<Commit> = Vec::new();
+        let mut file: Option<String> = None;
+        // Removed and added lines left in the current hunk
+        let mut pending = (0, 0);
+
+        for line in log.lines() {
+            if pending != (0, 0) {
+                match line.as_bytes().first() {
+                    Some(b'-') if pending.0 > 0 => pending.0 -= 1,
+                    Some(b'+') if pending.1 > 0 => pending.1 -= 1,
+                    Some(b'\\') => {}
+                    _ => pending = (0, 0),
+                }
+                if pending != (0, 0) || matches!(line.as_bytes().first(), Some(b'-' | b'+')) {
+                    continue;
+                }
+            }
+
+            if let Some(header) = line.strip_prefix(COMMIT_PREFIX) {
+                let (time, author) = header.split_once('\t').unwrap_or((header, ""));
+                commits.push(Commit {
+                    time: time.trim().parse().unwrap_or(0),
+                    author: author.to_string(),
+                    files: HashMap::new(),
+                });
+                file = None;
+            } else if line.starts_with("diff ") {
+                file = None;
+            } else if let Some(path) = line.strip_prefix("+++ ") {
+                file = path.strip_prefix("b/").map(str::to_string);
+            } else if let Some(hunk) = line.strip_prefix("@@ ").and_then(parse_hunk_header) {
+                pending = (hunk.old_count, hunk.new_count);
+                if let (Some(commit), Some(file)) = (commits.last_mut(), &file) {
+                    commit.files.entry(file.clone()).or_default().push(hunk);
+                }
+            }
+        }
+        Self { commits }
+    }
+
+    /// Churn of each callable of `graph` changed in the history, by node ID.
+    pub fn symbol_churn(&self, graph: &PetCodeGraph) -> HashMap<String, SymbolChurn> {
+        // Tracked spans per file, in the version after the commit being
+        // visited
+        let mut spans: HashMap<&str, Vec<(&str, Span)>> = HashMap::new();
+        for node in graph
+            .iter_nodes()
+            .filter(|n| n.node_type == NodeType::Callable && !n.file.is_empty())
+        {
+            spans
+                .entry(node.file.as_str())
+                .or_default()
+                .push((node.id.as_str(), Some((node.line, node.end_line))));
+        }
+
+        let mut found: HashMap<&str, (Vec<i64>, BTreeSet<&str>)> = HashMap::new();
+        for commit in &self.commits {
+            for (file, hunks) in &commit.files {
+                let Some(spans) = spans.get_mut(file.as_str()) else {
+                    continue;
+                };
+                for (id, span) in spans.iter_mut() {
+                    let Some((start, end)) = *span else {
+                        continue;
+                    };
+                    if hunks.iter().any(|h| touches(h, start, end)) {
+                        let (times, authors) = found.entry(id).or_default();
+                        times.push(commit.time);
+                        authors.insert(commit.author.as_str());
+                    }
+                    let (start, end) = (map_start(hunks, start), map_end(hunks, end));
+                    *span = (start <= end).then_some((start, end));
+                }
+            }
+        }
+
+        found
+            .into_iter()
+            .map(|(id, (times, authors))| {
+                let churn = SymbolChurn {
+                    commits: times.len() as u32,
+                    authors: authors.len() as u32,
+                    times,
+                };
+                (id.to_string(), churn)
+            })
+            .collect()
+    }
+}
+
+/// Parse the ranges of a hunk header after its leading `@@ `:
+/// `-12,3 +12,0 @@ ...`
+fn parse_hunk_header(header: &str) -> Option<Hunk> {
+    let mut ranges = header.split_whitespace();
+    let old = ranges.next()?.strip_prefix('-')?;
+    let new = ranges.next()?.strip_prefix('+')?;
+    let range = |text: &str| -> Option<(usize, usize)> {
+        match text.split_once(',') {
+            Some((start, count)) => Some((start.parse().ok()?, count.parse().ok()?)),
+            None => Some((text.parse().ok()?, 1)),
+        }
+    };
+    let (old_start, old_count) = range(old)?;
+    let (new_start, new_count) = range(new)?;
+    Some(Hunk {
+        old_start,
+        old_count,
+        new_start,
+        new_count,
+    })
+}
+
+/// Check if a hunk changed lines `start..=end` of the commit's version: it
+/// added lines among them, or removed lines between two of them
+fn touches(hunk: &Hunk, start: usize, end: usize) -> bool {
+    let first = hunk.new_first();
+    if hunk.new_count == 0 {
+        start < first && first <= end
+    } else {
+        first <= end && start < first + hunk.new_count
+    }
+}
+
+/// Line of the parent version a span starting at `line` starts at
+fn map_start(hunks: &[Hunk], line: usize) -> usize {
+    let mut shift: isize = 0;
+    for hunk in hunks {
+        let first = hunk.new_first();
+        if line < first {
+            break;
+        }
+        if line < first + hunk.new_count {
+            return hunk.old_first();
+        }
+        shift += hunk.old_count as isize - hunk.new_count as isize;
+    }
+    line.saturating_add_signed(shift)
+}
+
+/// Line of the parent version a span ending at `line` ends at
+fn map_end(hunks: &[Hunk], line: usize) -> usize {
+    let mut shift: isize = 0;
+    for hunk in hunks {
+        let first = hunk.new_first();
+        if line < first {
+            break;
+        }
+        if line < first + hunk.new_count {
+            return (hunk.old_first() + hunk.old_count).saturating_sub(1);
+        }
+        shift += hunk.old_count as isize - hunk.new_count as isize;
+    }
+    line.saturating_add_signed(shift)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::{CallableKind, Node};
+
+    const LOG: &str = "commit\t1700200000\tBob
+diff --git a/store.go b/store.go
+index 1111111..2222222 100644
+--- a/store.go
++++ b/store.go
+@@ -4,0 +5,2 @@ func Get() {
++\tlog()
++\tlog()
+@@ -9 +11 @@ func Put() {
+--- old comment
++-- new comment
+commit\t1700100000\tAlice
+diff --git a/store.go b/store.go
+index 0000000..1111111 100644
+--- a/store.go
++++ b/store.go
+@@ -2,2 +1,0 @@
+-// header
+-// header
+@@ -10 +8 @@ func Put() {
+-\told()
++\tnew()
+commit\t1700000000\tAlice
+diff --git a/store.go b/store.go
+new file mode 100644
+--- /dev/null
++++ b/store.go
+@@ -0,0 +1,14 @@
++package store
+";
+
+    #[test]
+    fn test_parse_log() {
+        let history = GitHistory::parse(LOG);
+        assert_eq!(history.commits.len(), 3);
+
+        let newest = &history.commits[0];
+        assert_eq!((newest.time, newest.author.as_str()), (1700200000, "Bob"));
+        assert_eq!(
+            newest.files["store.go"],
+            vec![
+                Hunk {
+                    old_start: 4,
+                    old_count: 0,
+                    new_start: 5,
+                    new_count: 2
+                },
+                // Removed and added lines starting with "--" are content
+                Hunk {
+                    old_start: 9,
+                    old_count: 1,
+                    new_start: 11,
+                    new_count: 1
+                },
+            ]
+        );
+        assert_eq!(history.commits[1].files["store.go"].len(), 2);
+        assert_eq!(history.commits[2].files["store.go"][0].new_count, 14);
+    }
+
+    #[test]
+    fn test_line_mapping() {
+        // Two lines inserted after line 4, one line deleted after line 8
+        let hunks = [
+            Hunk {
+                old_start: 4,
+                old_count: 0,
+                new_start: 5,
+                new_count: 2,
+            },
+            Hunk {
+                old_start: 7,
+                old_count: 1,
+                new_start: 8,
+                new_count: 0,
+            },
+        ];
+        assert_eq!(map_start(&hunks, 3), 3);
+        assert_eq!(map_start(&hunks, 5), 5);
+        assert_eq!(map_end(&hunks, 6), 4);
+        assert_eq!(map_start(&hunks, 7), 5);
+        assert_eq!(map_end(&hunks, 9), 8);
+
+        assert!(touches(&hunks[0], 1, 5));
+        assert!(!touches(&hunks[0], 7, 9));
+        assert!(touches(&hunks[1], 8, 9));
+        assert!(!touches(&hunks[1], 9, 12));
+        assert!(!touches(&hunks[1], 3, 8));
+    }
+
+    #[test]
+    fn test_symbol_churn() {
+        let mut graph = PetCodeGraph::new();
+        for (name, line, end_line) in [("Get", 3, 7), ("Put", 9, 12), ("New", 13, 14)] {
+            graph.add_node(Node::callable(
+                format!("store.go:{}", name),
+                name.to_string(),
+                CallableKind::Function,
+                "store.go".to_string(),
+                line,
+                end_line,
+            ));
+        }
+
+        let churn = GitHistory::parse(LOG).symbol_churn(&graph);
+
+        // Get gained lines in the newest commit; it was created with the file
+        let get = &churn["store.go:Get"];
+        assert_eq!((get.commits, get.authors), (2, 2));
+        assert_eq!(get.times, vec![1700200000, 1700000000]);
+
+        // Put changed in all three commits, by two authors
+        let put = &churn["store.go:Put"];
+        assert_eq!((put.commits, put.authors), (3, 2));
+        assert_eq!(put.last_changed(), Some(1700200000));
+
+        // New has not changed since the file was created
+        assert_eq!(churn["store.go:New"].times, vec![1700000000]);
+    }
+}
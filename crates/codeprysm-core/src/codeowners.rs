@@ -0,0 +1,243 @@
+//! CODEOWNERS Ownership Overlay
+//!
+//! Parses a repository's `CODEOWNERS` file and attaches the owning teams or
+//! users to every node as `metadata.owners`. Rules follow GitHub semantics:
+//!
+//! - Patterns use gitignore syntax, anchored to the repository root
+//! - The last matching rule wins
+//! - A pattern without owners explicitly leaves matching files unowned
+//!
+//! The file is looked up in the same locations GitHub uses, in order:
+//! `.github/CODEOWNERS`, `CODEOWNERS`, `docs/CODEOWNERS`.
+
+use std::path::{Path, PathBuf};
+
+use ignore::gitignore::{Gitignore, GitignoreBuilder};
+use tracing::warn;
+
+use crate::graph::PetCodeGraph;
+
+/// Locations searched for a CODEOWNERS file, relative to the repository root
+pub const CODEOWNERS_LOCATIONS: &[&str] = &[".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"];
+
+/// One CODEOWNERS rule
+#[derive(Debug, Clone)]
+struct Rule {
+    /// Pattern as written
+    pattern: String,
+    /// Owners (teams, users, or emails); empty means explicitly unowned
+    owners: Vec<String>,
+    /// Compiled pattern
+    matcher: Gitignore,
+}
+
+/// Parsed CODEOWNERS rules.
+#[derive(Debug, Clone, Default)]
+pub struct CodeOwners {
+    rules: Vec<Rule>,
+}
+
+impl CodeOwners {
+    /// Parse CODEOWNERS content.
+    ///
+    /// Lines with invalid patterns are skipped with a warning, matching
+    /// GitHub's behavior of ignoring them.
+    pub fn parse(content: &str) -> Self {
+        let mut rules = Vec::new();
+
+        for (index, line) in content.lines().enumerate() {
+            let line = line.trim();
+            if line.is_empty() || line.starts_with('#') {
+                continue;
+            }
+            // Trailing comments
+            let line = match line.find(" #") {
+                Some(pos) => &line[..pos],
+                None => line,
+            };
+
+            let mut parts = line.split_whitespace();
+            let Some(pattern) = parts.next() else {
+                continue;
+            };
+            let owners: Vec<String> = parts.map(str::to_string).collect();
+
+            let mut builder = GitignoreBuilder::new("");
+            let matcher = builder
+                .add_line(None, pattern)
+                .map_err(|e| e.to_string())
+                .and_then(|b| b.build().map_err(|e| e.to_string()));
+            match matcher {
+                Ok(matcher) => rules.push(Rule {
+                    pattern: pattern.to_string(),
+                    owners,
+                    matcher,
+                }),
+                Err(e) => warn!("CODEOWNERS line {}: invalid pattern: {}", index + 1, e),
+            }
+        }
+
+        Self { rules }
+    }
+
+    /// Find the CODEOWNERS file of a repository, if any
+    pub fn find(repo_root: &Path) -> Option<PathBuf> {
+        CODEOWNERS_LOCATIONS
+            .iter()
+            .map(|location| repo_root.join(location))
+            .find(|path| path.is_file())
+    }
+
+    /// Load the CODEOWNERS file of a repository, if any
+    pub fn load(repo_root: &Path) -> Option<Self> {
+        let path = Self::find(repo_root)?;
+        match std::fs::read_to_string(&path) {
+            Ok(content) => Some(Self::parse(&content)),
+            Err(e) => {
+                warn!("Failed to read {}: {}", path.display(), e);
+                None
+            }
+        }
+    }
+
+    /// Number of rules
+    pub fn len(&self) -> usize {
+        self.rules.len()
+    }
+
+    /// Check if there are no rules
+    pub fn is_empty(&self) -> bool {
+        self.rules.is_empty()
+    }
+
+    /// Owners of a file path relative to the repository root.
+    ///
+    /// Returns an empty slice for unowned files.
+    pub fn owners_of(&self, path: &str) -> &[String] {
+        match self.rule_for(path) {
+            Some(rule) => &rule.owners,
+            None => &[],
+        }
+    }
+
+    /// Pattern of the rule deciding a file's owners, if any matches
+    pub fn pattern_for(&self, path: &str) -> Option<&str> {
+        self.rule_for(path).map(|rule| rule.pattern.as_str())
+    }
+
+    /// Last rule matching a path
+    fn rule_for(&self, path: &str) -> Option<&Rule> {
+        let path = path.trim_start_matches("./");
+        self.rules.iter().rev().find(|rule| {
+            rule.matcher
+                .matched_path_or_any_parents(path, false)
+                .is_ignore()
+        })
+    }
+
+    /// Set `metadata.owners` on every node with a source file.
+    ///
+    /// Owners from a previous run are cleared first, so rules that no longer
+    /// match are not kept. Returns the number of owned nodes.
+    pub fn apply(&self, graph: &mut PetCodeGraph) -> usize {
+        let mut owned = 0;
+        for node in graph.inner_mut().node_weights_mut() {
+            node.metadata.owners = None;
+            if node.file.is_empty() {
+                continue;
+            }
+            let owners = self.owners_of(&node.file);
+            if !owners.is_empty() {
+                node.metadata.owners = Some(owners.to_vec());
+                owned += 1;
+            }
+        }
+        owned
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::{CallableKind, Node};
+
+    const SAMPLE: &str = "\
+# Default owners
+*                   @org/platform
+
+/api/               @org/api-team
+*.md                @org/docs        # documentation
+internal/billing/** @org/payments @alice
+/api/generated/
+";
+
+    #[test]
+    fn test_last_matching_rule_wins() {
+        let owners = CodeOwners::parse(SAMPLE);
+        assert_eq!(owners.len(), 5);
+
+        assert_eq!(owners.owners_of("main.go"), ["@org/platform"]);
+        assert_eq!(owners.owners_of("api/handler.go"), ["@org/api-team"]);
+        assert_eq!(owners.owners_of("api/README.md"), ["@org/docs"]);
+        assert_eq!(
+            owners.owners_of("internal/billing/invoice/tax.go"),
+            ["@org/payments", "@alice"]
+        );
+        // Anchored pattern does not match nested directories of the same name
+        assert_eq!(owners.owners_of("pkg/api/client.go"), ["@org/platform"]);
+        // Ownerless rule leaves generated code unowned
+        assert!(owners.owners_of("api/generated/types.go").is_empty());
+        assert_eq!(
+            owners.pattern_for("api/generated/types.go"),
+            Some("/api/generated/")
+        );
+    }
+
+    #[test]
+    fn test_apply_sets_node_owners() {
+        let mut graph = PetCodeGraph::new();
+        for (id, file) in [
+            ("api/handler.go:Serve", "api/handler.go"),
+            ("api/generated/types.go:Decode", "api/generated/types.go"),
+        ] {
+            graph.add_node(Node::callable(
+                id.to_string(),
+                "f".to_string(),
+                CallableKind::Function,
+                file.to_string(),
+                1,
+                3,
+            ));
+        }
+
+        let owners = CodeOwners::parse(SAMPLE);
+        assert_eq!(owners.apply(&mut graph), 1);
+        assert_eq!(
+            graph
+                .get_node("api/handler.go:Serve")
+                .unwrap()
+                .metadata
+                .owners,
+            Some(vec!["@org/api-team".to_string()])
+        );
+        assert_eq!(
+            graph
+                .get_node("api/generated/types.go:Decode")
+                .unwrap()
+                .metadata
+                .owners,
+            None
+        );
+
+        // Reapplying with fewer rules clears stale owners
+        assert_eq!(CodeOwners::default().apply(&mut graph), 0);
+        assert_eq!(
+            graph
+                .get_node("api/handler.go:Serve")
+                .unwrap()
+                .metadata
+                .owners,
+            None
+        );
+    }
+}
@@ -0,0 +1,394 @@
+//! Function Complexity Metrics
+//!
+//! Computes cyclomatic and cognitive complexity for a callable's body directly
+//! from its tree-sitter AST. Node kinds are matched by name across all
+//! supported grammars, so one walker serves every language.
+//!
+//! - **Cyclomatic**: 1 + number of decision points (branches, loops, non-default
+//!   cases, catch clauses, ternaries, and `&&`/`||` operators).
+//! - **Cognitive**: follows the SonarSource definition in simplified form:
+//!   branches and loops cost 1 plus their nesting depth, `else`/`else if` cost
+//!   1, each run of like logical operators costs 1, and lambdas deepen nesting.
+//!
+//! Nested named functions and classes are skipped; they receive their own
+//! metrics as separate nodes.
+
+use serde::{Deserialize, Serialize};
+use tree_sitter::Node;
+
+/// `if` statements and expressions
+pub(crate) const IF_KINDS: &[&str] = &["if_statement", "if_expression"];
+
+/// Loops
+pub(crate) const LOOP_KINDS: &[&str] = &[
+    "for_statement",
+    "for_in_statement",
+    "for_range_loop",
+    "foreach_statement",
+    "while_statement",
+    "do_statement",
+    "for_expression",
+    "while_expression",
+    "loop_expression",
+];
+
+/// Multi-way branches (their cases add to cyclomatic complexity)
+pub(crate) const SWITCH_KINDS: &[&str] = &[
+    "switch_statement",
+    "switch_expression",
+    "expression_switch_statement",
+    "type_switch_statement",
+    "select_statement",
+    "match_statement",
+    "match_expression",
+];
+
+/// Individual cases of a multi-way branch
+pub(crate) const CASE_KINDS: &[&str] = &[
+    "switch_case",
+    "case_statement",
+    "switch_section",
+    "switch_expression_arm",
+    "expression_case",
+    "type_case",
+    "communication_case",
+    "case_clause",
+    "match_arm",
+];
+
+/// Exception handlers
+pub(crate) const CATCH_KINDS: &[&str] = &["catch_clause", "except_clause"];
+
+/// Conditional (ternary) expressions
+pub(crate) const TERNARY_KINDS: &[&str] = &["conditional_expression", "ternary_expression"];
+
+/// Anonymous functions, which deepen nesting without adding complexity
+pub(crate) const LAMBDA_KINDS: &[&str] = &[
+    "lambda",
+    "lambda_expression",
+    "arrow_function",
+    "function_expression",
+    "closure_expression",
+    "func_literal",
+    "anonymous_method_expression",
+];
+
+/// Unstructured jumps
+const JUMP_KINDS: &[&str] = &["goto_statement"];
+
+/// Nested definitions measured separately
+pub(crate) const BOUNDARY_KINDS: &[&str] = &[
+    "function_definition",
+    "function_declaration",
+    "function_item",
+    "method_declaration",
+    "method_definition",
+    "local_function_statement",
+    "class_definition",
+    "class_declaration",
+];
+
+/// Binary operators that short-circuit
+const LOGICAL_OPERATORS: &[&str] = &["&&", "||", "??", "and", "or"];
+
+/// Complexity metrics for a single callable.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+pub struct Complexity {
+    /// Cyclomatic complexity (McCabe)
+    pub cyclomatic: u32,
+    /// Cognitive complexity
+    pub cognitive: u32,
+}
+
+/// Measure the complexity of a callable definition node.
+///
+/// Returns `None` when the definition has no body (e.g., interface method
+/// specifications, trait signatures, and prototypes).
+pub fn measure(definition: &Node, source: &[u8]) -> Option<Complexity> {
+    let body = function_body(definition)?;
+
+    let mut counter = Counter {
+        source,
+        cyclomatic: 1,
+        cognitive: 0,
+    };
+    counter.walk(body, 0);
+
+    Some(Complexity {
+        cyclomatic: counter.cyclomatic,
+        cognitive: counter.cognitive,
+    })
+}
+
+/// Locate the body of a callable definition.
+///
+/// Handles definitions that carry the body directly, variable declarators
+/// whose value is a function (`const f = () => {}`), and C/C++ declarators
+/// nested inside a `function_definition`.
+pub(crate) fn function_body<'t>(definition: &Node<'t>) -> Option<Node<'t>> {
+    if let Some(body) = definition.child_by_field_name("body") {
+        return Some(body);
+    }
+
+    if let Some(value) = definition.child_by_field_name("value") {
+        if let Some(body) = value.child_by_field_name("body") {
+            return Some(body);
+        }
+    }
+
+    if definition.kind().ends_with("declarator") {
+        let mut owner = definition.parent();
+        while let Some(node) = owner {
+            if !node.kind().ends_with("declarator") {
+                break;
+            }
+            owner = node.parent();
+        }
+        return owner?.child_by_field_name("body");
+    }
+
+    None
+}
+
+/// Accumulates complexity while walking a function body
+struct Counter<'s> {
+    source: &'s [u8],
+    cyclomatic: u32,
+    cognitive: u32,
+}
+
+impl<'s> Counter<'s> {
+    fn walk(&mut self, node: Node<'_>, nesting: u32) {
+        let kind = node.kind();
+
+        if BOUNDARY_KINDS.contains(&kind) {
+            return;
+        }
+
+        if IF_KINDS.contains(&kind) {
+            self.visit_if(node, nesting, false);
+            return;
+        }
+
+        let mut child_nesting = nesting;
+        if LOOP_KINDS.contains(&kind)
+            || CATCH_KINDS.contains(&kind)
+            || TERNARY_KINDS.contains(&kind)
+        {
+            self.cyclomatic += 1;
+            self.cognitive += 1 + nesting;
+            child_nesting += 1;
+        } else if SWITCH_KINDS.contains(&kind) {
+            self.cognitive += 1 + nesting;
+            child_nesting += 1;
+        } else if CASE_KINDS.contains(&kind) {
+            if !is_default_case(node, self.source) {
+                self.cyclomatic += 1;
+            }
+        } else if LAMBDA_KINDS.contains(&kind) {
+            child_nesting += 1;
+        } else if JUMP_KINDS.contains(&kind) {
+            self.cognitive += 1;
+        } else if let Some(operator) = self.logical_operator(node) {
+            self.cyclomatic += 1;
+            // `a && b && c` is one run; only its outermost operator counts
+            let continues_run = node
+                .parent()
+                .and_then(|parent| self.logical_operator(parent))
+                == Some(operator);
+            if !continues_run {
+                self.cognitive += 1;
+            }
+        }
+
+        let mut cursor = node.walk();
+        for child in node.named_children(&mut cursor) {
+            self.walk(child, child_nesting);
+        }
+    }
+
+    /// Visit an `if`, treating its alternatives as `else`/`else if` chains
+    fn visit_if(&mut self, node: Node<'_>, nesting: u32, is_else_if: bool) {
+        self.cyclomatic += 1;
+        self.cognitive += if is_else_if { 1 } else { 1 + nesting };
+
+        let mut cursor = node.walk();
+        let alternatives: Vec<usize> = node
+            .children_by_field_name("alternative", &mut cursor)
+            .map(|alt| alt.id())
+            .collect();
+
+        let mut cursor = node.walk();
+        for child in node.named_children(&mut cursor) {
+            if alternatives.contains(&child.id()) {
+                self.visit_else(child, nesting);
+            } else {
+                self.walk(child, nesting + 1);
+            }
+        }
+    }
+
+    /// Visit the alternative branch of an `if`
+    fn visit_else(&mut self, alternative: Node<'_>, nesting: u32) {
+        let kind = alternative.kind();
+
+        // Go and C# chain `else if` directly as the alternative
+        if IF_KINDS.contains(&kind) {
+            self.visit_if(alternative, nesting, true);
+            return;
+        }
+
+        let mut cursor = alternative.walk();
+        let children: Vec<Node<'_>> = alternative.named_children(&mut cursor).collect();
+
+        match kind {
+            // Python `elif`
+            "elif_clause" => {
+                self.cyclomatic += 1;
+                self.cognitive += 1;
+                for child in children {
+                    self.walk(child, nesting + 1);
+                }
+            }
+            // `else if` wrapped in an else clause (JS, Rust, C)
+            "else_clause" if children.len() == 1 && IF_KINDS.contains(&children[0].kind()) => {
+                self.visit_if(children[0], nesting, true);
+            }
+            "else_clause" => {
+                self.cognitive += 1;
+                for child in children {
+                    self.walk(child, nesting + 1);
+                }
+            }
+            // Plain `else` block
+            _ => {
+                self.cognitive += 1;
+                self.walk(alternative, nesting + 1);
+            }
+        }
+    }
+
+    /// Get the short-circuit operator of a logical binary expression
+    fn logical_operator(&self, node: Node<'_>) -> Option<&'s str> {
+        if !matches!(node.kind(), "binary_expression" | "boolean_operator") {
+            return None;
+        }
+        let operator = node
+            .child_by_field_name("operator")?
+            .utf8_text(self.source)
+            .ok()?;
+        LOGICAL_OPERATORS.contains(&operator).then_some(operator)
+    }
+}
+
+/// Check whether a case is the default/catch-all branch
+pub(crate) fn is_default_case(node: Node<'_>, source: &[u8]) -> bool {
+    let text = node.utf8_text(source).unwrap_or("").trim_start();
+    if text.starts_with("default") || text.starts_with("case _:") {
+        return true;
+    }
+    node.child_by_field_name("pattern")
+        .and_then(|p| p.utf8_text(source).ok())
+        .is_some_and(|p| p.trim() == "_")
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::parser::{CodeParser, SupportedLanguage};
+
+    /// Parse `source` and measure the first definition of the given kind
+    fn measure_first(language: SupportedLanguage, source: &str, kind: &str) -> Option<Complexity> {
+        let mut parser = CodeParser::new(language).unwrap();
+        let tree = parser.parse(source).unwrap();
+        let root = tree.root_node();
+        let mut cursor = root.walk();
+        let definition = root
+            .named_children(&mut cursor)
+            .find(|n| n.kind() == kind)
+            .expect("definition not found");
+        measure(&definition, source.as_bytes())
+    }
+
+    #[test]
+    fn test_go_complexity() {
+        let source = r#"package main
+
+func f(a, b int) int {
+	if a > 0 && b > 0 {
+		for i := 0; i < a; i++ {
+			if i == b {
+				return i
+			}
+		}
+	} else if a < 0 {
+		return -1
+	} else {
+		return 0
+	}
+	switch b {
+	case 1:
+		return 1
+	case 2:
+		return 2
+	default:
+	}
+	return a
+}
+"#;
+        let metrics = measure_first(SupportedLanguage::Go, source, "function_declaration").unwrap();
+        assert_eq!(metrics.cyclomatic, 8);
+        assert_eq!(metrics.cognitive, 10);
+    }
+
+    #[test]
+    fn test_python_complexity() {
+        let source = r#"
+def g(x, y, z):
+    if x and y or z:
+        return [i for i in x]
+    elif x:
+        pass
+    else:
+        pass
+    try:
+        while x:
+            x -= 1
+    except ValueError:
+        pass
+    return (lambda: 1 if x else 2)()
+"#;
+        let metrics =
+            measure_first(SupportedLanguage::Python, source, "function_definition").unwrap();
+        assert_eq!(metrics.cyclomatic, 8);
+        assert_eq!(metrics.cognitive, 9);
+    }
+
+    #[test]
+    fn test_straight_line_and_bodiless_functions() {
+        let source = "fn simple() -> i32 { 1 + 2 }\n";
+        let metrics = measure_first(SupportedLanguage::Rust, source, "function_item").unwrap();
+        assert_eq!(
+            metrics,
+            Complexity {
+                cyclomatic: 1,
+                cognitive: 0
+            }
+        );
+
+        let source = "package main\n\ntype Reader interface {\n\tRead() error\n}\n";
+        let mut parser = CodeParser::new(SupportedLanguage::Go).unwrap();
+        let tree = parser.parse(source).unwrap();
+        let mut stack = vec![tree.root_node()];
+        let mut method_elem = None;
+        while let Some(node) = stack.pop() {
+            if node.kind() == "method_elem" {
+                method_elem = Some(node);
+                break;
+            }
+            let mut cursor = node.walk();
+            stack.extend(node.named_children(&mut cursor));
+        }
+        assert!(measure(&method_elem.unwrap(), source.as_bytes()).is_none());
+    }
+}
@@ -0,0 +1,299 @@
+//! Control-Flow Summaries
+//!
+//! Summarizes the shape of a callable's body from its tree-sitter AST: how
+//! many branches and loops it has, how often it returns before its last
+//! statement, how deeply its control structures nest, and whether it
+//! dispatches with a `switch`/`match` or waits on a Go `select`. The summary
+//! is stored on the callable (`control_flow`), giving metrics and language
+//! model consumers the structure of a function without a separate CFG tool.
+//!
+//! Node kinds are shared with the complexity walker (see
+//! `crate::complexity`), so the same grammars are covered and nested named
+//! functions and classes are likewise left to their own nodes. Anonymous
+//! functions are part of the body, but their `return`s are not early
+//! returns of the enclosing callable.
+
+use serde::{Deserialize, Serialize};
+use tree_sitter::Node;
+
+use crate::complexity::{
+    function_body, is_default_case, BOUNDARY_KINDS, CASE_KINDS, CATCH_KINDS, IF_KINDS,
+    LAMBDA_KINDS, LOOP_KINDS, SWITCH_KINDS, TERNARY_KINDS,
+};
+
+/// `return` statements and expressions
+const RETURN_KINDS: &[&str] = &["return_statement", "return_expression"];
+
+/// Go `select` statements
+const SELECT_KINDS: &[&str] = &["select_statement"];
+
+/// Comments, which do not count as the last statement of a body
+const COMMENT_KINDS: &[&str] = &["comment", "line_comment", "block_comment"];
+
+/// Control-flow summary of a single callable.
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, Serialize, Deserialize)]
+pub struct ControlFlow {
+    /// Conditional branches: `if`/`else if`, ternaries, and non-default
+    /// cases
+    pub branches: u32,
+    /// Loops
+    pub loops: u32,
+    /// `return`s other than the body's last statement
+    pub early_returns: u32,
+    /// Deepest nesting of branches, loops, switches, and exception handlers
+    /// (0 for straight-line code)
+    pub max_nesting: u32,
+    /// Whether the body has a `switch` or `match`
+    pub has_switch: bool,
+    /// Whether the body has a Go `select`
+    pub has_select: bool,
+}
+
+/// Summarize the control flow of a callable definition node.
+///
+/// Returns `None` when the definition has no body (e.g., interface method
+/// specifications, trait signatures, and prototypes).
+pub fn summarize(definition: &Node, source: &[u8]) -> Option<ControlFlow> {
+    let body = function_body(definition)?;
+
+    let mut walker = Walker {
+        source,
+        summary: ControlFlow::default(),
+        returns: 0,
+    };
+    walker.walk(body, 0, false);
+
+    let mut summary = walker.summary;
+    summary.early_returns = walker
+        .returns
+        .saturating_sub(u32::from(ends_with_return(body)));
+    Some(summary)
+}
+
+/// Check whether the last statement of a body is a `return`
+fn ends_with_return(body: Node<'_>) -> bool {
+    let mut last = Some(body);
+    while let Some(node) = last {
+        if RETURN_KINDS.contains(&node.kind()) {
+            return true;
+        }
+        // Descend through statement lists and expression statements
+        if !matches!(
+            node.kind(),
+            "block" | "statement_block" | "statement_list" | "expression_statement"
+        ) && node.id() != body.id()
+        {
+            return false;
+        }
+        let mut cursor = node.walk();
+        last = node
+            .named_children(&mut cursor)
+            .filter(|child| !COMMENT_KINDS.contains(&child.kind()))
+            .last();
+    }
+    false
+}
+
+/// Accumulates the summary while walking a function body
+struct Walker<'s> {
+    source: &'s [u8],
+    summary: ControlFlow,
+    /// All `return`s outside anonymous functions
+    returns: u32,
+}
+
+impl Walker<'_> {
+    fn walk(&mut self, node: Node<'_>, nesting: u32, in_lambda: bool) {
+        let kind = node.kind();
+
+        if BOUNDARY_KINDS.contains(&kind) {
+            return;
+        }
+
+        if IF_KINDS.contains(&kind) {
+            self.visit_if(node, nesting, in_lambda);
+            return;
+        }
+
+        let mut child_nesting = nesting;
+        let mut child_in_lambda = in_lambda;
+        if LOOP_KINDS.contains(&kind) {
+            self.summary.loops += 1;
+            child_nesting = self.enter(nesting);
+        } else if SELECT_KINDS.contains(&kind) {
+            self.summary.has_select = true;
+            child_nesting = self.enter(nesting);
+        } else if SWITCH_KINDS.contains(&kind) {
+            self.summary.has_switch = true;
+            child_nesting = self.enter(nesting);
+        } else if CATCH_KINDS.contains(&kind) {
+            child_nesting = self.enter(nesting);
+        } else if CASE_KINDS.contains(&kind) {
+            if !is_default_case(node, self.source) {
+                self.summary.branches += 1;
+            }
+        } else if TERNARY_KINDS.contains(&kind) {
+            self.summary.branches += 1;
+        } else if LAMBDA_KINDS.contains(&kind) {
+            child_in_lambda = true;
+        } else if RETURN_KINDS.contains(&kind) && !in_lambda {
+            self.returns += 1;
+        }
+
+        let mut cursor = node.walk();
+        for child in node.named_children(&mut cursor) {
+            self.walk(child, child_nesting, child_in_lambda);
+        }
+    }
+
+    /// Record entering a control structure at `nesting`; returns the
+    /// nesting of its contents
+    fn enter(&mut self, nesting: u32) -> u32 {
+        self.summary.max_nesting = self.summary.max_nesting.max(nesting + 1);
+        nesting + 1
+    }
+
+    /// Visit an `if`, keeping `else if` chains at the same nesting
+    fn visit_if(&mut self, node: Node<'_>, nesting: u32, in_lambda: bool) {
+        self.summary.branches += 1;
+        let inner = self.enter(nesting);
+
+        let mut cursor = node.walk();
+        let alternatives: Vec<usize> = node
+            .children_by_field_name("alternative", &mut cursor)
+            .map(|alt| alt.id())
+            .collect();
+
+        let mut cursor = node.walk();
+        for child in node.named_children(&mut cursor) {
+            if !alternatives.contains(&child.id()) {
+                self.walk(child, inner, in_lambda);
+                continue;
+            }
+
+            // Go and C# chain `else if` directly as the alternative
+            if IF_KINDS.contains(&child.kind()) {
+                self.visit_if(child, nesting, in_lambda);
+                continue;
+            }
+            let mut cursor = child.walk();
+            let children: Vec<Node<'_>> = child.named_children(&mut cursor).collect();
+            match child.kind() {
+                // Python `elif`
+                "elif_clause" => {
+                    self.summary.branches += 1;
+                    for grandchild in children {
+                        self.walk(grandchild, inner, in_lambda);
+                    }
+                }
+                // `else if` wrapped in an else clause (JS, Rust, C)
+                "else_clause" if children.len() == 1 && IF_KINDS.contains(&children[0].kind()) => {
+                    self.visit_if(children[0], nesting, in_lambda);
+                }
+                // Plain `else`
+                _ => self.walk(child, inner, in_lambda),
+            }
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::parser::{CodeParser, SupportedLanguage};
+
+    /// Parse `source` and summarize the first definition of the given kind
+    fn summarize_first(
+        language: SupportedLanguage,
+        source: &str,
+        kind: &str,
+    ) -> Option<ControlFlow> {
+        let mut parser = CodeParser::new(language).unwrap();
+        let tree = parser.parse(source).unwrap();
+        let root = tree.root_node();
+        let mut cursor = root.walk();
+        let definition = root
+            .named_children(&mut cursor)
+            .find(|n| n.kind() == kind)
+            .expect("definition not found");
+        summarize(&definition, source.as_bytes())
+    }
+
+    #[test]
+    fn test_go_control_flow() {
+        let source = r#"package main
+
+func f(ctx context.Context, ch chan int) int {
+	if ctx == nil {
+		return 0
+	}
+	go func() {
+		return
+	}()
+	for {
+		select {
+		case v := <-ch:
+			if v > 0 {
+				return v
+			}
+		case <-ctx.Done():
+			return -1
+		default:
+		}
+	}
+}
+"#;
+        let summary = summarize_first(SupportedLanguage::Go, source, "function_declaration");
+        assert_eq!(
+            summary,
+            Some(ControlFlow {
+                branches: 4,
+                loops: 1,
+                early_returns: 3,
+                max_nesting: 3,
+                has_switch: false,
+                has_select: true,
+            })
+        );
+    }
+
+    #[test]
+    fn test_python_control_flow() {
+        let source = r#"
+def g(x):
+    if x:
+        return 1
+    elif x is None:
+        pass
+    else:
+        for i in x:
+            while i:
+                i -= 1
+    match x:
+        case 1:
+            pass
+        case _:
+            pass
+    return 2
+"#;
+        let summary = summarize_first(SupportedLanguage::Python, source, "function_definition");
+        assert_eq!(
+            summary,
+            Some(ControlFlow {
+                branches: 3,
+                loops: 2,
+                early_returns: 1,
+                max_nesting: 3,
+                has_switch: true,
+                has_select: false,
+            })
+        );
+    }
+
+    #[test]
+    fn test_straight_line_function() {
+        let source = "fn simple() -> i32 {\n    return 1 + 2;\n}\n";
+        let summary = summarize_first(SupportedLanguage::Rust, source, "function_item");
+        assert_eq!(summary, Some(ControlFlow::default()));
+    }
+}
@@ -0,0 +1,261 @@
+//! Go Coverage Overlay
+//!
+//! Parses Go cover profiles (`go test -coverprofile=coverage.out`) and
+//! annotates callable nodes with covered and total statement counts as
+//! `metadata.covered_statements` and `metadata.total_statements`.
+//!
+//! Profile entries name files by import path (e.g.,
+//! `github.com/org/repo/pkg/store.go`), so each profile file is matched to the
+//! longest indexed file path it ends with. A block belongs to the callable
+//! whose line span contains the block's start line.
+
+use std::collections::{BTreeMap, HashMap};
+use std::path::Path;
+
+use thiserror::Error;
+
+use crate::graph::{NodeType, PetCodeGraph};
+
+/// Errors that can occur while reading a cover profile.
+#[derive(Debug, Error)]
+pub enum CoverageError {
+    /// Failed to read the profile
+    #[error("Failed to read cover profile: {0}")]
+    Io(#[from] std::io::Error),
+
+    /// Malformed profile line
+    #[error("Invalid cover profile line {line}: {message}")]
+    Parse { line: usize, message: String },
+}
+
+/// One coverage block: a run of statements and how often it executed.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub struct CoverBlock {
+    /// First line of the block (1-indexed)
+    pub start_line: usize,
+    /// Last line of the block (1-indexed)
+    pub end_line: usize,
+    /// Number of statements in the block
+    pub statements: u32,
+    /// Execution count (0 or 1 in "set" mode)
+    pub count: u64,
+}
+
+/// Parsed cover profile, keyed by profile file name.
+#[derive(Debug, Clone, Default)]
+pub struct CoverProfile {
+    /// Coverage mode ("set", "count", or "atomic")
+    pub mode: String,
+    /// Blocks per file, sorted by start line
+    pub files: BTreeMap<String, Vec<CoverBlock>>,
+}
+
+/// Block position within a file, used to merge duplicate entries
+type BlockKey = (usize, usize, usize, usize);
+
+impl CoverProfile {
+    /// Parse cover profile content.
+    ///
+    /// Several profiles concatenated together (e.g., from `-coverpkg` runs)
+    /// are accepted: repeated `mode:` lines are skipped and duplicate blocks
+    /// are merged, keeping the highest count.
+    pub fn parse(content: &str) -> Result<Self, CoverageError> {
+        let mut mode = String::new();
+        let mut blocks: BTreeMap<String, BTreeMap<BlockKey, CoverBlock>> = BTreeMap::new();
+
+        for (index, line) in content.lines().enumerate() {
+            let line = line.trim();
+            if line.is_empty() {
+                continue;
+            }
+            if let Some(value) = line.strip_prefix("mode:") {
+                mode = value.trim().to_string();
+                continue;
+            }
+
+            let error = |message: &str| CoverageError::Parse {
+                line: index + 1,
+                message: message.to_string(),
+            };
+
+            // name.go:12.34,15.2 3 1
+            let (file, rest) = line
+                .rsplit_once(':')
+                .ok_or_else(|| error("missing file name"))?;
+            let mut fields = rest.split_whitespace();
+            let (span, statements, count) = match (fields.next(), fields.next(), fields.next()) {
+                (Some(span), Some(statements), Some(count)) => (span, statements, count),
+                _ => return Err(error("expected span, statement count, and hit count")),
+            };
+            let (start, end) = span
+                .split_once(',')
+                .ok_or_else(|| error("invalid block span"))?;
+            let (start_line, start_col) = position(start).ok_or_else(|| error("invalid start"))?;
+            let (end_line, end_col) = position(end).ok_or_else(|| error("invalid end"))?;
+            let statements: u32 = statements
+                .parse()
+                .map_err(|_| error("invalid statement count"))?;
+            let count: u64 = count.parse().map_err(|_| error("invalid hit count"))?;
+
+            let block = blocks
+                .entry(file.to_string())
+                .or_default()
+                .entry((start_line, start_col, end_line, end_col))
+                .or_insert(CoverBlock {
+                    start_line,
+                    end_line,
+                    statements,
+                    count: 0,
+                });
+            block.count = block.count.max(count);
+        }
+
+        let files = blocks
+            .into_iter()
+            .map(|(file, blocks)| (file, blocks.into_values().collect()))
+            .collect();
+        Ok(Self { mode, files })
+    }
+
+    /// Read and parse a cover profile
+    pub fn load(path: &Path) -> Result<Self, CoverageError> {
+        Self::parse(&std::fs::read_to_string(path)?)
+    }
+
+    /// Annotate callables with covered/total statement counts.
+    ///
+    /// Annotations from a previous profile are cleared first. Callables in
+    /// files missing from the profile, or without statements, are left
+    /// unannotated. Returns the number of annotated callables.
+    pub fn apply(&self, graph: &mut PetCodeGraph) -> usize {
+        let files = self.match_files(graph);
+
+        let mut annotated = 0;
+        for node in graph.inner_mut().node_weights_mut() {
+            node.metadata.covered_statements = None;
+            node.metadata.total_statements = None;
+            if node.node_type != NodeType::Callable {
+                continue;
+            }
+            let Some(blocks) = files.get(node.file.as_str()) else {
+                continue;
+            };
+
+            let (mut covered, mut total) = (0, 0);
+            for block in blocks
+                .iter()
+                .filter(|b| b.start_line >= node.line && b.start_line <= node.end_line)
+            {
+                total += block.statements;
+                if block.count > 0 {
+                    covered += block.statements;
+                }
+            }
+            if total > 0 {
+                node.metadata.covered_statements = Some(covered);
+                node.metadata.total_statements = Some(total);
+                annotated += 1;
+            }
+        }
+        annotated
+    }
+
+    /// Map indexed files to their profile blocks
+    fn match_files(&self, graph: &PetCodeGraph) -> HashMap<String, &[CoverBlock]> {
+        let mut indexed: Vec<&str> = graph
+            .iter_nodes()
+            .map(|n| n.file.as_str())
+            .filter(|f| !f.is_empty())
+            .collect();
+        indexed.sort_unstable();
+        indexed.dedup();
+        // Longest paths first, so the most specific suffix wins
+        indexed.sort_by_key(|f| std::cmp::Reverse(f.len()));
+
+        let mut files = HashMap::new();
+        for (name, blocks) in &self.files {
+            let matched = indexed.iter().find(|file| {
+                name.as_str() == **file
+                    || name
+                        .strip_suffix(**file)
+                        .is_some_and(|prefix| prefix.ends_with('/'))
+            });
+            if let Some(file) = matched {
+                files.insert(file.to_string(), blocks.as_slice());
+            }
+        }
+        files
+    }
+}
+
+/// Parse a "line.column" position
+fn position(text: &str) -> Option<(usize, usize)> {
+    let (line, column) = text.split_once('.')?;
+    Some((line.parse().ok()?, column.parse().ok()?))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::{CallableKind, Node};
+
+    const PROFILE: &str = "\
+mode: set
+github.com/acme/shop/store/db.go:10.30,12.16 2 1
+github.com/acme/shop/store/db.go:12.16,14.3 1 0
+github.com/acme/shop/store/db.go:15.2,15.12 1 1
+github.com/acme/shop/store/db.go:20.25,22.2 2 0
+mode: set
+github.com/acme/shop/store/db.go:12.16,14.3 1 1
+";
+
+    #[test]
+    fn test_parse_merges_duplicate_blocks() {
+        let profile = CoverProfile::parse(PROFILE).unwrap();
+        assert_eq!(profile.mode, "set");
+
+        let blocks = &profile.files["github.com/acme/shop/store/db.go"];
+        assert_eq!(blocks.len(), 4);
+        assert_eq!(blocks[1].start_line, 12);
+        assert_eq!(blocks[1].count, 1);
+
+        let err = CoverProfile::parse("mode: set\nstore/db.go:10.30 2 1\n").unwrap_err();
+        assert!(matches!(err, CoverageError::Parse { line: 2, .. }));
+    }
+
+    #[test]
+    fn test_apply_annotates_callables() {
+        let mut graph = PetCodeGraph::new();
+        for (id, line, end_line) in [
+            ("store/db.go:Query", 10, 16),
+            ("store/db.go:Close", 20, 22),
+            ("db.go:Unrelated", 10, 16),
+        ] {
+            graph.add_node(Node::callable(
+                id.to_string(),
+                id.rsplit(':').next().unwrap().to_string(),
+                CallableKind::Function,
+                id.split(':').next().unwrap().to_string(),
+                line,
+                end_line,
+            ));
+        }
+
+        let profile = CoverProfile::parse(PROFILE).unwrap();
+        assert_eq!(profile.apply(&mut graph), 2);
+
+        let query = &graph.get_node("store/db.go:Query").unwrap().metadata;
+        assert_eq!(
+            (query.covered_statements, query.total_statements),
+            (Some(4), Some(4))
+        );
+        let close = &graph.get_node("store/db.go:Close").unwrap().metadata;
+        assert_eq!(
+            (close.covered_statements, close.total_statements),
+            (Some(0), Some(2))
+        );
+        // Shorter root-level path loses to the more specific match
+        let unrelated = &graph.get_node("db.go:Unrelated").unwrap().metadata;
+        assert_eq!(unrelated.total_statements, None);
+    }
+}
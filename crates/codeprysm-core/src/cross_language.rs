@@ -0,0 +1,1188 @@
+//! Cross-Language Linking
+//!
+//! Connects entities across language boundaries with CROSS_LANGUAGE edges,
+//! so a polyglot repository is one graph rather than one graph per
+//! language:
+//!
+//! - cgo: a Go function using `C.name` links to the C function, type, or
+//!   global of that name (`C.struct_name`, `C.union_name`, and
+//!   `C.enum_name` to the tagged type), and a C function calling a Go
+//!   function exported with `//export` links to it
+//! - protobuf: `.proto` files become File nodes with language `proto`,
+//!   holding their messages, enums, and services (Containers), rpcs
+//!   (Callables), and fields and enum values (Data). Go code generated from
+//!   them (`.pb.go` files naming their `// source:`) links to what it was
+//!   generated from: message and enum types, their fields, the client and
+//!   server interfaces of services, and their methods
+//!
+//! Edges point from the entity on the using or generated side. cgo edges
+//! carry the line and name of the reference; protobuf edges carry the fully
+//! qualified name of the definition.
+//!
+//! The analysis is textual. A C name is looked up in the Go package's
+//! directory first, then preferring source files over headers anywhere in
+//! the repository; names without a C definition in the repository (libc,
+//! system headers) are not linked.
+
+use std::cmp::Reverse;
+use std::collections::{BTreeSet, HashMap, HashSet};
+use std::path::Path;
+
+use crate::analysis::package_of;
+use crate::file_policy::is_test_file;
+use crate::graph::{
+    CallableKind, ContainerKind, DataKind, Edge, EdgeData, Node, NodeMetadata, NodeType,
+    PetCodeGraph,
+};
+use crate::merkle::compute_content_hash;
+use crate::parser::SupportedLanguage;
+
+/// Language recorded on `.proto` File nodes
+pub const PROTO_LANGUAGE: &str = "proto";
+
+/// cgo helpers that are not C symbols
+const CGO_HELPERS: &[&str] = &["CString", "CBytes", "GoString", "GoStringN", "GoBytes"];
+
+/// Prefixes cgo gives to tagged C types
+const CGO_TAG_PREFIXES: &[&str] = &["struct_", "union_", "enum_"];
+
+/// Check if a path names a protocol buffers definition file.
+pub fn is_proto_path(path: &Path) -> bool {
+    path.extension().is_some_and(|ext| ext == "proto")
+}
+
+/// Result of cross-language linking.
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq)]
+pub struct CrossLanguageLinks {
+    /// `.proto` File nodes added
+    pub proto_files: usize,
+    /// CROSS_LANGUAGE edges between Go and C
+    pub cgo: usize,
+    /// CROSS_LANGUAGE edges from generated Go to `.proto` definitions
+    pub protobuf: usize,
+}
+
+/// Add `.proto` files to a graph built from `directory` and link entities
+/// across languages.
+///
+/// `proto_files` are the `.proto` files found in the repository (relative
+/// to `directory`). Their File nodes are contained in the `repo_name` node,
+/// if any.
+pub fn link_cross_language(
+    graph: &mut PetCodeGraph,
+    directory: &Path,
+    repo_name: &str,
+    proto_files: &[String],
+) -> CrossLanguageLinks {
+    let mut links = CrossLanguageLinks::default();
+
+    let mut protos: HashMap<&str, ProtoFile> = HashMap::new();
+    for file in proto_files {
+        let Ok(source) = std::fs::read_to_string(directory.join(file)) else {
+            continue;
+        };
+        let proto = ProtoFile::parse(&source);
+        if !graph.contains_node(file) {
+            add_proto_nodes(graph, repo_name, file, &source, &proto);
+            links.proto_files += 1;
+        }
+        protos.insert(file.as_str(), proto);
+    }
+
+    let go_files: Vec<(String, String)> = graph
+        .iter_nodes()
+        .filter(|n| n.is_file() && language_of(&n.file) == Some(SupportedLanguage::Go))
+        .filter_map(|n| {
+            let source = std::fs::read_to_string(directory.join(&n.file)).ok()?;
+            Some((n.file.clone(), source))
+        })
+        .collect();
+
+    let mut edges: Vec<Edge> = Vec::new();
+    let index = SymbolIndex::new(graph);
+    for (file, source) in &go_files {
+        if let Some(proto_file) = generated_from(source)
+            .and_then(|source| find_proto(protos.keys().copied(), file, source))
+        {
+            let found = link_generated(graph, file, proto_file, &protos[proto_file]);
+            links.protobuf += found.len();
+            edges.extend(found);
+        }
+        if imports_c(source) {
+            let found = dedupe(link_cgo(&index, directory, file, source));
+            links.cgo += found.len();
+            edges.extend(found);
+        }
+    }
+
+    for edge in &edges {
+        graph.add_edge(&edge.source, &edge.target, EdgeData::from(edge));
+    }
+    links
+}
+
+/// Drop repeated references between the same nodes on the same line
+fn dedupe(edges: Vec<Edge>) -> Vec<Edge> {
+    let mut seen = HashSet::new();
+    edges
+        .into_iter()
+        .filter(|e| seen.insert((e.source.clone(), e.target.clone(), e.ref_line)))
+        .collect()
+}
+
+/// Language of a file, from its extension
+fn language_of(file: &str) -> Option<SupportedLanguage> {
+    SupportedLanguage::from_path(Path::new(file))
+}
+
+/// Check if a file is C or C++
+fn is_c_family(file: &str) -> bool {
+    matches!(
+        language_of(file),
+        Some(SupportedLanguage::C | SupportedLanguage::Cpp)
+    )
+}
+
+/// Check if a C or C++ file is a header
+fn is_header(file: &str) -> bool {
+    Path::new(file)
+        .extension()
+        .and_then(|ext| ext.to_str())
+        .is_some_and(|ext| matches!(ext, "h" | "hh" | "hpp" | "hxx"))
+}
+
+fn is_ident_char(c: char) -> bool {
+    c.is_ascii_alphanumeric() || c == '_'
+}
+
+// ============================================================================
+// cgo
+// ============================================================================
+
+/// Definitions by name, and the callables and containers of each file
+struct SymbolIndex<'g> {
+    /// C and C++ callables, types, and globals by name
+    c_symbols: HashMap<&'g str, Vec<&'g Node>>,
+    /// Callables and containers by file
+    scopes: HashMap<&'g str, Vec<&'g Node>>,
+}
+
+impl<'g> SymbolIndex<'g> {
+    fn new(graph: &'g PetCodeGraph) -> Self {
+        let mut c_symbols: HashMap<&str, Vec<&Node>> = HashMap::new();
+        let mut scopes: HashMap<&str, Vec<&Node>> = HashMap::new();
+        for node in graph.iter_nodes().filter(|n| !n.is_file()) {
+            let scope = matches!(node.node_type, NodeType::Callable | NodeType::Container);
+            if scope {
+                scopes.entry(node.file.as_str()).or_default().push(node);
+            }
+            let global = node.node_type == NodeType::Data
+                && matches!(node.kind.as_deref(), Some("value" | "constant"));
+            if is_c_family(&node.file) && (scope || global) {
+                c_symbols.entry(node.name.as_str()).or_default().push(node);
+            }
+        }
+        Self { c_symbols, scopes }
+    }
+
+    /// C definition a Go file's `C.name` refers to
+    fn c_symbol(&self, go_file: &str, name: &str) -> Option<&'g Node> {
+        let package = package_of(go_file);
+        self.c_symbols.get(name)?.iter().copied().min_by_key(|n| {
+            (
+                package_of(&n.file) != package,
+                is_header(&n.file),
+                Reverse(common_prefix(&n.file, go_file)),
+                n.id.as_str(),
+            )
+        })
+    }
+
+    /// Innermost callable or container of a file enclosing a line
+    fn enclosing(&self, file: &str, line: usize) -> Option<&'g Node> {
+        self.scopes
+            .get(file)?
+            .iter()
+            .filter(|n| n.line <= line && line <= n.end_line)
+            .min_by_key(|n| n.end_line - n.line)
+            .copied()
+    }
+
+    /// C function a line of a C file belongs to: the enclosing callable, or
+    /// the closest one declared above it, since C callables may span only
+    /// their declarator
+    fn c_function(&self, file: &str, line: usize) -> Option<&'g Node> {
+        let functions = || {
+            self.scopes
+                .get(file)
+                .into_iter()
+                .flatten()
+                .filter(|n| n.node_type == NodeType::Callable)
+        };
+        functions()
+            .filter(|n| n.line <= line && line <= n.end_line)
+            .min_by_key(|n| n.end_line - n.line)
+            .or_else(|| functions().filter(|n| n.line < line).max_by_key(|n| n.line))
+            .copied()
+    }
+}
+
+/// Length of the common directory prefix of two paths
+fn common_prefix(a: &str, b: &str) -> usize {
+    Path::new(package_of(a))
+        .components()
+        .zip(Path::new(package_of(b)).components())
+        .take_while(|(x, y)| x == y)
+        .count()
+}
+
+/// Check if a Go source file imports the C pseudo-package
+fn imports_c(source: &str) -> bool {
+    source.lines().any(|line| {
+        let line = line.trim();
+        line == "import \"C\"" || line == "\"C\""
+    })
+}
+
+/// CROSS_LANGUAGE edges of a cgo file: its uses of C names, and C calls to
+/// the functions it exports
+fn link_cgo(index: &SymbolIndex, directory: &Path, file: &str, source: &str) -> Vec<Edge> {
+    let mut edges = Vec::new();
+    let mut exports: Vec<&str> = Vec::new();
+    for (i, line) in source.lines().enumerate() {
+        let line_number = i + 1;
+        if let Some(name) = line.strip_prefix("//export ") {
+            exports.push(name.trim());
+            continue;
+        }
+        if line.trim_start().starts_with("//") {
+            continue;
+        }
+        for name in c_references(line) {
+            if CGO_HELPERS.contains(&name) {
+                continue;
+            }
+            let symbol = CGO_TAG_PREFIXES
+                .iter()
+                .find_map(|prefix| name.strip_prefix(prefix))
+                .unwrap_or(name);
+            let Some(target) = index.c_symbol(file, symbol) else {
+                continue;
+            };
+            let source_id = index
+                .enclosing(file, line_number)
+                .map_or(file, |n| n.id.as_str());
+            edges.push(Edge::cross_language(
+                source_id.to_string(),
+                target.id.clone(),
+                Some(line_number),
+                Some(format!("C.{}", name)),
+            ));
+        }
+    }
+    if exports.is_empty() {
+        return edges;
+    }
+
+    // C files of the package calling exported functions
+    let package = package_of(file);
+    let exported: HashMap<&str, &Node> = index
+        .scopes
+        .get(file)
+        .into_iter()
+        .flatten()
+        .filter(|n| n.node_type == NodeType::Callable && exports.contains(&n.name.as_str()))
+        .map(|n| (n.name.as_str(), *n))
+        .collect();
+    let c_files: BTreeSet<&str> = index
+        .scopes
+        .keys()
+        .copied()
+        .filter(|f| is_c_family(f) && package_of(f) == package)
+        .collect();
+    for c_file in c_files {
+        let Ok(c_source) = std::fs::read_to_string(directory.join(c_file)) else {
+            continue;
+        };
+        for (i, line) in c_source.lines().enumerate() {
+            for (name, target) in &exported {
+                if !calls(line, name) {
+                    continue;
+                }
+                // Skip prototypes, which are callables of the name itself
+                let Some(caller) = index
+                    .c_function(c_file, i + 1)
+                    .filter(|caller| caller.name != *name)
+                else {
+                    continue;
+                };
+                edges.push(Edge::cross_language(
+                    caller.id.clone(),
+                    target.id.clone(),
+                    Some(i + 1),
+                    Some(name.to_string()),
+                ));
+            }
+        }
+    }
+    edges
+}
+
+/// Names referenced as `C.name` on a line of Go code
+fn c_references(line: &str) -> Vec<&str> {
+    let mut names = Vec::new();
+    let mut rest = line;
+    let mut offset = 0;
+    while let Some(pos) = rest.find("C.") {
+        let start = offset + pos;
+        let before = line[..start].chars().next_back();
+        let name_start = start + 2;
+        let name_len = line[name_start..]
+            .find(|c: char| !is_ident_char(c))
+            .unwrap_or(line.len() - name_start);
+        if !before.is_some_and(|c| is_ident_char(c) || c == '.') && name_len > 0 {
+            names.push(&line[name_start..name_start + name_len]);
+        }
+        offset = name_start + name_len;
+        rest = &line[offset..];
+    }
+    names
+}
+
+/// Check if a line of C code calls a function
+fn calls(line: &str, name: &str) -> bool {
+    line.match_indices(name).any(|(pos, _)| {
+        let before = line[..pos].chars().next_back();
+        let after = line[pos + name.len()..].trim_start();
+        !before.is_some_and(is_ident_char) && after.starts_with('(')
+    })
+}
+
+// ============================================================================
+// Protocol buffers
+// ============================================================================
+
+/// `.proto` file a Go file was generated from, as named by its header
+fn generated_from(source: &str) -> Option<&str> {
+    source
+        .lines()
+        .take_while(|line| line.is_empty() || line.starts_with("//"))
+        .find_map(|line| line.strip_prefix("// source: "))
+        .map(str::trim)
+        .filter(|source| source.ends_with(".proto"))
+}
+
+/// Indexed `.proto` file a generated Go file names: the one whose path ends
+/// with the name, closest to the Go file
+fn find_proto<'a>(
+    protos: impl Iterator<Item = &'a str>,
+    go_file: &str,
+    source: &str,
+) -> Option<&'a str> {
+    protos
+        .filter(|p| *p == source || p.ends_with(&format!("/{}", source)))
+        .min_by_key(|p| (Reverse(common_prefix(p, go_file)), *p))
+}
+
+/// CROSS_LANGUAGE edges from a generated Go file to its `.proto` file
+fn link_generated(
+    graph: &PetCodeGraph,
+    go_file: &str,
+    proto_file: &str,
+    proto: &ProtoFile,
+) -> Vec<Edge> {
+    let mut edges = Vec::new();
+    let mut link = |source: &Node, entity: &ProtoEntity| {
+        edges.push(Edge::cross_language(
+            source.id.clone(),
+            entity.id(proto_file),
+            None,
+            Some(proto.full_name(entity)),
+        ));
+    };
+
+    let go_types: HashMap<&str, &Node> = graph
+        .iter_nodes()
+        .filter(|n| {
+            n.file == go_file
+                && n.node_type == NodeType::Container
+                && n.kind.as_deref() == Some(ContainerKind::Type.as_str())
+        })
+        .map(|n| (n.name.as_str(), n))
+        .collect();
+
+    for (i, entity) in proto.entities.iter().enumerate() {
+        let types: Vec<String> = match entity.kind {
+            ProtoKind::Message | ProtoKind::Enum => vec![entity.go_name()],
+            ProtoKind::Service => {
+                let name = go_camel_case(&entity.name);
+                vec![format!("{}Client", name), format!("{}Server", name)]
+            }
+            _ => continue,
+        };
+        for go_type in types.iter().filter_map(|t| go_types.get(t.as_str())) {
+            link(go_type, entity);
+
+            // Fields of messages, methods of service interfaces
+            let members: Vec<&ProtoEntity> = proto
+                .members(i)
+                .filter(|m| matches!(m.kind, ProtoKind::Field | ProtoKind::Rpc))
+                .collect();
+            for child in graph.children(&go_type.id) {
+                if let Some(member) = members.iter().find(|m| m.go_name() == child.name) {
+                    link(child, member);
+                }
+            }
+        }
+    }
+    edges
+}
+
+/// Add the File node of a `.proto` file and the nodes of its definitions
+fn add_proto_nodes(
+    graph: &mut PetCodeGraph,
+    repo_name: &str,
+    file: &str,
+    source: &str,
+    proto: &ProtoFile,
+) {
+    let node = Node::source_file(
+        file.to_string(),
+        file.to_string(),
+        compute_content_hash(source.as_bytes()),
+        source.lines().count(),
+    )
+    .with_metadata(NodeMetadata::new().with_file(
+        PROTO_LANGUAGE,
+        source.len() as u64,
+        false,
+        is_test_file(file),
+    ));
+    graph.add_node(node);
+    if !repo_name.is_empty() {
+        graph.add_edge_from_struct(&Edge::contains(repo_name.to_string(), file.to_string()));
+    }
+
+    for entity in &proto.entities {
+        let id = entity.id(file);
+        if graph.contains_node(&id) {
+            continue;
+        }
+        let (name, path, line, end_line) = (
+            entity.name.clone(),
+            file.to_string(),
+            entity.line,
+            entity.end_line,
+        );
+        let subtype = |s: &str| Some(s.to_string());
+        let node = match entity.kind {
+            ProtoKind::Message => Node::container(
+                id.clone(),
+                name,
+                ContainerKind::Type,
+                subtype("message"),
+                path,
+                line,
+                end_line,
+            ),
+            ProtoKind::Enum => Node::container(
+                id.clone(),
+                name,
+                ContainerKind::Type,
+                subtype("enum"),
+                path,
+                line,
+                end_line,
+            ),
+            ProtoKind::Service => Node::container(
+                id.clone(),
+                name,
+                ContainerKind::Type,
+                subtype("service"),
+                path,
+                line,
+                end_line,
+            ),
+            ProtoKind::Rpc => {
+                let mut node =
+                    Node::callable(id.clone(), name, CallableKind::Method, path, line, end_line);
+                node.metadata.receiver = entity.scope.last().cloned();
+                node
+            }
+            ProtoKind::Field => Node::data(
+                id.clone(),
+                name,
+                DataKind::Field,
+                None,
+                path,
+                line,
+                end_line,
+            ),
+            ProtoKind::EnumValue => Node::data(
+                id.clone(),
+                name,
+                DataKind::Constant,
+                None,
+                path,
+                line,
+                end_line,
+            ),
+        };
+        graph.add_node(node);
+
+        let parent = entity.parent_id(file);
+        graph.add_edge_from_struct(&Edge::contains(parent.clone(), id.clone()));
+        graph.add_edge_from_struct(&Edge::defined_in(id.clone(), file.to_string()));
+        if matches!(entity.kind, ProtoKind::Field | ProtoKind::EnumValue) {
+            graph.add_edge_from_struct(&Edge::defines(parent, id));
+        }
+    }
+}
+
+/// Kinds of `.proto` definitions
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+enum ProtoKind {
+    Message,
+    Enum,
+    EnumValue,
+    Service,
+    Rpc,
+    Field,
+}
+
+/// A definition in a `.proto` file
+#[derive(Debug, Clone, PartialEq, Eq)]
+struct ProtoEntity {
+    kind: ProtoKind,
+    name: String,
+    /// Names of the enclosing messages, enums, or services, outermost first
+    scope: Vec<String>,
+    line: usize,
+    end_line: usize,
+}
+
+impl ProtoEntity {
+    fn id(&self, file: &str) -> String {
+        let mut id = file.to_string();
+        for part in self.scope.iter().chain(std::iter::once(&self.name)) {
+            id.push(':');
+            id.push_str(part);
+        }
+        id
+    }
+
+    fn parent_id(&self, file: &str) -> String {
+        let mut id = file.to_string();
+        for part in &self.scope {
+            id.push(':');
+            id.push_str(part);
+        }
+        id
+    }
+
+    /// Name of the Go identifier protoc-gen-go generates
+    fn go_name(&self) -> String {
+        match self.kind {
+            ProtoKind::Message | ProtoKind::Enum => {
+                let mut path = self.scope.join(".");
+                if !path.is_empty() {
+                    path.push('.');
+                }
+                path.push_str(&self.name);
+                go_camel_case(&path)
+            }
+            _ => go_camel_case(&self.name),
+        }
+    }
+}
+
+/// Definitions of a `.proto` file
+#[derive(Debug, Clone, Default)]
+struct ProtoFile {
+    /// Package name
+    package: String,
+    /// Definitions, in source order
+    entities: Vec<ProtoEntity>,
+}
+
+impl ProtoFile {
+    fn parse(source: &str) -> Self {
+        let mut parser = ProtoParser {
+            tokens: tokenize(source),
+            pos: 0,
+            file: ProtoFile::default(),
+        };
+        parser.body(&[], None);
+        parser.file
+    }
+
+    /// Fully qualified name of a definition
+    fn full_name(&self, entity: &ProtoEntity) -> String {
+        let mut parts: Vec<&str> = Vec::new();
+        if !self.package.is_empty() {
+            parts.push(&self.package);
+        }
+        parts.extend(entity.scope.iter().map(String::as_str));
+        parts.push(&entity.name);
+        parts.join(".")
+    }
+
+    /// Direct members of the definition at `index`
+    fn members(&self, index: usize) -> impl Iterator<Item = &ProtoEntity> {
+        let parent = &self.entities[index];
+        let depth = parent.scope.len() + 1;
+        self.entities.iter().filter(move |e| {
+            e.scope.len() == depth
+                && e.scope[..depth - 1] == parent.scope[..]
+                && e.scope[depth - 1] == parent.name
+        })
+    }
+}
+
+/// A token of a `.proto` file
+#[derive(Debug, Clone)]
+struct Token {
+    text: String,
+    line: usize,
+}
+
+/// Split a `.proto` file into identifiers, literals, and punctuation,
+/// dropping comments
+fn tokenize(source: &str) -> Vec<Token> {
+    let mut tokens = Vec::new();
+    let chars: Vec<char> = source.chars().collect();
+    let (mut i, mut line) = (0, 1);
+    while i < chars.len() {
+        let c = chars[i];
+        if c == '\n' {
+            line += 1;
+            i += 1;
+        } else if c.is_whitespace() {
+            i += 1;
+        } else if c == '/' && chars.get(i + 1) == Some(&'/') {
+            while i < chars.len() && chars[i] != '\n' {
+                i += 1;
+            }
+        } else if c == '/' && chars.get(i + 1) == Some(&'*') {
+            i += 2;
+            while i < chars.len() && !(chars[i] == '*' && chars.get(i + 1) == Some(&'/')) {
+                if chars[i] == '\n' {
+                    line += 1;
+                }
+                i += 1;
+            }
+            i += 2;
+        } else if c == '"' || c == '\'' {
+            let start = i;
+            i += 1;
+            while i < chars.len() && chars[i] != c && chars[i] != '\n' {
+                if chars[i] == '\\' {
+                    i += 1;
+                }
+                i += 1;
+            }
+            i += 1;
+            let end = i.min(chars.len());
+            tokens.push(Token {
+                text: chars[start..end].iter().collect(),
+                line,
+            });
+        } else if is_ident_char(c) || c == '.' {
+            let start = i;
+            while i < chars.len() && (is_ident_char(chars[i]) || chars[i] == '.') {
+                i += 1;
+            }
+            tokens.push(Token {
+                text: chars[start..i].iter().collect(),
+                line,
+            });
+        } else {
+            tokens.push(Token {
+                text: c.to_string(),
+                line,
+            });
+            i += 1;
+        }
+    }
+    tokens
+}
+
+/// Recursive descent over the tokens of a `.proto` file
+struct ProtoParser {
+    tokens: Vec<Token>,
+    pos: usize,
+    file: ProtoFile,
+}
+
+impl ProtoParser {
+    fn peek(&self, offset: usize) -> Option<&str> {
+        self.tokens.get(self.pos + offset).map(|t| t.text.as_str())
+    }
+
+    /// Parse definitions up to the closing brace of the enclosing block,
+    /// returning the brace's line
+    fn body(&mut self, scope: &[String], kind: Option<ProtoKind>) -> usize {
+        while let Some(token) = self.tokens.get(self.pos).cloned() {
+            self.pos += 1;
+            match token.text.as_str() {
+                "}" => return token.line,
+                ";" => {}
+                "message" | "enum" | "service"
+                    if self.peek(1) == Some("{") && self.peek(0).is_some_and(is_identifier) =>
+                {
+                    let block_kind = match token.text.as_str() {
+                        "message" => ProtoKind::Message,
+                        "enum" => ProtoKind::Enum,
+                        _ => ProtoKind::Service,
+                    };
+                    let name = self.tokens[self.pos].text.clone();
+                    self.pos += 2;
+                    let index = self.push(block_kind, &name, scope, token.line);
+                    let mut inner = scope.to_vec();
+                    inner.push(name);
+                    let end_line = self.body(&inner, Some(block_kind));
+                    self.file.entities[index].end_line = end_line;
+                }
+                "oneof" if self.peek(1) == Some("{") => {
+                    // Members of a oneof are fields of the message
+                    self.pos += 2;
+                    self.body(scope, kind);
+                }
+                "rpc" if kind == Some(ProtoKind::Service) => {
+                    let Some(name) = self.peek(0).filter(|n| is_identifier(n)) else {
+                        continue;
+                    };
+                    let name = name.to_string();
+                    let index = self.push(ProtoKind::Rpc, &name, scope, token.line);
+                    let end_line = self.statement().1;
+                    self.file.entities[index].end_line = end_line;
+                }
+                "package" => {
+                    let (tokens, _) = self.statement();
+                    self.file.package = tokens.concat();
+                }
+                "syntax" | "edition" | "import" | "option" | "reserved" | "extensions"
+                | "extend" => {
+                    self.statement();
+                }
+                _ => {
+                    // A field or enum value: `[label] type name = number [...];`
+                    self.pos -= 1;
+                    let (tokens, end_line) = self.statement();
+                    let Some(equals) = tokens.iter().position(|t| t == "=") else {
+                        continue;
+                    };
+                    let entity_kind = match kind {
+                        Some(ProtoKind::Message) => ProtoKind::Field,
+                        Some(ProtoKind::Enum) => ProtoKind::EnumValue,
+                        _ => continue,
+                    };
+                    if let Some(name) = equals.checked_sub(1).map(|i| &tokens[i]) {
+                        if is_identifier(name) {
+                            let index = self.push(entity_kind, name, scope, token.line);
+                            self.file.entities[index].end_line = end_line;
+                        }
+                    }
+                }
+            }
+        }
+        self.tokens.last().map_or(1, |t| t.line)
+    }
+
+    /// Consume a statement up to its `;`, or up to the end of its block,
+    /// returning its tokens (without nested blocks) and its last line
+    fn statement(&mut self) -> (Vec<String>, usize) {
+        let mut tokens = Vec::new();
+        let mut depth = 0;
+        while let Some(token) = self.tokens.get(self.pos) {
+            self.pos += 1;
+            match token.text.as_str() {
+                "{" => depth += 1,
+                "}" if depth > 0 => {
+                    depth -= 1;
+                    if depth == 0 && self.peek(0) != Some(";") {
+                        return (tokens, token.line);
+                    }
+                }
+                "}" => {
+                    // End of the enclosing block: leave it to the caller
+                    self.pos -= 1;
+                    return (tokens, token.line);
+                }
+                ";" if depth == 0 => return (tokens, token.line),
+                _ if depth == 0 => tokens.push(token.text.clone()),
+                _ => {}
+            }
+        }
+        (tokens, self.tokens.last().map_or(1, |t| t.line))
+    }
+
+    fn push(&mut self, kind: ProtoKind, name: &str, scope: &[String], line: usize) -> usize {
+        self.file.entities.push(ProtoEntity {
+            kind,
+            name: name.to_string(),
+            scope: scope.to_vec(),
+            line,
+            end_line: line,
+        });
+        self.file.entities.len() - 1
+    }
+}
+
+fn is_identifier(token: &str) -> bool {
+    token
+        .chars()
+        .next()
+        .is_some_and(|c| c.is_ascii_alphabetic() || c == '_')
+        && token.chars().all(is_ident_char)
+}
+
+/// Convert a `.proto` name to the Go name protoc-gen-go gives it
+/// (`protogen.GoCamelCase`): `foo_bar` becomes `FooBar`, and
+/// `Outer.Inner` becomes `Outer_Inner`
+fn go_camel_case(name: &str) -> String {
+    let bytes = name.as_bytes();
+    let mut out = String::with_capacity(name.len());
+    let mut i = 0;
+    while i < bytes.len() {
+        let c = bytes[i];
+        let next_lower = bytes.get(i + 1).is_some_and(u8::is_ascii_lowercase);
+        if c == b'.' && next_lower {
+            // Skip the dot of ".lowercase"
+        } else if c == b'.' {
+            out.push('_');
+        } else if c == b'_' && (i == 0 || bytes[i - 1] == b'.') {
+            out.push('X');
+        } else if c == b'_' && next_lower {
+            // Skip the underscore of "_lowercase"
+        } else if c.is_ascii_digit() {
+            out.push(c as char);
+        } else {
+            out.push(c.to_ascii_uppercase() as char);
+            while bytes.get(i + 1).is_some_and(u8::is_ascii_lowercase) {
+                i += 1;
+                out.push(bytes[i] as char);
+            }
+        }
+        i += 1;
+    }
+    out
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::EdgeType;
+
+    const PROTO: &str = r#"syntax = "proto3";
+
+package shop.v1;
+
+option go_package = "example.com/shop/gen/shopv1";
+
+// An order
+message Order {
+  string order_id = 1;
+  repeated Line lines = 2 [deprecated = true];
+  map<string, string> labels = 3;
+
+  message Line {
+    string sku = 1;
+  }
+
+  enum Status {
+    STATUS_UNSPECIFIED = 0;
+    STATUS_PAID = 1;
+  }
+
+  oneof payment {
+    string card_token = 4;
+  }
+  reserved 5, 6;
+}
+
+service OrderService {
+  rpc GetOrder(GetOrderRequest) returns (Order);
+  rpc WatchOrders(stream GetOrderRequest) returns (stream Order) {
+    option deprecated = true;
+  }
+}
+"#;
+
+    #[test]
+    fn test_parse_proto() {
+        let proto = ProtoFile::parse(PROTO);
+        assert_eq!(proto.package, "shop.v1");
+
+        let ids: Vec<(ProtoKind, String, usize, usize)> = proto
+            .entities
+            .iter()
+            .map(|e| (e.kind, e.id("shop.proto"), e.line, e.end_line))
+            .collect();
+        let expected = [
+            (ProtoKind::Message, "shop.proto:Order", 8, 26),
+            (ProtoKind::Field, "shop.proto:Order:order_id", 9, 9),
+            (ProtoKind::Field, "shop.proto:Order:lines", 10, 10),
+            (ProtoKind::Field, "shop.proto:Order:labels", 11, 11),
+            (ProtoKind::Message, "shop.proto:Order:Line", 13, 15),
+            (ProtoKind::Field, "shop.proto:Order:Line:sku", 14, 14),
+            (ProtoKind::Enum, "shop.proto:Order:Status", 17, 20),
+            (
+                ProtoKind::EnumValue,
+                "shop.proto:Order:Status:STATUS_UNSPECIFIED",
+                18,
+                18,
+            ),
+            (
+                ProtoKind::EnumValue,
+                "shop.proto:Order:Status:STATUS_PAID",
+                19,
+                19,
+            ),
+            (ProtoKind::Field, "shop.proto:Order:card_token", 23, 23),
+            (ProtoKind::Service, "shop.proto:OrderService", 28, 33),
+            (ProtoKind::Rpc, "shop.proto:OrderService:GetOrder", 29, 29),
+            (
+                ProtoKind::Rpc,
+                "shop.proto:OrderService:WatchOrders",
+                30,
+                32,
+            ),
+        ];
+        let expected: Vec<(ProtoKind, String, usize, usize)> = expected
+            .iter()
+            .map(|(kind, id, line, end)| (*kind, id.to_string(), *line, *end))
+            .collect();
+        assert_eq!(ids, expected);
+
+        let line = &proto.entities[4];
+        assert_eq!(line.go_name(), "Order_Line");
+        assert_eq!(proto.full_name(line), "shop.v1.Order.Line");
+    }
+
+    #[test]
+    fn test_go_camel_case() {
+        assert_eq!(go_camel_case("order_id"), "OrderId");
+        assert_eq!(go_camel_case("Order.Line"), "Order_Line");
+        assert_eq!(go_camel_case("_private"), "XPrivate");
+        assert_eq!(go_camel_case("field2_name"), "Field2Name");
+        assert_eq!(go_camel_case("HTTPServer"), "HTTPServer");
+    }
+
+    #[test]
+    fn test_c_references() {
+        assert_eq!(
+            c_references("\tn := C.add(C.int(a), b.C.x) // C.free"),
+            vec!["add", "int", "free"]
+        );
+        assert_eq!(c_references("var s C.struct_point"), vec!["struct_point"]);
+        assert!(c_references("ABC.x").is_empty());
+        assert!(calls("  goCallback (1);", "goCallback"));
+        assert!(!calls("  my_goCallback(1);", "goCallback"));
+    }
+
+    fn write(dir: &Path, file: &str, source: &str) {
+        let path = dir.join(file);
+        std::fs::create_dir_all(path.parent().unwrap()).unwrap();
+        std::fs::write(path, source).unwrap();
+    }
+
+    fn add_file(graph: &mut PetCodeGraph, file: &str) {
+        graph.add_node(Node::source_file(
+            file.to_string(),
+            file.to_string(),
+            String::new(),
+            1,
+        ));
+    }
+
+    fn add_callable(graph: &mut PetCodeGraph, file: &str, name: &str, lines: (usize, usize)) {
+        graph.add_node(Node::callable(
+            format!("{}:{}", file, name),
+            name.to_string(),
+            CallableKind::Function,
+            file.to_string(),
+            lines.0,
+            lines.1,
+        ));
+    }
+
+    fn cross_language(graph: &PetCodeGraph) -> Vec<(String, String, Option<String>)> {
+        let mut edges: Vec<_> = graph
+            .edges_by_type(EdgeType::CrossLanguage)
+            .map(|(source, target, edge)| {
+                (source.id.clone(), target.id.clone(), edge.ident.clone())
+            })
+            .collect();
+        edges.sort();
+        edges
+    }
+
+    #[test]
+    fn test_link_cgo() {
+        let dir = tempfile::tempdir().unwrap();
+        let go = "package calc
+
+// #include \"calc.h\"
+import \"C\"
+
+func Add(a, b int) int {
+\treturn int(C.add(C.int(a), C.int(b)))
+}
+
+//export goLog
+func goLog(msg *C.char) {
+\tprintln(C.GoString(msg))
+}
+";
+        let c = "#include \"calc.h\"
+
+extern void goLog(char *msg);
+
+int add(int a, int b) {
+    goLog(\"add\");
+    return a + b;
+}
+";
+        write(dir.path(), "calc/calc.go", go);
+        write(dir.path(), "calc/calc.c", c);
+
+        let mut graph = PetCodeGraph::new();
+        add_file(&mut graph, "calc/calc.go");
+        add_callable(&mut graph, "calc/calc.go", "Add", (6, 8));
+        add_callable(&mut graph, "calc/calc.go", "goLog", (11, 13));
+        add_file(&mut graph, "calc/calc.c");
+        add_callable(&mut graph, "calc/calc.c", "goLog", (3, 3));
+        add_callable(&mut graph, "calc/calc.c", "add", (5, 5));
+        // A declaration in a header elsewhere loses to the source file
+        add_file(&mut graph, "include/calc.h");
+        add_callable(&mut graph, "include/calc.h", "add", (1, 1));
+
+        let links = link_cross_language(&mut graph, dir.path(), "", &[]);
+        assert_eq!(links.cgo, 2);
+        assert_eq!(
+            cross_language(&graph),
+            vec![
+                (
+                    "calc/calc.c:add".to_string(),
+                    "calc/calc.go:goLog".to_string(),
+                    Some("goLog".to_string())
+                ),
+                (
+                    "calc/calc.go:Add".to_string(),
+                    "calc/calc.c:add".to_string(),
+                    Some("C.add".to_string())
+                ),
+            ]
+        );
+    }
+
+    #[test]
+    fn test_link_protobuf() {
+        let dir = tempfile::tempdir().unwrap();
+        write(dir.path(), "proto/shop/v1/shop.proto", PROTO);
+        let go = "// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: shop/v1/shop.proto
+
+package shopv1
+
+type Order struct {
+\tOrderId string
+}
+
+type OrderServiceClient interface {
+\tGetOrder()
+}
+";
+        write(dir.path(), "gen/shopv1/shop.pb.go", go);
+
+        let go_file = "gen/shopv1/shop.pb.go";
+        let mut graph = PetCodeGraph::new();
+        graph.add_node(Node::repository(
+            "shop".to_string(),
+            NodeMetadata::default(),
+        ));
+        add_file(&mut graph, go_file);
+        for (name, subtype, line) in [
+            ("Order", "struct", 6),
+            ("OrderServiceClient", "interface", 10),
+        ] {
+            let id = format!("{}:{}", go_file, name);
+            graph.add_node(Node::container(
+                id.clone(),
+                name.to_string(),
+                ContainerKind::Type,
+                Some(subtype.to_string()),
+                go_file.to_string(),
+                line,
+                line + 2,
+            ));
+            graph.add_edge_from_struct(&Edge::contains(go_file.to_string(), id));
+        }
+        graph.add_node(Node::data(
+            format!("{}:Order:OrderId", go_file),
+            "OrderId".to_string(),
+            DataKind::Field,
+            None,
+            go_file.to_string(),
+            7,
+            7,
+        ));
+        graph.add_edge_from_struct(&Edge::contains(
+            format!("{}:Order", go_file),
+            format!("{}:Order:OrderId", go_file),
+        ));
+        graph.add_node(Node::callable(
+            format!("{}:OrderServiceClient:GetOrder", go_file),
+            "GetOrder".to_string(),
+            CallableKind::Method,
+            go_file.to_string(),
+            11,
+            11,
+        ));
+        graph.add_edge_from_struct(&Edge::contains(
+            format!("{}:OrderServiceClient", go_file),
+            format!("{}:OrderServiceClient:GetOrder", go_file),
+        ));
+
+        let proto_files = vec!["proto/shop/v1/shop.proto".to_string()];
+        let links = link_cross_language(&mut graph, dir.path(), "shop", &proto_files);
+        assert_eq!(links.proto_files, 1);
+        assert_eq!(links.protobuf, 4);
+
+        let file = graph.get_node("proto/shop/v1/shop.proto").unwrap();
+        assert_eq!(file.metadata.language.as_deref(), Some(PROTO_LANGUAGE));
+        let rpc = graph
+            .get_node("proto/shop/v1/shop.proto:OrderService:GetOrder")
+            .unwrap();
+        assert_eq!(rpc.node_type, NodeType::Callable);
+        assert_eq!(
+            graph
+                .parent("proto/shop/v1/shop.proto:Order:Line:sku")
+                .unwrap()
+                .id,
+            "proto/shop/v1/shop.proto:Order:Line"
+        );
+
+        let edge = |source: &str, target: &str, name: &str| {
+            (
+                format!("{}:{}", go_file, source),
+                format!("proto/shop/v1/shop.proto:{}", target),
+                Some(name.to_string()),
+            )
+        };
+        assert_eq!(
+            cross_language(&graph),
+            vec![
+                edge("Order", "Order", "shop.v1.Order"),
+                edge("Order:OrderId", "Order:order_id", "shop.v1.Order.order_id"),
+                edge("OrderServiceClient", "OrderService", "shop.v1.OrderService"),
+                edge(
+                    "OrderServiceClient:GetOrder",
+                    "OrderService:GetOrder",
+                    "shop.v1.OrderService.GetOrder"
+                ),
+            ]
+        );
+    }
+}
@@ -0,0 +1,338 @@
+//! Tags Files
+//!
+//! Writes the symbols of a graph as a tags file, so editors with built-in
+//! tags support jump to definitions using the codeprysm index:
+//!
+//! - **ctags**: universal-ctags extended format (`tags`), read by Vim,
+//!   Neovim, and most editors
+//! - **etags**: Emacs format (`TAGS`)
+//!
+//! Definitions are located by search pattern built from the source line, so
+//! tags keep working while lines shift above them. Symbols in files that
+//! cannot be read fall back to line numbers.
+
+use std::collections::BTreeMap;
+use std::fmt::Write;
+use std::path::Path;
+
+use serde::{Deserialize, Serialize};
+
+use crate::graph::{ContainerKind, DataKind, Node, NodeType, PetCodeGraph};
+use crate::parser::SupportedLanguage;
+
+/// Supported tags file formats.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum TagsFormat {
+    /// universal-ctags extended format
+    Ctags,
+    /// Emacs etags format
+    Etags,
+}
+
+impl TagsFormat {
+    /// All formats
+    pub const ALL: [TagsFormat; 2] = [TagsFormat::Ctags, TagsFormat::Etags];
+
+    /// Get the string representation
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            TagsFormat::Ctags => "ctags",
+            TagsFormat::Etags => "etags",
+        }
+    }
+
+    /// Conventional file name for the format
+    pub fn default_file_name(&self) -> &'static str {
+        match self {
+            TagsFormat::Ctags => "tags",
+            TagsFormat::Etags => "TAGS",
+        }
+    }
+}
+
+impl std::str::FromStr for TagsFormat {
+    type Err = String;
+
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        TagsFormat::ALL
+            .into_iter()
+            .find(|f| f.as_str().eq_ignore_ascii_case(s))
+            .ok_or_else(|| format!("Unknown tags format: {} (expected ctags or etags)", s))
+    }
+}
+
+/// A symbol to tag, with its location and scope
+struct Tag<'a> {
+    node: &'a Node,
+    kind: &'a str,
+    /// Kind and qualified name of the enclosing symbol
+    scope: Option<(&'a str, String)>,
+}
+
+/// Render the symbols of a graph as a tags file.
+///
+/// Source lines for search patterns are read from `repo_root`, against which
+/// node file paths are relative.
+pub fn write_tags(graph: &PetCodeGraph, repo_root: &Path, format: TagsFormat) -> String {
+    let mut by_file: BTreeMap<&str, Vec<Tag>> = BTreeMap::new();
+    for node in graph.iter_nodes() {
+        let Some(kind) = tag_kind(node) else {
+            continue;
+        };
+        let tag = Tag {
+            node,
+            kind,
+            scope: scope_of(graph, node),
+        };
+        by_file.entry(node.file.as_str()).or_default().push(tag);
+    }
+
+    let sources: BTreeMap<&str, String> = by_file
+        .keys()
+        .filter_map(|file| {
+            let content = std::fs::read_to_string(repo_root.join(file)).ok()?;
+            Some((*file, content))
+        })
+        .collect();
+
+    match format {
+        TagsFormat::Ctags => to_ctags(&by_file, &sources),
+        TagsFormat::Etags => to_etags(&by_file, &sources),
+    }
+}
+
+/// Kind to report for a node, or `None` for nodes that are not tagged
+/// (structural containers, parameters, and locals).
+fn tag_kind(node: &Node) -> Option<&str> {
+    if node.file.is_empty() || node.line == 0 {
+        return None;
+    }
+    let kind = node.kind.as_deref()?;
+    match node.node_type {
+        NodeType::Container => match node.container_kind()? {
+            ContainerKind::Type => Some(node.subtype.as_deref().unwrap_or(kind)),
+            ContainerKind::Namespace | ContainerKind::Module | ContainerKind::Package => Some(kind),
+            _ => None,
+        },
+        NodeType::Callable => Some(kind),
+        NodeType::Data => {
+            if kind == DataKind::Parameter.as_str() || kind == DataKind::Local.as_str() {
+                None
+            } else if kind == DataKind::Value.as_str() {
+                Some("variable")
+            } else {
+                Some(kind)
+            }
+        }
+    }
+}
+
+/// Kind and dotted name of the symbols enclosing a node, innermost kind
+/// first (e.g. `("class", "Outer.Inner")`). Go methods are scoped to their
+/// receiver type.
+fn scope_of<'a>(graph: &'a PetCodeGraph, node: &Node) -> Option<(&'a str, String)> {
+    let mut names = Vec::new();
+    let mut kind = None;
+    let mut current = graph.parent(&node.id);
+    while let Some(parent) = current {
+        let Some(parent_kind) = tag_kind(parent) else {
+            break;
+        };
+        kind.get_or_insert(parent_kind);
+        names.push(parent.name.as_str());
+        current = graph.parent(&parent.id);
+    }
+
+    if names.is_empty() {
+        let receiver = node.metadata.receiver.as_deref()?;
+        return Some(("type", receiver.to_string()));
+    }
+    names.reverse();
+    Some((kind?, names.join(".")))
+}
+
+/// Source line `line` (1-indexed) without its line terminator
+fn source_line(content: &str, line: usize) -> Option<&str> {
+    content
+        .lines()
+        .nth(line.checked_sub(1)?)
+        .map(|l| l.trim_end_matches('\r'))
+}
+
+fn to_ctags(by_file: &BTreeMap<&str, Vec<Tag>>, sources: &BTreeMap<&str, String>) -> String {
+    let mut lines = Vec::new();
+    for (file, tags) in by_file {
+        let content = sources.get(file);
+        for tag in tags {
+            let address = match content.and_then(|c| source_line(c, tag.node.line)) {
+                Some(text) => format!("/^{}$/", escape_pattern(text)),
+                None => tag.node.line.to_string(),
+            };
+
+            let mut line = format!(
+                "{}\t{}\t{};\"\tkind:{}\tline:{}",
+                tag.node.name, file, address, tag.kind, tag.node.line
+            );
+            if let Some(language) = SupportedLanguage::from_path(Path::new(file)) {
+                let _ = write!(line, "\tlanguage:{}", language.as_str());
+            }
+            if let Some((kind, ref name)) = tag.scope {
+                let _ = write!(line, "\t{}:{}", kind, name);
+            }
+            lines.push(line);
+        }
+    }
+    // Sorted byte-wise, as `!_TAG_FILE_SORTED 1` promises, so editors can
+    // binary search
+    lines.sort();
+
+    let mut out = String::new();
+    out.push_str(
+        "!_TAG_FILE_FORMAT\t2\t/extended format; --format=1 will not append ;\" to lines/\n",
+    );
+    out.push_str("!_TAG_FILE_SORTED\t1\t/0=unsorted, 1=sorted, 2=foldcase/\n");
+    out.push_str("!_TAG_PROGRAM_NAME\tcodeprysm\t//\n");
+    out.push_str("!_TAG_PROGRAM_URL\thttps://github.com/codeprysm/codeprysm\t//\n");
+    let _ = writeln!(
+        out,
+        "!_TAG_PROGRAM_VERSION\t{}\t//",
+        env!("CARGO_PKG_VERSION")
+    );
+    for line in lines {
+        out.push_str(&line);
+        out.push('\n');
+    }
+    out
+}
+
+/// Escape a source line for a `/^...$/` search pattern
+fn escape_pattern(text: &str) -> String {
+    text.replace('\\', "\\\\").replace('/', "\\/")
+}
+
+fn to_etags(by_file: &BTreeMap<&str, Vec<Tag>>, sources: &BTreeMap<&str, String>) -> String {
+    let mut out = String::new();
+    for (file, tags) in by_file {
+        let content = sources.get(file).map(String::as_str).unwrap_or("");
+        let offsets = line_offsets(content);
+
+        let mut tags: Vec<&Tag> = tags.iter().collect();
+        tags.sort_by_key(|t| (t.node.line, t.node.name.as_str()));
+
+        let mut section = String::new();
+        for tag in tags {
+            let text = source_line(content, tag.node.line).unwrap_or("");
+            // Emacs matches the line text up to the end of the name
+            let prefix = match text.find(tag.node.name.as_str()) {
+                Some(start) => &text[..start + tag.node.name.len()],
+                None => text,
+            };
+            let offset = offsets.get(tag.node.line - 1).copied().unwrap_or(0);
+            let _ = writeln!(
+                section,
+                "{}\x7f{}\x01{},{}",
+                prefix, tag.node.name, tag.node.line, offset
+            );
+        }
+
+        let _ = write!(out, "\x0c\n{},{}\n{}", file, section.len(), section);
+    }
+    out
+}
+
+/// Byte offset of the start of each line
+fn line_offsets(content: &str) -> Vec<usize> {
+    let mut offsets = vec![0];
+    offsets.extend(content.match_indices('\n').map(|(i, _)| i + 1));
+    offsets
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::{CallableKind, EdgeData};
+
+    fn sample_graph() -> PetCodeGraph {
+        let mut graph = PetCodeGraph::new();
+        graph.add_node(Node::source_file(
+            "app.py".to_string(),
+            "app.py".to_string(),
+            String::new(),
+            5,
+        ));
+        graph.add_node(Node::container(
+            "app.py:Server".to_string(),
+            "Server".to_string(),
+            ContainerKind::Type,
+            Some("class".to_string()),
+            "app.py".to_string(),
+            1,
+            3,
+        ));
+        graph.add_node(Node::callable(
+            "app.py:Server:start".to_string(),
+            "start".to_string(),
+            CallableKind::Method,
+            "app.py".to_string(),
+            2,
+            3,
+        ));
+        graph.add_node(Node::callable(
+            "app.py:main".to_string(),
+            "main".to_string(),
+            CallableKind::Function,
+            "app.py".to_string(),
+            5,
+            5,
+        ));
+        graph.add_edge("app.py", "app.py:Server", EdgeData::contains());
+        graph.add_edge("app.py:Server", "app.py:Server:start", EdgeData::contains());
+        graph.add_edge("app.py", "app.py:main", EdgeData::contains());
+        graph
+    }
+
+    const SOURCE: &str =
+        "class Server:\n    def start(self):\n        return 1\n\ndef main(): pass\n";
+
+    #[test]
+    fn test_ctags() {
+        let dir = tempfile::tempdir().unwrap();
+        std::fs::write(dir.path().join("app.py"), SOURCE).unwrap();
+
+        let tags = write_tags(&sample_graph(), dir.path(), TagsFormat::Ctags);
+        let entries: Vec<&str> = tags.lines().filter(|l| !l.starts_with("!_")).collect();
+        assert_eq!(
+            entries,
+            vec![
+                "Server\tapp.py\t/^class Server:$/;\"\tkind:class\tline:1\tlanguage:python",
+                "main\tapp.py\t/^def main(): pass$/;\"\tkind:function\tline:5\tlanguage:python",
+                "start\tapp.py\t/^    def start(self):$/;\"\tkind:method\tline:2\tlanguage:python\tclass:Server",
+            ]
+        );
+        assert!(tags.starts_with("!_TAG_FILE_FORMAT\t2\t"));
+    }
+
+    #[test]
+    fn test_ctags_without_source_uses_line_numbers() {
+        let dir = tempfile::tempdir().unwrap();
+        let tags = write_tags(&sample_graph(), dir.path(), TagsFormat::Ctags);
+        assert!(tags.contains("main\tapp.py\t5;\"\tkind:function"));
+    }
+
+    #[test]
+    fn test_etags() {
+        let dir = tempfile::tempdir().unwrap();
+        std::fs::write(dir.path().join("app.py"), SOURCE).unwrap();
+
+        let tags = write_tags(&sample_graph(), dir.path(), TagsFormat::Etags);
+        let section = "class Server\x7fServer\x011,0\n    def start\x7fstart\x012,14\ndef main\x7fmain\x015,53\n";
+        assert_eq!(tags, format!("\x0c\napp.py,{}\n{}", section.len(), section));
+    }
+
+    #[test]
+    fn test_format_parsing() {
+        assert_eq!("ETAGS".parse::<TagsFormat>().unwrap(), TagsFormat::Etags);
+        assert!("gtags".parse::<TagsFormat>().is_err());
+    }
+}
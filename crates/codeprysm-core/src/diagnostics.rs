@@ -0,0 +1,326 @@
+//! Build Diagnostics
+//!
+//! Records where a build could not fully extract the code, so users can tell
+//! where the graph is incomplete instead of finding symbols silently missing:
+//!
+//! - Files that could not be read or parsed at all (errors)
+//! - Syntax errors tree-sitter recovered from; definitions in the affected
+//!   region may be missing (warnings)
+//! - Files with references that resolved to nothing in the index, usually
+//!   calls into external dependencies (info)
+//! - Go template references to fields and methods the rendered type lacks,
+//!   which fail at render time (warnings)
+//!
+//! Builds collect diagnostics (see
+//! [`GraphBuilder::diagnostics`](crate::builder::GraphBuilder::diagnostics))
+//! and `codeprysm init` and `codeprysm update` save them next to the index.
+
+use std::fmt;
+use std::path::Path;
+use std::str::FromStr;
+
+use serde::{Deserialize, Serialize};
+use tree_sitter::{Node as TsNode, Tree};
+
+/// File in the index directory holding the diagnostics of the last build
+pub const DIAGNOSTICS_FILE: &str = "diagnostics.json";
+
+/// Syntax errors reported per file; later ones are usually follow-on errors
+pub const MAX_SYNTAX_ERRORS_PER_FILE: usize = 20;
+
+/// How much a diagnostic affects the graph.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, PartialOrd, Ord, Hash, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum Severity {
+    /// The graph is complete, but may be less connected than expected
+    Info,
+    /// Part of a file may be missing from the graph
+    Warning,
+    /// A whole file is missing from the graph
+    Error,
+}
+
+impl Severity {
+    /// Severity name as used in output
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            Self::Info => "info",
+            Self::Warning => "warning",
+            Self::Error => "error",
+        }
+    }
+}
+
+impl fmt::Display for Severity {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        f.pad(self.as_str())
+    }
+}
+
+impl FromStr for Severity {
+    type Err = String;
+
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        match s.to_lowercase().as_str() {
+            "info" => Ok(Self::Info),
+            "warning" | "warn" => Ok(Self::Warning),
+            "error" => Ok(Self::Error),
+            _ => Err(format!(
+                "Unknown severity '{}': expected info, warning, or error",
+                s
+            )),
+        }
+    }
+}
+
+/// What went wrong.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, PartialOrd, Ord, Hash, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum DiagnosticKind {
+    /// The file could not be read, decoded, or parsed
+    ExtractionFailed,
+    /// The parser recovered from a syntax error
+    SyntaxError,
+    /// References from the file resolved to nothing in the index
+    UnresolvedReferences,
+    /// A template refers to a field or method its data type lacks
+    TemplateMismatch,
+    /// Symbols were left out of the graph to stay within a symbol limit
+    SymbolsTruncated,
+}
+
+/// A source range, with 1-indexed lines and columns.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, PartialOrd, Ord, Hash, Serialize, Deserialize)]
+pub struct Span {
+    /// Start line
+    pub line: usize,
+    /// Start column
+    pub column: usize,
+    /// End line
+    pub end_line: usize,
+    /// End column
+    pub end_column: usize,
+}
+
+impl Span {
+    /// Span of a tree-sitter node
+    fn of(node: &TsNode) -> Self {
+        let (start, end) = (node.start_position(), node.end_position());
+        Self {
+            line: start.row + 1,
+            column: start.column + 1,
+            end_line: end.row + 1,
+            end_column: end.column + 1,
+        }
+    }
+}
+
+/// A problem found while building the graph.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct Diagnostic {
+    /// File path relative to the workspace root
+    pub file: String,
+    /// Affected range (None for the whole file)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub span: Option<Span>,
+    /// Severity
+    pub severity: Severity,
+    /// What went wrong
+    pub kind: DiagnosticKind,
+    /// Human-readable reason
+    pub message: String,
+}
+
+impl Diagnostic {
+    /// A file left out of the graph because it could not be extracted
+    pub fn extraction_failed(file: &str, reason: &str) -> Self {
+        Self {
+            file: file.to_string(),
+            span: None,
+            severity: Severity::Error,
+            kind: DiagnosticKind::ExtractionFailed,
+            message: format!("File not indexed: {}", reason),
+        }
+    }
+
+    /// A syntax error the parser recovered from; `missing` is the kind of
+    /// a token the parser inserted, if that is how it recovered
+    pub fn syntax_error(file: &str, span: Span, missing: Option<&str>) -> Self {
+        let message = match missing {
+            Some(kind) => format!(
+                "Syntax error: missing `{}`; definitions nearby may be missing",
+                kind
+            ),
+            None => "Syntax error; definitions in this range may be missing".to_string(),
+        };
+        Self {
+            file: file.to_string(),
+            span: Some(span),
+            severity: Severity::Warning,
+            kind: DiagnosticKind::SyntaxError,
+            message,
+        }
+    }
+
+    /// References from a file that resolved to nothing in the index
+    pub fn unresolved_references(file: &str, resolved: u32, unresolved: u32) -> Self {
+        Self {
+            file: file.to_string(),
+            span: None,
+            severity: Severity::Info,
+            kind: DiagnosticKind::UnresolvedReferences,
+            message: format!(
+                "{} of {} reference(s) not resolved (external dependencies or unsupported constructs); their USES edges are missing",
+                unresolved,
+                resolved + unresolved
+            ),
+        }
+    }
+
+    /// A template reference to a field or method the rendered type lacks
+    pub fn template_mismatch(file: &str, span: Span, field: &str, type_name: &str) -> Self {
+        Self {
+            file: file.to_string(),
+            span: Some(span),
+            severity: Severity::Warning,
+            kind: DiagnosticKind::TemplateMismatch,
+            message: format!(
+                "Template references `.{}`, but `{}` has no field or method of that name",
+                field, type_name
+            ),
+        }
+    }
+
+    /// Symbols of `files` files, starting with `file`, left out of the graph
+    /// because `limit` was reached
+    pub fn symbols_truncated(file: &str, files: usize, symbols: usize, limit: &str) -> Self {
+        Self {
+            file: file.to_string(),
+            span: None,
+            severity: Severity::Warning,
+            kind: DiagnosticKind::SymbolsTruncated,
+            message: format!(
+                "{} symbol(s) in {} file(s) not indexed: {}",
+                symbols, files, limit
+            ),
+        }
+    }
+}
+
+/// Collect the syntax errors of a parsed file, in source order, up to
+/// [`MAX_SYNTAX_ERRORS_PER_FILE`].
+pub fn syntax_errors(tree: &Tree, file: &str) -> Vec<Diagnostic> {
+    let mut errors = Vec::new();
+    let mut stack = vec![tree.root_node()];
+    while let Some(node) = stack.pop() {
+        if errors.len() >= MAX_SYNTAX_ERRORS_PER_FILE {
+            break;
+        }
+        if node.is_error() || node.is_missing() {
+            let missing = node.is_missing().then(|| node.kind());
+            errors.push(Diagnostic::syntax_error(file, Span::of(&node), missing));
+            continue;
+        }
+        if node.has_error() {
+            let mut cursor = node.walk();
+            let children: Vec<TsNode> = node.children(&mut cursor).collect();
+            stack.extend(children.into_iter().rev());
+        }
+    }
+    errors
+}
+
+/// Diagnostics of a build, saved next to the index.
+#[derive(Debug, Clone, Default, PartialEq, Eq, Serialize, Deserialize)]
+pub struct Diagnostics {
+    /// Diagnostics sorted by file, position, and kind
+    pub diagnostics: Vec<Diagnostic>,
+}
+
+impl Diagnostics {
+    /// Collect diagnostics, sorted by file, position, and kind.
+    pub fn new(mut diagnostics: Vec<Diagnostic>) -> Self {
+        diagnostics.sort_by(|a, b| {
+            (&a.file, a.span, a.kind, &a.message).cmp(&(&b.file, b.span, b.kind, &b.message))
+        });
+        Self { diagnostics }
+    }
+
+    /// Number of diagnostics
+    pub fn len(&self) -> usize {
+        self.diagnostics.len()
+    }
+
+    /// Check if there are no diagnostics
+    pub fn is_empty(&self) -> bool {
+        self.diagnostics.is_empty()
+    }
+
+    /// Number of diagnostics of a severity
+    pub fn count(&self, severity: Severity) -> usize {
+        self.diagnostics
+            .iter()
+            .filter(|d| d.severity == severity)
+            .count()
+    }
+
+    /// Save the diagnostics into an index directory.
+    pub fn save(&self, prism_dir: &Path) -> std::io::Result<()> {
+        let json = serde_json::to_string_pretty(self).map_err(std::io::Error::other)?;
+        std::fs::write(prism_dir.join(DIAGNOSTICS_FILE), json)
+    }
+
+    /// Load the diagnostics saved in an index directory, if any.
+    pub fn load(prism_dir: &Path) -> Option<Self> {
+        let json = std::fs::read_to_string(prism_dir.join(DIAGNOSTICS_FILE)).ok()?;
+        serde_json::from_str(&json).ok()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::parser::{CodeParser, SupportedLanguage};
+
+    fn parse(language: SupportedLanguage, source: &str) -> Tree {
+        CodeParser::new(language).unwrap().parse(source).unwrap()
+    }
+
+    #[test]
+    fn test_syntax_errors() {
+        let clean = parse(SupportedLanguage::Python, "def ok():\n    pass\n");
+        assert!(syntax_errors(&clean, "ok.py").is_empty());
+
+        let broken = parse(
+            SupportedLanguage::Python,
+            "def ok():\n    pass\n\ndef broken(:\n    pass\n",
+        );
+        let errors = syntax_errors(&broken, "broken.py");
+        assert!(!errors.is_empty());
+        assert!(errors.len() <= MAX_SYNTAX_ERRORS_PER_FILE);
+        let first = &errors[0];
+        assert_eq!(first.file, "broken.py");
+        assert_eq!(first.severity, Severity::Warning);
+        assert_eq!(first.kind, DiagnosticKind::SyntaxError);
+        assert_eq!(first.span.unwrap().line, 4);
+    }
+
+    #[test]
+    fn test_save_and_load() {
+        let dir = tempfile::tempdir().unwrap();
+        assert!(Diagnostics::load(dir.path()).is_none());
+
+        let diagnostics = Diagnostics::new(vec![
+            Diagnostic::unresolved_references("b.go", 3, 2),
+            Diagnostic::extraction_failed("a.go", "invalid UTF-8"),
+        ]);
+        assert_eq!(diagnostics.diagnostics[0].file, "a.go");
+        assert_eq!(diagnostics.count(Severity::Error), 1);
+        diagnostics.save(dir.path()).unwrap();
+        assert_eq!(Diagnostics::load(dir.path()), Some(diagnostics));
+
+        assert_eq!("WARN".parse::<Severity>(), Ok(Severity::Warning));
+        assert!(Severity::Error > Severity::Info);
+        assert!("fatal".parse::<Severity>().is_err());
+    }
+}
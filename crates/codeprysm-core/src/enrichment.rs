@@ -0,0 +1,297 @@
+//! Attribute Enrichment
+//!
+//! Tags nodes with user-defined attributes, such as `layer=domain` or
+//! `team=payments`, from rules in the configuration. A rule matches nodes by
+//! file path and by name with glob patterns, and sets its attributes on every
+//! node it matches; when several rules set the same key, the last one wins.
+//!
+//! Attributes are stored in `metadata.attributes`, so they are kept in the
+//! index and carried by every export, and queries can filter on them with an
+//! [`AttributeFilter`].
+
+use std::collections::BTreeMap;
+use std::fmt;
+use std::str::FromStr;
+
+use globset::{Glob, GlobSet, GlobSetBuilder};
+use serde::{Deserialize, Serialize};
+use tracing::warn;
+
+use crate::graph::{Node, PetCodeGraph};
+
+/// A rule tagging matching nodes with attributes.
+#[derive(Debug, Clone, Default, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(default)]
+pub struct AttributeRule {
+    /// Glob patterns matched against the node's file path (any if empty)
+    pub paths: Vec<String>,
+    /// Glob patterns matched against the node's name (any if empty)
+    pub names: Vec<String>,
+    /// Attributes set on matching nodes
+    pub attributes: BTreeMap<String, String>,
+}
+
+/// A compiled rule
+#[derive(Debug, Clone)]
+struct CompiledRule {
+    paths: Option<GlobSet>,
+    names: Option<GlobSet>,
+    attributes: BTreeMap<String, String>,
+}
+
+impl CompiledRule {
+    fn matches(&self, node: &Node) -> bool {
+        let path = node.file.trim_start_matches("./");
+        self.paths
+            .as_ref()
+            .is_none_or(|paths| !path.is_empty() && paths.is_match(path))
+            && self
+                .names
+                .as_ref()
+                .is_none_or(|names| names.is_match(&node.name))
+    }
+}
+
+/// Compiled attribute rules.
+#[derive(Debug, Clone, Default)]
+pub struct Enrichment {
+    rules: Vec<CompiledRule>,
+}
+
+impl Enrichment {
+    /// Compile attribute rules.
+    ///
+    /// Invalid patterns are skipped with a warning; a rule left without any
+    /// valid pattern of a kind it had patterns for matches nothing.
+    pub fn new(rules: &[AttributeRule]) -> Self {
+        let rules = rules
+            .iter()
+            .filter(|rule| !rule.attributes.is_empty())
+            .map(|rule| CompiledRule {
+                paths: compile(&rule.paths),
+                names: compile(&rule.names),
+                attributes: rule.attributes.clone(),
+            })
+            .collect();
+        Self { rules }
+    }
+
+    /// Number of rules
+    pub fn len(&self) -> usize {
+        self.rules.len()
+    }
+
+    /// Check if there are no rules
+    pub fn is_empty(&self) -> bool {
+        self.rules.is_empty()
+    }
+
+    /// Attributes of a node under these rules.
+    ///
+    /// Returns an empty map when no rule matches.
+    pub fn attributes_of(&self, node: &Node) -> BTreeMap<String, String> {
+        let mut attributes = BTreeMap::new();
+        for rule in self.rules.iter().filter(|rule| rule.matches(node)) {
+            attributes.extend(
+                rule.attributes
+                    .iter()
+                    .map(|(key, value)| (key.clone(), value.clone())),
+            );
+        }
+        attributes
+    }
+
+    /// Set `metadata.attributes` on a node, replacing any it had.
+    ///
+    /// Returns whether the node has attributes afterwards.
+    pub fn tag(&self, node: &mut Node) -> bool {
+        let attributes = self.attributes_of(node);
+        node.metadata.attributes = (!attributes.is_empty()).then_some(attributes);
+        node.metadata.attributes.is_some()
+    }
+
+    /// Set `metadata.attributes` on every node.
+    ///
+    /// Attributes from a previous run are cleared first, so rules that no
+    /// longer match are not kept. Returns the number of tagged nodes.
+    pub fn apply(&self, graph: &mut PetCodeGraph) -> usize {
+        let mut tagged = 0;
+        for node in graph.inner_mut().node_weights_mut() {
+            if self.tag(node) {
+                tagged += 1;
+            }
+        }
+        tagged
+    }
+}
+
+/// Compile glob patterns, or None when there are none to match
+fn compile(patterns: &[String]) -> Option<GlobSet> {
+    if patterns.is_empty() {
+        return None;
+    }
+    let mut builder = GlobSetBuilder::new();
+    for pattern in patterns {
+        match Glob::new(pattern) {
+            Ok(glob) => {
+                builder.add(glob);
+            }
+            Err(e) => warn!("Attribute rule: invalid pattern '{}': {}", pattern, e),
+        }
+    }
+    Some(builder.build().unwrap_or_else(|_| GlobSet::empty()))
+}
+
+/// A filter on a node attribute: `key=value`, or `key` for any value.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct AttributeFilter {
+    /// Attribute name
+    pub key: String,
+    /// Required value (any if None)
+    pub value: Option<String>,
+}
+
+impl AttributeFilter {
+    /// Check whether a node's attributes satisfy the filter
+    pub fn matches(&self, node: &Node) -> bool {
+        self.matches_value(
+            node.metadata
+                .attributes
+                .as_ref()
+                .and_then(|attributes| attributes.get(&self.key))
+                .map(String::as_str),
+        )
+    }
+
+    /// Check whether the value of the filtered attribute satisfies the
+    /// filter, for callers holding attributes outside a [`Node`]
+    pub fn matches_value(&self, value: Option<&str>) -> bool {
+        match (&self.value, value) {
+            (_, None) => false,
+            (None, Some(_)) => true,
+            (Some(expected), Some(actual)) => expected == actual,
+        }
+    }
+}
+
+impl FromStr for AttributeFilter {
+    type Err = String;
+
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        let (key, value) = match s.split_once('=') {
+            Some((key, value)) => (key.trim(), Some(value.trim().to_string())),
+            None => (s.trim(), None),
+        };
+        if key.is_empty() {
+            return Err(format!(
+                "Invalid attribute filter '{}': expected KEY or KEY=VALUE",
+                s
+            ));
+        }
+        Ok(Self {
+            key: key.to_string(),
+            value,
+        })
+    }
+}
+
+impl fmt::Display for AttributeFilter {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        match &self.value {
+            Some(value) => write!(f, "{}={}", self.key, value),
+            None => f.write_str(&self.key),
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::CallableKind;
+
+    fn rule(paths: &[&str], names: &[&str], attributes: &[(&str, &str)]) -> AttributeRule {
+        AttributeRule {
+            paths: paths.iter().map(|p| p.to_string()).collect(),
+            names: names.iter().map(|n| n.to_string()).collect(),
+            attributes: attributes
+                .iter()
+                .map(|(k, v)| (k.to_string(), v.to_string()))
+                .collect(),
+        }
+    }
+
+    fn function(file: &str, name: &str) -> Node {
+        Node::callable(
+            format!("{}:{}", file, name),
+            name.to_string(),
+            CallableKind::Function,
+            file.to_string(),
+            1,
+            5,
+        )
+    }
+
+    #[test]
+    fn test_rules_match_paths_and_names() {
+        let enrichment = Enrichment::new(&[
+            rule(&["internal/domain/**"], &[], &[("layer", "domain")]),
+            rule(&["internal/payments/**"], &[], &[("team", "payments")]),
+            rule(&[], &["*Handler"], &[("layer", "api")]),
+            rule(&["**"], &[], &[]),
+        ]);
+        assert_eq!(enrichment.len(), 3);
+
+        let order = function("internal/domain/order.go", "NewOrder");
+        assert_eq!(
+            enrichment.attributes_of(&order),
+            BTreeMap::from([("layer".to_string(), "domain".to_string())])
+        );
+
+        // The later rule overrides the layer, the team is kept
+        let refund = function("internal/payments/refund.go", "RefundHandler");
+        let attributes = enrichment.attributes_of(&refund);
+        assert_eq!(attributes["layer"], "api");
+        assert_eq!(attributes["team"], "payments");
+
+        assert!(enrichment
+            .attributes_of(&function("cmd/main.go", "main"))
+            .is_empty());
+    }
+
+    #[test]
+    fn test_apply_replaces_previous_attributes() {
+        let mut graph = PetCodeGraph::new();
+        let mut stale = function("cmd/main.go", "main");
+        stale.metadata.attributes = Some(BTreeMap::from([("old".to_string(), "x".to_string())]));
+        graph.add_node(stale);
+        graph.add_node(function("internal/domain/order.go", "NewOrder"));
+
+        let enrichment = Enrichment::new(&[rule(&["internal/**"], &[], &[("layer", "domain")])]);
+        assert_eq!(enrichment.apply(&mut graph), 1);
+        assert!(graph
+            .get_node("cmd/main.go:main")
+            .unwrap()
+            .metadata
+            .attributes
+            .is_none());
+
+        let filter: AttributeFilter = "layer=domain".parse().unwrap();
+        let order = graph.get_node("internal/domain/order.go:NewOrder").unwrap();
+        assert!(filter.matches(order));
+        assert!("layer".parse::<AttributeFilter>().unwrap().matches(order));
+        assert!(!"layer=api"
+            .parse::<AttributeFilter>()
+            .unwrap()
+            .matches(order));
+    }
+
+    #[test]
+    fn test_parse_filter() {
+        let filter: AttributeFilter = " team = payments ".parse().unwrap();
+        assert_eq!(filter.key, "team");
+        assert_eq!(filter.value.as_deref(), Some("payments"));
+        assert_eq!(filter.to_string(), "team=payments");
+        assert!("=x".parse::<AttributeFilter>().is_err());
+        assert!(!filter.matches_value(None));
+    }
+}
@@ -0,0 +1,468 @@
+//! Entry Point Detection
+//!
+//! Tags the symbols execution starts from with an `entry_point` attribute, so
+//! reachability-based analyses (dead code, impact) can start from them:
+//!
+//! - **main**: `main` functions, such as those of Go `main` packages under
+//!   `cmd/`
+//! - **test_main**: Go `TestMain` functions
+//! - **http_handler**: `ServeHTTP` methods, Go functions taking an
+//!   `http.ResponseWriter` and `*http.Request`, callables passed to route
+//!   registrations (`http.HandleFunc`, `mux.Handle`, `r.GET`, ...), and
+//!   route decorators (`@app.route`, `@router.get`)
+//! - **grpc_handler**: types passed to generated `Register...Server`
+//!   functions, and their exported methods
+//! - **scheduled**: callables passed to cron schedulers (`AddFunc`,
+//!   `AddJob`, ...) or decorated as periodic jobs
+//! - **consumer**: callables passed to queue and topic subscriptions
+//!   (`Subscribe`, `Consume`, ...) or decorated as tasks and listeners
+//!
+//! Registrations are recognized on the line of the reference to the
+//! registered symbol, so it has to be passed by name (or, for gRPC, as a
+//! `&server{}` literal) in the registration call rather than through a
+//! variable.
+
+use std::collections::HashMap;
+use std::str::FromStr;
+
+use serde::{Deserialize, Serialize};
+
+use crate::analysis::api_surface::is_type;
+use crate::analysis::package_of;
+use crate::file_policy::is_test_file;
+use crate::graph::{EdgeType, Node, NodeType, PetCodeGraph};
+
+/// Calls registering the callable passed to them, by the entry point it
+/// becomes
+const REGISTRATIONS: &[(&str, EntryPointKind)] = &[
+    ("HandleFunc(", EntryPointKind::HttpHandler),
+    ("HandlerFunc(", EntryPointKind::HttpHandler),
+    ("Handle(", EntryPointKind::HttpHandler),
+    (".GET(", EntryPointKind::HttpHandler),
+    (".POST(", EntryPointKind::HttpHandler),
+    (".PUT(", EntryPointKind::HttpHandler),
+    (".PATCH(", EntryPointKind::HttpHandler),
+    (".DELETE(", EntryPointKind::HttpHandler),
+    (".HEAD(", EntryPointKind::HttpHandler),
+    (".OPTIONS(", EntryPointKind::HttpHandler),
+    (".Any(", EntryPointKind::HttpHandler),
+    (".Get(", EntryPointKind::HttpHandler),
+    (".Post(", EntryPointKind::HttpHandler),
+    (".Put(", EntryPointKind::HttpHandler),
+    (".Patch(", EntryPointKind::HttpHandler),
+    (".Delete(", EntryPointKind::HttpHandler),
+    (".MethodFunc(", EntryPointKind::HttpHandler),
+    ("AddFunc(", EntryPointKind::Scheduled),
+    ("AddJob(", EntryPointKind::Scheduled),
+    (".Cron(", EntryPointKind::Scheduled),
+    (".Every(", EntryPointKind::Scheduled),
+    ("Subscribe(", EntryPointKind::Consumer),
+    ("Consume(", EntryPointKind::Consumer),
+    ("AddConsumer(", EntryPointKind::Consumer),
+    ("AddHandler(", EntryPointKind::Consumer),
+];
+
+/// Decorator names (last dotted segment, lowercased) marking an entry point
+const DECORATORS: &[(&str, EntryPointKind)] = &[
+    ("route", EntryPointKind::HttpHandler),
+    ("api_view", EntryPointKind::HttpHandler),
+    ("websocket", EntryPointKind::HttpHandler),
+    ("scheduled", EntryPointKind::Scheduled),
+    ("scheduled_job", EntryPointKind::Scheduled),
+    ("periodic_task", EntryPointKind::Scheduled),
+    ("cron", EntryPointKind::Scheduled),
+    ("task", EntryPointKind::Consumer),
+    ("shared_task", EntryPointKind::Consumer),
+    ("consumer", EntryPointKind::Consumer),
+    ("subscriber", EntryPointKind::Consumer),
+    ("kafkalistener", EntryPointKind::Consumer),
+    ("rabbitlistener", EntryPointKind::Consumer),
+    ("sqslistener", EntryPointKind::Consumer),
+];
+
+/// Route decorators named after an HTTP method (e.g., `@app.get`), which
+/// only count on an object
+const METHOD_DECORATORS: &[&str] = &["get", "post", "put", "patch", "delete"];
+
+/// Kinds of entry points.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum EntryPointKind {
+    /// Program `main` function
+    Main,
+    /// Go `TestMain`, which runs a package's tests
+    TestMain,
+    /// HTTP handler, registered on a router or implementing `http.Handler`
+    HttpHandler,
+    /// gRPC service implementation
+    GrpcHandler,
+    /// Job run by a scheduler
+    Scheduled,
+    /// Consumer of a queue or topic
+    Consumer,
+}
+
+impl EntryPointKind {
+    /// All entry point kinds
+    pub const ALL: [EntryPointKind; 6] = [
+        EntryPointKind::Main,
+        EntryPointKind::TestMain,
+        EntryPointKind::HttpHandler,
+        EntryPointKind::GrpcHandler,
+        EntryPointKind::Scheduled,
+        EntryPointKind::Consumer,
+    ];
+
+    /// Get the string representation
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            EntryPointKind::Main => "main",
+            EntryPointKind::TestMain => "test_main",
+            EntryPointKind::HttpHandler => "http_handler",
+            EntryPointKind::GrpcHandler => "grpc_handler",
+            EntryPointKind::Scheduled => "scheduled",
+            EntryPointKind::Consumer => "consumer",
+        }
+    }
+}
+
+impl FromStr for EntryPointKind {
+    type Err = String;
+
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        EntryPointKind::ALL
+            .into_iter()
+            .find(|kind| kind.as_str() == s.to_ascii_lowercase().replace('-', "_"))
+            .ok_or_else(|| {
+                format!(
+                    "Unknown entry point kind: '{}'. Valid values: main, test_main, \
+                     http_handler, grpc_handler, scheduled, consumer",
+                    s
+                )
+            })
+    }
+}
+
+/// Tag the entry points of `graph`, replacing any earlier tags, and return
+/// how many were found.
+///
+/// `read_source` returns the content of a file by its path in the graph;
+/// files it cannot read contribute no signature or registration matches.
+pub fn tag_entry_points<F>(graph: &mut PetCodeGraph, read_source: F) -> usize
+where
+    F: FnMut(&str) -> Option<String>,
+{
+    let found = detect(graph, read_source);
+    for node in graph.inner_mut().node_weights_mut() {
+        node.metadata.entry_point = found.get(&node.id).map(|kind| kind.as_str().to_string());
+    }
+    found.len()
+}
+
+/// Entry points of `graph` by node ID
+fn detect<F>(graph: &PetCodeGraph, mut read_source: F) -> HashMap<String, EntryPointKind>
+where
+    F: FnMut(&str) -> Option<String>,
+{
+    let mut files: HashMap<String, Option<Vec<String>>> = HashMap::new();
+    let mut line_of = |file: &str, line: usize| -> Option<String> {
+        files
+            .entry(file.to_string())
+            .or_insert_with(|| {
+                read_source(file).map(|content| content.lines().map(String::from).collect())
+            })
+            .as_ref()?
+            .get(line.checked_sub(1)?)
+            .cloned()
+    };
+
+    let mut found: HashMap<String, EntryPointKind> = HashMap::new();
+    for node in graph.iter_nodes() {
+        if node.node_type != NodeType::Callable {
+            continue;
+        }
+        let kind = declared_kind(graph, node).or_else(|| {
+            (node.file.ends_with(".go")
+                && line_of(&node.file, node.line).is_some_and(|text| is_http_signature(&text)))
+            .then_some(EntryPointKind::HttpHandler)
+        });
+        if let Some(kind) = kind {
+            found.insert(node.id.clone(), kind);
+        }
+    }
+
+    // Symbols passed to registration calls
+    let mut services: Vec<&Node> = Vec::new();
+    for (source, target, edge) in graph.edges_by_type(EdgeType::Uses) {
+        let Some(line) = edge.ref_line else {
+            continue;
+        };
+        if found.contains_key(&target.id) || !(target.is_callable() || is_type(target)) {
+            continue;
+        }
+        let Some(text) = line_of(&source.file, line) else {
+            continue;
+        };
+        let ident = edge.ident.as_deref().unwrap_or(&target.name);
+        let ident = ident.rsplit('.').next().unwrap_or(ident);
+        let Some(kind) = registration_kind(&text, ident) else {
+            continue;
+        };
+        if kind == EntryPointKind::GrpcHandler && is_type(target) {
+            services.push(target);
+        } else if target.is_callable() {
+            found.insert(target.id.clone(), kind);
+        }
+    }
+
+    // A registered gRPC service serves requests through its exported methods
+    for service in services {
+        found.insert(service.id.clone(), EntryPointKind::GrpcHandler);
+        let package = package_of(&service.file);
+        for method in graph.iter_nodes().filter(|n| {
+            n.metadata.receiver.as_deref() == Some(service.name.as_str())
+                && package_of(&n.file) == package
+                && n.name.starts_with(|c: char| c.is_uppercase())
+        }) {
+            found.insert(method.id.clone(), EntryPointKind::GrpcHandler);
+        }
+    }
+    found
+}
+
+/// Entry point kind evident from a callable's name or decorators
+fn declared_kind(graph: &PetCodeGraph, node: &Node) -> Option<EntryPointKind> {
+    let top_level = node.metadata.receiver.is_none()
+        && !graph
+            .parent(&node.id)
+            .is_some_and(|p| p.is_callable() || is_type(p));
+    match node.name.as_str() {
+        "main" if top_level && !is_test_file(&node.file) => return Some(EntryPointKind::Main),
+        "TestMain" if top_level && node.file.ends_with("_test.go") => {
+            return Some(EntryPointKind::TestMain)
+        }
+        "ServeHTTP" if node.metadata.receiver.is_some() => {
+            return Some(EntryPointKind::HttpHandler)
+        }
+        _ => {}
+    }
+
+    node.metadata
+        .decorators
+        .iter()
+        .flatten()
+        .find_map(|decorator| {
+            let (object, name) = match decorator.rsplit_once(['.', ':']) {
+                Some((object, name)) => (Some(object), name),
+                None => (None, decorator.as_str()),
+            };
+            let name = name.to_ascii_lowercase();
+            if object.is_some() && METHOD_DECORATORS.contains(&name.as_str()) {
+                return Some(EntryPointKind::HttpHandler);
+            }
+            DECORATORS
+                .iter()
+                .find(|(decorator, _)| *decorator == name)
+                .map(|(_, kind)| *kind)
+        })
+}
+
+/// Check if a Go declaration line has the `http.HandlerFunc` signature
+fn is_http_signature(text: &str) -> bool {
+    text.trim_start().starts_with("func ")
+        && text.contains("http.ResponseWriter")
+        && text.contains("*http.Request")
+}
+
+/// Kind of entry point `ident` becomes if the line registers it
+fn registration_kind(text: &str, ident: &str) -> Option<EntryPointKind> {
+    if let Some(start) = grpc_registration(text) {
+        if passes(&text[start..], ident) {
+            return Some(EntryPointKind::GrpcHandler);
+        }
+    }
+    REGISTRATIONS.iter().find_map(|(call, kind)| {
+        let start = text.find(call)? + call.len();
+        passes(&text[start..], ident).then_some(*kind)
+    })
+}
+
+/// Position after the opening parenthesis of a generated
+/// `Register<Service>Server(` call
+fn grpc_registration(text: &str) -> Option<usize> {
+    let mut from = 0;
+    while let Some(offset) = text[from..].find("Register") {
+        let start = from + offset;
+        let rest = &text[start..];
+        let name_len = rest
+            .find(|c: char| !(c.is_alphanumeric() || c == '_'))
+            .unwrap_or(rest.len());
+        if rest[..name_len].ends_with("Server") && rest[name_len..].starts_with('(') {
+            return Some(start + name_len + 1);
+        }
+        from = start + "Register".len();
+    }
+    None
+}
+
+/// Check whether `ident` is passed in the arguments rather than called
+fn passes(args: &str, ident: &str) -> bool {
+    let is_word = |c: char| c.is_alphanumeric() || c == '_';
+    args.match_indices(ident).any(|(index, _)| {
+        let before = args[..index].chars().next_back();
+        let after = args[index + ident.len()..].trim_start().chars().next();
+        !before.is_some_and(is_word) && !after.is_some_and(|c| is_word(c) || c == '(')
+    })
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::{CallableKind, ContainerKind, EdgeData};
+
+    const MAIN: &str = "package main
+
+func main() {
+	http.HandleFunc(\"/health\", health)
+	mux.Handle(\"/api\", newAPI())
+	c.AddFunc(\"@hourly\", cleanup)
+	pb.RegisterGreeterServer(s, &greeter{})
+	nc.Subscribe(\"orders\", onOrder)
+}
+";
+
+    const HANDLERS: &str = "package main
+
+func list(w http.ResponseWriter, r *http.Request) {}
+";
+
+    fn add_fn(graph: &mut PetCodeGraph, file: &str, name: &str, receiver: Option<&str>) {
+        let mut node = Node::callable(
+            format!("{}:{}", file, name),
+            name.to_string(),
+            CallableKind::Function,
+            file.to_string(),
+            3,
+            3,
+        );
+        node.metadata.receiver = receiver.map(String::from);
+        graph.add_node(node);
+    }
+
+    fn sample_graph() -> PetCodeGraph {
+        let mut graph = PetCodeGraph::new();
+        for name in ["main", "health", "newAPI", "cleanup", "onOrder"] {
+            add_fn(&mut graph, "cmd/api/main.go", name, None);
+        }
+        add_fn(&mut graph, "cmd/api/handlers.go", "list", None);
+        add_fn(&mut graph, "cmd/api/api.go", "ServeHTTP", Some("api"));
+        add_fn(&mut graph, "cmd/api/main_test.go", "TestMain", None);
+        graph.add_node(Node::container(
+            "cmd/api/greeter.go:greeter".to_string(),
+            "greeter".to_string(),
+            ContainerKind::Type,
+            Some("struct".to_string()),
+            "cmd/api/greeter.go".to_string(),
+            3,
+            3,
+        ));
+        add_fn(
+            &mut graph,
+            "cmd/api/greeter.go",
+            "SayHello",
+            Some("greeter"),
+        );
+        add_fn(&mut graph, "cmd/api/greeter.go", "reply", Some("greeter"));
+
+        let mut task = Node::callable(
+            "worker/tasks.py:send_mail".to_string(),
+            "send_mail".to_string(),
+            CallableKind::Function,
+            "worker/tasks.py".to_string(),
+            2,
+            3,
+        );
+        task.metadata.decorators = Some(vec!["app.task".to_string()]);
+        graph.add_node(task);
+
+        for (line, target) in [
+            (4, "cmd/api/main.go:health"),
+            (5, "cmd/api/main.go:newAPI"),
+            (6, "cmd/api/main.go:cleanup"),
+            (7, "cmd/api/greeter.go:greeter"),
+            (8, "cmd/api/main.go:onOrder"),
+        ] {
+            graph.add_edge(
+                "cmd/api/main.go:main",
+                target,
+                EdgeData::uses(Some(line), None),
+            );
+        }
+        graph
+    }
+
+    #[test]
+    fn test_tag_entry_points() {
+        let mut graph = sample_graph();
+        let count = tag_entry_points(&mut graph, |file| match file {
+            "cmd/api/main.go" => Some(MAIN.to_string()),
+            "cmd/api/handlers.go" => Some(HANDLERS.to_string()),
+            _ => None,
+        });
+
+        let kind_of = |id: &str| graph.get_node(id).unwrap().metadata.entry_point.clone();
+        assert_eq!(kind_of("cmd/api/main.go:main").as_deref(), Some("main"));
+        assert_eq!(
+            kind_of("cmd/api/main_test.go:TestMain").as_deref(),
+            Some("test_main")
+        );
+        for id in [
+            "cmd/api/main.go:health",
+            "cmd/api/handlers.go:list",
+            "cmd/api/api.go:ServeHTTP",
+        ] {
+            assert_eq!(kind_of(id).as_deref(), Some("http_handler"), "{}", id);
+        }
+        assert_eq!(
+            kind_of("cmd/api/main.go:cleanup").as_deref(),
+            Some("scheduled")
+        );
+        assert_eq!(
+            kind_of("cmd/api/main.go:onOrder").as_deref(),
+            Some("consumer")
+        );
+        assert_eq!(
+            kind_of("worker/tasks.py:send_mail").as_deref(),
+            Some("consumer")
+        );
+        assert_eq!(
+            kind_of("cmd/api/greeter.go:SayHello").as_deref(),
+            Some("grpc_handler")
+        );
+
+        // Called rather than passed, or unexported on a service
+        assert_eq!(kind_of("cmd/api/main.go:newAPI"), None);
+        assert_eq!(kind_of("cmd/api/greeter.go:reply"), None);
+        assert_eq!(count, 10);
+    }
+
+    #[test]
+    fn test_registration_kind() {
+        assert_eq!(
+            registration_kind("\tr.GET(\"/users\", h.listUsers)", "listUsers"),
+            Some(EntryPointKind::HttpHandler)
+        );
+        assert_eq!(registration_kind("\tcache.Get(key(id))", "key"), None);
+        assert_eq!(
+            registration_kind(
+                "\tpb.RegisterFooServer(srv, &fooServer{db: db})",
+                "fooServer"
+            ),
+            Some(EntryPointKind::GrpcHandler)
+        );
+        assert_eq!(
+            "grpc-handler".parse::<EntryPointKind>(),
+            Ok(EntryPointKind::GrpcHandler)
+        );
+    }
+}
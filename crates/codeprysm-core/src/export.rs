@@ -0,0 +1,1010 @@
+//! Graph Export
+//!
+//! Renders a `PetCodeGraph` (typically a bounded slice of the full graph) in
+//! formats understood by visualization tools and LLM pipelines:
+//!
+//! - **JSON**: `{"schema_version": ..., "nodes": [...], "edges": [...]}`
+//!   using the graph schema
+//! - **DOT**: Graphviz digraph
+//! - **Mermaid**: flowchart for Markdown renderers
+//! - **GraphML**: XML for Gephi, yEd, and NetworkX
+//!
+//! Exports carry the [`Provenance`] of the index they were taken from, when
+//! given: as a `provenance` object in JSON, as comments in DOT and Mermaid,
+//! and as graph attributes in GraphML.
+//!
+//! User-defined node attributes (see [`crate::enrichment`]) are part of the
+//! node metadata in JSON and are written as `attr.<key>` node data in
+//! GraphML.
+//!
+//! Source permalinks (see [`crate::source_links`]) are the `url` of nodes in
+//! JSON, `URL` attributes in DOT, `click` links in Mermaid, and `url` node
+//! data in GraphML.
+//!
+//! Type usage weights (see [`crate::type_usage`]) are the `weight` of types
+//! and edges in JSON, the `weight` of edges in DOT, and `weight` node and edge
+//! data in GraphML.
+//!
+//! An [`ExportFilter`] slims a graph down before export, e.g. to the exported
+//! API of non-test, hand-written code, for documentation or LLM context. An
+//! [`ExportBudget`] then caps its size: it keeps the most connected nodes and
+//! collapses the rest into aggregate nodes, so a diagram stays readable and a
+//! prompt stays within its context window.
+//!
+//! Output is deterministic: nodes are sorted by ID and edges by source,
+//! target, type, and their remaining attributes (see [`Edge::stable_cmp`]),
+//! so the same graph exports byte for byte the same however it was built and
+//! exports can be committed and diffed.
+
+use std::cmp::Reverse;
+use std::collections::{BTreeMap, BTreeSet, HashMap, HashSet};
+use std::fmt::Write;
+
+use serde::{Deserialize, Serialize};
+
+use crate::analysis::api_surface::{is_exported, is_type};
+use crate::analysis::dead_code::is_test_code;
+use crate::graph::{Edge, Node, NodeMetadata, NodeType, PetCodeGraph, GRAPH_SCHEMA_VERSION};
+use crate::provenance::Provenance;
+
+/// Supported export formats.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum ExportFormat {
+    /// JSON nodes and edges
+    Json,
+    /// Graphviz DOT
+    Dot,
+    /// Mermaid flowchart
+    Mermaid,
+    /// GraphML XML
+    GraphMl,
+}
+
+impl ExportFormat {
+    /// All formats
+    pub const ALL: [ExportFormat; 4] = [
+        ExportFormat::Json,
+        ExportFormat::Dot,
+        ExportFormat::Mermaid,
+        ExportFormat::GraphMl,
+    ];
+
+    /// Get the string representation
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            ExportFormat::Json => "json",
+            ExportFormat::Dot => "dot",
+            ExportFormat::Mermaid => "mermaid",
+            ExportFormat::GraphMl => "graphml",
+        }
+    }
+}
+
+impl std::str::FromStr for ExportFormat {
+    type Err = String;
+
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        ExportFormat::ALL
+            .into_iter()
+            .find(|f| f.as_str().eq_ignore_ascii_case(s))
+            .ok_or_else(|| {
+                format!(
+                    "Unknown export format: {} (expected json, dot, mermaid, or graphml)",
+                    s
+                )
+            })
+    }
+}
+
+/// Node filters applied before export.
+///
+/// Edges are kept when both of their ends are. The default filter keeps
+/// everything.
+#[derive(Debug, Clone, Default, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(default)]
+pub struct ExportFilter {
+    /// Keep only exported symbols, and the files and namespaces holding them
+    pub only_exported: bool,
+    /// Drop test files and the symbols in them or in test-only scopes
+    pub exclude_tests: bool,
+    /// Drop generated files and the symbols in them
+    pub exclude_generated: bool,
+    /// Drop symbols nested more than this many levels below their file
+    /// (1 keeps top-level declarations only)
+    pub max_depth: Option<usize>,
+}
+
+impl ExportFilter {
+    /// Check if the filter keeps every node
+    pub fn is_empty(&self) -> bool {
+        *self == Self::default()
+    }
+
+    /// Check if a node passes the filter
+    pub fn keeps(&self, graph: &PetCodeGraph, node: &Node) -> bool {
+        // Files, packages, and namespaces stay to hold the exported symbols
+        let structural = node.node_type == NodeType::Container && !is_type(node);
+        if self.only_exported && !structural && !is_exported(graph, node) {
+            return false;
+        }
+        if self.exclude_tests && is_test_code(node) {
+            return false;
+        }
+        if self.exclude_generated
+            && graph
+                .get_node(&node.file)
+                .and_then(|file| file.metadata.is_generated)
+                .unwrap_or(false)
+        {
+            return false;
+        }
+        match self.max_depth {
+            Some(max_depth) => containment_depth(graph, node) <= max_depth,
+            None => true,
+        }
+    }
+
+    /// Copy the nodes of `slice` that pass the filter and the edges among
+    /// them.
+    ///
+    /// Nodes are judged in `full`, the graph the slice was taken from, since
+    /// a slice may lack their files and enclosing types. Pass the same graph
+    /// twice to filter a whole graph.
+    pub fn apply(&self, slice: &PetCodeGraph, full: &PetCodeGraph) -> PetCodeGraph {
+        let mut filtered = PetCodeGraph::new();
+        let mut keep: HashSet<&str> = HashSet::new();
+        for node in slice.iter_nodes() {
+            if self.keeps(full, node) {
+                keep.insert(node.id.as_str());
+                filtered.add_node(node.clone());
+            }
+        }
+        for edge in slice.iter_edges() {
+            if keep.contains(edge.source.as_str()) && keep.contains(edge.target.as_str()) {
+                filtered.add_edge_from_struct(&edge);
+            }
+        }
+        filtered
+    }
+}
+
+/// Kind of the nodes standing in for nodes collapsed by an [`ExportBudget`]
+pub const AGGREGATE_KIND: &str = "aggregate";
+
+/// Attribute holding the number of nodes an aggregate node stands for
+pub const AGGREGATED_ATTRIBUTE: &str = "aggregated";
+
+/// Size limits applied to a graph before export.
+///
+/// Nodes are ranked by degree (edges in and out), most connected first. The
+/// top-ranked nodes are kept, and the other nodes next to one are collapsed
+/// into a single aggregate node per kept neighbor, connected to it by the
+/// types of the edges it replaces. Nodes with no kept neighbor are omitted.
+/// When edges are over budget, those between the most connected nodes are
+/// kept. The default budget is unlimited.
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(default)]
+pub struct ExportBudget {
+    /// Maximum number of nodes, aggregates included
+    pub max_nodes: Option<usize>,
+    /// Maximum number of edges
+    pub max_edges: Option<usize>,
+}
+
+/// A graph cut down to an [`ExportBudget`].
+#[derive(Debug, Clone)]
+pub struct SampledGraph {
+    /// The sampled graph
+    pub graph: PetCodeGraph,
+    /// Nodes collapsed into aggregate nodes
+    pub collapsed: usize,
+    /// Aggregate nodes added
+    pub aggregates: usize,
+    /// Nodes dropped without an aggregate
+    pub omitted: usize,
+    /// Edges dropped, other than those of collapsed or omitted nodes
+    pub dropped_edges: usize,
+}
+
+impl SampledGraph {
+    /// Check whether the budget changed the graph
+    pub fn is_sampled(&self) -> bool {
+        self.collapsed + self.omitted + self.dropped_edges > 0
+    }
+}
+
+impl ExportBudget {
+    /// Check if the budget keeps every graph as is
+    pub fn is_unlimited(&self) -> bool {
+        self.max_nodes.is_none() && self.max_edges.is_none()
+    }
+
+    /// Cut a graph down to the budget.
+    ///
+    /// `pinned` nodes (e.g. the center of a subgraph) rank before all others.
+    pub fn apply(&self, graph: &PetCodeGraph, pinned: &[&str]) -> SampledGraph {
+        let over = |max: Option<usize>, count: usize| matches!(max, Some(max) if count > max);
+        if !over(self.max_nodes, graph.node_count()) && !over(self.max_edges, graph.edge_count()) {
+            return SampledGraph {
+                graph: graph.clone(),
+                collapsed: 0,
+                aggregates: 0,
+                omitted: 0,
+                dropped_edges: 0,
+            };
+        }
+
+        let edges: Vec<Edge> = graph.iter_edges().collect();
+        let mut degree: HashMap<&str, usize> = HashMap::new();
+        for edge in &edges {
+            *degree.entry(&edge.source).or_default() += 1;
+            *degree.entry(&edge.target).or_default() += 1;
+        }
+        let degree_of = |id: &str| degree.get(id).copied().unwrap_or(0);
+
+        let mut ranked: Vec<&Node> = graph.iter_nodes().collect();
+        ranked.sort_by(|a, b| {
+            let key = |n: &Node| (!pinned.contains(&n.id.as_str()), Reverse(degree_of(&n.id)));
+            key(a).cmp(&key(b)).then_with(|| a.id.cmp(&b.id))
+        });
+        let rank: HashMap<&str, usize> = ranked
+            .iter()
+            .enumerate()
+            .map(|(i, node)| (node.id.as_str(), i))
+            .collect();
+
+        // The most nodes that leave room for the aggregates of the rest
+        let kept = match self.max_nodes {
+            Some(max) if ranked.len() > max => {
+                let fits = |k: usize| k + count_anchors(&edges, &rank, k) <= max;
+                let (mut low, mut high) = (0, max);
+                while low < high {
+                    let mid = (low + high).div_ceil(2);
+                    if fits(mid) {
+                        low = mid;
+                    } else {
+                        high = mid - 1;
+                    }
+                }
+                low
+            }
+            _ => ranked.len(),
+        };
+        let anchors = anchors(&edges, &rank, kept);
+
+        let mut sampled = PetCodeGraph::new();
+        for node in &ranked[..kept] {
+            sampled.add_node((*node).clone());
+        }
+        let mut members: BTreeMap<&str, Vec<&Node>> = BTreeMap::new();
+        for node in &ranked[kept..] {
+            if let Some(anchor) = anchors.get(node.id.as_str()) {
+                members.entry(anchor).or_default().push(node);
+            }
+        }
+        let collapsed: usize = members.values().map(Vec::len).sum();
+        for (anchor, nodes) in &members {
+            sampled.add_node(aggregate_node(graph, anchor, nodes));
+        }
+
+        // Edges among kept nodes, and one per type and direction between an
+        // aggregate and its anchor; ranked by the degree of their ends
+        let mut candidates: Vec<(usize, Edge)> = Vec::new();
+        let mut seen: HashSet<(String, String, &str)> = HashSet::new();
+        for edge in &edges {
+            let ends = [edge.source.as_str(), edge.target.as_str()].map(|id| match rank.get(id) {
+                Some(&i) if i < kept => Some(id.to_string()),
+                _ => anchors
+                    .get(id)
+                    .filter(|anchor| **anchor == other_end(edge, id))
+                    .map(|anchor| aggregate_id(anchor)),
+            });
+            let [Some(source), Some(target)] = ends else {
+                continue;
+            };
+            let aggregated = source != edge.source || target != edge.target;
+            if aggregated && !seen.insert((source.clone(), target.clone(), edge.edge_type.as_str()))
+            {
+                continue;
+            }
+            let score = degree_of(&edge.source) + degree_of(&edge.target);
+            let mut edge = edge.clone();
+            if aggregated {
+                edge.ref_line = None;
+                edge.ident = None;
+            }
+            edge.source = source;
+            edge.target = target;
+            candidates.push((score, edge));
+        }
+        let mut dropped_edges = 0;
+        if let Some(max) = self.max_edges {
+            if candidates.len() > max {
+                candidates.sort_by(|(a_score, a), (b_score, b)| {
+                    let pinned_end = |e: &Edge| {
+                        !(pinned.contains(&e.source.as_str())
+                            || pinned.contains(&e.target.as_str()))
+                    };
+                    (pinned_end(a), Reverse(*a_score))
+                        .cmp(&(pinned_end(b), Reverse(*b_score)))
+                        .then_with(|| a.stable_cmp(b))
+                });
+                dropped_edges = candidates.len() - max;
+                candidates.truncate(max);
+            }
+        }
+        for (_, edge) in &candidates {
+            sampled.add_edge_from_struct(edge);
+        }
+
+        SampledGraph {
+            graph: sampled,
+            collapsed,
+            aggregates: members.len(),
+            omitted: ranked.len() - kept - collapsed,
+            dropped_edges,
+        }
+    }
+}
+
+/// Number of aggregate nodes needed when keeping the `kept` top-ranked nodes
+fn count_anchors(edges: &[Edge], rank: &HashMap<&str, usize>, kept: usize) -> usize {
+    anchors(edges, rank, kept)
+        .into_values()
+        .collect::<HashSet<_>>()
+        .len()
+}
+
+/// Map each dropped node next to a kept one to its best-ranked kept neighbor
+fn anchors<'a>(
+    edges: &'a [Edge],
+    rank: &HashMap<&str, usize>,
+    kept: usize,
+) -> HashMap<&'a str, &'a str> {
+    let mut anchors: HashMap<&str, (usize, &str)> = HashMap::new();
+    for edge in edges {
+        let (Some(&source), Some(&target)) = (
+            rank.get(edge.source.as_str()),
+            rank.get(edge.target.as_str()),
+        ) else {
+            continue;
+        };
+        let (dropped, anchor, anchor_rank) = match (source < kept, target < kept) {
+            (true, false) => (&edge.target, &edge.source, source),
+            (false, true) => (&edge.source, &edge.target, target),
+            _ => continue,
+        };
+        let best = anchors
+            .entry(dropped.as_str())
+            .or_insert((anchor_rank, anchor.as_str()));
+        if anchor_rank < best.0 {
+            *best = (anchor_rank, anchor.as_str());
+        }
+    }
+    anchors
+        .into_iter()
+        .map(|(dropped, (_, anchor))| (dropped, anchor))
+        .collect()
+}
+
+/// The end of an edge other than `id`
+fn other_end<'a>(edge: &'a Edge, id: &str) -> &'a str {
+    if edge.source == id {
+        &edge.target
+    } else {
+        &edge.source
+    }
+}
+
+/// ID of the aggregate node of a kept node's collapsed neighbors
+fn aggregate_id(anchor: &str) -> String {
+    format!("{}#aggregate", anchor)
+}
+
+/// Aggregate node standing in for the collapsed neighbors of `anchor`, typed
+/// like the best-ranked of them
+fn aggregate_node(graph: &PetCodeGraph, anchor: &str, members: &[&Node]) -> Node {
+    let location = graph.get_node(anchor);
+    Node {
+        id: aggregate_id(anchor),
+        name: format!("{} more", members.len()),
+        node_type: members[0].node_type,
+        kind: Some(AGGREGATE_KIND.to_string()),
+        subtype: None,
+        file: location.map(|n| n.file.clone()).unwrap_or_default(),
+        line: location.map_or(0, |n| n.line),
+        end_line: location.map_or(0, |n| n.end_line),
+        text: None,
+        metadata: NodeMetadata {
+            attributes: Some(BTreeMap::from([(
+                AGGREGATED_ATTRIBUTE.to_string(),
+                members.len().to_string(),
+            )])),
+            ..NodeMetadata::default()
+        },
+        hash: None,
+    }
+}
+
+/// Number of containment levels between a node and its file (0 for files
+/// and for nodes outside any file)
+fn containment_depth(graph: &PetCodeGraph, node: &Node) -> usize {
+    if node.is_file() {
+        return 0;
+    }
+    let mut depth = 0;
+    let mut current = node;
+    while let Some(parent) = graph.parent(&current.id) {
+        depth += 1;
+        if parent.is_file() {
+            return depth;
+        }
+        current = parent;
+    }
+    0
+}
+
+/// Render a graph in the given format, with the provenance of its index if
+/// known.
+pub fn export_graph(
+    graph: &PetCodeGraph,
+    format: ExportFormat,
+    provenance: Option<&Provenance>,
+) -> String {
+    let mut nodes: Vec<&Node> = graph.iter_nodes().collect();
+    nodes.sort_by(|a, b| a.id.cmp(&b.id));
+    let mut edges: Vec<Edge> = graph.iter_edges().collect();
+    edges.sort_by(Edge::stable_cmp);
+
+    match format {
+        ExportFormat::Json => to_json(&nodes, &edges, provenance),
+        ExportFormat::Dot => to_dot(&nodes, &edges, provenance),
+        ExportFormat::Mermaid => to_mermaid(&nodes, &edges, provenance),
+        ExportFormat::GraphMl => to_graphml(&nodes, &edges, provenance),
+    }
+}
+
+/// Short label for a node: name and kind
+fn label(node: &Node) -> String {
+    format!(
+        "{} ({})",
+        node.name,
+        node.kind.as_deref().unwrap_or(node.node_type.as_str())
+    )
+}
+
+fn to_json(nodes: &[&Node], edges: &[Edge], provenance: Option<&Provenance>) -> String {
+    let mut output = serde_json::json!({
+        "schema_version": GRAPH_SCHEMA_VERSION,
+    });
+    if let Some(provenance) = provenance {
+        output["provenance"] = serde_json::json!(provenance);
+    }
+    output["nodes"] = serde_json::json!(nodes);
+    output["edges"] = serde_json::json!(edges);
+    serde_json::to_string_pretty(&output).unwrap_or_default()
+}
+
+fn to_dot(nodes: &[&Node], edges: &[Edge], provenance: Option<&Provenance>) -> String {
+    let quote = |s: &str| format!("\"{}\"", s.replace('\\', "\\\\").replace('"', "\\\""));
+
+    let mut out = String::new();
+    for (key, value) in provenance.map(Provenance::to_pairs).unwrap_or_default() {
+        let _ = writeln!(out, "// {}: {}", key, value);
+    }
+    out.push_str("digraph codeprysm {\n  rankdir=LR;\n  node [shape=box];\n");
+    for node in nodes {
+        let url = match node.metadata.url {
+            Some(ref url) => format!(", URL={}", quote(url)),
+            None => String::new(),
+        };
+        let _ = writeln!(
+            out,
+            "  {} [label={}{}];",
+            quote(&node.id),
+            quote(&label(node)),
+            url
+        );
+    }
+    for edge in edges {
+        let weight = match edge.weight {
+            Some(weight) => format!(", weight={}", weight),
+            None => String::new(),
+        };
+        let _ = writeln!(
+            out,
+            "  {} -> {} [label={}{}];",
+            quote(&edge.source),
+            quote(&edge.target),
+            quote(edge.edge_type.as_str()),
+            weight
+        );
+    }
+    out.push_str("}\n");
+    out
+}
+
+fn to_mermaid(nodes: &[&Node], edges: &[Edge], provenance: Option<&Provenance>) -> String {
+    // Mermaid IDs cannot contain most punctuation, so number the nodes
+    let index: std::collections::HashMap<&str, usize> = nodes
+        .iter()
+        .enumerate()
+        .map(|(i, n)| (n.id.as_str(), i))
+        .collect();
+
+    let mut out = String::from("flowchart LR\n");
+    for (key, value) in provenance.map(Provenance::to_pairs).unwrap_or_default() {
+        let _ = writeln!(out, "  %% {}: {}", key, value);
+    }
+    for (i, node) in nodes.iter().enumerate() {
+        let _ = writeln!(out, "  n{}[\"{}\"]", i, label(node).replace('"', "#quot;"));
+    }
+    for (i, node) in nodes.iter().enumerate() {
+        if let Some(ref url) = node.metadata.url {
+            let _ = writeln!(out, "  click n{} href \"{}\"", i, url.replace('"', "%22"));
+        }
+    }
+    for edge in edges {
+        if let (Some(source), Some(target)) = (
+            index.get(edge.source.as_str()),
+            index.get(edge.target.as_str()),
+        ) {
+            let _ = writeln!(
+                out,
+                "  n{} -->|{}| n{}",
+                source,
+                edge.edge_type.as_str(),
+                target
+            );
+        }
+    }
+    out
+}
+
+fn to_graphml(nodes: &[&Node], edges: &[Edge], provenance: Option<&Provenance>) -> String {
+    let pairs = provenance.map(Provenance::to_pairs).unwrap_or_default();
+    let attribute_keys: BTreeSet<&str> = nodes
+        .iter()
+        .flat_map(|node| node.metadata.attributes.iter().flatten())
+        .map(|(key, _)| key.as_str())
+        .collect();
+    let mut out = String::from(concat!(
+        "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n",
+        "<graphml xmlns=\"http://graphml.graphdrawing.org/xmlns\">\n",
+        "  <key id=\"name\" for=\"node\" attr.name=\"name\" attr.type=\"string\"/>\n",
+        "  <key id=\"type\" for=\"node\" attr.name=\"type\" attr.type=\"string\"/>\n",
+        "  <key id=\"kind\" for=\"node\" attr.name=\"kind\" attr.type=\"string\"/>\n",
+        "  <key id=\"file\" for=\"node\" attr.name=\"file\" attr.type=\"string\"/>\n",
+        "  <key id=\"line\" for=\"node\" attr.name=\"line\" attr.type=\"int\"/>\n",
+        "  <key id=\"url\" for=\"node\" attr.name=\"url\" attr.type=\"string\"/>\n",
+        "  <key id=\"weight\" for=\"node\" attr.name=\"weight\" attr.type=\"int\"/>\n",
+        "  <key id=\"edge_type\" for=\"edge\" attr.name=\"type\" attr.type=\"string\"/>\n",
+        "  <key id=\"edge_weight\" for=\"edge\" attr.name=\"weight\" attr.type=\"int\"/>\n",
+    ));
+    for key in &attribute_keys {
+        let _ = writeln!(
+            out,
+            "  <key id=\"attr.{0}\" for=\"node\" attr.name=\"{0}\" attr.type=\"string\"/>",
+            xml_escape(key)
+        );
+    }
+    for (key, _) in &pairs {
+        let _ = writeln!(
+            out,
+            "  <key id=\"{0}\" for=\"graph\" attr.name=\"{0}\" attr.type=\"string\"/>",
+            xml_escape(key)
+        );
+    }
+    out.push_str("  <graph id=\"codeprysm\" edgedefault=\"directed\">\n");
+    for (key, value) in &pairs {
+        let _ = writeln!(
+            out,
+            "    <data key=\"{}\">{}</data>",
+            xml_escape(key),
+            xml_escape(value)
+        );
+    }
+    for node in nodes {
+        let _ = writeln!(out, "    <node id=\"{}\">", xml_escape(&node.id));
+        let _ = writeln!(
+            out,
+            "      <data key=\"name\">{}</data>",
+            xml_escape(&node.name)
+        );
+        let _ = writeln!(
+            out,
+            "      <data key=\"type\">{}</data>",
+            node.node_type.as_str()
+        );
+        if let Some(ref kind) = node.kind {
+            let _ = writeln!(out, "      <data key=\"kind\">{}</data>", xml_escape(kind));
+        }
+        let _ = writeln!(
+            out,
+            "      <data key=\"file\">{}</data>",
+            xml_escape(&node.file)
+        );
+        let _ = writeln!(out, "      <data key=\"line\">{}</data>", node.line);
+        if let Some(ref url) = node.metadata.url {
+            let _ = writeln!(out, "      <data key=\"url\">{}</data>", xml_escape(url));
+        }
+        if let Some(weight) = node.metadata.weight {
+            let _ = writeln!(out, "      <data key=\"weight\">{}</data>", weight);
+        }
+        for (key, value) in node.metadata.attributes.iter().flatten() {
+            let _ = writeln!(
+                out,
+                "      <data key=\"attr.{}\">{}</data>",
+                xml_escape(key),
+                xml_escape(value)
+            );
+        }
+        out.push_str("    </node>\n");
+    }
+    for edge in edges {
+        let _ = writeln!(
+            out,
+            "    <edge source=\"{}\" target=\"{}\">\n      <data key=\"edge_type\">{}</data>",
+            xml_escape(&edge.source),
+            xml_escape(&edge.target),
+            edge.edge_type.as_str()
+        );
+        if let Some(weight) = edge.weight {
+            let _ = writeln!(out, "      <data key=\"edge_weight\">{}</data>", weight);
+        }
+        out.push_str("    </edge>\n");
+    }
+    out.push_str("  </graph>\n</graphml>\n");
+    out
+}
+
+/// Escape text for XML content and attributes
+fn xml_escape(text: &str) -> String {
+    text.replace('&', "&amp;")
+        .replace('<', "&lt;")
+        .replace('>', "&gt;")
+        .replace('"', "&quot;")
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::{CallableKind, ContainerKind, EdgeData, EdgeType, NodeMetadata};
+
+    fn sample_graph() -> PetCodeGraph {
+        let mut graph = PetCodeGraph::new();
+        for (id, name) in [("a.go:Run", "Run"), ("a.go:parse<T>", "parse<T>")] {
+            graph.add_node(Node::callable(
+                id.to_string(),
+                name.to_string(),
+                CallableKind::Function,
+                "a.go".to_string(),
+                1,
+                5,
+            ));
+        }
+        graph.add_edge("a.go:Run", "a.go:parse<T>", EdgeData::uses(Some(3), None));
+        graph
+    }
+
+    #[test]
+    fn test_export_formats() {
+        let graph = sample_graph();
+
+        let json: serde_json::Value =
+            serde_json::from_str(&export_graph(&graph, ExportFormat::Json, None)).unwrap();
+        assert_eq!(json["nodes"].as_array().unwrap().len(), 2);
+        assert_eq!(json["edges"][0]["type"], "USES");
+
+        let dot = export_graph(&graph, ExportFormat::Dot, None);
+        assert!(dot.contains("\"a.go:Run\" -> \"a.go:parse<T>\" [label=\"USES\"];"));
+
+        let mermaid = export_graph(&graph, ExportFormat::Mermaid, None);
+        assert!(mermaid.contains("n0 -->|USES| n1"));
+
+        let graphml = export_graph(&graph, ExportFormat::GraphMl, None);
+        assert!(graphml.contains("<node id=\"a.go:parse&lt;T&gt;\">"));
+    }
+
+    #[test]
+    fn test_export_ignores_insertion_order() {
+        let mut forward = sample_graph();
+        forward.add_edge("a.go:Run", "a.go:parse<T>", EdgeData::uses(Some(4), None));
+        forward.add_edge("a.go:parse<T>", "a.go:Run", EdgeData::uses(Some(2), None));
+
+        let mut backward = PetCodeGraph::new();
+        for node in forward.iter_nodes().collect::<Vec<_>>().into_iter().rev() {
+            backward.add_node(node.clone());
+        }
+        let mut edges: Vec<Edge> = forward.iter_edges().collect();
+        edges.reverse();
+        for edge in &edges {
+            backward.add_edge_from_struct(edge);
+        }
+
+        for format in ExportFormat::ALL {
+            assert_eq!(
+                export_graph(&forward, format, None),
+                export_graph(&backward, format, None)
+            );
+        }
+    }
+
+    #[test]
+    fn test_export_provenance() {
+        let graph = sample_graph();
+        let dir = tempfile::tempdir().unwrap();
+        let provenance = Provenance::collect(dir.path());
+
+        let json: serde_json::Value =
+            serde_json::from_str(&export_graph(&graph, ExportFormat::Json, Some(&provenance)))
+                .unwrap();
+        assert_eq!(
+            json["provenance"]["tool_version"],
+            env!("CARGO_PKG_VERSION")
+        );
+        assert_eq!(json["nodes"].as_array().unwrap().len(), 2);
+
+        let dot = export_graph(&graph, ExportFormat::Dot, Some(&provenance));
+        assert!(dot.starts_with("// tool_version: "));
+        let mermaid = export_graph(&graph, ExportFormat::Mermaid, Some(&provenance));
+        assert!(mermaid.contains("  %% schema_version: "));
+        let graphml = export_graph(&graph, ExportFormat::GraphMl, Some(&provenance));
+        assert!(graphml.contains("<key id=\"indexed_at\" for=\"graph\""));
+        assert!(graphml.contains("<data key=\"grammar.go\">abi "));
+    }
+
+    #[test]
+    fn test_export_attributes() {
+        let mut graph = sample_graph();
+        graph.get_node_mut("a.go:Run").unwrap().metadata.attributes =
+            Some([("layer".to_string(), "domain".to_string())].into());
+
+        let json: serde_json::Value =
+            serde_json::from_str(&export_graph(&graph, ExportFormat::Json, None)).unwrap();
+        assert_eq!(
+            json["nodes"][0]["metadata"]["attributes"]["layer"],
+            "domain"
+        );
+
+        let graphml = export_graph(&graph, ExportFormat::GraphMl, None);
+        assert!(graphml.contains("<key id=\"attr.layer\" for=\"node\" attr.name=\"layer\""));
+        assert!(graphml.contains("<data key=\"attr.layer\">domain</data>"));
+    }
+
+    #[test]
+    fn test_export_source_links() {
+        let mut graph = sample_graph();
+        let url = "https://github.com/org/repo/blob/abc/a.go#L1-L5";
+        graph.get_node_mut("a.go:Run").unwrap().metadata.url = Some(url.to_string());
+
+        let json: serde_json::Value =
+            serde_json::from_str(&export_graph(&graph, ExportFormat::Json, None)).unwrap();
+        assert_eq!(json["nodes"][0]["metadata"]["url"], url);
+
+        let dot = export_graph(&graph, ExportFormat::Dot, None);
+        assert!(dot.contains(&format!("[label=\"Run (function)\", URL=\"{}\"];", url)));
+        let mermaid = export_graph(&graph, ExportFormat::Mermaid, None);
+        assert!(mermaid.contains(&format!("  click n0 href \"{}\"", url)));
+        assert!(!mermaid.contains("click n1"));
+        let graphml = export_graph(&graph, ExportFormat::GraphMl, None);
+        assert!(graphml.contains(&format!("<data key=\"url\">{}</data>", url)));
+    }
+
+    #[test]
+    fn test_export_weights() {
+        let mut graph = sample_graph();
+        graph.get_node_mut("a.go:Run").unwrap().metadata.weight = Some(7);
+        for edge in graph.inner_mut().edge_weights_mut() {
+            edge.weight = Some(3);
+        }
+
+        let json: serde_json::Value =
+            serde_json::from_str(&export_graph(&graph, ExportFormat::Json, None)).unwrap();
+        assert_eq!(json["nodes"][0]["metadata"]["weight"], 7);
+        assert_eq!(json["edges"][0]["weight"], 3);
+
+        let dot = export_graph(&graph, ExportFormat::Dot, None);
+        assert!(dot.contains("\"a.go:Run\" -> \"a.go:parse<T>\" [label=\"USES\", weight=3];"));
+        let graphml = export_graph(&graph, ExportFormat::GraphMl, None);
+        assert!(graphml.contains("<data key=\"weight\">7</data>"));
+        assert!(graphml.contains("<data key=\"edge_weight\">3</data>"));
+    }
+
+    #[test]
+    fn test_export_filter() {
+        let mut graph = PetCodeGraph::new();
+        for (file, generated, test) in [
+            ("api.go", false, false),
+            ("api_test.go", false, true),
+            ("api.pb.go", true, false),
+        ] {
+            graph.add_node(
+                Node::source_file(file.to_string(), file.to_string(), String::new(), 50)
+                    .with_metadata(NodeMetadata::new().with_file("go", 1, generated, test)),
+            );
+        }
+        let mut add = |node: Node, parent: &str| {
+            let id = node.id.clone();
+            graph.add_node(node);
+            graph.add_edge(parent, &id, EdgeData::contains());
+        };
+        let callable = |id: &str, name: &str, file: &str| {
+            let mut node = Node::callable(
+                id.to_string(),
+                name.to_string(),
+                CallableKind::Function,
+                file.to_string(),
+                1,
+                5,
+            );
+            if name.starts_with(char::is_uppercase) {
+                node.metadata.visibility = Some("public".to_string());
+            }
+            node
+        };
+        let mut server = Node::container(
+            "api.go:Server".to_string(),
+            "Server".to_string(),
+            ContainerKind::Type,
+            None,
+            "api.go".to_string(),
+            1,
+            20,
+        );
+        server.metadata.visibility = Some("public".to_string());
+        add(server, "api.go");
+        add(
+            callable("api.go:Server:Serve", "Serve", "api.go"),
+            "api.go:Server",
+        );
+        add(callable("api.go:helper", "helper", "api.go"), "api.go");
+        add(
+            callable("api_test.go:TestServe", "TestServe", "api_test.go"),
+            "api_test.go",
+        );
+        add(
+            callable("api.pb.go:Marshal", "Marshal", "api.pb.go"),
+            "api.pb.go",
+        );
+        graph.add_edge(
+            "api.go:Server:Serve",
+            "api.go:helper",
+            EdgeData::uses(Some(3), None),
+        );
+
+        assert!(ExportFilter::default().is_empty());
+        assert_eq!(
+            ExportFilter::default().apply(&graph, &graph).node_count(),
+            8
+        );
+
+        let ids = |filter: ExportFilter| {
+            let filtered = filter.apply(&graph, &graph);
+            let mut ids: Vec<String> = filtered.iter_nodes().map(|n| n.id.clone()).collect();
+            ids.sort();
+            ids
+        };
+        assert_eq!(
+            ids(ExportFilter {
+                only_exported: true,
+                exclude_tests: true,
+                exclude_generated: true,
+                ..Default::default()
+            }),
+            vec!["api.go", "api.go:Server", "api.go:Server:Serve"]
+        );
+        assert_eq!(
+            ids(ExportFilter {
+                max_depth: Some(1),
+                exclude_tests: true,
+                ..Default::default()
+            }),
+            vec![
+                "api.go",
+                "api.go:Server",
+                "api.go:helper",
+                "api.pb.go",
+                "api.pb.go:Marshal"
+            ]
+        );
+        // Dropping an end drops its edges
+        let filtered = ExportFilter {
+            only_exported: true,
+            ..Default::default()
+        }
+        .apply(&graph, &graph);
+        assert_eq!(filtered.edges_by_type(EdgeType::Uses).count(), 0);
+
+        // A slice is judged in the full graph, which holds the file nodes
+        let mut slice = PetCodeGraph::new();
+        slice.add_node(graph.get_node("api.pb.go:Marshal").unwrap().clone());
+        let generated = ExportFilter {
+            exclude_generated: true,
+            ..Default::default()
+        };
+        assert_eq!(generated.apply(&slice, &slice).node_count(), 1);
+        assert_eq!(generated.apply(&slice, &graph).node_count(), 0);
+    }
+
+    #[test]
+    fn test_export_budget() {
+        // Main calls Hub and L0; Hub calls L0 through L9
+        let mut graph = PetCodeGraph::new();
+        let names = ["Main", "Hub"]
+            .into_iter()
+            .map(String::from)
+            .chain((0..10).map(|i| format!("L{}", i)));
+        for name in names {
+            graph.add_node(Node::callable(
+                format!("a.go:{}", name),
+                name,
+                CallableKind::Function,
+                "a.go".to_string(),
+                1,
+                5,
+            ));
+        }
+        graph.add_edge("a.go:Main", "a.go:Hub", EdgeData::uses(Some(2), None));
+        graph.add_edge("a.go:Main", "a.go:L0", EdgeData::uses(Some(3), None));
+        for i in 0..10 {
+            let leaf = format!("a.go:L{}", i);
+            graph.add_edge("a.go:Hub", &leaf, EdgeData::uses(Some(i + 2), None));
+        }
+
+        let unlimited = ExportBudget::default().apply(&graph, &[]);
+        assert!(!unlimited.is_sampled());
+        assert_eq!(unlimited.graph.node_count(), 12);
+
+        let budget = ExportBudget {
+            max_nodes: Some(4),
+            max_edges: None,
+        };
+        let sampled = budget.apply(&graph, &[]);
+        assert_eq!(
+            (sampled.collapsed, sampled.aggregates, sampled.omitted),
+            (9, 1, 0)
+        );
+        let mut ids: Vec<&str> = sampled
+            .graph
+            .iter_nodes()
+            .map(|node| node.id.as_str())
+            .collect();
+        ids.sort();
+        assert_eq!(
+            ids,
+            ["a.go:Hub", "a.go:Hub#aggregate", "a.go:L0", "a.go:Main"]
+        );
+        let aggregate = sampled.graph.get_node("a.go:Hub#aggregate").unwrap();
+        assert_eq!(aggregate.name, "9 more");
+        assert_eq!(aggregate.kind.as_deref(), Some(AGGREGATE_KIND));
+        assert_eq!(
+            aggregate.metadata.attributes.as_ref().unwrap()[AGGREGATED_ATTRIBUTE],
+            "9"
+        );
+        // One edge stands in for the nine calls from Hub
+        assert_eq!(sampled.graph.edge_count(), 4);
+
+        // Pinned nodes are kept; edges between hubs win
+        let budget = ExportBudget {
+            max_nodes: Some(4),
+            max_edges: Some(2),
+        };
+        let sampled = budget.apply(&graph, &["a.go:L5"]);
+        assert!(sampled.graph.contains_node("a.go:L5"));
+        assert!(!sampled.graph.contains_node("a.go:Main"));
+        assert_eq!(sampled.graph.edge_count(), 2);
+        assert_eq!(sampled.dropped_edges, 2);
+        assert!(sampled
+            .graph
+            .iter_edges()
+            .any(|e| e.source == "a.go:Hub" && e.target == "a.go:L5"));
+    }
+
+    #[test]
+    fn test_parse_format() {
+        assert_eq!("GraphML".parse::<ExportFormat>(), Ok(ExportFormat::GraphMl));
+        assert!("svg".parse::<ExportFormat>().is_err());
+    }
+}
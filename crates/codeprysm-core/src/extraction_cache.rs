@@ -0,0 +1,439 @@
+//! Extraction Cache
+//!
+//! Persists what the builder extracted from each file (its nodes, CONTAINS
+//! and DEFINES edges, definitions, and unresolved references) keyed by the
+//! file's content hash and path relative to its repository root. A later
+//! build reuses the extraction of every file whose content it has seen
+//! before and parses only the rest.
+//!
+//! Since entries do not depend on where the repository is checked out or
+//! which branch is current, one cache serves every checkout: inside a git
+//! repository it is kept in the common git directory, shared by all
+//! worktrees, so switching branches or indexing another worktree reuses the
+//! results for unchanged files. Entries for content no build has used in the
+//! last [`RETAINED_BUILDS`] builds are pruned.
+//!
+//! Reference resolution, clone linking, and the CODEOWNERS overlay still run
+//! over the whole repository, so USES edges into and out of changed files are
+//! re-resolved and the result matches a build without the cache.
+//!
+//! The cache is dropped as a whole when the codeprysm version or a builder
+//! setting that shapes extraction changes.
+
+use std::collections::HashMap;
+use std::path::{Path, PathBuf};
+
+use serde::{Deserialize, Serialize};
+use tracing::{debug, info};
+
+use crate::builder::{BuilderError, ReferenceInfo};
+use crate::diagnostics::Diagnostic;
+use crate::graph::{Edge, Node, PetCodeGraph};
+use crate::limits::is_symbol;
+
+/// File holding the extraction cache
+const CACHE_FILE: &str = "extraction_cache.json";
+
+/// Builds an entry is kept for without being used
+pub const RETAINED_BUILDS: u64 = 10;
+
+/// Extraction results of one file.
+#[derive(Debug, Clone, Default, Serialize, Deserialize)]
+pub(crate) struct FileExtraction {
+    /// Content hash of the file the results were extracted from
+    pub hash: String,
+    /// File node and definition nodes
+    pub nodes: Vec<Node>,
+    /// CONTAINS and DEFINES edges between the nodes
+    pub edges: Vec<Edge>,
+    /// Definition names and the node IDs they resolve to
+    pub defines: HashMap<String, String>,
+    /// References by referenced name
+    pub references: HashMap<String, Vec<ReferenceInfo>>,
+    /// Data nodes skipped by `skip_data_nodes`
+    pub skipped_data_nodes: usize,
+    /// Nodes skipped by `max_containment_depth`
+    pub skipped_depth_nodes: usize,
+    /// Syntax errors found while extracting
+    #[serde(default)]
+    pub diagnostics: Vec<Diagnostic>,
+}
+
+impl FileExtraction {
+    /// Collect the nodes and edges of a graph holding a single file.
+    pub fn from_graph(hash: String, graph: &PetCodeGraph) -> Self {
+        Self {
+            hash,
+            nodes: graph.iter_nodes().cloned().collect(),
+            edges: graph.iter_edges().collect(),
+            ..Self::default()
+        }
+    }
+
+    /// Number of nodes counting toward symbol limits
+    pub fn symbol_count(&self) -> usize {
+        self.nodes.iter().filter(|node| is_symbol(node)).count()
+    }
+
+    /// The extraction without symbols: only the file node, for a file whose
+    /// symbols are left out of a build.
+    pub fn file_only(&self) -> Self {
+        Self {
+            hash: self.hash.clone(),
+            nodes: self.nodes.iter().filter(|n| n.is_file()).cloned().collect(),
+            ..Self::default()
+        }
+    }
+
+    /// Add the file's entities to a build in progress.
+    pub fn merge_into(
+        &self,
+        graph: &mut PetCodeGraph,
+        defines: &mut HashMap<String, String>,
+        references: &mut HashMap<String, Vec<ReferenceInfo>>,
+    ) {
+        for node in &self.nodes {
+            if !graph.contains_node(&node.id) {
+                graph.add_node(node.clone());
+            }
+        }
+        for edge in &self.edges {
+            graph.add_edge_from_struct(edge);
+        }
+        defines.extend(
+            self.defines
+                .iter()
+                .map(|(name, id)| (name.clone(), id.clone())),
+        );
+        for (name, refs) in &self.references {
+            references
+                .entry(name.clone())
+                .or_default()
+                .extend(refs.iter().cloned());
+        }
+    }
+}
+
+/// A cached extraction and the last build that used it
+#[derive(Debug, Serialize, Deserialize)]
+struct CacheEntry {
+    /// Generation of the last build that used the entry
+    used: u64,
+    extraction: FileExtraction,
+}
+
+/// On-disk layout of the cache
+#[derive(Deserialize)]
+struct CacheFile {
+    fingerprint: String,
+    /// Generation of the last build that saved the cache
+    generation: u64,
+    entries: HashMap<String, CacheEntry>,
+}
+
+/// [`CacheFile`] borrowing the entries to save
+#[derive(Serialize)]
+struct CacheFileRef<'a> {
+    fingerprint: &'a str,
+    generation: u64,
+    entries: HashMap<&'a str, &'a CacheEntry>,
+}
+
+/// Per-file extraction results, saved between builds.
+///
+/// Attach a cache with [`GraphBuilder::with_cache`] and [`save`](Self::save)
+/// it after building. Each build that saves the cache counts as one
+/// generation; entries unused for [`RETAINED_BUILDS`] generations are pruned
+/// on save, so entries of other branches survive a branch switch while those
+/// of deleted files eventually go.
+///
+/// Checkouts saving the same cache at the same time do not corrupt it, but
+/// the entries added by all but the last one to save are lost.
+///
+/// [`GraphBuilder::with_cache`]: crate::builder::GraphBuilder::with_cache
+#[derive(Debug)]
+pub struct ExtractionCache {
+    /// Cache file
+    path: PathBuf,
+    /// Version and builder settings the entries were extracted with
+    fingerprint: String,
+    /// Generation of the builds using the cache since loading
+    generation: u64,
+    /// Entries by content hash and relative path
+    entries: HashMap<String, CacheEntry>,
+}
+
+impl ExtractionCache {
+    /// Create an empty cache saved into `dir`, ignoring any cache already
+    /// there.
+    pub fn new(dir: &Path) -> Self {
+        Self {
+            path: dir.join(CACHE_FILE),
+            fingerprint: String::new(),
+            generation: 1,
+            entries: HashMap::new(),
+        }
+    }
+
+    /// Load the cache saved in `dir`.
+    ///
+    /// A missing or unreadable cache loads as empty, so the next build parses
+    /// every file.
+    pub fn load(dir: &Path) -> Self {
+        let mut cache = Self::new(dir);
+        let Ok(json) = std::fs::read_to_string(&cache.path) else {
+            return cache;
+        };
+        match serde_json::from_str::<CacheFile>(&json) {
+            Ok(file) => {
+                info!(
+                    "Loaded extraction cache with {} file(s)",
+                    file.entries.len()
+                );
+                cache.fingerprint = file.fingerprint;
+                cache.generation = file.generation + 1;
+                cache.entries = file.entries;
+            }
+            Err(e) => debug!("Ignoring unreadable extraction cache: {}", e),
+        }
+        cache
+    }
+
+    /// Directory to keep the extraction cache of a workspace in.
+    ///
+    /// Inside a git repository this is `codeprysm/` in the common git
+    /// directory, shared by every worktree of the repository; otherwise it is
+    /// the workspace's index directory.
+    pub fn locate(workspace: &Path, prism_dir: &Path) -> PathBuf {
+        let common_dir = std::process::Command::new("git")
+            .args(["rev-parse", "--git-common-dir"])
+            .current_dir(workspace)
+            .output()
+            .ok()
+            .filter(|o| o.status.success())
+            .map(|o| String::from_utf8_lossy(&o.stdout).trim().to_string())
+            .filter(|s| !s.is_empty());
+        match common_dir {
+            // Relative paths are relative to the workspace
+            Some(dir) => workspace.join(dir).join("codeprysm"),
+            None => prism_dir.to_path_buf(),
+        }
+    }
+
+    /// Number of cached files
+    pub fn len(&self) -> usize {
+        self.entries.len()
+    }
+
+    /// Check if the cache holds no files
+    pub fn is_empty(&self) -> bool {
+        self.len() == 0
+    }
+
+    /// Save the cache, pruning entries no recent build has used.
+    pub fn save(&self) -> Result<(), BuilderError> {
+        let file = CacheFileRef {
+            fingerprint: &self.fingerprint,
+            generation: self.generation,
+            entries: self
+                .entries
+                .iter()
+                .filter(|(_, entry)| entry.used + RETAINED_BUILDS > self.generation)
+                .map(|(key, entry)| (key.as_str(), entry))
+                .collect(),
+        };
+        let json = serde_json::to_string(&file).map_err(std::io::Error::other)?;
+
+        // Write a temporary file and rename it so that concurrent builds of
+        // other checkouts never read a partial cache
+        if let Some(dir) = self.path.parent() {
+            std::fs::create_dir_all(dir)?;
+        }
+        let tmp = self
+            .path
+            .with_extension(format!("json.{}.tmp", std::process::id()));
+        std::fs::write(&tmp, json)?;
+        std::fs::rename(&tmp, &self.path)?;
+        Ok(())
+    }
+
+    /// Drop the entries if they were extracted with different settings.
+    pub(crate) fn bind(&mut self, fingerprint: String) {
+        if self.fingerprint != fingerprint {
+            if !self.entries.is_empty() {
+                info!("Builder settings changed; discarding extraction cache");
+            }
+            self.entries.clear();
+            self.fingerprint = fingerprint;
+        }
+    }
+
+    /// The entry for a file path if it was extracted from the same content.
+    pub(crate) fn get(&self, rel_path: &str, hash: &str) -> Option<&FileExtraction> {
+        self.entries
+            .get(&entry_key(rel_path, hash))
+            .map(|entry| &entry.extraction)
+    }
+
+    /// Take the entry for a file path if it was extracted from the same
+    /// content.
+    pub(crate) fn take(&mut self, rel_path: &str, hash: &str) -> Option<FileExtraction> {
+        self.entries
+            .remove(&entry_key(rel_path, hash))
+            .map(|entry| entry.extraction)
+    }
+
+    /// Record the extraction of a file seen by the current build.
+    pub(crate) fn insert(&mut self, rel_path: &str, extraction: FileExtraction) {
+        let entry = CacheEntry {
+            used: self.generation,
+            extraction,
+        };
+        self.entries
+            .insert(entry_key(rel_path, &entry.extraction.hash), entry);
+    }
+}
+
+/// Key of an entry: node IDs embed the file path, so the same content at
+/// another path is extracted anew
+fn entry_key(rel_path: &str, hash: &str) -> String {
+    format!("{}:{}", hash, rel_path)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::builder::GraphBuilder;
+    use crate::graph::EdgeType;
+
+    fn sorted_edges(graph: &PetCodeGraph) -> Vec<(String, String, &'static str)> {
+        let mut edges: Vec<_> = graph
+            .iter_edges()
+            .map(|e| (e.source, e.target, e.edge_type.as_str()))
+            .collect();
+        edges.sort();
+        edges
+    }
+
+    #[test]
+    fn test_rebuild_reuses_unchanged_files() {
+        let repo = tempfile::tempdir().unwrap();
+        let prism = tempfile::tempdir().unwrap();
+        std::fs::write(repo.path().join("util.py"), "def helper():\n    return 1\n").unwrap();
+        std::fs::write(
+            repo.path().join("main.py"),
+            "from util import helper\n\ndef run():\n    return helper()\n",
+        )
+        .unwrap();
+
+        let mut builder = GraphBuilder::new_with_embedded_queries()
+            .with_cache(ExtractionCache::load(prism.path()));
+        let first = builder.build_from_directory(repo.path()).unwrap();
+        assert_eq!(builder.stats().files_cached, 0);
+        builder.cache().unwrap().save().unwrap();
+
+        // Nothing changed: every file comes from the cache
+        let mut builder = GraphBuilder::new_with_embedded_queries()
+            .with_cache(ExtractionCache::load(prism.path()));
+        let second = builder.build_from_directory(repo.path()).unwrap();
+        assert_eq!(builder.stats().files_parsed, 0);
+        assert_eq!(builder.stats().files_cached, 2);
+        assert_eq!(second.node_count(), first.node_count());
+        assert_eq!(sorted_edges(&second), sorted_edges(&first));
+
+        // A definition moves; the cached caller is resolved against it
+        std::fs::write(
+            repo.path().join("util.py"),
+            "\n\ndef helper():\n    return 2\n",
+        )
+        .unwrap();
+        let mut builder = GraphBuilder::new_with_embedded_queries()
+            .with_cache(ExtractionCache::load(prism.path()));
+        let third = builder.build_from_directory(repo.path()).unwrap();
+        assert_eq!(builder.stats().files_parsed, 1);
+        assert_eq!(builder.stats().files_cached, 1);
+        let helper = third
+            .iter_nodes()
+            .find(|n| n.name == "helper" && n.is_callable())
+            .unwrap();
+        assert_eq!(helper.line, 3);
+        assert!(third
+            .incoming_edges(&helper.id)
+            .any(|(_, data)| data.edge_type == EdgeType::Uses));
+    }
+
+    #[test]
+    fn test_settings_change_discards_cache() {
+        let repo = tempfile::tempdir().unwrap();
+        let prism = tempfile::tempdir().unwrap();
+        std::fs::write(repo.path().join("app.py"), "def main(x):\n    return x\n").unwrap();
+
+        let mut builder = GraphBuilder::new_with_embedded_queries()
+            .with_cache(ExtractionCache::load(prism.path()));
+        builder.build_from_directory(repo.path()).unwrap();
+        builder.cache().unwrap().save().unwrap();
+
+        let config = crate::builder::BuilderConfig {
+            skip_data_nodes: true,
+            ..Default::default()
+        };
+        let mut builder = GraphBuilder::with_embedded_queries(config)
+            .with_cache(ExtractionCache::load(prism.path()));
+        builder.build_from_directory(repo.path()).unwrap();
+        assert_eq!(builder.stats().files_cached, 0);
+        assert_eq!(builder.stats().files_parsed, 1);
+    }
+
+    #[test]
+    fn test_cache_shared_across_checkouts_and_branches() {
+        let cache_dir = tempfile::tempdir().unwrap();
+        let main = tempfile::tempdir().unwrap();
+        let worktree = tempfile::tempdir().unwrap();
+        let v1 = "def helper():\n    return 1\n";
+        let v2 = "def helper():\n    return 2\n";
+        std::fs::write(main.path().join("util.py"), v1).unwrap();
+        std::fs::write(worktree.path().join("util.py"), v1).unwrap();
+
+        let build = |dir: &Path| {
+            let mut builder = GraphBuilder::new_with_embedded_queries()
+                .with_cache(ExtractionCache::load(cache_dir.path()));
+            builder.build_from_directory(dir).unwrap();
+            builder.cache().unwrap().save().unwrap();
+            builder.stats().files_cached
+        };
+
+        // Another checkout of the same content reuses the first one's results
+        assert_eq!(build(main.path()), 0);
+        assert_eq!(build(worktree.path()), 1);
+
+        // Switching branches back and forth keeps both versions
+        std::fs::write(main.path().join("util.py"), v2).unwrap();
+        assert_eq!(build(main.path()), 0);
+        std::fs::write(main.path().join("util.py"), v1).unwrap();
+        assert_eq!(build(main.path()), 1);
+        assert_eq!(ExtractionCache::load(cache_dir.path()).len(), 2);
+    }
+
+    #[test]
+    fn test_save_prunes_entries_unused_for_retained_builds() {
+        let cache_dir = tempfile::tempdir().unwrap();
+        let repo = tempfile::tempdir().unwrap();
+        std::fs::write(repo.path().join("old.py"), "def old():\n    pass\n").unwrap();
+        std::fs::write(repo.path().join("app.py"), "def main():\n    pass\n").unwrap();
+
+        let build = || {
+            let mut builder = GraphBuilder::new_with_embedded_queries()
+                .with_cache(ExtractionCache::load(cache_dir.path()));
+            builder.build_from_directory(repo.path()).unwrap();
+            builder.cache().unwrap().save().unwrap();
+        };
+        build();
+        std::fs::remove_file(repo.path().join("old.py")).unwrap();
+        for _ in 0..RETAINED_BUILDS - 1 {
+            build();
+        }
+        assert_eq!(ExtractionCache::load(cache_dir.path()).len(), 2);
+        build();
+        assert_eq!(ExtractionCache::load(cache_dir.path()).len(), 1);
+    }
+}
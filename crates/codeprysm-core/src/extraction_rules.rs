@@ -0,0 +1,138 @@
+//! Extraction Rules
+//!
+//! Per-language adjustments of what extraction produces, configured without
+//! recompiling:
+//!
+//! - **Disabled edges**: edge types dropped from the edges of the language's
+//!   symbols (e.g., `refers_by_name` for Go when registry keys are noisy)
+//! - **Query files**: tree-sitter query files appended to the language's tag
+//!   queries, adding capture rules that follow the SCM tag naming convention
+//!   (e.g., a framework's registration calls captured as references)
+//!
+//! Rules are keyed by language name as used in query file names (`go`,
+//! `python`, `typescript`, ...). An edge belongs to the language of the file
+//! its source node is declared in. `CONTAINS` edges hold the graph together
+//! and cannot be disabled.
+
+use std::collections::HashMap;
+use std::path::{Path, PathBuf};
+
+use crate::graph::{EdgeType, PetCodeGraph};
+use crate::parser::SupportedLanguage;
+
+/// Extraction rules of one language.
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub struct ExtractionRules {
+    /// Edge types removed from the edges of the language's symbols
+    pub disabled_edges: Vec<EdgeType>,
+    /// Query files appended to the language's tag queries, in order
+    pub query_files: Vec<PathBuf>,
+}
+
+impl ExtractionRules {
+    /// Whether the rules leave extraction unchanged
+    pub fn is_empty(&self) -> bool {
+        self.disabled_edges.is_empty() && self.query_files.is_empty()
+    }
+}
+
+/// Remove the disabled edges of every language from a graph.
+///
+/// Returns the number of removed edges.
+pub fn remove_disabled_edges(
+    graph: &mut PetCodeGraph,
+    rules: &HashMap<String, ExtractionRules>,
+) -> usize {
+    if rules.values().all(|rules| rules.disabled_edges.is_empty()) {
+        return 0;
+    }
+    graph.retain_edges(|source, _, edge| !is_disabled(rules, &source.file, edge.edge_type))
+}
+
+/// Check if edges of a type are disabled for the symbols of `file`
+pub fn is_disabled(
+    rules: &HashMap<String, ExtractionRules>,
+    file: &str,
+    edge_type: EdgeType,
+) -> bool {
+    if edge_type == EdgeType::Contains {
+        return false;
+    }
+    let Some(language) = SupportedLanguage::from_path(Path::new(file)) else {
+        return false;
+    };
+    rules
+        .get(language.as_str())
+        .is_some_and(|rules| rules.disabled_edges.contains(&edge_type))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::{CallableKind, EdgeData, Node};
+
+    fn function(id: &str) -> Node {
+        let (file, name) = id.split_once(':').unwrap();
+        Node::callable(
+            id.to_string(),
+            name.to_string(),
+            CallableKind::Function,
+            file.to_string(),
+            1,
+            5,
+        )
+    }
+
+    #[test]
+    fn test_remove_disabled_edges() {
+        let mut graph = PetCodeGraph::new();
+        for id in ["main.go:main", "main.go:Register", "app.py:main"] {
+            graph.add_node(function(id));
+        }
+        graph.add_edge(
+            "main.go:main",
+            "main.go:Register",
+            EdgeData::uses(Some(2), Some("Register".to_string())),
+        );
+        graph.add_edge(
+            "main.go:main",
+            "main.go:Register",
+            EdgeData::refers_by_name(Some(3), None),
+        );
+        graph.add_edge(
+            "app.py:main",
+            "main.go:Register",
+            EdgeData::refers_by_name(Some(1), None),
+        );
+        graph.add_edge("main.go:main", "main.go:Register", EdgeData::contains());
+
+        // Nothing disabled
+        assert_eq!(remove_disabled_edges(&mut graph, &HashMap::new()), 0);
+
+        let rules = HashMap::from([(
+            "go".to_string(),
+            ExtractionRules {
+                disabled_edges: vec![EdgeType::RefersByName, EdgeType::Contains],
+                query_files: Vec::new(),
+            },
+        )]);
+        assert!(is_disabled(&rules, "cmd/main.go", EdgeType::RefersByName));
+        assert!(!is_disabled(&rules, "app.py", EdgeType::RefersByName));
+        assert_eq!(remove_disabled_edges(&mut graph, &rules), 1);
+
+        // Python edges and CONTAINS edges are kept
+        let mut kept: Vec<(String, EdgeType)> = graph
+            .iter_edges()
+            .map(|edge| (edge.source, edge.edge_type))
+            .collect();
+        kept.sort_by_key(|(source, edge_type)| (source.clone(), edge_type.as_str()));
+        assert_eq!(
+            kept,
+            vec![
+                ("app.py:main".to_string(), EdgeType::RefersByName),
+                ("main.go:main".to_string(), EdgeType::Contains),
+                ("main.go:main".to_string(), EdgeType::Uses),
+            ]
+        );
+    }
+}
@@ -0,0 +1,582 @@
+//! Multi-Repository Federation
+//!
+//! Combines the graphs of several repositories, built for the occasion or
+//! loaded from indexes produced separately, into one federated graph under a
+//! workspace node. Each repository's node IDs and file paths are prefixed
+//! with its name, so files at the same path in different repositories stay
+//! apart.
+//!
+//! Go imports of a package in another federated repository, matched against
+//! the module path in each repository's root `go.mod`, become concrete edges:
+//! DEPENDS_ON from the importing repository to the imported one, and USES
+//! from the importing code to the package-level definitions it names
+//! (`pkg.Name`). Service-to-library relationships across an organization
+//! can then be queried like any other edge.
+
+use std::collections::{BTreeMap, HashMap, HashSet};
+use std::path::{Path, PathBuf};
+
+use serde::Serialize;
+use thiserror::Error;
+use tracing::{debug, info, info_span};
+use tree_sitter::Node as TsNode;
+
+use crate::analysis::clones::{link_clones, CloneOptions};
+use crate::analysis::package_of;
+use crate::graph::{Edge, Node, NodeMetadata, PetCodeGraph};
+use crate::manifest::ManifestParser;
+use crate::parser::{CodeParser, SupportedLanguage};
+use crate::type_usage::weigh_type_usage;
+
+/// Errors that can occur federating repositories.
+#[derive(Debug, Error)]
+pub enum FederationError {
+    /// Two repositories, or a repository and the workspace, share a name
+    #[error("Name '{0}' is used more than once in the federation")]
+    DuplicateName(String),
+
+    /// A repository name that cannot prefix paths
+    #[error("Invalid repository name '{0}'")]
+    InvalidName(String),
+}
+
+/// A repository to federate: its graph and the checkout it was built from.
+#[derive(Debug)]
+pub struct FederatedRepo {
+    /// Name of the repository in the federated graph
+    pub name: String,
+    /// Root of the checkout, for `go.mod` and Go sources
+    pub root: PathBuf,
+    /// Graph of the repository, with paths relative to `root`
+    pub graph: PetCodeGraph,
+}
+
+/// A repository in a federated graph.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize)]
+pub struct FederatedRepoSummary {
+    /// Repository name
+    pub name: String,
+    /// Go module path from the root `go.mod`, if any
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub module: Option<String>,
+    /// Number of nodes the repository contributed
+    pub nodes: usize,
+}
+
+/// A repository importing packages of another.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize)]
+pub struct RepoDependency {
+    /// Importing repository
+    pub from: String,
+    /// Imported repository
+    pub to: String,
+    /// Module path of the imported repository
+    pub module: String,
+    /// Number of files with imports of the module
+    pub files: usize,
+}
+
+/// What a federation linked.
+#[derive(Debug, Clone, Default, PartialEq, Eq, Serialize)]
+pub struct FederationReport {
+    /// Federated repositories, in input order
+    pub repositories: Vec<FederatedRepoSummary>,
+    /// Dependencies between repositories, sorted
+    pub dependencies: Vec<RepoDependency>,
+    /// USES edges created across repositories
+    pub resolved: usize,
+    /// Qualified names into federated packages without a matching definition
+    pub unresolved: usize,
+    /// Go files that could not be read or parsed for imports
+    pub skipped_files: usize,
+}
+
+/// An import declaration of a Go file
+struct GoImport {
+    /// Explicit package name, `.`, or `_` (None to use the package clause)
+    name: Option<String>,
+    /// Import path
+    path: String,
+}
+
+/// A qualified name in a Go file (`pkg.Name`)
+struct Selector {
+    qualifier: String,
+    name: String,
+    /// 1-indexed line
+    line: usize,
+}
+
+/// Federate repositories into one graph under a workspace node named `name`.
+///
+/// Repository names must be unique and differ from `name`. Clones are
+/// linked across repositories, as a build of a multi-root workspace would.
+pub fn federate(
+    name: &str,
+    repos: Vec<FederatedRepo>,
+) -> Result<(PetCodeGraph, FederationReport), FederationError> {
+    let _span = info_span!("federate", repositories = repos.len()).entered();
+
+    let mut names: HashSet<&str> = HashSet::from([name]);
+    for repo in &repos {
+        if repo.name.is_empty() || repo.name.contains(':') {
+            return Err(FederationError::InvalidName(repo.name.clone()));
+        }
+        if !names.insert(repo.name.as_str()) {
+            return Err(FederationError::DuplicateName(repo.name.clone()));
+        }
+    }
+
+    let modules: Vec<Option<String>> = repos.iter().map(|r| go_module(&r.root)).collect();
+    // Package-level definitions by (repository, package directory), by name
+    let mut packages: HashMap<(usize, &str), HashMap<&str, String>> = HashMap::new();
+    for (index, repo) in repos.iter().enumerate() {
+        for node in repo.graph.iter_nodes() {
+            if !node.file.is_empty() && node.id == format!("{}:{}", node.file, node.name) {
+                packages
+                    .entry((index, package_of(&node.file)))
+                    .or_default()
+                    .insert(&node.name, prefixed(&repo.name, &node.id));
+            }
+        }
+    }
+
+    let mut graph = PetCodeGraph::new();
+    graph.add_node(Node::workspace(name.to_string()));
+    let mut report = FederationReport::default();
+    let mut dependencies: BTreeMap<(usize, usize), usize> = BTreeMap::new();
+    let mut uses: Vec<Edge> = Vec::new();
+    let mut package_names: HashMap<(usize, String), Option<String>> = HashMap::new();
+    let mut parser = CodeParser::new(SupportedLanguage::Go).ok();
+
+    for (index, repo) in repos.iter().enumerate() {
+        let nodes = add_repository(&mut graph, name, repo);
+        report.repositories.push(FederatedRepoSummary {
+            name: repo.name.clone(),
+            module: modules[index].clone(),
+            nodes,
+        });
+
+        let Some(parser) = parser.as_mut() else {
+            continue;
+        };
+        let go_files = repo
+            .graph
+            .iter_nodes()
+            .filter(|n| n.is_file() && n.id.ends_with(".go"));
+        for file in go_files {
+            let Some((imports, selectors)) = std::fs::read_to_string(repo.root.join(&file.id))
+                .ok()
+                .and_then(|source| scan_go_file(parser, &source))
+            else {
+                debug!("Skipping imports of {}/{}", repo.name, file.id);
+                report.skipped_files += 1;
+                continue;
+            };
+
+            // Imported packages of other repositories, by the name they are used as
+            let mut qualifiers: HashMap<String, (usize, String)> = HashMap::new();
+            let mut imported: HashSet<usize> = HashSet::new();
+            for import in &imports {
+                let Some((target, dir)) = resolve_import(&modules, index, &import.path) else {
+                    continue;
+                };
+                imported.insert(target);
+                let alias = match import.name.as_deref() {
+                    // Dot and blank imports are not used as qualifiers
+                    Some("." | "_") => None,
+                    Some(name) => Some(name.to_string()),
+                    None => package_names
+                        .entry((target, dir.clone()))
+                        .or_insert_with(|| package_name(&repos[target], &dir))
+                        .clone(),
+                };
+                if let Some(alias) = alias {
+                    qualifiers.insert(alias, (target, dir));
+                }
+            }
+            for target in imported {
+                *dependencies.entry((index, target)).or_default() += 1;
+            }
+
+            let mut seen: HashSet<(String, String, usize)> = HashSet::new();
+            for selector in selectors {
+                let Some((target, dir)) = qualifiers.get(&selector.qualifier) else {
+                    continue;
+                };
+                let Some(definition) = packages
+                    .get(&(*target, dir.as_str()))
+                    .and_then(|names| names.get(selector.name.as_str()))
+                else {
+                    report.unresolved += 1;
+                    continue;
+                };
+                let source = enclosing(&repo.graph, &file.id, selector.line).map_or_else(
+                    || prefixed(&repo.name, &file.id),
+                    |n| prefixed(&repo.name, n),
+                );
+                if seen.insert((source.clone(), definition.clone(), selector.line)) {
+                    uses.push(Edge::uses(
+                        source,
+                        definition.clone(),
+                        Some(selector.line),
+                        Some(format!("{}.{}", selector.qualifier, selector.name)),
+                    ));
+                }
+            }
+        }
+    }
+
+    report.resolved = uses.len();
+    for edge in &uses {
+        graph.add_edge_from_struct(edge);
+    }
+    for ((from, to), files) in dependencies {
+        let module = modules[to].clone().unwrap_or_default();
+        graph.add_edge_from_struct(&Edge::depends_on(
+            repos[from].name.clone(),
+            repos[to].name.clone(),
+            Some(module.clone()),
+            None,
+            None,
+        ));
+        report.dependencies.push(RepoDependency {
+            from: repos[from].name.clone(),
+            to: repos[to].name.clone(),
+            module,
+            files,
+        });
+    }
+
+    let clone_count = {
+        let _span = info_span!("clones").entered();
+        link_clones(&mut graph, &CloneOptions::default())
+    };
+    // Weigh types and repository dependencies across the workspace
+    weigh_type_usage(&mut graph);
+    info!(
+        "Federated {} repositories: {} nodes, {} cross-repository USES edges, {} dependencies, {} clone pair(s)",
+        repos.len(),
+        graph.node_count(),
+        report.resolved,
+        report.dependencies.len(),
+        clone_count
+    );
+
+    Ok((graph, report))
+}
+
+/// Prefix a node ID or file path with a repository name
+fn prefixed(repo: &str, id: &str) -> String {
+    format!("{}/{}", repo, id)
+}
+
+/// Add a repository's nodes and edges to the federated graph under the
+/// workspace node, returning the number of nodes added.
+fn add_repository(graph: &mut PetCodeGraph, workspace: &str, repo: &FederatedRepo) -> usize {
+    // The root of the repository graph becomes the repository node
+    let root = repo
+        .graph
+        .iter_nodes()
+        .find(|n| n.is_workspace())
+        .or_else(|| repo.graph.iter_nodes().find(|n| n.is_repository()))
+        .map(|n| n.id.clone());
+    let rename = |id: &str| {
+        if root.as_deref() == Some(id) {
+            repo.name.clone()
+        } else {
+            prefixed(&repo.name, id)
+        }
+    };
+
+    let mut count = 0;
+    for node in repo.graph.iter_nodes() {
+        let mut node = node.clone();
+        if root.as_deref() == Some(node.id.as_str()) {
+            node.name = repo.name.clone();
+        }
+        node.id = rename(&node.id);
+        if !node.file.is_empty() {
+            node.file = prefixed(&repo.name, &node.file);
+        }
+        graph.add_node(node);
+        count += 1;
+    }
+    if root.is_none() {
+        graph.add_node(Node::repository(repo.name.clone(), NodeMetadata::default()));
+        count += 1;
+        for file in repo.graph.iter_nodes().filter(|n| n.is_file()) {
+            graph.add_edge_from_struct(&Edge::contains(
+                repo.name.clone(),
+                prefixed(&repo.name, &file.id),
+            ));
+        }
+    }
+    for mut edge in repo.graph.iter_edges() {
+        edge.source = rename(&edge.source);
+        edge.target = rename(&edge.target);
+        graph.add_edge_from_struct(&edge);
+    }
+    graph.add_edge_from_struct(&Edge::contains(workspace.to_string(), repo.name.clone()));
+    count
+}
+
+/// Module path declared by the `go.mod` at the root of a checkout
+fn go_module(root: &Path) -> Option<String> {
+    let path = root.join("go.mod");
+    let content = std::fs::read_to_string(&path).ok()?;
+    ManifestParser::new()
+        .ok()?
+        .parse(&path, &content)
+        .ok()?
+        .component_name
+}
+
+/// Find the repository and package directory of a Go import path among the
+/// modules of repositories other than `from`, preferring the longest module.
+fn resolve_import(modules: &[Option<String>], from: usize, path: &str) -> Option<(usize, String)> {
+    modules
+        .iter()
+        .enumerate()
+        .filter(|(index, _)| *index != from)
+        .filter_map(|(index, module)| {
+            let module = module.as_deref()?;
+            let rest = path.strip_prefix(module)?;
+            (rest.is_empty() || rest.starts_with('/')).then(|| {
+                (
+                    index,
+                    module.len(),
+                    rest.trim_start_matches('/').to_string(),
+                )
+            })
+        })
+        .max_by_key(|(_, len, _)| *len)
+        .map(|(index, _, dir)| (index, dir))
+}
+
+/// Name declared by the `package` clause of a package's files
+fn package_name(repo: &FederatedRepo, dir: &str) -> Option<String> {
+    let file = repo
+        .graph
+        .iter_nodes()
+        .filter(|n| n.is_file() && n.id.ends_with(".go") && !n.id.ends_with("_test.go"))
+        .find(|n| package_of(&n.id) == dir)?;
+    let source = std::fs::read_to_string(repo.root.join(&file.id)).ok()?;
+    source.lines().find_map(|line| {
+        let name = line.trim().strip_prefix("package ")?;
+        name.split_whitespace().next().map(str::to_string)
+    })
+}
+
+/// Innermost callable or container of `file`, other than the file itself,
+/// spanning a 1-indexed line
+fn enclosing<'a>(graph: &'a PetCodeGraph, file: &str, line: usize) -> Option<&'a str> {
+    graph
+        .iter_nodes()
+        .filter(|n| n.file == file && !n.is_file() && (n.is_callable() || n.is_container()))
+        .filter(|n| n.line <= line && line <= n.end_line)
+        .min_by_key(|n| n.end_line - n.line)
+        .map(|n| n.id.as_str())
+}
+
+/// Imports and qualified names of a Go file, or None if it does not parse
+fn scan_go_file(parser: &mut CodeParser, source: &str) -> Option<(Vec<GoImport>, Vec<Selector>)> {
+    let tree = parser.parse(source).ok()?;
+    let bytes = source.as_bytes();
+    let text = |node: TsNode| node.utf8_text(bytes).unwrap_or("").to_string();
+
+    let mut imports = Vec::new();
+    let mut selectors = Vec::new();
+    let mut stack = vec![tree.root_node()];
+    while let Some(node) = stack.pop() {
+        match node.kind() {
+            "import_spec" => {
+                let Some(path) = node.child_by_field_name("path") else {
+                    continue;
+                };
+                imports.push(GoImport {
+                    name: node.child_by_field_name("name").map(text),
+                    path: text(path)
+                        .trim_matches(|c| c == '"' || c == '`')
+                        .to_string(),
+                });
+                continue;
+            }
+            "selector_expression" | "qualified_type" => {
+                let (qualifier, name) = if node.kind() == "selector_expression" {
+                    ("operand", "field")
+                } else {
+                    ("package", "name")
+                };
+                if let (Some(qualifier), Some(name)) = (
+                    node.child_by_field_name(qualifier),
+                    node.child_by_field_name(name),
+                ) {
+                    if matches!(qualifier.kind(), "identifier" | "package_identifier") {
+                        selectors.push(Selector {
+                            qualifier: text(qualifier),
+                            name: text(name),
+                            line: node.start_position().row + 1,
+                        });
+                    }
+                }
+            }
+            _ => {}
+        }
+        let mut cursor = node.walk();
+        stack.extend(node.children(&mut cursor));
+    }
+    Some((imports, selectors))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::builder::GraphBuilder;
+    use crate::graph::EdgeType;
+
+    fn write(root: &Path, path: &str, content: &str) {
+        let path = root.join(path);
+        std::fs::create_dir_all(path.parent().unwrap()).unwrap();
+        std::fs::write(path, content).unwrap();
+    }
+
+    fn repo(name: &str, root: &Path) -> FederatedRepo {
+        let graph = GraphBuilder::new_with_embedded_queries()
+            .build_from_directory(root)
+            .unwrap();
+        FederatedRepo {
+            name: name.to_string(),
+            root: root.to_path_buf(),
+            graph,
+        }
+    }
+
+    #[test]
+    fn test_federate_resolves_go_imports() {
+        let lib = tempfile::tempdir().unwrap();
+        write(
+            lib.path(),
+            "go.mod",
+            "module github.com/acme/lib\n\ngo 1.22\n",
+        );
+        write(
+            lib.path(),
+            "auth/token.go",
+            "package auth\n\ntype Token struct{}\n\nfunc Verify(t Token) bool {\n\treturn true\n}\n",
+        );
+        write(lib.path(), "main.go", "package main\n\nfunc main() {}\n");
+
+        let api = tempfile::tempdir().unwrap();
+        write(
+            api.path(),
+            "go.mod",
+            "module github.com/acme/api\n\ngo 1.22\n",
+        );
+        write(
+            api.path(),
+            "handler.go",
+            "package api\n\nimport (\n\t\"fmt\"\n\n\t\"github.com/acme/lib/auth\"\n)\n\nfunc Handle(t auth.Token) {\n\tfmt.Println(auth.Verify(t), auth.Missing)\n}\n",
+        );
+        write(api.path(), "main.go", "package main\n\nfunc main() {}\n");
+
+        let (graph, report) = federate(
+            "acme",
+            vec![repo("api", api.path()), repo("lib", lib.path())],
+        )
+        .unwrap();
+
+        // Files at the same path in both repositories are kept apart
+        assert!(graph.contains_node("api/main.go:main"));
+        assert!(graph.contains_node("lib/main.go:main"));
+        assert_eq!(
+            graph.get_node("lib/auth/token.go:Verify").unwrap().file,
+            "lib/auth/token.go"
+        );
+
+        let mut uses: Vec<(String, String)> = graph
+            .edges_by_type(EdgeType::Uses)
+            .filter(|(source, target, _)| {
+                source.id.starts_with("api/") && target.id.starts_with("lib/")
+            })
+            .map(|(source, target, _)| (source.id.clone(), target.id.clone()))
+            .collect();
+        uses.sort();
+        assert_eq!(
+            uses,
+            vec![
+                (
+                    "api/handler.go:Handle".to_string(),
+                    "lib/auth/token.go:Token".to_string()
+                ),
+                (
+                    "api/handler.go:Handle".to_string(),
+                    "lib/auth/token.go:Verify".to_string()
+                ),
+            ]
+        );
+        assert_eq!(report.resolved, 2);
+        assert_eq!(report.unresolved, 1);
+        assert_eq!(
+            report.dependencies,
+            vec![RepoDependency {
+                from: "api".to_string(),
+                to: "lib".to_string(),
+                module: "github.com/acme/lib".to_string(),
+                files: 1,
+            }]
+        );
+        assert!(graph
+            .edges_by_type(EdgeType::DependsOn)
+            .any(|(source, target, _)| source.id == "api" && target.id == "lib"));
+        assert_eq!(
+            report.repositories[1].module.as_deref(),
+            Some("github.com/acme/lib")
+        );
+    }
+
+    #[test]
+    fn test_federate_rejects_duplicate_names() {
+        let dir = tempfile::tempdir().unwrap();
+        let repo = |name: &str| FederatedRepo {
+            name: name.to_string(),
+            root: dir.path().to_path_buf(),
+            graph: PetCodeGraph::new(),
+        };
+        assert!(matches!(
+            federate("org", vec![repo("a"), repo("a")]),
+            Err(FederationError::DuplicateName(_))
+        ));
+        assert!(matches!(
+            federate("org", vec![repo("org")]),
+            Err(FederationError::DuplicateName(_))
+        ));
+        assert!(matches!(
+            federate("org", vec![repo("")]),
+            Err(FederationError::InvalidName(_))
+        ));
+    }
+
+    #[test]
+    fn test_resolve_import_prefers_longest_module() {
+        let modules = vec![
+            None,
+            Some("github.com/acme/lib".to_string()),
+            Some("github.com/acme/lib/v2".to_string()),
+        ];
+        assert_eq!(
+            resolve_import(&modules, 0, "github.com/acme/lib/v2/auth"),
+            Some((2, "auth".to_string()))
+        );
+        assert_eq!(
+            resolve_import(&modules, 0, "github.com/acme/lib"),
+            Some((1, String::new()))
+        );
+        assert_eq!(resolve_import(&modules, 0, "github.com/acme/library"), None);
+        // Imports within a repository are resolved by its own build
+        assert_eq!(
+            resolve_import(&modules, 1, "github.com/acme/lib/auth"),
+            None
+        );
+    }
+}
@@ -0,0 +1,383 @@
+//! File Policy
+//!
+//! Per-language rules deciding which source files a build indexes: include
+//! and exclude globs, a target platform whose platform-specific files are
+//! kept, and whether generated files are indexed. The rules come from the
+//! project configuration and apply on top of `.gitignore`,
+//! `.codeprysmignore`, and the builder's exclude patterns.
+
+use std::collections::HashMap;
+use std::fmt;
+use std::io::Read;
+use std::path::Path;
+
+use globset::{Glob, GlobSet, GlobSetBuilder};
+use serde::Serialize;
+
+use crate::parser::SupportedLanguage;
+
+/// Bytes at the start of a file searched for a generated-code marker
+const GENERATED_HEADER_BYTES: u64 = 4096;
+
+/// Operating systems recognized in platform-specific file names
+const KNOWN_OS: &[&str] = &[
+    "aix",
+    "android",
+    "darwin",
+    "dragonfly",
+    "freebsd",
+    "illumos",
+    "ios",
+    "js",
+    "linux",
+    "netbsd",
+    "openbsd",
+    "plan9",
+    "solaris",
+    "wasip1",
+    "windows",
+];
+
+/// Architectures recognized in platform-specific file names
+const KNOWN_ARCH: &[&str] = &[
+    "386", "amd64", "arm", "arm64", "loong64", "mips", "mips64", "mips64le", "mipsle", "ppc64",
+    "ppc64le", "riscv64", "s390x", "wasm",
+];
+
+/// Which files of each language a build indexes.
+#[derive(Debug, Clone, Default)]
+pub struct FilePolicy {
+    /// Skip generated files of languages without their own setting
+    pub skip_generated: bool,
+    /// Rules by language name, as returned by [`SupportedLanguage::as_str`]
+    pub languages: HashMap<String, LanguageRules>,
+}
+
+/// File rules for one language.
+#[derive(Debug, Clone)]
+pub struct LanguageRules {
+    /// Index files of this language at all
+    pub enabled: bool,
+    /// Only index files matching these patterns (all if empty)
+    pub include_patterns: Vec<String>,
+    /// Skip files matching these patterns
+    pub exclude_patterns: Vec<String>,
+    /// Skip generated files (defaults to [`FilePolicy::skip_generated`])
+    pub skip_generated: Option<bool>,
+    /// Target platform as "os" or "os/arch"; files named for another
+    /// platform are skipped
+    pub target: Option<String>,
+}
+
+impl Default for LanguageRules {
+    fn default() -> Self {
+        Self {
+            enabled: true,
+            include_patterns: Vec::new(),
+            exclude_patterns: Vec::new(),
+            skip_generated: None,
+            target: None,
+        }
+    }
+}
+
+impl FilePolicy {
+    /// Compile the glob patterns into a filter.
+    ///
+    /// Invalid patterns are ignored, as for the builder's exclude patterns.
+    pub fn compile(&self) -> FileFilter {
+        let languages = self
+            .languages
+            .iter()
+            .map(|(name, rules)| {
+                let compiled = CompiledRules {
+                    enabled: rules.enabled,
+                    include: (!rules.include_patterns.is_empty())
+                        .then(|| build_glob_set(&rules.include_patterns)),
+                    exclude: build_glob_set(&rules.exclude_patterns),
+                    skip_generated: rules.skip_generated.unwrap_or(self.skip_generated),
+                    target: rules.target.clone(),
+                };
+                (name.clone(), compiled)
+            })
+            .collect();
+        FileFilter {
+            skip_generated: self.skip_generated,
+            languages,
+        }
+    }
+}
+
+/// A compiled [`FilePolicy`].
+#[derive(Debug)]
+pub struct FileFilter {
+    skip_generated: bool,
+    languages: HashMap<String, CompiledRules>,
+}
+
+#[derive(Debug)]
+struct CompiledRules {
+    enabled: bool,
+    include: Option<GlobSet>,
+    exclude: GlobSet,
+    skip_generated: bool,
+    target: Option<String>,
+}
+
+/// Why a [`FileFilter`] rejects a file.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash, PartialOrd, Ord, Serialize)]
+#[serde(rename_all = "snake_case")]
+pub enum PolicyExclusion {
+    /// The file's language is disabled
+    LanguageDisabled,
+    /// The file matches an exclude pattern of its language
+    LanguageExcluded,
+    /// The file matches none of its language's include patterns
+    LanguageNotIncluded,
+    /// The file is named for a platform other than the target
+    OtherPlatform,
+    /// The file is generated and generated files are skipped
+    Generated,
+}
+
+impl fmt::Display for PolicyExclusion {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        f.write_str(match self {
+            Self::LanguageDisabled => "language disabled",
+            Self::LanguageExcluded => "language exclude pattern",
+            Self::LanguageNotIncluded => "not matched by language include patterns",
+            Self::OtherPlatform => "named for another platform",
+            Self::Generated => "generated code",
+        })
+    }
+}
+
+impl FileFilter {
+    /// Check whether a source file is indexed.
+    ///
+    /// `rel_path` is relative to the repository root; `abs_path` is read for
+    /// a generated-code marker when generated files are skipped. Files of
+    /// unsupported languages are admitted, since the builder skips them
+    /// anyway.
+    pub fn admits(&self, rel_path: &str, abs_path: &Path) -> bool {
+        self.exclusion(rel_path, abs_path).is_none()
+    }
+
+    /// The reason a source file is not indexed, if it is not.
+    ///
+    /// Takes the same arguments as [`admits`](Self::admits).
+    pub fn exclusion(&self, rel_path: &str, abs_path: &Path) -> Option<PolicyExclusion> {
+        let language = SupportedLanguage::from_path(Path::new(rel_path))?;
+
+        let skip_generated = match self.languages.get(language.as_str()) {
+            Some(rules) => {
+                if !rules.enabled {
+                    return Some(PolicyExclusion::LanguageDisabled);
+                }
+                if rules.exclude.is_match(rel_path) {
+                    return Some(PolicyExclusion::LanguageExcluded);
+                }
+                if rules
+                    .include
+                    .as_ref()
+                    .is_some_and(|include| !include.is_match(rel_path))
+                {
+                    return Some(PolicyExclusion::LanguageNotIncluded);
+                }
+                let file_name = Path::new(rel_path)
+                    .file_name()
+                    .and_then(|name| name.to_str())
+                    .unwrap_or_default();
+                if let Some(target) = &rules.target {
+                    if !matches_target(file_name, target) {
+                        return Some(PolicyExclusion::OtherPlatform);
+                    }
+                }
+                rules.skip_generated
+            }
+            None => self.skip_generated,
+        };
+
+        (skip_generated && read_header(abs_path).is_some_and(|header| is_generated(&header)))
+            .then_some(PolicyExclusion::Generated)
+    }
+}
+
+/// Check whether file content starts with a generated-code marker.
+///
+/// Recognizes the Go convention (`Code generated ... DO NOT EDIT.`),
+/// `@generated`, and `<auto-generated>` (C#) in the first lines.
+pub fn is_generated(content: &[u8]) -> bool {
+    let head = &content[..content.len().min(GENERATED_HEADER_BYTES as usize)];
+    String::from_utf8_lossy(head).lines().any(|line| {
+        (line.contains("Code generated") && line.contains("DO NOT EDIT"))
+            || line.contains("@generated")
+            || line.contains("<auto-generated")
+    })
+}
+
+/// Check whether a file path follows a common test file convention.
+///
+/// Matches test directories (`test`, `tests`, `__tests__`, `testdata`) and
+/// file names such as `server_test.go`, `test_models.py`, `app.spec.ts`, and
+/// `ServerTests.cs`.
+pub fn is_test_file(file: &str) -> bool {
+    let path = Path::new(file);
+    let in_test_dir = path.components().any(|c| {
+        matches!(
+            c.as_os_str().to_str(),
+            Some("test" | "tests" | "__tests__" | "testdata")
+        )
+    });
+    if in_test_dir {
+        return true;
+    }
+
+    let Some(name) = path.file_name().and_then(|n| n.to_str()) else {
+        return false;
+    };
+    let stem = name.split('.').next().unwrap_or(name);
+    stem.ends_with("_test")
+        || stem.starts_with("test_")
+        || name.contains(".test.")
+        || name.contains(".spec.")
+        || stem.ends_with("Tests")
+}
+
+/// Check whether a file is built for the target platform.
+///
+/// Follows the Go file name convention: `name_os`, `name_arch`, and
+/// `name_os_arch` (before the extension and an optional `_test`) restrict
+/// a file to that platform. `target` is "os" or "os/arch"; with only an OS,
+/// files for any architecture match.
+pub fn matches_target(file_name: &str, target: &str) -> bool {
+    let (target_os, target_arch) = match target.split_once('/') {
+        Some((os, arch)) => (os, Some(arch)),
+        None => (target, None),
+    };
+
+    let stem = file_name.split('.').next().unwrap_or(file_name);
+    let stem = stem.strip_suffix("_test").unwrap_or(stem);
+    let parts: Vec<&str> = stem.split('_').collect();
+    // The first part is the base name, never a platform
+    if parts.len() < 2 {
+        return true;
+    }
+
+    let last = parts[parts.len() - 1];
+    let arch_matches = |arch: &str| target_arch.is_none_or(|target| target == arch);
+    if KNOWN_ARCH.contains(&last) {
+        let before = parts[parts.len() - 2];
+        if parts.len() > 2 && KNOWN_OS.contains(&before) {
+            return before == target_os && arch_matches(last);
+        }
+        return arch_matches(last);
+    }
+    if KNOWN_OS.contains(&last) {
+        return last == target_os;
+    }
+    true
+}
+
+/// Read the first bytes of a file.
+fn read_header(path: &Path) -> Option<Vec<u8>> {
+    let file = std::fs::File::open(path).ok()?;
+    let mut header = Vec::new();
+    file.take(GENERATED_HEADER_BYTES)
+        .read_to_end(&mut header)
+        .ok()?;
+    Some(header)
+}
+
+/// Build a glob set from patterns, ignoring invalid ones.
+pub(crate) fn build_glob_set(patterns: &[String]) -> GlobSet {
+    let mut builder = GlobSetBuilder::new();
+    for pattern in patterns {
+        if let Ok(glob) = Glob::new(pattern) {
+            builder.add(glob);
+        }
+    }
+    builder.build().unwrap_or_else(|_| GlobSet::empty())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_matches_target() {
+        assert!(matches_target("poll.go", "linux/amd64"));
+        assert!(matches_target("poll_linux.go", "linux/amd64"));
+        assert!(!matches_target("poll_windows.go", "linux/amd64"));
+        assert!(!matches_target("poll_windows_test.go", "linux"));
+        assert!(matches_target("asm_arm64.s", "linux"));
+        assert!(!matches_target("asm_arm64.go", "linux/amd64"));
+        assert!(matches_target("sys_linux_amd64.go", "linux/amd64"));
+        assert!(!matches_target("sys_linux_arm64.go", "linux/amd64"));
+        // A platform name alone is the base name, not a constraint
+        assert!(matches_target("windows.go", "linux"));
+    }
+
+    #[test]
+    fn test_is_generated() {
+        assert!(is_generated(
+            b"// Code generated by protoc-gen-go. DO NOT EDIT.\n\npackage pb\n"
+        ));
+        assert!(is_generated(b"# @generated by tooling\nx = 1\n"));
+        assert!(!is_generated(b"// Hand written.\npackage main\n"));
+    }
+
+    #[test]
+    fn test_is_test_file() {
+        assert!(is_test_file("pkg/server_test.go"));
+        assert!(is_test_file("tests/integration.rs"));
+        assert!(is_test_file("src/app.spec.ts"));
+        assert!(is_test_file("test_models.py"));
+        assert!(!is_test_file("src/testing.go"));
+        assert!(!is_test_file("src/main.rs"));
+    }
+
+    #[test]
+    fn test_filter_applies_language_rules() {
+        let dir = tempfile::tempdir().unwrap();
+        let generated = dir.path().join("api.pb.go");
+        std::fs::write(&generated, "// Code generated by protoc. DO NOT EDIT.\n").unwrap();
+        let written = dir.path().join("main.go");
+        std::fs::write(&written, "package main\n").unwrap();
+
+        let policy = FilePolicy {
+            skip_generated: false,
+            languages: HashMap::from([(
+                "go".to_string(),
+                LanguageRules {
+                    exclude_patterns: vec!["**/testdata/**".to_string()],
+                    skip_generated: Some(true),
+                    target: Some("linux".to_string()),
+                    ..Default::default()
+                },
+            )]),
+        };
+        let filter = policy.compile();
+
+        assert!(filter.admits("main.go", &written));
+        assert!(!filter.admits("api.pb.go", &generated));
+        assert!(!filter.admits("pkg/testdata/case.go", &written));
+        assert!(!filter.admits("poll_windows.go", &written));
+        // Other languages only follow the global generated-code setting
+        assert!(filter.admits("gen/api_pb2.py", &generated));
+
+        assert_eq!(
+            filter.exclusion("api.pb.go", &generated),
+            Some(PolicyExclusion::Generated)
+        );
+        assert_eq!(
+            filter.exclusion("pkg/testdata/case.go", &written),
+            Some(PolicyExclusion::LanguageExcluded)
+        );
+        assert_eq!(
+            filter.exclusion("poll_windows.go", &written),
+            Some(PolicyExclusion::OtherPlatform)
+        );
+        assert_eq!(filter.exclusion("main.go", &written), None);
+    }
+}
@@ -0,0 +1,233 @@
+//! Clone Fingerprints
+//!
+//! Summarizes a callable's body as a MinHash signature over shingles of its
+//! normalized token stream, so near-duplicate functions can be found by
+//! comparing small fixed-size signatures instead of source text.
+//!
+//! Tokens are the leaves of the tree-sitter AST. Identifiers and literals are
+//! replaced by placeholders and comments are dropped, so copies that only
+//! rename variables or change constants produce the same token stream. The
+//! fraction of matching signature slots estimates the Jaccard similarity of
+//! two bodies' shingle sets, which tolerates small edits between copies.
+//!
+//! Hashing is implemented here (FNV-1a and SplitMix64) rather than with the
+//! standard library hasher, because signatures are persisted and must stay
+//! stable across builds.
+
+use std::collections::HashSet;
+
+use serde::{Deserialize, Serialize};
+use tree_sitter::Node;
+
+use crate::complexity::function_body;
+
+/// Number of MinHash values in a signature
+pub const SIGNATURE_SIZE: usize = 32;
+
+/// Number of consecutive tokens hashed together into one shingle
+pub const SHINGLE_SIZE: usize = 5;
+
+/// Leaf kinds that are literals in some grammars but don't say so in their name
+const LITERAL_KINDS: &[&str] = &[
+    "integer", "float", "number", "true", "false", "nil", "none", "null", "char",
+];
+
+/// Placeholder token for identifiers
+const IDENTIFIER_TOKEN: &str = "$id";
+
+/// Placeholder token for literals
+const LITERAL_TOKEN: &str = "$lit";
+
+/// Clone fingerprint of a single callable body.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct Fingerprint {
+    /// Number of normalized tokens in the body
+    pub tokens: u32,
+    /// MinHash signature ([`SIGNATURE_SIZE`] values)
+    pub signature: Vec<u32>,
+}
+
+/// Fingerprint the body of a callable definition node.
+///
+/// Returns `None` when the definition has no body or the body is shorter than
+/// one shingle.
+pub fn fingerprint(definition: &Node, source: &[u8]) -> Option<Fingerprint> {
+    let body = function_body(definition)?;
+
+    let mut tokens = Vec::new();
+    collect_tokens(body, source, &mut tokens);
+    if tokens.len() < SHINGLE_SIZE {
+        return None;
+    }
+
+    let shingles: HashSet<u64> = tokens.windows(SHINGLE_SIZE).map(hash_shingle).collect();
+    let signature = (0..SIGNATURE_SIZE as u64)
+        .map(|seed| {
+            let salt = splitmix64(seed);
+            shingles
+                .iter()
+                .map(|&shingle| splitmix64(shingle ^ salt) as u32)
+                .min()
+                .unwrap_or(u32::MAX)
+        })
+        .collect();
+
+    Some(Fingerprint {
+        tokens: u32::try_from(tokens.len()).unwrap_or(u32::MAX),
+        signature,
+    })
+}
+
+/// Estimate the similarity of two signatures as a percentage (0-100).
+///
+/// Signatures of different lengths are never similar.
+pub fn similarity(a: &[u32], b: &[u32]) -> u32 {
+    if a.is_empty() || a.len() != b.len() {
+        return 0;
+    }
+    let matching = a.iter().zip(b).filter(|(x, y)| x == y).count();
+    ((matching * 100 + a.len() / 2) / a.len()) as u32
+}
+
+/// Append the normalized token hashes of a subtree
+fn collect_tokens(node: Node<'_>, source: &[u8], tokens: &mut Vec<u64>) {
+    let kind = node.kind();
+
+    if node.is_extra() || kind.contains("comment") {
+        return;
+    }
+
+    // Literals may have children (quotes, escapes); treat them as one token
+    if is_literal(kind) {
+        tokens.push(fnv1a(LITERAL_TOKEN.as_bytes()));
+        return;
+    }
+
+    if node.child_count() == 0 {
+        let token = if kind.ends_with("identifier") {
+            IDENTIFIER_TOKEN.as_bytes()
+        } else if node.is_named() {
+            // Named leaves (e.g. primitive types) are compared by their text
+            node.utf8_text(source).map(str::as_bytes).unwrap_or(b"")
+        } else {
+            // Anonymous leaves are keywords and punctuation; the kind is the text
+            kind.as_bytes()
+        };
+        tokens.push(fnv1a(token));
+        return;
+    }
+
+    let mut cursor = node.walk();
+    for child in node.children(&mut cursor) {
+        collect_tokens(child, source, tokens);
+    }
+}
+
+/// Check whether a node kind is a literal
+fn is_literal(kind: &str) -> bool {
+    kind.contains("literal")
+        || kind.starts_with("string")
+        || kind.ends_with("string")
+        || LITERAL_KINDS.contains(&kind)
+}
+
+/// Hash a window of token hashes into one shingle
+fn hash_shingle(window: &[u64]) -> u64 {
+    window
+        .iter()
+        .fold(FNV_OFFSET, |hash, &token| splitmix64(hash ^ token))
+}
+
+const FNV_OFFSET: u64 = 0xcbf2_9ce4_8422_2325;
+const FNV_PRIME: u64 = 0x0000_0100_0000_01b3;
+
+/// FNV-1a hash of a byte string
+fn fnv1a(bytes: &[u8]) -> u64 {
+    bytes.iter().fold(FNV_OFFSET, |hash, &byte| {
+        (hash ^ u64::from(byte)).wrapping_mul(FNV_PRIME)
+    })
+}
+
+/// SplitMix64 finalizer, used as a cheap family of hash permutations
+fn splitmix64(value: u64) -> u64 {
+    let mut z = value.wrapping_add(0x9e37_79b9_7f4a_7c15);
+    z = (z ^ (z >> 30)).wrapping_mul(0xbf58_476d_1ce4_e5b9);
+    z = (z ^ (z >> 27)).wrapping_mul(0x94d0_49bb_1331_11eb);
+    z ^ (z >> 31)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::parser::{CodeParser, SupportedLanguage};
+
+    /// Fingerprint every top-level Go function in `source`, in order
+    fn fingerprint_all(source: &str) -> Vec<Option<Fingerprint>> {
+        let mut parser = CodeParser::new(SupportedLanguage::Go).unwrap();
+        let tree = parser.parse(source).unwrap();
+        let root = tree.root_node();
+        let mut cursor = root.walk();
+        root.named_children(&mut cursor)
+            .filter(|n| n.kind() == "function_declaration")
+            .map(|n| fingerprint(&n, source.as_bytes()))
+            .collect()
+    }
+
+    #[test]
+    fn test_renamed_copy_is_identical() {
+        let source = r#"package main
+
+func sum(values []int) int {
+	total := 0
+	for _, v := range values {
+		if v > 0 {
+			total += v
+		}
+	}
+	return total
+}
+
+// Copied with different names and a different constant
+func add(xs []int) int {
+	acc := 0
+	for _, x := range xs {
+		if x > 10 {
+			acc += x
+		}
+	}
+	return acc
+}
+
+func other(name string) error {
+	if name == "" {
+		return errors.New("empty name")
+	}
+	fmt.Println("hello", name)
+	return nil
+}
+"#;
+        let prints = fingerprint_all(source);
+        let sum = prints[0].as_ref().unwrap();
+        let add = prints[1].as_ref().unwrap();
+        let other = prints[2].as_ref().unwrap();
+
+        assert_eq!(sum.signature.len(), SIGNATURE_SIZE);
+        assert_eq!(sum.tokens, add.tokens);
+        assert_eq!(similarity(&sum.signature, &add.signature), 100);
+        assert!(similarity(&sum.signature, &other.signature) < 50);
+    }
+
+    #[test]
+    fn test_short_and_bodiless_functions() {
+        let prints = fingerprint_all("package main\n\nfunc f() {}\n");
+        assert_eq!(prints, vec![None]);
+    }
+
+    #[test]
+    fn test_similarity() {
+        assert_eq!(similarity(&[1, 2, 3, 4], &[1, 2, 3, 4]), 100);
+        assert_eq!(similarity(&[1, 2, 3, 4], &[1, 2, 0, 0]), 50);
+        assert_eq!(similarity(&[1, 2], &[1, 2, 3]), 0);
+        assert_eq!(similarity(&[], &[]), 0);
+    }
+}
@@ -0,0 +1,451 @@
+//! Type-Checked Go Resolution
+//!
+//! The syntactic extractor resolves references by name, so a call to
+//! `users.Save` may be linked to whichever `Save` the index defines. Builds
+//! that prefer correctness over indexing speed can run a type checker
+//! through the Go toolchain and merge its resolutions into the graph:
+//!
+//! - USES edges the type checker agrees with are kept
+//! - edges from the same reference (source, line, and name) to other
+//!   targets are removed
+//! - references the name-based resolver missed, such as uses of struct
+//!   fields and methods promoted through embedded fields, get new edges
+//!
+//! The type checker is `codeprysm-gotypes` from the Go SDK
+//! (`go install go.codeprysm.dev/graph/cmd/codeprysm-gotypes@latest`), which
+//! loads packages and their tests with `golang.org/x/tools/go/packages` and
+//! type-checks them from source. It prints one JSON object per resolved
+//! reference; any command printing the same output can be configured
+//! instead.
+//!
+//! References to parameters and locals, and to declarations without a node,
+//! are skipped. Files the type checker does not cover, such as those of
+//! packages that fail to load, keep their name-based edges.
+
+use std::collections::{BTreeMap, BTreeSet, HashMap, HashSet};
+use std::path::Path;
+use std::process::Command;
+
+use petgraph::stable_graph::{EdgeIndex, NodeIndex};
+use petgraph::visit::EdgeRef;
+use petgraph::Direction;
+use serde::{Deserialize, Serialize};
+use thiserror::Error;
+use tracing::debug;
+
+use crate::graph::{DataKind, EdgeData, EdgeType, Node, NodeType, PetCodeGraph};
+
+/// Type checker run when no command is configured
+pub const DEFAULT_COMMAND: &str = "codeprysm-gotypes";
+
+/// Errors that can occur while running the Go type checker.
+#[derive(Debug, Error)]
+pub enum GoTypesError {
+    /// The configured command is empty
+    #[error("Go type checker has no command")]
+    NoCommand,
+
+    /// The type checker could not be started
+    #[error("Failed to start Go type checker '{program}': {source}")]
+    Spawn {
+        program: String,
+        source: std::io::Error,
+    },
+
+    /// The type checker exited with an error
+    #[error("Go type checker '{program}' failed ({status}): {stderr}")]
+    Failed {
+        program: String,
+        status: std::process::ExitStatus,
+        stderr: String,
+    },
+
+    /// The type checker printed something other than references
+    #[error("Invalid Go type checker output on line {line}: {source}")]
+    Output {
+        line: usize,
+        source: serde_json::Error,
+    },
+}
+
+/// How to run the Go type checker.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct GoTypeCheck {
+    /// Program and arguments; the repository root is appended
+    pub command: Vec<String>,
+}
+
+impl Default for GoTypeCheck {
+    fn default() -> Self {
+        Self {
+            command: vec![DEFAULT_COMMAND.to_string()],
+        }
+    }
+}
+
+impl GoTypeCheck {
+    /// Type-check the Go modules under `directory` and return the
+    /// references the type checker resolved.
+    pub fn run(&self, directory: &Path) -> Result<Vec<TypedReference>, GoTypesError> {
+        let (program, args) = self.command.split_first().ok_or(GoTypesError::NoCommand)?;
+        let output = Command::new(program)
+            .args(args)
+            .arg(directory)
+            .output()
+            .map_err(|source| GoTypesError::Spawn {
+                program: program.clone(),
+                source,
+            })?;
+
+        let stderr = String::from_utf8_lossy(&output.stderr);
+        if !output.status.success() {
+            return Err(GoTypesError::Failed {
+                program: program.clone(),
+                status: output.status,
+                stderr: stderr.trim().to_string(),
+            });
+        }
+        for line in stderr.lines() {
+            debug!("Go type checker: {}", line);
+        }
+        parse_references(&String::from_utf8_lossy(&output.stdout))
+    }
+}
+
+/// An identifier the type checker resolved, and the declaration it refers
+/// to. Paths are relative to the repository root; lines and columns are
+/// 1-indexed.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct TypedReference {
+    /// File of the reference
+    pub file: String,
+    /// Line of the reference
+    pub line: usize,
+    /// Column of the reference
+    pub column: usize,
+    /// Referenced name
+    pub name: String,
+    /// File of the declaration
+    pub target_file: String,
+    /// Line of the declared name
+    pub target_line: usize,
+    /// Column of the declared name
+    pub target_column: usize,
+}
+
+/// Parse type checker output: one JSON reference per line.
+pub fn parse_references(output: &str) -> Result<Vec<TypedReference>, GoTypesError> {
+    output
+        .lines()
+        .enumerate()
+        .filter(|(_, line)| !line.trim().is_empty())
+        .map(|(i, line)| {
+            serde_json::from_str(line).map_err(|source| GoTypesError::Output {
+                line: i + 1,
+                source,
+            })
+        })
+        .collect()
+}
+
+/// Changes made by merging type-checked references.
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq)]
+pub struct MergeStats {
+    /// References whose name-based edge was right
+    pub confirmed: usize,
+    /// Edges added for references the name-based resolver missed or got
+    /// wrong
+    pub added: usize,
+    /// Name-based edges to the wrong target, removed
+    pub removed: usize,
+    /// References to declarations without a node
+    pub unmatched: usize,
+}
+
+/// Merge type-checked references into the USES edges of a graph.
+pub fn merge_typed_references(
+    graph: &mut PetCodeGraph,
+    references: &[TypedReference],
+) -> MergeStats {
+    let mut stats = MergeStats::default();
+
+    let mut by_file: HashMap<&str, Vec<&Node>> = HashMap::new();
+    for node in graph.iter_nodes().filter(|n| !n.is_file()) {
+        by_file.entry(node.file.as_str()).or_default().push(node);
+    }
+
+    // Targets of each reference, by source, line, and name
+    let mut targets: BTreeMap<(NodeIndex, usize, &str), BTreeSet<NodeIndex>> = BTreeMap::new();
+    for reference in references {
+        let Some(target) = declaration(&by_file, reference) else {
+            stats.unmatched += 1;
+            continue;
+        };
+        if is_local(target) {
+            continue;
+        }
+        let source = enclosing(&by_file, &reference.file, reference.line)
+            .map_or(reference.file.as_str(), |n| n.id.as_str());
+        if source == target.id {
+            continue;
+        }
+        let (Some(source), Some(target)) = (
+            graph.get_node_index(source),
+            graph.get_node_index(&target.id),
+        ) else {
+            stats.unmatched += 1;
+            continue;
+        };
+        targets
+            .entry((source, reference.line, reference.name.as_str()))
+            .or_default()
+            .insert(target);
+    }
+
+    let inner = graph.inner();
+    let mut remove: HashSet<EdgeIndex> = HashSet::new();
+    let mut add: Vec<(NodeIndex, NodeIndex, usize, String)> = Vec::new();
+    for ((source, line, name), expected) in &targets {
+        let mut found: HashSet<NodeIndex> = HashSet::new();
+        for edge in inner.edges_directed(*source, Direction::Outgoing) {
+            let data = edge.weight();
+            if data.edge_type != EdgeType::Uses
+                || data.ref_line != Some(*line)
+                || data.ident.as_deref() != Some(*name)
+            {
+                continue;
+            }
+            if expected.contains(&edge.target()) {
+                found.insert(edge.target());
+            } else {
+                remove.insert(edge.id());
+            }
+        }
+        stats.confirmed += found.len();
+        for target in expected.difference(&found.into_iter().collect()) {
+            add.push((*source, *target, *line, name.to_string()));
+        }
+    }
+
+    stats.removed = remove.len();
+    stats.added = add.len();
+    let inner = graph.inner_mut();
+    inner.retain_edges(|_, edge| !remove.contains(&edge));
+    for (source, target, line, name) in add {
+        inner.add_edge(source, target, EdgeData::uses(Some(line), Some(name)));
+    }
+    stats
+}
+
+/// Node declaring the name a reference refers to: the narrowest node of
+/// that name spanning the declaration
+fn declaration<'g>(
+    by_file: &HashMap<&str, Vec<&'g Node>>,
+    reference: &TypedReference,
+) -> Option<&'g Node> {
+    by_file
+        .get(reference.target_file.as_str())?
+        .iter()
+        .filter(|n| {
+            n.name == reference.name
+                && n.line <= reference.target_line
+                && reference.target_line <= n.end_line
+        })
+        .min_by_key(|n| n.end_line - n.line)
+        .copied()
+}
+
+/// Innermost callable or container enclosing a line of a file
+fn enclosing<'g>(
+    by_file: &HashMap<&str, Vec<&'g Node>>,
+    file: &str,
+    line: usize,
+) -> Option<&'g Node> {
+    by_file
+        .get(file)?
+        .iter()
+        .filter(|n| {
+            matches!(n.node_type, NodeType::Callable | NodeType::Container)
+                && n.line <= line
+                && line <= n.end_line
+        })
+        .min_by_key(|n| n.end_line - n.line)
+        .copied()
+}
+
+/// Check if a node is a parameter or local variable
+fn is_local(node: &Node) -> bool {
+    node.node_type == NodeType::Data
+        && matches!(
+            node.kind.as_deref(),
+            Some(kind) if kind == DataKind::Parameter.as_str() || kind == DataKind::Local.as_str()
+        )
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::{CallableKind, ContainerKind};
+
+    fn reference(line: usize, name: &str, target: (&str, usize)) -> TypedReference {
+        TypedReference {
+            file: "api/api.go".to_string(),
+            line,
+            column: 10,
+            name: name.to_string(),
+            target_file: target.0.to_string(),
+            target_line: target.1,
+            target_column: 6,
+        }
+    }
+
+    /// `Create` calls `users.Save`, which name-based resolution linked to
+    /// `Orders.Save`
+    fn graph() -> PetCodeGraph {
+        let mut graph = PetCodeGraph::new();
+        graph.add_node(Node::source_file(
+            "api/api.go".to_string(),
+            "api/api.go".to_string(),
+            String::new(),
+            10,
+        ));
+        graph.add_node(Node::callable(
+            "api/api.go:Create".to_string(),
+            "Create".to_string(),
+            CallableKind::Function,
+            "api/api.go".to_string(),
+            5,
+            8,
+        ));
+        graph.add_node(Node::data(
+            "api/api.go:Create:users".to_string(),
+            "users".to_string(),
+            DataKind::Parameter,
+            None,
+            "api/api.go".to_string(),
+            5,
+            5,
+        ));
+        for (name, line) in [("Users", 3), ("Orders", 9)] {
+            graph.add_node(Node::container(
+                format!("store/store.go:{}", name),
+                name.to_string(),
+                ContainerKind::Type,
+                Some("struct".to_string()),
+                "store/store.go".to_string(),
+                line,
+                line + 1,
+            ));
+            let mut save = Node::callable(
+                format!("store/store.go:{}.Save", name),
+                "Save".to_string(),
+                CallableKind::Method,
+                "store/store.go".to_string(),
+                line + 3,
+                line + 3,
+            );
+            save.metadata.receiver = Some(name.to_string());
+            graph.add_node(save);
+        }
+        graph.add_node(Node::data(
+            "store/store.go:Users:Name".to_string(),
+            "Name".to_string(),
+            DataKind::Field,
+            None,
+            "store/store.go".to_string(),
+            4,
+            4,
+        ));
+        graph.add_edge(
+            "api/api.go:Create",
+            "store/store.go:Orders.Save",
+            EdgeData::uses(Some(6), Some("Save".to_string())),
+        );
+        graph.add_edge(
+            "api/api.go:Create",
+            "store/store.go:Users",
+            EdgeData::uses(Some(5), Some("Users".to_string())),
+        );
+        graph
+    }
+
+    fn uses(graph: &PetCodeGraph) -> Vec<(String, Option<usize>)> {
+        let mut targets: Vec<(String, Option<usize>)> = graph
+            .outgoing_edges("api/api.go:Create")
+            .filter(|(_, edge)| edge.edge_type == EdgeType::Uses)
+            .map(|(target, edge)| (target.id.clone(), edge.ref_line))
+            .collect();
+        targets.sort();
+        targets
+    }
+
+    #[test]
+    fn test_merge_typed_references() {
+        let mut graph = graph();
+        let references = vec![
+            reference(5, "Users", ("store/store.go", 3)),
+            reference(6, "users", ("api/api.go", 5)),
+            reference(6, "Save", ("store/store.go", 6)),
+            reference(7, "Name", ("store/store.go", 4)),
+            reference(7, "Missing", ("store/store.go", 20)),
+        ];
+
+        let stats = merge_typed_references(&mut graph, &references);
+        assert_eq!(
+            stats,
+            MergeStats {
+                confirmed: 1,
+                added: 2,
+                removed: 1,
+                unmatched: 1,
+            }
+        );
+        assert_eq!(
+            uses(&graph),
+            vec![
+                ("store/store.go:Users".to_string(), Some(5)),
+                ("store/store.go:Users.Save".to_string(), Some(6)),
+                ("store/store.go:Users:Name".to_string(), Some(7)),
+            ]
+        );
+
+        // Merging again changes nothing
+        let again = merge_typed_references(&mut graph, &references);
+        assert_eq!((again.confirmed, again.added, again.removed), (3, 0, 0));
+    }
+
+    #[test]
+    fn test_parse_references() {
+        let output = concat!(
+            r#"{"file":"api/api.go","line":6,"column":15,"name":"Save","#,
+            r#""target_file":"store/store.go","target_line":6,"target_column":16}"#,
+            "\n\n"
+        );
+        let references = parse_references(output).unwrap();
+        assert_eq!(references.len(), 1);
+        assert_eq!(references[0].target_file, "store/store.go");
+
+        let err = parse_references("{}\n").unwrap_err();
+        assert!(matches!(err, GoTypesError::Output { line: 1, .. }));
+    }
+
+    #[test]
+    fn test_run_errors() {
+        let dir = tempfile::tempdir().unwrap();
+        let check = |command: &[&str]| {
+            GoTypeCheck {
+                command: command.iter().map(|s| s.to_string()).collect(),
+            }
+            .run(dir.path())
+        };
+        assert!(matches!(check(&[]), Err(GoTypesError::NoCommand)));
+        assert!(matches!(
+            check(&["codeprysm-no-such-command"]),
+            Err(GoTypesError::Spawn { .. })
+        ));
+        assert!(matches!(
+            check(&["false"]),
+            Err(GoTypesError::Failed { .. })
+        ));
+    }
+}
@@ -12,10 +12,25 @@ use petgraph::stable_graph::{EdgeIndex, NodeIndex, StableGraph};
 use petgraph::visit::{EdgeRef, IntoEdgeReferences};
 use petgraph::Direction;
 use serde::{Deserialize, Serialize};
-use std::collections::HashMap;
-
-/// Schema version constant
-pub const GRAPH_SCHEMA_VERSION: &str = "2.0";
+use std::collections::{BTreeMap, HashMap};
+
+use crate::control_flow::ControlFlow;
+
+/// Schema version of nodes and edges, written to graph exports and archives
+/// v2.1 adds the `symbol_id` node attribute
+/// v2.2 adds file attributes (`language`, `size_bytes`, `generated`, `test`)
+/// and DEFINED_IN edges
+/// v2.3 adds user-defined `attributes` from enrichment rules
+/// v2.4 adds the file header attributes `license` and `copyright`
+/// v2.5 adds model-written symbol `summary` attributes
+/// v2.6 adds the `entry_point` attribute of detected entry points
+/// v2.7 adds CROSS_LANGUAGE edges and `.proto` files
+/// v2.8 adds REFERS_BY_NAME edges from string literals
+/// v2.9 adds the `control_flow` summary of callables
+/// v2.10 adds EQUIVALENT_TO and SUBSET_OF edges between Go interfaces
+/// v2.11 adds the `url` source permalink of nodes
+/// v2.12 adds type usage counts and the `weight` of types and edges
+pub const GRAPH_SCHEMA_VERSION: &str = "2.12";
 
 // ============================================================================
 // Edge Types
@@ -32,8 +47,25 @@ pub enum EdgeType {
     /// Definition relationships (Container→Data, Callable→Data)
     Defines,
     /// Component dependency (Component→Component for local workspace dependencies)
-    /// Used for: workspace:*, path dependencies, ProjectReference, replace directives
+    /// Used for: workspace:*, path dependencies, ProjectReference, replace directives,
+    /// and Go imports between federated repositories (Repository→Repository)
     DependsOn,
+    /// Near-duplicate code (Callable→Callable), with a similarity score
+    CloneOf,
+    /// Symbol location (any symbol→the File it is declared in)
+    DefinedIn,
+    /// Link across a language boundary (Go→C through cgo, C→Go through
+    /// `//export`, generated Go→the `.proto` definition it was generated from,
+    /// Makefile and Dockerfile build targets→the Go `main` functions they build)
+    CrossLanguage,
+    /// Weak reference through a string literal naming the target (registry
+    /// keys, reflection, subtests, route paths)
+    RefersByName,
+    /// Interface with the same method set as the target (Container→Container)
+    EquivalentTo,
+    /// Interface whose method set is a strict subset of the target's
+    /// (Container→Container)
+    SubsetOf,
 }
 
 impl EdgeType {
@@ -44,6 +76,36 @@ impl EdgeType {
             EdgeType::Uses => "USES",
             EdgeType::Defines => "DEFINES",
             EdgeType::DependsOn => "DEPENDS_ON",
+            EdgeType::CloneOf => "CLONE_OF",
+            EdgeType::DefinedIn => "DEFINED_IN",
+            EdgeType::CrossLanguage => "CROSS_LANGUAGE",
+            EdgeType::RefersByName => "REFERS_BY_NAME",
+            EdgeType::EquivalentTo => "EQUIVALENT_TO",
+            EdgeType::SubsetOf => "SUBSET_OF",
+        }
+    }
+}
+
+impl std::str::FromStr for EdgeType {
+    type Err = String;
+
+    /// Parse an edge type case-insensitively ("USES", "uses", "DependsOn", "depends_on")
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        match s.to_lowercase().as_str() {
+            "contains" => Ok(EdgeType::Contains),
+            "uses" => Ok(EdgeType::Uses),
+            "defines" => Ok(EdgeType::Defines),
+            "dependson" | "depends_on" | "depends-on" => Ok(EdgeType::DependsOn),
+            "cloneof" | "clone_of" | "clone-of" => Ok(EdgeType::CloneOf),
+            "definedin" | "defined_in" | "defined-in" => Ok(EdgeType::DefinedIn),
+            "crosslanguage" | "cross_language" | "cross-language" => Ok(EdgeType::CrossLanguage),
+            "refersbyname" | "refers_by_name" | "refers-by-name" => Ok(EdgeType::RefersByName),
+            "equivalentto" | "equivalent_to" | "equivalent-to" => Ok(EdgeType::EquivalentTo),
+            "subsetof" | "subset_of" | "subset-of" => Ok(EdgeType::SubsetOf),
+            _ => Err(format!(
+                "Unknown edge type: '{}'. Valid values: contains, uses, defines, depends_on, clone_of, defined_in, cross_language, refers_by_name, equivalent_to, subset_of",
+                s
+            )),
         }
     }
 }
@@ -187,6 +249,17 @@ pub enum DataKind {
     Parameter,
     /// Local variable within a callable
     Local,
+    /// TODO, FIXME, or HACK comment (see [`crate::markers`])
+    Marker,
+    /// Package overview from package comments and the README (see
+    /// [`crate::package_docs`])
+    Documentation,
+    /// Symbols left out of a package over its symbol limit (see
+    /// [`crate::limits`])
+    Truncation,
+    /// Makefile target or Dockerfile stage building Go binaries (see
+    /// [`crate::build_targets`])
+    BuildTarget,
 }
 
 impl DataKind {
@@ -199,6 +272,10 @@ impl DataKind {
             DataKind::Property => "property",
             DataKind::Parameter => "parameter",
             DataKind::Local => "local",
+            DataKind::Marker => "marker",
+            DataKind::Documentation => "documentation",
+            DataKind::Truncation => "truncation",
+            DataKind::BuildTarget => "build_target",
         }
     }
 }
@@ -273,6 +350,126 @@ pub struct NodeMetadata {
     #[serde(skip_serializing_if = "Option::is_none")]
     pub scope: Option<String>,
 
+    /// Go method receiver type name (e.g., "Server" for `func (s *Server) Start()`)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub receiver: Option<String>,
+
+    /// Owning teams or users from CODEOWNERS (e.g., "@org/api-team")
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub owners: Option<Vec<String>>,
+
+    /// Author of the line, from git blame (for marker Data nodes)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub author: Option<String>,
+
+    /// Author of most of the symbol's lines, from git blame (for Callable
+    /// and type Container nodes); see [`crate::blame`]
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub primary_author: Option<String>,
+
+    /// Author of the symbol's most recently changed line, from git blame
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub last_modified_by: Option<String>,
+
+    /// User-defined attributes from enrichment rules (e.g., "layer" = "domain");
+    /// see [`crate::enrichment`]
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub attributes: Option<BTreeMap<String, String>>,
+
+    /// One-paragraph summary written by a language model (for Callable and
+    /// type Container nodes); see [`crate::summaries`]
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub summary: Option<String>,
+
+    /// Kind of entry point execution starts from (e.g., "main",
+    /// "http_handler"); see [`crate::entry_points`]
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub entry_point: Option<String>,
+
+    /// Stable symbol ID that survives re-indexing and moves between files
+    /// (e.g., "sym:go:1f0c6a9e3b2d4c58"); see [`crate::symbol_id`]
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub symbol_id: Option<String>,
+
+    // --- Complexity metrics (for Callable nodes with a body) ---
+    /// Cyclomatic complexity
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub cyclomatic_complexity: Option<u32>,
+
+    /// Cognitive complexity
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub cognitive_complexity: Option<u32>,
+
+    /// Branches, loops, early returns, nesting, and switch/select use; see
+    /// [`crate::control_flow`]
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub control_flow: Option<ControlFlow>,
+
+    // --- Clone fingerprint (for Callable nodes with a body) ---
+    /// Number of normalized tokens in the body
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub token_count: Option<u32>,
+
+    /// MinHash signature of the body, used to detect near-duplicates
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub clone_signature: Option<Vec<u32>>,
+
+    // --- Test coverage (for Callable nodes, from a Go cover profile) ---
+    /// Statements executed by tests
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub covered_statements: Option<u32>,
+
+    /// Statements in the callable's coverage blocks
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub total_statements: Option<u32>,
+
+    // --- Change history (for Callable nodes, from git log) ---
+    /// Commits that changed the callable's lines
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub churn_commits: Option<u32>,
+
+    /// Distinct authors of those commits
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub churn_authors: Option<u32>,
+
+    /// Author time of the latest of those commits (Unix seconds)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub last_changed: Option<i64>,
+
+    /// Risk score combining churn, complexity, and fan-in; see
+    /// [`crate::analysis::risk`]
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub risk_score: Option<u32>,
+
+    // --- Type usage (for type Containers, see [`crate::type_usage`]) ---
+    /// Uses of the type in function signatures (parameters, results,
+    /// receivers)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub signature_uses: Option<u32>,
+
+    /// Uses of the type in fields, embedded types, and variables
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub field_uses: Option<u32>,
+
+    /// Uses of the type in function bodies (composite literals,
+    /// conversions, local declarations)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub instantiations: Option<u32>,
+
+    /// All uses of the type across the workspace: how load-bearing it is
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub weight: Option<u32>,
+
+    // --- Reference resolution (for File containers) ---
+    /// References from this file resolved to a definition in the index
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub resolved_refs: Option<u32>,
+
+    /// References from this file to names not defined in the index
+    /// (external dependencies, standard library, or unsupported constructs)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub unresolved_refs: Option<u32>,
+
     // --- Git metadata (for Repository containers) ---
     /// Git remote URL (e.g., "https://github.com/org/repo.git")
     #[serde(skip_serializing_if = "Option::is_none")]
@@ -298,6 +495,37 @@ pub struct NodeMetadata {
     /// Path to the manifest file relative to repo root (for quick lookup)
     #[serde(skip_serializing_if = "Option::is_none")]
     pub manifest_path: Option<String>,
+
+    // --- File attributes (for File containers) ---
+    /// Language the file was parsed as (e.g., "go", "typescript")
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub language: Option<String>,
+
+    /// File size in bytes
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub size_bytes: Option<u64>,
+
+    /// Generated file (carries a "Code generated ... DO NOT EDIT" style marker)
+    #[serde(rename = "generated", skip_serializing_if = "Option::is_none")]
+    pub is_generated: Option<bool>,
+
+    /// Test file, judging by its path (e.g., "server_test.go", "tests/")
+    #[serde(rename = "test", skip_serializing_if = "Option::is_none")]
+    pub is_test: Option<bool>,
+
+    /// SPDX license expression from the file header (e.g., "Apache-2.0");
+    /// see [`crate::license`]
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub license: Option<String>,
+
+    /// Copyright statement from the file header (e.g., "2024 Acme Inc.")
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub copyright: Option<String>,
+
+    /// Permalink to the node's source on its code host, set when the graph
+    /// is served or exported; see [`crate::source_links`]
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub url: Option<String>,
 }
 
 impl NodeMetadata {
@@ -316,12 +544,45 @@ impl NodeMetadata {
             && self.decorators.is_none()
             && self.modifiers.is_none()
             && self.scope.is_none()
+            && self.receiver.is_none()
+            && self.owners.is_none()
+            && self.author.is_none()
+            && self.primary_author.is_none()
+            && self.last_modified_by.is_none()
+            && self.attributes.is_none()
+            && self.summary.is_none()
+            && self.entry_point.is_none()
+            && self.symbol_id.is_none()
+            && self.cyclomatic_complexity.is_none()
+            && self.cognitive_complexity.is_none()
+            && self.control_flow.is_none()
+            && self.token_count.is_none()
+            && self.clone_signature.is_none()
+            && self.covered_statements.is_none()
+            && self.total_statements.is_none()
+            && self.churn_commits.is_none()
+            && self.churn_authors.is_none()
+            && self.last_changed.is_none()
+            && self.risk_score.is_none()
+            && self.signature_uses.is_none()
+            && self.field_uses.is_none()
+            && self.instantiations.is_none()
+            && self.weight.is_none()
+            && self.resolved_refs.is_none()
+            && self.unresolved_refs.is_none()
             && self.git_remote.is_none()
             && self.git_branch.is_none()
             && self.git_commit.is_none()
             && self.is_workspace_root.is_none()
             && self.is_publishable.is_none()
             && self.manifest_path.is_none()
+            && self.language.is_none()
+            && self.size_bytes.is_none()
+            && self.is_generated.is_none()
+            && self.is_test.is_none()
+            && self.license.is_none()
+            && self.copyright.is_none()
+            && self.url.is_none()
     }
 
     /// Create git metadata for a repository container
@@ -354,6 +615,28 @@ impl NodeMetadata {
         self.manifest_path = manifest_path;
         self
     }
+
+    /// Create file attributes for a file container
+    pub fn with_file(
+        mut self,
+        language: &str,
+        size_bytes: u64,
+        is_generated: bool,
+        is_test: bool,
+    ) -> Self {
+        self.language = Some(language.to_string());
+        self.size_bytes = Some(size_bytes);
+        self.is_generated = Some(is_generated);
+        self.is_test = Some(is_test);
+        self
+    }
+
+    /// Set the license and copyright from a file header
+    pub fn with_license(mut self, license: Option<String>, copyright: Option<String>) -> Self {
+        self.license = license;
+        self.copyright = copyright;
+        self
+    }
 }
 
 // ============================================================================
@@ -595,6 +878,27 @@ impl Node {
         self.node_type == NodeType::Container
     }
 
+    /// Check if this is a TODO, FIXME, or HACK marker (Data with kind="marker")
+    pub fn is_marker(&self) -> bool {
+        self.node_type == NodeType::Data && self.kind.as_deref() == Some("marker")
+    }
+
+    /// Check if this is a package documentation node (Data with
+    /// kind="documentation")
+    pub fn is_documentation(&self) -> bool {
+        self.node_type == NodeType::Data && self.kind.as_deref() == Some("documentation")
+    }
+
+    /// Check if this is a truncation marker (Data with kind="truncation")
+    pub fn is_truncation(&self) -> bool {
+        self.node_type == NodeType::Data && self.kind.as_deref() == Some("truncation")
+    }
+
+    /// Check if this is a build target (Data with kind="build_target")
+    pub fn is_build_target(&self) -> bool {
+        self.node_type == NodeType::Data && self.kind.as_deref() == Some("build_target")
+    }
+
     /// Check if this is a Callable node
     pub fn is_callable(&self) -> bool {
         self.node_type == NodeType::Callable
@@ -648,9 +952,37 @@ pub struct Edge {
     /// Whether this is a development dependency (devDependencies, dev-dependencies, etc.)
     #[serde(skip_serializing_if = "Option::is_none")]
     pub is_dev_dependency: Option<bool>,
+
+    // --- CloneOf edge metadata ---
+    /// Similarity of the two clones as a percentage (0-100)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub similarity: Option<u32>,
+
+    // --- Type usage weight (for USES edges to types and DEPENDS_ON edges) ---
+    /// Number of type uses the edge stands for (see [`crate::type_usage`])
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub weight: Option<u32>,
 }
 
 impl Edge {
+    /// Compare edges by source, target, and type, then by their remaining
+    /// attributes.
+    ///
+    /// A total order over edge contents: edges written in this order come out
+    /// the same on every build, whatever order they were added in.
+    pub fn stable_cmp(&self, other: &Self) -> std::cmp::Ordering {
+        self.source
+            .cmp(&other.source)
+            .then_with(|| self.target.cmp(&other.target))
+            .then_with(|| self.edge_type.as_str().cmp(other.edge_type.as_str()))
+            .then_with(|| self.ref_line.cmp(&other.ref_line))
+            .then_with(|| self.ident.cmp(&other.ident))
+            .then_with(|| self.version_spec.cmp(&other.version_spec))
+            .then_with(|| self.is_dev_dependency.cmp(&other.is_dev_dependency))
+            .then_with(|| self.similarity.cmp(&other.similarity))
+            .then_with(|| self.weight.cmp(&other.weight))
+    }
+
     /// Create a CONTAINS edge (parent contains child)
     pub fn contains(parent: String, child: String) -> Self {
         Self {
@@ -661,6 +993,8 @@ impl Edge {
             ident: None,
             version_spec: None,
             is_dev_dependency: None,
+            similarity: None,
+            weight: None,
         }
     }
 
@@ -679,6 +1013,8 @@ impl Edge {
             ident,
             version_spec: None,
             is_dev_dependency: None,
+            similarity: None,
+            weight: None,
         }
     }
 
@@ -692,6 +1028,8 @@ impl Edge {
             ident: None,
             version_spec: None,
             is_dev_dependency: None,
+            similarity: None,
+            weight: None,
         }
     }
 
@@ -718,6 +1056,114 @@ impl Edge {
             ident,
             version_spec,
             is_dev_dependency: is_dev,
+            similarity: None,
+            weight: None,
+        }
+    }
+
+    /// Create a CLONE_OF edge (source duplicates target)
+    ///
+    /// # Arguments
+    /// * `similarity` - Similarity as a percentage (0-100)
+    pub fn clone_of(source: String, target: String, similarity: u32) -> Self {
+        Self {
+            source,
+            target,
+            edge_type: EdgeType::CloneOf,
+            ref_line: None,
+            ident: None,
+            version_spec: None,
+            is_dev_dependency: None,
+            similarity: Some(similarity),
+            weight: None,
+        }
+    }
+
+    /// Create a DEFINED_IN edge (symbol is declared in file)
+    pub fn defined_in(symbol: String, file: String) -> Self {
+        Self {
+            source: symbol,
+            target: file,
+            edge_type: EdgeType::DefinedIn,
+            ref_line: None,
+            ident: None,
+            version_spec: None,
+            is_dev_dependency: None,
+            similarity: None,
+            weight: None,
+        }
+    }
+
+    /// Create a CROSS_LANGUAGE edge (source uses or was generated from
+    /// target, in another language)
+    pub fn cross_language(
+        source: String,
+        target: String,
+        ref_line: Option<usize>,
+        ident: Option<String>,
+    ) -> Self {
+        Self {
+            source,
+            target,
+            edge_type: EdgeType::CrossLanguage,
+            ref_line,
+            ident,
+            version_spec: None,
+            is_dev_dependency: None,
+            similarity: None,
+            weight: None,
+        }
+    }
+
+    /// Create a REFERS_BY_NAME edge (a string literal in source names target)
+    pub fn refers_by_name(
+        source: String,
+        target: String,
+        ref_line: Option<usize>,
+        ident: Option<String>,
+    ) -> Self {
+        Self {
+            source,
+            target,
+            edge_type: EdgeType::RefersByName,
+            ref_line,
+            ident,
+            version_spec: None,
+            is_dev_dependency: None,
+            similarity: None,
+            weight: None,
+        }
+    }
+
+    /// Create an EQUIVALENT_TO edge (source and target interfaces have the
+    /// same method set)
+    pub fn equivalent_to(source: String, target: String) -> Self {
+        Self {
+            source,
+            target,
+            edge_type: EdgeType::EquivalentTo,
+            ref_line: None,
+            ident: None,
+            version_spec: None,
+            is_dev_dependency: None,
+            similarity: None,
+            weight: None,
+        }
+    }
+
+    /// Create a SUBSET_OF edge (the source interface's methods are a strict
+    /// subset of the target's)
+    pub fn subset_of(source: String, target: String) -> Self {
+        Self {
+            source,
+            target,
+            edge_type: EdgeType::SubsetOf,
+            ref_line: None,
+            ident: None,
+            version_spec: None,
+            is_dev_dependency: None,
+            similarity: None,
+            weight: None,
         }
     }
 }
@@ -794,6 +1240,10 @@ pub fn parse_data_kind(kind: &str) -> Option<DataKind> {
         "property" => Some(DataKind::Property),
         "parameter" => Some(DataKind::Parameter),
         "local" => Some(DataKind::Local),
+        "marker" => Some(DataKind::Marker),
+        "documentation" => Some(DataKind::Documentation),
+        "truncation" => Some(DataKind::Truncation),
+        "build_target" => Some(DataKind::BuildTarget),
         _ => None,
     }
 }
@@ -808,7 +1258,7 @@ pub fn parse_data_kind(kind: &str) -> Option<DataKind> {
 /// enabling efficient traversal while preserving edge semantics.
 #[derive(Debug, Clone, PartialEq, Eq)]
 pub struct EdgeData {
-    /// Relationship type (CONTAINS, USES, DEFINES, DEPENDS_ON)
+    /// Relationship type (CONTAINS, USES, DEFINES, DEPENDS_ON, CLONE_OF)
     pub edge_type: EdgeType,
     /// Line number where the reference occurs (for USES edges)
     pub ref_line: Option<usize>,
@@ -818,6 +1268,10 @@ pub struct EdgeData {
     pub version_spec: Option<String>,
     /// Whether this is a development dependency (for DEPENDS_ON edges)
     pub is_dev_dependency: Option<bool>,
+    /// Similarity percentage (for CLONE_OF edges)
+    pub similarity: Option<u32>,
+    /// Number of type uses (for USES edges to types and DEPENDS_ON edges)
+    pub weight: Option<u32>,
 }
 
 impl EdgeData {
@@ -829,6 +1283,8 @@ impl EdgeData {
             ident: None,
             version_spec: None,
             is_dev_dependency: None,
+            similarity: None,
+            weight: None,
         }
     }
 
@@ -840,6 +1296,8 @@ impl EdgeData {
             ident,
             version_spec: None,
             is_dev_dependency: None,
+            similarity: None,
+            weight: None,
         }
     }
 
@@ -851,6 +1309,8 @@ impl EdgeData {
             ident: None,
             version_spec: None,
             is_dev_dependency: None,
+            similarity: None,
+            weight: None,
         }
     }
 
@@ -871,6 +1331,86 @@ impl EdgeData {
             ident,
             version_spec,
             is_dev_dependency: is_dev,
+            similarity: None,
+            weight: None,
+        }
+    }
+
+    /// Create a CLONE_OF edge data with a similarity percentage (0-100)
+    pub fn clone_of(similarity: u32) -> Self {
+        Self {
+            edge_type: EdgeType::CloneOf,
+            ref_line: None,
+            ident: None,
+            version_spec: None,
+            is_dev_dependency: None,
+            similarity: Some(similarity),
+            weight: None,
+        }
+    }
+
+    /// Create a DEFINED_IN edge data
+    pub fn defined_in() -> Self {
+        Self {
+            edge_type: EdgeType::DefinedIn,
+            ref_line: None,
+            ident: None,
+            version_spec: None,
+            is_dev_dependency: None,
+            similarity: None,
+            weight: None,
+        }
+    }
+
+    /// Create a CROSS_LANGUAGE edge data
+    pub fn cross_language(ref_line: Option<usize>, ident: Option<String>) -> Self {
+        Self {
+            edge_type: EdgeType::CrossLanguage,
+            ref_line,
+            ident,
+            version_spec: None,
+            is_dev_dependency: None,
+            similarity: None,
+            weight: None,
+        }
+    }
+
+    /// Create a REFERS_BY_NAME edge data
+    pub fn refers_by_name(ref_line: Option<usize>, ident: Option<String>) -> Self {
+        Self {
+            edge_type: EdgeType::RefersByName,
+            ref_line,
+            ident,
+            version_spec: None,
+            is_dev_dependency: None,
+            similarity: None,
+            weight: None,
+        }
+    }
+
+    /// Create an EQUIVALENT_TO edge data
+    pub fn equivalent_to() -> Self {
+        Self {
+            edge_type: EdgeType::EquivalentTo,
+            ref_line: None,
+            ident: None,
+            version_spec: None,
+            is_dev_dependency: None,
+            similarity: None,
+            weight: None,
+        }
+    }
+
+    /// Create a SUBSET_OF edge data
+    pub fn subset_of() -> Self {
+        Self {
+            edge_type: EdgeType::SubsetOf,
+            ref_line: None,
+            ident: None,
+            version_spec: None,
+            is_dev_dependency: None,
+            similarity: None,
+            weight: None,
         }
     }
 }
@@ -883,6 +1423,8 @@ impl From<&Edge> for EdgeData {
             ident: edge.ident.clone(),
             version_spec: edge.version_spec.clone(),
             is_dev_dependency: edge.is_dev_dependency,
+            similarity: edge.similarity,
+            weight: edge.weight,
         }
     }
 }
@@ -1035,6 +1577,8 @@ impl PetCodeGraph {
                 ident: edge.ident.clone(),
                 version_spec: edge.version_spec.clone(),
                 is_dev_dependency: edge.is_dev_dependency,
+                similarity: edge.similarity,
+                weight: edge.weight,
             },
         )
     }
@@ -1099,6 +1643,8 @@ impl PetCodeGraph {
                 ident: edge_data.ident.clone(),
                 version_spec: edge_data.version_spec.clone(),
                 is_dev_dependency: edge_data.is_dev_dependency,
+                similarity: edge_data.similarity,
+                weight: edge_data.weight,
             })
         })
     }
@@ -1145,6 +1691,14 @@ impl PetCodeGraph {
             .map(|(node, _)| node)
     }
 
+    /// Get every symbol declared in a file, at any depth (incoming
+    /// DEFINED_IN edges)
+    pub fn file_symbols(&self, file_id: &str) -> impl Iterator<Item = &Node> {
+        self.incoming_edges(file_id)
+            .filter(|(_, edge_data)| edge_data.edge_type == EdgeType::DefinedIn)
+            .map(|(node, _)| node)
+    }
+
     // ------------------------------------------------------------------------
     // File Operations
     // ------------------------------------------------------------------------
@@ -1165,6 +1719,25 @@ impl PetCodeGraph {
         }
     }
 
+    /// Remove all edges of a type, returning how many were removed
+    pub fn remove_edges_by_type(&mut self, edge_type: EdgeType) -> usize {
+        let before = self.graph.edge_count();
+        self.graph
+            .retain_edges(|graph, edge| graph[edge].edge_type != edge_type);
+        before - self.graph.edge_count()
+    }
+
+    /// Keep only the edges for which `keep(source, target, edge)` holds,
+    /// returning how many were removed
+    pub fn retain_edges(&mut self, mut keep: impl FnMut(&Node, &Node, &EdgeData) -> bool) -> usize {
+        let before = self.graph.edge_count();
+        self.graph.retain_edges(|graph, edge| {
+            let (source, target) = graph.edge_endpoints(edge).expect("retained edges exist");
+            keep(&graph[source], &graph[target], &graph[edge])
+        });
+        before - self.graph.edge_count()
+    }
+
     // ------------------------------------------------------------------------
     // Low-level Access (for advanced use cases)
     // ------------------------------------------------------------------------
@@ -1200,6 +1773,25 @@ mod tests {
         assert_eq!(json, "\"USES\"");
     }
 
+    #[test]
+    fn test_edge_type_from_str() {
+        assert_eq!("USES".parse::<EdgeType>(), Ok(EdgeType::Uses));
+        assert_eq!("contains".parse::<EdgeType>(), Ok(EdgeType::Contains));
+        assert_eq!("DependsOn".parse::<EdgeType>(), Ok(EdgeType::DependsOn));
+        assert_eq!("depends_on".parse::<EdgeType>(), Ok(EdgeType::DependsOn));
+        assert_eq!("DEFINED_IN".parse::<EdgeType>(), Ok(EdgeType::DefinedIn));
+        assert_eq!(
+            "cross-language".parse::<EdgeType>(),
+            Ok(EdgeType::CrossLanguage)
+        );
+        assert_eq!(
+            "REFERS_BY_NAME".parse::<EdgeType>(),
+            Ok(EdgeType::RefersByName)
+        );
+        assert_eq!("subset-of".parse::<EdgeType>(), Ok(EdgeType::SubsetOf));
+        assert!("calls".parse::<EdgeType>().is_err());
+    }
+
     #[test]
     fn test_node_type_serialization() {
         // Test serialization
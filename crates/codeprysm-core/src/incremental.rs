@@ -3,18 +3,44 @@
 //! This module orchestrates incremental updates to the code graph based on
 //! file changes detected via Merkle trees. It enables efficient updates by
 //! only reprocessing modified, added, or deleted files.
+//!
+//! Changed files are reparsed and their references resolved against the whole
+//! graph. References from unchanged files into changed ones are kept (or
+//! re-resolved by name if their target moved); references from unchanged files
+//! to names that a change newly defines are picked up by the next full build.
 
-use std::collections::HashMap;
+use std::collections::{HashMap, HashSet};
 use std::path::{Path, PathBuf};
+use std::time::{SystemTime, UNIX_EPOCH};
 
+use globset::{Glob, GlobSetBuilder};
+use ignore::gitignore::GitignoreBuilder;
 use thiserror::Error;
 use tracing::{debug, info, warn};
 
-use crate::builder::{BuilderConfig, GraphBuilder};
-use crate::graph::PetCodeGraph;
+use crate::aliases::{detect_aliases, AliasOptions, AliasTable, SymbolAlias};
+use crate::analysis::clones::{link_clones, CloneOptions};
+use crate::analysis::interfaces::{link_interface_overlaps, InterfaceOptions};
+use crate::analysis::leaks::{attach_leaks, find_leaks};
+use crate::builder::{
+    definitions, resolve_file_references, BuilderConfig, FileReferences, GraphBuilder,
+};
+use crate::codeowners::CodeOwners;
+use crate::enrichment::Enrichment;
+use crate::extraction_rules::remove_disabled_edges;
+use crate::file_policy::build_glob_set;
+use crate::graph::{Edge, EdgeType, PetCodeGraph};
+use crate::lazy::lock::WriteLock;
 use crate::lazy::manager::LazyGraphManager;
 use crate::lazy::partitioner::GraphPartitioner;
-use crate::merkle::{ChangeSet, ExclusionFilter, MerkleTree, MerkleTreeManager};
+use crate::limits::enforce_limits;
+use crate::markers;
+use crate::merkle::{compute_file_hash, ChangeSet, ExclusionFilter, MerkleTree, MerkleTreeManager};
+use crate::package_docs::attach_package_docs;
+use crate::parser::SupportedLanguage;
+use crate::projects::attach_projects;
+use crate::string_refs::link_string_references;
+use crate::type_usage::weigh_type_usage;
 
 // ============================================================================
 // Errors
@@ -50,6 +76,14 @@ pub enum UpdaterError {
     /// Merkle tree error
     #[error("Merkle tree error: {0}")]
     Merkle(#[from] crate::merkle::MerkleError),
+
+    /// No index to update
+    #[error("Index not found: {0}")]
+    IndexNotFound(PathBuf),
+
+    /// Another process kept the index locked
+    #[error("Lock error: {0}")]
+    Lock(#[from] crate::lazy::lock::LockError),
 }
 
 /// Result type for updater operations.
@@ -299,15 +333,19 @@ impl IncrementalUpdater {
     ///
     /// `UpdateResult` with information about what was updated.
     pub fn update_repository(&mut self, force_rebuild: bool) -> Result<UpdateResult> {
+        // Held from loading the graph until the update is saved, so a
+        // concurrent writer's changes are neither overwritten nor lost
+        let lock = WriteLock::acquire(&self.prism_dir)?;
+
         if force_rebuild {
             info!("Performing force rebuild...");
-            return self.full_rebuild();
+            return self.full_rebuild(&lock);
         }
 
         // Load existing state
         if !self.load_graph_state()? {
             info!("No existing graph found, performing initial build...");
-            return self.full_rebuild();
+            return self.full_rebuild(&lock);
         }
 
         // Detect changes
@@ -325,10 +363,12 @@ impl IncrementalUpdater {
         info!("Processing {} changed files...", changes.total_changes());
 
         // Process changes
-        self.process_changes(&changes)?;
+        let repo_path = self.repo_path.clone();
+        let aliases = self.process_changes(&changes, &repo_path)?;
+        self.record_aliases(aliases, &lock)?;
 
         // Save updated graph
-        self.save_graph()?;
+        self.save_graph(&lock)?;
 
         info!("Incremental update completed successfully");
 
@@ -339,10 +379,205 @@ impl IncrementalUpdater {
         })
     }
 
-    /// Process detected file changes.
-    fn process_changes(&mut self, changes: &ChangeSet) -> Result<()> {
+    /// Apply changes to specific paths, e.g. from filesystem notifications.
+    ///
+    /// Each path (relative to the repository) is rehashed and compared with
+    /// the indexed state, so paths whose content did not change are no-ops.
+    /// Directories stand for the files in them; pass an empty path to sync
+    /// the whole repository. Paths that are excluded, ignored, or not source
+    /// files are skipped. The graph state is loaded on first use; the updated
+    /// graph is saved if anything changed. The index's [`WriteLock`] is held
+    /// throughout.
+    pub fn apply_file_changes(&mut self, paths: &[String]) -> Result<UpdateResult> {
+        let lock = WriteLock::acquire(&self.prism_dir)?;
+        if self.graph.is_none() && !self.load_graph_state()? {
+            info!("No existing graph found, performing initial build...");
+            return self.full_rebuild(&lock);
+        }
+
+        let mut changes = ChangeSet::new();
+        for rel_path in self.expand_paths(paths)? {
+            let abs_path = self.repo_path.join(&rel_path);
+            let indexed = self.current_merkle_tree.get(&rel_path).cloned();
+
+            if !abs_path.is_file() {
+                if indexed.is_some() {
+                    self.current_merkle_tree.remove(&rel_path);
+                    changes.deleted.push(rel_path);
+                }
+                continue;
+            }
+            if self.is_ignored(&rel_path) {
+                continue;
+            }
+            // The file may be gone again or unreadable mid-save
+            let Ok(hash) = compute_file_hash(&abs_path) else {
+                continue;
+            };
+            match indexed {
+                Some(ref old) if *old == hash => {}
+                Some(_) => changes.modified.push(rel_path.clone()),
+                None => changes.added.push(rel_path.clone()),
+            }
+            self.current_merkle_tree.insert(rel_path, hash);
+        }
+
+        if changes.has_changes() {
+            changes.modified.sort();
+            changes.added.sort();
+            changes.deleted.sort();
+            info!("Processing {} changed files...", changes.total_changes());
+            let repo_path = self.repo_path.clone();
+            let aliases = self.process_changes(&changes, &repo_path)?;
+            self.record_aliases(aliases, &lock)?;
+            self.save_graph(&lock)?;
+        }
+
+        Ok(UpdateResult {
+            success: true,
+            changes,
+            was_full_rebuild: false,
+        })
+    }
+
+    /// Apply a change set to a copy of the indexed graph and return it,
+    /// leaving the index and the updater state untouched.
+    ///
+    /// Added and modified files are read from `source_root` instead of the
+    /// repository, e.g. from a checkout of the git index, so content that is
+    /// not in the working tree can be evaluated. Paths that are excluded,
+    /// ignored, or not source files are skipped, as are deletions of files
+    /// that are not indexed.
+    pub fn preview_changes(
+        &mut self,
+        source_root: &Path,
+        changes: &ChangeSet,
+    ) -> Result<PetCodeGraph> {
+        if self.graph.is_none() && !self.load_graph_state()? {
+            return Err(UpdaterError::IndexNotFound(self.prism_dir.clone()));
+        }
+
+        let keep = |paths: &[String]| -> Vec<String> {
+            paths
+                .iter()
+                .filter(|path| !self.is_ignored(path))
+                .cloned()
+                .collect()
+        };
+        let changes = ChangeSet {
+            modified: keep(&changes.modified),
+            added: keep(&changes.added),
+            deleted: changes
+                .deleted
+                .iter()
+                .filter(|path| self.current_merkle_tree.contains_key(*path))
+                .cloned()
+                .collect(),
+        };
+
+        let baseline = self.graph.clone();
+        let result = self.process_changes(&changes, source_root);
+        let preview = std::mem::replace(&mut self.graph, baseline);
+        result?;
+        Ok(preview.expect("graph loaded above"))
+    }
+
+    /// Replace directories by the files in them, on disk and in the index
+    /// (so removed files are noticed). An empty path is the repository root.
+    fn expand_paths(&self, paths: &[String]) -> Result<HashSet<String>> {
+        let mut files = HashSet::new();
+        for rel_path in paths {
+            let rel_path = rel_path.trim_end_matches('/');
+            let abs_path = self.repo_path.join(rel_path);
+            let exists = abs_path.exists();
+            if exists && !abs_path.is_dir() {
+                files.insert(rel_path.to_string());
+                continue;
+            }
+
+            let prefix = if rel_path.is_empty() {
+                String::new()
+            } else {
+                format!("{}/", rel_path)
+            };
+            files.extend(
+                self.current_merkle_tree
+                    .keys()
+                    .filter(|file| file.starts_with(&prefix))
+                    .cloned(),
+            );
+            if exists {
+                let tree = self.merkle_manager.build_merkle_tree(&abs_path)?;
+                files.extend(tree.into_keys().map(|file| format!("{}{}", prefix, file)));
+            } else {
+                files.insert(rel_path.to_string());
+            }
+        }
+        Ok(files)
+    }
+
+    /// Check if a file should stay out of the graph: not a supported source
+    /// file, excluded by the filter, configured patterns, or file policy, not
+    /// matching the include patterns, or ignored by the repository's root
+    /// `.gitignore` or `.codeprysmignore`.
+    fn is_ignored(&self, rel_path: &str) -> bool {
+        let path = Path::new(rel_path);
+        if SupportedLanguage::from_path(path).is_none()
+            || self.merkle_manager.exclusion_filter().should_exclude(path)
+        {
+            return true;
+        }
+
+        let mut patterns = GlobSetBuilder::new();
+        for pattern in &self.builder_config.exclude_patterns {
+            if let Ok(glob) = Glob::new(pattern) {
+                patterns.add(glob);
+            }
+        }
+        if patterns.build().is_ok_and(|set| set.is_match(path)) {
+            return true;
+        }
+        let include = &self.builder_config.include_patterns;
+        if !include.is_empty() && !build_glob_set(include).is_match(path) {
+            return true;
+        }
+        if !self
+            .builder_config
+            .file_policy
+            .compile()
+            .admits(rel_path, &self.repo_path.join(rel_path))
+        {
+            return true;
+        }
+
+        let mut gitignore = GitignoreBuilder::new(&self.repo_path);
+        for name in [".gitignore", ".codeprysmignore"] {
+            let file = self.repo_path.join(name);
+            if file.exists() {
+                gitignore.add(file);
+            }
+        }
+        gitignore.build().is_ok_and(|gitignore| {
+            gitignore
+                .matched_path_or_any_parents(path, false)
+                .is_ignore()
+        })
+    }
+
+    /// Process detected file changes, reading changed files from `source_root`.
+    fn process_changes(
+        &mut self,
+        changes: &ChangeSet,
+        source_root: &Path,
+    ) -> Result<Vec<SymbolAlias>> {
         let start = std::time::Instant::now();
 
+        // References from unchanged files go away with the nodes they target
+        let inbound = self.inbound_references(changes);
+
+        // Symbols of changed files, to notice renames and moves
+        let before = self.symbols_in(changes.modified.iter().chain(&changes.deleted));
+
         // Handle deleted files first
         if !changes.deleted.is_empty() {
             self.process_deleted_files(&changes.deleted);
@@ -361,19 +596,173 @@ impl IncrementalUpdater {
             .cloned()
             .collect();
 
-        if !files_to_reparse.is_empty() {
-            self.reparse_files(&files_to_reparse)?;
+        let references = if files_to_reparse.is_empty() {
+            Vec::new()
+        } else {
+            self.reparse_files(&files_to_reparse, source_root)?
+        };
+
+        if let Some(graph) = self.graph.as_mut() {
+            let defines = definitions(graph);
+            for file_references in &references {
+                resolve_file_references(graph, &defines, file_references);
+            }
+            let restored = Self::restore_inbound_references(graph, &defines, inbound);
+            debug!("Restored {} inbound reference(s)", restored);
+
+            // Changed bodies can gain or lose clones anywhere in the graph
+            let clone_count = link_clones(graph, &CloneOptions::default());
+            debug!("Relinked {} clone pair(s)", clone_count);
+            let overlaps = link_interface_overlaps(graph, &InterfaceOptions::default(), |file| {
+                std::fs::read_to_string(self.repo_path.join(file)).ok()
+            });
+            debug!("Relinked {} overlapping interface pair(s)", overlaps);
+            let by_name = link_string_references(graph, |file| {
+                std::fs::read_to_string(self.repo_path.join(file)).ok()
+            });
+            debug!(
+                "Relinked {} string reference(s)",
+                by_name.symbols + by_name.routes
+            );
+            let leaks = find_leaks(graph, |file| {
+                std::fs::read_to_string(self.repo_path.join(file)).ok()
+            });
+            let leaky = attach_leaks(graph, &leaks);
+            debug!(
+                "Found {} suspected leak(s) in {} function(s)",
+                leaks.len(),
+                leaky
+            );
+            let disabled = remove_disabled_edges(graph, &self.builder_config.extraction);
+            debug!("Removed {} disabled edge(s)", disabled);
+            for truncation in enforce_limits(graph, &self.builder_config.symbol_limits) {
+                warn!(
+                    "Left {} symbol(s) in {} out of the graph: {}",
+                    truncation.symbols,
+                    truncation.package,
+                    truncation.limit.describe()
+                );
+            }
+            // Changed files change the uses of types anywhere in the graph
+            let weighed = weigh_type_usage(graph);
+            debug!("Reweighed {} type(s)", weighed.types);
+
+            // Reparsed nodes come back without owners
+            let codeowners = CodeOwners::load(&self.repo_path).unwrap_or_default();
+            let owned = codeowners.apply(graph);
+            debug!("Assigned owners to {} node(s)", owned);
+            let attributed = markers::attach_graph_authors(&self.repo_path, graph);
+            debug!("Attributed {} marker(s)", attributed);
+            let documented = attach_package_docs(&self.repo_path, graph);
+            debug!("Documented {} package(s)", documented);
+            let tagged = Enrichment::new(&self.builder_config.attributes).apply(graph);
+            debug!("Tagged {} node(s) with attributes", tagged);
+            let in_projects = attach_projects(&self.repo_path, graph);
+            debug!("Tagged {} node(s) with their project", in_projects);
         }
 
+        let after = self.symbols_in(changes.modified.iter().chain(&changes.added));
+        let aliases = detect_aliases(&before, &after, &AliasOptions::default());
+
         let elapsed = start.elapsed();
         info!(
             "Change processing completed in {:.2}s",
             elapsed.as_secs_f64()
         );
 
+        Ok(aliases)
+    }
+
+    /// Copy the nodes with symbol IDs defined in the given files.
+    fn symbols_in<'a>(&self, files: impl Iterator<Item = &'a String>) -> PetCodeGraph {
+        let files: HashSet<&str> = files.map(String::as_str).collect();
+        let mut symbols = PetCodeGraph::new();
+        if let Some(graph) = self.graph.as_ref() {
+            for node in graph.iter_nodes() {
+                if node.metadata.symbol_id.is_some() && files.contains(node.file.as_str()) {
+                    symbols.add_node(node.clone());
+                }
+            }
+        }
+        symbols
+    }
+
+    /// Record aliases of symbols whose ID changed in the index's alias table,
+    /// and attach every symbol's earlier IDs to the graph.
+    fn record_aliases(&mut self, aliases: Vec<SymbolAlias>, _lock: &WriteLock) -> Result<()> {
+        let mut table = AliasTable::load(&self.prism_dir);
+        let now = SystemTime::now()
+            .duration_since(UNIX_EPOCH)
+            .map(|d| d.as_secs() as i64)
+            .unwrap_or(0);
+        let recorded = table.record(aliases, now);
+        if recorded > 0 {
+            table.save(&self.prism_dir)?;
+            info!("Recorded {} renamed or moved symbol(s)", recorded);
+        }
+        if let Some(graph) = self.graph.as_mut() {
+            let aliased = table.attach(graph);
+            debug!("Attached earlier IDs to {} symbol(s)", aliased);
+        }
         Ok(())
     }
 
+    /// Collect USES edges from unchanged files into changed or deleted ones.
+    fn inbound_references(&self, changes: &ChangeSet) -> Vec<Edge> {
+        let Some(graph) = self.graph.as_ref() else {
+            return Vec::new();
+        };
+        let changed: HashSet<&str> = changes
+            .modified
+            .iter()
+            .chain(changes.added.iter())
+            .chain(changes.deleted.iter())
+            .map(String::as_str)
+            .collect();
+
+        let mut inbound = Vec::new();
+        for target in graph
+            .iter_nodes()
+            .filter(|n| changed.contains(n.file.as_str()))
+        {
+            for (source, edge) in graph.incoming_edges(&target.id) {
+                if edge.edge_type == EdgeType::Uses && !changed.contains(source.file.as_str()) {
+                    inbound.push(Edge::uses(
+                        source.id.clone(),
+                        target.id.clone(),
+                        edge.ref_line,
+                        edge.ident.clone(),
+                    ));
+                }
+            }
+        }
+        inbound
+    }
+
+    /// Re-add inbound references after their targets were reparsed.
+    ///
+    /// References whose target no longer exists are re-resolved by name, so a
+    /// renamed or moved definition is still found; returns how many were kept.
+    fn restore_inbound_references(
+        graph: &mut PetCodeGraph,
+        defines: &HashMap<String, String>,
+        inbound: Vec<Edge>,
+    ) -> usize {
+        let mut restored = 0;
+        for mut edge in inbound {
+            if !graph.contains_node(&edge.target) {
+                match edge.ident.as_ref().and_then(|name| defines.get(name)) {
+                    Some(target) if *target != edge.source => edge.target = target.clone(),
+                    _ => continue,
+                }
+            }
+            if graph.add_edge_from_struct(&edge).is_some() {
+                restored += 1;
+            }
+        }
+        restored
+    }
+
     /// Remove nodes for deleted files.
     fn process_deleted_files(&mut self, deleted_files: &[String]) {
         info!("Processing {} deleted files...", deleted_files.len());
@@ -402,8 +791,13 @@ impl IncrementalUpdater {
         }
     }
 
-    /// Reparse modified and added files.
-    fn reparse_files(&mut self, file_paths: &[String]) -> Result<()> {
+    /// Reparse modified and added files from `source_root`, returning their
+    /// references.
+    fn reparse_files(
+        &mut self,
+        file_paths: &[String],
+        source_root: &Path,
+    ) -> Result<Vec<FileReferences>> {
         info!("Reparsing {} files...", file_paths.len());
 
         // Create a builder for parsing - use embedded queries or custom directory
@@ -411,22 +805,23 @@ impl IncrementalUpdater {
             Some(dir) => GraphBuilder::with_config(dir, self.builder_config.clone())?,
             None => GraphBuilder::with_embedded_queries(self.builder_config.clone()),
         };
+        builder.check_query_files()?;
 
         // Collect file graphs first to avoid borrow issues
         let mut file_graphs = Vec::new();
 
         for rel_path in file_paths {
-            let abs_path = self.repo_path.join(rel_path);
+            let abs_path = source_root.join(rel_path);
 
             if !abs_path.exists() {
                 warn!("File not found during reparse: {}", rel_path);
                 continue;
             }
 
-            // Parse single file with full entity extraction (returns PetCodeGraph directly)
-            match builder.parse_file(&abs_path, rel_path) {
-                Ok(file_graph) => {
-                    file_graphs.push((rel_path.clone(), file_graph));
+            // Parse single file with full entity extraction
+            match builder.parse_file_with_references(&abs_path, rel_path) {
+                Ok((file_graph, references)) => {
+                    file_graphs.push((rel_path.clone(), file_graph, references));
                 }
                 Err(e) => {
                     warn!("Error reparsing {}: {}", rel_path, e);
@@ -440,12 +835,25 @@ impl IncrementalUpdater {
             .as_mut()
             .expect("Graph must be loaded before processing changes");
 
-        for (rel_path, file_graph) in file_graphs {
+        // Single-file parses have no repository context to attach files to
+        let repo_id = graph
+            .iter_nodes()
+            .find(|n| n.is_repository())
+            .map(|n| n.id.clone());
+
+        let mut references = Vec::with_capacity(file_graphs.len());
+        for (rel_path, file_graph, file_references) in file_graphs {
             Self::merge_file_graph(graph, file_graph);
+            if let Some(ref repo_id) = repo_id {
+                if graph.contains_node(&rel_path) {
+                    graph.add_edge_from_struct(&Edge::contains(repo_id.clone(), rel_path.clone()));
+                }
+            }
+            references.push(file_references);
             debug!("Reparsed file: {}", rel_path);
         }
 
-        Ok(())
+        Ok(references)
     }
 
     /// Merge a file's graph into the main graph.
@@ -464,7 +872,7 @@ impl IncrementalUpdater {
     }
 
     /// Save the updated graph to partitions.
-    fn save_graph(&self) -> Result<()> {
+    fn save_graph(&self, lock: &WriteLock) -> Result<()> {
         let graph = self.graph.as_ref().expect("Graph must exist to save");
 
         info!("Saving graph to {:?}", self.prism_dir);
@@ -478,7 +886,7 @@ impl IncrementalUpdater {
 
         // Partition and save to prism directory
         let (_, stats) =
-            GraphPartitioner::partition_with_stats(graph, &self.prism_dir, Some(&root_name))?;
+            GraphPartitioner::partition_locked(graph, &self.prism_dir, Some(&root_name), lock)?;
 
         info!(
             "Saved graph: {} nodes, {} partitions, {} cross-partition edges",
@@ -489,7 +897,7 @@ impl IncrementalUpdater {
     }
 
     /// Perform a full rebuild of the repository.
-    fn full_rebuild(&mut self) -> Result<UpdateResult> {
+    fn full_rebuild(&mut self, lock: &WriteLock) -> Result<UpdateResult> {
         info!("Performing full rebuild...");
 
         // Build Merkle tree
@@ -522,8 +930,11 @@ impl IncrementalUpdater {
         self.graph = Some(graph);
         self.current_merkle_tree = merkle_tree.clone();
 
+        // Keep earlier symbol IDs resolvable
+        self.record_aliases(Vec::new(), lock)?;
+
         // Save graph
-        self.save_graph()?;
+        self.save_graph(lock)?;
 
         // Return result indicating full rebuild
         let changes = ChangeSet {
@@ -718,4 +1129,154 @@ mod tests {
 
         assert!(result_full_rebuild.has_changes());
     }
+
+    fn has_uses(graph: &PetCodeGraph, source: &str, target: &str) -> bool {
+        graph
+            .outgoing_edges(source)
+            .any(|(node, edge)| node.id == target && edge.edge_type == EdgeType::Uses)
+    }
+
+    /// A repository where `b.py:main` calls `a.py:helper`, fully built
+    fn setup_built_repo() -> (TempDir, PathBuf, IncrementalUpdater) {
+        let temp_dir = TempDir::new().unwrap();
+        let repo_path = temp_dir.path().to_path_buf();
+        std::fs::write(repo_path.join("a.py"), "def helper():\n    return 1\n").unwrap();
+        std::fs::write(
+            repo_path.join("b.py"),
+            "from a import helper\n\ndef main():\n    helper()\n",
+        )
+        .unwrap();
+
+        let prism_dir = repo_path.join(".codeprysm");
+        let mut updater =
+            IncrementalUpdater::new_with_embedded_queries(&repo_path, &prism_dir).unwrap();
+        updater.update_repository(true).unwrap();
+        assert!(has_uses(
+            updater.graph().unwrap(),
+            "b.py:main",
+            "a.py:helper"
+        ));
+
+        (temp_dir, repo_path, updater)
+    }
+
+    #[test]
+    fn test_apply_file_changes_keeps_inbound_references() {
+        let (_temp_dir, repo_path, mut updater) = setup_built_repo();
+
+        std::fs::write(
+            repo_path.join("a.py"),
+            "# helpers\n\ndef helper():\n    return 2\n",
+        )
+        .unwrap();
+        let result = updater.apply_file_changes(&["a.py".to_string()]).unwrap();
+
+        assert_eq!(result.changes.modified, vec!["a.py"]);
+        let graph = updater.graph().unwrap();
+        assert_eq!(graph.get_node("a.py:helper").unwrap().line, 3);
+        assert!(has_uses(graph, "b.py:main", "a.py:helper"));
+    }
+
+    #[test]
+    fn test_apply_file_changes_resolves_new_references() {
+        let (_temp_dir, repo_path, mut updater) = setup_built_repo();
+
+        std::fs::write(
+            repo_path.join("c.py"),
+            "from a import helper\n\ndef run():\n    helper()\n",
+        )
+        .unwrap();
+        let result = updater.apply_file_changes(&["c.py".to_string()]).unwrap();
+
+        assert_eq!(result.changes.added, vec!["c.py"]);
+        assert!(has_uses(
+            updater.graph().unwrap(),
+            "c.py:run",
+            "a.py:helper"
+        ));
+
+        std::fs::remove_file(repo_path.join("a.py")).unwrap();
+        let result = updater.apply_file_changes(&["a.py".to_string()]).unwrap();
+
+        assert_eq!(result.changes.deleted, vec!["a.py"]);
+        let graph = updater.graph().unwrap();
+        assert!(!graph.contains_node("a.py:helper"));
+        assert!(graph.contains_node("c.py:run"));
+    }
+
+    #[test]
+    fn test_concurrent_updates_keep_both_changes() {
+        let (_temp_dir, repo_path, _updater) = setup_built_repo();
+        let prism_dir = repo_path.join(".codeprysm");
+
+        // Each updater loads the index, adds its file, and saves; without
+        // the lock across the whole update one save would drop the other file
+        let barrier = std::sync::Barrier::new(2);
+        std::thread::scope(|scope| {
+            for (file, source) in [
+                ("c.py", "def first():\n    return 1\n"),
+                ("d.py", "def second():\n    return 2\n"),
+            ] {
+                let (repo_path, prism_dir, barrier) = (&repo_path, &prism_dir, &barrier);
+                scope.spawn(move || {
+                    std::fs::write(repo_path.join(file), source).unwrap();
+                    let mut updater =
+                        IncrementalUpdater::new_with_embedded_queries(repo_path, prism_dir)
+                            .unwrap();
+                    barrier.wait();
+                    updater.apply_file_changes(&[file.to_string()]).unwrap();
+                });
+            }
+        });
+
+        let mut updater =
+            IncrementalUpdater::new_with_embedded_queries(&repo_path, &prism_dir).unwrap();
+        assert!(updater.load_graph_state().unwrap());
+        let graph = updater.graph().unwrap();
+        assert!(graph.contains_node("c.py:first"));
+        assert!(graph.contains_node("d.py:second"));
+        assert!(has_uses(graph, "b.py:main", "a.py:helper"));
+    }
+
+    #[test]
+    fn test_apply_file_changes_skips_unchanged_and_ignored() {
+        let (_temp_dir, repo_path, mut updater) = setup_built_repo();
+
+        std::fs::write(repo_path.join("notes.txt"), "not code").unwrap();
+        std::fs::write(repo_path.join(".gitignore"), "gen/\n").unwrap();
+        std::fs::create_dir(repo_path.join("gen")).unwrap();
+        std::fs::write(repo_path.join("gen/out.py"), "def generated():\n    pass\n").unwrap();
+
+        let paths = ["a.py", "notes.txt", "gen/out.py"].map(String::from);
+        let result = updater.apply_file_changes(&paths).unwrap();
+
+        assert!(!result.has_changes());
+        assert!(!updater.graph().unwrap().contains_node("gen/out.py"));
+    }
+
+    #[test]
+    fn test_preview_changes_leaves_index_untouched() {
+        let (_temp_dir, _repo_path, mut updater) = setup_built_repo();
+
+        let staged = TempDir::new().unwrap();
+        std::fs::write(
+            staged.path().join("b.py"),
+            "from a import helper\n\ndef main():\n    pass\n",
+        )
+        .unwrap();
+        let changes = ChangeSet {
+            modified: vec!["b.py".to_string()],
+            added: Vec::new(),
+            deleted: vec!["missing.py".to_string()],
+        };
+        let preview = updater.preview_changes(staged.path(), &changes).unwrap();
+
+        assert!(preview.contains_node("b.py:main"));
+        assert!(!has_uses(&preview, "b.py:main", "a.py:helper"));
+        assert!(has_uses(
+            updater.graph().unwrap(),
+            "b.py:main",
+            "a.py:helper"
+        ));
+    }
 }
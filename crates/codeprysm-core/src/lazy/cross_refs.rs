@@ -16,6 +16,7 @@
 //! ```
 
 use crate::graph::EdgeType;
+use crate::lazy::lock::BUSY_TIMEOUT;
 use rusqlite::{params, Connection, Result as SqliteResult};
 use std::collections::HashMap;
 use std::path::Path;
@@ -23,7 +24,9 @@ use thiserror::Error;
 
 /// Schema version for cross_refs.db
 /// v1.1 adds version_spec and is_dev_dependency columns for DEPENDS_ON edges
-pub const CROSS_REFS_SCHEMA_VERSION: &str = "1.1";
+/// v1.2 adds the similarity column for CLONE_OF edges
+/// v1.3 adds the weight column for type usage weights
+pub const CROSS_REFS_SCHEMA_VERSION: &str = "1.3";
 
 /// SQL to create the cross_refs table
 const SCHEMA_CREATE_CROSS_REFS: &str = r#"
@@ -48,6 +51,12 @@ CREATE TABLE IF NOT EXISTS cross_refs (
     version_spec TEXT,
     is_dev_dependency INTEGER,
 
+    -- CloneOf edge metadata (v1.2)
+    similarity INTEGER,
+
+    -- Type usage weight (v1.3)
+    weight INTEGER,
+
     -- Ensure no duplicate cross-refs
     UNIQUE(source_id, target_id, edge_type, ref_line)
 )
@@ -108,6 +117,10 @@ pub struct CrossRef {
     pub version_spec: Option<String>,
     /// Whether this is a development dependency (for DEPENDS_ON edges)
     pub is_dev_dependency: Option<bool>,
+    /// Similarity percentage (for CLONE_OF edges)
+    pub similarity: Option<u32>,
+    /// Number of type uses (for USES edges to types and DEPENDS_ON edges)
+    pub weight: Option<u32>,
 }
 
 impl CrossRef {
@@ -131,6 +144,8 @@ impl CrossRef {
             ident,
             version_spec: None,
             is_dev_dependency: None,
+            similarity: None,
+            weight: None,
         }
     }
 
@@ -154,6 +169,8 @@ impl CrossRef {
             ident,
             version_spec,
             is_dev_dependency,
+            similarity: None,
+            weight: None,
         }
     }
 }
@@ -304,13 +321,27 @@ impl CrossRefStore {
 
         let store = Self { conn };
 
-        // Verify schema version
+        // Verify schema version, migrating v1.1 and v1.2 stores in place
         if let Some(version) = store.get_metadata("schema_version")? {
-            if version != CROSS_REFS_SCHEMA_VERSION {
-                return Err(CrossRefError::SchemaVersionMismatch {
-                    expected: CROSS_REFS_SCHEMA_VERSION.to_string(),
-                    found: version,
-                });
+            match version.as_str() {
+                v if v == CROSS_REFS_SCHEMA_VERSION => {}
+                "1.1" | "1.2" => {
+                    if version == "1.1" {
+                        store
+                            .conn
+                            .execute("ALTER TABLE cross_refs ADD COLUMN similarity INTEGER", [])?;
+                    }
+                    store
+                        .conn
+                        .execute("ALTER TABLE cross_refs ADD COLUMN weight INTEGER", [])?;
+                    store.set_metadata("schema_version", CROSS_REFS_SCHEMA_VERSION)?;
+                }
+                _ => {
+                    return Err(CrossRefError::SchemaVersionMismatch {
+                        expected: CROSS_REFS_SCHEMA_VERSION.to_string(),
+                        found: version,
+                    });
+                }
             }
         }
 
@@ -359,6 +390,7 @@ impl CrossRefStore {
 
     /// Configure connection with optimal settings
     fn configure_connection(conn: &Connection) -> SqliteResult<()> {
+        conn.busy_timeout(BUSY_TIMEOUT)?;
         conn.pragma_update(None, "journal_mode", "WAL")?;
         conn.pragma_update(None, "foreign_keys", "ON")?;
         conn.pragma_update(None, "cache_size", -16000)?; // 16MB cache (smaller than partitions)
@@ -394,7 +426,7 @@ impl CrossRefStore {
         let mut index = CrossRefIndex::new();
 
         let mut stmt = self.conn.prepare(
-            "SELECT source_id, source_partition, target_id, target_partition, edge_type, ref_line, ident, version_spec, is_dev_dependency FROM cross_refs",
+            "SELECT source_id, source_partition, target_id, target_partition, edge_type, ref_line, ident, version_spec, is_dev_dependency, similarity, weight FROM cross_refs",
         )?;
 
         let rows = stmt.query_map([], |row| {
@@ -404,6 +436,12 @@ impl CrossRefStore {
                 "USES" => EdgeType::Uses,
                 "DEFINES" => EdgeType::Defines,
                 "DEPENDS_ON" => EdgeType::DependsOn,
+                "CLONE_OF" => EdgeType::CloneOf,
+                "DEFINED_IN" => EdgeType::DefinedIn,
+                "CROSS_LANGUAGE" => EdgeType::CrossLanguage,
+                "REFERS_BY_NAME" => EdgeType::RefersByName,
+                "EQUIVALENT_TO" => EdgeType::EquivalentTo,
+                "SUBSET_OF" => EdgeType::SubsetOf,
                 _ => EdgeType::Uses, // Default fallback
             };
 
@@ -417,6 +455,8 @@ impl CrossRefStore {
                 ident: row.get(6)?,
                 version_spec: row.get(7)?,
                 is_dev_dependency: row.get::<_, Option<i64>>(8)?.map(|v| v != 0),
+                similarity: row.get(9)?,
+                weight: row.get(10)?,
             })
         })?;
 
@@ -436,7 +476,7 @@ impl CrossRefStore {
 
         // Insert all cross-refs
         let mut stmt = tx.prepare(
-            "INSERT INTO cross_refs (source_id, source_partition, target_id, target_partition, edge_type, ref_line, ident, version_spec, is_dev_dependency) VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9)",
+            "INSERT INTO cross_refs (source_id, source_partition, target_id, target_partition, edge_type, ref_line, ident, version_spec, is_dev_dependency, similarity, weight) VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11)",
         )?;
 
         for cross_ref in index.iter() {
@@ -452,6 +492,8 @@ impl CrossRefStore {
                 cross_ref
                     .is_dev_dependency
                     .map(|b| if b { 1i64 } else { 0i64 }),
+                cross_ref.similarity,
+                cross_ref.weight,
             ])?;
         }
 
@@ -466,7 +508,7 @@ impl CrossRefStore {
         let tx = self.conn.unchecked_transaction()?;
 
         let mut stmt = tx.prepare(
-            "INSERT OR IGNORE INTO cross_refs (source_id, source_partition, target_id, target_partition, edge_type, ref_line, ident, version_spec, is_dev_dependency) VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9)",
+            "INSERT OR IGNORE INTO cross_refs (source_id, source_partition, target_id, target_partition, edge_type, ref_line, ident, version_spec, is_dev_dependency, similarity, weight) VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11)",
         )?;
 
         for cross_ref in refs {
@@ -482,6 +524,8 @@ impl CrossRefStore {
                 cross_ref
                     .is_dev_dependency
                     .map(|b| if b { 1i64 } else { 0i64 }),
+                cross_ref.similarity,
+                cross_ref.weight,
             ])?;
         }
 
@@ -0,0 +1,772 @@
+//! Graph History
+//!
+//! Keeps timestamped snapshots of a graph's health measures in an SQLite
+//! database next to the partitions, so engineering-health dashboards can
+//! chart how a codebase evolves: package coupling, API surface growth, and
+//! dead code over time. A snapshot records the measures of the whole graph
+//! and of every package, not the graph itself.
+//!
+//! # Storage
+//!
+//! ```text
+//! history.db
+//! ├── snapshots (time, commit, key, graph-wide totals)
+//! ├── snapshot_packages (per-package coupling, API, and dead code)
+//! └── history_metadata (schema version)
+//! ```
+//!
+//! Snapshots are kept per commit or per day: recording a snapshot replaces
+//! the one with the same commit (or taken the same day). Unlike the other
+//! stores, history cannot be rebuilt from the source, so a database written
+//! by another schema version is refused rather than rebuilt.
+
+use std::collections::BTreeMap;
+use std::fmt;
+use std::path::{Path, PathBuf};
+use std::str::FromStr;
+
+use rusqlite::{params, Connection, OptionalExtension, Result as SqliteResult};
+use serde::{Deserialize, Serialize};
+use thiserror::Error;
+
+use crate::analysis::{api_surface, find_dead_code, package_metrics, package_of, DeadCodeOptions};
+use crate::graph::PetCodeGraph;
+use crate::lazy::lock::BUSY_TIMEOUT;
+
+/// Schema version for history.db
+pub const HISTORY_SCHEMA_VERSION: &str = "1.0";
+
+/// File name of the history database in the .codeprysm directory
+pub const HISTORY_FILE: &str = "history.db";
+
+/// Seconds per day
+const DAY: i64 = 86_400;
+
+/// SQL to create the snapshot table
+const SCHEMA_CREATE_SNAPSHOTS: &str = r#"
+CREATE TABLE IF NOT EXISTS snapshots (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    -- Unix seconds
+    taken_at INTEGER NOT NULL,
+    commit_hash TEXT,
+    -- Commit or day the snapshot stands for; a new snapshot replaces it
+    key TEXT NOT NULL UNIQUE,
+    nodes INTEGER NOT NULL,
+    edges INTEGER NOT NULL,
+    files INTEGER NOT NULL,
+    api_symbols INTEGER NOT NULL,
+    dead_symbols INTEGER NOT NULL
+)
+"#;
+
+/// SQL to create the per-package measures table
+const SCHEMA_CREATE_PACKAGES: &str = r#"
+CREATE TABLE IF NOT EXISTS snapshot_packages (
+    snapshot_id INTEGER NOT NULL REFERENCES snapshots(id) ON DELETE CASCADE,
+    package TEXT NOT NULL,
+    afferent INTEGER NOT NULL,
+    efferent INTEGER NOT NULL,
+    instability REAL NOT NULL,
+    distance REAL NOT NULL,
+    api_symbols INTEGER NOT NULL,
+    dead_symbols INTEGER NOT NULL,
+    PRIMARY KEY (snapshot_id, package)
+)
+"#;
+
+/// SQL to index snapshots by time
+const SCHEMA_CREATE_INDEXES: &str =
+    "CREATE INDEX IF NOT EXISTS idx_snapshots_taken_at ON snapshots(taken_at)";
+
+/// SQL to create the metadata table
+const SCHEMA_CREATE_METADATA: &str = r#"
+CREATE TABLE IF NOT EXISTS history_metadata (
+    key TEXT PRIMARY KEY NOT NULL,
+    value TEXT NOT NULL
+)
+"#;
+
+/// Errors that can occur during history operations
+#[derive(Debug, Error)]
+pub enum HistoryError {
+    #[error("SQLite error: {0}")]
+    Sqlite(#[from] rusqlite::Error),
+
+    #[error("IO error: {0}")]
+    IoError(#[from] std::io::Error),
+
+    #[error("History has schema version {found}, unknown to this version (expected {expected})")]
+    UnsupportedVersion { found: String, expected: String },
+
+    #[error("Snapshot has no commit to be kept per commit")]
+    MissingCommit,
+}
+
+/// Measures of one package in a snapshot.
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+pub struct PackageSnapshot {
+    /// Package path (containing directory)
+    pub package: String,
+    /// Packages depending on this one
+    pub afferent: usize,
+    /// Packages this one depends on
+    pub efferent: usize,
+    /// Instability: Ce / (Ca + Ce)
+    pub instability: f64,
+    /// Distance from the main sequence
+    pub distance: f64,
+    /// Exported symbols
+    pub api_symbols: usize,
+    /// Symbols unreachable from any root
+    pub dead_symbols: usize,
+}
+
+/// Health measures of a graph at one point in time.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct GraphSnapshot {
+    /// When the snapshot was taken (Unix seconds)
+    pub taken_at: i64,
+    /// Indexed commit, if known
+    pub commit: Option<String>,
+    /// Nodes in the graph
+    pub nodes: usize,
+    /// Edges in the graph
+    pub edges: usize,
+    /// Source files in the graph
+    pub files: usize,
+    /// Exported symbols
+    pub api_symbols: usize,
+    /// Symbols unreachable from any root
+    pub dead_symbols: usize,
+    /// Per-package measures, sorted by package
+    pub packages: Vec<PackageSnapshot>,
+}
+
+impl GraphSnapshot {
+    /// Measure `graph`, whose source is read from `repo_root`.
+    ///
+    /// Dead code is found from all root kinds, honoring suppression
+    /// comments, as `analyze dead-code` does by default.
+    pub fn capture(
+        graph: &PetCodeGraph,
+        repo_root: &Path,
+        taken_at: i64,
+        commit: Option<String>,
+    ) -> Self {
+        let mut packages: BTreeMap<String, PackageSnapshot> = BTreeMap::new();
+        for metrics in package_metrics(graph) {
+            packages.insert(
+                metrics.package.clone(),
+                PackageSnapshot {
+                    package: metrics.package,
+                    afferent: metrics.afferent,
+                    efferent: metrics.efferent,
+                    instability: metrics.instability,
+                    distance: metrics.distance,
+                    ..Default::default()
+                },
+            );
+        }
+        let api = api_surface(graph, repo_root);
+        for symbol in &api {
+            package_entry(&mut packages, &symbol.package).api_symbols += 1;
+        }
+        let mut dead = find_dead_code(graph, &DeadCodeOptions::default());
+        dead.apply_suppressions(repo_root);
+        for symbol in &dead.dead {
+            package_entry(&mut packages, package_of(&symbol.file)).dead_symbols += 1;
+        }
+
+        Self {
+            taken_at,
+            commit,
+            nodes: graph.node_count(),
+            edges: graph.edge_count(),
+            files: graph.iter_nodes().filter(|n| n.is_file()).count(),
+            api_symbols: api.len(),
+            dead_symbols: dead.dead.len(),
+            packages: packages.into_values().collect(),
+        }
+    }
+}
+
+/// Measures of a package, added empty if missing
+fn package_entry<'a>(
+    packages: &'a mut BTreeMap<String, PackageSnapshot>,
+    package: &str,
+) -> &'a mut PackageSnapshot {
+    packages
+        .entry(package.to_string())
+        .or_insert_with(|| PackageSnapshot {
+            package: package.to_string(),
+            ..Default::default()
+        })
+}
+
+/// How often snapshots are kept.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum SnapshotGranularity {
+    /// One snapshot per commit
+    Commit,
+    /// One snapshot per day (UTC)
+    Day,
+}
+
+impl SnapshotGranularity {
+    /// Key of the snapshot a new one replaces
+    fn key(&self, snapshot: &GraphSnapshot) -> Result<String, HistoryError> {
+        match self {
+            Self::Commit => snapshot
+                .commit
+                .as_ref()
+                .map(|commit| format!("commit:{}", commit))
+                .ok_or(HistoryError::MissingCommit),
+            Self::Day => Ok(format!(
+                "day:{}",
+                civil_date(snapshot.taken_at.div_euclid(DAY))
+            )),
+        }
+    }
+}
+
+/// A stored snapshot, without its packages.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct SnapshotSummary {
+    /// Snapshot ID
+    pub id: i64,
+    /// When the snapshot was taken (Unix seconds)
+    pub taken_at: i64,
+    /// Indexed commit, if known
+    pub commit: Option<String>,
+    /// Nodes in the graph
+    pub nodes: usize,
+    /// Edges in the graph
+    pub edges: usize,
+    /// Source files in the graph
+    pub files: usize,
+    /// Exported symbols
+    pub api_symbols: usize,
+    /// Symbols unreachable from any root
+    pub dead_symbols: usize,
+}
+
+/// Measure charted by a trend query.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash, Serialize, Deserialize)]
+#[serde(rename_all = "kebab-case")]
+pub enum TrendMetric {
+    /// Nodes in the graph
+    Nodes,
+    /// Edges in the graph
+    Edges,
+    /// Source files
+    Files,
+    /// Exported symbols
+    ApiSurface,
+    /// Symbols unreachable from any root
+    DeadCode,
+    /// Package dependencies (efferent coupling summed over packages)
+    Coupling,
+    /// Mean package instability
+    Instability,
+    /// Mean package distance from the main sequence
+    Distance,
+}
+
+impl TrendMetric {
+    /// All trend metrics
+    pub const ALL: [TrendMetric; 8] = [
+        TrendMetric::Nodes,
+        TrendMetric::Edges,
+        TrendMetric::Files,
+        TrendMetric::ApiSurface,
+        TrendMetric::DeadCode,
+        TrendMetric::Coupling,
+        TrendMetric::Instability,
+        TrendMetric::Distance,
+    ];
+
+    /// Name of the metric
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            Self::Nodes => "nodes",
+            Self::Edges => "edges",
+            Self::Files => "files",
+            Self::ApiSurface => "api-surface",
+            Self::DeadCode => "dead-code",
+            Self::Coupling => "coupling",
+            Self::Instability => "instability",
+            Self::Distance => "distance",
+        }
+    }
+
+    /// Check whether the metric can be limited to packages
+    pub fn is_per_package(&self) -> bool {
+        !matches!(self, Self::Nodes | Self::Edges | Self::Files)
+    }
+
+    /// SQL expression computing the metric for snapshot `s`; `?1` is the
+    /// package prefix (NULL for all packages)
+    fn sql(&self) -> String {
+        let aggregate = match self {
+            Self::Nodes => return "s.nodes".to_string(),
+            Self::Edges => return "s.edges".to_string(),
+            Self::Files => return "s.files".to_string(),
+            Self::ApiSurface => "total(p.api_symbols)",
+            Self::DeadCode => "total(p.dead_symbols)",
+            Self::Coupling => "total(p.efferent)",
+            Self::Instability => "coalesce(avg(p.instability), 0)",
+            Self::Distance => "coalesce(avg(p.distance), 0)",
+        };
+        format!(
+            "(SELECT {} FROM snapshot_packages p WHERE p.snapshot_id = s.id \
+             AND (?1 IS NULL OR substr(p.package, 1, length(?1)) = ?1))",
+            aggregate
+        )
+    }
+}
+
+impl fmt::Display for TrendMetric {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        f.pad(self.as_str())
+    }
+}
+
+impl FromStr for TrendMetric {
+    type Err = String;
+
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        Self::ALL
+            .into_iter()
+            .find(|metric| metric.as_str().eq_ignore_ascii_case(s))
+            .ok_or_else(|| {
+                let names: Vec<&str> = Self::ALL.iter().map(TrendMetric::as_str).collect();
+                format!(
+                    "Unknown trend metric '{}': expected {}",
+                    s,
+                    names.join(", ")
+                )
+            })
+    }
+}
+
+/// Period a trend query reports one value per.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum TrendPeriod {
+    /// Every snapshot
+    Snapshot,
+    /// The last snapshot of each day (UTC)
+    Day,
+    /// The last snapshot of each week (starting Monday, UTC)
+    Week,
+}
+
+/// One value of a trend.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct TrendPoint {
+    /// Date of the period (the Monday for weeks), as YYYY-MM-DD
+    pub period: String,
+    /// When the snapshot was taken (Unix seconds)
+    pub taken_at: i64,
+    /// Indexed commit of the snapshot, if known
+    pub commit: Option<String>,
+    /// Value of the metric
+    pub value: f64,
+}
+
+/// SQLite store of graph snapshots.
+pub struct History {
+    conn: Connection,
+}
+
+impl History {
+    /// Path of the history database in a .codeprysm directory
+    pub fn path(prism_dir: &Path) -> PathBuf {
+        prism_dir.join(HISTORY_FILE)
+    }
+
+    /// Check whether a .codeprysm directory has a history database
+    pub fn exists(prism_dir: &Path) -> bool {
+        Self::path(prism_dir).exists()
+    }
+
+    /// Open the history of a .codeprysm directory, creating it if missing.
+    pub fn open(prism_dir: &Path) -> Result<Self, HistoryError> {
+        std::fs::create_dir_all(prism_dir)?;
+        let conn = Connection::open(Self::path(prism_dir))?;
+        Self::configure_connection(&conn)?;
+        Self::with_connection(conn)
+    }
+
+    /// Create an in-memory history (for testing)
+    pub fn in_memory() -> Result<Self, HistoryError> {
+        let conn = Connection::open_in_memory()?;
+        conn.pragma_update(None, "foreign_keys", "ON")?;
+        Self::with_connection(conn)
+    }
+
+    /// Create or check the schema on a new connection
+    fn with_connection(conn: Connection) -> Result<Self, HistoryError> {
+        conn.execute(SCHEMA_CREATE_METADATA, [])?;
+        let history = Self { conn };
+        match history.get_metadata("schema_version")? {
+            Some(version) if version == HISTORY_SCHEMA_VERSION => {}
+            Some(found) => {
+                return Err(HistoryError::UnsupportedVersion {
+                    found,
+                    expected: HISTORY_SCHEMA_VERSION.to_string(),
+                })
+            }
+            None => {
+                history.conn.execute(SCHEMA_CREATE_SNAPSHOTS, [])?;
+                history.conn.execute(SCHEMA_CREATE_PACKAGES, [])?;
+                history.conn.execute(SCHEMA_CREATE_INDEXES, [])?;
+                history.set_metadata("schema_version", HISTORY_SCHEMA_VERSION)?;
+            }
+        }
+        Ok(history)
+    }
+
+    /// Configure connection with optimal settings
+    fn configure_connection(conn: &Connection) -> SqliteResult<()> {
+        conn.busy_timeout(BUSY_TIMEOUT)?;
+        conn.pragma_update(None, "journal_mode", "WAL")?;
+        conn.pragma_update(None, "synchronous", "NORMAL")?;
+        conn.pragma_update(None, "foreign_keys", "ON")?;
+        Ok(())
+    }
+
+    /// Get a metadata value
+    fn get_metadata(&self, key: &str) -> Result<Option<String>, HistoryError> {
+        let result = self
+            .conn
+            .query_row(
+                "SELECT value FROM history_metadata WHERE key = ?1",
+                [key],
+                |row| row.get(0),
+            )
+            .optional()?;
+        Ok(result)
+    }
+
+    /// Set a metadata value
+    fn set_metadata(&self, key: &str, value: &str) -> Result<(), HistoryError> {
+        self.conn.execute(
+            "INSERT OR REPLACE INTO history_metadata (key, value) VALUES (?1, ?2)",
+            params![key, value],
+        )?;
+        Ok(())
+    }
+
+    /// Store a snapshot, replacing the one kept for the same commit or day.
+    ///
+    /// Returns the ID of the new snapshot.
+    pub fn record(
+        &mut self,
+        snapshot: &GraphSnapshot,
+        granularity: SnapshotGranularity,
+    ) -> Result<i64, HistoryError> {
+        let key = granularity.key(snapshot)?;
+        let tx = self.conn.transaction()?;
+        tx.execute("DELETE FROM snapshots WHERE key = ?1", [&key])?;
+        tx.execute(
+            "INSERT INTO snapshots (taken_at, commit_hash, key, nodes, edges, files, api_symbols, dead_symbols) \
+             VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8)",
+            params![
+                snapshot.taken_at,
+                snapshot.commit,
+                key,
+                snapshot.nodes as i64,
+                snapshot.edges as i64,
+                snapshot.files as i64,
+                snapshot.api_symbols as i64,
+                snapshot.dead_symbols as i64,
+            ],
+        )?;
+        let id = tx.last_insert_rowid();
+        {
+            let mut insert = tx.prepare(
+                "INSERT INTO snapshot_packages (snapshot_id, package, afferent, efferent, instability, distance, api_symbols, dead_symbols) \
+                 VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8)",
+            )?;
+            for package in &snapshot.packages {
+                insert.execute(params![
+                    id,
+                    package.package,
+                    package.afferent as i64,
+                    package.efferent as i64,
+                    package.instability,
+                    package.distance,
+                    package.api_symbols as i64,
+                    package.dead_symbols as i64,
+                ])?;
+            }
+        }
+        tx.commit()?;
+        Ok(id)
+    }
+
+    /// All snapshots, oldest first
+    pub fn snapshots(&self) -> Result<Vec<SnapshotSummary>, HistoryError> {
+        let mut stmt = self.conn.prepare(
+            "SELECT id, taken_at, commit_hash, nodes, edges, files, api_symbols, dead_symbols \
+             FROM snapshots ORDER BY taken_at, id",
+        )?;
+        let rows = stmt.query_map([], |row| {
+            let count = |i: usize| row.get::<_, i64>(i).map(|n| n as usize);
+            Ok(SnapshotSummary {
+                id: row.get(0)?,
+                taken_at: row.get(1)?,
+                commit: row.get(2)?,
+                nodes: count(3)?,
+                edges: count(4)?,
+                files: count(5)?,
+                api_symbols: count(6)?,
+                dead_symbols: count(7)?,
+            })
+        })?;
+        Ok(rows.collect::<SqliteResult<_>>()?)
+    }
+
+    /// Per-package measures of a snapshot, sorted by package
+    pub fn packages(&self, snapshot_id: i64) -> Result<Vec<PackageSnapshot>, HistoryError> {
+        let mut stmt = self.conn.prepare(
+            "SELECT package, afferent, efferent, instability, distance, api_symbols, dead_symbols \
+             FROM snapshot_packages WHERE snapshot_id = ?1 ORDER BY package",
+        )?;
+        let rows = stmt.query_map([snapshot_id], |row| {
+            let count = |i: usize| row.get::<_, i64>(i).map(|n| n as usize);
+            Ok(PackageSnapshot {
+                package: row.get(0)?,
+                afferent: count(1)?,
+                efferent: count(2)?,
+                instability: row.get(3)?,
+                distance: row.get(4)?,
+                api_symbols: count(5)?,
+                dead_symbols: count(6)?,
+            })
+        })?;
+        Ok(rows.collect::<SqliteResult<_>>()?)
+    }
+
+    /// Chart `metric` over time, oldest first, taking the last snapshot of
+    /// each period.
+    ///
+    /// `package` limits package measures to the packages under a path
+    /// prefix; graph-wide measures (nodes, edges, files) ignore it.
+    /// `since` drops snapshots taken before a time (Unix seconds).
+    pub fn trend(
+        &self,
+        metric: TrendMetric,
+        period: TrendPeriod,
+        package: Option<&str>,
+        since: Option<i64>,
+    ) -> Result<Vec<TrendPoint>, HistoryError> {
+        let sql = format!(
+            "SELECT s.taken_at, s.commit_hash, {} FROM snapshots s \
+             WHERE s.taken_at >= ?2 ORDER BY s.taken_at, s.id",
+            metric.sql()
+        );
+        let mut stmt = self.conn.prepare(&sql)?;
+        let rows = stmt.query_map(params![package, since.unwrap_or(i64::MIN)], |row| {
+            Ok((
+                row.get::<_, i64>(0)?,
+                row.get::<_, Option<String>>(1)?,
+                row.get::<_, f64>(2)?,
+            ))
+        })?;
+
+        let mut points: Vec<(i64, TrendPoint)> = Vec::new();
+        for row in rows {
+            let (taken_at, commit, value) = row?;
+            let day = taken_at.div_euclid(DAY);
+            let start = match period {
+                TrendPeriod::Snapshot | TrendPeriod::Day => day,
+                // 1970-01-01 was a Thursday
+                TrendPeriod::Week => day - (day + 3).rem_euclid(7),
+            };
+            let point = TrendPoint {
+                period: civil_date(start),
+                taken_at,
+                commit,
+                value,
+            };
+            match points.last_mut() {
+                Some((last, previous)) if period != TrendPeriod::Snapshot && *last == start => {
+                    *previous = point
+                }
+                _ => points.push((start, point)),
+            }
+        }
+        Ok(points.into_iter().map(|(_, point)| point).collect())
+    }
+
+    /// Delete snapshots taken before a time (Unix seconds), returning how
+    /// many were deleted
+    pub fn prune(&mut self, before: i64) -> Result<usize, HistoryError> {
+        Ok(self
+            .conn
+            .execute("DELETE FROM snapshots WHERE taken_at < ?1", [before])?)
+    }
+}
+
+/// Date (YYYY-MM-DD, proleptic Gregorian) of a day counted from 1970-01-01
+pub fn civil_date(days: i64) -> String {
+    // Howard Hinnant's civil_from_days
+    let z = days + 719_468;
+    let era = z.div_euclid(146_097);
+    let doe = z.rem_euclid(146_097);
+    let yoe = (doe - doe / 1460 + doe / 36_524 - doe / 146_096) / 365;
+    let doy = doe - (365 * yoe + yoe / 4 - yoe / 100);
+    let mp = (5 * doy + 2) / 153;
+    let day = doy - (153 * mp + 2) / 5 + 1;
+    let month = if mp < 10 { mp + 3 } else { mp - 9 };
+    let year = yoe + era * 400 + i64::from(month <= 2);
+    format!("{:04}-{:02}-{:02}", year, month, day)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    // 2024-03-04, a Monday
+    const MONDAY: i64 = 1_709_510_400;
+
+    fn snapshot(taken_at: i64, commit: &str, packages: &[(&str, usize, usize)]) -> GraphSnapshot {
+        GraphSnapshot {
+            taken_at,
+            commit: Some(commit.to_string()),
+            nodes: 100,
+            edges: 200,
+            files: 10,
+            api_symbols: packages.iter().map(|p| p.1).sum(),
+            dead_symbols: packages.iter().map(|p| p.2).sum(),
+            packages: packages
+                .iter()
+                .map(|&(package, api_symbols, dead_symbols)| PackageSnapshot {
+                    package: package.to_string(),
+                    efferent: 1,
+                    instability: 0.5,
+                    api_symbols,
+                    dead_symbols,
+                    ..Default::default()
+                })
+                .collect(),
+        }
+    }
+
+    #[test]
+    fn test_civil_date() {
+        assert_eq!(civil_date(0), "1970-01-01");
+        assert_eq!(civil_date(MONDAY / DAY), "2024-03-04");
+        assert_eq!(civil_date(-1), "1969-12-31");
+        assert_eq!(civil_date(11_016), "2000-02-29");
+    }
+
+    #[test]
+    fn test_record_replaces_same_key() {
+        let mut history = History::in_memory().unwrap();
+        let first = snapshot(MONDAY, "a1", &[("api", 3, 1)]);
+        history.record(&first, SnapshotGranularity::Commit).unwrap();
+        let again = snapshot(MONDAY + 60, "a1", &[("api", 4, 1)]);
+        let id = history.record(&again, SnapshotGranularity::Commit).unwrap();
+
+        let snapshots = history.snapshots().unwrap();
+        assert_eq!(snapshots.len(), 1);
+        assert_eq!(snapshots[0].api_symbols, 4);
+        assert_eq!(history.packages(id).unwrap()[0].package, "api");
+
+        // Per day, a later commit of the same day replaces it too
+        let later = snapshot(MONDAY + 3600, "b2", &[("api", 5, 0)]);
+        history.record(&later, SnapshotGranularity::Day).unwrap();
+        history.record(&later, SnapshotGranularity::Day).unwrap();
+        assert_eq!(history.snapshots().unwrap().len(), 2);
+
+        let mut uncommitted = later.clone();
+        uncommitted.commit = None;
+        assert!(matches!(
+            history.record(&uncommitted, SnapshotGranularity::Commit),
+            Err(HistoryError::MissingCommit)
+        ));
+    }
+
+    #[test]
+    fn test_trend() {
+        let mut history = History::in_memory().unwrap();
+        let days = [
+            (MONDAY, "a", 2),
+            (MONDAY + DAY, "b", 3),
+            (MONDAY + 7 * DAY, "c", 5),
+            (MONDAY + 7 * DAY + 10, "d", 4),
+        ];
+        for (taken_at, commit, dead) in days {
+            let snapshot = snapshot(taken_at, commit, &[("api", 2, dead), ("store", 1, 1)]);
+            history
+                .record(&snapshot, SnapshotGranularity::Commit)
+                .unwrap();
+        }
+
+        let weekly = history
+            .trend(TrendMetric::DeadCode, TrendPeriod::Week, None, None)
+            .unwrap();
+        let values: Vec<(&str, f64)> = weekly
+            .iter()
+            .map(|p| (p.period.as_str(), p.value))
+            .collect();
+        assert_eq!(values, vec![("2024-03-04", 4.0), ("2024-03-11", 5.0)]);
+        assert_eq!(weekly[1].commit.as_deref(), Some("d"));
+
+        let daily = history
+            .trend(TrendMetric::DeadCode, TrendPeriod::Day, Some("store"), None)
+            .unwrap();
+        assert_eq!(daily.len(), 3);
+        assert!(daily.iter().all(|p| p.value == 1.0));
+
+        let coupling = history
+            .trend(
+                TrendMetric::Coupling,
+                TrendPeriod::Snapshot,
+                None,
+                Some(MONDAY + DAY),
+            )
+            .unwrap();
+        assert_eq!(coupling.len(), 3);
+        assert_eq!(coupling[0].value, 2.0);
+
+        let api = history
+            .trend(
+                TrendMetric::ApiSurface,
+                TrendPeriod::Week,
+                Some("missing"),
+                None,
+            )
+            .unwrap();
+        assert!(api.iter().all(|p| p.value == 0.0));
+
+        assert_eq!(history.prune(MONDAY + 7 * DAY).unwrap(), 2);
+        assert_eq!(
+            "dead-code".parse::<TrendMetric>(),
+            Ok(TrendMetric::DeadCode)
+        );
+    }
+
+    #[test]
+    fn test_open_persists() {
+        let dir = tempfile::tempdir().unwrap();
+        assert!(!History::exists(dir.path()));
+        let mut history = History::open(dir.path()).unwrap();
+        history
+            .record(&snapshot(MONDAY, "a", &[]), SnapshotGranularity::Day)
+            .unwrap();
+        drop(history);
+        assert_eq!(
+            History::open(dir.path())
+                .unwrap()
+                .snapshots()
+                .unwrap()
+                .len(),
+            1
+        );
+    }
+}
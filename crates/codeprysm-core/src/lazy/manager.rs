@@ -4,7 +4,7 @@
 //! Provides transparent access to nodes and edges, loading partitions on-demand.
 
 use crate::discovery::{DiscoveredRoot, DiscoveryError, RootDiscovery, RootType};
-use crate::graph::{EdgeData, Node, PetCodeGraph};
+use crate::graph::{Edge, EdgeData, Node, PetCodeGraph};
 use crate::lazy::cache::{CacheMetrics, MemoryBudgetCache, PartitionStats as CachePartitionStats};
 use crate::lazy::cross_refs::{CrossRef, CrossRefError, CrossRefIndex, CrossRefStore};
 use crate::lazy::partition::{PartitionConnection, PartitionError};
@@ -100,6 +100,9 @@ impl RootInfo {
     }
 }
 
+/// Schema version of manifest.json
+pub const MANIFEST_SCHEMA_VERSION: &str = "1.0";
+
 /// Manifest that maps files to partitions
 #[derive(Debug, Clone, Default, serde::Serialize, serde::Deserialize)]
 pub struct Manifest {
@@ -118,7 +121,7 @@ impl Manifest {
     /// Create a new empty manifest
     pub fn new() -> Self {
         Self {
-            schema_version: "1.0".to_string(),
+            schema_version: MANIFEST_SCHEMA_VERSION.to_string(),
             roots: HashMap::new(),
             files: HashMap::new(),
             partitions: HashMap::new(),
@@ -138,7 +141,12 @@ impl Manifest {
         if let Some(parent) = path.parent() {
             std::fs::create_dir_all(parent)?;
         }
-        std::fs::write(path, content)?;
+
+        // Write a temporary file and rename it so that readers in other
+        // processes never load a partial manifest
+        let tmp = path.with_extension(format!("json.{}.tmp", std::process::id()));
+        std::fs::write(&tmp, content)?;
+        std::fs::rename(&tmp, path)?;
         Ok(())
     }
 
@@ -755,6 +763,8 @@ impl LazyGraphManager {
                         ident: edge.ident,
                         version_spec: edge.version_spec,
                         is_dev_dependency: edge.is_dev_dependency,
+                        similarity: edge.similarity,
+                        weight: edge.weight,
                     },
                 );
             }
@@ -796,6 +806,65 @@ impl LazyGraphManager {
         Ok(loaded)
     }
 
+    /// Build a standalone graph containing every partition and cross-partition edge
+    ///
+    /// Unlike `load_all_partitions`, this does not touch the runtime graph or the
+    /// memory budget cache. Partitions are read straight from SQLite into a fresh
+    /// `PetCodeGraph`, and cross-partition edges from the `CrossRefIndex` are added
+    /// so whole-graph analyses (paths, reachability, cycles) see every edge.
+    pub fn load_full_graph(&self) -> Result<PetCodeGraph, LazyGraphError> {
+        let (nodes, edges) = self.read_contents()?;
+
+        let mut graph = PetCodeGraph::new();
+        for node in nodes {
+            graph.add_node(node);
+        }
+        // Add edges once every node is present so no endpoint is missed
+        for edge in &edges {
+            graph.add_edge_from_struct(edge);
+        }
+
+        Ok(graph)
+    }
+
+    /// Read every stored node and edge, cross-partition edges included
+    ///
+    /// The raw contents behind `load_full_graph`: nodes stored in several
+    /// partitions are returned once per copy, and edges are returned whether
+    /// or not their endpoints exist, for index validation.
+    pub fn read_contents(&self) -> Result<(Vec<Node>, Vec<Edge>), LazyGraphError> {
+        let mut partition_ids: Vec<&String> = self.manifest.partitions.keys().collect();
+        partition_ids.sort();
+
+        let mut nodes = Vec::new();
+        let mut edges = Vec::new();
+
+        for partition_id in partition_ids {
+            let db_path = self.partition_db_path(partition_id);
+            if !db_path.exists() {
+                return Err(LazyGraphError::PartitionNotFound(partition_id.clone()));
+            }
+
+            let conn = PartitionConnection::open(&db_path, partition_id)?;
+            nodes.extend(conn.query_all_nodes()?);
+            edges.extend(conn.query_all_edges()?);
+        }
+
+        edges.extend(self.cross_refs.iter().map(|cross_ref| Edge {
+            source: cross_ref.source_id.clone(),
+            target: cross_ref.target_id.clone(),
+            edge_type: cross_ref.edge_type,
+            ref_line: cross_ref.ref_line,
+            ident: cross_ref.ident.clone(),
+            version_spec: cross_ref.version_spec.clone(),
+            is_dev_dependency: cross_ref.is_dev_dependency,
+            similarity: cross_ref.similarity,
+            weight: cross_ref.weight,
+        }));
+
+        Ok((nodes, edges))
+    }
+
     /// Unload a partition from petgraph to free memory
     ///
     /// Removes all nodes and edges belonging to this partition.
@@ -918,6 +987,8 @@ impl LazyGraphManager {
                     ident: cross_ref.ident,
                     version_spec: cross_ref.version_spec,
                     is_dev_dependency: cross_ref.is_dev_dependency,
+                    similarity: cross_ref.similarity,
+                    weight: cross_ref.weight,
                 };
                 edges.push((source_node.clone(), edge_data));
             }
@@ -969,6 +1040,8 @@ impl LazyGraphManager {
                     ident: cross_ref.ident,
                     version_spec: cross_ref.version_spec,
                     is_dev_dependency: cross_ref.is_dev_dependency,
+                    similarity: cross_ref.similarity,
+                    weight: cross_ref.weight,
                 };
                 edges.push((target_node.clone(), edge_data));
             }
@@ -1742,6 +1815,49 @@ mod tests {
         assert_eq!(incoming[0].0.id, "a/main.py:caller");
     }
 
+    #[test]
+    fn test_load_full_graph_includes_cross_refs() {
+        use crate::graph::EdgeType;
+
+        let temp_dir = TempDir::new().unwrap();
+        let prism_dir = temp_dir.path().join(".codeprysm");
+
+        let mut manager = LazyGraphManager::init(&prism_dir).unwrap();
+
+        for (partition, id, name, file) in [
+            ("partition_a", "a/main.py:caller", "caller", "a/main.py"),
+            ("partition_b", "b/lib.py:helper", "helper", "b/lib.py"),
+        ] {
+            let db_path = manager.partition_db_path(partition);
+            let conn = PartitionConnection::create(&db_path, partition).unwrap();
+            conn.insert_node(&create_test_node(id, name, file)).unwrap();
+            manager
+                .manifest_mut()
+                .set_file(file.to_string(), partition.to_string(), None);
+            manager
+                .manifest_mut()
+                .register_partition(partition.to_string(), format!("{}.db", partition));
+        }
+
+        manager.add_cross_ref(CrossRef::new(
+            "a/main.py:caller".to_string(),
+            "partition_a".to_string(),
+            "b/lib.py:helper".to_string(),
+            "partition_b".to_string(),
+            EdgeType::Uses,
+            Some(10),
+            Some("helper".to_string()),
+        ));
+
+        let graph = manager.load_full_graph().unwrap();
+        assert_eq!(graph.node_count(), 2);
+        assert_eq!(graph.edge_count(), 1);
+        assert_eq!(graph.outgoing_edges("a/main.py:caller").count(), 1);
+
+        // The runtime graph is left untouched
+        assert_eq!(manager.loaded_partition_count(), 0);
+    }
+
     #[test]
     fn test_cross_refs_persistence() {
         use crate::graph::EdgeType;
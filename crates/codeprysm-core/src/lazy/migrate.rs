@@ -0,0 +1,378 @@
+//! Index Migration
+//!
+//! Upgrades an index written by an older version in place, so it can be read
+//! without re-parsing the workspace. Every store records its schema version:
+//! manifest.json in its `schema_version` field, the partition and cross-ref
+//! databases in their metadata tables, and the memory-mapped graph store in
+//! its header.
+//!
+//! SQLite stores are migrated by the same steps they run when opened, which
+//! add the columns later versions introduced. The memory-mapped graph store
+//! holds no data of its own and is rewritten from the partitions instead.
+//! Versions are checked for every store before anything is changed, so an
+//! index containing a store from a newer release is left untouched.
+
+use std::fmt;
+use std::path::{Path, PathBuf};
+
+use rusqlite::{Connection, OpenFlags, OptionalExtension};
+use serde::Serialize;
+use thiserror::Error;
+
+use super::cross_refs::{CrossRefError, CrossRefStore, CROSS_REFS_SCHEMA_VERSION};
+use super::manager::{LazyGraphError, LazyGraphManager, Manifest, MANIFEST_SCHEMA_VERSION};
+use super::partition::{PartitionConnection, PartitionError};
+use super::schema::PARTITION_SCHEMA_VERSION;
+use crate::mmap::{MmapGraph, MmapGraphError, MMAP_GRAPH_VERSION};
+
+/// Partition schema versions that opening a partition migrates from
+const PARTITION_UPGRADABLE: &[&str] = &["1.0", "1.1", "1.2"];
+
+/// Cross-ref schema versions that opening the store migrates from
+const CROSS_REFS_UPGRADABLE: &[&str] = &["1.1", "1.2"];
+
+/// Errors that can occur while migrating an index
+#[derive(Debug, Error)]
+pub enum MigrateError {
+    #[error("Index not found: {}", .0.display())]
+    NotFound(PathBuf),
+
+    #[error(
+        "{store} {} has schema version {found}, newer than or unknown to this version (expected {expected})",
+        .path.display()
+    )]
+    Unsupported {
+        store: StoreKind,
+        path: PathBuf,
+        found: String,
+        expected: String,
+    },
+
+    #[error("SQLite error: {0}")]
+    Sqlite(#[from] rusqlite::Error),
+
+    #[error("Partition error: {0}")]
+    Partition(#[from] PartitionError),
+
+    #[error("Cross-ref error: {0}")]
+    CrossRef(#[from] CrossRefError),
+
+    #[error("Lazy graph error: {0}")]
+    LazyGraph(#[from] LazyGraphError),
+
+    #[error("Graph store error: {0}")]
+    GraphStore(#[from] MmapGraphError),
+}
+
+/// A versioned store in the index
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize)]
+#[serde(rename_all = "snake_case")]
+pub enum StoreKind {
+    /// manifest.json
+    Manifest,
+    /// A partition database
+    Partition,
+    /// cross_refs.db
+    CrossRefs,
+    /// The memory-mapped graph store
+    GraphStore,
+}
+
+impl fmt::Display for StoreKind {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        f.write_str(match self {
+            Self::Manifest => "Manifest",
+            Self::Partition => "Partition",
+            Self::CrossRefs => "Cross-ref store",
+            Self::GraphStore => "Graph store",
+        })
+    }
+}
+
+/// What migrating a store does
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize)]
+#[serde(rename_all = "snake_case")]
+pub enum MigrationAction {
+    /// Already at the current version
+    UpToDate,
+    /// Upgraded in place
+    Migrated,
+    /// Rewritten from the partitions
+    Rebuilt,
+}
+
+/// The migration of one store
+#[derive(Debug, Clone, PartialEq, Eq, Serialize)]
+pub struct StoreMigration {
+    /// Kind of store
+    pub store: StoreKind,
+    /// Path of the store
+    pub path: PathBuf,
+    /// Schema version found ("unknown" for unreadable graph stores)
+    pub from: String,
+    /// Schema version after migrating
+    pub to: String,
+    /// What migrating does
+    pub action: MigrationAction,
+}
+
+impl StoreMigration {
+    /// Check whether migrating changes the store
+    pub fn changes(&self) -> bool {
+        self.action != MigrationAction::UpToDate
+    }
+}
+
+/// Migrate the index in `prism_dir`, and the memory-mapped graph store at
+/// `graph_store` if there is one, to the current schema versions.
+///
+/// Returns the migration of every store, in the order applied. With
+/// `dry_run`, only reports what would be done.
+pub fn migrate_index(
+    prism_dir: &Path,
+    graph_store: Option<&Path>,
+    dry_run: bool,
+) -> Result<Vec<StoreMigration>, MigrateError> {
+    let plan = plan(prism_dir, graph_store)?;
+    if dry_run {
+        return Ok(plan);
+    }
+
+    for step in plan.iter().filter(|step| step.changes()) {
+        match step.store {
+            StoreKind::Manifest => {}
+            StoreKind::Partition => {
+                let partition_id = step
+                    .path
+                    .file_stem()
+                    .map(|stem| stem.to_string_lossy().to_string())
+                    .unwrap_or_default();
+                PartitionConnection::open(&step.path, &partition_id)?;
+            }
+            StoreKind::CrossRefs => {
+                CrossRefStore::open(&step.path)?;
+            }
+            StoreKind::GraphStore => {
+                let graph = LazyGraphManager::open(prism_dir)?.load_full_graph()?;
+                MmapGraph::write(&graph, &step.path)?;
+            }
+        }
+    }
+    Ok(plan)
+}
+
+/// Check the version of every store and decide what to do with it
+fn plan(prism_dir: &Path, graph_store: Option<&Path>) -> Result<Vec<StoreMigration>, MigrateError> {
+    let manifest_path = prism_dir.join("manifest.json");
+    if !manifest_path.exists() {
+        return Err(MigrateError::NotFound(prism_dir.to_path_buf()));
+    }
+    let manifest = Manifest::load(&manifest_path)?;
+
+    let mut plan = vec![step(
+        StoreKind::Manifest,
+        manifest_path,
+        manifest.schema_version,
+        MANIFEST_SCHEMA_VERSION,
+        &[],
+    )?];
+
+    let mut partitions: Vec<&String> = manifest.partitions.values().collect();
+    partitions.sort();
+    for filename in partitions {
+        let path = prism_dir.join("partitions").join(filename);
+        if !path.exists() {
+            continue;
+        }
+        let found = stored_version(&path, "partition_metadata")?;
+        plan.push(step(
+            StoreKind::Partition,
+            path,
+            found.unwrap_or_else(|| PARTITION_SCHEMA_VERSION.to_string()),
+            PARTITION_SCHEMA_VERSION,
+            PARTITION_UPGRADABLE,
+        )?);
+    }
+
+    let cross_refs_path = prism_dir.join("cross_refs.db");
+    if cross_refs_path.exists() {
+        let found = stored_version(&cross_refs_path, "cross_refs_metadata")?;
+        plan.push(step(
+            StoreKind::CrossRefs,
+            cross_refs_path,
+            found.unwrap_or_else(|| CROSS_REFS_SCHEMA_VERSION.to_string()),
+            CROSS_REFS_SCHEMA_VERSION,
+            CROSS_REFS_UPGRADABLE,
+        )?);
+    }
+
+    // The graph store is derived from the partitions, so any version can be
+    // replaced, including one that cannot be read at all
+    if let Some(path) = graph_store.filter(|path| path.exists()) {
+        let (from, action) = match MmapGraph::open(path) {
+            Ok(_) => (MMAP_GRAPH_VERSION.to_string(), MigrationAction::UpToDate),
+            Err(MmapGraphError::Version { found, .. }) => {
+                (found.to_string(), MigrationAction::Rebuilt)
+            }
+            Err(_) => ("unknown".to_string(), MigrationAction::Rebuilt),
+        };
+        plan.push(StoreMigration {
+            store: StoreKind::GraphStore,
+            path: path.to_path_buf(),
+            from,
+            to: MMAP_GRAPH_VERSION.to_string(),
+            action,
+        });
+    }
+
+    Ok(plan)
+}
+
+/// Decide how to migrate a store from version `found` to `current`
+fn step(
+    store: StoreKind,
+    path: PathBuf,
+    found: String,
+    current: &str,
+    upgradable: &[&str],
+) -> Result<StoreMigration, MigrateError> {
+    let action = if found == current {
+        MigrationAction::UpToDate
+    } else if upgradable.contains(&found.as_str()) {
+        MigrationAction::Migrated
+    } else {
+        return Err(MigrateError::Unsupported {
+            store,
+            path,
+            found,
+            expected: current.to_string(),
+        });
+    };
+    Ok(StoreMigration {
+        store,
+        path,
+        from: found,
+        to: current.to_string(),
+        action,
+    })
+}
+
+/// Read the schema version of a SQLite store without migrating it
+fn stored_version(path: &Path, metadata_table: &str) -> Result<Option<String>, rusqlite::Error> {
+    let conn = Connection::open_with_flags(path, OpenFlags::SQLITE_OPEN_READ_ONLY)?;
+    conn.query_row(
+        &format!(
+            "SELECT value FROM {} WHERE key = 'schema_version'",
+            metadata_table
+        ),
+        [],
+        |row| row.get(0),
+    )
+    .optional()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::{CallableKind, Edge, Node, PetCodeGraph};
+    use crate::lazy::partitioner::GraphPartitioner;
+
+    fn write_index(prism_dir: &Path) {
+        let mut graph = PetCodeGraph::new();
+        graph.add_node(Node::source_file(
+            "main.go".to_string(),
+            "main.go".to_string(),
+            "hash".to_string(),
+            10,
+        ));
+        graph.add_node(Node::callable(
+            "main.go:main".to_string(),
+            "main".to_string(),
+            CallableKind::Function,
+            "main.go".to_string(),
+            1,
+            10,
+        ));
+        graph.add_edge_from_struct(&Edge::contains(
+            "main.go".to_string(),
+            "main.go:main".to_string(),
+        ));
+        GraphPartitioner::partition_with_stats(&graph, prism_dir, Some("repo")).unwrap();
+    }
+
+    /// Turn every partition back into a v1.1 partition
+    fn downgrade_partitions(prism_dir: &Path) {
+        for entry in std::fs::read_dir(prism_dir.join("partitions")).unwrap() {
+            let conn = Connection::open(entry.unwrap().path()).unwrap();
+            conn.execute_batch(
+                "ALTER TABLE edges DROP COLUMN similarity;
+                 ALTER TABLE edges DROP COLUMN weight;
+                 UPDATE partition_metadata SET value = '1.1' WHERE key = 'schema_version';",
+            )
+            .unwrap();
+        }
+    }
+
+    #[test]
+    fn test_migrate_upgrades_old_partitions() {
+        let dir = tempfile::tempdir().unwrap();
+        write_index(dir.path());
+        downgrade_partitions(dir.path());
+
+        let planned = migrate_index(dir.path(), None, true).unwrap();
+        assert_eq!(planned[0].store, StoreKind::Manifest);
+        let partition = planned
+            .iter()
+            .find(|step| step.store == StoreKind::Partition)
+            .unwrap();
+        assert_eq!(partition.from, "1.1");
+        assert_eq!(partition.action, MigrationAction::Migrated);
+        // A dry run changes nothing
+        assert_eq!(
+            stored_version(&partition.path, "partition_metadata").unwrap(),
+            Some("1.1".to_string())
+        );
+
+        migrate_index(dir.path(), None, false).unwrap();
+        assert_eq!(
+            stored_version(&partition.path, "partition_metadata").unwrap(),
+            Some(PARTITION_SCHEMA_VERSION.to_string())
+        );
+        let again = migrate_index(dir.path(), None, false).unwrap();
+        assert!(again.iter().all(|step| !step.changes()));
+    }
+
+    #[test]
+    fn test_migrate_rebuilds_unreadable_graph_store() {
+        let dir = tempfile::tempdir().unwrap();
+        write_index(dir.path());
+        let store = dir.path().join("graph.cpg");
+        std::fs::write(&store, b"not a graph store").unwrap();
+
+        let migrated = migrate_index(dir.path(), Some(&store), false).unwrap();
+        let step = migrated.last().unwrap();
+        assert_eq!(step.store, StoreKind::GraphStore);
+        assert_eq!(step.action, MigrationAction::Rebuilt);
+        let graph = MmapGraph::open(&store).unwrap();
+        assert!(graph.get_node("main.go:main").is_some());
+    }
+
+    #[test]
+    fn test_migrate_refuses_newer_stores() {
+        let dir = tempfile::tempdir().unwrap();
+        write_index(dir.path());
+        let manifest_path = dir.path().join("manifest.json");
+        let mut manifest = Manifest::load(&manifest_path).unwrap();
+        manifest.schema_version = "9.0".to_string();
+        manifest.save(&manifest_path).unwrap();
+
+        let err = migrate_index(dir.path(), None, false).unwrap_err();
+        assert!(matches!(
+            err,
+            MigrateError::Unsupported {
+                store: StoreKind::Manifest,
+                ..
+            }
+        ));
+    }
+}
@@ -5,6 +5,12 @@
 //! - On-demand partition loading into petgraph
 //! - Memory-based eviction with LRU tracking
 //! - Cross-partition edge indexing
+//! - Streaming partition writes from [`GraphBuilder::build_streaming`]
+//! - In-place migration of indexes written by older versions
+//! - Full-text index over doc comments, comments, and string literals
+//! - Timestamped snapshots of graph health measures for trend queries
+//! - A cross-process write lock, so a watcher and ad-hoc commands can share
+//!   one index
 //!
 //! # Architecture
 //!
@@ -18,15 +24,34 @@
 //! Storage:
 //! ├── manifest.json (file → partition mapping)
 //! ├── partitions/*.db (SQLite partition files)
-//! └── cross_refs.db (cross-partition edges)
+//! ├── cross_refs.db (cross-partition edges)
+//! ├── text_index.db (FTS5 index of docs, comments, strings)
+//! ├── history.db (graph snapshots for trend queries)
+//! └── write.lock (held by the process writing partitions)
 //! ```
+//!
+//! # Concurrency
+//!
+//! Any number of processes may read an index while one writes it. Writers
+//! hold the directory's [`WriteLock`] for the whole write; others wait for
+//! it. Each partition and `cross_refs.db` is replaced in a single SQLite
+//! transaction and the manifest by an atomic rename, so readers see either
+//! the old or the new contents of each file. Connections wait for a busy
+//! database rather than failing.
+//!
+//! [`GraphBuilder::build_streaming`]: crate::builder::GraphBuilder::build_streaming
 
 pub mod cache;
 pub mod cross_refs;
+pub mod history;
+pub mod lock;
 pub mod manager;
+pub mod migrate;
 pub mod partition;
 pub mod partitioner;
 pub mod schema;
+pub mod sink;
+pub mod text_index;
 
 // Re-exports
 pub use cache::{
@@ -35,10 +60,24 @@ pub use cache::{
 pub use cross_refs::{
     CrossRef, CrossRefError, CrossRefIndex, CrossRefStore, CROSS_REFS_SCHEMA_VERSION,
 };
-pub use manager::{LazyGraphError, LazyGraphManager, LazyGraphStats, Manifest, ManifestEntry};
+pub use history::{
+    civil_date, GraphSnapshot, History, HistoryError, PackageSnapshot, SnapshotGranularity,
+    SnapshotSummary, TrendMetric, TrendPeriod, TrendPoint, HISTORY_SCHEMA_VERSION,
+};
+pub use lock::{LockError, WriteLock, DEFAULT_LOCK_TIMEOUT, WRITE_LOCK_FILE};
+pub use manager::{
+    LazyGraphError, LazyGraphManager, LazyGraphStats, Manifest, ManifestEntry,
+    MANIFEST_SCHEMA_VERSION,
+};
+pub use migrate::{migrate_index, MigrateError, MigrationAction, StoreKind, StoreMigration};
 pub use partition::{PartitionConnection, PartitionError, PartitionStats};
 pub use partitioner::{GraphPartitioner, PartitionerError, PartitioningStats};
 pub use schema::{
     EDGE_COLUMNS, NODE_COLUMNS, PARTITION_SCHEMA_VERSION, SCHEMA_CREATE_EDGES,
     SCHEMA_CREATE_INDEXES, SCHEMA_CREATE_METADATA, SCHEMA_CREATE_NODES,
 };
+pub use sink::PartitionSink;
+pub use text_index::{
+    TextIndex, TextIndexError, TextKind, TextMatch, TextSpan, TextSyncStats,
+    TEXT_INDEX_SCHEMA_VERSION,
+};
@@ -9,9 +9,10 @@ use rusqlite::{params, Connection, OptionalExtension, Result as SqliteResult};
 use std::path::Path;
 use thiserror::Error;
 
+use super::lock::BUSY_TIMEOUT;
 use super::schema::{
-    PARTITION_SCHEMA_VERSION, SCHEMA_CREATE_EDGES, SCHEMA_CREATE_INDEXES, SCHEMA_CREATE_METADATA,
-    SCHEMA_CREATE_NODES,
+    MIGRATION_V1_1_TO_V1_2, MIGRATION_V1_2_TO_V1_3, PARTITION_SCHEMA_VERSION, SCHEMA_CREATE_EDGES,
+    SCHEMA_CREATE_INDEXES, SCHEMA_CREATE_METADATA, SCHEMA_CREATE_NODES,
 };
 
 /// Errors that can occur during partition operations
@@ -43,8 +44,8 @@ pub struct PartitionConnection {
 impl PartitionConnection {
     /// Open an existing partition database
     ///
-    /// If the partition is at an older schema version (v1.0), it will be
-    /// automatically migrated to the current version (v1.1).
+    /// If the partition is at an older schema version (v1.0 to v1.2), it will
+    /// be automatically migrated to the current version (v1.3).
     pub fn open(path: &Path, partition_id: &str) -> Result<Self, PartitionError> {
         let conn = Connection::open(path)?;
         Self::configure_connection(&conn)?;
@@ -61,8 +62,17 @@ impl PartitionConnection {
                     // Already at current version, nothing to do
                 }
                 "1.0" => {
-                    // Migrate from v1.0 to v1.1
+                    // Migrate from v1.0 to v1.1, then to v1.2 and v1.3
                     pc.migrate_v1_0_to_v1_1()?;
+                    pc.migrate_v1_1_to_v1_2()?;
+                    pc.migrate_v1_2_to_v1_3()?;
+                }
+                "1.1" => {
+                    pc.migrate_v1_1_to_v1_2()?;
+                    pc.migrate_v1_2_to_v1_3()?;
+                }
+                "1.2" => {
+                    pc.migrate_v1_2_to_v1_3()?;
                 }
                 _ => {
                     return Err(PartitionError::SchemaVersionMismatch {
@@ -87,6 +97,28 @@ impl PartitionConnection {
             .execute("ALTER TABLE edges ADD COLUMN is_dev_dependency INTEGER", [])?;
 
         // Update schema version
+        self.set_metadata("schema_version", "1.1")?;
+
+        Ok(())
+    }
+
+    /// Migrate partition from v1.1 to v1.2
+    ///
+    /// Adds the similarity column to edges table.
+    fn migrate_v1_1_to_v1_2(&self) -> Result<(), PartitionError> {
+        self.conn.execute_batch(MIGRATION_V1_1_TO_V1_2)?;
+
+        self.set_metadata("schema_version", "1.2")?;
+
+        Ok(())
+    }
+
+    /// Migrate partition from v1.2 to v1.3
+    ///
+    /// Adds the weight column to edges table.
+    fn migrate_v1_2_to_v1_3(&self) -> Result<(), PartitionError> {
+        self.conn.execute_batch(MIGRATION_V1_2_TO_V1_3)?;
+
         self.set_metadata("schema_version", PARTITION_SCHEMA_VERSION)?;
 
         Ok(())
@@ -144,6 +176,8 @@ impl PartitionConnection {
 
     /// Configure connection with optimal settings
     fn configure_connection(conn: &Connection) -> SqliteResult<()> {
+        // Wait for other processes' writes instead of failing with SQLITE_BUSY
+        conn.busy_timeout(BUSY_TIMEOUT)?;
         // Enable WAL mode for better concurrent read performance
         conn.pragma_update(None, "journal_mode", "WAL")?;
         // Enable foreign keys (not currently used but good practice)
@@ -228,40 +262,42 @@ impl PartitionConnection {
     /// Insert multiple nodes in a transaction
     pub fn insert_nodes(&self, nodes: &[Node]) -> Result<(), PartitionError> {
         let tx = self.conn.unchecked_transaction()?;
+        Self::write_nodes(&tx, nodes)?;
+        tx.commit()?;
+        Ok(())
+    }
 
-        {
-            let mut stmt = tx.prepare(
-                r#"
-                INSERT OR REPLACE INTO nodes
-                    (id, name, node_type, kind, subtype, file, line, end_line, text, hash, metadata_json)
-                VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11)
-                "#,
-            )?;
-
-            for node in nodes {
-                let metadata_json = if node.metadata.is_empty() {
-                    None
-                } else {
-                    Some(serde_json::to_string(&node.metadata)?)
-                };
-
-                stmt.execute(params![
-                    node.id,
-                    node.name,
-                    node.node_type.as_str(),
-                    node.kind,
-                    node.subtype,
-                    node.file,
-                    node.line as i64,
-                    node.end_line as i64,
-                    node.text,
-                    node.hash,
-                    metadata_json,
-                ])?;
-            }
-        }
+    /// Insert nodes with an existing connection or transaction
+    fn write_nodes(conn: &Connection, nodes: &[Node]) -> Result<(), PartitionError> {
+        let mut stmt = conn.prepare(
+            r#"
+            INSERT OR REPLACE INTO nodes
+                (id, name, node_type, kind, subtype, file, line, end_line, text, hash, metadata_json)
+            VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11)
+            "#,
+        )?;
 
-        tx.commit()?;
+        for node in nodes {
+            let metadata_json = if node.metadata.is_empty() {
+                None
+            } else {
+                Some(serde_json::to_string(&node.metadata)?)
+            };
+
+            stmt.execute(params![
+                node.id,
+                node.name,
+                node.node_type.as_str(),
+                node.kind,
+                node.subtype,
+                node.file,
+                node.line as i64,
+                node.end_line as i64,
+                node.text,
+                node.hash,
+                metadata_json,
+            ])?;
+        }
         Ok(())
     }
 
@@ -383,8 +419,8 @@ impl PartitionConnection {
     pub fn insert_edge(&self, edge: &Edge) -> Result<(), PartitionError> {
         self.conn.execute(
             r#"
-            INSERT OR IGNORE INTO edges (source, target, edge_type, ref_line, ident, version_spec, is_dev_dependency)
-            VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7)
+            INSERT OR IGNORE INTO edges (source, target, edge_type, ref_line, ident, version_spec, is_dev_dependency, similarity, weight)
+            VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9)
             "#,
             params![
                 edge.source,
@@ -394,6 +430,8 @@ impl PartitionConnection {
                 edge.ident,
                 edge.version_spec,
                 edge.is_dev_dependency,
+                edge.similarity,
+                edge.weight,
             ],
         )?;
         Ok(())
@@ -402,29 +440,33 @@ impl PartitionConnection {
     /// Insert multiple edges in a transaction
     pub fn insert_edges(&self, edges: &[Edge]) -> Result<(), PartitionError> {
         let tx = self.conn.unchecked_transaction()?;
+        Self::write_edges(&tx, edges)?;
+        tx.commit()?;
+        Ok(())
+    }
 
-        {
-            let mut stmt = tx.prepare(
-                r#"
-                INSERT OR IGNORE INTO edges (source, target, edge_type, ref_line, ident, version_spec, is_dev_dependency)
-                VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7)
-                "#,
-            )?;
-
-            for edge in edges {
-                stmt.execute(params![
-                    edge.source,
-                    edge.target,
-                    edge.edge_type.as_str(),
-                    edge.ref_line.map(|l| l as i64),
-                    edge.ident,
-                    edge.version_spec,
-                    edge.is_dev_dependency,
-                ])?;
-            }
-        }
+    /// Insert edges with an existing connection or transaction
+    fn write_edges(conn: &Connection, edges: &[Edge]) -> Result<(), PartitionError> {
+        let mut stmt = conn.prepare(
+            r#"
+            INSERT OR IGNORE INTO edges (source, target, edge_type, ref_line, ident, version_spec, is_dev_dependency, similarity, weight)
+            VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9)
+            "#,
+        )?;
 
-        tx.commit()?;
+        for edge in edges {
+            stmt.execute(params![
+                edge.source,
+                edge.target,
+                edge.edge_type.as_str(),
+                edge.ref_line.map(|l| l as i64),
+                edge.ident,
+                edge.version_spec,
+                edge.is_dev_dependency,
+                edge.similarity,
+                edge.weight,
+            ])?;
+        }
         Ok(())
     }
 
@@ -432,7 +474,7 @@ impl PartitionConnection {
     pub fn query_edges_by_source(&self, source: &str) -> Result<Vec<Edge>, PartitionError> {
         let mut stmt = self.conn.prepare(
             r#"
-            SELECT source, target, edge_type, ref_line, ident, version_spec, is_dev_dependency
+            SELECT source, target, edge_type, ref_line, ident, version_spec, is_dev_dependency, similarity, weight
             FROM edges WHERE source = ?1
             "#,
         )?;
@@ -448,7 +490,7 @@ impl PartitionConnection {
     pub fn query_edges_by_target(&self, target: &str) -> Result<Vec<Edge>, PartitionError> {
         let mut stmt = self.conn.prepare(
             r#"
-            SELECT source, target, edge_type, ref_line, ident, version_spec, is_dev_dependency
+            SELECT source, target, edge_type, ref_line, ident, version_spec, is_dev_dependency, similarity, weight
             FROM edges WHERE target = ?1
             "#,
         )?;
@@ -464,7 +506,7 @@ impl PartitionConnection {
     pub fn query_all_edges(&self) -> Result<Vec<Edge>, PartitionError> {
         let mut stmt = self.conn.prepare(
             r#"
-            SELECT source, target, edge_type, ref_line, ident, version_spec, is_dev_dependency
+            SELECT source, target, edge_type, ref_line, ident, version_spec, is_dev_dependency, similarity, weight
             FROM edges
             "#,
         )?;
@@ -509,6 +551,12 @@ impl PartitionConnection {
             "USES" => EdgeType::Uses,
             "DEFINES" => EdgeType::Defines,
             "DEPENDS_ON" => EdgeType::DependsOn,
+            "CLONE_OF" => EdgeType::CloneOf,
+            "DEFINED_IN" => EdgeType::DefinedIn,
+            "CROSS_LANGUAGE" => EdgeType::CrossLanguage,
+            "REFERS_BY_NAME" => EdgeType::RefersByName,
+            "EQUIVALENT_TO" => EdgeType::EquivalentTo,
+            "SUBSET_OF" => EdgeType::SubsetOf,
             _ => EdgeType::Uses, // Default fallback
         };
 
@@ -520,6 +568,8 @@ impl PartitionConnection {
             ident: row.get(4)?,
             version_spec: row.get(5).ok().flatten(),
             is_dev_dependency: row.get(6).ok().flatten(),
+            similarity: row.get(7).ok().flatten(),
+            weight: row.get(8).ok().flatten(),
         })
     }
 
@@ -534,6 +584,20 @@ impl PartitionConnection {
         Ok(())
     }
 
+    /// Replace all nodes and edges of the partition in one transaction.
+    ///
+    /// Readers in other processes see either the old or the new contents,
+    /// never a partially rewritten partition.
+    pub fn replace_all(&self, nodes: &[Node], edges: &[Edge]) -> Result<(), PartitionError> {
+        let tx = self.conn.unchecked_transaction()?;
+        tx.execute("DELETE FROM edges", [])?;
+        tx.execute("DELETE FROM nodes", [])?;
+        Self::write_nodes(&tx, nodes)?;
+        Self::write_edges(&tx, edges)?;
+        tx.commit()?;
+        Ok(())
+    }
+
     /// Get partition statistics
     pub fn stats(&self) -> Result<PartitionStats, PartitionError> {
         Ok(PartitionStats {
@@ -582,6 +646,8 @@ mod tests {
             ident: Some("test".to_string()),
             version_spec: None,
             is_dev_dependency: None,
+            similarity: None,
+            weight: None,
         }
     }
 
@@ -809,6 +875,8 @@ mod tests {
             ident: Some("bar".to_string()),
             version_spec: Some("^1.0.0".to_string()),
             is_dev_dependency: Some(true),
+            similarity: None,
+            weight: None,
         };
 
         conn.insert_edge(&edge).unwrap();
@@ -905,9 +973,9 @@ mod tests {
         // Open the partition with PartitionConnection - this should trigger migration
         let conn = PartitionConnection::open(&db_path, "test_partition").unwrap();
 
-        // Verify schema version is now 1.1
+        // Verify schema version is now current
         let version = conn.get_metadata("schema_version").unwrap();
-        assert_eq!(version, Some("1.1".to_string()));
+        assert_eq!(version, Some(PARTITION_SCHEMA_VERSION.to_string()));
 
         // Verify old edges can still be read (new columns should be None)
         let edges = conn.query_edges_by_source("a").unwrap();
@@ -919,6 +987,8 @@ mod tests {
         assert_eq!(edges[0].ident, Some("test_ident".to_string()));
         assert_eq!(edges[0].version_spec, None); // Backward compat
         assert_eq!(edges[0].is_dev_dependency, None); // Backward compat
+        assert_eq!(edges[0].similarity, None); // Backward compat
+        assert_eq!(edges[0].weight, None); // Backward compat
 
         // Insert a new edge with v1.1 schema
         let new_edge = Edge {
@@ -929,6 +999,8 @@ mod tests {
             ident: Some("dep".to_string()),
             version_spec: Some(">=2.0".to_string()),
             is_dev_dependency: Some(false),
+            similarity: None,
+            weight: Some(12),
         };
         conn.insert_edge(&new_edge).unwrap();
 
@@ -937,5 +1009,13 @@ mod tests {
         assert_eq!(new_edges.len(), 1);
         assert_eq!(new_edges[0].version_spec, Some(">=2.0".to_string()));
         assert_eq!(new_edges[0].is_dev_dependency, Some(false));
+        assert_eq!(new_edges[0].weight, Some(12)); // v1.3 column
+
+        // CLONE_OF similarity is stored in the v1.2 column
+        conn.insert_edge(&Edge::clone_of("e".to_string(), "f".to_string(), 87))
+            .unwrap();
+        let clone_edges = conn.query_edges_by_source("e").unwrap();
+        assert_eq!(clone_edges[0].edge_type, EdgeType::CloneOf);
+        assert_eq!(clone_edges[0].similarity, Some(87));
     }
 }
@@ -27,11 +27,13 @@
 
 use crate::graph::{Edge, Node, PetCodeGraph};
 use crate::lazy::cross_refs::{CrossRef, CrossRefIndex, CrossRefStore};
+use crate::lazy::lock::WriteLock;
 use crate::lazy::manager::{LazyGraphManager, Manifest, RootInfo};
 use crate::lazy::partition::PartitionConnection;
 use std::collections::{HashMap, HashSet};
 use std::path::Path;
 use thiserror::Error;
+use tracing::info_span;
 
 /// Errors that can occur during partitioning
 #[derive(Debug, Error)]
@@ -50,6 +52,9 @@ pub enum PartitionerError {
 
     #[error("Manifest error: {0}")]
     Manifest(#[from] crate::lazy::manager::LazyGraphError),
+
+    #[error("Lock error: {0}")]
+    Lock(#[from] crate::lazy::lock::LockError),
 }
 
 /// Statistics from the partitioning process
@@ -99,6 +104,20 @@ impl GraphPartitioner {
         prism_dir: &Path,
         root_name: Option<&str>,
     ) -> Result<(Manifest, PartitioningStats), PartitionerError> {
+        let lock = WriteLock::acquire(prism_dir)?;
+        Self::partition_locked(graph, prism_dir, root_name, &lock)
+    }
+
+    /// Like `partition_with_stats()`, for a caller that already holds the
+    /// directory's [`WriteLock`], e.g. across reading the previous graph
+    /// and saving the updated one.
+    pub fn partition_locked(
+        graph: &PetCodeGraph,
+        prism_dir: &Path,
+        root_name: Option<&str>,
+        _lock: &WriteLock,
+    ) -> Result<(Manifest, PartitioningStats), PartitionerError> {
+        let _span = info_span!("export", prism_dir = %prism_dir.display()).entered();
         let root = root_name.unwrap_or("default");
         let partitions_dir = prism_dir.join("partitions");
 
@@ -131,18 +150,13 @@ impl GraphPartitioner {
             let filename = format!("{}.db", safe_name);
             let db_path = partitions_dir.join(&filename);
 
-            // Create partition connection
+            // Replace the partition's nodes and intra-partition edges
             let conn = PartitionConnection::create(&db_path, partition_id)?;
-
-            // Insert nodes
-            let node_vec: Vec<Node> = nodes.to_vec();
-            conn.insert_nodes(&node_vec)?;
-
-            // Insert intra-partition edges for this partition
-            if let Some(edges) = intra_edges.get(partition_id) {
-                let edge_vec: Vec<Edge> = edges.to_vec();
-                conn.insert_edges(&edge_vec)?;
-            }
+            let edges = intra_edges
+                .get(partition_id)
+                .map(Vec::as_slice)
+                .unwrap_or_default();
+            conn.replace_all(nodes, edges)?;
 
             // Register in manifest
             partition_filenames.insert(partition_id.clone(), filename.clone());
@@ -159,8 +173,7 @@ impl GraphPartitioner {
         // Step 5: Write cross-partition edges to cross_refs.db
         let cross_refs_path = prism_dir.join("cross_refs.db");
         let cross_ref_store = CrossRefStore::create(&cross_refs_path)?;
-        let cross_ref_vec: Vec<CrossRef> = cross_refs.iter().cloned().collect();
-        cross_ref_store.add_refs(&cross_ref_vec)?;
+        cross_ref_store.save_all(&cross_refs)?;
 
         // Step 6: Register root info (if we have a root name)
         manifest.register_root(RootInfo {
@@ -197,6 +210,8 @@ impl GraphPartitioner {
         prism_dir: &Path,
         root_info: RootInfo,
     ) -> Result<(Manifest, PartitioningStats), PartitionerError> {
+        let _span = info_span!("export", prism_dir = %prism_dir.display()).entered();
+        let _lock = WriteLock::acquire(prism_dir)?;
         let root = &root_info.name;
         let partitions_dir = prism_dir.join("partitions");
 
@@ -228,14 +243,11 @@ impl GraphPartitioner {
             let db_path = partitions_dir.join(&filename);
 
             let conn = PartitionConnection::create(&db_path, partition_id)?;
-
-            let node_vec: Vec<Node> = nodes.to_vec();
-            conn.insert_nodes(&node_vec)?;
-
-            if let Some(edges) = intra_edges.get(partition_id) {
-                let edge_vec: Vec<Edge> = edges.to_vec();
-                conn.insert_edges(&edge_vec)?;
-            }
+            let edges = intra_edges
+                .get(partition_id)
+                .map(Vec::as_slice)
+                .unwrap_or_default();
+            conn.replace_all(nodes, edges)?;
 
             manifest.register_partition(partition_id.clone(), filename);
 
@@ -249,8 +261,7 @@ impl GraphPartitioner {
         // Step 5: Write cross-refs
         let cross_refs_path = prism_dir.join("cross_refs.db");
         let cross_ref_store = CrossRefStore::create(&cross_refs_path)?;
-        let cross_ref_vec: Vec<CrossRef> = cross_refs.iter().cloned().collect();
-        cross_ref_store.add_refs(&cross_ref_vec)?;
+        cross_ref_store.save_all(&cross_refs)?;
 
         // Step 6: Register root with full info
         manifest.register_root(root_info);
@@ -314,15 +325,21 @@ impl GraphPartitioner {
                 }
                 (Some(src_part), Some(tgt_part)) => {
                     // Cross-partition edge
-                    cross_refs.add(CrossRef::new(
-                        edge.source.clone(),
-                        src_part.clone(),
-                        edge.target.clone(),
-                        tgt_part.clone(),
-                        edge.edge_type,
-                        edge.ref_line,
-                        edge.ident.clone(),
-                    ));
+                    cross_refs.add(CrossRef {
+                        version_spec: edge.version_spec.clone(),
+                        is_dev_dependency: edge.is_dev_dependency,
+                        similarity: edge.similarity,
+                        weight: edge.weight,
+                        ..CrossRef::new(
+                            edge.source.clone(),
+                            src_part.clone(),
+                            edge.target.clone(),
+                            tgt_part.clone(),
+                            edge.edge_type,
+                            edge.ref_line,
+                            edge.ident.clone(),
+                        )
+                    });
                 }
                 _ => {
                     // Edge references unknown node(s) - skip
@@ -350,6 +367,7 @@ impl GraphPartitioner {
         partition_id: &str,
         root_name: &str,
     ) -> Result<(), PartitionerError> {
+        let _lock = WriteLock::acquire(prism_dir)?;
         let partitions_dir = prism_dir.join("partitions");
         let safe_name = partition_id.replace(['/', '\\', ':'], "_");
         let db_path = partitions_dir.join(format!("{}.db", safe_name));
@@ -362,9 +380,6 @@ impl GraphPartitioner {
             PartitionConnection::create(&db_path, partition_id)?
         };
 
-        // Clear existing data and rewrite
-        conn.clear()?;
-
         // Compute partition IDs for edge classification
         let node_to_partition: HashMap<String, String> = graph
             .iter_nodes()
@@ -384,13 +399,13 @@ impl GraphPartitioner {
             .cloned()
             .collect();
 
-        conn.insert_nodes(&nodes)?;
-
-        // Classify and insert intra-partition edges
+        // Classify edges and rewrite the partition
         let (intra_edges, _) = Self::classify_edges(graph, &node_to_partition);
-        if let Some(edges) = intra_edges.get(partition_id) {
-            conn.insert_edges(edges)?;
-        }
+        let edges = intra_edges
+            .get(partition_id)
+            .map(Vec::as_slice)
+            .unwrap_or_default();
+        conn.replace_all(&nodes, edges)?;
 
         Ok(())
     }
@@ -757,6 +772,33 @@ mod tests {
         assert_eq!(stats.node_count, 2);
     }
 
+    #[test]
+    fn test_repartition_replaces_stale_rows() {
+        let temp_dir = TempDir::new().unwrap();
+        let prism_dir = temp_dir.path().join(".codeprysm");
+
+        let mut graph = create_test_graph();
+        GraphPartitioner::partition(&graph, &prism_dir, Some("myrepo")).unwrap();
+        let db_path = prism_dir.join("partitions/myrepo_src_core.db");
+        let before = PartitionConnection::open(&db_path, "myrepo_src/core")
+            .unwrap()
+            .stats()
+            .unwrap();
+
+        // Removing main also drops the only cross-partition edge
+        graph.remove_node("src/core/main.py:main");
+        GraphPartitioner::partition(&graph, &prism_dir, Some("myrepo")).unwrap();
+
+        let after = PartitionConnection::open(&db_path, "myrepo_src/core")
+            .unwrap()
+            .stats()
+            .unwrap();
+        assert_eq!(after.node_count, before.node_count - 1);
+        assert!(after.edge_count < before.edge_count);
+        let cross_refs = CrossRefStore::open(&prism_dir.join("cross_refs.db")).unwrap();
+        assert_eq!(cross_refs.count().unwrap(), 0);
+    }
+
     #[test]
     fn test_get_unique_files() {
         let graph = create_test_graph();
@@ -6,7 +6,9 @@
 
 /// Schema version for partition databases
 /// v1.1 adds version_spec and is_dev_dependency columns for DEPENDS_ON edges
-pub const PARTITION_SCHEMA_VERSION: &str = "1.1";
+/// v1.2 adds the similarity column for CLONE_OF edges
+/// v1.3 adds the weight column for type usage weights
+pub const PARTITION_SCHEMA_VERSION: &str = "1.3";
 
 /// SQL to create the nodes table
 ///
@@ -57,7 +59,8 @@ CREATE TABLE IF NOT EXISTS edges (
     source TEXT NOT NULL,
     target TEXT NOT NULL,
 
-    -- Relationship type (CONTAINS, USES, DEFINES, DEPENDS_ON)
+    -- Relationship type (CONTAINS, USES, DEFINES, DEPENDS_ON, CLONE_OF, DEFINED_IN,
+    -- CROSS_LANGUAGE, REFERS_BY_NAME, EQUIVALENT_TO, SUBSET_OF)
     edge_type TEXT NOT NULL,
 
     -- Line number where the reference occurs (for USES edges)
@@ -70,6 +73,12 @@ CREATE TABLE IF NOT EXISTS edges (
     version_spec TEXT,
     is_dev_dependency INTEGER,
 
+    -- CloneOf edge metadata (v1.2)
+    similarity INTEGER,
+
+    -- Type usage weight (v1.3)
+    weight INTEGER,
+
     -- Ensure no duplicate edges
     UNIQUE(source, target, edge_type, ref_line)
 )
@@ -116,7 +125,7 @@ pub const NODE_COLUMNS: &str =
 
 /// Column names for edge queries (in order for row mapping)
 pub const EDGE_COLUMNS: &str =
-    "id, source, target, edge_type, ref_line, ident, version_spec, is_dev_dependency";
+    "id, source, target, edge_type, ref_line, ident, version_spec, is_dev_dependency, similarity, weight";
 
 /// Migration SQL from v1.0 to v1.1
 ///
@@ -127,6 +136,20 @@ ALTER TABLE edges ADD COLUMN version_spec TEXT;
 ALTER TABLE edges ADD COLUMN is_dev_dependency INTEGER;
 "#;
 
+/// Migration SQL from v1.1 to v1.2
+///
+/// Adds the nullable similarity column for CLONE_OF edges.
+pub const MIGRATION_V1_1_TO_V1_2: &str = r#"
+ALTER TABLE edges ADD COLUMN similarity INTEGER;
+"#;
+
+/// Migration SQL from v1.2 to v1.3
+///
+/// Adds the nullable weight column for type usage weights.
+pub const MIGRATION_V1_2_TO_V1_3: &str = r#"
+ALTER TABLE edges ADD COLUMN weight INTEGER;
+"#;
+
 #[cfg(test)]
 mod tests {
     use super::*;
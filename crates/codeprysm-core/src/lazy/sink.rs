@@ -0,0 +1,280 @@
+//! Partition Sink
+//!
+//! Writes a streaming build straight into partition files, without holding
+//! the graph in memory. Nodes and intra-partition edges go to their
+//! partition's SQLite file as each file is extracted, cross-partition edges
+//! to cross_refs.db, and the manifest is saved when the stream finishes.
+//!
+//! The result is the same layout [`GraphPartitioner`] writes for the whole
+//! graph.
+//!
+//! [`GraphPartitioner`]: crate::lazy::partitioner::GraphPartitioner
+
+use std::collections::HashMap;
+use std::io;
+use std::path::{Path, PathBuf};
+
+use crate::graph::{Edge, Node};
+use crate::lazy::cross_refs::{CrossRef, CrossRefIndex, CrossRefStore};
+use crate::lazy::lock::WriteLock;
+use crate::lazy::manager::{LazyGraphManager, Manifest, RootInfo};
+use crate::lazy::partition::PartitionConnection;
+use crate::lazy::partitioner::{PartitionerError, PartitioningStats};
+use crate::stream::{GraphSink, NodePatch};
+
+/// Partition connections kept open at once
+const MAX_OPEN_PARTITIONS: usize = 64;
+
+/// A [`GraphSink`] writing partition files into an index directory.
+pub struct PartitionSink {
+    /// Write lock of the index directory
+    _lock: WriteLock,
+    /// The .codeprysm directory
+    prism_dir: PathBuf,
+    /// Root the partitions belong to
+    root: RootInfo,
+    /// Manifest built up as partitions are written
+    manifest: Manifest,
+    /// Partition of every node written so far
+    node_partitions: HashMap<String, String>,
+    /// Recently used partition connections
+    connections: HashMap<String, PartitionConnection>,
+    /// Cross-partition edges
+    cross_refs: CrossRefStore,
+    /// Counts of what was written
+    stats: PartitioningStats,
+}
+
+impl PartitionSink {
+    /// Create a sink writing partitions of `root` into `prism_dir`.
+    ///
+    /// Partitions and cross-partition edges already in the directory are
+    /// replaced as the stream reaches them. The sink holds the directory's
+    /// [`WriteLock`] until it is dropped, so other writers wait for it.
+    pub fn create(prism_dir: &Path, root: RootInfo) -> Result<Self, PartitionerError> {
+        let lock = WriteLock::acquire(prism_dir)?;
+        std::fs::create_dir_all(prism_dir.join("partitions"))?;
+
+        // Clear rather than delete the store: readers in other processes may
+        // still have it (and its write-ahead log) open
+        let cross_refs = CrossRefStore::create(&prism_dir.join("cross_refs.db"))?;
+        cross_refs.save_all(&CrossRefIndex::new())?;
+
+        Ok(Self {
+            _lock: lock,
+            prism_dir: prism_dir.to_path_buf(),
+            root,
+            manifest: Manifest::new(),
+            node_partitions: HashMap::new(),
+            connections: HashMap::new(),
+            cross_refs,
+            stats: PartitioningStats {
+                total_nodes: 0,
+                total_edges: 0,
+                partition_count: 0,
+                cross_partition_edges: 0,
+                intra_partition_edges: 0,
+            },
+        })
+    }
+
+    /// Counts of what was written so far
+    pub fn stats(&self) -> &PartitioningStats {
+        &self.stats
+    }
+
+    /// The manifest of the partitions written so far
+    pub fn manifest(&self) -> &Manifest {
+        &self.manifest
+    }
+
+    /// Connection to a partition, created empty on first use.
+    fn connection(&mut self, partition_id: &str) -> Result<&PartitionConnection, PartitionerError> {
+        if !self.connections.contains_key(partition_id) {
+            if self.connections.len() >= MAX_OPEN_PARTITIONS {
+                self.connections.clear();
+            }
+
+            let existing = self
+                .manifest
+                .get_partition_file(partition_id)
+                .map(str::to_string);
+            let conn = match existing {
+                Some(filename) => {
+                    let db_path = self.prism_dir.join("partitions").join(&filename);
+                    PartitionConnection::open(&db_path, partition_id)?
+                }
+                None => {
+                    let safe_name = partition_id.replace(['/', '\\', ':'], "_");
+                    let filename = format!("{}.db", safe_name);
+                    let db_path = self.prism_dir.join("partitions").join(&filename);
+                    let conn = PartitionConnection::create(&db_path, partition_id)?;
+                    conn.clear()?;
+                    self.manifest
+                        .register_partition(partition_id.to_string(), filename);
+                    self.stats.partition_count += 1;
+                    conn
+                }
+            };
+            self.connections.insert(partition_id.to_string(), conn);
+        }
+        Ok(&self.connections[partition_id])
+    }
+
+    fn write_batch(&mut self, nodes: &[Node], edges: &[Edge]) -> Result<(), PartitionerError> {
+        let mut by_partition: HashMap<String, Vec<Node>> = HashMap::new();
+        for node in nodes {
+            let partition_id =
+                LazyGraphManager::compute_partition_id_for_root(&self.root.name, &node.file);
+            if !node.file.is_empty() {
+                self.manifest
+                    .set_file(node.file.clone(), partition_id.clone(), node.hash.clone());
+            }
+            self.node_partitions
+                .insert(node.id.clone(), partition_id.clone());
+            by_partition
+                .entry(partition_id)
+                .or_default()
+                .push(node.clone());
+        }
+        for (partition_id, nodes) in &by_partition {
+            self.connection(partition_id)?.insert_nodes(nodes)?;
+            self.stats.total_nodes += nodes.len();
+        }
+
+        let mut intra_edges: HashMap<String, Vec<Edge>> = HashMap::new();
+        let mut cross_refs = Vec::new();
+        for edge in edges {
+            let source_partition = self.node_partitions.get(&edge.source);
+            let target_partition = self.node_partitions.get(&edge.target);
+            match (source_partition, target_partition) {
+                (Some(src_part), Some(tgt_part)) if src_part == tgt_part => {
+                    intra_edges
+                        .entry(src_part.clone())
+                        .or_default()
+                        .push(edge.clone());
+                }
+                (Some(src_part), Some(tgt_part)) => {
+                    cross_refs.push(CrossRef {
+                        version_spec: edge.version_spec.clone(),
+                        is_dev_dependency: edge.is_dev_dependency,
+                        similarity: edge.similarity,
+                        weight: edge.weight,
+                        ..CrossRef::new(
+                            edge.source.clone(),
+                            src_part.clone(),
+                            edge.target.clone(),
+                            tgt_part.clone(),
+                            edge.edge_type,
+                            edge.ref_line,
+                            edge.ident.clone(),
+                        )
+                    });
+                }
+                // Edge to a node never written - skip, as the partitioner does
+                _ => {}
+            }
+        }
+        for (partition_id, edges) in &intra_edges {
+            self.connection(partition_id)?.insert_edges(edges)?;
+            self.stats.intra_partition_edges += edges.len();
+        }
+        if !cross_refs.is_empty() {
+            self.cross_refs.add_refs(&cross_refs)?;
+            self.stats.cross_partition_edges += cross_refs.len();
+        }
+        self.stats.total_edges =
+            self.stats.intra_partition_edges + self.stats.cross_partition_edges;
+        Ok(())
+    }
+
+    fn apply_patch(&mut self, patch: &NodePatch) -> Result<(), PartitionerError> {
+        let Some(partition_id) = self.node_partitions.get(&patch.id).cloned() else {
+            return Ok(());
+        };
+        let conn = self.connection(&partition_id)?;
+        if let Some(mut node) = conn.get_node(&patch.id)? {
+            node.metadata.resolved_refs = Some(patch.resolved_refs);
+            node.metadata.unresolved_refs = Some(patch.unresolved_refs);
+            conn.insert_node(&node)?;
+        }
+        Ok(())
+    }
+
+    fn save(&mut self) -> Result<(), PartitionerError> {
+        self.connections.clear();
+        self.manifest.register_root(self.root.clone());
+        self.manifest.save(&self.prism_dir.join("manifest.json"))?;
+        Ok(())
+    }
+}
+
+impl GraphSink for PartitionSink {
+    fn write(&mut self, nodes: &[Node], edges: &[Edge]) -> io::Result<()> {
+        self.write_batch(nodes, edges).map_err(io::Error::other)
+    }
+
+    fn patch(&mut self, patch: &NodePatch) -> io::Result<()> {
+        self.apply_patch(patch).map_err(io::Error::other)
+    }
+
+    fn finish(&mut self) -> io::Result<()> {
+        self.save().map_err(io::Error::other)
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::builder::GraphBuilder;
+
+    fn root_info(name: &str) -> RootInfo {
+        RootInfo {
+            name: name.to_string(),
+            root_type: "code".to_string(),
+            relative_path: ".".to_string(),
+            remote_url: None,
+            branch: None,
+            commit: None,
+        }
+    }
+
+    #[test]
+    fn test_streamed_partitions_load_as_full_graph() {
+        let repo = tempfile::tempdir().unwrap();
+        std::fs::create_dir_all(repo.path().join("lib")).unwrap();
+        std::fs::write(
+            repo.path().join("lib/util.py"),
+            "def helper():\n    return 1\n",
+        )
+        .unwrap();
+        std::fs::write(
+            repo.path().join("main.py"),
+            "from util import helper\n\ndef run():\n    return helper()\n",
+        )
+        .unwrap();
+        let full = GraphBuilder::new_with_embedded_queries()
+            .build_from_directory(repo.path())
+            .unwrap();
+
+        let prism = tempfile::tempdir().unwrap();
+        let mut sink = PartitionSink::create(prism.path(), root_info("repo")).unwrap();
+        GraphBuilder::new_with_embedded_queries()
+            .build_streaming(repo.path(), &mut sink)
+            .unwrap();
+        assert!(sink.stats().cross_partition_edges > 0);
+        assert_eq!(sink.stats().total_nodes, full.node_count());
+
+        let manager = LazyGraphManager::open(prism.path()).unwrap();
+        let loaded = manager.load_full_graph().unwrap();
+        assert_eq!(loaded.node_count(), full.node_count());
+        assert_eq!(loaded.edge_count(), full.edge_count());
+
+        // The final pass patched the file node in its partition
+        let file = manager.get_node("main.py").unwrap().unwrap();
+        assert_eq!(
+            file.metadata.resolved_refs,
+            full.get_node("main.py").unwrap().metadata.resolved_refs
+        );
+    }
+}
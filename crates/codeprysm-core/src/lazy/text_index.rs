@@ -0,0 +1,679 @@
+//! Full-Text Index over Docs, Comments, and Strings
+//!
+//! Indexes the prose of a codebase (doc comments, inline comments, and
+//! string literals) in an SQLite FTS5 table stored next to the partitions,
+//! so prose-level search uses the same storage as structural queries. Each
+//! entry links to a graph node:
+//!
+//! - **doc**: a comment directly above a symbol (attributes and decorators
+//!   may sit in between) or a Python docstring, linked to that symbol
+//! - **comment**: any other comment, linked to the innermost symbol
+//!   containing it (or the file)
+//! - **string**: a string literal outside imports, linked the same way
+//!
+//! # Storage
+//!
+//! ```text
+//! text_index.db
+//! ├── texts (FTS5: text, kind, file, line, end_line, node_id)
+//! ├── text_files (file → content hash when indexed)
+//! └── text_index_metadata (schema version)
+//! ```
+//!
+//! [`TextIndex::sync`] re-scans only the files whose content hash changed
+//! since the last sync. The index is derived data: one written by another
+//! schema version is rebuilt rather than migrated.
+
+use std::collections::hash_map::Entry;
+use std::collections::{HashMap, HashSet};
+use std::fmt;
+use std::path::{Path, PathBuf};
+use std::str::FromStr;
+
+use rusqlite::{params, params_from_iter, Connection, OptionalExtension, Result as SqliteResult};
+use serde::{Deserialize, Serialize};
+use thiserror::Error;
+use tree_sitter::{Node as TsNode, Tree};
+
+use crate::analysis::api_surface::is_attribute;
+use crate::graph::{Node, PetCodeGraph};
+use crate::lazy::lock::BUSY_TIMEOUT;
+use crate::parser::{CodeParser, SupportedLanguage};
+
+/// Schema version for text_index.db
+pub const TEXT_INDEX_SCHEMA_VERSION: &str = "1.0";
+
+/// File name of the text index in the .codeprysm directory
+pub const TEXT_INDEX_FILE: &str = "text_index.db";
+
+/// Longest text kept per entry, in characters (longer literals are cut)
+const MAX_TEXT_CHARS: usize = 4096;
+
+/// Fewest alphanumeric characters a string literal needs to be indexed
+const MIN_STRING_CHARS: usize = 3;
+
+/// SQL to create the full-text table
+///
+/// Only `text` is tokenized; the other columns locate and link the entry.
+const SCHEMA_CREATE_TEXTS: &str = r#"
+CREATE VIRTUAL TABLE IF NOT EXISTS texts USING fts5(
+    text,
+    kind UNINDEXED,
+    file UNINDEXED,
+    line UNINDEXED,
+    end_line UNINDEXED,
+    node_id UNINDEXED,
+    tokenize = 'porter unicode61'
+)
+"#;
+
+/// SQL to create the table of indexed files
+const SCHEMA_CREATE_FILES: &str = r#"
+CREATE TABLE IF NOT EXISTS text_files (
+    file TEXT PRIMARY KEY NOT NULL,
+    -- Content hash of the file when it was scanned
+    hash TEXT
+)
+"#;
+
+/// SQL to create the metadata table
+const SCHEMA_CREATE_METADATA: &str = r#"
+CREATE TABLE IF NOT EXISTS text_index_metadata (
+    key TEXT PRIMARY KEY NOT NULL,
+    value TEXT NOT NULL
+)
+"#;
+
+/// Errors that can occur during text index operations
+#[derive(Debug, Error)]
+pub enum TextIndexError {
+    #[error("SQLite error: {0}")]
+    Sqlite(#[from] rusqlite::Error),
+
+    #[error("IO error: {0}")]
+    IoError(#[from] std::io::Error),
+}
+
+/// Kind of indexed text.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum TextKind {
+    /// Doc comment or docstring of a symbol
+    Doc,
+    /// Other comment
+    Comment,
+    /// String literal
+    String,
+}
+
+impl TextKind {
+    /// All text kinds
+    pub const ALL: [TextKind; 3] = [TextKind::Doc, TextKind::Comment, TextKind::String];
+
+    /// Name of the kind as stored
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            Self::Doc => "doc",
+            Self::Comment => "comment",
+            Self::String => "string",
+        }
+    }
+}
+
+impl fmt::Display for TextKind {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        f.pad(self.as_str())
+    }
+}
+
+impl FromStr for TextKind {
+    type Err = String;
+
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        Self::ALL
+            .into_iter()
+            .find(|kind| kind.as_str().eq_ignore_ascii_case(s))
+            .ok_or_else(|| {
+                format!(
+                    "Unknown text kind '{}': expected doc, comment, or string",
+                    s
+                )
+            })
+    }
+}
+
+/// A comment or string literal found in a file.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct TextSpan {
+    /// Kind of text (comments directly above symbols become docs when linked)
+    pub kind: TextKind,
+    /// First line (1-indexed)
+    pub line: usize,
+    /// Last line (1-indexed)
+    pub end_line: usize,
+    /// Source text, including comment markers or quotes
+    pub text: String,
+}
+
+/// A full-text search result.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct TextMatch {
+    /// Node the text documents or sits in (a symbol, or the file)
+    pub node_id: String,
+    /// File containing the text
+    pub file: String,
+    /// First line of the text (1-indexed)
+    pub line: usize,
+    /// Last line of the text (1-indexed)
+    pub end_line: usize,
+    /// Kind of text
+    pub kind: TextKind,
+    /// Part of the text around the matched words, which are bracketed
+    pub snippet: String,
+    /// Relevance (BM25, higher is better)
+    pub score: f64,
+}
+
+/// Counts of a [`TextIndex::sync`].
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq)]
+pub struct TextSyncStats {
+    /// Files scanned because they are new or changed
+    pub files_indexed: usize,
+    /// Files skipped because their hash is unchanged
+    pub files_unchanged: usize,
+    /// Files dropped because they left the graph
+    pub files_removed: usize,
+    /// Entries written for the scanned files
+    pub entries: usize,
+}
+
+/// SQLite FTS5 index of the docs, comments, and strings of a graph's files.
+pub struct TextIndex {
+    conn: Connection,
+}
+
+impl TextIndex {
+    /// Path of the text index in a .codeprysm directory
+    pub fn path(prism_dir: &Path) -> PathBuf {
+        prism_dir.join(TEXT_INDEX_FILE)
+    }
+
+    /// Check whether a .codeprysm directory has a text index
+    pub fn exists(prism_dir: &Path) -> bool {
+        Self::path(prism_dir).exists()
+    }
+
+    /// Open the text index of a .codeprysm directory, creating it if missing
+    /// and rebuilding it empty if written by another schema version.
+    pub fn open(prism_dir: &Path) -> Result<Self, TextIndexError> {
+        std::fs::create_dir_all(prism_dir)?;
+        let conn = Connection::open(Self::path(prism_dir))?;
+        Self::configure_connection(&conn)?;
+        Self::with_connection(conn)
+    }
+
+    /// Create an in-memory text index (for testing)
+    pub fn in_memory() -> Result<Self, TextIndexError> {
+        Self::with_connection(Connection::open_in_memory()?)
+    }
+
+    /// Create or check the schema on a new connection
+    fn with_connection(conn: Connection) -> Result<Self, TextIndexError> {
+        conn.execute(SCHEMA_CREATE_METADATA, [])?;
+        let index = Self { conn };
+        match index.get_metadata("schema_version")? {
+            Some(version) if version == TEXT_INDEX_SCHEMA_VERSION => {}
+            Some(_) => {
+                index.conn.execute_batch(
+                    "DROP TABLE IF EXISTS texts; DROP TABLE IF EXISTS text_files;",
+                )?;
+                index.create_schema()?;
+            }
+            None => index.create_schema()?,
+        }
+        Ok(index)
+    }
+
+    /// Create the tables and record the schema version
+    fn create_schema(&self) -> Result<(), TextIndexError> {
+        self.conn.execute(SCHEMA_CREATE_TEXTS, [])?;
+        self.conn.execute(SCHEMA_CREATE_FILES, [])?;
+        self.set_metadata("schema_version", TEXT_INDEX_SCHEMA_VERSION)
+    }
+
+    /// Configure connection with optimal settings
+    fn configure_connection(conn: &Connection) -> SqliteResult<()> {
+        conn.busy_timeout(BUSY_TIMEOUT)?;
+        conn.pragma_update(None, "journal_mode", "WAL")?;
+        conn.pragma_update(None, "synchronous", "NORMAL")?;
+        conn.pragma_update(None, "temp_store", "MEMORY")?;
+        Ok(())
+    }
+
+    /// Get a metadata value
+    fn get_metadata(&self, key: &str) -> Result<Option<String>, TextIndexError> {
+        let result = self
+            .conn
+            .query_row(
+                "SELECT value FROM text_index_metadata WHERE key = ?1",
+                [key],
+                |row| row.get(0),
+            )
+            .optional()?;
+        Ok(result)
+    }
+
+    /// Set a metadata value
+    fn set_metadata(&self, key: &str, value: &str) -> Result<(), TextIndexError> {
+        self.conn.execute(
+            "INSERT OR REPLACE INTO text_index_metadata (key, value) VALUES (?1, ?2)",
+            params![key, value],
+        )?;
+        Ok(())
+    }
+
+    /// Bring the index in line with the file nodes of `graph`, reading the
+    /// files from `root`.
+    ///
+    /// Files whose content hash matches the last sync are skipped; files no
+    /// longer in the graph are dropped. Files that cannot be read or parsed
+    /// are recorded without entries.
+    pub fn sync(
+        &mut self,
+        root: &Path,
+        graph: &PetCodeGraph,
+    ) -> Result<TextSyncStats, TextIndexError> {
+        let mut files: Vec<&Node> = Vec::new();
+        let mut symbols: HashMap<&str, Vec<&Node>> = HashMap::new();
+        for node in graph.iter_nodes() {
+            if node.is_file() {
+                files.push(node);
+            } else if is_linkable(node) {
+                symbols.entry(node.file.as_str()).or_default().push(node);
+            }
+        }
+        for nodes in symbols.values_mut() {
+            nodes.sort_by(|a, b| a.line.cmp(&b.line).then(b.end_line.cmp(&a.end_line)));
+        }
+
+        let indexed = self.indexed_files()?;
+        let current: HashSet<&str> = files.iter().map(|f| f.file.as_str()).collect();
+        let mut parsers: HashMap<SupportedLanguage, CodeParser> = HashMap::new();
+        let mut stats = TextSyncStats::default();
+
+        let tx = self.conn.transaction()?;
+        for file in indexed.keys().filter(|f| !current.contains(f.as_str())) {
+            tx.execute("DELETE FROM texts WHERE file = ?1", [file])?;
+            tx.execute("DELETE FROM text_files WHERE file = ?1", [file])?;
+            stats.files_removed += 1;
+        }
+        {
+            let mut insert = tx.prepare(
+                "INSERT INTO texts (text, kind, file, line, end_line, node_id) VALUES (?1, ?2, ?3, ?4, ?5, ?6)",
+            )?;
+            for file in &files {
+                if file.hash.is_some() && indexed.get(&file.file) == Some(&file.hash) {
+                    stats.files_unchanged += 1;
+                    continue;
+                }
+                tx.execute("DELETE FROM texts WHERE file = ?1", [&file.file])?;
+                let file_symbols = symbols
+                    .get(file.file.as_str())
+                    .map_or(&[][..], Vec::as_slice);
+                for (span, node_id) in scan_file(root, file, file_symbols, &mut parsers) {
+                    insert.execute(params![
+                        span.text,
+                        span.kind.as_str(),
+                        file.file,
+                        span.line as i64,
+                        span.end_line as i64,
+                        node_id,
+                    ])?;
+                    stats.entries += 1;
+                }
+                tx.execute(
+                    "INSERT OR REPLACE INTO text_files (file, hash) VALUES (?1, ?2)",
+                    params![file.file, file.hash],
+                )?;
+                stats.files_indexed += 1;
+            }
+        }
+        tx.commit()?;
+        Ok(stats)
+    }
+
+    /// Content hash of every indexed file
+    fn indexed_files(&self) -> Result<HashMap<String, Option<String>>, TextIndexError> {
+        let mut stmt = self.conn.prepare("SELECT file, hash FROM text_files")?;
+        let rows = stmt.query_map([], |row| Ok((row.get(0)?, row.get(1)?)))?;
+        Ok(rows.collect::<SqliteResult<_>>()?)
+    }
+
+    /// Number of indexed entries
+    pub fn entry_count(&self) -> Result<usize, TextIndexError> {
+        let count: i64 = self
+            .conn
+            .query_row("SELECT count(*) FROM texts", [], |row| row.get(0))?;
+        Ok(count as usize)
+    }
+
+    /// Search the index, best matches first.
+    ///
+    /// Every word of `query` must occur in a match; a word ending in `*`
+    /// matches as a prefix, and words are stemmed (`retries` matches
+    /// `retry`). `kinds` limits the kinds of text searched (all if empty).
+    pub fn search(
+        &self,
+        query: &str,
+        kinds: &[TextKind],
+        limit: usize,
+    ) -> Result<Vec<TextMatch>, TextIndexError> {
+        let Some(expression) = match_expression(query) else {
+            return Ok(Vec::new());
+        };
+        let mut sql = String::from(
+            "SELECT node_id, file, line, end_line, kind, snippet(texts, 0, '[', ']', '...', 24), bm25(texts) \
+             FROM texts WHERE texts MATCH ?1",
+        );
+        if !kinds.is_empty() {
+            let placeholders: Vec<String> =
+                (0..kinds.len()).map(|i| format!("?{}", i + 2)).collect();
+            sql.push_str(&format!(" AND kind IN ({})", placeholders.join(", ")));
+        }
+        sql.push_str(&format!(" ORDER BY bm25(texts) LIMIT {}", limit));
+
+        let values =
+            std::iter::once(expression).chain(kinds.iter().map(|k| k.as_str().to_string()));
+        let mut stmt = self.conn.prepare(&sql)?;
+        let rows = stmt.query_map(params_from_iter(values), |row| {
+            let kind: String = row.get(4)?;
+            let line: i64 = row.get(2)?;
+            let end_line: i64 = row.get(3)?;
+            let rank: f64 = row.get(6)?;
+            Ok(TextMatch {
+                node_id: row.get(0)?,
+                file: row.get(1)?,
+                line: line as usize,
+                end_line: end_line as usize,
+                kind: kind.parse().unwrap_or(TextKind::Comment),
+                snippet: row.get(5)?,
+                score: -rank,
+            })
+        })?;
+        Ok(rows.collect::<SqliteResult<_>>()?)
+    }
+}
+
+/// Turn a user query into an FTS5 expression: every word quoted as a
+/// phrase (so punctuation cannot be misread as query syntax), keeping a
+/// trailing `*` as a prefix match. Words without letters or digits are
+/// dropped.
+fn match_expression(query: &str) -> Option<String> {
+    let terms: Vec<String> = query
+        .split_whitespace()
+        .filter_map(|word| {
+            let stem = word.trim_end_matches('*');
+            if !stem.chars().any(char::is_alphanumeric) {
+                return None;
+            }
+            let prefix = if stem.len() < word.len() { "*" } else { "" };
+            Some(format!("\"{}\"{}", stem.replace('"', "\"\""), prefix))
+        })
+        .collect();
+    (!terms.is_empty()).then(|| terms.join(" "))
+}
+
+/// Check whether text can be linked to a node (symbols, not parameters,
+/// locals, markers, package documentation, truncation markers, or the
+/// workspace structure)
+fn is_linkable(node: &Node) -> bool {
+    !node.file.is_empty()
+        && !node.is_repository()
+        && !node.is_workspace()
+        && !node.is_component()
+        && !matches!(
+            node.kind.as_deref(),
+            Some("parameter" | "local" | "marker" | "documentation" | "truncation")
+        )
+}
+
+/// Scan a file for text, linked to the node each span belongs to
+fn scan_file(
+    root: &Path,
+    file: &Node,
+    symbols: &[&Node],
+    parsers: &mut HashMap<SupportedLanguage, CodeParser>,
+) -> Vec<(TextSpan, String)> {
+    let Some(language) = SupportedLanguage::from_path(Path::new(&file.file)) else {
+        return Vec::new();
+    };
+    let Ok(source) = std::fs::read_to_string(root.join(&file.file)) else {
+        return Vec::new();
+    };
+    let parser = match parsers.entry(language) {
+        Entry::Occupied(entry) => entry.into_mut(),
+        Entry::Vacant(entry) => match CodeParser::new(language) {
+            Ok(parser) => entry.insert(parser),
+            Err(_) => return Vec::new(),
+        },
+    };
+    let Ok(tree) = parser.parse(&source) else {
+        return Vec::new();
+    };
+
+    let lines: Vec<&str> = source.lines().collect();
+    scan_texts(&tree, &source, language)
+        .into_iter()
+        .map(|mut span| {
+            let documented = (span.kind == TextKind::Comment)
+                .then(|| documented_symbol(&span, symbols, &lines))
+                .flatten();
+            let node_id = match documented {
+                Some(symbol) => {
+                    span.kind = TextKind::Doc;
+                    symbol.id.clone()
+                }
+                None => enclosing_symbol(span.line, symbols)
+                    .map_or_else(|| file.id.clone(), |symbol| symbol.id.clone()),
+            };
+            (span, node_id)
+        })
+        .collect()
+}
+
+/// The symbol a comment sits directly above, with only attributes between
+fn documented_symbol<'a>(
+    span: &TextSpan,
+    symbols: &[&'a Node],
+    lines: &[&str],
+) -> Option<&'a Node> {
+    let symbol = symbols.iter().find(|s| s.line > span.end_line)?;
+    let between = lines
+        .get(span.end_line..symbol.line - 1)
+        .unwrap_or_default();
+    between
+        .iter()
+        .all(|line| is_attribute(line.trim()))
+        .then_some(*symbol)
+}
+
+/// The innermost symbol spanning `line`
+fn enclosing_symbol<'a>(line: usize, symbols: &[&'a Node]) -> Option<&'a Node> {
+    symbols
+        .iter()
+        .filter(|s| s.line <= line && line <= s.end_line)
+        .min_by_key(|s| s.end_line - s.line)
+        .copied()
+}
+
+/// Find the comments and string literals of a parsed file, in source order.
+///
+/// Python docstrings are returned as docs; every other comment as a comment.
+/// String literals in imports and those with fewer than three letters or
+/// digits are skipped.
+pub fn scan_texts(tree: &Tree, source: &str, language: SupportedLanguage) -> Vec<TextSpan> {
+    let mut spans = Vec::new();
+    let mut stack = vec![tree.root_node()];
+    while let Some(node) = stack.pop() {
+        let kind = node.kind();
+        let text_kind = if kind.contains("comment") {
+            Some(TextKind::Comment)
+        } else if kind.contains("string") && node.is_named() {
+            Some(
+                if language == SupportedLanguage::Python && is_docstring(node) {
+                    TextKind::Doc
+                } else {
+                    TextKind::String
+                },
+            )
+        } else {
+            None
+        };
+        if let Some(text_kind) = text_kind {
+            let text = &source[node.byte_range()];
+            if text_kind == TextKind::Comment || is_indexed_string(node, text) {
+                spans.push(TextSpan {
+                    kind: text_kind,
+                    line: node.start_position().row + 1,
+                    end_line: node.end_position().row + 1,
+                    text: text.chars().take(MAX_TEXT_CHARS).collect(),
+                });
+            }
+            continue;
+        }
+        let mut cursor = node.walk();
+        let children: Vec<TsNode> = node.children(&mut cursor).collect();
+        stack.extend(children.into_iter().rev());
+    }
+    spans
+}
+
+/// Check whether a Python string is the first statement of a module, class,
+/// or function body
+fn is_docstring(node: TsNode) -> bool {
+    node.parent().is_some_and(|statement| {
+        statement.kind() == "expression_statement"
+            && statement.prev_named_sibling().is_none()
+            && statement
+                .parent()
+                .is_some_and(|body| matches!(body.kind(), "block" | "module"))
+    })
+}
+
+/// Check whether a string literal is worth indexing
+fn is_indexed_string(node: TsNode, text: &str) -> bool {
+    let in_import = node
+        .parent()
+        .is_some_and(|parent| parent.kind().contains("import"));
+    !in_import && text.chars().filter(|c| c.is_alphanumeric()).count() >= MIN_STRING_CHARS
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::builder::GraphBuilder;
+
+    const BILLING: &str = r#""""Billing helpers."""
+
+
+class Invoice:
+    """An invoice sent to a customer."""
+
+    def total(self):
+        # sum the line items before applying discounts
+        return sum(self.items)
+
+
+def send(invoice):
+    log("sending invoice to the payment gateway")
+"#;
+
+    const SERVER: &str = r#"package main
+
+import "fmt"
+
+// Handle serves health checks for the load balancer.
+func Handle() {
+	fmt.Println("ok")
+}
+"#;
+
+    #[test]
+    fn test_scan_texts() {
+        let mut parser = CodeParser::new(SupportedLanguage::Python).unwrap();
+        let tree = parser.parse(BILLING).unwrap();
+        let kinds: Vec<(TextKind, usize)> = scan_texts(&tree, BILLING, SupportedLanguage::Python)
+            .into_iter()
+            .map(|span| (span.kind, span.line))
+            .collect();
+        assert_eq!(
+            kinds,
+            vec![
+                (TextKind::Doc, 1),
+                (TextKind::Doc, 5),
+                (TextKind::Comment, 8),
+                (TextKind::String, 13),
+            ]
+        );
+
+        let mut parser = CodeParser::new(SupportedLanguage::Go).unwrap();
+        let tree = parser.parse(SERVER).unwrap();
+        let spans = scan_texts(&tree, SERVER, SupportedLanguage::Go);
+        // The import path and the short "ok" literal are skipped
+        assert_eq!(spans.len(), 1);
+        assert_eq!(spans[0].kind, TextKind::Comment);
+    }
+
+    #[test]
+    fn test_sync_and_search() {
+        let dir = tempfile::tempdir().unwrap();
+        std::fs::write(dir.path().join("billing.py"), BILLING).unwrap();
+        std::fs::write(dir.path().join("server.go"), SERVER).unwrap();
+        let graph = GraphBuilder::new_with_embedded_queries()
+            .build_from_directory(dir.path())
+            .unwrap();
+
+        let mut index = TextIndex::in_memory().unwrap();
+        let stats = index.sync(dir.path(), &graph).unwrap();
+        assert_eq!(stats.files_indexed, 2);
+        assert_eq!(stats.entries, 5);
+
+        let discounts = index.search("discount", &[], 10).unwrap();
+        assert_eq!(discounts.len(), 1);
+        assert_eq!(discounts[0].node_id, "billing.py:Invoice:total");
+        assert_eq!(discounts[0].kind, TextKind::Comment);
+
+        let health = index.search("health check*", &[], 10).unwrap();
+        assert_eq!(health[0].node_id, "server.go:Handle");
+        assert_eq!(health[0].kind, TextKind::Doc);
+
+        let docs = index.search("invoice", &[TextKind::Doc], 10).unwrap();
+        assert_eq!(docs.len(), 1);
+        assert_eq!(docs[0].node_id, "billing.py:Invoice");
+        let strings = index.search("gateway", &[TextKind::String], 10).unwrap();
+        assert_eq!(strings[0].node_id, "billing.py:send");
+        assert!(index
+            .search("\"unbalanced ( query", &[], 10)
+            .unwrap()
+            .is_empty());
+
+        // Unchanged files are skipped; files gone from the graph are dropped
+        let stats = index.sync(dir.path(), &graph).unwrap();
+        assert_eq!(stats.files_unchanged, 2);
+        assert_eq!(index.entry_count().unwrap(), 5);
+        let stats = index.sync(dir.path(), &PetCodeGraph::new()).unwrap();
+        assert_eq!(stats.files_removed, 2);
+        assert_eq!(index.entry_count().unwrap(), 0);
+    }
+
+    #[test]
+    fn test_open_persists() {
+        let dir = tempfile::tempdir().unwrap();
+        assert!(!TextIndex::exists(dir.path()));
+        TextIndex::open(dir.path()).unwrap();
+        assert!(TextIndex::exists(dir.path()));
+        let index = TextIndex::open(dir.path()).unwrap();
+        assert_eq!(index.entry_count().unwrap(), 0);
+        assert_eq!("Doc".parse::<TextKind>(), Ok(TextKind::Doc));
+    }
+}
@@ -3,21 +3,140 @@
 //! This crate provides the core functionality for code graph generation:
 //! - Tree-sitter AST parsing for multiple languages
 //! - Merkle tree-based change detection for incremental updates
+//! - Per-file extraction cache so rebuilds only re-parse changed files
+//! - Index plans: the files a build would parse, reuse from the cache, or
+//!   leave out (and why), with an estimated node count
+//! - Per-language file policies (include/exclude globs, target platform,
+//!   generated code)
+//! - Per-language extraction rules: disabled edge types and user query files
+//!   extending the built-in tree-sitter queries
+//! - Sharded indexing of monorepos, merged with cross-shard resolution
+//! - Streaming builds that write the graph to a sink as files are extracted
+//! - Build diagnostics (unparsable files, syntax errors, unresolved
+//!   references) showing where the graph is incomplete
+//! - Per-package and per-workspace symbol limits, leaving the symbols of
+//!   files over a limit out behind truncation markers
 //! - Graph schema and construction
+//! - Federation of several repositories into one graph, with Go imports
+//!   across them resolved to concrete edges
+//! - Optional type-checked Go resolution through the Go toolchain, merged
+//!   into name-based resolution
+//! - Stable symbol IDs that survive re-indexing and file moves, with an
+//!   alias table following renames and moves between packages
 //! - Tag parsing for declarative SCM queries
-//! - Incremental updates for efficient repository synchronization
+//! - Per-function complexity metrics (cyclomatic, cognitive)
+//! - Per-function control-flow summaries (branches, loops, early returns,
+//!   nesting, switch/select)
+//! - Per-function clone fingerprints for near-duplicate detection
+//! - CODEOWNERS ownership overlay on files and symbols
+//! - TODO/FIXME/HACK comment markers as nodes, with authors from git blame
+//! - Package documentation nodes aggregating Go package comments and READMEs
+//! - Primary author and last modifier of symbols from git blame
+//! - License (SPDX or well-known notice) and copyright detection from file
+//!   headers
+//! - Entry point detection (`main`, `TestMain`, HTTP/gRPC handlers, cron
+//!   jobs, queue consumers) tagging reachability roots
+//! - Go template linking (`html/template`, `text/template`): templates as
+//!   files using the fields they render, with mismatches as diagnostics
+//! - Cross-language linking (Go↔C through cgo, generated Go→`.proto`
+//!   definitions) into one connected graph
+//! - Makefile and Dockerfile build targets linked to the Go binaries they
+//!   build
+//! - String-literal references (registry keys, subtests, route paths) as
+//!   weak `REFERS_BY_NAME` edges
+//! - Go interfaces with the same or a subset method set across packages as
+//!   `EQUIVALENT_TO` and `SUBSET_OF` edges
+//! - Type usage weights: uses of each type in signatures, fields, and
+//!   function bodies, on type nodes and on the edges depending on them
+//! - Config-driven attribute enrichment (e.g., `layer=domain`) with filters
+//! - Monorepo project discovery (`go.mod`, `Cargo.toml`, `package.json`),
+//!   tagging every node with its `project`
+//! - Model-written symbol summaries, cached by content hash
+//! - Go test coverage overlay from cover profiles
+//! - Per-symbol churn (commits, authors, recency) from git history
+//! - Graph export (JSON, DOT, Mermaid, GraphML), with size budgets that keep
+//!   the most connected nodes and collapse the rest into aggregates
+//! - Cursor pagination of long result lists, stable across index rebuilds
+//! - Index provenance (tool, grammar, and query versions, indexed commit,
+//!   configuration hash) carried by exports, archives, and servers
+//! - Permalink URLs of nodes on code hosts (GitHub, GitLab, Bitbucket, or a
+//!   custom template) at the indexed commit
+//! - Index validation (dangling edges, duplicate IDs, schema version, spans)
+//! - Indexing benchmarks with per-language and per-file-size throughput
+//! - Tags files for editors (ctags, etags)
+//! - Single-file parse dumps (syntax tree, query captures, nodes, edges,
+//!   and references) for debugging extraction
+//! - Subprocess plugins for custom extractors and analyses (JSON-RPC)
+//! - Incremental updates for efficient repository synchronization (`storage`
+//!   feature, on by default)
+//! - Memory-mapped graph store for graphs larger than RAM (`storage`)
+//! - Compressed single-file graph archives with random-access reads (`storage`)
+//! - Full-text index of doc comments, comments, and string literals linked to
+//!   their symbols (`storage`)
+//! - Whole-graph analyses (shortest paths, interface implementers, dead code,
+//!   metrics, hotspots, API surface, graph diffs, change impact, clones,
+//!   index stats)
 
 // Implemented modules
+pub mod aliases;
+pub mod analysis;
+#[cfg(feature = "storage")]
+pub mod archive;
+pub mod bench;
+pub mod blame;
+pub mod build_targets;
 pub mod builder;
+pub mod churn;
+pub mod codeowners;
+pub mod complexity;
+pub mod control_flow;
+pub mod coverage;
+pub mod cross_language;
+pub mod ctags;
+pub mod diagnostics;
 pub mod discovery;
 pub mod embedded_queries;
+pub mod enrichment;
+pub mod entry_points;
+pub mod export;
+pub mod extraction_cache;
+pub mod extraction_rules;
+pub mod federation;
+pub mod file_policy;
+pub mod fingerprint;
+pub mod go_types;
 pub mod graph;
+#[cfg(feature = "storage")]
 pub mod incremental;
+#[cfg(feature = "storage")]
 pub mod lazy;
+pub mod license;
+pub mod limits;
 pub mod manifest;
+pub mod markers;
 pub mod merkle;
+#[cfg(feature = "storage")]
+pub mod mmap;
+pub mod package_docs;
+pub mod pagination;
+pub mod parse_dump;
 pub mod parser;
+pub mod plan;
+pub mod plugin;
+pub mod projects;
+pub mod provenance;
+pub mod shard;
+pub mod source_links;
+pub mod stream;
+pub mod string_refs;
+pub mod summaries;
+pub mod symbol_id;
 pub mod tags;
+pub mod templates;
+#[cfg(test)]
+pub(crate) mod test_support;
+pub mod type_usage;
+pub mod validate;
 
 // Embedded queries re-exports
 pub use embedded_queries::{get_query, has_embedded_query, supported_languages};
@@ -30,19 +149,138 @@ pub use graph::{
 pub use merkle::{compute_file_hash, ChangeSet, ExclusionFilter, MerkleTreeManager, TreeStats};
 pub use parser::{
     generate_node_id, parse_node_id, CodeParser, ContainmentContext, ContainmentEntry,
-    ExtractedTag, ManifestLanguage, MetadataExtractor, ParserError, QueryManager,
+    ExtractedSource, ExtractedTag, ManifestLanguage, MetadataExtractor, ParserError, QueryManager,
     SupportedLanguage, TagExtractor,
 };
 pub use tags::{parse_tag_string, TagCategory, TagParseError, TagParseResult};
 
 // Builder re-exports
 pub use builder::{
-    BuilderConfig, BuilderError, ComponentBuilder, DiscoveredComponent, GraphBuilder,
+    BuildPhase, BuildProgress, BuildStats, BuilderConfig, BuilderError, ComponentBuilder,
+    DiscoveredComponent, FileReferences, GraphBuilder, ProgressCallback,
 };
 
 // Incremental updater re-exports
+#[cfg(feature = "storage")]
 pub use incremental::{IncrementalUpdater, UpdateResult, UpdaterError};
 
+// Memory-mapped graph store re-exports
+#[cfg(feature = "storage")]
+pub use mmap::{MmapGraph, MmapGraphError};
+
+// Graph archive re-exports
+#[cfg(feature = "storage")]
+pub use archive::{ArchiveError, ArchiveIndex, GraphArchive};
+
+// Extraction cache re-exports
+pub use extraction_cache::ExtractionCache;
+
+// Extraction rule re-exports
+pub use extraction_rules::{remove_disabled_edges, ExtractionRules};
+
+// File policy re-exports
+pub use file_policy::{FilePolicy, LanguageRules, PolicyExclusion};
+
+// Index plan re-exports
+pub use plan::{plan_workspace, Exclusion, FileAction, IndexPlan, PlannedFile};
+
+// Shard re-exports
+pub use shard::{merge_shards, GraphShard, ShardError};
+
+// Benchmark re-exports
+pub use bench::{run_benchmark, BenchReport};
+
+// Diagnostics re-exports
+pub use diagnostics::{Diagnostic, DiagnosticKind, Diagnostics, Severity};
+
+// Streaming build re-exports
+pub use stream::{GraphSink, JsonLinesSink, NodePatch, StreamStats};
+
+// Symbol ID re-exports
+pub use symbol_id::{is_symbol_id, symbol_id};
+
+// Symbol alias re-exports
+pub use aliases::{detect_aliases, AliasOptions, AliasReason, AliasTable, SymbolAlias};
+
+// Federation re-exports
+pub use federation::{federate, FederatedRepo, FederationError, FederationReport};
+
+// CODEOWNERS re-exports
+pub use codeowners::CodeOwners;
+
+// License re-exports
+pub use license::LicenseHeader;
+
+// Symbol limit re-exports
+pub use limits::{enforce_limits, SymbolLimits, Truncation, TruncationLimit};
+
+// Blame re-exports
+pub use blame::{attach_symbol_authors, BlameLine};
+
+// Marker re-exports
+pub use markers::{Marker, MarkerKind};
+
+// Package documentation re-exports
+pub use package_docs::{attach_package_docs, package_doc};
+
+// Attribute enrichment re-exports
+pub use enrichment::{AttributeFilter, AttributeRule, Enrichment};
+pub use projects::{attach_projects, Project, ProjectKind, ProjectResolver};
+
+// Entry point re-exports
+pub use entry_points::{tag_entry_points, EntryPointKind};
+
+// Template linking re-exports
+pub use templates::{link_templates, TemplateLinks};
+
+// Cross-language linking re-exports
+pub use cross_language::{link_cross_language, CrossLanguageLinks};
+
+// Build target re-exports
+pub use build_targets::{link_build_targets, BuildFileKind, BuildTargetLinks};
+
+// String-literal reference re-exports
+pub use string_refs::{link_string_references, StringLinks};
+
+// Type usage re-exports
+pub use type_usage::{weigh_type_usage, TypeUsageStats};
+
+// Go type checking re-exports
+pub use go_types::{GoTypeCheck, GoTypesError, MergeStats, TypedReference};
+
+// Summary re-exports
+pub use summaries::{SummaryCache, SummaryOptions, SummaryRequest};
+
+// Coverage re-exports
+pub use coverage::{CoverBlock, CoverProfile, CoverageError};
+
+// Churn re-exports
+pub use churn::{ChurnError, GitHistory, SymbolChurn};
+
+// Plugin re-exports
+pub use plugin::{apply_extractors, Capabilities, ExtractStats, Finding, Plugin, PluginError};
+
+// Export re-exports
+pub use export::{export_graph, ExportBudget, ExportFilter, ExportFormat, SampledGraph};
+
+// Provenance re-exports
+pub use provenance::{GrammarVersion, Provenance};
+
+// Source link re-exports
+pub use source_links::{attach_source_links, LinkTemplate, LinkTemplateError};
+
+// Validation re-exports
+pub use validate::{validate, Check, IndexContents, Problem, ValidationReport};
+
+// Tags file re-exports
+pub use ctags::{write_tags, TagsFormat};
+
+// Pagination re-exports
+pub use pagination::{paginate, Cursor, InvalidCursor, Page, PageRequest};
+
+// Parse dump re-exports
+pub use parse_dump::{dump_source, ParseDump};
+
 // Discovery re-exports
 pub use discovery::{DiscoveredRoot, DiscoveryConfig, DiscoveryError, RootDiscovery, RootType};
 
@@ -0,0 +1,342 @@
+//! License and Copyright Headers
+//!
+//! Detects the license of a source file from its header: an SPDX tag
+//! (`SPDX-License-Identifier: Apache-2.0 OR MIT`) or, failing that, the
+//! opening words of a well-known license notice, along with the copyright
+//! line. Builds record both as file attributes (`license`, `copyright`).
+//!
+//! [`conflict`] decides whether two license expressions can be combined in
+//! one package, from a short table of well-known incompatibilities (such as
+//! GPL-2.0-only with Apache-2.0). Licenses outside the table are assumed to
+//! be compatible.
+
+/// Lines at the top of a file searched for a header
+const HEADER_LINES: usize = 40;
+
+/// SPDX identifiers this module produces or knows incompatibilities of, in
+/// canonical case
+const KNOWN_LICENSES: &[&str] = &[
+    "0BSD",
+    "AGPL-3.0-only",
+    "AGPL-3.0-or-later",
+    "Apache-1.1",
+    "Apache-2.0",
+    "BSD-2-Clause",
+    "BSD-3-Clause",
+    "CDDL-1.0",
+    "CDDL-1.1",
+    "CPL-1.0",
+    "EPL-1.0",
+    "EPL-2.0",
+    "GPL-2.0-only",
+    "GPL-2.0-or-later",
+    "GPL-3.0-only",
+    "GPL-3.0-or-later",
+    "ISC",
+    "LGPL-2.1-only",
+    "LGPL-2.1-or-later",
+    "LGPL-3.0-only",
+    "LGPL-3.0-or-later",
+    "MIT",
+    "MPL-1.1",
+    "MPL-2.0",
+    "Unlicense",
+    "Zlib",
+];
+
+/// Licenses the GPL and AGPL cannot be combined with
+const GPL_INCOMPATIBLE: &[&str] = &[
+    "Apache-1.1",
+    "CDDL-1.0",
+    "CDDL-1.1",
+    "CPL-1.0",
+    "EPL-1.0",
+    "MPL-1.1",
+];
+
+/// The license and copyright found in a file header.
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub struct LicenseHeader {
+    /// SPDX license expression (e.g., "Apache-2.0", "MIT OR Apache-2.0")
+    pub license: Option<String>,
+    /// Copyright statement without the "Copyright (c)" prefix (e.g.,
+    /// "2024 Acme Inc.")
+    pub copyright: Option<String>,
+}
+
+/// Detect the license and copyright in the header of a source file.
+pub fn detect(source: &str) -> LicenseHeader {
+    let lines: Vec<&str> = source.lines().take(HEADER_LINES).collect();
+    let license = lines
+        .iter()
+        .find_map(|line| spdx_identifier(line))
+        .or_else(|| notice_license(&lines));
+    let copyright = lines.iter().find_map(|line| copyright_holder(line));
+    LicenseHeader { license, copyright }
+}
+
+/// The expression of an `SPDX-License-Identifier:` tag
+fn spdx_identifier(line: &str) -> Option<String> {
+    let (_, rest) = line.split_once("SPDX-License-Identifier:")?;
+    let expression = rest
+        .trim()
+        .trim_end_matches("*/")
+        .trim_end_matches("-->")
+        .trim_end_matches("*)")
+        .trim();
+    (!expression.is_empty()).then(|| expression.to_string())
+}
+
+/// The license of a well-known notice in the header
+fn notice_license(lines: &[&str]) -> Option<String> {
+    let text = lines
+        .iter()
+        .map(|line| strip_comment(line))
+        .collect::<Vec<_>>()
+        .join(" ")
+        .to_lowercase();
+    let text = text.split_whitespace().collect::<Vec<_>>().join(" ");
+
+    let id = if text.contains("licensed under the apache license, version 2.0") {
+        "Apache-2.0".to_string()
+    } else if text.contains("mozilla public license, v. 2.0") {
+        "MPL-2.0".to_string()
+    } else if text.contains("gnu affero general public license") {
+        gnu_license("AGPL", &text)
+    } else if text.contains("gnu lesser general public license") {
+        gnu_license("LGPL", &text)
+    } else if text.contains("gnu general public license") {
+        gnu_license("GPL", &text)
+    } else if text.contains("eclipse public license") {
+        if text.contains("v2.0") || text.contains("v 2.0") || text.contains("version 2.0") {
+            "EPL-2.0".to_string()
+        } else {
+            "EPL-1.0".to_string()
+        }
+    } else if text.contains("permission is hereby granted, free of charge") {
+        "MIT".to_string()
+    } else if text.contains(
+        "permission to use, copy, modify, and/or distribute this software for any purpose",
+    ) {
+        "ISC".to_string()
+    } else if text.contains("redistribution and use in source and binary forms") {
+        if text.contains("neither the name") {
+            "BSD-3-Clause".to_string()
+        } else {
+            "BSD-2-Clause".to_string()
+        }
+    } else if text
+        .contains("this is free and unencumbered software released into the public domain")
+    {
+        "Unlicense".to_string()
+    } else {
+        return None;
+    };
+    Some(id)
+}
+
+/// SPDX identifier of a GNU license notice, from its version and whether it
+/// allows any later version
+fn gnu_license(family: &str, text: &str) -> String {
+    let version = if text.contains("version 3") {
+        "3.0"
+    } else if text.contains("version 2.1") {
+        "2.1"
+    } else if text.contains("version 2") {
+        "2.0"
+    } else if family == "LGPL" {
+        "2.1"
+    } else {
+        "3.0"
+    };
+    let scope = if text.contains("any later version") {
+        "or-later"
+    } else {
+        "only"
+    };
+    format!("{}-{}-{}", family, version, scope)
+}
+
+/// The holder of a `Copyright (c) <year> <holder>` line
+fn copyright_holder(line: &str) -> Option<String> {
+    let body = strip_comment(line);
+    let lower = body.to_lowercase();
+    let rest = if lower.starts_with("copyright") {
+        &body["copyright".len()..]
+    } else if body.starts_with('©') {
+        body
+    } else {
+        return None;
+    };
+    let holder = rest
+        .trim_start_matches(|c: char| c.is_whitespace() || c == ':')
+        .trim_start_matches("(c)")
+        .trim_start_matches("(C)")
+        .trim_start_matches('©')
+        .trim()
+        .trim_end_matches("All rights reserved.")
+        .trim_end_matches(|c: char| c.is_whitespace() || c == ',');
+    // Requiring a year skips prose such as "copyright notice" in license text
+    holder
+        .contains(|c: char| c.is_ascii_digit())
+        .then(|| holder.to_string())
+}
+
+/// A header line without comment markers
+fn strip_comment(line: &str) -> &str {
+    line.trim_start_matches(|c: char| c.is_whitespace() || "/*#!-;%'".contains(c))
+        .trim_end()
+        .trim_end_matches("*/")
+        .trim_end_matches("-->")
+        .trim_end()
+}
+
+/// Why two license expressions cannot be combined in one package, or None
+/// if they can.
+///
+/// Expressions with `OR` are compatible when any choice of alternatives is;
+/// `AND` requires every license of the alternative, and `WITH` exceptions
+/// are ignored. Deprecated identifiers (`GPL-2.0`, `GPL-2.0+`) are read as
+/// their `-only` and `-or-later` forms.
+pub fn conflict(first: &str, second: &str) -> Option<String> {
+    let first = alternatives(first);
+    let second = alternatives(second);
+    let mut reason = None;
+    for a in &first {
+        for b in &second {
+            match a
+                .iter()
+                .flat_map(|x| b.iter().map(move |y| (x, y)))
+                .find_map(|(x, y)| license_conflict(x, y))
+            {
+                Some(found) => {
+                    reason.get_or_insert(found);
+                }
+                None => return None,
+            }
+        }
+    }
+    reason
+}
+
+/// The alternatives of a license expression, each the licenses required
+/// together
+fn alternatives(expression: &str) -> Vec<Vec<String>> {
+    let mut alternatives = vec![Vec::new()];
+    let spaced = expression.replace(['(', ')'], " ");
+    let mut tokens = spaced.split_whitespace();
+    while let Some(token) = tokens.next() {
+        if token.eq_ignore_ascii_case("or") {
+            alternatives.push(Vec::new());
+        } else if token.eq_ignore_ascii_case("with") {
+            tokens.next();
+        } else if !token.eq_ignore_ascii_case("and") {
+            if let Some(current) = alternatives.last_mut() {
+                current.push(normalize_id(token));
+            }
+        }
+    }
+    alternatives.retain(|licenses| !licenses.is_empty());
+    alternatives
+}
+
+/// Canonical form of an SPDX identifier
+pub fn normalize_id(id: &str) -> String {
+    let lower = id.to_ascii_lowercase();
+    for family in ["gpl", "lgpl", "agpl"] {
+        for version in ["2.0", "2.1", "3.0"] {
+            let base = format!("{}-{}", family, version);
+            let scope = if lower == base {
+                "only"
+            } else if lower == format!("{}+", base) {
+                "or-later"
+            } else {
+                continue;
+            };
+            return format!("{}-{}-{}", family.to_uppercase(), version, scope);
+        }
+    }
+    KNOWN_LICENSES
+        .iter()
+        .find(|known| known.eq_ignore_ascii_case(id))
+        .map_or_else(|| id.to_string(), |known| known.to_string())
+}
+
+/// Why two licenses cannot be combined, or None if they can
+fn license_conflict(a: &str, b: &str) -> Option<String> {
+    [(a, b), (b, a)]
+        .into_iter()
+        .find_map(|(x, y)| one_way_conflict(x, y))
+}
+
+fn one_way_conflict(x: &str, y: &str) -> Option<String> {
+    let version_3 = |id: &str| {
+        ["GPL-3.0", "LGPL-3.0", "AGPL-3.0"]
+            .iter()
+            .any(|prefix| id.starts_with(prefix))
+    };
+    if x == "GPL-2.0-only" && (y == "Apache-2.0" || version_3(y)) {
+        return Some(format!("{} code cannot be distributed with {} code", x, y));
+    }
+    let strong_copyleft = x.starts_with("GPL-") || x.starts_with("AGPL-");
+    if strong_copyleft && GPL_INCOMPATIBLE.contains(&y) {
+        return Some(format!("{} is incompatible with {}", y, x));
+    }
+    None
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_detect_spdx() {
+        let header = detect(
+            "// Copyright (c) 2024 Acme Inc. All rights reserved.\n// SPDX-License-Identifier: Apache-2.0 OR MIT\n\npackage main\n",
+        );
+        assert_eq!(header.license.as_deref(), Some("Apache-2.0 OR MIT"));
+        assert_eq!(header.copyright.as_deref(), Some("2024 Acme Inc."));
+
+        let css = detect("/* SPDX-License-Identifier: MPL-2.0 */\n");
+        assert_eq!(css.license.as_deref(), Some("MPL-2.0"));
+        assert_eq!(detect("package main\n"), LicenseHeader::default());
+    }
+
+    #[test]
+    fn test_detect_notice() {
+        let gpl = detect(
+            "# Copyright 2019 Jane Doe\n#\n# This program is free software; you can redistribute it and/or modify\n# it under the terms of the GNU General Public License as published by\n# the Free Software Foundation; version 2 of the License.\n",
+        );
+        assert_eq!(gpl.license.as_deref(), Some("GPL-2.0-only"));
+        assert_eq!(gpl.copyright.as_deref(), Some("2019 Jane Doe"));
+
+        let apache = detect(
+            "/*\n * Licensed under the Apache License, Version 2.0 (the \"License\");\n * you may not use this file except in compliance with the License.\n */\n",
+        );
+        assert_eq!(apache.license.as_deref(), Some("Apache-2.0"));
+
+        // The license text mentions copyright without a year
+        let mit = detect(
+            "// Permission is hereby granted, free of charge, to any person\n// The above copyright notice and this permission notice shall be included\n",
+        );
+        assert_eq!(mit.license.as_deref(), Some("MIT"));
+        assert_eq!(mit.copyright, None);
+    }
+
+    #[test]
+    fn test_conflict() {
+        assert!(conflict("GPL-2.0-only", "Apache-2.0").is_some());
+        assert!(conflict("GPL-2.0", "GPL-3.0-or-later").is_some());
+        assert!(conflict("Apache-2.0", "GPL-2.0-only").is_some());
+        assert!(conflict("GPL-3.0-only", "EPL-1.0").is_some());
+        assert!(conflict("GPL-2.0+", "Apache-2.0").is_none());
+        assert!(conflict("GPL-2.0-only", "MIT").is_none());
+        assert!(conflict("MIT", "Apache-2.0").is_none());
+        // One compatible alternative is enough
+        assert!(conflict("GPL-2.0-only", "Apache-2.0 OR MIT").is_none());
+        assert!(conflict("GPL-2.0-only", "(Apache-2.0 AND MIT)").is_some());
+        assert!(conflict("GPL-2.0-only WITH Classpath-exception-2.0", "apache-2.0").is_some());
+        assert_eq!(normalize_id("gpl-3.0+"), "GPL-3.0-or-later");
+        assert_eq!(normalize_id("mit"), "MIT");
+    }
+}
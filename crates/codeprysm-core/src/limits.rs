@@ -0,0 +1,353 @@
+//! Symbol Limits
+//!
+//! Caps on the number of symbols a build keeps, so pathological inputs
+//! (generated code with millions of symbols) degrade gracefully instead of
+//! growing the graph without bound:
+//!
+//! - **Per package**: symbols of one package (source directory)
+//! - **Per workspace**: symbols of the whole build
+//!
+//! Limits apply to whole files, in build order: a file whose symbols would
+//! take its package (or the workspace) over a limit keeps its file node but
+//! none of its symbols. Each package with omitted files gets a Data node of
+//! kind `truncation`, contained by its first omitted file, saying how many
+//! symbols were left out, and a warning diagnostic.
+
+use std::collections::{BTreeMap, HashMap, HashSet};
+
+use crate::analysis::package_of;
+use crate::diagnostics::Diagnostic;
+use crate::graph::{DataKind, EdgeData, Node, PetCodeGraph};
+use crate::parser::generate_node_id;
+
+/// Name of truncation marker nodes
+pub const TRUNCATION_NAME: &str = "<truncated>";
+
+/// Symbol limits of a build (None = unlimited).
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq)]
+pub struct SymbolLimits {
+    /// Maximum symbols per package
+    pub max_per_package: Option<usize>,
+    /// Maximum symbols in the whole build
+    pub max_total: Option<usize>,
+}
+
+impl SymbolLimits {
+    /// Whether no limit is set
+    pub fn is_unlimited(&self) -> bool {
+        self.max_per_package.is_none() && self.max_total.is_none()
+    }
+}
+
+/// The limit that caused a truncation.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum TruncationLimit {
+    /// The package's symbol limit
+    Package(usize),
+    /// The workspace's symbol limit
+    Workspace(usize),
+}
+
+impl TruncationLimit {
+    /// Human-readable description
+    pub fn describe(&self) -> String {
+        match self {
+            Self::Package(max) => format!("package limit of {} symbols reached", max),
+            Self::Workspace(max) => format!("workspace limit of {} symbols reached", max),
+        }
+    }
+}
+
+/// Symbols of a package left out of the graph.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct Truncation {
+    /// Package path
+    pub package: String,
+    /// Limit first reached
+    pub limit: TruncationLimit,
+    /// Files whose symbols were left out, in build order
+    pub files: Vec<String>,
+    /// Number of symbols left out
+    pub symbols: usize,
+}
+
+impl Truncation {
+    /// Warning diagnostic on the first omitted file
+    pub fn diagnostic(&self) -> Diagnostic {
+        Diagnostic::symbols_truncated(
+            &self.files[0],
+            self.files.len(),
+            self.symbols,
+            &format!(
+                "{} ({})",
+                self.limit.describe(),
+                package_name(&self.package)
+            ),
+        )
+    }
+
+    /// Marker node, declared in the first omitted file
+    pub fn node(&self) -> Node {
+        let file = self.files[0].clone();
+        Node::data(
+            generate_node_id(&file, &[], TRUNCATION_NAME, None),
+            package_name(&self.package).to_string(),
+            DataKind::Truncation,
+            None,
+            file,
+            1,
+            1,
+        )
+        .with_text(format!(
+            "{} symbol(s) in {} file(s) not indexed: {}",
+            self.symbols,
+            self.files.len(),
+            self.limit.describe()
+        ))
+    }
+}
+
+/// Symbols admitted so far, against the limits of a build.
+#[derive(Debug, Default)]
+pub struct SymbolBudget {
+    limits: SymbolLimits,
+    per_package: HashMap<String, usize>,
+    total: usize,
+    truncations: BTreeMap<String, Truncation>,
+}
+
+impl SymbolBudget {
+    /// Create an empty budget.
+    pub fn new(limits: SymbolLimits) -> Self {
+        Self {
+            limits,
+            ..Default::default()
+        }
+    }
+
+    /// Whether the budget admits everything
+    pub fn is_unlimited(&self) -> bool {
+        self.limits.is_unlimited()
+    }
+
+    /// Admit the `symbols` of `file` if they fit within the limits, or record
+    /// them as left out. Returns whether the file's symbols are kept.
+    pub fn admit(&mut self, file: &str, symbols: usize) -> bool {
+        let package = package_of(file);
+        let in_package = self.per_package.get(package).copied().unwrap_or(0);
+        let limit = match (self.limits.max_per_package, self.limits.max_total) {
+            (Some(max), _) if in_package + symbols > max => Some(TruncationLimit::Package(max)),
+            (_, Some(max)) if self.total + symbols > max => Some(TruncationLimit::Workspace(max)),
+            _ => None,
+        };
+        let Some(limit) = limit else {
+            *self.per_package.entry(package.to_string()).or_default() += symbols;
+            self.total += symbols;
+            return true;
+        };
+        let truncation = self
+            .truncations
+            .entry(package.to_string())
+            .or_insert_with(|| Truncation {
+                package: package.to_string(),
+                limit,
+                files: Vec::new(),
+                symbols: 0,
+            });
+        truncation.files.push(file.to_string());
+        truncation.symbols += symbols;
+        false
+    }
+
+    /// Packages with symbols left out, by package path
+    pub fn truncations(&self) -> impl Iterator<Item = &Truncation> {
+        self.truncations.values()
+    }
+
+    /// Consume the budget, returning its truncations by package path
+    pub fn into_truncations(self) -> Vec<Truncation> {
+        self.truncations.into_values().collect()
+    }
+}
+
+/// Check if a node counts toward the symbol limits
+pub fn is_symbol(node: &Node) -> bool {
+    !node.file.is_empty()
+        && !node.is_file()
+        && !node.is_repository()
+        && !node.is_workspace()
+        && !node.is_component()
+        && !node.is_truncation()
+}
+
+/// Apply symbol limits to a built graph, dropping the symbols of files over
+/// a limit (in path order).
+///
+/// The markers of packages truncated again are replaced; the others stay,
+/// as the files they describe are still without symbols until a full
+/// rebuild. Returns the new truncations, by package path.
+pub fn enforce_limits(graph: &mut PetCodeGraph, limits: &SymbolLimits) -> Vec<Truncation> {
+    if limits.is_unlimited() {
+        return Vec::new();
+    }
+
+    let mut symbols: BTreeMap<String, Vec<String>> = BTreeMap::new();
+    for node in graph.iter_nodes().filter(|node| is_symbol(node)) {
+        symbols
+            .entry(node.file.clone())
+            .or_default()
+            .push(node.id.clone());
+    }
+    let mut budget = SymbolBudget::new(*limits);
+    for (file, ids) in &symbols {
+        if !budget.admit(file, ids.len()) {
+            for id in ids {
+                graph.remove_node(id);
+            }
+        }
+    }
+
+    let truncations = budget.into_truncations();
+    let packages: HashSet<&str> = truncations.iter().map(|t| t.package.as_str()).collect();
+    let stale: Vec<String> = graph
+        .iter_nodes()
+        .filter(|node| node.is_truncation() && packages.contains(package_of(&node.file)))
+        .map(|node| node.id.clone())
+        .collect();
+    for id in stale {
+        graph.remove_node(&id);
+    }
+    attach_truncations(graph, &truncations);
+    truncations
+}
+
+/// Add the marker nodes of truncations to a graph, contained by their first
+/// omitted file if it is in the graph.
+pub fn attach_truncations(graph: &mut PetCodeGraph, truncations: &[Truncation]) {
+    for truncation in truncations {
+        let node = truncation.node();
+        let (id, file) = (node.id.clone(), node.file.clone());
+        graph.add_node(node);
+        if graph.contains_node(&file) {
+            graph.add_edge(&file, &id, EdgeData::contains());
+        }
+    }
+}
+
+/// Display name of a package path
+fn package_name(package: &str) -> &str {
+    if package.is_empty() {
+        "."
+    } else {
+        package
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::CallableKind;
+
+    #[test]
+    fn test_budget() {
+        let mut budget = SymbolBudget::new(SymbolLimits {
+            max_per_package: Some(10),
+            max_total: Some(15),
+        });
+        assert!(budget.admit("gen/a.go", 6));
+        assert!(budget.admit("gen/b.go", 4));
+        // The package is full, but smaller files elsewhere still fit
+        assert!(!budget.admit("gen/c.go", 1));
+        assert!(budget.admit("api/server.go", 5));
+        // The workspace is full
+        assert!(!budget.admit("cmd/main.go", 1));
+
+        let truncations: Vec<&Truncation> = budget.truncations().collect();
+        assert_eq!(truncations.len(), 2);
+        assert_eq!(truncations[0].package, "cmd");
+        assert_eq!(truncations[0].limit, TruncationLimit::Workspace(15));
+        assert_eq!(truncations[1].package, "gen");
+        assert_eq!(truncations[1].limit, TruncationLimit::Package(10));
+        assert_eq!(truncations[1].files, ["gen/c.go"]);
+
+        let diagnostic = truncations[1].diagnostic();
+        assert_eq!(diagnostic.file, "gen/c.go");
+        assert!(diagnostic.message.contains("package limit of 10 symbols"));
+
+        assert!(SymbolBudget::new(SymbolLimits::default()).admit("a.go", usize::MAX));
+    }
+
+    #[test]
+    fn test_enforce_limits() {
+        let mut graph = PetCodeGraph::new();
+        for file in ["gen/a.go", "gen/b.go", "main.go"] {
+            graph.add_node(Node::source_file(
+                file.to_string(),
+                file.to_string(),
+                String::new(),
+                10,
+            ));
+            for name in ["One", "Two"] {
+                let id = format!("{}:{}", file, name);
+                graph.add_node(Node::callable(
+                    id.clone(),
+                    name.to_string(),
+                    CallableKind::Function,
+                    file.to_string(),
+                    1,
+                    2,
+                ));
+                graph.add_edge(file, &id, EdgeData::contains());
+            }
+        }
+        let limits = SymbolLimits {
+            max_per_package: Some(3),
+            max_total: None,
+        };
+
+        let truncations = enforce_limits(&mut graph, &limits);
+        assert_eq!(truncations.len(), 1);
+        assert_eq!(truncations[0].files, ["gen/b.go"]);
+        assert_eq!(truncations[0].symbols, 2);
+        assert!(graph.get_node("gen/a.go:One").is_some());
+        assert!(graph.get_node("gen/b.go:One").is_none());
+        assert!(graph.get_node("gen/b.go").is_some());
+
+        let marker = graph.get_node("gen/b.go:<truncated>").unwrap();
+        assert!(marker.is_truncation());
+        assert_eq!(marker.name, "gen");
+        assert_eq!(graph.parent(&marker.id).unwrap().id, "gen/b.go");
+
+        // The marker outlives reruns, since gen/b.go still has no symbols
+        assert!(enforce_limits(&mut graph, &limits).is_empty());
+        assert!(graph.get_node("gen/b.go:<truncated>").is_some());
+
+        // Truncating the package again replaces it
+        graph.add_node(Node::callable(
+            "gen/c.go:Three".to_string(),
+            "Three".to_string(),
+            CallableKind::Function,
+            "gen/c.go".to_string(),
+            1,
+            2,
+        ));
+        graph.add_node(Node::callable(
+            "gen/a.go:Four".to_string(),
+            "Four".to_string(),
+            CallableKind::Function,
+            "gen/a.go".to_string(),
+            3,
+            4,
+        ));
+        let truncations = enforce_limits(&mut graph, &limits);
+        assert_eq!(truncations[0].files, ["gen/c.go"]);
+        assert!(graph.get_node("gen/b.go:<truncated>").is_none());
+        assert_eq!(
+            graph
+                .iter_nodes()
+                .filter(|node| node.is_truncation())
+                .count(),
+            1
+        );
+    }
+}
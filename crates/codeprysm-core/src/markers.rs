@@ -0,0 +1,259 @@
+//! Tech-Debt Markers
+//!
+//! Finds `TODO`, `FIXME`, and `HACK` comments so tech debt can be queried
+//! alongside the structural graph. Builds add each marker as a Data node of
+//! kind `marker` (subtype `todo`, `fixme`, or `hack`) holding the comment
+//! text, contained by the innermost enclosing symbol (or the file).
+//!
+//! A marker must start a comment line, as in `// TODO: ...`, `# FIXME(alice)
+//! ...`, or `* HACK - ...`; the words in running prose are ignored. In a git
+//! checkout, [`attach_authors`] records who last changed each marker's line,
+//! from `git blame`.
+
+use std::collections::HashMap;
+use std::fmt;
+use std::path::Path;
+use std::str::FromStr;
+
+use serde::{Deserialize, Serialize};
+use tree_sitter::{Node as TsNode, Tree};
+
+use crate::blame::blame_file;
+use crate::graph::{DataKind, Node, PetCodeGraph};
+use crate::parser::generate_node_id;
+
+/// Kind of tech-debt marker.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, PartialOrd, Ord, Hash, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum MarkerKind {
+    /// Work left to do
+    Todo,
+    /// Known bug
+    Fixme,
+    /// Workaround to clean up
+    Hack,
+}
+
+impl MarkerKind {
+    /// All marker kinds
+    pub const ALL: [MarkerKind; 3] = [MarkerKind::Todo, MarkerKind::Fixme, MarkerKind::Hack];
+
+    /// Subtype of marker nodes of this kind
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            Self::Todo => "todo",
+            Self::Fixme => "fixme",
+            Self::Hack => "hack",
+        }
+    }
+
+    /// The word as written in comments
+    pub fn keyword(&self) -> &'static str {
+        match self {
+            Self::Todo => "TODO",
+            Self::Fixme => "FIXME",
+            Self::Hack => "HACK",
+        }
+    }
+}
+
+impl fmt::Display for MarkerKind {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        f.pad(self.keyword())
+    }
+}
+
+impl FromStr for MarkerKind {
+    type Err = String;
+
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        Self::ALL
+            .into_iter()
+            .find(|kind| kind.as_str().eq_ignore_ascii_case(s))
+            .ok_or_else(|| format!("Unknown marker '{}': expected todo, fixme, or hack", s))
+    }
+}
+
+/// A marker found in a comment.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct Marker {
+    /// Kind of marker
+    pub kind: MarkerKind,
+    /// Line of the marker (1-indexed)
+    pub line: usize,
+    /// Comment text after the keyword (e.g., "(alice): handle timeouts")
+    pub text: String,
+}
+
+impl Marker {
+    /// ID of the marker's node (e.g., "api/server.go:<todo>:42")
+    pub fn node_id(&self, file: &str) -> String {
+        generate_node_id(
+            file,
+            &[],
+            &format!("<{}>", self.kind.as_str()),
+            Some(self.line),
+        )
+    }
+
+    /// The marker's node in `file`
+    pub fn to_node(&self, file: &str) -> Node {
+        Node::data(
+            self.node_id(file),
+            self.kind.keyword().to_string(),
+            DataKind::Marker,
+            Some(self.kind.as_str().to_string()),
+            file.to_string(),
+            self.line,
+            self.line,
+        )
+        .with_text(self.text.clone())
+    }
+}
+
+/// Find the markers in the comments of a parsed file, in source order.
+pub fn scan(tree: &Tree, source: &str) -> Vec<Marker> {
+    let mut markers = Vec::new();
+    let mut stack = vec![tree.root_node()];
+    while let Some(node) = stack.pop() {
+        if node.kind().contains("comment") {
+            let text = &source[node.byte_range()];
+            let first_line = node.start_position().row + 1;
+            markers.extend(text.lines().enumerate().filter_map(|(offset, line)| {
+                parse_line(line).map(|(kind, text)| Marker {
+                    kind,
+                    line: first_line + offset,
+                    text,
+                })
+            }));
+            continue;
+        }
+        let mut cursor = node.walk();
+        let children: Vec<TsNode> = node.children(&mut cursor).collect();
+        stack.extend(children.into_iter().rev());
+    }
+    markers
+}
+
+/// Parse a comment line starting with a marker into its kind and text
+fn parse_line(line: &str) -> Option<(MarkerKind, String)> {
+    let body = line.trim_start_matches(|c: char| c.is_whitespace() || "/*#!-;".contains(c));
+    MarkerKind::ALL.into_iter().find_map(|kind| {
+        let rest = body.strip_prefix(kind.keyword())?;
+        if rest.starts_with(|c: char| c.is_alphanumeric() || c == '_') {
+            return None;
+        }
+        let text = rest
+            .trim_start_matches(|c: char| c == ':' || c == '-' || c.is_whitespace())
+            .trim_end()
+            .trim_end_matches("*/")
+            .trim_end();
+        Some((kind, text.to_string()))
+    })
+}
+
+/// Record the author of each marker node without one, from `git blame` of
+/// the repository at `root`.
+///
+/// Returns the number of markers attributed. Does nothing outside a git
+/// checkout; lines not committed yet are left without an author.
+pub fn attach_authors<'a>(root: &Path, nodes: impl IntoIterator<Item = &'a mut Node>) -> usize {
+    if !root.join(".git").exists() {
+        return 0;
+    }
+    let mut by_file: HashMap<String, Vec<&mut Node>> = HashMap::new();
+    for node in nodes {
+        if node.is_marker() && node.metadata.author.is_none() {
+            by_file.entry(node.file.clone()).or_default().push(node);
+        }
+    }
+
+    let mut attributed = 0;
+    for (file, nodes) in by_file {
+        let Some(lines) = blame_file(root, &file) else {
+            continue;
+        };
+        for node in nodes {
+            if let Some(line) = lines.get(&node.line) {
+                node.metadata.author = Some(line.author.clone());
+                attributed += 1;
+            }
+        }
+    }
+    attributed
+}
+
+/// Record the author of each marker in `graph` without one; see
+/// [`attach_authors`].
+pub fn attach_graph_authors(root: &Path, graph: &mut PetCodeGraph) -> usize {
+    attach_authors(root, graph.inner_mut().node_weights_mut())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::parser::{CodeParser, SupportedLanguage};
+
+    fn markers(language: SupportedLanguage, source: &str) -> Vec<Marker> {
+        let tree = CodeParser::new(language).unwrap().parse(source).unwrap();
+        scan(&tree, source)
+    }
+
+    #[test]
+    fn test_scan_comments() {
+        let source = r#"package main
+
+// TODO(alice): handle timeouts
+func main() {
+	s := "TODO: not a comment"
+	/* FIXME - leaks the connection */
+	// The TODO list is long
+	// HACKS are not markers
+	// HACK
+}
+"#;
+        let found = markers(SupportedLanguage::Go, source);
+        assert_eq!(
+            found,
+            vec![
+                Marker {
+                    kind: MarkerKind::Todo,
+                    line: 3,
+                    text: "(alice): handle timeouts".to_string()
+                },
+                Marker {
+                    kind: MarkerKind::Fixme,
+                    line: 6,
+                    text: "leaks the connection".to_string()
+                },
+                Marker {
+                    kind: MarkerKind::Hack,
+                    line: 9,
+                    text: String::new()
+                },
+            ]
+        );
+
+        let python = markers(
+            SupportedLanguage::Python,
+            "def f():\n    # TODO: cache this\n    return 1\n",
+        );
+        assert_eq!(python[0].line, 2);
+        assert_eq!(python[0].text, "cache this");
+    }
+
+    #[test]
+    fn test_marker_node() {
+        let marker = Marker {
+            kind: MarkerKind::Fixme,
+            line: 7,
+            text: "retry".to_string(),
+        };
+        let node = marker.to_node("api/server.go");
+        assert_eq!(node.id, "api/server.go:<fixme>:7");
+        assert!(node.is_marker());
+        assert_eq!(node.subtype.as_deref(), Some("fixme"));
+        assert_eq!(node.text.as_deref(), Some("retry"));
+        assert_eq!("Hack".parse::<MarkerKind>(), Ok(MarkerKind::Hack));
+    }
+}
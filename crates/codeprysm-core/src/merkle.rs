@@ -269,6 +269,11 @@ impl MerkleTreeManager {
         Self { exclusion_filter }
     }
 
+    /// Get the exclusion filter.
+    pub fn exclusion_filter(&self) -> &ExclusionFilter {
+        &self.exclusion_filter
+    }
+
     /// Build a Merkle tree for the given repository.
     ///
     /// Uses rayon for parallel file hashing to maximize performance.
@@ -344,6 +349,7 @@ impl MerkleTreeManager {
             .git_ignore(true) // Respect .gitignore
             .git_global(true) // Respect global gitignore
             .git_exclude(true) // Respect .git/info/exclude
+            .require_git(false) // Respect .gitignore outside git repositories too
             .add_custom_ignore_filename(".codeprysmignore") // Respect .codeprysmignore
             .build();
 
@@ -528,6 +534,12 @@ pub fn compute_file_hash(file_path: &Path) -> std::io::Result<String> {
     })
 }
 
+/// Compute the SHA-256 hash of in-memory content, matching
+/// [`compute_file_hash`] for a file with the same bytes.
+pub fn compute_content_hash(content: &[u8]) -> String {
+    format!("{:x}", Sha256::digest(content))
+}
+
 fn hash_file(file_path: &Path) -> Option<String> {
     let file = match File::open(file_path) {
         Ok(f) => f,
@@ -614,6 +626,10 @@ mod tests {
             hash.unwrap(),
             "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
         );
+        assert_eq!(
+            compute_content_hash(b"hello world"),
+            "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
+        );
     }
 
     #[test]
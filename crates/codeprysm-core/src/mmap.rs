@@ -0,0 +1,870 @@
+//! Memory-Mapped Graph Store
+//!
+//! A read-only, single-file graph format that queries run against directly
+//! through a memory map, for graphs too large to materialize in memory. Only
+//! the pages a query touches are read, and the operating system evicts them
+//! under memory pressure.
+//!
+//! # Layout
+//!
+//! All integers are little-endian. Strings (IDs, names, kinds, file paths,
+//! identifiers) are interned once in a string table; records refer to them by
+//! index, with `u32::MAX` for none.
+//!
+//! ```text
+//! header          magic, version, counts, section offsets
+//! string offsets  (strings + 1) x u64 into string data
+//! string data     UTF-8 bytes
+//! nodes           fixed-size records, sorted by ID
+//! out index       (nodes + 1) x u32 into out edges
+//! out edges       fixed-size records, grouped by source node
+//! in index        (nodes + 1) x u32 into in edges
+//! in edges        (source node, out edge) pairs, grouped by target node
+//! name order      node indices sorted by name
+//! ```
+//!
+//! Nodes sorted by ID make ID lookups a binary search; the out and in
+//! indexes are adjacency lists in compressed sparse row form. Node text,
+//! metadata, and hashes, and edge dependency and clone metadata, are stored
+//! as JSON strings and only decoded by [`NodeRef::to_node`] and
+//! [`EdgeRef::to_edge`].
+
+use std::cmp::Ordering;
+use std::collections::HashMap;
+use std::fs::File;
+use std::io::{BufWriter, Write};
+use std::path::Path;
+
+use memmap2::Mmap;
+use serde::{Deserialize, Serialize};
+use thiserror::Error;
+
+use crate::graph::{Edge, EdgeType, Node, NodeMetadata, NodeType, PetCodeGraph};
+
+/// File magic
+const MAGIC: &[u8; 8] = b"CPRYSMG\0";
+
+/// Format version, bumped on incompatible layout changes
+pub const MMAP_GRAPH_VERSION: u32 = 1;
+
+/// Conventional file name of the store in the index directory
+pub const MMAP_GRAPH_FILE: &str = "graph.cpg";
+
+/// Index meaning "none" in records
+const NONE: u32 = u32::MAX;
+
+/// Number of sections after the header
+const SECTIONS: usize = 8;
+const STRING_OFFSETS: usize = 0;
+const STRING_DATA: usize = 1;
+const NODES: usize = 2;
+const OUT_INDEX: usize = 3;
+const OUT_EDGES: usize = 4;
+const IN_INDEX: usize = 5;
+const IN_EDGES: usize = 6;
+const NAME_ORDER: usize = 7;
+
+/// Magic, version, node/edge/string counts, section offsets
+const HEADER_SIZE: usize = 8 + 4 * 4 + SECTIONS * 8;
+/// id, name, type, kind, subtype, file, line, end line, extra
+const NODE_SIZE: usize = 9 * 4;
+/// target, type, ref line, ident, extra
+const EDGE_SIZE: usize = 5 * 4;
+/// source, out edge
+const IN_EDGE_SIZE: usize = 2 * 4;
+
+/// Errors that can occur reading or writing a memory-mapped graph.
+#[derive(Debug, Error)]
+pub enum MmapGraphError {
+    /// IO error
+    #[error("IO error: {0}")]
+    Io(#[from] std::io::Error),
+
+    /// The file is not a graph store or is truncated
+    #[error("Invalid graph store: {0}")]
+    Invalid(String),
+
+    /// The file was written by an incompatible version
+    #[error("Unsupported graph store version {found} (expected {expected})")]
+    Version { found: u32, expected: u32 },
+}
+
+// ============================================================================
+// Writing
+// ============================================================================
+
+/// Node fields decoded only on demand
+#[derive(Default, Serialize, Deserialize)]
+struct NodeExtra {
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    text: Option<String>,
+    #[serde(default, skip_serializing_if = "NodeMetadata::is_empty")]
+    metadata: NodeMetadata,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    hash: Option<String>,
+}
+
+/// Edge fields decoded only on demand
+#[derive(Default, Serialize, Deserialize)]
+struct EdgeExtra {
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    version_spec: Option<String>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    is_dev_dependency: Option<bool>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    similarity: Option<u32>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    weight: Option<u32>,
+}
+
+/// String table under construction
+#[derive(Default)]
+struct Interner {
+    index: HashMap<String, u32>,
+    offsets: Vec<u64>,
+    data: Vec<u8>,
+}
+
+impl Interner {
+    fn intern(&mut self, s: &str) -> u32 {
+        if let Some(&index) = self.index.get(s) {
+            return index;
+        }
+        let index = self.offsets.len() as u32;
+        self.offsets.push(self.data.len() as u64);
+        self.data.extend_from_slice(s.as_bytes());
+        self.index.insert(s.to_string(), index);
+        index
+    }
+
+    fn intern_opt(&mut self, s: Option<&str>) -> u32 {
+        s.map_or(NONE, |s| self.intern(s))
+    }
+}
+
+fn node_type_code(node_type: NodeType) -> u32 {
+    match node_type {
+        NodeType::Container => 0,
+        NodeType::Callable => 1,
+        NodeType::Data => 2,
+    }
+}
+
+fn node_type_from_code(code: u32) -> NodeType {
+    match code {
+        1 => NodeType::Callable,
+        2 => NodeType::Data,
+        _ => NodeType::Container,
+    }
+}
+
+fn edge_type_code(edge_type: EdgeType) -> u32 {
+    match edge_type {
+        EdgeType::Contains => 0,
+        EdgeType::Uses => 1,
+        EdgeType::Defines => 2,
+        EdgeType::DependsOn => 3,
+        EdgeType::CloneOf => 4,
+        EdgeType::DefinedIn => 5,
+        EdgeType::CrossLanguage => 6,
+        EdgeType::RefersByName => 7,
+        EdgeType::EquivalentTo => 8,
+        EdgeType::SubsetOf => 9,
+    }
+}
+
+fn edge_type_from_code(code: u32) -> EdgeType {
+    match code {
+        1 => EdgeType::Uses,
+        2 => EdgeType::Defines,
+        3 => EdgeType::DependsOn,
+        4 => EdgeType::CloneOf,
+        5 => EdgeType::DefinedIn,
+        6 => EdgeType::CrossLanguage,
+        7 => EdgeType::RefersByName,
+        8 => EdgeType::EquivalentTo,
+        9 => EdgeType::SubsetOf,
+        _ => EdgeType::Contains,
+    }
+}
+
+fn put_u32(buf: &mut Vec<u8>, value: u32) {
+    buf.extend_from_slice(&value.to_le_bytes());
+}
+
+fn opt_u32(value: Option<usize>) -> u32 {
+    value.map_or(NONE, |v| v as u32)
+}
+
+/// Encode a graph in the store layout.
+fn encode(graph: &PetCodeGraph) -> Vec<Vec<u8>> {
+    let mut nodes: Vec<&Node> = graph.iter_nodes().collect();
+    nodes.sort_by(|a, b| a.id.cmp(&b.id));
+    let index_of = |id: &str| nodes.binary_search_by(|n| n.id.as_str().cmp(id)).ok();
+
+    let mut strings = Interner::default();
+    let mut node_records = Vec::with_capacity(nodes.len() * NODE_SIZE);
+    let mut out_index = Vec::with_capacity((nodes.len() + 1) * 4);
+    let mut out_edges = Vec::new();
+    // (target, source, out edge) of every edge, for the in index
+    let mut incoming: Vec<(u32, u32, u32)> = Vec::new();
+
+    for (index, node) in nodes.iter().enumerate() {
+        let extra = NodeExtra {
+            text: node.text.clone(),
+            metadata: node.metadata.clone(),
+            hash: node.hash.clone(),
+        };
+        let extra = if extra.text.is_none() && extra.metadata.is_empty() && extra.hash.is_none() {
+            NONE
+        } else {
+            strings.intern(&serde_json::to_string(&extra).unwrap_or_default())
+        };
+
+        put_u32(&mut node_records, strings.intern(&node.id));
+        put_u32(&mut node_records, strings.intern(&node.name));
+        put_u32(&mut node_records, node_type_code(node.node_type));
+        put_u32(&mut node_records, strings.intern_opt(node.kind.as_deref()));
+        put_u32(
+            &mut node_records,
+            strings.intern_opt(node.subtype.as_deref()),
+        );
+        put_u32(&mut node_records, strings.intern(&node.file));
+        put_u32(&mut node_records, node.line as u32);
+        put_u32(&mut node_records, node.end_line as u32);
+        put_u32(&mut node_records, extra);
+
+        let mut edges: Vec<(u32, _)> = graph
+            .outgoing_edges(&node.id)
+            .filter_map(|(target, data)| Some((index_of(&target.id)? as u32, data)))
+            .collect();
+        edges
+            .sort_by_key(|(target, data)| (*target, edge_type_code(data.edge_type), data.ref_line));
+
+        put_u32(&mut out_index, (out_edges.len() / EDGE_SIZE) as u32);
+        for (target, data) in edges {
+            let extra = EdgeExtra {
+                version_spec: data.version_spec.clone(),
+                is_dev_dependency: data.is_dev_dependency,
+                similarity: data.similarity,
+                weight: data.weight,
+            };
+            let extra = if extra.version_spec.is_none()
+                && extra.is_dev_dependency.is_none()
+                && extra.similarity.is_none()
+                && extra.weight.is_none()
+            {
+                NONE
+            } else {
+                strings.intern(&serde_json::to_string(&extra).unwrap_or_default())
+            };
+
+            incoming.push((target, index as u32, (out_edges.len() / EDGE_SIZE) as u32));
+            put_u32(&mut out_edges, target);
+            put_u32(&mut out_edges, edge_type_code(data.edge_type));
+            put_u32(&mut out_edges, opt_u32(data.ref_line));
+            put_u32(&mut out_edges, strings.intern_opt(data.ident.as_deref()));
+            put_u32(&mut out_edges, extra);
+        }
+    }
+    put_u32(&mut out_index, (out_edges.len() / EDGE_SIZE) as u32);
+
+    // Group incoming edges by target, keeping source order within a target
+    incoming.sort_by_key(|&(target, _, edge)| (target, edge));
+    let mut in_index = Vec::with_capacity((nodes.len() + 1) * 4);
+    let mut in_edges = Vec::with_capacity(incoming.len() * IN_EDGE_SIZE);
+    let mut next = 0;
+    for index in 0..nodes.len() as u32 {
+        put_u32(&mut in_index, next as u32);
+        while next < incoming.len() && incoming[next].0 == index {
+            put_u32(&mut in_edges, incoming[next].1);
+            put_u32(&mut in_edges, incoming[next].2);
+            next += 1;
+        }
+    }
+    put_u32(&mut in_index, next as u32);
+
+    let mut by_name: Vec<u32> = (0..nodes.len() as u32).collect();
+    by_name.sort_by(|&a, &b| nodes[a as usize].name.cmp(&nodes[b as usize].name));
+    let mut name_order = Vec::with_capacity(by_name.len() * 4);
+    for index in by_name {
+        put_u32(&mut name_order, index);
+    }
+
+    let data_len = strings.data.len() as u64;
+    let mut string_offsets = Vec::with_capacity((strings.offsets.len() + 1) * 8);
+    for offset in strings.offsets.iter().chain(std::iter::once(&data_len)) {
+        string_offsets.extend_from_slice(&offset.to_le_bytes());
+    }
+
+    let mut header = Vec::with_capacity(HEADER_SIZE);
+    header.extend_from_slice(MAGIC);
+    put_u32(&mut header, MMAP_GRAPH_VERSION);
+    put_u32(&mut header, nodes.len() as u32);
+    put_u32(&mut header, (out_edges.len() / EDGE_SIZE) as u32);
+    put_u32(&mut header, strings.offsets.len() as u32);
+
+    let sections = vec![
+        string_offsets,
+        strings.data,
+        node_records,
+        out_index,
+        out_edges,
+        in_index,
+        in_edges,
+        name_order,
+    ];
+    let mut offset = HEADER_SIZE as u64;
+    for section in &sections {
+        header.extend_from_slice(&offset.to_le_bytes());
+        offset += section.len() as u64;
+    }
+
+    let mut parts = vec![header];
+    parts.extend(sections);
+    parts
+}
+
+// ============================================================================
+// Reading
+// ============================================================================
+
+/// A graph store opened through a memory map.
+///
+/// ## Example
+///
+/// ```ignore
+/// use codeprysm_core::mmap::MmapGraph;
+///
+/// MmapGraph::write(&graph, Path::new(".codeprysm/graph.cpg"))?;
+/// let store = MmapGraph::open(Path::new(".codeprysm/graph.cpg"))?;
+///
+/// let node = store.get_node("src/api.py:handle").unwrap();
+/// for edge in node.incoming() {
+///     println!("used by {}", edge.source().id());
+/// }
+/// ```
+pub struct MmapGraph {
+    mmap: Mmap,
+    node_count: usize,
+    edge_count: usize,
+    string_count: usize,
+    sections: [usize; SECTIONS],
+}
+
+impl std::fmt::Debug for MmapGraph {
+    fn fmt(&self, f: &mut std::fmt::Formatter<'_>) -> std::fmt::Result {
+        f.debug_struct("MmapGraph")
+            .field("node_count", &self.node_count)
+            .field("edge_count", &self.edge_count)
+            .finish()
+    }
+}
+
+impl MmapGraph {
+    /// Write a graph as a store file.
+    ///
+    /// The file is written next to `path` and renamed into place, so stores
+    /// already open keep reading the previous version.
+    pub fn write(graph: &PetCodeGraph, path: &Path) -> Result<(), MmapGraphError> {
+        let tmp = path.with_extension("cpg.tmp");
+        {
+            let mut out = BufWriter::new(File::create(&tmp)?);
+            for part in encode(graph) {
+                out.write_all(&part)?;
+            }
+            out.flush()?;
+        }
+        std::fs::rename(&tmp, path)?;
+        Ok(())
+    }
+
+    /// Open a store file.
+    pub fn open(path: &Path) -> Result<Self, MmapGraphError> {
+        let file = File::open(path)?;
+        // SAFETY: store files are only replaced by rename, never modified in
+        // place, so the mapping stays valid while it is open.
+        let mmap = unsafe { Mmap::map(&file)? };
+
+        if mmap.len() < HEADER_SIZE || &mmap[..8] != MAGIC {
+            return Err(MmapGraphError::Invalid(
+                "missing graph store header".to_string(),
+            ));
+        }
+        let read = |offset: usize| u32::from_le_bytes(mmap[offset..offset + 4].try_into().unwrap());
+        let version = read(8);
+        if version != MMAP_GRAPH_VERSION {
+            return Err(MmapGraphError::Version {
+                found: version,
+                expected: MMAP_GRAPH_VERSION,
+            });
+        }
+        let node_count = read(12) as usize;
+        let edge_count = read(16) as usize;
+        let string_count = read(20) as usize;
+
+        let mut sections = [0; SECTIONS];
+        for (i, section) in sections.iter_mut().enumerate() {
+            let at = 24 + i * 8;
+            *section = u64::from_le_bytes(mmap[at..at + 8].try_into().unwrap()) as usize;
+        }
+
+        let store = Self {
+            mmap,
+            node_count,
+            edge_count,
+            string_count,
+            sections,
+        };
+        store.validate()?;
+        Ok(store)
+    }
+
+    /// Check that every section fits the file at the size its counts imply.
+    fn validate(&self) -> Result<(), MmapGraphError> {
+        let expected = [
+            (STRING_OFFSETS, (self.string_count + 1) * 8),
+            (NODES, self.node_count * NODE_SIZE),
+            (OUT_INDEX, (self.node_count + 1) * 4),
+            (OUT_EDGES, self.edge_count * EDGE_SIZE),
+            (IN_INDEX, (self.node_count + 1) * 4),
+            (IN_EDGES, self.edge_count * IN_EDGE_SIZE),
+            (NAME_ORDER, self.node_count * 4),
+        ];
+        for (section, size) in expected {
+            if self.sections[section].saturating_add(size) > self.mmap.len() {
+                return Err(MmapGraphError::Invalid(format!(
+                    "section {} is truncated",
+                    section
+                )));
+            }
+        }
+        let data_end = self.u64_at(self.sections[STRING_OFFSETS] + self.string_count * 8);
+        if self.sections[STRING_DATA].saturating_add(data_end as usize) > self.mmap.len() {
+            return Err(MmapGraphError::Invalid(
+                "string data is truncated".to_string(),
+            ));
+        }
+        Ok(())
+    }
+
+    fn u32_at(&self, offset: usize) -> u32 {
+        self.mmap
+            .get(offset..offset + 4)
+            .map_or(NONE, |b| u32::from_le_bytes(b.try_into().unwrap()))
+    }
+
+    fn u64_at(&self, offset: usize) -> u64 {
+        self.mmap
+            .get(offset..offset + 8)
+            .map_or(0, |b| u64::from_le_bytes(b.try_into().unwrap()))
+    }
+
+    /// Interned string by index
+    fn string(&self, index: u32) -> Option<&str> {
+        let index = index as usize;
+        if index >= self.string_count {
+            return None;
+        }
+        let at = self.sections[STRING_OFFSETS] + index * 8;
+        let start = self.sections[STRING_DATA] + self.u64_at(at) as usize;
+        let end = self.sections[STRING_DATA] + self.u64_at(at + 8) as usize;
+        std::str::from_utf8(self.mmap.get(start..end)?).ok()
+    }
+
+    /// Field `field` of node record `index`
+    fn node_field(&self, index: u32, field: usize) -> u32 {
+        self.u32_at(self.sections[NODES] + index as usize * NODE_SIZE + field * 4)
+    }
+
+    /// Number of nodes
+    pub fn node_count(&self) -> usize {
+        self.node_count
+    }
+
+    /// Number of edges
+    pub fn edge_count(&self) -> usize {
+        self.edge_count
+    }
+
+    /// Iterate over all nodes in ID order.
+    pub fn nodes(&self) -> impl Iterator<Item = NodeRef<'_>> {
+        (0..self.node_count as u32).map(move |index| NodeRef { store: self, index })
+    }
+
+    /// Look up a node by ID.
+    pub fn get_node(&self, id: &str) -> Option<NodeRef<'_>> {
+        let (mut low, mut high) = (0, self.node_count);
+        while low < high {
+            let mid = low + (high - low) / 2;
+            let node = NodeRef {
+                store: self,
+                index: mid as u32,
+            };
+            match node.id().cmp(id) {
+                Ordering::Equal => return Some(node),
+                Ordering::Less => low = mid + 1,
+                Ordering::Greater => high = mid,
+            }
+        }
+        None
+    }
+
+    /// Nodes with exactly the given name.
+    pub fn find_by_name<'a>(&'a self, name: &'a str) -> impl Iterator<Item = NodeRef<'a>> + 'a {
+        let at = |i: usize| NodeRef {
+            store: self,
+            index: self.u32_at(self.sections[NAME_ORDER] + i * 4),
+        };
+        let (mut low, mut high) = (0, self.node_count);
+        while low < high {
+            let mid = low + (high - low) / 2;
+            if at(mid).name() < name {
+                low = mid + 1;
+            } else {
+                high = mid;
+            }
+        }
+        (low..self.node_count)
+            .map(at)
+            .take_while(move |node| node.name() == name)
+    }
+}
+
+/// A node in a [`MmapGraph`], read from the map on access.
+#[derive(Clone, Copy)]
+pub struct NodeRef<'a> {
+    store: &'a MmapGraph,
+    index: u32,
+}
+
+impl<'a> NodeRef<'a> {
+    /// Position of the node in ID order
+    pub fn index(&self) -> u32 {
+        self.index
+    }
+
+    /// Node ID
+    pub fn id(&self) -> &'a str {
+        self.store
+            .string(self.store.node_field(self.index, 0))
+            .unwrap_or("")
+    }
+
+    /// Entity name
+    pub fn name(&self) -> &'a str {
+        self.store
+            .string(self.store.node_field(self.index, 1))
+            .unwrap_or("")
+    }
+
+    /// Node type
+    pub fn node_type(&self) -> NodeType {
+        node_type_from_code(self.store.node_field(self.index, 2))
+    }
+
+    /// Kind within the node type
+    pub fn kind(&self) -> Option<&'a str> {
+        self.store.string(self.store.node_field(self.index, 3))
+    }
+
+    /// Language-specific subtype
+    pub fn subtype(&self) -> Option<&'a str> {
+        self.store.string(self.store.node_field(self.index, 4))
+    }
+
+    /// Source file path
+    pub fn file(&self) -> &'a str {
+        self.store
+            .string(self.store.node_field(self.index, 5))
+            .unwrap_or("")
+    }
+
+    /// Starting line number (1-indexed)
+    pub fn line(&self) -> usize {
+        self.store.node_field(self.index, 6) as usize
+    }
+
+    /// Ending line number (1-indexed)
+    pub fn end_line(&self) -> usize {
+        self.store.node_field(self.index, 7) as usize
+    }
+
+    /// Outgoing edges
+    pub fn outgoing(&self) -> impl Iterator<Item = EdgeRef<'a>> + 'a {
+        let store = self.store;
+        let (start, end) = self.range(OUT_INDEX);
+        let source = self.index;
+        (start..end).map(move |edge| EdgeRef {
+            store,
+            source,
+            edge,
+        })
+    }
+
+    /// Incoming edges
+    pub fn incoming(&self) -> impl Iterator<Item = EdgeRef<'a>> + 'a {
+        let store = self.store;
+        let (start, end) = self.range(IN_INDEX);
+        (start..end).map(move |i| {
+            let at = store.sections[IN_EDGES] + i as usize * IN_EDGE_SIZE;
+            EdgeRef {
+                store,
+                source: store.u32_at(at),
+                edge: store.u32_at(at + 4),
+            }
+        })
+    }
+
+    /// Range of this node's entries in an adjacency index
+    fn range(&self, section: usize) -> (u32, u32) {
+        let at = self.store.sections[section] + self.index as usize * 4;
+        let (start, end) = (self.store.u32_at(at), self.store.u32_at(at + 4));
+        if start > end || end as usize > self.store.edge_count {
+            (0, 0)
+        } else {
+            (start, end)
+        }
+    }
+
+    /// Decode the complete node.
+    pub fn to_node(&self) -> Node {
+        let extra: NodeExtra = self
+            .store
+            .string(self.store.node_field(self.index, 8))
+            .and_then(|json| serde_json::from_str(json).ok())
+            .unwrap_or_default();
+        Node {
+            id: self.id().to_string(),
+            name: self.name().to_string(),
+            node_type: self.node_type(),
+            kind: self.kind().map(String::from),
+            subtype: self.subtype().map(String::from),
+            file: self.file().to_string(),
+            line: self.line(),
+            end_line: self.end_line(),
+            text: extra.text,
+            metadata: extra.metadata,
+            hash: extra.hash,
+        }
+    }
+}
+
+impl std::fmt::Debug for NodeRef<'_> {
+    fn fmt(&self, f: &mut std::fmt::Formatter<'_>) -> std::fmt::Result {
+        f.debug_tuple("NodeRef").field(&self.id()).finish()
+    }
+}
+
+/// An edge in a [`MmapGraph`], read from the map on access.
+#[derive(Clone, Copy)]
+pub struct EdgeRef<'a> {
+    store: &'a MmapGraph,
+    source: u32,
+    /// Position in the out edges section
+    edge: u32,
+}
+
+impl<'a> EdgeRef<'a> {
+    fn field(&self, field: usize) -> u32 {
+        self.store
+            .u32_at(self.store.sections[OUT_EDGES] + self.edge as usize * EDGE_SIZE + field * 4)
+    }
+
+    fn node(&self, index: u32) -> NodeRef<'a> {
+        NodeRef {
+            store: self.store,
+            index: index.min(self.store.node_count.saturating_sub(1) as u32),
+        }
+    }
+
+    /// Source node
+    pub fn source(&self) -> NodeRef<'a> {
+        self.node(self.source)
+    }
+
+    /// Target node
+    pub fn target(&self) -> NodeRef<'a> {
+        self.node(self.field(0))
+    }
+
+    /// Relationship type
+    pub fn edge_type(&self) -> EdgeType {
+        edge_type_from_code(self.field(1))
+    }
+
+    /// Line number where the reference occurs (for USES edges)
+    pub fn ref_line(&self) -> Option<usize> {
+        Some(self.field(2))
+            .filter(|&l| l != NONE)
+            .map(|l| l as usize)
+    }
+
+    /// The identifier text at the reference site
+    pub fn ident(&self) -> Option<&'a str> {
+        self.store.string(self.field(3))
+    }
+
+    /// Decode the complete edge.
+    pub fn to_edge(&self) -> Edge {
+        let extra: EdgeExtra = self
+            .store
+            .string(self.field(4))
+            .and_then(|json| serde_json::from_str(json).ok())
+            .unwrap_or_default();
+        Edge {
+            source: self.source().id().to_string(),
+            target: self.target().id().to_string(),
+            edge_type: self.edge_type(),
+            ref_line: self.ref_line(),
+            ident: self.ident().map(String::from),
+            version_spec: extra.version_spec,
+            is_dev_dependency: extra.is_dev_dependency,
+            similarity: extra.similarity,
+            weight: extra.weight,
+        }
+    }
+}
+
+impl std::fmt::Debug for EdgeRef<'_> {
+    fn fmt(&self, f: &mut std::fmt::Formatter<'_>) -> std::fmt::Result {
+        f.debug_struct("EdgeRef")
+            .field("source", &self.source().id())
+            .field("target", &self.target().id())
+            .field("type", &self.edge_type())
+            .finish()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::{CallableKind, ContainerKind, EdgeData};
+
+    fn sample_graph() -> PetCodeGraph {
+        let mut graph = PetCodeGraph::new();
+        graph.add_node(Node::source_file(
+            "app.py".to_string(),
+            "app.py".to_string(),
+            "abc123".to_string(),
+            10,
+        ));
+        graph.add_node(Node::container(
+            "app.py:Server".to_string(),
+            "Server".to_string(),
+            ContainerKind::Type,
+            Some("class".to_string()),
+            "app.py".to_string(),
+            1,
+            5,
+        ));
+        let mut start = Node::callable(
+            "app.py:Server:start".to_string(),
+            "start".to_string(),
+            CallableKind::Method,
+            "app.py".to_string(),
+            2,
+            5,
+        );
+        start.metadata.cyclomatic_complexity = Some(3);
+        graph.add_node(start);
+        graph.add_node(Node::callable(
+            "app.py:main".to_string(),
+            "main".to_string(),
+            CallableKind::Function,
+            "app.py".to_string(),
+            7,
+            10,
+        ));
+        graph.add_node(Node::callable(
+            "util.py:start".to_string(),
+            "start".to_string(),
+            CallableKind::Function,
+            "util.py".to_string(),
+            1,
+            2,
+        ));
+        graph.add_edge("app.py", "app.py:Server", EdgeData::contains());
+        graph.add_edge("app.py:Server", "app.py:Server:start", EdgeData::contains());
+        graph.add_edge("app.py", "app.py:main", EdgeData::contains());
+        graph.add_edge(
+            "app.py:main",
+            "app.py:Server:start",
+            EdgeData::uses(Some(8), Some("start".to_string())),
+        );
+        graph
+    }
+
+    fn open_sample() -> (tempfile::TempDir, MmapGraph) {
+        let dir = tempfile::tempdir().unwrap();
+        let path = dir.path().join(MMAP_GRAPH_FILE);
+        MmapGraph::write(&sample_graph(), &path).unwrap();
+        let store = MmapGraph::open(&path).unwrap();
+        (dir, store)
+    }
+
+    #[test]
+    fn test_roundtrip() {
+        let (_dir, store) = open_sample();
+        let graph = sample_graph();
+        assert_eq!(store.node_count(), graph.node_count());
+        assert_eq!(store.edge_count(), graph.edge_count());
+
+        for node in graph.iter_nodes() {
+            assert_eq!(store.get_node(&node.id).unwrap().to_node(), *node);
+        }
+        let mut edges: Vec<Edge> = store
+            .nodes()
+            .flat_map(|n| n.outgoing().map(|e| e.to_edge()).collect::<Vec<_>>())
+            .collect();
+        let mut expected: Vec<Edge> = graph.iter_edges().collect();
+        edges.sort_by(|a, b| (&a.source, &a.target).cmp(&(&b.source, &b.target)));
+        expected.sort_by(|a, b| (&a.source, &a.target).cmp(&(&b.source, &b.target)));
+        assert_eq!(edges, expected);
+    }
+
+    #[test]
+    fn test_queries() {
+        let (_dir, store) = open_sample();
+        assert!(store.get_node("app.py:missing").is_none());
+
+        let start = store.get_node("app.py:Server:start").unwrap();
+        assert_eq!(start.kind(), Some("method"));
+        assert_eq!((start.line(), start.end_line()), (2, 5));
+
+        let incoming: Vec<_> = start
+            .incoming()
+            .map(|e| (e.source().id(), e.edge_type(), e.ref_line()))
+            .collect();
+        assert_eq!(
+            incoming,
+            vec![
+                ("app.py:Server", EdgeType::Contains, None),
+                ("app.py:main", EdgeType::Uses, Some(8)),
+            ]
+        );
+
+        let named: Vec<&str> = store.find_by_name("start").map(|n| n.id()).collect();
+        assert_eq!(named, vec!["app.py:Server:start", "util.py:start"]);
+        assert_eq!(store.find_by_name("stop").count(), 0);
+    }
+
+    #[test]
+    fn test_rejects_invalid_files() {
+        let dir = tempfile::tempdir().unwrap();
+        let path = dir.path().join("bad.cpg");
+        std::fs::write(&path, b"not a graph").unwrap();
+        assert!(matches!(
+            MmapGraph::open(&path),
+            Err(MmapGraphError::Invalid(_))
+        ));
+
+        MmapGraph::write(&sample_graph(), &path).unwrap();
+        let bytes = std::fs::read(&path).unwrap();
+        std::fs::write(&path, &bytes[..bytes.len() / 2]).unwrap();
+        assert!(matches!(
+            MmapGraph::open(&path),
+            Err(MmapGraphError::Invalid(_))
+        ));
+    }
+}
@@ -0,0 +1,365 @@
+//! Package Documentation
+//!
+//! Aggregates the documentation of each package (source directory) into one
+//! Data node of kind `documentation`, so package overviews can be queried and
+//! shown next to the package's symbols:
+//!
+//! - **Package comments**: the comment directly above the `package` clause of
+//!   the package's Go files, `doc.go` first (test files are skipped)
+//! - **README**: a `README.md`, `README.markdown`, `README.rst`,
+//!   `README.txt`, or `README` in the package directory
+//!
+//! The node holds the comments and then the README as its text. It is
+//! declared in the file of the first package comment (or the README), which
+//! contains it if it is indexed, and is otherwise contained by the
+//! repository. The package clause nodes of the package's Go files DEFINE it.
+//! Only directories with indexed files get a node, so a README next to
+//! nothing but documentation is not picked up.
+
+use std::collections::BTreeMap;
+use std::path::Path;
+
+use crate::analysis::package_of;
+use crate::file_policy::is_test_file;
+use crate::graph::{DataKind, EdgeData, Node, NodeType, PetCodeGraph};
+use crate::parser::generate_node_id;
+
+/// Name of documentation nodes
+pub const PACKAGE_DOC_NAME: &str = "package-doc";
+
+/// README file names, in order of preference (matched case-insensitively)
+const README_NAMES: &[&str] = &[
+    "README.md",
+    "README.markdown",
+    "README.rst",
+    "README.txt",
+    "README",
+];
+
+/// Comment lines that are directives rather than documentation
+const DIRECTIVE_PREFIXES: &[&str] = &["go:", "+build", "line ", "nolint"];
+
+/// A Go package comment.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct PackageComment {
+    /// Comment text without comment markers
+    pub text: String,
+    /// First line of the comment (1-indexed)
+    pub line: usize,
+    /// Last line of the comment (1-indexed)
+    pub end_line: usize,
+}
+
+/// Find the package comment of a Go source file: the comment ending on the
+/// line directly above the `package` clause.
+pub fn package_comment(source: &str) -> Option<PackageComment> {
+    let mut lines: Vec<String> = Vec::new();
+    let mut start = 0;
+    let mut in_block = false;
+
+    for (index, line) in source.lines().enumerate() {
+        let trimmed = line.trim();
+        if in_block {
+            let (text, closed) = match trimmed.split_once("*/") {
+                Some((text, _)) => (text, true),
+                None => (trimmed, false),
+            };
+            lines.push(strip_block_line(text));
+            in_block = !closed;
+            continue;
+        }
+        if lines.is_empty() {
+            start = index + 1;
+        }
+        if let Some(text) = trimmed.strip_prefix("//") {
+            let text = text.strip_prefix(' ').unwrap_or(text);
+            // Directives do not end the comment, but are not part of it
+            if !DIRECTIVE_PREFIXES.iter().any(|p| text.starts_with(p)) {
+                lines.push(text.trim_end().to_string());
+            }
+        } else if let Some(text) = trimmed.strip_prefix("/*") {
+            let (text, closed) = match text.split_once("*/") {
+                Some((text, _)) => (text, true),
+                None => (text, false),
+            };
+            lines.push(strip_block_line(text));
+            in_block = !closed;
+        } else if trimmed.starts_with("package ") || trimmed == "package" {
+            let text = lines.join("\n").trim().to_string();
+            return (!text.is_empty()).then_some(PackageComment {
+                text,
+                line: start,
+                end_line: index,
+            });
+        } else {
+            // A blank line or code detaches the comment from the clause
+            lines.clear();
+        }
+    }
+    None
+}
+
+/// Text of a line inside a block comment, without a leading `*`
+fn strip_block_line(line: &str) -> String {
+    let line = line.trim();
+    let line = line.strip_prefix('*').map_or(line, str::trim_start);
+    line.to_string()
+}
+
+/// Find the README of a directory: its name and contents.
+pub fn find_readme(dir: &Path) -> Option<(String, String)> {
+    let names: Vec<String> = std::fs::read_dir(dir)
+        .ok()?
+        .filter_map(|entry| entry.ok())
+        .filter(|entry| entry.file_type().is_ok_and(|t| t.is_file()))
+        .filter_map(|entry| entry.file_name().to_str().map(str::to_string))
+        .collect();
+    README_NAMES.iter().find_map(|wanted| {
+        let name = names
+            .iter()
+            .find(|name| name.eq_ignore_ascii_case(wanted))?;
+        let contents = std::fs::read_to_string(dir.join(name)).ok()?;
+        Some((name.clone(), contents))
+    })
+}
+
+/// Get the documentation node of a package, if it has one.
+pub fn package_doc<'a>(graph: &'a PetCodeGraph, package: &str) -> Option<&'a Node> {
+    let package = package.trim_end_matches('/');
+    graph
+        .iter_nodes()
+        .find(|node| node.is_documentation() && package_of(&node.file) == package)
+}
+
+/// Replace the documentation nodes of a graph built from `repo_root` with
+/// ones for the current package comments and READMEs.
+///
+/// Returns the number of documented packages.
+pub fn attach_package_docs(repo_root: &Path, graph: &mut PetCodeGraph) -> usize {
+    let stale: Vec<String> = graph
+        .iter_nodes()
+        .filter(|node| node.is_documentation())
+        .map(|node| node.id.clone())
+        .collect();
+    for id in stale {
+        graph.remove_node(&id);
+    }
+
+    // Indexed files and Go package clauses, by package
+    let mut files: BTreeMap<&str, Vec<&str>> = BTreeMap::new();
+    let mut clauses: BTreeMap<&str, Vec<String>> = BTreeMap::new();
+    for node in graph.iter_nodes() {
+        if node.is_file() {
+            files
+                .entry(package_of(&node.file))
+                .or_default()
+                .push(&node.file);
+        } else if is_package_clause(node) {
+            clauses
+                .entry(package_of(&node.file))
+                .or_default()
+                .push(node.id.clone());
+        }
+    }
+    let repository = graph
+        .iter_nodes()
+        .find(|node| node.is_repository())
+        .map(|node| node.id.clone());
+
+    let mut docs = Vec::new();
+    for (package, mut package_files) in files {
+        // doc.go first, then by path
+        package_files.sort_by_key(|file| (!file.ends_with("/doc.go") && *file != "doc.go", *file));
+        if let Some(doc) = collect(repo_root, package, &package_files) {
+            docs.push((doc, clauses.remove(package).unwrap_or_default()));
+        }
+    }
+
+    let count = docs.len();
+    for (doc, package_clauses) in docs {
+        let parent = if graph.contains_node(&doc.file) {
+            Some(doc.file.clone())
+        } else {
+            repository.clone()
+        };
+        let id = doc.id.clone();
+        graph.add_node(doc);
+        if let Some(parent) = parent {
+            graph.add_edge(&parent, &id, EdgeData::contains());
+        }
+        for clause in package_clauses {
+            graph.add_edge(&clause, &id, EdgeData::defines());
+        }
+    }
+    count
+}
+
+/// Build the documentation node of a package from its files' package
+/// comments and its README
+fn collect(repo_root: &Path, package: &str, files: &[&str]) -> Option<Node> {
+    let mut comments: Vec<(&str, PackageComment)> = Vec::new();
+    for file in files {
+        if !file.ends_with(".go") || is_test_file(file) {
+            continue;
+        }
+        let Ok(source) = std::fs::read_to_string(repo_root.join(file)) else {
+            continue;
+        };
+        if let Some(comment) = package_comment(&source) {
+            if !comments.iter().any(|(_, c)| c.text == comment.text) {
+                comments.push((file, comment));
+            }
+        }
+    }
+    let readme = find_readme(&repo_root.join(package));
+
+    let mut sections: Vec<&str> = comments.iter().map(|(_, c)| c.text.as_str()).collect();
+    if let Some((_, ref contents)) = readme {
+        if !contents.trim().is_empty() {
+            sections.push(contents.trim());
+        }
+    }
+    if sections.is_empty() {
+        return None;
+    }
+    let text = sections.join("\n\n");
+
+    let (file, line, end_line) = match (comments.first(), &readme) {
+        (Some((file, comment)), _) => (file.to_string(), comment.line, comment.end_line),
+        (None, Some((name, contents))) => {
+            let file = if package.is_empty() {
+                name.clone()
+            } else {
+                format!("{}/{}", package, name)
+            };
+            (file, 1, contents.lines().count().max(1))
+        }
+        (None, None) => unreachable!("sections are not empty"),
+    };
+    let name = match package.rsplit('/').next() {
+        Some(last) if !last.is_empty() => last.to_string(),
+        _ => ".".to_string(),
+    };
+
+    Some(
+        Node::data(
+            generate_node_id(&file, &[], PACKAGE_DOC_NAME, None),
+            name,
+            DataKind::Documentation,
+            None,
+            file,
+            line,
+            end_line,
+        )
+        .with_text(text),
+    )
+}
+
+/// Check if a node is the `package` clause of a Go file
+fn is_package_clause(node: &Node) -> bool {
+    node.node_type == NodeType::Container
+        && node.kind.as_deref() == Some("module")
+        && node.file.ends_with(".go")
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::{ContainerKind, Edge, EdgeType};
+
+    #[test]
+    fn test_package_comment() {
+        let source = "// Copyright 2024 Example\n\n//go:build linux\n\n// Package api serves the HTTP API.\n//\n// It is versioned.\n//go:generate stringer\npackage api\n";
+        let comment = package_comment(source).unwrap();
+        assert_eq!(
+            comment.text,
+            "Package api serves the HTTP API.\n\nIt is versioned."
+        );
+        assert_eq!((comment.line, comment.end_line), (5, 8));
+
+        let block = "/*\n * Package db stores records.\n */\npackage db\n";
+        assert_eq!(
+            package_comment(block).unwrap().text,
+            "Package db stores records."
+        );
+
+        // Detached by a blank line
+        assert!(package_comment("// Helpers.\n\npackage util\n").is_none());
+        assert!(package_comment("package util\n").is_none());
+    }
+
+    fn add_file(graph: &mut PetCodeGraph, file: &str) {
+        graph.add_node(Node::source_file(
+            file.to_string(),
+            file.to_string(),
+            String::new(),
+            10,
+        ));
+        graph.add_edge_from_struct(&Edge::contains("repo".to_string(), file.to_string()));
+        if file.ends_with(".go") {
+            let clause = format!("{}:api", file);
+            graph.add_node(Node::container(
+                clause.clone(),
+                "api".to_string(),
+                ContainerKind::Module,
+                None,
+                file.to_string(),
+                1,
+                1,
+            ));
+            graph.add_edge(file, &clause, EdgeData::contains());
+        }
+    }
+
+    #[test]
+    fn test_attach_package_docs() {
+        let dir = tempfile::tempdir().unwrap();
+        let root = dir.path();
+        for (path, contents) in [
+            (
+                "api/doc.go",
+                "// Package api serves the HTTP API.\npackage api\n",
+            ),
+            ("api/server.go", "package api\n\nfunc Serve() {}\n"),
+            ("api/README.md", "# API\n\nEndpoints and auth.\n"),
+            ("web/app.py", "def main(): pass\n"),
+            ("web/readme.txt", "The web frontend.\n"),
+            ("util/util.go", "package util\n"),
+        ] {
+            std::fs::create_dir_all(root.join(path).parent().unwrap()).unwrap();
+            std::fs::write(root.join(path), contents).unwrap();
+        }
+
+        let mut graph = PetCodeGraph::new();
+        graph.add_node(Node::repository("repo".to_string(), Default::default()));
+        for file in ["api/doc.go", "api/server.go", "web/app.py", "util/util.go"] {
+            add_file(&mut graph, file);
+        }
+
+        assert_eq!(attach_package_docs(root, &mut graph), 2);
+        // Rerunning replaces the nodes
+        assert_eq!(attach_package_docs(root, &mut graph), 2);
+
+        let api = package_doc(&graph, "api/").unwrap();
+        assert_eq!(api.id, "api/doc.go:package-doc");
+        assert_eq!(api.name, "api");
+        assert_eq!(
+            api.text.as_deref(),
+            Some("Package api serves the HTTP API.\n\n# API\n\nEndpoints and auth.")
+        );
+        assert_eq!(graph.parent(&api.id).unwrap().id, "api/doc.go");
+        let definers: Vec<&str> = graph
+            .incoming_edges(&api.id)
+            .filter(|(_, edge)| edge.edge_type == EdgeType::Defines)
+            .map(|(node, _)| node.id.as_str())
+            .collect();
+        assert_eq!(definers.len(), 2);
+
+        let web = package_doc(&graph, "web").unwrap();
+        assert_eq!(web.file, "web/readme.txt");
+        assert_eq!(web.text.as_deref(), Some("The web frontend."));
+        assert_eq!(graph.parent(&web.id).unwrap().id, "repo");
+
+        assert!(package_doc(&graph, "util").is_none());
+    }
+}
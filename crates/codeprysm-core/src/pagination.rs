@@ -0,0 +1,237 @@
+//! Cursor Pagination
+//!
+//! Cuts long result lists (all callers of a function, all implementers of an
+//! interface) into pages that clients walk with opaque cursors. A cursor
+//! names the last item of a page by its key rather than only by its index,
+//! so the next page starts right after that item even when the index was
+//! rebuilt in between and items were added or removed before it.
+//!
+//! Cursors only mean the same thing on every request if the list is in a
+//! stable order: sorted by a total order, with ties broken by the key. All
+//! lists served by the CLI and the server are.
+//!
+//! # Example
+//!
+//! ```
+//! use codeprysm_core::pagination::{paginate, PageRequest};
+//!
+//! let ids: Vec<String> = (1..=5).map(|i| format!("api/h.go:F{}", i)).collect();
+//! let first = paginate(ids.clone(), &PageRequest::first(2), |id| id.clone());
+//! assert_eq!(first.items, ["api/h.go:F1", "api/h.go:F2"]);
+//!
+//! let request = PageRequest::parse(0, first.next_cursor.as_deref(), 2).unwrap();
+//! let second = paginate(ids, &request, |id| id.clone());
+//! assert_eq!(second.items, ["api/h.go:F3", "api/h.go:F4"]);
+//! ```
+
+use serde::Serialize;
+use thiserror::Error;
+
+/// A cursor that could not be decoded
+#[derive(Debug, Clone, Error)]
+#[error("Invalid cursor '{0}'")]
+pub struct InvalidCursor(pub String);
+
+/// Position of an item in a list: its index and its key.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct Cursor {
+    /// Index of the item when the cursor was issued
+    pub index: usize,
+    /// Key of the item (e.g. its node ID)
+    pub key: String,
+}
+
+impl Cursor {
+    /// Cursor naming the item at `index` with the given key
+    pub fn new(index: usize, key: impl Into<String>) -> Self {
+        Self {
+            index,
+            key: key.into(),
+        }
+    }
+
+    /// Encode as an opaque, URL-safe string.
+    pub fn encode(&self) -> String {
+        let key: String = self.key.bytes().map(|b| format!("{:02x}", b)).collect();
+        format!("{:x}-{}", self.index, key)
+    }
+
+    /// Decode a string produced by [`Cursor::encode`].
+    pub fn decode(cursor: &str) -> Result<Self, InvalidCursor> {
+        let invalid = || InvalidCursor(cursor.to_string());
+
+        let (index, key) = cursor.split_once('-').ok_or_else(invalid)?;
+        let index = usize::from_str_radix(index, 16).map_err(|_| invalid())?;
+        if key.len() % 2 != 0 {
+            return Err(invalid());
+        }
+        let bytes = (0..key.len())
+            .step_by(2)
+            .map(|i| {
+                key.get(i..i + 2)
+                    .and_then(|b| u8::from_str_radix(b, 16).ok())
+            })
+            .collect::<Option<Vec<u8>>>()
+            .ok_or_else(invalid)?;
+        let key = String::from_utf8(bytes).map_err(|_| invalid())?;
+        Ok(Self { index, key })
+    }
+
+    /// Index at which the items following this cursor start in `keys`.
+    ///
+    /// Falls back to the issued index when the item is gone, so removing the
+    /// item itself does not skip the one that took its place.
+    fn resume(&self, mut keys: impl Iterator<Item = String>) -> usize {
+        match keys.position(|key| key == self.key) {
+            Some(position) => position + 1,
+            None => self.index,
+        }
+    }
+}
+
+/// Which page of a list to return.
+#[derive(Debug, Clone, Default)]
+pub struct PageRequest {
+    /// Index of the first item, when no cursor is given
+    pub offset: usize,
+    /// Continue after this item (takes precedence over `offset`)
+    pub after: Option<Cursor>,
+    /// Maximum number of items on the page
+    pub limit: usize,
+}
+
+impl PageRequest {
+    /// The first page of `limit` items
+    pub fn first(limit: usize) -> Self {
+        Self {
+            offset: 0,
+            after: None,
+            limit,
+        }
+    }
+
+    /// Build a request from wire parameters, decoding `cursor` if given.
+    pub fn parse(offset: usize, cursor: Option<&str>, limit: usize) -> Result<Self, InvalidCursor> {
+        Ok(Self {
+            offset,
+            after: cursor.map(Cursor::decode).transpose()?,
+            limit,
+        })
+    }
+}
+
+/// One page of a longer result list.
+#[derive(Debug, Clone, Serialize)]
+pub struct Page<T> {
+    /// Items on this page
+    pub items: Vec<T>,
+    /// Number of items across all pages
+    pub total: usize,
+    /// Index of the first item on this page
+    pub offset: usize,
+    /// Maximum number of items per page
+    pub limit: usize,
+    /// Offset of the next page, if there is one
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub next_offset: Option<usize>,
+    /// Cursor of the next page, if there is one
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub next_cursor: Option<String>,
+}
+
+/// Cut the requested page out of `items`, identifying items by `key`.
+pub fn paginate<T>(items: Vec<T>, request: &PageRequest, key: impl Fn(&T) -> String) -> Page<T> {
+    let total = items.len();
+    let offset = match &request.after {
+        Some(cursor) => cursor.resume(items.iter().map(&key)),
+        None => request.offset,
+    }
+    .min(total);
+
+    let items: Vec<T> = items.into_iter().skip(offset).take(request.limit).collect();
+    let end = offset + items.len();
+    let next_cursor = match items.last() {
+        Some(last) if end < total => Some(Cursor::new(end - 1, key(last)).encode()),
+        _ => None,
+    };
+    Page {
+        items,
+        total,
+        offset,
+        limit: request.limit,
+        // An empty page would point at itself
+        next_offset: (end < total && request.limit > 0).then_some(end),
+        next_cursor,
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn keys(range: std::ops::RangeInclusive<u32>) -> Vec<String> {
+        range.map(|i| format!("pkg/a.go:F{}", i)).collect()
+    }
+
+    #[test]
+    fn test_cursor_round_trip() {
+        let cursor = Cursor::new(41, "api/server.go:Server:Handle");
+        let encoded = cursor.encode();
+        assert!(encoded.chars().all(|c| c.is_ascii_hexdigit() || c == '-'));
+        assert_eq!(Cursor::decode(&encoded).unwrap(), cursor);
+
+        for invalid in ["", "29", "zz-00", "1-0", "1-xy", "1-ff"] {
+            assert!(Cursor::decode(invalid).is_err(), "{}", invalid);
+        }
+    }
+
+    #[test]
+    fn test_paginate_follows_cursor() {
+        let first = paginate(keys(1..=5), &PageRequest::first(2), Clone::clone);
+        assert_eq!(first.items, ["pkg/a.go:F1", "pkg/a.go:F2"]);
+        assert_eq!((first.total, first.next_offset), (5, Some(2)));
+
+        let after = Cursor::decode(first.next_cursor.as_deref().unwrap()).unwrap();
+        let request = PageRequest {
+            after: Some(after.clone()),
+            ..PageRequest::first(2)
+        };
+
+        // An item inserted before the cursor does not repeat F2
+        let mut grown = keys(1..=5);
+        grown.insert(0, "pkg/a.go:F0".to_string());
+        let second = paginate(grown, &request, Clone::clone);
+        assert_eq!(second.items, ["pkg/a.go:F3", "pkg/a.go:F4"]);
+        assert_eq!(second.offset, 3);
+
+        // Removing the named item resumes where it was
+        let mut shrunk = keys(1..=5);
+        shrunk.remove(1);
+        let second = paginate(shrunk, &request, Clone::clone);
+        assert_eq!(second.items, ["pkg/a.go:F3", "pkg/a.go:F4"]);
+
+        let last = paginate(
+            keys(1..=5),
+            &PageRequest::parse(4, None, 2).unwrap(),
+            Clone::clone,
+        );
+        assert_eq!(last.items, ["pkg/a.go:F5"]);
+        assert_eq!((last.next_offset, last.next_cursor), (None, None));
+
+        let past_end = paginate(
+            keys(1..=5),
+            &PageRequest::parse(10, None, 2).unwrap(),
+            Clone::clone,
+        );
+        assert!(past_end.items.is_empty());
+        assert_eq!(past_end.offset, 5);
+    }
+
+    #[test]
+    fn test_paginate_zero_limit_has_no_next_page() {
+        let page = paginate(keys(1..=5), &PageRequest::first(0), Clone::clone);
+        assert!(page.items.is_empty());
+        assert_eq!(page.total, 5);
+        assert_eq!((page.next_offset, page.next_cursor), (None, None));
+    }
+}
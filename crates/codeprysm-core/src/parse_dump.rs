@@ -0,0 +1,265 @@
+//! Single-File Parse Dumps
+//!
+//! Everything extraction sees and produces for one file, for reporting
+//! extraction bugs with precise evidence and for iterating on queries:
+//!
+//! - the syntax tree, in the S-expression format of `tree-sitter parse`
+//!   (named nodes with field names and 0-indexed `[row, column]` ranges)
+//! - every capture of the language's tag query
+//! - the nodes and edges built from the captures
+//! - the references, resolved against the file's own definitions
+//! - syntax errors the parser recovered from
+//!
+//! References to other files are listed as unresolved, since a dump never
+//! looks beyond its file.
+
+use std::fmt::Write;
+use std::path::Path;
+
+use serde::Serialize;
+use tree_sitter::{Tree, TreeCursor};
+
+use crate::builder::{definitions, resolve_file_references, BuilderError, GraphBuilder};
+use crate::diagnostics::Diagnostic;
+use crate::graph::{Edge, EdgeType, Node};
+use crate::parser::{CodeParser, ParserError, SupportedLanguage};
+
+/// Everything extraction sees and produces for one file.
+#[derive(Debug, Clone, Serialize)]
+pub struct ParseDump {
+    /// File path, as used in node IDs
+    pub file: String,
+    /// Detected language
+    pub language: String,
+    /// Syntax tree as an S-expression
+    pub tree: String,
+    /// Query captures, in match order
+    pub captures: Vec<Capture>,
+    /// Nodes built from the captures
+    pub nodes: Vec<Node>,
+    /// Edges built from the captures, with USES edges for references
+    /// resolved within the file
+    pub edges: Vec<Edge>,
+    /// References, in line order
+    pub references: Vec<Reference>,
+    /// Syntax errors and extraction failures
+    pub diagnostics: Vec<Diagnostic>,
+}
+
+/// A capture of the tag query. Lines and columns are 1-indexed.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize)]
+pub struct Capture {
+    /// Capture name (e.g., "name.definition.callable.function")
+    pub capture: String,
+    /// Captured text
+    pub text: String,
+    /// Start line
+    pub line: usize,
+    /// Start column
+    pub column: usize,
+    /// End line
+    pub end_line: usize,
+    /// End column
+    pub end_column: usize,
+    /// Go method receiver type
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub receiver: Option<String>,
+    /// Rust impl block type
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub impl_target: Option<String>,
+}
+
+/// A reference of the file.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize)]
+pub struct Reference {
+    /// Referenced name
+    pub name: String,
+    /// Node the reference is made from
+    pub source: String,
+    /// Line of the reference
+    pub line: usize,
+    /// Node the reference resolved to within the file, if any
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub target: Option<String>,
+}
+
+/// Dump the parse of `source`, whose language is detected from `rel_path`,
+/// with the queries and configuration of `builder`.
+pub fn dump_source(
+    builder: &mut GraphBuilder,
+    rel_path: &str,
+    source: &str,
+) -> Result<ParseDump, BuilderError> {
+    let language = SupportedLanguage::from_path(Path::new(rel_path))
+        .ok_or_else(|| ParserError::UnsupportedLanguage(rel_path.to_string()))?;
+
+    let tree = CodeParser::new(language)?.parse(source)?;
+    let captures = builder
+        .tag_extractor(language)?
+        .extract(source)?
+        .into_iter()
+        .map(|tag| Capture {
+            line: tag.line_number(),
+            column: tag.start_col + 1,
+            end_line: tag.end_line_number(),
+            end_column: tag.end_col + 1,
+            capture: tag.tag,
+            text: tag.name,
+            receiver: tag.receiver,
+            impl_target: tag.impl_target,
+        })
+        .collect();
+
+    let diagnostics_before = builder.diagnostics().len();
+    let (mut graph, file_references) = builder.parse_source(rel_path, source)?;
+    let defines = definitions(&graph);
+    resolve_file_references(&mut graph, &defines, &file_references);
+
+    let edges: Vec<Edge> = graph.iter_edges().collect();
+    let mut references: Vec<Reference> = file_references
+        .iter()
+        .map(|(name, source_id, line)| Reference {
+            name: name.to_string(),
+            source: source_id.to_string(),
+            line,
+            target: edges
+                .iter()
+                .find(|e| {
+                    e.edge_type == EdgeType::Uses
+                        && e.source == source_id
+                        && e.ref_line == Some(line)
+                        && e.ident.as_deref() == Some(name)
+                })
+                .map(|e| e.target.clone()),
+        })
+        .collect();
+    references.sort_by(|a, b| (a.line, &a.name, &a.source).cmp(&(b.line, &b.name, &b.source)));
+
+    Ok(ParseDump {
+        file: rel_path.to_string(),
+        language: language.as_str().to_string(),
+        tree: syntax_tree(&tree),
+        captures,
+        nodes: graph.iter_nodes().cloned().collect(),
+        edges,
+        references,
+        diagnostics: builder.diagnostics()[diagnostics_before..].to_vec(),
+    })
+}
+
+/// Format a syntax tree as `tree-sitter parse` does.
+pub fn syntax_tree(tree: &Tree) -> String {
+    let mut out = String::new();
+    write_node(&mut tree.walk(), &mut out, 0, None);
+    out
+}
+
+/// Write the node under the cursor and its named descendants
+fn write_node(cursor: &mut TreeCursor, out: &mut String, depth: usize, field: Option<&str>) {
+    let node = cursor.node();
+    let (start, end) = (node.start_position(), node.end_position());
+    if depth > 0 {
+        out.push('\n');
+    }
+    out.push_str(&"  ".repeat(depth));
+    if let Some(field) = field {
+        let _ = write!(out, "{}: ", field);
+    }
+    if node.is_missing() {
+        let kind = if node.is_named() {
+            node.kind().to_string()
+        } else {
+            format!("\"{}\"", node.kind())
+        };
+        let _ = write!(out, "(MISSING {}", kind);
+    } else {
+        let _ = write!(out, "({}", node.kind());
+    }
+    let _ = write!(
+        out,
+        " [{}, {}] - [{}, {}]",
+        start.row, start.column, end.row, end.column
+    );
+
+    if cursor.goto_first_child() {
+        loop {
+            let child = cursor.node();
+            if child.is_named() || child.is_missing() {
+                let field = cursor.field_name();
+                write_node(cursor, out, depth + 1, field);
+            }
+            if !cursor.goto_next_sibling() {
+                break;
+            }
+        }
+        cursor.goto_parent();
+    }
+    out.push(')');
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    const SOURCE: &str = "package app
+
+func helper() {}
+
+func run() {
+\thelper()
+\tfmt.Println()
+}
+";
+
+    #[test]
+    fn test_dump_source() {
+        let mut builder = GraphBuilder::new_with_embedded_queries();
+        let dump = dump_source(&mut builder, "cmd/app.go", SOURCE).unwrap();
+
+        assert_eq!(dump.language, "go");
+        assert!(dump.tree.starts_with("(source_file [0, 0] - [8, 0]"));
+        assert!(dump.tree.contains(
+            "\n  (function_declaration [2, 0] - [2, 16]\n    name: (identifier [2, 5] - [2, 11])"
+        ));
+        assert!(dump.diagnostics.is_empty());
+
+        let capture = dump
+            .captures
+            .iter()
+            .find(|c| c.capture == "name.definition.callable.function" && c.text == "run")
+            .unwrap();
+        assert_eq!((capture.line, capture.column), (5, 6));
+        assert_eq!((capture.end_line, capture.end_column), (5, 9));
+
+        assert!(dump.nodes.iter().any(|n| n.id == "cmd/app.go:helper"));
+        let helper = dump.references.iter().find(|r| r.name == "helper").unwrap();
+        assert_eq!(helper.source, "cmd/app.go:run");
+        assert_eq!(helper.line, 6);
+        assert_eq!(helper.target.as_deref(), Some("cmd/app.go:helper"));
+        assert!(dump.edges.iter().any(|e| e.edge_type == EdgeType::Uses
+            && e.source == "cmd/app.go:run"
+            && e.target == "cmd/app.go:helper"));
+        assert!(dump
+            .references
+            .iter()
+            .any(|r| r.name == "Println" && r.target.is_none()));
+    }
+
+    #[test]
+    fn test_dump_syntax_error() {
+        let mut builder = GraphBuilder::new_with_embedded_queries();
+        let dump = dump_source(&mut builder, "app.go", "package app\n\nfunc run( {\n").unwrap();
+        assert!(dump.tree.contains("ERROR") || dump.tree.contains("MISSING"));
+        assert!(!dump.diagnostics.is_empty());
+    }
+
+    #[test]
+    fn test_dump_unsupported_language() {
+        let mut builder = GraphBuilder::new_with_embedded_queries();
+        let err = dump_source(&mut builder, "notes.txt", "").unwrap_err();
+        assert!(matches!(
+            err,
+            BuilderError::Parser(ParserError::UnsupportedLanguage(_))
+        ));
+    }
+}
@@ -15,12 +15,18 @@
 //! - C# (.cs)
 
 use std::collections::HashMap;
-use std::path::Path;
+use std::path::{Path, PathBuf};
 use std::sync::OnceLock;
 
 use thiserror::Error;
 use tree_sitter::{Language, Parser, Query, QueryCursor, StreamingIterator, Tree};
 
+use crate::complexity::{self, Complexity};
+use crate::control_flow::{self, ControlFlow};
+use crate::diagnostics::{self, Diagnostic};
+use crate::fingerprint::{self, Fingerprint};
+use crate::markers::{self, Marker};
+
 // ============================================================================
 // Supported Languages
 // ============================================================================
@@ -397,6 +403,16 @@ impl QueryManager {
         language: SupportedLanguage,
         queries_dir: &Path,
     ) -> Result<Self, ParserError> {
+        let query_source = Self::source_from_queries_dir(language, queries_dir)?;
+        Self::new(language, &query_source)
+    }
+
+    /// Get the query source of a queries directory: `{language}-tags.scm`
+    /// followed by the overlay files from `overlays/{language}-*.scm`.
+    pub fn source_from_queries_dir(
+        language: SupportedLanguage,
+        queries_dir: &Path,
+    ) -> Result<String, ParserError> {
         let lang_str = language.as_str();
         let base_path = queries_dir.join(format!("{}-tags.scm", lang_str));
 
@@ -435,7 +451,7 @@ impl QueryManager {
             }
         }
 
-        Self::new(language, &query_source)
+        Ok(query_source)
     }
 
     /// Load query from embedded queries (compiled into the binary).
@@ -443,13 +459,39 @@ impl QueryManager {
     /// This is the preferred method for production use as it doesn't require
     /// external query files.
     pub fn from_embedded(language: SupportedLanguage) -> Result<Self, ParserError> {
-        let query_source = crate::embedded_queries::get_query(language).ok_or_else(|| {
+        let query_source = Self::embedded_source(language)?;
+        Self::new(language, &query_source)
+    }
+
+    /// Get the embedded query source of a language.
+    pub fn embedded_source(language: SupportedLanguage) -> Result<String, ParserError> {
+        crate::embedded_queries::get_query(language).ok_or_else(|| {
             ParserError::QueryLoad(format!(
                 "No embedded query available for language: {:?}",
                 language
             ))
-        })?;
-        Self::new(language, &query_source)
+        })
+    }
+
+    /// Append user-provided query files to a query source.
+    ///
+    /// Each file is compiled on its own first, so an invalid file is reported
+    /// by path rather than as an error in the combined query.
+    pub fn append_query_files(
+        language: SupportedLanguage,
+        query_source: &mut String,
+        files: &[PathBuf],
+    ) -> Result<(), ParserError> {
+        let ts_language = language.tree_sitter_language();
+        for file in files {
+            let source = std::fs::read_to_string(file)
+                .map_err(|e| ParserError::QueryLoad(format!("{}: {}", file.display(), e)))?;
+            Query::new(&ts_language, &source)
+                .map_err(|e| ParserError::QueryCompile(format!("{}: {:?}", file.display(), e)))?;
+            query_source.push_str("\n\n");
+            query_source.push_str(&source);
+        }
+        Ok(())
     }
 
     /// Get the underlying tree-sitter query.
@@ -477,6 +519,17 @@ impl QueryManager {
 // Extracted Tag
 // ============================================================================
 
+/// Everything extracted from one parse of a source file.
+#[derive(Debug, Clone, Default)]
+pub struct ExtractedSource {
+    /// Tags matched by the language's queries
+    pub tags: Vec<ExtractedTag>,
+    /// Syntax errors the parser recovered from
+    pub diagnostics: Vec<Diagnostic>,
+    /// TODO, FIXME, and HACK comments
+    pub markers: Vec<Marker>,
+}
+
 /// A tag extracted from source code via tree-sitter query.
 #[derive(Debug, Clone)]
 pub struct ExtractedTag {
@@ -503,6 +556,19 @@ pub struct ExtractedTag {
     /// For Rust: The type being implemented (from impl blocks)
     /// This allows methods in `impl Foo { }` to be associated with struct Foo
     pub impl_target: Option<String>,
+    /// For Go: The receiver type of a method declaration
+    /// (e.g., "Server" for `func (s *Server) Start()`)
+    pub receiver: Option<String>,
+    /// For Go anonymous struct and interface types: the field or variable
+    /// declared with the type, which it is named after (e.g., "Server" for
+    /// the type `Server.struct` of `Server struct { Port int }`)
+    pub anonymous_owner: Option<String>,
+    /// For callable definitions: complexity of the body (None if bodiless)
+    pub complexity: Option<Complexity>,
+    /// For callable definitions: control-flow summary of the body (None if bodiless)
+    pub control_flow: Option<ControlFlow>,
+    /// For callable definitions: clone fingerprint of the body (None if bodiless)
+    pub fingerprint: Option<Fingerprint>,
 }
 
 impl ExtractedTag {
@@ -807,9 +873,24 @@ impl TagExtractor {
 
     /// Extract tags from source code.
     pub fn extract(&mut self, source: &str) -> Result<Vec<ExtractedTag>, ParserError> {
+        self.extract_source(source, "")
+            .map(|extracted| extracted.tags)
+    }
+
+    /// Extract tags from source code, along with diagnostics for the syntax
+    /// errors the parser recovered from in `file` and the tech-debt markers
+    /// in its comments.
+    pub fn extract_source(
+        &mut self,
+        source: &str,
+        file: &str,
+    ) -> Result<ExtractedSource, ParserError> {
         let tree = self.parser.parse(source)?;
+        let diagnostics = diagnostics::syntax_errors(&tree, file);
+        let markers = markers::scan(&tree, source);
         let source_bytes = source.as_bytes();
         let is_rust = self.parser.language() == SupportedLanguage::Rust;
+        let is_go = self.parser.language() == SupportedLanguage::Go;
 
         let mut tags = Vec::new();
         let mut cursor = QueryCursor::new();
@@ -818,17 +899,49 @@ impl TagExtractor {
 
         let mut matches = cursor.matches(query, tree.root_node(), source_bytes);
         while let Some(match_) = matches.next() {
+            // The whole definition node for callables, used to measure complexity
+            let callable_definition = match_
+                .captures
+                .iter()
+                .find(|c| capture_names[c.index as usize].starts_with("definition.callable"))
+                .map(|c| c.node);
+
             for capture in match_.captures {
                 let capture_name = &capture_names[capture.index as usize];
                 let node = capture.node;
 
+                // Go anonymous struct and interface types have no name; they are
+                // named after their field or variable, and empty ones are skipped
+                let anonymous_owner = if is_go
+                    && matches!(node.kind(), "struct_type" | "interface_type")
+                    && capture_name.starts_with("name.definition.")
+                {
+                    match go_anonymous_type_owner(&node, source_bytes) {
+                        Some(owner) if go_type_has_members(&node) => Some(owner),
+                        _ => continue,
+                    }
+                } else {
+                    None
+                };
+
                 // Get the text of the captured node
-                let text = node.utf8_text(source_bytes).unwrap_or("").to_string();
+                let text = match anonymous_owner {
+                    Some(ref owner) => {
+                        format!("{}.{}", owner, node.kind().trim_end_matches("_type"))
+                    }
+                    None => node.utf8_text(source_bytes).unwrap_or("").to_string(),
+                };
 
                 // For name. captures (e.g., @name.definition.X), get the parent node's
                 // line range for proper containment tracking. The parent is the actual
                 // definition node (class_definition, function_definition, etc.).
-                let (parent_start_line, parent_end_line) = if capture_name.starts_with("name.") {
+                // Anonymous types are their own definition node.
+                let (parent_start_line, parent_end_line) = if anonymous_owner.is_some() {
+                    (
+                        Some(node.start_position().row),
+                        Some(node.end_position().row),
+                    )
+                } else if capture_name.starts_with("name.") {
                     if let Some(parent) = node.parent() {
                         (
                             Some(parent.start_position().row),
@@ -848,6 +961,24 @@ impl TagExtractor {
                     None
                 };
 
+                // For Go method declarations, record the receiver type
+                let receiver = if is_go && capture_name.contains("callable.method") {
+                    find_go_receiver(&node, source_bytes)
+                } else {
+                    None
+                };
+
+                let (complexity, control_flow, fingerprint) = match callable_definition
+                    .filter(|_| capture_name.starts_with("name.definition.callable"))
+                {
+                    Some(def) => (
+                        complexity::measure(&def, source_bytes),
+                        control_flow::summarize(&def, source_bytes),
+                        fingerprint::fingerprint(&def, source_bytes),
+                    ),
+                    None => (None, None, None),
+                };
+
                 tags.push(ExtractedTag {
                     tag: (*capture_name).to_string(),
                     name: text,
@@ -860,11 +991,20 @@ impl TagExtractor {
                     parent_start_line,
                     parent_end_line,
                     impl_target,
+                    receiver,
+                    anonymous_owner,
+                    complexity,
+                    control_flow,
+                    fingerprint,
                 });
             }
         }
 
-        Ok(tags)
+        Ok(ExtractedSource {
+            tags,
+            diagnostics,
+            markers,
+        })
     }
 }
 
@@ -910,6 +1050,71 @@ fn find_impl_target(node: &tree_sitter::Node, source: &[u8]) -> Option<String> {
     None
 }
 
+/// Find the receiver type name for a Go method declaration.
+///
+/// Handles value (`func (s Server)`), pointer (`func (s *Server)`) and generic
+/// (`func (r *Repo[T])`) receivers. Returns `None` for interface method
+/// specifications, which have no receiver.
+fn find_go_receiver(node: &tree_sitter::Node, source: &[u8]) -> Option<String> {
+    let mut current = Some(*node);
+    while let Some(candidate) = current {
+        if candidate.kind() == "method_declaration" {
+            break;
+        }
+        current = candidate.parent();
+    }
+    let method = current?;
+
+    let receiver = method.child_by_field_name("receiver")?;
+    let param = receiver
+        .named_children(&mut receiver.walk())
+        .find(|c| c.kind() == "parameter_declaration")?;
+    let mut type_node = param.child_by_field_name("type")?;
+
+    // Unwrap pointer and generic receivers down to the type identifier
+    loop {
+        match type_node.kind() {
+            "type_identifier" => return type_node.utf8_text(source).ok().map(String::from),
+            "pointer_type" => type_node = type_node.named_child(0)?,
+            "generic_type" => type_node = type_node.child_by_field_name("type")?,
+            _ => return None,
+        }
+    }
+}
+
+/// Find the field or variable declared with a Go anonymous struct or
+/// interface type, through pointer, slice, array, map, and channel types
+/// (e.g., "Items" for `Items []struct { ID int }`).
+fn go_anonymous_type_owner(node: &tree_sitter::Node, source: &[u8]) -> Option<String> {
+    let mut current = node.parent()?;
+    while matches!(
+        current.kind(),
+        "pointer_type" | "slice_type" | "array_type" | "map_type" | "channel_type"
+    ) {
+        current = current.parent()?;
+    }
+    if !matches!(current.kind(), "field_declaration" | "var_spec") {
+        return None;
+    }
+    let name = current.child_by_field_name("name")?;
+    name.utf8_text(source).ok().map(String::from)
+}
+
+/// Check if a Go struct or interface type declares any fields, methods, or
+/// embedded types (`struct{}` and `interface{}` have no structure to show).
+fn go_type_has_members(node: &tree_sitter::Node) -> bool {
+    let body = if node.kind() == "struct_type" {
+        node.named_children(&mut node.walk())
+            .find(|c| c.kind() == "field_declaration_list")
+    } else {
+        Some(*node)
+    };
+    body.is_some_and(|body| {
+        body.named_children(&mut body.walk())
+            .any(|c| c.kind() != "comment")
+    })
+}
+
 // ============================================================================
 // Metadata Extraction
 // ============================================================================
@@ -0,0 +1,426 @@
+//! Index Planning
+//!
+//! What a build of a workspace would do, without parsing anything: which
+//! source files it would parse, which it would reuse from the extraction
+//! cache, and which it would leave out and why (ignore files, exclude and
+//! include patterns, per-language file policies, the file limit). Users tune
+//! their configuration against the plan before starting a long build.
+//!
+//! The plan also estimates the nodes of the graph: cached files count their
+//! cached nodes, and files to parse are estimated from their size at the
+//! node density of the cached files, or [`DEFAULT_NODES_PER_KIB`] without a
+//! cache.
+
+use std::collections::{BTreeMap, HashSet};
+use std::fmt;
+use std::path::{Path, PathBuf};
+
+use globset::{Glob, GlobMatcher};
+use ignore::WalkBuilder;
+use serde::Serialize;
+use tracing::{debug, info, info_span};
+
+use crate::builder::{BuilderError, GraphBuilder};
+use crate::discovery::RootDiscovery;
+use crate::file_policy::{build_glob_set, PolicyExclusion};
+use crate::merkle::compute_content_hash;
+use crate::parser::SupportedLanguage;
+
+/// Nodes per KiB of source assumed for files to parse when no cached file
+/// gives the workspace's own density
+pub const DEFAULT_NODES_PER_KIB: f64 = 6.0;
+
+/// Why a build leaves a source file out.
+#[derive(Debug, Clone, PartialEq, Eq, Hash, PartialOrd, Ord, Serialize)]
+#[serde(rename_all = "snake_case")]
+pub enum Exclusion {
+    /// Ignored by `.gitignore`, `.codeprysmignore`, or git's exclude files
+    IgnoreFile,
+    /// Matches an exclude pattern of the configuration
+    ExcludePattern(String),
+    /// Matches none of the include patterns of the configuration
+    NotIncluded,
+    /// Rejected by its language's file policy
+    Policy(PolicyExclusion),
+    /// Past the builder's file limit
+    FileLimit,
+}
+
+impl fmt::Display for Exclusion {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        match self {
+            Self::IgnoreFile => f.write_str("ignore file (.gitignore, .codeprysmignore)"),
+            Self::ExcludePattern(pattern) => write!(f, "exclude pattern '{}'", pattern),
+            Self::NotIncluded => f.write_str("not matched by include patterns"),
+            Self::Policy(reason) => reason.fmt(f),
+            Self::FileLimit => f.write_str("over the file limit"),
+        }
+    }
+}
+
+/// What a build does with a source file.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize)]
+#[serde(tag = "action", rename_all = "snake_case")]
+pub enum FileAction {
+    /// Parse and extract the file
+    Parse,
+    /// Reuse the file's cached extraction
+    Cached {
+        /// Nodes of the cached extraction
+        nodes: usize,
+    },
+    /// Leave the file out
+    Excluded {
+        /// Why the file is left out
+        reason: Exclusion,
+    },
+}
+
+/// A source file of a plan.
+#[derive(Debug, Clone, Serialize)]
+pub struct PlannedFile {
+    /// Path relative to the workspace
+    pub path: String,
+    /// File size in bytes
+    pub bytes: u64,
+    /// What the build does with the file
+    #[serde(flatten)]
+    pub action: FileAction,
+}
+
+/// What a build of a workspace would do.
+#[derive(Debug, Clone, Default, Serialize)]
+pub struct IndexPlan {
+    /// Files to parse
+    pub parse: usize,
+    /// Files reused from the extraction cache
+    pub cached: usize,
+    /// Files left out
+    pub excluded: usize,
+    /// Estimated nodes of the graph built
+    pub estimated_nodes: usize,
+    /// Every source file found, by path
+    pub files: Vec<PlannedFile>,
+}
+
+impl IndexPlan {
+    /// Files left out, grouped by reason
+    pub fn exclusions(&self) -> BTreeMap<&Exclusion, Vec<&PlannedFile>> {
+        let mut groups: BTreeMap<&Exclusion, Vec<&PlannedFile>> = BTreeMap::new();
+        for file in &self.files {
+            if let FileAction::Excluded { reason } = &file.action {
+                groups.entry(reason).or_default().push(file);
+            }
+        }
+        groups
+    }
+
+    /// Bytes of the files to parse
+    pub fn parse_bytes(&self) -> u64 {
+        self.files
+            .iter()
+            .filter(|file| file.action == FileAction::Parse)
+            .map(|file| file.bytes)
+            .sum()
+    }
+
+    /// Count the files and estimate the nodes
+    fn summarize(&mut self) {
+        let mut cached_nodes = 0;
+        let mut cached_bytes = 0;
+        for file in &self.files {
+            match file.action {
+                FileAction::Parse => self.parse += 1,
+                FileAction::Cached { nodes } => {
+                    self.cached += 1;
+                    cached_nodes += nodes;
+                    cached_bytes += file.bytes;
+                }
+                FileAction::Excluded { .. } => self.excluded += 1,
+            }
+        }
+        let per_byte = if cached_bytes > 0 {
+            cached_nodes as f64 / cached_bytes as f64
+        } else {
+            DEFAULT_NODES_PER_KIB / 1024.0
+        };
+        // Every parsed file has at least its file node
+        let parsed_nodes: usize = self
+            .files
+            .iter()
+            .filter(|file| file.action == FileAction::Parse)
+            .map(|file| ((file.bytes as f64 * per_byte).round() as usize).max(1))
+            .sum();
+        self.estimated_nodes = cached_nodes + parsed_nodes;
+    }
+}
+
+/// Plan a build of a workspace with `builder`, reading files but parsing
+/// none.
+///
+/// Roots are discovered as [`GraphBuilder::build_from_workspace`] does, and
+/// each root's files are classified with the builder's exclude and include
+/// patterns, file policy, file limit, and extraction cache. Only files of
+/// supported languages are listed; hidden files are never indexed and are
+/// left out of the plan.
+pub fn plan_workspace(builder: &GraphBuilder, workspace: &Path) -> Result<IndexPlan, BuilderError> {
+    let workspace = workspace.canonicalize()?;
+    let _span = info_span!("plan", root = %workspace.display()).entered();
+    let roots = RootDiscovery::with_defaults()
+        .discover(&workspace)
+        .map_err(|e| BuilderError::Io(std::io::Error::other(e.to_string())))?;
+
+    let mut plan = IndexPlan::default();
+    if roots.len() == 1 && roots[0].relative_path == "." {
+        plan.files = plan_directory(builder, &workspace, "")?;
+    } else {
+        for root in &roots {
+            plan.files
+                .extend(plan_directory(builder, &root.path, &root.relative_path)?);
+        }
+    }
+    plan.summarize();
+    info!(
+        "Planned {} file(s): {} to parse, {} cached, {} excluded",
+        plan.files.len(),
+        plan.parse,
+        plan.cached,
+        plan.excluded
+    );
+    Ok(plan)
+}
+
+/// Classify the source files of one root, with paths under `prefix`
+fn plan_directory(
+    builder: &GraphBuilder,
+    directory: &Path,
+    prefix: &str,
+) -> Result<Vec<PlannedFile>, BuilderError> {
+    let config = builder.config();
+    let excludes: Vec<(&str, GlobMatcher)> = config
+        .exclude_patterns
+        .iter()
+        .filter_map(|pattern| Some((pattern.as_str(), Glob::new(pattern).ok()?.compile_matcher())))
+        .collect();
+    let include_set =
+        (!config.include_patterns.is_empty()).then(|| build_glob_set(&config.include_patterns));
+    let file_filter = config.file_policy.compile();
+
+    let unignored: HashSet<PathBuf> = source_files(directory, true).into_iter().collect();
+    let mut files = Vec::new();
+    let mut indexed = 0;
+    for path in source_files(directory, false) {
+        let rel_path = path
+            .strip_prefix(directory)
+            .unwrap_or(&path)
+            .to_string_lossy()
+            .to_string();
+        let bytes = std::fs::metadata(&path).map(|m| m.len()).unwrap_or(0);
+
+        let reason = if !unignored.contains(&path) {
+            Some(Exclusion::IgnoreFile)
+        } else if let Some((pattern, _)) = excludes.iter().find(|(_, m)| m.is_match(&rel_path)) {
+            Some(Exclusion::ExcludePattern(pattern.to_string()))
+        } else if include_set
+            .as_ref()
+            .is_some_and(|include| !include.is_match(&rel_path))
+        {
+            Some(Exclusion::NotIncluded)
+        } else if let Some(reason) = file_filter.exclusion(&rel_path, &path) {
+            Some(Exclusion::Policy(reason))
+        } else if config.max_files.is_some_and(|max| indexed >= max) {
+            Some(Exclusion::FileLimit)
+        } else {
+            None
+        };
+
+        let action = match reason {
+            Some(reason) => FileAction::Excluded { reason },
+            None => {
+                indexed += 1;
+                let cached = builder.cache().and_then(|cache| {
+                    let content = std::fs::read(&path).ok()?;
+                    cache
+                        .get(&rel_path, &compute_content_hash(&content))
+                        .map(|extraction| extraction.nodes.len())
+                });
+                match cached {
+                    Some(nodes) => FileAction::Cached { nodes },
+                    None => FileAction::Parse,
+                }
+            }
+        };
+        let path = if prefix.is_empty() || prefix == "." {
+            rel_path
+        } else {
+            format!("{}/{}", prefix, rel_path)
+        };
+        files.push(PlannedFile {
+            path,
+            bytes,
+            action,
+        });
+    }
+    Ok(files)
+}
+
+/// Source files under a directory in path order, skipping hidden files and,
+/// if `ignore_files`, the files ignore files exclude as the builder does
+fn source_files(directory: &Path, ignore_files: bool) -> Vec<PathBuf> {
+    let mut walker = WalkBuilder::new(directory);
+    walker
+        .follow_links(false)
+        .hidden(true)
+        .parents(ignore_files)
+        .ignore(ignore_files)
+        .git_ignore(ignore_files)
+        .git_global(ignore_files)
+        .git_exclude(ignore_files)
+        .require_git(false);
+    if ignore_files {
+        walker.add_custom_ignore_filename(".codeprysmignore");
+    }
+
+    let mut files: Vec<PathBuf> = walker
+        .build()
+        .filter_map(|entry| match entry {
+            Ok(entry) => Some(entry),
+            Err(e) => {
+                debug!("Error walking directory: {}", e);
+                None
+            }
+        })
+        .filter(|entry| entry.file_type().is_some_and(|ft| ft.is_file()))
+        .map(|entry| entry.into_path())
+        .filter(|path| SupportedLanguage::from_path(path).is_some())
+        .collect();
+    files.sort();
+    files
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::builder::BuilderConfig;
+    use crate::extraction_cache::ExtractionCache;
+    use crate::file_policy::{FilePolicy, LanguageRules};
+    use std::collections::HashMap;
+
+    fn write(root: &Path, path: &str, content: &str) {
+        let path = root.join(path);
+        std::fs::create_dir_all(path.parent().unwrap()).unwrap();
+        std::fs::write(path, content).unwrap();
+    }
+
+    fn action<'a>(plan: &'a IndexPlan, path: &str) -> &'a FileAction {
+        &plan
+            .files
+            .iter()
+            .find(|file| file.path == path)
+            .unwrap_or_else(|| panic!("{} not planned", path))
+            .action
+    }
+
+    fn excluded(reason: Exclusion) -> FileAction {
+        FileAction::Excluded { reason }
+    }
+
+    #[test]
+    fn test_plan_classifies_files() {
+        let repo = tempfile::tempdir().unwrap();
+        let root = repo.path();
+        // A repository, so its directories are not discovered as roots
+        std::fs::create_dir(root.join(".git")).unwrap();
+        write(root, ".gitignore", "build/\n");
+        write(root, "main.py", "def main():\n    return 1\n");
+        write(root, "build/out.py", "x = 1\n");
+        write(root, "vendor/lib.py", "y = 2\n");
+        write(
+            root,
+            "gen/api.pb.go",
+            "// Code generated by protoc. DO NOT EDIT.\npackage gen\n",
+        );
+        write(root, "poll_windows.go", "package poll\n");
+        write(root, ".hidden/skip.py", "z = 3\n");
+        write(root, "README.md", "# Readme\n");
+
+        let config = BuilderConfig {
+            exclude_patterns: vec!["vendor/**".to_string()],
+            file_policy: FilePolicy {
+                skip_generated: false,
+                languages: HashMap::from([(
+                    "go".to_string(),
+                    LanguageRules {
+                        skip_generated: Some(true),
+                        target: Some("linux".to_string()),
+                        ..Default::default()
+                    },
+                )]),
+            },
+            ..Default::default()
+        };
+        let builder = GraphBuilder::with_embedded_queries(config);
+        let plan = plan_workspace(&builder, root).unwrap();
+
+        assert_eq!(action(&plan, "main.py"), &FileAction::Parse);
+        assert_eq!(
+            action(&plan, "build/out.py"),
+            &excluded(Exclusion::IgnoreFile)
+        );
+        assert_eq!(
+            action(&plan, "vendor/lib.py"),
+            &excluded(Exclusion::ExcludePattern("vendor/**".to_string()))
+        );
+        assert_eq!(
+            action(&plan, "gen/api.pb.go"),
+            &excluded(Exclusion::Policy(PolicyExclusion::Generated))
+        );
+        assert_eq!(
+            action(&plan, "poll_windows.go"),
+            &excluded(Exclusion::Policy(PolicyExclusion::OtherPlatform))
+        );
+        // Hidden and unsupported files are not listed
+        assert_eq!(plan.files.len(), 5);
+        assert_eq!((plan.parse, plan.cached, plan.excluded), (1, 0, 4));
+        assert_eq!(plan.exclusions().len(), 4);
+        assert!(plan.estimated_nodes >= 1);
+
+        // The planned files are the ones the builder collects
+        let collected = builder.collect_files(root).unwrap();
+        assert_eq!(collected, vec![root.join("main.py")]);
+    }
+
+    #[test]
+    fn test_plan_uses_cache_and_file_limit() {
+        let repo = tempfile::tempdir().unwrap();
+        let prism = tempfile::tempdir().unwrap();
+        let root = repo.path();
+        write(root, "a.py", "def a():\n    return 1\n");
+        write(root, "b.py", "def b():\n    return 2\n");
+        write(root, "c.py", "def c():\n    return 3\n");
+
+        let mut builder = GraphBuilder::new_with_embedded_queries()
+            .with_cache(ExtractionCache::load(prism.path()));
+        let graph = builder.build_from_directory(root).unwrap();
+        builder.cache().unwrap().save().unwrap();
+        write(root, "b.py", "def b():\n    return 20\n");
+
+        let config = BuilderConfig {
+            max_files: Some(2),
+            ..Default::default()
+        };
+        let builder = GraphBuilder::with_embedded_queries(config)
+            .with_cache(ExtractionCache::load(prism.path()));
+        let plan = plan_workspace(&builder, root).unwrap();
+
+        let a_nodes = graph.iter_nodes().filter(|n| n.file == "a.py").count();
+        assert_eq!(
+            action(&plan, "a.py"),
+            &FileAction::Cached { nodes: a_nodes }
+        );
+        assert_eq!(action(&plan, "b.py"), &FileAction::Parse);
+        assert_eq!(action(&plan, "c.py"), &excluded(Exclusion::FileLimit));
+        // The changed file is estimated at the density of the cached one
+        assert_eq!(plan.estimated_nodes, a_nodes * 2);
+    }
+}
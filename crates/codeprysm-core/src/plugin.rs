@@ -0,0 +1,507 @@
+//! Plugins
+//!
+//! External programs that add node/edge extractors (e.g., for an in-house
+//! framework's route or DI declarations) and custom analyses without forking
+//! the core. A plugin is any executable speaking JSON-RPC 2.0 over stdin and
+//! stdout, one message per line:
+//!
+//! - `initialize` `{}` -> `{"name", "extractor"?: {"files": [glob]},
+//!   "analyses"?: [{"name", "description"}]}`
+//! - `extract` `{"path", "source"}` -> `{"nodes": [Node], "edges": [Edge]}`,
+//!   called for every file matching the extractor's globs
+//! - `analyze` `{"analysis", "graph": {"nodes", "edges"}, "args"}` ->
+//!   `{"findings": [{"message", "severity"?, "node"?, "file"?, "line"?}]}`
+//! - `shutdown` (notification): the plugin should exit
+//!
+//! Nodes and edges use the graph's JSON schema (as in `codeprysm graph
+//! export --format json`). Extracted nodes are placed under their file's node
+//! when it exists; edges whose ends are not in the graph are dropped. A plugin
+//! may write diagnostics to stderr, which is passed through.
+
+use std::io::{BufRead, BufReader, Write};
+use std::path::Path;
+use std::process::{Child, ChildStdin, ChildStdout, Command, Stdio};
+use std::time::{Duration, Instant};
+
+use globset::{Glob, GlobSet, GlobSetBuilder};
+use ignore::WalkBuilder;
+use serde::{Deserialize, Serialize};
+use serde_json::{json, Value};
+use thiserror::Error;
+use tracing::{debug, info_span, warn};
+
+use crate::graph::{Edge, Node, PetCodeGraph};
+
+/// How long a plugin gets to exit after `shutdown` before it is killed
+const SHUTDOWN_TIMEOUT: Duration = Duration::from_secs(2);
+
+/// Errors that can occur while talking to a plugin.
+#[derive(Debug, Error)]
+pub enum PluginError {
+    /// The plugin has no command
+    #[error("Plugin '{0}' has no command")]
+    NoCommand(String),
+
+    /// The plugin could not be started
+    #[error("Failed to start plugin '{name}': {source}")]
+    Spawn {
+        name: String,
+        source: std::io::Error,
+    },
+
+    /// Reading from or writing to the plugin failed
+    #[error("Plugin '{name}' I/O error: {source}")]
+    Io {
+        name: String,
+        source: std::io::Error,
+    },
+
+    /// The plugin sent something other than the expected response
+    #[error("Plugin '{name}' protocol error: {message}")]
+    Protocol { name: String, message: String },
+
+    /// The plugin answered with a JSON-RPC error
+    #[error("Plugin '{name}' error {code}: {message}")]
+    Remote {
+        name: String,
+        code: i64,
+        message: String,
+    },
+}
+
+/// What a plugin provides, from its `initialize` response.
+#[derive(Debug, Clone, Default, Serialize, Deserialize)]
+#[serde(default)]
+pub struct Capabilities {
+    /// Name the plugin reports for itself
+    pub name: String,
+    /// Extractor, if the plugin adds nodes and edges
+    pub extractor: Option<ExtractorInfo>,
+    /// Analyses the plugin can run
+    pub analyses: Vec<AnalysisInfo>,
+}
+
+/// Files an extractor plugin handles.
+#[derive(Debug, Clone, Default, Serialize, Deserialize)]
+#[serde(default)]
+pub struct ExtractorInfo {
+    /// Glob patterns of file paths relative to the workspace root
+    pub files: Vec<String>,
+}
+
+/// An analysis offered by a plugin.
+#[derive(Debug, Clone, Default, Serialize, Deserialize)]
+#[serde(default)]
+pub struct AnalysisInfo {
+    /// Name passed to `analyze`
+    pub name: String,
+    /// One-line description
+    pub description: String,
+}
+
+/// Nodes and edges extracted from one file.
+#[derive(Debug, Clone, Default, Serialize, Deserialize)]
+#[serde(default)]
+pub struct Extraction {
+    pub nodes: Vec<Node>,
+    pub edges: Vec<Edge>,
+}
+
+/// One result of a plugin analysis.
+#[derive(Debug, Clone, Default, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(default)]
+pub struct Finding {
+    /// What was found
+    pub message: String,
+    /// Severity as reported by the plugin (e.g., "error", "warning")
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub severity: Option<String>,
+    /// Node ID the finding is about
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub node: Option<String>,
+    /// File path relative to the workspace root
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub file: Option<String>,
+    /// Line number (1-indexed)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub line: Option<usize>,
+}
+
+/// Result of running extractor plugins over a workspace.
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq)]
+pub struct ExtractStats {
+    /// Files sent to a plugin
+    pub files: usize,
+    /// Nodes added
+    pub nodes: usize,
+    /// Edges added
+    pub edges: usize,
+    /// Files a plugin failed on
+    pub errors: usize,
+}
+
+/// A running plugin process.
+pub struct Plugin {
+    name: String,
+    child: Child,
+    /// Taken on drop to close the pipe
+    stdin: Option<ChildStdin>,
+    stdout: BufReader<ChildStdout>,
+    next_id: u64,
+    capabilities: Capabilities,
+}
+
+impl Plugin {
+    /// Start a plugin and exchange `initialize`.
+    ///
+    /// `command` is the program and its arguments; it runs in `cwd`.
+    pub fn spawn(name: &str, command: &[String], cwd: &Path) -> Result<Self, PluginError> {
+        let (program, args) = command
+            .split_first()
+            .ok_or_else(|| PluginError::NoCommand(name.to_string()))?;
+        let mut child = Command::new(program)
+            .args(args)
+            .current_dir(cwd)
+            .stdin(Stdio::piped())
+            .stdout(Stdio::piped())
+            .stderr(Stdio::inherit())
+            .spawn()
+            .map_err(|source| PluginError::Spawn {
+                name: name.to_string(),
+                source,
+            })?;
+        let stdin = child.stdin.take();
+        let stdout = BufReader::new(child.stdout.take().expect("piped stdout"));
+
+        let mut plugin = Self {
+            name: name.to_string(),
+            child,
+            stdin,
+            stdout,
+            next_id: 1,
+            capabilities: Capabilities::default(),
+        };
+        plugin.capabilities = plugin.call("initialize", json!({}))?;
+        Ok(plugin)
+    }
+
+    /// Get the configured plugin name.
+    pub fn name(&self) -> &str {
+        &self.name
+    }
+
+    /// Get what the plugin provides.
+    pub fn capabilities(&self) -> &Capabilities {
+        &self.capabilities
+    }
+
+    /// Extract nodes and edges from one file.
+    pub fn extract(&mut self, path: &str, source: &str) -> Result<Extraction, PluginError> {
+        self.call("extract", json!({ "path": path, "source": source }))
+    }
+
+    /// Run one of the plugin's analyses over a graph.
+    pub fn analyze(
+        &mut self,
+        analysis: &str,
+        graph: &PetCodeGraph,
+        args: Value,
+    ) -> Result<Vec<Finding>, PluginError> {
+        #[derive(Deserialize)]
+        struct Response {
+            #[serde(default)]
+            findings: Vec<Finding>,
+        }
+
+        let nodes: Vec<&Node> = graph.iter_nodes().collect();
+        let edges: Vec<Edge> = graph.iter_edges().collect();
+        let params = json!({
+            "analysis": analysis,
+            "graph": { "nodes": nodes, "edges": edges },
+            "args": args,
+        });
+        let response: Response = self.call("analyze", params)?;
+        Ok(response.findings)
+    }
+
+    /// Send a request and wait for its response
+    fn call<T: serde::de::DeserializeOwned>(
+        &mut self,
+        method: &str,
+        params: Value,
+    ) -> Result<T, PluginError> {
+        let id = self.next_id;
+        self.next_id += 1;
+        self.send(&json!({ "jsonrpc": "2.0", "id": id, "method": method, "params": params }))?;
+
+        let mut line = String::new();
+        loop {
+            line.clear();
+            let read = self.stdout.read_line(&mut line).map_err(|e| self.io(e))?;
+            if read == 0 {
+                return Err(self.protocol(format!("exited before answering '{}'", method)));
+            }
+            if line.trim().is_empty() {
+                continue;
+            }
+            let message: Value = serde_json::from_str(&line)
+                .map_err(|e| self.protocol(format!("invalid JSON: {}", e)))?;
+            // Notifications from the plugin are ignored
+            if message.get("id").and_then(Value::as_u64) != Some(id) {
+                debug!(
+                    "Ignoring message from plugin '{}': {}",
+                    self.name,
+                    line.trim()
+                );
+                continue;
+            }
+            if let Some(error) = message.get("error") {
+                return Err(PluginError::Remote {
+                    name: self.name.clone(),
+                    code: error.get("code").and_then(Value::as_i64).unwrap_or(0),
+                    message: error
+                        .get("message")
+                        .and_then(Value::as_str)
+                        .unwrap_or("unknown error")
+                        .to_string(),
+                });
+            }
+            let result = message.get("result").cloned().unwrap_or(Value::Null);
+            return serde_json::from_value(result)
+                .map_err(|e| self.protocol(format!("invalid result for '{}': {}", method, e)));
+        }
+    }
+
+    fn send(&mut self, message: &Value) -> Result<(), PluginError> {
+        let mut line = message.to_string();
+        line.push('\n');
+        let Some(stdin) = self.stdin.as_mut() else {
+            return Err(self.protocol("stdin is closed".to_string()));
+        };
+        stdin
+            .write_all(line.as_bytes())
+            .and_then(|_| stdin.flush())
+            .map_err(|e| self.io(e))
+    }
+
+    fn io(&self, source: std::io::Error) -> PluginError {
+        PluginError::Io {
+            name: self.name.clone(),
+            source,
+        }
+    }
+
+    fn protocol(&self, message: String) -> PluginError {
+        PluginError::Protocol {
+            name: self.name.clone(),
+            message,
+        }
+    }
+}
+
+impl Drop for Plugin {
+    fn drop(&mut self) {
+        let _ = self.send(&json!({ "jsonrpc": "2.0", "method": "shutdown" }));
+        // Closing stdin lets plugins that read until EOF exit too
+        drop(self.stdin.take());
+        let deadline = Instant::now() + SHUTDOWN_TIMEOUT;
+        while matches!(self.child.try_wait(), Ok(None)) && Instant::now() < deadline {
+            std::thread::sleep(Duration::from_millis(10));
+        }
+        let _ = self.child.kill();
+        let _ = self.child.wait();
+    }
+}
+
+/// Run the extractor plugins over the files under `root` and add what they
+/// extract to `graph`.
+///
+/// Files are walked like indexing does (respecting `.gitignore` and
+/// `.codeprysmignore`); paths are relative to `root`. A plugin failing on a
+/// file is logged and counted, and the remaining files are still processed.
+pub fn apply_extractors(
+    graph: &mut PetCodeGraph,
+    root: &Path,
+    plugins: &mut [Plugin],
+) -> ExtractStats {
+    let mut stats = ExtractStats::default();
+    let mut extractors: Vec<(&mut Plugin, GlobSet)> = Vec::new();
+    for plugin in plugins.iter_mut() {
+        let Some(extractor) = &plugin.capabilities.extractor else {
+            continue;
+        };
+        match file_globs(&extractor.files) {
+            Ok(globs) => extractors.push((plugin, globs)),
+            Err(e) => warn!("Plugin '{}' has invalid file patterns: {}", plugin.name, e),
+        }
+    }
+    if extractors.is_empty() {
+        return stats;
+    }
+
+    let walker = WalkBuilder::new(root)
+        .hidden(true)
+        .git_ignore(true)
+        .add_custom_ignore_filename(".codeprysmignore")
+        .build();
+    let mut files: Vec<String> = walker
+        .filter_map(|entry| entry.ok())
+        .filter(|entry| entry.file_type().is_some_and(|t| t.is_file()))
+        .filter_map(|entry| {
+            entry
+                .path()
+                .strip_prefix(root)
+                .ok()
+                .map(|p| p.to_string_lossy().replace('\\', "/"))
+        })
+        .collect();
+    files.sort();
+
+    for (plugin, globs) in &mut extractors {
+        let _span = info_span!("plugin", name = %plugin.name).entered();
+        for path in files.iter().filter(|path| globs.is_match(path.as_str())) {
+            let Ok(source) = std::fs::read_to_string(root.join(path)) else {
+                continue;
+            };
+            stats.files += 1;
+            match plugin.extract(path, &source) {
+                Ok(extraction) => {
+                    let (nodes, edges) = add_extraction(graph, path, extraction);
+                    stats.nodes += nodes;
+                    stats.edges += edges;
+                }
+                Err(e) => {
+                    warn!("{} (file {})", e, path);
+                    stats.errors += 1;
+                }
+            }
+        }
+    }
+    stats
+}
+
+/// Add extracted nodes under the file's node, then the edges between nodes
+/// that exist. Returns the number of nodes and edges added.
+fn add_extraction(graph: &mut PetCodeGraph, path: &str, extraction: Extraction) -> (usize, usize) {
+    let file_exists = graph.contains_node(path);
+    let mut nodes = 0;
+    for node in extraction.nodes {
+        let id = node.id.clone();
+        let is_new = !graph.contains_node(&id);
+        graph.add_node(node);
+        if is_new {
+            nodes += 1;
+            if file_exists && id != path {
+                graph.add_edge_from_struct(&Edge::contains(path.to_string(), id));
+            }
+        }
+    }
+
+    let mut edges = 0;
+    for edge in extraction.edges {
+        if graph.add_edge_from_struct(&edge).is_some() {
+            edges += 1;
+        } else {
+            debug!(
+                "Dropping plugin edge {} -> {}: unknown node",
+                edge.source, edge.target
+            );
+        }
+    }
+    (nodes, edges)
+}
+
+fn file_globs(patterns: &[String]) -> Result<GlobSet, globset::Error> {
+    let mut builder = GlobSetBuilder::new();
+    for pattern in patterns {
+        builder.add(Glob::new(pattern)?);
+    }
+    builder.build()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::{CallableKind, EdgeType};
+
+    /// A plugin answering requests 1 (initialize), 2 (extract), and 3
+    /// (analyze) with canned responses
+    #[cfg(unix)]
+    fn script_plugin(dir: &Path) -> Plugin {
+        let script = r#"
+read -r _
+echo '{"jsonrpc":"2.0","id":1,"result":{"name":"routes","extractor":{"files":["*.routes"]},"analyses":[{"name":"unrouted","description":"Handlers without routes"}]}}'
+read -r _
+echo '{"jsonrpc":"2.0","method":"log","params":{}}'
+echo '{"jsonrpc":"2.0","id":2,"result":{"nodes":[{"id":"app.routes:GET /users","name":"GET /users","type":"Data","kind":"value","file":"app.routes","line":1,"end_line":1}],"edges":[{"source":"app.routes:GET /users","target":"users.go:List","type":"USES"},{"source":"app.routes:GET /users","target":"missing","type":"USES"}]}}'
+read -r _
+echo '{"jsonrpc":"2.0","id":3,"result":{"findings":[{"message":"no route","node":"users.go:Get","severity":"warning"}]}}'
+read -r _
+"#;
+        std::fs::write(dir.join("plugin.sh"), script).unwrap();
+        std::fs::write(dir.join("app.routes"), "GET /users users.List\n").unwrap();
+        let command = vec!["sh".to_string(), "plugin.sh".to_string()];
+        Plugin::spawn("routes", &command, dir).unwrap()
+    }
+
+    #[cfg(unix)]
+    #[test]
+    fn test_plugin_extract_and_analyze() {
+        let dir = tempfile::tempdir().unwrap();
+        let mut plugins = vec![script_plugin(dir.path())];
+        assert_eq!(plugins[0].capabilities().name, "routes");
+        assert_eq!(plugins[0].capabilities().analyses[0].name, "unrouted");
+
+        let mut graph = PetCodeGraph::new();
+        graph.add_node(Node::source_file(
+            "app.routes".to_string(),
+            "app.routes".to_string(),
+            String::new(),
+            1,
+        ));
+        graph.add_node(Node::callable(
+            "users.go:List".to_string(),
+            "List".to_string(),
+            CallableKind::Function,
+            "users.go".to_string(),
+            1,
+            3,
+        ));
+
+        let stats = apply_extractors(&mut graph, dir.path(), &mut plugins);
+        assert_eq!(
+            stats,
+            ExtractStats {
+                files: 1,
+                nodes: 1,
+                edges: 1,
+                errors: 0
+            }
+        );
+        assert!(graph
+            .outgoing_edges("app.routes:GET /users")
+            .any(|(node, edge)| node.id == "users.go:List" && edge.edge_type == EdgeType::Uses));
+        assert!(graph
+            .outgoing_edges("app.routes")
+            .any(|(node, edge)| node.id == "app.routes:GET /users"
+                && edge.edge_type == EdgeType::Contains));
+
+        let findings = plugins[0].analyze("unrouted", &graph, json!({})).unwrap();
+        assert_eq!(findings[0].node.as_deref(), Some("users.go:Get"));
+        assert_eq!(findings[0].severity.as_deref(), Some("warning"));
+    }
+
+    #[test]
+    fn test_plugin_missing_command() {
+        let dir = tempfile::tempdir().unwrap();
+        assert!(matches!(
+            Plugin::spawn("empty", &[], dir.path()),
+            Err(PluginError::NoCommand(_))
+        ));
+        let missing = vec!["codeprysm-no-such-plugin".to_string()];
+        assert!(matches!(
+            Plugin::spawn("missing", &missing, dir.path()),
+            Err(PluginError::Spawn { .. })
+        ));
+    }
+}
@@ -0,0 +1,331 @@
+//! Monorepo Project Discovery
+//!
+//! Detects project boundaries inside a repository from the manifests that
+//! mark them (`go.mod`, `Cargo.toml`, `package.json`) and tags every node
+//! with the project it belongs to, in the `project` (name) and `project_root`
+//! (directory) attributes. A file belongs to the project of the nearest
+//! directory above it holding a manifest, so nested projects take their own
+//! files.
+//!
+//! Names come from the manifest: the Go module path, the `[package]` name of
+//! a crate, or the `name` of an npm package; the directory name otherwise.
+//! Virtual Cargo workspaces (a `[workspace]` without a `[package]`) are not
+//! projects themselves. Because the tags are attributes, they are kept in the
+//! index and can be filtered on like any other attribute (`project=api`).
+
+use std::collections::HashMap;
+use std::path::{Path, PathBuf};
+
+use serde::{Deserialize, Serialize};
+
+use crate::graph::PetCodeGraph;
+
+/// Attribute holding the name of a node's project
+pub const PROJECT_ATTRIBUTE: &str = "project";
+
+/// Attribute holding the root directory of a node's project, relative to the
+/// repository root ("." for the root itself)
+pub const PROJECT_ROOT_ATTRIBUTE: &str = "project_root";
+
+/// Ecosystem of a project, from its manifest.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum ProjectKind {
+    /// Go module (`go.mod`)
+    Go,
+    /// Rust crate (`Cargo.toml`)
+    Cargo,
+    /// npm package (`package.json`)
+    Npm,
+}
+
+impl ProjectKind {
+    /// Kinds in the order manifests are looked for in one directory
+    pub const ALL: [ProjectKind; 3] = [ProjectKind::Go, ProjectKind::Cargo, ProjectKind::Npm];
+
+    /// Get the string representation
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            ProjectKind::Go => "go",
+            ProjectKind::Cargo => "cargo",
+            ProjectKind::Npm => "npm",
+        }
+    }
+
+    /// Manifest file name
+    pub fn manifest(&self) -> &'static str {
+        match self {
+            ProjectKind::Go => "go.mod",
+            ProjectKind::Cargo => "Cargo.toml",
+            ProjectKind::Npm => "package.json",
+        }
+    }
+
+    /// Project name declared in a manifest, if any
+    pub fn declared_name(&self, manifest: &str) -> Option<String> {
+        match self {
+            ProjectKind::Go => manifest.lines().find_map(|line| {
+                let module = line.trim().strip_prefix("module")?;
+                (module.starts_with(char::is_whitespace) || module.starts_with('"'))
+                    .then(|| module.trim().trim_matches('"').to_string())
+            }),
+            ProjectKind::Cargo => cargo_package_name(manifest),
+            ProjectKind::Npm => serde_json::from_str::<serde_json::Value>(manifest)
+                .ok()?
+                .get("name")?
+                .as_str()
+                .map(str::to_string),
+        }
+        .filter(|name| !name.is_empty())
+    }
+}
+
+/// A project inside a repository.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct Project {
+    /// Name from the manifest, or the directory name
+    pub name: String,
+    /// Directory relative to the repository root ("." for the root)
+    pub root: String,
+    /// Ecosystem
+    pub kind: ProjectKind,
+    /// Manifest path relative to the repository root
+    pub manifest: String,
+}
+
+/// Finds the project of files in a repository, caching per directory.
+#[derive(Debug)]
+pub struct ProjectResolver {
+    repo_root: PathBuf,
+    /// Nearest project of each directory looked up (relative path)
+    dirs: HashMap<String, Option<Project>>,
+}
+
+impl ProjectResolver {
+    /// Create a resolver for the repository at `repo_root`
+    pub fn new(repo_root: impl Into<PathBuf>) -> Self {
+        Self {
+            repo_root: repo_root.into(),
+            dirs: HashMap::new(),
+        }
+    }
+
+    /// Project of a file (path relative to the repository root)
+    pub fn project_of(&mut self, file: &str) -> Option<&Project> {
+        let dir = Path::new(file)
+            .parent()
+            .and_then(Path::to_str)
+            .unwrap_or_default()
+            .to_string();
+        self.resolve(&dir);
+        self.dirs.get(&dir)?.as_ref()
+    }
+
+    /// Projects found so far, sorted by root
+    pub fn projects(&self) -> Vec<Project> {
+        let mut projects: Vec<Project> = self.dirs.values().flatten().cloned().collect();
+        projects.sort_by(|a, b| a.root.cmp(&b.root));
+        projects.dedup_by(|a, b| a.root == b.root);
+        projects
+    }
+
+    /// Fill the cache for `dir` and the directories above it
+    fn resolve(&mut self, dir: &str) {
+        if self.dirs.contains_key(dir) {
+            return;
+        }
+        let project = match project_at(&self.repo_root, dir) {
+            Some(project) => Some(project),
+            None if dir.is_empty() => None,
+            None => {
+                let parent = Path::new(dir)
+                    .parent()
+                    .and_then(Path::to_str)
+                    .unwrap_or_default()
+                    .to_string();
+                self.resolve(&parent);
+                self.dirs[&parent].clone()
+            }
+        };
+        self.dirs.insert(dir.to_string(), project);
+    }
+}
+
+/// Project whose manifest is in `dir` (relative to `repo_root`), if any
+fn project_at(repo_root: &Path, dir: &str) -> Option<Project> {
+    ProjectKind::ALL.into_iter().find_map(|kind| {
+        let manifest = Path::new(dir).join(kind.manifest());
+        let content = std::fs::read_to_string(repo_root.join(&manifest)).ok()?;
+        let declared = kind.declared_name(&content);
+        // A virtual workspace manifest only groups other crates
+        if kind == ProjectKind::Cargo && declared.is_none() && content.contains("[workspace]") {
+            return None;
+        }
+        let fallback = || {
+            Path::new(dir)
+                .file_name()
+                .or_else(|| repo_root.file_name())
+                .map(|name| name.to_string_lossy().to_string())
+                .unwrap_or_else(|| ".".to_string())
+        };
+        Some(Project {
+            name: declared.unwrap_or_else(fallback),
+            root: if dir.is_empty() {
+                ".".to_string()
+            } else {
+                dir.replace('\\', "/")
+            },
+            kind,
+            manifest: manifest.to_string_lossy().replace('\\', "/"),
+        })
+    })
+}
+
+/// `name` of the `[package]` table of a Cargo manifest
+fn cargo_package_name(manifest: &str) -> Option<String> {
+    let mut in_package = false;
+    for line in manifest.lines() {
+        let line = line.trim();
+        if line.starts_with('[') {
+            in_package = line == "[package]";
+            continue;
+        }
+        if !in_package {
+            continue;
+        }
+        if let Some((key, value)) = line.split_once('=') {
+            if key.trim() == "name" {
+                return Some(value.trim().trim_matches('"').to_string());
+            }
+        }
+    }
+    None
+}
+
+/// Tag every node of a repository's graph with its project.
+///
+/// A `project` attribute set by a user rule is kept. Returns the number of
+/// tagged nodes.
+pub fn attach_projects(repo_root: &Path, graph: &mut PetCodeGraph) -> usize {
+    let mut resolver = ProjectResolver::new(repo_root);
+    let mut tagged = 0;
+    for node in graph.inner_mut().node_weights_mut() {
+        if node.file.is_empty() {
+            continue;
+        }
+        let Some(project) = resolver.project_of(node.file.trim_start_matches("./")) else {
+            continue;
+        };
+        let attributes = node
+            .metadata
+            .attributes
+            .get_or_insert_with(Default::default);
+        attributes
+            .entry(PROJECT_ATTRIBUTE.to_string())
+            .or_insert_with(|| project.name.clone());
+        attributes
+            .entry(PROJECT_ROOT_ATTRIBUTE.to_string())
+            .or_insert_with(|| project.root.clone());
+        tagged += 1;
+    }
+    tagged
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::{CallableKind, Node};
+
+    #[test]
+    fn test_declared_name() {
+        assert_eq!(
+            ProjectKind::Go
+                .declared_name("// comment\nmodule github.com/acme/api\n\ngo 1.22\n")
+                .as_deref(),
+            Some("github.com/acme/api")
+        );
+        assert_eq!(ProjectKind::Go.declared_name("modules x\n"), None);
+        assert_eq!(
+            ProjectKind::Cargo
+                .declared_name(
+                    "[workspace]\nmembers = []\n[package]\nversion = \"1\"\nname = \"core\"\n"
+                )
+                .as_deref(),
+            Some("core")
+        );
+        assert_eq!(
+            ProjectKind::Cargo.declared_name("[dependencies]\nname = \"x\"\n"),
+            None
+        );
+        assert_eq!(
+            ProjectKind::Npm
+                .declared_name(r#"{"name": "@acme/web", "private": true}"#)
+                .as_deref(),
+            Some("@acme/web")
+        );
+        assert_eq!(ProjectKind::Npm.declared_name("{}"), None);
+    }
+
+    #[test]
+    fn test_attach_projects() {
+        let dir = tempfile::tempdir().unwrap();
+        let root = dir.path();
+        std::fs::write(root.join("package.json"), r#"{"name": "mono"}"#).unwrap();
+        std::fs::create_dir_all(root.join("services/api/internal")).unwrap();
+        std::fs::write(root.join("services/api/go.mod"), "module example.com/api\n").unwrap();
+        std::fs::create_dir_all(root.join("crates/util/src")).unwrap();
+        std::fs::write(root.join("crates/Cargo.toml"), "[workspace]\n").unwrap();
+        std::fs::write(
+            root.join("crates/util/Cargo.toml"),
+            "[package]\nname = \"util\"\n",
+        )
+        .unwrap();
+
+        let mut graph = PetCodeGraph::new();
+        for file in [
+            "services/api/internal/h.go",
+            "crates/util/src/lib.rs",
+            "crates/build.rs",
+            "web/app.ts",
+        ] {
+            graph.add_node(Node::callable(
+                format!("{}:f", file),
+                "f".to_string(),
+                CallableKind::Function,
+                file.to_string(),
+                1,
+                2,
+            ));
+        }
+        assert_eq!(attach_projects(root, &mut graph), 4);
+
+        let project = |id: &str| {
+            let attributes = graph.get_node(id).unwrap().metadata.attributes.clone();
+            let attributes = attributes.unwrap();
+            (
+                attributes[PROJECT_ATTRIBUTE].clone(),
+                attributes[PROJECT_ROOT_ATTRIBUTE].clone(),
+            )
+        };
+        assert_eq!(
+            project("services/api/internal/h.go:f"),
+            ("example.com/api".to_string(), "services/api".to_string())
+        );
+        assert_eq!(
+            project("crates/util/src/lib.rs:f"),
+            ("util".to_string(), "crates/util".to_string())
+        );
+        // The virtual workspace is skipped, so the root package owns the file
+        assert_eq!(
+            project("crates/build.rs:f"),
+            ("mono".to_string(), ".".to_string())
+        );
+        assert_eq!(project("web/app.ts:f").0, "mono");
+
+        let mut resolver = ProjectResolver::new(root);
+        resolver.project_of("services/api/internal/h.go");
+        resolver.project_of("web/app.ts");
+        let roots: Vec<String> = resolver.projects().into_iter().map(|p| p.root).collect();
+        assert_eq!(roots, vec![".", "services/api"]);
+    }
+}
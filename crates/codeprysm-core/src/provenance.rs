@@ -0,0 +1,227 @@
+//! Index Provenance
+//!
+//! Records what produced an index: the tool and graph schema versions, the
+//! tree-sitter ABI and per-language grammar and query versions, the indexed
+//! commit and whether the working tree had uncommitted changes, a hash of the
+//! configuration, and when the index was built. Builds save it next to the
+//! index, and exports, archives, and servers pass it on, so consumers can
+//! tell a stale graph, or one built with different parsers or settings,
+//! from a current one.
+
+use std::collections::BTreeMap;
+use std::path::Path;
+use std::process::Command;
+use std::time::{SystemTime, UNIX_EPOCH};
+
+use serde::{Deserialize, Serialize};
+use sha2::{Digest, Sha256};
+
+use crate::embedded_queries::{get_query, supported_languages};
+use crate::graph::GRAPH_SCHEMA_VERSION;
+
+/// File in the index directory holding the provenance of the last build
+pub const PROVENANCE_FILE: &str = "provenance.json";
+
+/// Number of hex digits kept from query and configuration digests
+const DIGEST_HEX_LEN: usize = 16;
+
+/// What produced an index.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct Provenance {
+    /// CodePrysm version that built the index
+    pub tool_version: String,
+    /// Graph schema version of nodes and edges
+    pub schema_version: String,
+    /// Tree-sitter ABI version of the parser library
+    pub parser_abi: usize,
+    /// Grammar and query versions by language
+    pub grammars: BTreeMap<String, GrammarVersion>,
+    /// Indexed commit SHA (None outside a git repository)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub commit: Option<String>,
+    /// Whether tracked files had uncommitted changes when indexed
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub dirty: Option<bool>,
+    /// Digest of the configuration the index was built with
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub config_hash: Option<String>,
+    /// When the index was built (seconds since the Unix epoch)
+    pub indexed_at: u64,
+}
+
+/// Versions of what extracts one language.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct GrammarVersion {
+    /// Tree-sitter ABI version the grammar was generated for
+    pub abi: usize,
+    /// Digest of the embedded tags query
+    pub queries: String,
+}
+
+impl Provenance {
+    /// Describe a build of the repository at `repo` made now.
+    pub fn collect(repo: &Path) -> Self {
+        let grammars = supported_languages()
+            .iter()
+            .map(|language| {
+                let queries = get_query(*language).map(|q| digest(q.as_bytes()));
+                let version = GrammarVersion {
+                    abi: language.tree_sitter_language().abi_version(),
+                    queries: queries.unwrap_or_default(),
+                };
+                // TSX shares the TypeScript name but has its own grammar
+                (format!("{:?}", language).to_lowercase(), version)
+            })
+            .collect();
+
+        let commit = git(repo, &["rev-parse", "HEAD"]);
+        let dirty = commit
+            .as_ref()
+            .and_then(|_| git(repo, &["status", "--porcelain", "--untracked-files=no"]))
+            .map(|status| !status.is_empty());
+
+        Self {
+            tool_version: env!("CARGO_PKG_VERSION").to_string(),
+            schema_version: GRAPH_SCHEMA_VERSION.to_string(),
+            parser_abi: tree_sitter::LANGUAGE_VERSION,
+            grammars,
+            commit,
+            dirty,
+            config_hash: None,
+            indexed_at: SystemTime::now()
+                .duration_since(UNIX_EPOCH)
+                .map(|d| d.as_secs())
+                .unwrap_or(0),
+        }
+    }
+
+    /// Record the configuration the index was built with.
+    ///
+    /// Map keys are sorted before hashing, so configurations held in hash
+    /// maps hash the same on every run.
+    pub fn with_config(mut self, config: &impl Serialize) -> Self {
+        self.config_hash = serde_json::to_value(config)
+            .ok()
+            .map(|value| digest(canonical(value).to_string().as_bytes()));
+        self
+    }
+
+    /// Save the provenance into an index directory.
+    pub fn save(&self, prism_dir: &Path) -> std::io::Result<()> {
+        let json = serde_json::to_string_pretty(self).map_err(std::io::Error::other)?;
+        std::fs::write(prism_dir.join(PROVENANCE_FILE), json)
+    }
+
+    /// Load the provenance saved in an index directory, if any.
+    pub fn load(prism_dir: &Path) -> Option<Self> {
+        let json = std::fs::read_to_string(prism_dir.join(PROVENANCE_FILE)).ok()?;
+        serde_json::from_str(&json).ok()
+    }
+
+    /// Flatten into key-value pairs, for formats without nested data
+    pub fn to_pairs(&self) -> Vec<(String, String)> {
+        let mut pairs = vec![
+            ("tool_version".to_string(), self.tool_version.clone()),
+            ("schema_version".to_string(), self.schema_version.clone()),
+            ("parser_abi".to_string(), self.parser_abi.to_string()),
+        ];
+        if let Some(ref commit) = self.commit {
+            pairs.push(("commit".to_string(), commit.clone()));
+        }
+        if let Some(dirty) = self.dirty {
+            pairs.push(("dirty".to_string(), dirty.to_string()));
+        }
+        if let Some(ref config_hash) = self.config_hash {
+            pairs.push(("config_hash".to_string(), config_hash.clone()));
+        }
+        pairs.push(("indexed_at".to_string(), self.indexed_at.to_string()));
+        for (language, grammar) in &self.grammars {
+            pairs.push((
+                format!("grammar.{}", language),
+                format!("abi {} queries {}", grammar.abi, grammar.queries),
+            ));
+        }
+        pairs
+    }
+}
+
+/// A JSON value with the keys of every object in sorted order
+fn canonical(value: serde_json::Value) -> serde_json::Value {
+    match value {
+        serde_json::Value::Object(map) => {
+            let sorted: BTreeMap<String, serde_json::Value> = map
+                .into_iter()
+                .map(|(key, value)| (key, canonical(value)))
+                .collect();
+            serde_json::Value::Object(sorted.into_iter().collect())
+        }
+        serde_json::Value::Array(values) => {
+            serde_json::Value::Array(values.into_iter().map(canonical).collect())
+        }
+        value => value,
+    }
+}
+
+/// Short hex digest of some bytes
+fn digest(bytes: &[u8]) -> String {
+    let digest = format!("{:x}", Sha256::digest(bytes));
+    digest[..DIGEST_HEX_LEN].to_string()
+}
+
+/// Run git in a repository, returning its trimmed output on success
+fn git(repo: &Path, args: &[&str]) -> Option<String> {
+    Command::new("git")
+        .args(args)
+        .current_dir(repo)
+        .output()
+        .ok()
+        .filter(|o| o.status.success())
+        .map(|o| String::from_utf8_lossy(&o.stdout).trim().to_string())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_collect_outside_git() {
+        let dir = tempfile::tempdir().unwrap();
+        let config = std::collections::HashMap::from([("go", 1), ("python", 2), ("rust", 3)]);
+        let provenance = Provenance::collect(dir.path()).with_config(&config);
+
+        assert_eq!(provenance.schema_version, GRAPH_SCHEMA_VERSION);
+        assert!(provenance.commit.is_none());
+        assert!(provenance.dirty.is_none());
+        assert_eq!(
+            provenance.config_hash.as_ref().unwrap().len(),
+            DIGEST_HEX_LEN
+        );
+        // Key order does not matter
+        let sorted: BTreeMap<_, _> = config.into_iter().collect();
+        assert_eq!(
+            Provenance::collect(dir.path())
+                .with_config(&sorted)
+                .config_hash,
+            provenance.config_hash
+        );
+
+        let go = &provenance.grammars["go"];
+        assert!(go.abi > 0);
+        assert_eq!(go.queries.len(), DIGEST_HEX_LEN);
+        assert!(provenance.grammars.contains_key("tsx"));
+    }
+
+    #[test]
+    fn test_save_and_load() {
+        let dir = tempfile::tempdir().unwrap();
+        assert!(Provenance::load(dir.path()).is_none());
+
+        let provenance = Provenance::collect(dir.path());
+        provenance.save(dir.path()).unwrap();
+        assert_eq!(Provenance::load(dir.path()), Some(provenance.clone()));
+
+        let pairs = provenance.to_pairs();
+        assert_eq!(pairs[0].0, "tool_version");
+        assert!(pairs.iter().any(|(key, _)| key == "grammar.python"));
+    }
+}
@@ -0,0 +1,294 @@
+//! Graph Shards
+//!
+//! Sharded indexing for monorepos. Each shard is built from a disjoint set of
+//! directories, possibly on a separate machine or CI job, with
+//! [`GraphBuilder::build_shard`]. A shard holds its files' entities and the
+//! references they make, unresolved, since a reference's target may live in
+//! another shard.
+//!
+//! [`merge_shards`] unifies the shards into one graph, deduplicating the
+//! repository node and repeated references, then runs reference resolution
+//! and clone linking across all of them, so the result matches a build of the
+//! whole repository.
+//!
+//! [`GraphBuilder::build_shard`]: crate::builder::GraphBuilder::build_shard
+
+use std::collections::{BTreeMap, HashMap, HashSet};
+use std::path::Path;
+
+use serde::{Deserialize, Serialize};
+use thiserror::Error;
+use tracing::{info, info_span};
+
+use crate::analysis::clones::{link_clones, CloneOptions};
+use crate::builder::{definitions, GraphBuilder, ReferenceInfo};
+use crate::graph::{Edge, Node, PetCodeGraph};
+use crate::type_usage::weigh_type_usage;
+
+/// Format version, bumped on incompatible changes
+pub const SHARD_VERSION: u32 = 1;
+
+/// Errors that can occur saving, loading, or merging shards.
+#[derive(Debug, Error)]
+pub enum ShardError {
+    /// IO error
+    #[error("IO error: {0}")]
+    Io(#[from] std::io::Error),
+
+    /// The shard file could not be encoded or decoded
+    #[error("Invalid shard file: {0}")]
+    Json(#[from] serde_json::Error),
+
+    /// The shard was written by an incompatible version
+    #[error("Unsupported shard version {found} (expected {expected})")]
+    Version { found: u32, expected: u32 },
+
+    /// Shards of different repositories
+    #[error("Shard of repository '{found}' cannot be merged into '{expected}'")]
+    Repository { expected: String, found: String },
+
+    /// Shards that are not disjoint
+    #[error("File '{file}' is in more than one shard")]
+    Overlap { file: String },
+}
+
+/// The entities of one shard of a repository, with unresolved references.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct GraphShard {
+    /// Format version
+    pub version: u32,
+    /// ID of the repository node
+    pub repository: String,
+    /// Directories of the shard, relative to the repository root
+    pub paths: Vec<String>,
+    /// Repository node, file nodes, and definition nodes
+    nodes: Vec<Node>,
+    /// CONTAINS and DEFINES edges
+    edges: Vec<Edge>,
+    /// References by referenced name
+    #[serde(serialize_with = "serialize_sorted")]
+    references: HashMap<String, Vec<ReferenceInfo>>,
+}
+
+/// Serialize a map with its keys in order
+fn serialize_sorted<S: serde::Serializer>(
+    map: &HashMap<String, Vec<ReferenceInfo>>,
+    serializer: S,
+) -> Result<S::Ok, S::Error> {
+    serializer.collect_map(map.iter().collect::<BTreeMap<_, _>>())
+}
+
+impl GraphShard {
+    /// Collect a shard from its extracted graph and references.
+    pub(crate) fn new(
+        repository: String,
+        paths: Vec<String>,
+        graph: &PetCodeGraph,
+        references: HashMap<String, Vec<ReferenceInfo>>,
+    ) -> Self {
+        // Sorted so a shard of the same files is written the same every time
+        let mut nodes: Vec<Node> = graph.iter_nodes().cloned().collect();
+        nodes.sort_by(|a, b| a.id.cmp(&b.id));
+        let mut edges: Vec<Edge> = graph.iter_edges().collect();
+        edges.sort_by(Edge::stable_cmp);
+        Self {
+            version: SHARD_VERSION,
+            repository,
+            paths,
+            nodes,
+            edges,
+            references,
+        }
+    }
+
+    /// Number of nodes
+    pub fn node_count(&self) -> usize {
+        self.nodes.len()
+    }
+
+    /// Number of edges, before cross-shard resolution
+    pub fn edge_count(&self) -> usize {
+        self.edges.len()
+    }
+
+    /// Number of unresolved references
+    pub fn reference_count(&self) -> usize {
+        self.references.values().map(Vec::len).sum()
+    }
+
+    /// Paths of the files in the shard
+    pub fn files(&self) -> impl Iterator<Item = &str> {
+        self.nodes
+            .iter()
+            .filter(|n| n.is_file())
+            .map(|n| n.id.as_str())
+    }
+
+    /// Save the shard to a file.
+    pub fn save(&self, path: &Path) -> Result<(), ShardError> {
+        let file = std::io::BufWriter::new(std::fs::File::create(path)?);
+        serde_json::to_writer(file, self)?;
+        Ok(())
+    }
+
+    /// Load a shard saved with [`save`](Self::save).
+    pub fn load(path: &Path) -> Result<Self, ShardError> {
+        let file = std::io::BufReader::new(std::fs::File::open(path)?);
+        let shard: Self = serde_json::from_reader(file)?;
+        if shard.version != SHARD_VERSION {
+            return Err(ShardError::Version {
+                found: shard.version,
+                expected: SHARD_VERSION,
+            });
+        }
+        Ok(shard)
+    }
+}
+
+/// Merge the shards of one repository into a graph.
+///
+/// Shards must be of the same repository and hold disjoint files. References
+/// recorded by more than one shard are resolved once.
+pub fn merge_shards(shards: &[GraphShard]) -> Result<PetCodeGraph, ShardError> {
+    let _span = info_span!("merge", shards = shards.len()).entered();
+    let mut graph = PetCodeGraph::new();
+    let Some(first) = shards.first() else {
+        return Ok(graph);
+    };
+
+    let mut files: HashSet<&str> = HashSet::new();
+    for shard in shards {
+        if shard.repository != first.repository {
+            return Err(ShardError::Repository {
+                expected: first.repository.clone(),
+                found: shard.repository.clone(),
+            });
+        }
+        if let Some(file) = shard.files().find(|file| !files.insert(*file)) {
+            return Err(ShardError::Overlap {
+                file: file.to_string(),
+            });
+        }
+    }
+
+    let mut references: HashMap<String, Vec<ReferenceInfo>> = HashMap::new();
+    let mut seen: HashSet<(&str, &str, usize)> = HashSet::new();
+    for shard in shards {
+        // The repository node is in every shard; keep the first
+        for node in &shard.nodes {
+            if !graph.contains_node(&node.id) {
+                graph.add_node(node.clone());
+            }
+        }
+        for edge in &shard.edges {
+            graph.add_edge_from_struct(edge);
+        }
+        for (name, refs) in &shard.references {
+            for reference in refs {
+                if seen.insert((name.as_str(), reference.source_id.as_str(), reference.line)) {
+                    references
+                        .entry(name.clone())
+                        .or_default()
+                        .push(reference.clone());
+                }
+            }
+        }
+    }
+    info!(
+        "Merged {} shards: {} nodes, {} references to resolve",
+        shards.len(),
+        graph.node_count(),
+        seen.len()
+    );
+
+    let defines = definitions(&graph);
+    GraphBuilder::resolve_references(&mut graph, &defines, &references);
+    let clone_count = {
+        let _span = info_span!("clones").entered();
+        link_clones(&mut graph, &CloneOptions::default())
+    };
+    info!("Linked {} clone pair(s) across shards", clone_count);
+    weigh_type_usage(&mut graph);
+
+    Ok(graph)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::EdgeType;
+    use std::path::PathBuf;
+
+    fn sorted_edges(graph: &PetCodeGraph) -> Vec<(String, String, &'static str)> {
+        let mut edges: Vec<_> = graph
+            .iter_edges()
+            .map(|e| (e.source, e.target, e.edge_type.as_str()))
+            .collect();
+        edges.sort();
+        edges
+    }
+
+    fn repo() -> tempfile::TempDir {
+        let repo = tempfile::tempdir().unwrap();
+        std::fs::create_dir_all(repo.path().join("lib")).unwrap();
+        std::fs::create_dir_all(repo.path().join("app")).unwrap();
+        std::fs::write(
+            repo.path().join("lib/util.py"),
+            "def helper():\n    return 1\n",
+        )
+        .unwrap();
+        std::fs::write(
+            repo.path().join("app/main.py"),
+            "from util import helper\n\ndef run():\n    return helper()\n",
+        )
+        .unwrap();
+        repo
+    }
+
+    #[test]
+    fn test_merge_matches_full_build() {
+        let repo = repo();
+        let full = GraphBuilder::new_with_embedded_queries()
+            .build_from_directory(repo.path())
+            .unwrap();
+
+        let mut builder = GraphBuilder::new_with_embedded_queries();
+        let lib = builder
+            .build_shard(repo.path(), &[PathBuf::from("lib")])
+            .unwrap();
+        let app = builder
+            .build_shard(repo.path(), &[PathBuf::from("./app")])
+            .unwrap();
+        assert_eq!(lib.files().collect::<Vec<_>>(), vec!["lib/util.py"]);
+        assert_eq!(app.paths, vec!["app"]);
+
+        // The call into lib is only resolved by the merge
+        assert!(!app.edges.iter().any(|e| e.edge_type == EdgeType::Uses));
+
+        let dir = tempfile::tempdir().unwrap();
+        lib.save(&dir.path().join("lib.json")).unwrap();
+        let lib = GraphShard::load(&dir.path().join("lib.json")).unwrap();
+
+        let merged = merge_shards(&[lib, app]).unwrap();
+        assert_eq!(merged.node_count(), full.node_count());
+        assert_eq!(sorted_edges(&merged), sorted_edges(&full));
+        assert!(merged
+            .edges_by_type(EdgeType::Uses)
+            .any(|(_, target, _)| target.id == "lib/util.py:helper"));
+    }
+
+    #[test]
+    fn test_merge_rejects_overlapping_shards() {
+        let repo = repo();
+        let mut builder = GraphBuilder::new_with_embedded_queries();
+        let all = builder
+            .build_shard(repo.path(), &[PathBuf::from(".")])
+            .unwrap();
+        let lib = builder
+            .build_shard(repo.path(), &[PathBuf::from("lib")])
+            .unwrap();
+
+        let result = merge_shards(&[all, lib]);
+        assert!(matches!(result, Err(ShardError::Overlap { file }) if file == "lib/util.py"));
+    }
+}
@@ -0,0 +1,229 @@
+//! Streaming Graph Output
+//!
+//! [`GraphBuilder::build_streaming`] hands each file's nodes and edges to a
+//! [`GraphSink`] as soon as the file is extracted, instead of accumulating the
+//! whole graph before writing it. Only what reference resolution and clone
+//! linking need (definitions, references, and clone fingerprints) is kept in
+//! memory, so peak memory stays bounded on very large inputs.
+//!
+//! Once every file is written, a final pass emits the USES and CLONE_OF edges
+//! and patches each file node with its reference resolution counts. Writing
+//! the stream into a [`PetCodeGraph`] yields the same graph as
+//! [`GraphBuilder::build_from_directory`].
+//!
+//! [`GraphBuilder::build_streaming`]: crate::builder::GraphBuilder::build_streaming
+//! [`GraphBuilder::build_from_directory`]: crate::builder::GraphBuilder::build_from_directory
+
+use std::io::{self, Write};
+
+use serde::{Deserialize, Serialize};
+
+use crate::graph::{Edge, Node, PetCodeGraph, GRAPH_SCHEMA_VERSION};
+
+/// Metadata of a node written earlier, known only after resolution.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct NodePatch {
+    /// ID of the patched node
+    pub id: String,
+    /// References from the node's file resolved to a definition
+    pub resolved_refs: u32,
+    /// References from the node's file left unresolved
+    pub unresolved_refs: u32,
+}
+
+/// Counts of what a streaming build wrote to its sink.
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq)]
+pub struct StreamStats {
+    /// Nodes written
+    pub nodes: usize,
+    /// Edges written, including the final pass
+    pub edges: usize,
+    /// Patches applied by the final pass
+    pub patches: usize,
+}
+
+/// Destination of a streaming build.
+///
+/// Nodes arrive before any edge that touches them. Patches arrive after all
+/// nodes and edges, followed by a single call to [`finish`](Self::finish).
+pub trait GraphSink {
+    /// Write a batch of nodes and the edges between them.
+    fn write(&mut self, nodes: &[Node], edges: &[Edge]) -> io::Result<()>;
+
+    /// Update a node written earlier.
+    fn patch(&mut self, patch: &NodePatch) -> io::Result<()>;
+
+    /// Flush the output once the build is complete.
+    fn finish(&mut self) -> io::Result<()>;
+}
+
+impl GraphSink for PetCodeGraph {
+    fn write(&mut self, nodes: &[Node], edges: &[Edge]) -> io::Result<()> {
+        for node in nodes {
+            if !self.contains_node(&node.id) {
+                self.add_node(node.clone());
+            }
+        }
+        for edge in edges {
+            self.add_edge_from_struct(edge);
+        }
+        Ok(())
+    }
+
+    fn patch(&mut self, patch: &NodePatch) -> io::Result<()> {
+        if let Some(node) = self.get_node_mut(&patch.id) {
+            node.metadata.resolved_refs = Some(patch.resolved_refs);
+            node.metadata.unresolved_refs = Some(patch.unresolved_refs);
+        }
+        Ok(())
+    }
+
+    fn finish(&mut self) -> io::Result<()> {
+        Ok(())
+    }
+}
+
+/// One line of a [`JsonLinesSink`] stream
+#[derive(Serialize)]
+#[serde(tag = "record", rename_all = "lowercase")]
+enum Record<'a> {
+    Header { schema_version: &'a str },
+    Node(&'a Node),
+    Edge(&'a Edge),
+    Patch(&'a NodePatch),
+}
+
+/// Writes the stream as JSON lines, one record per line.
+///
+/// The first record is a header carrying the graph `schema_version`. Each
+/// following record is a node, edge, or patch object with a `"record"` field
+/// naming which. Consumers apply patches to the node with the same ID.
+pub struct JsonLinesSink<W: Write> {
+    writer: W,
+    header_written: bool,
+}
+
+impl<W: Write> JsonLinesSink<W> {
+    /// Create a sink writing to `writer`.
+    pub fn new(writer: W) -> Self {
+        Self {
+            writer,
+            header_written: false,
+        }
+    }
+
+    /// Consume the sink, returning the writer.
+    pub fn into_inner(self) -> W {
+        self.writer
+    }
+
+    fn record(&mut self, record: &Record<'_>) -> io::Result<()> {
+        if !self.header_written {
+            self.header_written = true;
+            self.record(&Record::Header {
+                schema_version: GRAPH_SCHEMA_VERSION,
+            })?;
+        }
+        serde_json::to_writer(&mut self.writer, record)?;
+        self.writer.write_all(b"\n")
+    }
+}
+
+impl<W: Write> GraphSink for JsonLinesSink<W> {
+    fn write(&mut self, nodes: &[Node], edges: &[Edge]) -> io::Result<()> {
+        for node in nodes {
+            self.record(&Record::Node(node))?;
+        }
+        for edge in edges {
+            self.record(&Record::Edge(edge))?;
+        }
+        Ok(())
+    }
+
+    fn patch(&mut self, patch: &NodePatch) -> io::Result<()> {
+        self.record(&Record::Patch(patch))
+    }
+
+    fn finish(&mut self) -> io::Result<()> {
+        self.writer.flush()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::builder::GraphBuilder;
+
+    fn sorted_edges(graph: &PetCodeGraph) -> Vec<(String, String, &'static str)> {
+        let mut edges: Vec<_> = graph
+            .iter_edges()
+            .map(|e| (e.source, e.target, e.edge_type.as_str()))
+            .collect();
+        edges.sort();
+        edges
+    }
+
+    fn repo() -> tempfile::TempDir {
+        let repo = tempfile::tempdir().unwrap();
+        std::fs::create_dir_all(repo.path().join("lib")).unwrap();
+        std::fs::write(
+            repo.path().join("lib/util.py"),
+            "def helper():\n    return 1\n",
+        )
+        .unwrap();
+        std::fs::write(
+            repo.path().join("main.py"),
+            "from util import helper\n\ndef run():\n    return helper()\n",
+        )
+        .unwrap();
+        repo
+    }
+
+    #[test]
+    fn test_streamed_graph_matches_full_build() {
+        let repo = repo();
+        let full = GraphBuilder::new_with_embedded_queries()
+            .build_from_directory(repo.path())
+            .unwrap();
+
+        let mut streamed = PetCodeGraph::new();
+        let stats = GraphBuilder::new_with_embedded_queries()
+            .build_streaming(repo.path(), &mut streamed)
+            .unwrap();
+        assert_eq!(stats.nodes, full.node_count());
+        assert_eq!(stats.edges, full.edge_count());
+        assert_eq!(sorted_edges(&streamed), sorted_edges(&full));
+
+        let file = streamed.get_node("main.py").unwrap();
+        let expected = full.get_node("main.py").unwrap();
+        assert_eq!(file.metadata.resolved_refs, expected.metadata.resolved_refs);
+        assert_eq!(
+            file.metadata.unresolved_refs,
+            expected.metadata.unresolved_refs
+        );
+    }
+
+    #[test]
+    fn test_json_lines_sink_writes_patches_last() {
+        let repo = repo();
+        let mut sink = JsonLinesSink::new(Vec::new());
+        let stats = GraphBuilder::new_with_embedded_queries()
+            .build_streaming(repo.path(), &mut sink)
+            .unwrap();
+
+        let output = String::from_utf8(sink.into_inner()).unwrap();
+        let records: Vec<serde_json::Value> = output
+            .lines()
+            .map(|line| serde_json::from_str(line).unwrap())
+            .collect();
+        assert_eq!(records.len(), 1 + stats.nodes + stats.edges + stats.patches);
+        assert_eq!(records[0]["record"], "header");
+        assert_eq!(records[0]["schema_version"], GRAPH_SCHEMA_VERSION);
+        assert_eq!(records[1]["record"], "node");
+
+        let first_patch = records.iter().position(|r| r["record"] == "patch").unwrap();
+        assert!(records[first_patch..]
+            .iter()
+            .all(|r| r["record"] == "patch"));
+    }
+}
@@ -0,0 +1,586 @@
+//! String-Literal References
+//!
+//! Registries, reflection, and routers wire code together through strings
+//! that name it, which reference resolution cannot see. This pass finds
+//! string literals naming a symbol or a route and links the innermost symbol
+//! holding the literal (or its file) to what it names with REFERS_BY_NAME
+//! edges:
+//!
+//! - **Qualified names** (`"sample.SimpleCalculator"`, `"app::Handler"`):
+//!   a type or callable named by the last segment, whose package directory,
+//!   file, or enclosing type is named by the segment before it
+//! - **Bare names** (`t.Run("Add", ...)`, `getattr(obj, "handle_request")`):
+//!   a type or callable of that name in the same package or, unless the
+//!   literal is a plain lowercase word, the only one in the repository
+//! - **Routes** (`"/users/{id}"`, `"GET /users/{id}"`): the HTTP handler
+//!   registered with that path, from code using the same path elsewhere,
+//!   such as clients and tests. A registration is a line passing both the
+//!   path and a callable tagged as an `http_handler` entry point (see
+//!   [`crate::entry_points`]), which therefore have to be tagged first.
+//!
+//! Edges carry the line and the text of the literal. The analysis is
+//! textual, so the edges are weak: the analyses following USES edges (dead
+//! code, impact) do not follow them, and the same string can name unrelated
+//! things. Comments, docstrings, and Go raw strings (mostly struct tags) are
+//! not scanned.
+
+use std::collections::{HashMap, HashSet};
+use std::path::Path;
+
+use crate::analysis::api_surface::is_type;
+use crate::analysis::package_of;
+use crate::entry_points::EntryPointKind;
+use crate::graph::{EdgeData, EdgeType, Node, PetCodeGraph};
+use crate::parser::SupportedLanguage;
+
+/// Shortest bare name linked, to skip keys like `"id"` and `"ok"`
+const MIN_BARE_NAME_LEN: usize = 3;
+
+/// HTTP methods that may prefix a route pattern (`"GET /users"`)
+const HTTP_METHODS: &[&str] = &[
+    "GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "CONNECT", "OPTIONS", "TRACE",
+];
+
+/// Result of string-literal linking.
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq)]
+pub struct StringLinks {
+    /// Edges to symbols named by a literal
+    pub symbols: usize,
+    /// Edges to the handlers of routes
+    pub routes: usize,
+}
+
+/// A route handler's node ID, with the file and line registering it
+type Registration<'a> = (&'a str, (&'a str, usize));
+
+/// A string literal in a source file
+#[derive(Debug, Clone, PartialEq, Eq)]
+struct Literal {
+    line: usize,
+    text: String,
+}
+
+/// Link string literals naming symbols or routes, replacing earlier links.
+///
+/// `read_source` returns the content of a file by its path in the graph;
+/// files it cannot read are not scanned.
+pub fn link_string_references<F>(graph: &mut PetCodeGraph, mut read_source: F) -> StringLinks
+where
+    F: FnMut(&str) -> Option<String>,
+{
+    graph.remove_edges_by_type(EdgeType::RefersByName);
+
+    let mut literals: Vec<(String, Literal)> = Vec::new();
+    for node in graph.iter_nodes().filter(|n| n.is_file()) {
+        let Some(language) = SupportedLanguage::from_path(Path::new(&node.file)) else {
+            continue;
+        };
+        let Some(source) = read_source(&node.file) else {
+            continue;
+        };
+        for literal in string_literals(&source, language) {
+            literals.push((node.file.clone(), literal));
+        }
+    }
+
+    let (symbol_edges, route_edges) = {
+        let linker = Linker::new(graph);
+        let routes = linker.routes(&literals);
+        let mut symbol_edges = Vec::new();
+        let mut route_edges = Vec::new();
+        for (file, literal) in &literals {
+            let Some(source) = linker.enclosing(file, literal.line) else {
+                continue;
+            };
+            if let Some(path) = route_path(&literal.text) {
+                for (handler, registered_at) in routes.get(path).into_iter().flatten() {
+                    if *registered_at != (file.as_str(), literal.line) {
+                        route_edges.push((source, *handler, literal));
+                    }
+                }
+            } else {
+                for target in linker.named(file, &literal.text) {
+                    symbol_edges.push((source, target, literal));
+                }
+            }
+        }
+        (own(graph, symbol_edges), own(graph, route_edges))
+    };
+
+    let mut seen: HashSet<(String, String)> = HashSet::new();
+    let mut links = StringLinks::default();
+    for (edges, count) in [
+        (symbol_edges, &mut links.symbols),
+        (route_edges, &mut links.routes),
+    ] {
+        for (source, target, literal) in edges {
+            if !seen.insert((source.clone(), target.clone())) {
+                continue;
+            }
+            graph.add_edge(
+                &source,
+                &target,
+                EdgeData::refers_by_name(Some(literal.line), Some(literal.text)),
+            );
+            *count += 1;
+        }
+    }
+    links
+}
+
+/// Edges by node ID, dropping self-references and references from a symbol
+/// to one enclosing it
+fn own(graph: &PetCodeGraph, edges: Vec<(&str, &str, &Literal)>) -> Vec<(String, String, Literal)> {
+    edges
+        .into_iter()
+        .filter(|(source, target, _)| !encloses(graph, target, source))
+        .map(|(source, target, literal)| (source.to_string(), target.to_string(), literal.clone()))
+        .collect()
+}
+
+/// Check if `outer` is `inner` or contains it
+fn encloses(graph: &PetCodeGraph, outer: &str, inner: &str) -> bool {
+    let mut current = inner;
+    loop {
+        if current == outer {
+            return true;
+        }
+        match graph.parent(current) {
+            Some(parent) => current = parent.id.as_str(),
+            None => return false,
+        }
+    }
+}
+
+/// Symbol lookups over a graph
+struct Linker<'a> {
+    graph: &'a PetCodeGraph,
+    /// Types and callables by name
+    by_name: HashMap<&'a str, Vec<&'a Node>>,
+    /// Symbols of each file, by file
+    by_file: HashMap<&'a str, Vec<&'a Node>>,
+}
+
+impl<'a> Linker<'a> {
+    fn new(graph: &'a PetCodeGraph) -> Self {
+        let mut by_name: HashMap<&str, Vec<&Node>> = HashMap::new();
+        let mut by_file: HashMap<&str, Vec<&Node>> = HashMap::new();
+        for node in graph.iter_nodes() {
+            if node.is_file() || node.file.is_empty() {
+                continue;
+            }
+            by_file.entry(node.file.as_str()).or_default().push(node);
+            if node.is_callable() || is_type(node) {
+                by_name.entry(node.name.as_str()).or_default().push(node);
+            }
+        }
+        Self {
+            graph,
+            by_name,
+            by_file,
+        }
+    }
+
+    /// Innermost symbol of `file` spanning `line`, or the file itself
+    fn enclosing(&self, file: &str, line: usize) -> Option<&'a str> {
+        let innermost = self
+            .by_file
+            .get(file)
+            .into_iter()
+            .flatten()
+            .filter(|n| n.line <= line && line <= n.end_line)
+            .min_by_key(|n| (n.end_line - n.line, std::cmp::Reverse(n.line)));
+        match innermost {
+            Some(node) => Some(node.id.as_str()),
+            None => self.graph.get_node(file).map(|n| n.id.as_str()),
+        }
+    }
+
+    /// Symbols a literal in `file` names
+    fn named(&self, file: &str, text: &str) -> Vec<&'a str> {
+        let segments: Vec<&str> = text
+            .split(['.', '/', ':'])
+            .filter(|s| !s.is_empty())
+            .collect();
+        if segments.is_empty() || !segments.iter().all(|s| is_identifier(s)) {
+            return Vec::new();
+        }
+        let Some(candidates) = self.by_name.get(segments[segments.len() - 1]) else {
+            return Vec::new();
+        };
+
+        if let [.., qualifier, _] = segments[..] {
+            return candidates
+                .iter()
+                .filter(|n| self.qualified_by(n, qualifier))
+                .map(|n| n.id.as_str())
+                .collect();
+        }
+
+        if text.len() < MIN_BARE_NAME_LEN {
+            return Vec::new();
+        }
+        let package = package_of(file);
+        let local: Vec<&str> = candidates
+            .iter()
+            .filter(|n| package_of(&n.file) == package)
+            .map(|n| n.id.as_str())
+            .collect();
+        if !local.is_empty() {
+            return local;
+        }
+        // Elsewhere, only an unambiguous identifier-like name
+        let word = text.chars().all(|c| c.is_ascii_lowercase());
+        match candidates[..] {
+            [only] if !word => vec![only.id.as_str()],
+            _ => Vec::new(),
+        }
+    }
+
+    /// Check if `qualifier` names the package, file, or enclosing type of a
+    /// node
+    fn qualified_by(&self, node: &Node, qualifier: &str) -> bool {
+        let package = package_of(&node.file);
+        let stem = Path::new(&node.file)
+            .file_stem()
+            .and_then(|s| s.to_str())
+            .unwrap_or_default();
+        package.rsplit('/').next() == Some(qualifier)
+            || stem == qualifier
+            || node.metadata.receiver.as_deref() == Some(qualifier)
+            || self
+                .graph
+                .parent(&node.id)
+                .is_some_and(|p| is_type(p) && p.name == qualifier)
+    }
+
+    /// Handlers of each route path, with the file and line registering them
+    fn routes(&self, literals: &'a [(String, Literal)]) -> HashMap<&'a str, Vec<Registration<'a>>> {
+        let at: HashMap<(&str, usize), &str> = literals
+            .iter()
+            .filter_map(|(file, literal)| {
+                Some(((file.as_str(), literal.line), route_path(&literal.text)?))
+            })
+            .collect();
+        let handler = EntryPointKind::HttpHandler.as_str();
+
+        let mut routes: HashMap<&str, Vec<Registration>> = HashMap::new();
+        for (source, target, edge) in self.graph.edges_by_type(EdgeType::Uses) {
+            if target.metadata.entry_point.as_deref() != Some(handler) {
+                continue;
+            }
+            let Some(line) = edge.ref_line else {
+                continue;
+            };
+            let Some((&site, &path)) = at.get_key_value(&(source.file.as_str(), line)) else {
+                continue;
+            };
+            let handlers = routes.entry(path).or_default();
+            if !handlers.iter().any(|(id, _)| *id == target.id) {
+                handlers.push((target.id.as_str(), site));
+            }
+        }
+        routes
+    }
+}
+
+/// Path of a route literal (`"/users/{id}"` or `"GET /users/{id}"`)
+fn route_path(text: &str) -> Option<&str> {
+    let path = match text.split_once(' ') {
+        Some((method, path)) if HTTP_METHODS.contains(&method) => path,
+        Some(_) => return None,
+        None => text,
+    };
+    (path.len() > 1 && path.starts_with('/') && !path.contains(char::is_whitespace)).then_some(path)
+}
+
+/// Check if text is an identifier (letters, digits, underscores, not
+/// starting with a digit)
+fn is_identifier(text: &str) -> bool {
+    text.starts_with(|c: char| c.is_alphabetic() || c == '_')
+        && text.chars().all(|c| c.is_alphanumeric() || c == '_')
+}
+
+/// Single-line string literals of a source file, outside comments.
+///
+/// Escapes are kept as written; literals with escapes or spanning lines
+/// never name a symbol or route anyway.
+fn string_literals(source: &str, language: SupportedLanguage) -> Vec<Literal> {
+    let python = language == SupportedLanguage::Python;
+    let quotes: &[char] = match language {
+        SupportedLanguage::Python
+        | SupportedLanguage::JavaScript
+        | SupportedLanguage::TypeScript
+        | SupportedLanguage::Tsx => &['"', '\''],
+        _ => &['"'],
+    };
+    let line_comment = if python { "#" } else { "//" };
+
+    let mut literals = Vec::new();
+    let mut line = 1;
+    let mut rest = source;
+    while let Some(c) = rest.chars().next() {
+        if c == '\n' {
+            line += 1;
+        }
+        // Comments
+        if rest.starts_with(line_comment) {
+            rest = &rest[rest.find('\n').unwrap_or(rest.len())..];
+            continue;
+        }
+        if !python && rest.starts_with("/*") {
+            let end = rest.find("*/").map_or(rest.len(), |i| i + 2);
+            line += rest[..end].matches('\n').count();
+            rest = &rest[end..];
+            continue;
+        }
+        // Docstrings and other triple-quoted strings
+        if python && (rest.starts_with("\"\"\"") || rest.starts_with("'''")) {
+            let end = rest[3..].find(&rest[..3]).map_or(rest.len(), |i| i + 6);
+            line += rest[..end].matches('\n').count();
+            rest = &rest[end..];
+            continue;
+        }
+        // Go raw strings and JavaScript templates
+        if c == '`' {
+            let end = rest[1..].find('`').map_or(rest.len(), |i| i + 2);
+            line += rest[..end].matches('\n').count();
+            rest = &rest[end..];
+            continue;
+        }
+        if quotes.contains(&c) {
+            let body = &rest[1..];
+            let mut escaped = false;
+            let close = body.char_indices().find(|&(_, ch)| {
+                let found = !escaped && (ch == c || ch == '\n');
+                escaped = !escaped && ch == '\\';
+                found
+            });
+            match close {
+                Some((end, ch)) if ch == c => {
+                    literals.push(Literal {
+                        line,
+                        text: body[..end].to_string(),
+                    });
+                    rest = &body[end + 1..];
+                }
+                // Unterminated on this line (or a Rust lifetime/char)
+                Some((end, _)) => rest = &body[end..],
+                None => rest = "",
+            }
+            continue;
+        }
+        rest = &rest[c.len_utf8()..];
+    }
+    literals
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::{CallableKind, ContainerKind, NodeType};
+
+    const REGISTRY: &str = r#"package registry
+
+// "sample.Ignored" in a comment
+var types = map[string]any{
+	"sample.SimpleCalculator": nil,
+	"json": nil,
+}
+"#;
+
+    const CALC_TEST: &str = r#"package sample
+
+func TestCalc(t *testing.T) {
+	t.Run("Add", func(t *testing.T) {})
+	t.Run("add twice", func(t *testing.T) {})
+}
+"#;
+
+    const ROUTES: &str = r#"package api
+
+func Routes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /users/{id}", getUser)
+}
+"#;
+
+    const CLIENT: &str = r#"package client
+
+func Fetch() {
+	get("/users/{id}")
+}
+"#;
+
+    fn file(graph: &mut PetCodeGraph, path: &str, lines: usize) {
+        graph.add_node(Node::source_file(
+            path.to_string(),
+            path.to_string(),
+            String::new(),
+            lines,
+        ));
+    }
+
+    fn symbol(
+        graph: &mut PetCodeGraph,
+        file: &str,
+        name: &str,
+        span: (usize, usize),
+        node_type: NodeType,
+    ) -> String {
+        let id = format!("{}:{}", file, name);
+        let mut node = Node::callable(
+            id.clone(),
+            name.to_string(),
+            CallableKind::Function,
+            file.to_string(),
+            span.0,
+            span.1,
+        );
+        if node_type == NodeType::Container {
+            node.node_type = NodeType::Container;
+            node.kind = Some(ContainerKind::Type.as_str().to_string());
+        } else if node_type == NodeType::Data {
+            node.node_type = NodeType::Data;
+            node.kind = Some("variable".to_string());
+        }
+        graph.add_node(node);
+        graph.add_edge(file, &id, EdgeData::contains());
+        id
+    }
+
+    #[test]
+    fn test_string_literals() {
+        let go = string_literals(
+            "x := \"a\\\"b\" // \"c\"\n/* \"d\"\n */ y := `e` + \"f\"\nr := 'g'",
+            SupportedLanguage::Go,
+        );
+        let texts: Vec<(usize, &str)> = go.iter().map(|l| (l.line, l.text.as_str())).collect();
+        assert_eq!(texts, vec![(1, "a\\\"b"), (3, "f")]);
+
+        let python = string_literals(
+            "def f():\n    \"\"\"Doc \"x\".\"\"\"\n    return getattr(o, 'handle')  # \"y\"\n",
+            SupportedLanguage::Python,
+        );
+        assert_eq!(
+            python,
+            vec![Literal {
+                line: 3,
+                text: "handle".to_string()
+            }]
+        );
+    }
+
+    #[test]
+    fn test_route_path() {
+        assert_eq!(route_path("/users/{id}"), Some("/users/{id}"));
+        assert_eq!(route_path("GET /users"), Some("/users"));
+        assert_eq!(route_path("/"), None);
+        assert_eq!(route_path("see /users"), None);
+        assert_eq!(route_path("users"), None);
+    }
+
+    #[test]
+    fn test_link_string_references() {
+        let mut graph = PetCodeGraph::new();
+        file(&mut graph, "registry/types.go", 7);
+        let types = symbol(
+            &mut graph,
+            "registry/types.go",
+            "types",
+            (4, 7),
+            NodeType::Data,
+        );
+        file(&mut graph, "sample/calc.go", 20);
+        let calc = symbol(
+            &mut graph,
+            "sample/calc.go",
+            "SimpleCalculator",
+            (1, 10),
+            NodeType::Container,
+        );
+        let add = symbol(
+            &mut graph,
+            "sample/calc.go",
+            "Add",
+            (12, 14),
+            NodeType::Callable,
+        );
+        symbol(
+            &mut graph,
+            "sample/calc.go",
+            "Ignored",
+            (16, 18),
+            NodeType::Callable,
+        );
+        file(&mut graph, "sample/calc_test.go", 6);
+        let test = symbol(
+            &mut graph,
+            "sample/calc_test.go",
+            "TestCalc",
+            (3, 6),
+            NodeType::Callable,
+        );
+        file(&mut graph, "api/routes.go", 5);
+        let routes = symbol(
+            &mut graph,
+            "api/routes.go",
+            "Routes",
+            (3, 5),
+            NodeType::Callable,
+        );
+        let handler = symbol(
+            &mut graph,
+            "api/routes.go",
+            "getUser",
+            (7, 9),
+            NodeType::Callable,
+        );
+        graph.get_node_mut(&handler).unwrap().metadata.entry_point =
+            Some(EntryPointKind::HttpHandler.as_str().to_string());
+        graph.add_edge(&routes, &handler, EdgeData::uses(Some(4), None));
+        file(&mut graph, "client/fetch.go", 5);
+        let fetch = symbol(
+            &mut graph,
+            "client/fetch.go",
+            "Fetch",
+            (3, 5),
+            NodeType::Callable,
+        );
+
+        let sources: HashMap<&str, &str> = HashMap::from([
+            ("registry/types.go", REGISTRY),
+            ("sample/calc_test.go", CALC_TEST),
+            ("api/routes.go", ROUTES),
+            ("client/fetch.go", CLIENT),
+        ]);
+        let read = |file: &str| sources.get(file).map(|s| s.to_string());
+        let links = link_string_references(&mut graph, read);
+        assert_eq!(
+            links,
+            StringLinks {
+                symbols: 2,
+                routes: 1
+            }
+        );
+
+        let mut edges: Vec<(String, String, Option<String>)> = graph
+            .iter_edges()
+            .filter(|e| e.edge_type == EdgeType::RefersByName)
+            .map(|e| (e.source, e.target, e.ident))
+            .collect();
+        edges.sort();
+        assert_eq!(
+            edges,
+            vec![
+                (fetch, handler, Some("/users/{id}".to_string())),
+                (types, calc, Some("sample.SimpleCalculator".to_string())),
+                (test, add, Some("Add".to_string())),
+            ]
+        );
+
+        // Relinking replaces the earlier edges
+        let read = |file: &str| sources.get(file).map(|s| s.to_string());
+        link_string_references(&mut graph, read);
+        assert_eq!(graph.edges_by_type(EdgeType::RefersByName).count(), 3);
+    }
+}
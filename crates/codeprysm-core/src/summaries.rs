@@ -0,0 +1,382 @@
+//! Symbol Summaries
+//!
+//! Keeps one-paragraph summaries of functions, methods, and types, written by
+//! a language model outside this crate, in the node's `summary` attribute.
+//! Summaries are cached by the content hash of the symbol's source, so a
+//! build only asks for summaries of symbols that are new or have changed and
+//! sets the cached ones on everything else.
+//!
+//! The cache belongs to one model and prompt: callers pass a fingerprint of
+//! both, and a cache saved under another fingerprint loads as empty. Entries
+//! no build has used in the last [`RETAINED_BUILDS`] builds are pruned, as in
+//! the [extraction cache](crate::extraction_cache).
+
+use std::collections::HashMap;
+use std::path::{Path, PathBuf};
+
+use globset::{Glob, GlobSet, GlobSetBuilder};
+use serde::{Deserialize, Serialize};
+use sha2::{Digest, Sha256};
+use tracing::{debug, info, warn};
+
+use crate::extraction_cache::RETAINED_BUILDS;
+use crate::file_policy::is_test_file;
+use crate::graph::{Node, PetCodeGraph};
+
+/// File holding the summary cache
+const CACHE_FILE: &str = "summaries.json";
+
+/// Which symbols get a summary.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct SummaryOptions {
+    /// Glob patterns matched against file paths (any if empty)
+    pub paths: Vec<String>,
+    /// Symbols shorter than this many lines are skipped
+    pub min_lines: usize,
+    /// Source beyond this many lines is left out of the request
+    pub max_lines: usize,
+}
+
+impl Default for SummaryOptions {
+    fn default() -> Self {
+        Self {
+            paths: Vec::new(),
+            min_lines: 3,
+            max_lines: 200,
+        }
+    }
+}
+
+/// A symbol lacking a summary.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct SummaryRequest {
+    /// Node ID of the symbol
+    pub node_id: String,
+    /// Cache key: hash of the symbol's kind and source
+    pub key: String,
+    /// Symbol name
+    pub name: String,
+    /// Kind of symbol (e.g., "function", "method", "type")
+    pub kind: String,
+    /// File containing the symbol
+    pub file: String,
+    /// Language of the file, if known
+    pub language: Option<String>,
+    /// Source of the symbol, cut at `max_lines`
+    pub source: String,
+}
+
+/// A cached summary and the last build that used it
+#[derive(Debug, Serialize, Deserialize)]
+struct CacheEntry {
+    /// Generation of the last build that used the entry
+    used: u64,
+    summary: String,
+}
+
+/// On-disk layout of the cache
+#[derive(Deserialize)]
+struct CacheFile {
+    fingerprint: String,
+    generation: u64,
+    entries: HashMap<String, CacheEntry>,
+}
+
+/// [`CacheFile`] borrowing the entries to save
+#[derive(Serialize)]
+struct CacheFileRef<'a> {
+    fingerprint: &'a str,
+    generation: u64,
+    entries: HashMap<&'a str, &'a CacheEntry>,
+}
+
+/// Summaries by content hash, saved between builds.
+#[derive(Debug)]
+pub struct SummaryCache {
+    /// Cache file
+    path: PathBuf,
+    /// Model and prompt the summaries were written with
+    fingerprint: String,
+    /// Generation of the build using the cache
+    generation: u64,
+    /// Entries by cache key
+    entries: HashMap<String, CacheEntry>,
+}
+
+impl SummaryCache {
+    /// Create an empty cache saved into `dir`, ignoring any cache already
+    /// there.
+    pub fn new(dir: &Path, fingerprint: &str) -> Self {
+        Self {
+            path: dir.join(CACHE_FILE),
+            fingerprint: fingerprint.to_string(),
+            generation: 1,
+            entries: HashMap::new(),
+        }
+    }
+
+    /// Load the cache saved in `dir`.
+    ///
+    /// A missing or unreadable cache, or one saved under another
+    /// fingerprint, loads as empty.
+    pub fn load(dir: &Path, fingerprint: &str) -> Self {
+        let mut cache = Self::new(dir, fingerprint);
+        let Ok(json) = std::fs::read_to_string(&cache.path) else {
+            return cache;
+        };
+        match serde_json::from_str::<CacheFile>(&json) {
+            Ok(file) if file.fingerprint == fingerprint => {
+                info!("Loaded {} cached summaries", file.entries.len());
+                cache.generation = file.generation + 1;
+                cache.entries = file.entries;
+            }
+            Ok(_) => info!("Dropping summaries written with another model or prompt"),
+            Err(e) => debug!("Ignoring unreadable summary cache: {}", e),
+        }
+        cache
+    }
+
+    /// Number of cached summaries
+    pub fn len(&self) -> usize {
+        self.entries.len()
+    }
+
+    /// Check if the cache holds no summaries
+    pub fn is_empty(&self) -> bool {
+        self.len() == 0
+    }
+
+    /// Look up a summary, marking it as used by this build.
+    pub fn get(&mut self, key: &str) -> Option<&str> {
+        let entry = self.entries.get_mut(key)?;
+        entry.used = self.generation;
+        Some(&entry.summary)
+    }
+
+    /// Add a summary.
+    pub fn insert(&mut self, key: String, summary: String) {
+        let used = self.generation;
+        self.entries.insert(key, CacheEntry { used, summary });
+    }
+
+    /// Save the cache, pruning entries no recent build has used.
+    pub fn save(&self) -> std::io::Result<()> {
+        let file = CacheFileRef {
+            fingerprint: &self.fingerprint,
+            generation: self.generation,
+            entries: self
+                .entries
+                .iter()
+                .filter(|(_, entry)| entry.used + RETAINED_BUILDS > self.generation)
+                .map(|(key, entry)| (key.as_str(), entry))
+                .collect(),
+        };
+        let json = serde_json::to_string(&file).map_err(std::io::Error::other)?;
+        if let Some(parent) = self.path.parent() {
+            std::fs::create_dir_all(parent)?;
+        }
+        std::fs::write(&self.path, json)
+    }
+}
+
+/// Set cached summaries on the symbols of `graph` and return requests for
+/// the symbols still lacking one.
+///
+/// Sources are read from the files under `workspace`; symbols whose file
+/// cannot be read are skipped.
+pub fn apply_cached(
+    graph: &mut PetCodeGraph,
+    workspace: &Path,
+    cache: &mut SummaryCache,
+    options: &SummaryOptions,
+) -> Vec<SummaryRequest> {
+    let paths = compile(&options.paths);
+    let languages: HashMap<String, String> = graph
+        .iter_nodes()
+        .filter(|node| node.is_file())
+        .filter_map(|node| Some((node.file.clone(), node.metadata.language.clone()?)))
+        .collect();
+
+    let mut files: HashMap<String, Option<Vec<String>>> = HashMap::new();
+    let mut requests = Vec::new();
+    for node in graph.inner_mut().node_weights_mut() {
+        node.metadata.summary = None;
+        let Some(kind) = summary_kind(node) else {
+            continue;
+        };
+        if node.end_line + 1 < node.line + options.min_lines
+            || is_test_file(&node.file)
+            || paths.as_ref().is_some_and(|p| !p.is_match(&node.file))
+        {
+            continue;
+        }
+
+        let lines = files.entry(node.file.clone()).or_insert_with(|| {
+            std::fs::read_to_string(workspace.join(&node.file))
+                .map(|content| content.lines().map(String::from).collect())
+                .ok()
+        });
+        let Some(lines) = lines else {
+            continue;
+        };
+        let start = node.line.saturating_sub(1).min(lines.len());
+        let end = node
+            .end_line
+            .min(lines.len())
+            .min(start + options.max_lines)
+            .max(start);
+        let source = lines[start..end].join("\n");
+        if source.trim().is_empty() {
+            continue;
+        }
+
+        let key = summary_key(&kind, &source);
+        match cache.get(&key) {
+            Some(summary) => node.metadata.summary = Some(summary.to_string()),
+            None => requests.push(SummaryRequest {
+                node_id: node.id.clone(),
+                key,
+                name: node.name.clone(),
+                kind,
+                file: node.file.clone(),
+                language: languages.get(&node.file).cloned(),
+                source,
+            }),
+        }
+    }
+    requests
+}
+
+/// Record a new summary in the cache and set it on the requested node.
+pub fn apply_summary(
+    graph: &mut PetCodeGraph,
+    cache: &mut SummaryCache,
+    request: &SummaryRequest,
+    summary: &str,
+) {
+    let summary = summary.trim();
+    if summary.is_empty() {
+        warn!("Empty summary for {}", request.node_id);
+        return;
+    }
+    cache.insert(request.key.clone(), summary.to_string());
+    if let Some(node) = graph.get_node_mut(&request.node_id) {
+        node.metadata.summary = Some(summary.to_string());
+    }
+}
+
+/// Kind of symbol to summarize, or None for nodes that get no summary
+fn summary_kind(node: &Node) -> Option<String> {
+    if node.is_callable() {
+        return Some(node.kind.clone().unwrap_or_else(|| "function".to_string()));
+    }
+    (node.is_container() && node.kind.as_deref() == Some("type"))
+        .then(|| node.subtype.clone().unwrap_or_else(|| "type".to_string()))
+}
+
+/// Cache key of a symbol's source
+fn summary_key(kind: &str, source: &str) -> String {
+    let mut hasher = Sha256::new();
+    hasher.update(kind.as_bytes());
+    hasher.update([0]);
+    hasher.update(source.as_bytes());
+    format!("{:x}", hasher.finalize())
+}
+
+/// Compile glob patterns, or None when there are none to match
+fn compile(patterns: &[String]) -> Option<GlobSet> {
+    if patterns.is_empty() {
+        return None;
+    }
+    let mut builder = GlobSetBuilder::new();
+    for pattern in patterns {
+        match Glob::new(pattern) {
+            Ok(glob) => {
+                builder.add(glob);
+            }
+            Err(e) => warn!("Skipping invalid summary path pattern '{}': {}", pattern, e),
+        }
+    }
+    builder.build().ok()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::{CallableKind, ContainerKind};
+
+    fn workspace() -> tempfile::TempDir {
+        let dir = tempfile::tempdir().unwrap();
+        std::fs::write(
+            dir.path().join("server.go"),
+            "package api\n\nfunc Serve() {\n\tlisten()\n\twait()\n}\n\nfunc Ping() {}\n",
+        )
+        .unwrap();
+        dir
+    }
+
+    fn graph() -> PetCodeGraph {
+        let mut graph = PetCodeGraph::new();
+        graph.add_node(Node::callable(
+            "server.go:Serve".to_string(),
+            "Serve".to_string(),
+            CallableKind::Function,
+            "server.go".to_string(),
+            3,
+            6,
+        ));
+        graph.add_node(Node::callable(
+            "server.go:Ping".to_string(),
+            "Ping".to_string(),
+            CallableKind::Function,
+            "server.go".to_string(),
+            8,
+            8,
+        ));
+        graph.add_node(Node::container(
+            "server.go".to_string(),
+            "server.go".to_string(),
+            ContainerKind::File,
+            None,
+            "server.go".to_string(),
+            1,
+            8,
+        ));
+        graph
+    }
+
+    #[test]
+    fn test_apply_cached() {
+        let dir = workspace();
+        let mut graph = graph();
+        let mut cache = SummaryCache::new(dir.path(), "model");
+        let options = SummaryOptions::default();
+
+        let requests = apply_cached(&mut graph, dir.path(), &mut cache, &options);
+        assert_eq!(requests.len(), 1);
+        assert_eq!(requests[0].node_id, "server.go:Serve");
+        assert!(requests[0].source.starts_with("func Serve()"));
+
+        apply_summary(&mut graph, &mut cache, &requests[0], " Serves requests. ");
+        let node = graph.get_node("server.go:Serve").unwrap();
+        assert_eq!(node.metadata.summary.as_deref(), Some("Serves requests."));
+        cache.save().unwrap();
+
+        // A rebuilt graph gets the summary from the cache
+        let mut graph = self::graph();
+        let mut cache = SummaryCache::load(dir.path(), "model");
+        assert!(apply_cached(&mut graph, dir.path(), &mut cache, &options).is_empty());
+        let node = graph.get_node("server.go:Serve").unwrap();
+        assert_eq!(node.metadata.summary.as_deref(), Some("Serves requests."));
+
+        // Another model starts over
+        let mut cache = SummaryCache::load(dir.path(), "other-model");
+        assert!(cache.is_empty());
+        assert_eq!(
+            apply_cached(&mut graph, dir.path(), &mut cache, &options).len(),
+            1
+        );
+        let node = graph.get_node("server.go:Serve").unwrap();
+        assert!(node.metadata.summary.is_none());
+    }
+}
@@ -0,0 +1,259 @@
+//! Stable Symbol IDs
+//!
+//! Node IDs include the file path and change whenever a symbol moves between
+//! files. Symbol IDs are derived from what identifies a symbol to its users
+//! instead: the language, the package, the path of enclosing symbols, and
+//! (for callables) the declaration signature. The same symbol gets the same
+//! ID on every build and on every machine, so issue trackers, dashboards,
+//! and other external systems can refer to it durably.
+//!
+//! A symbol ID changes when the symbol is renamed, moved to another package,
+//! or has its signature changed; all of these are API changes for callers.
+//! In languages whose modules are files (Python, JavaScript, TypeScript,
+//! Rust), the module is the file, so moving a symbol to another file changes
+//! its ID as it changes the symbol's import path.
+
+use std::path::Path;
+
+use sha2::{Digest, Sha256};
+
+use crate::analysis::package_of;
+use crate::parser::SupportedLanguage;
+
+/// `GOOS` values a Go file name suffix can constrain the file to
+const GO_OS: &[&str] = &[
+    "aix",
+    "android",
+    "darwin",
+    "dragonfly",
+    "freebsd",
+    "hurd",
+    "illumos",
+    "ios",
+    "js",
+    "linux",
+    "nacl",
+    "netbsd",
+    "openbsd",
+    "plan9",
+    "solaris",
+    "wasip1",
+    "windows",
+    "zos",
+];
+
+/// `GOARCH` values a Go file name suffix can constrain the file to
+const GO_ARCH: &[&str] = &[
+    "386", "amd64", "arm", "arm64", "loong64", "mips", "mips64", "mips64le", "mipsle", "ppc64",
+    "ppc64le", "riscv64", "s390x", "wasm",
+];
+
+/// Prefix of every symbol ID, distinguishing them from node IDs
+pub const SYMBOL_ID_PREFIX: &str = "sym:";
+
+/// Number of hex digits of the digest kept in a symbol ID
+const DIGEST_HEX_LEN: usize = 16;
+
+/// Compute the stable ID of a symbol.
+///
+/// `package` is the scope the symbol is declared in (see [`symbol_scope`]),
+/// `symbol_path` the names of its enclosing symbols followed by its own, and
+/// `signature` its whitespace-normalized declaration signature, if it has one.
+///
+/// # Examples
+///
+/// ```
+/// use codeprysm_core::symbol_id::symbol_id;
+/// use codeprysm_core::SupportedLanguage;
+///
+/// let id = symbol_id(SupportedLanguage::Go, "pkg/api", &["Server", "Handle"], None);
+/// assert!(id.starts_with("sym:go:"));
+/// assert_eq!(id, symbol_id(SupportedLanguage::Go, "pkg/api", &["Server", "Handle"], None));
+/// ```
+pub fn symbol_id(
+    language: SupportedLanguage,
+    package: &str,
+    symbol_path: &[&str],
+    signature: Option<&str>,
+) -> String {
+    let mut hasher = Sha256::new();
+    // Separate components with NUL so ("a", "bc") and ("ab", "c") differ
+    for component in [language.as_str(), package]
+        .into_iter()
+        .chain(symbol_path.iter().copied())
+    {
+        hasher.update(component.as_bytes());
+        hasher.update([0]);
+    }
+    if let Some(signature) = signature {
+        hasher.update([1]);
+        hasher.update(signature.as_bytes());
+    }
+    let digest = format!("{:x}", hasher.finalize());
+    format!(
+        "{}{}:{}",
+        SYMBOL_ID_PREFIX,
+        language.as_str(),
+        &digest[..DIGEST_HEX_LEN]
+    )
+}
+
+/// The scope a symbol's name is unique within, passed as the `package` of
+/// [`symbol_id`] for a symbol declared in `file` (relative to the root).
+///
+/// Python, JavaScript, TypeScript, and Rust modules are files, so the scope
+/// is the file path without its extension. Go packages are directories, but
+/// files built under different constraints (`x_linux.go` and
+/// `x_windows.go`, or `//go:build` lines) may declare the same names, so the
+/// constraint is part of the scope; a `//go:build ignore` file is a program
+/// of its own and scoped to the file. Other languages use the directory.
+pub fn symbol_scope(language: SupportedLanguage, file: &str, source: &str) -> String {
+    let module = || {
+        Path::new(file)
+            .with_extension("")
+            .to_string_lossy()
+            .into_owned()
+    };
+    match language {
+        SupportedLanguage::Python
+        | SupportedLanguage::JavaScript
+        | SupportedLanguage::TypeScript
+        | SupportedLanguage::Tsx
+        | SupportedLanguage::Rust => module(),
+        SupportedLanguage::Go => match go_build_constraint(file, source) {
+            Some(constraint) if constraint == "ignore" => module(),
+            Some(constraint) => format!("{}#{}", package_of(file), constraint),
+            None => package_of(file).to_string(),
+        },
+        _ => package_of(file).to_string(),
+    }
+}
+
+/// The build constraint of a Go file: its `//go:build` expression, or else
+/// the `GOOS`/`GOARCH` suffix of its name
+fn go_build_constraint(file: &str, source: &str) -> Option<String> {
+    // Constraints precede the package clause
+    let expression = source
+        .lines()
+        .map(str::trim)
+        .take_while(|line| !line.starts_with("package "))
+        .find_map(|line| line.strip_prefix("//go:build "));
+    if let Some(expression) = expression {
+        return Some(expression.split_whitespace().collect::<Vec<_>>().join(" "));
+    }
+
+    let stem = Path::new(file).file_stem()?.to_str()?;
+    let stem = stem.strip_suffix("_test").unwrap_or(stem);
+    let parts: Vec<&str> = stem.split('_').skip(1).collect();
+    match parts.as_slice() {
+        [.., os, arch] if GO_OS.contains(os) && GO_ARCH.contains(arch) => {
+            Some(format!("{}_{}", os, arch))
+        }
+        [.., last] if GO_OS.contains(last) || GO_ARCH.contains(last) => Some(last.to_string()),
+        _ => None,
+    }
+}
+
+/// Check whether a string has the form of a symbol ID
+pub fn is_symbol_id(s: &str) -> bool {
+    s.strip_prefix(SYMBOL_ID_PREFIX)
+        .and_then(|rest| rest.split_once(':'))
+        .is_some_and(|(language, digest)| {
+            !language.is_empty()
+                && digest.len() == DIGEST_HEX_LEN
+                && digest.bytes().all(|b| b.is_ascii_hexdigit())
+        })
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_symbol_id_components() {
+        let base = symbol_id(
+            SupportedLanguage::Go,
+            "pkg/api",
+            &["Server", "Handle"],
+            Some("func (s *Server) Handle(w http.ResponseWriter)"),
+        );
+        assert!(is_symbol_id(&base));
+
+        // Every component contributes
+        let others = [
+            symbol_id(
+                SupportedLanguage::Python,
+                "pkg/api",
+                &["Server", "Handle"],
+                Some("func (s *Server) Handle(w http.ResponseWriter)"),
+            ),
+            symbol_id(
+                SupportedLanguage::Go,
+                "pkg/web",
+                &["Server", "Handle"],
+                Some("func (s *Server) Handle(w http.ResponseWriter)"),
+            ),
+            symbol_id(
+                SupportedLanguage::Go,
+                "pkg/api",
+                &["ServerHandle"],
+                Some("func (s *Server) Handle(w http.ResponseWriter)"),
+            ),
+            symbol_id(
+                SupportedLanguage::Go,
+                "pkg/api",
+                &["Server", "Handle"],
+                Some("func (s *Server) Handle(w http.ResponseWriter) error"),
+            ),
+            symbol_id(
+                SupportedLanguage::Go,
+                "pkg/api",
+                &["Server", "Handle"],
+                None,
+            ),
+        ];
+        for other in &others {
+            assert_ne!(&base, other);
+        }
+    }
+
+    #[test]
+    fn test_symbol_scope() {
+        use SupportedLanguage::{Go, Python, Rust};
+
+        // File-scoped modules
+        assert_eq!(symbol_scope(Python, "scripts/a.py", ""), "scripts/a");
+        assert_ne!(
+            symbol_scope(Python, "scripts/a.py", ""),
+            symbol_scope(Python, "scripts/b.py", "")
+        );
+        assert_eq!(symbol_scope(Rust, "src/lib.rs", ""), "src/lib");
+
+        // Go packages, split by build constraint
+        assert_eq!(symbol_scope(Go, "pkg/api/server.go", ""), "pkg/api");
+        assert_eq!(symbol_scope(Go, "pkg/api/x_linux.go", ""), "pkg/api#linux");
+        assert_eq!(
+            symbol_scope(Go, "pkg/api/x_windows_amd64_test.go", ""),
+            "pkg/api#windows_amd64"
+        );
+        assert_eq!(symbol_scope(Go, "pkg/api/http_server.go", ""), "pkg/api");
+        let source = "// Copyright\n\n//go:build  linux && !cgo\n\npackage api\n";
+        assert_eq!(
+            symbol_scope(Go, "pkg/api/x.go", source),
+            "pkg/api#linux && !cgo"
+        );
+        let source = "//go:build ignore\n\npackage main\n";
+        assert_eq!(symbol_scope(Go, "tools/gen.go", source), "tools/gen");
+        // Only constraints before the package clause count
+        let source = "package api\n\n//go:build linux\n";
+        assert_eq!(symbol_scope(Go, "pkg/api/x.go", source), "pkg/api");
+    }
+
+    #[test]
+    fn test_is_symbol_id() {
+        assert!(is_symbol_id("sym:go:0123456789abcdef"));
+        assert!(!is_symbol_id("sym:go:0123"));
+        assert!(!is_symbol_id("main.go:Server:Handle"));
+        assert!(!is_symbol_id("sym::0123456789abcdef"));
+    }
+}
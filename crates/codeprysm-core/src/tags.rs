@@ -379,6 +379,10 @@ fn parse_kind(node_type: NodeType, kind_str: &str) -> Option<NodeKind> {
             "property" => Some(NodeKind::Data(DataKind::Property)),
             "parameter" => Some(NodeKind::Data(DataKind::Parameter)),
             "local" => Some(NodeKind::Data(DataKind::Local)),
+            "marker" => Some(NodeKind::Data(DataKind::Marker)),
+            "documentation" => Some(NodeKind::Data(DataKind::Documentation)),
+            "truncation" => Some(NodeKind::Data(DataKind::Truncation)),
+            "build_target" => Some(NodeKind::Data(DataKind::BuildTarget)),
             _ => None,
         },
     }
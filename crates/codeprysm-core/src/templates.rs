@@ -0,0 +1,1528 @@
+//! Go Template Linking
+//!
+//! Links `html/template` and `text/template` files to the Go code that loads
+//! them and to the data they render, so a template's dependencies on struct
+//! fields show up in the graph like any other reference:
+//!
+//! - Template files (`.tmpl`, `.gotmpl`, and any file named in a
+//!   `ParseFiles`, `ParseGlob`, or `ParseFS` call) become File nodes with
+//!   language `gotemplate`
+//! - The function parsing a template USES the template file
+//! - A template USES the type passed to `Execute` or `ExecuteTemplate`, and
+//!   the fields and methods its `{{ .Field }}` references resolve to
+//!
+//! References to a field or method the data type lacks fail at render time.
+//! They are counted as unresolved references of the template file and
+//! reported as build diagnostics.
+//!
+//! The analysis is textual and only checks references whose dot it can
+//! type:
+//!
+//! - `Execute` calls are matched to the variable or struct field the
+//!   templates were parsed into, by name within the package
+//! - data is typed from composite literals (`&Page{...}`) and from variables
+//!   and parameters declared with a type in the calling function
+//! - `range`, `with`, `block`, and `{{template "name" .}}` move the dot
+//!   through field and method result types; maps of structs and slices are
+//!   followed, while template variables other than `$`, function results,
+//!   and types with embedded fields leave it unknown
+
+use std::collections::{BTreeMap, BTreeSet, HashMap, HashSet};
+use std::path::Path;
+
+use crate::analysis::api_surface::is_type;
+use crate::analysis::package_of;
+use crate::diagnostics::{Diagnostic, Span};
+use crate::file_policy::is_test_file;
+use crate::graph::{Edge, EdgeData, Node, NodeMetadata, NodeType, PetCodeGraph};
+use crate::merkle::compute_content_hash;
+
+/// Language recorded on template File nodes
+pub const TEMPLATE_LANGUAGE: &str = "gotemplate";
+
+/// Extensions of Go template files
+const TEMPLATE_EXTENSIONS: &[&str] = &["tmpl", "gotmpl"];
+
+/// Calls parsing template files: the call, the number of leading arguments
+/// that are not file names, and whether the names are glob patterns
+const PARSE_CALLS: &[(&str, usize, bool)] = &[
+    (".ParseFiles(", 0, false),
+    (".ParseGlob(", 0, true),
+    (".ParseFS(", 1, true),
+];
+
+/// Check if a path names a Go template file.
+pub fn is_template_path(path: &Path) -> bool {
+    path.extension()
+        .and_then(|ext| ext.to_str())
+        .is_some_and(|ext| TEMPLATE_EXTENSIONS.contains(&ext))
+}
+
+/// Result of linking templates.
+#[derive(Debug, Clone, Default)]
+pub struct TemplateLinks {
+    /// Template File nodes added
+    pub templates: usize,
+    /// USES edges added to and from templates
+    pub edges: usize,
+    /// References to fields and methods the rendered type lacks
+    pub diagnostics: Vec<Diagnostic>,
+}
+
+/// Add template files to a graph built from `directory` and link them to
+/// Go code.
+///
+/// `template_files` are the template files found in the repository
+/// (relative to `directory`); files named by parse calls are added as well.
+/// Template File nodes are contained in the `repo_name` node, if any.
+pub fn link_templates(
+    graph: &mut PetCodeGraph,
+    directory: &Path,
+    repo_name: &str,
+    template_files: &[String],
+) -> TemplateLinks {
+    let mut links = TemplateLinks::default();
+
+    let go_files: Vec<(String, String)> = graph
+        .iter_nodes()
+        .filter(|n| n.is_file() && n.file.ends_with(".go"))
+        .filter_map(|n| {
+            let source = std::fs::read_to_string(directory.join(&n.file)).ok()?;
+            Some((n.file.clone(), source))
+        })
+        .collect();
+    let loads: Vec<Load> = go_files
+        .iter()
+        .flat_map(|(file, source)| find_loads(directory, file, source))
+        .collect();
+
+    // Template files, parsed
+    let mut files: BTreeSet<&str> = template_files.iter().map(String::as_str).collect();
+    files.extend(
+        loads
+            .iter()
+            .flat_map(|l| l.files.iter().map(String::as_str)),
+    );
+    let mut templates: HashMap<String, Template> = HashMap::new();
+    for file in files {
+        let Ok(source) = std::fs::read_to_string(directory.join(file)) else {
+            continue;
+        };
+        if !graph.contains_node(file) {
+            add_template_node(graph, repo_name, file, &source);
+            links.templates += 1;
+        }
+        templates.insert(file.to_string(), Template::parse(&source));
+    }
+
+    let mut linker = Linker::new(graph, directory, &templates);
+
+    // Templates parsed into each variable, by package and variable name
+    let mut bindings: HashMap<(&str, &str), Vec<String>> = HashMap::new();
+    for load in &loads {
+        let source = linker.enclosing(&load.file, load.line);
+        for file in load.files.iter().filter(|f| templates.contains_key(*f)) {
+            linker.edge(&source, file, Some(load.line), &load.literal);
+            if let Some(variable) = &load.variable {
+                let set = bindings
+                    .entry((package_of(&load.file), variable))
+                    .or_default();
+                if !set.contains(file) {
+                    set.push(file.clone());
+                }
+            }
+        }
+    }
+
+    for (file, source) in &go_files {
+        for call in find_executes(source) {
+            let Some(set) = bindings.get(&(package_of(file), call.receiver.as_str())) else {
+                continue;
+            };
+            let data = linker.data_type(file, call.line, &call.data);
+            linker.execute(set, call.name.as_deref(), data);
+        }
+    }
+
+    let Linker {
+        edges,
+        resolved,
+        mismatches,
+        ..
+    } = linker;
+
+    // Count each template's resolved and mismatched references
+    let mut counts: BTreeMap<String, (u32, u32)> = BTreeMap::new();
+    for (file, _) in &resolved {
+        counts.entry(file.clone()).or_default().0 += 1;
+    }
+    for (file, _) in mismatches.keys() {
+        counts.entry(file.clone()).or_default().1 += 1;
+    }
+    for (file, (resolved, unresolved)) in counts {
+        if let Some(node) = graph.get_node_mut(&file) {
+            node.metadata.resolved_refs = Some(resolved);
+            node.metadata.unresolved_refs = Some(unresolved);
+        }
+    }
+
+    for (source, target, line, ident) in edges {
+        if graph
+            .add_edge(&source, &target, EdgeData::uses(line, Some(ident)))
+            .is_some()
+        {
+            links.edges += 1;
+        }
+    }
+    links.diagnostics = mismatches.into_values().collect();
+    links
+}
+
+/// Add the File node of a template
+fn add_template_node(graph: &mut PetCodeGraph, repo_name: &str, file: &str, source: &str) {
+    let node = Node::source_file(
+        file.to_string(),
+        file.to_string(),
+        compute_content_hash(source.as_bytes()),
+        source.lines().count(),
+    )
+    .with_metadata(NodeMetadata::new().with_file(
+        TEMPLATE_LANGUAGE,
+        source.len() as u64,
+        false,
+        is_test_file(file),
+    ));
+    graph.add_node(node);
+    if !repo_name.is_empty() {
+        graph.add_edge_from_struct(&Edge::contains(repo_name.to_string(), file.to_string()));
+    }
+}
+
+/// A call parsing template files
+#[derive(Debug)]
+struct Load {
+    /// Go file making the call
+    file: String,
+    /// Line of the call
+    line: usize,
+    /// Variable or field the templates are assigned to
+    variable: Option<String>,
+    /// First file name or pattern passed
+    literal: String,
+    /// Template files, relative to the repository root
+    files: Vec<String>,
+}
+
+/// Find the calls parsing template files in a Go source file
+fn find_loads(directory: &Path, file: &str, source: &str) -> Vec<Load> {
+    let mut loads = Vec::new();
+    for (call, skip, glob) in PARSE_CALLS {
+        for (at, _) in source.match_indices(call) {
+            let Some(args) = arguments(&source[at + call.len()..]) else {
+                continue;
+            };
+            let literals: Vec<String> = split_arguments(args)
+                .into_iter()
+                .skip(*skip)
+                .filter_map(string_literal)
+                .collect();
+            let Some(literal) = literals.first().cloned() else {
+                continue;
+            };
+
+            // Embedded file systems are relative to the package; other names
+            // to the working directory, usually the module root
+            let bases = if *skip > 0 {
+                vec![package_of(file).to_string()]
+            } else {
+                ancestors(file)
+            };
+            let files: Vec<String> = literals
+                .iter()
+                .flat_map(|name| {
+                    if *glob {
+                        expand_glob(directory, &bases, name)
+                    } else {
+                        resolve_path(directory, &bases, name).into_iter().collect()
+                    }
+                })
+                .collect();
+
+            let line_start = source[..at].rfind('\n').map_or(0, |i| i + 1);
+            loads.push(Load {
+                file: file.to_string(),
+                line: line_of(source, at),
+                variable: assigned_variable(&source[line_start..at]),
+                literal,
+                files,
+            });
+        }
+    }
+    loads.sort_by_key(|load| load.line);
+    loads
+}
+
+/// Directories a relative path may be resolved against: those containing
+/// the file, innermost first, up to the repository root ("")
+fn ancestors(file: &str) -> Vec<String> {
+    let mut bases = Vec::new();
+    let mut dir = package_of(file);
+    loop {
+        bases.push(dir.to_string());
+        if dir.is_empty() {
+            break;
+        }
+        dir = package_of(dir);
+    }
+    bases
+}
+
+/// Join a relative path onto a base directory
+fn join(base: &str, path: &str) -> String {
+    let path = path.trim_start_matches("./");
+    if base.is_empty() {
+        path.to_string()
+    } else {
+        format!("{}/{}", base, path)
+    }
+}
+
+/// Resolve a template file name against the first base it exists under
+fn resolve_path(directory: &Path, bases: &[String], name: &str) -> Option<String> {
+    bases
+        .iter()
+        .map(|base| join(base, name))
+        .find(|path| directory.join(path).is_file())
+}
+
+/// Expand a glob pattern against the first base it matches files under.
+///
+/// Only the file name may contain wildcards.
+fn expand_glob(directory: &Path, bases: &[String], pattern: &str) -> Vec<String> {
+    let (dir, name) = pattern.rsplit_once('/').unwrap_or(("", pattern));
+    let Ok(name) = glob::Pattern::new(name) else {
+        return Vec::new();
+    };
+    for base in bases {
+        let dir = if dir.is_empty() {
+            base.clone()
+        } else {
+            join(base, dir)
+        };
+        let Ok(entries) = std::fs::read_dir(directory.join(&dir)) else {
+            continue;
+        };
+        let mut files: Vec<String> = entries
+            .filter_map(Result::ok)
+            .filter(|e| e.file_type().is_ok_and(|t| t.is_file()))
+            .filter_map(|e| e.file_name().into_string().ok())
+            .filter(|file| name.matches(file))
+            .map(|file| join(&dir, &file))
+            .collect();
+        if !files.is_empty() {
+            files.sort();
+            return files;
+        }
+    }
+    Vec::new()
+}
+
+/// Variable or field a call on this line is assigned to, as in
+/// `tmpl := ...`, `var tmpl = ...`, `s.tmpl = ...`, or `&server{tmpl: ...`
+fn assigned_variable(prefix: &str) -> Option<String> {
+    let at = prefix.find([':', '='])?;
+    let target = prefix[..at].trim();
+    let target = target.strip_prefix("var ").unwrap_or(target);
+    let target = target.split(',').next()?.trim();
+    let name = target
+        .rsplit(|c: char| !(c.is_alphanumeric() || c == '_'))
+        .next()?;
+    is_identifier(name).then(|| name.to_string())
+}
+
+/// A call rendering a template
+#[derive(Debug)]
+struct ExecuteCall {
+    /// Line of the call
+    line: usize,
+    /// Variable or field the template is called on
+    receiver: String,
+    /// Template name, for `ExecuteTemplate`
+    name: Option<String>,
+    /// Data argument
+    data: String,
+}
+
+/// Find the calls rendering templates in a Go source file
+fn find_executes(source: &str) -> Vec<ExecuteCall> {
+    let mut calls = Vec::new();
+    for (call, named) in [(".Execute(", false), (".ExecuteTemplate(", true)] {
+        for (at, _) in source.match_indices(call) {
+            let receiver = source[..at]
+                .rsplit(|c: char| !(c.is_alphanumeric() || c == '_'))
+                .next()
+                .unwrap_or_default();
+            let Some(args) = arguments(&source[at + call.len()..]) else {
+                continue;
+            };
+            let args = split_arguments(args);
+            let (name, data) = if named {
+                let Some(name) = args.get(1).and_then(|a| string_literal(a)) else {
+                    continue;
+                };
+                (Some(name), args.get(2))
+            } else {
+                (None, args.get(1))
+            };
+            let Some(data) = data else {
+                continue;
+            };
+            if !is_identifier(receiver) {
+                continue;
+            }
+            calls.push(ExecuteCall {
+                line: line_of(source, at),
+                receiver: receiver.to_string(),
+                name,
+                data: data.to_string(),
+            });
+        }
+    }
+    calls
+}
+
+/// Type of the dot in a template
+#[derive(Debug, Clone)]
+enum Value<'g> {
+    /// Not known; references are not checked
+    Unknown,
+    /// A type in the graph
+    Type(&'g Node),
+    /// A slice, array, map, or channel of elements
+    Collection(Box<Value<'g>>),
+}
+
+impl Value<'_> {
+    /// Value of the dot when ranging over this value
+    fn element(self) -> Self {
+        match self {
+            Self::Collection(element) => *element,
+            _ => Self::Unknown,
+        }
+    }
+
+    /// Key identifying the value, None if it is unknown
+    fn key(&self) -> Option<String> {
+        match self {
+            Self::Unknown => None,
+            Self::Type(node) => Some(node.id.clone()),
+            Self::Collection(element) => element.key().map(|key| format!("[]{}", key)),
+        }
+    }
+}
+
+/// Resolves template references against Go types
+struct Linker<'g> {
+    graph: &'g PetCodeGraph,
+    directory: &'g Path,
+    templates: &'g HashMap<String, Template>,
+    /// Go types by package and name
+    types: HashMap<(&'g str, &'g str), &'g Node>,
+    /// Go methods by package and receiver type
+    methods: HashMap<(&'g str, &'g str), Vec<&'g Node>>,
+    /// Callables by file
+    callables: HashMap<&'g str, Vec<&'g Node>>,
+    /// Source lines by file, None if unreadable
+    sources: HashMap<String, Option<Vec<String>>>,
+    /// Template parts already checked against a type
+    visited: HashSet<(String, usize, String)>,
+    /// USES edges: source, target, line, and identifier
+    edges: BTreeSet<(String, String, Option<usize>, String)>,
+    /// References resolved, by template file and offset
+    resolved: BTreeSet<(String, usize)>,
+    /// Mismatches, by template file and offset
+    mismatches: BTreeMap<(String, usize), Diagnostic>,
+}
+
+impl<'g> Linker<'g> {
+    fn new(
+        graph: &'g PetCodeGraph,
+        directory: &'g Path,
+        templates: &'g HashMap<String, Template>,
+    ) -> Self {
+        let mut types = HashMap::new();
+        let mut methods: HashMap<(&str, &str), Vec<&Node>> = HashMap::new();
+        let mut callables: HashMap<&str, Vec<&Node>> = HashMap::new();
+        for node in graph.iter_nodes().filter(|n| n.file.ends_with(".go")) {
+            if is_type(node) {
+                types
+                    .entry((package_of(&node.file), node.name.as_str()))
+                    .or_insert(node);
+            } else if node.node_type == NodeType::Callable {
+                callables.entry(node.file.as_str()).or_default().push(node);
+                if let Some(receiver) = node.metadata.receiver.as_deref() {
+                    methods
+                        .entry((package_of(&node.file), receiver))
+                        .or_default()
+                        .push(node);
+                }
+            }
+        }
+        Self {
+            graph,
+            directory,
+            templates,
+            types,
+            methods,
+            callables,
+            sources: HashMap::new(),
+            visited: HashSet::new(),
+            edges: BTreeSet::new(),
+            resolved: BTreeSet::new(),
+            mismatches: BTreeMap::new(),
+        }
+    }
+
+    /// Record a USES edge
+    fn edge(&mut self, source: &str, target: &str, line: Option<usize>, ident: &str) {
+        self.edges.insert((
+            source.to_string(),
+            target.to_string(),
+            line,
+            ident.to_string(),
+        ));
+    }
+
+    /// ID of the innermost callable containing a line, or of the file
+    fn enclosing(&self, file: &str, line: usize) -> String {
+        self.callable_at(file, line)
+            .map_or_else(|| file.to_string(), |n| n.id.clone())
+    }
+
+    /// Innermost callable containing a line
+    fn callable_at(&self, file: &str, line: usize) -> Option<&'g Node> {
+        self.callables
+            .get(file)?
+            .iter()
+            .filter(|n| n.line <= line && line <= n.end_line)
+            .min_by_key(|n| n.end_line - n.line)
+            .copied()
+    }
+
+    /// Lines of a source file
+    fn lines(&mut self, file: &str) -> Option<&[String]> {
+        let directory = self.directory;
+        self.sources
+            .entry(file.to_string())
+            .or_insert_with(|| {
+                std::fs::read_to_string(directory.join(file))
+                    .ok()
+                    .map(|content| content.lines().map(String::from).collect())
+            })
+            .as_deref()
+    }
+
+    /// Type named in Go source: `Name`, `pkg.Name`, `*T`, `[]T`, `map[K]V`
+    fn type_value(&self, package: &str, text: &str) -> Value<'g> {
+        let text = text.trim().trim_start_matches('*');
+        if let Some(rest) = text.strip_prefix('[') {
+            // Slices, arrays, and maps: skip to the element type
+            let Some(end) = rest.find(']') else {
+                return Value::Unknown;
+            };
+            return Value::Collection(Box::new(self.type_value(package, &rest[end + 1..])));
+        }
+        if let Some(rest) = text.strip_prefix("map[") {
+            let Some(end) = closing(rest, '[', ']') else {
+                return Value::Unknown;
+            };
+            return Value::Collection(Box::new(self.type_value(package, &rest[end + 1..])));
+        }
+        if let Some(rest) = text.strip_prefix("chan ") {
+            return Value::Collection(Box::new(self.type_value(package, rest)));
+        }
+
+        // Drop type arguments
+        let text = text.split('[').next().unwrap_or_default();
+        let node = match text.split_once('.') {
+            None => self.types.get(&(package, text)).copied(),
+            Some((qualifier, name)) => {
+                let mut candidates = self.types.iter().filter(|((pkg, type_name), _)| {
+                    *type_name == name && pkg.rsplit('/').next() == Some(qualifier)
+                });
+                match (candidates.next(), candidates.next()) {
+                    (Some((_, node)), None) => Some(*node),
+                    _ => None,
+                }
+            }
+        };
+        node.map_or(Value::Unknown, Value::Type)
+    }
+
+    /// Type of the data argument of a render call on a line of a Go file
+    fn data_type(&mut self, file: &str, line: usize, data: &str) -> Value<'g> {
+        let package = package_of(file);
+        let data = data.trim().trim_start_matches('&');
+        if let Some((head, _)) = data.split_once('{') {
+            return self.type_value(package, head);
+        }
+        if !is_identifier(data) {
+            return Value::Unknown;
+        }
+        let Some(callable) = self.callable_at(file, line) else {
+            return Value::Unknown;
+        };
+        let Some(lines) = self.lines(file) else {
+            return Value::Unknown;
+        };
+        let Some(signature) = lines.get(callable.line.saturating_sub(1)) else {
+            return Value::Unknown;
+        };
+        let body = lines
+            .get(callable.line..line.saturating_sub(1))
+            .unwrap_or_default();
+
+        // The nearest declaration or assignment before the call
+        let mut declared: Option<String> = None;
+        for text in body.iter().rev() {
+            let text = text.trim();
+            if let Some(rest) = text.strip_prefix("var ").and_then(|t| after_word(t, data)) {
+                let rest = rest.trim_start();
+                declared = Some(match rest.strip_prefix('=') {
+                    Some(value) => literal_type(value).unwrap_or_default(),
+                    None => rest.split('=').next().unwrap_or_default().to_string(),
+                });
+                break;
+            }
+            if let Some(rest) = after_word(text, data) {
+                let rest = rest.trim_start();
+                if rest.starts_with("==") {
+                    continue;
+                }
+                if let Some(value) = rest.strip_prefix(":=").or_else(|| rest.strip_prefix('=')) {
+                    declared = Some(literal_type(value).unwrap_or_default());
+                    break;
+                }
+            }
+        }
+
+        // Otherwise a parameter
+        let declared = declared.or_else(|| {
+            let signature = signature.trim_start();
+            let signature = match signature.strip_prefix("func (") {
+                Some(rest) => closing(rest, '(', ')').map_or("", |end| &rest[end + 1..]),
+                None => signature,
+            };
+            let params = signature.find('(').map_or("", |i| &signature[i + 1..]);
+            let params = closing(params, '(', ')').map_or(params, |end| &params[..end]);
+            split_arguments(params)
+                .into_iter()
+                .find_map(|param| after_word(param, data).map(|t| t.trim().to_string()))
+        });
+        declared.map_or(Value::Unknown, |text| self.type_value(package, &text))
+    }
+
+    /// Check the templates a render call executes with the given data
+    fn execute(&mut self, set: &[String], name: Option<&str>, data: Value<'g>) {
+        let Value::Type(node) = data else {
+            return;
+        };
+        match name {
+            None => {
+                if let Some(file) = set.first() {
+                    self.edge(file, &node.id, None, &node.name);
+                    let end = self.templates[file].actions.len();
+                    self.check(set, file, 0, end, Value::Type(node));
+                }
+            }
+            Some(name) => self.invoke(set, name, Value::Type(node), true),
+        }
+    }
+
+    /// Check the template of a name, the body of a `define` or `block` or
+    /// a file named after it, with the given dot
+    fn invoke(&mut self, set: &[String], name: &str, dot: Value<'g>, link: bool) {
+        let templates = self.templates;
+        let found = set.iter().find_map(|file| {
+            let template = &templates[file];
+            if file.rsplit('/').next() == Some(name) {
+                return Some((file, 0, template.actions.len()));
+            }
+            template
+                .blocks
+                .get(name)
+                .map(|&(start, end)| (file, start, end))
+        });
+        let Some((file, start, end)) = found else {
+            return;
+        };
+        if let (true, Value::Type(node)) = (link, &dot) {
+            self.edge(file, &node.id, None, &node.name);
+        }
+        self.check(set, file, start, end, dot);
+    }
+
+    /// Check the actions `from..to` of a template file with the given dot
+    fn check(&mut self, set: &[String], file: &str, from: usize, to: usize, dot: Value<'g>) {
+        let Some(key) = dot.key() else {
+            return;
+        };
+        if !self.visited.insert((file.to_string(), from, key)) {
+            return;
+        }
+
+        let templates = self.templates;
+        let template = &templates[file];
+        let root = dot.clone();
+        let mut dot = dot;
+        let mut saved: Vec<Value<'g>> = Vec::new();
+        let mut i = from;
+        while i < to {
+            let action = &template.actions[i];
+            let (keyword, rest) = action.keyword();
+            let rest_offset = action.offset + action.text.len() - rest.len();
+            match keyword {
+                "define" => {
+                    i = template.ends.get(&i).map_or(to, |end| end + 1);
+                    continue;
+                }
+                "if" => {
+                    self.pipeline(file, rest, rest_offset, &dot, &root);
+                    saved.push(dot.clone());
+                }
+                "range" | "with" => {
+                    let value = self.pipeline(file, rest, rest_offset, &dot, &root);
+                    saved.push(dot.clone());
+                    dot = if keyword == "range" {
+                        value.element()
+                    } else {
+                        value
+                    };
+                }
+                "block" | "template" => {
+                    let Some((name, pipe)) = leading_literal(rest) else {
+                        i += 1;
+                        continue;
+                    };
+                    let pipe_offset = rest_offset + rest.len() - pipe.len();
+                    let value = self.pipeline(file, pipe, pipe_offset, &dot, &root);
+                    if keyword == "block" {
+                        saved.push(dot.clone());
+                        dot = value;
+                    } else {
+                        self.invoke(set, &name, value, false);
+                    }
+                }
+                "else" => {
+                    // The else branch of `range` and `with` sees the outer dot
+                    let outer = saved.last().cloned().unwrap_or(Value::Unknown);
+                    let (chained, pipe) = Action::split_keyword(rest.trim_start());
+                    let pipe_offset = rest_offset + rest.len() - pipe.len();
+                    dot = match chained {
+                        "with" => self.pipeline(file, pipe, pipe_offset, &outer, &root),
+                        "if" => {
+                            self.pipeline(file, pipe, pipe_offset, &outer, &root);
+                            outer
+                        }
+                        _ => outer,
+                    };
+                }
+                "end" => dot = saved.pop().unwrap_or(Value::Unknown),
+                _ => {
+                    self.pipeline(file, &action.text, action.offset, &dot, &root);
+                }
+            }
+            i += 1;
+        }
+    }
+
+    /// Resolve the field and method references of a pipeline and return
+    /// its value, known only for a bare `.Field.Chain`
+    fn pipeline(
+        &mut self,
+        file: &str,
+        text: &str,
+        offset: usize,
+        dot: &Value<'g>,
+        root: &Value<'g>,
+    ) -> Value<'g> {
+        // Variable declarations leave the value to the right of `:=`
+        let (text, offset) = match text.find(":=") {
+            Some(at) => (&text[at + 2..], offset + at + 2),
+            None => (text, offset),
+        };
+        let trimmed = text.trim();
+
+        let mut value = Value::Unknown;
+        for chain in field_chains(text) {
+            let base = if chain.from_root { root } else { dot };
+            let resolved = self.resolve(file, offset + chain.offset, base, &chain.fields);
+            if chain.text == trimmed {
+                value = resolved;
+            }
+        }
+        value
+    }
+
+    /// Resolve a chain of field and method names against a value
+    fn resolve(
+        &mut self,
+        file: &str,
+        offset: usize,
+        base: &Value<'g>,
+        fields: &[(usize, String)],
+    ) -> Value<'g> {
+        let mut value = base.clone();
+        for (at, name) in fields {
+            let Value::Type(node) = value else {
+                return Value::Unknown;
+            };
+            let offset = offset + at;
+            let templates = self.templates;
+            let template = &templates[file];
+            match self.member(node, name) {
+                Some((member, next)) => {
+                    let line = template.line_of(offset);
+                    self.edge(file, &member.id, Some(line), name);
+                    self.resolved.insert((file.to_string(), offset));
+                    value = next;
+                }
+                None => {
+                    if self.is_closed(node) {
+                        let (line, column) = template.position(offset);
+                        let span = Span {
+                            line,
+                            column,
+                            end_line: line,
+                            end_column: column + name.len() + 1,
+                        };
+                        self.mismatches.insert(
+                            (file.to_string(), offset),
+                            Diagnostic::template_mismatch(file, span, name, &node.name),
+                        );
+                    }
+                    return Value::Unknown;
+                }
+            }
+        }
+        value
+    }
+
+    /// Field or method of a type, and the type it yields
+    fn member(&mut self, node: &'g Node, name: &str) -> Option<(&'g Node, Value<'g>)> {
+        let graph = self.graph;
+        let member = graph
+            .children(&node.id)
+            .find(|child| child.name == name && !child.is_marker())
+            .or_else(|| {
+                self.methods
+                    .get(&(package_of(&node.file), node.name.as_str()))?
+                    .iter()
+                    .find(|method| method.name == name)
+                    .copied()
+            })?;
+        let declared = if member.node_type == NodeType::Callable {
+            self.result_type(member)
+        } else {
+            self.field_type(member)
+        };
+        let value = declared.map_or(Value::Unknown, |text| match self.anonymous_type(member) {
+            Some(anonymous) => anonymous_value(&text, anonymous),
+            None => self.type_value(package_of(&member.file), &text),
+        });
+        Some((member, value))
+    }
+
+    /// Anonymous struct or interface type a field is declared with, which the
+    /// field USES (e.g., `Server.struct` for `Server struct { ... }`)
+    fn anonymous_type(&self, field: &Node) -> Option<&'g Node> {
+        let graph = self.graph;
+        graph
+            .outgoing_edges(&field.id)
+            .map(|(target, _)| target)
+            .find(|target| {
+                is_type(target)
+                    && target
+                        .name
+                        .strip_prefix(field.name.as_str())
+                        .is_some_and(|rest| matches!(rest, ".struct" | ".interface"))
+            })
+    }
+
+    /// Declared type of a struct field
+    fn field_type(&mut self, field: &Node) -> Option<String> {
+        let line = self.lines(&field.file)?.get(field.line.checked_sub(1)?)?;
+        let rest = after_word(line.trim(), &field.name)?;
+
+        // Skip the other names of `A, B Type`
+        let mut rest = rest.trim_start();
+        while let Some(more) = rest.strip_prefix(',') {
+            let more = more.trim_start();
+            let end = more
+                .find(|c: char| !(c.is_alphanumeric() || c == '_'))
+                .unwrap_or(more.len());
+            rest = more[end..].trim_start();
+        }
+        let end = rest
+            .find(|c: char| c.is_whitespace() || c == '`')
+            .unwrap_or(rest.len());
+        Some(rest[..end].to_string())
+    }
+
+    /// First result type of a method
+    fn result_type(&mut self, method: &Node) -> Option<String> {
+        let lines = self.lines(&method.file)?;
+        let start = method.line.checked_sub(1)?;
+        let signature = lines.get(start..(start + 5).min(lines.len()))?.join(" ");
+        let at = signature.find(&format!("{}(", method.name))? + method.name.len();
+        let params = closing(&signature[at + 1..], '(', ')')?;
+        let results = signature[at + 1 + params + 1..]
+            .split('{')
+            .next()
+            .unwrap_or_default()
+            .trim();
+        let first = match results.strip_prefix('(') {
+            Some(list) => list.split([',', ')']).next()?.trim(),
+            None => results,
+        };
+        // Named results: `(page *Page, err error)`
+        first.rsplit(' ').next().map(String::from)
+    }
+
+    /// Check whether a type's fields and methods are all in the graph: a
+    /// struct or interface without embedded types
+    fn is_closed(&mut self, node: &Node) -> bool {
+        if !matches!(node.subtype.as_deref(), Some("struct" | "interface")) {
+            return false;
+        }
+        let Some(lines) = self.lines(&node.file) else {
+            return false;
+        };
+        if node.end_line <= node.line || node.end_line > lines.len() {
+            return false;
+        }
+        !lines[node.line..node.end_line - 1].iter().any(|line| {
+            let line = line.split("//").next().unwrap_or_default();
+            let line = line.split('`').next().unwrap_or_default().trim();
+            !line.is_empty() && !line.contains(char::is_whitespace) && !line.contains(['{', '}'])
+        })
+    }
+}
+
+/// A `{{ ... }}` action of a template
+#[derive(Debug)]
+struct Action {
+    /// Text between the delimiters and trim markers, trimmed
+    text: String,
+    /// Byte offset of the text in the file
+    offset: usize,
+}
+
+impl Action {
+    /// Leading keyword of the action (empty for plain pipelines) and the rest
+    fn keyword(&self) -> (&str, &str) {
+        Self::split_keyword(&self.text)
+    }
+
+    fn split_keyword(text: &str) -> (&str, &str) {
+        const KEYWORDS: &[&str] = &[
+            "block", "define", "else", "end", "if", "range", "template", "with",
+        ];
+        let end = text
+            .find(|c: char| !c.is_ascii_alphabetic())
+            .unwrap_or(text.len());
+        if KEYWORDS.contains(&&text[..end]) {
+            (&text[..end], &text[end..])
+        } else {
+            ("", text)
+        }
+    }
+}
+
+/// A parsed template file
+#[derive(Debug, Default)]
+struct Template {
+    /// Actions in source order
+    actions: Vec<Action>,
+    /// Index of the `end` closing each `if`, `range`, `with`, `define`, and
+    /// `block` action
+    ends: HashMap<usize, usize>,
+    /// Bodies of `define` and `block` templates by name, as action ranges
+    blocks: HashMap<String, (usize, usize)>,
+    /// Byte offset of each line
+    line_starts: Vec<usize>,
+}
+
+impl Template {
+    fn parse(source: &str) -> Self {
+        let mut template = Template {
+            line_starts: std::iter::once(0)
+                .chain(source.match_indices('\n').map(|(i, _)| i + 1))
+                .collect(),
+            ..Default::default()
+        };
+
+        let mut rest = 0;
+        while let Some(open) = source[rest..].find("{{") {
+            let start = rest + open + 2;
+            let Some(close) = source[start..].find("}}") else {
+                break;
+            };
+            let end = start + close;
+            rest = end + 2;
+
+            let mut text = &source[start..end];
+            let mut offset = start;
+            if let Some(trimmed) = text.strip_prefix('-').filter(|t| t.starts_with(' ')) {
+                text = trimmed;
+                offset += 1;
+            }
+            if let Some(trimmed) = text.strip_suffix('-').filter(|t| t.ends_with(' ')) {
+                text = trimmed;
+            }
+            let body = text.trim_start();
+            offset += text.len() - body.len();
+            let body = body.trim_end();
+            if body.is_empty() || body.starts_with("/*") {
+                continue;
+            }
+            template.actions.push(Action {
+                text: body.to_string(),
+                offset,
+            });
+        }
+
+        let mut open: Vec<usize> = Vec::new();
+        for (i, action) in template.actions.iter().enumerate() {
+            match action.keyword().0 {
+                "if" | "range" | "with" | "define" | "block" => open.push(i),
+                "end" => {
+                    let Some(start) = open.pop() else {
+                        continue;
+                    };
+                    template.ends.insert(start, i);
+                    let (opener, rest) = template.actions[start].keyword();
+                    if matches!(opener, "define" | "block") {
+                        if let Some((name, _)) = leading_literal(rest) {
+                            template.blocks.insert(name, (start + 1, i));
+                        }
+                    }
+                }
+                _ => {}
+            }
+        }
+        template
+    }
+
+    /// Line (1-indexed) of a byte offset
+    fn line_of(&self, offset: usize) -> usize {
+        self.line_starts.partition_point(|&start| start <= offset)
+    }
+
+    /// Line and column (1-indexed) of a byte offset
+    fn position(&self, offset: usize) -> (usize, usize) {
+        let line = self.line_of(offset);
+        (line, offset - self.line_starts[line - 1] + 1)
+    }
+}
+
+/// A `.Field.Chain` or `$.Field.Chain` in a pipeline
+#[derive(Debug, PartialEq, Eq)]
+struct FieldChain<'a> {
+    /// Text of the chain
+    text: &'a str,
+    /// Byte offset of the chain in the pipeline
+    offset: usize,
+    /// Whether the chain starts at `$` rather than the dot
+    from_root: bool,
+    /// Field and method names, with their offsets (of the `.`) in the chain
+    fields: Vec<(usize, String)>,
+}
+
+/// Field chains of a pipeline, skipping string literals and chains on
+/// variables and parenthesized results
+fn field_chains(text: &str) -> Vec<FieldChain<'_>> {
+    let bytes = text.as_bytes();
+    let mut chains = Vec::new();
+    let mut i = 0;
+    while i < bytes.len() {
+        match bytes[i] {
+            quote @ (b'"' | b'\'' | b'`') => {
+                i += 1;
+                while i < bytes.len() && bytes[i] != quote {
+                    if bytes[i] == b'\\' && quote != b'`' {
+                        i += 1;
+                    }
+                    i += 1;
+                }
+                i += 1;
+            }
+            b'$' | b'.' => {
+                let start = i;
+                let from_root = bytes[i] == b'$';
+                let follows_value = i > 0
+                    && (bytes[i - 1].is_ascii_alphanumeric() || b"_)]".contains(&bytes[i - 1]));
+                let mut end = i + 1;
+                while end < bytes.len()
+                    && (bytes[end].is_ascii_alphanumeric()
+                        || bytes[end] == b'_'
+                        || bytes[end] == b'.')
+                {
+                    end += 1;
+                }
+                let chain = &text[start..end];
+                let is_root = chain == "$" || chain.starts_with("$.");
+                if !follows_value && (!from_root || is_root) {
+                    let names = if from_root { &chain[1..] } else { chain };
+                    let mut fields = Vec::new();
+                    let mut at = usize::from(from_root);
+                    for name in names.split('.').skip(1) {
+                        if is_identifier(name) {
+                            fields.push((at, name.to_string()));
+                        }
+                        at += name.len() + 1;
+                    }
+                    chains.push(FieldChain {
+                        text: chain,
+                        offset: start,
+                        from_root,
+                        fields,
+                    });
+                }
+                i = end;
+            }
+            _ => i += 1,
+        }
+    }
+    chains
+}
+
+/// Byte index of the bracket closing an opened one in `text`
+fn closing(text: &str, open: char, close: char) -> Option<usize> {
+    let mut depth = 0;
+    for (i, c) in text.char_indices() {
+        if c == open {
+            depth += 1;
+        } else if c == close {
+            if depth == 0 {
+                return Some(i);
+            }
+            depth -= 1;
+        }
+    }
+    None
+}
+
+/// Arguments of a call whose opening parenthesis precedes `text`
+fn arguments(text: &str) -> Option<&str> {
+    closing(text, '(', ')').map(|end| &text[..end])
+}
+
+/// Split an argument list at top-level commas
+fn split_arguments(args: &str) -> Vec<&str> {
+    let mut parts = Vec::new();
+    let mut depth = 0;
+    let mut quote: Option<char> = None;
+    let mut start = 0;
+    let mut escaped = false;
+    for (i, c) in args.char_indices() {
+        if let Some(q) = quote {
+            if escaped {
+                escaped = false;
+            } else if c == '\\' && q != '`' {
+                escaped = true;
+            } else if c == q {
+                quote = None;
+            }
+            continue;
+        }
+        match c {
+            '"' | '`' | '\'' => quote = Some(c),
+            '(' | '[' | '{' => depth += 1,
+            ')' | ']' | '}' => depth -= 1,
+            ',' if depth == 0 => {
+                parts.push(args[start..i].trim());
+                start = i + 1;
+            }
+            _ => {}
+        }
+    }
+    let last = args[start..].trim();
+    if !last.is_empty() {
+        parts.push(last);
+    }
+    parts
+}
+
+/// Value of a Go string literal argument
+fn string_literal(arg: &str) -> Option<String> {
+    let arg = arg.trim();
+    ["\"", "`"].iter().find_map(|quote| {
+        arg.strip_prefix(quote)
+            .and_then(|rest| rest.strip_suffix(quote))
+            .filter(|value| !value.contains(quote))
+            .map(String::from)
+    })
+}
+
+/// A leading string literal of a template action and the rest
+fn leading_literal(text: &str) -> Option<(String, &str)> {
+    let text = text.trim_start();
+    let quote = text.chars().next().filter(|c| matches!(c, '"' | '`'))?;
+    let end = text[1..].find(quote)? + 1;
+    Some((text[1..end].to_string(), &text[end + 1..]))
+}
+
+/// Type of a composite literal value, as in `&Page{...}`
+fn literal_type(value: &str) -> Option<String> {
+    let value = value.trim().trim_start_matches('&');
+    let (head, _) = value.split_once('{')?;
+    Some(head.trim().to_string())
+}
+
+/// Text after a leading word, if `text` starts with it
+fn after_word<'a>(text: &'a str, word: &str) -> Option<&'a str> {
+    let rest = text.strip_prefix(word)?;
+    (!rest.starts_with(|c: char| c.is_alphanumeric() || c == '_')).then_some(rest)
+}
+
+/// Check if a string is a Go identifier
+fn is_identifier(s: &str) -> bool {
+    s.starts_with(|c: char| c.is_alphabetic() || c == '_')
+        && s.chars().all(|c| c.is_alphanumeric() || c == '_')
+}
+
+/// Line (1-indexed) of a byte offset
+fn line_of(source: &str, offset: usize) -> usize {
+    source[..offset].matches('\n').count() + 1
+}
+
+/// Value of a field declared with an anonymous type, from the start of its
+/// declared type: `struct`, `*struct`, `[]struct`, `map[K]struct`, `chan`
+fn anonymous_value<'g>(text: &str, anonymous: &'g Node) -> Value<'g> {
+    let text = text.trim().trim_start_matches('*');
+    let element = if let Some(rest) = text.strip_prefix("map[") {
+        closing(rest, '[', ']').map(|end| &rest[end + 1..])
+    } else if let Some(rest) = text.strip_prefix('[') {
+        rest.find(']').map(|end| &rest[end + 1..])
+    } else if let Some(rest) = text.strip_prefix("chan") {
+        Some(rest)
+    } else {
+        return Value::Type(anonymous);
+    };
+    match element {
+        Some(element) => Value::Collection(Box::new(anonymous_value(element, anonymous))),
+        None => Value::Unknown,
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::diagnostics::DiagnosticKind;
+    use crate::graph::{CallableKind, ContainerKind, DataKind, EdgeType};
+
+    fn write(dir: &Path, file: &str, content: &str) {
+        let path = dir.join(file);
+        std::fs::create_dir_all(path.parent().unwrap()).unwrap();
+        std::fs::write(path, content).unwrap();
+    }
+
+    fn add_file(graph: &mut PetCodeGraph, file: &str, lines: usize) {
+        graph.add_node(Node::source_file(
+            file.to_string(),
+            file.to_string(),
+            String::new(),
+            lines,
+        ));
+    }
+
+    fn add_struct(graph: &mut PetCodeGraph, file: &str, name: &str, lines: (usize, usize)) {
+        graph.add_node(Node::container(
+            format!("{}:{}", file, name),
+            name.to_string(),
+            ContainerKind::Type,
+            Some("struct".to_string()),
+            file.to_string(),
+            lines.0,
+            lines.1,
+        ));
+    }
+
+    fn add_field(graph: &mut PetCodeGraph, file: &str, owner: &str, name: &str, line: usize) {
+        let owner = format!("{}:{}", file, owner);
+        let id = format!("{}:{}", owner, name);
+        graph.add_node(Node::data(
+            id.clone(),
+            name.to_string(),
+            DataKind::Field,
+            None,
+            file.to_string(),
+            line,
+            line,
+        ));
+        graph.add_edge(&owner, &id, EdgeData::contains());
+    }
+
+    fn add_fn(
+        graph: &mut PetCodeGraph,
+        file: &str,
+        name: &str,
+        receiver: Option<&str>,
+        lines: (usize, usize),
+    ) {
+        let mut node = Node::callable(
+            format!("{}:{}", file, name),
+            name.to_string(),
+            CallableKind::Function,
+            file.to_string(),
+            lines.0,
+            lines.1,
+        );
+        node.metadata.receiver = receiver.map(String::from);
+        graph.add_node(node);
+    }
+
+    fn uses(graph: &PetCodeGraph, source: &str) -> Vec<(String, Option<usize>)> {
+        let mut targets: Vec<(String, Option<usize>)> = graph
+            .outgoing_edges(source)
+            .filter(|(_, edge)| edge.edge_type == EdgeType::Uses)
+            .map(|(target, edge)| (target.id.clone(), edge.ref_line))
+            .collect();
+        targets.sort();
+        targets
+    }
+
+    #[test]
+    fn test_link_templates() {
+        let dir = tempfile::tempdir().unwrap();
+        write(
+            dir.path(),
+            "web/handler.go",
+            "package web\n\
+             \n\
+             var pages = template.Must(template.ParseFiles(\"templates/page.tmpl\"))\n\
+             \n\
+             type Page struct {\n\
+             \tTitle  string\n\
+             \tItems  []Item\n\
+             \tAuthor *User\n\
+             }\n\
+             \n\
+             type Item struct {\n\
+             \tName string\n\
+             }\n\
+             \n\
+             type User struct {\n\
+             \tName string\n\
+             }\n\
+             \n\
+             func (p *Page) Count() int { return len(p.Items) }\n\
+             \n\
+             func Show(w io.Writer) error {\n\
+             \tpage := &Page{Title: \"Home\"}\n\
+             \treturn pages.Execute(w, page)\n\
+             }\n",
+        );
+        write(
+            dir.path(),
+            "web/templates/page.tmpl",
+            "<h1>{{ .Title }}</h1>\n\
+             {{ range .Items }}<li>{{ .Name }} {{ .Price }}</li>{{ end }}\n\
+             {{- with .Author }}{{ .Name }} of {{ $.Count }}{{ end }}\n\
+             {{ .Subtitle | printf \"%s.Ignored\" }}\n",
+        );
+
+        let file = "web/handler.go";
+        let mut graph = PetCodeGraph::new();
+        add_file(&mut graph, file, 24);
+        add_struct(&mut graph, file, "Page", (5, 9));
+        add_field(&mut graph, file, "Page", "Title", 6);
+        add_field(&mut graph, file, "Page", "Items", 7);
+        add_field(&mut graph, file, "Page", "Author", 8);
+        add_struct(&mut graph, file, "Item", (11, 13));
+        add_field(&mut graph, file, "Item", "Name", 12);
+        add_struct(&mut graph, file, "User", (15, 17));
+        add_field(&mut graph, file, "User", "Name", 16);
+        add_fn(&mut graph, file, "Count", Some("Page"), (19, 19));
+        add_fn(&mut graph, file, "Show", None, (21, 24));
+
+        let links = link_templates(&mut graph, dir.path(), "", &[]);
+        assert_eq!(links.templates, 1);
+
+        let template = "web/templates/page.tmpl";
+        let node = graph.get_node(template).unwrap();
+        assert_eq!(node.metadata.language.as_deref(), Some(TEMPLATE_LANGUAGE));
+        assert_eq!(node.metadata.resolved_refs, Some(6));
+        assert_eq!(node.metadata.unresolved_refs, Some(2));
+        assert_eq!(uses(&graph, file), vec![(template.to_string(), Some(3))]);
+        assert_eq!(
+            uses(&graph, template),
+            vec![
+                ("web/handler.go:Count".to_string(), Some(3)),
+                ("web/handler.go:Item:Name".to_string(), Some(2)),
+                ("web/handler.go:Page".to_string(), None),
+                ("web/handler.go:Page:Author".to_string(), Some(3)),
+                ("web/handler.go:Page:Items".to_string(), Some(2)),
+                ("web/handler.go:Page:Title".to_string(), Some(1)),
+                ("web/handler.go:User:Name".to_string(), Some(3)),
+            ]
+        );
+
+        assert_eq!(links.diagnostics.len(), 2);
+        let price = &links.diagnostics[0];
+        assert_eq!(price.kind, DiagnosticKind::TemplateMismatch);
+        assert_eq!(price.file, template);
+        let span = price.span.unwrap();
+        assert_eq!((span.line, span.column, span.end_column), (2, 38, 44));
+        assert!(price.message.contains("`.Price`") && price.message.contains("`Item`"));
+        assert_eq!(links.diagnostics[1].span.unwrap().line, 4);
+    }
+
+    #[test]
+    fn test_link_anonymous_types() {
+        let dir = tempfile::tempdir().unwrap();
+        write(
+            dir.path(),
+            "web/handler.go",
+            "package web\n\
+             \n\
+             var pages = template.Must(template.ParseFiles(\"templates/page.tmpl\"))\n\
+             \n\
+             type Page struct {\n\
+             \tMeta struct {\n\
+             \t\tAuthor string\n\
+             \t}\n\
+             \tRows []struct {\n\
+             \t\tID int\n\
+             \t}\n\
+             }\n\
+             \n\
+             func Show(w io.Writer) error {\n\
+             \tpage := &Page{}\n\
+             \treturn pages.Execute(w, page)\n\
+             }\n",
+        );
+        write(
+            dir.path(),
+            "web/templates/page.tmpl",
+            "{{ .Meta.Author }}\n{{ range .Rows }}{{ .ID }} {{ .Name }}{{ end }}\n",
+        );
+
+        let file = "web/handler.go";
+        let mut graph = PetCodeGraph::new();
+        add_file(&mut graph, file, 17);
+        add_struct(&mut graph, file, "Page", (5, 12));
+        for (field, lines) in [("Meta", (6, 8)), ("Rows", (9, 11))] {
+            add_field(&mut graph, file, "Page", field, lines.0);
+            let name = format!("{}.struct", field);
+            let id = format!("{}:Page:{}", file, name);
+            graph.add_node(Node::container(
+                id.clone(),
+                name.clone(),
+                ContainerKind::Type,
+                Some("struct".to_string()),
+                file.to_string(),
+                lines.0,
+                lines.1,
+            ));
+            graph.add_edge(&format!("{}:Page", file), &id, EdgeData::contains());
+            graph.add_edge(
+                &format!("{}:Page:{}", file, field),
+                &id,
+                EdgeData::uses(Some(lines.0), Some(name)),
+            );
+        }
+        add_field(&mut graph, file, "Page:Meta.struct", "Author", 7);
+        add_field(&mut graph, file, "Page:Rows.struct", "ID", 10);
+        add_fn(&mut graph, file, "Show", None, (14, 17));
+
+        let links = link_templates(&mut graph, dir.path(), "", &[]);
+        let template = "web/templates/page.tmpl";
+        let targets: Vec<String> = uses(&graph, template)
+            .into_iter()
+            .map(|(target, _)| target)
+            .collect();
+        assert!(targets.contains(&"web/handler.go:Page:Meta.struct:Author".to_string()));
+        assert!(targets.contains(&"web/handler.go:Page:Rows.struct:ID".to_string()));
+
+        // Fields missing from the anonymous type are reported against it
+        assert_eq!(links.diagnostics.len(), 1);
+        assert!(links.diagnostics[0].message.contains("`.Name`"));
+    }
+
+    #[test]
+    fn test_link_named_templates() {
+        let dir = tempfile::tempdir().unwrap();
+        write(
+            dir.path(),
+            "app/render.go",
+            "package app\n\
+             \n\
+             type server struct {\n\
+             \ttmpl *template.Template\n\
+             }\n\
+             \n\
+             type Profile struct {\n\
+             \tName string\n\
+             }\n\
+             \n\
+             func newServer() *server {\n\
+             \treturn &server{tmpl: template.Must(template.ParseGlob(\"views/*.gotmpl\"))}\n\
+             }\n\
+             \n\
+             func (s *server) render(w io.Writer, profile *Profile) {\n\
+             \ts.tmpl.ExecuteTemplate(w, \"profile\", profile)\n\
+             }\n",
+        );
+        write(
+            dir.path(),
+            "app/views/layout.gotmpl",
+            "{{define \"profile\"}}<h2>{{.Name}}</h2>{{template \"card\" .}}{{end}}\n\
+             {{define \"card\"}}<p>{{.Email}}</p>{{end}}\n",
+        );
+
+        let file = "app/render.go";
+        let mut graph = PetCodeGraph::new();
+        add_file(&mut graph, file, 17);
+        add_struct(&mut graph, file, "server", (3, 5));
+        add_field(&mut graph, file, "server", "tmpl", 4);
+        add_struct(&mut graph, file, "Profile", (7, 9));
+        add_field(&mut graph, file, "Profile", "Name", 8);
+        add_fn(&mut graph, file, "newServer", None, (11, 13));
+        add_fn(&mut graph, file, "render", Some("server"), (15, 17));
+
+        let template = "app/views/layout.gotmpl";
+        let links = link_templates(&mut graph, dir.path(), "", &[template.to_string()]);
+        assert_eq!(links.templates, 1);
+        assert_eq!(
+            uses(&graph, "app/render.go:newServer"),
+            vec![(template.to_string(), Some(12))]
+        );
+        assert_eq!(
+            uses(&graph, template),
+            vec![
+                ("app/render.go:Profile".to_string(), None),
+                ("app/render.go:Profile:Name".to_string(), Some(1)),
+            ]
+        );
+
+        // The card template is checked with the dot passed to it
+        assert_eq!(links.diagnostics.len(), 1);
+        assert_eq!(links.diagnostics[0].span.unwrap().line, 2);
+    }
+
+    #[test]
+    fn test_field_chains() {
+        let chains = field_chains(r#"index .Items 0 | printf "%s.X" $.Site.Name $x.Y (call .F).G"#);
+        let texts: Vec<&str> = chains.iter().map(|c| c.text).collect();
+        assert_eq!(texts, vec![".Items", "$.Site.Name", ".F"]);
+        assert!(chains[1].from_root);
+        assert_eq!(
+            chains[1].fields,
+            vec![(1, "Site".to_string()), (6, "Name".to_string())]
+        );
+        assert!(is_template_path(Path::new("views/page.gotmpl")));
+        assert!(!is_template_path(Path::new("main.go")));
+    }
+}
@@ -0,0 +1,29 @@
+//! Graph fixtures shared by unit tests.
+//!
+//! Node ids follow the builder's `file:name` form, so a fixture only names
+//! the node; tests needing other lines or metadata adjust the returned node.
+
+use crate::graph::{CallableKind, EdgeData, Node, PetCodeGraph};
+
+/// A function node for `id`, spanning lines 1-5 of the file before the first
+/// `:` and named after the last segment.
+pub(crate) fn function(id: &str) -> Node {
+    Node::callable(
+        id.to_string(),
+        id.rsplit(':').next().unwrap().to_string(),
+        CallableKind::Function,
+        id.split(':').next().unwrap().to_string(),
+        1,
+        5,
+    )
+}
+
+/// Add [`function`]`(id)` to `graph`.
+pub(crate) fn add_fn(graph: &mut PetCodeGraph, id: &str) {
+    graph.add_node(function(id));
+}
+
+/// Add a USES edge from `source` to `target`, referenced on line 2.
+pub(crate) fn uses(graph: &mut PetCodeGraph, source: &str, target: &str) {
+    graph.add_edge(source, target, EdgeData::uses(Some(2), None));
+}
@@ -0,0 +1,326 @@
+//! Type Usage Weights
+//!
+//! How load-bearing each type is: the number of times it appears in function
+//! signatures, fields, and function bodies across the workspace, counted from
+//! the USES edges reaching it. Types get the counts as the `signature_uses`,
+//! `field_uses`, and `instantiations` attributes and their total as
+//! `weight`, so queries can rank them
+//! (`MATCH (t:Struct) RETURN t.name, t.weight ORDER BY t.weight DESC`) and
+//! visualizations can size them.
+//!
+//! Edges carry weights too: each USES edge to a type has the number of uses
+//! of the type by the edge's source, and each DEPENDS_ON edge the number of
+//! uses from the dependent component or repository of types declared in the
+//! dependency.
+//!
+//! A use is classified by the node using the type: a callable using it on
+//! its declaration line uses it in its signature, and elsewhere in its body;
+//! fields, variables, types (embedding), and files declare with it. Uses by
+//! the type's own members, such as method receivers, are not counted.
+
+use std::collections::{HashMap, HashSet};
+
+use crate::analysis::api_surface::is_type;
+use crate::analysis::package_of;
+use crate::graph::{EdgeType, Node, PetCodeGraph};
+
+/// Result of weighing type usage.
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq)]
+pub struct TypeUsageStats {
+    /// Types weighed, including unused ones
+    pub types: usize,
+    /// Uses counted
+    pub uses: usize,
+    /// DEPENDS_ON edges weighed
+    pub dependencies: usize,
+}
+
+/// Uses of one type
+#[derive(Debug, Clone, Copy, Default)]
+struct Counts {
+    signatures: u32,
+    fields: u32,
+    instantiations: u32,
+}
+
+/// Weigh every type and every USES and DEPENDS_ON edge by type usage,
+/// replacing earlier weights.
+pub fn weigh_type_usage(graph: &mut PetCodeGraph) -> TypeUsageStats {
+    let mut stats = TypeUsageStats::default();
+    let mut counts: HashMap<String, Counts> = HashMap::new();
+    // Uses by source, then by type
+    let mut pairs: HashMap<String, HashMap<String, u32>> = HashMap::new();
+
+    for (source, target, data) in graph.edges_by_type(EdgeType::Uses) {
+        if !is_type(target) || is_member(graph, source, target) {
+            continue;
+        }
+        let counts = counts.entry(target.id.clone()).or_default();
+        if !source.is_callable() {
+            counts.fields += 1;
+        } else if data.ref_line == Some(source.line) {
+            counts.signatures += 1;
+        } else {
+            counts.instantiations += 1;
+        }
+        *pairs
+            .entry(source.id.clone())
+            .or_default()
+            .entry(target.id.clone())
+            .or_default() += 1;
+        stats.uses += 1;
+    }
+
+    let dependencies = dependency_weights(graph, &pairs);
+
+    let inner = graph.inner_mut();
+    for node in inner.node_weights_mut() {
+        let uses = is_type(node).then(|| counts.get(&node.id).copied().unwrap_or_default());
+        let metadata = &mut node.metadata;
+        if let Some(c) = uses {
+            metadata.signature_uses = Some(c.signatures);
+            metadata.field_uses = Some(c.fields);
+            metadata.instantiations = Some(c.instantiations);
+            metadata.weight = Some(c.signatures + c.fields + c.instantiations);
+            stats.types += 1;
+        } else {
+            metadata.signature_uses = None;
+            metadata.field_uses = None;
+            metadata.instantiations = None;
+            metadata.weight = None;
+        }
+    }
+    for index in inner.edge_indices().collect::<Vec<_>>() {
+        let Some((source, target)) = inner.edge_endpoints(index) else {
+            continue;
+        };
+        let (source, target) = (inner[source].id.as_str(), inner[target].id.as_str());
+        let weight = match inner[index].edge_type {
+            EdgeType::Uses => pairs.get(source).and_then(|uses| uses.get(target)),
+            EdgeType::DependsOn => dependencies.get(source).and_then(|uses| uses.get(target)),
+            _ => None,
+        }
+        .copied();
+        if inner[index].edge_type == EdgeType::DependsOn && weight.is_some() {
+            stats.dependencies += 1;
+        }
+        inner[index].weight = weight;
+    }
+    stats
+}
+
+/// Uses of types across each DEPENDS_ON edge, by the dependent and then the
+/// dependency
+fn dependency_weights(
+    graph: &PetCodeGraph,
+    pairs: &HashMap<String, HashMap<String, u32>>,
+) -> HashMap<String, HashMap<String, u32>> {
+    let depends_on: HashSet<(&str, &str)> = graph
+        .edges_by_type(EdgeType::DependsOn)
+        .map(|(source, target, _)| (source.id.as_str(), target.id.as_str()))
+        .collect();
+    let endpoints: HashSet<&str> = depends_on.iter().flat_map(|&(a, b)| [a, b]).collect();
+    let mut weights: HashMap<String, HashMap<String, u32>> = HashMap::new();
+    if endpoints.is_empty() {
+        return weights;
+    }
+
+    let mut owners: HashMap<String, Option<String>> = HashMap::new();
+    for (source, uses) in pairs {
+        let Some(from) = owner(graph, source, &endpoints, &mut owners) else {
+            continue;
+        };
+        for (target, count) in uses {
+            let Some(to) = owner(graph, target, &endpoints, &mut owners) else {
+                continue;
+            };
+            if depends_on.contains(&(from.as_str(), to.as_str())) {
+                *weights
+                    .entry(from.clone())
+                    .or_default()
+                    .entry(to)
+                    .or_default() += count;
+            }
+        }
+    }
+    weights
+}
+
+/// The nearest container of a node taking part in a dependency (memoized)
+fn owner(
+    graph: &PetCodeGraph,
+    id: &str,
+    endpoints: &HashSet<&str>,
+    owners: &mut HashMap<String, Option<String>>,
+) -> Option<String> {
+    if let Some(owner) = owners.get(id) {
+        return owner.clone();
+    }
+    let owner = if endpoints.contains(id) {
+        Some(id.to_string())
+    } else {
+        graph
+            .parent(id)
+            .map(|parent| parent.id.clone())
+            .and_then(|parent| owner(graph, &parent, endpoints, owners))
+    };
+    owners.insert(id.to_string(), owner.clone());
+    owner
+}
+
+/// Whether `source` is the type itself or one of its members: nested in it,
+/// or a Go method with the type as its receiver
+fn is_member(graph: &PetCodeGraph, source: &Node, ty: &Node) -> bool {
+    if source.metadata.receiver.as_deref() == Some(ty.name.as_str())
+        && package_of(&source.file) == package_of(&ty.file)
+    {
+        return true;
+    }
+    let mut node = Some(source);
+    while let Some(current) = node.filter(|n| !n.is_file()) {
+        if current.id == ty.id {
+            return true;
+        }
+        node = graph.parent(&current.id);
+    }
+    false
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::{CallableKind, ContainerKind, DataKind, Edge, EdgeData};
+
+    const STORE: &str = "store/db.go:Store";
+    const NEW_SERVER: &str = "api/server.go:NewServer";
+
+    fn ty(file: &str, name: &str) -> Node {
+        Node::container(
+            format!("{}:{}", file, name),
+            name.to_string(),
+            ContainerKind::Type,
+            Some("struct".to_string()),
+            file.to_string(),
+            1,
+            5,
+        )
+    }
+
+    fn function(file: &str, name: &str, line: usize) -> Node {
+        Node::callable(
+            format!("{}:{}", file, name),
+            name.to_string(),
+            CallableKind::Function,
+            file.to_string(),
+            line,
+            line + 5,
+        )
+    }
+
+    fn uses(graph: &mut PetCodeGraph, source: &str, target: &str, line: usize) {
+        graph.add_edge(source, target, EdgeData::uses(Some(line), None));
+    }
+
+    #[test]
+    fn test_weigh_type_usage() {
+        let mut graph = PetCodeGraph::new();
+        graph.add_node(ty("store/db.go", "Store"));
+        graph.add_node(ty("store/db.go", "Unused"));
+        graph.add_node(function("api/server.go", "NewServer", 10));
+        graph.add_node(Node::data(
+            "api/server.go:Server:store".to_string(),
+            "store".to_string(),
+            DataKind::Field,
+            None,
+            "api/server.go".to_string(),
+            4,
+            4,
+        ));
+        let mut method = function("store/db.go", "Save", 20);
+        method.metadata.receiver = Some("Store".to_string());
+        graph.add_node(method);
+
+        // Signature, body twice, field, and the receiver of its own method
+        uses(&mut graph, NEW_SERVER, STORE, 10);
+        uses(&mut graph, NEW_SERVER, STORE, 12);
+        uses(&mut graph, NEW_SERVER, STORE, 13);
+        uses(&mut graph, "api/server.go:Server:store", STORE, 4);
+        uses(&mut graph, "store/db.go:Save", STORE, 20);
+
+        let stats = weigh_type_usage(&mut graph);
+        assert_eq!(stats.types, 2);
+        assert_eq!(stats.uses, 4);
+
+        let store = &graph.get_node(STORE).unwrap().metadata;
+        assert_eq!(store.signature_uses, Some(1));
+        assert_eq!(store.instantiations, Some(2));
+        assert_eq!(store.field_uses, Some(1));
+        assert_eq!(store.weight, Some(4));
+        let unused = &graph.get_node("store/db.go:Unused").unwrap().metadata;
+        assert_eq!(unused.weight, Some(0));
+        assert!(graph
+            .get_node(NEW_SERVER)
+            .unwrap()
+            .metadata
+            .weight
+            .is_none());
+
+        let weights: Vec<(String, Option<u32>)> =
+            graph.iter_edges().map(|e| (e.source, e.weight)).collect();
+        assert_eq!(
+            weights
+                .iter()
+                .filter(|(source, _)| source == NEW_SERVER)
+                .map(|(_, weight)| *weight)
+                .collect::<Vec<_>>(),
+            vec![Some(3); 3]
+        );
+        assert!(weights
+            .iter()
+            .any(|(source, weight)| source == "store/db.go:Save" && weight.is_none()));
+    }
+
+    #[test]
+    fn test_dependency_weights() {
+        let mut graph = PetCodeGraph::new();
+        for component in ["api", "store"] {
+            graph.add_node(Node::component(
+                component.to_string(),
+                component.to_string(),
+                format!("{}/go.mod", component),
+                Default::default(),
+            ));
+        }
+        graph.add_node(ty("store/db.go", "Store"));
+        graph.add_node(function("api/server.go", "NewServer", 10));
+        graph.add_edge_from_struct(&Edge::contains("store".to_string(), STORE.to_string()));
+        graph.add_edge_from_struct(&Edge::contains("api".to_string(), NEW_SERVER.to_string()));
+        graph.add_edge_from_struct(&Edge::depends_on(
+            "api".to_string(),
+            "store".to_string(),
+            None,
+            None,
+            None,
+        ));
+        uses(&mut graph, NEW_SERVER, STORE, 10);
+        uses(&mut graph, NEW_SERVER, STORE, 11);
+
+        let stats = weigh_type_usage(&mut graph);
+        assert_eq!(stats.dependencies, 1);
+        let dependency = graph
+            .iter_edges()
+            .find(|e| e.edge_type == EdgeType::DependsOn)
+            .unwrap();
+        assert_eq!(dependency.weight, Some(2));
+
+        // Weighing again replaces the weights
+        graph.remove_edges_by_type(EdgeType::Uses);
+        weigh_type_usage(&mut graph);
+        let dependency = graph
+            .iter_edges()
+            .find(|e| e.edge_type == EdgeType::DependsOn)
+            .unwrap();
+        assert_eq!(dependency.weight, None);
+        assert_eq!(graph.get_node(STORE).unwrap().metadata.weight, Some(0));
+    }
+}
@@ -0,0 +1,576 @@
+//! Index Validation
+//!
+//! Consistency checks of a stored index, run on its raw nodes and edges
+//! (assembling a [`PetCodeGraph`](crate::graph::PetCodeGraph) silently drops
+//! edges with a missing endpoint, hiding the very problems looked for):
+//!
+//! - **Schema**: the index's schema version can be read by this build
+//! - **Referential integrity**: every edge's source and target exist
+//! - **ID uniqueness**: node IDs and stable symbol IDs are unique
+//! - **Spans**: line ranges are well-formed, within their parent's span,
+//!   and within their file
+//!
+//! Each problem names the nodes, file, and line involved, so it can be
+//! filed as a bug against the extractor or storage layer that produced it.
+
+use std::collections::{BTreeMap, HashMap};
+
+use serde::Serialize;
+
+use crate::diagnostics::Severity;
+use crate::graph::{Edge, EdgeType, Node, GRAPH_SCHEMA_VERSION};
+
+/// Raw contents of a stored index.
+#[derive(Debug, Clone, Default)]
+pub struct IndexContents {
+    /// Schema version the index was written with, if recorded
+    pub schema_version: Option<String>,
+    /// Every stored node, duplicates included
+    pub nodes: Vec<Node>,
+    /// Every stored edge, including edges whose endpoints are missing
+    pub edges: Vec<Edge>,
+}
+
+/// Kind of consistency check.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, PartialOrd, Ord, Hash, Serialize)]
+#[serde(rename_all = "snake_case")]
+pub enum Check {
+    /// Schema version compatibility
+    Schema,
+    /// Edge with a missing source or target node
+    DanglingEdge,
+    /// Node ID stored more than once
+    DuplicateId,
+    /// Stable symbol ID shared by different nodes
+    DuplicateSymbolId,
+    /// Malformed or misplaced line span
+    Span,
+}
+
+impl Check {
+    /// Check name as used in output
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            Self::Schema => "schema",
+            Self::DanglingEdge => "dangling_edge",
+            Self::DuplicateId => "duplicate_id",
+            Self::DuplicateSymbolId => "duplicate_symbol_id",
+            Self::Span => "span",
+        }
+    }
+}
+
+/// A consistency problem found in an index.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize)]
+pub struct Problem {
+    /// Check that found the problem
+    pub check: Check,
+    /// How much the problem affects the graph
+    pub severity: Severity,
+    /// Human-readable description
+    pub message: String,
+    /// IDs of the nodes involved
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    pub nodes: Vec<String>,
+    /// File the problem is located in
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub file: Option<String>,
+    /// Line the problem is located at (1-indexed)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub line: Option<usize>,
+}
+
+impl Problem {
+    fn new(check: Check, severity: Severity, message: String) -> Self {
+        Self {
+            check,
+            severity,
+            message,
+            nodes: Vec::new(),
+            file: None,
+            line: None,
+        }
+    }
+
+    fn with_nodes(mut self, nodes: Vec<String>) -> Self {
+        self.nodes = nodes;
+        self
+    }
+
+    fn at(mut self, file: &str, line: Option<usize>) -> Self {
+        if !file.is_empty() {
+            self.file = Some(file.to_string());
+            self.line = line;
+        }
+        self
+    }
+}
+
+/// Result of validating an index.
+#[derive(Debug, Clone, Default, Serialize)]
+pub struct ValidationReport {
+    /// Schema version the index was written with, if recorded
+    pub schema_version: Option<String>,
+    /// Number of stored nodes
+    pub nodes: usize,
+    /// Number of stored edges
+    pub edges: usize,
+    /// Problems found, by check
+    pub problems: Vec<Problem>,
+}
+
+impl ValidationReport {
+    /// Whether no problem is an error
+    pub fn is_valid(&self) -> bool {
+        self.count(Severity::Error) == 0
+    }
+
+    /// Number of problems of a severity
+    pub fn count(&self, severity: Severity) -> usize {
+        self.problems
+            .iter()
+            .filter(|p| p.severity == severity)
+            .count()
+    }
+
+    /// Problems grouped by check, in check order
+    pub fn by_check(&self) -> BTreeMap<Check, Vec<&Problem>> {
+        let mut groups: BTreeMap<Check, Vec<&Problem>> = BTreeMap::new();
+        for problem in &self.problems {
+            groups.entry(problem.check).or_default().push(problem);
+        }
+        groups
+    }
+}
+
+/// Validate the contents of an index.
+pub fn validate(contents: &IndexContents) -> ValidationReport {
+    let mut problems = Vec::new();
+    problems.extend(check_schema(contents.schema_version.as_deref()));
+
+    let mut nodes: HashMap<&str, &Node> = HashMap::with_capacity(contents.nodes.len());
+    let mut copies: BTreeMap<&str, Vec<&Node>> = BTreeMap::new();
+    for node in &contents.nodes {
+        if let Some(first) = nodes.insert(node.id.as_str(), node) {
+            copies
+                .entry(node.id.as_str())
+                .or_insert_with(|| vec![first]);
+            copies.get_mut(node.id.as_str()).unwrap().push(node);
+        }
+    }
+    problems.extend(check_duplicate_ids(&copies));
+    problems.extend(check_symbol_ids(&nodes));
+    problems.extend(check_edges(&contents.edges, &nodes));
+    problems.extend(check_spans(&contents.edges, &nodes));
+
+    ValidationReport {
+        schema_version: contents.schema_version.clone(),
+        nodes: contents.nodes.len(),
+        edges: contents.edges.len(),
+        problems,
+    }
+}
+
+/// Check a schema version against the one this build writes.
+///
+/// Another major version cannot be read. A newer minor version carries
+/// attributes this build ignores, and an older one lacks attributes added
+/// since, until re-indexed.
+pub fn check_schema(version: Option<&str>) -> Option<Problem> {
+    let Some(version) = version else {
+        return Some(Problem::new(
+            Check::Schema,
+            Severity::Info,
+            "no schema version recorded; the index predates provenance".to_string(),
+        ));
+    };
+    let current = parse_version(GRAPH_SCHEMA_VERSION).expect("valid schema version");
+    let Some(found) = parse_version(version) else {
+        return Some(Problem::new(
+            Check::Schema,
+            Severity::Error,
+            format!("unreadable schema version '{}'", version),
+        ));
+    };
+    let (severity, message) = if found.0 != current.0 {
+        (
+            Severity::Error,
+            format!(
+                "schema version {} is incompatible with this build (schema {})",
+                version, GRAPH_SCHEMA_VERSION
+            ),
+        )
+    } else if found > current {
+        (
+            Severity::Warning,
+            format!(
+                "schema version {} is newer than this build's ({}); newer attributes are ignored",
+                version, GRAPH_SCHEMA_VERSION
+            ),
+        )
+    } else if found < current {
+        (
+            Severity::Info,
+            format!(
+                "schema version {} predates this build's ({}); re-index for the attributes added since",
+                version, GRAPH_SCHEMA_VERSION
+            ),
+        )
+    } else {
+        return None;
+    };
+    Some(Problem::new(Check::Schema, severity, message))
+}
+
+/// Parse a `major.minor` schema version
+fn parse_version(version: &str) -> Option<(u32, u32)> {
+    let (major, minor) = version.split_once('.')?;
+    Some((major.parse().ok()?, minor.parse().ok()?))
+}
+
+/// Node IDs stored more than once
+fn check_duplicate_ids(copies: &BTreeMap<&str, Vec<&Node>>) -> Vec<Problem> {
+    copies
+        .iter()
+        .map(|(id, copies)| {
+            let locations: Vec<String> = copies.iter().map(|node| location(node)).collect();
+            Problem::new(
+                Check::DuplicateId,
+                Severity::Error,
+                format!(
+                    "node ID '{}' is stored {} times ({})",
+                    id,
+                    copies.len(),
+                    locations.join(", ")
+                ),
+            )
+            .with_nodes(vec![id.to_string()])
+            .at(&copies[0].file, Some(copies[0].line))
+        })
+        .collect()
+}
+
+/// Stable symbol IDs shared by different nodes
+fn check_symbol_ids(nodes: &HashMap<&str, &Node>) -> Vec<Problem> {
+    let mut by_symbol: BTreeMap<&str, Vec<&Node>> = BTreeMap::new();
+    for node in nodes.values() {
+        if let Some(symbol_id) = node.metadata.symbol_id.as_deref() {
+            by_symbol.entry(symbol_id).or_default().push(node);
+        }
+    }
+    by_symbol
+        .into_iter()
+        .filter(|(_, nodes)| nodes.len() > 1)
+        .map(|(symbol_id, mut nodes)| {
+            nodes.sort_by(|a, b| a.id.cmp(&b.id));
+            let ids: Vec<String> = nodes.iter().map(|node| node.id.clone()).collect();
+            Problem::new(
+                Check::DuplicateSymbolId,
+                Severity::Error,
+                format!(
+                    "symbol ID '{}' is shared by {} nodes: {}",
+                    symbol_id,
+                    ids.len(),
+                    ids.join(", ")
+                ),
+            )
+            .at(&nodes[0].file, Some(nodes[0].line))
+            .with_nodes(ids)
+        })
+        .collect()
+}
+
+/// Edges with a missing source or target
+fn check_edges(edges: &[Edge], nodes: &HashMap<&str, &Node>) -> Vec<Problem> {
+    let mut problems = Vec::new();
+    for edge in edges {
+        let source = nodes.get(edge.source.as_str());
+        let target = nodes.get(edge.target.as_str());
+        let missing = match (source, target) {
+            (Some(_), Some(_)) => continue,
+            (None, Some(_)) => "source",
+            (Some(_), None) => "target",
+            (None, None) => "source and target",
+        };
+        let mut message = format!(
+            "{} edge {} -> {} has a missing {}",
+            edge.edge_type.as_str(),
+            edge.source,
+            edge.target,
+            missing
+        );
+        if let Some(ident) = &edge.ident {
+            message.push_str(&format!(" (ident '{}')", ident));
+        }
+        let file = source
+            .or(target)
+            .map(|node| node.file.as_str())
+            .unwrap_or("");
+        problems.push(
+            Problem::new(Check::DanglingEdge, Severity::Error, message)
+                .with_nodes(vec![edge.source.clone(), edge.target.clone()])
+                .at(file, edge.ref_line.or(source.map(|node| node.line))),
+        );
+    }
+    problems
+}
+
+/// Malformed spans, and spans outside their parent or file
+fn check_spans(edges: &[Edge], nodes: &HashMap<&str, &Node>) -> Vec<Problem> {
+    let mut problems = Vec::new();
+    let mut ids: Vec<&&str> = nodes.keys().collect();
+    ids.sort();
+
+    for id in &ids {
+        let node = nodes[**id];
+        if !has_span(node) {
+            continue;
+        }
+        if node.line == 0 || node.end_line < node.line {
+            problems.push(
+                Problem::new(
+                    Check::Span,
+                    Severity::Error,
+                    format!(
+                        "{} has a malformed span (lines {}-{})",
+                        node.id, node.line, node.end_line
+                    ),
+                )
+                .with_nodes(vec![node.id.clone()])
+                .at(&node.file, Some(node.line)),
+            );
+            continue;
+        }
+        let Some(file) = nodes.get(node.file.as_str()).filter(|file| file.is_file()) else {
+            continue;
+        };
+        if node.end_line > file.end_line {
+            problems.push(
+                Problem::new(
+                    Check::Span,
+                    Severity::Warning,
+                    format!(
+                        "{} ends at line {}, past the end of {} ({} lines)",
+                        node.id, node.end_line, node.file, file.end_line
+                    ),
+                )
+                .with_nodes(vec![node.id.clone()])
+                .at(&node.file, Some(node.line)),
+            );
+        }
+    }
+
+    let mut parents: BTreeMap<&str, Vec<&str>> = BTreeMap::new();
+    for edge in edges.iter().filter(|e| e.edge_type == EdgeType::Contains) {
+        let (Some(parent), Some(child)) = (
+            nodes.get(edge.source.as_str()),
+            nodes.get(edge.target.as_str()),
+        ) else {
+            continue;
+        };
+        parents.entry(&child.id).or_default().push(&parent.id);
+        if !has_span(parent) || !has_span(child) || parent.file != child.file {
+            continue;
+        }
+        if child.line < parent.line || child.end_line > parent.end_line {
+            problems.push(
+                Problem::new(
+                    Check::Span,
+                    Severity::Warning,
+                    format!(
+                        "{} (lines {}-{}) lies outside its parent {} (lines {}-{})",
+                        child.id,
+                        child.line,
+                        child.end_line,
+                        parent.id,
+                        parent.line,
+                        parent.end_line
+                    ),
+                )
+                .with_nodes(vec![child.id.clone(), parent.id.clone()])
+                .at(&child.file, Some(child.line)),
+            );
+        }
+    }
+    for (child, mut parents) in parents.into_iter().filter(|(_, p)| p.len() > 1) {
+        parents.sort();
+        parents.dedup();
+        if parents.len() < 2 {
+            continue;
+        }
+        let node = nodes[child];
+        let mut involved = vec![child.to_string()];
+        involved.extend(parents.iter().map(|p| p.to_string()));
+        problems.push(
+            Problem::new(
+                Check::Span,
+                Severity::Warning,
+                format!(
+                    "{} is contained by {} parents: {}",
+                    child,
+                    parents.len(),
+                    parents.join(", ")
+                ),
+            )
+            .with_nodes(involved)
+            .at(&node.file, Some(node.line)),
+        );
+    }
+    problems
+}
+
+/// Check if a node is declared at a line range of a file
+fn has_span(node: &Node) -> bool {
+    !node.file.is_empty()
+        && !node.is_file()
+        && !node.is_repository()
+        && !node.is_workspace()
+        && !node.is_component()
+}
+
+/// `file:line` of a node, or its ID if it has no file
+fn location(node: &Node) -> String {
+    if node.file.is_empty() {
+        node.id.clone()
+    } else {
+        format!("{}:{}", node.file, node.line)
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::graph::CallableKind;
+
+    fn function(id: &str, line: usize, end_line: usize) -> Node {
+        let (file, name) = id.split_once(':').unwrap();
+        Node::callable(
+            id.to_string(),
+            name.to_string(),
+            CallableKind::Function,
+            file.to_string(),
+            line,
+            end_line,
+        )
+    }
+
+    fn contents() -> IndexContents {
+        IndexContents {
+            schema_version: Some(GRAPH_SCHEMA_VERSION.to_string()),
+            nodes: vec![
+                Node::source_file(
+                    "main.go".to_string(),
+                    "main.go".to_string(),
+                    String::new(),
+                    20,
+                ),
+                function("main.go:main", 3, 10),
+                function("main.go:helper", 12, 18),
+            ],
+            edges: vec![
+                Edge::contains("main.go".to_string(), "main.go:main".to_string()),
+                Edge::contains("main.go".to_string(), "main.go:helper".to_string()),
+                Edge::uses(
+                    "main.go:main".to_string(),
+                    "main.go:helper".to_string(),
+                    Some(5),
+                    Some("helper".to_string()),
+                ),
+            ],
+        }
+    }
+
+    #[test]
+    fn test_valid_index() {
+        let report = validate(&contents());
+        assert!(report.problems.is_empty(), "{:?}", report.problems);
+        assert!(report.is_valid());
+        assert_eq!(report.nodes, 3);
+        assert_eq!(report.edges, 3);
+    }
+
+    #[test]
+    fn test_check_schema() {
+        assert!(check_schema(Some(GRAPH_SCHEMA_VERSION)).is_none());
+        assert_eq!(check_schema(None).unwrap().severity, Severity::Info);
+        assert_eq!(check_schema(Some("2.0")).unwrap().severity, Severity::Info);
+        assert_eq!(
+            check_schema(Some("2.999")).unwrap().severity,
+            Severity::Warning
+        );
+        assert_eq!(check_schema(Some("1.4")).unwrap().severity, Severity::Error);
+        assert_eq!(check_schema(Some("two")).unwrap().severity, Severity::Error);
+    }
+
+    #[test]
+    fn test_dangling_edges_and_duplicates() {
+        let mut contents = contents();
+        contents.edges.push(Edge::uses(
+            "main.go:main".to_string(),
+            "util.go:Gone".to_string(),
+            Some(7),
+            Some("Gone".to_string()),
+        ));
+        contents.nodes.push(function("main.go:helper", 12, 18));
+        let mut a = function("main.go:a", 1, 2);
+        let mut b = function("main.go:b", 1, 2);
+        a.metadata.symbol_id = Some("sym:go:1".to_string());
+        b.metadata.symbol_id = Some("sym:go:1".to_string());
+        contents.nodes.extend([a, b]);
+
+        let report = validate(&contents);
+        assert!(!report.is_valid());
+        let groups = report.by_check();
+
+        let dangling = &groups[&Check::DanglingEdge];
+        assert_eq!(dangling.len(), 1);
+        assert!(dangling[0].message.contains("missing target"));
+        assert!(dangling[0].message.contains("'Gone'"));
+        assert_eq!(dangling[0].file.as_deref(), Some("main.go"));
+        assert_eq!(dangling[0].line, Some(7));
+
+        let duplicates = &groups[&Check::DuplicateId];
+        assert_eq!(duplicates[0].nodes, ["main.go:helper"]);
+        assert!(duplicates[0]
+            .message
+            .contains("2 times (main.go:12, main.go:12)"));
+
+        let symbols = &groups[&Check::DuplicateSymbolId];
+        assert_eq!(symbols[0].nodes, ["main.go:a", "main.go:b"]);
+    }
+
+    #[test]
+    fn test_spans() {
+        let mut contents = contents();
+        // Inverted span
+        contents.nodes.push(function("main.go:broken", 9, 4));
+        // Outside its parent, and past the end of the file
+        contents.nodes.push(function("main.go:main:inner", 8, 25));
+        contents.edges.push(Edge::contains(
+            "main.go:main".to_string(),
+            "main.go:main:inner".to_string(),
+        ));
+        // Two parents
+        contents.edges.push(Edge::contains(
+            "main.go:helper".to_string(),
+            "main.go:main:inner".to_string(),
+        ));
+
+        let report = validate(&contents);
+        let spans = &report.by_check()[&Check::Span];
+        let messages: Vec<&str> = spans.iter().map(|p| p.message.as_str()).collect();
+        assert!(messages
+            .iter()
+            .any(|m| m.contains("main.go:broken has a malformed span (lines 9-4)")));
+        assert!(messages
+            .iter()
+            .any(|m| m.contains("ends at line 25, past the end of main.go (20 lines)")));
+        assert!(messages
+            .iter()
+            .any(|m| m.contains("lies outside its parent main.go:main (lines 3-10)")));
+        assert!(messages
+            .iter()
+            .any(|m| m.contains("is contained by 2 parents")));
+        assert_eq!(report.count(Severity::Error), 1);
+    }
+}
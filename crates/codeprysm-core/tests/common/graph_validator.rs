@@ -75,6 +75,12 @@ pub struct GraphStats {
     pub uses_edges: usize,
     pub defines_edges: usize,
     pub depends_on_edges: usize,
+    pub clone_of_edges: usize,
+    pub defined_in_edges: usize,
+    pub cross_language_edges: usize,
+    pub refers_by_name_edges: usize,
+    pub equivalent_to_edges: usize,
+    pub subset_of_edges: usize,
 }
 
 impl GraphStats {
@@ -172,6 +178,10 @@ pub fn validate_semantic(graph: &PetCodeGraph) -> Vec<String> {
         "property",
         "parameter",
         "local",
+        "marker",
+        "documentation",
+        "truncation",
+        "build_target",
     ]
     .iter()
     .cloned()
@@ -355,6 +365,12 @@ pub fn compute_stats(graph: &PetCodeGraph) -> GraphStats {
             EdgeType::Uses => stats.uses_edges += 1,
             EdgeType::Defines => stats.defines_edges += 1,
             EdgeType::DependsOn => stats.depends_on_edges += 1,
+            EdgeType::CloneOf => stats.clone_of_edges += 1,
+            EdgeType::DefinedIn => stats.defined_in_edges += 1,
+            EdgeType::CrossLanguage => stats.cross_language_edges += 1,
+            EdgeType::RefersByName => stats.refers_by_name_edges += 1,
+            EdgeType::EquivalentTo => stats.equivalent_to_edges += 1,
+            EdgeType::SubsetOf => stats.subset_of_edges += 1,
         }
     }
 
@@ -3,6 +3,7 @@
 //! This module implements the MCP server using the rmcp SDK, exposing:
 //! - Semantic search (search_graph_nodes with code/info/hybrid modes)
 //! - Graph navigation (find_references, find_outgoing_references, find_definitions, find_call_chain)
+//! - Whole-graph queries (find_symbol, get_implementers, get_subgraph)
 //! - Code viewing (get_node_info, read_code, find_module_structure)
 //! - Index management (sync_repository, get_index_status)
 
@@ -22,8 +23,14 @@ use rmcp::{
 use tokio::sync::{watch, RwLock};
 use tracing::{debug, info, warn};
 
+use codeprysm_core::analysis::{
+    ego_graph, fuzzy_search, EdgeSelector, EgoDirection, EgoOptions, FuzzyOptions, MethodSets,
+};
 use codeprysm_core::lazy::manager::LazyGraphManager;
-use codeprysm_core::{EdgeType, IncrementalUpdater, Node, PetCodeGraph};
+use codeprysm_core::{
+    export_graph, AttributeFilter, EdgeType, ExportFormat, IncrementalUpdater, Node, PetCodeGraph,
+    Provenance,
+};
 use codeprysm_search::{GraphIndexer, HybridSearcher, QdrantConfig};
 
 use crate::tools::*;
@@ -275,6 +282,18 @@ fn save_indexed_version(codeprysm_dir: &std::path::Path, version: &str) -> std::
     )
 }
 
+/// Record the provenance of an incremental update.
+///
+/// The updater builds with the configuration of the last full build, so its
+/// configuration hash is kept.
+fn refresh_provenance(repo_path: &std::path::Path, codeprysm_dir: &std::path::Path) {
+    let mut provenance = Provenance::collect(repo_path);
+    provenance.config_hash = Provenance::load(codeprysm_dir).and_then(|p| p.config_hash);
+    if let Err(e) = provenance.save(codeprysm_dir) {
+        warn!("Failed to save provenance: {}", e);
+    }
+}
+
 /// Shared state for the MCP server (contains only graph data)
 ///
 /// Note: IncrementalUpdater and HybridSearcher are intentionally kept outside
@@ -1234,6 +1253,221 @@ impl PrismServer {
         )]))
     }
 
+    /// Materialize the complete graph for whole-graph queries.
+    ///
+    /// The lazily loaded runtime graph only holds the partitions touched so
+    /// far, so analyses that scan every node or follow cross-partition edges
+    /// need a full copy.
+    async fn full_graph(&self) -> Result<PetCodeGraph, McpError> {
+        let state = acquire_state_read(&self.state).await?;
+        state
+            .lazy_graph
+            .load_full_graph()
+            .map_err(|e| McpError::internal_error(format!("Failed to load graph: {}", e), None))
+    }
+
+    #[tool(
+        name = "find_symbol",
+        description = "Find symbols by fuzzy name match, like an editor's symbol picker. Works without a search index. Ranked by match quality, then kind (types and functions first), then number of users. Use when you know roughly what a symbol is called."
+    )]
+    async fn find_symbol(
+        &self,
+        Parameters(params): Parameters<FindSymbolParams>,
+    ) -> Result<CallToolResult, McpError> {
+        let max_results = params.max_results.unwrap_or(20);
+        let type_filter = params.node_types.unwrap_or_default();
+        let attribute_filter = params
+            .attributes
+            .unwrap_or_default()
+            .iter()
+            .map(|filter| filter.parse::<AttributeFilter>())
+            .collect::<Result<Vec<_>, _>>()
+            .map_err(|e| McpError::invalid_params(e, None))?;
+
+        debug!("find_symbol: pattern='{}'", params.pattern);
+
+        let graph = self.full_graph().await?;
+        let options = FuzzyOptions {
+            // Filter before truncating so node_types and attributes do not
+            // starve the results
+            limit: if type_filter.is_empty() && attribute_filter.is_empty() {
+                max_results
+            } else {
+                usize::MAX
+            },
+            docs_root: None,
+        };
+
+        let mut matches = fuzzy_search(&graph, &params.pattern, &options);
+        if !type_filter.is_empty() {
+            matches.retain(|m| {
+                type_filter.iter().any(|t| {
+                    t.eq_ignore_ascii_case(m.node_type.as_str())
+                        || m.kind.as_deref().is_some_and(|k| t.eq_ignore_ascii_case(k))
+                })
+            });
+        }
+        if !attribute_filter.is_empty() {
+            matches.retain(|m| {
+                graph
+                    .get_node(&m.id)
+                    .is_some_and(|node| attribute_filter.iter().all(|f| f.matches(node)))
+            });
+        }
+        matches.truncate(max_results);
+
+        let response = serde_json::json!({
+            "pattern": params.pattern,
+            "match_count": matches.len(),
+            "matches": matches,
+        });
+
+        Ok(CallToolResult::success(vec![Content::text(
+            serde_json::to_string_pretty(&response).unwrap_or_default(),
+        )]))
+    }
+
+    #[tool(
+        name = "get_implementers",
+        description = "Find types implementing an interface (or, with direction 'interfaces', the interfaces a type implements), matched by method sets like Go's structural typing. Answer: 'What can be passed where this interface is expected?'"
+    )]
+    async fn get_implementers(
+        &self,
+        Parameters(params): Parameters<GetImplementersParams>,
+    ) -> Result<CallToolResult, McpError> {
+        let node_id = params.node_id;
+        let direction = params
+            .direction
+            .unwrap_or_else(|| "implementers".to_string());
+
+        debug!(
+            "get_implementers: node_id='{}', direction='{}'",
+            node_id, direction
+        );
+
+        let graph = self.full_graph().await?;
+        let sets = MethodSets::build(&graph);
+
+        let ids = match direction.as_str() {
+            "implementers" => {
+                if !sets.is_interface(&node_id) {
+                    return Err(McpError::invalid_params(
+                        format!("Not an interface: {}", node_id),
+                        None,
+                    ));
+                }
+                sets.implementers(&node_id)
+            }
+            "interfaces" => {
+                if !sets.is_type(&node_id) {
+                    return Err(McpError::invalid_params(
+                        format!("Not a type: {}", node_id),
+                        None,
+                    ));
+                }
+                sets.interfaces_of(&node_id)
+            }
+            other => {
+                return Err(McpError::invalid_params(
+                    format!(
+                        "Invalid direction '{}' (expected 'implementers' or 'interfaces')",
+                        other
+                    ),
+                    None,
+                ))
+            }
+        };
+
+        let results: Vec<serde_json::Value> = ids
+            .iter()
+            .filter_map(|id| graph.get_node(id))
+            .map(format_node_info)
+            .collect();
+
+        let response = serde_json::json!({
+            "node_id": node_id,
+            "direction": direction,
+            "count": results.len(),
+            "results": results,
+        });
+
+        Ok(CallToolResult::success(vec![Content::text(
+            serde_json::to_string_pretty(&response).unwrap_or_default(),
+        )]))
+    }
+
+    #[tool(
+        name = "get_subgraph",
+        description = "Get the neighborhood of a node: every node within a number of hops and the edges among them, as JSON, DOT, Mermaid, or GraphML. Use to see how a piece of code fits together in one call instead of many find_references calls."
+    )]
+    async fn get_subgraph(
+        &self,
+        Parameters(params): Parameters<GetSubgraphParams>,
+    ) -> Result<CallToolResult, McpError> {
+        let node_id = params.node_id;
+        let format: ExportFormat = params
+            .format
+            .as_deref()
+            .unwrap_or("json")
+            .parse()
+            .map_err(|e: String| McpError::invalid_params(e, None))?;
+        let direction = match params.direction.as_deref().unwrap_or("both") {
+            "outgoing" => EgoDirection::Outgoing,
+            "incoming" => EgoDirection::Incoming,
+            "both" => EgoDirection::Both,
+            other => {
+                return Err(McpError::invalid_params(
+                    format!(
+                        "Invalid direction '{}' (expected 'outgoing', 'incoming', or 'both')",
+                        other
+                    ),
+                    None,
+                ))
+            }
+        };
+        let edge_types = params
+            .edge_types
+            .map(|types| {
+                types
+                    .iter()
+                    .map(|t| t.parse::<EdgeSelector>())
+                    .collect::<Result<Vec<_>, _>>()
+            })
+            .transpose()
+            .map_err(|e| McpError::invalid_params(e, None))?;
+
+        let options = EgoOptions {
+            radius: params.radius.unwrap_or(2),
+            edge_types,
+            direction,
+            max_nodes: Some(params.max_nodes.unwrap_or(50)),
+        };
+
+        debug!(
+            "get_subgraph: node_id='{}', radius={}",
+            node_id, options.radius
+        );
+
+        let graph = self.full_graph().await?;
+        if graph.get_node(&node_id).is_none() {
+            return Err(McpError::invalid_params(
+                format!("Node not found: {}", node_id),
+                None,
+            ));
+        }
+
+        let subgraph = ego_graph(&graph, &node_id, &options);
+        let provenance = {
+            let state = acquire_state_read(&self.state).await?;
+            Provenance::load(&state.codeprysm_dir)
+        };
+        Ok(CallToolResult::success(vec![Content::text(export_graph(
+            &subgraph,
+            format,
+            provenance.as_ref(),
+        ))]))
+    }
+
     #[tool(
         name = "sync_repository",
         description = "Trigger re-indexing after code changes. Call when search returns outdated results or after editing files. Runs in background - use get_index_status to check completion."
@@ -1393,6 +1627,9 @@ impl rmcp::ServerHandler for PrismServer {
                 - find_definitions: What does this contain? (methods, fields)\n\
                 - find_call_chain: Trace execution paths (upstream/downstream)\n\
                 - find_module_structure: Explore directory organization\n\
+                - find_symbol: Fuzzy name lookup (e.g., 'hreq' for HandleRequest), no search index needed\n\
+                - get_implementers: Types implementing an interface, or interfaces of a type\n\
+                - get_subgraph: Neighborhood of a node within N hops, as JSON/DOT/Mermaid\n\
                 - sync_repository / get_index_status: Keep index current\n\n\
                 NODE IDs: Format is 'file_path:entity_name' (e.g., 'src/main.rs:main', 'app/user.py:User').\n\
                 NODE TYPES: Container (files, classes, modules), Callable (functions, methods), Data (fields, variables).\n\n\
@@ -1426,6 +1663,15 @@ fn format_node_info(node: &Node) -> serde_json::Value {
     if let Some(ref subtype) = node.subtype {
         info["subtype"] = serde_json::json!(subtype);
     }
+    if let Some(ref summary) = node.metadata.summary {
+        info["summary"] = serde_json::json!(summary);
+    }
+    if let Some(ref entry_point) = node.metadata.entry_point {
+        info["entry_point"] = serde_json::json!(entry_point);
+    }
+    if let Some(ref control_flow) = node.metadata.control_flow {
+        info["control_flow"] = serde_json::json!(control_flow);
+    }
 
     info
 }
@@ -1596,6 +1842,7 @@ async fn auto_sync_task(
                         if let Err(e) = state_guard.lazy_graph.reload_cross_refs() {
                             warn!("Auto-sync: failed to reload cross-refs: {}", e);
                         }
+                        refresh_provenance(&state_guard.repo_path, &state_guard.codeprysm_dir);
 
                         let stats = state_guard.lazy_graph.stats();
                         info!(
@@ -1668,6 +1915,7 @@ async fn background_sync_task(
             if let Err(e) = state_guard.lazy_graph.reload_cross_refs() {
                 warn!("Failed to reload cross-refs: {}", e);
             }
+            refresh_provenance(&state_guard.repo_path, &state_guard.codeprysm_dir);
         }
 
         // Clone data needed for indexing
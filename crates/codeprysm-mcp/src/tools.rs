@@ -177,6 +177,76 @@ pub struct FindModuleStructureParams {
     pub include_empty: Option<bool>,
 }
 
+/// Parameters for find_symbol tool
+#[derive(Debug, Clone, Serialize, Deserialize, JsonSchema)]
+pub struct FindSymbolParams {
+    /// Fuzzy name pattern
+    #[schemars(
+        description = "Symbol name or abbreviation. Characters must appear in order; word and camel-hump starts rank highest (e.g., \"hreq\" or \"HR\" finds HandleRequest)"
+    )]
+    pub pattern: String,
+
+    /// Filter by node types
+    #[schemars(
+        description = "Filter by node type or kind (e.g., [\"Callable\"], [\"type\"], [\"method\"])"
+    )]
+    pub node_types: Option<Vec<String>>,
+
+    /// Attribute filters
+    #[schemars(
+        description = "Only symbols with all these user-defined attributes, as \"key=value\" or \"key\" (e.g., [\"layer=domain\", \"team=payments\"])"
+    )]
+    pub attributes: Option<Vec<String>>,
+
+    /// Maximum results
+    #[schemars(description = "Maximum results to return (default 20)")]
+    pub max_results: Option<usize>,
+}
+
+/// Parameters for get_implementers tool
+#[derive(Debug, Clone, Serialize, Deserialize, JsonSchema)]
+pub struct GetImplementersParams {
+    /// Interface or type node ID
+    #[schemars(description = "The interface (or, with direction 'interfaces', the type) node ID")]
+    pub node_id: String,
+
+    /// Lookup direction
+    #[schemars(
+        description = "'implementers' (default) for types implementing the interface, 'interfaces' for interfaces the type implements"
+    )]
+    pub direction: Option<String>,
+}
+
+/// Parameters for get_subgraph tool
+#[derive(Debug, Clone, Serialize, Deserialize, JsonSchema)]
+pub struct GetSubgraphParams {
+    /// Center node ID
+    #[schemars(description = "The node at the center of the neighborhood")]
+    pub node_id: String,
+
+    /// Maximum hops from the center
+    #[schemars(description = "Maximum number of hops from the center (default 2)")]
+    pub radius: Option<usize>,
+
+    /// Edge types to follow
+    #[schemars(
+        description = "Edge types to follow (e.g., [\"USES\"], or [\"CALLS\", \"IMPLEMENTS\"]). If not specified, follows all edge types"
+    )]
+    pub edge_types: Option<Vec<String>>,
+
+    /// Direction to follow edges in
+    #[schemars(description = "'outgoing', 'incoming', or 'both' (default)")]
+    pub direction: Option<String>,
+
+    /// Maximum nodes
+    #[schemars(description = "Maximum nodes to include, closest first (default 50)")]
+    pub max_nodes: Option<usize>,
+
+    /// Output format
+    #[schemars(description = "'json' (default), 'dot', 'mermaid', or 'graphml'")]
+    pub format: Option<String>,
+}
+
 /// Parameters for sync_repository tool (no params needed)
 #[derive(Debug, Clone, Serialize, Deserialize, JsonSchema)]
 pub struct SyncRepositoryParams {}
@@ -17,7 +17,9 @@ mod common;
 
 use std::path::PathBuf;
 
+use codeprysm_core::analysis::{ego_graph, fuzzy_search, EgoOptions, FuzzyOptions, MethodSets};
 use codeprysm_core::lazy::manager::LazyGraphManager;
+use codeprysm_core::{export_graph, ExportFormat};
 use codeprysm_mcp::ServerConfig;
 use codeprysm_search::QdrantConfig;
 
@@ -376,6 +378,77 @@ fn test_all_fixture_languages() {
     }
 }
 
+// ============================================================================
+// Whole-Graph Tool Tests (find_symbol, get_implementers, get_subgraph)
+// ============================================================================
+
+#[test]
+fn test_full_graph_find_symbol() {
+    let (_temp_dir, _repo_path, prism_dir) = common::setup_test_environment("go");
+    let manager = LazyGraphManager::open(&prism_dir).expect("Failed to open lazy graph manager");
+    let graph = manager
+        .load_full_graph()
+        .expect("Failed to load full graph");
+
+    let matches = fuzzy_search(&graph, "NSC", &FuzzyOptions::default());
+    assert!(!matches.is_empty(), "Should match NewSimpleCalculator");
+    assert_eq!(matches[0].name, "NewSimpleCalculator");
+}
+
+#[test]
+fn test_full_graph_implementers() {
+    let (_temp_dir, _repo_path, prism_dir) = common::setup_test_environment("go");
+    let manager = LazyGraphManager::open(&prism_dir).expect("Failed to open lazy graph manager");
+    let graph = manager
+        .load_full_graph()
+        .expect("Failed to load full graph");
+
+    let calculator = graph
+        .iter_nodes()
+        .find(|n| n.name == "Calculator")
+        .expect("Should have Calculator interface");
+    let implementers = MethodSets::build(&graph).implementers(&calculator.id);
+    assert!(
+        implementers
+            .iter()
+            .any(|id| id.ends_with(":SimpleCalculator")),
+        "SimpleCalculator should implement Calculator: {:?}",
+        implementers
+    );
+}
+
+#[test]
+fn test_full_graph_subgraph() {
+    let (_temp_dir, _repo_path, prism_dir) = common::setup_test_environment("go");
+    let manager = LazyGraphManager::open(&prism_dir).expect("Failed to open lazy graph manager");
+    let graph = manager
+        .load_full_graph()
+        .expect("Failed to load full graph");
+
+    let center = graph
+        .iter_nodes()
+        .find(|n| n.name == "SimpleCalculator")
+        .expect("Should have SimpleCalculator type");
+    let options = EgoOptions {
+        radius: 1,
+        max_nodes: Some(50),
+        ..Default::default()
+    };
+    let subgraph = ego_graph(&graph, &center.id, &options);
+    assert!(
+        subgraph.node_count() > 1,
+        "Should include the type's methods"
+    );
+    assert!(subgraph.node_count() <= 50);
+
+    let json: serde_json::Value =
+        serde_json::from_str(&export_graph(&subgraph, ExportFormat::Json, None)).unwrap();
+    assert_eq!(
+        json["nodes"].as_array().unwrap().len(),
+        subgraph.node_count()
+    );
+}
+
 // ============================================================================
 // Summary Test
 // ============================================================================
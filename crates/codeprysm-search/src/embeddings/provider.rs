@@ -36,7 +36,7 @@ impl std::fmt::Display for EmbeddingProviderType {
 /// Status of an embedding provider
 ///
 /// Contains health and capability information for diagnostics.
-#[derive(Debug, Clone)]
+#[derive(Debug, Clone, Serialize, Deserialize)]
 pub struct ProviderStatus {
     /// Whether the provider is available and responding
     pub available: bool,
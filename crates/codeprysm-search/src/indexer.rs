@@ -28,6 +28,7 @@
 use std::path::Path;
 use std::sync::Arc;
 
+use codeprysm_core::analysis::doc_summary;
 use codeprysm_core::{Node, PetCodeGraph};
 use tracing::{debug, info};
 
@@ -310,8 +311,8 @@ impl GraphIndexer {
             }
 
             // Read source code for the node
-            let content = match self.read_node_content(node) {
-                Ok(c) => c,
+            let (content, doc) = match self.read_node_source(node) {
+                Ok(source) => source,
                 Err(e) => {
                     debug!("Failed to read content for {}: {}", node.id, e);
                     stats.total_failed += 1;
@@ -326,7 +327,7 @@ impl GraphIndexer {
             }
 
             // Create rich semantic text using graph traversal for context
-            let semantic_text = semantic_builder.build(node, &content);
+            let semantic_text = semantic_builder.build_with_doc(node, &content, doc.as_deref());
 
             // Create payload
             let payload = EntityPayload {
@@ -494,8 +495,8 @@ impl GraphIndexer {
             }
 
             // Read source code for the node
-            let content = match self.read_node_content(node) {
-                Ok(c) => c,
+            let (content, doc) = match self.read_node_source(node) {
+                Ok(source) => source,
                 Err(e) => {
                     debug!("Failed to read content for {}: {}", node.id, e);
                     stats.total_failed += 1;
@@ -510,7 +511,7 @@ impl GraphIndexer {
             }
 
             // Create rich semantic text
-            let semantic_text = semantic_builder.build(node, &content);
+            let semantic_text = semantic_builder.build_with_doc(node, &content, doc.as_deref());
 
             // Create payload
             let payload = EntityPayload {
@@ -624,8 +625,8 @@ impl GraphIndexer {
         Ok(())
     }
 
-    /// Read source code content for a node
-    fn read_node_content(&self, node: &Node) -> std::io::Result<String> {
+    /// Read source code content and doc comment summary for a node
+    fn read_node_source(&self, node: &Node) -> std::io::Result<(String, Option<String>)> {
         let file_path = self.repo_path.join(&node.file);
         let content = std::fs::read_to_string(&file_path)?;
 
@@ -634,11 +635,11 @@ impl GraphIndexer {
         let end = node.end_line.min(lines.len());
 
         if start >= lines.len() {
-            return Ok(String::new());
+            return Ok((String::new(), None));
         }
 
         let selected: Vec<&str> = lines[start..end].to_vec();
-        Ok(selected.join("\n"))
+        Ok((selected.join("\n"), doc_summary(&lines, node.line)))
     }
 
     /// Check if collections exist and have data for this repo
@@ -747,8 +748,8 @@ impl GraphIndexer {
             }
 
             // Read source code for the node
-            let content = match self.read_node_content(node) {
-                Ok(c) => c,
+            let (content, doc) = match self.read_node_source(node) {
+                Ok(source) => source,
                 Err(e) => {
                     debug!("Failed to read content for {}: {}", node.id, e);
                     stats.total_failed += 1;
@@ -763,7 +764,7 @@ impl GraphIndexer {
             }
 
             // Create rich semantic text using graph traversal for context
-            let semantic_text = semantic_builder.build(node, &content);
+            let semantic_text = semantic_builder.build_with_doc(node, &content, doc.as_deref());
 
             // Create payload
             let payload = EntityPayload {
@@ -58,6 +58,14 @@ impl<'a> SemanticTextBuilder<'a> {
     /// 8. Semantic keywords
     /// 9. Code preview
     pub fn build(&self, node: &Node, content: &str) -> String {
+        self.build_with_doc(node, content, None)
+    }
+
+    /// Build semantic text for a node, including its doc comment summary.
+    ///
+    /// The doc summary follows the parameters, since it usually states the
+    /// intent in the same words as natural language queries.
+    pub fn build_with_doc(&self, node: &Node, content: &str, doc: Option<&str>) -> String {
         let mut parts = Vec::new();
 
         // 1. Build entity description with modifiers
@@ -77,6 +85,14 @@ impl<'a> SemanticTextBuilder<'a> {
             }
         }
 
+        // 3b. Add doc comment summary, then the model-written summary
+        if let Some(doc) = doc.filter(|d| !d.trim().is_empty()) {
+            parts.push(doc.trim().to_string());
+        }
+        if let Some(ref summary) = node.metadata.summary {
+            parts.push(summary.clone());
+        }
+
         // 4. Add children context for containers
         if node.node_type == NodeType::Container && !node.is_file() {
             if let Some(children_ctx) = self.build_children_context(node) {
@@ -790,6 +806,37 @@ mod tests {
         assert!(result.contains("in file"), "Should have 'in file' prefix");
     }
 
+    #[test]
+    fn test_build_with_doc_summary() {
+        let graph = create_test_graph();
+        let builder = SemanticTextBuilder::new(&graph);
+        let node = graph.get_node("test.py:MyClass:process").unwrap();
+
+        let content = "async def process(self, data):";
+        let result = builder.build_with_doc(node, content, Some("Retry failed HTTP requests."));
+        assert!(result.contains("Retry failed HTTP requests."));
+
+        assert_eq!(
+            builder.build_with_doc(node, content, None),
+            builder.build(node, content)
+        );
+    }
+
+    #[test]
+    fn test_build_with_model_summary() {
+        let mut graph = create_test_graph();
+        graph
+            .get_node_mut("test.py:MyClass:process")
+            .unwrap()
+            .metadata
+            .summary = Some("Validates and stores a batch of records.".to_string());
+        let builder = SemanticTextBuilder::new(&graph);
+        let node = graph.get_node("test.py:MyClass:process").unwrap();
+
+        let result = builder.build(node, "async def process(self, data):");
+        assert!(result.contains("Validates and stores a batch of records."));
+    }
+
     // ========================================================================
     // UTF-8 Truncation Tests
     // ========================================================================
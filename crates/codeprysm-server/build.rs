@@ -0,0 +1,6 @@
+fn main() -> Result<(), Box<dyn std::error::Error>> {
+    // Use a bundled protoc so builds do not need one installed
+    std::env::set_var("PROTOC", protoc_bin_vendored::protoc_bin_path()?);
+    tonic_build::configure().compile_protos(&["proto/codeprysm/v1/graph.proto"], &["proto"])?;
+    Ok(())
+}
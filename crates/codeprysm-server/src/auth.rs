@@ -0,0 +1,307 @@
+//! Authentication and Authorization
+//!
+//! Servers configured with API tokens require one on every request, as
+//! `Authorization: Bearer <token>` (HTTP) or `authorization` metadata (gRPC).
+//! Each token is a [`Principal`] with a scope and the paths it may see:
+//!
+//! - **read**: query the graph, limited to the token's paths
+//! - **admin**: also read `/metrics`
+//!
+//! A token limited to some paths is served a view of the graph with only the
+//! nodes in matching files (plus the workspace and repository nodes) and the
+//! edges between them, so hidden code cannot be reached through lookups,
+//! edges, subgraphs, or GraphQL. Without tokens, every request is served the
+//! whole graph, as before.
+
+use std::sync::Arc;
+
+use codeprysm_core::PetCodeGraph;
+use globset::{Glob, GlobSet, GlobSetBuilder};
+
+use crate::error::{Result, ServerError};
+
+/// What a token may do.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, PartialOrd, Ord, Default)]
+pub enum Scope {
+    /// Query the graph
+    #[default]
+    Read,
+    /// Query the graph and read server metrics
+    Admin,
+}
+
+impl std::str::FromStr for Scope {
+    type Err = String;
+
+    fn from_str(s: &str) -> std::result::Result<Self, Self::Err> {
+        match s.to_lowercase().as_str() {
+            "read" | "read-only" | "readonly" => Ok(Self::Read),
+            "admin" => Ok(Self::Admin),
+            _ => Err(format!("Unknown scope '{}': expected read or admin", s)),
+        }
+    }
+}
+
+/// A token accepted by the servers.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct ApiToken {
+    /// Name of the token, recorded in logs
+    pub name: String,
+    /// Secret the client presents
+    pub secret: String,
+    /// What the token may do
+    pub scope: Scope,
+    /// Glob patterns of the files the token may see (all if empty)
+    pub paths: Vec<String>,
+}
+
+/// An authenticated caller.
+#[derive(Debug, Clone)]
+pub struct Principal {
+    /// Name of the token (`anonymous` when authentication is off)
+    pub name: String,
+    /// What the caller may do
+    pub scope: Scope,
+    /// Files the caller may see (None for all)
+    visible: Option<GlobSet>,
+}
+
+impl Principal {
+    /// A caller allowed everything, used when authentication is off
+    pub fn anonymous() -> Self {
+        Self {
+            name: "anonymous".to_string(),
+            scope: Scope::Admin,
+            visible: None,
+        }
+    }
+
+    /// Check whether the caller sees the whole graph
+    pub fn sees_everything(&self) -> bool {
+        self.visible.is_none()
+    }
+
+    /// Check whether the caller may see the nodes of a file; nodes without a
+    /// file (workspace, repository) are always visible
+    pub fn can_see(&self, file: &str) -> bool {
+        file.is_empty()
+            || self
+                .visible
+                .as_ref()
+                .is_none_or(|visible| visible.is_match(file.trim_start_matches("./")))
+    }
+
+    /// Fail unless the caller has at least `scope`
+    pub fn require(&self, scope: Scope) -> Result<()> {
+        if self.scope >= scope {
+            Ok(())
+        } else {
+            Err(ServerError::Forbidden(format!(
+                "token '{}' lacks the admin scope",
+                self.name
+            )))
+        }
+    }
+
+    /// The part of `graph` the caller may see.
+    pub fn view(&self, graph: &PetCodeGraph) -> PetCodeGraph {
+        let mut view = PetCodeGraph::new();
+        for node in graph.iter_nodes().filter(|n| self.can_see(&n.file)) {
+            view.add_node(node.clone());
+        }
+        for edge in graph.iter_edges() {
+            if view.contains_node(&edge.source) && view.contains_node(&edge.target) {
+                view.add_edge_from_struct(&edge);
+            }
+        }
+        view
+    }
+}
+
+/// The tokens a server accepts.
+#[derive(Debug, Clone, Default)]
+pub struct Auth {
+    tokens: Vec<(String, Arc<Principal>)>,
+}
+
+impl Auth {
+    /// Accept every request (no tokens).
+    pub fn disabled() -> Self {
+        Self::default()
+    }
+
+    /// Require one of `tokens` on every request.
+    pub fn new(tokens: Vec<ApiToken>) -> Result<Self> {
+        let mut names = std::collections::HashSet::new();
+        let tokens = tokens
+            .into_iter()
+            .map(|token| {
+                if !names.insert(token.name.clone()) {
+                    return Err(ServerError::InvalidParams(format!(
+                        "Token name '{}' is used more than once",
+                        token.name
+                    )));
+                }
+                if token.secret.is_empty() {
+                    return Err(ServerError::InvalidParams(format!(
+                        "Token '{}' has an empty secret",
+                        token.name
+                    )));
+                }
+                let visible = compile(&token.name, &token.paths)?;
+                let principal = Principal {
+                    name: token.name,
+                    scope: token.scope,
+                    visible,
+                };
+                Ok((token.secret, Arc::new(principal)))
+            })
+            .collect::<Result<Vec<_>>>()?;
+        Ok(Self { tokens })
+    }
+
+    /// Check whether requests need a token
+    pub fn is_enabled(&self) -> bool {
+        !self.tokens.is_empty()
+    }
+
+    /// Identify the caller presenting `authorization` (the header or
+    /// metadata value, `Bearer <token>`).
+    pub fn authenticate(&self, authorization: Option<&str>) -> Result<Arc<Principal>> {
+        if !self.is_enabled() {
+            return Ok(Arc::new(Principal::anonymous()));
+        }
+        let presented = authorization
+            .and_then(|value| {
+                value
+                    .strip_prefix("Bearer ")
+                    .or_else(|| value.strip_prefix("bearer "))
+            })
+            .map(str::trim)
+            .ok_or(ServerError::Unauthenticated)?;
+        // Compare against every token so the time taken does not tell which
+        // token came close
+        let mut matched = None;
+        for (secret, principal) in &self.tokens {
+            if constant_time_eq(secret.as_bytes(), presented.as_bytes()) {
+                matched = Some(principal);
+            }
+        }
+        matched.cloned().ok_or(ServerError::Unauthenticated)
+    }
+}
+
+/// Compile a token's path patterns, or None when it may see every path
+fn compile(name: &str, patterns: &[String]) -> Result<Option<GlobSet>> {
+    if patterns.is_empty() {
+        return Ok(None);
+    }
+    let mut builder = GlobSetBuilder::new();
+    for pattern in patterns {
+        let glob = Glob::new(pattern).map_err(|e| {
+            ServerError::InvalidParams(format!(
+                "Token '{}': invalid path pattern '{}': {}",
+                name, pattern, e
+            ))
+        })?;
+        builder.add(glob);
+    }
+    builder
+        .build()
+        .map(Some)
+        .map_err(|e| ServerError::InvalidParams(e.to_string()))
+}
+
+/// Compare two byte strings in time independent of where they differ
+fn constant_time_eq(a: &[u8], b: &[u8]) -> bool {
+    if a.len() != b.len() {
+        return false;
+    }
+    a.iter().zip(b).fold(0u8, |acc, (x, y)| acc | (x ^ y)) == 0
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use codeprysm_core::{CallableKind, EdgeData, Node};
+
+    fn token(name: &str, secret: &str, scope: Scope, paths: &[&str]) -> ApiToken {
+        ApiToken {
+            name: name.to_string(),
+            secret: secret.to_string(),
+            scope,
+            paths: paths.iter().map(|p| p.to_string()).collect(),
+        }
+    }
+
+    #[test]
+    fn test_authenticate() {
+        assert!(Auth::disabled()
+            .authenticate(None)
+            .unwrap()
+            .sees_everything());
+
+        let auth = Auth::new(vec![
+            token("ci", "s3cret", Scope::Read, &["api/**"]),
+            token("ops", "0ps", Scope::Admin, &[]),
+        ])
+        .unwrap();
+        let ci = auth.authenticate(Some("Bearer s3cret")).unwrap();
+        assert_eq!(ci.name, "ci");
+        assert!(ci.can_see("api/server.go"));
+        assert!(!ci.can_see("cmd/main.go"));
+        assert!(ci.can_see(""));
+        assert!(matches!(
+            ci.require(Scope::Admin),
+            Err(ServerError::Forbidden(_))
+        ));
+        assert!(auth
+            .authenticate(Some("Bearer 0ps"))
+            .unwrap()
+            .require(Scope::Admin)
+            .is_ok());
+
+        for header in [None, Some("s3cret"), Some("Bearer wrong")] {
+            assert!(matches!(
+                auth.authenticate(header),
+                Err(ServerError::Unauthenticated)
+            ));
+        }
+        assert!(Auth::new(vec![token("bad", "x", Scope::Read, &["a/{"])]).is_err());
+        assert!(Auth::new(vec![
+            token("ci", "a", Scope::Read, &[]),
+            token("ci", "b", Scope::Read, &[])
+        ])
+        .is_err());
+        assert!("admin".parse::<Scope>().is_ok());
+    }
+
+    #[test]
+    fn test_view_hides_other_paths() {
+        let mut graph = PetCodeGraph::new();
+        for (id, file) in [
+            ("api/server.go:Handle", "api/server.go"),
+            ("cmd/main.go:main", "cmd/main.go"),
+        ] {
+            graph.add_node(Node::callable(
+                id.to_string(),
+                id.rsplit(':').next().unwrap().to_string(),
+                CallableKind::Function,
+                file.to_string(),
+                1,
+                3,
+            ));
+        }
+        graph.add_edge(
+            "cmd/main.go:main",
+            "api/server.go:Handle",
+            EdgeData::uses(Some(2), None),
+        );
+
+        let auth = Auth::new(vec![token("ci", "s", Scope::Read, &["api/**"])]).unwrap();
+        let view = auth.authenticate(Some("Bearer s")).unwrap().view(&graph);
+        assert!(view.contains_node("api/server.go:Handle"));
+        assert!(!view.contains_node("cmd/main.go:main"));
+        assert_eq!(view.edge_count(), 0);
+    }
+}
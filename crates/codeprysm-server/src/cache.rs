@@ -0,0 +1,362 @@
+//! Query Cache
+//!
+//! Keeps the responses of traversal queries (callers, call hierarchies,
+//! implementations, subgraphs, package dependencies), so dashboards polling
+//! the same hot queries do not re-traverse the graph on every request.
+//!
+//! Each entry records the packages its response was computed from. When the
+//! store reloads the graph after an index update, the cache compares a digest
+//! of every package with the previous graph and evicts only the entries
+//! touching a changed package; the others stay valid. An edge counts toward
+//! the packages of both its endpoints, so a new call into a package from
+//! elsewhere changes that package too. Entries computed over the whole graph
+//! (e.g., the package list) are evicted on any change.
+
+use std::collections::hash_map::DefaultHasher;
+use std::collections::{BTreeSet, HashMap};
+use std::hash::{Hash, Hasher};
+use std::num::NonZeroUsize;
+use std::sync::{Arc, Mutex, PoisonError};
+
+use codeprysm_core::analysis::package_of;
+use codeprysm_core::{Node, PetCodeGraph};
+use lru::LruCache;
+
+/// Responses kept by default
+pub const DEFAULT_CACHE_ENTRIES: usize = 1024;
+
+/// What a cached response was computed from.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub enum CacheScope {
+    /// Nodes and edges of these packages
+    Packages(BTreeSet<String>),
+    /// The whole graph
+    Graph,
+}
+
+impl CacheScope {
+    /// The packages of the files of `nodes`
+    pub fn of_nodes<'a>(nodes: impl IntoIterator<Item = &'a Node>) -> Self {
+        Self::Packages(
+            nodes
+                .into_iter()
+                .map(|node| package_of(&node.file).to_string())
+                .collect(),
+        )
+    }
+
+    /// Whether a change to `changed` invalidates the response
+    fn touches(&self, changed: &BTreeSet<String>) -> bool {
+        match self {
+            Self::Packages(packages) => !packages.is_disjoint(changed),
+            Self::Graph => !changed.is_empty(),
+        }
+    }
+}
+
+/// A cached response body.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct CachedResponse {
+    /// Value of the `Content-Type` header
+    pub content_type: &'static str,
+    /// Response body
+    pub body: String,
+}
+
+impl CachedResponse {
+    /// A JSON response
+    pub fn json(value: &impl serde::Serialize) -> Self {
+        Self {
+            content_type: "application/json",
+            body: serde_json::to_string(value).expect("query results serialize to JSON"),
+        }
+    }
+}
+
+/// A cached response with the packages it depends on
+struct Entry {
+    response: Arc<CachedResponse>,
+    scope: CacheScope,
+}
+
+struct CacheState {
+    /// None if caching is disabled
+    entries: Option<LruCache<String, Entry>>,
+    /// Digest of every package of the current graph (None before the first
+    /// load)
+    digests: Option<HashMap<String, u64>>,
+    /// Incremented on every graph reload
+    generation: u64,
+}
+
+/// Least-recently-used cache of query responses, invalidated by package.
+pub struct QueryCache {
+    state: Mutex<CacheState>,
+}
+
+impl QueryCache {
+    /// Create a cache holding up to `capacity` responses (0 disables it).
+    pub fn new(capacity: usize) -> Self {
+        Self {
+            state: Mutex::new(CacheState {
+                entries: NonZeroUsize::new(capacity).map(LruCache::new),
+                digests: None,
+                generation: 0,
+            }),
+        }
+    }
+
+    /// Whether responses are cached at all
+    pub fn is_enabled(&self) -> bool {
+        self.lock().entries.is_some()
+    }
+
+    /// Number of cached responses
+    pub fn len(&self) -> usize {
+        self.lock().entries.as_ref().map_or(0, LruCache::len)
+    }
+
+    /// Whether no responses are cached
+    pub fn is_empty(&self) -> bool {
+        self.len() == 0
+    }
+
+    /// Generation of the graph responses are cached for.
+    ///
+    /// Read it before getting the graph a response is computed from, and pass
+    /// it to [`QueryCache::insert`].
+    pub fn generation(&self) -> u64 {
+        self.lock().generation
+    }
+
+    /// Get a cached response, marking it recently used.
+    pub fn get(&self, key: &str) -> Option<Arc<CachedResponse>> {
+        let mut state = self.lock();
+        let entry = state.entries.as_mut()?.get(key)?;
+        Some(Arc::clone(&entry.response))
+    }
+
+    /// Cache a response computed from the graph of `generation`.
+    ///
+    /// Responses computed from a graph that was reloaded since are dropped,
+    /// as they may predate the invalidation of their packages.
+    pub fn insert(
+        &self,
+        key: String,
+        response: Arc<CachedResponse>,
+        scope: CacheScope,
+        generation: u64,
+    ) {
+        let mut state = self.lock();
+        if state.generation != generation {
+            return;
+        }
+        if let Some(entries) = state.entries.as_mut() {
+            entries.put(key, Entry { response, scope });
+        }
+    }
+
+    /// Switch to a newly loaded graph, evicting the responses that depend on
+    /// packages it changed. Returns the number of evicted responses.
+    pub fn update_graph(&self, graph: &PetCodeGraph) -> usize {
+        let digests = package_digests(graph);
+
+        let mut state = self.lock();
+        // Responses computed from the first graph loaded stay valid
+        let Some(previous) = state.digests.as_ref() else {
+            state.digests = Some(digests);
+            return 0;
+        };
+        let changed = changed_packages(previous, &digests);
+        state.digests = Some(digests);
+        state.generation += 1;
+        let Some(entries) = state.entries.as_mut() else {
+            return 0;
+        };
+        let stale: Vec<String> = entries
+            .iter()
+            .filter(|(_, entry)| entry.scope.touches(&changed))
+            .map(|(key, _)| key.clone())
+            .collect();
+        for key in &stale {
+            entries.pop(key);
+        }
+        stale.len()
+    }
+
+    fn lock(&self) -> std::sync::MutexGuard<'_, CacheState> {
+        self.state.lock().unwrap_or_else(PoisonError::into_inner)
+    }
+}
+
+impl Default for QueryCache {
+    fn default() -> Self {
+        Self::new(DEFAULT_CACHE_ENTRIES)
+    }
+}
+
+/// Order-independent digest of the nodes and edges of every package
+fn package_digests(graph: &PetCodeGraph) -> HashMap<String, u64> {
+    let mut digests: HashMap<String, u64> = HashMap::new();
+    let mut packages: HashMap<&str, &str> = HashMap::new();
+    for node in graph.iter_nodes() {
+        let package = package_of(&node.file);
+        packages.insert(&node.id, package);
+        let digest = digests.entry(package.to_string()).or_default();
+        *digest = digest.wrapping_add(hash(node));
+    }
+    for edge in graph.iter_edges() {
+        let edge_hash = hash(&edge);
+        let endpoints: BTreeSet<&str> = [&edge.source, &edge.target]
+            .into_iter()
+            .filter_map(|id| packages.get(id.as_str()).copied())
+            .collect();
+        for package in endpoints {
+            let digest = digests.entry(package.to_string()).or_default();
+            *digest = digest.wrapping_add(edge_hash);
+        }
+    }
+    digests
+}
+
+/// Hash of the serialized form of `value`
+fn hash(value: &impl serde::Serialize) -> u64 {
+    let mut hasher = DefaultHasher::new();
+    serde_json::to_vec(value)
+        .unwrap_or_default()
+        .hash(&mut hasher);
+    hasher.finish()
+}
+
+/// Packages added, removed, or with a different digest
+fn changed_packages(
+    previous: &HashMap<String, u64>,
+    current: &HashMap<String, u64>,
+) -> BTreeSet<String> {
+    let mut changed: BTreeSet<String> = previous
+        .iter()
+        .filter(|(package, digest)| current.get(*package) != Some(digest))
+        .map(|(package, _)| package.clone())
+        .collect();
+    changed.extend(
+        current
+            .keys()
+            .filter(|package| !previous.contains_key(*package))
+            .cloned(),
+    );
+    changed
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use codeprysm_core::{CallableKind, EdgeData};
+
+    fn function(id: &str) -> Node {
+        let (file, name) = id.split_once(':').unwrap();
+        Node::callable(
+            id.to_string(),
+            name.to_string(),
+            CallableKind::Function,
+            file.to_string(),
+            1,
+            5,
+        )
+    }
+
+    fn sample_graph() -> PetCodeGraph {
+        let mut graph = PetCodeGraph::new();
+        for id in [
+            "api/server.go:Handle",
+            "cmd/main.go:main",
+            "db/store.go:Get",
+        ] {
+            graph.add_node(function(id));
+        }
+        graph.add_edge(
+            "cmd/main.go:main",
+            "api/server.go:Handle",
+            EdgeData::uses(Some(3), Some("Handle".to_string())),
+        );
+        graph
+    }
+
+    fn response(body: &str) -> Arc<CachedResponse> {
+        Arc::new(CachedResponse::json(&body))
+    }
+
+    fn packages(packages: &[&str]) -> CacheScope {
+        CacheScope::Packages(packages.iter().map(|p| p.to_string()).collect())
+    }
+
+    #[test]
+    fn test_evicts_changed_packages() {
+        let cache = QueryCache::new(16);
+        let mut graph = sample_graph();
+        assert_eq!(cache.update_graph(&graph), 0);
+
+        let generation = cache.generation();
+        cache.insert(
+            "callers".into(),
+            response("a"),
+            packages(&["api", "cmd"]),
+            generation,
+        );
+        cache.insert("db".into(), response("b"), packages(&["db"]), generation);
+        cache.insert(
+            "packages".into(),
+            response("c"),
+            CacheScope::Graph,
+            generation,
+        );
+
+        // Reloading an unchanged graph keeps everything
+        assert_eq!(cache.update_graph(&graph), 0);
+        assert_eq!(cache.len(), 3);
+
+        // A new call from db into api changes both packages
+        let generation = cache.generation();
+        graph.add_edge(
+            "db/store.go:Get",
+            "api/server.go:Handle",
+            EdgeData::uses(Some(4), Some("Handle".to_string())),
+        );
+        assert_eq!(cache.update_graph(&graph), 3);
+        assert!(cache.is_empty());
+
+        // Responses computed before the reload are not cached
+        cache.insert("db".into(), response("b"), packages(&["db"]), generation);
+        assert!(cache.get("db").is_none());
+
+        let generation = cache.generation();
+        cache.insert(
+            "callers".into(),
+            response("a"),
+            packages(&["api", "cmd"]),
+            generation,
+        );
+        cache.insert("db".into(), response("b"), packages(&["db"]), generation);
+        graph.add_node(function("cmd/main.go:init"));
+        assert_eq!(cache.update_graph(&graph), 1);
+        assert!(cache.get("callers").is_none());
+        assert_eq!(cache.get("db"), Some(response("b")));
+    }
+
+    #[test]
+    fn test_lru_and_disabled() {
+        let cache = QueryCache::new(2);
+        for key in ["a", "b"] {
+            cache.insert(key.into(), response(key), CacheScope::Graph, 0);
+        }
+        cache.get("a");
+        cache.insert("c".into(), response("c"), CacheScope::Graph, 0);
+        assert!(cache.get("b").is_none());
+        assert!(cache.get("a").is_some());
+
+        let disabled = QueryCache::new(0);
+        assert!(!disabled.is_enabled());
+        disabled.insert("a".into(), response("a"), CacheScope::Graph, 0);
+        assert!(disabled.get("a").is_none());
+        assert_eq!(disabled.update_graph(&sample_graph()), 0);
+    }
+}
@@ -0,0 +1,60 @@
+//! Error types for the query services
+
+use thiserror::Error;
+
+/// Result type for server operations
+pub type Result<T> = std::result::Result<T, ServerError>;
+
+/// Errors that can occur while serving queries
+#[derive(Error, Debug)]
+pub enum ServerError {
+    /// No index in the given directory
+    #[error("No index found at {0}. Run 'codeprysm init' first.")]
+    IndexNotFound(String),
+
+    /// Graph failed to load
+    #[error("Failed to load graph: {0}")]
+    GraphLoad(String),
+
+    /// Node not found in graph
+    #[error("Node not found: {0}")]
+    NodeNotFound(String),
+
+    /// No indexed file in a package
+    #[error("Package not found: {0}")]
+    PackageNotFound(String),
+
+    /// A package has no package comment or README
+    #[error("No documentation for package: {0}")]
+    DocumentationNotFound(String),
+
+    /// The index has no recorded provenance
+    #[error("No provenance recorded for the index. Run 'codeprysm update' to record it.")]
+    ProvenanceNotFound,
+
+    /// The request presented no valid token
+    #[error("Missing or invalid API token")]
+    Unauthenticated,
+
+    /// The token does not allow the request
+    #[error("Forbidden: {0}")]
+    Forbidden(String),
+
+    /// Invalid parameters provided
+    #[error("Invalid parameters: {0}")]
+    InvalidParams(String),
+
+    /// TLS certificates or keys could not be loaded
+    #[error("TLS configuration error: {0}")]
+    Tls(String),
+
+    /// Server transport failed
+    #[error("Transport error: {0}")]
+    Transport(String),
+}
+
+impl From<codeprysm_core::lazy::LazyGraphError> for ServerError {
+    fn from(e: codeprysm_core::lazy::LazyGraphError) -> Self {
+        ServerError::GraphLoad(e.to_string())
+    }
+}
@@ -0,0 +1,411 @@
+//! GraphQL API
+//!
+//! Serves the graph at `/graphql` so clients can fetch exactly the slice they
+//! need in one round trip: look up symbols, then follow their edges as
+//! Relay-style connections, nesting as deep as [`MAX_DEPTH`] allows.
+//!
+//! ```graphql
+//! {
+//!   symbol(id: "cmd/main.go:main") {
+//!     name
+//!     edges(direction: OUTGOING, types: ["USES"], first: 10) {
+//!       totalCount
+//!       edges { edgeType refLine node { id file line } }
+//!     }
+//!   }
+//! }
+//! ```
+//!
+//! Cursors name their item by key, so `after` continues where the previous
+//! page ended even if the index was reloaded in between.
+//!
+//! Every request runs against one snapshot of the graph, even if the index is
+//! reloaded while it executes, limited to the files its token may see.
+//! `GET /graphql` serves a GraphiQL explorer.
+
+use std::sync::Arc;
+
+use async_graphql::http::GraphiQLSource;
+use async_graphql::{
+    ComplexObject, Context, EmptyMutation, EmptySubscription, Enum, Object, Schema, SimpleObject,
+};
+use async_graphql_axum::{GraphQLRequest, GraphQLResponse};
+use axum::extract::{Extension, State};
+use axum::response::Html;
+use axum::routing::get;
+use axum::Router;
+use codeprysm_core::analysis::EgoDirection;
+use codeprysm_core::pagination::Cursor;
+use codeprysm_core::{Node, PetCodeGraph};
+use tracing::debug;
+
+use crate::auth::Principal;
+use crate::error::{Result, ServerError};
+use crate::query::{self, page_request, paginate, Page, PageRequest, SymbolQuery};
+use crate::store::GraphStore;
+
+/// Maximum nesting depth of a query (each edge hop takes three levels)
+pub const MAX_DEPTH: usize = 16;
+
+/// The GraphQL schema (read-only).
+pub type GraphSchema = Schema<QueryRoot, EmptyMutation, EmptySubscription>;
+
+/// Build the GraphQL schema.
+///
+/// Queries need the graph as request data (`Arc<PetCodeGraph>`).
+pub fn schema() -> GraphSchema {
+    Schema::build(QueryRoot, EmptyMutation, EmptySubscription)
+        .limit_depth(MAX_DEPTH)
+        .finish()
+}
+
+/// Build the router serving `/graphql`.
+pub fn router(store: Arc<GraphStore>) -> Router {
+    Router::new()
+        .route("/graphql", get(graphiql).post(execute))
+        .with_state(GraphQlState {
+            store,
+            schema: schema(),
+        })
+}
+
+#[derive(Clone)]
+struct GraphQlState {
+    store: Arc<GraphStore>,
+    schema: GraphSchema,
+}
+
+async fn execute(
+    State(state): State<GraphQlState>,
+    Extension(principal): Extension<Arc<Principal>>,
+    request: GraphQLRequest,
+) -> Result<GraphQLResponse> {
+    debug!("POST /graphql");
+
+    let graph = state.store.load_graph_for(&principal).await?;
+    let response = state.schema.execute(request.into_inner().data(graph)).await;
+    Ok(response.into())
+}
+
+async fn graphiql() -> Html<String> {
+    Html(GraphiQLSource::build().endpoint("/graphql").finish())
+}
+
+/// Root query type.
+pub struct QueryRoot;
+
+#[Object]
+impl QueryRoot {
+    /// Get a symbol by node ID
+    async fn symbol(&self, ctx: &Context<'_>, id: String) -> async_graphql::Result<Option<Symbol>> {
+        Ok(graph(ctx)?.get_node(&id).map(Symbol::from))
+    }
+
+    /// Find symbols by ID, name, or ID suffix (or fuzzy name match); an empty
+    /// query lists all symbols
+    async fn symbols(
+        &self,
+        ctx: &Context<'_>,
+        #[graphql(default)] query: String,
+        #[graphql(default)] fuzzy: bool,
+        #[graphql(desc = "Node types or kinds to keep", default)] types: Vec<String>,
+        #[graphql(desc = "File path prefix")] file: Option<String>,
+        #[graphql(desc = "Attributes to require (\"key=value\" or \"key\")", default)]
+        attributes: Vec<String>,
+        first: Option<i32>,
+        after: Option<String>,
+    ) -> async_graphql::Result<SymbolConnection> {
+        let request = page_args(first, after)?;
+        let symbol_query = SymbolQuery {
+            query,
+            fuzzy,
+            node_types: types,
+            file,
+            attributes: query::parse_attribute_filters(&attributes)?,
+            limit: usize::MAX,
+        };
+        let symbols = query::lookup_symbols(graph(ctx)?, &symbol_query);
+        let key = |node: &&Node| node.id.clone();
+        let page = paginate(symbols, &request, key);
+
+        let edges: Vec<SymbolEdge> = page
+            .items
+            .iter()
+            .enumerate()
+            .map(|(i, node)| SymbolEdge {
+                cursor: Cursor::new(page.offset + i, key(node)).encode(),
+                node: Symbol::from(*node),
+            })
+            .collect();
+        Ok(SymbolConnection {
+            page_info: PageInfo::of(
+                &page,
+                edges.first().map(|edge| &edge.cursor),
+                edges.last().map(|edge| &edge.cursor),
+            ),
+            total_count: page.total as u32,
+            edges,
+        })
+    }
+}
+
+/// Edge direction relative to a symbol.
+#[derive(Enum, Debug, Clone, Copy, PartialEq, Eq, Default)]
+pub enum Direction {
+    /// Edges from the symbol
+    Outgoing,
+    /// Edges to the symbol
+    Incoming,
+    /// Both
+    #[default]
+    Both,
+}
+
+/// A code entity (file, container, callable, or data).
+#[derive(SimpleObject, Debug, Clone)]
+#[graphql(complex)]
+pub struct Symbol {
+    /// Node ID
+    pub id: String,
+    /// Entity name
+    pub name: String,
+    /// Node type ("Container", "Callable", "Data", ...)
+    pub node_type: String,
+    /// Kind within the node type ("function", "method", "type", ...)
+    pub kind: Option<String>,
+    /// Language-specific subtype ("struct", "interface", ...)
+    pub subtype: Option<String>,
+    /// File path relative to the repository root
+    pub file: String,
+    /// Start line (1-indexed)
+    pub line: u32,
+    /// End line (1-indexed)
+    pub end_line: u32,
+    /// User-defined attributes from enrichment rules, sorted by key
+    pub attributes: Vec<Attribute>,
+    /// Permalink to the source on the code host, if links are configured
+    pub url: Option<String>,
+}
+
+/// A user-defined attribute of a symbol.
+#[derive(SimpleObject, Debug, Clone)]
+pub struct Attribute {
+    /// Attribute name (e.g. "layer")
+    pub key: String,
+    /// Attribute value (e.g. "domain")
+    pub value: String,
+}
+
+#[ComplexObject]
+impl Symbol {
+    /// Edges of the symbol with the symbol at their other end, outgoing
+    /// edges first
+    async fn edges(
+        &self,
+        ctx: &Context<'_>,
+        #[graphql(default)] direction: Direction,
+        #[graphql(desc = "Edge types to keep (e.g. USES, CONTAINS)", default)] types: Vec<String>,
+        first: Option<i32>,
+        after: Option<String>,
+    ) -> async_graphql::Result<NeighborConnection> {
+        let request = page_args(first, after)?;
+        let edge_types = query::parse_edge_types(&types)?;
+        let direction = match direction {
+            Direction::Outgoing => EgoDirection::Outgoing,
+            Direction::Incoming => EgoDirection::Incoming,
+            Direction::Both => EgoDirection::Both,
+        };
+        let neighbors = query::neighbors(graph(ctx)?, &self.id, direction, &edge_types)?;
+        let page = paginate(neighbors, &request, query::Neighbor::key);
+
+        let edges: Vec<Neighbor> = page
+            .items
+            .iter()
+            .enumerate()
+            .map(|(i, neighbor)| Neighbor {
+                cursor: Cursor::new(page.offset + i, neighbor.key()).encode(),
+                node: Symbol::from(neighbor.node),
+                edge_type: neighbor.edge.edge_type.as_str().to_string(),
+                source: neighbor.source.to_string(),
+                target: neighbor.target.to_string(),
+                ref_line: neighbor.edge.ref_line.map(|line| line as u32),
+                ident: neighbor.edge.ident.clone(),
+            })
+            .collect();
+        Ok(NeighborConnection {
+            page_info: PageInfo::of(
+                &page,
+                edges.first().map(|edge| &edge.cursor),
+                edges.last().map(|edge| &edge.cursor),
+            ),
+            total_count: page.total as u32,
+            edges,
+        })
+    }
+}
+
+impl From<&Node> for Symbol {
+    fn from(node: &Node) -> Self {
+        Self {
+            id: node.id.clone(),
+            name: node.name.clone(),
+            node_type: node.node_type.as_str().to_string(),
+            kind: node.kind.clone(),
+            subtype: node.subtype.clone(),
+            file: node.file.clone(),
+            line: node.line as u32,
+            end_line: node.end_line as u32,
+            attributes: node
+                .metadata
+                .attributes
+                .iter()
+                .flatten()
+                .map(|(key, value)| Attribute {
+                    key: key.clone(),
+                    value: value.clone(),
+                })
+                .collect(),
+            url: node.metadata.url.clone(),
+        }
+    }
+}
+
+/// A page of symbols.
+#[derive(SimpleObject, Debug)]
+pub struct SymbolConnection {
+    /// Symbols on this page
+    pub edges: Vec<SymbolEdge>,
+    /// Pagination state
+    pub page_info: PageInfo,
+    /// Number of symbols across all pages
+    pub total_count: u32,
+}
+
+/// A symbol in a [`SymbolConnection`].
+#[derive(SimpleObject, Debug)]
+pub struct SymbolEdge {
+    /// Cursor to pass as `after` to continue after this symbol
+    pub cursor: String,
+    /// The symbol
+    pub node: Symbol,
+}
+
+/// A page of a symbol's edges.
+#[derive(SimpleObject, Debug)]
+pub struct NeighborConnection {
+    /// Edges on this page
+    pub edges: Vec<Neighbor>,
+    /// Pagination state
+    pub page_info: PageInfo,
+    /// Number of edges across all pages
+    pub total_count: u32,
+}
+
+/// A graph edge, with the symbol at its other end as `node`.
+#[derive(SimpleObject, Debug)]
+pub struct Neighbor {
+    /// Cursor to pass as `after` to continue after this edge
+    pub cursor: String,
+    /// The symbol at the other end of the edge
+    pub node: Symbol,
+    /// Edge type ("CONTAINS", "USES", "DEFINES")
+    pub edge_type: String,
+    /// Source node ID
+    pub source: String,
+    /// Target node ID
+    pub target: String,
+    /// Line of the reference, for USES edges (1-indexed)
+    pub ref_line: Option<u32>,
+    /// Identifier used at the reference
+    pub ident: Option<String>,
+}
+
+/// Relay pagination state.
+#[derive(SimpleObject, Debug)]
+pub struct PageInfo {
+    /// Whether items follow this page
+    pub has_next_page: bool,
+    /// Whether items precede this page
+    pub has_previous_page: bool,
+    /// Cursor of the first item on this page
+    pub start_cursor: Option<String>,
+    /// Cursor of the last item on this page
+    pub end_cursor: Option<String>,
+}
+
+impl PageInfo {
+    /// Pagination state of `page`, given the cursors of its first and last
+    /// items
+    fn of<T>(page: &Page<T>, start_cursor: Option<&String>, end_cursor: Option<&String>) -> Self {
+        Self {
+            has_next_page: page.next_offset.is_some(),
+            has_previous_page: page.offset > 0,
+            start_cursor: start_cursor.cloned(),
+            end_cursor: end_cursor.cloned(),
+        }
+    }
+}
+
+/// The graph snapshot of the current request
+fn graph<'a>(ctx: &Context<'a>) -> async_graphql::Result<&'a PetCodeGraph> {
+    Ok(ctx.data::<Arc<PetCodeGraph>>()?.as_ref())
+}
+
+/// Translate Relay `first`/`after` arguments into a page request.
+///
+/// Cursors name their item by key, so they stay valid when the index is
+/// reloaded between pages.
+fn page_args(first: Option<i32>, after: Option<String>) -> Result<PageRequest> {
+    let limit = first
+        .map(|first| {
+            usize::try_from(first)
+                .map_err(|_| ServerError::InvalidParams("'first' must not be negative".to_string()))
+        })
+        .transpose()?;
+    page_request(0, after.as_deref(), limit)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::query::DEFAULT_LIMIT;
+
+    #[test]
+    fn test_page_args() {
+        let request = page_args(None, None).unwrap();
+        assert_eq!((request.limit, request.after), (DEFAULT_LIMIT, None));
+
+        let after = Cursor::new(9, "cmd/main.go:main");
+        let request = page_args(Some(5), Some(after.encode())).unwrap();
+        assert_eq!((request.limit, request.after), (5, Some(after)));
+
+        assert!(page_args(Some(-1), None).is_err());
+        assert!(page_args(None, Some("abc".to_string())).is_err());
+    }
+
+    #[test]
+    fn test_page_info() {
+        let key = |item: &&str| item.to_string();
+        let request = PageRequest {
+            offset: 1,
+            ..PageRequest::first(1)
+        };
+        let page = paginate(vec!["a", "b", "c"], &request, key);
+        let cursor = Cursor::new(1, "b").encode();
+        let info = PageInfo::of(&page, Some(&cursor), Some(&cursor));
+        assert!(info.has_next_page && info.has_previous_page);
+        assert_eq!(info.start_cursor.as_ref(), Some(&cursor));
+        assert_eq!(info.end_cursor.as_ref(), Some(&cursor));
+
+        let empty = paginate(Vec::<&str>::new(), &PageRequest::first(10), key);
+        let info = PageInfo::of(&empty, None, None);
+        assert!(!info.has_next_page && info.start_cursor.is_none());
+    }
+
+    #[test]
+    fn test_schema_sdl() {
+        let sdl = schema().sdl();
+        assert!(sdl.contains("type SymbolConnection"));
+        assert!(sdl.contains("type NeighborConnection"));
+    }
+}
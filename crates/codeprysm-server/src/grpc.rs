@@ -0,0 +1,330 @@
+//! gRPC Service
+//!
+//! Implements `codeprysm.v1.GraphService` over a [`GraphStore`]. Node and edge
+//! types travel as the strings the graph uses elsewhere ("Callable", "USES"),
+//! so clients can pass values from JSON exports back unchanged.
+//!
+//! Lists are paginated like the REST API: responses carry a
+//! `next_page_token` to pass back as `page_token`, which stays valid when the
+//! index is reloaded in between.
+//!
+//! Servers with API tokens expect one as `authorization: Bearer <token>`
+//! metadata and answer from the files the token may see.
+
+use std::future::Future;
+use std::sync::Arc;
+use std::time::Instant;
+
+use codeprysm_core::analysis::{EgoDirection, EgoOptions};
+use codeprysm_core::{export_graph, EdgeData, ExportFormat, Node, PetCodeGraph};
+use tokio::net::TcpListener;
+use tokio_stream::wrappers::TcpListenerStream;
+use tonic::{Request, Response, Status};
+use tracing::debug;
+
+use crate::error::{Result, ServerError};
+use crate::proto::graph_service_server::{GraphService, GraphServiceServer};
+use crate::proto::{
+    Direction, Edge, GetEdgesRequest, GetEdgesResponse, GetSubgraphRequest, GetSubgraphResponse,
+    GetSymbolRequest, LookupSymbolsRequest, LookupSymbolsResponse, Neighbor, Symbol,
+};
+use crate::query::{
+    self, page_request, paginate, SymbolQuery, DEFAULT_LIMIT, DEFAULT_MAX_NODES, DEFAULT_RADIUS,
+};
+use crate::store::GraphStore;
+use crate::tls::TlsConfig;
+
+/// `codeprysm.v1.GraphService` implementation.
+pub struct GraphServiceImpl {
+    store: Arc<GraphStore>,
+}
+
+impl GraphServiceImpl {
+    /// Create a service reading from `store`.
+    pub fn new(store: Arc<GraphStore>) -> Self {
+        Self { store }
+    }
+
+    /// Wrap the service for registration with a tonic server.
+    pub fn into_server(self) -> GraphServiceServer<Self> {
+        GraphServiceServer::new(self)
+    }
+
+    /// Get the part of the graph the caller of `request` may see
+    async fn graph<T>(
+        &self,
+        request: &Request<T>,
+    ) -> std::result::Result<Arc<PetCodeGraph>, Status> {
+        let authorization = request
+            .metadata()
+            .get("authorization")
+            .and_then(|value| value.to_str().ok());
+        let principal = self.store.auth().authenticate(authorization)?;
+        Ok(self.store.load_graph_for(&principal).await?)
+    }
+
+    /// Run a handler, recording its latency and outcome under `method`
+    async fn observe<T>(
+        &self,
+        method: &str,
+        handler: impl Future<Output = std::result::Result<T, Status>>,
+    ) -> std::result::Result<T, Status> {
+        let start = Instant::now();
+        let result = handler.await;
+        self.store
+            .metrics()
+            .observe_request("grpc", method, start.elapsed(), result.is_err());
+        result
+    }
+}
+
+#[tonic::async_trait]
+impl GraphService for GraphServiceImpl {
+    async fn get_symbol(
+        &self,
+        request: Request<GetSymbolRequest>,
+    ) -> std::result::Result<Response<Symbol>, Status> {
+        self.observe("GetSymbol", async {
+            let graph = self.graph(&request).await?;
+            let request = request.into_inner();
+            debug!("GetSymbol: id='{}'", request.id);
+
+            let node = query::get_symbol(&graph, &request.id)?;
+            Ok(Response::new(to_symbol(node)))
+        })
+        .await
+    }
+
+    async fn lookup_symbols(
+        &self,
+        request: Request<LookupSymbolsRequest>,
+    ) -> std::result::Result<Response<LookupSymbolsResponse>, Status> {
+        self.observe("LookupSymbols", async {
+            let graph = self.graph(&request).await?;
+            let request = request.into_inner();
+            debug!(
+                "LookupSymbols: query='{}', fuzzy={}",
+                request.query, request.fuzzy
+            );
+
+            let paging = page_request(
+                0,
+                Some(&request.page_token),
+                Some(or_default(request.limit, DEFAULT_LIMIT)),
+            )?;
+            let symbol_query = SymbolQuery {
+                query: request.query,
+                fuzzy: request.fuzzy,
+                node_types: request.node_types,
+                file: None,
+                attributes: query::parse_attribute_filters(&request.attributes)?,
+                limit: usize::MAX,
+            };
+            let symbols = query::lookup_symbols(&graph, &symbol_query);
+            let page = paginate(symbols, &paging, |node| node.id.clone());
+            Ok(Response::new(LookupSymbolsResponse {
+                symbols: page.items.into_iter().map(to_symbol).collect(),
+                next_page_token: page.next_cursor.unwrap_or_default(),
+                total_count: page.total as u32,
+            }))
+        })
+        .await
+    }
+
+    async fn get_edges(
+        &self,
+        request: Request<GetEdgesRequest>,
+    ) -> std::result::Result<Response<GetEdgesResponse>, Status> {
+        self.observe("GetEdges", async {
+            let graph = self.graph(&request).await?;
+            let direction = to_direction(request.get_ref().direction());
+            let request = request.into_inner();
+            debug!("GetEdges: id='{}'", request.id);
+
+            let edge_types = query::parse_edge_types(&request.edge_types)?;
+            let neighbors = query::neighbors(&graph, &request.id, direction, &edge_types)?;
+            let total_count = neighbors.len() as u32;
+
+            // Clients that predate pagination get every edge
+            let (neighbors, next_page_token) =
+                if request.limit == 0 && request.page_token.is_empty() {
+                    (neighbors, String::new())
+                } else {
+                    let paging = page_request(
+                        0,
+                        Some(&request.page_token),
+                        Some(or_default(request.limit, DEFAULT_LIMIT)),
+                    )?;
+                    let page = paginate(neighbors, &paging, query::Neighbor::key);
+                    (page.items, page.next_cursor.unwrap_or_default())
+                };
+            Ok(Response::new(GetEdgesResponse {
+                neighbors: neighbors
+                    .into_iter()
+                    .map(|neighbor| Neighbor {
+                        symbol: Some(to_symbol(neighbor.node)),
+                        edge: Some(to_edge(neighbor.source, neighbor.target, neighbor.edge)),
+                    })
+                    .collect(),
+                next_page_token,
+                total_count,
+            }))
+        })
+        .await
+    }
+
+    async fn get_subgraph(
+        &self,
+        request: Request<GetSubgraphRequest>,
+    ) -> std::result::Result<Response<GetSubgraphResponse>, Status> {
+        self.observe("GetSubgraph", async {
+            let graph = self.graph(&request).await?;
+            let direction = to_direction(request.get_ref().direction());
+            let request = request.into_inner();
+            debug!(
+                "GetSubgraph: id='{}', radius={}",
+                request.id, request.radius
+            );
+
+            let format = if request.format.is_empty() {
+                None
+            } else {
+                Some(
+                    request
+                        .format
+                        .parse::<ExportFormat>()
+                        .map_err(ServerError::InvalidParams)?,
+                )
+            };
+            let edge_types = query::parse_edge_selectors(&request.edge_types)?;
+            let options = EgoOptions {
+                radius: or_default(request.radius, DEFAULT_RADIUS),
+                edge_types: (!edge_types.is_empty()).then_some(edge_types),
+                direction,
+                max_nodes: Some(or_default(request.max_nodes, DEFAULT_MAX_NODES)),
+            };
+
+            let subgraph = query::subgraph(&graph, &request.id, &options)?;
+
+            let mut nodes: Vec<Symbol> = subgraph.iter_nodes().map(to_symbol).collect();
+            nodes.sort_by(|a, b| a.id.cmp(&b.id));
+            let mut edges: Vec<Edge> = subgraph
+                .iter_edges()
+                .map(|edge| Edge {
+                    source: edge.source,
+                    target: edge.target,
+                    edge_type: edge.edge_type.as_str().to_string(),
+                    ref_line: edge.ref_line.map(|line| line as u32),
+                    ident: edge.ident,
+                })
+                .collect();
+            edges.sort_by(|a, b| {
+                (&a.source, &a.target, &a.edge_type).cmp(&(&b.source, &b.target, &b.edge_type))
+            });
+            let rendered = format
+                .map(|format| export_graph(&subgraph, format, self.store.provenance().as_ref()))
+                .unwrap_or_default();
+
+            Ok(Response::new(GetSubgraphResponse {
+                nodes,
+                edges,
+                rendered,
+            }))
+        })
+        .await
+    }
+}
+
+/// Serve the gRPC API on `listener` until `shutdown` completes.
+pub async fn serve_grpc(
+    store: Arc<GraphStore>,
+    listener: TcpListener,
+    shutdown: impl Future<Output = ()>,
+) -> Result<()> {
+    tonic::transport::Server::builder()
+        .add_service(GraphServiceImpl::new(store).into_server())
+        .serve_with_incoming_shutdown(TcpListenerStream::new(listener), shutdown)
+        .await
+        .map_err(|e| ServerError::Transport(e.to_string()))
+}
+
+/// Serve the gRPC API over TLS on `listener` until `shutdown` completes.
+pub async fn serve_grpc_tls(
+    store: Arc<GraphStore>,
+    listener: TcpListener,
+    tls: &TlsConfig,
+    shutdown: impl Future<Output = ()>,
+) -> Result<()> {
+    tonic::transport::Server::builder()
+        .tls_config(tls.tonic_config())
+        .map_err(|e| ServerError::Tls(e.to_string()))?
+        .add_service(GraphServiceImpl::new(store).into_server())
+        .serve_with_incoming_shutdown(TcpListenerStream::new(listener), shutdown)
+        .await
+        .map_err(|e| ServerError::Transport(e.to_string()))
+}
+
+impl From<ServerError> for Status {
+    fn from(e: ServerError) -> Self {
+        match e {
+            ServerError::NodeNotFound(_)
+            | ServerError::PackageNotFound(_)
+            | ServerError::DocumentationNotFound(_)
+            | ServerError::ProvenanceNotFound => Status::not_found(e.to_string()),
+            ServerError::InvalidParams(_) => Status::invalid_argument(e.to_string()),
+            ServerError::Unauthenticated => Status::unauthenticated(e.to_string()),
+            ServerError::Forbidden(_) => Status::permission_denied(e.to_string()),
+            ServerError::IndexNotFound(_) => Status::failed_precondition(e.to_string()),
+            ServerError::GraphLoad(_) => Status::unavailable(e.to_string()),
+            ServerError::Tls(_) | ServerError::Transport(_) => Status::internal(e.to_string()),
+        }
+    }
+}
+
+/// Use `default` for an unset (zero) numeric field
+fn or_default(value: u32, default: usize) -> usize {
+    if value == 0 {
+        default
+    } else {
+        value as usize
+    }
+}
+
+fn to_direction(direction: Direction) -> EgoDirection {
+    match direction {
+        Direction::Outgoing => EgoDirection::Outgoing,
+        Direction::Incoming => EgoDirection::Incoming,
+        Direction::Unspecified | Direction::Both => EgoDirection::Both,
+    }
+}
+
+fn to_symbol(node: &Node) -> Symbol {
+    Symbol {
+        id: node.id.clone(),
+        name: node.name.clone(),
+        node_type: node.node_type.as_str().to_string(),
+        kind: node.kind.clone().unwrap_or_default(),
+        subtype: node.subtype.clone().unwrap_or_default(),
+        file: node.file.clone(),
+        line: node.line as u32,
+        end_line: node.end_line as u32,
+        attributes: node
+            .metadata
+            .attributes
+            .clone()
+            .unwrap_or_default()
+            .into_iter()
+            .collect(),
+        url: node.metadata.url.clone().unwrap_or_default(),
+    }
+}
+
+fn to_edge(source: &str, target: &str, edge: &EdgeData) -> Edge {
+    Edge {
+        source: source.to_string(),
+        target: target.to_string(),
+        edge_type: edge.edge_type.as_str().to_string(),
+        ref_line: edge.ref_line.map(|line| line as u32),
+        ident: edge.ident.clone(),
+    }
+}
@@ -0,0 +1,642 @@
+//! REST API
+//!
+//! JSON endpoints over a [`GraphStore`] for lightweight tools and dashboards:
+//!
+//! - `GET /symbols` - list or search symbols
+//! - `GET /symbols/{id}` - one symbol
+//! - `GET /symbols/{id}/callers`, `/symbols/{id}/callees` - call hierarchy
+//! - `GET /symbols/{id}/hierarchy` - call hierarchy tree in the LSP
+//!   `callHierarchy` shape (see [`codeprysm_core::analysis::call_hierarchy`])
+//! - `GET /symbols/{id}/implementations` - implementing types of an interface,
+//!   or interfaces of a type
+//! - `GET /symbols/{id}/subgraph` - neighborhood in any export format
+//! - `GET /packages` - packages with coupling metrics
+//! - `GET /packages/{path}/dependencies` - package dependencies or dependents
+//! - `GET /packages/{path}/doc` - package documentation (package comments
+//!   and README)
+//! - `GET /provenance` - what built the index, and from which commit
+//! - `POST /query` - a Cypher-like pattern query (see
+//!   [`codeprysm_core::analysis::query`])
+//! - `POST /graphql` - the GraphQL API (see [`crate::graphql`])
+//! - `GET /metrics` - Prometheus metrics (see [`crate::metrics`])
+//!
+//! Servers with API tokens answer requests without a valid token with 401,
+//! serve each token only the files it may see, and serve `/metrics` only to
+//! admin tokens (see [`crate::auth`]).
+//!
+//! Symbol IDs and package paths contain slashes and are matched as the rest of
+//! the path, so they can be used unescaped (`/symbols/src/app.go:main/callers`).
+//! Lists are paginated with `limit` and either `offset` or `cursor`: every
+//! page carries the `next_cursor` to pass for the one after it, which stays
+//! valid when the index is reloaded in between.
+//!
+//! Responses of the traversal endpoints (callers, callees, hierarchy,
+//! implementations, subgraph, packages, and dependencies) are kept in the
+//! store's [`crate::cache`] until an index update changes a package they
+//! were computed from.
+
+use std::future::Future;
+use std::sync::Arc;
+use std::time::Instant;
+
+use axum::extract::{Extension, MatchedPath, Path, Query, Request, State};
+use axum::http::{header, HeaderValue, StatusCode, Uri};
+use axum::middleware::{self, Next};
+use axum::response::{IntoResponse, Response};
+use axum::routing::{get, post};
+use axum::{Json, Router};
+use codeprysm_core::analysis::{
+    call_edges, call_hierarchy, package_metrics, run_query, CallDirection, CallHierarchy,
+    CallHierarchyCall, CallHierarchyOptions, EgoDirection, EgoOptions,
+};
+use codeprysm_core::{export_graph, ExportFilter, ExportFormat, Node, PetCodeGraph};
+use serde::{Deserialize, Serialize};
+use serde_json::json;
+use tokio::net::TcpListener;
+use tracing::debug;
+
+use crate::auth::{Principal, Scope};
+use crate::cache::{CacheScope, CachedResponse};
+use crate::error::{Result, ServerError};
+use crate::graphql;
+use crate::metrics;
+use crate::query::{
+    self, page_request, paginate, DependencyDirection, SymbolQuery, DEFAULT_MAX_NODES,
+    DEFAULT_RADIUS,
+};
+use crate::store::GraphStore;
+use crate::tls::{self, TlsConfig};
+
+/// Query parameters of `GET /symbols`
+#[derive(Debug, Default, Deserialize)]
+#[serde(default)]
+struct SymbolParams {
+    /// Node ID, name, or ID suffix (or fuzzy pattern); empty lists all
+    q: String,
+    /// Match `q` fuzzily
+    fuzzy: bool,
+    /// Node types or kinds, comma-separated
+    #[serde(rename = "type")]
+    node_type: Option<String>,
+    /// File path prefix
+    file: Option<String>,
+    /// Attribute filters (`key=value` or `key`), comma-separated
+    attr: Option<String>,
+    offset: usize,
+    /// `next_cursor` of the previous page
+    cursor: Option<String>,
+    limit: Option<usize>,
+}
+
+/// Query parameters of paginated sub-resources
+#[derive(Debug, Default, Deserialize)]
+#[serde(default)]
+struct PageParams {
+    offset: usize,
+    /// `next_cursor` of the previous page
+    cursor: Option<String>,
+    limit: Option<usize>,
+}
+
+/// Query parameters of `GET /symbols/{id}/subgraph`
+#[derive(Debug, Default, Deserialize)]
+#[serde(default)]
+struct SubgraphParams {
+    radius: Option<usize>,
+    /// Edge types, comma-separated
+    edges: Option<String>,
+    /// "outgoing", "incoming", or "both"
+    direction: Option<String>,
+    max_nodes: Option<usize>,
+    /// "json", "dot", "mermaid", or "graphml"
+    format: Option<String>,
+    only_exported: bool,
+    exclude_tests: bool,
+    exclude_generated: bool,
+    max_depth: Option<usize>,
+}
+
+/// Query parameters of `GET /symbols/{id}/hierarchy`
+#[derive(Debug, Default, Deserialize)]
+#[serde(default)]
+struct HierarchyParams {
+    /// "incoming" (callers, the default) or "outgoing" (callees)
+    direction: Option<String>,
+    /// Levels expanded below the symbol
+    depth: Option<usize>,
+    /// Maximum number of calls in the tree
+    max_calls: Option<usize>,
+}
+
+/// Query parameters of `GET /packages/{path}/dependencies`
+#[derive(Debug, Default, Deserialize)]
+#[serde(default)]
+struct DependencyParams {
+    /// "outgoing" (what the package depends on) or "incoming" (dependents)
+    direction: Option<String>,
+    offset: usize,
+    /// `next_cursor` of the previous page
+    cursor: Option<String>,
+    limit: Option<usize>,
+}
+
+/// Body of `POST /query`
+#[derive(Debug, Deserialize)]
+struct QueryBody {
+    /// Pattern query, e.g. `MATCH (f:Function) RETURN f.name`
+    query: String,
+}
+
+/// A caller or callee with the lines of its calls
+#[derive(Debug, Serialize)]
+struct Call<'a> {
+    symbol: &'a Node,
+    lines: Vec<usize>,
+}
+
+/// Build the REST API router, with the GraphQL API mounted at `/graphql` and
+/// metrics at `/metrics`, all behind the store's tokens.
+pub fn router(store: Arc<GraphStore>) -> Router {
+    Router::new()
+        .route("/symbols", get(list_symbols))
+        .route("/symbols/*path", get(symbol_resource))
+        .route("/packages", get(list_packages))
+        .route("/packages/*path", get(package_resource))
+        .route("/provenance", get(get_provenance))
+        .route("/query", post(run_pattern_query))
+        .with_state(Arc::clone(&store))
+        .merge(graphql::router(Arc::clone(&store)))
+        // Added after the layer so that scrapes are not counted as queries
+        .route_layer(middleware::from_fn_with_state(
+            Arc::clone(&store),
+            track_request,
+        ))
+        .route(
+            "/metrics",
+            get(render_metrics).with_state(Arc::clone(&store)),
+        )
+        .layer(middleware::from_fn_with_state(store, authenticate))
+}
+
+/// Serve the REST API on `listener` until `shutdown` completes.
+pub async fn serve_http(
+    store: Arc<GraphStore>,
+    listener: TcpListener,
+    shutdown: impl Future<Output = ()> + Send + 'static,
+) -> Result<()> {
+    axum::serve(listener, router(store))
+        .with_graceful_shutdown(shutdown)
+        .await
+        .map_err(|e| ServerError::Transport(e.to_string()))
+}
+
+/// Serve the REST API over TLS on `listener` until `shutdown` completes.
+pub async fn serve_https(
+    store: Arc<GraphStore>,
+    listener: TcpListener,
+    tls: &TlsConfig,
+    shutdown: impl Future<Output = ()> + Send + 'static,
+) -> Result<()> {
+    tls::serve_router(router(store), listener, tls, shutdown).await
+}
+
+/// Identify the caller from its token, for handlers to take as an
+/// `Extension<Arc<Principal>>`
+async fn authenticate(
+    State(store): State<Arc<GraphStore>>,
+    mut request: Request,
+    next: Next,
+) -> Response {
+    let authorization = request
+        .headers()
+        .get(header::AUTHORIZATION)
+        .and_then(|value| value.to_str().ok());
+    match store.auth().authenticate(authorization) {
+        Ok(principal) => {
+            request.extensions_mut().insert(principal);
+            next.run(request).await
+        }
+        Err(e) => {
+            let mut response = e.into_response();
+            response
+                .headers_mut()
+                .insert(header::WWW_AUTHENTICATE, HeaderValue::from_static("Bearer"));
+            response
+        }
+    }
+}
+
+/// Record the latency and outcome of a query
+async fn track_request(
+    State(store): State<Arc<GraphStore>>,
+    request: Request,
+    next: Next,
+) -> Response {
+    let endpoint = request.extensions().get::<MatchedPath>().map_or_else(
+        || request.uri().path().to_string(),
+        |p| p.as_str().to_string(),
+    );
+    let start = Instant::now();
+    let response = next.run(request).await;
+    let failed = response.status().is_client_error() || response.status().is_server_error();
+    store
+        .metrics()
+        .observe_request("http", &endpoint, start.elapsed(), failed);
+    response
+}
+
+/// `GET /provenance`
+async fn get_provenance(State(store): State<Arc<GraphStore>>) -> Result<Response> {
+    let provenance = store.provenance().ok_or(ServerError::ProvenanceNotFound)?;
+    Ok(Json(provenance).into_response())
+}
+
+/// `GET /metrics` (admin tokens only)
+async fn render_metrics(
+    State(store): State<Arc<GraphStore>>,
+    Extension(principal): Extension<Arc<Principal>>,
+) -> Result<Response> {
+    principal.require(Scope::Admin)?;
+    Ok((
+        [(header::CONTENT_TYPE, metrics::CONTENT_TYPE)],
+        store.render_metrics(),
+    )
+        .into_response())
+}
+
+async fn list_symbols(
+    State(store): State<Arc<GraphStore>>,
+    Extension(principal): Extension<Arc<Principal>>,
+    Query(params): Query<SymbolParams>,
+) -> Result<Response> {
+    debug!("GET /symbols: q='{}'", params.q);
+
+    let request = page_request(params.offset, params.cursor.as_deref(), params.limit)?;
+    let graph = store.load_graph_for(&principal).await?;
+    let symbol_query = SymbolQuery {
+        query: params.q,
+        fuzzy: params.fuzzy,
+        node_types: split_list(params.node_type.as_deref()),
+        file: params.file,
+        attributes: query::parse_attribute_filters(&split_list(params.attr.as_deref()))?,
+        limit: usize::MAX,
+    };
+    let symbols = query::lookup_symbols(&graph, &symbol_query);
+    let page = paginate(symbols, &request, |node| node.id.clone());
+    Ok(Json(page).into_response())
+}
+
+/// `GET /symbols/{id}[/callers|/callees|/hierarchy|/implementations|/subgraph]`
+async fn symbol_resource(
+    State(store): State<Arc<GraphStore>>,
+    Extension(principal): Extension<Arc<Principal>>,
+    Path(path): Path<String>,
+    uri: Uri,
+) -> Result<Response> {
+    debug!("GET /symbols/{}", path);
+
+    let (id, resource) = split_resource(
+        &path,
+        &[
+            "callers",
+            "callees",
+            "hierarchy",
+            "implementations",
+            "subgraph",
+        ],
+    );
+    let Some(resource) = resource else {
+        let graph = store.load_graph_for(&principal).await?;
+        return Ok(Json(query::get_symbol(&graph, id)?).into_response());
+    };
+
+    let mut key = cache_key(&principal, &uri);
+    if resource == "subgraph" {
+        // Exports embed the provenance, which changes with every update
+        if let Some(provenance) = store.provenance() {
+            key.push_str(&format!("\n{}", provenance.indexed_at));
+        }
+    }
+    cached(&store, &principal, key, |graph| match resource {
+        "implementations" => {
+            let params: PageParams = parse_query(&uri)?;
+            let request = page_request(params.offset, params.cursor.as_deref(), params.limit)?;
+            let implementations = query::implementations(graph, id)?;
+            let page = paginate(implementations, &request, |node| node.id.clone());
+            // Method sets span packages
+            Ok((CachedResponse::json(&page), CacheScope::Graph))
+        }
+        "hierarchy" => {
+            let params: HierarchyParams = parse_query(&uri)?;
+            let direction = match params.direction.as_deref().unwrap_or("incoming") {
+                "incoming" => CallDirection::Incoming,
+                "outgoing" => CallDirection::Outgoing,
+                other => {
+                    return Err(ServerError::InvalidParams(format!(
+                        "Invalid direction '{}' (expected 'incoming' or 'outgoing')",
+                        other
+                    )))
+                }
+            };
+            let defaults = CallHierarchyOptions::default();
+            let options = CallHierarchyOptions {
+                depth: params.depth.unwrap_or(defaults.depth),
+                max_calls: params.max_calls.unwrap_or(defaults.max_calls),
+            };
+            query::get_symbol(graph, id)?;
+            // Sources are not served, so items carry repository-relative paths
+            let hierarchy =
+                call_hierarchy(graph, id, direction, None, &options).ok_or_else(|| {
+                    ServerError::InvalidParams(format!("'{}' is not a function or method", id))
+                })?;
+            Ok((
+                CachedResponse::json(&hierarchy),
+                hierarchy_scope(graph, &hierarchy),
+            ))
+        }
+        "subgraph" => {
+            let params: SubgraphParams = parse_query(&uri)?;
+            let format = match params.format.as_deref() {
+                Some(format) => format
+                    .parse::<ExportFormat>()
+                    .map_err(ServerError::InvalidParams)?,
+                None => ExportFormat::Json,
+            };
+            let edge_types = query::parse_edge_selectors(&split_list(params.edges.as_deref()))?;
+            let options = EgoOptions {
+                radius: params.radius.unwrap_or(DEFAULT_RADIUS),
+                edge_types: (!edge_types.is_empty()).then_some(edge_types),
+                direction: parse_ego_direction(params.direction.as_deref())?,
+                max_nodes: Some(params.max_nodes.unwrap_or(DEFAULT_MAX_NODES)),
+            };
+            let filter = ExportFilter {
+                only_exported: params.only_exported,
+                exclude_tests: params.exclude_tests,
+                exclude_generated: params.exclude_generated,
+                max_depth: params.max_depth,
+            };
+            let ego = query::subgraph(graph, id, &options)?;
+            let subgraph = filter.apply(&ego, graph);
+            let content_type = match format {
+                ExportFormat::Json => "application/json",
+                ExportFormat::Dot => "text/vnd.graphviz",
+                ExportFormat::Mermaid => "text/plain; charset=utf-8",
+                ExportFormat::GraphMl => "application/xml",
+            };
+            let response = CachedResponse {
+                content_type,
+                body: export_graph(&subgraph, format, store.provenance().as_ref()),
+            };
+            Ok((response, CacheScope::of_nodes(ego.iter_nodes())))
+        }
+        calls => {
+            let params: PageParams = parse_query(&uri)?;
+            let request = page_request(params.offset, params.cursor.as_deref(), params.limit)?;
+            let symbol = query::get_symbol(graph, id)?;
+            let direction = if calls == "callers" {
+                CallDirection::Incoming
+            } else {
+                CallDirection::Outgoing
+            };
+            let calls: Vec<Call> = call_edges(graph, id, direction)
+                .into_iter()
+                .map(|call| Call {
+                    symbol: call.node,
+                    lines: call.lines,
+                })
+                .collect();
+            // Every call touches the symbol's package, so unlisted callers
+            // are covered as well
+            let scope = CacheScope::of_nodes(
+                std::iter::once(symbol).chain(calls.iter().map(|call| call.symbol)),
+            );
+            let page = paginate(calls, &request, |call| call.symbol.id.clone());
+            Ok((CachedResponse::json(&page), scope))
+        }
+    })
+    .await
+}
+
+async fn list_packages(
+    State(store): State<Arc<GraphStore>>,
+    Extension(principal): Extension<Arc<Principal>>,
+    Query(params): Query<PageParams>,
+    uri: Uri,
+) -> Result<Response> {
+    debug!("GET /packages");
+
+    let request = page_request(params.offset, params.cursor.as_deref(), params.limit)?;
+    cached(&store, &principal, cache_key(&principal, &uri), |graph| {
+        let packages = package_metrics(graph);
+        let page = paginate(packages, &request, |metrics| metrics.package.clone());
+        Ok((CachedResponse::json(&page), CacheScope::Graph))
+    })
+    .await
+}
+
+/// `POST /query`
+///
+/// Queries run on the blocking pool, bounded by the step budget of
+/// [`run_query`], so an expensive pattern does not stall the other requests.
+async fn run_pattern_query(
+    State(store): State<Arc<GraphStore>>,
+    Extension(principal): Extension<Arc<Principal>>,
+    Json(body): Json<QueryBody>,
+) -> Result<Response> {
+    debug!("POST /query: {}", body.query);
+
+    let graph = store.load_graph_for(&principal).await?;
+    let result = tokio::task::spawn_blocking(move || run_query(&graph, &body.query))
+        .await
+        .map_err(|e| ServerError::Transport(e.to_string()))?
+        .map_err(|e| ServerError::InvalidParams(e.to_string()))?;
+    Ok(Json(result).into_response())
+}
+
+/// `GET /packages/{path}/dependencies`, `GET /packages/{path}/doc`
+async fn package_resource(
+    State(store): State<Arc<GraphStore>>,
+    Extension(principal): Extension<Arc<Principal>>,
+    Path(path): Path<String>,
+    Query(params): Query<DependencyParams>,
+    uri: Uri,
+) -> Result<Response> {
+    debug!("GET /packages/{}", path);
+
+    let (package, resource) = split_resource(&path, &["dependencies", "doc"]);
+    match resource {
+        Some("doc") => {
+            let graph = store.load_graph_for(&principal).await?;
+            return Ok(Json(query::package_doc(&graph, package)?).into_response());
+        }
+        Some(_) => {}
+        None => {
+            return Err(ServerError::InvalidParams(format!(
+                "Unknown package resource '{}' (expected /packages/<path>/dependencies or /doc)",
+                path
+            )))
+        }
+    }
+    let direction = match params.direction.as_deref().unwrap_or("outgoing") {
+        "outgoing" => DependencyDirection::Outgoing,
+        "incoming" => DependencyDirection::Incoming,
+        other => {
+            return Err(ServerError::InvalidParams(format!(
+                "Invalid direction '{}' (expected 'outgoing' or 'incoming')",
+                other
+            )))
+        }
+    };
+
+    let request = page_request(params.offset, params.cursor.as_deref(), params.limit)?;
+    cached(&store, &principal, cache_key(&principal, &uri), |graph| {
+        let dependencies = query::package_dependencies(graph, package, direction)?;
+        let page = paginate(dependencies, &request, |dependency| {
+            format!("{}\n{}", dependency.from, dependency.to)
+        });
+        // Dependencies in either direction are edges into the package
+        let scope = CacheScope::Packages([package.trim_end_matches('/').to_string()].into());
+        Ok((CachedResponse::json(&page), scope))
+    })
+    .await
+}
+
+/// Answer a traversal query from the query cache, or compute its response
+/// and cache it with the packages it was computed from.
+async fn cached(
+    store: &Arc<GraphStore>,
+    principal: &Arc<Principal>,
+    key: String,
+    compute: impl FnOnce(&PetCodeGraph) -> Result<(CachedResponse, CacheScope)>,
+) -> Result<Response> {
+    let cache = store.cache();
+    let generation = cache.generation();
+    // Loading first picks up index updates and evicts what they changed
+    let graph = store.load_graph_for(principal).await?;
+    if let Some(response) = cache.get(&key) {
+        store.metrics().observe_cache_lookup(true);
+        return Ok(respond(&response));
+    }
+    if cache.is_enabled() {
+        store.metrics().observe_cache_lookup(false);
+    }
+
+    let (response, scope) = compute(&graph)?;
+    let response = Arc::new(response);
+    cache.insert(key, Arc::clone(&response), scope, generation);
+    Ok(respond(&response))
+}
+
+/// Cache key of a request: the caller's token and the path with its query
+fn cache_key(principal: &Principal, uri: &Uri) -> String {
+    format!("{}\n{}", principal.name, uri)
+}
+
+fn respond(response: &CachedResponse) -> Response {
+    (
+        [(header::CONTENT_TYPE, response.content_type)],
+        response.body.clone(),
+    )
+        .into_response()
+}
+
+/// Packages of every symbol in a call hierarchy
+fn hierarchy_scope(graph: &PetCodeGraph, hierarchy: &CallHierarchy) -> CacheScope {
+    fn collect<'a>(calls: &'a [CallHierarchyCall], ids: &mut Vec<&'a str>) {
+        for call in calls {
+            ids.push(&call.item().detail);
+            collect(&call.calls, ids);
+        }
+    }
+
+    let mut ids = vec![hierarchy.item.detail.as_str()];
+    collect(&hierarchy.calls, &mut ids);
+    CacheScope::of_nodes(ids.into_iter().filter_map(|id| graph.get_node(id)))
+}
+
+impl IntoResponse for ServerError {
+    fn into_response(self) -> Response {
+        let status = match self {
+            ServerError::NodeNotFound(_)
+            | ServerError::PackageNotFound(_)
+            | ServerError::DocumentationNotFound(_)
+            | ServerError::ProvenanceNotFound => StatusCode::NOT_FOUND,
+            ServerError::InvalidParams(_) => StatusCode::BAD_REQUEST,
+            ServerError::Unauthenticated => StatusCode::UNAUTHORIZED,
+            ServerError::Forbidden(_) => StatusCode::FORBIDDEN,
+            ServerError::IndexNotFound(_) | ServerError::GraphLoad(_) => {
+                StatusCode::SERVICE_UNAVAILABLE
+            }
+            ServerError::Tls(_) | ServerError::Transport(_) => StatusCode::INTERNAL_SERVER_ERROR,
+        };
+        (status, Json(json!({"error": self.to_string()}))).into_response()
+    }
+}
+
+/// Split a trailing `/resource` off a path if it is one of `resources`
+fn split_resource<'a>(path: &'a str, resources: &[&'a str]) -> (&'a str, Option<&'a str>) {
+    match path.rsplit_once('/') {
+        Some((base, last)) if resources.contains(&last) => (base, Some(last)),
+        _ => (path, None),
+    }
+}
+
+/// Split a comma-separated parameter, dropping empty entries
+fn split_list(value: Option<&str>) -> Vec<String> {
+    value
+        .unwrap_or_default()
+        .split(',')
+        .map(str::trim)
+        .filter(|s| !s.is_empty())
+        .map(str::to_string)
+        .collect()
+}
+
+fn parse_query<T: serde::de::DeserializeOwned>(uri: &Uri) -> Result<T> {
+    Query::try_from_uri(uri)
+        .map(|Query(params)| params)
+        .map_err(|e| ServerError::InvalidParams(e.body_text()))
+}
+
+fn parse_ego_direction(direction: Option<&str>) -> Result<EgoDirection> {
+    match direction.unwrap_or("both") {
+        "outgoing" => Ok(EgoDirection::Outgoing),
+        "incoming" => Ok(EgoDirection::Incoming),
+        "both" => Ok(EgoDirection::Both),
+        other => Err(ServerError::InvalidParams(format!(
+            "Invalid direction '{}' (expected 'outgoing', 'incoming', or 'both')",
+            other
+        ))),
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_split_resource() {
+        let resources = ["callers", "subgraph"];
+        assert_eq!(
+            split_resource("api/server.go:Server:Handle/callers", &resources),
+            ("api/server.go:Server:Handle", Some("callers"))
+        );
+        assert_eq!(
+            split_resource("api/server.go:Server:Handle", &resources),
+            ("api/server.go:Server:Handle", None)
+        );
+        assert_eq!(
+            split_resource("api/server.go", &resources),
+            ("api/server.go", None)
+        );
+    }
+
+    #[test]
+    fn test_split_list() {
+        assert_eq!(
+            split_list(Some("USES, contains,")),
+            vec!["USES", "contains"]
+        );
+        assert!(split_list(None).is_empty());
+    }
+}
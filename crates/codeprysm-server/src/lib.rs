@@ -0,0 +1,51 @@
+//! CodePrysm Server - Network query services over a code graph
+//!
+//! Serves read-only queries over a repository's index to other services, so
+//! they can use typed clients instead of shelling out to the CLI.
+//!
+//! # Services
+//!
+//! - **gRPC**: `codeprysm.v1.GraphService` (see `proto/codeprysm/v1/graph.proto`)
+//!   for symbol lookup, edge traversal, and subgraph export
+//! - **REST**: paginated JSON endpoints for symbols, callers, package
+//!   dependencies, and subgraphs (see [`http`])
+//! - **GraphQL**: symbols with their edges as connections, for nested
+//!   traversal in one round trip (see [`graphql`]); served next to REST
+//! - **Web UI**: an interactive graph explorer over the REST API (see [`ui`])
+//!
+//! All services read from a [`GraphStore`], which reloads the graph when the
+//! index changes on disk and records the [`metrics`] served at `/metrics`.
+//! REST responses of traversal queries are cached until an index update
+//! changes one of the packages they were computed from (see [`cache`]).
+//! The store can require API tokens with read or admin scope, each limited to
+//! some paths (see [`auth`]), and every service can be served over TLS,
+//! optionally requiring client certificates (see [`tls`]).
+
+pub mod auth;
+pub mod cache;
+pub mod error;
+pub mod graphql;
+pub mod grpc;
+pub mod http;
+pub mod metrics;
+pub mod query;
+pub mod store;
+pub mod tls;
+pub mod ui;
+
+/// Generated protobuf types and gRPC client/server stubs
+pub mod proto {
+    tonic::include_proto!("codeprysm.v1");
+}
+
+// Re-exports
+pub use auth::{ApiToken, Auth, Principal, Scope};
+pub use cache::{CacheScope, CachedResponse, QueryCache, DEFAULT_CACHE_ENTRIES};
+pub use error::{Result, ServerError};
+pub use graphql::{schema, GraphSchema};
+pub use grpc::{serve_grpc, serve_grpc_tls, GraphServiceImpl};
+pub use http::{router, serve_http, serve_https};
+pub use metrics::Metrics;
+pub use store::GraphStore;
+pub use tls::TlsConfig;
+pub use ui::{serve_ui, serve_ui_tls};
@@ -0,0 +1,386 @@
+//! Prometheus Metrics
+//!
+//! Operational metrics for the services, rendered in the Prometheus text
+//! exposition format at `GET /metrics`:
+//!
+//! - `codeprysm_request_duration_seconds{api,endpoint}` - query latency
+//!   (histogram; its `_count` is the request count)
+//! - `codeprysm_request_errors_total{api,endpoint}` - failed queries
+//! - `codeprysm_graph_load_duration_seconds` - graph loads and reloads
+//! - `codeprysm_query_cache_hits_total`, `codeprysm_query_cache_misses_total`,
+//!   `codeprysm_query_cache_evictions_total` - the query cache (see
+//!   [`crate::cache`])
+//! - `codeprysm_index_nodes`, `codeprysm_index_edges`, `codeprysm_index_files`
+//!   - size of the loaded graph
+//! - `codeprysm_index_size_bytes` - size of the index on disk
+//! - `codeprysm_build_duration_seconds`, `codeprysm_build_files_parsed`,
+//!   `codeprysm_build_files_cached`, `codeprysm_build_parse_errors`,
+//!   `codeprysm_build_timestamp_seconds` - the last `codeprysm init` or
+//!   `codeprysm update`
+//!
+//! Graph size gauges are omitted until the first query loads the graph, so
+//! scraping never triggers a load.
+
+use std::collections::BTreeMap;
+use std::fmt::Write;
+use std::path::Path;
+use std::sync::{Mutex, PoisonError};
+use std::time::Duration;
+
+use codeprysm_core::{BuildStats, PetCodeGraph};
+
+/// Histogram bucket upper bounds in seconds
+const BUCKETS: &[f64] = &[
+    0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1.0, 2.5, 5.0, 10.0, 30.0, 60.0,
+];
+
+/// Content type of the text exposition format
+pub const CONTENT_TYPE: &str = "text/plain; version=0.0.4; charset=utf-8";
+
+/// Observations of one duration distribution.
+#[derive(Debug, Clone, Default)]
+struct Histogram {
+    /// Observations per bucket (not cumulative); the last slot is +Inf
+    counts: Vec<u64>,
+    sum: f64,
+    count: u64,
+}
+
+impl Histogram {
+    fn observe(&mut self, seconds: f64) {
+        if self.counts.is_empty() {
+            self.counts = vec![0; BUCKETS.len() + 1];
+        }
+        let bucket = BUCKETS
+            .iter()
+            .position(|&bound| seconds <= bound)
+            .unwrap_or(BUCKETS.len());
+        self.counts[bucket] += 1;
+        self.sum += seconds;
+        self.count += 1;
+    }
+
+    /// Write the `_bucket`, `_sum`, and `_count` series. `labels` is empty or
+    /// ends with a comma.
+    fn render(&self, out: &mut String, name: &str, labels: &str) {
+        let mut cumulative = 0;
+        for (i, count) in self.counts.iter().enumerate() {
+            cumulative += count;
+            let bound = BUCKETS
+                .get(i)
+                .map_or_else(|| "+Inf".to_string(), |b| b.to_string());
+            let _ = writeln!(
+                out,
+                "{}_bucket{{{}le=\"{}\"}} {}",
+                name, labels, bound, cumulative
+            );
+        }
+        let labels = labels.trim_end_matches(',');
+        let _ = writeln!(out, "{}_sum{{{}}} {}", name, labels, self.sum);
+        let _ = writeln!(out, "{}_count{{{}}} {}", name, labels, self.count);
+    }
+}
+
+/// Request statistics of one endpoint
+#[derive(Debug, Default)]
+struct EndpointStats {
+    latency: Histogram,
+    errors: u64,
+}
+
+/// Lookups and invalidations of the query cache
+#[derive(Debug, Default)]
+struct CacheCounts {
+    hits: u64,
+    misses: u64,
+    evictions: u64,
+}
+
+/// Metrics collected by a running server.
+#[derive(Debug, Default)]
+pub struct Metrics {
+    /// Keyed by (api, endpoint)
+    requests: Mutex<BTreeMap<(String, String), EndpointStats>>,
+    graph_loads: Mutex<Histogram>,
+    query_cache: Mutex<CacheCounts>,
+}
+
+impl Metrics {
+    /// Create an empty set of metrics.
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Record a handled query.
+    ///
+    /// `api` is the service ("http", "grpc") and `endpoint` the route or
+    /// method.
+    pub fn observe_request(&self, api: &str, endpoint: &str, elapsed: Duration, failed: bool) {
+        let mut requests = self.requests.lock().unwrap_or_else(PoisonError::into_inner);
+        let stats = requests
+            .entry((api.to_string(), endpoint.to_string()))
+            .or_default();
+        stats.latency.observe(elapsed.as_secs_f64());
+        if failed {
+            stats.errors += 1;
+        }
+    }
+
+    /// Record a graph load or reload.
+    pub fn observe_graph_load(&self, elapsed: Duration) {
+        self.graph_loads
+            .lock()
+            .unwrap_or_else(PoisonError::into_inner)
+            .observe(elapsed.as_secs_f64());
+    }
+
+    /// Record a query cache lookup.
+    pub fn observe_cache_lookup(&self, hit: bool) {
+        let mut counts = self.cache_counts();
+        if hit {
+            counts.hits += 1;
+        } else {
+            counts.misses += 1;
+        }
+    }
+
+    /// Record responses evicted from the query cache by an index update.
+    pub fn observe_cache_evictions(&self, evicted: usize) {
+        self.cache_counts().evictions += evicted as u64;
+    }
+
+    fn cache_counts(&self) -> std::sync::MutexGuard<'_, CacheCounts> {
+        self.query_cache
+            .lock()
+            .unwrap_or_else(PoisonError::into_inner)
+    }
+
+    /// Render all metrics in the Prometheus text format.
+    ///
+    /// `graph` is the currently loaded graph, if any; `prism_dir` the index
+    /// directory, if the server reads one.
+    pub fn render(&self, graph: Option<&PetCodeGraph>, prism_dir: Option<&Path>) -> String {
+        let mut out = String::new();
+
+        {
+            let requests = self.requests.lock().unwrap_or_else(PoisonError::into_inner);
+            header(
+                &mut out,
+                "codeprysm_request_duration_seconds",
+                "histogram",
+                "Query latency by API and endpoint",
+            );
+            for ((api, endpoint), stats) in requests.iter() {
+                stats.latency.render(
+                    &mut out,
+                    "codeprysm_request_duration_seconds",
+                    &endpoint_labels(api, endpoint, ","),
+                );
+            }
+            header(
+                &mut out,
+                "codeprysm_request_errors_total",
+                "counter",
+                "Queries that returned an error, by API and endpoint",
+            );
+            for ((api, endpoint), stats) in requests.iter() {
+                let _ = writeln!(
+                    out,
+                    "codeprysm_request_errors_total{{{}}} {}",
+                    endpoint_labels(api, endpoint, ""),
+                    stats.errors
+                );
+            }
+        }
+
+        header(
+            &mut out,
+            "codeprysm_graph_load_duration_seconds",
+            "histogram",
+            "Time to load the graph from the index",
+        );
+        let loads = self
+            .graph_loads
+            .lock()
+            .unwrap_or_else(PoisonError::into_inner);
+        if loads.count > 0 {
+            loads.render(&mut out, "codeprysm_graph_load_duration_seconds", "");
+        }
+
+        let cache = self.cache_counts();
+        counter(
+            &mut out,
+            "codeprysm_query_cache_hits_total",
+            "Queries answered from the query cache",
+            cache.hits,
+        );
+        counter(
+            &mut out,
+            "codeprysm_query_cache_misses_total",
+            "Cacheable queries computed from the graph",
+            cache.misses,
+        );
+        counter(
+            &mut out,
+            "codeprysm_query_cache_evictions_total",
+            "Cached responses invalidated by index updates",
+            cache.evictions,
+        );
+
+        if let Some(graph) = graph {
+            let files = graph.iter_nodes().filter(|n| n.is_file()).count();
+            gauge(
+                &mut out,
+                "codeprysm_index_nodes",
+                "Nodes in the loaded graph",
+                graph.node_count(),
+            );
+            gauge(
+                &mut out,
+                "codeprysm_index_edges",
+                "Edges in the loaded graph",
+                graph.edge_count(),
+            );
+            gauge(
+                &mut out,
+                "codeprysm_index_files",
+                "Files in the loaded graph",
+                files,
+            );
+        }
+
+        let Some(prism_dir) = prism_dir else {
+            return out;
+        };
+        gauge(
+            &mut out,
+            "codeprysm_index_size_bytes",
+            "Size of the index on disk",
+            index_size(prism_dir),
+        );
+        if let Some(build) = BuildStats::load(prism_dir) {
+            gauge(
+                &mut out,
+                "codeprysm_build_duration_seconds",
+                "Duration of the last index build",
+                build.duration_ms as f64 / 1000.0,
+            );
+            gauge(
+                &mut out,
+                "codeprysm_build_files_parsed",
+                "Files parsed by the last index build",
+                build.files_parsed,
+            );
+            gauge(
+                &mut out,
+                "codeprysm_build_files_cached",
+                "Files reused from the extraction cache by the last index build",
+                build.files_cached,
+            );
+            gauge(
+                &mut out,
+                "codeprysm_build_parse_errors",
+                "Files that failed to parse in the last index build",
+                build.parse_errors,
+            );
+            gauge(
+                &mut out,
+                "codeprysm_build_timestamp_seconds",
+                "When the last index build finished",
+                build.finished_at,
+            );
+        }
+        out
+    }
+}
+
+fn header(out: &mut String, name: &str, kind: &str, help: &str) {
+    let _ = writeln!(out, "# HELP {} {}", name, help);
+    let _ = writeln!(out, "# TYPE {} {}", name, kind);
+}
+
+fn gauge(out: &mut String, name: &str, help: &str, value: impl std::fmt::Display) {
+    header(out, name, "gauge", help);
+    let _ = writeln!(out, "{} {}", name, value);
+}
+
+fn counter(out: &mut String, name: &str, help: &str, value: u64) {
+    header(out, name, "counter", help);
+    let _ = writeln!(out, "{} {}", name, value);
+}
+
+/// `api` and `endpoint` labels, escaped, followed by `suffix`
+fn endpoint_labels(api: &str, endpoint: &str, suffix: &str) -> String {
+    format!(
+        "api=\"{}\",endpoint=\"{}\"{}",
+        escape(api),
+        escape(endpoint),
+        suffix
+    )
+}
+
+/// Escape a label value
+fn escape(value: &str) -> String {
+    value
+        .replace('\\', "\\\\")
+        .replace('"', "\\\"")
+        .replace('\n', "\\n")
+}
+
+/// Total size of the manifest, cross-reference database, and partitions
+fn index_size(prism_dir: &Path) -> u64 {
+    let file_size = |path: &Path| std::fs::metadata(path).map_or(0, |m| m.len());
+    let partitions: u64 = std::fs::read_dir(prism_dir.join("partitions"))
+        .map(|entries| {
+            entries
+                .filter_map(|entry| entry.ok())
+                .map(|entry| file_size(&entry.path()))
+                .sum()
+        })
+        .unwrap_or(0);
+    file_size(&prism_dir.join("manifest.json"))
+        + file_size(&prism_dir.join("cross_refs.db"))
+        + partitions
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_histogram_render() {
+        let mut histogram = Histogram::default();
+        histogram.observe(0.003);
+        histogram.observe(0.2);
+        histogram.observe(120.0);
+
+        let mut out = String::new();
+        histogram.render(&mut out, "latency", "api=\"grpc\",");
+        assert!(out.contains("latency_bucket{api=\"grpc\",le=\"0.001\"} 0\n"));
+        assert!(out.contains("latency_bucket{api=\"grpc\",le=\"0.005\"} 1\n"));
+        assert!(out.contains("latency_bucket{api=\"grpc\",le=\"0.25\"} 2\n"));
+        assert!(out.contains("latency_bucket{api=\"grpc\",le=\"+Inf\"} 3\n"));
+        assert!(out.contains("latency_count{api=\"grpc\"} 3\n"));
+    }
+
+    #[test]
+    fn test_render_requests() {
+        let metrics = Metrics::new();
+        metrics.observe_request("http", "/symbols", Duration::from_millis(4), false);
+        metrics.observe_request("http", "/symbols", Duration::from_millis(8), true);
+        metrics.observe_cache_lookup(true);
+        metrics.observe_cache_lookup(false);
+        metrics.observe_cache_lookup(true);
+
+        let out = metrics.render(None, None);
+        assert!(out.contains(
+            "codeprysm_request_duration_seconds_count{api=\"http\",endpoint=\"/symbols\"} 2\n"
+        ));
+        assert!(
+            out.contains("codeprysm_request_errors_total{api=\"http\",endpoint=\"/symbols\"} 1\n")
+        );
+        assert!(out.contains("codeprysm_query_cache_hits_total 2\n"));
+        assert!(out.contains("codeprysm_query_cache_misses_total 1\n"));
+        assert!(!out.contains("codeprysm_index_nodes"));
+    }
+}
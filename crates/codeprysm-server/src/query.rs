@@ -0,0 +1,564 @@
+//! Query Operations
+//!
+//! Protocol-independent queries shared by the services: symbol lookup, edge
+//! traversal, subgraph extraction, package dependencies, and pagination.
+//! Services translate their requests into these calls and the results into
+//! their wire types.
+//!
+//! Every list is in a stable order, ties broken by the key its items are
+//! paginated by, so cursors (see [`codeprysm_core::pagination`]) continue
+//! where the previous page ended even after the index is reloaded.
+
+use codeprysm_core::analysis::{
+    collect_dependencies, ego_graph, fuzzy_search, package_of, resolve_symbol, CycleLevel,
+    Dependency, EdgeSelector, EgoDirection, EgoOptions, FuzzyOptions, MethodSets,
+};
+pub use codeprysm_core::pagination::{Page, PageRequest};
+use codeprysm_core::{AttributeFilter, EdgeData, EdgeType, Node, PetCodeGraph};
+
+use crate::error::{Result, ServerError};
+
+/// Default number of symbols returned by a lookup
+pub const DEFAULT_LIMIT: usize = 50;
+
+/// Default subgraph radius
+pub const DEFAULT_RADIUS: usize = 2;
+
+/// Default maximum number of subgraph nodes
+pub const DEFAULT_MAX_NODES: usize = 200;
+
+/// Largest page size a client may request
+pub const MAX_PAGE_SIZE: usize = 1000;
+
+/// A symbol lookup.
+#[derive(Debug, Clone, Default)]
+pub struct SymbolQuery {
+    /// Node ID, name, or ID suffix; or a fuzzy pattern (empty = all symbols)
+    pub query: String,
+    /// Match names fuzzily
+    pub fuzzy: bool,
+    /// Node types or kinds to keep (empty = all)
+    pub node_types: Vec<String>,
+    /// Only keep symbols in files under this path prefix
+    pub file: Option<String>,
+    /// Only keep symbols with all these attributes
+    pub attributes: Vec<AttributeFilter>,
+    /// Maximum number of symbols
+    pub limit: usize,
+}
+
+/// Which side of a dependency to list.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum DependencyDirection {
+    /// Packages the package depends on
+    Outgoing,
+    /// Packages depending on the package
+    Incoming,
+}
+
+/// An edge of a node, with the node at its other end.
+#[derive(Debug, Clone)]
+pub struct Neighbor<'a> {
+    /// The node at the other end of the edge
+    pub node: &'a Node,
+    /// Edge source node ID
+    pub source: &'a str,
+    /// Edge target node ID
+    pub target: &'a str,
+    /// Edge data
+    pub edge: &'a EdgeData,
+}
+
+impl Neighbor<'_> {
+    /// Key identifying the edge within a list of neighbors
+    pub fn key(&self) -> String {
+        format!(
+            "{}\n{}\n{}\n{}\n{}",
+            self.source,
+            self.target,
+            self.edge.edge_type.as_str(),
+            self.edge.ref_line.unwrap_or(0),
+            self.edge.ident.as_deref().unwrap_or_default()
+        )
+    }
+}
+
+/// Get a node by ID.
+pub fn get_symbol<'a>(graph: &'a PetCodeGraph, id: &str) -> Result<&'a Node> {
+    graph
+        .get_node(id)
+        .ok_or_else(|| ServerError::NodeNotFound(id.to_string()))
+}
+
+/// Find symbols matching a query.
+///
+/// Exact lookups resolve the query like the CLI does (node ID, then name, then
+/// ID suffix) and return all candidates sorted by ID; fuzzy lookups are ranked
+/// by match quality. An empty query lists every node, sorted by ID.
+pub fn lookup_symbols<'a>(graph: &'a PetCodeGraph, query: &SymbolQuery) -> Vec<&'a Node> {
+    let mut symbols: Vec<&Node> = if query.query.is_empty() {
+        let mut all: Vec<&Node> = graph.iter_nodes().collect();
+        all.sort_by(|a, b| a.id.cmp(&b.id));
+        all
+    } else if query.fuzzy {
+        let options = FuzzyOptions {
+            limit: usize::MAX,
+            docs_root: None,
+        };
+        fuzzy_search(graph, &query.query, &options)
+            .iter()
+            .filter_map(|m| graph.get_node(&m.id))
+            .collect()
+    } else {
+        resolve_symbol(graph, &query.query)
+    };
+
+    symbols.retain(|node| {
+        matches_type(node, &query.node_types)
+            && query
+                .file
+                .as_deref()
+                .is_none_or(|prefix| node.file.starts_with(prefix))
+            && query.attributes.iter().all(|filter| filter.matches(node))
+    });
+    symbols.truncate(query.limit);
+    symbols
+}
+
+/// Parse attribute filters (`key=value`, or `key` for any value).
+pub fn parse_attribute_filters(filters: &[String]) -> Result<Vec<AttributeFilter>> {
+    filters
+        .iter()
+        .map(|filter| filter.parse().map_err(ServerError::InvalidParams))
+        .collect()
+}
+
+/// Check if a node has one of the given node types or kinds
+/// (case-insensitive; an empty list matches everything).
+pub fn matches_type(node: &Node, types: &[String]) -> bool {
+    types.is_empty()
+        || types.iter().any(|t| {
+            t.eq_ignore_ascii_case(node.node_type.as_str())
+                || node
+                    .kind
+                    .as_deref()
+                    .is_some_and(|k| t.eq_ignore_ascii_case(k))
+        })
+}
+
+/// List the edges of a node, outgoing edges first, each sorted by the ID of
+/// the node at the other end, then by edge type and reference line.
+pub fn neighbors<'a>(
+    graph: &'a PetCodeGraph,
+    id: &str,
+    direction: EgoDirection,
+    edge_types: &[EdgeType],
+) -> Result<Vec<Neighbor<'a>>> {
+    let center = get_symbol(graph, id)?;
+    let keep = |edge: &EdgeData| edge_types.is_empty() || edge_types.contains(&edge.edge_type);
+
+    let mut outgoing: Vec<Neighbor> = Vec::new();
+    let mut incoming: Vec<Neighbor> = Vec::new();
+    if direction != EgoDirection::Incoming {
+        outgoing = graph
+            .outgoing_edges(id)
+            .filter(|(_, edge)| keep(edge))
+            .map(|(node, edge)| Neighbor {
+                node,
+                source: &center.id,
+                target: &node.id,
+                edge,
+            })
+            .collect();
+    }
+    if direction != EgoDirection::Outgoing {
+        incoming = graph
+            .incoming_edges(id)
+            .filter(|(_, edge)| keep(edge))
+            .map(|(node, edge)| Neighbor {
+                node,
+                source: &node.id,
+                target: &center.id,
+                edge,
+            })
+            .collect();
+    }
+
+    let order = |a: &Neighbor, b: &Neighbor| {
+        a.node
+            .id
+            .cmp(&b.node.id)
+            .then_with(|| a.edge.edge_type.as_str().cmp(b.edge.edge_type.as_str()))
+            .then_with(|| a.edge.ref_line.cmp(&b.edge.ref_line))
+            .then_with(|| a.edge.ident.cmp(&b.edge.ident))
+    };
+    outgoing.sort_by(order);
+    incoming.sort_by(order);
+    outgoing.extend(incoming);
+    Ok(outgoing)
+}
+
+/// Extract the neighborhood of a node.
+pub fn subgraph(graph: &PetCodeGraph, id: &str, options: &EgoOptions) -> Result<PetCodeGraph> {
+    get_symbol(graph, id)?;
+    Ok(ego_graph(graph, id, options))
+}
+
+/// Types implementing an interface, or interfaces implemented by a type,
+/// sorted by ID.
+///
+/// Implementations are matched by method set (see [`MethodSets`]); other
+/// symbols have none.
+pub fn implementations<'a>(graph: &'a PetCodeGraph, id: &str) -> Result<Vec<&'a Node>> {
+    get_symbol(graph, id)?;
+    let sets = MethodSets::build(graph);
+    let ids = if sets.is_interface(id) {
+        sets.implementers(id)
+    } else {
+        sets.interfaces_of(id)
+    };
+    Ok(ids.iter().filter_map(|id| graph.get_node(id)).collect())
+}
+
+/// Dependencies of a package (or dependents, for incoming), most referenced
+/// first, then by package.
+///
+/// Fails if no indexed file is in the package.
+pub fn package_dependencies(
+    graph: &PetCodeGraph,
+    package: &str,
+    direction: DependencyDirection,
+) -> Result<Vec<Dependency>> {
+    let package = package.trim_end_matches('/');
+    if !graph
+        .iter_nodes()
+        .any(|n| !n.file.is_empty() && package_of(&n.file) == package)
+    {
+        return Err(ServerError::PackageNotFound(package.to_string()));
+    }
+
+    let mut dependencies: Vec<Dependency> = collect_dependencies(graph, CycleLevel::Package)
+        .into_iter()
+        .filter(|d| match direction {
+            DependencyDirection::Outgoing => d.from == package,
+            DependencyDirection::Incoming => d.to == package,
+        })
+        .collect();
+    dependencies.sort_by(|a, b| {
+        b.references
+            .cmp(&a.references)
+            .then_with(|| (&a.from, &a.to).cmp(&(&b.from, &b.to)))
+    });
+    Ok(dependencies)
+}
+
+/// Documentation node of a package (see [`codeprysm_core::package_docs`]).
+///
+/// Fails if no indexed file is in the package, or it is not documented.
+pub fn package_doc<'a>(graph: &'a PetCodeGraph, package: &str) -> Result<&'a Node> {
+    let package = package.trim_end_matches('/');
+    if let Some(doc) = codeprysm_core::package_doc(graph, package) {
+        return Ok(doc);
+    }
+    if graph
+        .iter_nodes()
+        .any(|n| !n.file.is_empty() && package_of(&n.file) == package)
+    {
+        Err(ServerError::DocumentationNotFound(package.to_string()))
+    } else {
+        Err(ServerError::PackageNotFound(package.to_string()))
+    }
+}
+
+/// Build a page request from an offset, an optional cursor (which takes
+/// precedence), and an optional limit ([`DEFAULT_LIMIT`] if unset).
+pub fn page_request(
+    offset: usize,
+    cursor: Option<&str>,
+    limit: Option<usize>,
+) -> Result<PageRequest> {
+    let cursor = cursor.filter(|cursor| !cursor.is_empty());
+    PageRequest::parse(offset, cursor, limit.unwrap_or(DEFAULT_LIMIT))
+        .map_err(|e| ServerError::InvalidParams(e.to_string()))
+}
+
+/// Cut the requested page out of `items`, identifying items by `key`; the
+/// limit is clamped to [`MAX_PAGE_SIZE`].
+pub fn paginate<T>(items: Vec<T>, request: &PageRequest, key: impl Fn(&T) -> String) -> Page<T> {
+    let request = PageRequest {
+        limit: request.limit.clamp(1, MAX_PAGE_SIZE),
+        ..request.clone()
+    };
+    codeprysm_core::paginate(items, &request, key)
+}
+
+/// Parse edge type names (e.g. "USES", "contains").
+pub fn parse_edge_types(names: &[String]) -> Result<Vec<EdgeType>> {
+    names
+        .iter()
+        .map(|name| name.parse::<EdgeType>().map_err(ServerError::InvalidParams))
+        .collect()
+}
+
+/// Parse edge type names for a subgraph, which also accepts "calls" and
+/// "implements".
+pub fn parse_edge_selectors(names: &[String]) -> Result<Vec<EdgeSelector>> {
+    names
+        .iter()
+        .map(|name| name.parse().map_err(ServerError::InvalidParams))
+        .collect()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use codeprysm_core::{CallableKind, ContainerKind, DataKind, Edge};
+
+    fn sample_graph() -> PetCodeGraph {
+        let mut graph = PetCodeGraph::new();
+        graph.add_node(Node::container(
+            "api/server.go:Server".to_string(),
+            "Server".to_string(),
+            ContainerKind::Type,
+            Some("struct".to_string()),
+            "api/server.go".to_string(),
+            3,
+            8,
+        ));
+        for (id, line) in [
+            ("api/server.go:Server:Handle", 10),
+            ("api/server.go:Server:HandleAll", 20),
+            ("cmd/main.go:main", 1),
+        ] {
+            graph.add_node(Node::callable(
+                id.to_string(),
+                id.rsplit(':').next().unwrap().to_string(),
+                CallableKind::Method,
+                id.split(':').next().unwrap().to_string(),
+                line,
+                line + 5,
+            ));
+        }
+        graph.add_edge(
+            "api/server.go:Server",
+            "api/server.go:Server:Handle",
+            EdgeData::defines(),
+        );
+        graph.add_edge(
+            "cmd/main.go:main",
+            "api/server.go:Server:Handle",
+            EdgeData::uses(Some(2), Some("Handle".to_string())),
+        );
+        graph
+    }
+
+    #[test]
+    fn test_lookup_symbols() {
+        let graph = sample_graph();
+
+        let exact = SymbolQuery {
+            query: "Handle".to_string(),
+            limit: DEFAULT_LIMIT,
+            ..Default::default()
+        };
+        let ids: Vec<&str> = lookup_symbols(&graph, &exact)
+            .iter()
+            .map(|n| n.id.as_str())
+            .collect();
+        assert_eq!(ids, vec!["api/server.go:Server:Handle"]);
+
+        let fuzzy = SymbolQuery {
+            query: "hdl".to_string(),
+            fuzzy: true,
+            node_types: vec!["callable".to_string()],
+            limit: 1,
+            ..Default::default()
+        };
+        let symbols = lookup_symbols(&graph, &fuzzy);
+        assert_eq!(symbols.len(), 1);
+        assert_eq!(symbols[0].name, "Handle");
+    }
+
+    #[test]
+    fn test_lookup_symbols_by_attribute() {
+        let mut graph = sample_graph();
+        graph
+            .get_node_mut("cmd/main.go:main")
+            .unwrap()
+            .metadata
+            .attributes = Some([("layer".to_string(), "cli".to_string())].into());
+
+        let query = SymbolQuery {
+            attributes: parse_attribute_filters(&["layer=cli".to_string()]).unwrap(),
+            limit: DEFAULT_LIMIT,
+            ..Default::default()
+        };
+        let ids: Vec<&str> = lookup_symbols(&graph, &query)
+            .iter()
+            .map(|n| n.id.as_str())
+            .collect();
+        assert_eq!(ids, vec!["cmd/main.go:main"]);
+
+        assert!(parse_attribute_filters(&["=cli".to_string()]).is_err());
+    }
+
+    #[test]
+    fn test_neighbors() {
+        let graph = sample_graph();
+
+        let all = neighbors(
+            &graph,
+            "api/server.go:Server:Handle",
+            EgoDirection::Both,
+            &[],
+        )
+        .unwrap();
+        let ends: Vec<(&str, &str)> = all.iter().map(|n| (n.source, n.target)).collect();
+        assert_eq!(
+            ends,
+            vec![
+                ("api/server.go:Server", "api/server.go:Server:Handle"),
+                ("cmd/main.go:main", "api/server.go:Server:Handle"),
+            ]
+        );
+
+        let uses = neighbors(
+            &graph,
+            "api/server.go:Server:Handle",
+            EgoDirection::Incoming,
+            &[EdgeType::Uses],
+        )
+        .unwrap();
+        assert_eq!(uses.len(), 1);
+        assert_eq!(uses[0].node.id, "cmd/main.go:main");
+        assert_eq!(uses[0].edge.ref_line, Some(2));
+
+        assert!(matches!(
+            neighbors(&graph, "missing", EgoDirection::Both, &[]),
+            Err(ServerError::NodeNotFound(_))
+        ));
+        assert!(parse_edge_types(&["calls".to_string()]).is_err());
+        assert_eq!(
+            parse_edge_selectors(&["calls".to_string()]).unwrap(),
+            vec![EdgeSelector::Calls]
+        );
+    }
+
+    #[test]
+    fn test_package_dependencies() {
+        let graph = sample_graph();
+
+        let outgoing = package_dependencies(&graph, "cmd", DependencyDirection::Outgoing).unwrap();
+        assert_eq!(outgoing.len(), 1);
+        assert_eq!(
+            (outgoing[0].to.as_str(), outgoing[0].references),
+            ("api", 1)
+        );
+
+        let incoming = package_dependencies(&graph, "api/", DependencyDirection::Incoming).unwrap();
+        assert_eq!(incoming[0].from, "cmd");
+        assert!(
+            package_dependencies(&graph, "api", DependencyDirection::Outgoing)
+                .unwrap()
+                .is_empty()
+        );
+
+        assert!(matches!(
+            package_dependencies(&graph, "web", DependencyDirection::Outgoing),
+            Err(ServerError::PackageNotFound(_))
+        ));
+    }
+
+    #[test]
+    fn test_package_doc() {
+        let mut graph = sample_graph();
+        graph.add_node(
+            Node::data(
+                "api/doc.go:package-doc".to_string(),
+                "api".to_string(),
+                DataKind::Documentation,
+                None,
+                "api/doc.go".to_string(),
+                1,
+                1,
+            )
+            .with_text("Package api serves the HTTP API.".to_string()),
+        );
+
+        let doc = package_doc(&graph, "api/").unwrap();
+        assert_eq!(
+            doc.text.as_deref(),
+            Some("Package api serves the HTTP API.")
+        );
+        assert!(matches!(
+            package_doc(&graph, "cmd"),
+            Err(ServerError::DocumentationNotFound(_))
+        ));
+        assert!(matches!(
+            package_doc(&graph, "web"),
+            Err(ServerError::PackageNotFound(_))
+        ));
+    }
+
+    #[test]
+    fn test_implementations() {
+        let mut graph = sample_graph();
+        graph.add_node(Node::container(
+            "api/handler.go:Handler".to_string(),
+            "Handler".to_string(),
+            ContainerKind::Type,
+            Some("interface".to_string()),
+            "api/handler.go".to_string(),
+            1,
+            3,
+        ));
+        graph.add_node(Node::callable(
+            "api/handler.go:Handler:Handle".to_string(),
+            "Handle".to_string(),
+            CallableKind::Method,
+            "api/handler.go".to_string(),
+            2,
+            2,
+        ));
+        for (parent, child) in [
+            ("api/handler.go:Handler", "api/handler.go:Handler:Handle"),
+            ("api/server.go:Server", "api/server.go:Server:Handle"),
+        ] {
+            graph.add_edge_from_struct(&Edge::contains(parent.to_string(), child.to_string()));
+        }
+
+        let implementers = implementations(&graph, "api/handler.go:Handler").unwrap();
+        assert_eq!(implementers[0].id, "api/server.go:Server");
+        let interfaces = implementations(&graph, "api/server.go:Server").unwrap();
+        assert_eq!(interfaces[0].id, "api/handler.go:Handler");
+        assert!(implementations(&graph, "cmd/main.go:main")
+            .unwrap()
+            .is_empty());
+    }
+
+    #[test]
+    fn test_paginate() {
+        let page_of = |offset, cursor, limit| {
+            let request = page_request(offset, cursor, Some(limit)).unwrap();
+            paginate((0..5).collect::<Vec<i32>>(), &request, i32::to_string)
+        };
+
+        let page = page_of(0, None, 2);
+        assert_eq!(page.items, vec![0, 1]);
+        assert_eq!((page.total, page.next_offset), (5, Some(2)));
+
+        let next = page_of(0, page.next_cursor.as_deref(), 2);
+        assert_eq!(next.items, vec![2, 3]);
+
+        let last = page_of(4, None, 2);
+        assert_eq!(last.items, vec![4]);
+        assert_eq!((last.next_offset, last.next_cursor), (None, None));
+
+        assert_eq!(page_of(0, None, 0).limit, 1);
+
+        assert!(matches!(
+            page_request(0, Some("not-a-cursor"), None),
+            Err(ServerError::InvalidParams(_))
+        ));
+    }
+}
@@ -0,0 +1,240 @@
+//! Graph Store
+//!
+//! Holds the graph shared by request handlers. The full graph is loaded on
+//! first use and reloaded when the index manifest changes, so a running server
+//! picks up `codeprysm update` without a restart. Handlers get an `Arc` of the
+//! current graph and keep using it even if a reload happens meanwhile.
+//!
+//! Callers limited to some paths (see [`crate::auth`]) get a view of the
+//! graph instead, computed once per token and graph reload.
+//!
+//! The store also holds the [`QueryCache`] of traversal query responses, and
+//! invalidates the packages a reload changed in it.
+//!
+//! With a [`LinkTemplate`], nodes of each loaded graph get the `url` of their
+//! source at the commit recorded in the index's provenance.
+
+use std::collections::HashMap;
+use std::path::{Path, PathBuf};
+use std::sync::{Arc, PoisonError, RwLock};
+use std::time::{Instant, SystemTime};
+
+use codeprysm_core::lazy::LazyGraphManager;
+use codeprysm_core::{attach_source_links, LinkTemplate, PetCodeGraph, Provenance};
+use tracing::info;
+
+use crate::auth::{Auth, Principal};
+use crate::cache::QueryCache;
+use crate::error::{Result, ServerError};
+use crate::metrics::Metrics;
+
+/// A loaded graph and the manifest modification time it was loaded at
+type Loaded = (Arc<PetCodeGraph>, Option<SystemTime>);
+
+/// Views of the graph by token name, with the graph each was computed from
+type Views = HashMap<String, (Arc<PetCodeGraph>, Arc<PetCodeGraph>)>;
+
+/// Graph shared by the services, reloaded when the index changes.
+pub struct GraphStore {
+    /// Index directory (None for a fixed in-memory graph)
+    prism_dir: Option<PathBuf>,
+    loaded: RwLock<Option<Loaded>>,
+    views: RwLock<Views>,
+    auth: Auth,
+    metrics: Metrics,
+    cache: QueryCache,
+    links: Option<LinkTemplate>,
+}
+
+impl GraphStore {
+    /// Open the index in a `.codeprysm` directory.
+    ///
+    /// The graph is loaded on the first query.
+    pub fn open(prism_dir: impl Into<PathBuf>) -> Result<Self> {
+        let prism_dir = prism_dir.into();
+        if !manifest_path(&prism_dir).exists() {
+            return Err(ServerError::IndexNotFound(prism_dir.display().to_string()));
+        }
+        Ok(Self {
+            prism_dir: Some(prism_dir),
+            loaded: RwLock::new(None),
+            views: RwLock::default(),
+            auth: Auth::disabled(),
+            metrics: Metrics::new(),
+            cache: QueryCache::default(),
+            links: None,
+        })
+    }
+
+    /// Serve a fixed in-memory graph (never reloaded).
+    pub fn from_graph(graph: PetCodeGraph) -> Self {
+        Self {
+            prism_dir: None,
+            loaded: RwLock::new(Some((Arc::new(graph), None))),
+            views: RwLock::default(),
+            auth: Auth::disabled(),
+            metrics: Metrics::new(),
+            cache: QueryCache::default(),
+            links: None,
+        }
+    }
+
+    /// Require the tokens of `auth` on every request.
+    pub fn with_auth(mut self, auth: Auth) -> Self {
+        self.auth = auth;
+        self
+    }
+
+    /// Cache query responses in `cache` (up to
+    /// [`crate::cache::DEFAULT_CACHE_ENTRIES`] by default).
+    pub fn with_cache(mut self, cache: QueryCache) -> Self {
+        self.cache = cache;
+        self
+    }
+
+    /// Link the nodes of every loaded graph to their source with `links`.
+    pub fn with_source_links(mut self, links: LinkTemplate) -> Self {
+        self.links = Some(links);
+        self
+    }
+
+    /// Get the tokens the services accept.
+    pub fn auth(&self) -> &Auth {
+        &self.auth
+    }
+
+    /// Get the index directory, if the store was opened from one.
+    pub fn prism_dir(&self) -> Option<&Path> {
+        self.prism_dir.as_deref()
+    }
+
+    /// Get the provenance of the index, if it was recorded.
+    ///
+    /// Read on every call, so it always describes the index on disk.
+    pub fn provenance(&self) -> Option<Provenance> {
+        self.prism_dir.as_deref().and_then(Provenance::load)
+    }
+
+    /// Get the metrics recorded by the services.
+    pub fn metrics(&self) -> &Metrics {
+        &self.metrics
+    }
+
+    /// Get the cache of query responses.
+    pub fn cache(&self) -> &QueryCache {
+        &self.cache
+    }
+
+    /// Get the graph loaded so far, without loading or reloading it.
+    pub fn loaded_graph(&self) -> Option<Arc<PetCodeGraph>> {
+        let loaded = self.loaded.read().unwrap_or_else(PoisonError::into_inner);
+        loaded.as_ref().map(|(graph, _)| Arc::clone(graph))
+    }
+
+    /// Render the metrics in the Prometheus text format.
+    pub fn render_metrics(&self) -> String {
+        self.metrics
+            .render(self.loaded_graph().as_deref(), self.prism_dir())
+    }
+
+    /// Get the current graph without blocking the async runtime while a
+    /// reload is in progress.
+    pub async fn load_graph(self: &Arc<Self>) -> Result<Arc<PetCodeGraph>> {
+        let store = Arc::clone(self);
+        tokio::task::spawn_blocking(move || store.graph())
+            .await
+            .map_err(|e| ServerError::GraphLoad(e.to_string()))?
+    }
+
+    /// Get the part of the current graph `principal` may see, without
+    /// blocking the async runtime.
+    pub async fn load_graph_for(
+        self: &Arc<Self>,
+        principal: &Arc<Principal>,
+    ) -> Result<Arc<PetCodeGraph>> {
+        let store = Arc::clone(self);
+        let principal = Arc::clone(principal);
+        tokio::task::spawn_blocking(move || store.graph_for(&principal))
+            .await
+            .map_err(|e| ServerError::GraphLoad(e.to_string()))?
+    }
+
+    /// Get the part of the current graph `principal` may see.
+    pub fn graph_for(&self, principal: &Principal) -> Result<Arc<PetCodeGraph>> {
+        let graph = self.graph()?;
+        if principal.sees_everything() {
+            return Ok(graph);
+        }
+        let views = self.views.read().unwrap_or_else(PoisonError::into_inner);
+        if let Some((base, view)) = views.get(&principal.name) {
+            if Arc::ptr_eq(base, &graph) {
+                return Ok(Arc::clone(view));
+            }
+        }
+        drop(views);
+
+        let view = Arc::new(principal.view(&graph));
+        self.views
+            .write()
+            .unwrap_or_else(PoisonError::into_inner)
+            .insert(principal.name.clone(), (graph, Arc::clone(&view)));
+        Ok(view)
+    }
+
+    /// Get the current graph, loading or reloading it if needed.
+    pub fn graph(&self) -> Result<Arc<PetCodeGraph>> {
+        let Some(ref prism_dir) = self.prism_dir else {
+            let loaded = self.loaded.read().unwrap_or_else(PoisonError::into_inner);
+            return Ok(Arc::clone(&loaded.as_ref().expect("fixed graph").0));
+        };
+        let modified = std::fs::metadata(manifest_path(prism_dir))
+            .and_then(|m| m.modified())
+            .ok();
+
+        if let Some(graph) = current(
+            &self.loaded.read().unwrap_or_else(PoisonError::into_inner),
+            modified,
+        ) {
+            return Ok(graph);
+        }
+
+        let mut loaded = self.loaded.write().unwrap_or_else(PoisonError::into_inner);
+        // Another request may have reloaded while we waited for the lock
+        if let Some(graph) = current(&loaded, modified) {
+            return Ok(graph);
+        }
+
+        let start = Instant::now();
+        let mut graph = LazyGraphManager::open(prism_dir)?.load_full_graph()?;
+        if let Some(ref links) = self.links {
+            // Links need the indexed commit; indexes outside git get none
+            if let Some(commit) = self.provenance().and_then(|p| p.commit) {
+                attach_source_links(&mut graph, links, &commit);
+            }
+        }
+        let graph = Arc::new(graph);
+        self.metrics.observe_graph_load(start.elapsed());
+        info!(
+            "Loaded graph from {} ({} nodes, {} edges)",
+            prism_dir.display(),
+            graph.node_count(),
+            graph.edge_count()
+        );
+        let evicted = self.cache.update_graph(&graph);
+        self.metrics.observe_cache_evictions(evicted);
+        *loaded = Some((Arc::clone(&graph), modified));
+        Ok(graph)
+    }
+}
+
+/// The loaded graph, if it is up to date with the manifest
+fn current(loaded: &Option<Loaded>, modified: Option<SystemTime>) -> Option<Arc<PetCodeGraph>> {
+    loaded
+        .as_ref()
+        .filter(|(_, loaded_at)| *loaded_at == modified)
+        .map(|(graph, _)| Arc::clone(graph))
+}
+
+fn manifest_path(prism_dir: &Path) -> PathBuf {
+    prism_dir.join("manifest.json")
+}
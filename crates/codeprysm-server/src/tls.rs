@@ -0,0 +1,146 @@
+//! TLS
+//!
+//! Serves the APIs over TLS, optionally requiring clients to present a
+//! certificate signed by a given CA (mutual TLS). Certificates and keys are
+//! PEM files; the gRPC API uses tonic's TLS support, and the HTTP APIs
+//! terminate TLS with rustls before handing connections to axum.
+
+use std::future::Future;
+use std::path::Path;
+use std::sync::Arc;
+
+use axum::Router;
+use hyper_util::rt::{TokioExecutor, TokioIo};
+use hyper_util::server::conn::auto;
+use hyper_util::service::TowerToHyperService;
+use tokio::net::TcpListener;
+use tokio_rustls::rustls::pki_types::{CertificateDer, PrivateKeyDer};
+use tokio_rustls::rustls::server::WebPkiClientVerifier;
+use tokio_rustls::rustls::{self, RootCertStore, ServerConfig};
+use tokio_rustls::TlsAcceptor;
+use tracing::debug;
+
+use crate::error::{Result, ServerError};
+
+/// Certificates and keys of a TLS server.
+#[derive(Debug, Clone)]
+pub struct TlsConfig {
+    /// PEM server certificate chain
+    cert: Vec<u8>,
+    /// PEM server private key
+    key: Vec<u8>,
+    /// PEM CAs client certificates must be signed by (no client
+    /// certificates if None)
+    client_ca: Option<Vec<u8>>,
+}
+
+impl TlsConfig {
+    /// Read the PEM files of a server certificate chain and private key, and
+    /// optionally of the CAs to verify client certificates against.
+    pub fn from_pem_files(cert: &Path, key: &Path, client_ca: Option<&Path>) -> Result<Self> {
+        let read = |path: &Path| {
+            std::fs::read(path)
+                .map_err(|e| ServerError::Tls(format!("Cannot read {}: {}", path.display(), e)))
+        };
+        Ok(Self {
+            cert: read(cert)?,
+            key: read(key)?,
+            client_ca: client_ca.map(read).transpose()?,
+        })
+    }
+
+    /// Check whether clients must present a certificate
+    pub fn requires_client_certificates(&self) -> bool {
+        self.client_ca.is_some()
+    }
+
+    /// tonic settings for the gRPC server
+    pub fn tonic_config(&self) -> tonic::transport::ServerTlsConfig {
+        let identity = tonic::transport::Identity::from_pem(&self.cert, &self.key);
+        let config = tonic::transport::ServerTlsConfig::new().identity(identity);
+        match self.client_ca {
+            Some(ref ca) => config.client_ca_root(tonic::transport::Certificate::from_pem(ca)),
+            None => config,
+        }
+    }
+
+    /// rustls settings for the HTTP servers, offering HTTP/2 and HTTP/1.1
+    pub fn rustls_config(&self) -> Result<Arc<ServerConfig>> {
+        let provider = Arc::new(rustls::crypto::ring::default_provider());
+        let certs = rustls_pemfile::certs(&mut self.cert.as_slice())
+            .collect::<std::result::Result<Vec<CertificateDer<'static>>, _>>()
+            .map_err(|e| ServerError::Tls(format!("Invalid certificate: {}", e)))?;
+        let key: PrivateKeyDer<'static> = rustls_pemfile::private_key(&mut self.key.as_slice())
+            .map_err(|e| ServerError::Tls(format!("Invalid private key: {}", e)))?
+            .ok_or_else(|| ServerError::Tls("No private key found".to_string()))?;
+
+        let builder = ServerConfig::builder_with_provider(Arc::clone(&provider))
+            .with_safe_default_protocol_versions()
+            .map_err(|e| ServerError::Tls(e.to_string()))?;
+        let builder = match self.client_ca {
+            Some(ref ca) => {
+                let mut roots = RootCertStore::empty();
+                for cert in rustls_pemfile::certs(&mut ca.as_slice()) {
+                    let cert =
+                        cert.map_err(|e| ServerError::Tls(format!("Invalid client CA: {}", e)))?;
+                    roots
+                        .add(cert)
+                        .map_err(|e| ServerError::Tls(format!("Invalid client CA: {}", e)))?;
+                }
+                let verifier =
+                    WebPkiClientVerifier::builder_with_provider(Arc::new(roots), provider)
+                        .build()
+                        .map_err(|e| ServerError::Tls(e.to_string()))?;
+                builder.with_client_cert_verifier(verifier)
+            }
+            None => builder.with_no_client_auth(),
+        };
+        let mut config = builder
+            .with_single_cert(certs, key)
+            .map_err(|e| ServerError::Tls(e.to_string()))?;
+        config.alpn_protocols = vec![b"h2".to_vec(), b"http/1.1".to_vec()];
+        Ok(Arc::new(config))
+    }
+}
+
+/// Serve `router` over TLS on `listener` until `shutdown` completes.
+///
+/// Connections still open at shutdown are closed with the runtime.
+pub(crate) async fn serve_router(
+    router: Router,
+    listener: TcpListener,
+    tls: &TlsConfig,
+    shutdown: impl Future<Output = ()> + Send + 'static,
+) -> Result<()> {
+    let acceptor = TlsAcceptor::from(tls.rustls_config()?);
+    tokio::pin!(shutdown);
+    loop {
+        let (stream, peer) = tokio::select! {
+            accepted = listener.accept() => match accepted {
+                Ok(accepted) => accepted,
+                Err(e) => {
+                    debug!("Failed to accept a connection: {}", e);
+                    continue;
+                }
+            },
+            _ = &mut shutdown => return Ok(()),
+        };
+        let acceptor = acceptor.clone();
+        let service = TowerToHyperService::new(router.clone());
+        tokio::spawn(async move {
+            let stream = match acceptor.accept(stream).await {
+                Ok(stream) => stream,
+                Err(e) => {
+                    debug!("TLS handshake with {} failed: {}", peer, e);
+                    return;
+                }
+            };
+            if let Err(e) = auto::Builder::new(TokioExecutor::new())
+                .serve_connection_with_upgrades(TokioIo::new(stream), service)
+                .await
+            {
+                debug!("Connection from {} failed: {}", peer, e);
+            }
+        });
+    }
+}
@@ -0,0 +1,57 @@
+//! Web UI
+//!
+//! A graph explorer served at `/` next to the REST API: browse packages,
+//! search symbols, and expand call and implementation neighborhoods in a
+//! force-directed view. The page is embedded in the binary and only talks to
+//! the REST endpoints (see [`crate::http`]), so it works offline and without a
+//! build step.
+//!
+//! The page itself needs no token; on servers with API tokens, open it as
+//! `/#token=<token>` and it sends the token with its API requests.
+
+use std::future::Future;
+use std::sync::Arc;
+
+use axum::response::Html;
+use axum::routing::get;
+use axum::Router;
+use tokio::net::TcpListener;
+
+use crate::error::{Result, ServerError};
+use crate::http;
+use crate::store::GraphStore;
+use crate::tls::{self, TlsConfig};
+
+/// The explorer page (HTML, CSS, and JavaScript in one file)
+const INDEX_HTML: &str = include_str!("../ui/index.html");
+
+/// Build the router serving the explorer at `/` and the REST API it uses.
+pub fn router(store: Arc<GraphStore>) -> Router {
+    http::router(store).route("/", get(index))
+}
+
+/// Serve the explorer on `listener` until `shutdown` completes.
+pub async fn serve_ui(
+    store: Arc<GraphStore>,
+    listener: TcpListener,
+    shutdown: impl Future<Output = ()> + Send + 'static,
+) -> Result<()> {
+    axum::serve(listener, router(store))
+        .with_graceful_shutdown(shutdown)
+        .await
+        .map_err(|e| ServerError::Transport(e.to_string()))
+}
+
+/// Serve the explorer over TLS on `listener` until `shutdown` completes.
+pub async fn serve_ui_tls(
+    store: Arc<GraphStore>,
+    listener: TcpListener,
+    tls: &TlsConfig,
+    shutdown: impl Future<Output = ()> + Send + 'static,
+) -> Result<()> {
+    tls::serve_router(router(store), listener, tls, shutdown).await
+}
+
+async fn index() -> Html<&'static str> {
+    Html(INDEX_HTML)
+}
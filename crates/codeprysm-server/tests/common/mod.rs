@@ -0,0 +1,82 @@
+//! Common test utilities for codeprysm-server integration tests.
+
+#![allow(dead_code)]
+
+use std::path::PathBuf;
+
+use codeprysm_core::lazy::partitioner::GraphPartitioner;
+use codeprysm_core::{CallableKind, ContainerKind, EdgeData, Node, PetCodeGraph};
+use codeprysm_server::{ApiToken, Auth, Scope};
+use tempfile::TempDir;
+
+/// A small graph: `cmd/main.go:main` calls `Server.Handle` in `api`.
+pub fn sample_graph() -> PetCodeGraph {
+    let mut graph = PetCodeGraph::new();
+    graph.add_node(Node::container(
+        "api/server.go:Server".to_string(),
+        "Server".to_string(),
+        ContainerKind::Type,
+        Some("struct".to_string()),
+        "api/server.go".to_string(),
+        3,
+        8,
+    ));
+    graph.add_node(Node::callable(
+        "api/server.go:Server:Handle".to_string(),
+        "Handle".to_string(),
+        CallableKind::Method,
+        "api/server.go".to_string(),
+        10,
+        15,
+    ));
+    graph.add_node(Node::callable(
+        "cmd/main.go:main".to_string(),
+        "main".to_string(),
+        CallableKind::Function,
+        "cmd/main.go".to_string(),
+        1,
+        6,
+    ));
+    graph.add_edge(
+        "api/server.go:Server",
+        "api/server.go:Server:Handle",
+        EdgeData::defines(),
+    );
+    graph.add_edge(
+        "cmd/main.go:main",
+        "api/server.go:Server:Handle",
+        EdgeData::uses(Some(2), Some("Handle".to_string())),
+    );
+    graph
+}
+
+/// Partition the sample graph into a temporary index.
+///
+/// Returns (temp_dir, prism_dir); keep the temp dir alive while serving.
+pub fn setup_index() -> (TempDir, PathBuf) {
+    let temp = TempDir::new().expect("Failed to create temp directory");
+    let prism_dir = temp.path().join(".codeprysm");
+    std::fs::create_dir_all(&prism_dir).expect("Failed to create prism directory");
+    GraphPartitioner::partition_with_stats(&sample_graph(), &prism_dir, Some("test"))
+        .expect("Failed to partition graph");
+    (temp, prism_dir)
+}
+
+/// Tokens for the sample index: `reader` sees only `api/`, `admin` sees all.
+pub fn sample_auth() -> Auth {
+    Auth::new(vec![
+        ApiToken {
+            name: "api-team".to_string(),
+            secret: "reader".to_string(),
+            scope: Scope::Read,
+            paths: vec!["api/**".to_string()],
+        },
+        ApiToken {
+            name: "ops".to_string(),
+            secret: "admin".to_string(),
+            scope: Scope::Admin,
+            paths: Vec::new(),
+        },
+    ])
+    .expect("Failed to build tokens")
+}
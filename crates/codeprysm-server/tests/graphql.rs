@@ -0,0 +1,140 @@
+//! Integration tests for the GraphQL API.
+//!
+//! Each test serves the sample index on an ephemeral port and posts queries to
+//! `/graphql`.
+
+mod common;
+
+use std::sync::Arc;
+
+use codeprysm_server::{serve_http, GraphStore};
+use serde_json::{json, Value};
+use tempfile::TempDir;
+use tokio::net::TcpListener;
+use tokio::sync::oneshot;
+
+/// Serve the sample graph; the server stops when the returned sender drops.
+async fn start_server() -> (TempDir, String, oneshot::Sender<()>) {
+    let (temp, prism_dir) = common::setup_index();
+    let store = Arc::new(GraphStore::open(&prism_dir).unwrap());
+    let listener = TcpListener::bind("127.0.0.1:0").await.unwrap();
+    let url = format!("http://{}/graphql", listener.local_addr().unwrap());
+    let (stop, stopped) = oneshot::channel::<()>();
+    tokio::spawn(serve_http(store, listener, async {
+        let _ = stopped.await;
+    }));
+
+    (temp, url, stop)
+}
+
+async fn post(url: &str, query: &str) -> Value {
+    reqwest::Client::new()
+        .post(url)
+        .json(&json!({ "query": query }))
+        .send()
+        .await
+        .unwrap()
+        .json()
+        .await
+        .unwrap()
+}
+
+#[tokio::test]
+async fn test_graphql_symbols() {
+    let (_temp, url, _stop) = start_server().await;
+
+    let response = post(
+        &url,
+        r#"{ symbols(types: ["Callable"], first: 1) {
+            totalCount
+            pageInfo { hasNextPage endCursor }
+            edges { node { id kind } }
+        } }"#,
+    )
+    .await;
+    let symbols = &response["data"]["symbols"];
+    assert_eq!(symbols["totalCount"], 2);
+    assert_eq!(symbols["pageInfo"]["hasNextPage"], true);
+    assert_eq!(
+        symbols["edges"][0]["node"]["id"],
+        "api/server.go:Server:Handle"
+    );
+
+    let cursor = symbols["pageInfo"]["endCursor"].as_str().unwrap();
+    let response = post(
+        &url,
+        &format!(
+            r#"{{ symbols(types: ["Callable"], after: "{}") {{ edges {{ node {{ id }} }} }} }}"#,
+            cursor
+        ),
+    )
+    .await;
+    assert_eq!(
+        response["data"]["symbols"]["edges"][0]["node"]["id"],
+        "cmd/main.go:main"
+    );
+
+    let response = post(&url, r#"{ symbol(id: "api/missing.go:X") { id } }"#).await;
+    assert_eq!(response["data"]["symbol"], Value::Null);
+}
+
+#[tokio::test]
+async fn test_graphql_nested_traversal() {
+    let (_temp, url, _stop) = start_server().await;
+
+    // main -> Handle <- Server
+    let response = post(
+        &url,
+        r#"{ symbol(id: "cmd/main.go:main") {
+            edges(direction: OUTGOING, types: ["USES"]) {
+                edges {
+                    edgeType
+                    refLine
+                    node {
+                        name
+                        edges(direction: INCOMING, types: ["DEFINES"]) {
+                            edges { node { id } }
+                        }
+                    }
+                }
+            }
+        } }"#,
+    )
+    .await;
+    let call = &response["data"]["symbol"]["edges"]["edges"][0];
+    assert_eq!(call["edgeType"], "USES");
+    assert_eq!(call["refLine"], 2);
+    assert_eq!(call["node"]["name"], "Handle");
+    assert_eq!(
+        call["node"]["edges"]["edges"][0]["node"]["id"],
+        "api/server.go:Server"
+    );
+}
+
+#[tokio::test]
+async fn test_graphql_errors() {
+    let (_temp, url, _stop) = start_server().await;
+
+    let response = post(
+        &url,
+        r#"{ symbol(id: "cmd/main.go:main") { edges(types: ["CALLS"]) { totalCount } } }"#,
+    )
+    .await;
+    assert!(response["errors"][0]["message"]
+        .as_str()
+        .unwrap()
+        .contains("CALLS"));
+
+    // Nine hops exceed the depth limit
+    let hop = "edges { edges { node { id ";
+    let query = format!(
+        "{{ symbol(id: \"cmd/main.go:main\") {{ {}{} }} }}",
+        hop.repeat(9),
+        "} } } ".repeat(9)
+    );
+    let response = post(&url, &query).await;
+    assert!(response["errors"][0]["message"]
+        .as_str()
+        .unwrap()
+        .contains("nested too deep"));
+}
@@ -0,0 +1,222 @@
+//! Integration tests for the gRPC service.
+//!
+//! Each test serves the sample index on an ephemeral port and queries it
+//! through the generated client.
+
+mod common;
+
+use std::net::SocketAddr;
+use std::sync::Arc;
+
+use codeprysm_server::proto::graph_service_client::GraphServiceClient;
+use codeprysm_server::proto::{
+    Direction, GetEdgesRequest, GetSubgraphRequest, GetSymbolRequest, LookupSymbolsRequest,
+};
+use codeprysm_server::{serve_grpc, GraphStore, ServerError};
+use tempfile::TempDir;
+use tokio::net::TcpListener;
+use tokio::sync::oneshot;
+
+/// Serve the sample graph; the server stops when the returned sender drops.
+async fn start_server() -> (TempDir, SocketAddr, oneshot::Sender<()>) {
+    let (temp, prism_dir) = common::setup_index();
+    let store = Arc::new(GraphStore::open(&prism_dir).unwrap());
+    let listener = TcpListener::bind("127.0.0.1:0").await.unwrap();
+    let addr = listener.local_addr().unwrap();
+    let (stop, stopped) = oneshot::channel::<()>();
+    tokio::spawn(serve_grpc(store, listener, async {
+        let _ = stopped.await;
+    }));
+
+    (temp, addr, stop)
+}
+
+/// Serve the sample graph behind the sample tokens.
+async fn start_secured_server() -> (TempDir, SocketAddr, oneshot::Sender<()>) {
+    let (temp, prism_dir) = common::setup_index();
+    let store = GraphStore::open(&prism_dir)
+        .unwrap()
+        .with_auth(common::sample_auth());
+    let listener = TcpListener::bind("127.0.0.1:0").await.unwrap();
+    let addr = listener.local_addr().unwrap();
+    let (stop, stopped) = oneshot::channel::<()>();
+    tokio::spawn(serve_grpc(Arc::new(store), listener, async {
+        let _ = stopped.await;
+    }));
+
+    (temp, addr, stop)
+}
+
+/// A request carrying `token`
+fn with_token<T>(message: T, token: &str) -> tonic::Request<T> {
+    let mut request = tonic::Request::new(message);
+    request.metadata_mut().insert(
+        "authorization",
+        format!("Bearer {}", token).parse().unwrap(),
+    );
+    request
+}
+
+async fn connect(addr: SocketAddr) -> GraphServiceClient<tonic::transport::Channel> {
+    GraphServiceClient::connect(format!("http://{}", addr))
+        .await
+        .unwrap()
+}
+
+#[test]
+fn test_open_without_index() {
+    let temp = TempDir::new().unwrap();
+    assert!(matches!(
+        GraphStore::open(temp.path()),
+        Err(ServerError::IndexNotFound(_))
+    ));
+}
+
+#[tokio::test]
+async fn test_grpc_symbol_lookup() {
+    let (_temp, addr, _stop) = start_server().await;
+    let mut client = connect(addr).await;
+
+    let symbol = client
+        .get_symbol(GetSymbolRequest {
+            id: "api/server.go:Server:Handle".to_string(),
+        })
+        .await
+        .unwrap()
+        .into_inner();
+    assert_eq!(symbol.name, "Handle");
+    assert_eq!(symbol.node_type, "Callable");
+    assert_eq!((symbol.line, symbol.end_line), (10, 15));
+
+    let missing = client
+        .get_symbol(GetSymbolRequest {
+            id: "missing".to_string(),
+        })
+        .await
+        .unwrap_err();
+    assert_eq!(missing.code(), tonic::Code::NotFound);
+
+    let found = client
+        .lookup_symbols(LookupSymbolsRequest {
+            query: "srv".to_string(),
+            fuzzy: true,
+            node_types: vec!["type".to_string()],
+            limit: 0,
+            attributes: vec![],
+            page_token: String::new(),
+        })
+        .await
+        .unwrap()
+        .into_inner();
+    let ids: Vec<&str> = found.symbols.iter().map(|s| s.id.as_str()).collect();
+    assert_eq!(ids, vec!["api/server.go:Server"]);
+
+    // Page through the callables one at a time
+    let mut ids = Vec::new();
+    let mut page_token = String::new();
+    loop {
+        let page = client
+            .lookup_symbols(LookupSymbolsRequest {
+                node_types: vec!["Callable".to_string()],
+                limit: 1,
+                page_token,
+                ..Default::default()
+            })
+            .await
+            .unwrap()
+            .into_inner();
+        assert_eq!(page.total_count, 2);
+        ids.extend(page.symbols.into_iter().map(|s| s.id));
+        if page.next_page_token.is_empty() {
+            break;
+        }
+        page_token = page.next_page_token;
+    }
+    assert_eq!(ids, vec!["api/server.go:Server:Handle", "cmd/main.go:main"]);
+}
+
+#[tokio::test]
+async fn test_grpc_edges_and_subgraph() {
+    let (_temp, addr, _stop) = start_server().await;
+    let mut client = connect(addr).await;
+
+    let edges = client
+        .get_edges(GetEdgesRequest {
+            id: "api/server.go:Server:Handle".to_string(),
+            direction: Direction::Incoming as i32,
+            edge_types: vec!["USES".to_string()],
+            ..Default::default()
+        })
+        .await
+        .unwrap()
+        .into_inner();
+    assert_eq!(edges.neighbors.len(), 1);
+    assert!(edges.next_page_token.is_empty());
+    let caller = &edges.neighbors[0];
+    assert_eq!(caller.symbol.as_ref().unwrap().id, "cmd/main.go:main");
+    assert_eq!(caller.edge.as_ref().unwrap().ref_line, Some(2));
+
+    let invalid = client
+        .get_edges(GetEdgesRequest {
+            id: "api/server.go:Server:Handle".to_string(),
+            direction: Direction::Both as i32,
+            edge_types: vec!["calls".to_string()],
+            ..Default::default()
+        })
+        .await
+        .unwrap_err();
+    assert_eq!(invalid.code(), tonic::Code::InvalidArgument);
+
+    let subgraph = client
+        .get_subgraph(GetSubgraphRequest {
+            id: "cmd/main.go:main".to_string(),
+            radius: 2,
+            format: "mermaid".to_string(),
+            ..Default::default()
+        })
+        .await
+        .unwrap()
+        .into_inner();
+    let ids: Vec<&str> = subgraph.nodes.iter().map(|n| n.id.as_str()).collect();
+    assert_eq!(
+        ids,
+        vec![
+            "api/server.go:Server",
+            "api/server.go:Server:Handle",
+            "cmd/main.go:main"
+        ]
+    );
+    assert_eq!(subgraph.edges.len(), 2);
+    assert!(subgraph.rendered.starts_with("flowchart"));
+}
+
+#[tokio::test]
+async fn test_grpc_auth() {
+    let (_temp, addr, _stop) = start_secured_server().await;
+    let mut client = connect(addr).await;
+    let request = |id: &str| GetSymbolRequest { id: id.to_string() };
+
+    let error = client
+        .get_symbol(request("api/server.go:Server"))
+        .await
+        .unwrap_err();
+    assert_eq!(error.code(), tonic::Code::Unauthenticated);
+
+    let symbol = client
+        .get_symbol(with_token(request("api/server.go:Server"), "reader"))
+        .await
+        .unwrap()
+        .into_inner();
+    assert_eq!(symbol.name, "Server");
+
+    // Files outside the token's paths do not exist for it
+    let hidden = client
+        .get_symbol(with_token(request("cmd/main.go:main"), "reader"))
+        .await
+        .unwrap_err();
+    assert_eq!(hidden.code(), tonic::Code::NotFound);
+    client
+        .get_symbol(with_token(request("cmd/main.go:main"), "admin"))
+        .await
+        .unwrap();
+}
@@ -0,0 +1,454 @@
+//! Integration tests for the REST API.
+//!
+//! Each test serves the sample index on an ephemeral port and queries it over
+//! HTTP.
+
+mod common;
+
+use std::sync::Arc;
+
+use codeprysm_core::lazy::partitioner::GraphPartitioner;
+use codeprysm_core::{
+    CallableKind, DataKind, EdgeData, LinkTemplate, Node, Provenance, GRAPH_SCHEMA_VERSION,
+};
+use codeprysm_server::{serve_http, serve_ui, GraphStore};
+use reqwest::StatusCode;
+use serde_json::Value;
+use tempfile::TempDir;
+use tokio::net::TcpListener;
+use tokio::sync::oneshot;
+
+/// Serve the sample graph; the server stops when the returned sender drops.
+async fn start_server() -> (TempDir, String, oneshot::Sender<()>) {
+    let (temp, prism_dir) = common::setup_index();
+    let store = Arc::new(GraphStore::open(&prism_dir).unwrap());
+    let listener = TcpListener::bind("127.0.0.1:0").await.unwrap();
+    let base = format!("http://{}", listener.local_addr().unwrap());
+    let (stop, stopped) = oneshot::channel::<()>();
+    tokio::spawn(serve_http(store, listener, async {
+        let _ = stopped.await;
+    }));
+
+    (temp, base, stop)
+}
+
+/// Serve the sample graph behind the sample tokens.
+async fn start_secured_server() -> (TempDir, String, oneshot::Sender<()>) {
+    let (temp, prism_dir) = common::setup_index();
+    let store = GraphStore::open(&prism_dir)
+        .unwrap()
+        .with_auth(common::sample_auth());
+    let listener = TcpListener::bind("127.0.0.1:0").await.unwrap();
+    let base = format!("http://{}", listener.local_addr().unwrap());
+    let (stop, stopped) = oneshot::channel::<()>();
+    tokio::spawn(serve_http(Arc::new(store), listener, async {
+        let _ = stopped.await;
+    }));
+
+    (temp, base, stop)
+}
+
+async fn get(url: &str) -> (StatusCode, Value) {
+    let response = reqwest::get(url).await.unwrap();
+    let status = response.status();
+    (status, response.json().await.unwrap())
+}
+
+#[tokio::test]
+async fn test_http_symbols() {
+    let (_temp, base, _stop) = start_server().await;
+
+    let (status, page) = get(&format!("{}/symbols?type=Callable&limit=1", base)).await;
+    assert_eq!(status, StatusCode::OK);
+    assert_eq!(page["total"], 2);
+    assert_eq!(page["items"][0]["id"], "api/server.go:Server:Handle");
+    assert_eq!(page["next_offset"], 1);
+
+    let cursor = page["next_cursor"].as_str().unwrap();
+    let (_, page) = get(&format!(
+        "{}/symbols?type=Callable&limit=1&cursor={}",
+        base, cursor
+    ))
+    .await;
+    assert_eq!(page["items"][0]["id"], "cmd/main.go:main");
+    assert!(page.get("next_cursor").is_none());
+
+    let (status, _) = get(&format!("{}/symbols?cursor=bogus", base)).await;
+    assert_eq!(status, StatusCode::BAD_REQUEST);
+
+    let (_, page) = get(&format!("{}/symbols?q=main&file=cmd/", base)).await;
+    assert_eq!(page["items"][0]["id"], "cmd/main.go:main");
+    assert!(page.get("next_offset").is_none());
+
+    let (status, symbol) = get(&format!("{}/symbols/api/server.go:Server", base)).await;
+    assert_eq!(status, StatusCode::OK);
+    assert_eq!(symbol["name"], "Server");
+
+    let (status, error) = get(&format!("{}/symbols/api/missing.go:X", base)).await;
+    assert_eq!(status, StatusCode::NOT_FOUND);
+    assert!(error["error"].as_str().unwrap().contains("missing.go:X"));
+}
+
+#[tokio::test]
+async fn test_http_callers_and_subgraph() {
+    let (_temp, base, _stop) = start_server().await;
+
+    let (status, callers) = get(&format!(
+        "{}/symbols/api/server.go:Server:Handle/callers",
+        base
+    ))
+    .await;
+    assert_eq!(status, StatusCode::OK);
+    assert_eq!(callers["total"], 1);
+    assert_eq!(callers["items"][0]["symbol"]["id"], "cmd/main.go:main");
+    assert_eq!(callers["items"][0]["lines"], serde_json::json!([2]));
+
+    let (status, hierarchy) = get(&format!(
+        "{}/symbols/api/server.go:Server:Handle/hierarchy",
+        base
+    ))
+    .await;
+    assert_eq!(status, StatusCode::OK);
+    assert_eq!(
+        hierarchy["item"]["data"]["id"],
+        "api/server.go:Server:Handle"
+    );
+    assert_eq!(hierarchy["item"]["kind"], 6);
+    let call = &hierarchy["calls"][0];
+    assert_eq!(call["from"]["detail"], "cmd/main.go:main");
+    assert_eq!(call["fromRanges"][0]["start"]["line"], 1);
+
+    let (status, _) = get(&format!(
+        "{}/symbols/cmd/main.go:main/hierarchy?direction=sideways",
+        base
+    ))
+    .await;
+    assert_eq!(status, StatusCode::BAD_REQUEST);
+
+    let (status, subgraph) = get(&format!(
+        "{}/symbols/cmd/main.go:main/subgraph?radius=1&edges=USES",
+        base
+    ))
+    .await;
+    assert_eq!(status, StatusCode::OK);
+    assert_eq!(subgraph["nodes"].as_array().unwrap().len(), 2);
+
+    // Neither sample function is declared public
+    let (status, subgraph) = get(&format!(
+        "{}/symbols/cmd/main.go:main/subgraph?radius=1&only_exported=true",
+        base
+    ))
+    .await;
+    assert_eq!(status, StatusCode::OK);
+    assert!(subgraph["nodes"].as_array().unwrap().is_empty());
+
+    let response = reqwest::get(format!(
+        "{}/symbols/cmd/main.go:main/subgraph?format=dot",
+        base
+    ))
+    .await
+    .unwrap();
+    assert_eq!(response.headers()["content-type"], "text/vnd.graphviz");
+    assert!(response.text().await.unwrap().starts_with("digraph"));
+
+    let (status, _) = get(&format!(
+        "{}/symbols/cmd/main.go:main/subgraph?direction=sideways",
+        base
+    ))
+    .await;
+    assert_eq!(status, StatusCode::BAD_REQUEST);
+}
+
+#[tokio::test]
+async fn test_http_package_dependencies() {
+    let (_temp, base, _stop) = start_server().await;
+
+    let (status, page) = get(&format!("{}/packages/cmd/dependencies", base)).await;
+    assert_eq!(status, StatusCode::OK);
+    assert_eq!(page["items"][0]["to"], "api");
+    assert_eq!(page["items"][0]["references"], 1);
+
+    let (_, page) = get(&format!(
+        "{}/packages/api/dependencies?direction=incoming",
+        base
+    ))
+    .await;
+    assert_eq!(page["items"][0]["from"], "cmd");
+
+    let (status, _) = get(&format!("{}/packages/web/dependencies", base)).await;
+    assert_eq!(status, StatusCode::NOT_FOUND);
+
+    let (_, packages) = get(&format!("{}/packages", base)).await;
+    assert_eq!(packages["total"], 2);
+}
+
+#[tokio::test]
+async fn test_http_package_doc() {
+    let (temp, base, _stop) = start_server().await;
+    let doc = format!("{}/packages/api/doc", base);
+
+    let (status, _) = get(&doc).await;
+    assert_eq!(status, StatusCode::NOT_FOUND);
+
+    let mut graph = common::sample_graph();
+    graph.add_node(
+        Node::data(
+            "api/server.go:package-doc".to_string(),
+            "api".to_string(),
+            DataKind::Documentation,
+            None,
+            "api/server.go".to_string(),
+            1,
+            2,
+        )
+        .with_text("Package api serves the HTTP API.".to_string()),
+    );
+    GraphPartitioner::partition_with_stats(&graph, &temp.path().join(".codeprysm"), Some("test"))
+        .unwrap();
+
+    let (status, node) = get(&doc).await;
+    assert_eq!(status, StatusCode::OK);
+    assert_eq!(node["kind"], "documentation");
+    assert_eq!(node["text"], "Package api serves the HTTP API.");
+
+    let (status, _) = get(&format!("{}/packages/web/doc", base)).await;
+    assert_eq!(status, StatusCode::NOT_FOUND);
+}
+
+#[tokio::test]
+async fn test_http_query() {
+    let (_temp, base, _stop) = start_server().await;
+    let client = reqwest::Client::new();
+    let post = |query: &str| {
+        client
+            .post(format!("{}/query", base))
+            .json(&serde_json::json!({ "query": query }))
+            .send()
+    };
+
+    let response = post(r#"MATCH (f:Function)-[:CALLS]->(g {name: "Handle"}) RETURN f.id"#)
+        .await
+        .unwrap();
+    assert_eq!(response.status(), StatusCode::OK);
+    let result: Value = response.json().await.unwrap();
+    assert_eq!(result["columns"], serde_json::json!(["f.id"]));
+    assert_eq!(result["rows"], serde_json::json!([["cmd/main.go:main"]]));
+
+    let response = post("MATCH (f RETURN f").await.unwrap();
+    assert_eq!(response.status(), StatusCode::BAD_REQUEST);
+    let error: Value = response.json().await.unwrap();
+    assert!(error["error"].as_str().unwrap().contains("Syntax error"));
+
+    // A cross product of 16 node patterns rejected only by its filter stops
+    // at the step budget
+    let patterns: Vec<String> = (0..16).map(|i| format!("(n{})", i)).collect();
+    let query = format!(
+        r#"MATCH {} WHERE n0.name = "nope" RETURN n0"#,
+        patterns.join(", ")
+    );
+    let response = post(&query).await.unwrap();
+    assert_eq!(response.status(), StatusCode::BAD_REQUEST);
+    let error: Value = response.json().await.unwrap();
+    assert!(error["error"].as_str().unwrap().contains("nodes and edges"));
+}
+
+#[tokio::test]
+async fn test_http_provenance() {
+    let (temp, base, _stop) = start_server().await;
+
+    let (status, _) = get(&format!("{}/provenance", base)).await;
+    assert_eq!(status, StatusCode::NOT_FOUND);
+
+    Provenance::collect(temp.path())
+        .save(&temp.path().join(".codeprysm"))
+        .unwrap();
+    let (status, provenance) = get(&format!("{}/provenance", base)).await;
+    assert_eq!(status, StatusCode::OK);
+    assert_eq!(provenance["schema_version"], GRAPH_SCHEMA_VERSION);
+
+    let response = reqwest::get(format!(
+        "{}/symbols/cmd/main.go:main/subgraph?format=dot",
+        base
+    ))
+    .await
+    .unwrap();
+    assert!(response.text().await.unwrap().contains("// tool_version: "));
+}
+
+#[tokio::test]
+async fn test_http_source_links() {
+    let (temp, prism_dir) = common::setup_index();
+    let mut provenance = Provenance::collect(temp.path());
+    provenance.commit = Some("abc123".to_string());
+    provenance.save(&prism_dir).unwrap();
+    let links = LinkTemplate::parse("github", Some("git@github.com:org/repo.git")).unwrap();
+    let store = GraphStore::open(&prism_dir)
+        .unwrap()
+        .with_source_links(links);
+    let listener = TcpListener::bind("127.0.0.1:0").await.unwrap();
+    let base = format!("http://{}", listener.local_addr().unwrap());
+    let (_stop, stopped) = oneshot::channel::<()>();
+    tokio::spawn(serve_http(Arc::new(store), listener, async {
+        let _ = stopped.await;
+    }));
+
+    let (status, symbol) = get(&format!("{}/symbols/cmd/main.go:main", base)).await;
+    assert_eq!(status, StatusCode::OK);
+    assert_eq!(
+        symbol["metadata"]["url"],
+        "https://github.com/org/repo/blob/abc123/cmd/main.go#L1-L6"
+    );
+}
+
+#[tokio::test]
+async fn test_http_metrics() {
+    let (_temp, base, _stop) = start_server().await;
+
+    let (status, _) = get(&format!("{}/symbols?q=main", base)).await;
+    assert_eq!(status, StatusCode::OK);
+    let (status, _) = get(&format!("{}/symbols/api/missing.go:X", base)).await;
+    assert_eq!(status, StatusCode::NOT_FOUND);
+
+    let response = reqwest::get(format!("{}/metrics", base)).await.unwrap();
+    assert_eq!(response.status(), StatusCode::OK);
+    assert!(response.headers()["content-type"]
+        .to_str()
+        .unwrap()
+        .starts_with("text/plain"));
+    let body = response.text().await.unwrap();
+    assert!(body.contains(
+        "codeprysm_request_duration_seconds_count{api=\"http\",endpoint=\"/symbols\"} 1\n"
+    ));
+    assert!(body
+        .contains("codeprysm_request_errors_total{api=\"http\",endpoint=\"/symbols/*path\"} 1\n"));
+    assert!(body.contains("codeprysm_graph_load_duration_seconds_count 1\n"));
+    assert!(body.contains("codeprysm_index_nodes "));
+    assert!(body.contains("codeprysm_index_size_bytes "));
+    assert!(!body.contains("endpoint=\"/metrics\""));
+}
+
+#[tokio::test]
+async fn test_http_query_cache() {
+    let (temp, base, _stop) = start_server().await;
+    let callers = format!("{}/symbols/api/server.go:Server:Handle/callers", base);
+    let dependencies = format!("{}/packages/cmd/dependencies", base);
+
+    let (_, first) = get(&callers).await;
+    let (_, second) = get(&callers).await;
+    assert_eq!(first, second);
+    let (status, _) = get(&dependencies).await;
+    assert_eq!(status, StatusCode::OK);
+
+    // A new caller in another package changes `api`, but not `cmd`
+    let mut graph = common::sample_graph();
+    graph.add_node(Node::callable(
+        "web/app.go:serve".to_string(),
+        "serve".to_string(),
+        CallableKind::Function,
+        "web/app.go".to_string(),
+        1,
+        4,
+    ));
+    graph.add_edge(
+        "web/app.go:serve",
+        "api/server.go:Server:Handle",
+        EdgeData::uses(Some(3), Some("Handle".to_string())),
+    );
+    GraphPartitioner::partition_with_stats(&graph, &temp.path().join(".codeprysm"), Some("test"))
+        .unwrap();
+
+    let (_, updated) = get(&callers).await;
+    assert_eq!(updated["total"], 2);
+    let (status, _) = get(&dependencies).await;
+    assert_eq!(status, StatusCode::OK);
+
+    let metrics = reqwest::get(format!("{}/metrics", base))
+        .await
+        .unwrap()
+        .text()
+        .await
+        .unwrap();
+    assert!(metrics.contains("codeprysm_query_cache_hits_total 2\n"));
+    assert!(metrics.contains("codeprysm_query_cache_misses_total 2\n"));
+    assert!(metrics.contains("codeprysm_query_cache_evictions_total 1\n"));
+}
+
+#[tokio::test]
+async fn test_http_auth() {
+    let (_temp, base, _stop) = start_secured_server().await;
+    let client = reqwest::Client::new();
+    let get_with = |path: &str, token: &str| {
+        client
+            .get(format!("{}{}", base, path))
+            .bearer_auth(token)
+            .send()
+    };
+
+    let (status, _) = get(&format!("{}/symbols", base)).await;
+    assert_eq!(status, StatusCode::UNAUTHORIZED);
+    let response = get_with("/symbols", "wrong").await.unwrap();
+    assert_eq!(response.status(), StatusCode::UNAUTHORIZED);
+    assert_eq!(response.headers()["www-authenticate"], "Bearer");
+
+    // The reader only sees api/
+    let page: Value = get_with("/symbols", "reader")
+        .await
+        .unwrap()
+        .json()
+        .await
+        .unwrap();
+    assert_eq!(page["total"], 2);
+    let response = get_with("/symbols/cmd/main.go:main", "reader")
+        .await
+        .unwrap();
+    assert_eq!(response.status(), StatusCode::NOT_FOUND);
+    let callers: Value = get_with("/symbols/api/server.go:Server:Handle/callers", "reader")
+        .await
+        .unwrap()
+        .json()
+        .await
+        .unwrap();
+    assert_eq!(callers["total"], 0);
+    let response = get_with("/metrics", "reader").await.unwrap();
+    assert_eq!(response.status(), StatusCode::FORBIDDEN);
+
+    // The admin sees everything, including metrics
+    let page: Value = get_with("/symbols", "admin")
+        .await
+        .unwrap()
+        .json()
+        .await
+        .unwrap();
+    assert_eq!(page["total"], 3);
+    let response = get_with("/metrics", "admin").await.unwrap();
+    assert_eq!(response.status(), StatusCode::OK);
+}
+
+#[tokio::test]
+async fn test_http_ui() {
+    let (_temp, prism_dir) = common::setup_index();
+    let store = Arc::new(GraphStore::open(&prism_dir).unwrap());
+    let listener = TcpListener::bind("127.0.0.1:0").await.unwrap();
+    let base = format!("http://{}", listener.local_addr().unwrap());
+    let (_stop, stopped) = oneshot::channel::<()>();
+    tokio::spawn(serve_ui(store, listener, async {
+        let _ = stopped.await;
+    }));
+
+    let response = reqwest::get(format!("{}/", base)).await.unwrap();
+    assert_eq!(response.status(), StatusCode::OK);
+    assert!(response
+        .text()
+        .await
+        .unwrap()
+        .contains("CodePrysm Explorer"));
+
+    // The REST API the page uses is served alongside it
+    let (status, page) = get(&format!(
+        "{}/symbols/api/server.go:Server/implementations",
+        base
+    ))
+    .await;
+    assert_eq!(status, StatusCode::OK);
+    assert_eq!(page["total"], 0);
+}
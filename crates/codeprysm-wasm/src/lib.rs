@@ -0,0 +1,132 @@
+//! CodePrysm for WebAssembly
+//!
+//! Generates code graphs client-side, in browser tools and VS Code for the
+//! Web, without native binaries. Sources are passed in memory, so nothing
+//! touches a filesystem:
+//!
+//! ```js
+//! import init, { buildGraph, parseFile, supportedLanguages } from "codeprysm-wasm";
+//!
+//! await init();
+//! const graph = JSON.parse(buildGraph("my-repo", {
+//!   "src/util.py": "def helper():\n    return 1\n",
+//!   "src/main.py": "from util import helper\n\ndef run():\n    return helper()\n",
+//! }));
+//! console.log(graph.nodes.length, graph.edges.length);
+//!
+//! const mermaid = parseFile("src/main.py", source, "mermaid");
+//! ```
+//!
+//! Graphs are returned as strings in any export format (`json` by default,
+//! `dot`, `mermaid`, `graphml`); JSON uses the same schema as
+//! `codeprysm graph export --format json`.
+
+use std::collections::BTreeMap;
+use std::path::Path;
+
+use codeprysm_core::{
+    export_graph, supported_languages, ExportFormat, GraphBuilder, SupportedLanguage,
+};
+use wasm_bindgen::prelude::*;
+
+/// Build a code graph from `files`, an object mapping relative paths to
+/// source text.
+///
+/// References are resolved across all the files, as in `codeprysm init`.
+/// Files in unsupported languages are skipped.
+#[wasm_bindgen(js_name = buildGraph)]
+pub fn build_graph(name: &str, files: JsValue, format: Option<String>) -> Result<String, JsError> {
+    let files: BTreeMap<String, String> = serde_wasm_bindgen::from_value(files)?;
+    build(name, &files, format.as_deref()).map_err(|e| JsError::new(&e))
+}
+
+/// Extract the entities of a single file.
+///
+/// The graph holds the file and its entities but no USES edges, since those
+/// need the definitions in other files.
+#[wasm_bindgen(js_name = parseFile)]
+pub fn parse_file(path: &str, source: &str, format: Option<String>) -> Result<String, JsError> {
+    parse(path, source, format.as_deref()).map_err(|e| JsError::new(&e))
+}
+
+/// Names of the languages the analyzer can parse.
+#[wasm_bindgen(js_name = supportedLanguages)]
+pub fn supported_language_names() -> Vec<String> {
+    let mut names: Vec<String> = supported_languages()
+        .iter()
+        .map(|language| language.to_string())
+        .collect();
+    // TSX shares TypeScript's name
+    names.dedup();
+    names
+}
+
+/// Whether `path` is in a supported language, judging by its extension.
+#[wasm_bindgen(js_name = isSupported)]
+pub fn is_supported(path: &str) -> bool {
+    SupportedLanguage::from_path(Path::new(path)).is_some()
+}
+
+fn build(
+    name: &str,
+    files: &BTreeMap<String, String>,
+    format: Option<&str>,
+) -> Result<String, String> {
+    let format = parse_format(format)?;
+    let graph = GraphBuilder::new_with_embedded_queries().build_from_sources(name, files);
+    Ok(export_graph(&graph, format, None))
+}
+
+fn parse(path: &str, source: &str, format: Option<&str>) -> Result<String, String> {
+    let format = parse_format(format)?;
+    let (graph, _) = GraphBuilder::new_with_embedded_queries()
+        .parse_source(path, source)
+        .map_err(|e| e.to_string())?;
+    Ok(export_graph(&graph, format, None))
+}
+
+fn parse_format(format: Option<&str>) -> Result<ExportFormat, String> {
+    format.map_or(Ok(ExportFormat::Json), str::parse)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_build() {
+        let files = BTreeMap::from([
+            (
+                "util.py".to_string(),
+                "def helper():\n    return 1\n".to_string(),
+            ),
+            (
+                "main.py".to_string(),
+                "from util import helper\n\ndef run():\n    return helper()\n".to_string(),
+            ),
+        ]);
+
+        let json: serde_json::Value =
+            serde_json::from_str(&build("demo", &files, None).unwrap()).unwrap();
+        let edges = json["edges"].as_array().unwrap();
+        assert!(edges.iter().any(|e| e["type"] == "USES"));
+
+        let dot = build("demo", &files, Some("dot")).unwrap();
+        assert!(dot.starts_with("digraph"));
+        assert!(build("demo", &files, Some("svg")).is_err());
+    }
+
+    #[test]
+    fn test_parse() {
+        let json = parse("lib.rs", "pub fn answer() -> u32 { 42 }\n", None).unwrap();
+        assert!(json.contains("answer"));
+        assert!(parse("notes.txt", "hello", None).is_err());
+    }
+
+    #[test]
+    fn test_is_supported() {
+        assert!(is_supported("src/main.go"));
+        assert!(!is_supported("README.md"));
+        assert!(supported_language_names().contains(&"python".to_string()));
+    }
+}
@@ -0,0 +1,131 @@
+// Package client queries a running `codeprysm serve`.
+//
+// [HTTP] talks to the REST API (`codeprysm serve --http`, default
+// 127.0.0.1:8080) and [GRPC] to the codeprysm.v1.GraphService gRPC API
+// (`codeprysm serve --grpc`, default 127.0.0.1:50051). Both return the
+// node and edge types of the graph package:
+//
+//	c := client.NewHTTP("http://127.0.0.1:8080")
+//	for sym, err := range c.AllSymbols(ctx, client.SymbolQuery{Types: []string{"Callable"}}) {
+//		if err != nil {
+//			return err
+//		}
+//		fmt.Println(sym.ID)
+//	}
+package client
+
+import (
+	"fmt"
+
+	"go.codeprysm.dev/graph"
+)
+
+// Page is one page of a longer result list.
+type Page[T any] struct {
+	// Items on this page.
+	Items []T `json:"items"`
+	// Total is the number of items across all pages.
+	Total int `json:"total"`
+	// Offset is the index of the first item on this page.
+	Offset int `json:"offset"`
+	// Limit is the maximum number of items per page.
+	Limit int `json:"limit"`
+	// NextOffset is the offset of the next page, or nil on the last page.
+	NextOffset *int `json:"next_offset,omitempty"`
+	// NextCursor continues after this page, or is empty on the last page.
+	// Unlike NextOffset it stays valid when the server reloads the index.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// PageOptions select a page. Zero values use the server defaults.
+type PageOptions struct {
+	Offset int
+	// Cursor is the NextCursor of the previous page; it takes precedence
+	// over Offset.
+	Cursor string
+	Limit  int
+}
+
+// SymbolQuery filters symbols.
+type SymbolQuery struct {
+	// Query is a node ID, name, or ID suffix; with Fuzzy, a fuzzy name
+	// pattern. Empty lists all symbols.
+	Query string
+	// Fuzzy matches names fuzzily, ranked like an editor's symbol picker.
+	Fuzzy bool
+	// Types keeps symbols of these node types or kinds (e.g. "Callable",
+	// "type"); empty keeps all.
+	Types []string
+	// File keeps symbols under this path prefix (HTTP only).
+	File string
+	// Attributes keeps symbols with all these attributes ("key=value", or
+	// "key" for any value).
+	Attributes []string
+	PageOptions
+}
+
+// Call is a caller or callee with the lines of its calls.
+type Call struct {
+	Symbol graph.Node `json:"symbol"`
+	Lines  []int      `json:"lines"`
+}
+
+// Package is a package (directory) with its coupling metrics.
+type Package struct {
+	// Package is the package path.
+	Package string `json:"package"`
+	// Afferent is the number of packages depending on this one.
+	Afferent int `json:"afferent"`
+	// Efferent is the number of packages this one depends on.
+	Efferent int `json:"efferent"`
+	// Instability is Efferent / (Afferent + Efferent).
+	Instability float64 `json:"instability"`
+	// Abstractness is the share of abstract types.
+	Abstractness float64 `json:"abstractness"`
+	// Distance is the distance from the main sequence.
+	Distance float64 `json:"distance"`
+	// TypeCount is the number of types declared in the package.
+	TypeCount int `json:"type_count"`
+	// AbstractCount is the number of abstract types.
+	AbstractCount int `json:"abstract_count"`
+	// Dependencies are the packages this one depends on.
+	Dependencies []string `json:"dependencies"`
+}
+
+// Dependency is a dependency between two packages.
+type Dependency struct {
+	// From is the depending package.
+	From string `json:"from"`
+	// To is the package depended on.
+	To string `json:"to"`
+	// References is the number of references making up the dependency.
+	References int `json:"references"`
+	// File and Line locate a sample reference.
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
+
+// SubgraphOptions bound the neighborhood of a symbol. Zero values use the
+// server defaults.
+type SubgraphOptions struct {
+	// Radius is the maximum number of hops from the center.
+	Radius int
+	// EdgeTypes to follow; empty follows all.
+	EdgeTypes []graph.EdgeType
+	// Direction of the edges to follow.
+	Direction graph.Direction
+	// MaxNodes stops after this many nodes, closest first.
+	MaxNodes int
+}
+
+// Error is an error response from the server.
+type Error struct {
+	// StatusCode is the HTTP status (404 for unknown symbols or packages).
+	StatusCode int
+	// Message is the server's error message.
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("codeprysm: %s (HTTP %d)", e.Message, e.StatusCode)
+}
@@ -0,0 +1,124 @@
+package client
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"go.codeprysm.dev/graph"
+)
+
+const service = "/codeprysm.v1.GraphService/"
+
+// GRPC is a client for the codeprysm.v1.GraphService gRPC API.
+type GRPC struct {
+	conn grpc.ClientConnInterface
+	// closer is set when the client owns the connection
+	closer interface{ Close() error }
+}
+
+// Neighbor is an edge of a symbol and the symbol at its other end.
+type Neighbor struct {
+	Edge *graph.Edge
+	Node *graph.Node
+}
+
+// LookupOptions filter [GRPC.LookupSymbols].
+type LookupOptions struct {
+	// Fuzzy matches names fuzzily, ranked like an editor's symbol picker.
+	Fuzzy bool
+	// Types keeps symbols of these node types or kinds; empty keeps all.
+	Types []string
+	// Limit is the maximum number of symbols; 0 uses the server default.
+	Limit int
+	// Attributes keeps symbols with all these attributes ("key=value", or
+	// "key" for any value).
+	Attributes []string
+}
+
+// DialGRPC connects to the gRPC API at target (e.g. "127.0.0.1:50051").
+// Without options the connection is unencrypted, as `codeprysm serve` is.
+func DialGRPC(target string, opts ...grpc.DialOption) (*GRPC, error) {
+	if len(opts) == 0 {
+		opts = []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	}
+	conn, err := grpc.NewClient(target, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &GRPC{conn: conn, closer: conn}, nil
+}
+
+// NewGRPC returns a client using an existing connection, which the caller
+// keeps ownership of.
+func NewGRPC(conn grpc.ClientConnInterface) *GRPC {
+	return &GRPC{conn: conn}
+}
+
+// Close closes the connection if the client opened it.
+func (c *GRPC) Close() error {
+	if c.closer == nil {
+		return nil
+	}
+	return c.closer.Close()
+}
+
+// GetSymbol returns the symbol with the given node ID.
+func (c *GRPC) GetSymbol(ctx context.Context, id string) (*graph.Node, error) {
+	req := getSymbolRequest{id: id}
+	var resp symbol
+	if err := c.invoke(ctx, "GetSymbol", &req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.node(), nil
+}
+
+// LookupSymbols finds symbols by node ID, name, or ID suffix, or by fuzzy
+// name match.
+func (c *GRPC) LookupSymbols(ctx context.Context, query string, opts LookupOptions) ([]*graph.Node, error) {
+	req := lookupSymbolsRequest{query: query, fuzzy: opts.Fuzzy, nodeTypes: opts.Types, limit: opts.Limit, attributes: opts.Attributes}
+	var resp lookupSymbolsResponse
+	if err := c.invoke(ctx, "LookupSymbols", &req, &resp); err != nil {
+		return nil, err
+	}
+	nodes := make([]*graph.Node, len(resp.symbols))
+	for i, s := range resp.symbols {
+		nodes[i] = s.node()
+	}
+	return nodes, nil
+}
+
+// GetEdges lists the edges of a symbol in direction d, with the symbols at
+// their other end. Only edges of the given types are listed, or all when
+// none are given.
+func (c *GRPC) GetEdges(ctx context.Context, id string, d graph.Direction, types ...graph.EdgeType) ([]Neighbor, error) {
+	req := getEdgesRequest{id: id, direction: d, edgeTypes: types}
+	var resp getEdgesResponse
+	if err := c.invoke(ctx, "GetEdges", &req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.neighbors, nil
+}
+
+// GetSubgraph returns the neighborhood of a symbol. With a format ("json",
+// "dot", "mermaid", or "graphml") the subgraph is also returned rendered.
+func (c *GRPC) GetSubgraph(ctx context.Context, id string, opts SubgraphOptions, format string) (*graph.Graph, string, error) {
+	req := getSubgraphRequest{id: id, options: opts, format: format}
+	var resp getSubgraphResponse
+	if err := c.invoke(ctx, "GetSubgraph", &req, &resp); err != nil {
+		return nil, "", err
+	}
+	g := graph.New()
+	for _, s := range resp.nodes {
+		g.AddNode(s.node())
+	}
+	for _, e := range resp.edges {
+		_ = g.AddEdge(e)
+	}
+	return g, resp.rendered, nil
+}
+
+func (c *GRPC) invoke(ctx context.Context, method string, req message, resp message) error {
+	return c.conn.Invoke(ctx, service+method, req, resp, grpc.ForceCodec(codec{}))
+}
@@ -0,0 +1,179 @@
+package client
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"go.codeprysm.dev/graph"
+)
+
+// rawCodec passes messages through as bytes, so the fake server can check
+// the encoded requests and hand-encode its responses.
+type rawCodec struct{}
+
+func (rawCodec) Name() string { return "proto" }
+
+func (rawCodec) Marshal(v any) ([]byte, error) { return *v.(*[]byte), nil }
+
+func (rawCodec) Unmarshal(data []byte, v any) error {
+	*v.(*[]byte) = append([]byte(nil), data...)
+	return nil
+}
+
+func encodeSymbol(id, name string, line int) []byte {
+	b := appendString(nil, 1, id)
+	b = appendString(b, 2, name)
+	b = appendString(b, 3, "Callable")
+	b = appendString(b, 4, "function")
+	b = appendString(b, 6, "main.go")
+	return appendVarint(b, 7, uint64(line))
+}
+
+func encodeEdge(source, target string, line int) []byte {
+	b := appendString(nil, 1, source)
+	b = appendString(b, 2, target)
+	b = appendString(b, 3, "USES")
+	return appendVarint(b, 4, uint64(line))
+}
+
+func appendBytes(b []byte, num protowire.Number, v []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+// fakeGraphService answers GraphService calls for a two-function graph in
+// which main calls run, recording the last request.
+func fakeGraphService(t *testing.T, requests map[string][]byte) *GRPC {
+	t.Helper()
+	handler := func(_ any, stream grpc.ServerStream) error {
+		method, _ := grpc.MethodFromServerStream(stream)
+		var req []byte
+		if err := stream.RecvMsg(&req); err != nil {
+			return err
+		}
+		requests[method] = req
+
+		var resp []byte
+		switch method {
+		case service + "GetSymbol":
+			if string(req) != string(appendString(nil, 1, "main.go:run")) {
+				return status.Error(codes.NotFound, "Node not found")
+			}
+			resp = encodeSymbol("main.go:run", "run", 7)
+		case service + "LookupSymbols":
+			entry := appendString(appendString(nil, 1, "layer"), 2, "cli")
+			resp = appendBytes(nil, 1, appendBytes(encodeSymbol("main.go:main", "main", 3), 9, entry))
+			resp = appendBytes(resp, 1, encodeSymbol("main.go:run", "run", 7))
+		case service + "GetEdges":
+			neighbor := appendBytes(nil, 1, encodeSymbol("main.go:main", "main", 3))
+			neighbor = appendBytes(neighbor, 2, encodeEdge("main.go:main", "main.go:run", 4))
+			resp = appendBytes(nil, 1, neighbor)
+		case service + "GetSubgraph":
+			resp = appendBytes(nil, 1, encodeSymbol("main.go:main", "main", 3))
+			resp = appendBytes(resp, 1, encodeSymbol("main.go:run", "run", 7))
+			resp = appendBytes(resp, 2, encodeEdge("main.go:main", "main.go:run", 4))
+			resp = appendString(resp, 3, "graph LR")
+		default:
+			return status.Error(codes.Unimplemented, method)
+		}
+		return stream.SendMsg(&resp)
+	}
+
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(grpc.UnknownServiceHandler(handler), grpc.ForceServerCodec(rawCodec{}))
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	c, err := DialGRPC("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestGRPCGetSymbol(t *testing.T) {
+	c := fakeGraphService(t, map[string][]byte{})
+	ctx := context.Background()
+
+	n, err := c.GetSymbol(ctx, "main.go:run")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Name != "run" || n.Type != graph.Callable || n.Line != 7 {
+		t.Errorf("GetSymbol = %+v", n)
+	}
+
+	if _, err := c.GetSymbol(ctx, "missing"); status.Code(err) != codes.NotFound {
+		t.Errorf("err = %v, want NotFound", err)
+	}
+}
+
+func TestGRPCLookupSymbols(t *testing.T) {
+	requests := map[string][]byte{}
+	c := fakeGraphService(t, requests)
+
+	opts := LookupOptions{Fuzzy: true, Types: []string{"Callable"}, Limit: 5, Attributes: []string{"layer=cli"}}
+	nodes, err := c.LookupSymbols(context.Background(), "mn", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 2 || nodes[0].ID != "main.go:main" {
+		t.Errorf("LookupSymbols = %v", nodes)
+	}
+	if got := nodes[0].Metadata.Attributes["layer"]; got != "cli" {
+		t.Errorf("attributes = %v", nodes[0].Metadata.Attributes)
+	}
+
+	want := appendString(nil, 1, "mn")
+	want = appendVarint(want, 2, 1)
+	want = appendString(want, 3, "Callable")
+	want = appendVarint(want, 4, 5)
+	want = appendString(want, 5, "layer=cli")
+	if got := requests[service+"LookupSymbols"]; string(got) != string(want) {
+		t.Errorf("request = %x, want %x", got, want)
+	}
+}
+
+func TestGRPCGetEdges(t *testing.T) {
+	requests := map[string][]byte{}
+	c := fakeGraphService(t, requests)
+
+	neighbors, err := c.GetEdges(context.Background(), "main.go:run", graph.Incoming, graph.Uses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(neighbors) != 1 || neighbors[0].Node.ID != "main.go:main" || neighbors[0].Edge.RefLine != 4 {
+		t.Errorf("GetEdges = %+v", neighbors)
+	}
+
+	want := appendString(nil, 1, "main.go:run")
+	want = appendVarint(want, 2, 2)
+	want = appendString(want, 3, "USES")
+	if got := requests[service+"GetEdges"]; string(got) != string(want) {
+		t.Errorf("request = %x, want %x", got, want)
+	}
+}
+
+func TestGRPCGetSubgraph(t *testing.T) {
+	c := fakeGraphService(t, map[string][]byte{})
+
+	g, rendered, err := c.GetSubgraph(context.Background(), "main.go:run", SubgraphOptions{Radius: 1}, "mermaid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.NodeCount() != 2 || g.EdgeCount() != 1 || rendered != "graph LR" {
+		t.Errorf("GetSubgraph: %d nodes, %d edges, %q", g.NodeCount(), g.EdgeCount(), rendered)
+	}
+}
@@ -0,0 +1,270 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"go.codeprysm.dev/graph"
+)
+
+// HTTP is a client for the REST API.
+type HTTP struct {
+	// BaseURL is the server address, e.g. "http://127.0.0.1:8080".
+	BaseURL string
+	// Client sends the requests; nil uses http.DefaultClient.
+	Client *http.Client
+}
+
+// NewHTTP returns a client for the REST API at baseURL.
+func NewHTTP(baseURL string) *HTTP {
+	return &HTTP{BaseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// Symbol returns the symbol with the given node ID.
+func (c *HTTP) Symbol(ctx context.Context, id string) (*graph.Node, error) {
+	var n graph.Node
+	if err := c.getJSON(ctx, symbolPath(id, ""), nil, &n); err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// Symbols returns one page of the symbols matching q.
+func (c *HTTP) Symbols(ctx context.Context, q SymbolQuery) (*Page[graph.Node], error) {
+	params := pageParams(q.PageOptions)
+	if q.Query != "" {
+		params.Set("q", q.Query)
+	}
+	if q.Fuzzy {
+		params.Set("fuzzy", "true")
+	}
+	if len(q.Types) > 0 {
+		params.Set("type", strings.Join(q.Types, ","))
+	}
+	if q.File != "" {
+		params.Set("file", q.File)
+	}
+	if len(q.Attributes) > 0 {
+		params.Set("attr", strings.Join(q.Attributes, ","))
+	}
+	var page Page[graph.Node]
+	if err := c.getJSON(ctx, "/symbols", params, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// AllSymbols returns every symbol matching q, starting at q.Cursor or
+// q.Offset and fetching pages of q.Limit as the iteration proceeds.
+// Iteration stops after yielding an error.
+func (c *HTTP) AllSymbols(ctx context.Context, q SymbolQuery) iter.Seq2[graph.Node, error] {
+	return func(yield func(graph.Node, error) bool) {
+		for {
+			page, err := c.Symbols(ctx, q)
+			if err != nil {
+				yield(graph.Node{}, err)
+				return
+			}
+			for _, n := range page.Items {
+				if !yield(n, nil) {
+					return
+				}
+			}
+			switch {
+			case page.NextCursor != "":
+				q.Cursor = page.NextCursor
+			case page.NextOffset != nil:
+				// Servers predating cursors
+				q.Offset = *page.NextOffset
+			default:
+				return
+			}
+		}
+	}
+}
+
+// Callers returns one page of the callers of a symbol.
+func (c *HTTP) Callers(ctx context.Context, id string, opts PageOptions) (*Page[Call], error) {
+	var page Page[Call]
+	if err := c.getJSON(ctx, symbolPath(id, "callers"), pageParams(opts), &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// Callees returns one page of the callees of a symbol.
+func (c *HTTP) Callees(ctx context.Context, id string, opts PageOptions) (*Page[Call], error) {
+	var page Page[Call]
+	if err := c.getJSON(ctx, symbolPath(id, "callees"), pageParams(opts), &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// Implementations returns one page of the implementing types of an
+// interface, or of the interfaces a type implements.
+func (c *HTTP) Implementations(ctx context.Context, id string, opts PageOptions) (*Page[graph.Node], error) {
+	var page Page[graph.Node]
+	if err := c.getJSON(ctx, symbolPath(id, "implementations"), pageParams(opts), &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// Subgraph returns the neighborhood of a symbol.
+func (c *HTTP) Subgraph(ctx context.Context, id string, opts SubgraphOptions) (*graph.Graph, error) {
+	body, err := c.get(ctx, symbolPath(id, "subgraph"), subgraphParams(opts, "json"))
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return graph.ReadJSON(body)
+}
+
+// RenderSubgraph returns the neighborhood of a symbol rendered as "json",
+// "dot", "mermaid", or "graphml".
+func (c *HTTP) RenderSubgraph(ctx context.Context, id string, opts SubgraphOptions, format string) (string, error) {
+	body, err := c.get(ctx, symbolPath(id, "subgraph"), subgraphParams(opts, format))
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	return string(data), err
+}
+
+// Packages returns one page of packages with their coupling metrics.
+func (c *HTTP) Packages(ctx context.Context, opts PageOptions) (*Page[Package], error) {
+	var page Page[Package]
+	if err := c.getJSON(ctx, "/packages", pageParams(opts), &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// Dependencies returns one page of the packages pkg depends on.
+func (c *HTTP) Dependencies(ctx context.Context, pkg string, opts PageOptions) (*Page[Dependency], error) {
+	return c.dependencies(ctx, pkg, "outgoing", opts)
+}
+
+// Dependents returns one page of the packages depending on pkg.
+func (c *HTTP) Dependents(ctx context.Context, pkg string, opts PageOptions) (*Page[Dependency], error) {
+	return c.dependencies(ctx, pkg, "incoming", opts)
+}
+
+func (c *HTTP) dependencies(ctx context.Context, pkg, direction string, opts PageOptions) (*Page[Dependency], error) {
+	params := pageParams(opts)
+	params.Set("direction", direction)
+	var page Page[Dependency]
+	if err := c.getJSON(ctx, "/packages/"+escapePath(pkg)+"/dependencies", params, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+func (c *HTTP) getJSON(ctx context.Context, path string, params url.Values, v any) error {
+	body, err := c.get(ctx, path, params)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	if err := json.NewDecoder(body).Decode(v); err != nil {
+		return fmt.Errorf("codeprysm: decode %s: %w", path, err)
+	}
+	return nil
+}
+
+// get sends a GET request and returns the body of a successful response.
+func (c *HTTP) get(ctx context.Context, path string, params url.Values) (io.ReadCloser, error) {
+	target := c.BaseURL + path
+	if len(params) > 0 {
+		target += "?" + params.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		var body struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&body) != nil || body.Error == "" {
+			body.Error = resp.Status
+		}
+		return nil, &Error{StatusCode: resp.StatusCode, Message: body.Error}
+	}
+	return resp.Body, nil
+}
+
+// symbolPath is the path of a symbol resource. IDs contain slashes, which
+// the server matches as part of the path.
+func symbolPath(id, resource string) string {
+	path := "/symbols/" + escapePath(id)
+	if resource != "" {
+		path += "/" + resource
+	}
+	return path
+}
+
+// escapePath escapes each segment of a slash-separated path.
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+func pageParams(opts PageOptions) url.Values {
+	params := url.Values{}
+	if opts.Offset > 0 {
+		params.Set("offset", strconv.Itoa(opts.Offset))
+	}
+	if opts.Cursor != "" {
+		params.Set("cursor", opts.Cursor)
+	}
+	if opts.Limit > 0 {
+		params.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	return params
+}
+
+func subgraphParams(opts SubgraphOptions, format string) url.Values {
+	params := url.Values{"format": {format}}
+	if opts.Radius > 0 {
+		params.Set("radius", strconv.Itoa(opts.Radius))
+	}
+	if len(opts.EdgeTypes) > 0 {
+		types := make([]string, len(opts.EdgeTypes))
+		for i, t := range opts.EdgeTypes {
+			types[i] = string(t)
+		}
+		params.Set("edges", strings.Join(types, ","))
+	}
+	switch opts.Direction {
+	case graph.Outgoing:
+		params.Set("direction", "outgoing")
+	case graph.Incoming:
+		params.Set("direction", "incoming")
+	}
+	if opts.MaxNodes > 0 {
+		params.Set("max_nodes", strconv.Itoa(opts.MaxNodes))
+	}
+	return params
+}
@@ -0,0 +1,149 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+
+	"go.codeprysm.dev/graph"
+)
+
+// fakeServer serves a few symbols the way `codeprysm serve --http` does.
+func fakeServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	symbols := []graph.Node{
+		{ID: "api/server.go:Handle", Name: "Handle", Type: graph.Callable, Kind: "function", File: "api/server.go", Line: 3, EndLine: 9},
+		{ID: "api/server.go:parse", Name: "parse", Type: graph.Callable, Kind: "function", File: "api/server.go", Line: 11, EndLine: 20},
+		{ID: "cmd/main.go:main", Name: "main", Type: graph.Callable, Kind: "function", File: "cmd/main.go", Line: 5, EndLine: 8},
+	}
+	writeJSON := func(w http.ResponseWriter, status int, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(v)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /symbols", func(w http.ResponseWriter, r *http.Request) {
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		// Cursors name the last symbol of the previous page
+		if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+			offset = slices.IndexFunc(symbols, func(n graph.Node) bool { return n.ID == cursor }) + 1
+		}
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if limit == 0 {
+			limit = 50
+		}
+		end := min(offset+limit, len(symbols))
+		page := Page[graph.Node]{Items: symbols[offset:end], Total: len(symbols), Offset: offset, Limit: limit}
+		if end < len(symbols) {
+			page.NextOffset = &end
+			page.NextCursor = symbols[end-1].ID
+		}
+		writeJSON(w, http.StatusOK, page)
+	})
+	mux.HandleFunc("GET /symbols/api/server.go:Handle", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, symbols[0])
+	})
+	mux.HandleFunc("GET /symbols/api/server.go:Handle/callers", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, Page[Call]{Items: []Call{{Symbol: symbols[2], Lines: []int{6}}}, Total: 1, Limit: 50})
+	})
+	mux.HandleFunc("GET /symbols/api/server.go:Handle/subgraph", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("format") != "json" || r.URL.Query().Get("direction") != "incoming" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unexpected query " + r.URL.RawQuery})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"nodes": []graph.Node{symbols[0], symbols[2]},
+			"edges": []graph.Edge{{Source: symbols[2].ID, Target: symbols[0].ID, Type: graph.Uses, RefLine: 6}},
+		})
+	})
+	mux.HandleFunc("GET /symbols/missing", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Node not found: missing"})
+	})
+	mux.HandleFunc("GET /packages/api/dependencies", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("direction") != "incoming" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "expected incoming"})
+			return
+		}
+		writeJSON(w, http.StatusOK, Page[Dependency]{Items: []Dependency{{From: "cmd", To: "api", References: 1, File: "cmd/main.go", Line: 6}}, Total: 1, Limit: 50})
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestHTTPSymbol(t *testing.T) {
+	c := NewHTTP(fakeServer(t).URL + "/")
+	ctx := context.Background()
+
+	sym, err := c.Symbol(ctx, "api/server.go:Handle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sym.Name != "Handle" || sym.Line != 3 {
+		t.Errorf("Symbol = %+v", sym)
+	}
+
+	_, err = c.Symbol(ctx, "missing")
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || apiErr.Message != "Node not found: missing" {
+		t.Errorf("err = %v", err)
+	}
+
+	callers, err := c.Callers(ctx, "api/server.go:Handle", PageOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(callers.Items) != 1 || callers.Items[0].Symbol.ID != "cmd/main.go:main" || callers.Items[0].Lines[0] != 6 {
+		t.Errorf("Callers = %+v", callers)
+	}
+}
+
+func TestHTTPAllSymbols(t *testing.T) {
+	c := NewHTTP(fakeServer(t).URL)
+
+	var ids []string
+	for sym, err := range c.AllSymbols(context.Background(), SymbolQuery{PageOptions: PageOptions{Limit: 2}}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, sym.ID)
+	}
+	if len(ids) != 3 || ids[2] != "cmd/main.go:main" {
+		t.Errorf("AllSymbols = %v", ids)
+	}
+}
+
+func TestHTTPSubgraph(t *testing.T) {
+	c := NewHTTP(fakeServer(t).URL)
+	g, err := c.Subgraph(context.Background(), "api/server.go:Handle", SubgraphOptions{Direction: graph.Incoming})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.NodeCount() != 2 || g.EdgeCount() != 1 {
+		t.Errorf("Subgraph: %d nodes, %d edges", g.NodeCount(), g.EdgeCount())
+	}
+}
+
+func TestHTTPDependents(t *testing.T) {
+	c := NewHTTP(fakeServer(t).URL)
+	page, err := c.Dependents(context.Background(), "api", PageOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 1 || page.Items[0].From != "cmd" {
+		t.Errorf("Dependents = %+v", page)
+	}
+}
+
+func TestEscapePath(t *testing.T) {
+	if got := escapePath("src/a b.go:Run"); got != "src/a%20b.go:Run" {
+		t.Errorf("escapePath = %q", got)
+	}
+}
@@ -0,0 +1,346 @@
+package client
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"go.codeprysm.dev/graph"
+)
+
+// The messages of crates/codeprysm-server/proto/codeprysm/v1/graph.proto,
+// encoded by hand with protowire so the module needs no generated code.
+// Field numbers must match the proto file.
+
+// message is a request or response of the gRPC API.
+type message interface {
+	marshal() []byte
+	unmarshal([]byte) error
+}
+
+// codec encodes messages in the protobuf wire format.
+type codec struct{}
+
+func (codec) Name() string { return "proto" }
+
+func (codec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(message)
+	if !ok {
+		return nil, fmt.Errorf("codeprysm: cannot marshal %T", v)
+	}
+	return m.marshal(), nil
+}
+
+func (codec) Unmarshal(data []byte, v any) error {
+	m, ok := v.(message)
+	if !ok {
+		return fmt.Errorf("codeprysm: cannot unmarshal into %T", v)
+	}
+	return m.unmarshal(data)
+}
+
+// field is a decoded field value: varints in u, length-delimited in b.
+type field struct {
+	u uint64
+	b []byte
+}
+
+// decode calls fn for each varint and length-delimited field of b,
+// skipping fields of other wire types.
+func decode(b []byte, fn func(protowire.Number, field) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		var f field
+		switch typ {
+		case protowire.VarintType:
+			f.u, n = protowire.ConsumeVarint(b)
+		case protowire.BytesType:
+			f.b, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			b = b[n:]
+			continue
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		if err := fn(num, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendStrings(b []byte, num protowire.Number, values []string) []byte {
+	for _, s := range values {
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		b = protowire.AppendString(b, s)
+	}
+	return b
+}
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func boolVarint(v bool) uint64 {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+// protoDirection maps a direction to the codeprysm.v1.Direction enum.
+func protoDirection(d graph.Direction) uint64 {
+	switch d {
+	case graph.Outgoing:
+		return 1
+	case graph.Incoming:
+		return 2
+	default:
+		return 3
+	}
+}
+
+func edgeTypeStrings(types []graph.EdgeType) []string {
+	out := make([]string, len(types))
+	for i, t := range types {
+		out[i] = string(t)
+	}
+	return out
+}
+
+// requestOnly is embedded in requests, which are never decoded.
+type requestOnly struct{}
+
+func (requestOnly) unmarshal([]byte) error { return fmt.Errorf("codeprysm: requests are not decoded") }
+
+// responseOnly is embedded in responses, which are never encoded.
+type responseOnly struct{}
+
+func (responseOnly) marshal() []byte { return nil }
+
+type getSymbolRequest struct {
+	requestOnly
+	id string
+}
+
+func (m *getSymbolRequest) marshal() []byte {
+	return appendString(nil, 1, m.id)
+}
+
+type lookupSymbolsRequest struct {
+	requestOnly
+	query      string
+	fuzzy      bool
+	nodeTypes  []string
+	limit      int
+	attributes []string
+}
+
+func (m *lookupSymbolsRequest) marshal() []byte {
+	b := appendString(nil, 1, m.query)
+	b = appendVarint(b, 2, boolVarint(m.fuzzy))
+	b = appendStrings(b, 3, m.nodeTypes)
+	b = appendVarint(b, 4, uint64(m.limit))
+	return appendStrings(b, 5, m.attributes)
+}
+
+type getEdgesRequest struct {
+	requestOnly
+	id        string
+	direction graph.Direction
+	edgeTypes []graph.EdgeType
+}
+
+func (m *getEdgesRequest) marshal() []byte {
+	b := appendString(nil, 1, m.id)
+	b = appendVarint(b, 2, protoDirection(m.direction))
+	return appendStrings(b, 3, edgeTypeStrings(m.edgeTypes))
+}
+
+type getSubgraphRequest struct {
+	requestOnly
+	id      string
+	options SubgraphOptions
+	format  string
+}
+
+func (m *getSubgraphRequest) marshal() []byte {
+	b := appendString(nil, 1, m.id)
+	b = appendVarint(b, 2, uint64(m.options.Radius))
+	b = appendStrings(b, 3, edgeTypeStrings(m.options.EdgeTypes))
+	b = appendVarint(b, 4, protoDirection(m.options.Direction))
+	b = appendVarint(b, 5, uint64(m.options.MaxNodes))
+	return appendString(b, 6, m.format)
+}
+
+// symbol is codeprysm.v1.Symbol.
+type symbol struct {
+	responseOnly
+	graph.Node
+}
+
+func (m *symbol) unmarshal(b []byte) error {
+	return decode(b, func(num protowire.Number, f field) error {
+		switch num {
+		case 1:
+			m.ID = string(f.b)
+		case 2:
+			m.Name = string(f.b)
+		case 3:
+			m.Type = graph.NodeType(f.b)
+		case 4:
+			m.Kind = string(f.b)
+		case 5:
+			m.Subtype = string(f.b)
+		case 6:
+			m.File = string(f.b)
+		case 7:
+			m.Line = int(f.u)
+		case 8:
+			m.EndLine = int(f.u)
+		case 9:
+			// Map entries are messages with the key in field 1 and the
+			// value in field 2
+			var key, value string
+			err := decode(f.b, func(num protowire.Number, f field) error {
+				switch num {
+				case 1:
+					key = string(f.b)
+				case 2:
+					value = string(f.b)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if m.Metadata.Attributes == nil {
+				m.Metadata.Attributes = map[string]string{}
+			}
+			m.Metadata.Attributes[key] = value
+		}
+		return nil
+	})
+}
+
+func (m *symbol) node() *graph.Node {
+	n := m.Node
+	return &n
+}
+
+// edge is codeprysm.v1.Edge.
+type edge struct {
+	responseOnly
+	graph.Edge
+}
+
+func (m *edge) unmarshal(b []byte) error {
+	return decode(b, func(num protowire.Number, f field) error {
+		switch num {
+		case 1:
+			m.Source = string(f.b)
+		case 2:
+			m.Target = string(f.b)
+		case 3:
+			m.Type = graph.EdgeType(f.b)
+		case 4:
+			m.RefLine = int(f.u)
+		case 5:
+			m.Ident = string(f.b)
+		}
+		return nil
+	})
+}
+
+type lookupSymbolsResponse struct {
+	responseOnly
+	symbols []*symbol
+}
+
+func (m *lookupSymbolsResponse) unmarshal(b []byte) error {
+	return decode(b, func(num protowire.Number, f field) error {
+		if num != 1 {
+			return nil
+		}
+		s := new(symbol)
+		m.symbols = append(m.symbols, s)
+		return s.unmarshal(f.b)
+	})
+}
+
+type getEdgesResponse struct {
+	responseOnly
+	neighbors []Neighbor
+}
+
+func (m *getEdgesResponse) unmarshal(b []byte) error {
+	return decode(b, func(num protowire.Number, f field) error {
+		if num != 1 {
+			return nil
+		}
+		var s symbol
+		var e edge
+		err := decode(f.b, func(num protowire.Number, f field) error {
+			switch num {
+			case 1:
+				return s.unmarshal(f.b)
+			case 2:
+				return e.unmarshal(f.b)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		m.neighbors = append(m.neighbors, Neighbor{Edge: &e.Edge, Node: s.node()})
+		return nil
+	})
+}
+
+type getSubgraphResponse struct {
+	responseOnly
+	nodes    []*symbol
+	edges    []*graph.Edge
+	rendered string
+}
+
+func (m *getSubgraphResponse) unmarshal(b []byte) error {
+	return decode(b, func(num protowire.Number, f field) error {
+		switch num {
+		case 1:
+			s := new(symbol)
+			m.nodes = append(m.nodes, s)
+			return s.unmarshal(f.b)
+		case 2:
+			e := new(edge)
+			m.edges = append(m.edges, &e.Edge)
+			return e.unmarshal(f.b)
+		case 3:
+			m.rendered = string(f.b)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,211 @@
+// Command codeprysm-gotypes type-checks the Go modules under a directory and
+// prints every reference it resolves, for `codeprysm init` and `codeprysm
+// update` to merge into the syntactic graph when `analysis.go_type_check` is
+// set.
+//
+// Usage:
+//
+//	codeprysm-gotypes <root>
+//
+// The packages of each module (a directory with a go.mod file) are loaded
+// with their tests through golang.org/x/tools/go/packages and type-checked
+// from source. Each reference to an object declared under the root is
+// printed as one JSON object per line:
+//
+//	{"file": "api/server.go", "line": 12, "column": 5, "name": "Save",
+//	 "target_file": "store/db.go", "target_line": 40, "target_column": 17}
+//
+// Paths are relative to the root. Type errors are reported on stderr and
+// do not stop the analysis; the references that could be resolved are still
+// printed.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// Reference is a resolved identifier and the declaration it refers to.
+type Reference struct {
+	File         string `json:"file"`
+	Line         int    `json:"line"`
+	Column       int    `json:"column"`
+	Name         string `json:"name"`
+	TargetFile   string `json:"target_file"`
+	TargetLine   int    `json:"target_line"`
+	TargetColumn int    `json:"target_column"`
+}
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: codeprysm-gotypes <root>")
+		os.Exit(2)
+	}
+	out := bufio.NewWriter(os.Stdout)
+	if err := run(os.Args[1], out, os.Stderr); err != nil {
+		fmt.Fprintln(os.Stderr, "codeprysm-gotypes:", err)
+		os.Exit(1)
+	}
+	if err := out.Flush(); err != nil {
+		fmt.Fprintln(os.Stderr, "codeprysm-gotypes:", err)
+		os.Exit(1)
+	}
+}
+
+// run type-checks the modules under root and writes their references to out.
+func run(root string, out, warnings io.Writer) error {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	modules, err := findModules(root)
+	if err != nil {
+		return err
+	}
+	if len(modules) == 0 {
+		return errors.New("no go.mod found under " + root)
+	}
+
+	enc := json.NewEncoder(out)
+	for _, module := range modules {
+		refs, err := checkModule(root, module, warnings)
+		if err != nil {
+			return fmt.Errorf("%s: %w", module, err)
+		}
+		for _, ref := range refs {
+			if err := enc.Encode(ref); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// findModules returns the directories under root holding a go.mod file,
+// skipping hidden, vendor, and testdata directories.
+func findModules(root string) ([]string, error) {
+	var modules []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if path != root && (strings.HasPrefix(name, ".") || name == "vendor" || name == "testdata" || name == "node_modules") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Name() == "go.mod" {
+			modules = append(modules, filepath.Dir(path))
+		}
+		return nil
+	})
+	return modules, err
+}
+
+// checkModule type-checks the packages of one module, with their tests,
+// and returns the references to objects declared under root, sorted by
+// position.
+func checkModule(root, module string, warnings io.Writer) ([]Reference, error) {
+	// Dependencies are type-checked from source too, so the result does not
+	// depend on reading the export data of whichever toolchain is installed
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedDeps |
+			packages.NeedSyntax | packages.NeedTypes | packages.NeedTypesInfo,
+		Dir:   module,
+		Tests: true,
+	}
+	pkgs, err := packages.Load(cfg, "./...")
+	if err != nil {
+		return nil, err
+	}
+
+	// A package is loaded again with its tests, so its references and
+	// errors are reported once
+	seen := make(map[Reference]bool)
+	reported := make(map[string]bool)
+	var refs []Reference
+	for _, pkg := range pkgs {
+		for _, err := range pkg.Errors {
+			if msg := err.Error(); !reported[msg] {
+				reported[msg] = true
+				fmt.Fprintln(warnings, msg)
+			}
+		}
+		if pkg.TypesInfo == nil {
+			continue
+		}
+		for ident, obj := range pkg.TypesInfo.Uses {
+			ref, ok := reference(root, pkg.Fset, ident, obj)
+			if ok && !seen[ref] {
+				seen[ref] = true
+				refs = append(refs, ref)
+			}
+		}
+	}
+
+	slices.SortFunc(refs, func(a, b Reference) int {
+		if c := strings.Compare(a.File, b.File); c != 0 {
+			return c
+		}
+		if a.Line != b.Line {
+			return a.Line - b.Line
+		}
+		return a.Column - b.Column
+	})
+	return refs, nil
+}
+
+// reference converts a resolved identifier into a Reference, if both it
+// and the object it refers to are in files under root.
+func reference(root string, fset *token.FileSet, ident *ast.Ident, obj types.Object) (Reference, bool) {
+	if obj.Pkg() == nil || !obj.Pos().IsValid() {
+		return Reference{}, false
+	}
+	if _, ok := obj.(*types.PkgName); ok {
+		return Reference{}, false
+	}
+	use := fset.Position(ident.Pos())
+	decl := fset.Position(obj.Pos())
+	file, ok := relative(root, use.Filename)
+	if !ok {
+		return Reference{}, false
+	}
+	target, ok := relative(root, decl.Filename)
+	if !ok {
+		return Reference{}, false
+	}
+	return Reference{
+		File:         file,
+		Line:         use.Line,
+		Column:       use.Column,
+		Name:         ident.Name,
+		TargetFile:   target,
+		TargetLine:   decl.Line,
+		TargetColumn: decl.Column,
+	}, true
+}
+
+// relative returns path relative to root with forward slashes, if it is
+// under root.
+func relative(root, path string) (string, bool) {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
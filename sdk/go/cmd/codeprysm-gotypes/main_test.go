@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func write(t *testing.T, root, name, content string) {
+	t.Helper()
+	path := filepath.Join(root, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	root := t.TempDir()
+	write(t, root, "svc/go.mod", "module example.com/svc\n\ngo 1.24\n")
+	write(t, root, "svc/store/store.go", `package store
+
+type Users struct{}
+
+func (Users) Save(name string) error { return nil }
+
+type Orders struct{}
+
+func (Orders) Save(id int) error { return nil }
+`)
+	write(t, root, "svc/api/api.go", `package api
+
+import "example.com/svc/store"
+
+func Create(users store.Users) error {
+	return users.Save("ada")
+}
+`)
+	write(t, root, "svc/api/api_test.go", `package api
+
+import (
+	"testing"
+
+	"example.com/svc/store"
+)
+
+func TestCreate(t *testing.T) {
+	if err := Create(store.Users{}); err != nil {
+		t.Fatal(err)
+	}
+}
+`)
+
+	var out, warnings bytes.Buffer
+	if err := run(root, &out, &warnings); err != nil {
+		t.Fatal(err, warnings.String())
+	}
+	if warnings.Len() > 0 {
+		t.Errorf("unexpected warnings: %s", warnings.String())
+	}
+
+	var refs []Reference
+	dec := json.NewDecoder(&out)
+	for dec.More() {
+		var ref Reference
+		if err := dec.Decode(&ref); err != nil {
+			t.Fatal(err)
+		}
+		refs = append(refs, ref)
+	}
+
+	// The call resolves to the Save method of Users, not of Orders
+	want := Reference{
+		File: "svc/api/api.go", Line: 6, Column: 15, Name: "Save",
+		TargetFile: "svc/store/store.go", TargetLine: 5, TargetColumn: 14,
+	}
+	if !slices.Contains(refs, want) {
+		t.Errorf("missing %+v in %+v", want, refs)
+	}
+
+	// Test files are checked too, and a package loaded again with its tests
+	// adds no duplicates
+	create := Reference{
+		File: "svc/api/api_test.go", Line: 10, Column: 12, Name: "Create",
+		TargetFile: "svc/api/api.go", TargetLine: 5, TargetColumn: 6,
+	}
+	if !slices.Contains(refs, create) {
+		t.Errorf("missing %+v in %+v", create, refs)
+	}
+	if n := len(slices.Compact(slices.Clone(refs))); n != len(refs) {
+		t.Errorf("%d duplicate references in %+v", len(refs)-n, refs)
+	}
+	for _, ref := range refs {
+		if ref.TargetFile == "" || ref.File == "" {
+			t.Errorf("reference outside root: %+v", ref)
+		}
+	}
+}
+
+func TestRunWithoutModule(t *testing.T) {
+	var out, warnings bytes.Buffer
+	if err := run(t.TempDir(), &out, &warnings); err == nil {
+		t.Error("expected an error without a go.mod")
+	}
+}
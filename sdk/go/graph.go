@@ -0,0 +1,223 @@
+// Package graph reads and queries CodePrysm code graphs.
+//
+// A Graph holds the nodes (code entities) and edges (relationships) that
+// `codeprysm init` extracts from a repository. Load one from a JSON export
+// with [Load] or [ReadJSON], from an index directory with the sqlite
+// subpackage, or query a running `codeprysm serve` with the client
+// subpackage. Traversal methods return iterators:
+//
+//	g, err := graph.Load("graph.json")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	for caller := range g.Callers("src/server.go:Server:Handle") {
+//		fmt.Println(caller.ID, caller.File, caller.Line)
+//	}
+package graph
+
+import "fmt"
+
+// NodeType is the coarse classification of a node.
+type NodeType string
+
+// Node types.
+const (
+	// Container nodes hold other nodes: repositories, files, types, modules.
+	Container NodeType = "Container"
+	// Callable nodes are functions, methods, and constructors.
+	Callable NodeType = "Callable"
+	// Data nodes are fields, constants, variables, and parameters.
+	Data NodeType = "Data"
+)
+
+// EdgeType is the kind of relationship an edge represents.
+type EdgeType string
+
+// Edge types.
+const (
+	// Contains links a container to a node inside it.
+	Contains EdgeType = "CONTAINS"
+	// Uses links a node to a node it references, such as a call.
+	Uses EdgeType = "USES"
+	// Defines links a container to a member it defines.
+	Defines EdgeType = "DEFINES"
+	// DependsOn links a component to a component it depends on.
+	DependsOn EdgeType = "DEPENDS_ON"
+	// CloneOf links a callable to a near-duplicate callable.
+	CloneOf EdgeType = "CLONE_OF"
+	// DefinedIn links a symbol to the file it is declared in.
+	DefinedIn EdgeType = "DEFINED_IN"
+	// CrossLanguage links an entity to one in another language that it uses
+	// or was generated from (cgo calls, protobuf-generated Go code).
+	CrossLanguage EdgeType = "CROSS_LANGUAGE"
+	// RefersByName weakly links code to a symbol or route handler that a
+	// string literal in it names (registry keys, subtests, route paths).
+	RefersByName EdgeType = "REFERS_BY_NAME"
+	// EquivalentTo links a Go interface to one in another package with the
+	// same method set.
+	EquivalentTo EdgeType = "EQUIVALENT_TO"
+	// SubsetOf links a Go interface to one in another package whose method
+	// set strictly contains its own.
+	SubsetOf EdgeType = "SUBSET_OF"
+)
+
+// Node is a code entity.
+type Node struct {
+	// ID is the hierarchical node ID (e.g. "src/server.go:Server:Handle").
+	ID string `json:"id"`
+	// Name is the entity name.
+	Name string `json:"name"`
+	// Type is the node type.
+	Type NodeType `json:"type"`
+	// Kind is the kind within the node type (e.g. "function", "type",
+	// "field", "file"); empty if unknown.
+	Kind string `json:"kind,omitempty"`
+	// Subtype is the language-specific subtype (e.g. "struct",
+	// "interface"); empty if unknown.
+	Subtype string `json:"subtype,omitempty"`
+	// File is the source path relative to the repository root.
+	File string `json:"file"`
+	// Line is the start line (1-indexed).
+	Line int `json:"line"`
+	// EndLine is the end line (1-indexed).
+	EndLine int `json:"end_line"`
+	// Text is the source text, if it was stored.
+	Text string `json:"text,omitempty"`
+	// Hash is the content hash of file nodes.
+	Hash string `json:"hash,omitempty"`
+	// Metadata holds semantic metadata.
+	Metadata Metadata `json:"metadata,omitzero"`
+}
+
+// Metadata is semantic metadata attached to a node. Unset fields are nil.
+type Metadata struct {
+	Visibility  *string           `json:"visibility,omitempty"`
+	Async       *bool             `json:"async,omitempty"`
+	Static      *bool             `json:"static,omitempty"`
+	Abstract    *bool             `json:"abstract,omitempty"`
+	Virtual     *bool             `json:"virtual,omitempty"`
+	Decorators  []string          `json:"decorators,omitempty"`
+	Modifiers   []string          `json:"modifiers,omitempty"`
+	Scope       *string           `json:"scope,omitempty"`
+	Receiver    *string           `json:"receiver,omitempty"`
+	Owners      []string          `json:"owners,omitempty"`
+	Author      *string           `json:"author,omitempty"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+	Summary     *string           `json:"summary,omitempty"`
+	EntryPoint  *string           `json:"entry_point,omitempty"`
+	Cyclomatic  *uint32           `json:"cyclomatic_complexity,omitempty"`
+	Cognitive   *uint32           `json:"cognitive_complexity,omitempty"`
+	ControlFlow *ControlFlow      `json:"control_flow,omitempty"`
+	TokenCount  *uint32           `json:"token_count,omitempty"`
+	Covered     *uint32           `json:"covered_statements,omitempty"`
+	Statements  *uint32           `json:"total_statements,omitempty"`
+	Signatures  *uint32           `json:"signature_uses,omitempty"`
+	FieldUses   *uint32           `json:"field_uses,omitempty"`
+	Instances   *uint32           `json:"instantiations,omitempty"`
+	Weight      *uint32           `json:"weight,omitempty"`
+	Resolved    *uint32           `json:"resolved_refs,omitempty"`
+	Unresolved  *uint32           `json:"unresolved_refs,omitempty"`
+	GitRemote   *string           `json:"git_remote,omitempty"`
+	GitBranch   *string           `json:"git_branch,omitempty"`
+	GitCommit   *string           `json:"git_commit,omitempty"`
+	Root        *bool             `json:"is_workspace_root,omitempty"`
+	Publishable *bool             `json:"is_publishable,omitempty"`
+	Manifest    *string           `json:"manifest_path,omitempty"`
+	Language    *string           `json:"language,omitempty"`
+	Size        *uint64           `json:"size_bytes,omitempty"`
+	Generated   *bool             `json:"generated,omitempty"`
+	Test        *bool             `json:"test,omitempty"`
+	License     *string           `json:"license,omitempty"`
+	Copyright   *string           `json:"copyright,omitempty"`
+}
+
+// ControlFlow summarizes the control structures of a callable's body.
+type ControlFlow struct {
+	// Branches counts if/else-if branches, ternaries, and non-default cases.
+	Branches uint32 `json:"branches"`
+	// Loops counts loops.
+	Loops uint32 `json:"loops"`
+	// EarlyReturns counts returns other than the body's last statement.
+	EarlyReturns uint32 `json:"early_returns"`
+	// MaxNesting is the deepest nesting of control structures.
+	MaxNesting uint32 `json:"max_nesting"`
+	// HasSwitch reports a switch or match in the body.
+	HasSwitch bool `json:"has_switch"`
+	// HasSelect reports a Go select in the body.
+	HasSelect bool `json:"has_select"`
+}
+
+// Edge is a relationship between two nodes.
+type Edge struct {
+	// Source is the ID of the node the edge starts at.
+	Source string `json:"source"`
+	// Target is the ID of the node the edge ends at.
+	Target string `json:"target"`
+	// Type is the relationship type.
+	Type EdgeType `json:"type"`
+	// RefLine is the line of the reference, for USES edges (0 if unknown).
+	RefLine int `json:"ref_line,omitempty"`
+	// Ident is the identifier at the reference site, for USES and
+	// DEPENDS_ON edges.
+	Ident string `json:"ident,omitempty"`
+	// VersionSpec is the version requirement of DEPENDS_ON edges.
+	VersionSpec string `json:"version_spec,omitempty"`
+	// Dev reports whether a DEPENDS_ON edge is a development dependency.
+	Dev bool `json:"is_dev_dependency,omitempty"`
+	// Similarity is the percentage similarity of CLONE_OF edges.
+	Similarity int `json:"similarity,omitempty"`
+	// Weight is the number of type uses behind USES edges to types and
+	// DEPENDS_ON edges.
+	Weight int `json:"weight,omitempty"`
+}
+
+// IsFile reports whether n is a source file.
+func (n *Node) IsFile() bool { return n.Type == Container && n.Kind == "file" }
+
+// IsCallable reports whether n is a function, method, or constructor.
+func (n *Node) IsCallable() bool { return n.Type == Callable }
+
+// Graph is an in-memory code graph. It is not safe for concurrent
+// modification, but concurrent reads are fine.
+type Graph struct {
+	nodes    map[string]*Node
+	edges    []*Edge
+	outgoing map[string][]*Edge
+	incoming map[string][]*Edge
+}
+
+// New returns an empty graph.
+func New() *Graph {
+	return &Graph{
+		nodes:    make(map[string]*Node),
+		outgoing: make(map[string][]*Edge),
+		incoming: make(map[string][]*Edge),
+	}
+}
+
+// AddNode adds n, replacing any node with the same ID.
+func (g *Graph) AddNode(n *Node) {
+	g.nodes[n.ID] = n
+}
+
+// AddEdge adds e. Both ends must already be in the graph.
+func (g *Graph) AddEdge(e *Edge) error {
+	for _, id := range []string{e.Source, e.Target} {
+		if _, ok := g.nodes[id]; !ok {
+			return fmt.Errorf("edge %s -> %s: node %q not found", e.Source, e.Target, id)
+		}
+	}
+	g.edges = append(g.edges, e)
+	g.outgoing[e.Source] = append(g.outgoing[e.Source], e)
+	g.incoming[e.Target] = append(g.incoming[e.Target], e)
+	return nil
+}
+
+// Node returns the node with the given ID, or nil if there is none.
+func (g *Graph) Node(id string) *Node { return g.nodes[id] }
+
+// NodeCount returns the number of nodes.
+func (g *Graph) NodeCount() int { return len(g.nodes) }
+
+// EdgeCount returns the number of edges.
+func (g *Graph) EdgeCount() int { return len(g.edges) }
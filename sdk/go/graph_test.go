@@ -0,0 +1,205 @@
+package graph
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"iter"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const sample = `{
+  "nodes": [
+    {"id": "repo", "name": "repo", "type": "Container", "kind": "repository", "file": "", "line": 0, "end_line": 0},
+    {"id": "api/server.go", "name": "api/server.go", "type": "Container", "kind": "file", "file": "api/server.go", "line": 1, "end_line": 40, "hash": "abc"},
+    {"id": "api/server.go:Server", "name": "Server", "type": "Container", "kind": "type", "subtype": "struct", "file": "api/server.go", "line": 3, "end_line": 6},
+    {"id": "api/server.go:Server:Handle", "name": "Handle", "type": "Callable", "kind": "method", "file": "api/server.go", "line": 8, "end_line": 20,
+     "metadata": {"visibility": "public", "receiver": "Server", "cyclomatic_complexity": 4,
+       "control_flow": {"branches": 2, "loops": 1, "early_returns": 1, "max_nesting": 2, "has_switch": false, "has_select": true}}},
+    {"id": "api/server.go:parse", "name": "parse", "type": "Callable", "kind": "function", "file": "api/server.go", "line": 22, "end_line": 30},
+    {"id": "main.go:main", "name": "main", "type": "Callable", "kind": "function", "file": "main.go", "line": 5, "end_line": 9}
+  ],
+  "edges": [
+    {"source": "repo", "target": "api/server.go", "type": "CONTAINS"},
+    {"source": "api/server.go", "target": "api/server.go:Server", "type": "CONTAINS"},
+    {"source": "api/server.go:Server", "target": "api/server.go:Server:Handle", "type": "CONTAINS"},
+    {"source": "api/server.go", "target": "api/server.go:parse", "type": "CONTAINS"},
+    {"source": "api/server.go:Server", "target": "api/server.go", "type": "DEFINED_IN"},
+    {"source": "api/server.go:Server:Handle", "target": "api/server.go", "type": "DEFINED_IN"},
+    {"source": "api/server.go:parse", "target": "api/server.go", "type": "DEFINED_IN"},
+    {"source": "api/server.go:Server:Handle", "target": "api/server.go:parse", "type": "USES", "ref_line": 12, "ident": "parse"},
+    {"source": "main.go:main", "target": "api/server.go:Server:Handle", "type": "USES", "ref_line": 7, "ident": "Handle"},
+    {"source": "main.go:main", "target": "fmt.Println", "type": "USES"}
+  ]
+}`
+
+func loadSample(t *testing.T) *Graph {
+	t.Helper()
+	g, err := ReadJSON(strings.NewReader(sample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func ids(seq iter.Seq[*Node]) []string {
+	var out []string
+	for n := range seq {
+		out = append(out, n.ID)
+	}
+	return out
+}
+
+func TestReadJSON(t *testing.T) {
+	g := loadSample(t)
+	if g.NodeCount() != 6 {
+		t.Errorf("NodeCount = %d, want 6", g.NodeCount())
+	}
+	// The edge to fmt.Println has no target node
+	if g.EdgeCount() != 9 {
+		t.Errorf("EdgeCount = %d, want 9", g.EdgeCount())
+	}
+
+	handle := g.Node("api/server.go:Server:Handle")
+	if handle == nil || !handle.IsCallable() {
+		t.Fatalf("Handle = %+v", handle)
+	}
+	if *handle.Metadata.Receiver != "Server" || *handle.Metadata.Cyclomatic != 4 {
+		t.Errorf("Metadata = %+v", handle.Metadata)
+	}
+	if cf := handle.Metadata.ControlFlow; cf == nil || cf.Loops != 1 || !cf.HasSelect {
+		t.Errorf("ControlFlow = %+v", cf)
+	}
+	if !g.Node("api/server.go").IsFile() {
+		t.Error("api/server.go is not a file")
+	}
+}
+
+func TestLoadGzip(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(sample))
+	zw.Close()
+
+	path := filepath.Join(t.TempDir(), "graph.json.gz")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	g, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.NodeCount() != 6 {
+		t.Errorf("NodeCount = %d, want 6", g.NodeCount())
+	}
+
+	if _, err := Load(t.TempDir()); err == nil {
+		t.Error("Load of a directory succeeded")
+	}
+}
+
+func TestLoadUnsupportedFormat(t *testing.T) {
+	dir := t.TempDir()
+	for name, magic := range map[string]string{
+		"graph.cpg": "CPRYSMG\x00",
+		"graph.cpz": "CPRYSMZ\x00",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(magic+"\x01\x00\x00\x00"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := Load(path)
+		if !errors.Is(err, ErrUnsupportedFormat) {
+			t.Errorf("Load(%s) error = %v, want ErrUnsupportedFormat", name, err)
+		}
+	}
+}
+
+func TestWriteJSONRoundTrip(t *testing.T) {
+	g := loadSample(t)
+	var buf bytes.Buffer
+	if err := g.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	again, err := ReadJSON(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.NodeCount() != g.NodeCount() || again.EdgeCount() != g.EdgeCount() {
+		t.Errorf("round trip: %d nodes, %d edges", again.NodeCount(), again.EdgeCount())
+	}
+	if again.Node("api/server.go:Server:Handle").Metadata.Visibility == nil {
+		t.Error("metadata lost in round trip")
+	}
+}
+
+func TestTraversal(t *testing.T) {
+	g := loadSample(t)
+
+	if got := ids(g.Children("api/server.go")); !slices.Equal(got, []string{"api/server.go:Server", "api/server.go:parse"}) {
+		t.Errorf("Children = %v", got)
+	}
+	if got := ids(g.FileSymbols("api/server.go")); len(got) != 3 {
+		t.Errorf("FileSymbols = %v", got)
+	}
+	if got := g.Parent("api/server.go:Server:Handle"); got == nil || got.ID != "api/server.go:Server" {
+		t.Errorf("Parent = %v", got)
+	}
+	if got := ids(g.Callers("api/server.go:parse")); !slices.Equal(got, []string{"api/server.go:Server:Handle"}) {
+		t.Errorf("Callers = %v", got)
+	}
+	if got := ids(g.Callees("main.go:main")); !slices.Equal(got, []string{"api/server.go:Server:Handle"}) {
+		t.Errorf("Callees = %v", got)
+	}
+	if got := ids(g.Find("Handle")); !slices.Equal(got, []string{"api/server.go:Server:Handle"}) {
+		t.Errorf("Find = %v", got)
+	}
+	if got := ids(g.Find("Server:Handle")); len(got) != 1 {
+		t.Errorf("Find by suffix = %v", got)
+	}
+
+	nodes := ids(g.Nodes())
+	if !slices.IsSorted(nodes) || len(nodes) != 6 {
+		t.Errorf("Nodes = %v", nodes)
+	}
+}
+
+func TestWalk(t *testing.T) {
+	g := loadSample(t)
+
+	depths := make(map[string]int)
+	for n, depth := range g.Walk("main.go:main", WalkOptions{Direction: Outgoing, EdgeTypes: []EdgeType{Uses}}) {
+		depths[n.ID] = depth
+	}
+	want := map[string]int{"main.go:main": 0, "api/server.go:Server:Handle": 1, "api/server.go:parse": 2}
+	if len(depths) != len(want) {
+		t.Fatalf("Walk = %v", depths)
+	}
+	for id, depth := range want {
+		if depths[id] != depth {
+			t.Errorf("depth of %s = %d, want %d", id, depths[id], depth)
+		}
+	}
+
+	var bounded []string
+	for n := range g.Walk("repo", WalkOptions{Direction: Outgoing, MaxDepth: 1}) {
+		bounded = append(bounded, n.ID)
+	}
+	if !slices.Equal(bounded, []string{"repo", "api/server.go"}) {
+		t.Errorf("bounded Walk = %v", bounded)
+	}
+
+	// Stopping early is honored
+	count := 0
+	for range g.Walk("repo", WalkOptions{Direction: Both}) {
+		count++
+		break
+	}
+	if count != 1 {
+		t.Errorf("visited %d nodes after break", count)
+	}
+}
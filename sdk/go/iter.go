@@ -0,0 +1,189 @@
+package graph
+
+import (
+	"iter"
+	"slices"
+	"strings"
+)
+
+// Direction selects which edges of a node to follow.
+type Direction int
+
+// Directions.
+const (
+	// Both follows edges in either direction.
+	Both Direction = iota
+	// Outgoing follows edges out of a node (what it uses, contains, ...).
+	Outgoing
+	// Incoming follows edges into a node (what uses or contains it).
+	Incoming
+)
+
+// Nodes returns the nodes in ID order.
+func (g *Graph) Nodes() iter.Seq[*Node] {
+	return func(yield func(*Node) bool) {
+		ids := make([]string, 0, len(g.nodes))
+		for id := range g.nodes {
+			ids = append(ids, id)
+		}
+		slices.Sort(ids)
+		for _, id := range ids {
+			if !yield(g.nodes[id]) {
+				return
+			}
+		}
+	}
+}
+
+// Edges returns the edges in the order they were added.
+func (g *Graph) Edges() iter.Seq[*Edge] {
+	return slices.Values(g.edges)
+}
+
+// OutgoingEdges returns the edges out of the node with the given ID.
+func (g *Graph) OutgoingEdges(id string) iter.Seq[*Edge] {
+	return slices.Values(g.outgoing[id])
+}
+
+// IncomingEdges returns the edges into the node with the given ID.
+func (g *Graph) IncomingEdges(id string) iter.Seq[*Edge] {
+	return slices.Values(g.incoming[id])
+}
+
+// Neighbors returns the edges of the node with the given ID in direction d,
+// each with the node at its other end. Only edges of the given types are
+// returned, or all edges when none are given.
+func (g *Graph) Neighbors(id string, d Direction, types ...EdgeType) iter.Seq2[*Edge, *Node] {
+	return func(yield func(*Edge, *Node) bool) {
+		if d != Incoming {
+			for _, e := range g.outgoing[id] {
+				if matches(e, types) && !yield(e, g.nodes[e.Target]) {
+					return
+				}
+			}
+		}
+		if d != Outgoing {
+			for _, e := range g.incoming[id] {
+				if matches(e, types) && !yield(e, g.nodes[e.Source]) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Children returns the nodes the node with the given ID contains directly.
+func (g *Graph) Children(id string) iter.Seq[*Node] {
+	return nodes(g.Neighbors(id, Outgoing, Contains))
+}
+
+// Parent returns the container of the node with the given ID, or nil.
+func (g *Graph) Parent(id string) *Node {
+	for _, n := range g.Neighbors(id, Incoming, Contains) {
+		return n
+	}
+	return nil
+}
+
+// FileSymbols returns every symbol declared in the file with the given ID,
+// at any depth.
+func (g *Graph) FileSymbols(id string) iter.Seq[*Node] {
+	return nodes(g.Neighbors(id, Incoming, DefinedIn))
+}
+
+// Callers returns the callables that use the node with the given ID, once
+// each.
+func (g *Graph) Callers(id string) iter.Seq[*Node] {
+	return callables(g.Neighbors(id, Incoming, Uses))
+}
+
+// Callees returns the callables the node with the given ID uses, once each.
+func (g *Graph) Callees(id string) iter.Seq[*Node] {
+	return callables(g.Neighbors(id, Outgoing, Uses))
+}
+
+// Find returns the nodes whose ID equals query, whose name equals query, or
+// whose ID ends with ":" followed by query, in ID order.
+func (g *Graph) Find(query string) iter.Seq[*Node] {
+	return func(yield func(*Node) bool) {
+		for n := range g.Nodes() {
+			if n.ID == query || n.Name == query || strings.HasSuffix(n.ID, ":"+query) {
+				if !yield(n) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// WalkOptions bound a [Graph.Walk].
+type WalkOptions struct {
+	// Direction of the edges to follow.
+	Direction Direction
+	// EdgeTypes to follow; nil follows all.
+	EdgeTypes []EdgeType
+	// MaxDepth is the maximum number of hops from the start; 0 is
+	// unlimited.
+	MaxDepth int
+}
+
+// Walk visits the nodes reachable from the node with the given ID breadth
+// first, yielding each once with its distance in hops. The start node is
+// yielded first at depth 0.
+func (g *Graph) Walk(id string, opts WalkOptions) iter.Seq2[*Node, int] {
+	return func(yield func(*Node, int) bool) {
+		start := g.nodes[id]
+		if start == nil {
+			return
+		}
+		seen := map[string]bool{id: true}
+		frontier := []*Node{start}
+		for depth := 0; len(frontier) > 0; depth++ {
+			var next []*Node
+			for _, n := range frontier {
+				if !yield(n, depth) {
+					return
+				}
+				if opts.MaxDepth > 0 && depth >= opts.MaxDepth {
+					continue
+				}
+				for _, m := range g.Neighbors(n.ID, opts.Direction, opts.EdgeTypes...) {
+					if m != nil && !seen[m.ID] {
+						seen[m.ID] = true
+						next = append(next, m)
+					}
+				}
+			}
+			frontier = next
+		}
+	}
+}
+
+func matches(e *Edge, types []EdgeType) bool {
+	return len(types) == 0 || slices.Contains(types, e.Type)
+}
+
+func nodes(seq iter.Seq2[*Edge, *Node]) iter.Seq[*Node] {
+	return func(yield func(*Node) bool) {
+		for _, n := range seq {
+			if !yield(n) {
+				return
+			}
+		}
+	}
+}
+
+func callables(seq iter.Seq2[*Edge, *Node]) iter.Seq[*Node] {
+	return func(yield func(*Node) bool) {
+		seen := make(map[string]bool)
+		for _, n := range seq {
+			if n == nil || !n.IsCallable() || seen[n.ID] {
+				continue
+			}
+			seen[n.ID] = true
+			if !yield(n) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package graph
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ErrUnsupportedFormat is returned for CodePrysm graph files the SDK cannot
+// read: the memory-mapped graph store (graph.cpg) and .cpz archives.
+var ErrUnsupportedFormat = errors.New("unsupported graph format")
+
+// unsupportedMagic maps the magic bytes of unsupported files to their format.
+var unsupportedMagic = map[string]string{
+	"CPRYSMG\x00": "memory-mapped graph store (.cpg)",
+	"CPRYSMZ\x00": "graph archive (.cpz)",
+}
+
+// document is the JSON export format: {"nodes": [...], "edges": [...]}.
+type document struct {
+	Nodes []*Node `json:"nodes"`
+	Edges []*Edge `json:"edges"`
+}
+
+// Load reads a graph in the JSON export format, as written by
+// `codeprysm subgraph --format json`, the wasm buildGraph function, or
+// [Graph.WriteJSON]. Gzip-compressed files are decompressed transparently.
+//
+// To read an index directory (.codeprysm) instead, use the sqlite
+// subpackage.
+func Load(path string) (*Graph, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory; open index directories with the sqlite package", path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	g, err := ReadJSON(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return g, nil
+}
+
+// ReadJSON reads a graph in the JSON export format from r, which may be
+// gzip-compressed. Memory-mapped graph stores and .cpz archives are
+// rejected with [ErrUnsupportedFormat].
+//
+// Edges whose ends are not among the nodes are dropped, as when the export
+// was a bounded slice of a larger graph.
+func ReadJSON(r io.Reader) (*Graph, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(8); len(magic) == 8 {
+		if format, ok := unsupportedMagic[string(magic)]; ok {
+			return nil, fmt.Errorf("%w: %s; export JSON with codeprysm subgraph or open the index with the sqlite package", ErrUnsupportedFormat, format)
+		}
+	}
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	} else {
+		r = br
+	}
+
+	var doc document
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decode graph: %w", err)
+	}
+
+	g := New()
+	for _, n := range doc.Nodes {
+		g.AddNode(n)
+	}
+	for _, e := range doc.Edges {
+		// Dangling edges are expected in slices; skip them
+		_ = g.AddEdge(e)
+	}
+	return g, nil
+}
+
+// WriteJSON writes g in the JSON export format, with nodes in ID order.
+func (g *Graph) WriteJSON(w io.Writer) error {
+	doc := document{Nodes: make([]*Node, 0, len(g.nodes)), Edges: g.edges}
+	for n := range g.Nodes() {
+		doc.Nodes = append(doc.Nodes, n)
+	}
+	if doc.Edges == nil {
+		doc.Edges = []*Edge{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
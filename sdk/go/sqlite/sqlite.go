@@ -0,0 +1,221 @@
+// Package sqlite loads code graphs from CodePrysm index directories.
+//
+// `codeprysm init` stores the graph as partitioned SQLite databases under
+// .codeprysm: a manifest.json, one partitions/<id>.db per directory, and
+// cross_refs.db with the edges between partitions. Open reads all of them
+// into a single graph.Graph:
+//
+//	g, err := sqlite.Open("/path/to/repo/.codeprysm")
+//
+// The databases are opened read-only, so this is safe while `codeprysm
+// watch` or `codeprysm update` is running. The driver is
+// github.com/mattn/go-sqlite3, which needs cgo.
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"go.codeprysm.dev/graph"
+)
+
+// manifest is the subset of manifest.json needed to find the partitions.
+type manifest struct {
+	SchemaVersion string            `json:"schema_version"`
+	Partitions    map[string]string `json:"partitions"`
+}
+
+// Open loads the graph stored in the index directory dir. dir may also be a
+// repository root containing a .codeprysm directory.
+func Open(dir string) (*graph.Graph, error) {
+	if _, err := os.Stat(filepath.Join(dir, "manifest.json")); errors.Is(err, os.ErrNotExist) {
+		if _, err := os.Stat(filepath.Join(dir, ".codeprysm", "manifest.json")); err == nil {
+			dir = filepath.Join(dir, ".codeprysm")
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "manifest.json"))
+	if err != nil {
+		return nil, fmt.Errorf("read manifest (run `codeprysm init` first?): %w", err)
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parse manifest: %w", err)
+	}
+
+	ids := make([]string, 0, len(m.Partitions))
+	for id := range m.Partitions {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	g := graph.New()
+	var edges []*graph.Edge
+	for _, id := range ids {
+		path := filepath.Join(dir, "partitions", partitionFile(id))
+		partitionEdges, err := readPartition(g, path)
+		if err != nil {
+			return nil, fmt.Errorf("partition %s: %w", id, err)
+		}
+		edges = append(edges, partitionEdges...)
+	}
+
+	crossRefs := filepath.Join(dir, "cross_refs.db")
+	if _, err := os.Stat(crossRefs); err == nil {
+		crossEdges, err := readEdges(crossRefs, "cross_refs", "source_id", "target_id")
+		if err != nil {
+			return nil, fmt.Errorf("cross_refs.db: %w", err)
+		}
+		edges = append(edges, crossEdges...)
+	}
+
+	// Edges are added once every node is present so no endpoint is missed
+	for _, e := range edges {
+		// Edges to nodes removed since the last full build are skipped
+		_ = g.AddEdge(e)
+	}
+	return g, nil
+}
+
+// partitionFile is the database file name of a partition, as written by
+// codeprysm.
+func partitionFile(id string) string {
+	return strings.NewReplacer("/", "_", `\`, "_", ":", "_").Replace(id) + ".db"
+}
+
+func openReadOnly(path string) (*sql.DB, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	u := url.URL{Scheme: "file", Path: path, RawQuery: "mode=ro"}
+	return sql.Open("sqlite3", u.String())
+}
+
+// readPartition adds a partition's nodes to g and returns its edges.
+func readPartition(g *graph.Graph, path string) ([]*graph.Edge, error) {
+	db, err := openReadOnly(path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT id, name, node_type, kind, subtype, file, line, end_line,
+		text, hash, metadata_json FROM nodes`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			n                                      graph.Node
+			nodeType                               string
+			kind, subtype, text, hash, metadataRaw sql.NullString
+		)
+		if err := rows.Scan(&n.ID, &n.Name, &nodeType, &kind, &subtype, &n.File,
+			&n.Line, &n.EndLine, &text, &hash, &metadataRaw); err != nil {
+			return nil, err
+		}
+		n.Type = graph.NodeType(nodeType)
+		n.Kind, n.Subtype, n.Text, n.Hash = kind.String, subtype.String, text.String, hash.String
+		// Legacy file nodes
+		if nodeType == "FILE" {
+			n.Type, n.Kind = graph.Container, "file"
+		}
+		if metadataRaw.Valid {
+			// Unreadable metadata is dropped, as codeprysm does
+			_ = json.Unmarshal([]byte(metadataRaw.String), &n.Metadata)
+		}
+		g.AddNode(&n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return readEdgesFrom(db, "edges", "source", "target")
+}
+
+func readEdges(path, table, source, target string) ([]*graph.Edge, error) {
+	db, err := openReadOnly(path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	return readEdgesFrom(db, table, source, target)
+}
+
+// readEdgesFrom reads the edges in table. Columns added in later schema
+// versions read as unset when missing.
+func readEdgesFrom(db *sql.DB, table, source, target string) ([]*graph.Edge, error) {
+	present, err := columns(db, table)
+	if err != nil {
+		return nil, err
+	}
+	selected := []string{source, target, "edge_type", "ref_line", "ident"}
+	for _, column := range []string{"version_spec", "is_dev_dependency", "similarity", "weight"} {
+		if present[column] {
+			selected = append(selected, column)
+		} else {
+			selected = append(selected, "NULL")
+		}
+	}
+
+	rows, err := db.Query(fmt.Sprintf("SELECT %s FROM %s", strings.Join(selected, ", "), table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var edges []*graph.Edge
+	for rows.Next() {
+		var (
+			e                   graph.Edge
+			edgeType            string
+			ident, versionSpec  sql.NullString
+			refLine, similarity sql.NullInt64
+			weight              sql.NullInt64
+			dev                 sql.NullBool
+		)
+		if err := rows.Scan(&e.Source, &e.Target, &edgeType, &refLine, &ident,
+			&versionSpec, &dev, &similarity, &weight); err != nil {
+			return nil, err
+		}
+		e.Type = graph.EdgeType(edgeType)
+		e.RefLine, e.Ident = int(refLine.Int64), ident.String
+		e.VersionSpec, e.Dev, e.Similarity = versionSpec.String, dev.Bool, int(similarity.Int64)
+		e.Weight = int(weight.Int64)
+		edges = append(edges, &e)
+	}
+	return edges, rows.Err()
+}
+
+// columns returns the set of column names of table.
+func columns(db *sql.DB, table string) (map[string]bool, error) {
+	rows, err := db.Query(fmt.Sprintf("SELECT name FROM pragma_table_info('%s')", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names[name] = true
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no table %s", table)
+	}
+	return names, rows.Err()
+}
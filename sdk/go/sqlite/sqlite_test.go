@@ -0,0 +1,115 @@
+package sqlite
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.codeprysm.dev/graph"
+)
+
+const schema = `
+CREATE TABLE nodes (
+    id TEXT PRIMARY KEY NOT NULL, name TEXT NOT NULL, node_type TEXT NOT NULL,
+    kind TEXT, subtype TEXT, file TEXT NOT NULL, line INTEGER NOT NULL,
+    end_line INTEGER NOT NULL, text TEXT, hash TEXT, metadata_json TEXT
+);
+CREATE TABLE edges (
+    id INTEGER PRIMARY KEY AUTOINCREMENT, source TEXT NOT NULL, target TEXT NOT NULL,
+    edge_type TEXT NOT NULL, ref_line INTEGER, ident TEXT, version_spec TEXT,
+    is_dev_dependency INTEGER, similarity INTEGER
+);`
+
+// Cross-refs in the v1.0 schema, before the dependency and clone columns
+const crossRefsSchema = `
+CREATE TABLE cross_refs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT, source_id TEXT NOT NULL,
+    source_partition TEXT NOT NULL, target_id TEXT NOT NULL,
+    target_partition TEXT NOT NULL, edge_type TEXT NOT NULL, ref_line INTEGER, ident TEXT
+);`
+
+func exec(t *testing.T, path string, statements ...string) {
+	t.Helper()
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, s := range statements {
+		if _, err := db.Exec(s); err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+	}
+}
+
+func writeIndex(t *testing.T) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), ".codeprysm")
+	if err := os.MkdirAll(filepath.Join(dir, "partitions"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	manifest := `{"schema_version": "1.0", "files": {},
+		"partitions": {"repo:api": "repo_api.db", "repo:cmd": "repo_cmd.db"}}`
+	if err := os.WriteFile(filepath.Join(dir, "manifest.json"), []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	exec(t, filepath.Join(dir, "partitions", "repo_api.db"), schema,
+		`INSERT INTO nodes VALUES ('api/server.go', 'api/server.go', 'FILE', NULL, NULL, 'api/server.go', 1, 30, NULL, 'abc', NULL)`,
+		`INSERT INTO nodes VALUES ('api/server.go:Handle', 'Handle', 'Callable', 'function', NULL, 'api/server.go', 3, 12, NULL, NULL,
+			'{"visibility": "public", "cyclomatic_complexity": 3}')`,
+		`INSERT INTO edges (source, target, edge_type) VALUES ('api/server.go', 'api/server.go:Handle', 'CONTAINS')`,
+	)
+	exec(t, filepath.Join(dir, "partitions", "repo_cmd.db"), schema,
+		`INSERT INTO nodes VALUES ('cmd/main.go:main', 'main', 'Callable', 'function', NULL, 'cmd/main.go', 5, 9, NULL, NULL, NULL)`,
+	)
+	exec(t, filepath.Join(dir, "cross_refs.db"), crossRefsSchema,
+		`INSERT INTO cross_refs (source_id, source_partition, target_id, target_partition, edge_type, ref_line, ident)
+			VALUES ('cmd/main.go:main', 'repo:cmd', 'api/server.go:Handle', 'repo:api', 'USES', 7, 'Handle')`,
+	)
+	return dir
+}
+
+func TestOpen(t *testing.T) {
+	dir := writeIndex(t)
+
+	// Both the index directory and the repository root work
+	for _, path := range []string{dir, filepath.Dir(dir)} {
+		g, err := Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if g.NodeCount() != 3 || g.EdgeCount() != 2 {
+			t.Fatalf("%s: %d nodes, %d edges", path, g.NodeCount(), g.EdgeCount())
+		}
+
+		file := g.Node("api/server.go")
+		if !file.IsFile() || file.Hash != "abc" {
+			t.Errorf("file node = %+v", file)
+		}
+		handle := g.Node("api/server.go:Handle")
+		if handle.Metadata.Cyclomatic == nil || *handle.Metadata.Cyclomatic != 3 {
+			t.Errorf("metadata = %+v", handle.Metadata)
+		}
+
+		var callers []string
+		for n := range g.Callers("api/server.go:Handle") {
+			callers = append(callers, n.ID)
+		}
+		if len(callers) != 1 || callers[0] != "cmd/main.go:main" {
+			t.Errorf("callers = %v", callers)
+		}
+		for e := range g.IncomingEdges("api/server.go:Handle") {
+			if e.Type == graph.Uses && (e.RefLine != 7 || e.Ident != "Handle") {
+				t.Errorf("cross-partition edge = %+v", e)
+			}
+		}
+	}
+}
+
+func TestOpenMissing(t *testing.T) {
+	if _, err := Open(t.TempDir()); err == nil {
+		t.Error("Open of a directory without an index succeeded")
+	}
+}